@@ -0,0 +1,132 @@
+// Package audit implements the commands that read the local audit log of
+// mutating homeops-cli invocations.
+package audit
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"homeops-cli/internal/audit"
+	"homeops-cli/internal/config"
+	"homeops-cli/internal/ui"
+)
+
+var nowFn = time.Now
+
+// NewCommand creates the top-level audit command group.
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Inspect the log of mutating homeops-cli invocations",
+		Long: `Every mutating invocation appends one JSON line to audit.path (default
+~/.local/state/homeops/audit.log): when it ran, the OS user and host, the
+command with its flags (credential values masked), the cluster, how long it
+took, and whether it succeeded. Read-only commands and --dry-run/--plan runs
+are not recorded. Disable with audit.disabled in homeops.yaml or --no-audit.`,
+	}
+	cmd.AddCommand(newTailCommand())
+	return cmd
+}
+
+func newTailCommand() *cobra.Command {
+	var (
+		since  string
+		lines  int
+		output string
+	)
+	cmd := &cobra.Command{
+		Use:          "tail",
+		Short:        "Show the most recent audit log entries",
+		SilenceUsage: true,
+		Example: `  homeops-cli audit tail
+  homeops-cli audit tail --since 24h
+  homeops-cli audit tail --since 2026-10-01 -n 0 -o json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ui.ValidateOutputFormat(output); err != nil {
+				return err
+			}
+			if lines < 0 {
+				return fmt.Errorf("--lines must not be negative")
+			}
+			cutoff, err := parseSince(since, nowFn())
+			if err != nil {
+				return err
+			}
+			entries, err := audit.Read(config.Get().Audit.Path, cutoff)
+			if err != nil {
+				return err
+			}
+			if lines > 0 && len(entries) > lines {
+				entries = entries[len(entries)-lines:]
+			}
+			rendered, err := renderEntries(entries, output)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), rendered)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&since, "since", "", "only entries newer than a duration (24h) or at/after a date or RFC 3339 time")
+	cmd.Flags().IntVarP(&lines, "lines", "n", 20, "number of most recent entries to show (0 = all)")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table or json")
+	return cmd
+}
+
+// parseSince accepts a lookback duration, a YYYY-MM-DD date (local time), or
+// an RFC 3339 timestamp. Empty means no cutoff.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		if d < 0 {
+			return time.Time{}, fmt.Errorf("--since must not be negative")
+		}
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, now.Location()); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q: want a duration (24h), a date (2026-10-01), or an RFC 3339 time", value)
+}
+
+func renderEntries(entries []audit.Entry, output string) (string, error) {
+	if output == "json" {
+		if entries == nil {
+			entries = []audit.Entry{}
+		}
+		return ui.RenderJSON(entries)
+	}
+	if len(entries) == 0 {
+		return "No audit entries.", nil
+	}
+	rows := make([][]string, 0, len(entries))
+	for _, entry := range entries {
+		command := strings.TrimSpace(entry.Command + " " + strings.Join(entry.Args, " "))
+		result := entry.Result
+		if entry.Error != "" {
+			result += ": " + entry.Error
+		}
+		cluster := entry.Cluster
+		if entry.Context != "" {
+			cluster += " (" + entry.Context + ")"
+		}
+		rows = append(rows, []string{
+			entry.Time.Local().Format("2006-01-02 15:04:05"),
+			entry.User + "@" + entry.Host,
+			command,
+			cluster,
+			(time.Duration(entry.DurationMS) * time.Millisecond).String(),
+			result,
+		})
+	}
+	return ui.Table([]string{"TIME", "WHO", "COMMAND", "CLUSTER", "DURATION", "RESULT"}, rows), nil
+}
@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/audit"
+	"homeops-cli/internal/config"
+	"homeops-cli/internal/testutil"
+)
+
+func TestTailFiltersAndLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	cfg := &config.Config{}
+	cfg.Audit.Path = path
+	t.Cleanup(config.SetForTesting(cfg))
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	testutil.Swap(t, &nowFn, func() time.Time { return now })
+
+	for i, command := range []string{"homeops-cli talos apply-node", "homeops-cli k8s sync", "homeops-cli volsync restore"} {
+		require.NoError(t, audit.Append(path, audit.Entry{
+			Time: now.Add(time.Duration(i-2) * 24 * time.Hour), User: "ops", Host: "laptop",
+			Command: command, Cluster: "home", DurationMS: 1500, Result: audit.ResultOK,
+		}))
+	}
+
+	out, err := testutil.ExecuteCommand(NewCommand(), "tail", "--since", "36h", "-o", "json")
+	require.NoError(t, err)
+	var entries []audit.Entry
+	require.NoError(t, json.Unmarshal([]byte(out), &entries))
+	require.Len(t, entries, 2)
+	assert.Equal(t, "homeops-cli k8s sync", entries[0].Command)
+
+	out, err = testutil.ExecuteCommand(NewCommand(), "tail", "-n", "1")
+	require.NoError(t, err)
+	assert.Contains(t, out, "volsync restore")
+	assert.Contains(t, out, "ops@laptop")
+	assert.NotContains(t, out, "apply-node")
+
+	_, err = testutil.ExecuteCommand(NewCommand(), "tail", "--since", "yesterday")
+	assert.ErrorContains(t, err, "invalid --since")
+}
+
+func TestTailEmptyLog(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Audit.Path = filepath.Join(t.TempDir(), "missing.log")
+	t.Cleanup(config.SetForTesting(cfg))
+
+	out, err := testutil.ExecuteCommand(NewCommand(), "tail")
+	require.NoError(t, err)
+	assert.Contains(t, out, "No audit entries.")
+}
@@ -15,10 +15,13 @@ import (
 	"homeops-cli/internal/common"
 	versionconfig "homeops-cli/internal/config"
 	"homeops-cli/internal/constants"
+	"homeops-cli/internal/kube"
+	"homeops-cli/internal/metrics"
 	"homeops-cli/internal/secrets"
 	"homeops-cli/internal/state"
 	"homeops-cli/internal/templates"
 	"homeops-cli/internal/ui"
+	"homeops-cli/internal/versioncheck"
 
 	"github.com/spf13/cobra"
 )
@@ -38,7 +41,11 @@ type BootstrapConfig struct {
 	// only the post-CNI bootstrap (Cilium/helmfile/Flux) against an already-built
 	// control plane; the kubeconfig is still fetched from node0 (step 2).
 	SkipKubeadm bool
-	Verbose     bool
+	// SkipGatewayCheck turns off the post-Flux smoke test that requires a
+	// Programmed Gateway with an address whenever any Gateway exists. Also set
+	// by bootstrap.skip_gateway_check in the homeops config.
+	SkipGatewayCheck bool
+	Verbose          bool
 	// FreshPKI (flatcar provider) skips restoring the persisted cluster PKI from
 	// 1Password before `kubeadm init`, so kubeadm mints a NEW cluster CA. Default
 	// (false) reuses the persisted PKI for a stable identity across rebuilds.
@@ -51,7 +58,32 @@ type BootstrapConfig struct {
 	// confirmations, preflight checks, secret resolution, or infrastructure I/O.
 	Plan         bool
 	CheckSecrets bool
-	Output       string
+	// Check assesses the live cluster against each bootstrap step and prints
+	// ALREADY-DONE / WOULD-CHANGE / UNKNOWN per step without changing anything.
+	Check  bool
+	Output string
+	// Resume skips the steps the last run completed for the same inputs,
+	// and FromStep every step before the named one; preflight always runs.
+	Resume   bool
+	FromStep string
+	// Only runs just the named steps, in flow order; see resume.go.
+	Only []string
+	// MaxParallel (talos provider) is how many nodes get their machine
+	// config at once.
+	MaxParallel int
+	// CheckHypervisor adds the preflight check that every cluster node's VM
+	// exists and is running; see hypervisorPreflightProvider.
+	CheckHypervisor bool
+	// UnsafeSaveSecrets saves each rendered config's debug copy with its
+	// resolved secrets instead of redacted; see secrets.go.
+	UnsafeSaveSecrets bool
+	// ReportFile is where a JSON summary of the run is written when it
+	// ends, failed or not; see report.go.
+	ReportFile string
+	// report collects the ReportFile summary.
+	report *bootstrapReport
+	// progress persists per-step completion for Resume; see resume.go.
+	progress *bootstrapProgress
 }
 
 type PreflightResult struct {
@@ -62,31 +94,40 @@ type PreflightResult struct {
 }
 
 var (
-	bootstrapNow              = time.Now
-	bootstrapSleep            = time.Sleep
-	bootstrapChoose           = ui.Choose
-	bootstrapChooseMulti      = ui.ChooseMulti
-	bootstrapConfirm          = ui.Confirm
-	bootstrapInteractive      = ui.IsInteractive
-	runBootstrapFn            = runBootstrap
-	bootstrapRunWithSpinner   = ui.RunWithSpinner
-	bootstrapResetTerminal    = ui.ResetTerminal
-	bootstrapWorkingDirectory = common.GetWorkingDirectory
-	bootstrapGetVersions      = versionconfig.GetVersions
-	bootstrapLookPath         = exec.LookPath
-	bootstrapEnsureOPAuth     = secrets.EnsureOpAuth
-	bootstrapHTTPDo           = func(req *http.Request) (*http.Response, error) {
+	bootstrapNow            = time.Now
+	bootstrapSleep          = time.Sleep
+	bootstrapChoose         = ui.Choose
+	bootstrapChooseMulti    = ui.ChooseMulti
+	bootstrapConfirm        = ui.Confirm
+	bootstrapInteractive    = ui.IsInteractive
+	runBootstrapFn          = runBootstrap
+	bootstrapRunWithSpinner = ui.RunWithSpinner
+	bootstrapResetTerminal  = ui.ResetTerminal
+	bootstrapRepoRoot       = common.RepoRoot
+	// bootstrapWorkingDirectory is the --root-dir default; "" outside a
+	// checkout so RunE can report why.
+	bootstrapWorkingDirectory = func() string {
+		root, _ := bootstrapRepoRoot()
+		return root
+	}
+	bootstrapGetVersions   = versionconfig.GetVersions
+	bootstrapCheckVersions = versioncheck.Check
+	bootstrapLookPath      = exec.LookPath
+	bootstrapEnsureOPAuth  = secrets.EnsureOpAuth
+	bootstrapHTTPDo        = func(req *http.Request) (*http.Response, error) {
 		client := &http.Client{Timeout: 10 * time.Second}
 		return client.Do(req)
 	}
 	bootstrapLookupHost = func(ctx context.Context, host string) ([]string, error) {
 		return (&net.Resolver{}).LookupHost(ctx, host)
 	}
-	bootstrapKubectlRun        = kubectlRun
-	bootstrapKubectlOutput     = kubectlOutput
-	bootstrapKubectlCombined   = kubectlCombinedOutput
-	bootstrapKubectlCombinedIn = kubectlCombinedOutputWithInput
-	bootstrapTalosctlOutput    = func(talosConfig string, args ...string) ([]byte, error) {
+	bootstrapDialTimeout = net.DialTimeout
+	// bootstrapKubeClient connects to config's cluster; bootstrap needs no
+	// kubectl binary.
+	bootstrapKubeClient = func(config *BootstrapConfig) (*kube.Client, error) {
+		return kube.New(config.KubeConfig)
+	}
+	bootstrapTalosctlOutput = func(talosConfig string, args ...string) ([]byte, error) {
 		return buildTalosctlCmd(talosConfig, args...).Output()
 	}
 	bootstrapTalosctlCombined = func(talosConfig string, args ...string) ([]byte, error) {
@@ -111,6 +152,7 @@ var (
 	bootstrapPatchKubeconfig        = patchKubeconfigForBootstrap
 	bootstrapGetRandomController    = getRandomController
 	bootstrapRunPreflightChecks     = runPreflightChecks
+	bootstrapHypervisorVMs          = listHypervisorVMs
 	bootstrapValidatePrereqs        = validatePrerequisites
 	bootstrapApplyTalosConfig       = applyTalosConfig
 	bootstrapBootstrapTalos         = bootstrapTalos
@@ -122,12 +164,15 @@ var (
 	bootstrapCheckNodesReady        = checkIfNodesReady
 	bootstrapWaitNodesReadyFalse    = waitForNodesReadyFalse
 	bootstrapApplyNamespaces        = applyNamespaces
+	bootstrapApplyClusterSettings   = applyClusterSettings
+	bootstrapLoadClusterSettings    = templates.LoadClusterSettings
 	bootstrapApplyResources         = applyResources
 	bootstrapApplyCRDs              = applyCRDs
 	bootstrapApplyGatewayCRDs       = applyGatewayAPICRDs
 	bootstrapApplyCRDsHelmfile      = applyCRDsFromHelmfile
 	bootstrapSyncHelmReleases       = syncHelmReleases
 	bootstrapWaitForFlux            = waitForFluxReconciliation
+	bootstrapWaitGateways           = waitForGatewaysProgrammed
 	bootstrapWaitFluxController     = waitForFluxController
 	bootstrapWaitGitRepository      = waitForGitRepositoryReady
 	bootstrapWaitFluxKS             = waitForFluxKustomizationReady
@@ -153,7 +198,9 @@ var (
 	bootstrapRunHelmfileSyncCmd = func(tempDir, helmfilePath string, config *BootstrapConfig) error {
 		cmd := buildHelmfileCmd(tempDir, config, "--file", helmfilePath, "sync", "--hide-notes")
 		cmd.Stdout = bootstrapHelmfileStdout
-		cmd.Stderr = bootstrapHelmfileStderr
+		stderr := helmfileStderr()
+		defer func() { _ = stderr.Close() }()
+		cmd.Stderr = stderr
 		cmd.Env = append(cmd.Env, fmt.Sprintf("HELMFILE_TEMPLATE_DIR=%s", tempDir))
 		return cmd.Run()
 	}
@@ -174,6 +221,7 @@ var (
 	bootstrapNodeMaxWait                   = time.Duration(constants.BootstrapNodeMaxWait) * time.Second
 	bootstrapKubeconfigMaxWait             = time.Duration(constants.BootstrapKubeconfigMaxWait) * time.Second
 	bootstrapCRDMaxWait                    = time.Duration(constants.BootstrapCRDMaxWait) * time.Second
+	bootstrapGatewayMaxWait                = time.Duration(constants.BootstrapGatewayMaxWait) * time.Second
 	bootstrapTalosTempDir        string
 	bootstrapPreflightChecks     = []preflightCheck{
 		{fn: checkToolAvailability},
@@ -184,7 +232,10 @@ var (
 		{fn: check1PasswordAuthPreflight, serial: true},
 		// Serial: resolves op:// references, so it needs the auth check first.
 		{fn: checkMachineConfigRendering, serial: true},
+		// Serial: resolves the hypervisor credentials through 1Password.
+		{fn: checkHypervisorVMs, serial: true},
 		{fn: checkTalosNodes},
+		{fn: checkNodeAddressFamilies},
 	}
 )
 
@@ -216,6 +267,9 @@ talosctl bootstrap) instead.`,
   # Include availability-only secret checks; values/references are never printed
   homeops-cli bootstrap --plan --check-secrets --output json
 
+  # Compare an existing cluster with what bootstrap would do, read-only
+  homeops-cli bootstrap --check
+
   # Exercise operational dry-run branches
   homeops-cli bootstrap --dry-run
 
@@ -225,6 +279,15 @@ talosctl bootstrap) instead.`,
   # Re-run only the post-CNI phase against an existing control plane
   homeops-cli bootstrap --skip-kubeadm
 
+  # After a failure, skip the steps that already completed
+  homeops-cli bootstrap --resume
+
+  # Start at a step regardless of saved progress
+  homeops-cli bootstrap --from-step helm-releases
+
+  # Re-apply just the CRDs against a running cluster
+  homeops-cli bootstrap --only crds
+
   # Legacy Talos path
   homeops-cli bootstrap --provider talos`,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -234,8 +297,30 @@ talosctl bootstrap) instead.`,
 			if err := ui.ValidateOutputFormat(config.Output); err != nil {
 				return err
 			}
-			if !config.Plan && cmd.Flags().Changed("output") {
-				return fmt.Errorf("--output requires --plan")
+			if config.Plan && config.Check {
+				return fmt.Errorf("--plan and --check are mutually exclusive")
+			}
+			if err := validateBootstrapResume(&config); err != nil {
+				return err
+			}
+			if !config.Plan && !config.Check && cmd.Flags().Changed("output") {
+				return fmt.Errorf("--output requires --plan or --check")
+			}
+			// Bootstrap reads the version plans and manifests from the repo.
+			if config.RootDir == "" {
+				root, err := bootstrapRepoRoot()
+				if err != nil {
+					return err
+				}
+				config.RootDir = root
+			}
+			if config.Check {
+				rendered, err := renderBootstrapCheck(bootstrapAssessFn(&config), config.Output)
+				if err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintln(cmd.OutOrStdout(), rendered)
+				return nil
 			}
 			if config.Plan {
 				plan, err := bootstrapBuildPlanFn(config)
@@ -263,6 +348,7 @@ talosctl bootstrap) instead.`,
 			// Bootstrapping a cluster deserves an explicit yes (--yes/-y
 			// skips the prompt, dry runs never mutate anything).
 			if !config.DryRun {
+				warnIfAlreadyBootstrapped(&config, common.NewColorLogger())
 				ok, err := bootstrapConfirm("Bootstrap the cluster now? (preflight, PKI, kubeadm, CRDs, helmfile)", false)
 				if err != nil {
 					if ui.IsCancellation(err) {
@@ -275,13 +361,21 @@ talosctl bootstrap) instead.`,
 					return nil
 				}
 			}
+			components := []versioncheck.Component{versioncheck.Kubernetes, versioncheck.Flatcar}
+			if strings.EqualFold(config.Provider, "talos") {
+				components = []versioncheck.Component{versioncheck.Talos}
+			}
+			if err := bootstrapCheckVersions(cmd.Context(), config.RootDir, components...); err != nil {
+				return err
+			}
 			return runBootstrapFn(&config)
 		},
 	}
 
-	// Add flags - default root-dir to git repository root
+	// Add flags - default root-dir to the repository root. This local flag
+	// shadows the global --root-dir with the same meaning.
 	defaultRootDir := bootstrapWorkingDirectory()
-	cmd.Flags().StringVar(&config.RootDir, "root-dir", defaultRootDir, "Root directory of the project")
+	cmd.Flags().StringVar(&config.RootDir, "root-dir", defaultRootDir, "Root directory of the home-ops repository")
 	cmd.Flags().StringVar(&config.KubeConfig, "kubeconfig", os.Getenv(constants.EnvKubeconfig), "Path to kubeconfig file")
 	cmd.Flags().StringVar(&config.TalosConfig, "talosconfig", os.Getenv(constants.EnvTalosconfig), "Path to talosconfig file (legacy --provider talos only; ignored for Flatcar)")
 	cmd.Flags().StringVar(&config.K8sVersion, "k8s-version", os.Getenv(constants.EnvKubernetesVersion), "Kubernetes version")
@@ -292,11 +386,20 @@ talosctl bootstrap) instead.`,
 	cmd.Flags().BoolVar(&config.SkipHelmfile, "skip-helmfile", false, "Skip Helmfile sync")
 	cmd.Flags().BoolVar(&config.SkipPreflight, "skip-preflight", false, "Skip preflight checks (not recommended)")
 	cmd.Flags().BoolVar(&config.SkipKubeadm, "skip-kubeadm", false, "Flatcar: skip kubeadm init/join; run only post-CNI bootstrap against an existing control plane")
+	cmd.Flags().BoolVar(&config.SkipGatewayCheck, "skip-gateway-check", false, "Skip the post-bootstrap check that a Gateway API Gateway is Programmed with an address")
 	cmd.Flags().BoolVar(&config.FreshPKI, "fresh-pki", false, "Flatcar: mint a NEW cluster CA instead of restoring the persisted PKI from 1Password (breaks existing kubeconfigs)")
 	cmd.Flags().BoolVar(&config.Plan, "plan", false, "print the complete ordered bootstrap plan and exit without making changes")
 	cmd.Flags().BoolVar(&config.CheckSecrets, "check-secrets", false, "with --plan, check whether listed secret references currently resolve without printing values")
-	cmd.Flags().StringVarP(&config.Output, "output", "o", "table", "plan output format: table or json")
+	cmd.Flags().BoolVar(&config.Check, "check", false, "report which bootstrap steps the live cluster already satisfies and exit without making changes")
+	cmd.Flags().StringVarP(&config.Output, "output", "o", "table", "plan/check output format: table or json")
 	cmd.Flags().BoolVarP(&config.Verbose, "verbose", "v", false, "Enable verbose output (shows all logs, disables spinners)")
+	cmd.Flags().BoolVar(&config.Resume, "resume", false, "Skip the steps the last bootstrap completed for the same provider, versions, and kubeconfig (preflight always runs)")
+	cmd.Flags().StringVar(&config.FromStep, "from-step", "", "Start at this step, skipping every earlier one except preflight (e.g. helm-releases)")
+	cmd.Flags().StringSliceVar(&config.Only, "only", nil, "Run only these steps, comma-separated, in bootstrap order (e.g. crds or namespaces,resources)")
+	cmd.Flags().BoolVar(&config.UnsafeSaveSecrets, "unsafe-save-secrets", false, "Save rendered configs to the debug directory with their resolved secrets in plaintext (redacted otherwise)")
+	cmd.Flags().StringVar(&config.ReportFile, "report-file", "", "Write a JSON summary of every step, the preflight checks, and the cluster to this path when the run ends")
+	cmd.Flags().BoolVar(&config.CheckHypervisor, "check-hypervisor", false, "Preflight: check that every node's VM exists and is running on the hypervisor (on by default when hypervisors.default is truenas or vsphere)")
+	cmd.Flags().IntVar(&config.MaxParallel, "max-parallel", 3, "Talos: nodes to apply machine configs to at once (legacy --provider talos only)")
 	cmd.Flags().StringVar(&config.Provider, "provider", "flatcar", "Node provisioning provider: flatcar (kubeadm, default) or talos (legacy)")
 	_ = cmd.RegisterFlagCompletionFunc("provider", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
 		return []string{"flatcar", "talos"}, cobra.ShellCompDirectiveNoFileComp
@@ -371,15 +474,33 @@ func promptBootstrapOptions(config *BootstrapConfig, logger *common.ColorLogger)
 
 	return nil
 }
-func runBootstrap(config *BootstrapConfig) error {
+func runBootstrap(config *BootstrapConfig) (err error) {
 	// Initialize logger with colors
 	logger := common.NewColorLogger()
 
+	// The report is written however the run ends. Failing to write it fails
+	// an otherwise successful run, since CI reads it instead of the log.
+	config.report = newBootstrapReport(config)
+	defer func() {
+		if writeErr := config.report.write(config, config.ReportFile, err); writeErr != nil {
+			if err == nil {
+				err = writeErr
+				return
+			}
+			logger.Warn("Bootstrap report not written: %v", writeErr)
+		}
+	}()
+
 	// A dry run's whole output IS the plan: log every step directly instead
 	// of hiding the "[DRY RUN] would ..." lines behind spinners.
 	if config.DryRun {
 		config.Verbose = true
 	}
+	if versionconfig.Get().Bootstrap.SkipGatewayCheck {
+		config.SkipGatewayCheck = true
+	}
+	unsafeSaveRenderedSecrets = config.UnsafeSaveSecrets
+	defer func() { unsafeSaveRenderedSecrets = false }()
 
 	// Provider dispatch: Flatcar/kubeadm is the default for the CLI (the
 	// `--provider` flag defaults to "flatcar", so a bare `homeops-cli bootstrap`
@@ -393,3 +514,52 @@ func runBootstrap(config *BootstrapConfig) error {
 
 	return runTalosBootstrapFlow(config, logger)
 }
+
+// helmfileStderr is where helmfile sync writes its progress:
+// bootstrapHelmfileStderr, except that with --log-format json stderr gets
+// each line as a log message so it stays NDJSON. Close it after the run.
+func helmfileStderr() io.WriteCloser {
+	if common.JSONLogging() && bootstrapHelmfileStderr == io.Writer(os.Stderr) {
+		return common.NewColorLogger().With("source", "helmfile").LineWriter(common.InfoLevel)
+	}
+	return nopWriteCloser{bootstrapHelmfileStderr}
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// runBootstrapStep runs one bootstrap step behind a spinner, timing it as
+// step; see trackBootstrapStep.
+func runBootstrapStep(config *BootstrapConfig, logger *common.ColorLogger, step, title string, fn func() error) error {
+	return trackBootstrapStep(config, step, func() error {
+		return bootstrapRunWithSpinner(title, config.Verbose, logger, fn)
+	})
+}
+
+// trackBootstrapStep records how long step took, and whether it failed, in
+// metrics.Spans for --metrics-file and the --report-file report, and
+// persists its completion for --resume, which may skip it instead. A dry
+// run's steps are not timed and are reported skipped.
+func trackBootstrapStep(config *BootstrapConfig, step string, fn func() error) error {
+	if config.DryRun {
+		return config.report.track(step, "dry run", fn)
+	}
+	if config.progress.skip(step) {
+		reason := "--resume"
+		if config.FromStep != "" {
+			reason = "--from-step"
+		} else if len(config.Only) > 0 {
+			reason = "--only"
+		}
+		config.report.skip(step, reason)
+		return nil
+	}
+	if err := metrics.Spans.TrackOperation(step, func() error {
+		return config.report.track(step, "", fn)
+	}); err != nil {
+		return err
+	}
+	config.progress.complete(step)
+	return nil
+}
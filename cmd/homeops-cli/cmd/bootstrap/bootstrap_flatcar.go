@@ -12,14 +12,16 @@ import (
 	versionconfig "homeops-cli/internal/config"
 	"homeops-cli/internal/flatcar"
 	"homeops-cli/internal/proxmox"
+	"homeops-cli/internal/secrets"
 	"homeops-cli/internal/ssh"
 	"homeops-cli/internal/ui"
 )
 
 // This file adds an end-to-end kubeadm bootstrap path for the Talos->Flatcar
 // migration. It is purely ADDITIVE: it reuses the generic post-CNI steps from
-// bootstrap.go (waitForNodes, applyNamespaces, applyResources, applyCRDs,
-// syncHelmReleases, waitForFluxReconciliation) via their existing func vars and
+// bootstrap.go (waitForNodes, applyNamespaces, applyClusterSettings,
+// applyResources, applyCRDs, syncHelmReleases, waitForFluxReconciliation) via
+// their existing func vars and
 // only replaces the Talos-specific provisioning steps (apply-config + talos
 // bootstrap + talosctl kubeconfig fetch) with kubeadm-over-SSH equivalents.
 //
@@ -94,7 +96,9 @@ var (
 	flatcarRunHelmfileSelectorSyncCmd = func(tempDir, helmfilePath, selector string, config *BootstrapConfig) error {
 		cmd := buildHelmfileCmd(tempDir, config, "--file", helmfilePath, "--selector", selector, "sync", "--hide-notes")
 		cmd.Stdout = bootstrapHelmfileStdout
-		cmd.Stderr = bootstrapHelmfileStderr
+		stderr := helmfileStderr()
+		defer func() { _ = stderr.Close() }()
+		cmd.Stderr = stderr
 		cmd.Env = append(cmd.Env, fmt.Sprintf("HELMFILE_TEMPLATE_DIR=%s", tempDir))
 		return cmd.Run()
 	}
@@ -150,8 +154,8 @@ func buildFlatcarNodeEnv(node flatcarBootstrapNode, versions *versionconfig.Vers
 		NodeInterface:     cfg.Cluster.NodeInterface,
 		K8sEndpoint:       flatcarGetK8sEndpoint(),
 		ClusterName:       cfg.ClusterNameWithDefault(),
-		PodCIDR:           cfg.Cluster.PodCIDR,
-		ServiceCIDR:       cfg.Cluster.ServiceCIDR,
+		PodCIDR:           strings.Join(cfg.PodCIDRs(), ","),
+		ServiceCIDR:       strings.Join(cfg.ServiceCIDRs(), ","),
 		DNSDomain:         cfg.Cluster.DNSDomain,
 		ClusterDNS:        cfg.ClusterDNS(),
 	}
@@ -166,7 +170,7 @@ type bootstrapStepper struct {
 
 // flatcarStepTotal counts the steps the configured flags will execute.
 func flatcarStepTotal(config *BootstrapConfig) int {
-	total := 10 // init, kubeconfig, join, cilium, nodes, namespaces, resources, crds, helmfile, flux
+	total := 12 // init, kubeconfig, join, cilium, nodes, namespaces, cluster settings, resources, crds, helmfile, flux, gateways
 	if config.SkipKubeadm {
 		total -= 3 // init, kubeconfig, join
 	}
@@ -179,6 +183,9 @@ func flatcarStepTotal(config *BootstrapConfig) int {
 	if config.SkipHelmfile {
 		total -= 2 // helmfile sync + flux wait
 	}
+	if config.SkipHelmfile || config.SkipGatewayCheck {
+		total-- // gateway check
+	}
 	return total
 }
 
@@ -198,7 +205,8 @@ func (s *bootstrapStepper) sub(emoji, text string) string {
 //
 //	preflight -> init node0 -> fetch+save kubeconfig -> join node1/node2
 //	-> install Cilium (CNI) -> [generic] waitForNodes -> applyNamespaces
-//	-> applyResources -> applyCRDs -> syncHelmReleases -> waitForFlux
+//	-> applyClusterSettings -> applyResources -> applyCRDs -> syncHelmReleases
+//	-> waitForFlux -> waitForGateways
 //
 // Steps after Cilium reuse the existing bootstrap.go func vars unchanged.
 func runBootstrapFlatcar(config *BootstrapConfig) error {
@@ -232,9 +240,12 @@ func runBootstrapFlatcar(config *BootstrapConfig) error {
 
 	// Plan panel (TTY only; the Info lines above cover CI logs).
 	nodeDescs := make([]string, 0, len(nodes))
+	nodeNames := make([]string, 0, len(nodes))
 	for _, n := range nodes {
 		nodeDescs = append(nodeDescs, fmt.Sprintf("%s (%s)", n.Name, n.IP))
+		nodeNames = append(nodeNames, n.Name)
 	}
+	config.report.setNodes(nodeNames)
 	mode := "apply"
 	if config.DryRun {
 		mode = "DRY RUN — no changes will be made"
@@ -249,10 +260,14 @@ func runBootstrapFlatcar(config *BootstrapConfig) error {
 	}
 
 	steps := &bootstrapStepper{total: flatcarStepTotal(config)}
+	config.progress = openBootstrapProgress(config, logger)
+	if err := checkBootstrapOnlyCluster(config); err != nil {
+		return err
+	}
 
 	// Step 0: Preflight (tools + node reachability + kubelet present).
 	if !config.SkipPreflight {
-		if err := bootstrapRunWithSpinner("🔍 Running Flatcar preflight checks", config.Verbose, logger, func() error {
+		if err := runBootstrapStep(config, logger, "preflight", "🔍 Running Flatcar preflight checks", func() error {
 			return flatcarPreflight(config, nodes, logger)
 		}); err != nil {
 			return fmt.Errorf("flatcar preflight checks failed: %w", err)
@@ -277,7 +292,7 @@ func runBootstrapFlatcar(config *BootstrapConfig) error {
 		logger.Warn("⚠️  Skipping kubeadm init/join (--skip-kubeadm): using existing control plane")
 	}
 	if !config.SkipKubeadm {
-		if err := bootstrapRunWithSpinner(steps.next("🎯", fmt.Sprintf("kubeadm init on %s (%s)", node0.Name, node0.IP)), config.Verbose, logger, func() error {
+		if err := runBootstrapStep(config, logger, "kubeadm-init", steps.next("🎯", fmt.Sprintf("kubeadm init on %s (%s)", node0.Name, node0.IP)), func() error {
 			if config.DryRun {
 				logger.Info("[DRY RUN] Would render kubeadm init config and run kubeadm init on %s", node0.IP)
 				kubeadmResult = &flatcar.KubeadmResult{}
@@ -306,7 +321,7 @@ func runBootstrapFlatcar(config *BootstrapConfig) error {
 	// validate helpers from bootstrap.go). Skipped with --skip-kubeadm; the
 	// caller must pass --kubeconfig for an already-built control plane.
 	if !config.SkipKubeadm {
-		if err := bootstrapRunWithSpinner(steps.next("🔑", "Fetching and validating kubeconfig"), config.Verbose, logger, func() error {
+		if err := runBootstrapStep(config, logger, "kubeconfig", steps.next("🔑", "Fetching and validating kubeconfig"), func() error {
 			return fetchFlatcarKubeconfig(config, orch, node0, logger)
 		}); err != nil {
 			return err
@@ -315,88 +330,101 @@ func runBootstrapFlatcar(config *BootstrapConfig) error {
 		logger.Info("Using provided --kubeconfig: %s", config.KubeConfig)
 	}
 
-	// Step 3: Join the remaining control-plane nodes (via the VIP).
+	// Step 3: Join the remaining control-plane nodes (via the VIP). One step
+	// for all of them, so --resume never skips a node that did not join.
 	if !config.SkipKubeadm {
 		steps.next("➕", "kubeadm join remaining control-plane nodes")
-		for _, node := range nodes[1:] {
-			node := node
-			if err := bootstrapRunWithSpinner(steps.sub("➕", fmt.Sprintf("kubeadm join %s (%s) as control-plane", node.Name, node.IP)), config.Verbose, logger, func() error {
-				if config.DryRun {
-					logger.Info("[DRY RUN] Would render kubeadm join config and join %s via VIP %s", node.IP, versionconfig.Get().Cluster.ControlPlaneVIP)
+		if err := trackBootstrapStep(config, "kubeadm-join", func() error {
+			for _, node := range nodes[1:] {
+				node := node
+				if err := bootstrapRunWithSpinner(steps.sub("➕", fmt.Sprintf("kubeadm join %s (%s) as control-plane", node.Name, node.IP)), config.Verbose, logger, func() error {
+					if config.DryRun {
+						logger.Info("[DRY RUN] Would render kubeadm join config and join %s via VIP %s", node.IP, versionconfig.Get().Cluster.ControlPlaneVIP)
+						return nil
+					}
+					joinEnv := buildFlatcarNodeEnv(node, versions)
+					joinEnv.BootstrapToken = kubeadmResult.BootstrapToken
+					joinEnv.CACertHash = kubeadmResult.CACertHash
+					joinEnv.CertificateKey = kubeadmResult.CertificateKey
+					joinConfig, rErr := flatcarRenderKubeadmJoin(joinEnv)
+					if rErr != nil {
+						return fmt.Errorf("failed to render kubeadm join config for %s: %w", node.Name, rErr)
+					}
+					if jErr := orch.JoinControlPlane(node.IP, joinConfig); jErr != nil {
+						return fmt.Errorf("kubeadm join failed for %s: %w", node.Name, jErr)
+					}
 					return nil
+				}); err != nil {
+					return err
 				}
-				joinEnv := buildFlatcarNodeEnv(node, versions)
-				joinEnv.BootstrapToken = kubeadmResult.BootstrapToken
-				joinEnv.CACertHash = kubeadmResult.CACertHash
-				joinEnv.CertificateKey = kubeadmResult.CertificateKey
-				joinConfig, rErr := flatcarRenderKubeadmJoin(joinEnv)
-				if rErr != nil {
-					return fmt.Errorf("failed to render kubeadm join config for %s: %w", node.Name, rErr)
-				}
-				if jErr := orch.JoinControlPlane(node.IP, joinConfig); jErr != nil {
-					return fmt.Errorf("kubeadm join failed for %s: %w", node.Name, jErr)
-				}
-				return nil
-			}); err != nil {
-				return err
 			}
+			return nil
+		}); err != nil {
+			return err
 		}
 	} // end if !SkipKubeadm (step 3 joins)
 	logger.Info("Nodes will report NotReady until the CNI is installed (expected)")
 
 	// Step 4: Install Cilium (CNI) BEFORE Flux so nodes go Ready and the
 	// VIP/LoadBalancer plane works.
-	if err := bootstrapRunWithSpinner(steps.next("🕸️ ", "Installing Cilium CNI"), config.Verbose, logger, func() error {
+	if err := runBootstrapStep(config, logger, "cilium", steps.next("🕸️ ", "Installing Cilium CNI"), func() error {
 		return flatcarInstallCilium(config, logger)
 	}); err != nil {
 		return fmt.Errorf("failed to install Cilium: %w", err)
 	}
 
 	// Step 5: Wait for nodes to be ready (generic; reused).
-	if err := bootstrapRunWithSpinner(steps.next("⏳", "Waiting for nodes to be ready"), config.Verbose, logger, func() error {
+	if err := runBootstrapStep(config, logger, "nodes", steps.next("⏳", "Waiting for nodes to be ready"), func() error {
 		return bootstrapWaitForNodes(config, logger)
 	}); err != nil {
 		return fmt.Errorf("failed waiting for nodes: %w", err)
 	}
 
 	// Step 6: Namespaces (generic; reused).
-	if err := bootstrapRunWithSpinner(steps.next("📦", "Creating initial namespaces"), config.Verbose, logger, func() error {
+	if err := runBootstrapStep(config, logger, "namespaces", steps.next("📦", "Creating initial namespaces"), func() error {
 		return bootstrapApplyNamespaces(config, logger)
 	}); err != nil {
 		return fmt.Errorf("failed to apply namespaces: %w", err)
 	}
 
-	// Step 7: Initial resources (generic; reused).
+	// Step 7: Flux cluster-settings ConfigMap (generic; reused).
+	if err := runBootstrapStep(config, logger, "cluster-settings", steps.next("🧭", "Applying cluster settings"), func() error {
+		return bootstrapApplyClusterSettings(config, logger)
+	}); err != nil {
+		return fmt.Errorf("failed to apply cluster settings: %w", err)
+	}
+
+	// Step 8: Initial resources (generic; reused).
 	if !config.SkipResources {
-		if err := bootstrapRunWithSpinner(steps.next("🔧", "Applying initial resources"), config.Verbose, logger, func() error {
+		if err := runBootstrapStep(config, logger, "resources", steps.next("🔧", "Applying initial resources"), func() error {
 			return bootstrapApplyResources(config, logger)
 		}); err != nil {
 			return fmt.Errorf("failed to apply resources: %w", err)
 		}
 	}
 
-	// Step 8: CRDs (generic; reused).
+	// Step 9: CRDs (generic; reused).
 	if !config.SkipCRDs {
-		if err := bootstrapRunWithSpinner(steps.next("📜", "Applying Custom Resource Definitions"), config.Verbose, logger, func() error {
+		if err := runBootstrapStep(config, logger, "crds", steps.next("📜", "Applying Custom Resource Definitions"), func() error {
 			return bootstrapApplyCRDs(config, logger)
 		}); err != nil {
 			return fmt.Errorf("failed to apply CRDs: %w", err)
 		}
 	}
 
-	// Step 9: Helm releases via helmfile (generic; reused). This installs the
+	// Step 10: Helm releases via helmfile (generic; reused). This installs the
 	// remaining stack (coredns, spegel, cert-manager, external-secrets, flux).
 	if !config.SkipHelmfile {
-		if err := bootstrapRunWithSpinner(steps.next("⚙️ ", "Syncing Helm releases"), config.Verbose, logger, func() error {
+		if err := runBootstrapStep(config, logger, "helm-releases", steps.next("⚙️ ", "Syncing Helm releases"), func() error {
 			return bootstrapSyncHelmReleases(config, logger)
 		}); err != nil {
 			return fmt.Errorf("failed to sync Helm releases: %w", err)
 		}
 	}
 
-	// Step 10: Wait for Flux initial reconciliation (generic; reused).
+	// Step 11: Wait for Flux initial reconciliation (generic; reused).
 	if !config.SkipHelmfile {
-		if err := bootstrapRunWithSpinner(steps.next("🔄", "Waiting for Flux initial reconciliation"), config.Verbose, logger, func() error {
+		if err := runBootstrapStep(config, logger, "flux", steps.next("🔄", "Waiting for Flux initial reconciliation"), func() error {
 			return bootstrapWaitForFlux(config, logger)
 		}); err != nil {
 			logger.Warn("Flux reconciliation wait completed with warnings: %v", err)
@@ -404,6 +432,16 @@ func runBootstrapFlatcar(config *BootstrapConfig) error {
 		}
 	}
 
+	// Step 12: Gateway API smoke test (generic; reused). Fatal, unlike the
+	// Flux wait: Gateways without an address leave the cluster unreachable.
+	if !config.SkipHelmfile && !config.SkipGatewayCheck {
+		if err := runBootstrapStep(config, logger, "gateways", steps.next("🌐", "Checking Gateway API Gateways"), func() error {
+			return bootstrapWaitGateways(config, logger)
+		}); err != nil {
+			return fmt.Errorf("gateway check failed (skip with --skip-gateway-check): %w", err)
+		}
+	}
+
 	if config.DryRun {
 		logger.Success("✅ Dry run complete — no changes were made (%d step(s) planned)", steps.total)
 		ui.PrintInfoBox("Dry run complete",
@@ -520,6 +558,7 @@ func installCiliumOnly(config *BootstrapConfig, logger *common.ColorLogger) erro
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
 		if attempt > 1 {
 			logger.Info("Cilium install attempt %d/%d", attempt, maxAttempts)
+			config.report.retried()
 		}
 		err := flatcarRunHelmfileSelectorSyncCmd(tempDir, helmfilePath, "name=cilium", config)
 		if err == nil {
@@ -539,11 +578,11 @@ func installCiliumOnly(config *BootstrapConfig, logger *common.ColorLogger) erro
 }
 
 // runFlatcarPreflight validates the Flatcar/kubeadm prerequisites: required
-// local tools, SSH reachability to all 3 nodes, and that each node is booted
-// into Flatcar with kubelet present.
+// local tools, the nodes' VMs running on the hypervisor, SSH reachability to
+// all 3 nodes, and that each node is booted into Flatcar with kubelet present.
 func runFlatcarPreflight(config *BootstrapConfig, nodes []flatcarBootstrapNode, logger *common.ColorLogger) error {
 	// 1. Local tools needed for the post-CNI generic steps.
-	requiredBins := []string{"kubectl", "helmfile", "op"}
+	requiredBins := withSecretBackendTool("helmfile")
 	var missing []string
 	for _, bin := range requiredBins {
 		if _, err := bootstrapLookPath(bin); err != nil {
@@ -551,12 +590,19 @@ func runFlatcarPreflight(config *BootstrapConfig, nodes []flatcarBootstrapNode,
 		}
 	}
 	if len(missing) > 0 {
+		config.report.addPreflight(&PreflightResult{Name: "Tool Availability", Status: "FAIL", Message: fmt.Sprintf("missing: %v", missing)})
 		return fmt.Errorf("missing required tools: %v", missing)
 	}
+	config.report.addPreflight(&PreflightResult{Name: "Tool Availability", Status: "PASS", Message: "all required tools available"})
 
-	// 2. 1Password auth (needed to resolve SSH creds + save kubeconfig).
-	if err := bootstrapEnsureOPAuth(); err != nil {
-		return fmt.Errorf("1Password authentication failed: %w", err)
+	// 2. 1Password auth (needed to resolve SSH creds + save kubeconfig),
+	// unless another secret backend reads op:// and no state store uses op.
+	if secrets.CurrentBackendName() == secrets.BackendOnePassword || versionconfig.Get().UsesOpReferences() {
+		if err := bootstrapEnsureOPAuth(); err != nil {
+			config.report.addPreflight(&PreflightResult{Name: "1Password Authentication", Status: "FAIL", Message: err.Error()})
+			return fmt.Errorf("1Password authentication failed: %w", err)
+		}
+		config.report.addPreflight(&PreflightResult{Name: "1Password Authentication", Status: "PASS", Message: "authenticated"})
 	}
 
 	if config.DryRun {
@@ -564,17 +610,43 @@ func runFlatcarPreflight(config *BootstrapConfig, nodes []flatcarBootstrapNode,
 		return nil
 	}
 
-	// 3. SSH reachability + Flatcar/kubelet presence on each node.
+	// 3. The nodes' VMs are up, when they run on TrueNAS or vSphere.
+	if provider, err := hypervisorPreflightProvider(config, versionconfig.Get()); err != nil || provider != "" {
+		result := checkHypervisorVMs(config, logger)
+		config.report.addPreflight(result)
+		switch result.Status {
+		case "FAIL":
+			return fmt.Errorf("hypervisor VM check failed: %s", result.Message)
+		case "WARN":
+			logger.Warn("%s: %s", result.Name, result.Message)
+		default:
+			logger.Debug("%s: %s", result.Name, result.Message)
+		}
+	}
+
+	// 4. SSH reachability + Flatcar/kubelet presence on each node.
 	sshUser, err := flatcarGetSSHUser()
 	if err != nil {
 		return fmt.Errorf("failed to resolve Flatcar SSH user: %w", err)
 	}
 	for _, node := range nodes {
+		check := "Node " + node.Name
 		if err := flatcarCheckNode(sshUser, node, logger); err != nil {
+			config.report.addPreflight(&PreflightResult{Name: check, Status: "FAIL", Message: err.Error()})
 			return fmt.Errorf("node %s (%s) preflight failed: %w", node.Name, node.IP, err)
 		}
+		config.report.addPreflight(&PreflightResult{Name: check, Status: "PASS", Message: fmt.Sprintf("%s reachable, Flatcar booted, kubelet present", node.IP)})
 		logger.Debug("Node %s (%s) reachable, Flatcar booted, kubelet present", node.Name, node.IP)
 	}
+
+	// 5. Dual-stack: the SSH checks above only used IPv4; probe both families
+	// on the SSH port, since the kubelet registers the IPv6 address too.
+	if versionconfig.Get().Cluster.DualStack {
+		if unreachable := probeNodeAddresses(versionconfig.Get().Cluster.NodeSSHPort); len(unreachable) > 0 {
+			config.report.addPreflight(&PreflightResult{Name: "Dual-stack Addresses", Status: "FAIL", Message: strings.Join(unreachable, "; ")})
+			return fmt.Errorf("dual-stack node addresses unreachable: %s", strings.Join(unreachable, "; "))
+		}
+	}
 	return nil
 }
 
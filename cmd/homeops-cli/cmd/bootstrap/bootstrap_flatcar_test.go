@@ -1,7 +1,11 @@
 package bootstrap
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -10,6 +14,11 @@ import (
 	"homeops-cli/internal/common"
 	versionconfig "homeops-cli/internal/config"
 	"homeops-cli/internal/flatcar"
+	"homeops-cli/internal/metrics"
+	"homeops-cli/internal/testutil"
+	"homeops-cli/internal/ui"
+
+	"github.com/fatih/color"
 )
 
 // fakeOrchestrator records init/join/fetch calls for the Flatcar bootstrap flow.
@@ -74,10 +83,12 @@ func installFlatcarFlowFakes(t *testing.T, result *flatcar.KubeadmResult) (*[]st
 	oldValidateKubeconfig := bootstrapValidateKubeconfig
 	oldWaitForNodes := bootstrapWaitForNodes
 	oldApplyNamespaces := bootstrapApplyNamespaces
+	oldApplyClusterSettings := bootstrapApplyClusterSettings
 	oldApplyResources := bootstrapApplyResources
 	oldApplyCRDs := bootstrapApplyCRDs
 	oldSyncHelm := bootstrapSyncHelmReleases
 	oldWaitFlux := bootstrapWaitForFlux
+	oldWaitGateways := bootstrapWaitGateways
 
 	t.Cleanup(func() {
 		bootstrapRunWithSpinner = oldRunWithSpinner
@@ -93,10 +104,12 @@ func installFlatcarFlowFakes(t *testing.T, result *flatcar.KubeadmResult) (*[]st
 		bootstrapValidateKubeconfig = oldValidateKubeconfig
 		bootstrapWaitForNodes = oldWaitForNodes
 		bootstrapApplyNamespaces = oldApplyNamespaces
+		bootstrapApplyClusterSettings = oldApplyClusterSettings
 		bootstrapApplyResources = oldApplyResources
 		bootstrapApplyCRDs = oldApplyCRDs
 		bootstrapSyncHelmReleases = oldSyncHelm
 		bootstrapWaitForFlux = oldWaitFlux
+		bootstrapWaitGateways = oldWaitGateways
 	})
 
 	// Spinner just runs the function (so step ordering is preserved).
@@ -159,6 +172,10 @@ func installFlatcarFlowFakes(t *testing.T, result *flatcar.KubeadmResult) (*[]st
 		*steps = append(*steps, "namespaces")
 		return nil
 	}
+	bootstrapApplyClusterSettings = func(*BootstrapConfig, *common.ColorLogger) error {
+		*steps = append(*steps, "cluster-settings")
+		return nil
+	}
 	bootstrapApplyResources = func(*BootstrapConfig, *common.ColorLogger) error {
 		*steps = append(*steps, "resources")
 		return nil
@@ -175,6 +192,10 @@ func installFlatcarFlowFakes(t *testing.T, result *flatcar.KubeadmResult) (*[]st
 		*steps = append(*steps, "flux")
 		return nil
 	}
+	bootstrapWaitGateways = func(*BootstrapConfig, *common.ColorLogger) error {
+		*steps = append(*steps, "gateways")
+		return nil
+	}
 
 	return steps, orch
 }
@@ -209,10 +230,12 @@ func TestRunBootstrapFlatcarStepOrder(t *testing.T) {
 		"cilium",
 		"wait-nodes",
 		"namespaces",
+		"cluster-settings",
 		"resources",
 		"crds",
 		"helmfile",
 		"flux",
+		"gateways",
 	}, ",")
 	if got != want {
 		t.Fatalf("unexpected step order:\n got: %s\nwant: %s", got, want)
@@ -240,6 +263,94 @@ func TestRunBootstrapFlatcarStepOrder(t *testing.T) {
 	}
 }
 
+func TestRunBootstrapFlatcarExportsStepDurations(t *testing.T) {
+	result := &flatcar.KubeadmResult{
+		BootstrapToken: "abcdef.0123456789abcdef",
+		CACertHash:     "sha256:" + strings.Repeat("a", 64),
+		CertificateKey: strings.Repeat("b", 64),
+	}
+	installFlatcarFlowFakes(t, result)
+	testutil.Swap(t, &metrics.Spans, metrics.NewPerformanceCollector())
+
+	cfg := &BootstrapConfig{RootDir: t.TempDir(), KubeConfig: t.TempDir() + "/kubeconfig", Provider: "flatcar"}
+	if err := runBootstrapFlatcar(cfg); err != nil {
+		t.Fatalf("runBootstrapFlatcar returned error: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "homeops.prom")
+	if err := metrics.Spans.Export(metrics.ExportOptions{TextfilePath: path}, "bootstrap"); err != nil {
+		t.Fatalf("Export returned error: %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("metrics file not written: %v", err)
+	}
+	for step, runs := range map[string]int{
+		"preflight": 1, "kubeadm-init": 1, "kubeconfig": 1, "kubeadm-join": 1, "cilium": 1, "nodes": 1,
+		"namespaces": 1, "cluster-settings": 1, "resources": 1, "crds": 1, "helm-releases": 1, "flux": 1, "gateways": 1,
+	} {
+		bucket := fmt.Sprintf(`homeops_step_duration_seconds_bucket{command="bootstrap",step=%q,status="success",le="+Inf"} %d`, step, runs)
+		if !strings.Contains(string(content), bucket) {
+			t.Errorf("metrics file lacks %s:\n%s", bucket, content)
+		}
+	}
+
+	// A dry run plans the steps without timing them.
+	testutil.Swap(t, &metrics.Spans, metrics.NewPerformanceCollector())
+	cfg.DryRun = true
+	if err := runBootstrapFlatcar(cfg); err != nil {
+		t.Fatalf("dry run returned error: %v", err)
+	}
+	if names := metrics.Spans.GetOperationNames(); len(names) != 0 {
+		t.Fatalf("dry run timed %v", names)
+	}
+}
+
+func TestRunBootstrapFlatcarLogsOnlyNDJSONInJSONFormat(t *testing.T) {
+	result := &flatcar.KubeadmResult{
+		BootstrapToken: "abcdef.0123456789abcdef",
+		CACertHash:     "sha256:" + strings.Repeat("a", 64),
+		CertificateKey: strings.Repeat("b", 64),
+	}
+	installFlatcarFlowFakes(t, result)
+	bootstrapRunWithSpinner = ui.RunWithSpinner
+	if err := common.SetGlobalLogFormat(common.LogFormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = common.SetGlobalLogFormat(common.LogFormatText) })
+	var stdout, stderr bytes.Buffer
+	testutil.Swap[io.Writer](t, &color.Output, &stdout)
+	testutil.Swap[io.Writer](t, &color.Error, &stderr)
+
+	cfg := &BootstrapConfig{RootDir: t.TempDir(), KubeConfig: t.TempDir() + "/kubeconfig", Provider: "flatcar"}
+	if err := runBootstrapFlatcar(cfg); err != nil {
+		t.Fatalf("runBootstrapFlatcar returned error: %v", err)
+	}
+
+	if stdout.Len() != 0 {
+		t.Fatalf("json format logged to stdout:\n%s", stdout.String())
+	}
+	lines := strings.Split(strings.TrimSuffix(stderr.String(), "\n"), "\n")
+	spinners := 0
+	for _, line := range lines {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("stderr line is not JSON: %q", line)
+		}
+		for _, key := range []string{"level", "timestamp", "message"} {
+			if _, ok := entry[key]; !ok {
+				t.Fatalf("log line lacks %s: %s", key, line)
+			}
+		}
+		if _, ok := entry["spinner"]; ok {
+			spinners++
+		}
+	}
+	if spinners < flatcarStepTotal(cfg) {
+		t.Fatalf("expected a progress line per step, got %d:\n%s", spinners, stderr.String())
+	}
+}
+
 func TestRunBootstrapFlatcarFailsOnIncompleteInitMaterial(t *testing.T) {
 	// Missing CertificateKey -> the flow must abort before joining.
 	result := &flatcar.KubeadmResult{
@@ -419,10 +530,11 @@ func TestFlatcarStepTotalAccountsForSkips(t *testing.T) {
 		config BootstrapConfig
 		want   int
 	}{
-		{"full", BootstrapConfig{}, 10},
-		{"skip kubeadm", BootstrapConfig{SkipKubeadm: true}, 7},
-		{"skip helmfile", BootstrapConfig{SkipHelmfile: true}, 8},
-		{"skip everything skippable", BootstrapConfig{SkipKubeadm: true, SkipResources: true, SkipCRDs: true, SkipHelmfile: true}, 3},
+		{"full", BootstrapConfig{}, 12},
+		{"skip kubeadm", BootstrapConfig{SkipKubeadm: true}, 9},
+		{"skip helmfile", BootstrapConfig{SkipHelmfile: true}, 9},
+		{"skip gateway check", BootstrapConfig{SkipGatewayCheck: true}, 11},
+		{"skip everything skippable", BootstrapConfig{SkipKubeadm: true, SkipResources: true, SkipCRDs: true, SkipHelmfile: true}, 4},
 	}
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -446,3 +558,183 @@ func TestBootstrapStepperNumbering(t *testing.T) {
 		t.Fatalf("unexpected third step label: %q", got)
 	}
 }
+
+func TestRunBootstrapFlatcarResumesAfterTheFailedStep(t *testing.T) {
+	result := &flatcar.KubeadmResult{
+		BootstrapToken: "abcdef.0123456789abcdef",
+		CACertHash:     "sha256:" + strings.Repeat("a", 64),
+		CertificateKey: strings.Repeat("b", 64),
+	}
+	steps, orch := installFlatcarFlowFakes(t, result)
+	statePath := filepath.Join(t.TempDir(), "bootstrap-state.json")
+	testutil.Swap(t, &bootstrapStatePath, func() (string, error) { return statePath, nil })
+	syncHelm := bootstrapSyncHelmReleases
+	helmErr := errors.New("helmfile sync failed")
+	testutil.Swap(t, &bootstrapSyncHelmReleases, func(config *BootstrapConfig, logger *common.ColorLogger) error {
+		if err := syncHelm(config, logger); err != nil {
+			return err
+		}
+		return helmErr
+	})
+	newConfig := func() *BootstrapConfig {
+		return &BootstrapConfig{RootDir: "/repo", KubeConfig: filepath.Join(t.TempDir(), "kubeconfig"), Provider: "flatcar"}
+	}
+	cfg := newConfig()
+	if err := runBootstrapFlatcar(cfg); err == nil || !strings.Contains(err.Error(), "helmfile sync failed") {
+		t.Fatalf("expected the helmfile failure, got %v", err)
+	}
+
+	*steps, helmErr = nil, nil
+	cfg.Resume = true
+	if err := runBootstrapFlatcar(cfg); err != nil {
+		t.Fatalf("resumed bootstrap returned error: %v", err)
+	}
+	if got, want := strings.Join(*steps, ","), "preflight,helmfile,flux,gateways"; got != want {
+		t.Fatalf("resume should jump to the Helm releases:\n got: %s\nwant: %s", got, want)
+	}
+	if len(orch.joinCalls) != 2 {
+		t.Fatalf("resume re-joined nodes: %v", orch.joinCalls)
+	}
+
+	*steps = nil
+	cfg = newConfig()
+	cfg.FromStep = "crds"
+	if err := runBootstrapFlatcar(cfg); err != nil {
+		t.Fatalf("--from-step bootstrap returned error: %v", err)
+	}
+	if got, want := strings.Join(*steps, ","), "preflight,crds,helmfile,flux,gateways"; got != want {
+		t.Fatalf("--from-step crds:\n got: %s\nwant: %s", got, want)
+	}
+
+	// --only runs just the named steps against the running cluster.
+	*steps = nil
+	cfg = newConfig()
+	cfg.Only = []string{"crds"}
+	stubBootstrapKube(t)
+	if err := runBootstrapFlatcar(cfg); err != nil {
+		t.Fatalf("--only bootstrap returned error: %v", err)
+	}
+	if got := strings.Join(*steps, ","); got != "crds" {
+		t.Fatalf("--only crds ran %s", got)
+	}
+	failBootstrapKube(t, errors.New("connection refused"))
+	if err := runBootstrapFlatcar(cfg); err == nil || !strings.Contains(err.Error(), "--only crds needs a reachable API server") {
+		t.Fatalf("expected the unreachable API server to fail --only crds, got %v", err)
+	}
+
+	// A new Kubernetes version invalidates what the last run completed.
+	*steps = nil
+	cfg = newConfig()
+	cfg.K8sVersion, cfg.Resume = "v1.37.0", true
+	if err := runBootstrapFlatcar(cfg); err != nil {
+		t.Fatalf("resumed bootstrap returned error: %v", err)
+	}
+	if len(*steps) != 15 {
+		t.Fatalf("a version change must rerun every step, got %v", *steps)
+	}
+}
+
+func TestValidateBootstrapResume(t *testing.T) {
+	for _, tc := range []struct {
+		config BootstrapConfig
+		err    string
+	}{
+		{config: BootstrapConfig{Resume: true}},
+		{config: BootstrapConfig{FromStep: "helm-releases"}},
+		{config: BootstrapConfig{FromStep: "apply-config"}},
+		{config: BootstrapConfig{Resume: true, FromStep: "crds"}, err: "--resume, --from-step and --only cannot be combined"},
+		{config: BootstrapConfig{Only: []string{"crds"}, FromStep: "crds"}, err: "--resume, --from-step and --only cannot be combined"},
+		{config: BootstrapConfig{Resume: true, DryRun: true}, err: "--resume, --from-step and --only cannot be combined with --dry-run, --plan, or --check"},
+		{config: BootstrapConfig{Only: []string{"namespaces", "crds"}}},
+		{config: BootstrapConfig{Only: []string{"crds", "helmfile"}}, err: `unknown bootstrap step "helmfile" (steps: preflight, apply-config, talos-bootstrap, kubeconfig, nodes, namespaces, cluster-settings, resources, crds, helm-releases, flux, gateways)`},
+		{config: BootstrapConfig{Provider: "flatcar", Only: []string{"kubeadm-join"}}, err: "bootstrap cannot run kubeadm-join alone: it needs kubeadm-init in the same run (use --only kubeadm-init,kubeadm-join)"},
+		{config: BootstrapConfig{Provider: "flatcar", Only: []string{"kubeadm-init", "kubeadm-join"}}},
+		{config: BootstrapConfig{Provider: "flatcar", FromStep: "apply-config"}, err: `unknown bootstrap step "apply-config" (steps: preflight, kubeadm-init, kubeconfig, kubeadm-join, cilium, nodes, namespaces, cluster-settings, resources, crds, helm-releases, flux, gateways)`},
+		{config: BootstrapConfig{Provider: "flatcar", FromStep: "kubeadm-join"}, err: "bootstrap cannot start at kubeadm-join: it needs kubeadm-init in the same run (use --from-step kubeadm-init)"},
+	} {
+		err := validateBootstrapResume(&tc.config)
+		if tc.err == "" && err != nil || tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Errorf("validateBootstrapResume(%+v) = %v, want %q", tc.config, err, tc.err)
+		}
+	}
+}
+
+func readBootstrapReport(t *testing.T, path string) *bootstrapReport {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("report not written: %v", err)
+	}
+	report := &bootstrapReport{}
+	if err := json.Unmarshal(data, report); err != nil {
+		t.Fatalf("report is not JSON: %v\n%s", err, data)
+	}
+	return report
+}
+
+func TestRunBootstrapDryRunReportsEveryStepSkipped(t *testing.T) {
+	installFlatcarFlowFakes(t, &flatcar.KubeadmResult{})
+	path := filepath.Join(t.TempDir(), "report.json")
+	cfg := &BootstrapConfig{RootDir: "/repo", KubeConfig: filepath.Join(t.TempDir(), "kubeconfig"), Provider: "flatcar", DryRun: true, ReportFile: path}
+	if err := runBootstrap(cfg); err != nil {
+		t.Fatalf("dry-run bootstrap returned error: %v", err)
+	}
+
+	report := readBootstrapReport(t, path)
+	if report.Status != "success" || !report.DryRun || report.Provider != "flatcar" || report.Cluster.KubernetesVersion != "v1.36.1" {
+		t.Fatalf("unexpected report header: %s %v %s %+v", report.Status, report.DryRun, report.Provider, report.Cluster)
+	}
+	if got := strings.Join(report.Cluster.Nodes, ","); got != "k8s-0,k8s-1,k8s-2" {
+		t.Fatalf("report nodes = %s", got)
+	}
+	var names []string
+	for _, step := range report.Steps {
+		names = append(names, step.Name)
+		if step.Status != "skipped" || step.Reason != "dry run" {
+			t.Errorf("dry-run step %s reported %s (%s)", step.Name, step.Status, step.Reason)
+		}
+	}
+	if got, want := strings.Join(names, ","), strings.Join(flatcarBootstrapSteps, ","); got != want {
+		t.Fatalf("report steps:\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestRunBootstrapReportIsWrittenWhenAStepFails(t *testing.T) {
+	installFlatcarFlowFakes(t, &flatcar.KubeadmResult{
+		BootstrapToken: "abcdef.0123456789abcdef",
+		CACertHash:     "sha256:" + strings.Repeat("a", 64),
+		CertificateKey: strings.Repeat("b", 64),
+	})
+	testutil.Swap(t, &bootstrapSyncHelmReleases, func(config *BootstrapConfig, _ *common.ColorLogger) error {
+		// Three attempts: two retries.
+		config.report.retried()
+		config.report.retried()
+		return errors.New("helmfile sync failed")
+	})
+	path := filepath.Join(t.TempDir(), "report.json")
+	cfg := &BootstrapConfig{RootDir: "/repo", KubeConfig: filepath.Join(t.TempDir(), "kubeconfig"), Provider: "flatcar", ReportFile: path}
+	if err := runBootstrap(cfg); err == nil {
+		t.Fatal("expected the helmfile failure")
+	}
+
+	report := readBootstrapReport(t, path)
+	if report.Status != "failed" || !strings.Contains(report.Error, "helmfile sync failed") {
+		t.Fatalf("unexpected report outcome: %s %q", report.Status, report.Error)
+	}
+	statuses := map[string]bootstrapReportStep{}
+	for _, step := range report.Steps {
+		statuses[step.Name] = step
+	}
+	if step := statuses["kubeadm-init"]; step.Status != "success" {
+		t.Fatalf("kubeadm-init reported %+v", step)
+	}
+	if step := statuses["helm-releases"]; step.Status != "failed" || step.Retries != 2 || step.Error == "" {
+		t.Fatalf("helm-releases reported %+v", step)
+	}
+	if step := statuses["gateways"]; step.Status != "skipped" || step.Reason != "not reached" {
+		t.Fatalf("gateways reported %+v", step)
+	}
+	if len(report.Steps) != len(flatcarBootstrapSteps) {
+		t.Fatalf("report has %d steps, want %d", len(report.Steps), len(flatcarBootstrapSteps))
+	}
+}
@@ -1,19 +1,45 @@
 package bootstrap
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"io"
+	"maps"
+	"net"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	versionconfig "homeops-cli/internal/config"
 	"homeops-cli/internal/constants"
+	"homeops-cli/internal/kube"
 	"homeops-cli/internal/metrics"
+	vmprov "homeops-cli/internal/provider"
+	"homeops-cli/internal/secrets"
+	"homeops-cli/internal/testutil"
+	"homeops-cli/internal/vmlifecycle"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	k8stesting "k8s.io/client-go/testing"
 
 	"homeops-cli/internal/common"
 )
@@ -172,11 +198,6 @@ func TestCommandBuildersWithContext(t *testing.T) {
 	if got := strings.Join(talosCmd.Args, " "); got != "talosctl --talosconfig /tmp/talosconfig config info" {
 		t.Fatalf("unexpected talosctl context args: %s", got)
 	}
-
-	kubectlCmd := buildKubectlCmdContext(ctx, &BootstrapConfig{KubeConfig: "/tmp/kubeconfig"}, "get", "nodes")
-	if got := strings.Join(kubectlCmd.Args, " "); got != "kubectl get nodes --kubeconfig /tmp/kubeconfig" {
-		t.Fatalf("unexpected kubectl context args: %s", got)
-	}
 }
 
 func TestPreflightCheckHelpers(t *testing.T) {
@@ -247,6 +268,88 @@ func TestPreflightCheckHelpers(t *testing.T) {
 		}
 	})
 
+	t.Run("node address families probe both families when dual-stack", func(t *testing.T) {
+		oldDial := bootstrapDialTimeout
+		t.Cleanup(func() { bootstrapDialTimeout = oldDial })
+		var dialed []string
+		bootstrapDialTimeout = func(_, address string, _ time.Duration) (net.Conn, error) {
+			dialed = append(dialed, address)
+			if address == "[fd00::11]:50000" {
+				return nil, errors.New("connection refused")
+			}
+			client, server := net.Pipe()
+			_ = server.Close()
+			return client, nil
+		}
+		cluster := versionconfig.ClusterConfig{Nodes: []versionconfig.Node{
+			{Name: "k8s-0", IP: "10.0.0.10", IPv6: "fd00::10"},
+			{Name: "k8s-1", IP: "10.0.0.11", IPv6: "fd00::11"},
+		}}
+
+		t.Cleanup(versionconfig.SetForTesting(&versionconfig.Config{Cluster: cluster}))
+		result := checkNodeAddressFamilies(&BootstrapConfig{}, common.NewColorLogger())
+		if result.Status != "PASS" || len(dialed) != 0 {
+			t.Fatalf("single-stack should pass without dialing: %+v %v", result, dialed)
+		}
+
+		cluster.DualStack = true
+		t.Cleanup(versionconfig.SetForTesting(&versionconfig.Config{Cluster: cluster}))
+		result = checkNodeAddressFamilies(&BootstrapConfig{}, common.NewColorLogger())
+		if result.Status != "FAIL" || !strings.Contains(result.Message, "k8s-1 fd00::11") {
+			t.Fatalf("unexpected address family result: %+v", result)
+		}
+		// The third default node has no IPv6 address.
+		if strings.Join(dialed, ",") != "10.0.0.10:50000,[fd00::10]:50000,10.0.0.11:50000,[fd00::11]:50000,192.168.122.12:50000" {
+			t.Fatalf("unexpected dials: %v", dialed)
+		}
+	})
+
+	t.Run("hypervisor VMs fail preflight naming the stopped VM", func(t *testing.T) {
+		cfg := &versionconfig.Config{
+			Hypervisors: versionconfig.HypervisorsConfig{Default: "truenas"},
+			Cluster: versionconfig.ClusterConfig{Nodes: []versionconfig.Node{
+				{Name: "k8s-0", IP: "10.0.0.10"}, {Name: "k8s-1", IP: "10.0.0.11"}, {Name: "k8s-2", IP: "10.0.0.12"},
+			}},
+		}
+		t.Cleanup(versionconfig.SetForTesting(cfg))
+		testutil.Swap(t, &vmlifecycle.GetTrueNASCredentialsFn, func() (string, string, error) { return "nas", "key", nil })
+		vms := []vmprov.VMSummary{{Name: "k8s0", Status: "RUNNING"}, {Name: "k8s1", Status: "STOPPED"}, {Name: "k8s2", Status: "RUNNING"}}
+		var listed string
+		testutil.Swap(t, &bootstrapHypervisorVMs, func(_ context.Context, provider string) ([]vmprov.VMSummary, error) {
+			listed = provider
+			return vms, nil
+		})
+
+		result := checkHypervisorVMs(&BootstrapConfig{}, common.NewColorLogger())
+		assert.Equal(t, "truenas", listed)
+		assert.Equal(t, "FAIL", result.Status)
+		assert.Equal(t, "VMs down on truenas: k8s1 (STOPPED)", result.Message)
+
+		vms[1].Status = "RUNNING"
+		result = checkHypervisorVMs(&BootstrapConfig{}, common.NewColorLogger())
+		assert.Equal(t, "PASS", result.Status, result.Message)
+
+		vms = vms[:2]
+		result = checkHypervisorVMs(&BootstrapConfig{}, common.NewColorLogger())
+		assert.Equal(t, "VMs down on truenas: k8s-2 (no VM)", result.Message)
+
+		testutil.Swap(t, &vmlifecycle.GetTrueNASCredentialsFn, func() (string, string, error) {
+			return "", "", errors.New("TrueNAS credentials not found")
+		})
+		result = checkHypervisorVMs(&BootstrapConfig{}, common.NewColorLogger())
+		assert.Equal(t, "WARN", result.Status)
+		assert.Contains(t, result.Message, "TrueNAS credentials not found")
+
+		cfg.Hypervisors.Default = "proxmox"
+		listed = ""
+		result = checkHypervisorVMs(&BootstrapConfig{}, common.NewColorLogger())
+		assert.Equal(t, "PASS", result.Status)
+		assert.Empty(t, listed, "Proxmox is only checked with --check-hypervisor")
+		testutil.Swap(t, &vmlifecycle.GetProxmoxCredentialsFn, func() (string, string, string, string, error) { return "pve", "id", "secret", "node", nil })
+		checkHypervisorVMs(&BootstrapConfig{CheckHypervisor: true}, common.NewColorLogger())
+		assert.Equal(t, "proxmox", listed)
+	})
+
 	t.Run("1password auth and machine rendering use seams", func(t *testing.T) {
 		oldEnsureOPAuth := bootstrapEnsureOPAuth
 		oldRenderMachineConfig := bootstrapRenderMachineConfig
@@ -261,7 +364,7 @@ func TestPreflightCheckHelpers(t *testing.T) {
 		bootstrapRenderMachineConfig = func(_, _, _ string, _ *common.ColorLogger) ([]byte, error) {
 			return []byte("version: v1alpha1"), nil
 		}
-		bootstrapGetTalosNodes = func(string) ([]string, error) { return []string{"10.0.0.10"}, nil }
+		bootstrapGetTalosNodes = func(string) ([]string, error) { return []string{"192.168.122.10"}, nil }
 
 		authResult := check1PasswordAuthPreflight(&BootstrapConfig{}, common.NewColorLogger())
 		if authResult.Status != "PASS" {
@@ -280,6 +383,38 @@ func TestPreflightCheckHelpers(t *testing.T) {
 	})
 }
 
+func TestCheckMachineConfigRenderingReportsEveryNode(t *testing.T) {
+	var rendered []string
+	testutil.Swap(t, &bootstrapGetTalosNodes, func(string) ([]string, error) {
+		return []string{"192.168.122.10", "192.168.122.13", "192.168.122.11", "192.168.122.14"}, nil
+	})
+	testutil.Swap(t, &bootstrapRenderMachineConfig, func(base, patch, _ string, _ *common.ColorLogger) ([]byte, error) {
+		rendered = append(rendered, patch)
+		if patch == "nodes/192.168.122.11.yaml" {
+			return nil, fmt.Errorf("failed to resolve 1Password references in patch config: op://Talos/missing")
+		}
+		return []byte("version: v1alpha1"), nil
+	})
+
+	result := checkMachineConfigRendering(&BootstrapConfig{}, common.NewColorLogger())
+	assert.Equal(t, "FAIL", result.Status)
+	assert.Contains(t, result.Message, "3 of 4 nodes")
+	assert.Contains(t, result.Message, "192.168.122.13 (failed to get node template")
+	assert.Contains(t, result.Message, "192.168.122.11 (failed to resolve 1Password references")
+	assert.Contains(t, result.Message, "192.168.122.14 (failed to get node template")
+	assert.NotContains(t, result.Message, "192.168.122.10")
+	assert.Equal(t, []string{"nodes/192.168.122.10.yaml", "nodes/192.168.122.11.yaml"}, rendered,
+		"a node without a template is not rendered")
+
+	testutil.Swap(t, &bootstrapGetTalosNodes, func(string) ([]string, error) { return nil, fmt.Errorf("talosctl missing") })
+	t.Cleanup(versionconfig.SetForTesting(nil))
+	versionconfig.Get().Cluster.Nodes = []versionconfig.Node{{Name: "k8s-0", IP: "192.168.122.10"}}
+	rendered = nil
+	result = checkMachineConfigRendering(&BootstrapConfig{}, common.NewColorLogger())
+	assert.Equal(t, "PASS", result.Status, result.Message)
+	assert.Equal(t, []string{"nodes/192.168.122.10.yaml"}, rendered, "without a talosconfig the configured nodes are rendered")
+}
+
 func TestGetTalosNodes(t *testing.T) {
 	oldTalosctlOutput := bootstrapTalosctlOutput
 	t.Cleanup(func() { bootstrapTalosctlOutput = oldTalosctlOutput })
@@ -297,6 +432,27 @@ func TestGetTalosNodes(t *testing.T) {
 	}
 }
 
+func TestGetTalosNodesCollapsesDualStackAddresses(t *testing.T) {
+	oldTalosctlOutput := bootstrapTalosctlOutput
+	t.Cleanup(func() { bootstrapTalosctlOutput = oldTalosctlOutput })
+	t.Cleanup(versionconfig.SetForTesting(&versionconfig.Config{Cluster: versionconfig.ClusterConfig{
+		DualStack: true,
+		Nodes:     []versionconfig.Node{{Name: "k8s-0", IP: "10.0.0.10", IPv6: "fd00::10"}},
+	}}))
+
+	bootstrapTalosctlOutput = func(_ string, _ ...string) ([]byte, error) {
+		return []byte(`{"nodes":["fd00::10","10.0.0.10","fd00::11"]}`), nil
+	}
+
+	nodes, err := getTalosNodes("/tmp/talosconfig")
+	if err != nil {
+		t.Fatalf("getTalosNodes returned error: %v", err)
+	}
+	if strings.Join(nodes, ",") != "10.0.0.10,fd00::11" {
+		t.Fatalf("unexpected nodes: %v", nodes)
+	}
+}
+
 func TestGetTalosNodesWithRetry(t *testing.T) {
 	oldGetTalosNodes := bootstrapGetTalosNodes
 	oldSleep := bootstrapSleep
@@ -416,6 +572,81 @@ func TestApplyTalosConfig(t *testing.T) {
 	}
 }
 
+func TestApplyTalosConfigConfiguresNodesInParallel(t *testing.T) {
+	nodes := []string{"10.0.0.10", "10.0.0.11", "10.0.0.12"}
+	testutil.Swap(t, &bootstrapGetTalosNodes, func(string) ([]string, error) { return nodes, nil })
+	testutil.Swap(t, &bootstrapGetMachineType, func(string) (string, error) { return "controlplane", nil })
+	testutil.Swap(t, &bootstrapGetTalosTemplate, func(path string) (string, error) {
+		if path == "talos/controlplane.yaml" {
+			return "version: v1alpha1\nmachine:\n  token: op://vault/talos/token\n", nil
+		}
+		return "machine:\n  network:\n    hostname: " + path + "\n", nil
+	})
+	testutil.Swap(t, &bootstrapMergeTalosConfigs, func(base, patch []byte) ([]byte, error) {
+		return append(base, patch...), nil
+	})
+	var resolving, resolves, baseResolves int
+	var mu sync.Mutex
+	testutil.Swap(t, &bootstrapResolveSecrets, func(content string, _ *common.ColorLogger) (string, error) {
+		mu.Lock()
+		resolving++
+		resolves++
+		if strings.Contains(content, "op://") {
+			baseResolves++
+		}
+		concurrent := resolving
+		mu.Unlock()
+		if concurrent > 1 {
+			t.Errorf("1Password references resolved concurrently")
+		}
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		resolving--
+		mu.Unlock()
+		return strings.ReplaceAll(content, "op://vault/talos/token", "resolved"), nil
+	})
+	// Every apply waits until all three are in flight, so a serial apply
+	// never gets past the first node.
+	var started sync.WaitGroup
+	started.Add(len(nodes))
+	testutil.Swap(t, &bootstrapApplyNodeConfigTry, func(node string, config []byte, _ *common.ColorLogger, _ int) error {
+		started.Done()
+		started.Wait()
+		if strings.Contains(string(config), "op://") {
+			return errors.New("unresolved reference")
+		}
+		if node == "10.0.0.11" {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	spinners := 0
+	testutil.Swap(t, &bootstrapRunWithSpinner, func(_ string, _ bool, _ interface {
+		Info(string, ...interface{})
+		SetQuiet(bool)
+	}, fn func() error) error {
+		spinners++
+		return fn()
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- applyTalosConfig(&BootstrapConfig{MaxParallel: 3}, common.NewColorLogger()) }()
+	select {
+	case err := <-done:
+		if err == nil || err.Error() != "failed to configure nodes: 10.0.0.11" {
+			t.Fatalf("expected only 10.0.0.11 to fail, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("nodes were not configured in parallel")
+	}
+	if spinners != 0 {
+		t.Fatalf("parallel applies ran %d spinners", spinners)
+	}
+	if baseResolves != 1 || resolves != len(nodes)+1 {
+		t.Fatalf("base config resolved %d times (%d resolves), want once plus one patch per node", baseResolves, resolves)
+	}
+}
+
 func TestRenderMachineConfigFromEmbeddedSeam(t *testing.T) {
 	oldGetTalosTemplate := bootstrapGetTalosTemplate
 	oldResolveSecrets := bootstrapResolveSecrets
@@ -696,14 +927,13 @@ clusters:
 func TestApplyCRDsFromHelmfile(t *testing.T) {
 	oldGetBootstrapFile := bootstrapGetBootstrapFile
 	oldHelmfileTemplateOutput := bootstrapHelmfileTemplateOutput
-	oldCombinedIn := bootstrapKubectlCombinedIn
 	oldWaitCRDs := bootstrapWaitCRDs
 	t.Cleanup(func() {
 		bootstrapGetBootstrapFile = oldGetBootstrapFile
 		bootstrapHelmfileTemplateOutput = oldHelmfileTemplateOutput
-		bootstrapKubectlCombinedIn = oldCombinedIn
 		bootstrapWaitCRDs = oldWaitCRDs
 	})
+	cluster := stubBootstrapKube(t)
 
 	bootstrapGetBootstrapFile = func(name string) (string, error) {
 		if name != "helmfile.d/00-crds.yaml" {
@@ -719,6 +949,11 @@ func TestApplyCRDsFromHelmfile(t *testing.T) {
 kind: CustomResourceDefinition
 metadata:
   name: widgets.example.com
+spec:
+  versions:
+    - name: v1
+      served: true
+      storage: true
 ---
 apiVersion: v1
 kind: ConfigMap
@@ -726,21 +961,12 @@ metadata:
   name: ignored
 `), nil
 	}
-	bootstrapKubectlCombinedIn = func(_ *BootstrapConfig, input io.Reader, args ...string) ([]byte, error) {
-		body, err := io.ReadAll(input)
-		if err != nil {
-			t.Fatalf("failed reading CRD apply input: %v", err)
-		}
-		if strings.Contains(string(body), "ConfigMap") || !strings.Contains(string(body), "CustomResourceDefinition") {
-			t.Fatalf("unexpected CRD apply manifest: %q", string(body))
-		}
-		return []byte("applied"), nil
-	}
 	bootstrapWaitCRDs = func(*BootstrapConfig, *common.ColorLogger) error { return nil }
 
 	if err := applyCRDsFromHelmfile(&BootstrapConfig{RootDir: "/repo/home-ops"}, common.NewColorLogger()); err != nil {
 		t.Fatalf("applyCRDsFromHelmfile returned error: %v", err)
 	}
+	assert.Equal(t, []string{"customresourcedefinitions /widgets.example.com"}, appliedObjects(cluster), "only the CRDs are applied")
 }
 
 func TestExecuteHelmfileSync(t *testing.T) {
@@ -784,79 +1010,58 @@ func TestExecuteHelmfileSync(t *testing.T) {
 }
 
 func TestFixExistingCRDMetadata(t *testing.T) {
-	oldKubectlOutput := bootstrapKubectlOutput
-	oldKubectlCombined := bootstrapKubectlCombined
-	t.Cleanup(func() {
-		bootstrapKubectlOutput = oldKubectlOutput
-		bootstrapKubectlCombined = oldKubectlCombined
-	})
-
-	var calls []string
-	bootstrapKubectlOutput = func(_ *BootstrapConfig, args ...string) ([]byte, error) {
-		if strings.Join(args, " ") != "get crds -o json" {
-			t.Fatalf("unexpected kubectl output args: %v", args)
-		}
-		return []byte(`{
-  "items": [
-    {
-      "metadata": {
-        "name": "clustersecretstores.external-secrets.io",
-        "labels": {},
-        "annotations": {}
-      }
-    },
-    {
-      "metadata": {
-        "name": "certificates.cert-manager.io",
-        "labels": {"app.kubernetes.io/managed-by": "Helm"},
-        "annotations": {"meta.helm.sh/release-name": "cert-manager"}
-      }
-    }
-  ]
-}`), nil
-	}
-	bootstrapKubectlCombined = func(_ *BootstrapConfig, args ...string) ([]byte, error) {
-		calls = append(calls, strings.Join(args, " "))
-		return []byte("ok"), nil
-	}
+	crd := func(name string, labels, annotations map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apiextensions.k8s.io/v1",
+			"kind":       "CustomResourceDefinition",
+			"metadata":   map[string]interface{}{"name": name, "labels": labels, "annotations": annotations},
+		}}
+	}
+	cluster := stubBootstrapKube(t,
+		crd("clustersecretstores.external-secrets.io", map[string]interface{}{}, map[string]interface{}{}),
+		crd("certificates.cert-manager.io", map[string]interface{}{"app.kubernetes.io/managed-by": "Helm"}, map[string]interface{}{"meta.helm.sh/release-name": "cert-manager"}),
+		crd("widgets.example.com", map[string]interface{}{}, map[string]interface{}{}),
+	)
 
 	if err := fixExistingCRDMetadata(&BootstrapConfig{}, common.NewColorLogger()); err != nil {
 		t.Fatalf("fixExistingCRDMetadata returned error: %v", err)
 	}
-	if len(calls) != 2 {
-		t.Fatalf("expected annotate and label calls, got %v", calls)
+	var patched []string
+	for _, action := range cluster.DynamicClient.Actions() {
+		if patch, ok := action.(k8stesting.PatchAction); ok {
+			patched = append(patched, patch.GetName())
+		}
 	}
+	assert.Equal(t, []string{"clustersecretstores.external-secrets.io"}, patched, "only the unowned CRD of a known group is patched")
+	store, err := cluster.Get(context.Background(), kube.CRDResource, "", "clustersecretstores.external-secrets.io")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"app.kubernetes.io/managed-by": "Helm"}, store.GetLabels())
+	assert.Equal(t, map[string]string{
+		"meta.helm.sh/release-name":      "external-secrets",
+		"meta.helm.sh/release-namespace": constants.NSExternalSecret,
+	}, store.GetAnnotations())
 }
 
 func TestValidateKubeconfig(t *testing.T) {
 	t.Run("succeeds when api server and nodes are reachable", func(t *testing.T) {
-		oldKubectlRun := bootstrapKubectlRun
-		t.Cleanup(func() { bootstrapKubectlRun = oldKubectlRun })
-
-		var calls []string
-		bootstrapKubectlRun = func(_ *BootstrapConfig, args ...string) error {
-			calls = append(calls, strings.Join(args, " "))
-			return nil
-		}
+		cluster := stubBootstrapKube(t)
 
 		err := validateKubeconfig(&BootstrapConfig{KubeConfig: "/tmp/kubeconfig"}, common.NewColorLogger())
 		if err != nil {
 			t.Fatalf("validateKubeconfig returned error: %v", err)
 		}
-		if len(calls) != 2 {
-			t.Fatalf("expected 2 kubectl calls, got %d (%v)", len(calls), calls)
+		if actions := cluster.Clientset.Actions(); len(actions) != 2 {
+			t.Fatalf("expected 2 API requests, got %d (%v)", len(actions), actions)
 		}
 	})
 
 	t.Run("fails when connectivity stalls", func(t *testing.T) {
-		oldKubectlRun := bootstrapKubectlRun
 		oldNow := bootstrapNow
 		oldSleep := bootstrapSleep
 		oldCheckInterval := bootstrapCheckIntervalNormal
 		oldStallTimeout := bootstrapStallTimeout
 		oldMaxWait := bootstrapKubeconfigMaxWait
 		t.Cleanup(func() {
-			bootstrapKubectlRun = oldKubectlRun
 			bootstrapNow = oldNow
 			bootstrapSleep = oldSleep
 			bootstrapCheckIntervalNormal = oldCheckInterval
@@ -870,7 +1075,7 @@ func TestValidateKubeconfig(t *testing.T) {
 		bootstrapCheckIntervalNormal = time.Second
 		bootstrapStallTimeout = 3 * time.Second
 		bootstrapKubeconfigMaxWait = 10 * time.Second
-		bootstrapKubectlRun = func(_ *BootstrapConfig, _ ...string) error { return errors.New("connection refused") }
+		failBootstrapKube(t, errors.New("connection refused"))
 
 		err := validateKubeconfig(&BootstrapConfig{KubeConfig: "/tmp/kubeconfig"}, common.NewColorLogger())
 		if err == nil || !strings.Contains(err.Error(), "cluster connectivity stalled") {
@@ -995,81 +1200,77 @@ func TestResolve1PasswordReferencesDebugSaveRedactsAndAvoidsWorkingDirectory(t *
 
 func TestApplyNamespaces(t *testing.T) {
 	t.Run("creates all bootstrap namespaces", func(t *testing.T) {
-		oldOutput := bootstrapKubectlOutput
-		oldCombinedIn := bootstrapKubectlCombinedIn
-		t.Cleanup(func() {
-			bootstrapKubectlOutput = oldOutput
-			bootstrapKubectlCombinedIn = oldCombinedIn
-		})
-
-		var generated []string
-		var applied []string
-		bootstrapKubectlOutput = func(_ *BootstrapConfig, args ...string) ([]byte, error) {
-			if len(args) < 3 {
-				t.Fatalf("unexpected kubectl output args: %v", args)
-			}
-			ns := args[2]
-			generated = append(generated, ns)
-			return []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: " + ns + "\n"), nil
-		}
-		bootstrapKubectlCombinedIn = func(_ *BootstrapConfig, input io.Reader, args ...string) ([]byte, error) {
-			body, err := io.ReadAll(input)
-			if err != nil {
-				t.Fatalf("failed to read apply input: %v", err)
-			}
-			applied = append(applied, string(body))
-			if strings.Join(args, " ") != "apply --filename -" {
-				t.Fatalf("unexpected apply args: %v", args)
-			}
-			return []byte("namespace applied"), nil
-		}
+		cluster := stubBootstrapKube(t)
 
 		err := applyNamespaces(&BootstrapConfig{KubeConfig: "/tmp/kubeconfig"}, common.NewColorLogger())
 		if err != nil {
 			t.Fatalf("applyNamespaces returned error: %v", err)
 		}
-		if len(generated) != 18 {
-			t.Fatalf("expected 18 generated namespaces, got %d", len(generated))
-		}
-		if len(applied) != len(generated) {
-			t.Fatalf("expected %d applied manifests, got %d", len(generated), len(applied))
+		namespaces, err := cluster.Clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			t.Fatalf("list namespaces: %v", err)
 		}
-		if !strings.Contains(applied[0], "kind: Namespace") {
-			t.Fatalf("expected namespace manifest, got %q", applied[0])
+		if len(namespaces.Items) != 18 {
+			t.Fatalf("expected 18 namespaces, got %d", len(namespaces.Items))
 		}
 	})
 
-	t.Run("ignores already exists responses", func(t *testing.T) {
-		oldOutput := bootstrapKubectlOutput
-		oldCombinedIn := bootstrapKubectlCombinedIn
-		t.Cleanup(func() {
-			bootstrapKubectlOutput = oldOutput
-			bootstrapKubectlCombinedIn = oldCombinedIn
-		})
-
-		bootstrapKubectlOutput = func(_ *BootstrapConfig, args ...string) ([]byte, error) {
-			return []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: " + args[2] + "\n"), nil
-		}
-		bootstrapKubectlCombinedIn = func(_ *BootstrapConfig, _ io.Reader, _ ...string) ([]byte, error) {
-			return []byte("AlreadyExists"), errors.New("conflict")
-		}
+	t.Run("leaves existing namespaces alone", func(t *testing.T) {
+		cluster := stubBootstrapKube(t, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name: constants.NSFluxSystem, Labels: map[string]string{"existing": "true"},
+		}})
 
 		if err := applyNamespaces(&BootstrapConfig{KubeConfig: "/tmp/kubeconfig"}, common.NewColorLogger()); err != nil {
 			t.Fatalf("applyNamespaces should ignore already-existing namespaces, got %v", err)
 		}
+		namespace, err := cluster.Clientset.CoreV1().Namespaces().Get(context.Background(), constants.NSFluxSystem, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("get namespace: %v", err)
+		}
+		if namespace.Labels["existing"] != "true" {
+			t.Fatalf("existing namespace was replaced: %v", namespace.Labels)
+		}
 	})
 }
 
+func TestApplyClusterSettings(t *testing.T) {
+	restore := versionconfig.SetForTesting(&versionconfig.Config{Cluster: versionconfig.ClusterConfig{Name: "lab", PodCIDR: "10.244.0.0/16"}})
+	defer restore()
+	cluster := stubBootstrapKube(t)
+
+	if err := applyClusterSettings(&BootstrapConfig{DryRun: true}, common.NewColorLogger()); err != nil {
+		t.Fatalf("dry run returned error: %v", err)
+	}
+	if applied := appliedObjects(cluster); len(applied) != 0 {
+		t.Fatalf("dry run must not apply, got %v", applied)
+	}
+
+	if err := applyClusterSettings(&BootstrapConfig{KubeConfig: "/tmp/kubeconfig"}, common.NewColorLogger()); err != nil {
+		t.Fatalf("applyClusterSettings returned error: %v", err)
+	}
+	assert.Equal(t, []string{"configmaps flux-system/cluster-settings"}, appliedObjects(cluster))
+	settings, err := cluster.DynamicClient.Resource(corev1.SchemeGroupVersion.WithResource("configmaps")).
+		Namespace(constants.NSFluxSystem).Get(context.Background(), "cluster-settings", metav1.GetOptions{})
+	require.NoError(t, err)
+	data, _, _ := unstructured.NestedStringMap(settings.Object, "data")
+	assert.Equal(t, "lab", data["CLUSTER_NAME"])
+	assert.Equal(t, "10.244.0.0/16", data["POD_CIDR"])
+	assert.Equal(t, "America/New_York", data["TIMEZONE"])
+
+	failBootstrapKube(t, errors.New("forbidden"))
+	if err := applyClusterSettings(&BootstrapConfig{}, common.NewColorLogger()); err == nil || !strings.Contains(err.Error(), "cluster-settings") {
+		t.Fatalf("expected apply failure naming the ConfigMap, got %v", err)
+	}
+}
+
 func TestWaitHelpersSuccessPaths(t *testing.T) {
 	t.Run("waitForNodesAvailable succeeds when nodes appear", func(t *testing.T) {
-		oldOutput := bootstrapKubectlOutput
 		oldNow := bootstrapNow
 		oldSleep := bootstrapSleep
 		oldCheckInterval := bootstrapCheckIntervalSlow
 		oldStallTimeout := bootstrapStallTimeout
 		oldMaxWait := bootstrapNodeMaxWait
 		t.Cleanup(func() {
-			bootstrapKubectlOutput = oldOutput
 			bootstrapNow = oldNow
 			bootstrapSleep = oldSleep
 			bootstrapCheckIntervalSlow = oldCheckInterval
@@ -1085,13 +1286,16 @@ func TestWaitHelpersSuccessPaths(t *testing.T) {
 		bootstrapNodeMaxWait = 20 * time.Second
 
 		calls := 0
-		bootstrapKubectlOutput = func(_ *BootstrapConfig, _ ...string) ([]byte, error) {
+		cluster := stubBootstrapKube(t)
+		cluster.Clientset.PrependReactor("list", "nodes", func(k8stesting.Action) (bool, k8sruntime.Object, error) {
 			calls++
 			if calls == 1 {
-				return []byte(""), nil
+				return true, &corev1.NodeList{}, nil
 			}
-			return []byte("node1 node2"), nil
-		}
+			return true, &corev1.NodeList{Items: []corev1.Node{
+				{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}, {ObjectMeta: metav1.ObjectMeta{Name: "node2"}},
+			}}, nil
+		})
 
 		if err := waitForNodesAvailable(&BootstrapConfig{}, common.NewColorLogger()); err != nil {
 			t.Fatalf("waitForNodesAvailable returned error: %v", err)
@@ -1099,14 +1303,12 @@ func TestWaitHelpersSuccessPaths(t *testing.T) {
 	})
 
 	t.Run("waitForNodesReadyFalse succeeds when all nodes are False", func(t *testing.T) {
-		oldOutput := bootstrapKubectlOutput
 		oldNow := bootstrapNow
 		oldSleep := bootstrapSleep
 		oldCheckInterval := bootstrapCheckIntervalSlow
 		oldStallTimeout := bootstrapStallTimeout
 		oldMaxWait := bootstrapNodeMaxWait
 		t.Cleanup(func() {
-			bootstrapKubectlOutput = oldOutput
 			bootstrapNow = oldNow
 			bootstrapSleep = oldSleep
 			bootstrapCheckIntervalSlow = oldCheckInterval
@@ -1121,9 +1323,11 @@ func TestWaitHelpersSuccessPaths(t *testing.T) {
 		bootstrapStallTimeout = 5 * time.Second
 		bootstrapNodeMaxWait = 20 * time.Second
 
-		bootstrapKubectlOutput = func(_ *BootstrapConfig, _ ...string) ([]byte, error) {
-			return []byte("node1:False\nnode2:False"), nil
-		}
+		notReady := corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionFalse}}}
+		stubBootstrapKube(t,
+			&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}, Status: notReady},
+			&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node2"}, Status: notReady},
+		)
 
 		if err := waitForNodesReadyFalse(&BootstrapConfig{}, common.NewColorLogger()); err != nil {
 			t.Fatalf("waitForNodesReadyFalse returned error: %v", err)
@@ -1131,14 +1335,12 @@ func TestWaitHelpersSuccessPaths(t *testing.T) {
 	})
 
 	t.Run("waitForExternalSecretsWebhook succeeds with endpoints", func(t *testing.T) {
-		oldOutput := bootstrapKubectlOutput
 		oldNow := bootstrapNow
 		oldSleep := bootstrapSleep
 		oldCheckInterval := bootstrapCheckIntervalNormal
 		oldStallTimeout := bootstrapStallTimeout
 		oldMaxWait := bootstrapExtSecMaxWait
 		t.Cleanup(func() {
-			bootstrapKubectlOutput = oldOutput
 			bootstrapNow = oldNow
 			bootstrapSleep = oldSleep
 			bootstrapCheckIntervalNormal = oldCheckInterval
@@ -1153,17 +1355,21 @@ func TestWaitHelpersSuccessPaths(t *testing.T) {
 		bootstrapStallTimeout = 5 * time.Second
 		bootstrapExtSecMaxWait = 20 * time.Second
 
-		bootstrapKubectlOutput = func(_ *BootstrapConfig, args ...string) ([]byte, error) {
-			joined := strings.Join(args, " ")
-			switch {
-			case strings.Contains(joined, "get deployment external-secrets-webhook"):
-				return []byte("1/1:True"), nil
-			case strings.Contains(joined, "get endpoints external-secrets-webhook"):
-				return []byte("10.0.0.25"), nil
-			default:
-				return nil, errors.New("unexpected command")
-			}
-		}
+		stubBootstrapKube(t,
+			&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "external-secrets-webhook", Namespace: constants.NSExternalSecret},
+				Status: appsv1.DeploymentStatus{Replicas: 1, ReadyReplicas: 1, Conditions: []appsv1.DeploymentCondition{
+					{Type: appsv1.DeploymentAvailable, Status: corev1.ConditionTrue},
+				}},
+			},
+			&discoveryv1.EndpointSlice{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "external-secrets-webhook-x", Namespace: constants.NSExternalSecret,
+					Labels: map[string]string{discoveryv1.LabelServiceName: "external-secrets-webhook"},
+				},
+				Endpoints: []discoveryv1.Endpoint{{Addresses: []string{"10.0.0.25"}}},
+			},
+		)
 
 		if err := waitForExternalSecretsWebhook(&BootstrapConfig{}, common.NewColorLogger()); err != nil {
 			t.Fatalf("waitForExternalSecretsWebhook returned error: %v", err)
@@ -1171,14 +1377,12 @@ func TestWaitHelpersSuccessPaths(t *testing.T) {
 	})
 
 	t.Run("waitForFluxController succeeds when deployment reaches ready", func(t *testing.T) {
-		oldOutput := bootstrapKubectlOutput
 		oldNow := bootstrapNow
 		oldSleep := bootstrapSleep
 		oldCheckInterval := bootstrapCheckIntervalNormal
 		oldStallTimeout := bootstrapStallTimeout
 		oldMaxWait := bootstrapFluxMaxWait
 		t.Cleanup(func() {
-			bootstrapKubectlOutput = oldOutput
 			bootstrapNow = oldNow
 			bootstrapSleep = oldSleep
 			bootstrapCheckIntervalNormal = oldCheckInterval
@@ -1194,16 +1398,18 @@ func TestWaitHelpersSuccessPaths(t *testing.T) {
 		bootstrapFluxMaxWait = 20 * time.Second
 
 		calls := 0
-		bootstrapKubectlOutput = func(_ *BootstrapConfig, args ...string) ([]byte, error) {
-			calls++
-			if !strings.Contains(strings.Join(args, " "), "source-controller") {
-				t.Fatalf("unexpected controller args: %v", args)
+		cluster := stubBootstrapKube(t)
+		cluster.Clientset.PrependReactor("get", "deployments", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+			if name := action.(k8stesting.GetAction).GetName(); name != "source-controller" {
+				t.Fatalf("unexpected controller: %s", name)
 			}
-			if calls == 1 {
-				return []byte("0/1"), nil
+			calls++
+			ready := int32(0)
+			if calls > 1 {
+				ready = 1
 			}
-			return []byte("1/1"), nil
-		}
+			return true, &appsv1.Deployment{Status: appsv1.DeploymentStatus{Replicas: 1, ReadyReplicas: ready}}, nil
+		})
 
 		if err := waitForFluxController(&BootstrapConfig{}, common.NewColorLogger(), "source-controller"); err != nil {
 			t.Fatalf("waitForFluxController returned error: %v", err)
@@ -1227,7 +1433,7 @@ func TestSaveRenderedConfig(t *testing.T) {
 }
 
 func TestApplyClusterSecretStore(t *testing.T) {
-	validSecretStore := `apiVersion: external-secrets.io/v1beta1
+	validSecretStore := `apiVersion: external-secrets.io/v1
 kind: ClusterSecretStore
 metadata:
   name: onepassword
@@ -1268,55 +1474,39 @@ spec:
 	t.Run("applies rendered manifest to external-secrets namespace", func(t *testing.T) {
 		oldGetBootstrapFile := bootstrapGetBootstrapFile
 		oldResolveSecrets := bootstrapResolveSecrets
-		oldCombinedIn := bootstrapKubectlCombinedIn
 		t.Cleanup(func() {
 			bootstrapGetBootstrapFile = oldGetBootstrapFile
 			bootstrapResolveSecrets = oldResolveSecrets
-			bootstrapKubectlCombinedIn = oldCombinedIn
 		})
 
 		bootstrapGetBootstrapFile = func(string) (string, error) { return validSecretStore, nil }
 		bootstrapResolveSecrets = func(content string, _ *common.ColorLogger) (string, error) {
 			return strings.ReplaceAll(content, "onepassword-connect-token", "resolved-token"), nil
 		}
-		bootstrapKubectlCombinedIn = func(_ *BootstrapConfig, input io.Reader, args ...string) ([]byte, error) {
-			body, err := io.ReadAll(input)
-			if err != nil {
-				t.Fatalf("failed to read cluster secret store input: %v", err)
-			}
-			if !strings.Contains(string(body), "resolved-token") {
-				t.Fatalf("expected resolved content, got %q", string(body))
-			}
-
-			got := strings.Join(args, " ")
-			want := "apply --namespace " + constants.NSExternalSecret + " --server-side --force-conflicts --filename - --wait=true"
-			if got != want {
-				t.Fatalf("unexpected kubectl args: got %q want %q", got, want)
-			}
-			return []byte("applied"), nil
-		}
+		cluster := stubBootstrapKube(t)
 
 		err := applyClusterSecretStore(&BootstrapConfig{KubeConfig: "/tmp/kubeconfig"}, common.NewColorLogger())
 		if err != nil {
 			t.Fatalf("applyClusterSecretStore returned error: %v", err)
 		}
+		assert.Equal(t, []string{"clustersecretstores /onepassword"}, appliedObjects(cluster))
+		store, err := cluster.Get(context.Background(), kube.ClusterSecretStoreResource, "", "onepassword")
+		require.NoError(t, err)
+		name, _, _ := unstructured.NestedString(store.Object, "spec", "provider", "onepassword", "auth", "secretRef", "connectTokenSecretRef", "name")
+		assert.Equal(t, "resolved-token", name)
 	})
 
 	t.Run("redacts apply errors", func(t *testing.T) {
 		oldGetBootstrapFile := bootstrapGetBootstrapFile
 		oldResolveSecrets := bootstrapResolveSecrets
-		oldCombinedIn := bootstrapKubectlCombinedIn
 		t.Cleanup(func() {
 			bootstrapGetBootstrapFile = oldGetBootstrapFile
 			bootstrapResolveSecrets = oldResolveSecrets
-			bootstrapKubectlCombinedIn = oldCombinedIn
 		})
 
 		bootstrapGetBootstrapFile = func(string) (string, error) { return validSecretStore, nil }
 		bootstrapResolveSecrets = func(content string, _ *common.ColorLogger) (string, error) { return content, nil }
-		bootstrapKubectlCombinedIn = func(_ *BootstrapConfig, _ io.Reader, _ ...string) ([]byte, error) {
-			return []byte("password: synthetic-test-fixture"), errors.New("apply failed")
-		}
+		failBootstrapKube(t, errors.New("admission webhook denied: password: synthetic-test-fixture"))
 
 		err := applyClusterSecretStore(&BootstrapConfig{KubeConfig: "/tmp/kubeconfig"}, common.NewColorLogger())
 		if err == nil {
@@ -1380,48 +1570,34 @@ metadata:
 	t.Run("applies resolved resources", func(t *testing.T) {
 		oldGetBootstrapFile := bootstrapGetBootstrapFile
 		oldResolveSecrets := bootstrapResolveSecrets
-		oldCombinedIn := bootstrapKubectlCombinedIn
 		t.Cleanup(func() {
 			bootstrapGetBootstrapFile = oldGetBootstrapFile
 			bootstrapResolveSecrets = oldResolveSecrets
-			bootstrapKubectlCombinedIn = oldCombinedIn
 		})
 
 		bootstrapGetBootstrapFile = func(string) (string, error) { return resourcesYAML, nil }
 		bootstrapResolveSecrets = func(content string, _ *common.ColorLogger) (string, error) {
 			return strings.ReplaceAll(content, "cloudflare-tunnel-id-secret", "resolved-cloudflare-secret"), nil
 		}
-		bootstrapKubectlCombinedIn = func(_ *BootstrapConfig, input io.Reader, args ...string) ([]byte, error) {
-			body, err := io.ReadAll(input)
-			if err != nil {
-				t.Fatalf("failed to read resources input: %v", err)
-			}
-			if !strings.Contains(string(body), "resolved-cloudflare-secret") {
-				t.Fatalf("expected resolved resources, got %q", string(body))
-			}
-			return []byte("applied"), nil
-		}
+		cluster := stubBootstrapKube(t)
 
 		if err := applyResources(&BootstrapConfig{KubeConfig: "/tmp/kubeconfig"}, common.NewColorLogger()); err != nil {
 			t.Fatalf("applyResources returned error: %v", err)
 		}
+		assert.Equal(t, []string{"secrets default/onepassword-secret", "secrets default/resolved-cloudflare-secret"}, appliedObjects(cluster))
 	})
 
 	t.Run("redacts apply errors", func(t *testing.T) {
 		oldGetBootstrapFile := bootstrapGetBootstrapFile
 		oldResolveSecrets := bootstrapResolveSecrets
-		oldCombinedIn := bootstrapKubectlCombinedIn
 		t.Cleanup(func() {
 			bootstrapGetBootstrapFile = oldGetBootstrapFile
 			bootstrapResolveSecrets = oldResolveSecrets
-			bootstrapKubectlCombinedIn = oldCombinedIn
 		})
 
 		bootstrapGetBootstrapFile = func(string) (string, error) { return resourcesYAML, nil }
 		bootstrapResolveSecrets = func(content string, _ *common.ColorLogger) (string, error) { return content, nil }
-		bootstrapKubectlCombinedIn = func(_ *BootstrapConfig, _ io.Reader, _ ...string) ([]byte, error) {
-			return []byte("token: synthetic-test-fixture"), errors.New("apply failed")
-		}
+		failBootstrapKube(t, errors.New("admission webhook denied: token: synthetic-test-fixture"))
 
 		err := applyResources(&BootstrapConfig{KubeConfig: "/tmp/kubeconfig"}, common.NewColorLogger())
 		if err == nil {
@@ -1433,6 +1609,71 @@ metadata:
 	})
 }
 
+func TestBootstrapResolvedSecretsNeverReachOutputSinks(t *testing.T) {
+	defer common.ResetSecretRegistryForTesting()()
+	values := map[string]string{
+		"op://Homelab/spice/password":              "spice-Pa55w0rd-xyz",
+		"op://Homelab/talos/secretboxEncryption":   "dGFsb3Mtc2VjcmV0Ym94LWtleS0wMQ==",
+		"op://Homelab/cloudflare/api-token-value":  "cf-api-token-0123456789abcdef",
+		"op://Homelab/kubeconfig/client-key-value": "LS0tLS1CRUdJTiBFQyBQUklWQVRF",
+	}
+	defer secrets.SetOpReadFnForTesting(func(reference string) (common.CommandResult, error) {
+		return common.CommandResult{Stdout: values[reference] + "\n"}, nil
+	})()
+
+	var resources strings.Builder
+	for _, ref := range slices.Sorted(maps.Keys(values)) {
+		fmt.Fprintf(&resources, "---\napiVersion: v1\nkind: Secret\nmetadata:\n  name: %s\nstringData:\n  value: %s\n", strings.ToLower(path.Base(ref)), ref)
+	}
+
+	oldNoColor, oldOutput, oldError := color.NoColor, color.Output, color.Error
+	color.NoColor = true
+	var logs bytes.Buffer
+	color.Output, color.Error = &logs, &logs
+	oldGetBootstrapFile := bootstrapGetBootstrapFile
+	t.Cleanup(func() {
+		color.NoColor, color.Output, color.Error = oldNoColor, oldOutput, oldError
+		bootstrapGetBootstrapFile = oldGetBootstrapFile
+	})
+	bootstrapGetBootstrapFile = func(string) (string, error) { return resources.String(), nil }
+	// The API server rejecting the manifest echoes it back, raw and
+	// base64-encoded, the way admission webhooks and server-side apply
+	// conflicts do.
+	logger := &common.ColorLogger{Level: common.DebugLevel}
+	cluster := stubBootstrapKube(t)
+	var echoed strings.Builder
+	applied := 0
+	cluster.DynamicClient.PrependReactor("patch", "secrets", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+		body := action.(k8stesting.PatchAction).GetPatch()
+		logger.Debug("apply input:\n%s", body)
+		echoed.Write(body)
+		if applied++; applied < len(values) {
+			return false, nil, nil
+		}
+		for _, value := range values {
+			echoed.WriteString("data: " + base64.StdEncoding.EncodeToString([]byte(value)) + "\n")
+		}
+		return true, nil, errors.New(echoed.String())
+	})
+
+	err := applyResources(&BootstrapConfig{KubeConfig: "/tmp/kubeconfig"}, logger)
+	require.Error(t, err)
+
+	sinks := map[string]string{
+		"error":       err.Error(),
+		"logs":        logs.String(),
+		"final error": common.RedactError(fmt.Errorf("bootstrap failed: %w", err)).Error(),
+	}
+	for name, output := range sinks {
+		for ref, value := range values {
+			assert.NotContains(t, output, value, "%s leaked %s", name, ref)
+			assert.NotContains(t, output, base64.StdEncoding.EncodeToString([]byte(value)), "%s leaked encoded %s", name, ref)
+		}
+	}
+	assert.Contains(t, err.Error(), "<redacted:Homelab/spice/password>")
+	assert.Contains(t, logs.String(), "<redacted:Homelab/talos/secretboxEncryption>")
+}
+
 func TestApplyCRDs(t *testing.T) {
 	t.Run("runs gateway and helmfile stages", func(t *testing.T) {
 		oldApplyGateway := bootstrapApplyGatewayCRDs
@@ -1486,7 +1727,7 @@ apiVersion: v1
 kind: ConfigMap
 metadata:
   name: app-config
-`)
+`, nil)
 	if err != nil {
 		t.Fatalf("separateCRDsFromManifests returned error: %v", err)
 	}
@@ -1495,16 +1736,31 @@ metadata:
 	}
 }
 
+// establishedCRDList is a CRD list of one CRD whose Established condition
+// has status.
+func establishedCRDList(name, status string) *unstructured.UnstructuredList {
+	list := &unstructured.UnstructuredList{}
+	list.SetAPIVersion("apiextensions.k8s.io/v1")
+	list.SetKind("CustomResourceDefinitionList")
+	list.Items = []unstructured.Unstructured{{Object: map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]interface{}{"name": name},
+		"status": map[string]interface{}{"conditions": []interface{}{
+			map[string]interface{}{"type": "Established", "status": status},
+		}},
+	}}}
+	return list
+}
+
 func TestWaitForCRDsEstablished(t *testing.T) {
 	t.Run("succeeds when all CRDs become established", func(t *testing.T) {
-		oldOutput := bootstrapKubectlOutput
 		oldNow := bootstrapNow
 		oldSleep := bootstrapSleep
 		oldCheckInterval := bootstrapCheckIntervalFast
 		oldStallTimeout := bootstrapStallTimeout
 		oldMaxWait := bootstrapCRDMaxWait
 		t.Cleanup(func() {
-			bootstrapKubectlOutput = oldOutput
 			bootstrapNow = oldNow
 			bootstrapSleep = oldSleep
 			bootstrapCheckIntervalFast = oldCheckInterval
@@ -1520,13 +1776,14 @@ func TestWaitForCRDsEstablished(t *testing.T) {
 		bootstrapCRDMaxWait = 20 * time.Second
 
 		calls := 0
-		bootstrapKubectlOutput = func(_ *BootstrapConfig, _ ...string) ([]byte, error) {
+		cluster := stubBootstrapKube(t)
+		cluster.DynamicClient.PrependReactor("list", "customresourcedefinitions", func(k8stesting.Action) (bool, k8sruntime.Object, error) {
 			calls++
 			if calls == 1 {
-				return []byte("widgets.example.com:False"), nil
+				return true, establishedCRDList("widgets.example.com", "False"), nil
 			}
-			return []byte("widgets.example.com:True"), nil
-		}
+			return true, establishedCRDList("widgets.example.com", "True"), nil
+		})
 
 		if err := waitForCRDsEstablished(&BootstrapConfig{}, common.NewColorLogger()); err != nil {
 			t.Fatalf("waitForCRDsEstablished returned error: %v", err)
@@ -1534,14 +1791,12 @@ func TestWaitForCRDsEstablished(t *testing.T) {
 	})
 
 	t.Run("fails when establishment stalls", func(t *testing.T) {
-		oldOutput := bootstrapKubectlOutput
 		oldNow := bootstrapNow
 		oldSleep := bootstrapSleep
 		oldCheckInterval := bootstrapCheckIntervalFast
 		oldStallTimeout := bootstrapStallTimeout
 		oldMaxWait := bootstrapCRDMaxWait
 		t.Cleanup(func() {
-			bootstrapKubectlOutput = oldOutput
 			bootstrapNow = oldNow
 			bootstrapSleep = oldSleep
 			bootstrapCheckIntervalFast = oldCheckInterval
@@ -1555,23 +1810,61 @@ func TestWaitForCRDsEstablished(t *testing.T) {
 		bootstrapCheckIntervalFast = time.Second
 		bootstrapStallTimeout = 3 * time.Second
 		bootstrapCRDMaxWait = 10 * time.Second
-		bootstrapKubectlOutput = func(_ *BootstrapConfig, _ ...string) ([]byte, error) {
-			return []byte("widgets.example.com:False"), nil
-		}
+		cluster := stubBootstrapKube(t)
+		cluster.DynamicClient.PrependReactor("list", "customresourcedefinitions", func(k8stesting.Action) (bool, k8sruntime.Object, error) {
+			return true, establishedCRDList("widgets.example.com", "False"), nil
+		})
 
 		err := waitForCRDsEstablished(&BootstrapConfig{}, common.NewColorLogger())
 		if err == nil || !strings.Contains(err.Error(), "CRD establishment stalled") {
 			t.Fatalf("expected CRD stall error, got %v", err)
 		}
 	})
+
+	t.Run("wakes on a watch event instead of the next poll", func(t *testing.T) {
+		testutil.Swap(t, &bootstrapCheckIntervalFast, time.Hour)
+		testutil.Swap(t, &bootstrapStallTimeout, 2*time.Hour)
+		testutil.Swap(t, &bootstrapCRDMaxWait, 2*time.Hour)
+		testutil.Swap(t, &bootstrapSleep, func(time.Duration) { t.Error("a watched wait must not poll") })
+
+		var established atomic.Bool
+		listed := make(chan struct{}, 1)
+		cluster := stubBootstrapKube(t)
+		cluster.DynamicClient.PrependReactor("list", "customresourcedefinitions", func(k8stesting.Action) (bool, k8sruntime.Object, error) {
+			select {
+			case listed <- struct{}{}:
+			default:
+			}
+			if established.Load() {
+				return true, establishedCRDList("widgets.example.com", "True"), nil
+			}
+			return true, establishedCRDList("widgets.example.com", "False"), nil
+		})
+		events := watch.NewFake()
+		cluster.DynamicClient.PrependWatchReactor("customresourcedefinitions", func(k8stesting.Action) (bool, watch.Interface, error) {
+			return true, events, nil
+		})
+
+		done := make(chan error, 1)
+		go func() { done <- waitForCRDsEstablished(&BootstrapConfig{}, common.NewColorLogger()) }()
+		<-listed
+		list := establishedCRDList("widgets.example.com", "True")
+		established.Store(true)
+		events.Modify(&list.Items[0])
+
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("waitForCRDsEstablished did not react to the Established event")
+		}
+	})
 }
 
 func TestIsExternalSecretsInstalled(t *testing.T) {
-	oldKubectlRun := bootstrapKubectlRun
 	oldSleep := bootstrapSleep
 	oldCheckInterval := bootstrapCheckIntervalNormal
 	t.Cleanup(func() {
-		bootstrapKubectlRun = oldKubectlRun
 		bootstrapSleep = oldSleep
 		bootstrapCheckIntervalNormal = oldCheckInterval
 	})
@@ -1579,16 +1872,17 @@ func TestIsExternalSecretsInstalled(t *testing.T) {
 	attempts := 0
 	bootstrapCheckIntervalNormal = time.Millisecond
 	bootstrapSleep = func(time.Duration) {}
-	bootstrapKubectlRun = func(_ *BootstrapConfig, args ...string) error {
+	cluster := stubBootstrapKube(t)
+	cluster.Clientset.PrependReactor("get", "deployments", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
 		attempts++
-		if !strings.Contains(strings.Join(args, " "), "external-secrets-webhook") {
-			t.Fatalf("unexpected kubectl args: %v", args)
+		if name := action.(k8stesting.GetAction).GetName(); name != "external-secrets-webhook" {
+			t.Fatalf("unexpected deployment: %s", name)
 		}
 		if attempts < 2 {
-			return errors.New("not found")
+			return true, nil, errors.New("not found")
 		}
-		return nil
-	}
+		return true, &appsv1.Deployment{}, nil
+	})
 
 	if !isExternalSecretsInstalled(&BootstrapConfig{}, common.NewColorLogger()) {
 		t.Fatal("expected external-secrets deployment to be detected")
@@ -1711,12 +2005,7 @@ func TestWaitForNodes(t *testing.T) {
 }
 
 func TestCheckIfNodesReady(t *testing.T) {
-	oldOutput := bootstrapKubectlOutput
-	t.Cleanup(func() { bootstrapKubectlOutput = oldOutput })
-
-	bootstrapKubectlOutput = func(_ *BootstrapConfig, _ ...string) ([]byte, error) {
-		return []byte("node1:True\nnode2:True\n"), nil
-	}
+	stubBootstrapKube(t, checkTestNodes("True", "True")...)
 
 	ready, err := checkIfNodesReady(&BootstrapConfig{}, common.NewColorLogger())
 	if err != nil {
@@ -1729,44 +2018,34 @@ func TestCheckIfNodesReady(t *testing.T) {
 
 func TestAPIServerConnectivity(t *testing.T) {
 	t.Run("passes through success", func(t *testing.T) {
-		oldCombined := bootstrapKubectlCombined
-		t.Cleanup(func() { bootstrapKubectlCombined = oldCombined })
-		bootstrapKubectlCombined = func(_ *BootstrapConfig, args ...string) ([]byte, error) {
-			if strings.Join(args, " ") != "cluster-info --request-timeout=10s" {
-				t.Fatalf("unexpected kubectl args: %v", args)
-			}
-			return []byte("ok"), nil
-		}
+		cluster := stubBootstrapKube(t)
 
 		if err := testAPIServerConnectivity(&BootstrapConfig{}, common.NewColorLogger()); err != nil {
 			t.Fatalf("testAPIServerConnectivity returned error: %v", err)
 		}
+		if actions := cluster.Clientset.Actions(); len(actions) != 1 || actions[0].GetResource().Resource != "version" {
+			t.Fatalf("expected a single version request, got %v", actions)
+		}
 	})
 
-	t.Run("wraps cluster-info failures", func(t *testing.T) {
-		oldCombined := bootstrapKubectlCombined
-		t.Cleanup(func() { bootstrapKubectlCombined = oldCombined })
-		bootstrapKubectlCombined = func(_ *BootstrapConfig, _ ...string) ([]byte, error) {
-			return []byte("connection refused"), errors.New("boom")
-		}
+	t.Run("wraps API server failures", func(t *testing.T) {
+		failBootstrapKube(t, errors.New("connection refused"))
 
 		err := testAPIServerConnectivity(&BootstrapConfig{}, common.NewColorLogger())
-		if err == nil || !strings.Contains(err.Error(), "cluster-info failed") {
-			t.Fatalf("expected cluster-info failure, got %v", err)
+		if err == nil || !strings.Contains(err.Error(), "API server unreachable: connection refused") {
+			t.Fatalf("expected API server failure, got %v", err)
 		}
 	})
 }
 
 func TestWaitForGitRepositoryReady(t *testing.T) {
 	t.Run("succeeds when repository reports ready", func(t *testing.T) {
-		oldOutput := bootstrapKubectlOutput
 		oldNow := bootstrapNow
 		oldSleep := bootstrapSleep
 		oldCheckInterval := bootstrapCheckIntervalNormal
 		oldStallTimeout := bootstrapStallTimeout
 		oldMaxWait := bootstrapFluxMaxWait
 		t.Cleanup(func() {
-			bootstrapKubectlOutput = oldOutput
 			bootstrapNow = oldNow
 			bootstrapSleep = oldSleep
 			bootstrapCheckIntervalNormal = oldCheckInterval
@@ -1781,12 +2060,8 @@ func TestWaitForGitRepositoryReady(t *testing.T) {
 		bootstrapStallTimeout = 4 * time.Second
 		bootstrapFluxMaxWait = 20 * time.Second
 
-		bootstrapKubectlOutput = func(_ *BootstrapConfig, args ...string) ([]byte, error) {
-			if strings.Contains(strings.Join(args, " "), "gitrepository flux-system") {
-				return []byte("True:Succeeded:main/abc123"), nil
-			}
-			return []byte(""), nil
-		}
+		stubBootstrapKube(t, checkTestObject("source.toolkit.fluxcd.io/v1", "GitRepository", constants.NSFluxSystem, "flux-system", "True", "Succeeded",
+			map[string]interface{}{"artifact": map[string]interface{}{"revision": "main/abc123"}}))
 
 		if err := waitForGitRepositoryReady(&BootstrapConfig{}, common.NewColorLogger()); err != nil {
 			t.Fatalf("waitForGitRepositoryReady returned error: %v", err)
@@ -1796,14 +2071,12 @@ func TestWaitForGitRepositoryReady(t *testing.T) {
 
 func TestWaitForFluxKustomizationReady(t *testing.T) {
 	t.Run("succeeds when kustomization reports ready", func(t *testing.T) {
-		oldOutput := bootstrapKubectlOutput
 		oldNow := bootstrapNow
 		oldSleep := bootstrapSleep
 		oldCheckInterval := bootstrapCheckIntervalNormal
 		oldStallTimeout := bootstrapStallTimeout
 		oldMaxWait := bootstrapFluxMaxWait
 		t.Cleanup(func() {
-			bootstrapKubectlOutput = oldOutput
 			bootstrapNow = oldNow
 			bootstrapSleep = oldSleep
 			bootstrapCheckIntervalNormal = oldCheckInterval
@@ -1818,12 +2091,8 @@ func TestWaitForFluxKustomizationReady(t *testing.T) {
 		bootstrapStallTimeout = 4 * time.Second
 		bootstrapFluxMaxWait = 20 * time.Second
 
-		bootstrapKubectlOutput = func(_ *BootstrapConfig, args ...string) ([]byte, error) {
-			if strings.Contains(strings.Join(args, " "), "kustomization cluster") {
-				return []byte("True:ReconciliationSucceeded:main/abc123"), nil
-			}
-			return []byte(""), nil
-		}
+		stubBootstrapKube(t, checkTestObject("kustomize.toolkit.fluxcd.io/v1", "Kustomization", constants.NSFluxSystem, "cluster", "True", "ReconciliationSucceeded",
+			map[string]interface{}{"lastAppliedRevision": "main/abc123"}))
 
 		if err := waitForFluxKustomizationReady(&BootstrapConfig{}, common.NewColorLogger(), "cluster"); err != nil {
 			t.Fatalf("waitForFluxKustomizationReady returned error: %v", err)
@@ -1984,10 +2253,12 @@ func TestRunBootstrapLoadsVersionsFromSeam(t *testing.T) {
 	oldValidateKubeconfig := bootstrapValidateKubeconfig
 	oldWaitForNodes := bootstrapWaitForNodes
 	oldApplyNamespaces := bootstrapApplyNamespaces
+	oldApplyClusterSettings := bootstrapApplyClusterSettings
 	oldApplyResources := bootstrapApplyResources
 	oldApplyCRDs := bootstrapApplyCRDs
 	oldSyncHelmReleases := bootstrapSyncHelmReleases
 	oldWaitForFlux := bootstrapWaitForFlux
+	oldWaitGateways := bootstrapWaitGateways
 	oldGetVersions := bootstrapGetVersions
 
 	t.Cleanup(func() {
@@ -2001,10 +2272,12 @@ func TestRunBootstrapLoadsVersionsFromSeam(t *testing.T) {
 		bootstrapValidateKubeconfig = oldValidateKubeconfig
 		bootstrapWaitForNodes = oldWaitForNodes
 		bootstrapApplyNamespaces = oldApplyNamespaces
+		bootstrapApplyClusterSettings = oldApplyClusterSettings
 		bootstrapApplyResources = oldApplyResources
 		bootstrapApplyCRDs = oldApplyCRDs
 		bootstrapSyncHelmReleases = oldSyncHelmReleases
 		bootstrapWaitForFlux = oldWaitForFlux
+		bootstrapWaitGateways = oldWaitGateways
 		bootstrapGetVersions = oldGetVersions
 	})
 
@@ -2023,10 +2296,12 @@ func TestRunBootstrapLoadsVersionsFromSeam(t *testing.T) {
 	bootstrapValidateKubeconfig = func(*BootstrapConfig, *common.ColorLogger) error { return nil }
 	bootstrapWaitForNodes = func(*BootstrapConfig, *common.ColorLogger) error { return nil }
 	bootstrapApplyNamespaces = func(*BootstrapConfig, *common.ColorLogger) error { return nil }
+	bootstrapApplyClusterSettings = func(*BootstrapConfig, *common.ColorLogger) error { return nil }
 	bootstrapApplyResources = func(*BootstrapConfig, *common.ColorLogger) error { return nil }
 	bootstrapApplyCRDs = func(*BootstrapConfig, *common.ColorLogger) error { return nil }
 	bootstrapSyncHelmReleases = func(*BootstrapConfig, *common.ColorLogger) error { return nil }
 	bootstrapWaitForFlux = func(*BootstrapConfig, *common.ColorLogger) error { return nil }
+	bootstrapWaitGateways = func(*BootstrapConfig, *common.ColorLogger) error { return nil }
 	bootstrapGetVersions = func(rootDir string) *versionconfig.VersionConfig {
 		if rootDir != "/repo/home-ops" {
 			t.Fatalf("unexpected root dir for versions: %s", rootDir)
@@ -2053,3 +2328,103 @@ func TestRunBootstrapLoadsVersionsFromSeam(t *testing.T) {
 		t.Fatalf("expected versions to be loaded from seam, got %+v", config)
 	}
 }
+
+func TestResolve1PasswordReferencesMasksTemplatedValuesAndUnsafeSaveKeepsThem(t *testing.T) {
+	t.Cleanup(common.ResetSecretRegistryForTesting())
+	oldInjectSecrets := bootstrapInjectSecrets
+	t.Cleanup(func() {
+		bootstrapInjectSecrets = oldInjectSecrets
+		unsafeSaveRenderedSecrets = false
+	})
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(constants.EnvDebug, "1")
+
+	const (
+		templatedValue = "fake-templated-secret"
+		injectedValue  = "fake-injected-machine-token"
+	)
+	common.RegisterSecret("secret:templated", templatedValue)
+	bootstrapInjectSecrets = func(content string) (string, error) {
+		return strings.ReplaceAll(content, "op://vault/item/token", injectedValue), nil
+	}
+	content := "token: op://vault/item/token\nkey: " + templatedValue + "\n"
+
+	saved := func() string {
+		t.Helper()
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			t.Fatalf("failed to get user cache dir: %v", err)
+		}
+		matches, err := filepath.Glob(filepath.Join(userCacheDir, "homeops-cli", "rendered-configs", "rendered-config-*.yaml"))
+		if err != nil || len(matches) != 1 {
+			t.Fatalf("expected one debug artifact, got %v (%v)", matches, err)
+		}
+		data, err := os.ReadFile(matches[0])
+		if err != nil {
+			t.Fatalf("failed reading debug artifact: %v", err)
+		}
+		if err := os.Remove(matches[0]); err != nil {
+			t.Fatalf("failed removing debug artifact: %v", err)
+		}
+		return string(data)
+	}
+
+	if _, err := resolve1PasswordReferences(content, common.NewColorLogger()); err != nil {
+		t.Fatalf("resolve1PasswordReferences returned error: %v", err)
+	}
+	if got := saved(); strings.Contains(got, templatedValue) || strings.Contains(got, injectedValue) {
+		t.Fatalf("debug artifact contains secret values: %q", got)
+	}
+	if got := common.RedactSecrets("echo " + injectedValue); strings.Contains(got, injectedValue) {
+		t.Fatalf("injected value is not masked in later log lines: %q", got)
+	}
+
+	unsafeSaveRenderedSecrets = true
+	if _, err := resolve1PasswordReferences(content, common.NewColorLogger()); err != nil {
+		t.Fatalf("resolve1PasswordReferences returned error: %v", err)
+	}
+	if got := saved(); !strings.Contains(got, injectedValue) || !strings.Contains(got, templatedValue) {
+		t.Fatalf("--unsafe-save-secrets should keep resolved values, got %q", got)
+	}
+}
+
+func TestBootstrapResolvesReferencesFromSopsBackendWithoutOp(t *testing.T) {
+	t.Cleanup(common.ResetSecretRegistryForTesting())
+	t.Cleanup(versionconfig.SetForTesting(&versionconfig.Config{
+		SecretBackend: versionconfig.SecretBackendConfig{Type: secrets.BackendSops, SopsFile: "/repo/secrets.sops.yaml"},
+	}))
+	t.Cleanup(secrets.SetOpReadFnForTesting(func(reference string) (common.CommandResult, error) {
+		t.Errorf("op read %s called with the sops backend", reference)
+		return common.CommandResult{}, errors.New("op must not run")
+	}))
+	t.Cleanup(secrets.SetSopsDecryptFnForTesting(func(path string) ([]byte, error) {
+		return []byte(`{"Talos":{"machine":{"token":"sops-machine-token"},"cluster":{"secret":"sops-cluster-secret"}}}`), nil
+	}))
+	var looked []string
+	testutil.Swap(t, &bootstrapLookPath, func(file string) (string, error) {
+		looked = append(looked, file)
+		return "/usr/bin/" + file, nil
+	})
+	testutil.Swap(t, &bootstrapEnsureOPAuth, func() error {
+		t.Error("1Password signin attempted with the sops backend")
+		return nil
+	})
+
+	if result := checkToolAvailability(&BootstrapConfig{}, common.NewColorLogger()); result.Status != "PASS" {
+		t.Fatalf("tool availability = %+v", result)
+	}
+	if slices.Contains(looked, "op") || !slices.Contains(looked, "sops") {
+		t.Fatalf("looked up %v, want sops and not op", looked)
+	}
+	if result := check1PasswordAuthPreflight(&BootstrapConfig{}, common.NewColorLogger()); !strings.Contains(result.Message, "sops secret backend") {
+		t.Fatalf("1Password preflight = %+v", result)
+	}
+
+	resolved, err := resolve1PasswordReferences("token: op://Talos/machine/token\nsecret: op://Talos/cluster/secret\n", common.NewColorLogger())
+	if err != nil {
+		t.Fatalf("resolve1PasswordReferences returned error: %v", err)
+	}
+	if want := "token: sops-machine-token\nsecret: sops-cluster-secret\n"; resolved != want {
+		t.Fatalf("resolved = %q, want %q", resolved, want)
+	}
+}
@@ -16,6 +16,10 @@ func runTalosBootstrapFlow(config *BootstrapConfig, logger *common.ColorLogger)
 	if err := prepareTalosBootstrapConfig(config, logger); err != nil {
 		return err
 	}
+	config.progress = openBootstrapProgress(config, logger)
+	if err := checkBootstrapOnlyCluster(config); err != nil {
+		return err
+	}
 
 	if err := runBootstrapPreflightPhase(config, logger); err != nil {
 		return err
@@ -70,7 +74,7 @@ func loadBootstrapVersions(config *BootstrapConfig, logger *common.ColorLogger)
 func runBootstrapPreflightPhase(config *BootstrapConfig, logger *common.ColorLogger) error {
 	// Run comprehensive preflight checks
 	if !config.SkipPreflight {
-		if err := bootstrapRunWithSpinner("🔍 Running preflight checks", config.Verbose, logger, func() error {
+		if err := runBootstrapStep(config, logger, "preflight", "🔍 Running preflight checks", func() error {
 			return bootstrapRunPreflightChecks(config, logger)
 		}); err != nil {
 			return fmt.Errorf("preflight checks failed: %w", err)
@@ -87,7 +91,9 @@ func runBootstrapPreflightPhase(config *BootstrapConfig, logger *common.ColorLog
 }
 
 func runTalosPreCNIBootstrap(config *BootstrapConfig, logger *common.ColorLogger) error {
-	if err := applyTalosConfigurationStep(config, logger); err != nil {
+	if err := trackBootstrapStep(config, "apply-config", func() error {
+		return applyTalosConfigurationStep(config, logger)
+	}); err != nil {
 		return err
 	}
 
@@ -116,7 +122,7 @@ func applyTalosConfigurationStep(config *BootstrapConfig, logger *common.ColorLo
 
 func bootstrapTalosClusterStep(config *BootstrapConfig, logger *common.ColorLogger) error {
 	// Step 2: Bootstrap Talos
-	if err := bootstrapRunWithSpinner("🎯 Step 2: Bootstrapping Talos cluster", config.Verbose, logger, func() error {
+	if err := runBootstrapStep(config, logger, "talos-bootstrap", "🎯 Step 2: Bootstrapping Talos cluster", func() error {
 		// Wait a moment for configurations to be fully processed (following onedr0p's pattern)
 		logger.Debug("Waiting for configurations to be processed...")
 		bootstrapSleep(5 * time.Second)
@@ -129,7 +135,7 @@ func bootstrapTalosClusterStep(config *BootstrapConfig, logger *common.ColorLogg
 
 func fetchAndValidateKubeconfigStep(config *BootstrapConfig, logger *common.ColorLogger) error {
 	// Step 3: Fetch kubeconfig
-	if err := bootstrapRunWithSpinner("🔑 Step 3: Fetching and validating kubeconfig", config.Verbose, logger, func() error {
+	if err := runBootstrapStep(config, logger, "kubeconfig", "🔑 Step 3: Fetching and validating kubeconfig", func() error {
 		if err := bootstrapFetchKubeconfig(config, logger); err != nil {
 			return fmt.Errorf("failed to fetch kubeconfig: %w", err)
 		}
@@ -146,7 +152,7 @@ func fetchAndValidateKubeconfigStep(config *BootstrapConfig, logger *common.Colo
 
 func waitForBootstrapNodesStep(config *BootstrapConfig, logger *common.ColorLogger) error {
 	// Step 4: Wait for nodes to be ready
-	if err := bootstrapRunWithSpinner("⏳ Step 4: Waiting for nodes to be ready", config.Verbose, logger, func() error {
+	if err := runBootstrapStep(config, logger, "nodes", "⏳ Step 4: Waiting for nodes to be ready", func() error {
 		return bootstrapWaitForNodes(config, logger)
 	}); err != nil {
 		return fmt.Errorf("failed waiting for nodes: %w", err)
@@ -159,6 +165,10 @@ func runSharedPostCNIBootstrap(config *BootstrapConfig, logger *common.ColorLogg
 		return err
 	}
 
+	if err := applyBootstrapClusterSettingsStep(config, logger); err != nil {
+		return err
+	}
+
 	if err := applyBootstrapResourcesStep(config, logger); err != nil {
 		return err
 	}
@@ -172,12 +182,12 @@ func runSharedPostCNIBootstrap(config *BootstrapConfig, logger *common.ColorLogg
 	}
 
 	waitForBootstrapFluxStep(config, logger)
-	return nil
+	return waitForBootstrapGatewaysStep(config, logger)
 }
 
 func applyBootstrapNamespacesStep(config *BootstrapConfig, logger *common.ColorLogger) error {
 	// Step 5: Apply namespaces first (following onedr0p pattern)
-	if err := bootstrapRunWithSpinner("📦 Step 5: Creating initial namespaces", config.Verbose, logger, func() error {
+	if err := runBootstrapStep(config, logger, "namespaces", "📦 Step 5: Creating initial namespaces", func() error {
 		return bootstrapApplyNamespaces(config, logger)
 	}); err != nil {
 		return fmt.Errorf("failed to apply namespaces: %w", err)
@@ -185,10 +195,20 @@ func applyBootstrapNamespacesStep(config *BootstrapConfig, logger *common.ColorL
 	return nil
 }
 
+func applyBootstrapClusterSettingsStep(config *BootstrapConfig, logger *common.ColorLogger) error {
+	// Step 6: Apply the Flux cluster-settings ConfigMap
+	if err := runBootstrapStep(config, logger, "cluster-settings", "🧭 Step 6: Applying cluster settings", func() error {
+		return bootstrapApplyClusterSettings(config, logger)
+	}); err != nil {
+		return fmt.Errorf("failed to apply cluster settings: %w", err)
+	}
+	return nil
+}
+
 func applyBootstrapResourcesStep(config *BootstrapConfig, logger *common.ColorLogger) error {
-	// Step 6: Apply initial resources
+	// Step 7: Apply initial resources
 	if !config.SkipResources {
-		if err := bootstrapRunWithSpinner("🔧 Step 6: Applying initial resources", config.Verbose, logger, func() error {
+		if err := runBootstrapStep(config, logger, "resources", "🔧 Step 7: Applying initial resources", func() error {
 			return bootstrapApplyResources(config, logger)
 		}); err != nil {
 			return fmt.Errorf("failed to apply resources: %w", err)
@@ -198,9 +218,9 @@ func applyBootstrapResourcesStep(config *BootstrapConfig, logger *common.ColorLo
 }
 
 func applyBootstrapCRDsStep(config *BootstrapConfig, logger *common.ColorLogger) error {
-	// Step 7: Apply CRDs
+	// Step 8: Apply CRDs
 	if !config.SkipCRDs {
-		if err := bootstrapRunWithSpinner("📜 Step 7: Applying Custom Resource Definitions", config.Verbose, logger, func() error {
+		if err := runBootstrapStep(config, logger, "crds", "📜 Step 8: Applying Custom Resource Definitions", func() error {
 			return bootstrapApplyCRDs(config, logger)
 		}); err != nil {
 			return fmt.Errorf("failed to apply CRDs: %w", err)
@@ -210,9 +230,9 @@ func applyBootstrapCRDsStep(config *BootstrapConfig, logger *common.ColorLogger)
 }
 
 func syncBootstrapHelmReleasesStep(config *BootstrapConfig, logger *common.ColorLogger) error {
-	// Step 8: Sync Helm releases
+	// Step 9: Sync Helm releases
 	if !config.SkipHelmfile {
-		if err := bootstrapRunWithSpinner("⚙️  Step 8: Syncing Helm releases", config.Verbose, logger, func() error {
+		if err := runBootstrapStep(config, logger, "helm-releases", "⚙️  Step 9: Syncing Helm releases", func() error {
 			return bootstrapSyncHelmReleases(config, logger)
 		}); err != nil {
 			return fmt.Errorf("failed to sync Helm releases: %w", err)
@@ -222,10 +242,10 @@ func syncBootstrapHelmReleasesStep(config *BootstrapConfig, logger *common.Color
 }
 
 func waitForBootstrapFluxStep(config *BootstrapConfig, logger *common.ColorLogger) {
-	// Step 9: Wait for Flux initial reconciliation
+	// Step 10: Wait for Flux initial reconciliation
 	// This is critical - without it, bootstrap declares success before Flux has actually reconciled
 	if !config.SkipHelmfile {
-		if err := bootstrapRunWithSpinner("🔄 Step 9: Waiting for Flux initial reconciliation", config.Verbose, logger, func() error {
+		if err := runBootstrapStep(config, logger, "flux", "🔄 Step 10: Waiting for Flux initial reconciliation", func() error {
 			return bootstrapWaitForFlux(config, logger)
 		}); err != nil {
 			// Not fatal - cluster is functional, just not fully reconciled yet
@@ -235,6 +255,19 @@ func waitForBootstrapFluxStep(config *BootstrapConfig, logger *common.ColorLogge
 	}
 }
 
+func waitForBootstrapGatewaysStep(config *BootstrapConfig, logger *common.ColorLogger) error {
+	// Step 11: Gateway API smoke test. Unlike the Flux wait this is fatal: an
+	// unprogrammed Gateway means nothing in the cluster is reachable.
+	if !config.SkipHelmfile && !config.SkipGatewayCheck {
+		if err := runBootstrapStep(config, logger, "gateways", "🌐 Step 11: Checking Gateway API Gateways", func() error {
+			return bootstrapWaitGateways(config, logger)
+		}); err != nil {
+			return fmt.Errorf("gateway check failed (skip with --skip-gateway-check): %w", err)
+		}
+	}
+	return nil
+}
+
 func finishBootstrap(logger *common.ColorLogger) {
 	logger.Success("🎉 Congrats! The cluster is bootstrapped and Flux has completed initial reconciliation")
 	ui.PrintSuccessBox("🎉 Cluster bootstrapped!",
@@ -12,12 +12,65 @@ import (
 	"time"
 
 	"homeops-cli/internal/common"
+	"homeops-cli/internal/kube"
+	"homeops-cli/internal/testutil"
+
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	k8stesting "k8s.io/client-go/testing"
 )
 
-func configureBootstrapWaitTest(t *testing.T, stub func(*BootstrapConfig, ...string) ([]byte, error)) {
+// TestMain keeps the bootstrap progress the flow tests record out of the
+// user's cache directory.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "bootstrap-state-")
+	if err != nil {
+		panic(err)
+	}
+	bootstrapStatePath = func() (string, error) { return filepath.Join(dir, "bootstrap-state.json"), nil }
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// stubBootstrapKube points bootstrap at a fake cluster seeded with objects.
+// Its watches fail, so waits poll on the clock the test controls.
+func stubBootstrapKube(t *testing.T, objects ...k8sruntime.Object) *kube.Fake {
+	t.Helper()
+	cluster := kube.NewFake(objects...)
+	cluster.DynamicClient.PrependWatchReactor("*", func(k8stesting.Action) (bool, watch.Interface, error) {
+		return true, nil, errors.New("watch disabled")
+	})
+	testutil.Swap(t, &bootstrapKubeClient, func(*BootstrapConfig) (*kube.Client, error) { return cluster.Client, nil })
+	return cluster
+}
+
+// failBootstrapKube makes every request to the cluster fail with err.
+func failBootstrapKube(t *testing.T, err error) *kube.Fake {
+	t.Helper()
+	cluster := stubBootstrapKube(t)
+	fail := func(k8stesting.Action) (bool, k8sruntime.Object, error) { return true, nil, err }
+	cluster.Clientset.PrependReactor("*", "*", fail)
+	cluster.DynamicClient.PrependReactor("*", "*", fail)
+	return cluster
+}
+
+// appliedObjects lists the "resource namespace/name" of every object
+// server-side applied to cluster, in order.
+func appliedObjects(cluster *kube.Fake) []string {
+	var applied []string
+	for _, action := range cluster.DynamicClient.Actions() {
+		if patch, ok := action.(k8stesting.PatchAction); ok && patch.GetPatchType() == types.ApplyPatchType {
+			applied = append(applied, patch.GetResource().Resource+" "+patch.GetNamespace()+"/"+patch.GetName())
+		}
+	}
+	return applied
+}
+
+func configureBootstrapWaitTest(t *testing.T, kubeErr error) {
 	t.Helper()
 
-	originalKubectlOutput := bootstrapKubectlOutput
 	originalCheckIntervalNormal := bootstrapCheckIntervalNormal
 	originalCheckIntervalSlow := bootstrapCheckIntervalSlow
 	originalStallTimeout := bootstrapStallTimeout
@@ -26,7 +79,6 @@ func configureBootstrapWaitTest(t *testing.T, stub func(*BootstrapConfig, ...str
 	originalNodeMaxWait := bootstrapNodeMaxWait
 
 	t.Cleanup(func() {
-		bootstrapKubectlOutput = originalKubectlOutput
 		bootstrapCheckIntervalNormal = originalCheckIntervalNormal
 		bootstrapCheckIntervalSlow = originalCheckIntervalSlow
 		bootstrapStallTimeout = originalStallTimeout
@@ -35,7 +87,7 @@ func configureBootstrapWaitTest(t *testing.T, stub func(*BootstrapConfig, ...str
 		bootstrapNodeMaxWait = originalNodeMaxWait
 	})
 
-	bootstrapKubectlOutput = stub
+	failBootstrapKube(t, kubeErr)
 	bootstrapCheckIntervalNormal = time.Millisecond
 	bootstrapCheckIntervalSlow = time.Millisecond
 	bootstrapStallTimeout = 5 * time.Millisecond
@@ -62,16 +114,6 @@ func TestBuildTalosctlCmd(t *testing.T) {
 	})
 }
 
-func TestBuildKubectlCmd(t *testing.T) {
-	config := &BootstrapConfig{KubeConfig: "/tmp/kubeconfig"}
-	cmd := buildKubectlCmd(config, "get", "nodes", "-o", "name")
-
-	want := []string{"kubectl", "get", "nodes", "-o", "name", "--kubeconfig", "/tmp/kubeconfig"}
-	if strings.Join(cmd.Args, "|") != strings.Join(want, "|") {
-		t.Fatalf("unexpected args: got %v want %v", cmd.Args, want)
-	}
-}
-
 func TestBuildHelmfileCmd(t *testing.T) {
 	config := &BootstrapConfig{RootDir: "/repo/root"}
 	cmd := buildHelmfileCmd("/tmp/work", config, "--file", "/tmp/work/01-apps.yaml", "sync")
@@ -148,28 +190,6 @@ func TestRunHelmfileSyncCmdUsesTestableWriters(t *testing.T) {
 	}
 }
 
-func TestRunKubectlContextCancellation(t *testing.T) {
-	dir := t.TempDir()
-	script := "#!/bin/sh\nsleep 30\n"
-	if err := os.WriteFile(filepath.Join(dir, "kubectl"), []byte(script), 0o755); err != nil {
-		t.Fatalf("write fake kubectl: %v", err)
-	}
-	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
-
-	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
-	defer cancel()
-
-	start := time.Now()
-	_, err := runKubectlContext(ctx, &BootstrapConfig{KubeConfig: "/tmp/kubeconfig"}, "get", "nodes")
-	elapsed := time.Since(start)
-	if err == nil {
-		t.Fatal("expected cancellation error")
-	}
-	if elapsed > 5*time.Second {
-		t.Fatalf("cancellation should kill process quickly, took %v", elapsed)
-	}
-}
-
 func TestRunTalosctlContextCancellation(t *testing.T) {
 	dir := t.TempDir()
 	script := "#!/bin/sh\nsleep 30\n"
@@ -192,23 +212,18 @@ func TestRunTalosctlContextCancellation(t *testing.T) {
 	}
 }
 
-func TestRunKubectlContextRedactsOutput(t *testing.T) {
-	dir := t.TempDir()
-	script := "#!/bin/sh\necho 'failure: password=SENTINEL_PWD api_key=SENTINEL_KEY' >&2\nexit 1\n"
-	if err := os.WriteFile(filepath.Join(dir, "kubectl"), []byte(script), 0o755); err != nil {
-		t.Fatalf("write fake kubectl: %v", err)
-	}
-	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+func TestApplyManifestRedactsAPIErrors(t *testing.T) {
+	failBootstrapKube(t, errors.New("admission webhook denied: password=SENTINEL_PWD api_key=SENTINEL_KEY"))
 
-	result, err := runKubectlContext(context.Background(), &BootstrapConfig{KubeConfig: "/tmp/kubeconfig"}, "get", "secrets")
+	err := applyManifest(&BootstrapConfig{}, "apiVersion: v1\nkind: Secret\nmetadata:\n  name: s\n  namespace: default\n", kube.ApplyOptions{})
 	if err == nil {
-		t.Fatal("expected fake kubectl to fail")
+		t.Fatal("expected the apply to fail")
 	}
-	if strings.Contains(result.Stderr, "SENTINEL_PWD") || strings.Contains(result.Stderr, "SENTINEL_KEY") {
-		t.Fatalf("stderr must be redacted, got: %s", result.Stderr)
+	if strings.Contains(err.Error(), "SENTINEL_PWD") || strings.Contains(err.Error(), "SENTINEL_KEY") {
+		t.Fatalf("error must be redacted, got: %v", err)
 	}
-	if !strings.Contains(result.Stderr, "<redacted>") {
-		t.Fatalf("expected redaction marker, got: %s", result.Stderr)
+	if !strings.Contains(err.Error(), "<redacted>") {
+		t.Fatalf("expected redaction marker, got: %v", err)
 	}
 }
 
@@ -466,9 +481,7 @@ func TestResolve1PasswordReferences(t *testing.T) {
 
 // TestWaitForNodesAvailable tests node availability waiting
 func TestWaitForNodesAvailable(t *testing.T) {
-	configureBootstrapWaitTest(t, func(_ *BootstrapConfig, _ ...string) ([]byte, error) {
-		return nil, errors.New("kubectl unavailable")
-	})
+	configureBootstrapWaitTest(t, errors.New("connection refused"))
 
 	config := &BootstrapConfig{KubeConfig: "/tmp/kubeconfig"}
 	logger := common.NewColorLogger()
@@ -478,7 +491,7 @@ func TestWaitForNodesAvailable(t *testing.T) {
 	duration := time.Since(start)
 
 	if err == nil {
-		t.Errorf("Expected kubectl stall error")
+		t.Errorf("Expected stall error")
 	}
 	if err != nil && !strings.Contains(err.Error(), "node discovery stalled") {
 		t.Errorf("Expected stall error, got: %v", err)
@@ -489,10 +502,8 @@ func TestWaitForNodesAvailable(t *testing.T) {
 	}
 }
 
-func TestWaitForNodesReadyFalseStallsOnKubectlErrors(t *testing.T) {
-	configureBootstrapWaitTest(t, func(_ *BootstrapConfig, _ ...string) ([]byte, error) {
-		return nil, errors.New("kubectl unavailable")
-	})
+func TestWaitForNodesReadyFalseStallsOnAPIErrors(t *testing.T) {
+	configureBootstrapWaitTest(t, errors.New("connection refused"))
 
 	config := &BootstrapConfig{KubeConfig: "/tmp/kubeconfig"}
 	logger := common.NewColorLogger()
@@ -512,10 +523,8 @@ func TestWaitForNodesReadyFalseStallsOnKubectlErrors(t *testing.T) {
 	}
 }
 
-func TestWaitForExternalSecretsWebhookStallsOnKubectlErrors(t *testing.T) {
-	configureBootstrapWaitTest(t, func(_ *BootstrapConfig, _ ...string) ([]byte, error) {
-		return nil, errors.New("kubectl unavailable")
-	})
+func TestWaitForExternalSecretsWebhookStallsOnAPIErrors(t *testing.T) {
+	configureBootstrapWaitTest(t, errors.New("connection refused"))
 
 	config := &BootstrapConfig{KubeConfig: "/tmp/kubeconfig"}
 	logger := common.NewColorLogger()
@@ -525,7 +534,7 @@ func TestWaitForExternalSecretsWebhookStallsOnKubectlErrors(t *testing.T) {
 	duration := time.Since(start)
 
 	if err == nil {
-		t.Fatal("Expected error with kubectl failures")
+		t.Fatal("Expected error with API failures")
 	}
 	if !strings.Contains(err.Error(), "external-secrets webhook stalled") {
 		t.Fatalf("Expected external-secrets stall error, got: %v", err)
@@ -535,10 +544,8 @@ func TestWaitForExternalSecretsWebhookStallsOnKubectlErrors(t *testing.T) {
 	}
 }
 
-func TestWaitForFluxControllerStallsOnKubectlErrors(t *testing.T) {
-	configureBootstrapWaitTest(t, func(_ *BootstrapConfig, _ ...string) ([]byte, error) {
-		return nil, errors.New("kubectl unavailable")
-	})
+func TestWaitForFluxControllerStallsOnAPIErrors(t *testing.T) {
+	configureBootstrapWaitTest(t, errors.New("connection refused"))
 
 	config := &BootstrapConfig{KubeConfig: "/tmp/kubeconfig"}
 	logger := common.NewColorLogger()
@@ -548,7 +555,7 @@ func TestWaitForFluxControllerStallsOnKubectlErrors(t *testing.T) {
 	duration := time.Since(start)
 
 	if err == nil {
-		t.Fatal("Expected error with kubectl failures")
+		t.Fatal("Expected error with API failures")
 	}
 	if !strings.Contains(err.Error(), "source-controller stalled") {
 		t.Fatalf("Expected flux controller stall error, got: %v", err)
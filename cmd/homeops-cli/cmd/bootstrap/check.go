@@ -0,0 +1,371 @@
+package bootstrap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/constants"
+	"homeops-cli/internal/kube"
+	"homeops-cli/internal/kubeutil"
+	"homeops-cli/internal/templates"
+	"homeops-cli/internal/ui"
+)
+
+// Step statuses reported by `bootstrap --check`.
+const (
+	checkAlreadyDone = "ALREADY-DONE"
+	checkWouldChange = "WOULD-CHANGE"
+	checkUnknown     = "UNKNOWN"
+)
+
+type bootstrapCheckStep struct {
+	Order  int    `json:"order"`
+	Step   string `json:"step"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+type bootstrapCheckReport struct {
+	Provider    string               `json:"provider"`
+	Kubeconfig  string               `json:"kubeconfig"`
+	AlreadyDone int                  `json:"already_done"`
+	WouldChange int                  `json:"would_change"`
+	Unknown     int                  `json:"unknown"`
+	Steps       []bootstrapCheckStep `json:"steps"`
+}
+
+var (
+	bootstrapAssessFn         = assessBootstrapState
+	bootstrapHelmReleasesJSON = func(config *BootstrapConfig) ([]byte, error) {
+		return common.Command("helm", "list", "--all-namespaces", "--output", "json", "--kubeconfig", config.KubeConfig).Output()
+	}
+)
+
+// assessBootstrapState compares the live cluster with what each bootstrap
+// step would do, using the same one-shot probes the wait loops poll. Nothing
+// is applied; a probe that cannot run yields UNKNOWN rather than a guess.
+func assessBootstrapState(config *BootstrapConfig) bootstrapCheckReport {
+	provider := strings.ToLower(strings.TrimSpace(config.Provider))
+	if provider == "" {
+		provider = "talos"
+	}
+	logger := common.NewColorLogger()
+	logger.SetQuiet(true)
+
+	report := bootstrapCheckReport{Provider: provider, Kubeconfig: config.KubeConfig}
+	nodeStep := "Apply Talos machine config"
+	if provider == "flatcar" {
+		nodeStep = "kubeadm init/join"
+	}
+	steps := []struct {
+		name  string
+		probe func() (string, string)
+	}{
+		{nodeStep, func() (string, string) { return checkNodesState(config, logger) }},
+		{"etcd bootstrap", func() (string, string) { return checkEtcdState(config, provider) }},
+		{"Namespaces", func() (string, string) { return checkNamespacesState(config) }},
+		{"Cluster settings", func() (string, string) { return checkClusterSettingsState(config) }},
+		{"CRDs established", func() (string, string) { return checkCRDsState(config) }},
+		{"Helm releases", func() (string, string) { return checkHelmReleasesState(config) }},
+		{"ClusterSecretStore", func() (string, string) { return checkSecretStoreState(config) }},
+		{"Flux reconciliation", func() (string, string) { return checkFluxState(config) }},
+		{"Gateway programmed", func() (string, string) { return checkGatewaysState(config) }},
+	}
+
+	// Without an API server every probe would fail the same way, so skip them
+	// rather than wait out each one's timeout.
+	apiErr := bootstrapTestAPIConnectivity(config, logger)
+	for index, step := range steps {
+		status, detail := checkUnknown, ""
+		if apiErr != nil {
+			detail = fmt.Sprintf("API server unreachable with %s: %v", displayKubeconfig(config.KubeConfig), apiErr)
+		} else {
+			status, detail = step.probe()
+		}
+		report.Steps = append(report.Steps, bootstrapCheckStep{Order: index + 1, Step: step.name, Status: status, Detail: detail})
+	}
+	report.tally()
+	return report
+}
+
+func (r *bootstrapCheckReport) tally() {
+	r.AlreadyDone, r.WouldChange, r.Unknown = 0, 0, 0
+	for _, step := range r.Steps {
+		switch step.Status {
+		case checkAlreadyDone:
+			r.AlreadyDone++
+		case checkWouldChange:
+			r.WouldChange++
+		default:
+			r.Unknown++
+		}
+	}
+}
+
+func displayKubeconfig(path string) string {
+	if path == "" {
+		return "the default kubeconfig"
+	}
+	return path
+}
+
+func checkNodesState(config *BootstrapConfig, logger *common.ColorLogger) (string, string) {
+	ready, err := bootstrapCheckNodesReady(config, logger)
+	switch {
+	case err != nil:
+		return checkUnknown, err.Error()
+	case ready:
+		return checkAlreadyDone, "all nodes registered and Ready; re-applying would be a no-op unless the rendered config drifted"
+	default:
+		return checkWouldChange, "nodes missing or not Ready"
+	}
+}
+
+func checkEtcdState(config *BootstrapConfig, provider string) (string, string) {
+	if provider == "talos" {
+		nodes := bootstrapPlanConfigFn().Cluster.Nodes
+		if len(nodes) == 0 {
+			return checkUnknown, "cluster.nodes is empty; no controller to ask"
+		}
+		if _, err := bootstrapTalosctlCombined(config.TalosConfig, "--nodes", nodes[0].IP, "etcd", "status"); err != nil {
+			return checkWouldChange, fmt.Sprintf("etcd status on %s failed: %v", nodes[0].IP, err)
+		}
+		return checkAlreadyDone, fmt.Sprintf("etcd responding on %s; talosctl bootstrap would be skipped", nodes[0].IP)
+	}
+
+	client, err := bootstrapKubeClient(config)
+	if err != nil {
+		return checkUnknown, fmt.Sprintf("list etcd pods: %v", err)
+	}
+	pods, err := client.Kubernetes.CoreV1().Pods(constants.NSKubeSystem).List(context.Background(), metav1.ListOptions{LabelSelector: "component=etcd"})
+	if err != nil {
+		return checkUnknown, fmt.Sprintf("list etcd pods: %v", err)
+	}
+	running := 0
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning {
+			running++
+		}
+	}
+	if running == 0 {
+		return checkWouldChange, "no running etcd static pods in kube-system"
+	}
+	return checkAlreadyDone, fmt.Sprintf("%d etcd member(s) running", running)
+}
+
+func checkNamespacesState(config *BootstrapConfig) (string, string) {
+	client, err := bootstrapKubeClient(config)
+	if err != nil {
+		return checkUnknown, fmt.Sprintf("list namespaces: %v", err)
+	}
+	namespaces, err := client.Kubernetes.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return checkUnknown, fmt.Sprintf("list namespaces: %v", err)
+	}
+	present := make(map[string]bool)
+	for _, namespace := range namespaces.Items {
+		present[namespace.Name] = true
+	}
+	var missing []string
+	for _, name := range initialBootstrapNamespaces() {
+		if !present[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return checkWouldChange, "would create " + strings.Join(missing, ", ")
+	}
+	return checkAlreadyDone, fmt.Sprintf("all %d namespaces present", len(initialBootstrapNamespaces()))
+}
+
+func checkClusterSettingsState(config *BootstrapConfig) (string, string) {
+	settings, err := bootstrapLoadClusterSettings()
+	if err != nil {
+		return checkUnknown, fmt.Sprintf("load cluster settings: %v", err)
+	}
+	client, err := bootstrapKubeClient(config)
+	if err != nil {
+		return checkUnknown, fmt.Sprintf("read ConfigMap %s: %v", templates.ClusterSettingsConfigMapName, err)
+	}
+	live, err := client.Kubernetes.CoreV1().ConfigMaps(constants.NSFluxSystem).Get(context.Background(), templates.ClusterSettingsConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if !kube.IsNotFound(err) {
+			return checkUnknown, fmt.Sprintf("read ConfigMap %s: %v", templates.ClusterSettingsConfigMapName, err)
+		}
+		return checkWouldChange, fmt.Sprintf("would create ConfigMap %s/%s", constants.NSFluxSystem, templates.ClusterSettingsConfigMapName)
+	}
+	var stale []string
+	for _, key := range settings.Keys() {
+		if value, ok := live.Data[key]; !ok || value != settings.Values[key] {
+			stale = append(stale, key)
+		}
+	}
+	if len(stale) > 0 {
+		return checkWouldChange, "would update " + strings.Join(stale, ", ")
+	}
+	return checkAlreadyDone, fmt.Sprintf("all %d settings current", len(settings.Values))
+}
+
+func checkCRDsState(config *BootstrapConfig) (string, string) {
+	established, total, pending, err := checkCRDsEstablished(config)
+	switch {
+	case err != nil:
+		return checkUnknown, fmt.Sprintf("list CRDs: %v", err)
+	case total == 0:
+		return checkWouldChange, "no CRDs installed"
+	case len(pending) > 0:
+		return checkWouldChange, fmt.Sprintf("%d/%d established; pending %s", established, total, strings.Join(pending, ", "))
+	default:
+		return checkAlreadyDone, fmt.Sprintf("all %d CRDs established", total)
+	}
+}
+
+type expectedHelmRelease struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"`
+	Chart     string `yaml:"chart"`
+	Version   string `yaml:"version"`
+}
+
+type deployedHelmRelease struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Status    string `json:"status"`
+	Chart     string `json:"chart"`
+}
+
+func checkHelmReleasesState(config *BootstrapConfig) (string, string) {
+	helmfile, err := bootstrapGetBootstrapFile("helmfile.d/01-apps.yaml")
+	if err != nil {
+		return checkUnknown, fmt.Sprintf("read embedded helmfile: %v", err)
+	}
+	var spec struct {
+		Releases []expectedHelmRelease `yaml:"releases"`
+	}
+	if err := yaml.Unmarshal([]byte(helmfile), &spec); err != nil {
+		return checkUnknown, fmt.Sprintf("parse embedded helmfile: %v", err)
+	}
+
+	output, err := bootstrapHelmReleasesJSON(config)
+	if err != nil {
+		return checkUnknown, fmt.Sprintf("helm list: %v", err)
+	}
+	var releases []deployedHelmRelease
+	if err := json.Unmarshal(output, &releases); err != nil {
+		return checkUnknown, fmt.Sprintf("parse helm list output: %v", err)
+	}
+	return helmReleaseDrift(spec.Releases, releases)
+}
+
+// helmReleaseDrift decides the Helm step: every helmfile release must be
+// deployed with a chart whose version matches the pinned one.
+func helmReleaseDrift(expected []expectedHelmRelease, deployed []deployedHelmRelease) (string, string) {
+	byKey := make(map[string]deployedHelmRelease, len(deployed))
+	for _, release := range deployed {
+		byKey[release.Namespace+"/"+release.Name] = release
+	}
+	var changes []string
+	for _, want := range expected {
+		key := want.Namespace + "/" + want.Name
+		got, ok := byKey[key]
+		switch {
+		case !ok:
+			changes = append(changes, key+" not installed")
+		case got.Status != "deployed":
+			changes = append(changes, fmt.Sprintf("%s is %s", key, got.Status))
+		case want.Version != "" && got.Chart != path.Base(want.Chart)+"-"+want.Version:
+			changes = append(changes, fmt.Sprintf("%s %s -> %s", key, got.Chart, want.Version))
+		}
+	}
+	if len(changes) > 0 {
+		sort.Strings(changes)
+		return checkWouldChange, strings.Join(changes, "; ")
+	}
+	return checkAlreadyDone, fmt.Sprintf("all %d releases deployed at pinned versions", len(expected))
+}
+
+func checkSecretStoreState(config *BootstrapConfig) (string, string) {
+	client, err := bootstrapKubeClient(config)
+	if err != nil {
+		return checkUnknown, fmt.Sprintf("get clustersecretstore onepassword: %v", err)
+	}
+	store, err := client.Get(context.Background(), kube.ClusterSecretStoreResource, "", "onepassword")
+	if err != nil {
+		if !kube.IsNotFound(err) {
+			return checkUnknown, fmt.Sprintf("get clustersecretstore onepassword: %v", err)
+		}
+		return checkWouldChange, "ClusterSecretStore onepassword not found"
+	}
+	if status, _ := kube.Condition(store, "Ready"); status != "True" {
+		return checkWouldChange, "ClusterSecretStore onepassword exists but is not Ready"
+	}
+	return checkAlreadyDone, "ClusterSecretStore onepassword Ready"
+}
+
+func checkFluxState(config *BootstrapConfig) (string, string) {
+	gitReady, gitState := checkFluxObjectReady(config, kube.GitRepositoryResource, "flux-system", "status", "artifact", "revision")
+	if gitState == "not-found" {
+		return checkWouldChange, "GitRepository flux-system not found"
+	}
+	ksReady, ksState := checkFluxObjectReady(config, kube.KustomizationResource, "cluster", "status", "lastAppliedRevision")
+	if gitReady && ksReady {
+		return checkAlreadyDone, "GitRepository and Kustomization cluster Ready"
+	}
+	return checkWouldChange, fmt.Sprintf("waiting on Flux (gitrepository %s, kustomization %s)", gitState, ksState)
+}
+
+func checkGatewaysState(config *BootstrapConfig) (string, string) {
+	gateways, err := listGateways(config)
+	if err != nil {
+		return checkUnknown, err.Error()
+	}
+	if len(gateways) == 0 {
+		return checkAlreadyDone, "no Gateways defined"
+	}
+	if err := kubeutil.CheckGatewayServing(gateways); err != nil {
+		return checkWouldChange, err.Error()
+	}
+	return checkAlreadyDone, fmt.Sprintf("%d Gateway(s), at least one Programmed with an address", len(gateways))
+}
+
+func renderBootstrapCheck(report bootstrapCheckReport, output string) (string, error) {
+	if output == "json" {
+		return ui.RenderJSON(report)
+	}
+	rows := make([][]string, 0, len(report.Steps))
+	for _, step := range report.Steps {
+		rows = append(rows, []string{fmt.Sprintf("%d", step.Order), step.Status, step.Step, step.Detail})
+	}
+	return fmt.Sprintf("BOOTSTRAP CHECK (NO CHANGES WILL BE MADE)\nProvider: %s\nKubeconfig: %s\n\n%s\n\nSummary: %d already done, %d would change, %d unknown",
+		report.Provider, displayKubeconfig(report.Kubeconfig),
+		ui.Table([]string{"ORDER", "STATUS", "STEP", "DETAIL"}, rows),
+		report.AlreadyDone, report.WouldChange, report.Unknown), nil
+}
+
+// warnIfAlreadyBootstrapped prints a one-line banner before a real bootstrap
+// when the cluster already looks (partly) bootstrapped. It only runs when the
+// kubeconfig exists, so a fresh build pays nothing.
+func warnIfAlreadyBootstrapped(config *BootstrapConfig, logger *common.ColorLogger) {
+	if config.KubeConfig == "" {
+		return
+	}
+	if _, err := os.Stat(config.KubeConfig); err != nil {
+		return
+	}
+	report := bootstrapAssessFn(config)
+	if report.AlreadyDone == 0 {
+		return
+	}
+	logger.Warn("Cluster already looks bootstrapped: %d/%d steps ALREADY-DONE, %d WOULD-CHANGE, %d UNKNOWN. Run 'homeops-cli bootstrap --check' for details.",
+		report.AlreadyDone, len(report.Steps), report.WouldChange, report.Unknown)
+}
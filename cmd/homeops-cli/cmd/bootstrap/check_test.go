@@ -0,0 +1,376 @@
+package bootstrap
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/constants"
+	"homeops-cli/internal/kube"
+	"homeops-cli/internal/templates"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const checkHealthyHelmList = `[
+  {"name":"cilium","namespace":"kube-system","status":"deployed","chart":"cilium-1.18.6"},
+  {"name":"coredns","namespace":"kube-system","status":"deployed","chart":"coredns-1.46.2"},
+  {"name":"spegel","namespace":"kube-system","status":"deployed","chart":"spegel-0.7.4"},
+  {"name":"cert-manager","namespace":"cert-manager","status":"deployed","chart":"cert-manager-v1.21.0"}
+]`
+
+// fakeBootstrapCluster is the cluster the --check probes read. Objects are
+// keyed by resource ("nodes", "customresourcedefinitions", ...) so a test
+// can replace one kind, and failures fail every request for a resource.
+type fakeBootstrapCluster struct {
+	apiErr   error
+	objects  map[string][]k8sruntime.Object
+	failures map[string]error
+	helmList string
+	cluster  *kube.Fake
+}
+
+func (f *fakeBootstrapCluster) install(t *testing.T) {
+	t.Helper()
+	oldHelm := bootstrapHelmReleasesJSON
+	oldFile := bootstrapGetBootstrapFile
+	t.Cleanup(func() {
+		bootstrapHelmReleasesJSON = oldHelm
+		bootstrapGetBootstrapFile = oldFile
+	})
+	var objects []k8sruntime.Object
+	for _, resource := range slices.Sorted(maps.Keys(f.objects)) {
+		objects = append(objects, f.objects[resource]...)
+	}
+	f.cluster = stubBootstrapKube(t, objects...)
+	react := func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+		require.Contains(t, []string{"get", "list"}, action.GetVerb(), "--check must only read")
+		if f.apiErr != nil {
+			return true, nil, f.apiErr
+		}
+		if err := f.failures[action.GetResource().Resource]; err != nil {
+			return true, nil, err
+		}
+		return false, nil, nil
+	}
+	f.cluster.Clientset.PrependReactor("*", "*", react)
+	f.cluster.DynamicClient.PrependReactor("*", "*", react)
+	bootstrapHelmReleasesJSON = func(*BootstrapConfig) ([]byte, error) {
+		if f.helmList == "" {
+			return nil, errors.New("helm: executable file not found")
+		}
+		return []byte(f.helmList), nil
+	}
+	// Pin the expected releases to the four listed above so the test does
+	// not churn with every chart bump in the embedded helmfile.
+	bootstrapGetBootstrapFile = func(name string) (string, error) {
+		require.Equal(t, "helmfile.d/01-apps.yaml", name)
+		return `releases:
+  - {name: cilium, namespace: kube-system, chart: oci://ghcr.io/home-operations/charts-mirror/cilium, version: 1.18.6}
+  - {name: coredns, namespace: kube-system, chart: oci://ghcr.io/coredns/charts/coredns, version: 1.46.2}
+  - {name: spegel, namespace: kube-system, chart: oci://ghcr.io/spegel-org/helm-charts/spegel, version: 0.7.4}
+  - {name: cert-manager, namespace: cert-manager, chart: oci://quay.io/jetstack/charts/cert-manager, version: v1.21.0}
+`, nil
+	}
+}
+
+// requests counts the requests the probes made.
+func (f *fakeBootstrapCluster) requests() int {
+	return len(f.cluster.Clientset.Actions()) + len(f.cluster.DynamicClient.Actions())
+}
+
+// liveClusterSettings is the cluster-settings ConfigMap bootstrap would apply
+// for the effective config.
+func liveClusterSettings(data map[string]string) *corev1.ConfigMap {
+	if data == nil {
+		settings, err := templates.LoadClusterSettings()
+		if err != nil {
+			panic(err)
+		}
+		data = settings.Values
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: templates.ClusterSettingsConfigMapName, Namespace: constants.NSFluxSystem},
+		Data:       data,
+	}
+}
+
+func checkTestNodes(ready ...string) []k8sruntime.Object {
+	nodes := make([]k8sruntime.Object, 0, len(ready))
+	for i, status := range ready {
+		nodes = append(nodes, &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("k8s-%d", i)},
+			Status:     corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionStatus(status)}}},
+		})
+	}
+	return nodes
+}
+
+func checkTestNamespaces(names ...string) []k8sruntime.Object {
+	namespaces := make([]k8sruntime.Object, 0, len(names))
+	for _, name := range names {
+		namespaces = append(namespaces, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	return namespaces
+}
+
+// checkTestObject is an unstructured object whose Ready (or, for a CRD,
+// Established) condition has status and reason.
+func checkTestObject(apiVersion, kind, namespace, name, status, reason string, fields map[string]interface{}) *unstructured.Unstructured {
+	conditionType := "Ready"
+	if kind == "CustomResourceDefinition" {
+		conditionType = "Established"
+	}
+	object := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+		"status": map[string]interface{}{"conditions": []interface{}{
+			map[string]interface{}{"type": conditionType, "status": status, "reason": reason},
+		}},
+	}}
+	for key, value := range fields {
+		_ = unstructured.SetNestedField(object.Object, value, "status", key)
+	}
+	return object
+}
+
+func checkTestCRDs(established map[string]string) []k8sruntime.Object {
+	var crds []k8sruntime.Object
+	for _, name := range slices.Sorted(maps.Keys(established)) {
+		crds = append(crds, checkTestObject("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", name, established[name], "", nil))
+	}
+	return crds
+}
+
+func checkTestGateways(list string) []k8sruntime.Object {
+	var gateways struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(list), &gateways); err != nil {
+		panic(err)
+	}
+	objects := make([]k8sruntime.Object, 0, len(gateways.Items))
+	for _, item := range gateways.Items {
+		item["apiVersion"], item["kind"] = "gateway.networking.k8s.io/v1", "Gateway"
+		objects = append(objects, &unstructured.Unstructured{Object: item})
+	}
+	return objects
+}
+
+func healthyBootstrapCluster() *fakeBootstrapCluster {
+	etcd := make([]k8sruntime.Object, 0, 3)
+	for i := range 3 {
+		etcd = append(etcd, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("etcd-k8s-%d", i), Namespace: constants.NSKubeSystem, Labels: map[string]string{"component": "etcd"}},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		})
+	}
+	return &fakeBootstrapCluster{
+		objects: map[string][]k8sruntime.Object{
+			"configmaps": {liveClusterSettings(nil)},
+			"nodes":      checkTestNodes("True", "True", "True"),
+			"pods":       etcd,
+			"namespaces": checkTestNamespaces(append(initialBootstrapNamespaces(), "default")...),
+			"customresourcedefinitions": checkTestCRDs(map[string]string{
+				"ciliumnodes.cilium.io": "True", "clustersecretstores.external-secrets.io": "True",
+			}),
+			"clustersecretstores": {checkTestObject("external-secrets.io/v1", "ClusterSecretStore", "", "onepassword", "True", "Valid", nil)},
+			"gitrepositories": {checkTestObject("source.toolkit.fluxcd.io/v1", "GitRepository", constants.NSFluxSystem, "flux-system", "True", "Succeeded",
+				map[string]interface{}{"artifact": map[string]interface{}{"revision": "main@sha1:abc"}})},
+			"kustomizations": {checkTestObject("kustomize.toolkit.fluxcd.io/v1", "Kustomization", constants.NSFluxSystem, "cluster", "True", "ReconciliationSucceeded",
+				map[string]interface{}{"lastAppliedRevision": "main@sha1:abc"})},
+			"gateways": checkTestGateways(gatewayTestServing),
+		},
+		helmList: checkHealthyHelmList,
+	}
+}
+
+func checkStatuses(report bootstrapCheckReport) map[string]string {
+	statuses := make(map[string]string, len(report.Steps))
+	for _, step := range report.Steps {
+		statuses[step.Step] = step.Status
+	}
+	return statuses
+}
+
+func TestAssessBootstrapStateHealthyClusterIsAlreadyDone(t *testing.T) {
+	cluster := healthyBootstrapCluster()
+	cluster.install(t)
+
+	report := assessBootstrapState(&BootstrapConfig{Provider: "flatcar", KubeConfig: "/tmp/kubeconfig"})
+	require.Len(t, report.Steps, 9)
+	assert.Equal(t, "kubeadm init/join", report.Steps[0].Step)
+	for _, step := range report.Steps {
+		assert.Equal(t, checkAlreadyDone, step.Status, "%s: %s", step.Step, step.Detail)
+	}
+	assert.Equal(t, 9, report.AlreadyDone)
+	assert.Zero(t, report.WouldChange+report.Unknown)
+}
+
+func TestAssessBootstrapStatePartialCluster(t *testing.T) {
+	cluster := healthyBootstrapCluster()
+	cluster.objects["nodes"] = checkTestNodes("True", "False")
+	cluster.objects["namespaces"] = checkTestNamespaces("default", "kube-system", "flux-system")
+	cluster.objects["customresourcedefinitions"] = checkTestCRDs(map[string]string{
+		"ciliumnodes.cilium.io": "True", "gateways.gateway.networking.k8s.io": "False",
+	})
+	cluster.objects["kustomizations"] = []k8sruntime.Object{
+		checkTestObject("kustomize.toolkit.fluxcd.io/v1", "Kustomization", constants.NSFluxSystem, "cluster", "False", "Progressing", nil),
+	}
+	cluster.objects["configmaps"] = []k8sruntime.Object{liveClusterSettings(map[string]string{"TIMEZONE": "Etc/UTC"})}
+	cluster.objects["gateways"] = checkTestGateways(gatewayTestUnprogrammed)
+	delete(cluster.objects, "clustersecretstores")
+	cluster.helmList = `[{"name":"cilium","namespace":"kube-system","status":"deployed","chart":"cilium-1.18.5"},
+  {"name":"coredns","namespace":"kube-system","status":"failed","chart":"coredns-1.46.2"}]`
+	cluster.install(t)
+
+	report := assessBootstrapState(&BootstrapConfig{Provider: "flatcar"})
+	statuses := checkStatuses(report)
+	assert.Equal(t, checkWouldChange, statuses["kubeadm init/join"])
+	assert.Equal(t, checkAlreadyDone, statuses["etcd bootstrap"])
+	assert.Equal(t, checkWouldChange, statuses["Namespaces"])
+	assert.Equal(t, checkWouldChange, statuses["Cluster settings"])
+	assert.Equal(t, checkWouldChange, statuses["CRDs established"])
+	assert.Equal(t, checkWouldChange, statuses["Helm releases"])
+	assert.Equal(t, checkWouldChange, statuses["ClusterSecretStore"])
+	assert.Equal(t, checkWouldChange, statuses["Flux reconciliation"])
+	assert.Equal(t, checkWouldChange, statuses["Gateway programmed"])
+	assert.Equal(t, 1, report.AlreadyDone)
+	assert.Equal(t, 8, report.WouldChange)
+
+	details := map[string]string{}
+	for _, step := range report.Steps {
+		details[step.Step] = step.Detail
+	}
+	assert.Contains(t, details["Namespaces"], "would create actions-runner-system")
+	assert.NotContains(t, details["Namespaces"], "kube-system,")
+	assert.Contains(t, details["Cluster settings"], "would update")
+	assert.Contains(t, details["Cluster settings"], "TIMEZONE")
+	assert.Contains(t, details["Cluster settings"], "CLUSTER_NAME")
+	assert.Contains(t, details["CRDs established"], "1/2 established; pending gateways.gateway.networking.k8s.io")
+	assert.Contains(t, details["Helm releases"], "kube-system/cilium cilium-1.18.5 -> 1.18.6")
+	assert.Contains(t, details["Helm releases"], "kube-system/coredns is failed")
+	assert.Contains(t, details["Helm releases"], "cert-manager/cert-manager not installed")
+	assert.Contains(t, details["Gateway programmed"], "network/external (Programmed=False (AddressNotAssigned), no address)")
+}
+
+func TestAssessBootstrapStateUnknownWhenProbesCannotRun(t *testing.T) {
+	cluster := healthyBootstrapCluster()
+	cluster.apiErr = errors.New("connection refused")
+	cluster.install(t)
+
+	report := assessBootstrapState(&BootstrapConfig{Provider: "flatcar"})
+	assert.Equal(t, 9, report.Unknown)
+	assert.Contains(t, report.Steps[0].Detail, "API server unreachable with the default kubeconfig")
+	assert.Equal(t, 1, cluster.requests(), "no per-step probes once the API server is unreachable")
+
+	cluster = healthyBootstrapCluster()
+	cluster.helmList = ""
+	cluster.failures = map[string]error{
+		"customresourcedefinitions": errors.New("forbidden"),
+		"nodes":                     errors.New("timeout"),
+		"clustersecretstores":       errors.New("dial tcp 192.168.122.5:6443: connect: connection refused"),
+	}
+	cluster.install(t)
+	statuses := checkStatuses(assessBootstrapState(&BootstrapConfig{Provider: "flatcar"}))
+	assert.Equal(t, checkUnknown, statuses["kubeadm init/join"])
+	assert.Equal(t, checkUnknown, statuses["ClusterSecretStore"], "a failed probe is not evidence the store is missing")
+	assert.Equal(t, checkUnknown, statuses["CRDs established"])
+	assert.Equal(t, checkUnknown, statuses["Helm releases"])
+	assert.Equal(t, checkAlreadyDone, statuses["Namespaces"])
+}
+
+func TestAssessBootstrapStateTalosEtcdUsesTalosctl(t *testing.T) {
+	installBootstrapPlanConfig(t)
+	cluster := healthyBootstrapCluster()
+	cluster.install(t)
+	oldTalosctl := bootstrapTalosctlCombined
+	t.Cleanup(func() { bootstrapTalosctlCombined = oldTalosctl })
+	var etcdErr error
+	bootstrapTalosctlCombined = func(talosConfig string, args ...string) ([]byte, error) {
+		assert.Equal(t, "/tmp/talosconfig", talosConfig)
+		assert.Equal(t, []string{"--nodes", "10.0.0.10", "etcd", "status"}, args)
+		return nil, etcdErr
+	}
+
+	report := assessBootstrapState(&BootstrapConfig{Provider: "talos", TalosConfig: "/tmp/talosconfig"})
+	assert.Equal(t, "Apply Talos machine config", report.Steps[0].Step)
+	assert.Equal(t, checkAlreadyDone, checkStatuses(report)["etcd bootstrap"])
+
+	etcdErr = errors.New("etcd not running")
+	assert.Equal(t, checkWouldChange, checkStatuses(assessBootstrapState(&BootstrapConfig{Provider: "talos", TalosConfig: "/tmp/talosconfig"}))["etcd bootstrap"])
+}
+
+func TestBootstrapCheckCommandPrintsSummaryWithoutRunning(t *testing.T) {
+	oldRun, oldConfirm, oldAssess := runBootstrapFn, bootstrapConfirm, bootstrapAssessFn
+	t.Cleanup(func() { runBootstrapFn, bootstrapConfirm, bootstrapAssessFn = oldRun, oldConfirm, oldAssess })
+	runBootstrapFn = func(*BootstrapConfig) error {
+		t.Fatal("operational bootstrap called in --check mode")
+		return nil
+	}
+	bootstrapConfirm = func(string, bool) (bool, error) {
+		t.Fatal("confirmation called in --check mode")
+		return false, nil
+	}
+	cluster := healthyBootstrapCluster()
+	delete(cluster.objects, "pods")
+	cluster.install(t)
+
+	cmd := NewCommand()
+	var output bytes.Buffer
+	cmd.SetOut(&output)
+	cmd.SetArgs([]string{"--check"})
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, output.String(), "BOOTSTRAP CHECK (NO CHANGES WILL BE MADE)")
+	assert.Contains(t, output.String(), "Summary: 8 already done, 1 would change, 0 unknown")
+
+	output.Reset()
+	cmd = NewCommand()
+	cmd.SetOut(&output)
+	cmd.SetArgs([]string{"--check", "--output", "json"})
+	require.NoError(t, cmd.Execute())
+	var report bootstrapCheckReport
+	require.NoError(t, json.Unmarshal(output.Bytes(), &report))
+	assert.Equal(t, 1, report.WouldChange)
+
+	cmd = NewCommand()
+	cmd.SetArgs([]string{"--check", "--plan"})
+	require.ErrorContains(t, cmd.Execute(), "mutually exclusive")
+}
+
+func TestWarnIfAlreadyBootstrappedOnlyWithExistingKubeconfig(t *testing.T) {
+	oldAssess := bootstrapAssessFn
+	t.Cleanup(func() { bootstrapAssessFn = oldAssess })
+	assessed := 0
+	done := 0
+	bootstrapAssessFn = func(*BootstrapConfig) bootstrapCheckReport {
+		assessed++
+		report := bootstrapCheckReport{Steps: make([]bootstrapCheckStep, 7), AlreadyDone: done, WouldChange: 7 - done}
+		return report
+	}
+	logger := common.NewColorLogger()
+
+	warnIfAlreadyBootstrapped(&BootstrapConfig{}, logger)
+	warnIfAlreadyBootstrapped(&BootstrapConfig{KubeConfig: filepath.Join(t.TempDir(), "missing")}, logger)
+	assert.Zero(t, assessed, "a fresh build has no kubeconfig to assess")
+
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfig, []byte("apiVersion: v1\n"), 0o600))
+	warnIfAlreadyBootstrapped(&BootstrapConfig{KubeConfig: kubeconfig}, logger)
+	done = 5
+	warnIfAlreadyBootstrapped(&BootstrapConfig{KubeConfig: kubeconfig}, logger)
+	assert.Equal(t, 2, assessed)
+}
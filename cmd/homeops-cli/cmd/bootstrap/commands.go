@@ -2,13 +2,14 @@ package bootstrap
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
 	"strings"
 
 	"homeops-cli/internal/common"
+	"homeops-cli/internal/kube"
 )
 
 func parseReplicaCount(value string) (int, error) {
@@ -72,42 +73,17 @@ func buildTalosctlCmdContext(ctx context.Context, talosConfig string, args ...st
 	return exec.CommandContext(ctx, "talosctl", args...) // #nosec G204 -- exec uses an argument array, no shell interpolation
 }
 
-func buildKubectlCmd(config *BootstrapConfig, args ...string) *exec.Cmd {
-	cmdArgs := append(append([]string{}, args...), "--kubeconfig", config.KubeConfig)
-	return common.Command("kubectl", cmdArgs...)
-}
-
-func buildKubectlCmdContext(ctx context.Context, config *BootstrapConfig, args ...string) *exec.Cmd {
-	cmdArgs := append(append([]string{}, args...), "--kubeconfig", config.KubeConfig)
-	return exec.CommandContext(ctx, "kubectl", cmdArgs...) // #nosec G204 -- exec uses an argument array, no shell interpolation
-}
-
-func kubectlOutput(config *BootstrapConfig, args ...string) ([]byte, error) {
-	return buildKubectlCmd(config, args...).Output()
-}
-
-func kubectlRun(config *BootstrapConfig, args ...string) error {
-	return buildKubectlCmd(config, args...).Run()
-}
-
-func kubectlCombinedOutput(config *BootstrapConfig, args ...string) ([]byte, error) {
-	return buildKubectlCmd(config, args...).CombinedOutput()
-}
-
-func kubectlCombinedOutputWithInput(config *BootstrapConfig, input io.Reader, args ...string) ([]byte, error) {
-	cmd := buildKubectlCmd(config, args...)
-	cmd.Stdin = input
-	return cmd.CombinedOutput()
-}
-
-// runKubectlContext executes kubectl with a context-bound timeout/cancellation
-// signal. Output is redacted before being returned via the error path.
-func runKubectlContext(ctx context.Context, config *BootstrapConfig, args ...string) (common.CommandResult, error) {
-	cmdArgs := append(append([]string{}, args...), "--kubeconfig", config.KubeConfig)
-	return common.RunCommand(ctx, common.CommandOptions{
-		Name: "kubectl",
-		Args: cmdArgs,
-	})
+// applyManifest server-side applies a multi-document manifest to config's
+// cluster. API errors can quote the applied fields, so they are redacted.
+func applyManifest(config *BootstrapConfig, manifest string, options kube.ApplyOptions) error {
+	client, err := bootstrapKubeClient(config)
+	if err != nil {
+		return err
+	}
+	if err := client.Apply(context.Background(), manifest, options); err != nil {
+		return errors.New(common.RedactCommandOutput(err.Error()))
+	}
+	return nil
 }
 
 // runTalosctlContext executes talosctl with a context-bound timeout/cancellation
@@ -131,5 +107,11 @@ func buildHelmfileCmd(tempDir string, config *BootstrapConfig, args ...string) *
 	cmd := common.Command("helmfile", args...)
 	cmd.Dir = tempDir
 	cmd.Env = append(os.Environ(), fmt.Sprintf("ROOT_DIR=%s", config.RootDir))
+	// Cluster settings reach values.yaml.gotmpl through env, as they do in
+	// RenderHelmfileValues. A load failure already stopped the cluster
+	// settings step, so it is not repeated here.
+	if settings, err := bootstrapLoadClusterSettings(); err == nil {
+		cmd.Env = append(cmd.Env, settings.Environ()...)
+	}
 	return cmd
 }
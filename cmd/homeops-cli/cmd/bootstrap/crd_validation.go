@@ -0,0 +1,210 @@
+package bootstrap
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// totalAnnotationSizeLimit is the API server's cap on the summed size of an
+// object's annotation keys and values (apimachinery TotalAnnotationSizeLimitB).
+const totalAnnotationSizeLimit = 256 * 1024
+
+// templatedManifest is one document of the CRDs helmfile template output,
+// attributed to the release whose chart rendered it.
+type templatedManifest struct {
+	Release   string
+	Namespace string // release namespace, for documents that omit their own
+	Source    string // the "# Source:" path helm writes above each document
+	Body      string
+}
+
+// crdViolation is one problem found in a templated CRD before it is applied.
+// Warnings are logged; anything else fails the bootstrap step.
+type crdViolation struct {
+	Release string
+	CRD     string
+	Problem string
+	Warning bool
+}
+
+func (v crdViolation) String() string {
+	return fmt.Sprintf("%s (release %s): %s", v.CRD, v.Release, v.Problem)
+}
+
+type crdDocument struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name        string            `yaml:"name"`
+		Namespace   string            `yaml:"namespace"`
+		Annotations map[string]string `yaml:"annotations"`
+	} `yaml:"metadata"`
+	Spec struct {
+		Versions []struct {
+			Name    string `yaml:"name"`
+			Storage bool   `yaml:"storage"`
+		} `yaml:"versions"`
+		Conversion struct {
+			Strategy string `yaml:"strategy"`
+			Webhook  struct {
+				ClientConfig struct {
+					Service *struct {
+						Namespace string `yaml:"namespace"`
+						Name      string `yaml:"name"`
+					} `yaml:"service"`
+				} `yaml:"clientConfig"`
+			} `yaml:"webhook"`
+		} `yaml:"conversion"`
+	} `yaml:"spec"`
+}
+
+// attributeManifest resolves which release rendered doc from helm's
+// "# Source: <chart>/..." header. helmfile concatenates releases in order, so
+// a document without a header belongs to the release before it.
+func attributeManifest(doc string, charts map[string]expectedHelmRelease, previous templatedManifest) templatedManifest {
+	manifest := templatedManifest{Release: previous.Release, Namespace: previous.Namespace, Body: doc}
+	for _, line := range strings.Split(doc, "\n") {
+		source, ok := strings.CutPrefix(strings.TrimSpace(line), "# Source: ")
+		if !ok {
+			continue
+		}
+		manifest.Source = source
+		chart, _, _ := strings.Cut(source, "/")
+		if release, known := charts[chart]; known {
+			manifest.Release, manifest.Namespace = release.Name, release.Namespace
+		} else {
+			manifest.Release, manifest.Namespace = chart, ""
+		}
+		break
+	}
+	if manifest.Release == "" {
+		manifest.Release = "unknown"
+	}
+	return manifest
+}
+
+// chartReleases indexes helmfile releases by chart name, the first segment of
+// helm's "# Source:" paths.
+func chartReleases(releases []expectedHelmRelease) map[string]expectedHelmRelease {
+	charts := make(map[string]expectedHelmRelease, len(releases))
+	for _, release := range releases {
+		charts[path.Base(release.Chart)] = release
+	}
+	return charts
+}
+
+// parseHelmfileReleases reads the releases of an embedded helmfile.
+func parseHelmfileReleases(helmfile string) ([]expectedHelmRelease, error) {
+	var spec struct {
+		Releases []expectedHelmRelease `yaml:"releases"`
+	}
+	if err := yamlv3.Unmarshal([]byte(helmfile), &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse helmfile releases: %w", err)
+	}
+	return spec.Releases, nil
+}
+
+// validateCRDManifests checks each templated CRD the way the API server
+// would, so every problem is reported at once instead of kubectl apply
+// failing on the first one mid-bootstrap. others are the non-CRD documents
+// of the same output; conversion webhooks must point at a Service among them.
+func validateCRDManifests(crds, others []templatedManifest) []crdViolation {
+	services := map[string]bool{}
+	for _, manifest := range others {
+		var doc crdDocument
+		if yamlv3.Unmarshal([]byte(manifest.Body), &doc) != nil || doc.Kind != "Service" {
+			continue
+		}
+		namespace := doc.Metadata.Namespace
+		if namespace == "" {
+			namespace = manifest.Namespace
+		}
+		services[namespace+"/"+doc.Metadata.Name] = true
+	}
+
+	var violations []crdViolation
+	for _, manifest := range crds {
+		violations = append(violations, validateCRD(manifest, services)...)
+	}
+	return violations
+}
+
+func validateCRD(manifest templatedManifest, services map[string]bool) []crdViolation {
+	var doc crdDocument
+	if err := yamlv3.Unmarshal([]byte(manifest.Body), &doc); err != nil {
+		return []crdViolation{{Release: manifest.Release, CRD: manifest.Source, Problem: fmt.Sprintf("not valid YAML: %v", err)}}
+	}
+	name := doc.Metadata.Name
+	if name == "" {
+		name = manifest.Source
+	}
+	var violations []crdViolation
+	add := func(warning bool, format string, args ...any) {
+		violations = append(violations, crdViolation{Release: manifest.Release, CRD: name, Problem: fmt.Sprintf(format, args...), Warning: warning})
+	}
+
+	if size := annotationSize(doc.Metadata.Annotations); size > totalAnnotationSizeLimit {
+		add(false, "annotations total %d bytes, over the API server's %d-byte limit", size, totalAnnotationSizeLimit)
+	}
+	if doc.APIVersion != "apiextensions.k8s.io/v1" {
+		// v1beta1 has a different spec layout and was removed in Kubernetes 1.22.
+		add(false, "apiVersion %s is not served; CRDs must be apiextensions.k8s.io/v1", doc.APIVersion)
+		return violations
+	}
+
+	var storage []string
+	for _, version := range doc.Spec.Versions {
+		if version.Storage {
+			storage = append(storage, version.Name)
+		}
+	}
+	if len(storage) != 1 {
+		add(false, "spec.versions must mark exactly one storage version, found %d%s", len(storage), listSuffix(storage))
+	}
+
+	if doc.Spec.Conversion.Strategy == "Webhook" {
+		service := doc.Spec.Conversion.Webhook.ClientConfig.Service
+		switch {
+		case service == nil:
+			add(true, "conversion webhook has no service reference (URL-based webhooks are not checked)")
+		case !services[service.Namespace+"/"+service.Name]:
+			add(true, "conversion webhook service %s/%s is not rendered by any 00-crds release; conversion fails until it exists", service.Namespace, service.Name)
+		}
+	}
+	return violations
+}
+
+func annotationSize(annotations map[string]string) int {
+	size := 0
+	for key, value := range annotations {
+		size += len(key) + len(value)
+	}
+	return size
+}
+
+func listSuffix(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(values, ", ") + ")"
+}
+
+// crdValidationError joins the blocking violations into one error; nil when
+// there are none.
+func crdValidationError(violations []crdViolation) error {
+	var lines []string
+	for _, violation := range violations {
+		if !violation.Warning {
+			lines = append(lines, "  - "+violation.String())
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	sort.Strings(lines)
+	return fmt.Errorf("%d problem(s) in templated CRDs would be rejected by the API server:\n%s", len(lines), strings.Join(lines, "\n"))
+}
@@ -0,0 +1,135 @@
+package bootstrap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/templates"
+)
+
+// crdFixture reads testdata/crd-validation/<name>.yaml.
+func crdFixture(t *testing.T, name string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join("testdata", "crd-validation", name+".yaml"))
+	require.NoError(t, err)
+	return string(content)
+}
+
+// crdsHelmfileReleases are the releases of the embedded 00-crds helmfile.
+func crdsHelmfileReleases(t *testing.T) []expectedHelmRelease {
+	t.Helper()
+	helmfile, err := templates.GetBootstrapFile("helmfile.d/00-crds.yaml")
+	require.NoError(t, err)
+	releases, err := parseHelmfileReleases(helmfile)
+	require.NoError(t, err)
+	require.NotEmpty(t, releases)
+	return releases
+}
+
+func validateCRDFixtures(t *testing.T, names ...string) []crdViolation {
+	t.Helper()
+	docs := make([]string, 0, len(names))
+	for _, name := range names {
+		docs = append(docs, crdFixture(t, name))
+	}
+	crds, others, err := separateCRDsFromManifests(strings.Join(docs, "\n---\n"), crdsHelmfileReleases(t))
+	require.NoError(t, err)
+	return validateCRDManifests(crds, others)
+}
+
+func TestValidateCRDManifestsRules(t *testing.T) {
+	cases := []struct {
+		fixture string
+		release string
+		crd     string
+		problem string
+		warning bool
+	}{
+		{fixture: "v1beta1", release: "keda", crd: "scaledjobs.keda.sh", problem: "apiVersion apiextensions.k8s.io/v1beta1 is not served"},
+		{fixture: "two-storage-versions", release: "grafana-operator", crd: "grafanas.grafana.integreatly.org", problem: "exactly one storage version, found 2 (v1beta1, v1)"},
+		{fixture: "missing-webhook-service", release: "kgateway-crds", crd: "backends.gateway.kgateway.dev", problem: "service network/kgateway-conversion is not rendered", warning: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.fixture, func(t *testing.T) {
+			violations := validateCRDFixtures(t, tc.fixture)
+			require.Len(t, violations, 1)
+			assert.Equal(t, tc.release, violations[0].Release)
+			assert.Equal(t, tc.crd, violations[0].CRD)
+			assert.Contains(t, violations[0].Problem, tc.problem)
+			assert.Equal(t, tc.warning, violations[0].Warning)
+		})
+	}
+
+	t.Run("oversized-annotations", func(t *testing.T) {
+		doc := strings.Replace(crdFixture(t, "oversized-annotations"), "{{PADDING}}", strings.Repeat("x", totalAnnotationSizeLimit), 1)
+		crds, others, err := separateCRDsFromManifests(doc, crdsHelmfileReleases(t))
+		require.NoError(t, err)
+		violations := validateCRDManifests(crds, others)
+		require.Len(t, violations, 1)
+		assert.Equal(t, "victoria-metrics-k8s-stack", violations[0].Release)
+		assert.Contains(t, violations[0].Problem, "over the API server's 262144-byte limit")
+		assert.False(t, violations[0].Warning)
+	})
+
+	t.Run("valid CRD with its webhook service rendered", func(t *testing.T) {
+		assert.Empty(t, validateCRDFixtures(t, "valid", "webhook-service"),
+			"the Service omits metadata.namespace and inherits the release namespace")
+		violations := validateCRDFixtures(t, "valid")
+		require.Len(t, violations, 1)
+		assert.True(t, violations[0].Warning, "a missing webhook service only warns")
+	})
+}
+
+func TestSeparateCRDsFromManifestsAttributesReleases(t *testing.T) {
+	output := strings.Join([]string{
+		crdFixture(t, "valid"),
+		// A document with no Source header belongs to the release before it.
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: leftover",
+		"# Source: external-dns/crds/dnsendpoint.yaml\napiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\nmetadata:\n  name: dnsendpoints.externaldns.k8s.io",
+		"# Source: mystery/crds/thing.yaml\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: thing",
+	}, "\n---\n")
+	crds, others, err := separateCRDsFromManifests(output, crdsHelmfileReleases(t))
+	require.NoError(t, err)
+	require.Len(t, crds, 2)
+	assert.Equal(t, "external-secrets", crds[0].Release)
+	assert.Equal(t, "external-secrets", crds[0].Namespace)
+	assert.Equal(t, "cloudflare-dns", crds[1].Release, "external-dns chart is installed as the cloudflare-dns release")
+	assert.Equal(t, "network", crds[1].Namespace)
+	require.Len(t, others, 2)
+	assert.Equal(t, "external-secrets", others[0].Release)
+	assert.Equal(t, "mystery", others[1].Release, "charts outside the helmfile keep their chart name")
+}
+
+func TestValidateCRDManifestsReportsEverythingTogether(t *testing.T) {
+	violations := validateCRDFixtures(t, "valid", "webhook-service", "v1beta1", "two-storage-versions", "missing-webhook-service")
+	require.Len(t, violations, 3)
+
+	err := crdValidationError(violations)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2 problem(s)")
+	assert.Contains(t, err.Error(), "scaledjobs.keda.sh (release keda)")
+	assert.Contains(t, err.Error(), "grafanas.grafana.integreatly.org (release grafana-operator)")
+	assert.NotContains(t, err.Error(), "kgateway-conversion", "warnings are logged, not failed on")
+
+	assert.NoError(t, crdValidationError(validateCRDFixtures(t, "missing-webhook-service")))
+}
+
+func TestApplyCRDsFromHelmfileStopsBeforeApplyingInvalidCRDs(t *testing.T) {
+	oldHelmfileTemplateOutput := bootstrapHelmfileTemplateOutput
+	t.Cleanup(func() { bootstrapHelmfileTemplateOutput = oldHelmfileTemplateOutput })
+	cluster := stubBootstrapKube(t)
+	bootstrapHelmfileTemplateOutput = func(string, *BootstrapConfig, string) ([]byte, error) {
+		return []byte(crdFixture(t, "valid") + "\n---\n" + crdFixture(t, "v1beta1")), nil
+	}
+
+	err := applyCRDsFromHelmfile(&BootstrapConfig{RootDir: t.TempDir()}, common.NewColorLogger())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "scaledjobs.keda.sh (release keda)")
+	assert.Empty(t, appliedObjects(cluster), "nothing is applied when a CRD would be rejected")
+}
@@ -1,9 +1,10 @@
 package bootstrap
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
+	"io"
+	"net/http"
 	neturl "net/url"
 	"os"
 	"path/filepath"
@@ -12,6 +13,7 @@ import (
 
 	"homeops-cli/internal/common"
 	"homeops-cli/internal/constants"
+	"homeops-cli/internal/kube"
 
 	yamlv3 "gopkg.in/yaml.v3"
 )
@@ -120,14 +122,41 @@ func applyGatewayAPICRDs(config *BootstrapConfig, logger *common.ColorLogger) er
 
 	logger.Info("Applying Gateway API CRDs %s from %s", version, url)
 
-	if output, err := kubectlCombinedOutput(config, "apply", "--server-side", "--filename", url); err != nil {
-		return fmt.Errorf("failed to apply Gateway API CRDs %s from %s: %w\nKubectl output: %s", version, url, err, redactCommandOutput(output))
+	manifest, err := fetchManifest(url)
+	if err != nil {
+		return fmt.Errorf("failed to download Gateway API CRDs %s from %s: %w", version, url, err)
+	}
+	if err := applyManifest(config, manifest, kube.ApplyOptions{}); err != nil {
+		return fmt.Errorf("failed to apply Gateway API CRDs %s from %s: %w", version, url, err)
 	}
 
 	logger.Success("Gateway API CRDs %s applied successfully", version)
 	return nil
 }
 
+// fetchManifest downloads the manifest at url.
+func fetchManifest(url string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := bootstrapHTTPDo(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
 func applyCRDsFromHelmfile(config *BootstrapConfig, logger *common.ColorLogger) error {
 	logger.Info("Applying CRDs from dedicated helmfile...")
 
@@ -172,8 +201,13 @@ func applyCRDsFromHelmfile(config *BootstrapConfig, logger *common.ColorLogger)
 		return nil
 	}
 
+	releases, err := parseHelmfileReleases(crdsHelmfileTemplate)
+	if err != nil {
+		return err
+	}
+
 	// Extract only the CRDs from the output
-	crdManifests, otherManifests, err := separateCRDsFromManifests(string(output))
+	crdManifests, otherManifests, err := separateCRDsFromManifests(string(output), releases)
 	if err != nil {
 		return fmt.Errorf("failed to separate CRDs from manifests: %w", err)
 	}
@@ -182,13 +216,27 @@ func applyCRDsFromHelmfile(config *BootstrapConfig, logger *common.ColorLogger)
 		logger.Debug("Found %d non-CRD resources in CRDs helmfile output, ignoring them", len(otherManifests))
 	}
 
+	violations := validateCRDManifests(crdManifests, otherManifests)
+	for _, violation := range violations {
+		if violation.Warning {
+			logger.Warn("CRD %s", violation)
+		}
+	}
+	if err := crdValidationError(violations); err != nil {
+		return err
+	}
+
 	// Apply only the CRDs
 	if len(crdManifests) > 0 {
 		logger.Info("Applying %d CRDs...", len(crdManifests))
-		crdYaml := strings.Join(crdManifests, "\n---\n")
+		bodies := make([]string, 0, len(crdManifests))
+		for _, manifest := range crdManifests {
+			bodies = append(bodies, manifest.Body)
+		}
+		crdYaml := strings.Join(bodies, "\n---\n")
 
-		if applyOutput, err := bootstrapKubectlCombinedIn(config, bytes.NewReader([]byte(crdYaml)), "apply", "--server-side", "--filename", "-"); err != nil {
-			return fmt.Errorf("failed to apply CRDs: %w\nOutput: %s", err, redactCommandOutput(applyOutput))
+		if err := applyManifest(config, crdYaml, kube.ApplyOptions{}); err != nil {
+			return fmt.Errorf("failed to apply CRDs: %w", err)
 		}
 
 		logger.Info("CRDs applied, waiting for them to be established...")
@@ -205,10 +253,13 @@ func applyCRDsFromHelmfile(config *BootstrapConfig, logger *common.ColorLogger)
 	return nil
 }
 
-// separateCRDsFromManifests separates CRD manifests from other manifests
-func separateCRDsFromManifests(manifestsYaml string) ([]string, []string, error) {
-	var crdManifests []string
-	var otherManifests []string
+// separateCRDsFromManifests separates CRD manifests from other manifests,
+// attributing each document to the helmfile release that rendered it
+func separateCRDsFromManifests(manifestsYaml string, releases []expectedHelmRelease) ([]templatedManifest, []templatedManifest, error) {
+	var crdManifests []templatedManifest
+	var otherManifests []templatedManifest
+	charts := chartReleases(releases)
+	var previous templatedManifest
 
 	// Split by YAML document separator
 	documents := strings.Split(manifestsYaml, "\n---\n")
@@ -218,12 +269,14 @@ func separateCRDsFromManifests(manifestsYaml string) ([]string, []string, error)
 		if doc == "" || doc == "---" {
 			continue
 		}
+		manifest := attributeManifest(doc, charts, previous)
+		previous = manifest
 
 		// Check if this is a CRD by looking for "kind: CustomResourceDefinition"
 		if strings.Contains(doc, "kind: CustomResourceDefinition") {
-			crdManifests = append(crdManifests, doc)
+			crdManifests = append(crdManifests, manifest)
 		} else {
-			otherManifests = append(otherManifests, doc)
+			otherManifests = append(otherManifests, manifest)
 		}
 	}
 
@@ -232,6 +285,32 @@ func separateCRDsFromManifests(manifestsYaml string) ([]string, []string, error)
 
 // waitForCRDsEstablished waits for all CRDs to be established using progress-based detection
 // It keeps waiting as long as progress is being made, only failing if stuck for too long
+// checkCRDsEstablished reads the Established condition of every CRD once,
+// returning the established and total counts and the names still pending.
+func checkCRDsEstablished(config *BootstrapConfig) (established, total int, pending []string, err error) {
+	crds, err := listCRDs(config)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	for _, crd := range crds {
+		total++
+		if crd.Established == "True" {
+			established++
+		} else {
+			pending = append(pending, crd.Name)
+		}
+	}
+	return established, total, pending, nil
+}
+
+func listCRDs(config *BootstrapConfig) ([]kube.CRD, error) {
+	client, err := bootstrapKubeClient(config)
+	if err != nil {
+		return nil, err
+	}
+	return client.CRDs(context.Background())
+}
+
 func waitForCRDsEstablished(config *BootstrapConfig, logger *common.ColorLogger) error {
 	checkInterval := bootstrapCheckIntervalFast
 	stallTimeout := bootstrapStallTimeout
@@ -239,8 +318,12 @@ func waitForCRDsEstablished(config *BootstrapConfig, logger *common.ColorLogger)
 
 	startTime := bootstrapNow()
 	lastProgressTime := bootstrapNow()
+	nextProgress := startTime.Add(20 * time.Second)
 	lastEstablishedCount := 0
 
+	watcher := watchBootstrapResources(config, logger, "", kube.CRDResource)
+	defer watcher.stop()
+
 	for {
 		elapsed := bootstrapNow().Sub(startTime)
 
@@ -249,40 +332,15 @@ func waitForCRDsEstablished(config *BootstrapConfig, logger *common.ColorLogger)
 			return fmt.Errorf("CRDs did not become established after %v (max wait exceeded)", elapsed.Round(time.Second))
 		}
 
-		output, err := bootstrapKubectlOutput(config, "get", "crd",
-			"--output=jsonpath={range .items[*]}{.metadata.name}:{.status.conditions[?(@.type=='Established')].status}{\"\\n\"}{end}")
+		establishedCount, totalCRDs, pendingCRDs, err := checkCRDsEstablished(config)
 		if err != nil {
 			logger.Debug("Failed to check CRD status: %v", err)
-			bootstrapSleep(checkInterval)
+			watcher.wait(checkInterval)
 			continue
 		}
 
-		lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-		allEstablished := true
-		establishedCount := 0
-		totalCRDs := 0
-		var pendingCRDs []string
-
-		for _, line := range lines {
-			if line == "" {
-				continue
-			}
-			parts := strings.Split(line, ":")
-			if len(parts) == 2 {
-				totalCRDs++
-				crdName := parts[0]
-				status := parts[1]
-				if status == "True" {
-					establishedCount++
-				} else {
-					allEstablished = false
-					pendingCRDs = append(pendingCRDs, crdName)
-				}
-			}
-		}
-
 		// Success: all CRDs established
-		if allEstablished && establishedCount > 0 {
+		if len(pendingCRDs) == 0 && establishedCount > 0 {
 			logger.Success("All %d CRDs are established (took %v)", establishedCount, elapsed.Round(time.Second))
 			return nil
 		}
@@ -302,14 +360,14 @@ func waitForCRDsEstablished(config *BootstrapConfig, logger *common.ColorLogger)
 		}
 
 		// Periodic status update
-		if int(elapsed.Seconds())%20 == 0 && elapsed.Seconds() > 0 {
+		if progressDue(&nextProgress, 20*time.Second) {
 			logger.Info("Waiting for CRDs: %d/%d established, %v elapsed", establishedCount, totalCRDs, elapsed.Round(time.Second))
 			if len(pendingCRDs) > 0 && len(pendingCRDs) <= 5 {
 				logger.Debug("Pending CRDs: %v", pendingCRDs)
 			}
 		}
 
-		bootstrapSleep(checkInterval)
+		watcher.wait(checkInterval)
 	}
 }
 
@@ -330,32 +388,23 @@ func fixExistingCRDMetadata(config *BootstrapConfig, logger *common.ColorLogger)
 		"cert-manager.io":     {releaseName: "cert-manager", releaseNamespace: constants.NSCertManager},
 	}
 
-	// Get all CRDs
-	output, err := bootstrapKubectlOutput(config, "get", "crds", "-o", "json")
+	client, err := bootstrapKubeClient(config)
 	if err != nil {
 		return fmt.Errorf("failed to get CRDs: %w", err)
 	}
+	ctx := context.Background()
 
-	// Parse the JSON output
-	var crdList struct {
-		Items []struct {
-			Metadata struct {
-				Name        string            `json:"name"`
-				Labels      map[string]string `json:"labels"`
-				Annotations map[string]string `json:"annotations"`
-			} `json:"metadata"`
-		} `json:"items"`
-	}
-
-	if err := json.Unmarshal(output, &crdList); err != nil {
-		return fmt.Errorf("failed to parse CRD list: %w", err)
+	// Get all CRDs
+	crds, err := client.CRDs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get CRDs: %w", err)
 	}
 
 	fixedCount := 0
-	for _, crd := range crdList.Items {
+	for _, crd := range crds {
 		// Check if CRD already has Helm ownership metadata
-		if crd.Metadata.Labels["app.kubernetes.io/managed-by"] == "Helm" &&
-			crd.Metadata.Annotations["meta.helm.sh/release-name"] != "" {
+		if crd.Labels["app.kubernetes.io/managed-by"] == "Helm" &&
+			crd.Annotations["meta.helm.sh/release-name"] != "" {
 			continue
 		}
 
@@ -366,7 +415,7 @@ func fixExistingCRDMetadata(config *BootstrapConfig, logger *common.ColorLogger)
 		}
 
 		for groupSuffix, groupOwner := range crdGroups {
-			if strings.HasSuffix(crd.Metadata.Name, groupSuffix) {
+			if strings.HasSuffix(crd.Name, groupSuffix) {
 				owner = &groupOwner
 				break
 			}
@@ -378,23 +427,16 @@ func fixExistingCRDMetadata(config *BootstrapConfig, logger *common.ColorLogger)
 		}
 
 		logger.Debug("Adding Helm metadata to CRD: %s (owner: %s/%s)",
-			crd.Metadata.Name, owner.releaseNamespace, owner.releaseName)
+			crd.Name, owner.releaseNamespace, owner.releaseName)
 
 		// Patch the CRD with Helm ownership metadata
-		if output, err := bootstrapKubectlCombined(config, "annotate", "crd", crd.Metadata.Name,
-			fmt.Sprintf("meta.helm.sh/release-name=%s", owner.releaseName),
-			fmt.Sprintf("meta.helm.sh/release-namespace=%s", owner.releaseNamespace),
-			"--overwrite"); err != nil {
-			logger.Warn("Failed to add annotations to CRD %s: %v\nOutput: %s",
-				crd.Metadata.Name, err, redactCommandOutput(output))
-			continue
-		}
-
-		if output, err := bootstrapKubectlCombined(config, "label", "crd", crd.Metadata.Name,
-			"app.kubernetes.io/managed-by=Helm",
-			"--overwrite"); err != nil {
-			logger.Warn("Failed to add labels to CRD %s: %v\nOutput: %s",
-				crd.Metadata.Name, err, redactCommandOutput(output))
+		if err := client.PatchMetadata(ctx, kube.CRDResource, "", crd.Name,
+			map[string]string{"app.kubernetes.io/managed-by": "Helm"},
+			map[string]string{
+				"meta.helm.sh/release-name":      owner.releaseName,
+				"meta.helm.sh/release-namespace": owner.releaseNamespace,
+			}); err != nil {
+			logger.Warn("Failed to add Helm metadata to CRD %s: %v", crd.Name, err)
 			continue
 		}
 
@@ -1,21 +1,29 @@
 package bootstrap
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
 
 	"homeops-cli/internal/common"
 	"homeops-cli/internal/constants"
+	"homeops-cli/internal/kube"
 	"homeops-cli/internal/metrics"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 // testAPIServerConnectivity is a simpler version for retry checks
 func testAPIServerConnectivity(config *BootstrapConfig, logger *common.ColorLogger) error {
-	output, err := bootstrapKubectlCombined(config, "cluster-info", "--request-timeout=10s")
+	client, err := bootstrapKubeClient(config)
+	if err == nil {
+		err = client.Ping()
+	}
 	if err != nil {
-		logger.Debug("API server connectivity check output: %s", redactCommandOutput(output))
-		return fmt.Errorf("cluster-info failed: %w", err)
+		logger.Debug("API server connectivity check failed: %v", err)
+		return fmt.Errorf("API server unreachable: %w", err)
 	}
 	return nil
 }
@@ -27,8 +35,7 @@ func isExternalSecretsInstalled(config *BootstrapConfig, logger *common.ColorLog
 	checkInterval := bootstrapCheckIntervalNormal
 
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		err := bootstrapKubectlRun(config, "get", "deployment", "external-secrets-webhook",
-			"-n", constants.NSExternalSecret, "--request-timeout=5s")
+		_, err := deploymentStatus(config, constants.NSExternalSecret, "external-secrets-webhook")
 
 		if err == nil {
 			logger.Debug("External-secrets deployment found on attempt %d", attempt)
@@ -45,6 +52,30 @@ func isExternalSecretsInstalled(config *BootstrapConfig, logger *common.ColorLog
 	return false
 }
 
+func deploymentStatus(config *BootstrapConfig, namespace, name string) (kube.DeploymentStatus, error) {
+	client, err := bootstrapKubeClient(config)
+	if err != nil {
+		return kube.DeploymentStatus{}, err
+	}
+	return client.Deployment(context.Background(), namespace, name)
+}
+
+func serviceEndpoints(config *BootstrapConfig, namespace, service string) ([]string, error) {
+	client, err := bootstrapKubeClient(config)
+	if err != nil {
+		return nil, err
+	}
+	return client.ServiceEndpoints(context.Background(), namespace, service)
+}
+
+func podSummary(config *BootstrapConfig, namespace string) (string, error) {
+	client, err := bootstrapKubeClient(config)
+	if err != nil {
+		return "", err
+	}
+	return client.PodSummary(context.Background(), namespace)
+}
+
 // waitForExternalSecretsWebhook waits for the external-secrets webhook to be ready using progress-based detection
 func waitForExternalSecretsWebhook(config *BootstrapConfig, logger *common.ColorLogger) error {
 	checkInterval := bootstrapCheckIntervalNormal
@@ -53,8 +84,12 @@ func waitForExternalSecretsWebhook(config *BootstrapConfig, logger *common.Color
 
 	startTime := bootstrapNow()
 	lastProgressTime := bootstrapNow()
+	nextProgress := startTime.Add(30 * time.Second)
 	lastState := ""
 
+	watcher := watchBootstrapResources(config, logger, constants.NSExternalSecret, kube.DeploymentResource, kube.EndpointSliceResource)
+	defer watcher.stop()
+
 	for {
 		elapsed := bootstrapNow().Sub(startTime)
 
@@ -64,28 +99,24 @@ func waitForExternalSecretsWebhook(config *BootstrapConfig, logger *common.Color
 		}
 
 		// Check deployment status
-		output, err := bootstrapKubectlOutput(config, "get", "deployment", "external-secrets-webhook",
-			"-n", constants.NSExternalSecret,
-			"--output=jsonpath={.status.readyReplicas}/{.status.replicas}:{.status.conditions[?(@.type=='Available')].status}")
+		deployment, err := deploymentStatus(config, constants.NSExternalSecret, "external-secrets-webhook")
 		var currentState string
 
 		if err != nil {
 			stallDuration := bootstrapNow().Sub(lastProgressTime)
 			if stallDuration > stallTimeout {
-				return fmt.Errorf("external-secrets webhook stalled: kubectl get deployment failed for %v: %w",
+				return fmt.Errorf("external-secrets webhook stalled: reading the deployment failed for %v: %w",
 					stallDuration.Round(time.Second), err)
 			}
-			bootstrapSleep(checkInterval)
+			watcher.wait(checkInterval)
 			continue
 		}
 
-		currentState = strings.TrimSpace(string(output))
+		currentState = fmt.Sprintf("%d/%d:%s", deployment.ReadyReplicas, deployment.Replicas, deployment.Available)
 
 		// Also check if the webhook service has endpoints.
-		endpointsOutput, endpointsErr := bootstrapKubectlOutput(config, "get", "endpoints", "external-secrets-webhook",
-			"-n", constants.NSExternalSecret,
-			"--output=jsonpath={.subsets[*].addresses[*].ip}")
-		if endpointsErr == nil && deploymentAndEndpointsReadyFromState(currentState, string(endpointsOutput)) {
+		endpoints, endpointsErr := serviceEndpoints(config, constants.NSExternalSecret, "external-secrets-webhook")
+		if endpointsErr == nil && deploymentAndEndpointsReadyFromState(currentState, strings.Join(endpoints, " ")) {
 			logger.Success("External-secrets webhook is ready (took %v)", elapsed.Round(time.Second))
 			return nil
 		}
@@ -102,21 +133,21 @@ func waitForExternalSecretsWebhook(config *BootstrapConfig, logger *common.Color
 		stallDuration := bootstrapNow().Sub(lastProgressTime)
 		if stallDuration > stallTimeout {
 			// Get detailed pod info for debugging
-			podOutput, _ := bootstrapKubectlOutput(config, "get", "pods", "-n", constants.NSExternalSecret, "-o", "wide")
+			podOutput, _ := podSummary(config, constants.NSExternalSecret)
 			return fmt.Errorf("external-secrets webhook stalled: no progress for %v (state: %s)\nPods:\n%s",
-				stallDuration.Round(time.Second), currentState, redactCommandOutput(podOutput))
+				stallDuration.Round(time.Second), currentState, podOutput)
 		}
 
 		// Periodic status update
-		if int(elapsed.Seconds())%30 == 0 && elapsed.Seconds() > 0 {
+		if progressDue(&nextProgress, 30*time.Second) {
 			logger.Info("Waiting for external-secrets webhook: state=%s, %v elapsed", currentState, elapsed.Round(time.Second))
 			// Show pod status for debugging
-			if podOutput, podErr := bootstrapKubectlOutput(config, "get", "pods", "-n", constants.NSExternalSecret, "-o", "wide"); podErr == nil {
-				logger.Debug("External-secrets pods:\n%s", redactCommandOutput(podOutput))
+			if podOutput, podErr := podSummary(config, constants.NSExternalSecret); podErr == nil {
+				logger.Debug("External-secrets pods:\n%s", podOutput)
 			}
 		}
 
-		bootstrapSleep(checkInterval)
+		watcher.wait(checkInterval)
 	}
 }
 
@@ -206,8 +237,12 @@ func waitForFluxController(config *BootstrapConfig, logger *common.ColorLogger,
 
 	startTime := bootstrapNow()
 	lastProgressTime := bootstrapNow()
+	nextProgress := startTime.Add(30 * time.Second)
 	lastState := ""
 
+	watcher := watchBootstrapResources(config, logger, constants.NSFluxSystem, kube.DeploymentResource)
+	defer watcher.stop()
+
 	for {
 		elapsed := bootstrapNow().Sub(startTime)
 
@@ -215,22 +250,20 @@ func waitForFluxController(config *BootstrapConfig, logger *common.ColorLogger,
 			return fmt.Errorf("%s did not become ready after %v (max wait exceeded)", controllerName, elapsed.Round(time.Second))
 		}
 
-		output, err := bootstrapKubectlOutput(config, "get", "deployment", controllerName,
-			"-n", constants.NSFluxSystem,
-			"--output=jsonpath={.status.readyReplicas}/{.status.replicas}")
+		deployment, err := deploymentStatus(config, constants.NSFluxSystem, controllerName)
 		var currentState string
 
 		if err != nil {
 			stallDuration := bootstrapNow().Sub(lastProgressTime)
 			if stallDuration > stallTimeout {
-				return fmt.Errorf("%s stalled: kubectl get deployment failed for %v: %w",
+				return fmt.Errorf("%s stalled: reading the deployment failed for %v: %w",
 					controllerName, stallDuration.Round(time.Second), err)
 			}
-			bootstrapSleep(checkInterval)
+			watcher.wait(checkInterval)
 			continue
 		}
 
-		currentState = strings.TrimSpace(string(output))
+		currentState = fmt.Sprintf("%d/%d", deployment.ReadyReplicas, deployment.Replicas)
 		if deploymentReadyFromState(currentState) {
 			logger.Debug("Flux %s is ready (took %v)", controllerName, elapsed.Round(time.Second))
 			return nil
@@ -249,14 +282,50 @@ func waitForFluxController(config *BootstrapConfig, logger *common.ColorLogger,
 			return fmt.Errorf("%s stalled: no progress for %v (state: %s)", controllerName, stallDuration.Round(time.Second), currentState)
 		}
 
-		if int(elapsed.Seconds())%30 == 0 && elapsed.Seconds() > 0 {
+		if progressDue(&nextProgress, 30*time.Second) {
 			logger.Debug("Waiting for Flux %s: state=%s, %v elapsed", controllerName, currentState, elapsed.Round(time.Second))
 		}
-		bootstrapSleep(checkInterval)
+		watcher.wait(checkInterval)
 	}
 }
 
 // waitForGitRepositoryReady waits for the flux-system GitRepository to be ready using progress-based detection
+// checkFluxObjectReady reads a flux-system object's Ready condition once and
+// returns it with a "status:reason:revision" state string ("not-found" when
+// the object cannot be read).
+func checkFluxObjectReady(config *BootstrapConfig, resource schema.GroupVersionResource, name string, revisionPath ...string) (bool, string) {
+	client, err := bootstrapKubeClient(config)
+	if err != nil {
+		return false, "not-found"
+	}
+	object, err := client.Get(context.Background(), resource, constants.NSFluxSystem, name)
+	if err != nil {
+		return false, "not-found"
+	}
+	status, reason := kube.Condition(object, "Ready")
+	revision, _, _ := unstructured.NestedString(object.Object, revisionPath...)
+	return status == "True", status + ":" + reason + ":" + revision
+}
+
+// fluxObjectsSummary is one "name Ready=status reason" line per flux-system
+// object of resource, for diagnostics.
+func fluxObjectsSummary(config *BootstrapConfig, resource schema.GroupVersionResource) string {
+	client, err := bootstrapKubeClient(config)
+	if err != nil {
+		return ""
+	}
+	objects, err := client.List(context.Background(), resource, constants.NSFluxSystem)
+	if err != nil {
+		return ""
+	}
+	lines := make([]string, 0, len(objects))
+	for i := range objects {
+		status, reason := kube.Condition(&objects[i], "Ready")
+		lines = append(lines, fmt.Sprintf("%s Ready=%s %s", objects[i].GetName(), status, reason))
+	}
+	return strings.Join(lines, "\n")
+}
+
 func waitForGitRepositoryReady(config *BootstrapConfig, logger *common.ColorLogger) error {
 	checkInterval := bootstrapCheckIntervalNormal
 	stallTimeout := bootstrapStallTimeout
@@ -264,8 +333,12 @@ func waitForGitRepositoryReady(config *BootstrapConfig, logger *common.ColorLogg
 
 	startTime := bootstrapNow()
 	lastProgressTime := bootstrapNow()
+	nextProgress := startTime.Add(30 * time.Second)
 	lastState := ""
 
+	watcher := watchBootstrapResources(config, logger, constants.NSFluxSystem, kube.GitRepositoryResource)
+	defer watcher.stop()
+
 	for {
 		elapsed := bootstrapNow().Sub(startTime)
 
@@ -274,18 +347,10 @@ func waitForGitRepositoryReady(config *BootstrapConfig, logger *common.ColorLogg
 		}
 
 		// Check GitRepository status with more detail
-		output, err := bootstrapKubectlOutput(config, "get", "gitrepository", "flux-system",
-			"-n", constants.NSFluxSystem,
-			"--output=jsonpath={.status.conditions[?(@.type=='Ready')].status}:{.status.conditions[?(@.type=='Ready')].reason}:{.status.artifact.revision}")
-		currentState := "not-found"
-
-		if err == nil {
-			currentState = strings.TrimSpace(string(output))
-			parts := strings.Split(currentState, ":")
-			if len(parts) >= 1 && parts[0] == "True" {
-				logger.Debug("GitRepository flux-system is ready (took %v)", elapsed.Round(time.Second))
-				return nil
-			}
+		ready, currentState := checkFluxObjectReady(config, kube.GitRepositoryResource, "flux-system", "status", "artifact", "revision")
+		if ready {
+			logger.Debug("GitRepository flux-system is ready (took %v)", elapsed.Round(time.Second))
+			return nil
 		}
 
 		// Check for progress (state change means something is happening)
@@ -299,15 +364,14 @@ func waitForGitRepositoryReady(config *BootstrapConfig, logger *common.ColorLogg
 		stallDuration := bootstrapNow().Sub(lastProgressTime)
 		if stallDuration > stallTimeout {
 			// Get diagnostic info
-			diagOutput, _ := bootstrapKubectlOutput(config, "get", "gitrepository", "-n", constants.NSFluxSystem, "-o", "wide")
 			return fmt.Errorf("GitRepository stalled: no progress for %v (state: %s)\n%s",
-				stallDuration.Round(time.Second), currentState, redactCommandOutput(diagOutput))
+				stallDuration.Round(time.Second), currentState, fluxObjectsSummary(config, kube.GitRepositoryResource))
 		}
 
-		if int(elapsed.Seconds())%30 == 0 && elapsed.Seconds() > 0 {
+		if progressDue(&nextProgress, 30*time.Second) {
 			logger.Info("Waiting for GitRepository: state=%s, %v elapsed", currentState, elapsed.Round(time.Second))
 		}
-		bootstrapSleep(checkInterval)
+		watcher.wait(checkInterval)
 	}
 }
 
@@ -319,8 +383,12 @@ func waitForFluxKustomizationReady(config *BootstrapConfig, logger *common.Color
 
 	startTime := bootstrapNow()
 	lastProgressTime := bootstrapNow()
+	nextProgress := startTime.Add(30 * time.Second)
 	lastState := ""
 
+	watcher := watchBootstrapResources(config, logger, constants.NSFluxSystem, kube.KustomizationResource)
+	defer watcher.stop()
+
 	for {
 		elapsed := bootstrapNow().Sub(startTime)
 
@@ -329,18 +397,10 @@ func waitForFluxKustomizationReady(config *BootstrapConfig, logger *common.Color
 		}
 
 		// Check Kustomization status with more detail
-		output, err := bootstrapKubectlOutput(config, "get", "kustomization", ksName,
-			"-n", constants.NSFluxSystem,
-			"--output=jsonpath={.status.conditions[?(@.type=='Ready')].status}:{.status.conditions[?(@.type=='Ready')].reason}:{.status.lastAppliedRevision}")
-		currentState := "not-found"
-
-		if err == nil {
-			currentState = strings.TrimSpace(string(output))
-			parts := strings.Split(currentState, ":")
-			if len(parts) >= 1 && parts[0] == "True" {
-				logger.Debug("Kustomization %s is ready (took %v)", ksName, elapsed.Round(time.Second))
-				return nil
-			}
+		ready, currentState := checkFluxObjectReady(config, kube.KustomizationResource, ksName, "status", "lastAppliedRevision")
+		if ready {
+			logger.Debug("Kustomization %s is ready (took %v)", ksName, elapsed.Round(time.Second))
+			return nil
 		}
 
 		// Check for progress
@@ -354,14 +414,13 @@ func waitForFluxKustomizationReady(config *BootstrapConfig, logger *common.Color
 		stallDuration := bootstrapNow().Sub(lastProgressTime)
 		if stallDuration > stallTimeout {
 			// Get diagnostic info
-			diagOutput, _ := bootstrapKubectlOutput(config, "get", "kustomization", "-n", constants.NSFluxSystem, "-o", "wide")
 			return fmt.Errorf("kustomization %s stalled: no progress for %v (state: %s)\n%s",
-				ksName, stallDuration.Round(time.Second), currentState, redactCommandOutput(diagOutput))
+				ksName, stallDuration.Round(time.Second), currentState, fluxObjectsSummary(config, kube.KustomizationResource))
 		}
 
-		if int(elapsed.Seconds())%30 == 0 && elapsed.Seconds() > 0 {
+		if progressDue(&nextProgress, 30*time.Second) {
 			logger.Info("Waiting for Kustomization '%s': state=%s, %v elapsed", ksName, currentState, elapsed.Round(time.Second))
 		}
-		bootstrapSleep(checkInterval)
+		watcher.wait(checkInterval)
 	}
 }
@@ -0,0 +1,83 @@
+package bootstrap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/kube"
+	"homeops-cli/internal/kubeutil"
+)
+
+// waitForGatewaysProgrammed is the post-Flux smoke test: when the cluster has
+// any Gateway API Gateway, at least one must become Programmed with an
+// address before bootstrap reports success. A cluster without Gateways passes.
+func waitForGatewaysProgrammed(config *BootstrapConfig, logger *common.ColorLogger) error {
+	if config.DryRun {
+		logger.Info("[DRY RUN] Would wait for a Gateway to be Programmed with an address")
+		return nil
+	}
+
+	// Bounded by attempts as well as elapsed time so a frozen clock cannot
+	// keep the loop alive.
+	checkInterval := bootstrapCheckIntervalNormal
+	maxWait := bootstrapGatewayMaxWait
+	maxAttempts := gatewayWaitAttempts(maxWait, checkInterval)
+	startTime := bootstrapNow()
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		lastErr = checkGatewaysProgrammed(config)
+		if lastErr == nil {
+			logger.Success("Gateway API check passed")
+			return nil
+		}
+		elapsed := bootstrapNow().Sub(startTime)
+		if elapsed > maxWait || attempt >= maxAttempts {
+			return fmt.Errorf("after %d attempt(s) over %v: %w", attempt, elapsed.Round(time.Second), lastErr)
+		}
+		logger.Debug("Waiting for Gateways: %v", lastErr)
+		bootstrapSleep(checkInterval)
+	}
+}
+
+// gatewayWaitAttempts is how many checks fit in maxWait at interval, at
+// least one.
+func gatewayWaitAttempts(maxWait, interval time.Duration) int {
+	if interval <= 0 {
+		return 1
+	}
+	return int(maxWait/interval) + 1
+}
+
+// checkGatewaysProgrammed reads every Gateway once and applies
+// kubeutil.CheckGatewayServing.
+func checkGatewaysProgrammed(config *BootstrapConfig) error {
+	gateways, err := listGateways(config)
+	if err != nil {
+		return err
+	}
+	return kubeutil.CheckGatewayServing(gateways)
+}
+
+// listGateways reads the Gateways of every namespace.
+func listGateways(config *BootstrapConfig) ([]kubeutil.Gateway, error) {
+	client, err := bootstrapKubeClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", kubeutil.GatewayResource, err)
+	}
+	items, err := client.List(context.Background(), kube.GatewayResource, "")
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", kubeutil.GatewayResource, err)
+	}
+	data, err := json.Marshal(map[string]interface{}{"items": items})
+	if err != nil {
+		return nil, err
+	}
+	var gateways kubeutil.GatewayList
+	if err := json.Unmarshal(data, &gateways); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", kubeutil.GatewayResource, err)
+	}
+	return gateways.Items, nil
+}
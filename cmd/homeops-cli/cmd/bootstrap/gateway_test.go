@@ -0,0 +1,98 @@
+package bootstrap
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homeops-cli/internal/common"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const (
+	gatewayTestUnprogrammed = `{"items":[{"metadata":{"namespace":"network","name":"external"},"status":{"conditions":[{"type":"Programmed","status":"False","reason":"AddressNotAssigned"}]}}]}`
+	gatewayTestServing      = `{"items":[{"metadata":{"namespace":"network","name":"external"},"status":{"addresses":[{"value":"192.168.122.201"}],"conditions":[{"type":"Programmed","status":"True"}]}}]}`
+)
+
+// stubGatewayWait freezes the clock, makes sleeps free, and answers each
+// Gateway list from responses in turn (the last one repeats).
+func stubGatewayWait(t *testing.T, responses ...string) *int {
+	t.Helper()
+	oldNow, oldSleep := bootstrapNow, bootstrapSleep
+	oldInterval, oldMaxWait := bootstrapCheckIntervalNormal, bootstrapGatewayMaxWait
+	t.Cleanup(func() {
+		bootstrapNow, bootstrapSleep = oldNow, oldSleep
+		bootstrapCheckIntervalNormal, bootstrapGatewayMaxWait = oldInterval, oldMaxWait
+	})
+	frozen := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	bootstrapNow = func() time.Time { return frozen }
+	bootstrapSleep = func(time.Duration) {}
+	bootstrapCheckIntervalNormal = time.Second
+	bootstrapGatewayMaxWait = 5 * time.Second
+
+	calls := 0
+	cluster := stubBootstrapKube(t)
+	cluster.DynamicClient.PrependReactor("list", "gateways", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		require.Empty(t, action.GetNamespace(), "Gateways are listed across namespaces")
+		response := responses[min(calls, len(responses)-1)]
+		calls++
+		if response == "" {
+			return true, nil, errors.New("the server could not find the requested resource")
+		}
+		list := &unstructured.UnstructuredList{}
+		require.NoError(t, list.UnmarshalJSON([]byte(`{"apiVersion":"gateway.networking.k8s.io/v1","kind":"GatewayList",`+response[1:])))
+		return true, list, nil
+	})
+	return &calls
+}
+
+func TestWaitForGatewaysProgrammed(t *testing.T) {
+	calls := stubGatewayWait(t, `{"items":[]}`)
+	require.NoError(t, waitForGatewaysProgrammed(&BootstrapConfig{}, common.NewColorLogger()), "no Gateways passes")
+	assert.Equal(t, 1, *calls)
+
+	calls = stubGatewayWait(t, gatewayTestUnprogrammed, gatewayTestUnprogrammed, gatewayTestServing)
+	require.NoError(t, waitForGatewaysProgrammed(&BootstrapConfig{}, common.NewColorLogger()))
+	assert.Equal(t, 3, *calls)
+
+	calls = stubGatewayWait(t, "")
+	assert.NoError(t, waitForGatewaysProgrammed(&BootstrapConfig{DryRun: true}, common.NewColorLogger()))
+	assert.Equal(t, 0, *calls, "a dry run does not read the cluster")
+}
+
+func TestWaitForGatewaysProgrammedIsBoundedWithAFrozenClock(t *testing.T) {
+	calls := stubGatewayWait(t, gatewayTestUnprogrammed)
+	err := waitForGatewaysProgrammed(&BootstrapConfig{}, common.NewColorLogger())
+	require.ErrorContains(t, err, "after 6 attempt(s)")
+	require.ErrorContains(t, err, "network/external (Programmed=False (AddressNotAssigned), no address)")
+	assert.Equal(t, 6, *calls, "a 5s wait at 1s intervals allows six checks")
+
+	stubGatewayWait(t, "")
+	require.ErrorContains(t, waitForGatewaysProgrammed(&BootstrapConfig{}, common.NewColorLogger()), "the server could not find the requested resource")
+}
+
+func TestBootstrapGatewayStepIsFatalAndSkippable(t *testing.T) {
+	oldWait, oldSpinner := bootstrapWaitGateways, bootstrapRunWithSpinner
+	t.Cleanup(func() { bootstrapWaitGateways, bootstrapRunWithSpinner = oldWait, oldSpinner })
+	bootstrapRunWithSpinner = func(_ string, _ bool, _ interface {
+		Info(string, ...interface{})
+		SetQuiet(bool)
+	}, fn func() error) error {
+		return fn()
+	}
+	ran := 0
+	bootstrapWaitGateways = func(*BootstrapConfig, *common.ColorLogger) error {
+		ran++
+		return errors.New("no Gateway is Programmed with an address")
+	}
+
+	err := waitForBootstrapGatewaysStep(&BootstrapConfig{}, common.NewColorLogger())
+	require.ErrorContains(t, err, "gateway check failed (skip with --skip-gateway-check)")
+	require.NoError(t, waitForBootstrapGatewaysStep(&BootstrapConfig{SkipGatewayCheck: true}, common.NewColorLogger()))
+	require.NoError(t, waitForBootstrapGatewaysStep(&BootstrapConfig{SkipHelmfile: true}, common.NewColorLogger()))
+	assert.Equal(t, 1, ran)
+}
@@ -40,6 +40,7 @@ func syncHelmReleases(config *BootstrapConfig, logger *common.ColorLogger) error
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
 		if attempt > 1 {
 			logger.Info("Helm sync attempt %d/%d", attempt, maxAttempts)
+			config.report.retried()
 		}
 
 		err := bootstrapExecuteHelmfileSync(config, logger)
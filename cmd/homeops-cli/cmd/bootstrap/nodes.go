@@ -1,11 +1,12 @@
 package bootstrap
 
 import (
+	"context"
 	"fmt"
-	"strings"
 	"time"
 
 	"homeops-cli/internal/common"
+	"homeops-cli/internal/kube"
 )
 
 func waitForNodes(config *BootstrapConfig, logger *common.ColorLogger) error {
@@ -39,33 +40,22 @@ func waitForNodes(config *BootstrapConfig, logger *common.ColorLogger) error {
 
 // checkIfNodesReady checks if nodes are already in Ready=True state
 func checkIfNodesReady(config *BootstrapConfig, logger *common.ColorLogger) (bool, error) {
-	output, err := bootstrapKubectlOutput(config, "get", "nodes",
-		"--output=jsonpath={range .items[*]}{.metadata.name}:{.status.conditions[?(@.type=='Ready')].status}{\"\\n\"}{end}")
+	nodes, err := listNodes(config)
 	if err != nil {
 		return false, fmt.Errorf("failed to check node ready status: %w", err)
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
 	allReady := true
 	readyCount := 0
-	totalNodes := 0
-
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
-		parts := strings.Split(line, ":")
-		if len(parts) == 2 {
-			totalNodes++
-			nodeName := parts[0]
-			readyStatus := parts[1]
-			if readyStatus == "True" {
-				readyCount++
-				logger.Debug("Node %s is Ready=True", nodeName)
-			} else {
-				logger.Debug("Node %s is Ready=%s", nodeName, readyStatus)
-				allReady = false
-			}
+	totalNodes := len(nodes)
+
+	for _, node := range nodes {
+		if node.Ready == "True" {
+			readyCount++
+			logger.Debug("Node %s is Ready=True", node.Name)
+		} else {
+			logger.Debug("Node %s is Ready=%s", node.Name, node.Ready)
+			allReady = false
 		}
 	}
 
@@ -78,6 +68,15 @@ func checkIfNodesReady(config *BootstrapConfig, logger *common.ColorLogger) (boo
 	return false, nil
 }
 
+// listNodes reads every node's Ready condition.
+func listNodes(config *BootstrapConfig) ([]kube.NodeReadiness, error) {
+	client, err := bootstrapKubeClient(config)
+	if err != nil {
+		return nil, err
+	}
+	return client.Nodes(context.Background())
+}
+
 func waitForNodesAvailable(config *BootstrapConfig, logger *common.ColorLogger) error {
 	checkInterval := bootstrapCheckIntervalSlow
 	stallTimeout := bootstrapStallTimeout
@@ -85,8 +84,12 @@ func waitForNodesAvailable(config *BootstrapConfig, logger *common.ColorLogger)
 
 	startTime := bootstrapNow()
 	lastProgressTime := bootstrapNow()
+	nextProgress := startTime.Add(60 * time.Second)
 	lastNodeCount := 0
 
+	watcher := watchBootstrapResources(config, logger, "", kube.NodeResource)
+	defer watcher.stop()
+
 	for {
 		elapsed := bootstrapNow().Sub(startTime)
 
@@ -94,19 +97,21 @@ func waitForNodesAvailable(config *BootstrapConfig, logger *common.ColorLogger)
 			return fmt.Errorf("nodes not available after %v (max wait exceeded)", elapsed.Round(time.Second))
 		}
 
-		output, err := bootstrapKubectlOutput(config, "get", "nodes",
-			"--output=jsonpath={.items[*].metadata.name}", "--no-headers")
+		nodes, err := listNodes(config)
 		if err != nil {
 			stallDuration := bootstrapNow().Sub(lastProgressTime)
 			if stallDuration > stallTimeout {
-				return fmt.Errorf("node discovery stalled: kubectl get nodes failed for %v: %w",
+				return fmt.Errorf("node discovery stalled: listing nodes failed for %v: %w",
 					stallDuration.Round(time.Second), err)
 			}
-			bootstrapSleep(checkInterval)
+			watcher.wait(checkInterval)
 			continue
 		}
 
-		nodeNames := strings.Fields(strings.TrimSpace(string(output)))
+		nodeNames := make([]string, 0, len(nodes))
+		for _, node := range nodes {
+			nodeNames = append(nodeNames, node.Name)
+		}
 		nodeCount := len(nodeNames)
 
 		if nodeCount > 0 {
@@ -126,11 +131,11 @@ func waitForNodesAvailable(config *BootstrapConfig, logger *common.ColorLogger)
 			return fmt.Errorf("node discovery stalled: no progress for %v", stallDuration.Round(time.Second))
 		}
 
-		if int(elapsed.Seconds())%60 == 0 && elapsed.Seconds() > 0 {
+		if progressDue(&nextProgress, 60*time.Second) {
 			logger.Info("Waiting for nodes to appear: %v elapsed", elapsed.Round(time.Second))
 		}
 
-		bootstrapSleep(checkInterval)
+		watcher.wait(checkInterval)
 	}
 }
 
@@ -141,8 +146,12 @@ func waitForNodesReadyFalse(config *BootstrapConfig, logger *common.ColorLogger)
 
 	startTime := bootstrapNow()
 	lastProgressTime := bootstrapNow()
+	nextProgress := startTime.Add(60 * time.Second)
 	lastReadyFalseCount := 0
 
+	watcher := watchBootstrapResources(config, logger, "", kube.NodeResource)
+	defer watcher.stop()
+
 	for {
 		elapsed := bootstrapNow().Sub(startTime)
 
@@ -150,36 +159,26 @@ func waitForNodesReadyFalse(config *BootstrapConfig, logger *common.ColorLogger)
 			return fmt.Errorf("nodes did not reach Ready=False state after %v (max wait exceeded)", elapsed.Round(time.Second))
 		}
 
-		output, err := bootstrapKubectlOutput(config, "get", "nodes",
-			"--output=jsonpath={range .items[*]}{.metadata.name}:{.status.conditions[?(@.type==\"Ready\")].status}{\"\\n\"}{end}")
+		nodes, err := listNodes(config)
 		if err != nil {
 			stallDuration := bootstrapNow().Sub(lastProgressTime)
 			if stallDuration > stallTimeout {
-				return fmt.Errorf("node readiness stalled: kubectl get nodes failed for %v: %w",
+				return fmt.Errorf("node readiness stalled: listing nodes failed for %v: %w",
 					stallDuration.Round(time.Second), err)
 			}
-			bootstrapSleep(checkInterval)
+			watcher.wait(checkInterval)
 			continue
 		}
 
-		lines := strings.Split(strings.TrimSpace(string(output)), "\n")
 		allReadyFalse := true
 		readyFalseCount := 0
-		totalNodes := 0
+		totalNodes := len(nodes)
 
-		for _, line := range lines {
-			if line == "" {
-				continue
-			}
-			parts := strings.Split(line, ":")
-			if len(parts) == 2 {
-				totalNodes++
-				readyStatus := parts[1]
-				if readyStatus == "False" {
-					readyFalseCount++
-				} else {
-					allReadyFalse = false
-				}
+		for _, node := range nodes {
+			if node.Ready == "False" {
+				readyFalseCount++
+			} else {
+				allReadyFalse = false
 			}
 		}
 
@@ -203,10 +202,10 @@ func waitForNodesReadyFalse(config *BootstrapConfig, logger *common.ColorLogger)
 				stallDuration.Round(time.Second), readyFalseCount, totalNodes)
 		}
 
-		if int(elapsed.Seconds())%60 == 0 && elapsed.Seconds() > 0 {
+		if progressDue(&nextProgress, 60*time.Second) {
 			logger.Info("Waiting for nodes: %d/%d Ready=False, %v elapsed", readyFalseCount, totalNodes, elapsed.Round(time.Second))
 		}
 
-		bootstrapSleep(checkInterval)
+		watcher.wait(checkInterval)
 	}
 }
@@ -159,7 +159,8 @@ func buildBootstrapPlan(options BootstrapConfig) (bootstrapPlan, error) {
 		plan.Nodes = append(plan.Nodes, bootstrapPlanNode{Order: index + 1, Name: node.Name, IP: node.IP, Role: role})
 		plan.VMs = append(plan.VMs, buildBootstrapPlanVM(cfg, node, provider))
 	}
-	plan.Preflight = buildBootstrapPlanPreflight(provider, options.SkipPreflight, cfg.Cluster.Nodes)
+	hypervisor, _ := hypervisorPreflightProvider(&options, cfg)
+	plan.Preflight = buildBootstrapPlanPreflight(provider, options.SkipPreflight, cfg.Cluster.Nodes, hypervisor)
 	plan.Artifacts = buildBootstrapPlanArtifacts(provider, options, cfg.Cluster.Nodes)
 	plan.Secrets = buildBootstrapPlanSecrets(cfg, provider, options)
 	plan.JoinSequence = buildBootstrapJoinSequence(provider, options.SkipKubeadm, cfg.Cluster.Nodes)
@@ -167,28 +168,42 @@ func buildBootstrapPlan(options BootstrapConfig) (bootstrapPlan, error) {
 	return plan, nil
 }
 
-func buildBootstrapPlanPreflight(provider string, skipped bool, nodes []versionconfig.Node) []bootstrapPlanCheck {
+func buildBootstrapPlanPreflight(provider string, skipped bool, nodes []versionconfig.Node, hypervisor string) []bootstrapPlanCheck {
 	status := "RUN"
 	if skipped {
 		status = "SKIP (--skip-preflight)"
 	}
-	var descriptions []struct{ name, effect string }
+	type planCheck struct{ name, effect string }
+	var descriptions []planCheck
+	hypervisorCheck := planCheck{
+		"Hypervisor VMs", fmt.Sprintf("List %s VMs; require each configured node's VM to be running", hypervisor),
+	}
 	if provider == "flatcar" {
-		descriptions = []struct{ name, effect string }{
-			{"Tool availability", "Require kubectl, helmfile, and op"},
+		descriptions = []planCheck{
+			{"Tool availability", "Require helmfile and op"},
 			{"1Password authentication", "Authenticate only when the operational bootstrap runs"},
-			{"Flatcar node readiness", fmt.Sprintf("SSH to %d configured node(s); require Flatcar and kubelet", len(nodes))},
 		}
+		if hypervisor != "" {
+			descriptions = append(descriptions, hypervisorCheck)
+		}
+		descriptions = append(descriptions, planCheck{
+			"Flatcar node readiness", fmt.Sprintf("SSH to %d configured node(s); require Flatcar and kubelet", len(nodes)),
+		})
 	} else {
-		descriptions = []struct{ name, effect string }{
-			{"Tool Availability", "Require talosctl, kubectl, kustomize, op, and helmfile"},
+		descriptions = []planCheck{
+			{"Tool Availability", "Require talosctl, kustomize, op, and helmfile"},
 			{"Environment Files", "Validate version inputs and talosconfig path"},
 			{"Network Connectivity", "HEAD github.com for CRD downloads"},
 			{"DNS Resolution", "Resolve github.com"},
 			{"1Password Authentication", "Authenticate when op:// references are configured"},
-			{"Machine Config Rendering", "Render the first Talos machine configuration"},
-			{"Talos Nodes", "Read and validate configured Talos node endpoints"},
+			{"Machine Config Rendering", "Render every Talos node's machine configuration"},
+		}
+		if hypervisor != "" {
+			descriptions = append(descriptions, hypervisorCheck)
 		}
+		descriptions = append(descriptions, planCheck{
+			"Talos Nodes", "Read and validate configured Talos node endpoints",
+		})
 	}
 	checks := make([]bootstrapPlanCheck, 0, len(descriptions))
 	for index, description := range descriptions {
@@ -311,6 +326,7 @@ func buildBootstrapPlanArtifacts(provider string, options BootstrapConfig, nodes
 		}
 	}
 	add("bootstrap namespaces", "apply", strings.Join(initialBootstrapNamespaces(), ", "))
+	add("cluster-settings.yaml", "apply", "ConfigMap flux-system/cluster-settings for Flux postBuild substitution")
 	if !options.SkipResources {
 		add("bootstrap/resources.yaml", "apply", "initial Secret resources")
 	}
@@ -444,6 +460,7 @@ func buildBootstrapPlanSteps(provider string, options BootstrapConfig, nodes []v
 	}
 	add("Wait for nodes", "Require the configured nodes to appear and become ready", "RUN")
 	add("Create namespaces", "Apply all bootstrap namespaces", "RUN")
+	add("Apply cluster settings", "Server-side apply ConfigMap flux-system/cluster-settings from cluster-settings.yaml", "RUN")
 	conditionalPlanStep(&steps, "Apply initial resources", "Resolve listed resource secrets and server-side apply bootstrap/resources.yaml", options.SkipResources, "--skip-resources")
 	conditionalPlanStep(&steps, "Apply CRDs", "Template CRD helmfile, apply Gateway API CRDs, wait for establishment", options.SkipCRDs, "--skip-crds")
 	conditionalPlanStep(&steps, "Sync Helm releases", "Sync bootstrap/helmfile.d/01-apps.yaml", options.SkipHelmfile, "--skip-helmfile")
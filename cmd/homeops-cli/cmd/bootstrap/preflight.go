@@ -3,19 +3,24 @@ package bootstrap
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"homeops-cli/internal/common"
 	versionconfig "homeops-cli/internal/config"
+	vmprov "homeops-cli/internal/provider"
+	"homeops-cli/internal/secrets"
+	"homeops-cli/internal/vmlifecycle"
 )
 
 func validatePrerequisites(config *BootstrapConfig) error {
 	// Check for required binaries
-	requiredBins := []string{"talosctl", "kubectl", "kustomize", "op", "helmfile"}
+	requiredBins := withSecretBackendTool("talosctl", "kustomize", "helmfile")
 	for _, bin := range requiredBins {
 		if _, err := bootstrapLookPath(bin); err != nil {
 			return fmt.Errorf("required binary '%s' not found in PATH", bin)
@@ -41,6 +46,15 @@ func validatePrerequisites(config *BootstrapConfig) error {
 	return nil
 }
 
+// withSecretBackendTool appends the CLI the op:// secret backend runs, if it
+// runs one, to tools.
+func withSecretBackendTool(tools ...string) []string {
+	if bin := secrets.BackendBinary(secrets.CurrentBackendName()); bin != "" {
+		tools = append(tools, bin)
+	}
+	return tools
+}
+
 func runPreflightChecks(config *BootstrapConfig, logger *common.ColorLogger) error {
 	// Independent checks run concurrently (network/DNS timeouts no longer
 	// add up); serial checks run in declaration order afterwards because they
@@ -67,6 +81,7 @@ func runPreflightChecks(config *BootstrapConfig, logger *common.ColorLogger) err
 
 	var failures []string
 	for _, result := range results {
+		config.report.addPreflight(result)
 		switch result.Status {
 		case "PASS":
 			logger.Success("✓ %s: %s", result.Name, result.Message)
@@ -85,7 +100,7 @@ func runPreflightChecks(config *BootstrapConfig, logger *common.ColorLogger) err
 }
 
 func checkToolAvailability(config *BootstrapConfig, logger *common.ColorLogger) *PreflightResult {
-	requiredBins := []string{"talosctl", "kubectl", "kustomize", "op", "helmfile"}
+	requiredBins := withSecretBackendTool("talosctl", "kustomize", "helmfile")
 	var missing []string
 
 	for _, bin := range requiredBins {
@@ -195,10 +210,14 @@ func checkDNSResolution(config *BootstrapConfig, logger *common.ColorLogger) *Pr
 
 func check1PasswordAuthPreflight(config *BootstrapConfig, logger *common.ColorLogger) *PreflightResult {
 	if !versionconfig.Get().UsesOpReferences() {
+		message := "Skipped — no op:// references in the homeops config"
+		if backend := secrets.CurrentBackendName(); backend != secrets.BackendOnePassword {
+			message = fmt.Sprintf("Skipped — op:// references resolve through the %s secret backend", backend)
+		}
 		return &PreflightResult{
 			Name:    "1Password Authentication",
 			Status:  "PASS",
-			Message: "Skipped — no op:// references in the homeops config",
+			Message: message,
 		}
 	}
 	if err := bootstrapEnsureOPAuth(); err != nil {
@@ -211,37 +230,67 @@ func check1PasswordAuthPreflight(config *BootstrapConfig, logger *common.ColorLo
 	return &PreflightResult{
 		Name:    "1Password Authentication",
 		Status:  "PASS",
-		Message: "1Password CLI authenticated",
+		Message: fmt.Sprintf("1Password authenticated (%s)", secrets.CurrentOpAuthMode()),
 	}
 }
 
+// checkMachineConfigRendering renders every Talos node's machine config,
+// resolving its 1Password references, so a node whose template is missing
+// or broken fails preflight by name instead of partway through apply-config.
 func checkMachineConfigRendering(config *BootstrapConfig, logger *common.ColorLogger) *PreflightResult {
-	// Test rendering of machine configurations using the first configured
-	// node's patch template.
-	nodes := versionconfig.Get().Cluster.Nodes
+	nodes, err := bootstrapGetTalosNodes(config.TalosConfig)
+	if err != nil {
+		// checkTalosNodes reports why; render the configured nodes instead.
+		nodes = nil
+		for _, node := range versionconfig.Get().Cluster.Nodes {
+			nodes = append(nodes, node.IP)
+		}
+	}
 	if len(nodes) == 0 {
 		return &PreflightResult{
 			Name:    "Machine Config Rendering",
 			Status:  "FAIL",
-			Message: "no cluster nodes configured (cluster.nodes in homeops.yaml)",
+			Message: "no Talos nodes in the talosconfig or cluster.nodes in homeops.yaml",
 		}
 	}
-	patchTemplate := fmt.Sprintf("nodes/%s.yaml", nodes[0].IP)
 
-	_, err := bootstrapRenderMachineConfig("controlplane.yaml", patchTemplate, "controlplane", logger)
-	if err != nil {
+	talosSecrets.begin()
+	defer talosSecrets.end()
+	var broken []string
+	for _, node := range nodes {
+		if err := renderTalosNodePreflight(node, logger); err != nil {
+			broken = append(broken, fmt.Sprintf("%s (%v)", node, err))
+		}
+	}
+	if len(broken) > 0 {
 		return &PreflightResult{
 			Name:    "Machine Config Rendering",
 			Status:  "FAIL",
-			Message: fmt.Sprintf("Failed to render machine config: %v", err),
+			Message: fmt.Sprintf("Machine configs failed to render for %d of %d nodes: %s", len(broken), len(nodes), strings.Join(broken, "; ")),
 		}
 	}
 
 	return &PreflightResult{
 		Name:    "Machine Config Rendering",
 		Status:  "PASS",
-		Message: "Machine configurations render successfully",
+		Message: fmt.Sprintf("Machine configurations render for all %d nodes", len(nodes)),
+	}
+}
+
+// renderTalosNodePreflight renders node's machine config the way
+// applyTalosNodeConfig does, without applying it.
+func renderTalosNodePreflight(node string, logger *common.ColorLogger) error {
+	nodeTemplate := fmt.Sprintf("nodes/%s.yaml", node)
+	machineType, err := bootstrapGetMachineType(nodeTemplate)
+	if err != nil {
+		return err
+	}
+	baseTemplate, err := talosBaseTemplate(machineType)
+	if err != nil {
+		return err
 	}
+	_, err = bootstrapRenderMachineConfig(baseTemplate, nodeTemplate, machineType, logger)
+	return err
 }
 
 func checkTalosNodes(config *BootstrapConfig, logger *common.ColorLogger) *PreflightResult {
@@ -270,4 +319,152 @@ func checkTalosNodes(config *BootstrapConfig, logger *common.ColorLogger) *Prefl
 	}
 }
 
+// checkNodeAddressFamilies probes the Talos API on every configured node
+// address, so a dual-stack cluster fails preflight when either family is
+// unreachable rather than after the configs are applied.
+func checkNodeAddressFamilies(config *BootstrapConfig, logger *common.ColorLogger) *PreflightResult {
+	if !versionconfig.Get().Cluster.DualStack {
+		return &PreflightResult{
+			Name:    "Node Address Families",
+			Status:  "PASS",
+			Message: "single-stack (cluster.dual_stack off)",
+		}
+	}
+	unreachable := probeNodeAddresses(50000)
+	if len(unreachable) > 0 {
+		return &PreflightResult{
+			Name:    "Node Address Families",
+			Status:  "FAIL",
+			Message: "Unreachable node addresses: " + strings.Join(unreachable, "; "),
+		}
+	}
+	return &PreflightResult{
+		Name:    "Node Address Families",
+		Status:  "PASS",
+		Message: "IPv4 and IPv6 node addresses reachable",
+	}
+}
+
+// probeNodeAddresses TCP-dials port on each address of every configured
+// node and describes the ones that did not answer.
+func probeNodeAddresses(port int) []string {
+	cfg := versionconfig.Get()
+	var unreachable []string
+	for _, node := range cfg.Cluster.Nodes {
+		for _, address := range cfg.NodeAddresses(node) {
+			conn, err := bootstrapDialTimeout("tcp", net.JoinHostPort(address, strconv.Itoa(port)), 5*time.Second)
+			if err != nil {
+				unreachable = append(unreachable, fmt.Sprintf("%s %s: %v", node.Name, address, err))
+				continue
+			}
+			_ = conn.Close()
+		}
+	}
+	return unreachable
+}
+
 // Removed local check1PasswordAuth in favor of common.Ensure1PasswordAuth
+
+// hypervisorPreflightProvider is the hypervisor whose VMs back the cluster
+// nodes, or "" when the check is off. --check-hypervisor turns it on for
+// hypervisors.default; without it the check runs when that is TrueNAS or
+// vSphere.
+func hypervisorPreflightProvider(config *BootstrapConfig, cfg *versionconfig.Config) (string, error) {
+	provider, err := vmlifecycle.NormalizeVMProvider(cfg.Hypervisors.Default)
+	if config.CheckHypervisor {
+		return provider, err
+	}
+	if err != nil || (provider != "truenas" && provider != "vsphere") {
+		return "", nil
+	}
+	return provider, nil
+}
+
+// hypervisorCredentials reports whether provider's credentials resolve.
+func hypervisorCredentials(provider string) error {
+	var err error
+	switch provider {
+	case "truenas":
+		_, _, err = vmlifecycle.GetTrueNASCredentialsFn()
+	case "vsphere":
+		_, _, _, err = vmlifecycle.GetVSphereCredsFn()
+	case "proxmox":
+		_, _, _, _, err = vmlifecycle.GetProxmoxCredentialsFn()
+	}
+	return err
+}
+
+// listHypervisorVMs reads provider's VM inventory.
+func listHypervisorVMs(ctx context.Context, provider string) ([]vmprov.VMSummary, error) {
+	var summaries []vmprov.VMSummary
+	err := vmlifecycle.WithVMLifecycleContext(ctx, provider, func(lifecycle vmprov.VMLifecycle) error {
+		var err error
+		summaries, err = lifecycle.VMSummaries()
+		return err
+	})
+	return summaries, err
+}
+
+// hypervisorVMKey matches a node to its VM ignoring '-' and '_', since
+// TrueNAS VM names cannot contain dashes (k8s-0 runs as k8s0).
+func hypervisorVMKey(name string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(name))
+}
+
+// checkHypervisorVMs checks that the VM of every configured cluster node
+// exists and is running, so a powered-off VM fails preflight by name rather
+// than a later step timing out. Missing credentials only warn.
+func checkHypervisorVMs(config *BootstrapConfig, logger *common.ColorLogger) *PreflightResult {
+	const name = "Hypervisor VMs"
+	provider, err := hypervisorPreflightProvider(config, versionconfig.Get())
+	if err != nil {
+		return &PreflightResult{Name: name, Status: "FAIL", Message: err.Error()}
+	}
+	if provider == "" {
+		return &PreflightResult{Name: name, Status: "PASS", Message: "not checked (hypervisors.default is not truenas or vsphere; --check-hypervisor enables it)"}
+	}
+	nodes := versionconfig.Get().Cluster.Nodes
+	if len(nodes) == 0 {
+		return &PreflightResult{Name: name, Status: "WARN", Message: "No cluster nodes configured to look up on " + provider}
+	}
+	if err := hypervisorCredentials(provider); err != nil {
+		return &PreflightResult{Name: name, Status: "WARN", Message: fmt.Sprintf("VMs not checked: %v", err)}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	summaries, err := bootstrapHypervisorVMs(ctx, provider)
+	if err != nil {
+		return &PreflightResult{Name: name, Status: "FAIL", Message: fmt.Sprintf("Cannot list VMs on %s: %v", provider, err), Error: err}
+	}
+	vms := make(map[string]vmprov.VMSummary, len(summaries))
+	for _, vm := range summaries {
+		vms[hypervisorVMKey(vm.Name)] = vm
+	}
+	var problems []string
+	for _, node := range nodes {
+		vm, ok := vms[hypervisorVMKey(node.Name)]
+		switch {
+		case !ok:
+			problems = append(problems, node.Name+" (no VM)")
+		case !hypervisorVMRunning(vm.Status):
+			problems = append(problems, fmt.Sprintf("%s (%s)", vm.Name, vm.Status))
+		default:
+			logger.Debug("VM %s of node %s is %s", vm.Name, node.Name, vm.Status)
+		}
+	}
+	if len(problems) > 0 {
+		return &PreflightResult{Name: name, Status: "FAIL", Message: fmt.Sprintf("VMs down on %s: %s", provider, strings.Join(problems, ", "))}
+	}
+	return &PreflightResult{Name: name, Status: "PASS", Message: fmt.Sprintf("All %d node VMs are running on %s", len(nodes), provider)}
+}
+
+// hypervisorVMRunning reads a VMSummary status: RUNNING on TrueNAS,
+// poweredOn on vSphere, running on Proxmox.
+func hypervisorVMRunning(status string) bool {
+	switch strings.ToLower(status) {
+	case "running", "poweredon":
+		return true
+	}
+	return false
+}
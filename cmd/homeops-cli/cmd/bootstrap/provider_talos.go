@@ -8,9 +8,11 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
 	"homeops-cli/internal/common"
+	versionconfig "homeops-cli/internal/config"
 	"homeops-cli/internal/secrets"
 	"homeops-cli/internal/templates"
 )
@@ -22,81 +24,152 @@ func applyTalosConfig(config *BootstrapConfig, logger *common.ColorLogger) error
 		return err
 	}
 
-	logger.Info("Found %d Talos nodes to configure", len(nodes))
-
-	// Apply configuration to each node
-	var failures []string
-	for _, node := range nodes {
-		nodeTemplate := fmt.Sprintf("nodes/%s.yaml", node)
-
-		// Get machine type from embedded node template - do this outside spinner for better error messages
-		machineType, err := bootstrapGetMachineType(nodeTemplate)
-		if err != nil {
-			logger.Error("Failed to determine machine type for %s: %v", node, err)
-			failures = append(failures, node)
-			continue
+	names := make([]string, len(nodes))
+	for i, node := range nodes {
+		names[i] = node
+		if configured, ok := versionconfig.Get().NodeByIP(node); ok && configured.Name != "" {
+			names[i] = configured.Name
 		}
+	}
+	config.report.setNodes(names)
+	parallel := max(config.MaxParallel, 1)
+	logger.Info("Found %d Talos nodes to configure (max parallel: %d)", len(nodes), parallel)
+
+	talosSecrets.begin()
+	defer talosSecrets.end()
+
+	// Apply configuration to each node, parallel at a time. Concurrent
+	// spinners would overwrite each other, so they only run one at a time;
+	// otherwise each node reports its own status lines.
+	spinners := parallel == 1 || len(nodes) == 1
+	errs := make([]error, len(nodes))
+	semaphore := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			errs[i] = applyTalosNodeConfig(config, node, spinners, logger)
+		}()
+	}
+	wg.Wait()
 
-		// Determine base template
-		var baseTemplate string
-		switch machineType {
-		case "controlplane":
-			baseTemplate = "controlplane.yaml"
-		case "worker":
-			baseTemplate = "worker.yaml"
-		default:
-			logger.Error("Unknown machine type for %s: %s", node, machineType)
+	var failures []string
+	for i, node := range nodes {
+		if errs[i] != nil {
 			failures = append(failures, node)
-			continue
 		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to configure nodes: %s", strings.Join(failures, ", "))
+	}
 
-		// Apply config with spinner showing the node being configured
-		spinnerTitle := fmt.Sprintf("  Applying config to %s (%s)", node, machineType)
-		err = bootstrapRunWithSpinner(spinnerTitle, config.Verbose, logger, func() error {
-			// Render machine config using embedded templates
-			renderedConfig, err := bootstrapRenderMachineConfig(baseTemplate, nodeTemplate, machineType, logger)
-			if err != nil {
-				return fmt.Errorf("failed to render config: %w", err)
-			}
+	return nil
+}
 
-			if config.DryRun {
-				// For dry-run, just simulate a brief delay so spinner is visible
-				bootstrapSleep(500 * time.Millisecond)
-				return nil
-			}
+// applyTalosNodeConfig renders and applies one node's machine config,
+// logging the outcome; the returned error has already been logged.
+func applyTalosNodeConfig(config *BootstrapConfig, node string, spinner bool, logger *common.ColorLogger) error {
+	nodeTemplate := fmt.Sprintf("nodes/%s.yaml", node)
 
-			// Apply the config with retry
-			if err := bootstrapApplyNodeConfigTry(node, renderedConfig, logger, 3); err != nil {
-				// Check if node is already configured
-				if strings.Contains(err.Error(), "certificate required") || strings.Contains(err.Error(), "already configured") {
-					return nil // Silent skip for already configured nodes
-				}
-				return fmt.Errorf("failed to apply config after retries: %w", err)
-			}
+	// Get machine type from embedded node template - do this outside spinner for better error messages
+	machineType, err := bootstrapGetMachineType(nodeTemplate)
+	if err != nil {
+		logger.Error("Failed to determine machine type for %s: %v", node, err)
+		return err
+	}
 
-			return nil
-		})
+	baseTemplate, err := talosBaseTemplate(machineType)
+	if err != nil {
+		logger.Error("Unknown machine type for %s: %s", node, machineType)
+		return err
+	}
 
+	apply := func() error {
+		// Render machine config using embedded templates
+		renderedConfig, err := bootstrapRenderMachineConfig(baseTemplate, nodeTemplate, machineType, logger)
 		if err != nil {
-			logger.Error("Failed to configure %s: %v", node, err)
-			failures = append(failures, node)
-			continue
+			return fmt.Errorf("failed to render config: %w", err)
 		}
 
 		if config.DryRun {
-			logger.Info("[DRY RUN] Would apply config to %s (type: %s)", node, machineType)
-		} else {
-			logger.Success("Successfully applied configuration to %s", node)
+			// For dry-run, just simulate a brief delay so spinner is visible
+			bootstrapSleep(500 * time.Millisecond)
+			return nil
+		}
+
+		// Apply the config with retry
+		if err := bootstrapApplyNodeConfigTry(node, renderedConfig, logger, 3); err != nil {
+			// Check if node is already configured
+			if strings.Contains(err.Error(), "certificate required") || strings.Contains(err.Error(), "already configured") {
+				return nil // Silent skip for already configured nodes
+			}
+			return fmt.Errorf("failed to apply config after retries: %w", err)
 		}
+
+		return nil
 	}
 
-	if len(failures) > 0 {
-		return fmt.Errorf("failed to configure nodes: %s", strings.Join(failures, ", "))
+	// Apply config with spinner showing the node being configured
+	title := fmt.Sprintf("  Applying config to %s (%s)", node, machineType)
+	if spinner {
+		err = bootstrapRunWithSpinner(title, config.Verbose, logger, apply)
+	} else {
+		logger.Info("%s", title)
+		err = apply()
+	}
+	if err != nil {
+		logger.Error("Failed to configure %s: %v", node, err)
+		return err
 	}
 
+	if config.DryRun {
+		logger.Info("[DRY RUN] Would apply config to %s (type: %s)", node, machineType)
+	} else {
+		logger.Success("Successfully applied configuration to %s", node)
+	}
 	return nil
 }
 
+// talosSecrets resolves the 1Password references of the machine configs
+// one at a time, each distinct document once per applyTalosConfig: every
+// node shares the base template, and concurrent op reads would each prompt
+// for or race the same session.
+var talosSecrets talosSecretCache
+
+type talosSecretCache struct {
+	mu       sync.Mutex
+	resolved map[string]string
+}
+
+func (c *talosSecretCache) begin() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resolved = map[string]string{}
+}
+
+// end drops the resolved secrets.
+func (c *talosSecretCache) end() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resolved = nil
+}
+
+func (c *talosSecretCache) resolve(content string, logger *common.ColorLogger) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if resolved, ok := c.resolved[content]; ok {
+		return resolved, nil
+	}
+	resolved, err := bootstrapResolveSecrets(content, logger)
+	if err == nil && c.resolved != nil {
+		c.resolved[content] = resolved
+	}
+	return resolved, err
+}
+
 func getTalosNodes(talosConfig string) ([]string, error) {
 	output, err := bootstrapTalosctlOutput(talosConfig, "config", "info", "--output", "json")
 	if err != nil {
@@ -114,7 +187,21 @@ func getTalosNodes(talosConfig string) ([]string, error) {
 		return nil, fmt.Errorf("no nodes found in Talos configuration")
 	}
 
-	return configInfo.Nodes, nil
+	// A dual-stack talosconfig may list a node under both families; apply
+	// each configured node once, through its IPv4 address.
+	cfg := versionconfig.Get()
+	seen := map[string]bool{}
+	var nodes []string
+	for _, address := range configInfo.Nodes {
+		if node, ok := cfg.NodeByIP(address); ok && node.IP != "" {
+			address = node.IP
+		}
+		if !seen[address] {
+			seen[address] = true
+			nodes = append(nodes, address)
+		}
+	}
+	return nodes, nil
 }
 
 func getTalosNodesWithRetry(talosConfig string, logger *common.ColorLogger, maxRetries int) ([]string, error) {
@@ -177,6 +264,19 @@ func applyNodeConfig(node string, config []byte) error {
 	return nil
 }
 
+// talosBaseTemplate returns the base machine config template for a
+// machine type.
+func talosBaseTemplate(machineType string) (string, error) {
+	switch machineType {
+	case "controlplane":
+		return "controlplane.yaml", nil
+	case "worker":
+		return "worker.yaml", nil
+	default:
+		return "", fmt.Errorf("unknown machine type %s", machineType)
+	}
+}
+
 func getMachineTypeFromEmbedded(nodeTemplate string) (string, error) {
 	// Get the node template content with proper talos/ prefix
 	fullTemplatePath := fmt.Sprintf("talos/%s", nodeTemplate)
@@ -246,12 +346,12 @@ func renderMachineConfigFromEmbedded(baseTemplate, patchTemplate, _ string, logg
 	// Resolve 1Password references in both configs BEFORE merging
 	// Use the logger passed in (which can be in quiet mode during spinners)
 
-	resolvedBaseConfig, err := bootstrapResolveSecrets(baseConfig, logger)
+	resolvedBaseConfig, err := talosSecrets.resolve(baseConfig, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve 1Password references in base config: %w", err)
 	}
 
-	resolvedPatchConfig, err := bootstrapResolveSecrets(machineConfigPatch, logger)
+	resolvedPatchConfig, err := talosSecrets.resolve(machineConfigPatch, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve 1Password references in patch config: %w", err)
 	}
@@ -270,7 +370,7 @@ func renderMachineConfigFromEmbedded(baseTemplate, patchTemplate, _ string, logg
 		additionalParts := strings.Join(additionalDocs, "\n---\n")
 
 		// Resolve 1Password references in additional parts too
-		resolvedAdditionalParts, err := bootstrapResolveSecrets(additionalParts, logger)
+		resolvedAdditionalParts, err := talosSecrets.resolve(additionalParts, logger)
 		if err != nil {
 			return nil, fmt.Errorf("failed to resolve 1Password references in additional config parts: %w", err)
 		}
@@ -443,6 +543,9 @@ func bootstrapTalos(config *BootstrapConfig, logger *common.ColorLogger) error {
 	var lastOutput string
 	for attempts := range maxAttempts {
 		logger.Debug("Bootstrap attempt %d/%d on controller %s", attempts+1, maxAttempts, controller)
+		if attempts > 0 {
+			config.report.retried()
+		}
 
 		output, err := bootstrapTalosctlCombined(config.TalosConfig, "--nodes", controller, "bootstrap")
 		outputStr := string(output)
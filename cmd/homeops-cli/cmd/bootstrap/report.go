@@ -0,0 +1,205 @@
+package bootstrap
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Step statuses of a --report-file report.
+const (
+	reportStepSuccess = "success"
+	reportStepSkipped = "skipped"
+	reportStepFailed  = "failed"
+)
+
+// bootstrapReport is the JSON summary --report-file writes when a bootstrap
+// ends, successful or not, so CI does not have to parse the log.
+type bootstrapReport struct {
+	Provider        string                 `json:"provider"`
+	DryRun          bool                   `json:"dry_run"`
+	Status          string                 `json:"status"`
+	Error           string                 `json:"error,omitempty"`
+	StartedAt       time.Time              `json:"started_at"`
+	DurationSeconds float64                `json:"duration_seconds"`
+	Cluster         bootstrapReportCluster `json:"cluster"`
+	Preflight       []bootstrapReportCheck `json:"preflight"`
+	Steps           []bootstrapReportStep  `json:"steps"`
+
+	mu sync.Mutex
+	// current is the index in Steps of the running step, or -1.
+	current int
+}
+
+type bootstrapReportCluster struct {
+	Nodes             []string `json:"nodes"`
+	KubernetesVersion string   `json:"kubernetes_version"`
+	TalosVersion      string   `json:"talos_version,omitempty"`
+}
+
+// bootstrapReportCheck is one PreflightResult.
+type bootstrapReportCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+type bootstrapReportStep struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// Reason says why a step was skipped: "dry run", "--resume",
+	// "--from-step", "--only", "not run" (a --skip-* flag) or "not reached".
+	Reason          string  `json:"reason,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	// Retries counts the attempts after the first of the step's retry loop.
+	Retries int    `json:"retries"`
+	Error   string `json:"error,omitempty"`
+}
+
+// newBootstrapReport starts the report of a run, or returns nil without
+// --report-file. Every method is a no-op on nil.
+func newBootstrapReport(config *BootstrapConfig) *bootstrapReport {
+	if config.ReportFile == "" {
+		return nil
+	}
+	return &bootstrapReport{
+		Provider:  bootstrapReportProvider(config),
+		DryRun:    config.DryRun,
+		StartedAt: bootstrapNow().UTC(),
+		Preflight: []bootstrapReportCheck{},
+		Steps:     []bootstrapReportStep{},
+		current:   -1,
+	}
+}
+
+func bootstrapReportProvider(config *BootstrapConfig) string {
+	if strings.EqualFold(config.Provider, "flatcar") {
+		return "flatcar"
+	}
+	return "talos"
+}
+
+// track runs step's fn and records the outcome. A step that succeeds with
+// skipReason set (a dry run) is reported skipped for that reason.
+func (r *bootstrapReport) track(step, skipReason string, fn func() error) error {
+	if r == nil {
+		return fn()
+	}
+	r.mu.Lock()
+	r.Steps = append(r.Steps, bootstrapReportStep{Name: step})
+	index := len(r.Steps) - 1
+	r.current = index
+	r.mu.Unlock()
+
+	start := bootstrapNow()
+	err := fn()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := &r.Steps[index]
+	result.DurationSeconds = bootstrapNow().Sub(start).Seconds()
+	switch {
+	case err != nil:
+		result.Status, result.Error = reportStepFailed, err.Error()
+	case skipReason != "":
+		result.Status, result.Reason = reportStepSkipped, skipReason
+	default:
+		result.Status = reportStepSuccess
+	}
+	r.current = -1
+	return err
+}
+
+// skip records step as skipped without running it.
+func (r *bootstrapReport) skip(step, reason string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Steps = append(r.Steps, bootstrapReportStep{Name: step, Status: reportStepSkipped, Reason: reason})
+}
+
+// retried counts a retry of the running step.
+func (r *bootstrapReport) retried() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.current >= 0 {
+		r.Steps[r.current].Retries++
+	}
+}
+
+func (r *bootstrapReport) addPreflight(result *PreflightResult) {
+	if r == nil || result == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Preflight = append(r.Preflight, bootstrapReportCheck{Name: result.Name, Status: result.Status, Message: result.Message})
+}
+
+func (r *bootstrapReport) setNodes(nodes []string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Cluster.Nodes = append([]string(nil), nodes...)
+}
+
+// write completes the report with the run's outcome, listing the provider's
+// steps that never started as skipped, and replaces path with it.
+func (r *bootstrapReport) write(config *BootstrapConfig, path string, runErr error) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.DurationSeconds = bootstrapNow().Sub(r.StartedAt).Seconds()
+	r.Cluster.KubernetesVersion = config.K8sVersion
+	if r.Provider == "talos" {
+		r.Cluster.TalosVersion = config.TalosVersion
+	}
+	if r.Cluster.Nodes == nil {
+		r.Cluster.Nodes = []string{}
+	}
+	r.Status, r.Error = reportStepSuccess, ""
+	unreached := "not run"
+	if runErr != nil {
+		r.Status, r.Error = reportStepFailed, runErr.Error()
+		unreached = "not reached"
+	}
+	recorded := map[string]bool{}
+	for _, step := range r.Steps {
+		recorded[step.Name] = true
+	}
+	for _, step := range bootstrapProviderSteps(config) {
+		if !recorded[step] {
+			r.Steps = append(r.Steps, bootstrapReportStep{Name: step, Status: reportStepSkipped, Reason: unreached})
+		}
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for bootstrap report: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write bootstrap report: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write bootstrap report: %w", err)
+	}
+	return nil
+}
@@ -1,12 +1,13 @@
 package bootstrap
 
 import (
-	"bytes"
+	"context"
 	"fmt"
-	"strings"
 
 	"homeops-cli/internal/common"
 	"homeops-cli/internal/constants"
+	"homeops-cli/internal/kube"
+	"homeops-cli/internal/templates"
 )
 
 // applyNamespaces creates the initial namespaces required for bootstrap
@@ -20,21 +21,20 @@ func applyNamespaces(config *BootstrapConfig, logger *common.ColorLogger) error
 	// This ensures all namespaces exist before any resources are applied
 	namespaces := initialBootstrapNamespaces()
 
+	client, err := bootstrapKubeClient(config)
+	if err != nil {
+		return err
+	}
 	for _, ns := range namespaces {
 		logger.Debug("Creating namespace: %s", ns)
 
-		manifestOutput, err := bootstrapKubectlOutput(config, "create", "namespace", ns, "--dry-run=client", "-o", "yaml")
+		created, err := client.EnsureNamespace(context.Background(), ns)
 		if err != nil {
-			return fmt.Errorf("failed to generate namespace manifest for %s: %w", ns, err)
+			return fmt.Errorf("failed to create namespace %s: %w", ns, err)
 		}
-
-		if output, err := bootstrapKubectlCombinedIn(config, bytes.NewReader(manifestOutput), "apply", "--filename", "-"); err != nil {
-			// Check if namespace already exists - that's okay
-			if strings.Contains(string(output), "AlreadyExists") || strings.Contains(string(output), "unchanged") {
-				logger.Debug("Namespace %s already exists", ns)
-				continue
-			}
-			return fmt.Errorf("failed to create namespace %s: %w\nOutput: %s", ns, err, redactCommandOutput(output))
+		if !created {
+			logger.Debug("Namespace %s already exists", ns)
+			continue
 		}
 
 		logger.Debug("Successfully created namespace: %s", ns)
@@ -43,6 +43,31 @@ func applyNamespaces(config *BootstrapConfig, logger *common.ColorLogger) error
 	return nil
 }
 
+// applyClusterSettings creates or updates the Flux cluster-settings ConfigMap
+// from cluster-settings.yaml, so cluster-apps has its postBuild substitution
+// values before Flux first reconciles.
+func applyClusterSettings(config *BootstrapConfig, logger *common.ColorLogger) error {
+	settings, err := bootstrapLoadClusterSettings()
+	if err != nil {
+		return fmt.Errorf("failed to load cluster settings: %w", err)
+	}
+	manifest, err := settings.ConfigMap(constants.NSFluxSystem)
+	if err != nil {
+		return err
+	}
+
+	if config.DryRun {
+		logger.Info("[DRY RUN] Would apply %s/%s ConfigMap (%d setting(s))", constants.NSFluxSystem, templates.ClusterSettingsConfigMapName, len(settings.Values))
+		return nil
+	}
+
+	if err := applyManifest(config, manifest, kube.ApplyOptions{Force: true}); err != nil {
+		return fmt.Errorf("failed to apply %s ConfigMap: %w", templates.ClusterSettingsConfigMapName, err)
+	}
+	logger.Debug("Applied %s/%s with %d setting(s)", constants.NSFluxSystem, templates.ClusterSettingsConfigMapName, len(settings.Values))
+	return nil
+}
+
 func applyResources(config *BootstrapConfig, logger *common.ColorLogger) error {
 	// Get resources from embedded YAML file (no longer a template)
 	resources, err := bootstrapGetBootstrapFile("resources.yaml")
@@ -67,8 +92,8 @@ func applyResources(config *BootstrapConfig, logger *common.ColorLogger) error {
 	}
 
 	// Apply resources with force-conflicts to handle cert-manager managed fields
-	if output, err := bootstrapKubectlCombinedIn(config, bytes.NewReader([]byte(resolvedResources)), "apply", "--server-side", "--force-conflicts", "--filename", "-"); err != nil {
-		return fmt.Errorf("failed to apply resources: %w\n%s", err, redactCommandOutput(output))
+	if err := applyManifest(config, resolvedResources, kube.ApplyOptions{Force: true}); err != nil {
+		return fmt.Errorf("failed to apply resources: %w", err)
 	}
 
 	logger.Info("Resources applied successfully")
@@ -99,9 +124,8 @@ func applyClusterSecretStore(config *BootstrapConfig, logger *common.ColorLogger
 	}
 
 	// Apply cluster secret store with force-conflicts to handle field management conflicts
-	if output, err := bootstrapKubectlCombinedIn(config, bytes.NewReader([]byte(resolvedClusterSecretStore)),
-		"apply", "--namespace", constants.NSExternalSecret, "--server-side", "--force-conflicts", "--filename", "-", "--wait=true"); err != nil {
-		return fmt.Errorf("failed to apply cluster secret store: %w\n%s", err, redactCommandOutput(output))
+	if err := applyManifest(config, resolvedClusterSecretStore, kube.ApplyOptions{Namespace: constants.NSExternalSecret, Force: true}); err != nil {
+		return fmt.Errorf("failed to apply cluster secret store: %w", err)
 	}
 
 	logger.Info("Cluster secret store applied successfully")
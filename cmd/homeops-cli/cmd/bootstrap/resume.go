@@ -0,0 +1,299 @@
+package bootstrap
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"homeops-cli/internal/common"
+)
+
+// flatcarBootstrapSteps and talosBootstrapSteps are the step names of each
+// flow in order, as --from-step takes them and metrics.Spans records them.
+var (
+	flatcarBootstrapSteps = []string{"preflight", "kubeadm-init", "kubeconfig", "kubeadm-join", "cilium", "nodes",
+		"namespaces", "cluster-settings", "resources", "crds", "helm-releases", "flux", "gateways"}
+	talosBootstrapSteps = []string{"preflight", "apply-config", "talos-bootstrap", "kubeconfig", "nodes",
+		"namespaces", "cluster-settings", "resources", "crds", "helm-releases", "flux", "gateways"}
+)
+
+// bootstrapStepsNeedingPredecessor are steps that only work after another
+// step in the same run: joining needs the join material kubeadm init
+// printed, which is never persisted. The predecessor counts as completed for
+// --resume only once the step has completed too.
+var bootstrapStepsNeedingPredecessor = map[string]string{"kubeadm-join": "kubeadm-init"}
+
+// bootstrapStatePath is where per-step completion is persisted between runs.
+var bootstrapStatePath = func() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "homeops-cli", "bootstrap-state.json"), nil
+}
+
+// bootstrapState is the persisted record of the last real bootstrap.
+type bootstrapState struct {
+	Provider          string                        `json:"provider"`
+	KubernetesVersion string                        `json:"kubernetes_version"`
+	TalosVersion      string                        `json:"talos_version,omitempty"`
+	Steps             map[string]bootstrapStepState `json:"steps"`
+}
+
+type bootstrapStepState struct {
+	// Inputs hashes what the step ran against; see bootstrapInputsHash.
+	Inputs      string    `json:"inputs"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// bootstrapProgress decides which steps of a run are skipped for --resume,
+// --from-step or --only and records each completed step.
+type bootstrapProgress struct {
+	path   string
+	inputs string
+	state  bootstrapState
+	resume bool
+	from   string
+	only   []string
+	// running is set once a step runs: every later step runs too.
+	running bool
+	logger  *common.ColorLogger
+}
+
+// bootstrapProviderSteps are the step names of config's flow; like
+// runBootstrap, anything but flatcar is the Talos flow.
+func bootstrapProviderSteps(config *BootstrapConfig) []string {
+	if strings.EqualFold(config.Provider, "flatcar") {
+		return flatcarBootstrapSteps
+	}
+	return talosBootstrapSteps
+}
+
+// validateBootstrapResume checks --resume, --from-step and --only before
+// anything runs.
+func validateBootstrapResume(config *BootstrapConfig) error {
+	if !config.Resume && config.FromStep == "" && len(config.Only) == 0 {
+		return nil
+	}
+	selected := 0
+	for _, set := range []bool{config.Resume, config.FromStep != "", len(config.Only) > 0} {
+		if set {
+			selected++
+		}
+	}
+	if selected > 1 {
+		return fmt.Errorf("--resume, --from-step and --only cannot be combined")
+	}
+	if config.DryRun || config.Plan || config.Check {
+		return fmt.Errorf("--resume, --from-step and --only cannot be combined with --dry-run, --plan, or --check")
+	}
+	steps := bootstrapProviderSteps(config)
+	for _, step := range append([]string{config.FromStep}, config.Only...) {
+		if step != "" && !slices.Contains(steps, step) {
+			return fmt.Errorf("unknown bootstrap step %q (steps: %s)", step, strings.Join(steps, ", "))
+		}
+	}
+	if predecessor, ok := bootstrapStepsNeedingPredecessor[config.FromStep]; ok {
+		return fmt.Errorf("bootstrap cannot start at %s: it needs %s in the same run (use --from-step %s)", config.FromStep, predecessor, predecessor)
+	}
+	for _, step := range config.Only {
+		if predecessor, ok := bootstrapStepsNeedingPredecessor[step]; ok && !slices.Contains(config.Only, predecessor) {
+			return fmt.Errorf("bootstrap cannot run %s alone: it needs %s in the same run (use --only %s,%s)", step, predecessor, predecessor, step)
+		}
+	}
+	return nil
+}
+
+// checkBootstrapOnlyCluster fails an --only run early when its steps need
+// the API server but it leaves out the steps that bring the cluster up and
+// fetch its kubeconfig, and the API server cannot be reached.
+func checkBootstrapOnlyCluster(config *BootstrapConfig) error {
+	if len(config.Only) == 0 || slices.Contains(config.Only, "kubeconfig") {
+		return nil
+	}
+	steps := bootstrapProviderSteps(config)
+	needAPI := steps[slices.Index(steps, "kubeconfig")+1:]
+	if !slices.ContainsFunc(config.Only, func(step string) bool { return slices.Contains(needAPI, step) }) {
+		return nil
+	}
+	client, err := bootstrapKubeClient(config)
+	if err == nil {
+		err = client.Ping()
+	}
+	if err != nil {
+		return fmt.Errorf("--only %s needs a reachable API server (or the kubeconfig step): %w", strings.Join(config.Only, ","), err)
+	}
+	return nil
+}
+
+// bootstrapInputsHash fingerprints what the steps run against, so a step
+// completed for other versions or another cluster is not skipped.
+func bootstrapInputsHash(config *BootstrapConfig) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		strings.ToLower(config.Provider), config.RootDir, config.KubeConfig, config.K8sVersion, config.TalosVersion,
+	}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// openBootstrapProgress loads the persisted state for a real run, once the
+// versions it runs are resolved. A run neither resuming, starting at a step
+// nor picking steps with --only starts over, and a state left by other
+// versions is discarded. It is nil for a dry run.
+func openBootstrapProgress(config *BootstrapConfig, logger *common.ColorLogger) *bootstrapProgress {
+	if config.DryRun {
+		return nil
+	}
+	path, err := bootstrapStatePath()
+	if err != nil {
+		logger.Warn("Bootstrap progress will not be saved: %v", err)
+		if config.FromStep == "" && len(config.Only) == 0 {
+			return nil
+		}
+		// The steps --from-step and --only select are still left out.
+		return &bootstrapProgress{from: config.FromStep, only: config.Only, logger: logger,
+			state: bootstrapState{Steps: map[string]bootstrapStepState{}}}
+	}
+	progress := &bootstrapProgress{
+		path:   path,
+		inputs: bootstrapInputsHash(config),
+		resume: config.Resume,
+		from:   config.FromStep,
+		only:   config.Only,
+		logger: logger,
+	}
+	fresh := bootstrapState{
+		Provider:          strings.ToLower(config.Provider),
+		KubernetesVersion: config.K8sVersion,
+		TalosVersion:      config.TalosVersion,
+		Steps:             map[string]bootstrapStepState{},
+	}
+	progress.state = fresh
+	if !config.Resume && config.FromStep == "" && len(config.Only) == 0 {
+		// Nothing a previous run completed is trusted by a later --resume.
+		if err := progress.save(); err != nil {
+			logger.Warn("Bootstrap progress will not be saved: %v", err)
+			return nil
+		}
+		return progress
+	}
+
+	stored, err := loadBootstrapState(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if config.Resume {
+			logger.Info("No bootstrap progress saved at %s: running every step", path)
+		}
+	case err != nil:
+		logger.Warn("Ignoring unreadable bootstrap progress: %v", err)
+	case stored.Provider != fresh.Provider:
+		logger.Warn("Saved bootstrap progress is for the %s provider: starting over", stored.Provider)
+	case stored.KubernetesVersion != fresh.KubernetesVersion:
+		logger.Warn("Saved bootstrap progress is for Kubernetes %s, not %s: starting over", stored.KubernetesVersion, fresh.KubernetesVersion)
+	case stored.TalosVersion != fresh.TalosVersion:
+		logger.Warn("Saved bootstrap progress is for Talos %s, not %s: starting over", stored.TalosVersion, fresh.TalosVersion)
+	default:
+		if stored.Steps != nil {
+			progress.state.Steps = stored.Steps
+		}
+	}
+	return progress
+}
+
+func loadBootstrapState(path string) (bootstrapState, error) {
+	var stored bootstrapState
+	data, err := os.ReadFile(path) // #nosec G304 -- bootstrap state file under the user cache directory
+	if err != nil {
+		return stored, err
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return stored, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return stored, nil
+}
+
+// skip reports whether step is left out of this run: steps not named by
+// --only, steps before --from-step, or with --resume the completed steps
+// before the first one that is not. Preflight always runs, except that
+// --only runs exactly the steps it names.
+func (p *bootstrapProgress) skip(step string) bool {
+	if p != nil && len(p.only) > 0 {
+		if slices.Contains(p.only, step) {
+			return false
+		}
+		p.logger.Info("⏭️  Skipping %s (--only)", step)
+		return true
+	}
+	if p == nil || p.running || step == "preflight" {
+		return false
+	}
+	if p.from != "" {
+		if step == p.from {
+			p.running = true
+			return false
+		}
+		p.logger.Info("⏭️  Skipping %s (--from-step %s)", step, p.from)
+		return true
+	}
+	if done, ok := p.state.Steps[step]; p.resume && ok && done.Inputs == p.inputs {
+		p.logger.Info("⏭️  Skipping %s: completed %s (--resume)", step, done.CompletedAt.Local().Format(time.RFC3339))
+		return true
+	}
+	p.running = true
+	return false
+}
+
+// complete records step, and the predecessor it needed, as completed. A
+// predecessor is recorded only with its step: until then a resume redoes it.
+func (p *bootstrapProgress) complete(step string) {
+	if p == nil || step == "preflight" {
+		return
+	}
+	p.running = true
+	if !isBootstrapPredecessor(step) {
+		done := bootstrapStepState{Inputs: p.inputs, CompletedAt: bootstrapNow().UTC()}
+		p.state.Steps[step] = done
+		if predecessor, ok := bootstrapStepsNeedingPredecessor[step]; ok {
+			p.state.Steps[predecessor] = done
+		}
+	}
+	if err := p.save(); err != nil {
+		p.logger.Warn("Bootstrap progress not saved (--resume will redo %s): %v", step, err)
+	}
+}
+
+func isBootstrapPredecessor(step string) bool {
+	for _, predecessor := range bootstrapStepsNeedingPredecessor {
+		if predecessor == step {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *bootstrapProgress) save() error {
+	if p.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(p.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p.path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", p.path, err)
+	}
+	tmp := p.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, p.path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", p.path, err)
+	}
+	return nil
+}
@@ -12,6 +12,10 @@ import (
 	"homeops-cli/internal/secrets"
 )
 
+// unsafeSaveRenderedSecrets is set by --unsafe-save-secrets for the run: the
+// debug copy of each rendered config keeps its resolved secret values.
+var unsafeSaveRenderedSecrets bool
+
 // get1PasswordSecret retrieves a secret through the shared scheme resolver.
 func get1PasswordSecret(reference string) (string, error) {
 	return secrets.Resolve(reference)
@@ -56,6 +60,12 @@ func resolve1PasswordReferences(content string, logger *common.ColorLogger) (str
 		}
 	}
 
+	// Whatever injected the values, mask them in every later log line that
+	// echoes this config.
+	for _, value := range secrets.InjectedValues(content, resolved) {
+		common.RegisterSecret("1password", value)
+	}
+
 	// Optional validation message
 	if remaining := secrets.ListReferences(resolved); len(remaining) > 0 {
 		logger.Warn("Warning: Resolved content still contains secret references")
@@ -67,8 +77,12 @@ func resolve1PasswordReferences(content string, logger *common.ColorLogger) (str
 	}
 
 	// Save rendered configuration for validation if debug is enabled
-	if os.Getenv(constants.EnvDebug) == "1" || os.Getenv("SAVE_RENDERED_CONFIG") == "1" {
+	if os.Getenv(constants.EnvDebug) == "1" || os.Getenv("SAVE_RENDERED_CONFIG") == "1" || unsafeSaveRenderedSecrets {
 		redacted := redactResolved1PasswordValues(content, resolved)
+		if unsafeSaveRenderedSecrets {
+			logger.Warn("--unsafe-save-secrets: the saved rendered configuration contains plaintext secrets")
+			redacted = resolved
+		}
 		hash := fmt.Sprintf("%x", sha256.Sum256([]byte(redacted)))
 		debugDir, err := renderedConfigDebugDir()
 		if err != nil {
@@ -92,17 +106,20 @@ func renderedConfigDebugDir() (string, error) {
 	return filepath.Join(cacheDir, "homeops-cli", "rendered-configs"), nil
 }
 
+// redactResolved1PasswordValues returns the config as saved for debugging:
+// the unresolved original with its op:// references masked, and any value
+// already resolved into it while templating masked too.
 func redactResolved1PasswordValues(original, resolved string) string {
 	opRefs := extractOnePasswordReferences(original)
 	if len(opRefs) == 0 {
-		return resolved
+		return common.RedactSecrets(resolved)
 	}
 
 	redacted := original
 	for _, ref := range opRefs {
 		redacted = strings.ReplaceAll(redacted, ref, "<redacted:1password>")
 	}
-	return redacted
+	return common.RedactSecrets(redacted)
 }
 
 // saveRenderedConfig saves the rendered configuration to a file for inspection
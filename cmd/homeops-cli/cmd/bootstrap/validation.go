@@ -1,6 +1,7 @@
 package bootstrap
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -34,11 +35,11 @@ func validateKubeconfig(config *BootstrapConfig, logger *common.ColorLogger) err
 			return fmt.Errorf("cluster did not become ready after %v (max wait exceeded)", elapsed.Round(time.Second))
 		}
 
-		// Test cluster connectivity with timeout
+		// Test cluster connectivity (every request is bounded by kube.RequestTimeout)
 		currentState := "no-connection"
-		if err := bootstrapKubectlRun(config, "cluster-info", "--request-timeout=10s"); err == nil {
-			// If cluster-info succeeds, test node accessibility
-			if err := bootstrapKubectlRun(config, "get", "nodes", "--request-timeout=10s"); err == nil {
+		if client, err := bootstrapKubeClient(config); err == nil && client.Ping() == nil {
+			// If the API server answers, test node accessibility
+			if _, err := client.Nodes(context.Background()); err == nil {
 				logger.Debug("Kubeconfig validation passed - cluster is accessible (took %v)", elapsed.Round(time.Second))
 				return nil
 			}
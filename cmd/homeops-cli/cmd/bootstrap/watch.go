@@ -0,0 +1,101 @@
+package bootstrap
+
+import (
+	"context"
+	"time"
+
+	"homeops-cli/internal/common"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// bootstrapWatcher wakes a wait loop as soon as an object it waits on
+// changes. The loop's check interval stays the longest it sleeps, so its
+// stall and max-wait checks and periodic progress lines keep their cadence.
+type bootstrapWatcher struct {
+	changes chan struct{}
+	cancel  context.CancelFunc
+}
+
+// watchBootstrapResources watches each resource in namespace (cluster-wide
+// when empty). It returns nil, and the wait polls, when no watch opens; a
+// resource whose watch cannot be reopened is polled from then on.
+func watchBootstrapResources(config *BootstrapConfig, logger *common.ColorLogger, namespace string, resources ...schema.GroupVersionResource) *bootstrapWatcher {
+	client, err := bootstrapKubeClient(config)
+	if err != nil {
+		logger.Debug("Not watching the cluster, polling instead: %v", err)
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	watcher := &bootstrapWatcher{changes: make(chan struct{}, 1), cancel: cancel}
+	watching := 0
+	for _, resource := range resources {
+		stream, err := client.Watch(ctx, resource, namespace)
+		if err != nil {
+			logger.Debug("Watching %s failed, polling instead: %v", resource.Resource, err)
+			continue
+		}
+		watching++
+		go func() {
+			for {
+				for range stream.ResultChan() {
+					watcher.notify()
+				}
+				stream.Stop()
+				if ctx.Err() != nil {
+					return
+				}
+				// The server ends every watch after a while; whatever
+				// changed in between is caught by the re-check this wakes.
+				watcher.notify()
+				if stream, err = client.Watch(ctx, resource, namespace); err != nil {
+					return
+				}
+			}
+		}()
+	}
+	if watching == 0 {
+		cancel()
+		return nil
+	}
+	return watcher
+}
+
+func (w *bootstrapWatcher) notify() {
+	select {
+	case w.changes <- struct{}{}:
+	default:
+	}
+}
+
+// wait returns on the next change, or after interval without one.
+func (w *bootstrapWatcher) wait(interval time.Duration) {
+	if w == nil {
+		bootstrapSleep(interval)
+		return
+	}
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	select {
+	case <-w.changes:
+	case <-timer.C:
+	}
+}
+
+func (w *bootstrapWatcher) stop() {
+	if w != nil {
+		w.cancel()
+	}
+}
+
+// progressDue reports whether a wait's periodic progress line is due and,
+// when it is, schedules the next one a period later. A wait woken by watch
+// events does not check at whole multiples of its interval.
+func progressDue(next *time.Time, period time.Duration) bool {
+	now := bootstrapNow()
+	if now.Before(*next) {
+		return false
+	}
+	*next = now.Add(period)
+	return true
+}
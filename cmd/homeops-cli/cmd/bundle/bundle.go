@@ -0,0 +1,175 @@
+// Package bundle implements the commands that build, check, and unpack the
+// offline disaster-recovery bundle.
+package bundle
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"homeops-cli/internal/bundle"
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/config"
+	"homeops-cli/internal/talos"
+	"homeops-cli/internal/ui"
+)
+
+var (
+	nowFn        = time.Now
+	executableFn = os.Executable
+)
+
+// NewCommand creates the top-level bundle command group.
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bundle",
+		Short: "Build and unpack an offline disaster-recovery bundle",
+		Long: `A recovery bundle is one tar.gz holding everything needed to rebuild the
+cluster without internet access: this homeops-cli binary, the external tools
+pinned under toolchain.tools in homeops.yaml (downloaded for one OS/arch and
+checksum-verified), prepared Talos ISOs and their schematic state, the
+embedded templates, and a BUNDLE-MANIFEST.json with every file's SHA-256.`,
+	}
+	cmd.AddCommand(newCreateCommand(), newVerifyCommand(), newExtractCommand())
+	return cmd
+}
+
+func newCreateCommand() *cobra.Command {
+	var (
+		output   string
+		goos     string
+		goarch   string
+		isoCache string
+		skipISOs bool
+	)
+	cmd := &cobra.Command{
+		Use:          "create",
+		Short:        "Package the CLI, pinned tools, ISOs and templates into a bundle",
+		SilenceUsage: true,
+		Example: `  homeops-cli bundle create --output homeops-dr.tar.gz
+  homeops-cli bundle create --os darwin --arch arm64 --skip-isos`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			binary, err := executableFn()
+			if err != nil {
+				return fmt.Errorf("locate the homeops-cli binary: %w", err)
+			}
+			if resolved, err := filepath.EvalSymlinks(binary); err == nil {
+				binary = resolved
+			}
+			if goos != runtime.GOOS || goarch != runtime.GOARCH {
+				common.NewColorLogger().Warn("Tools are for %s/%s but the bundled homeops-cli is this %s/%s binary", goos, goarch, runtime.GOOS, runtime.GOARCH)
+			}
+			manifest, err := bundle.Create(cmd.Context(), bundle.CreateOptions{
+				Output:         output,
+				BinaryPath:     binary,
+				HomeopsVersion: homeopsVersion(cmd),
+				OS:             goos,
+				Arch:           goarch,
+				Tools:          config.Get().Toolchain.Tools,
+				ISOCacheDir:    isoCache,
+				SkipISOs:       skipISOs,
+				Now:            nowFn(),
+			})
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), renderSummary(manifest))
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Wrote %s (%d files). Check it later with 'homeops-cli bundle verify %s'.\n", output, len(manifest.Entries), output)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&output, "output", "homeops-dr.tar.gz", "bundle file to write (must not exist)")
+	cmd.Flags().StringVar(&goos, "os", runtime.GOOS, "operating system the tools are downloaded for")
+	cmd.Flags().StringVar(&goarch, "arch", runtime.GOARCH, "architecture the tools are downloaded for")
+	cmd.Flags().StringVar(&isoCache, "iso-cache", talos.CacheDir, "Talos factory cache holding the prepared ISO records")
+	cmd.Flags().BoolVar(&skipISOs, "skip-isos", false, "bundle the schematic state without downloading the ISOs")
+	return cmd
+}
+
+func newVerifyCommand() *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:          "verify <bundle>",
+		Short:        "Check every file in a bundle against its manifest",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		Example: `  homeops-cli bundle verify homeops-dr.tar.gz
+  homeops-cli bundle verify homeops-dr.tar.gz -o json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ui.ValidateOutputFormat(output); err != nil {
+				return err
+			}
+			manifest, err := bundle.Verify(args[0])
+			if err != nil {
+				return err
+			}
+			if output == "json" {
+				raw, err := json.MarshalIndent(manifest, "", "  ")
+				if err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintln(cmd.OutOrStdout(), string(raw))
+				return nil
+			}
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), renderSummary(manifest))
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "OK: %d files match %s.\n", len(manifest.Entries), bundle.ManifestName)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table or json")
+	return cmd
+}
+
+func newExtractCommand() *cobra.Command {
+	var dir string
+	cmd := &cobra.Command{
+		Use:          "extract <bundle>",
+		Short:        "Verify and unpack a bundle with a recovery README",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		Example: `  homeops-cli bundle extract homeops-dr.tar.gz
+  homeops-cli bundle extract homeops-dr.tar.gz --dir /mnt/usb/dr`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if dir == "" {
+				dir = strings.TrimSuffix(strings.TrimSuffix(filepath.Base(args[0]), ".gz"), ".tar")
+			}
+			manifest, err := bundle.Extract(args[0], dir)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Extracted %d files to %s. Start with %s.\n", len(manifest.Entries), dir, filepath.Join(dir, bundle.ReadmeName))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&dir, "dir", "", "directory to extract into, empty or absent (default: the bundle name without .tar.gz)")
+	return cmd
+}
+
+// homeopsVersion is the root command's version string, "dev" when unset.
+func homeopsVersion(cmd *cobra.Command) string {
+	if v := cmd.Root().Version; v != "" {
+		return v
+	}
+	return "dev"
+}
+
+// renderSummary lists everything but the templates, which are counted.
+func renderSummary(m *bundle.Manifest) string {
+	var rows [][]string
+	templates := 0
+	for _, entry := range m.Entries {
+		if entry.Kind == bundle.KindTemplate {
+			templates++
+			continue
+		}
+		rows = append(rows, []string{entry.Kind, entry.Path, entry.Version, entry.SHA256[:12]})
+	}
+	rows = append(rows, []string{bundle.KindTemplate, "templates/", fmt.Sprintf("%d files", templates), ""})
+	return ui.Table([]string{"KIND", "PATH", "VERSION", "SHA256"}, rows)
+}
@@ -0,0 +1,74 @@
+package bundle
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/bundle"
+	"homeops-cli/internal/config"
+	"homeops-cli/internal/testutil"
+)
+
+func TestBundleCreateVerifyExtract(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1.36.2/linux/amd64/kubectl" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = io.WriteString(w, "kubectl-binary")
+	}))
+	t.Cleanup(server.Close)
+	sum := sha256.Sum256([]byte("kubectl-binary"))
+	cfg := &config.Config{}
+	cfg.Toolchain.Tools = []config.ToolPin{{
+		Name: "kubectl", Version: "v1.36.2", URL: server.URL + "/{version}/{os}/{arch}/kubectl",
+		SHA256: map[string]string{"linux/amd64": hex.EncodeToString(sum[:])},
+	}}
+	t.Cleanup(config.SetForTesting(cfg))
+
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "homeops-cli")
+	require.NoError(t, os.WriteFile(binary, []byte("homeops-binary"), 0o755))
+	testutil.Swap(t, &executableFn, func() (string, error) { return binary, nil })
+	testutil.Swap(t, &nowFn, func() time.Time { return time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC) })
+
+	output := filepath.Join(dir, "homeops-dr.tar.gz")
+	out, err := testutil.ExecuteCommand(NewCommand(), "create", "--output", output, "--os", "linux", "--arch", "amd64", "--iso-cache", filepath.Join(dir, "no-isos"))
+	require.NoError(t, err)
+	assert.Contains(t, out, "tools/kubectl/kubectl")
+	assert.Contains(t, out, "Wrote "+output)
+
+	out, err = testutil.ExecuteCommand(NewCommand(), "verify", output, "-o", "json")
+	require.NoError(t, err)
+	var manifest bundle.Manifest
+	require.NoError(t, json.NewDecoder(strings.NewReader(out)).Decode(&manifest))
+	assert.Equal(t, "linux", manifest.OS)
+	assert.Equal(t, "kubectl", manifest.Entries[1].Name)
+
+	out, err = testutil.ExecuteCommand(NewCommand(), "verify", output)
+	require.NoError(t, err)
+	assert.Contains(t, out, "OK: ")
+
+	target := filepath.Join(dir, "dr")
+	out, err = testutil.ExecuteCommand(NewCommand(), "extract", output, "--dir", target)
+	require.NoError(t, err)
+	assert.Contains(t, out, "Start with "+filepath.Join(target, bundle.ReadmeName))
+	data, err := os.ReadFile(filepath.Join(target, "homeops-cli"))
+	require.NoError(t, err)
+	assert.Equal(t, "homeops-binary", string(data))
+
+	_, err = testutil.ExecuteCommand(NewCommand(), "create", "--output", output)
+	require.ErrorContains(t, err, "already exists")
+}
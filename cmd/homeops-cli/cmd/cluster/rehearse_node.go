@@ -215,8 +215,10 @@ func buildRehearseNodeSpec(cfg *config.Config, providerName string) (rehearseNod
 		if node.Name == production.Name {
 			return rehearseNodeSpec{}, fmt.Errorf("refusing test node name %q: it matches production cluster.nodes entry", node.Name)
 		}
-		if node.IP == production.IP {
-			return rehearseNodeSpec{}, fmt.Errorf("refusing test node IP %s: it matches production node %s", node.IP, production.Name)
+		for _, address := range node.Addresses() {
+			if production.HasAddress(address) {
+				return rehearseNodeSpec{}, fmt.Errorf("refusing test node IP %s: it matches production node %s", address, production.Name)
+			}
 		}
 		for _, vmid := range productionVMIDs(production) {
 			if profile.VMID == vmid {
@@ -54,6 +54,10 @@ func TestBuildRehearseNodeSpecRefusals(t *testing.T) {
 	}{
 		{name: "production name collision", mutate: func(cfg *config.Config) { cfg.Cluster.TestNode.Name = "k8s-0" }, want: "matches production"},
 		{name: "production IP collision", mutate: func(cfg *config.Config) { cfg.Cluster.TestNode.IP = "192.0.2.10" }, want: "matches production"},
+		{name: "production IPv6 collision", mutate: func(cfg *config.Config) {
+			cfg.Cluster.Nodes[0].IPv6 = "2001:db8::10"
+			cfg.Cluster.TestNode.IPv6 = "2001:db8:0::10"
+		}, want: "refusing test node IP 2001:db8:0::10"},
 		{name: "production VMID collision", mutate: func(cfg *config.Config) { cfg.Cluster.TestNode.VM.VMID = 200 }, want: "matches production"},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
@@ -3,11 +3,15 @@ package completion
 import (
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 
 	"homeops-cli/internal/common"
 	"homeops-cli/internal/config"
 	"homeops-cli/internal/constants"
+	"homeops-cli/internal/templates"
+	"homeops-cli/internal/truenas"
+	"homeops-cli/internal/vmlifecycle"
 
 	"github.com/spf13/cobra"
 )
@@ -127,13 +131,24 @@ func getKubernetesApplications(namespace string) ([]string, error) {
 
 // getKubernetesNodes fetches node IPs from the cluster
 func getKubernetesNodes() ([]string, error) {
-	cmd := common.Command("kubectl", "get", "nodes", "-o", "jsonpath={.items[*].status.addresses[?(@.type=='InternalIP')].address}")
+	cmd := common.Command("kubectl", "get", "nodes", "-o", `jsonpath={range .items[*]}{.status.addresses[?(@.type=="InternalIP")].address}{"\n"}{end}`)
 	output, err := cmd.Output()
 	if err != nil {
 		return nil, err
 	}
-	nodeIPs := strings.Fields(string(output))
-	return nodeIPs, nil
+	return preferredNodeAddresses(string(output)), nil
+}
+
+// preferredNodeAddresses reduces one line of InternalIPs per node to a single
+// address, preferring IPv4 for dual-stack nodes.
+func preferredNodeAddresses(output string) []string {
+	var nodeIPs []string
+	for _, line := range strings.Split(output, "\n") {
+		if address := config.PreferredAddress(strings.Fields(line)); address != "" {
+			nodeIPs = append(nodeIPs, address)
+		}
+	}
+	return nodeIPs
 }
 
 // getKubernetesNodeNames fetches node names from the cluster
@@ -158,6 +173,8 @@ func ValidNodeNames(cmd *cobra.Command, args []string, toComplete string) ([]str
 	return config.Get().NodeNames(), cobra.ShellCompDirectiveNoFileComp
 }
 
+var talosNodeTemplateIPsFn = templates.TalosNodeTemplateIPs
+
 // ValidNodeIPs provides completion for node IP addresses
 func ValidNodeIPs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	// Try to get dynamic node IPs from cluster
@@ -165,12 +182,18 @@ func ValidNodeIPs(cmd *cobra.Command, args []string, toComplete string) ([]strin
 		return nodeIPs, cobra.ShellCompDirectiveNoFileComp
 	}
 
-	// Fallback to the configured cluster topology
+	// Fallback to the configured cluster topology, then nodes that only
+	// have an embedded talos/nodes/<ip>.yaml template so far
 	nodes := config.Get().Cluster.Nodes
 	nodeIPs := make([]string, 0, len(nodes))
 	for _, n := range nodes {
 		nodeIPs = append(nodeIPs, n.IP)
 	}
+	for _, ip := range talosNodeTemplateIPsFn() {
+		if !slices.Contains(nodeIPs, ip) {
+			nodeIPs = append(nodeIPs, ip)
+		}
+	}
 	return nodeIPs, cobra.ShellCompDirectiveNoFileComp
 }
 
@@ -239,3 +262,30 @@ func ValidVMNames(cmd *cobra.Command, args []string, toComplete string) ([]strin
 	// Fallback to the configured cluster node names.
 	return config.Get().NodeNames(), cobra.ShellCompDirectiveNoFileComp
 }
+
+// trueNASCPUModelsFn lists vm.cpu_model_choices from the NAS. Swappable for
+// tests.
+var trueNASCPUModelsFn = func() ([]string, error) {
+	var models []string
+	err := vmlifecycle.WithTrueNASVMManager(common.NewColorLogger(), func(manager vmlifecycle.TrueNASVMManager) error {
+		var err error
+		models, err = manager.CPUModelChoices()
+		return err
+	})
+	return models, err
+}
+
+// ValidCPUModes provides completion for TrueNAS --cpu-mode values
+func ValidCPUModes(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return truenas.CPUModes, cobra.ShellCompDirectiveNoFileComp
+}
+
+// ValidTrueNASCPUModels provides completion for --cpu-model from the NAS's
+// vm.cpu_model_choices
+func ValidTrueNASCPUModels(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	models, err := trueNASCPUModelsFn()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return models, cobra.ShellCompDirectiveNoFileComp
+}
@@ -65,3 +65,23 @@ func TestCompletionFallbacks(t *testing.T) {
 	assert.NotContains(t, vms, "k8s_0")
 	assert.Equal(t, cobra.ShellCompDirectiveNoFileComp, directive)
 }
+
+func TestValidNodeIPsIncludesNodeTemplates(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	testutil.Swap(t, &talosNodeTemplateIPsFn, func() []string { return []string{"192.168.122.10", "192.168.122.13"} })
+
+	nodeIPs, _ := ValidNodeIPs(nil, nil, "")
+	assert.Contains(t, nodeIPs, "192.168.122.13")
+	count := 0
+	for _, ip := range nodeIPs {
+		if ip == "192.168.122.10" {
+			count++
+		}
+	}
+	assert.Equal(t, 1, count, "a templated node in cluster.nodes is listed once")
+}
+
+func TestPreferredNodeAddresses(t *testing.T) {
+	output := "fd00::10 192.168.122.10\n192.168.122.11\nfd00::12\n\n"
+	assert.Equal(t, []string{"192.168.122.10", "192.168.122.11", "fd00::12"}, preferredNodeAddresses(output))
+}
@@ -20,6 +20,7 @@ import (
 	"homeops-cli/internal/secrets"
 	"homeops-cli/internal/ssh"
 	"homeops-cli/internal/state"
+	"homeops-cli/internal/templates"
 
 	"github.com/spf13/cobra"
 )
@@ -30,11 +31,15 @@ var (
 		_, err := exec.LookPath(bin)
 		return err
 	}
-	resolveRefFn     = secrets.Resolve
-	locateConfigFn   = config.Locate
-	loadConfigFn     = config.LoadFile
-	currentConfigFn  = config.Get
-	validateSSHKeyFn = ssh.ValidatePrivateKeyFile
+	resolveRefFn           = secrets.Resolve
+	locateConfigFn         = config.Locate
+	loadConfigFn           = config.LoadFile
+	currentConfigFn        = config.Get
+	validateSSHKeyFn       = ssh.ValidatePrivateKeyFile
+	clusterSettingsFn      = templates.LoadClusterSettings
+	lintTemplateSettingsFn = templates.LintTemplateSettings
+	lintDualStackFn        = templates.LintDualStackTemplates
+	lintDiskEncryptionFn   = templates.LintDiskEncryptionTemplates
 )
 
 // NewCommand builds the `config` command group.
@@ -50,7 +55,7 @@ Discovery order: --config flag > $HOMEOPS_CONFIG > ./homeops.yaml >
 <git root>/homeops.yaml > ~/.config/homeops/config.yaml. With no file at all,
 fully-portable defaults apply (env:// secrets, local-file state stores).`,
 	}
-	cmd.AddCommand(newInitCommand(), newShowCommand(), newDoctorCommand())
+	cmd.AddCommand(newInitCommand(), newShowCommand(), newDoctorCommand(), newLintTemplatesCommand())
 	return cmd
 }
 
@@ -157,6 +162,19 @@ cluster:
   service_cidr: 10.43.0.0/16
   dns_domain: cluster.local
   node_subnet: 192.168.120.0/22
+  # DHCP dynamic range on the node subnet; talos check-ip fails node IPs inside it.
+  #dhcp_pool:
+  #  start: 192.168.120.100
+  #  end: 192.168.120.199
+  # Externally exposed hostnames k8s tls-probe checks (default: HTTPRoutes on
+  # Gateways named *external*) and their HSTS/header policy.
+  #tls_probe:
+  #  gateways: [network/envoy-external]
+  #  hsts_min_max_age: 15552000
+  #  hosts:
+  #    legacy.example.com:
+  #      hsts: false
+  #      allow_missing_headers: [Content-Security-Policy]
   ntp_servers:
     - 10.123.123.123
     - 10.123.123.124
@@ -177,6 +195,11 @@ cluster:
       disk: /dev/sdb
       min_size: 800GB
       max_size: 900GB
+    # Encrypt STATE and EPHEMERAL with LUKS2: nodeid, static (secret
+    # talos_disk_encryption_key), or tpm (TrueNAS VMs get a vTPM). Enabling
+    # or disabling needs a node reset; see talos encryption-status.
+    #disk_encryption:
+    #  provider: nodeid
   # Apiserver DNS name added to the cert SANs. Either set endpoint directly,
   # or set domain_ref and the endpoint is derived as "k8s." + domain.
   #endpoint: k8s.example.com
@@ -253,6 +276,12 @@ hypervisors:
     #vm:
     #  boot_storage: tank/VM    # zvol parent dataset
     #  network_bridge: br0
+    # Tighten VM naming beyond the TrueNAS rule (letters, digits, underscores).
+    # proxmox and vsphere accept the same block.
+    #naming:
+    #  prefix: k8s
+    #  pattern: '^k8s[0-9]{2}$'
+    #  max_length: 16
   #vsphere:
   #  iso_datastore: datastore1
   #  iso_file: vmware-amd64.iso
@@ -306,9 +335,42 @@ state:
 #templates:
 #  dir: ~/.config/homeops/templates
 
-# Bootstrap manifest settings.
+# Optional: the append-only log of mutating commands ('homeops-cli audit tail').
+# mirror also records each entry as a Kubernetes Event when the cluster is reachable.
+#audit:
+#  disabled: false
+#  path: ~/.local/state/homeops/audit.log
+#  mirror: false
+#  namespace: default
+
+# Optional: ping when bootstrap, upgrade-cluster, restore-all, or reset-cluster
+# finishes. webhook POSTs JSON to the notify_webhook_url secret (ntfy, Slack,
+# and Discord all read it); topic is for an ntfy server-root URL.
+#notify:
+#  desktop: true
+#  webhook: false
+#  topic: homeops
+
+# Optional: a directory searched before PATH for homeops-<name> plugin
+# executables, which appear as 'homeops-cli <name>' subcommands.
+#plugins:
+#  dir: ~/.config/homeops/plugins
+
+# Optional: external tools 'bundle create' packages for offline recovery.
+# url/checksum_url may use {version}, {os} and {arch}; each tool needs sha256
+# (per os/arch) or checksum_url so the download can be verified.
+#toolchain:
+#  tools:
+#    - name: kubectl
+#      version: v1.36.2
+#      url: https://dl.k8s.io/release/{version}/bin/{os}/{arch}/kubectl
+#      checksum_url: https://dl.k8s.io/release/{version}/bin/{os}/{arch}/kubectl.sha256
+
+# Bootstrap manifest settings. skip_gateway_check turns off the post-bootstrap
+# check that a Gateway API Gateway is Programmed with an address.
 bootstrap:
   op_vault: Infrastructure
+  #skip_gateway_check: true
 
 secrets:
 `)
@@ -347,6 +409,76 @@ never shown — only their references.`,
 				}
 				fmt.Printf("#   %-36s %s%s\n", key, cfg.SecretRef(key), marker)
 			}
+
+			// Show the cluster settings every template and the Flux
+			// cluster-settings ConfigMap are rendered from.
+			settings, err := clusterSettingsFn()
+			if err != nil {
+				return err
+			}
+			fmt.Println("# effective cluster settings:")
+			for _, key := range settings.Keys() {
+				fmt.Printf("#   %-36s %s   # %s\n", key, settings.Values[key], settings.Sources[key])
+			}
+			return nil
+		},
+	}
+	return cmd
+}
+
+func newLintTemplatesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lint-templates",
+		Short: "Check that templates only reference defined cluster settings",
+		Long: `Scan the embedded templates (and their templates.dir overrides) for
+{{ SETTINGS.KEY }} and {{ .Settings.KEY }} references and fail if any names a
+key that cluster-settings.yaml and homeops.yaml do not define. A typo would
+otherwise render as an empty string or "<no value>".
+
+With cluster.dual_stack, the Talos base templates are also rendered and every
+pod, service, kubelet, and etcd CIDR list must carry both address families.
+
+The Talos base templates and every node patch that sets systemDiskEncryption
+must match cluster.talos.disk_encryption, so no node is left with a different
+STATE/EPHEMERAL key provider.`,
+		Example: `  homeops-cli config lint-templates`,
+		// Unknown keys are a lint failure, not a usage error.
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := common.NewColorLogger()
+			unknown, err := lintTemplateSettingsFn()
+			if err != nil {
+				return err
+			}
+			for _, ref := range unknown {
+				logger.Error("%s:%d references unknown cluster setting %s", ref.Template, ref.Line, ref.Key)
+			}
+			if len(unknown) > 0 {
+				return fmt.Errorf("%d unknown cluster setting reference(s)", len(unknown))
+			}
+			logger.Success("all template cluster-setting references resolve")
+
+			problems, err := lintDualStackFn()
+			if err != nil {
+				return err
+			}
+			for _, problem := range problems {
+				logger.Error("%s", problem)
+			}
+			if len(problems) > 0 {
+				return fmt.Errorf("%d single-family CIDR list(s) in a dual-stack cluster", len(problems))
+			}
+
+			problems, err = lintDiskEncryptionFn()
+			if err != nil {
+				return err
+			}
+			for _, problem := range problems {
+				logger.Error("%s", problem)
+			}
+			if len(problems) > 0 {
+				return fmt.Errorf("%d Talos config(s) disagree with cluster.talos.disk_encryption", len(problems))
+			}
 			return nil
 		},
 	}
@@ -433,6 +565,37 @@ func runDoctor(skipSecrets, network bool) error {
 			fail("%s %q is not a valid CIDR", cidr.name, cidr.value)
 		}
 	}
+	if cfg.Cluster.DualStack {
+		ipv6 := cfg.Cluster.IPv6
+		for _, cidr := range []struct {
+			name     string
+			value    string
+			required bool
+		}{
+			{"cluster.ipv6.pod_cidr", ipv6.PodCIDR, true},
+			{"cluster.ipv6.service_cidr", ipv6.ServiceCIDR, true},
+			{"cluster.ipv6.node_subnet", ipv6.NodeSubnet, false},
+		} {
+			if cidr.value == "" && !cidr.required {
+				continue
+			}
+			if prefix, err := netip.ParsePrefix(cidr.value); err != nil || !prefix.Addr().Is6() {
+				fail("%s %q is not a valid IPv6 CIDR (cluster.dual_stack is on)", cidr.name, cidr.value)
+			}
+		}
+		if vip := ipv6.ControlPlaneVIP; vip != "" {
+			if addr, err := netip.ParseAddr(vip); err != nil || !addr.Is6() {
+				fail("cluster.ipv6.control_plane_vip %q is not a valid IPv6 address", vip)
+			}
+		}
+		for _, n := range cfg.Cluster.Nodes {
+			if n.IPv6 == "" {
+				warn("cluster.nodes: node %q has no ipv6 address; it joins the dual-stack cluster over IPv4 only", n.Name)
+			} else if addr, err := netip.ParseAddr(n.IPv6); err != nil || !addr.Is6() {
+				fail("cluster.nodes: node %q has invalid IPv6 %q", n.Name, n.IPv6)
+			}
+		}
+	}
 	if cfg.Cluster.DNSDomain == "" {
 		fail("cluster.dns_domain is empty")
 	}
@@ -461,19 +624,26 @@ func runDoctor(skipSecrets, network bool) error {
 	if net.ParseIP(cfg.Cluster.ControlPlaneVIP) == nil {
 		fail("cluster.control_plane_vip %q is not a valid IP", cfg.Cluster.ControlPlaneVIP)
 	} else {
-		logger.Success("topology: %d node(s), VIP %s, interface %s, pod/service CIDRs %s/%s", len(cfg.Cluster.Nodes), cfg.Cluster.ControlPlaneVIP, cfg.Cluster.NodeInterface, cfg.Cluster.PodCIDR, cfg.Cluster.ServiceCIDR)
+		logger.Success("topology: %d node(s), VIP %s, interface %s, pod/service CIDRs %s/%s", len(cfg.Cluster.Nodes), cfg.Cluster.ControlPlaneVIP, cfg.Cluster.NodeInterface, strings.Join(cfg.PodCIDRs(), ","), strings.Join(cfg.ServiceCIDRs(), ","))
 	}
 
 	// 3. Required binaries
 	binaries := []string{"kubectl", "helmfile"}
-	if cfg.UsesOpReferences() {
+	// A Connect server answers op:// references without the op binary.
+	if cfg.UsesOpReferences() && secrets.CurrentOpAuthMode() != secrets.OpAuthConnect {
 		binaries = append(binaries, "op")
 	}
+	if secrets.CurrentBackendName() == secrets.BackendSops {
+		binaries = append(binaries, "sops")
+	}
 	for _, bin := range binaries {
 		if err := lookPathFn(bin); err != nil {
 			hint := ""
-			if bin == "op" {
+			switch bin {
+			case "op":
 				hint = " (required because the config uses op:// references — https://developer.1password.com/docs/cli/get-started/)"
+			case "sops":
+				hint = " (required by the sops secret backend — https://github.com/getsops/sops)"
 			}
 			fail("binary %q not found in PATH%s", bin, hint)
 		} else {
@@ -13,6 +13,7 @@ import (
 	"gopkg.in/yaml.v3"
 
 	"homeops-cli/internal/config"
+	"homeops-cli/internal/templates"
 	"homeops-cli/internal/testutil"
 )
 
@@ -130,6 +131,56 @@ func TestShowCommandNeverPrintsSecretValues(t *testing.T) {
 	assert.Contains(t, out, "check_image: docker.io/library/alpine:3.22")
 }
 
+func TestShowCommandIncludesClusterSettings(t *testing.T) {
+	restore := config.SetForTesting(&config.Config{Cluster: config.ClusterConfig{Name: "lab"}})
+	defer restore()
+
+	out, _, err := testutil.CaptureOutput(func() {
+		cmd := NewCommand()
+		cmd.SetArgs([]string{"show"})
+		require.NoError(t, cmd.Execute())
+	})
+	require.NoError(t, err)
+	assert.Contains(t, out, "# effective cluster settings:")
+	assert.Regexp(t, `CLUSTER_NAME\s+lab\s+# homeops.yaml \(cluster.name\)`, out)
+	assert.Regexp(t, `TIMEZONE\s+America/New_York\s+# cluster-settings.yaml`, out)
+}
+
+func TestLintTemplatesCommandFailsOnUnknownSettings(t *testing.T) {
+	testutil.Swap(t, &lintTemplateSettingsFn, func() ([]templates.UnknownSettingRef, error) { return nil, nil })
+	testutil.Swap(t, &lintDualStackFn, func() ([]string, error) { return nil, nil })
+	testutil.Swap(t, &lintDiskEncryptionFn, func() ([]string, error) { return nil, nil })
+	_, err := testutil.ExecuteCommand(NewCommand(), "lint-templates")
+	require.NoError(t, err)
+
+	testutil.Swap(t, &lintTemplateSettingsFn, func() ([]templates.UnknownSettingRef, error) {
+		return []templates.UnknownSettingRef{{Template: "talos/worker.yaml", Line: 3, Key: "POD_CIRD"}}, nil
+	})
+	_, err = testutil.ExecuteCommand(NewCommand(), "lint-templates")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 unknown cluster setting reference(s)")
+}
+
+func TestLintTemplatesCommandFailsOnSingleFamilyCIDRs(t *testing.T) {
+	testutil.Swap(t, &lintTemplateSettingsFn, func() ([]templates.UnknownSettingRef, error) { return nil, nil })
+	testutil.Swap(t, &lintDualStackFn, func() ([]string, error) {
+		return []string{"talos/worker.yaml: cluster.network.podSubnets has no IPv6 entry (got [10.42.0.0/16])"}, nil
+	})
+	_, err := testutil.ExecuteCommand(NewCommand(), "lint-templates")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 single-family CIDR list(s) in a dual-stack cluster")
+}
+
+func TestLintTemplatesCommandFailsOnDiskEncryptionDrift(t *testing.T) {
+	testutil.Swap(t, &lintTemplateSettingsFn, func() ([]templates.UnknownSettingRef, error) { return nil, nil })
+	testutil.Swap(t, &lintDualStackFn, func() ([]string, error) { return nil, nil })
+	testutil.Swap(t, &lintDiskEncryptionFn, func() ([]string, error) {
+		return []string{"talos/nodes/192.168.122.11.yaml (k8s-1): systemDiskEncryption is state static, ephemeral static, cluster.talos.disk_encryption wants state tpm, ephemeral tpm"}, nil
+	})
+	_, err := testutil.ExecuteCommand(NewCommand(), "lint-templates")
+	require.ErrorContains(t, err, "1 Talos config(s) disagree with cluster.talos.disk_encryption")
+}
+
 func TestRunDoctorValidatesDeploymentSettings(t *testing.T) {
 	doctorSeams(t)
 	restore := config.SetForTesting(&config.Config{})
@@ -147,6 +198,25 @@ func TestRunDoctorValidatesDeploymentSettings(t *testing.T) {
 	assert.Contains(t, err.Error(), "2 problem(s)")
 }
 
+func TestRunDoctorValidatesDualStackSettings(t *testing.T) {
+	doctorSeams(t)
+	restore := config.SetForTesting(&config.Config{})
+	defer restore()
+	cfg := config.Get()
+	cfg.Cluster.DualStack = true
+	cfg.Cluster.IPv6 = config.ClusterIPv6Config{PodCIDR: "fd00:10:42::/56", ServiceCIDR: "10.96.0.0/12", ControlPlaneVIP: "192.168.122.100"}
+	cfg.Cluster.Nodes[0].IPv6 = "fd00::10"
+	cfg.Cluster.Nodes[1].IPv6 = "not-an-ip"
+
+	lookPathFn = func(string) error { return nil }
+	locateConfigFn = func() (string, bool) { return "", false }
+	currentConfigFn = func() *config.Config { return cfg }
+
+	err := runDoctor(true, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "3 problem(s)", "IPv4 service CIDR, IPv4 VIP, and the bad node address")
+}
+
 // doctorSeams stubs every external touchpoint of runDoctor.
 func doctorSeams(t *testing.T) {
 	t.Helper()
@@ -4,7 +4,9 @@
 package flatcar
 
 import (
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -24,6 +26,8 @@ import (
 	"homeops-cli/internal/state"
 	"homeops-cli/internal/truenas"
 	"homeops-cli/internal/ui"
+	"homeops-cli/internal/versioncheck"
+	"homeops-cli/internal/vmlifecycle"
 	"homeops-cli/internal/vsphere"
 
 	"github.com/spf13/cobra"
@@ -37,10 +41,16 @@ type ignitionSSHClient interface {
 	VerifyFile(string) (bool, int64, error)
 }
 
+// NodeIPPreflightFn runs the 'talos check-ip' checks for configured node
+// names before deploy-vm renders or creates anything. main sets it to
+// talos.NodeIPPreflight (cmd/talos imports this package); nil skips the checks.
+var NodeIPPreflightFn func(ctx context.Context, logger *common.ColorLogger, names []string, expectExisting bool) error
+
 // Swappable function vars for testability (mirrors cmd/talos patterns).
 var (
-	getVersionsFn      = versionconfig.GetVersions
-	workingDirectoryFn = common.GetWorkingDirectory
+	getVersionsFn   = versionconfig.GetVersions
+	repoRootFn      = common.RepoRoot
+	checkVersionsFn = versioncheck.Check
 	// resolveSecretKeyFn resolves a semantic secret key (config.Key*) through
 	// the homeops config; "" on miss. Swappable for tests.
 	resolveSecretKeyFn = func(key string) string {
@@ -471,8 +481,12 @@ func (d *truenasFlatcarDeployer) DeployNode(node flatcarNode, ignitionHandle str
 	if err != nil {
 		return err
 	}
+	vmName, err := flatcarVMName(providerTrueNAS, node.name)
+	if err != nil {
+		return err
+	}
 	cfg := truenas.VMConfig{
-		Name:           node.name,
+		Name:           vmName,
 		StoragePool:    d.pool,
 		NetworkBridge:  d.networkBridge,
 		BootZVol:       d.bootZVol, // empty => derived <pool>/VM/<name>-boot
@@ -483,7 +497,7 @@ func (d *truenasFlatcarDeployer) DeployNode(node flatcarNode, ignitionHandle str
 		TrueNASPort:    d.port,
 		NoSSL:          !d.useSSL,
 	}
-	d.logger.Info("Deploying Flatcar VM %s on TrueNAS", node.name)
+	d.logger.Info("Deploying Flatcar VM %s (node %s) on TrueNAS", vmName, node.name)
 	return client.DeployVM(cfg)
 }
 
@@ -595,7 +609,13 @@ func buildNodeEnv(nodeName string, vip, pauseImage, kubeVipVersion, nodeInterfac
 		return flatcar.NodeEnv{}, fmt.Errorf("unknown flatcar node %q (known: %s)", nodeName, strings.Join(nodeNames(), ", "))
 	}
 
-	versions := getVersionsFn(workingDirectoryFn())
+	// Version plans are repo-relative: refuse to render with built-in
+	// fallbacks when no checkout can be found.
+	rootDir, err := repoRootFn()
+	if err != nil {
+		return flatcar.NodeEnv{}, err
+	}
+	versions := getVersionsFn(rootDir)
 
 	cfg := versionconfig.Get()
 	if vip == "" {
@@ -639,8 +659,8 @@ func buildNodeEnv(nodeName string, vip, pauseImage, kubeVipVersion, nodeInterfac
 		K8sEndpoint:       k8sEndpoint,
 		SSHAuthorizedKey:  sshKey,
 		ClusterName:       cfg.ClusterNameWithDefault(),
-		PodCIDR:           cfg.Cluster.PodCIDR,
-		ServiceCIDR:       cfg.Cluster.ServiceCIDR,
+		PodCIDR:           strings.Join(cfg.PodCIDRs(), ","),
+		ServiceCIDR:       strings.Join(cfg.ServiceCIDRs(), ","),
 		DNSDomain:         cfg.Cluster.DNSDomain,
 		ClusterDNS:        cfg.ClusterDNS(),
 	}, nil
@@ -1090,9 +1110,17 @@ OVA template (--vsphere-template) onto --datastore, and receives its Ignition vi
 VMware guestinfo (base64). No install ISO and no SSH upload are involved.
 
 --provider truenas: each VM (TrueNAS SCALE libvirt) boots a pre-staged Flatcar
-image zvol (--boot-zvol, or <pool>/VM/<node>-boot under --truenas-pool) and
+image zvol (--boot-zvol, or <pool>/VM/<vm>-boot under --truenas-pool) and
 receives its Ignition via qemu fw_cfg. The Ignition is staged to a dataset on the
-NAS (--ignition-dir, default /mnt/<pool>/VM) over SSH.`,
+NAS (--ignition-dir, default /mnt/<pool>/VM) over SSH. TrueNAS VM names cannot
+contain dashes, so <vm> is the node name with dashes as underscores (k8s-0 =>
+k8s_0).
+
+Each node's planned IP from cluster.nodes first goes through the same checks as
+'talos check-ip': a FAIL (address already answering, DNS pointing elsewhere,
+inside cluster.dhcp_pool) aborts before anything is rendered or created.
+--expect-existing treats a responding address as the node being redeployed;
+--skip-ip-check bypasses the checks.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runDeployVM(cmd, *opts)
 		},
@@ -1119,7 +1147,7 @@ func registerDeployVMFlags(cmd *cobra.Command, opts *deployVMOptions) {
 	cmd.Flags().IntVar(&opts.memory, "memory", 0, "[vsphere] memory in MB (0 = inherit template)")
 	cmd.Flags().StringVar(&opts.truenasPool, "truenas-pool", "", "[truenas] storage pool/dataset for the VM (default: hypervisors.truenas.vm.boot_storage from homeops.yaml)")
 	cmd.Flags().StringVar(&opts.networkBridge, "network-bridge", "", "[truenas] bridge to attach the VM NIC to (default: hypervisors.truenas.vm.network_bridge from homeops.yaml)")
-	cmd.Flags().StringVar(&opts.bootZVol, "boot-zvol", "", "[truenas] pre-staged Flatcar boot zvol (single-node; else <pool>/VM/<node>-boot)")
+	cmd.Flags().StringVar(&opts.bootZVol, "boot-zvol", "", "[truenas] pre-staged Flatcar boot zvol (single-node; else <pool>/VM/<vm>-boot, e.g. k8s_0-boot)")
 	cmd.Flags().StringVar(&opts.ignitionDir, "ignition-dir", "", "[truenas] dir on the NAS for Ignition files (default /mnt/<pool>/VM)")
 	cmd.Flags().StringVar(&opts.truenasSSHHost, "truenas-ssh-host", "", "[truenas] host to SSH the Ignition to (default: the TrueNAS API host)")
 	cmd.Flags().StringVar(&opts.truenasSSHUser, "truenas-ssh-user", "", "[truenas] SSH user for Ignition upload (default: hypervisors.truenas.ssh_user from homeops.yaml)")
@@ -1133,6 +1161,8 @@ func registerDeployVMFlags(cmd *cobra.Command, opts *deployVMOptions) {
 	_ = cmd.Flags().MarkDeprecated("concurrent", "use --concurrency")
 	cmd.Flags().BoolVar(&opts.powerOn, "power-on", false, "Power on VMs after creation")
 	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "Render and build configs but do not create VMs")
+	cmd.Flags().BoolVar(&opts.skipIPCheck, "skip-ip-check", false, "Skip the check-ip preflight for the nodes' planned IPs")
+	cmd.Flags().BoolVar(&opts.expectExisting, "expect-existing", false, "In the check-ip preflight, expect the planned IPs to answer (the nodes being redeployed)")
 }
 
 type deployVMOptions struct {
@@ -1166,11 +1196,24 @@ type deployVMOptions struct {
 	concurrent     int
 	powerOn        bool
 	dryRun         bool
+	skipIPCheck    bool
+	expectExisting bool
+}
+
+func checkRepoVersions(ctx context.Context, components ...versioncheck.Component) error {
+	rootDir, err := repoRootFn()
+	if err != nil {
+		return err
+	}
+	return checkVersionsFn(ctx, rootDir, components...)
 }
 
 func runDeployVM(cmd *cobra.Command, opts deployVMOptions) error {
 	logger := common.NewColorLogger()
 	applyDeployVMConfigDefaults(cmd, &opts)
+	if err := checkRepoVersions(cmd.Context(), versioncheck.Kubernetes, versioncheck.Flatcar); err != nil {
+		return err
+	}
 
 	provider, err := normalizeFlatcarProvider(opts.provider)
 	if err != nil {
@@ -1182,6 +1225,17 @@ func runDeployVM(cmd *cobra.Command, opts deployVMOptions) error {
 	if err := validateDeployVMOptions(provider, opts); err != nil {
 		return err
 	}
+	// Every node deploys as a VM, so hold each VM name to the provider's naming
+	// policy (with a suggested alternative) before rendering anything.
+	if err := validateFlatcarVMNames(provider, opts.nodes); err != nil {
+		return err
+	}
+	// A planned IP that already answers would collide with the VM about to claim it.
+	if !opts.skipIPCheck && NodeIPPreflightFn != nil {
+		if err := NodeIPPreflightFn(cmd.Context(), logger, opts.nodes, opts.expectExisting); err != nil {
+			return err
+		}
+	}
 
 	// Build the provider-neutral node list (render Ignition per node). Node names
 	// are validated against the predefined set so we fail before any mutation.
@@ -1256,9 +1310,9 @@ func validateDeployVMOptions(provider string, opts deployVMOptions) error {
 			return fmt.Errorf("--truenas-pool is required for --provider truenas")
 		}
 		// The boot zvol is per-node; an explicit override only makes sense for a
-		// single node (otherwise pre-stage <pool>/VM/<node>-boot for each node).
+		// single node (otherwise pre-stage <pool>/VM/<vm>-boot for each node).
 		if opts.bootZVol != "" && len(opts.nodes) > 1 {
-			return fmt.Errorf("--boot-zvol cannot be used with multiple --nodes; pre-stage <pool>/VM/<node>-boot per node instead")
+			return fmt.Errorf("--boot-zvol cannot be used with multiple --nodes; pre-stage <pool>/VM/<vm>-boot per node instead")
 		}
 		return nil
 	default: // providerProxmox
@@ -1285,6 +1339,38 @@ func validateDeployVMOptions(provider string, opts deployVMOptions) error {
 	}
 }
 
+// flatcarVMName is the VM name node deploys as on provider. Node names come from
+// cluster.nodes and are dashed (k8s-0), which TrueNAS rejects, so there the
+// naming policy's suggestion (k8s_0) is used; the boot zvol follows that name.
+// Elsewhere the node name is the VM name and must satisfy the policy as is.
+func flatcarVMName(provider, node string) (string, error) {
+	policy, err := vmlifecycle.VMNamePolicyFor(provider)
+	if err != nil {
+		return "", err
+	}
+	if provider == providerTrueNAS && len(policy.Violations(node)) > 0 {
+		if suggested, ok := policy.Suggest(node); ok {
+			return suggested, nil
+		}
+	}
+	if err := policy.Validate(node); err != nil {
+		return "", err
+	}
+	return node, nil
+}
+
+// validateFlatcarVMNames checks the VM name of every node up front, so a
+// multi-node deployment reports all offending names before anything is created.
+func validateFlatcarVMNames(provider string, nodes []string) error {
+	var errs []error
+	for _, node := range nodes {
+		if _, err := flatcarVMName(provider, node); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // buildFlatcarNodes renders the Ignition for each requested node, returning the
 // provider-neutral node list. Node names are validated against the predefined set.
 func buildFlatcarNodes(opts deployVMOptions) ([]flatcarNode, error) {
@@ -1420,8 +1506,12 @@ func deployTrueNAS(cmd *cobra.Command, opts deployVMOptions, logger *common.Colo
 			dst = "<truenas-host>"
 		}
 		for _, n := range nodes {
+			vmName, err := flatcarVMName(providerTrueNAS, n.name)
+			if err != nil {
+				return err
+			}
 			logger.Info("[DRY RUN] would upload %d bytes of Ignition to %s:%s/ignition-%s.json and create Flatcar VM %s on pool %s (Ignition via fw_cfg)",
-				len(n.ignition), dst, ignitionDir, n.name, n.name, opts.truenasPool)
+				len(n.ignition), dst, ignitionDir, n.name, vmName, opts.truenasPool)
 		}
 		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "[DRY RUN] %d Flatcar VM(s) planned\n", len(nodes))
 		return nil
@@ -2,6 +2,7 @@ package flatcar
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"errors"
 	"os"
@@ -18,6 +19,7 @@ import (
 	"homeops-cli/internal/ssh"
 	"homeops-cli/internal/testutil"
 	"homeops-cli/internal/truenas"
+	"homeops-cli/internal/versioncheck"
 	"homeops-cli/internal/vsphere"
 
 	"github.com/spf13/cobra"
@@ -821,6 +823,25 @@ func TestDeployVMVSphereRequiresTemplateAndDatastore(t *testing.T) {
 	assert.Contains(t, err.Error(), "--datastore")
 }
 
+func TestDeployVMStopsOnStrictVersionMismatch(t *testing.T) {
+	var checked []versioncheck.Component
+	testutil.Swap(t, &checkVersionsFn, func(_ context.Context, _ string, components ...versioncheck.Component) error {
+		checked = components
+		return errors.New("1 version mismatch(es) between the local plans and the cluster/environment (--strict-versions)")
+	})
+	testutil.Swap(t, &renderIgnitionFn, func(flatcar.NodeEnv) ([]byte, error) {
+		t.Fatal("Ignition must not render after a strict version mismatch")
+		return nil, nil
+	})
+	cmd := &cobra.Command{}
+	cmd.SetOut(&bytes.Buffer{})
+
+	err := runDeployVM(cmd, deployVMOptions{provider: "proxmox", nodes: []string{"k8s-0"}, dryRun: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--strict-versions")
+	assert.Equal(t, []versioncheck.Component{versioncheck.Kubernetes, versioncheck.Flatcar}, checked)
+}
+
 func TestDeployVMVSphereDryRun(t *testing.T) {
 	defer stubVersions(t)()
 	origIgn := renderIgnitionFn
@@ -936,7 +957,7 @@ func TestTrueNASFlatcarDeployer(t *testing.T) {
 	require.NoError(t, d.DeployNode(flatcarNode{name: "k8s-0", ignition: ign}, handle))
 	require.Len(t, fake.deployed, 1)
 	got := fake.deployed[0]
-	assert.Equal(t, "k8s-0", got.Name)
+	assert.Equal(t, "k8s_0", got.Name, "TrueNAS VM names cannot contain dashes")
 	assert.True(t, got.Flatcar)
 	assert.True(t, got.SkipZVolCreate)
 	assert.Equal(t, handle, got.IgnitionPath) // fw_cfg file= path
@@ -1068,6 +1089,79 @@ func TestDeployVMTrueNASDryRun(t *testing.T) {
 	assert.Contains(t, out.String(), "DRY RUN")
 }
 
+func TestDeployVMRespondingIPBlocksDeploy(t *testing.T) {
+	defer stubVersions(t)()
+	rendered := 0
+	testutil.Swap(t, &renderIgnitionFn, func(flatcar.NodeEnv) ([]byte, error) {
+		rendered++
+		return []byte(`{"ignition":{"version":"3.4.0"}}`), nil
+	})
+	testutil.Swap(t, &getTrueNASCredentialsFn, func() (string, string, error) {
+		t.Fatal("a failed IP check must stop before any provider call")
+		return "", "", nil
+	})
+	var checked []string
+	var expected []bool
+	testutil.Swap(t, &NodeIPPreflightFn, func(_ context.Context, _ *common.ColorLogger, names []string, expectExisting bool) error {
+		checked = append(checked, names...)
+		expected = append(expected, expectExisting)
+		if expectExisting {
+			return nil
+		}
+		return errors.New("planned node IP checks failed:\n  k8s-1 192.168.122.11 ping: address answers ICMP echo")
+	})
+
+	_, err := testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "truenas", "--nodes", "k8s-0,k8s-1", "--truenas-pool", "flashstor")
+	require.ErrorContains(t, err, "k8s-1 192.168.122.11 ping")
+	assert.Equal(t, []string{"k8s-0", "k8s-1"}, checked, "every node is checked up front")
+	assert.Zero(t, rendered, "a responding IP blocks the deploy before any Ignition is rendered")
+
+	checked, expected = nil, nil
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "truenas", "--nodes", "k8s-1", "--truenas-pool", "flashstor", "--expect-existing", "--dry-run")
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true}, expected, "--expect-existing reaches the IP checks")
+	assert.Equal(t, 1, rendered)
+
+	checked = nil
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "truenas", "--nodes", "k8s-1", "--truenas-pool", "flashstor", "--skip-ip-check", "--dry-run")
+	require.NoError(t, err)
+	assert.Empty(t, checked, "--skip-ip-check does not run the checks")
+}
+
+func TestFlatcarVMNameMapsDashedNodesOnTrueNAS(t *testing.T) {
+	defer versionconfig.SetForTesting(&versionconfig.Config{})()
+
+	name, err := flatcarVMName(providerTrueNAS, "k8s-0")
+	require.NoError(t, err)
+	assert.Equal(t, "k8s_0", name, "TrueNAS forbids dashes, so the node name is mapped")
+
+	for _, provider := range []string{providerProxmox, providerVSphere} {
+		name, err := flatcarVMName(provider, "k8s-0")
+		require.NoError(t, err)
+		assert.Equal(t, "k8s-0", name, "%s takes the node name as is", provider)
+	}
+}
+
+func TestDeployVMRejectsNamesOutsideProviderPolicy(t *testing.T) {
+	defer stubVersions(t)()
+	testutil.Swap(t, &renderIgnitionFn, func(flatcar.NodeEnv) ([]byte, error) {
+		t.Fatal("no Ignition may be rendered before every VM name is validated")
+		return nil, nil
+	})
+	defer versionconfig.SetForTesting(&versionconfig.Config{
+		Hypervisors: versionconfig.HypervisorsConfig{
+			Proxmox: versionconfig.ProxmoxConfig{Naming: versionconfig.NamingPolicy{Prefix: "flatcar-"}},
+		},
+	})()
+
+	cmd := &cobra.Command{}
+	cmd.SetOut(&bytes.Buffer{})
+	err := runDeployVM(cmd, deployVMOptions{provider: "proxmox", nodes: []string{"k8s-0", "k8s-1"}, imageVolume: "local-lvm:flatcar", snippetsDir: "/var/lib/vz/snippets", dryRun: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "VM name 'k8s-0' violates the proxmox naming policy")
+	assert.Contains(t, err.Error(), "suggested: 'flatcar-k8s-1'", "every offending node is reported at once")
+}
+
 func TestDeployTrueNASRealPathUsesCredentialUploadAndClientSeams(t *testing.T) {
 	testutil.Swap(t, &getTrueNASCredentialsFn, func() (string, string, error) {
 		return "nas.example.test", "api-key-placeholder", nil
@@ -1102,7 +1196,7 @@ func TestDeployTrueNASRealPathUsesCredentialUploadAndClientSeams(t *testing.T) {
 	assert.Equal(t, `truenas_admin@nas.example.test:22:/mnt/flashstor/VM/ignition-k8s-0.json:{"ignition":{"version":"3.4.0"}}`, uploadedTo)
 	require.Len(t, fake.deployed, 1)
 	got := fake.deployed[0]
-	assert.Equal(t, "k8s-0", got.Name)
+	assert.Equal(t, "k8s_0", got.Name)
 	assert.Equal(t, "flashstor", got.StoragePool)
 	assert.Equal(t, "br-test", got.NetworkBridge)
 	assert.Equal(t, "/mnt/flashstor/VM/ignition-k8s-0.json", got.IgnitionPath)
@@ -0,0 +1,490 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"homeops-cli/internal/cloudflare"
+	"homeops-cli/internal/config"
+	"homeops-cli/internal/kubeutil"
+	"homeops-cli/internal/ui"
+)
+
+const (
+	dnsIngressResource     = "ingresses.networking.k8s.io"
+	dnsEndpointResource    = "dnsendpoints.externaldns.k8s.io"
+	dnsDefaultTXTOwner     = "default"
+	dnsDefaultTXTPrefix    = "k8s."
+	externalDNSHeritage    = "heritage=external-dns"
+	externalDNSOwnerPrefix = "external-dns/owner="
+)
+
+type dnsReportStatus string
+
+const (
+	dnsOK      dnsReportStatus = "OK"
+	dnsMissing dnsReportStatus = "MISSING"
+	dnsStale   dnsReportStatus = "STALE"
+)
+
+// dnsRecordClient is the subset of the Cloudflare client dns-report needs.
+type dnsRecordClient interface {
+	ZoneByName(ctx context.Context, name string) (cloudflare.Zone, error)
+	ListDNSRecords(ctx context.Context, zoneID string) ([]cloudflare.DNSRecord, error)
+	DeleteDNSRecord(ctx context.Context, zoneID, recordID string) error
+}
+
+var (
+	newDNSRecordClientFn = func(token string) dnsRecordClient {
+		return cloudflare.NewClient(token)
+	}
+	resolveCloudflareDNSTokenFn = func() (string, error) {
+		return config.Get().ResolveSecret(config.KeyCloudflareDNSToken)
+	}
+	resolveClusterDomainFn = func() (string, error) {
+		return config.Get().ResolveSecret(config.KeyClusterDomain)
+	}
+)
+
+type dnsIngressList struct {
+	Items []struct {
+		Metadata metadataJSON `json:"metadata"`
+		Spec     struct {
+			Rules []struct {
+				Host string `json:"host"`
+			} `json:"rules"`
+			TLS []struct {
+				Hosts []string `json:"hosts"`
+			} `json:"tls"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+type dnsEndpointList struct {
+	Items []struct {
+		Metadata metadataJSON `json:"metadata"`
+		Spec     struct {
+			Endpoints []struct {
+				DNSName string `json:"dnsName"`
+			} `json:"endpoints"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+// dnsRecordRef identifies one Cloudflare record that --delete-stale removes.
+type dnsRecordRef struct {
+	ZoneID string `json:"zone_id"`
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+}
+
+type dnsReportEntry struct {
+	Status   dnsReportStatus `json:"status"`
+	Hostname string          `json:"hostname"`
+	Zone     string          `json:"zone"`
+	Records  []string        `json:"records,omitempty"`
+	Sources  []string        `json:"sources,omitempty"`
+	// Owned lists the address and ownership-TXT records of a STALE entry.
+	Owned []dnsRecordRef `json:"owned,omitempty"`
+}
+
+type dnsReportSummary struct {
+	OK      int `json:"ok"`
+	Missing int `json:"missing"`
+	Stale   int `json:"stale"`
+}
+
+type dnsReport struct {
+	Zones   []string         `json:"zones"`
+	Owner   string           `json:"owner"`
+	Summary dnsReportSummary `json:"summary"`
+	Entries []dnsReportEntry `json:"entries"`
+	Errors  []string         `json:"errors,omitempty"`
+}
+
+type dnsReportOptions struct {
+	Zones       []string
+	Owner       string
+	TXTPrefix   string
+	Output      string
+	DeleteStale bool
+	FailOnDrift bool
+}
+
+func newDNSReportCommand() *cobra.Command {
+	opts := dnsReportOptions{Owner: dnsDefaultTXTOwner, TXTPrefix: dnsDefaultTXTPrefix, Output: "table"}
+	cmd := &cobra.Command{
+		Use:          "dns-report",
+		Short:        "Reconcile Cloudflare DNS records against cluster HTTPRoute/Ingress hostnames",
+		SilenceUsage: true,
+		Long: `Lists hostnames declared by HTTPRoutes, Ingresses, and external-dns DNSEndpoints,
+reads the DNS records of each configured Cloudflare zone, and classifies them:
+
+  OK       a cluster hostname has a matching A/AAAA/CNAME record
+  MISSING  a cluster hostname in the zone has no record
+  STALE    a record carries an external-dns ownership TXT for this owner but
+           matches no cluster hostname
+
+The API token resolves from the cloudflare_dns_token secret key. Zones default
+to the cluster domain (cluster_domain secret key).`,
+		Example: `  homeops-cli k8s dns-report
+  homeops-cli k8s dns-report --zone example.com --output json
+  homeops-cli k8s dns-report --delete-stale`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := ui.ValidateOutputFormat(opts.Output); err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), kubernetesDefaultCommandTimeout)
+			defer cancel()
+			return runDNSReport(ctx, cmd, opts)
+		},
+	}
+	cmd.Flags().StringSliceVar(&opts.Zones, "zone", nil, "Cloudflare zone name to inspect (repeatable; default: the cluster domain)")
+	cmd.Flags().StringVar(&opts.Owner, "owner", opts.Owner, "external-dns TXT owner id (txtOwnerId) whose records may be reported STALE")
+	cmd.Flags().StringVar(&opts.TXTPrefix, "txt-prefix", opts.TXTPrefix, "external-dns TXT registry prefix (txtPrefix)")
+	cmd.Flags().StringVarP(&opts.Output, "output", "o", opts.Output, "output format: table or json")
+	cmd.Flags().BoolVar(&opts.DeleteStale, "delete-stale", false, "delete STALE records and their ownership TXT records after confirmation")
+	cmd.Flags().BoolVar(&opts.FailOnDrift, "fail-on-findings", false, "return a non-zero exit code when MISSING or STALE entries are present")
+	return cmd
+}
+
+func runDNSReport(ctx context.Context, cmd *cobra.Command, opts dnsReportOptions) error {
+	zones := normalizeDNSZones(opts.Zones)
+	if len(zones) == 0 {
+		domain, err := resolveClusterDomainFn()
+		if err != nil {
+			return fmt.Errorf("no --zone given and the cluster domain could not be resolved: %w", err)
+		}
+		zones = normalizeDNSZones([]string{domain})
+	}
+	token, err := resolveCloudflareDNSTokenFn()
+	if err != nil {
+		return fmt.Errorf("resolve Cloudflare API token: %w", err)
+	}
+	client := newDNSRecordClientFn(token)
+
+	report := buildDNSReport(ctx, client, zones, opts.Owner, opts.TXTPrefix)
+	rendered, err := renderDNSReport(report, opts.Output)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(cmd.OutOrStdout(), rendered)
+
+	if opts.DeleteStale && report.Summary.Stale > 0 {
+		if err := deleteStaleDNSRecords(ctx, cmd, client, report); err != nil {
+			return err
+		}
+	}
+	if opts.FailOnDrift && (report.Summary.Missing > 0 || report.Summary.Stale > 0) {
+		return fmt.Errorf("dns report found %d missing and %d stale record(s)", report.Summary.Missing, report.Summary.Stale)
+	}
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("dns report incomplete: %d error(s)", len(report.Errors))
+	}
+	return nil
+}
+
+func buildDNSReport(ctx context.Context, client dnsRecordClient, zones []string, owner, txtPrefix string) dnsReport {
+	report := dnsReport{Zones: zones, Owner: owner}
+	hosts, errs := collectClusterHostnames(ctx)
+	report.Errors = append(report.Errors, errs...)
+
+	var records []cloudflare.DNSRecord
+	zoneNames := map[string]string{}
+	for _, name := range zones {
+		zone, err := client.ZoneByName(ctx, name)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		zoneNames[zone.ID] = name
+		zoneRecords, err := client.ListDNSRecords(ctx, zone.ID)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		records = append(records, zoneRecords...)
+	}
+
+	report.Entries = compareDNSRecords(hosts, records, zones, owner, txtPrefix)
+	for _, entry := range report.Entries {
+		switch entry.Status {
+		case dnsOK:
+			report.Summary.OK++
+		case dnsMissing:
+			report.Summary.Missing++
+		case dnsStale:
+			report.Summary.Stale++
+		}
+	}
+	return report
+}
+
+// collectClusterHostnames maps every hostname declared in the cluster to the
+// objects declaring it. A failed listing is reported rather than treated as
+// "no hostnames", so records are never misclassified as STALE from a partial
+// view.
+func collectClusterHostnames(ctx context.Context) (map[string][]string, []string) {
+	hosts := map[string][]string{}
+	add := func(host, source string) {
+		host = normalizeDNSName(host)
+		if host == "" {
+			return
+		}
+		for _, existing := range hosts[host] {
+			if existing == source {
+				return
+			}
+		}
+		hosts[host] = append(hosts[host], source)
+	}
+	var errs []string
+
+	var routes netDoctorHTTPRouteList
+	if err := kubeutil.GetJSON(ctx, kubectlOutputCtxFn, "", netDoctorHTTPRouteResource, &routes); err != nil {
+		errs = append(errs, err.Error())
+	}
+	for _, route := range routes.Items {
+		for _, host := range route.Spec.Hostnames {
+			add(host, "httproute/"+namespacedName(route.Metadata.Namespace, route.Metadata.Name))
+		}
+	}
+
+	var ingresses dnsIngressList
+	if err := kubeutil.GetJSON(ctx, kubectlOutputCtxFn, "", dnsIngressResource, &ingresses); err != nil {
+		errs = append(errs, err.Error())
+	}
+	for _, ingress := range ingresses.Items {
+		source := "ingress/" + namespacedName(ingress.Metadata.Namespace, ingress.Metadata.Name)
+		for _, rule := range ingress.Spec.Rules {
+			add(rule.Host, source)
+		}
+		for _, tls := range ingress.Spec.TLS {
+			for _, host := range tls.Hosts {
+				add(host, source)
+			}
+		}
+	}
+
+	var endpoints dnsEndpointList
+	if err := kubeutil.GetJSON(ctx, kubectlOutputCtxFn, "", dnsEndpointResource, &endpoints); err != nil {
+		errs = append(errs, err.Error())
+	}
+	for _, endpoint := range endpoints.Items {
+		for _, ep := range endpoint.Spec.Endpoints {
+			add(ep.DNSName, "dnsendpoint/"+namespacedName(endpoint.Metadata.Namespace, endpoint.Metadata.Name))
+		}
+	}
+	return hosts, errs
+}
+
+// externalDNSOwnedName parses an external-dns TXT registry record and returns
+// the DNS name it claims ownership of. Both the legacy "<prefix><name>" and
+// the typed "<prefix><type>-<name>" layouts are recognised; the owner must
+// match exactly.
+func externalDNSOwnedName(record cloudflare.DNSRecord, owner, txtPrefix string) (string, bool) {
+	if !strings.EqualFold(record.Type, "TXT") {
+		return "", false
+	}
+	content := strings.Trim(strings.TrimSpace(record.Content), `"`)
+	if !strings.Contains(content, externalDNSHeritage) {
+		return "", false
+	}
+	recordOwner := ""
+	for _, field := range strings.Split(content, ",") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(field), externalDNSOwnerPrefix); ok {
+			recordOwner = value
+		}
+	}
+	if recordOwner != owner {
+		return "", false
+	}
+	name := normalizeDNSName(record.Name)
+	prefix := strings.ToLower(txtPrefix)
+	if prefix != "" {
+		trimmed, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			return "", false
+		}
+		name = trimmed
+	}
+	for _, recordType := range []string{"a-", "aaaa-", "cname-", "ns-", "mx-", "srv-", "txt-"} {
+		if trimmed, ok := strings.CutPrefix(name, recordType); ok {
+			return trimmed, true
+		}
+	}
+	return name, true
+}
+
+func isDNSAddressRecord(recordType string) bool {
+	switch strings.ToUpper(recordType) {
+	case "A", "AAAA", "CNAME":
+		return true
+	}
+	return false
+}
+
+// compareDNSRecords performs the three-way OK/MISSING/STALE comparison between
+// cluster hostnames and the zone records.
+func compareDNSRecords(hosts map[string][]string, records []cloudflare.DNSRecord, zones []string, owner, txtPrefix string) []dnsReportEntry {
+	addresses := map[string][]cloudflare.DNSRecord{}
+	ownership := map[string][]cloudflare.DNSRecord{}
+	for _, record := range records {
+		if isDNSAddressRecord(record.Type) {
+			name := normalizeDNSName(record.Name)
+			addresses[name] = append(addresses[name], record)
+			continue
+		}
+		if name, ok := externalDNSOwnedName(record, owner, txtPrefix); ok {
+			ownership[name] = append(ownership[name], record)
+		}
+	}
+
+	var entries []dnsReportEntry
+	for host, sources := range hosts {
+		zone := zoneForDNSName(host, zones)
+		if zone == "" {
+			continue
+		}
+		entry := dnsReportEntry{Status: dnsMissing, Hostname: host, Zone: zone, Sources: append([]string{}, sources...)}
+		sort.Strings(entry.Sources)
+		if matched := addresses[host]; len(matched) > 0 {
+			entry.Status = dnsOK
+			entry.Records = describeDNSRecords(matched)
+		}
+		entries = append(entries, entry)
+	}
+
+	for name, owned := range ownership {
+		if _, declared := hosts[name]; declared {
+			continue
+		}
+		matched := addresses[name]
+		if len(matched) == 0 {
+			continue
+		}
+		entry := dnsReportEntry{Status: dnsStale, Hostname: name, Zone: zoneForDNSName(name, zones), Records: describeDNSRecords(matched)}
+		for _, record := range append(append([]cloudflare.DNSRecord{}, matched...), owned...) {
+			entry.Owned = append(entry.Owned, dnsRecordRef{ZoneID: record.ZoneID, ID: record.ID, Name: record.Name, Type: record.Type})
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Status != entries[j].Status {
+			return dnsStatusOrder(entries[i].Status) < dnsStatusOrder(entries[j].Status)
+		}
+		return entries[i].Hostname < entries[j].Hostname
+	})
+	return entries
+}
+
+func dnsStatusOrder(status dnsReportStatus) int {
+	switch status {
+	case dnsStale:
+		return 0
+	case dnsMissing:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func describeDNSRecords(records []cloudflare.DNSRecord) []string {
+	out := make([]string, 0, len(records))
+	for _, record := range records {
+		out = append(out, fmt.Sprintf("%s %s", strings.ToUpper(record.Type), record.Content))
+	}
+	sort.Strings(out)
+	return out
+}
+
+func normalizeDNSName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
+
+func normalizeDNSZones(zones []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, zone := range zones {
+		zone = normalizeDNSName(zone)
+		if zone == "" || seen[zone] {
+			continue
+		}
+		seen[zone] = true
+		out = append(out, zone)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// zoneForDNSName returns the most specific configured zone containing name.
+func zoneForDNSName(name string, zones []string) string {
+	best := ""
+	for _, zone := range zones {
+		if (name == zone || strings.HasSuffix(name, "."+zone)) && len(zone) > len(best) {
+			best = zone
+		}
+	}
+	return best
+}
+
+func deleteStaleDNSRecords(ctx context.Context, cmd *cobra.Command, client dnsRecordClient, report dnsReport) error {
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("refusing --delete-stale: the report is incomplete (%d error(s)); resolve them and re-run", len(report.Errors))
+	}
+	var refs []dnsRecordRef
+	for _, entry := range report.Entries {
+		if entry.Status == dnsStale {
+			refs = append(refs, entry.Owned...)
+		}
+	}
+	confirmed, err := confirmActionFn(fmt.Sprintf("Delete %d Cloudflare record(s) for %d stale external-dns hostname(s)?", len(refs), report.Summary.Stale), false)
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), "Stale record deletion cancelled")
+		return nil
+	}
+	var failed []string
+	for _, ref := range refs {
+		if err := client.DeleteDNSRecord(ctx, ref.ZoneID, ref.ID); err != nil {
+			failed = append(failed, fmt.Sprintf("%s %s: %v", ref.Type, ref.Name, err))
+			continue
+		}
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Deleted %s %s\n", ref.Type, ref.Name)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to delete %d record(s):\n%s", len(failed), strings.Join(failed, "\n"))
+	}
+	return nil
+}
+
+func renderDNSReport(report dnsReport, output string) (string, error) {
+	if output == "json" {
+		return ui.RenderJSON(report)
+	}
+	var rows [][]string
+	for _, entry := range report.Entries {
+		detail := strings.Join(entry.Records, ", ")
+		if entry.Status == dnsMissing {
+			detail = "no A/AAAA/CNAME record"
+		}
+		source := strings.Join(entry.Sources, ", ")
+		if entry.Status == dnsStale {
+			source = "external-dns owner=" + report.Owner
+		}
+		rows = append(rows, []string{string(entry.Status), entry.Hostname, detail, source})
+	}
+	for _, detail := range report.Errors {
+		rows = append(rows, []string{"ERROR", "-", detail, "-"})
+	}
+	header := fmt.Sprintf("Zones: %s  OK: %d  MISSING: %d  STALE: %d", strings.Join(report.Zones, ", "),
+		report.Summary.OK, report.Summary.Missing, report.Summary.Stale)
+	return header + "\n" + ui.Table([]string{"STATUS", "HOSTNAME", "RECORDS", "SOURCE"}, rows), nil
+}
@@ -0,0 +1,230 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homeops-cli/internal/cloudflare"
+	"homeops-cli/internal/testutil"
+)
+
+type fakeDNSRecordClient struct {
+	zones   map[string]cloudflare.Zone
+	records map[string][]cloudflare.DNSRecord
+	listErr error
+	deleted []string
+}
+
+func (f *fakeDNSRecordClient) ZoneByName(_ context.Context, name string) (cloudflare.Zone, error) {
+	zone, ok := f.zones[name]
+	if !ok {
+		return cloudflare.Zone{}, errors.New("zone " + name + " not found")
+	}
+	return zone, nil
+}
+
+func (f *fakeDNSRecordClient) ListDNSRecords(_ context.Context, zoneID string) ([]cloudflare.DNSRecord, error) {
+	if f.listErr != nil {
+		return nil, f.listErr
+	}
+	return f.records[zoneID], nil
+}
+
+func (f *fakeDNSRecordClient) DeleteDNSRecord(_ context.Context, zoneID, recordID string) error {
+	f.deleted = append(f.deleted, zoneID+"/"+recordID)
+	return nil
+}
+
+func ownershipTXT(id, name, owner string) cloudflare.DNSRecord {
+	return cloudflare.DNSRecord{ID: id, ZoneID: "z1", Name: name, Type: "TXT",
+		Content: `"heritage=external-dns,external-dns/owner=` + owner + `,external-dns/resource=crd/network/kgateway-external"`}
+}
+
+func dnsReportFixture() *fakeDNSRecordClient {
+	return &fakeDNSRecordClient{
+		zones: map[string]cloudflare.Zone{"example.com": {ID: "z1", Name: "example.com"}},
+		records: map[string][]cloudflare.DNSRecord{"z1": {
+			{ID: "r-home", ZoneID: "z1", Name: "home.example.com", Type: "CNAME", Content: "external.example.com"},
+			ownershipTXT("t-home", "k8s.cname-home.example.com", "default"),
+			{ID: "r-old", ZoneID: "z1", Name: "old.example.com", Type: "CNAME", Content: "external.example.com"},
+			ownershipTXT("t-old", "k8s.cname-old.example.com", "default"),
+			{ID: "r-manual", ZoneID: "z1", Name: "manual.example.com", Type: "A", Content: "203.0.113.10"},
+			{ID: "r-other", ZoneID: "z1", Name: "other-owner.example.com", Type: "CNAME", Content: "x.example.com"},
+			ownershipTXT("t-other", "k8s.cname-other-owner.example.com", "staging"),
+		}},
+	}
+}
+
+func stubDNSReportCluster(t *testing.T) {
+	t.Helper()
+	testutil.Swap(t, &kubectlOutputCtxFn, func(_ context.Context, args ...string) ([]byte, error) {
+		switch args[1] {
+		case netDoctorHTTPRouteResource:
+			return []byte(`{"items":[{"metadata":{"namespace":"default","name":"home"},"spec":{"hostnames":["Home.example.com"]}}]}`), nil
+		case dnsIngressResource:
+			return []byte(`{"items":[{"metadata":{"namespace":"media","name":"plex"},"spec":{"rules":[{"host":"plex.example.com"}],"tls":[{"hosts":["plex.example.com"]}]}}]}`), nil
+		case dnsEndpointResource:
+			return []byte(`{"items":[{"metadata":{"namespace":"network","name":"ext"},"spec":{"endpoints":[{"dnsName":"home.example.com"},{"dnsName":"elsewhere.test"}]}}]}`), nil
+		default:
+			return []byte(`{"items":[]}`), nil
+		}
+	})
+}
+
+func TestExternalDNSOwnedName(t *testing.T) {
+	tests := []struct {
+		name   string
+		record cloudflare.DNSRecord
+		want   string
+		ok     bool
+	}{
+		{name: "typed prefix", record: ownershipTXT("1", "k8s.cname-app.example.com", "default"), want: "app.example.com", ok: true},
+		{name: "legacy prefix", record: ownershipTXT("1", "k8s.app.example.com", "default"), want: "app.example.com", ok: true},
+		{name: "other owner", record: ownershipTXT("1", "k8s.cname-app.example.com", "staging")},
+		{name: "missing prefix", record: ownershipTXT("1", "cname-app.example.com", "default")},
+		{name: "owner is not a substring match", record: ownershipTXT("1", "k8s.a-app.example.com", "default-2")},
+		{name: "not external-dns", record: cloudflare.DNSRecord{Type: "TXT", Name: "k8s.app.example.com", Content: "v=spf1 -all"}},
+		{name: "not txt", record: cloudflare.DNSRecord{Type: "CNAME", Name: "k8s.app.example.com", Content: "heritage=external-dns,external-dns/owner=default"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := externalDNSOwnedName(tt.record, "default", "k8s.")
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCompareDNSRecordsClassifiesOKMissingStale(t *testing.T) {
+	hosts := map[string][]string{
+		"home.example.com": {"httproute/default/home"},
+		"plex.example.com": {"ingress/media/plex"},
+		"elsewhere.test":   {"dnsendpoint/network/ext"},
+	}
+	entries := compareDNSRecords(hosts, dnsReportFixture().records["z1"], []string{"example.com"}, "default", "k8s.")
+
+	byHost := map[string]dnsReportEntry{}
+	for _, entry := range entries {
+		byHost[entry.Hostname] = entry
+	}
+	require.Len(t, entries, 3, "hosts outside the zone and unowned records are not reported")
+	assert.Equal(t, dnsStale, entries[0].Status, "stale entries sort first")
+	assert.Equal(t, dnsOK, byHost["home.example.com"].Status)
+	assert.Equal(t, []string{"CNAME external.example.com"}, byHost["home.example.com"].Records)
+	assert.Equal(t, dnsMissing, byHost["plex.example.com"].Status)
+	assert.Equal(t, dnsStale, byHost["old.example.com"].Status)
+	assert.ElementsMatch(t, []string{"r-old", "t-old"}, []string{byHost["old.example.com"].Owned[0].ID, byHost["old.example.com"].Owned[1].ID})
+	assert.NotContains(t, byHost, "manual.example.com")
+	assert.NotContains(t, byHost, "other-owner.example.com")
+}
+
+func TestZoneForDNSNamePrefersMostSpecificZone(t *testing.T) {
+	zones := []string{"example.com", "lab.example.com"}
+	assert.Equal(t, "lab.example.com", zoneForDNSName("nas.lab.example.com", zones))
+	assert.Equal(t, "example.com", zoneForDNSName("example.com", zones))
+	assert.Empty(t, zoneForDNSName("badexample.com", zones))
+}
+
+func TestBuildDNSReportFromClusterAndZone(t *testing.T) {
+	stubDNSReportCluster(t)
+
+	report := buildDNSReport(context.Background(), dnsReportFixture(), []string{"example.com"}, "default", "k8s.")
+	assert.Empty(t, report.Errors)
+	assert.Equal(t, dnsReportSummary{OK: 1, Missing: 1, Stale: 1}, report.Summary)
+	for _, entry := range report.Entries {
+		if entry.Hostname == "home.example.com" {
+			assert.Equal(t, []string{"dnsendpoint/network/ext", "httproute/default/home"}, entry.Sources)
+		}
+	}
+}
+
+func TestBuildDNSReportRecordsListingErrors(t *testing.T) {
+	stubDNSReportCluster(t)
+	client := dnsReportFixture()
+	client.listErr = errors.New("HTTP 403: 9109 Invalid access token")
+
+	report := buildDNSReport(context.Background(), client, []string{"example.com", "missing.test"}, "default", "k8s.")
+	require.Len(t, report.Errors, 2)
+	assert.Zero(t, report.Summary.Stale)
+}
+
+func runDNSReportForTest(t *testing.T, client *fakeDNSRecordClient, args ...string) (string, error) {
+	t.Helper()
+	testutil.Swap(t, &newDNSRecordClientFn, func(string) dnsRecordClient { return client })
+	testutil.Swap(t, &resolveCloudflareDNSTokenFn, func() (string, error) { return "token", nil })
+	testutil.Swap(t, &resolveClusterDomainFn, func() (string, error) { return "example.com", nil })
+	cmd := newDNSReportCommand()
+	cmd.SetContext(context.Background())
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestDNSReportDeleteStaleDeletesOnlyOwnedRecordsAfterConfirm(t *testing.T) {
+	stubDNSReportCluster(t)
+	client := dnsReportFixture()
+	var prompt string
+	testutil.Swap(t, &confirmActionFn, func(message string, _ bool) (bool, error) {
+		prompt = message
+		return true, nil
+	})
+
+	out, err := runDNSReportForTest(t, client, "--delete-stale")
+	require.NoError(t, err)
+	assert.Contains(t, prompt, "Delete 2 Cloudflare record(s) for 1 stale")
+	assert.ElementsMatch(t, []string{"z1/r-old", "z1/t-old"}, client.deleted)
+	assert.Contains(t, out, "STALE")
+	assert.Contains(t, out, "old.example.com")
+}
+
+func TestDNSReportDeleteStaleCancelled(t *testing.T) {
+	stubDNSReportCluster(t)
+	client := dnsReportFixture()
+	testutil.Swap(t, &confirmActionFn, func(string, bool) (bool, error) { return false, nil })
+
+	out, err := runDNSReportForTest(t, client, "--delete-stale")
+	require.NoError(t, err)
+	assert.Empty(t, client.deleted)
+	assert.Contains(t, out, "cancelled")
+}
+
+func TestDNSReportDeleteStaleRefusesIncompleteReport(t *testing.T) {
+	stubDNSReportCluster(t)
+	client := dnsReportFixture()
+	testutil.Swap(t, &confirmActionFn, func(string, bool) (bool, error) {
+		t.Fatal("confirmation must not be requested for an incomplete report")
+		return false, nil
+	})
+
+	_, err := runDNSReportForTest(t, client, "--delete-stale", "--zone", "example.com", "--zone", "missing.test")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "refusing --delete-stale")
+	assert.Empty(t, client.deleted)
+}
+
+func TestDNSReportFailOnFindingsAndJSON(t *testing.T) {
+	stubDNSReportCluster(t)
+
+	out, err := runDNSReportForTest(t, dnsReportFixture(), "--output", "json", "--fail-on-findings")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 missing and 1 stale")
+	var report dnsReport
+	decodeNetDoctorJSON(t, out, &report)
+	assert.Equal(t, []string{"example.com"}, report.Zones)
+	assert.Equal(t, 1, report.Summary.OK)
+}
+
+func TestDNSReportCommandOutputValidation(t *testing.T) {
+	cmd := newDNSReportCommand()
+	cmd.SetArgs([]string{"--output", "yaml"})
+	cmd.SetOut(&bytes.Buffer{})
+	err := cmd.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported output")
+}
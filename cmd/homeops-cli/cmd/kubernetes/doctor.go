@@ -180,6 +180,7 @@ type metadataJSON struct {
 	Namespace         string            `json:"namespace"`
 	CreationTimestamp string            `json:"creationTimestamp"`
 	Labels            map[string]string `json:"labels"`
+	Annotations       map[string]string `json:"annotations"`
 }
 
 type conditionJSON struct {
@@ -241,7 +242,16 @@ func (r *doctorReport) addFluxResource(ctx context.Context, namespace, kind, res
 	}
 	for _, item := range list.Items {
 		if item.Spec.Suspend {
-			r.add(doctorGroupFlux, kind, item.Metadata.Namespace, item.Metadata.Name, statusWarn, "suspended")
+			info := fluxSuspensionInfoFor(true, item.Metadata.Annotations)
+			switch label := info.label(); {
+			case info.SuspendExpired:
+				r.add(doctorGroupFlux, kind, item.Metadata.Namespace, item.Metadata.Name, statusFail,
+					"suspension "+label+" (lift with k8s flux-resume --expired-only)")
+			case label != "":
+				r.add(doctorGroupFlux, kind, item.Metadata.Namespace, item.Metadata.Name, statusWarn, "suspended "+label)
+			default:
+				r.add(doctorGroupFlux, kind, item.Metadata.Namespace, item.Metadata.Name, statusWarn, "suspended")
+			}
 			continue
 		}
 		ready, ok := readyCondition(item.Status.Conditions)
@@ -0,0 +1,646 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"homeops-cli/cmd/completion"
+	"homeops-cli/internal/kubeutil"
+	"homeops-cli/internal/ui"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+type drainVerdict string
+
+const (
+	drainSafe     drainVerdict = "safe to evict"
+	drainDowntime drainVerdict = "will cause downtime"
+	drainBlocked  drainVerdict = "eviction will be blocked"
+)
+
+// drainLocalPVNodeKeys are the node-affinity keys that pin a PV to one node:
+// local and hostPath PVs (OpenEBS hostpath) use the hostname label, the
+// OpenEBS LVM/ZFS CSI drivers their own topology key.
+var drainLocalPVNodeKeys = map[string]bool{
+	"kubernetes.io/hostname": true,
+	"openebs.io/nodename":    true,
+}
+
+type drainNodeList struct {
+	Items []struct {
+		Metadata metadataJSON `json:"metadata"`
+		Status   struct {
+			Addresses []struct {
+				Type    string `json:"type"`
+				Address string `json:"address"`
+			} `json:"addresses"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+type drainPod struct {
+	Metadata struct {
+		Name              string                    `json:"name"`
+		Namespace         string                    `json:"namespace"`
+		Labels            map[string]string         `json:"labels"`
+		OwnerReferences   []kubeutil.OwnerReference `json:"ownerReferences"`
+		DeletionTimestamp string                    `json:"deletionTimestamp"`
+	} `json:"metadata"`
+	Spec struct {
+		NodeName string `json:"nodeName"`
+		Volumes  []struct {
+			PersistentVolumeClaim *struct {
+				ClaimName string `json:"claimName"`
+			} `json:"persistentVolumeClaim"`
+		} `json:"volumes"`
+	} `json:"spec"`
+	Status struct {
+		Phase      string          `json:"phase"`
+		Conditions []conditionJSON `json:"conditions"`
+	} `json:"status"`
+}
+
+type drainPodList struct {
+	Items []drainPod `json:"items"`
+}
+
+type drainWorkloadList struct {
+	Items []struct {
+		Kind     string       `json:"kind"`
+		Metadata metadataJSON `json:"metadata"`
+		Spec     struct {
+			Replicas *int `json:"replicas"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+type drainPDBList struct {
+	Items []struct {
+		Metadata metadataJSON `json:"metadata"`
+		Spec     struct {
+			Selector       *metav1.LabelSelector `json:"selector"`
+			MinAvailable   *intstr.IntOrString   `json:"minAvailable"`
+			MaxUnavailable *intstr.IntOrString   `json:"maxUnavailable"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+type drainPVList struct {
+	Items []struct {
+		Metadata metadataJSON `json:"metadata"`
+		Spec     struct {
+			StorageClassName string `json:"storageClassName"`
+			ClaimRef         *struct {
+				Namespace string `json:"namespace"`
+				Name      string `json:"name"`
+			} `json:"claimRef"`
+			NodeAffinity *struct {
+				Required *struct {
+					NodeSelectorTerms []struct {
+						MatchExpressions []struct {
+							Key      string   `json:"key"`
+							Operator string   `json:"operator"`
+							Values   []string `json:"values"`
+						} `json:"matchExpressions"`
+					} `json:"nodeSelectorTerms"`
+				} `json:"required"`
+			} `json:"nodeAffinity"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+// drainCluster is the cluster state drain-impact evaluates.
+type drainCluster struct {
+	Nodes     drainNodeList
+	Pods      drainPodList
+	Workloads drainWorkloadList
+	PDBs      drainPDBList
+	PVs       drainPVList
+}
+
+// drainPDBImpact is one PodDisruptionBudget covering a workload, with the
+// budget the disruption controller would compute from the observed pods.
+type drainPDBImpact struct {
+	Name           string `json:"name"`
+	Expected       int    `json:"expected"`
+	Healthy        int    `json:"healthy"`
+	DesiredHealthy int    `json:"desired_healthy"`
+	Allowed        int    `json:"disruptions_allowed"`
+	OnNode         int    `json:"healthy_on_node"`
+	Violated       bool   `json:"violated"`
+}
+
+type drainWorkloadImpact struct {
+	Namespace      string           `json:"namespace"`
+	Workload       string           `json:"workload"`
+	Replicas       int              `json:"replicas"`
+	OnNode         int              `json:"on_node"`
+	ReadyElsewhere int              `json:"ready_elsewhere"`
+	PDBs           []drainPDBImpact `json:"pdbs,omitempty"`
+	LocalPVs       []string         `json:"local_pvs,omitempty"`
+	Verdict        drainVerdict     `json:"verdict"`
+	Notes          []string         `json:"notes,omitempty"`
+}
+
+type drainImpactReport struct {
+	Node          string                `json:"node"`
+	Workloads     []drainWorkloadImpact `json:"workloads"`
+	Safe          int                   `json:"safe"`
+	Downtime      int                   `json:"downtime"`
+	Blocked       int                   `json:"blocked"`
+	DaemonSetPods int                   `json:"daemonset_pods"`
+	Errors        []string              `json:"errors,omitempty"`
+}
+
+type drainAuditFinding struct {
+	Namespace string   `json:"namespace"`
+	Workload  string   `json:"workload"`
+	Replicas  int      `json:"replicas"`
+	Issues    []string `json:"issues"`
+}
+
+type drainAuditReport struct {
+	SingleReplica int                 `json:"single_replica"`
+	WithoutPDB    int                 `json:"without_pdb"`
+	Findings      []drainAuditFinding `json:"findings"`
+}
+
+func newDrainImpactCommand() *cobra.Command {
+	var (
+		node   string
+		audit  bool
+		output string
+	)
+	cmd := &cobra.Command{
+		Use:   "drain-impact",
+		Short: "Show what draining a node would disrupt, or audit workloads for drain risk",
+		Long: `Group the pods on --node by owning workload and report, per workload, its
+replicas, how many are ready on other nodes, the PodDisruptionBudgets that
+cover it and whether evicting its pods would exceed them, and any local PVs
+(OpenEBS hostpath, LVM, or ZFS) pinned to the node that keep its pods from
+rescheduling elsewhere. Each workload gets a verdict:
+
+  safe to evict             other replicas keep serving
+  will cause downtime       no ready replica is left on another node
+  eviction will be blocked  a PDB allows no disruption right now
+
+PDB budgets are computed like the disruption controller does, from the pods
+currently observed. DaemonSet and static pods are not evicted by a drain and
+are only counted. --node accepts a node name or its InternalIP. Exits non-zero
+when any workload would see downtime or block the drain.
+
+--audit checks the whole cluster instead and lists Deployments and
+StatefulSets running a single replica, without any PDB, or with every ready
+replica on one node.`,
+		SilenceUsage: true,
+		Example: `  homeops-cli k8s drain-impact --node k8s-1
+  homeops-cli k8s drain-impact --node 192.168.122.11 --output json
+  homeops-cli k8s drain-impact --audit`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := ui.ValidateOutputFormat(output); err != nil {
+				return err
+			}
+			if audit == (node != "") {
+				return fmt.Errorf("pass exactly one of --node or --audit")
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), kubernetesDefaultCommandTimeout)
+			defer cancel()
+			cluster, err := loadDrainCluster(ctx)
+			if err != nil {
+				return err
+			}
+			if audit {
+				rendered, err := renderDrainAudit(auditDrainRisk(cluster), output)
+				if err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintln(cmd.OutOrStdout(), rendered)
+				return nil
+			}
+			report, err := evaluateDrainImpact(cluster, node)
+			if err != nil {
+				return err
+			}
+			rendered, err := renderDrainImpact(report, output)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), rendered)
+			if report.Downtime+report.Blocked > 0 {
+				return fmt.Errorf("draining %s: %d workload(s) will cause downtime, %d will block eviction", report.Node, report.Downtime, report.Blocked)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&node, "node", "", "node name or InternalIP to evaluate")
+	cmd.Flags().BoolVar(&audit, "audit", false, "audit every workload for single replicas and missing PDBs")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table or json")
+	_ = cmd.RegisterFlagCompletionFunc("node", completion.ValidNodeNames)
+	return cmd
+}
+
+// DrainImpactRisks describes every workload on node (a name or InternalIP)
+// whose eviction would cause downtime or be blocked by a PDB. An empty result
+// means the node can be drained without disruption.
+func DrainImpactRisks(ctx context.Context, node string) ([]string, error) {
+	cluster, err := loadDrainCluster(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report, err := evaluateDrainImpact(cluster, node)
+	if err != nil {
+		return nil, err
+	}
+	var risks []string
+	for _, workload := range report.Workloads {
+		if workload.Verdict == drainSafe {
+			continue
+		}
+		risk := fmt.Sprintf("%s: %s", namespacedName(workload.Namespace, workload.Workload), workload.Verdict)
+		if len(workload.Notes) > 0 {
+			risk += " (" + strings.Join(workload.Notes, "; ") + ")"
+		}
+		risks = append(risks, risk)
+	}
+	return risks, nil
+}
+
+func loadDrainCluster(ctx context.Context) (drainCluster, error) {
+	var cluster drainCluster
+	if err := kubeutil.GetClusterJSON(ctx, kubectlOutputCtxFn, "nodes", &cluster.Nodes); err != nil {
+		return cluster, err
+	}
+	if err := kubeutil.GetJSON(ctx, kubectlOutputCtxFn, "", "pods", &cluster.Pods); err != nil {
+		return cluster, err
+	}
+	if err := kubeutil.GetJSON(ctx, kubectlOutputCtxFn, "", "deployments.apps,statefulsets.apps", &cluster.Workloads); err != nil {
+		return cluster, err
+	}
+	if err := kubeutil.GetJSON(ctx, kubectlOutputCtxFn, "", "poddisruptionbudgets.policy", &cluster.PDBs); err != nil {
+		return cluster, err
+	}
+	if err := kubeutil.GetClusterJSON(ctx, kubectlOutputCtxFn, "persistentvolumes", &cluster.PVs); err != nil {
+		return cluster, err
+	}
+	return cluster, nil
+}
+
+// resolveDrainNode maps a node name or InternalIP to the node name.
+func resolveDrainNode(nodes drainNodeList, node string) (string, error) {
+	for _, item := range nodes.Items {
+		if item.Metadata.Name == node {
+			return node, nil
+		}
+		for _, address := range item.Status.Addresses {
+			if address.Type == "InternalIP" && address.Address == node {
+				return item.Metadata.Name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("node %q not found by name or InternalIP", node)
+}
+
+// podWorkload names the controller that owns a pod, collapsing a
+// Deployment's ReplicaSet via the pod-template-hash label.
+func podWorkload(name string, labels map[string]string, owners []kubeutil.OwnerReference) string {
+	for _, owner := range owners {
+		if owner.Kind == "ReplicaSet" {
+			if hash := labels["pod-template-hash"]; hash != "" && strings.HasSuffix(owner.Name, "-"+hash) {
+				return "Deployment/" + strings.TrimSuffix(owner.Name, "-"+hash)
+			}
+		}
+		return owner.Kind + "/" + owner.Name
+	}
+	return "Pod/" + name
+}
+
+func drainPodActive(pod drainPod) bool {
+	return pod.Metadata.DeletionTimestamp == "" && pod.Status.Phase != "Succeeded" && pod.Status.Phase != "Failed"
+}
+
+func drainPodReady(pod drainPod) bool {
+	if !drainPodActive(pod) {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == "Ready" {
+			return condition.Status == "True"
+		}
+	}
+	return false
+}
+
+// drainWorkloadReplicas maps "namespace/Kind/name" to spec.replicas for the
+// Deployments and StatefulSets in the cluster.
+func drainWorkloadReplicas(workloads drainWorkloadList) map[string]int {
+	replicas := make(map[string]int, len(workloads.Items))
+	for _, item := range workloads.Items {
+		count := 1 // the API server default
+		if item.Spec.Replicas != nil {
+			count = *item.Spec.Replicas
+		}
+		replicas[item.Metadata.Namespace+"/"+item.Kind+"/"+item.Metadata.Name] = count
+	}
+	return replicas
+}
+
+// drainPDBBudget computes a PDB's budget over the active pods it selects,
+// following the disruption controller: an integer minAvailable counts the
+// selected pods as expected, a percentage or maxUnavailable uses the owning
+// workloads' replicas. Percentages round up.
+func drainPDBBudget(minAvailable, maxUnavailable *intstr.IntOrString, selected []drainPod, replicas map[string]int) (expected, healthy, desired int, err error) {
+	owners := map[string]bool{}
+	for _, pod := range selected {
+		if drainPodReady(pod) {
+			healthy++
+		}
+		key := pod.Metadata.Namespace + "/" + podWorkload(pod.Metadata.Name, pod.Metadata.Labels, pod.Metadata.OwnerReferences)
+		if count, ok := replicas[key]; ok && !owners[key] {
+			owners[key] = true
+			expected += count
+		}
+	}
+	if len(owners) == 0 || (minAvailable != nil && minAvailable.Type == intstr.Int) {
+		expected = len(selected)
+	}
+	switch {
+	case maxUnavailable != nil:
+		unavailable, err := intstr.GetScaledValueFromIntOrPercent(maxUnavailable, expected, true)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		desired = max(expected-unavailable, 0)
+	case minAvailable != nil:
+		desired, err = intstr.GetScaledValueFromIntOrPercent(minAvailable, expected, true)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+	}
+	return expected, healthy, desired, nil
+}
+
+// drainLocalPVs maps "namespace/claim" to the PVs pinned to node.
+func drainLocalPVs(pvs drainPVList, node string) map[string]string {
+	pinned := map[string]string{}
+	for _, pv := range pvs.Items {
+		if pv.Spec.ClaimRef == nil || pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil {
+			continue
+		}
+		for _, term := range pv.Spec.NodeAffinity.Required.NodeSelectorTerms {
+			for _, expression := range term.MatchExpressions {
+				if drainLocalPVNodeKeys[expression.Key] && expression.Operator == "In" && slices.Contains(expression.Values, node) {
+					label := pv.Metadata.Name
+					if pv.Spec.StorageClassName != "" {
+						label += " (" + pv.Spec.StorageClassName + ")"
+					}
+					pinned[pv.Spec.ClaimRef.Namespace+"/"+pv.Spec.ClaimRef.Name] = label
+				}
+			}
+		}
+	}
+	return pinned
+}
+
+func evaluateDrainImpact(cluster drainCluster, node string) (drainImpactReport, error) {
+	name, err := resolveDrainNode(cluster.Nodes, node)
+	if err != nil {
+		return drainImpactReport{}, err
+	}
+	report := drainImpactReport{Node: name}
+	replicas := drainWorkloadReplicas(cluster.Workloads)
+	localPVs := drainLocalPVs(cluster.PVs, name)
+
+	type workloadPods struct {
+		namespace, workload string
+		pods                []drainPod
+	}
+	byWorkload := map[string]*workloadPods{}
+	for _, pod := range cluster.Pods.Items {
+		if !drainPodActive(pod) {
+			continue
+		}
+		workload := podWorkload(pod.Metadata.Name, pod.Metadata.Labels, pod.Metadata.OwnerReferences)
+		key := pod.Metadata.Namespace + "/" + workload
+		if byWorkload[key] == nil {
+			byWorkload[key] = &workloadPods{namespace: pod.Metadata.Namespace, workload: workload}
+		}
+		byWorkload[key].pods = append(byWorkload[key].pods, pod)
+	}
+
+	for key, group := range byWorkload {
+		var onNode []drainPod
+		readyElsewhere := 0
+		for _, pod := range group.pods {
+			if pod.Spec.NodeName == name {
+				onNode = append(onNode, pod)
+			} else if drainPodReady(pod) {
+				readyElsewhere++
+			}
+		}
+		if len(onNode) == 0 {
+			continue
+		}
+		kind, _, _ := strings.Cut(group.workload, "/")
+		if kind == "DaemonSet" || kind == "Node" {
+			report.DaemonSetPods += len(onNode)
+			continue
+		}
+		impact := drainWorkloadImpact{
+			Namespace: group.namespace, Workload: group.workload, OnNode: len(onNode), ReadyElsewhere: readyElsewhere,
+			Replicas: len(group.pods), Verdict: drainSafe,
+		}
+		if count, ok := replicas[key]; ok {
+			impact.Replicas = count
+		}
+
+		for _, pdb := range cluster.PDBs.Items {
+			if pdb.Metadata.Namespace != group.namespace || pdb.Spec.Selector == nil {
+				continue
+			}
+			selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("pdb %s: %v", namespacedName(pdb.Metadata.Namespace, pdb.Metadata.Name), err))
+				continue
+			}
+			if !slices.ContainsFunc(onNode, func(pod drainPod) bool { return selector.Matches(labels.Set(pod.Metadata.Labels)) }) {
+				continue
+			}
+			var selected []drainPod
+			healthyOnNode := 0
+			for _, pod := range cluster.Pods.Items {
+				if pod.Metadata.Namespace != group.namespace || !drainPodActive(pod) || !selector.Matches(labels.Set(pod.Metadata.Labels)) {
+					continue
+				}
+				selected = append(selected, pod)
+				if pod.Spec.NodeName == name && drainPodReady(pod) {
+					healthyOnNode++
+				}
+			}
+			expected, healthy, desired, err := drainPDBBudget(pdb.Spec.MinAvailable, pdb.Spec.MaxUnavailable, selected, replicas)
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("pdb %s: %v", namespacedName(pdb.Metadata.Namespace, pdb.Metadata.Name), err))
+				continue
+			}
+			budget := drainPDBImpact{
+				Name: pdb.Metadata.Name, Expected: expected, Healthy: healthy, DesiredHealthy: desired,
+				Allowed: max(healthy-desired, 0), OnNode: healthyOnNode,
+			}
+			budget.Violated = budget.OnNode > budget.Allowed
+			impact.PDBs = append(impact.PDBs, budget)
+			switch {
+			case budget.Allowed == 0:
+				impact.Verdict = drainBlocked
+				impact.Notes = append(impact.Notes, fmt.Sprintf("PDB %s allows no disruption (%d healthy, %d required)", budget.Name, budget.Healthy, budget.DesiredHealthy))
+			case budget.Violated:
+				impact.Notes = append(impact.Notes, fmt.Sprintf("PDB %s allows %d of %d at a time; the drain waits for replacements", budget.Name, budget.Allowed, budget.OnNode))
+			}
+		}
+
+		for _, pod := range onNode {
+			for _, volume := range pod.Spec.Volumes {
+				if volume.PersistentVolumeClaim == nil {
+					continue
+				}
+				if pv, ok := localPVs[group.namespace+"/"+volume.PersistentVolumeClaim.ClaimName]; ok && !slices.Contains(impact.LocalPVs, pv) {
+					impact.LocalPVs = append(impact.LocalPVs, pv)
+				}
+			}
+		}
+		if len(impact.LocalPVs) > 0 {
+			impact.Notes = append(impact.Notes, "local PV pins its pods to the node; they stay Pending until it returns")
+		}
+
+		if impact.Verdict != drainBlocked {
+			switch {
+			case kind == "Pod":
+				impact.Verdict = drainDowntime
+				impact.Notes = append(impact.Notes, "unmanaged pod is deleted by a forced drain and not recreated")
+			case readyElsewhere == 0:
+				impact.Verdict = drainDowntime
+			}
+		}
+		switch impact.Verdict {
+		case drainBlocked:
+			report.Blocked++
+		case drainDowntime:
+			report.Downtime++
+		default:
+			report.Safe++
+		}
+		report.Workloads = append(report.Workloads, impact)
+	}
+
+	rank := map[drainVerdict]int{drainBlocked: 0, drainDowntime: 1, drainSafe: 2}
+	sort.Slice(report.Workloads, func(i, j int) bool {
+		a, b := report.Workloads[i], report.Workloads[j]
+		if rank[a.Verdict] != rank[b.Verdict] {
+			return rank[a.Verdict] < rank[b.Verdict]
+		}
+		return namespacedName(a.Namespace, a.Workload) < namespacedName(b.Namespace, b.Workload)
+	})
+	return report, nil
+}
+
+// auditDrainRisk lists the Deployments and StatefulSets that a drain of any
+// node could take down: single replicas, no PDB, or all ready replicas on one
+// node. Workloads scaled to zero are skipped.
+func auditDrainRisk(cluster drainCluster) drainAuditReport {
+	var report drainAuditReport
+	podsByWorkload := map[string][]drainPod{}
+	for _, pod := range cluster.Pods.Items {
+		if drainPodActive(pod) {
+			key := pod.Metadata.Namespace + "/" + podWorkload(pod.Metadata.Name, pod.Metadata.Labels, pod.Metadata.OwnerReferences)
+			podsByWorkload[key] = append(podsByWorkload[key], pod)
+		}
+	}
+	for key, replicas := range drainWorkloadReplicas(cluster.Workloads) {
+		if replicas == 0 {
+			continue
+		}
+		namespace, workload, _ := strings.Cut(key, "/")
+		pods := podsByWorkload[key]
+		finding := drainAuditFinding{Namespace: namespace, Workload: workload, Replicas: replicas}
+		if replicas == 1 {
+			finding.Issues = append(finding.Issues, "single replica")
+			report.SingleReplica++
+		}
+		covered := false
+		for _, pdb := range cluster.PDBs.Items {
+			if pdb.Metadata.Namespace != namespace || pdb.Spec.Selector == nil {
+				continue
+			}
+			selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+			if err != nil {
+				continue
+			}
+			if slices.ContainsFunc(pods, func(pod drainPod) bool { return selector.Matches(labels.Set(pod.Metadata.Labels)) }) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			finding.Issues = append(finding.Issues, "no PDB")
+			report.WithoutPDB++
+		}
+		if replicas > 1 {
+			nodes := map[string]bool{}
+			for _, pod := range pods {
+				if drainPodReady(pod) {
+					nodes[pod.Spec.NodeName] = true
+				}
+			}
+			if len(nodes) == 1 {
+				for node := range nodes {
+					finding.Issues = append(finding.Issues, "all ready replicas on "+node)
+				}
+			}
+		}
+		if len(finding.Issues) > 0 {
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+	sort.Slice(report.Findings, func(i, j int) bool {
+		return namespacedName(report.Findings[i].Namespace, report.Findings[i].Workload) < namespacedName(report.Findings[j].Namespace, report.Findings[j].Workload)
+	})
+	return report
+}
+
+func renderDrainImpact(report drainImpactReport, output string) (string, error) {
+	if output == "json" {
+		return ui.RenderJSON(report)
+	}
+	var rows [][]string
+	for _, workload := range report.Workloads {
+		pdbs := make([]string, 0, len(workload.PDBs))
+		for _, pdb := range workload.PDBs {
+			pdbs = append(pdbs, fmt.Sprintf("%s (%d allowed)", pdb.Name, pdb.Allowed))
+		}
+		rows = append(rows, []string{string(workload.Verdict), namespacedName(workload.Namespace, workload.Workload),
+			fmt.Sprintf("%d", workload.Replicas), fmt.Sprintf("%d", workload.OnNode), fmt.Sprintf("%d", workload.ReadyElsewhere),
+			valueOrDash(strings.Join(pdbs, ", ")), valueOrDash(strings.Join(workload.LocalPVs, ", ")), valueOrDash(strings.Join(workload.Notes, "; "))})
+	}
+	summary := fmt.Sprintf("Draining %s: %d safe, %d will cause downtime, %d blocked (%d DaemonSet/static pod(s) stay)",
+		report.Node, report.Safe, report.Downtime, report.Blocked, report.DaemonSetPods)
+	for _, problem := range report.Errors {
+		summary += "\nWARN " + problem
+	}
+	return summary + "\n" + ui.Table([]string{"VERDICT", "WORKLOAD", "REPLICAS", "ON NODE", "READY ELSEWHERE", "PDB", "LOCAL PV", "DETAIL"}, rows), nil
+}
+
+func renderDrainAudit(report drainAuditReport, output string) (string, error) {
+	if output == "json" {
+		return ui.RenderJSON(report)
+	}
+	var rows [][]string
+	for _, finding := range report.Findings {
+		rows = append(rows, []string{namespacedName(finding.Namespace, finding.Workload), fmt.Sprintf("%d", finding.Replicas), strings.Join(finding.Issues, ", ")})
+	}
+	summary := fmt.Sprintf("Drain risk: %d single-replica workload(s), %d without a PDB", report.SingleReplica, report.WithoutPDB)
+	return summary + "\n" + ui.Table([]string{"WORKLOAD", "REPLICAS", "ISSUES"}, rows), nil
+}
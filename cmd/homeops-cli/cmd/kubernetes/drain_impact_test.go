@@ -0,0 +1,253 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homeops-cli/internal/kubeutil"
+	"homeops-cli/internal/testutil"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	drainTestNodes = `{"items":[
+  {"metadata":{"name":"k8s-0"},"status":{"addresses":[{"type":"InternalIP","address":"192.168.122.10"}]}},
+  {"metadata":{"name":"k8s-1"},"status":{"addresses":[{"type":"InternalIP","address":"192.168.122.11"},{"type":"Hostname","address":"k8s-1"}]}},
+  {"metadata":{"name":"k8s-2"},"status":{"addresses":[{"type":"InternalIP","address":"192.168.122.12"}]}}]}`
+
+	drainTestWorkloads = `{"items":[
+  {"kind":"Deployment","metadata":{"namespace":"media","name":"radarr"},"spec":{"replicas":1}},
+  {"kind":"Deployment","metadata":{"namespace":"default","name":"echo"},"spec":{"replicas":2}},
+  {"kind":"Deployment","metadata":{"namespace":"network","name":"gateway"},"spec":{"replicas":3}},
+  {"kind":"Deployment","metadata":{"namespace":"default","name":"paired"},"spec":{"replicas":2}},
+  {"kind":"Deployment","metadata":{"namespace":"default","name":"parked"},"spec":{"replicas":0}},
+  {"kind":"StatefulSet","metadata":{"namespace":"database","name":"postgres"},"spec":{"replicas":3}}]}`
+
+	drainTestPDBs = `{"items":[
+  {"metadata":{"namespace":"default","name":"echo"},"spec":{"minAvailable":1,"selector":{"matchLabels":{"app":"echo"}}}},
+  {"metadata":{"namespace":"network","name":"gateway"},"spec":{"minAvailable":"50%","selector":{"matchLabels":{"app":"gateway"}}}},
+  {"metadata":{"namespace":"database","name":"postgres"},"spec":{"maxUnavailable":0,"selector":{"matchExpressions":[{"key":"app","operator":"In","values":["postgres"]}]}}}]}`
+
+	drainTestPVs = `{"items":[
+  {"metadata":{"name":"pvc-radarr"},"spec":{"storageClassName":"openebs-hostpath","claimRef":{"namespace":"media","name":"radarr"},
+   "nodeAffinity":{"required":{"nodeSelectorTerms":[{"matchExpressions":[{"key":"kubernetes.io/hostname","operator":"In","values":["k8s-1"]}]}]}}}},
+  {"metadata":{"name":"pvc-postgres-0"},"spec":{"storageClassName":"openebs-hostpath","claimRef":{"namespace":"database","name":"data-postgres-0"},
+   "nodeAffinity":{"required":{"nodeSelectorTerms":[{"matchExpressions":[{"key":"kubernetes.io/hostname","operator":"In","values":["k8s-0"]}]}]}}}},
+  {"metadata":{"name":"pvc-postgres-1"},"spec":{"storageClassName":"openebs-hostpath","claimRef":{"namespace":"database","name":"data-postgres-1"},
+   "nodeAffinity":{"required":{"nodeSelectorTerms":[{"matchExpressions":[{"key":"kubernetes.io/hostname","operator":"In","values":["k8s-1"]}]}]}}}},
+  {"metadata":{"name":"pvc-shared"},"spec":{"storageClassName":"ceph-block","claimRef":{"namespace":"media","name":"shared"}}}]}`
+)
+
+// drainTestPod builds one pod; ready pods carry a Ready=True condition.
+func drainTestPod(namespace, name, node, owner string, labels map[string]string, ready bool, claims ...string) string {
+	var b strings.Builder
+	b.WriteString(`{"metadata":{"namespace":"` + namespace + `","name":"` + name + `","labels":{`)
+	first := true
+	for key, value := range labels {
+		if !first {
+			b.WriteString(",")
+		}
+		first = false
+		b.WriteString(`"` + key + `":"` + value + `"`)
+	}
+	b.WriteString(`}`)
+	if kind, ownerName, ok := strings.Cut(owner, "/"); ok {
+		b.WriteString(`,"ownerReferences":[{"kind":"` + kind + `","name":"` + ownerName + `"}]`)
+	}
+	b.WriteString(`},"spec":{"nodeName":"` + node + `","volumes":[`)
+	for i, claim := range claims {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(`{"persistentVolumeClaim":{"claimName":"` + claim + `"}}`)
+	}
+	status := "False"
+	if ready {
+		status = "True"
+	}
+	b.WriteString(`]},"status":{"phase":"Running","conditions":[{"type":"Ready","status":"` + status + `"}]}}`)
+	return b.String()
+}
+
+func drainTestPods() string {
+	echo := map[string]string{"app": "echo", "pod-template-hash": "5c9f"}
+	gateway := map[string]string{"app": "gateway", "pod-template-hash": "77aa"}
+	paired := map[string]string{"app": "paired", "pod-template-hash": "d41"}
+	pods := []string{
+		drainTestPod("media", "radarr-6b7c-x1", "k8s-1", "ReplicaSet/radarr-6b7c", map[string]string{"app": "radarr", "pod-template-hash": "6b7c"}, true, "radarr", "shared"),
+		drainTestPod("default", "echo-5c9f-a", "k8s-0", "ReplicaSet/echo-5c9f", echo, true),
+		drainTestPod("default", "echo-5c9f-b", "k8s-1", "ReplicaSet/echo-5c9f", echo, true),
+		drainTestPod("network", "gateway-77aa-a", "k8s-1", "ReplicaSet/gateway-77aa", gateway, true),
+		drainTestPod("network", "gateway-77aa-b", "k8s-1", "ReplicaSet/gateway-77aa", gateway, true),
+		drainTestPod("network", "gateway-77aa-c", "k8s-2", "ReplicaSet/gateway-77aa", gateway, true),
+		drainTestPod("database", "postgres-0", "k8s-0", "StatefulSet/postgres", map[string]string{"app": "postgres"}, true, "data-postgres-0"),
+		drainTestPod("database", "postgres-1", "k8s-1", "StatefulSet/postgres", map[string]string{"app": "postgres"}, true, "data-postgres-1"),
+		drainTestPod("database", "postgres-2", "k8s-2", "StatefulSet/postgres", map[string]string{"app": "postgres"}, false),
+		drainTestPod("default", "paired-d41-a", "k8s-0", "ReplicaSet/paired-d41", paired, true),
+		drainTestPod("default", "paired-d41-b", "k8s-0", "ReplicaSet/paired-d41", paired, true),
+		drainTestPod("kube-system", "cilium-abcde", "k8s-1", "DaemonSet/cilium", nil, true),
+		drainTestPod("kube-system", "kube-apiserver-k8s-1", "k8s-1", "Node/k8s-1", nil, true),
+		drainTestPod("default", "debug", "k8s-1", "", nil, true),
+	}
+	finished := `{"metadata":{"namespace":"media","name":"cleanup-29","ownerReferences":[{"kind":"Job","name":"cleanup"}]},"spec":{"nodeName":"k8s-1"},"status":{"phase":"Succeeded"}}`
+	return `{"items":[` + strings.Join(append(pods, finished), ",") + `]}`
+}
+
+func withDrainFakeCluster(t *testing.T) {
+	t.Helper()
+	responses := map[string]string{
+		"nodes":                              drainTestNodes,
+		"pods":                               drainTestPods(),
+		"deployments.apps,statefulsets.apps": drainTestWorkloads,
+		"poddisruptionbudgets.policy":        drainTestPDBs,
+		"persistentvolumes":                  drainTestPVs,
+	}
+	testutil.Swap(t, &kubectlOutputCtxFn, func(_ context.Context, args ...string) ([]byte, error) {
+		if len(args) > 1 && args[0] == "get" {
+			if response, ok := responses[args[1]]; ok {
+				return []byte(response), nil
+			}
+		}
+		return nil, errors.New("unexpected kubectl call: " + strings.Join(args, " "))
+	})
+}
+
+func drainImpactByWorkload(t *testing.T, report drainImpactReport, name string) drainWorkloadImpact {
+	t.Helper()
+	for _, workload := range report.Workloads {
+		if namespacedName(workload.Namespace, workload.Workload) == name {
+			return workload
+		}
+	}
+	require.Failf(t, "workload not in report", "%s", name)
+	return drainWorkloadImpact{}
+}
+
+func TestEvaluateDrainImpact(t *testing.T) {
+	withDrainFakeCluster(t)
+	cluster, err := loadDrainCluster(context.Background())
+	require.NoError(t, err)
+	report, err := evaluateDrainImpact(cluster, "192.168.122.11")
+	require.NoError(t, err)
+
+	assert.Equal(t, "k8s-1", report.Node, "an InternalIP resolves to the node name")
+	assert.Equal(t, 2, report.DaemonSetPods, "DaemonSet and static pods stay on the node")
+	assert.Equal(t, []int{2, 2, 1}, []int{report.Safe, report.Downtime, report.Blocked})
+
+	radarr := drainImpactByWorkload(t, report, "media/Deployment/radarr")
+	assert.Equal(t, drainDowntime, radarr.Verdict)
+	assert.Equal(t, 1, radarr.Replicas)
+	assert.Zero(t, radarr.ReadyElsewhere)
+	assert.Empty(t, radarr.PDBs)
+	assert.Equal(t, []string{"pvc-radarr (openebs-hostpath)"}, radarr.LocalPVs, "only the PV pinned to k8s-1 counts")
+
+	echo := drainImpactByWorkload(t, report, "default/Deployment/echo")
+	assert.Equal(t, drainSafe, echo.Verdict)
+	assert.Equal(t, 1, echo.ReadyElsewhere)
+	require.Len(t, echo.PDBs, 1)
+	assert.Equal(t, drainPDBImpact{Name: "echo", Expected: 2, Healthy: 2, DesiredHealthy: 1, Allowed: 1, OnNode: 1}, echo.PDBs[0])
+
+	gateway := drainImpactByWorkload(t, report, "network/Deployment/gateway")
+	assert.Equal(t, drainSafe, gateway.Verdict, "a partial budget slows the drain but does not block it")
+	require.Len(t, gateway.PDBs, 1)
+	assert.Equal(t, drainPDBImpact{Name: "gateway", Expected: 3, Healthy: 3, DesiredHealthy: 2, Allowed: 1, OnNode: 2, Violated: true}, gateway.PDBs[0])
+	assert.Contains(t, gateway.Notes, "PDB gateway allows 1 of 2 at a time; the drain waits for replacements")
+
+	postgres := drainImpactByWorkload(t, report, "database/StatefulSet/postgres")
+	assert.Equal(t, drainBlocked, postgres.Verdict)
+	assert.Equal(t, 1, postgres.ReadyElsewhere, "the unready replica on k8s-2 does not count")
+	assert.Equal(t, []string{"pvc-postgres-1 (openebs-hostpath)"}, postgres.LocalPVs)
+	assert.Equal(t, drainPDBImpact{Name: "postgres", Expected: 3, Healthy: 2, DesiredHealthy: 3, Allowed: 0, OnNode: 1, Violated: true}, postgres.PDBs[0])
+
+	debug := drainImpactByWorkload(t, report, "default/Pod/debug")
+	assert.Equal(t, drainDowntime, debug.Verdict)
+
+	assert.Equal(t, drainBlocked, report.Workloads[0].Verdict, "blocked workloads sort first")
+	for _, workload := range report.Workloads {
+		assert.NotEqual(t, "media/Job/cleanup", namespacedName(workload.Namespace, workload.Workload), "finished pods are ignored")
+	}
+
+	_, err = evaluateDrainImpact(cluster, "k8s-9")
+	assert.EqualError(t, err, `node "k8s-9" not found by name or InternalIP`)
+}
+
+func TestDrainPDBBudget(t *testing.T) {
+	ready := func(owner string, count int) []drainPod {
+		var pods []drainPod
+		for range count {
+			var pod drainPod
+			pod.Metadata.Namespace = "default"
+			pod.Metadata.Name = "pod"
+			pod.Metadata.Labels = map[string]string{"pod-template-hash": "abc"}
+			if owner != "" {
+				pod.Metadata.OwnerReferences = append(pod.Metadata.OwnerReferences, kubeutil.OwnerReference{Kind: "ReplicaSet", Name: owner + "-abc"})
+			}
+			pod.Status.Phase = "Running"
+			pod.Status.Conditions = []conditionJSON{{Type: "Ready", Status: "True"}}
+			pods = append(pods, pod)
+		}
+		return pods
+	}
+	replicas := map[string]int{"default/Deployment/app": 4}
+	intValue := func(value int32) *intstr.IntOrString { v := intstr.FromInt32(value); return &v }
+	percent := func(value string) *intstr.IntOrString { v := intstr.FromString(value); return &v }
+	for _, tc := range []struct {
+		name                      string
+		minAvailable, maxUnavail  *intstr.IntOrString
+		pods                      []drainPod
+		expected, healthy, desire int
+	}{
+		// An integer minAvailable counts only the pods that exist.
+		{"min-int", intValue(2), nil, ready("app", 3), 3, 3, 2},
+		// Percentages and maxUnavailable scale the workload's replicas.
+		{"min-percent-rounds-up", percent("30%"), nil, ready("app", 3), 4, 3, 2},
+		{"max-int", nil, intValue(1), ready("app", 3), 4, 3, 3},
+		{"max-percent-rounds-up", nil, percent("30%"), ready("app", 4), 4, 4, 2},
+		{"max-zero", nil, intValue(0), ready("app", 4), 4, 4, 4},
+		{"unowned-pods", nil, percent("50%"), ready("", 3), 3, 3, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			expected, healthy, desired, err := drainPDBBudget(tc.minAvailable, tc.maxUnavail, tc.pods, replicas)
+			require.NoError(t, err)
+			assert.Equal(t, []int{tc.expected, tc.healthy, tc.desire}, []int{expected, healthy, desired})
+		})
+	}
+}
+
+func TestAuditDrainRisk(t *testing.T) {
+	withDrainFakeCluster(t)
+	cluster, err := loadDrainCluster(context.Background())
+	require.NoError(t, err)
+	report := auditDrainRisk(cluster)
+	assert.Equal(t, 1, report.SingleReplica)
+	assert.Equal(t, 2, report.WithoutPDB)
+	assert.Equal(t, []drainAuditFinding{
+		{Namespace: "default", Workload: "Deployment/paired", Replicas: 2, Issues: []string{"no PDB", "all ready replicas on k8s-0"}},
+		{Namespace: "media", Workload: "Deployment/radarr", Replicas: 1, Issues: []string{"single replica", "no PDB"}},
+	}, report.Findings, "scaled-down and covered workloads are not flagged")
+}
+
+func TestDrainImpactCommandExitsOnRisk(t *testing.T) {
+	withDrainFakeCluster(t)
+	output, err := testutil.ExecuteCommand(newDrainImpactCommand(), "--node", "k8s-1")
+	require.Error(t, err)
+	assert.Contains(t, output, "Draining k8s-1: 2 safe, 2 will cause downtime, 1 blocked (2 DaemonSet/static pod(s) stay)")
+	assert.Contains(t, output, "eviction will be blocked")
+	assert.Contains(t, err.Error(), "2 workload(s) will cause downtime, 1 will block eviction")
+
+	output, err = testutil.ExecuteCommand(newDrainImpactCommand(), "--audit")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Drain risk: 1 single-replica workload(s), 2 without a PDB")
+
+	_, err = testutil.ExecuteCommand(newDrainImpactCommand(), "--audit", "--node", "k8s-1")
+	assert.EqualError(t, err, "pass exactly one of --node or --audit")
+
+	risks, err := DrainImpactRisks(context.Background(), "k8s-1")
+	require.NoError(t, err)
+	require.Len(t, risks, 3)
+	assert.True(t, strings.HasPrefix(risks[0], "database/StatefulSet/postgres: eviction will be blocked (PDB postgres allows no disruption (2 healthy, 3 required)"), risks[0])
+}
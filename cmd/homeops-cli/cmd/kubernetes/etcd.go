@@ -710,6 +710,74 @@ func inspectEtcdBackups(dir string, staleAfter time.Duration) (etcdBackupInvento
 	}, nil
 }
 
+// RequireFreshEtcdBackup is the backup gate for disruptive cluster-wide
+// operations: it succeeds when the newest local snapshot in
+// state.etcd_backup.dir, or failing that the newest copy on the configured
+// remote host, is younger than maxAge.
+func RequireFreshEtcdBackup(ctx context.Context, maxAge time.Duration) error {
+	cfg := config.Get()
+	local, err := inspectEtcdBackups(cfg.State.EtcdBackup.Dir, maxAge)
+	if err != nil {
+		return fmt.Errorf("etcd backup gate: %w", err)
+	}
+	if local.Status == "OK" {
+		return nil
+	}
+	detail := local.Detail
+	if cfg.State.EtcdBackup.Upload.Configured() {
+		remote := inspectRemoteEtcdBackups(ctx, maxAge)
+		if remote.Status == "OK" {
+			return nil
+		}
+		detail += "; " + remote.Detail
+	}
+	return fmt.Errorf("etcd backup gate: %s; take a fresh one with 'homeops-cli k8s etcd backup'", detail)
+}
+
+// SaveEtcdSnapshot files a snapshot that take writes to path, for callers
+// that snapshot etcd without `k8s etcd backup` (Talos upgrades go through
+// talosctl). It lands in state.etcd_backup.dir under the backup naming, so
+// etcd status and RequireFreshEtcdBackup count it, and is checksummed,
+// pruned, and with upload set copied to the upload host like a backup.
+func SaveEtcdSnapshot(ctx context.Context, node string, upload bool, take func(path string) error) (string, error) {
+	cfg := config.Get()
+	dir, err := expandHomeDir(cfg.State.EtcdBackup.Dir)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("create etcd backup directory %s: %w", dir, err)
+	}
+	stamp := etcdNowFn().UTC().Format("20060102T150405Z")
+	path := filepath.Join(dir, fmt.Sprintf("etcd-snapshot-%s-%s.db", safeFilenamePart(node), stamp))
+	if err := take(path); err != nil {
+		_ = os.Remove(path)
+		return "", fmt.Errorf("take etcd snapshot from %s: %w", node, err)
+	}
+	content, err := os.ReadFile(path) // #nosec G304 -- path is built above inside state.etcd_backup.dir
+	if err != nil {
+		return "", fmt.Errorf("read etcd snapshot %s: %w", path, err)
+	}
+	if len(content) == 0 {
+		_ = os.Remove(path)
+		return "", fmt.Errorf("etcd snapshot from %s is empty", node)
+	}
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+	if err := writeEtcdSnapshotChecksum(path, digest); err != nil {
+		return "", err
+	}
+	if _, err := pruneEtcdSnapshots(dir, cfg.State.EtcdBackup.Keep); err != nil {
+		return path, err
+	}
+	if upload {
+		if _, err := uploadEtcdSnapshot(ctx, path, digest); err != nil {
+			return path, err
+		}
+	}
+	return path, nil
+}
+
 func renderEtcdBackupResult(result etcdBackupResult) string {
 	rows := [][]string{
 		{"Node", result.Node},
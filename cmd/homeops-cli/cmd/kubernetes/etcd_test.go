@@ -297,3 +297,53 @@ func TestInspectEtcdBackupsWarnsWhenNone(t *testing.T) {
 	assert.Equal(t, "WARN", inventory.Status)
 	assert.Contains(t, inventory.Detail, "no local")
 }
+
+func TestRequireFreshEtcdBackup(t *testing.T) {
+	dir := t.TempDir()
+	restore := config.SetForTesting(&config.Config{State: config.StateConfig{EtcdBackup: config.EtcdBackupConfig{Dir: dir}}})
+	t.Cleanup(restore)
+	now := time.Date(2026, 7, 14, 0, 0, 0, 0, time.UTC)
+	testutil.Swap(t, &etcdNowFn, func() time.Time { return now })
+
+	err := RequireFreshEtcdBackup(context.Background(), 24*time.Hour)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no local etcd snapshot found")
+	assert.Contains(t, err.Error(), "homeops-cli k8s etcd backup")
+
+	path := filepath.Join(dir, "etcd-snapshot-k8s-0-20260712T000000Z.db")
+	require.NoError(t, os.WriteFile(path, []byte("12345"), 0o600))
+	require.NoError(t, os.Chtimes(path, now.Add(-30*time.Hour), now.Add(-30*time.Hour)))
+	err = RequireFreshEtcdBackup(context.Background(), 24*time.Hour)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "older than 24h0m0s")
+
+	require.NoError(t, RequireFreshEtcdBackup(context.Background(), 48*time.Hour))
+}
+
+func TestSaveEtcdSnapshotChecksumsPrunesAndRejectsEmpty(t *testing.T) {
+	dir := t.TempDir()
+	t.Cleanup(config.SetForTesting(&config.Config{State: config.StateConfig{EtcdBackup: config.EtcdBackupConfig{Dir: dir, Keep: 1}}}))
+	now := time.Date(2026, 10, 14, 21, 0, 0, 0, time.UTC)
+	testutil.Swap(t, &etcdNowFn, func() time.Time { return now })
+	old := filepath.Join(dir, "etcd-snapshot-k8s-0-20261001T000000Z.db")
+	require.NoError(t, os.WriteFile(old, []byte("old"), 0o600))
+	longAgo := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(old, longAgo, longAgo))
+
+	path, err := SaveEtcdSnapshot(context.Background(), "k8s-0", false, func(path string) error {
+		return os.WriteFile(path, []byte("snapshot"), 0o600)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "etcd-snapshot-k8s-0-20261014T210000Z.db"), path)
+	checksum, err := os.ReadFile(path + ".sha256")
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(checksum), "  etcd-snapshot-k8s-0-20261014T210000Z.db\n"))
+	assert.NoFileExists(t, old, "older snapshots are pruned to state.etcd_backup.keep")
+
+	now = now.Add(time.Minute)
+	_, err = SaveEtcdSnapshot(context.Background(), "k8s-0", false, func(path string) error {
+		return os.WriteFile(path, nil, 0o600)
+	})
+	assert.EqualError(t, err, "etcd snapshot from k8s-0 is empty")
+	assert.NoFileExists(t, filepath.Join(dir, "etcd-snapshot-k8s-0-20261014T210100Z.db"))
+}
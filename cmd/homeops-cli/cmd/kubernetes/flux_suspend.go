@@ -0,0 +1,483 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/kubeutil"
+)
+
+// flux-suspend bookkeeping. The annotations live on the suspended object so
+// flux-resume, doctor, and flux-tree can read them back without local state.
+const (
+	fluxSuspendReasonAnnotation   = "homeops.io/suspend-reason"
+	fluxSuspendExpiresAnnotation  = "homeops.io/suspend-expires"
+	fluxSuspendPreviousAnnotation = "homeops.io/suspend-previous"
+	fluxReconcileAnnotation       = "reconcile.fluxcd.io/requestedAt"
+
+	fluxSuspendDefaultExpiry = 24 * time.Hour
+)
+
+// fluxSuspension is what flux-suspend recorded on an object.
+type fluxSuspension struct {
+	Reason string
+	// Expires is zero for a suspension without expiry.
+	Expires time.Time
+	// Previous is spec.suspend before flux-suspend touched the object;
+	// flux-resume restores it.
+	Previous bool
+}
+
+// readFluxSuspension reports false when the object carries no flux-suspend
+// bookkeeping.
+func readFluxSuspension(annotations map[string]string) (fluxSuspension, bool) {
+	reason, ok := annotations[fluxSuspendReasonAnnotation]
+	if !ok {
+		return fluxSuspension{}, false
+	}
+	suspension := fluxSuspension{Reason: reason, Previous: annotations[fluxSuspendPreviousAnnotation] == "true"}
+	if expires, err := time.Parse(time.RFC3339, annotations[fluxSuspendExpiresAnnotation]); err == nil {
+		suspension.Expires = expires
+	}
+	return suspension, true
+}
+
+func (s fluxSuspension) expired(now time.Time) bool {
+	return !s.Expires.IsZero() && !now.Before(s.Expires)
+}
+
+// fluxSuspensionInfo surfaces flux-suspend bookkeeping in the flux status
+// reports (flux-tree and doctor).
+type fluxSuspensionInfo struct {
+	SuspendReason  string `json:"suspend_reason,omitempty"`
+	SuspendExpires string `json:"suspend_expires,omitempty"`
+	SuspendExpired bool   `json:"suspend_expired,omitempty"`
+}
+
+func fluxSuspensionInfoFor(suspended bool, annotations map[string]string) fluxSuspensionInfo {
+	suspension, ok := readFluxSuspension(annotations)
+	if !suspended || !ok {
+		return fluxSuspensionInfo{}
+	}
+	info := fluxSuspensionInfo{SuspendReason: suspension.Reason, SuspendExpired: suspension.expired(nowFn())}
+	if !suspension.Expires.IsZero() {
+		info.SuspendExpires = suspension.Expires.UTC().Format(time.RFC3339)
+	}
+	return info
+}
+
+// label is "" for an object flux-suspend did not suspend.
+func (i fluxSuspensionInfo) label() string {
+	if i.SuspendReason == "" && i.SuspendExpires == "" {
+		return ""
+	}
+	var label string
+	switch {
+	case i.SuspendExpired:
+		label = "EXPIRED " + i.SuspendExpires
+	case i.SuspendExpires != "":
+		label = "until " + i.SuspendExpires
+	default:
+		label = "no expiry"
+	}
+	if i.SuspendReason != "" {
+		label += ": " + i.SuspendReason
+	}
+	return label
+}
+
+// fluxSuspendObject is a Kustomization or HelmRelease as flux-suspend and
+// flux-resume see it.
+type fluxSuspendObject struct {
+	Kind        string
+	Resource    string
+	Namespace   string
+	Name        string
+	Suspended   bool
+	Annotations map[string]string
+	// DependsOn holds object keys of the same kind.
+	DependsOn []string
+	// Via explains why the object was selected.
+	Via string
+}
+
+func (o fluxSuspendObject) key() string { return fluxObjectKey(o.Namespace, o.Name) }
+
+func (o fluxSuspendObject) String() string {
+	return fmt.Sprintf("%s %s", o.Kind, o.key())
+}
+
+func loadFluxSuspendObjects(ctx context.Context) ([]fluxSuspendObject, error) {
+	var kustomizations fluxKustomizationList
+	if err := kubeutil.GetJSON(ctx, kubectlOutputCtxFn, "", fluxKustomizationResource, &kustomizations); err != nil {
+		return nil, err
+	}
+	var releases fluxHelmReleaseList
+	if err := kubeutil.GetJSON(ctx, kubectlOutputCtxFn, "", fluxHelmReleaseResource, &releases); err != nil {
+		return nil, err
+	}
+	var objects []fluxSuspendObject
+	for _, item := range kustomizations.Items {
+		objects = append(objects, fluxSuspendObject{Kind: "Kustomization", Resource: fluxKustomizationResource,
+			Namespace: item.Metadata.Namespace, Name: item.Metadata.Name, Suspended: item.Spec.Suspend,
+			Annotations: item.Metadata.Annotations, DependsOn: fluxDependencyKeys(item.Metadata.Namespace, item.Spec.DependsOn)})
+	}
+	for _, item := range releases.Items {
+		objects = append(objects, fluxSuspendObject{Kind: "HelmRelease", Resource: fluxHelmReleaseResource,
+			Namespace: item.Metadata.Namespace, Name: item.Metadata.Name, Suspended: item.Spec.Suspend,
+			Annotations: item.Metadata.Annotations, DependsOn: fluxDependencyKeys(item.Metadata.Namespace, item.Spec.DependsOn)})
+	}
+	return objects, nil
+}
+
+// selectFluxObjects resolves namespace/name or bare-name references of one
+// kind. A bare name must be unambiguous across namespaces.
+func selectFluxObjects(objects []fluxSuspendObject, kind string, refs []string) ([]fluxSuspendObject, error) {
+	var selected []fluxSuspendObject
+	for _, ref := range refs {
+		ref = strings.TrimSpace(ref)
+		if ref == "" {
+			continue
+		}
+		var matches []fluxSuspendObject
+		for _, object := range objects {
+			if object.Kind != kind {
+				continue
+			}
+			if object.key() == ref || (!strings.Contains(ref, "/") && object.Name == ref) {
+				matches = append(matches, object)
+			}
+		}
+		switch len(matches) {
+		case 0:
+			return nil, fmt.Errorf("flux %s %q not found", kind, ref)
+		case 1:
+			matches[0].Via = "selected"
+			selected = append(selected, matches[0])
+		default:
+			var namespaces []string
+			for _, match := range matches {
+				namespaces = append(namespaces, match.Namespace)
+			}
+			return nil, fmt.Errorf("flux %s %q exists in multiple namespaces (%s) — use namespace/name", kind, ref, strings.Join(namespaces, ", "))
+		}
+	}
+	return selected, nil
+}
+
+// expandFluxDependents adds every object that transitively dependsOn a
+// selected one. Kustomizations depend on Kustomizations and HelmReleases on
+// HelmReleases, so the walk stays within a kind.
+func expandFluxDependents(objects, selected []fluxSuspendObject) []fluxSuspendObject {
+	dependents := map[string][]fluxSuspendObject{}
+	for _, object := range objects {
+		for _, dependency := range object.DependsOn {
+			dependents[object.Kind+" "+dependency] = append(dependents[object.Kind+" "+dependency], object)
+		}
+	}
+	seen := map[string]bool{}
+	for _, object := range selected {
+		seen[object.String()] = true
+	}
+	result := append([]fluxSuspendObject{}, selected...)
+	queue := append([]fluxSuspendObject{}, selected...)
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+		children := dependents[parent.Kind+" "+parent.key()]
+		sort.Slice(children, func(i, j int) bool { return children[i].key() < children[j].key() })
+		for _, child := range children {
+			if seen[child.String()] {
+				continue
+			}
+			seen[child.String()] = true
+			child.Via = "depends on " + parent.key()
+			result = append(result, child)
+			queue = append(queue, child)
+		}
+	}
+	return result
+}
+
+// fluxSuspendPatch suspends object and records the bookkeeping. Suspending an
+// object flux-suspend already holds keeps its original previous state, so a
+// second suspension does not make the first one permanent.
+func fluxSuspendPatch(object fluxSuspendObject, reason string, expires time.Time) (string, error) {
+	previous := object.Suspended
+	if existing, ok := readFluxSuspension(object.Annotations); ok {
+		previous = existing.Previous
+	}
+	annotations := map[string]any{
+		fluxSuspendReasonAnnotation:   reason,
+		fluxSuspendPreviousAnnotation: fmt.Sprintf("%t", previous),
+		fluxSuspendExpiresAnnotation:  nil,
+	}
+	if !expires.IsZero() {
+		annotations[fluxSuspendExpiresAnnotation] = expires.UTC().Format(time.RFC3339)
+	}
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{"annotations": annotations},
+		"spec":     map[string]any{"suspend": true},
+	})
+	return string(patch), err
+}
+
+// fluxResumePatch restores spec.suspend to what it was before flux-suspend,
+// removes the bookkeeping, and requests a reconcile when the object resumes.
+func fluxResumePatch(object fluxSuspendObject, now time.Time) (string, bool, error) {
+	suspension, _ := readFluxSuspension(object.Annotations)
+	annotations := map[string]any{
+		fluxSuspendReasonAnnotation:   nil,
+		fluxSuspendPreviousAnnotation: nil,
+		fluxSuspendExpiresAnnotation:  nil,
+	}
+	resumed := !suspension.Previous
+	if resumed {
+		annotations[fluxReconcileAnnotation] = now.UTC().Format(time.RFC3339Nano)
+	}
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{"annotations": annotations},
+		"spec":     map[string]any{"suspend": suspension.Previous},
+	})
+	return string(patch), resumed, err
+}
+
+func patchFluxObject(ctx context.Context, object fluxSuspendObject, patch string) error {
+	if err := commandRunCtxFn(ctx, "kubectl", "--namespace", object.Namespace, "patch", object.Resource, object.Name, "--type", "merge", "-p", patch); err != nil {
+		return fmt.Errorf("failed to patch %s: %w", object, err)
+	}
+	return nil
+}
+
+type fluxSuspendOptions struct {
+	Kustomizations []string
+	HelmReleases   []string
+	AllDependents  bool
+	Expires        time.Duration
+	Reason         string
+	DryRun         bool
+}
+
+func newFluxSuspendCommand() *cobra.Command {
+	var options fluxSuspendOptions
+	cmd := &cobra.Command{
+		Use:   "flux-suspend",
+		Short: "Suspend Flux Kustomizations and HelmReleases with a reason and expiry",
+		Long: `Sets spec.suspend on the selected Kustomizations and HelmReleases and records
+the reason, the expiry, and the previous suspend state in homeops.io/suspend-*
+annotations. --all-dependents also suspends everything that transitively
+dependsOn a selected object, so dependents do not fail while it is held.
+
+Expiry is not enforced by a controller: doctor and flux-tree flag expired
+suspensions, and flux-resume --expired-only lifts them.`,
+		Example: `  homeops-cli k8s flux-suspend --kustomization rook-ceph --all-dependents --reason "ceph maintenance"
+  homeops-cli k8s flux-suspend --helmrelease media/plex --expires 4h --reason "library rescan"
+  homeops-cli k8s flux-suspend --kustomization flux-system/a,flux-system/b --expires 0 --reason "hold" --dry-run`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runFluxSuspend(cmd.Context(), options, cmd.OutOrStdout())
+		},
+	}
+	cmd.Flags().StringSliceVar(&options.Kustomizations, "kustomization", nil, "Kustomizations to suspend, as name or namespace/name")
+	cmd.Flags().StringSliceVar(&options.HelmReleases, "helmrelease", nil, "HelmReleases to suspend, as name or namespace/name")
+	cmd.Flags().BoolVar(&options.AllDependents, "all-dependents", false, "also suspend objects that depend on the selected ones")
+	cmd.Flags().DurationVar(&options.Expires, "expires", fluxSuspendDefaultExpiry, "how long the suspension is meant to last (0 for no expiry)")
+	cmd.Flags().StringVar(&options.Reason, "reason", "", "why the objects are suspended (required)")
+	cmd.Flags().BoolVar(&options.DryRun, "dry-run", false, "print the objects that would be suspended without patching them")
+	_ = cmd.MarkFlagRequired("reason")
+	return cmd
+}
+
+func runFluxSuspend(ctx context.Context, options fluxSuspendOptions, out io.Writer) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	reason := strings.TrimSpace(options.Reason)
+	if reason == "" {
+		return fmt.Errorf("--reason is required")
+	}
+	if len(options.Kustomizations) == 0 && len(options.HelmReleases) == 0 {
+		return fmt.Errorf("select objects with --kustomization or --helmrelease")
+	}
+	if options.Expires < 0 {
+		return fmt.Errorf("--expires must not be negative")
+	}
+	objects, err := loadFluxSuspendObjects(ctx)
+	if err != nil {
+		return err
+	}
+	selected, err := selectFluxObjects(objects, "Kustomization", options.Kustomizations)
+	if err != nil {
+		return err
+	}
+	releases, err := selectFluxObjects(objects, "HelmRelease", options.HelmReleases)
+	if err != nil {
+		return err
+	}
+	selected = append(selected, releases...)
+	if options.AllDependents {
+		selected = expandFluxDependents(objects, selected)
+	}
+
+	var expires time.Time
+	until := "no expiry"
+	if options.Expires > 0 {
+		expires = nowFn().Add(options.Expires)
+		until = "until " + expires.UTC().Format(time.RFC3339)
+	}
+	prefix := ""
+	if options.DryRun {
+		prefix = "DRY-RUN "
+	}
+	_, _ = fmt.Fprintf(out, "%sSuspending %d object(s), %s: %s\n", prefix, len(selected), until, reason)
+	for _, object := range selected {
+		note := object.Via
+		if object.Suspended {
+			if _, ours := readFluxSuspension(object.Annotations); !ours {
+				note += ", already suspended: stays suspended on resume"
+			}
+		}
+		_, _ = fmt.Fprintf(out, "  %s (%s)\n", object, note)
+	}
+	if options.DryRun {
+		return nil
+	}
+	logger := common.NewColorLogger()
+	for _, object := range selected {
+		patch, err := fluxSuspendPatch(object, reason, expires)
+		if err != nil {
+			return err
+		}
+		if err := patchFluxObject(ctx, object, patch); err != nil {
+			return err
+		}
+	}
+	logger.Success("Suspended %d Flux object(s); lift with: homeops-cli k8s flux-resume --all-suspended", len(selected))
+	return nil
+}
+
+type fluxResumeOptions struct {
+	Kustomizations []string
+	HelmReleases   []string
+	ExpiredOnly    bool
+	AllSuspended   bool
+	DryRun         bool
+}
+
+func newFluxResumeCommand() *cobra.Command {
+	var options fluxResumeOptions
+	cmd := &cobra.Command{
+		Use:   "flux-resume",
+		Short: "Lift suspensions made by flux-suspend",
+		Long: `Restores spec.suspend to the state flux-suspend recorded, removes the
+homeops.io/suspend-* annotations, and requests a reconcile of every object that
+resumes. An object that was already suspended before flux-suspend stays
+suspended.
+
+--expired-only and --all-suspended only consider objects flux-suspend
+suspended; objects suspended another way are left to flux resume.`,
+		Example: `  homeops-cli k8s flux-resume --expired-only
+  homeops-cli k8s flux-resume --all-suspended --dry-run
+  homeops-cli k8s flux-resume --kustomization rook-ceph`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runFluxResume(cmd.Context(), options, cmd.OutOrStdout())
+		},
+	}
+	cmd.Flags().StringSliceVar(&options.Kustomizations, "kustomization", nil, "Kustomizations to resume, as name or namespace/name")
+	cmd.Flags().StringSliceVar(&options.HelmReleases, "helmrelease", nil, "HelmReleases to resume, as name or namespace/name")
+	cmd.Flags().BoolVar(&options.ExpiredOnly, "expired-only", false, "resume every flux-suspend suspension past its expiry")
+	cmd.Flags().BoolVar(&options.AllSuspended, "all-suspended", false, "resume every flux-suspend suspension")
+	cmd.Flags().BoolVar(&options.DryRun, "dry-run", false, "print the objects that would be resumed without patching them")
+	cmd.MarkFlagsMutuallyExclusive("expired-only", "all-suspended")
+	return cmd
+}
+
+func runFluxResume(ctx context.Context, options fluxResumeOptions, out io.Writer) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	explicit := len(options.Kustomizations) > 0 || len(options.HelmReleases) > 0
+	if explicit == (options.ExpiredOnly || options.AllSuspended) {
+		return fmt.Errorf("select objects with --kustomization/--helmrelease, or use --expired-only or --all-suspended")
+	}
+	objects, err := loadFluxSuspendObjects(ctx)
+	if err != nil {
+		return err
+	}
+	now := nowFn()
+	var selected []fluxSuspendObject
+	if explicit {
+		kustomizations, err := selectFluxObjects(objects, "Kustomization", options.Kustomizations)
+		if err != nil {
+			return err
+		}
+		releases, err := selectFluxObjects(objects, "HelmRelease", options.HelmReleases)
+		if err != nil {
+			return err
+		}
+		for _, object := range append(kustomizations, releases...) {
+			if _, ours := readFluxSuspension(object.Annotations); ours || object.Suspended {
+				selected = append(selected, object)
+			}
+		}
+	} else {
+		for _, object := range objects {
+			suspension, ours := readFluxSuspension(object.Annotations)
+			if ours && (options.AllSuspended || suspension.expired(now)) {
+				selected = append(selected, object)
+			}
+		}
+		sort.Slice(selected, func(i, j int) bool { return selected[i].String() < selected[j].String() })
+	}
+	if len(selected) == 0 {
+		_, _ = fmt.Fprintln(out, "No suspended Flux objects to resume")
+		return nil
+	}
+
+	prefix := ""
+	if options.DryRun {
+		prefix = "DRY-RUN "
+	}
+	_, _ = fmt.Fprintf(out, "%sResuming %d object(s)\n", prefix, len(selected))
+	for _, object := range selected {
+		suspension, ours := readFluxSuspension(object.Annotations)
+		detail := "suspended outside flux-suspend"
+		if ours {
+			detail = fluxSuspensionInfoFor(true, object.Annotations).label()
+			if suspension.Previous {
+				detail += "; was suspended before, stays suspended"
+			}
+		}
+		_, _ = fmt.Fprintf(out, "  %s (%s)\n", object, detail)
+	}
+	if options.DryRun {
+		return nil
+	}
+	logger := common.NewColorLogger()
+	resumed := 0
+	for _, object := range selected {
+		patch, unsuspended, err := fluxResumePatch(object, now)
+		if err != nil {
+			return err
+		}
+		if err := patchFluxObject(ctx, object, patch); err != nil {
+			return err
+		}
+		if unsuspended {
+			resumed++
+		}
+	}
+	logger.Success("Resumed %d Flux object(s); %d restored to suspended", resumed, len(selected)-resumed)
+	return nil
+}
@@ -0,0 +1,187 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/testutil"
+)
+
+var fluxSuspendTestNow = time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+
+// fakeFluxSuspendCluster serves the given Kustomizations and HelmReleases to
+// kubectl get and records every kubectl patch as "<resource> <ns>/<name>"
+// mapped to its decoded patch.
+func fakeFluxSuspendCluster(t *testing.T, kustomizations []fluxKustomization, releases []fluxHelmRelease) (map[string]map[string]any, *[]string) {
+	t.Helper()
+	testutil.Swap(t, &nowFn, func() time.Time { return fluxSuspendTestNow })
+	testutil.Swap(t, &kubectlOutputCtxFn, func(_ context.Context, args ...string) ([]byte, error) {
+		switch strings.Join(args, " ") {
+		case "get " + fluxKustomizationResource + " -A -o json":
+			return json.Marshal(fluxKustomizationList{Items: kustomizations})
+		case "get " + fluxHelmReleaseResource + " -A -o json":
+			return json.Marshal(fluxHelmReleaseList{Items: releases})
+		}
+		return nil, fmt.Errorf("unexpected kubectl %v", args)
+	})
+	patches := map[string]map[string]any{}
+	var order []string
+	swapK8sCommandRun(t, func(name string, args ...string) error {
+		require.Equal(t, "kubectl", name)
+		require.Equal(t, []string{"patch"}, args[2:3])
+		var patch map[string]any
+		require.NoError(t, json.Unmarshal([]byte(args[len(args)-1]), &patch))
+		key := fmt.Sprintf("%s %s/%s", args[3], args[1], args[4])
+		patches[key] = patch
+		order = append(order, key)
+		return nil
+	})
+	return patches, &order
+}
+
+func fluxSuspendTestObject(namespace, name string, suspended bool, annotations map[string]string, dependencies ...string) fluxKustomization {
+	item := fluxTestKustomization(namespace, name, "True", "")
+	item.Spec.Suspend = suspended
+	item.Metadata.Annotations = annotations
+	for _, dependency := range dependencies {
+		item.Spec.DependsOn = append(item.Spec.DependsOn, fluxDependencyRef{Name: dependency})
+	}
+	return item
+}
+
+func patchAnnotations(patch map[string]any) map[string]any {
+	return patch["metadata"].(map[string]any)["annotations"].(map[string]any)
+}
+
+func TestFluxSuspendRecordsBookkeepingAndWalksDependents(t *testing.T) {
+	release := fluxTestRelease("media", "plex", "apps", "True", "")
+	patches, order := fakeFluxSuspendCluster(t, []fluxKustomization{
+		fluxSuspendTestObject("flux-system", "rook-ceph", false, nil),
+		fluxSuspendTestObject("flux-system", "rook-ceph-cluster", false, nil, "rook-ceph"),
+		fluxSuspendTestObject("flux-system", "apps", false, nil, "rook-ceph-cluster"),
+		fluxSuspendTestObject("flux-system", "legacy", true, nil, "rook-ceph"),
+		fluxSuspendTestObject("flux-system", "unrelated", false, nil),
+		fluxSuspendTestObject("media", "rook-ceph", false, nil),
+	}, []fluxHelmRelease{release})
+
+	var out strings.Builder
+	err := runFluxSuspend(context.Background(), fluxSuspendOptions{Kustomizations: []string{"rook-ceph"}, Reason: "ceph maintenance"}, &out)
+	require.ErrorContains(t, err, "exists in multiple namespaces (flux-system, media)")
+
+	out.Reset()
+	err = runFluxSuspend(context.Background(), fluxSuspendOptions{
+		Kustomizations: []string{"flux-system/rook-ceph"}, HelmReleases: []string{"plex"},
+		AllDependents: true, Expires: 4 * time.Hour, Reason: "ceph maintenance",
+	}, &out)
+	require.NoError(t, err)
+	assert.Equal(t, `Suspending 5 object(s), until 2026-10-14T16:00:00Z: ceph maintenance
+  Kustomization flux-system/rook-ceph (selected)
+  HelmRelease media/plex (selected)
+  Kustomization flux-system/legacy (depends on flux-system/rook-ceph, already suspended: stays suspended on resume)
+  Kustomization flux-system/rook-ceph-cluster (depends on flux-system/rook-ceph)
+  Kustomization flux-system/apps (depends on flux-system/rook-ceph-cluster)
+`, out.String())
+	assert.Len(t, *order, 5)
+
+	root := patches[fluxKustomizationResource+" flux-system/rook-ceph"]
+	assert.Equal(t, map[string]any{"suspend": true}, root["spec"])
+	assert.Equal(t, map[string]any{
+		fluxSuspendReasonAnnotation:   "ceph maintenance",
+		fluxSuspendExpiresAnnotation:  "2026-10-14T16:00:00Z",
+		fluxSuspendPreviousAnnotation: "false",
+	}, patchAnnotations(root))
+	assert.Equal(t, "true", patchAnnotations(patches[fluxKustomizationResource+" flux-system/legacy"])[fluxSuspendPreviousAnnotation])
+	assert.Contains(t, patches, fluxHelmReleaseResource+" media/plex")
+
+	// Re-suspending something flux-suspend already holds keeps the original
+	// previous state, and --expires 0 clears the expiry.
+	held := fluxSuspendTestObject("flux-system", "rook-ceph", true, map[string]string{
+		fluxSuspendReasonAnnotation: "first", fluxSuspendPreviousAnnotation: "false", fluxSuspendExpiresAnnotation: "2026-10-14T13:00:00Z",
+	})
+	patch, err := fluxSuspendPatch(fluxSuspendObject{Suspended: held.Spec.Suspend, Annotations: held.Metadata.Annotations}, "second", time.Time{})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"metadata":{"annotations":{"homeops.io/suspend-reason":"second","homeops.io/suspend-previous":"false","homeops.io/suspend-expires":null}},"spec":{"suspend":true}}`, patch)
+}
+
+func TestFluxResumeRestoresPreviousState(t *testing.T) {
+	suspended := func(expires, previous string) map[string]string {
+		return map[string]string{fluxSuspendReasonAnnotation: "ceph maintenance", fluxSuspendExpiresAnnotation: expires, fluxSuspendPreviousAnnotation: previous}
+	}
+	patches, order := fakeFluxSuspendCluster(t, []fluxKustomization{
+		fluxSuspendTestObject("flux-system", "expired", true, suspended("2026-10-14T08:00:00Z", "false")),
+		fluxSuspendTestObject("flux-system", "pending", true, suspended("2026-10-14T16:00:00Z", "false")),
+		fluxSuspendTestObject("flux-system", "was-held", true, suspended("2026-10-14T11:00:00Z", "true")),
+		fluxSuspendTestObject("flux-system", "forever", true, suspended("", "false")),
+		fluxSuspendTestObject("flux-system", "manual", true, nil),
+	}, nil)
+
+	var out strings.Builder
+	require.ErrorContains(t, runFluxResume(context.Background(), fluxResumeOptions{}, &out), "--expired-only or --all-suspended")
+
+	require.NoError(t, runFluxResume(context.Background(), fluxResumeOptions{ExpiredOnly: true}, &out))
+	assert.Equal(t, []string{
+		fluxKustomizationResource + " flux-system/expired",
+		fluxKustomizationResource + " flux-system/was-held",
+	}, *order, "unexpired, unexpiring, and manually suspended objects are left alone")
+	assert.Contains(t, out.String(), "Kustomization flux-system/expired (EXPIRED 2026-10-14T08:00:00Z: ceph maintenance)")
+
+	expired := patches[fluxKustomizationResource+" flux-system/expired"]
+	assert.Equal(t, map[string]any{"suspend": false}, expired["spec"])
+	assert.Equal(t, map[string]any{
+		fluxSuspendReasonAnnotation:   nil,
+		fluxSuspendExpiresAnnotation:  nil,
+		fluxSuspendPreviousAnnotation: nil,
+		fluxReconcileAnnotation:       fluxSuspendTestNow.Format(time.RFC3339Nano),
+	}, patchAnnotations(expired))
+	held := patches[fluxKustomizationResource+" flux-system/was-held"]
+	assert.Equal(t, map[string]any{"suspend": true}, held["spec"], "suspended before flux-suspend, suspended after")
+	assert.NotContains(t, patchAnnotations(held), fluxReconcileAnnotation)
+
+	*order = nil
+	require.NoError(t, runFluxResume(context.Background(), fluxResumeOptions{AllSuspended: true, DryRun: true}, &out))
+	assert.Empty(t, *order)
+
+	require.NoError(t, runFluxResume(context.Background(), fluxResumeOptions{Kustomizations: []string{"manual"}}, &out))
+	assert.Equal(t, []string{fluxKustomizationResource + " flux-system/manual"}, *order)
+	assert.Equal(t, map[string]any{"suspend": false}, patches[fluxKustomizationResource+" flux-system/manual"]["spec"])
+}
+
+func TestFluxStatusReportsSurfaceSuspensionExpiry(t *testing.T) {
+	testutil.Swap(t, &nowFn, func() time.Time { return fluxSuspendTestNow })
+	expired := fluxSuspendTestObject("flux-system", "rook-ceph", true, map[string]string{
+		fluxSuspendReasonAnnotation: "ceph maintenance", fluxSuspendExpiresAnnotation: "2026-10-14T08:00:00Z", fluxSuspendPreviousAnnotation: "false",
+	})
+	pending := fluxSuspendTestObject("flux-system", "apps", true, map[string]string{
+		fluxSuspendReasonAnnotation: "ceph maintenance", fluxSuspendExpiresAnnotation: "2026-10-14T16:00:00Z", fluxSuspendPreviousAnnotation: "false",
+	}, "rook-ceph")
+
+	rendered, err := renderFluxKustomizationList(summarizeKustomizations([]fluxKustomization{expired, pending}, ""), "table")
+	require.NoError(t, err)
+	assert.Contains(t, rendered, "true (EXPIRED 2026-10-14T08:00:00Z: ceph maintenance)")
+	assert.Contains(t, rendered, "true (until 2026-10-14T16:00:00Z: ceph maintenance)")
+
+	report, err := buildFluxTree([]fluxKustomization{expired, pending}, nil, "flux-system", "apps")
+	require.NoError(t, err)
+	assert.True(t, report.Nodes["flux-system/rook-ceph"].SuspendExpired)
+	tree, err := renderFluxTree(report, "table", false)
+	require.NoError(t, err)
+	assert.Contains(t, tree, "Kustomization flux-system/rook-ceph [Suspended EXPIRED 2026-10-14T08:00:00Z: ceph maintenance]")
+
+	list, err := json.Marshal(fluxKustomizationList{Items: []fluxKustomization{expired, pending}})
+	require.NoError(t, err)
+	fakeKubectlDoctor(t, map[string]string{fluxKustomizationResource: string(list)})
+	r := buildDoctorReport("", doctorDefaultPendingGrace)
+	var details []string
+	for _, check := range statusFor(t, r, doctorGroupFlux) {
+		details = append(details, string(check.Status)+" "+check.Name+" "+check.Detail)
+	}
+	assert.Contains(t, details, string(statusFail)+" flux-system/rook-ceph suspension EXPIRED 2026-10-14T08:00:00Z: ceph maintenance (lift with k8s flux-resume --expired-only)")
+	assert.Contains(t, details, string(statusWarn)+" flux-system/apps suspended until 2026-10-14T16:00:00Z: ceph maintenance")
+}
@@ -13,9 +13,10 @@ import (
 )
 
 type fluxTreeMetadata struct {
-	Name      string            `json:"name"`
-	Namespace string            `json:"namespace"`
-	Labels    map[string]string `json:"labels"`
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
 }
 
 type fluxDependencyRef struct {
@@ -43,7 +44,8 @@ type fluxKustomizationList struct {
 type fluxHelmRelease struct {
 	Metadata fluxTreeMetadata `json:"metadata"`
 	Spec     struct {
-		Suspend bool `json:"suspend"`
+		DependsOn []fluxDependencyRef `json:"dependsOn"`
+		Suspend   bool                `json:"suspend"`
 	} `json:"spec"`
 	Status struct {
 		Conditions          []conditionJSON `json:"conditions"`
@@ -62,6 +64,7 @@ type fluxTreeHelmNode struct {
 	Suspended bool   `json:"suspended"`
 	Revision  string `json:"last_applied_revision,omitempty"`
 	Message   string `json:"message,omitempty"`
+	fluxSuspensionInfo
 }
 
 type fluxTreeNode struct {
@@ -74,6 +77,7 @@ type fluxTreeNode struct {
 	Missing      bool               `json:"missing,omitempty"`
 	Dependencies []string           `json:"dependencies"`
 	HelmReleases []fluxTreeHelmNode `json:"helm_releases"`
+	fluxSuspensionInfo
 }
 
 type fluxTreeReport struct {
@@ -90,6 +94,7 @@ type fluxKustomizationSummary struct {
 	Suspended bool   `json:"suspended"`
 	Revision  string `json:"last_applied_revision,omitempty"`
 	Message   string `json:"message,omitempty"`
+	fluxSuspensionInfo
 }
 
 func newFluxTreeCommand() *cobra.Command {
@@ -180,7 +185,8 @@ func summarizeKustomizations(items []fluxKustomization, namespace string) []flux
 		}
 		ready, message := fluxReady(item.Status.Conditions)
 		summaries = append(summaries, fluxKustomizationSummary{Name: item.Metadata.Name, Namespace: item.Metadata.Namespace,
-			Ready: ready, Suspended: item.Spec.Suspend, Revision: item.Status.LastAppliedRevision, Message: message})
+			Ready: ready, Suspended: item.Spec.Suspend, fluxSuspensionInfo: fluxSuspensionInfoFor(item.Spec.Suspend, item.Metadata.Annotations),
+			Revision: item.Status.LastAppliedRevision, Message: message})
 	}
 	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Name < summaries[j].Name })
 	return summaries
@@ -217,15 +223,9 @@ func buildFluxTree(kustomizations []fluxKustomization, releases []fluxHelmReleas
 		colors[key] = 1
 		ready, message := fluxReady(item.Status.Conditions)
 		node := fluxTreeNode{Name: item.Metadata.Name, Namespace: item.Metadata.Namespace, Ready: ready,
-			Suspended: item.Spec.Suspend, Revision: item.Status.LastAppliedRevision, Message: message}
-		for _, dependency := range item.Spec.DependsOn {
-			dependencyNamespace := dependency.Namespace
-			if dependencyNamespace == "" {
-				dependencyNamespace = item.Metadata.Namespace
-			}
-			node.Dependencies = append(node.Dependencies, fluxObjectKey(dependencyNamespace, dependency.Name))
-		}
-		sort.Strings(node.Dependencies)
+			Suspended: item.Spec.Suspend, fluxSuspensionInfo: fluxSuspensionInfoFor(item.Spec.Suspend, item.Metadata.Annotations),
+			Revision: item.Status.LastAppliedRevision, Message: message}
+		node.Dependencies = fluxDependencyKeys(item.Metadata.Namespace, item.Spec.DependsOn)
 		node.HelmReleases = matchingHelmReleases(item, releases)
 		report.Nodes[key] = node
 		for _, dependency := range node.Dependencies {
@@ -255,7 +255,8 @@ func matchingHelmReleases(item fluxKustomization, releases []fluxHelmRelease) []
 		}
 		ready, message := fluxReady(release.Status.Conditions)
 		result = append(result, fluxTreeHelmNode{Name: release.Metadata.Name, Namespace: release.Metadata.Namespace,
-			Ready: ready, Suspended: release.Spec.Suspend, Revision: release.Status.LastAppliedRevision, Message: message})
+			Ready: ready, Suspended: release.Spec.Suspend, fluxSuspensionInfo: fluxSuspensionInfoFor(release.Spec.Suspend, release.Metadata.Annotations),
+			Revision: release.Status.LastAppliedRevision, Message: message})
 	}
 	sort.Slice(result, func(i, j int) bool {
 		return namespacedName(result[i].Namespace, result[i].Name) < namespacedName(result[j].Namespace, result[j].Name)
@@ -263,6 +264,21 @@ func matchingHelmReleases(item fluxKustomization, releases []fluxHelmRelease) []
 	return result
 }
 
+// fluxDependencyKeys resolves dependsOn references to sorted object keys; a
+// reference without a namespace points into the owner's namespace.
+func fluxDependencyKeys(namespace string, refs []fluxDependencyRef) []string {
+	var keys []string
+	for _, ref := range refs {
+		dependencyNamespace := ref.Namespace
+		if dependencyNamespace == "" {
+			dependencyNamespace = namespace
+		}
+		keys = append(keys, fluxObjectKey(dependencyNamespace, ref.Name))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func fluxReady(conditions []conditionJSON) (string, string) {
 	condition, ok := readyCondition(conditions)
 	if !ok {
@@ -311,8 +327,12 @@ func renderFluxKustomizationList(summaries []fluxKustomizationSummary, output st
 	}
 	var rows [][]string
 	for _, summary := range summaries {
+		suspended := strconvBool(summary.Suspended)
+		if label := summary.label(); label != "" {
+			suspended += " (" + label + ")"
+		}
 		rows = append(rows, []string{fluxGlyph(summary.Ready, summary.Suspended, false), summary.Name,
-			summary.Ready, strconvBool(summary.Suspended), summary.Revision, summary.Message})
+			summary.Ready, suspended, summary.Revision, summary.Message})
 	}
 	return ui.Table([]string{"", "KUSTOMIZATION", "READY", "SUSPENDED", "REVISION", "MESSAGE"}, rows), nil
 }
@@ -390,7 +410,7 @@ func renderFluxTree(report fluxTreeReport, output string, includeAll bool) (stri
 				connector = "└── "
 			}
 			fmt.Fprintf(&b, "%s%s%s HelmRelease %s [%s]", childPrefix, connector,
-				fluxGlyph(release.Ready, release.Suspended, false), namespacedName(release.Namespace, release.Name), fluxState(release.Ready, release.Suspended))
+				fluxGlyph(release.Ready, release.Suspended, false), namespacedName(release.Namespace, release.Name), fluxState(release.Ready, release.Suspended, release.fluxSuspensionInfo))
 			if release.Revision != "" {
 				fmt.Fprintf(&b, " revision=%s", release.Revision)
 			}
@@ -418,7 +438,7 @@ func renderFluxTree(report fluxTreeReport, output string, includeAll bool) (stri
 }
 
 func fluxNodeDetail(node fluxTreeNode) string {
-	detail := fmt.Sprintf("Kustomization %s [%s]", namespacedName(node.Namespace, node.Name), fluxState(node.Ready, node.Suspended))
+	detail := fmt.Sprintf("Kustomization %s [%s]", namespacedName(node.Namespace, node.Name), fluxState(node.Ready, node.Suspended, node.fluxSuspensionInfo))
 	if node.Revision != "" {
 		detail += " revision=" + node.Revision
 	}
@@ -439,8 +459,11 @@ func fluxBlockingDetail(node fluxTreeNode) string {
 	return detail + ")"
 }
 
-func fluxState(ready string, suspended bool) string {
+func fluxState(ready string, suspended bool, suspension fluxSuspensionInfo) string {
 	if suspended {
+		if label := suspension.label(); label != "" {
+			return "Suspended " + label
+		}
 		return "Suspended"
 	}
 	return "Ready=" + ready
@@ -0,0 +1,117 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"homeops-cli/internal/kubeutil"
+	"homeops-cli/internal/ui"
+)
+
+type gatewayStatusReport struct {
+	Gateways []kubeutil.GatewayStatus `json:"gateways"`
+	Problems int                      `json:"problems"`
+}
+
+func newGatewayStatusCommand() *cobra.Command {
+	var namespace, output string
+	cmd := &cobra.Command{
+		Use:          "gateway-status",
+		Short:        "Show Gateway API Gateways, their addresses, and attached HTTPRoutes",
+		SilenceUsage: true,
+		Long: `Lists every Gateway with its GatewayClass, Programmed and Accepted conditions,
+assigned addresses, and the HTTPRoutes whose parentRefs name it, with each
+route's Accepted and ResolvedRefs state as reported for that Gateway.
+
+Gateways without an address, unaccepted classes, and routes that are not
+accepted or have unresolved backends are listed as problems and make the
+command exit non-zero. HTTPRoutes are read cluster-wide so cross-namespace
+attachments are counted when --namespace narrows the Gateways shown.`,
+		Example: `  homeops-cli k8s gateway-status
+  homeops-cli k8s gateway-status -n network
+  homeops-cli k8s gateway-status --output json`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := ui.ValidateOutputFormat(output); err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), kubernetesDefaultCommandTimeout)
+			defer cancel()
+			report, err := buildGatewayStatusReport(ctx, namespace)
+			if err != nil {
+				return err
+			}
+			rendered, err := renderGatewayStatus(report, output)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), rendered)
+			if report.Problems > 0 {
+				return fmt.Errorf("gateway-status found %d problem(s)", report.Problems)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "only show Gateways in this namespace")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table or json")
+	return cmd
+}
+
+func buildGatewayStatusReport(ctx context.Context, namespace string) (gatewayStatusReport, error) {
+	var classes kubeutil.GatewayClassList
+	if err := kubeutil.GetClusterJSON(ctx, kubectlOutputCtxFn, kubeutil.GatewayClassResource, &classes); err != nil {
+		return gatewayStatusReport{}, err
+	}
+	var gateways kubeutil.GatewayList
+	if err := kubeutil.GetJSON(ctx, kubectlOutputCtxFn, namespace, kubeutil.GatewayResource, &gateways); err != nil {
+		return gatewayStatusReport{}, err
+	}
+	var routes kubeutil.HTTPRouteList
+	if err := kubeutil.GetJSON(ctx, kubectlOutputCtxFn, "", kubeutil.HTTPRouteResource, &routes); err != nil {
+		return gatewayStatusReport{}, err
+	}
+	report := gatewayStatusReport{Gateways: kubeutil.SummarizeGateways(classes.Items, gateways.Items, routes.Items)}
+	for _, gateway := range report.Gateways {
+		report.Problems += len(gateway.Problems)
+	}
+	return report, nil
+}
+
+func renderGatewayStatus(report gatewayStatusReport, output string) (string, error) {
+	if output == "json" {
+		return ui.RenderJSON(report)
+	}
+	if len(report.Gateways) == 0 {
+		return "No Gateways found.", nil
+	}
+	var gatewayRows, routeRows [][]string
+	var problems []string
+	for _, gateway := range report.Gateways {
+		name := gateway.Namespace + "/" + gateway.Name
+		addresses := strings.Join(gateway.Addresses, ", ")
+		if addresses == "" {
+			addresses = "-"
+		}
+		routes := strconv.Itoa(len(gateway.Routes))
+		if gateway.RoutesFailed > 0 {
+			routes += fmt.Sprintf(" (%d failing)", gateway.RoutesFailed)
+		}
+		gatewayRows = append(gatewayRows, []string{name, gateway.Class, gateway.ClassState, gateway.Programmed, gateway.Accepted, addresses, routes})
+		for _, route := range gateway.Routes {
+			routeRows = append(routeRows, []string{name, route.Namespace + "/" + route.Name, route.Accepted, route.ResolvedRefs})
+		}
+		for _, problem := range gateway.Problems {
+			problems = append(problems, fmt.Sprintf("  %s: %s", name, problem))
+		}
+	}
+	sections := []string{ui.Table([]string{"GATEWAY", "CLASS", "CLASS ACCEPTED", "PROGRAMMED", "ACCEPTED", "ADDRESSES", "ROUTES"}, gatewayRows)}
+	if len(routeRows) > 0 {
+		sections = append(sections, ui.Table([]string{"GATEWAY", "HTTPROUTE", "ACCEPTED", "RESOLVEDREFS"}, routeRows))
+	}
+	if len(problems) > 0 {
+		sections = append(sections, "Problems:\n"+strings.Join(problems, "\n"))
+	}
+	return strings.Join(sections, "\n"), nil
+}
@@ -0,0 +1,67 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/kubeutil"
+	"homeops-cli/internal/testutil"
+)
+
+func stubGatewayStatusCluster(t *testing.T, gateways string) *[][]string {
+	t.Helper()
+	var calls [][]string
+	testutil.Swap(t, &kubectlOutputCtxFn, func(_ context.Context, args ...string) ([]byte, error) {
+		calls = append(calls, args)
+		switch args[1] {
+		case kubeutil.GatewayClassResource:
+			return []byte(`{"items":[{"metadata":{"name":"cilium"},"status":{"conditions":[{"type":"Accepted","status":"True"}]}}]}`), nil
+		case kubeutil.GatewayResource:
+			return []byte(gateways), nil
+		case kubeutil.HTTPRouteResource:
+			return []byte(`{"items":[
+  {"metadata":{"namespace":"default","name":"echo"},"spec":{"parentRefs":[{"name":"external","namespace":"network"}]},
+   "status":{"parents":[{"parentRef":{"name":"external","namespace":"network"},"conditions":[{"type":"Accepted","status":"True"},{"type":"ResolvedRefs","status":"True"}]}]}}
+]}`), nil
+		}
+		return []byte(`{"items":[]}`), nil
+	})
+	return &calls
+}
+
+func TestGatewayStatusCommand(t *testing.T) {
+	calls := stubGatewayStatusCluster(t, `{"items":[
+  {"metadata":{"namespace":"network","name":"external"},"spec":{"gatewayClassName":"cilium"},
+   "status":{"addresses":[{"type":"IPAddress","value":"192.168.122.201"}],"conditions":[{"type":"Programmed","status":"True"},{"type":"Accepted","status":"True"}]}}
+]}`)
+	out, err := testutil.ExecuteCommand(newGatewayStatusCommand(), "-n", "network")
+	require.NoError(t, err)
+	assert.Contains(t, out, "network/external")
+	assert.Contains(t, out, "192.168.122.201")
+	assert.Contains(t, out, "default/echo")
+	assert.NotContains(t, out, "Problems:")
+	assert.Equal(t, []string{"get", kubeutil.GatewayResource, "--namespace", "network", "-o", "json"}, (*calls)[1])
+	assert.Equal(t, []string{"get", kubeutil.HTTPRouteResource, "-A", "-o", "json"}, (*calls)[2], "routes are read cluster-wide")
+
+	stubGatewayStatusCluster(t, `{"items":[
+  {"metadata":{"namespace":"network","name":"external"},"spec":{"gatewayClassName":"cilium"},
+   "status":{"conditions":[{"type":"Programmed","status":"False","reason":"AddressNotAssigned"}]}}
+]}`)
+	out, err = testutil.ExecuteCommand(newGatewayStatusCommand(), "-o", "json")
+	require.ErrorContains(t, err, "gateway-status found 2 problem(s)")
+	var report gatewayStatusReport
+	require.NoError(t, json.NewDecoder(strings.NewReader(out)).Decode(&report))
+	require.Len(t, report.Gateways, 1)
+	assert.Equal(t, []string{"not Programmed: False (AddressNotAssigned)", "no address assigned"}, report.Gateways[0].Problems)
+	assert.Equal(t, 1, report.Gateways[0].RoutesOK)
+
+	stubGatewayStatusCluster(t, `{"items":[]}`)
+	out, err = testutil.ExecuteCommand(newGatewayStatusCommand())
+	require.NoError(t, err)
+	assert.Contains(t, out, "No Gateways found.")
+}
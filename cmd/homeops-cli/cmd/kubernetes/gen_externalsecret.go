@@ -0,0 +1,349 @@
+package kubernetes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"homeops-cli/cmd/completion"
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/secrets"
+)
+
+const (
+	externalSecretSchemaHeader = "# yaml-language-server: $schema=https://kubernetes-schema.pages.dev/external-secrets.io/externalsecret_v1.json"
+	externalSecretFile         = "externalsecret.yaml"
+	defaultSecretStore         = "onepassword"
+)
+
+var (
+	genExternalSecretFieldsFn   = secrets.ItemFields
+	genExternalSecretRepoRootFn = common.RepoRoot
+
+	// secretKeyInvalid matches runs of characters a Secret data key may not
+	// contain, so a 1Password label such as "API key" becomes "API_key".
+	secretKeyInvalid = regexp.MustCompile(`[^-._a-zA-Z0-9]+`)
+)
+
+type externalSecretManifest struct {
+	APIVersion string             `yaml:"apiVersion"`
+	Kind       string             `yaml:"kind"`
+	Metadata   externalSecretMeta `yaml:"metadata"`
+	Spec       externalSecretSpec `yaml:"spec"`
+}
+
+type externalSecretMeta struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+type externalSecretSpec struct {
+	SecretStoreRef struct {
+		Kind string `yaml:"kind"`
+		Name string `yaml:"name"`
+	} `yaml:"secretStoreRef"`
+	Target struct {
+		Name string `yaml:"name"`
+	} `yaml:"target"`
+	Data []externalSecretData `yaml:"data"`
+}
+
+type externalSecretData struct {
+	SecretKey string `yaml:"secretKey"`
+	RemoteRef struct {
+		Key      string `yaml:"key"`
+		Property string `yaml:"property"`
+	} `yaml:"remoteRef"`
+}
+
+type genExternalSecretOptions struct {
+	OpRef       string
+	Namespace   string
+	Name        string
+	App         string
+	Store       string
+	Keys        []string
+	AllFields   bool
+	Apply       bool
+	WriteToRepo bool
+}
+
+func newGenExternalSecretCommand() *cobra.Command {
+	var opts genExternalSecretOptions
+	cmd := &cobra.Command{
+		Use:   "gen-externalsecret",
+		Short: "Generate an ExternalSecret from an existing 1Password item's fields",
+		Long: `Read the field names of the 1Password item at --op-ref (never the values) and
+generate an ExternalSecret that maps the selected fields from the
+ClusterSecretStore (default onepassword) into a Secret. --keys must name
+fields the item really has, so a typo fails here instead of at sync time;
+--all-fields takes every field, and with neither the fields are picked from a
+multi-select. --name names both the ExternalSecret and the Secret it creates
+(default: the item name).
+
+The store is checked in the cluster when it is reachable. The manifest is
+printed by default; --apply applies it, and --write-to-repo writes it to
+kubernetes/apps/<namespace>/<app>/app/externalsecret.yaml (appending a new
+document when that file exists) and lists it in the directory's
+kustomization.yaml.`,
+		SilenceUsage: true,
+		Example: `  homeops-cli k8s gen-externalsecret --op-ref op://Infrastructure/myapp --namespace media
+  homeops-cli k8s gen-externalsecret --op-ref op://Infrastructure/myapp --namespace media --name myapp-secret --keys user,pass
+  homeops-cli k8s gen-externalsecret --op-ref op://Infrastructure/myapp --namespace media --all-fields --write-to-repo`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runGenExternalSecret(cmd, opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.OpRef, "op-ref", "", "1Password item as op://vault/item")
+	cmd.Flags().StringVarP(&opts.Namespace, "namespace", "n", "", "namespace of the ExternalSecret")
+	cmd.Flags().StringVar(&opts.Name, "name", "", "name of the ExternalSecret and its target Secret (default: the item name)")
+	cmd.Flags().StringVar(&opts.App, "app", "", "app directory for --write-to-repo (default: the item name)")
+	cmd.Flags().StringVar(&opts.Store, "store", defaultSecretStore, "ClusterSecretStore to read from")
+	cmd.Flags().StringSliceVar(&opts.Keys, "keys", nil, "comma-separated item fields to map")
+	cmd.Flags().BoolVar(&opts.AllFields, "all-fields", false, "map every field of the item")
+	cmd.Flags().BoolVar(&opts.Apply, "apply", false, "apply the manifest to the cluster")
+	cmd.Flags().BoolVar(&opts.WriteToRepo, "write-to-repo", false, "write the manifest into the repo's app layout")
+	_ = cmd.MarkFlagRequired("op-ref")
+	_ = cmd.MarkFlagRequired("namespace")
+	cmd.MarkFlagsMutuallyExclusive("keys", "all-fields")
+	cmd.MarkFlagsMutuallyExclusive("apply", "write-to-repo")
+	_ = cmd.RegisterFlagCompletionFunc("namespace", completion.ValidNamespaces)
+	return cmd
+}
+
+func runGenExternalSecret(cmd *cobra.Command, opts genExternalSecretOptions) error {
+	vault, item, err := secrets.ParseItemRef(opts.OpRef)
+	if err != nil {
+		return err
+	}
+	if opts.Name == "" {
+		opts.Name = item
+	}
+	if opts.App == "" {
+		opts.App = item
+	}
+	available, err := genExternalSecretFieldsFn(vault, item)
+	if err != nil {
+		return err
+	}
+	if len(available) == 0 {
+		return fmt.Errorf("1Password item %s has no fields to map", opts.OpRef)
+	}
+	fields, err := selectExternalSecretFields(opts, available)
+	if err != nil {
+		return err
+	}
+	if err := checkSecretStore(opts.Store); err != nil {
+		return err
+	}
+
+	manifest := buildExternalSecret(opts.Name, opts.Namespace, opts.Store, item, fields)
+	if opts.WriteToRepo {
+		manifest.Metadata.Namespace = "" // Flux sets it from the app's Kustomization
+	}
+	rendered, err := renderExternalSecret(manifest)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case opts.Apply:
+		if err := kubectlApplyManifestFn(rendered); err != nil {
+			return fmt.Errorf("failed to apply ExternalSecret %s/%s: %w", opts.Namespace, opts.Name, err)
+		}
+		common.NewColorLogger().Success("Applied ExternalSecret %s/%s with %d field(s)", opts.Namespace, opts.Name, len(fields))
+	case opts.WriteToRepo:
+		root, err := genExternalSecretRepoRootFn()
+		if err != nil {
+			return err
+		}
+		path, err := writeExternalSecretToRepo(root, opts.Namespace, opts.App, opts.Name, rendered)
+		if err != nil {
+			return err
+		}
+		common.NewColorLogger().Success("Wrote ExternalSecret %s with %d field(s) to %s", opts.Name, len(fields), path)
+	default:
+		_, _ = fmt.Fprint(cmd.OutOrStdout(), rendered)
+	}
+	return nil
+}
+
+// selectExternalSecretFields resolves --keys/--all-fields, or prompts, against
+// the fields the item really has.
+func selectExternalSecretFields(opts genExternalSecretOptions, available []string) ([]string, error) {
+	if opts.AllFields {
+		return available, nil
+	}
+	if len(opts.Keys) == 0 {
+		selected, err := chooseMultiOptionFn(fmt.Sprintf("Fields of %s to map:", opts.OpRef), available, 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(selected) == 0 {
+			return nil, fmt.Errorf("no fields selected")
+		}
+		return selected, nil
+	}
+	known := make(map[string]bool, len(available))
+	for _, field := range available {
+		known[field] = true
+	}
+	var missing []string
+	for _, key := range opts.Keys {
+		if !known[key] {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("1Password item %s has no field(s) %s (available: %s)", opts.OpRef, strings.Join(missing, ", "), strings.Join(available, ", "))
+	}
+	return opts.Keys, nil
+}
+
+// checkSecretStore fails when the cluster answers that the store does not
+// exist; an unreachable cluster only skips the check, so manifests can be
+// generated offline.
+func checkSecretStore(store string) error {
+	output, err := commandCombinedOutputFn("kubectl", "get", "clustersecretstore", store, "-o", "name")
+	if err == nil {
+		return nil
+	}
+	if strings.Contains(string(output), "NotFound") || strings.Contains(string(output), "not found") {
+		return fmt.Errorf("ClusterSecretStore %q does not exist in the cluster (pass --store)", store)
+	}
+	common.NewColorLogger().Warn("Could not check ClusterSecretStore %q (cluster unreachable?): skipping", store)
+	return nil
+}
+
+func buildExternalSecret(name, namespace, store, item string, fields []string) externalSecretManifest {
+	manifest := externalSecretManifest{
+		APIVersion: "external-secrets.io/v1",
+		Kind:       "ExternalSecret",
+		Metadata:   externalSecretMeta{Name: name, Namespace: namespace},
+	}
+	manifest.Spec.SecretStoreRef.Kind = "ClusterSecretStore"
+	manifest.Spec.SecretStoreRef.Name = store
+	manifest.Spec.Target.Name = name
+	for _, field := range fields {
+		data := externalSecretData{SecretKey: strings.Trim(secretKeyInvalid.ReplaceAllString(field, "_"), "_")}
+		data.RemoteRef.Key = item
+		data.RemoteRef.Property = field
+		manifest.Spec.Data = append(manifest.Spec.Data, data)
+	}
+	return manifest
+}
+
+func renderExternalSecret(manifest externalSecretManifest) (string, error) {
+	var buf bytes.Buffer
+	buf.WriteString("---\n" + externalSecretSchemaHeader + "\n")
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(manifest); err != nil {
+		return "", fmt.Errorf("failed to render ExternalSecret: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("failed to render ExternalSecret: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// writeExternalSecretToRepo places the manifest in the app's directory and
+// lists the file in its kustomization.yaml, creating either when missing.
+func writeExternalSecretToRepo(root, namespace, app, name, rendered string) (string, error) {
+	dir := filepath.Join(root, "kubernetes", "apps", namespace, app, "app")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	path := filepath.Join(dir, externalSecretFile)
+	existing, err := os.ReadFile(path) // #nosec G304 -- path under the repository root
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		existing = nil
+	case err != nil:
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	case externalSecretDefined(existing, name):
+		return "", fmt.Errorf("%s already defines ExternalSecret %q", path, name)
+	}
+	content := rendered
+	if len(existing) > 0 {
+		content = strings.TrimRight(string(existing), "\n") + "\n" + rendered
+	}
+	if err := writeRepoFile(path, []byte(content)); err != nil {
+		return "", err
+	}
+	if err := addKustomizationResource(filepath.Join(dir, "kustomization.yaml"), "./"+externalSecretFile); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func externalSecretDefined(content []byte, name string) bool {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var doc struct {
+			Kind     string `yaml:"kind"`
+			Metadata struct {
+				Name string `yaml:"name"`
+			} `yaml:"metadata"`
+		}
+		if err := decoder.Decode(&doc); err != nil {
+			return false
+		}
+		if doc.Kind == "ExternalSecret" && doc.Metadata.Name == name {
+			return true
+		}
+	}
+}
+
+// addKustomizationResource inserts resource at the top of the resources list
+// as a line edit, so the file's comments and ordering are left as they are.
+func addKustomizationResource(path, resource string) error {
+	raw, err := os.ReadFile(path) // #nosec G304 -- path under the repository root
+	if errors.Is(err, os.ErrNotExist) {
+		content := "---\n# yaml-language-server: $schema=https://json.schemastore.org/kustomization\n" +
+			"apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - " + resource + "\n"
+		return writeRepoFile(path, []byte(content))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var doc struct {
+		Resources []string `yaml:"resources"`
+	}
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, existing := range doc.Resources {
+		if strings.TrimPrefix(existing, "./") == strings.TrimPrefix(resource, "./") {
+			return nil
+		}
+	}
+	lines := strings.SplitAfter(string(raw), "\n")
+	for i, line := range lines {
+		if strings.TrimRight(line, "\r\n") == "resources:" {
+			lines = append(lines[:i+1], append([]string{"  - " + resource + "\n"}, lines[i+1:]...)...)
+			return writeRepoFile(path, []byte(strings.Join(lines, "")))
+		}
+	}
+	content := strings.TrimRight(string(raw), "\n") + "\nresources:\n  - " + resource + "\n"
+	return writeRepoFile(path, []byte(content))
+}
+
+// writeRepoFile keeps an existing file's mode; new files are 0644 like the
+// rest of the checkout.
+func writeRepoFile(path string, data []byte) error {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.WriteFile(path, data, mode); err != nil { // #nosec G306 -- repo manifests are world-readable
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
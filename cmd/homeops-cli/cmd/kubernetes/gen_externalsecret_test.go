@@ -0,0 +1,122 @@
+package kubernetes
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/testutil"
+)
+
+var genExternalSecretItemFields = []string{"username", "password", "MYAPP_API_KEY", "S3 access key"}
+
+func fakeGenExternalSecret(t *testing.T, storeOutput string, storeErr error) {
+	t.Helper()
+	testutil.Swap(t, &genExternalSecretFieldsFn, func(vault, item string) ([]string, error) {
+		assert.Equal(t, "Infrastructure", vault)
+		assert.Equal(t, "myapp", item)
+		return genExternalSecretItemFields, nil
+	})
+	testutil.Swap(t, &commandCombinedOutputFn, func(name string, args ...string) ([]byte, error) {
+		assert.Equal(t, []string{"get", "clustersecretstore", "onepassword", "-o", "name"}, args)
+		return []byte(storeOutput), storeErr
+	})
+}
+
+func assertGenExternalSecretGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", "gen-externalsecret", name)
+	if os.Getenv("UPDATE_GOLDEN") == "1" {
+		require.NoError(t, os.WriteFile(path, []byte(got), 0o600))
+	}
+	want, err := os.ReadFile(path)
+	require.NoError(t, err, "missing golden %s (run with UPDATE_GOLDEN=1)", path)
+	assert.Equal(t, string(want), got)
+}
+
+func TestGenExternalSecretPrintsSelectedKeys(t *testing.T) {
+	fakeGenExternalSecret(t, "clustersecretstore.external-secrets.io/onepassword\n", nil)
+
+	out, err := testutil.ExecuteCommand(newGenExternalSecretCommand(),
+		"--op-ref", "op://Infrastructure/myapp", "--namespace", "media", "--name", "myapp-secret",
+		"--keys", "username,password")
+	require.NoError(t, err)
+	assertGenExternalSecretGolden(t, "keys.golden", out)
+}
+
+func TestGenExternalSecretPromptsForFields(t *testing.T) {
+	fakeGenExternalSecret(t, "", errors.New("dial tcp: connection refused"))
+	testutil.Swap(t, &chooseMultiOptionFn, func(prompt string, options []string, limit int) ([]string, error) {
+		assert.Equal(t, genExternalSecretItemFields, options)
+		return []string{"MYAPP_API_KEY", "S3 access key"}, nil
+	})
+
+	out, err := testutil.ExecuteCommand(newGenExternalSecretCommand(),
+		"--op-ref", "op://Infrastructure/myapp", "--namespace", "media")
+	require.NoError(t, err, "an unreachable cluster only skips the store check")
+	assertGenExternalSecretGolden(t, "prompt.golden", out)
+}
+
+func TestGenExternalSecretRejectsUnknownKeysAndMissingStore(t *testing.T) {
+	fakeGenExternalSecret(t, "", nil)
+	_, err := testutil.ExecuteCommand(newGenExternalSecretCommand(),
+		"--op-ref", "op://Infrastructure/myapp", "--namespace", "media", "--keys", "username,pasword")
+	assert.ErrorContains(t, err, `no field(s) pasword (available: username, password, MYAPP_API_KEY, S3 access key)`)
+
+	fakeGenExternalSecret(t, `Error from server (NotFound): clustersecretstores.external-secrets.io "onepassword" not found`, errors.New("exit status 1"))
+	_, err = testutil.ExecuteCommand(newGenExternalSecretCommand(),
+		"--op-ref", "op://Infrastructure/myapp", "--namespace", "media", "--all-fields")
+	assert.ErrorContains(t, err, `ClusterSecretStore "onepassword" does not exist`)
+}
+
+func TestGenExternalSecretApplies(t *testing.T) {
+	fakeGenExternalSecret(t, "", nil)
+	var applied string
+	testutil.Swap(t, &kubectlApplyManifestFn, func(manifest string) error {
+		applied = manifest
+		return nil
+	})
+
+	out, err := testutil.ExecuteCommand(newGenExternalSecretCommand(),
+		"--op-ref", "op://Infrastructure/myapp", "--namespace", "media", "--all-fields", "--apply")
+	require.NoError(t, err)
+	assert.Empty(t, out)
+	assert.Contains(t, applied, "namespace: media")
+	assert.Contains(t, applied, "property: S3 access key")
+}
+
+func TestGenExternalSecretWritesToRepo(t *testing.T) {
+	fakeGenExternalSecret(t, "", nil)
+	root := t.TempDir()
+	testutil.Swap(t, &genExternalSecretRepoRootFn, func() (string, error) { return root, nil })
+	dir := filepath.Join(root, "kubernetes", "apps", "media", "myapp", "app")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte(`---
+# yaml-language-server: $schema=https://json.schemastore.org/kustomization
+apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+  - ./helmrelease.yaml
+`), 0o644))
+
+	args := []string{"--op-ref", "op://Infrastructure/myapp", "--namespace", "media", "--keys", "MYAPP_API_KEY", "--write-to-repo"}
+	_, err := testutil.ExecuteCommand(newGenExternalSecretCommand(), args...)
+	require.NoError(t, err)
+	_, err = testutil.ExecuteCommand(newGenExternalSecretCommand(),
+		"--op-ref", "op://Infrastructure/myapp", "--namespace", "media", "--name", "myapp-db", "--keys", "username,password", "--write-to-repo")
+	require.NoError(t, err)
+
+	manifest, err := os.ReadFile(filepath.Join(dir, "externalsecret.yaml"))
+	require.NoError(t, err)
+	assertGenExternalSecretGolden(t, "repo-externalsecret.golden", string(manifest))
+	kustomization, err := os.ReadFile(filepath.Join(dir, "kustomization.yaml"))
+	require.NoError(t, err)
+	assertGenExternalSecretGolden(t, "repo-kustomization.golden", string(kustomization))
+
+	_, err = testutil.ExecuteCommand(newGenExternalSecretCommand(), args...)
+	assert.ErrorContains(t, err, `already defines ExternalSecret "myapp"`)
+}
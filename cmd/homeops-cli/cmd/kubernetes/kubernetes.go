@@ -228,15 +228,11 @@ func resolveKustomizationFilePath(ksPath string) (string, error) {
 }
 
 func findGitRoot() (string, error) {
-	if gitRoot, err := common.FindGitRoot("."); err == nil {
-		return gitRoot, nil
-	}
-
-	gitRootOutput, err := common.Command("git", "rev-parse", "--show-toplevel").Output()
+	root, err := common.RepoRoot()
 	if err != nil {
-		return "", fmt.Errorf("failed to find git repository root: %w (make sure you're in a git repository)", err)
+		return "", fmt.Errorf("failed to find git repository root: %w", err)
 	}
-	return strings.TrimSpace(string(gitRootOutput)), nil
+	return root, nil
 }
 
 func findKustomizationFiles(appsDir string) ([]string, error) {
@@ -502,13 +498,26 @@ func NewCommand() *cobra.Command {
 		newForceSyncExternalSecretCommand(),
 		newRenderKsCommand(),
 		newDiffCommand(),
+		newPreviewCommand(),
+		newRolloutLagCommand(),
 		newApplyKsCommand(),
 		newDeleteKsCommand(),
 		newDoctorCommand(),
 		newNetDoctorCommand(),
+		newGatewayStatusCommand(),
+		newDNSReportCommand(),
+		newTLSProbeCommand(),
 		newStorageReportCommand(),
+		newStorageGCCommand(),
+		newPressureReportCommand(),
+		newObjectReportCommand(),
+		newDrainImpactCommand(),
 		newRightSizeCommand(),
 		newFluxTreeCommand(),
+		newFluxSuspendCommand(),
+		newFluxResumeCommand(),
+		newGenExternalSecretCommand(),
+		newSopsCommand(),
 		newUpgradeStatusCommand(),
 		newUpgradePlanCommand(),
 		newSupportBundleCommand(),
@@ -0,0 +1,587 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"homeops-cli/internal/ui"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	objectReportPageSize    = 500
+	objectReportSampleSize  = 5
+	objectReportDefaultTop  = 10
+	objectReportTopEventors = 10
+	// etcd's --quota-backend-bytes default; members on etcd 3.6+ report
+	// their configured quota directly.
+	objectReportDefaultEtcdQuota = 2 << 30
+	// objectReportEtcdWarnFraction flags members whose DB is this close to
+	// the space alarm.
+	objectReportEtcdWarnFraction = 0.8
+	// A type is flagged as growing suspiciously when, against --compare, it
+	// gained at least objectReportGrowthMinObjects objects and grew by at
+	// least objectReportGrowthFraction (new types only need the minimum).
+	objectReportGrowthMinObjects = 1000
+	objectReportGrowthFraction   = 0.5
+)
+
+// objectReportSkippedGroups serve aggregated APIs that are not stored in
+// etcd, or duplicate another group's storage (events.k8s.io events are the
+// core events).
+var objectReportSkippedGroups = map[string]bool{
+	"metrics.k8s.io":          true,
+	"custom.metrics.k8s.io":   true,
+	"external.metrics.k8s.io": true,
+	"events.k8s.io":           true,
+}
+
+var objectReportNowFn = time.Now
+
+type objectAPIResourceList struct {
+	GroupVersion string `json:"groupVersion"`
+	Resources    []struct {
+		Name       string   `json:"name"`
+		Namespaced bool     `json:"namespaced"`
+		Kind       string   `json:"kind"`
+		Verbs      []string `json:"verbs"`
+	} `json:"resources"`
+}
+
+type objectAPIGroupList struct {
+	Groups []struct {
+		Name             string `json:"name"`
+		PreferredVersion struct {
+			GroupVersion string `json:"groupVersion"`
+		} `json:"preferredVersion"`
+	} `json:"groups"`
+}
+
+type objectListPage struct {
+	Metadata struct {
+		Continue           string `json:"continue"`
+		RemainingItemCount *int64 `json:"remainingItemCount"`
+	} `json:"metadata"`
+	Items []json.RawMessage `json:"items"`
+}
+
+// objectResourceType is one listable resource found through discovery.
+type objectResourceType struct {
+	Resource     string // plural, qualified with the group for non-core types
+	Kind         string
+	GroupVersion string
+	Namespaced   bool
+}
+
+func (t objectResourceType) listPath() string {
+	if t.GroupVersion == "v1" {
+		return "/api/v1/" + strings.SplitN(t.Resource, ".", 2)[0]
+	}
+	return "/apis/" + t.GroupVersion + "/" + strings.SplitN(t.Resource, ".", 2)[0]
+}
+
+type objectTypeReport struct {
+	Resource       string `json:"resource"`
+	Kind           string `json:"kind"`
+	Namespaced     bool   `json:"namespaced"`
+	Count          int64  `json:"count"`
+	AvgBytes       int64  `json:"avg_bytes"`
+	EstimatedBytes int64  `json:"estimated_bytes"`
+	PreviousCount  *int64 `json:"previous_count,omitempty"`
+	Growth         int64  `json:"growth,omitempty"`
+	Suspicious     bool   `json:"suspicious,omitempty"`
+}
+
+type objectEventProducer struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Events    int64  `json:"events"`
+}
+
+type objectEventReport struct {
+	Count        int64                 `json:"count"`
+	TopProducers []objectEventProducer `json:"top_producers"`
+}
+
+type objectEtcdMember struct {
+	Endpoint     string  `json:"endpoint"`
+	MemberID     string  `json:"member_id"`
+	DBSizeBytes  int64   `json:"db_size_bytes"`
+	InUseBytes   int64   `json:"db_size_in_use_bytes"`
+	QuotaBytes   int64   `json:"quota_bytes"`
+	QuotaPercent float64 `json:"quota_percent"`
+	NearQuota    bool    `json:"near_quota,omitempty"`
+}
+
+type objectReport struct {
+	GeneratedAt    string             `json:"generated_at"`
+	ComparedTo     string             `json:"compared_to,omitempty"` // generated_at of the --compare report
+	TotalObjects   int64              `json:"total_objects"`
+	EstimatedBytes int64              `json:"estimated_bytes"`
+	Types          []objectTypeReport `json:"types"`
+	Events         objectEventReport  `json:"events"`
+	Etcd           []objectEtcdMember `json:"etcd"`
+	Suspicious     int                `json:"suspicious"`
+	Errors         []string           `json:"errors,omitempty"`
+}
+
+func newObjectReportCommand() *cobra.Command {
+	var (
+		output    string
+		top       int
+		compare   string
+		etcdQuota string
+	)
+	cmd := &cobra.Command{
+		Use:   "object-report",
+		Short: "Count objects and estimate their etcd footprint per resource type",
+		Long: `Count every listable resource type discovered from the API server,
+cluster-wide, and estimate its stored size as count x the average encoded
+size of a few sampled objects. Lists are paged (500 per request); where the
+API server reports remainingItemCount the first page is enough to count.
+Sizes are JSON sizes, so built-in types stored as protobuf are overestimated.
+
+The report shows the top --top types by count and by estimated size, events
+with their top producers (by involvedObject), and each etcd member's DB size
+against its quota, read with etcdctl through the etcd pod like 'k8s etcd
+status'. etcd 3.6+ reports its quota; older members use --etcd-quota, or
+etcd's 2 GiB default.
+
+--compare diffs counts against a previous 'object-report -o json' run and flags
+types that gained at least 1000 objects and grew by 50% or more. Exits
+non-zero when a type is flagged or an etcd member is above 80% of its quota.`,
+		SilenceUsage: true,
+		Example: `  homeops-cli k8s object-report
+  homeops-cli k8s object-report --output json > objects-$(date +%F).json
+  homeops-cli k8s object-report --compare objects-2026-10-01.json --top 20`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := ui.ValidateOutputFormat(output); err != nil {
+				return err
+			}
+			if top <= 0 {
+				return fmt.Errorf("--top must be positive")
+			}
+			var quota int64
+			if etcdQuota != "" {
+				parsed, err := resource.ParseQuantity(etcdQuota)
+				if err != nil || parsed.Value() <= 0 {
+					return fmt.Errorf("invalid --etcd-quota %q: expected a size such as 8Gi", etcdQuota)
+				}
+				quota = parsed.Value()
+			}
+			var previous *objectReport
+			if compare != "" {
+				loaded, err := loadObjectReport(compare)
+				if err != nil {
+					return err
+				}
+				previous = loaded
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), kubernetesDefaultCommandTimeout)
+			defer cancel()
+			report := buildObjectReport(ctx, quota)
+			if previous != nil {
+				compareObjectReports(&report, *previous)
+			}
+			rendered, err := renderObjectReport(report, output, top)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), rendered)
+			if report.Suspicious > 0 {
+				return fmt.Errorf("%d resource type(s) grew suspiciously since %s", report.Suspicious, report.ComparedTo)
+			}
+			for _, member := range report.Etcd {
+				if member.NearQuota {
+					return fmt.Errorf("etcd member %s is at %.0f%% of its quota", member.Endpoint, member.QuotaPercent)
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table or json")
+	cmd.Flags().IntVar(&top, "top", objectReportDefaultTop, "number of types to show by count and by size")
+	cmd.Flags().StringVar(&compare, "compare", "", "previous object-report JSON to diff counts against")
+	cmd.Flags().StringVar(&etcdQuota, "etcd-quota", "", "etcd quota-backend-bytes for members that do not report one (default 2Gi)")
+	return cmd
+}
+
+func buildObjectReport(ctx context.Context, etcdQuota int64) objectReport {
+	report := objectReport{GeneratedAt: objectReportNowFn().UTC().Format(time.RFC3339)}
+	types, problems := discoverObjectResourceTypes(ctx)
+	report.Errors = append(report.Errors, problems...)
+
+	for _, resourceType := range types {
+		var row objectTypeReport
+		var err error
+		if resourceType.Resource == "events" {
+			row, report.Events, err = countObjectEvents(ctx, resourceType)
+		} else {
+			row, err = countObjectResourceType(ctx, resourceType)
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("count %s: %v", resourceType.Resource, err))
+			continue
+		}
+		report.TotalObjects += row.Count
+		report.EstimatedBytes += row.EstimatedBytes
+		report.Types = append(report.Types, row)
+	}
+	sortObjectTypes(report.Types, func(row objectTypeReport) int64 { return row.Count })
+
+	members, err := objectReportEtcdMembers(ctx, etcdQuota)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("etcd status unavailable: %v", err))
+	}
+	report.Etcd = members
+	return report
+}
+
+// discoverObjectResourceTypes walks /api/v1 and the preferred version of
+// every group in /apis, keeping top-level resources that support list. A
+// group whose discovery fails (an unavailable aggregated API) is reported and
+// skipped.
+func discoverObjectResourceTypes(ctx context.Context) ([]objectResourceType, []string) {
+	var problems []string
+	var types []objectResourceType
+	var core objectAPIResourceList
+	if err := objectReportGetRaw(ctx, "/api/v1", &core); err != nil {
+		problems = append(problems, fmt.Sprintf("discover core API: %v", err))
+	} else {
+		types = append(types, listableObjectTypes(core, "")...)
+	}
+
+	var groups objectAPIGroupList
+	if err := objectReportGetRaw(ctx, "/apis", &groups); err != nil {
+		return types, append(problems, fmt.Sprintf("discover API groups: %v", err))
+	}
+	for _, group := range groups.Groups {
+		if objectReportSkippedGroups[group.Name] {
+			continue
+		}
+		var list objectAPIResourceList
+		if err := objectReportGetRaw(ctx, "/apis/"+group.PreferredVersion.GroupVersion, &list); err != nil {
+			problems = append(problems, fmt.Sprintf("discover %s: %v", group.PreferredVersion.GroupVersion, err))
+			continue
+		}
+		types = append(types, listableObjectTypes(list, group.Name)...)
+	}
+	return types, problems
+}
+
+func listableObjectTypes(list objectAPIResourceList, group string) []objectResourceType {
+	var types []objectResourceType
+	for _, apiResource := range list.Resources {
+		if strings.Contains(apiResource.Name, "/") || !slices.Contains(apiResource.Verbs, "list") {
+			continue // subresources and create-only reviews are not stored objects
+		}
+		name := apiResource.Name
+		if group != "" {
+			name += "." + group
+		}
+		types = append(types, objectResourceType{
+			Resource: name, Kind: apiResource.Kind, GroupVersion: list.GroupVersion, Namespaced: apiResource.Namespaced,
+		})
+	}
+	return types
+}
+
+// countObjectResourceType counts one type and samples the first page's items
+// for the average size. remainingItemCount, when the API server sends it,
+// saves paging through the rest.
+func countObjectResourceType(ctx context.Context, resourceType objectResourceType) (objectTypeReport, error) {
+	row := objectTypeReport{Resource: resourceType.Resource, Kind: resourceType.Kind, Namespaced: resourceType.Namespaced}
+	var sampled, sampledBytes int64
+	err := pageObjectList(ctx, resourceType.listPath(), func(page objectListPage) bool {
+		for _, item := range page.Items {
+			if sampled < objectReportSampleSize {
+				sampled++
+				sampledBytes += int64(len(item))
+			}
+		}
+		row.Count += int64(len(page.Items))
+		if page.Metadata.RemainingItemCount != nil {
+			row.Count += *page.Metadata.RemainingItemCount
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return objectTypeReport{}, err
+	}
+	if sampled > 0 {
+		row.AvgBytes = sampledBytes / sampled
+	}
+	row.EstimatedBytes = row.AvgBytes * row.Count
+	return row, nil
+}
+
+// countObjectEvents pages through every event: producers need each event's
+// involvedObject, so remainingItemCount is not enough here.
+func countObjectEvents(ctx context.Context, resourceType objectResourceType) (objectTypeReport, objectEventReport, error) {
+	row := objectTypeReport{Resource: resourceType.Resource, Kind: resourceType.Kind, Namespaced: resourceType.Namespaced}
+	var totalBytes int64
+	producers := map[objectEventProducer]int64{}
+	err := pageObjectList(ctx, resourceType.listPath(), func(page objectListPage) bool {
+		for _, item := range page.Items {
+			var event struct {
+				InvolvedObject struct {
+					Kind      string `json:"kind"`
+					Namespace string `json:"namespace"`
+					Name      string `json:"name"`
+				} `json:"involvedObject"`
+			}
+			if err := json.Unmarshal(item, &event); err == nil {
+				key := objectEventProducer{Kind: event.InvolvedObject.Kind, Namespace: event.InvolvedObject.Namespace, Name: event.InvolvedObject.Name}
+				producers[key]++
+			}
+			totalBytes += int64(len(item))
+		}
+		row.Count += int64(len(page.Items))
+		return true
+	})
+	if err != nil {
+		return objectTypeReport{}, objectEventReport{}, err
+	}
+	if row.Count > 0 {
+		row.AvgBytes = totalBytes / row.Count
+	}
+	row.EstimatedBytes = totalBytes
+
+	events := objectEventReport{Count: row.Count, TopProducers: []objectEventProducer{}}
+	for producer, count := range producers {
+		producer.Events = count
+		events.TopProducers = append(events.TopProducers, producer)
+	}
+	sort.Slice(events.TopProducers, func(i, j int) bool {
+		a, b := events.TopProducers[i], events.TopProducers[j]
+		if a.Events != b.Events {
+			return a.Events > b.Events
+		}
+		return a.Kind+"/"+namespacedName(a.Namespace, a.Name) < b.Kind+"/"+namespacedName(b.Namespace, b.Name)
+	})
+	if len(events.TopProducers) > objectReportTopEventors {
+		events.TopProducers = events.TopProducers[:objectReportTopEventors]
+	}
+	return row, events, nil
+}
+
+// pageObjectList lists path objectReportPageSize items at a time, following
+// continue tokens while visit returns true.
+func pageObjectList(ctx context.Context, path string, visit func(objectListPage) bool) error {
+	continueToken := ""
+	for {
+		query := url.Values{"limit": {fmt.Sprint(objectReportPageSize)}}
+		if continueToken != "" {
+			query.Set("continue", continueToken)
+		}
+		var page objectListPage
+		if err := objectReportGetRaw(ctx, path+"?"+query.Encode(), &page); err != nil {
+			return err
+		}
+		if !visit(page) || page.Metadata.Continue == "" {
+			return nil
+		}
+		continueToken = page.Metadata.Continue
+	}
+}
+
+func objectReportGetRaw(ctx context.Context, path string, target any) error {
+	raw, err := kubectlOutputCtxFn(ctx, "get", "--raw", path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, target); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// objectReportEtcdMembers reads every member's DB size with etcdctl
+// endpoint status through a healthy etcd pod.
+func objectReportEtcdMembers(ctx context.Context, defaultQuota int64) ([]objectEtcdMember, error) {
+	pod, err := findHealthyEtcdPod(ctx)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := etcdPodExecFn(ctx, pod.Name, etcdctlCommand("endpoint", "status", "--cluster", "-w", "json")...)
+	if err != nil {
+		return nil, fmt.Errorf("read etcd endpoint status through pod %s: %w", pod.Name, err)
+	}
+	var payload []struct {
+		Endpoint string `json:"Endpoint"`
+		Status   struct {
+			Header struct {
+				MemberID uint64 `json:"member_id"`
+			} `json:"header"`
+			DBSize      int64 `json:"dbSize"`
+			DBSizeInUse int64 `json:"dbSizeInUse"`
+			DBSizeQuota int64 `json:"dbSizeQuota"`
+		} `json:"Status"`
+	}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("parse etcd endpoint status: %w", err)
+	}
+	if defaultQuota <= 0 {
+		defaultQuota = objectReportDefaultEtcdQuota
+	}
+	members := make([]objectEtcdMember, 0, len(payload))
+	for _, endpoint := range payload {
+		quota := endpoint.Status.DBSizeQuota
+		if quota <= 0 {
+			quota = defaultQuota
+		}
+		percent := 100 * float64(endpoint.Status.DBSize) / float64(quota)
+		members = append(members, objectEtcdMember{
+			Endpoint: endpoint.Endpoint, MemberID: fmt.Sprintf("%x", endpoint.Status.Header.MemberID),
+			DBSizeBytes: endpoint.Status.DBSize, InUseBytes: endpoint.Status.DBSizeInUse,
+			QuotaBytes: quota, QuotaPercent: percent, NearQuota: percent >= 100*objectReportEtcdWarnFraction,
+		})
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Endpoint < members[j].Endpoint })
+	return members, nil
+}
+
+func loadObjectReport(path string) (*objectReport, error) {
+	raw, err := os.ReadFile(path) // #nosec G304 -- operator-supplied --compare path
+	if err != nil {
+		return nil, fmt.Errorf("read --compare report: %w", err)
+	}
+	var report objectReport
+	if err := json.Unmarshal(raw, &report); err != nil {
+		return nil, fmt.Errorf("parse --compare report %s: %w", path, err)
+	}
+	return &report, nil
+}
+
+// compareObjectReports records each type's previous count and flags
+// suspicious growth.
+func compareObjectReports(report *objectReport, previous objectReport) {
+	report.ComparedTo = previous.GeneratedAt
+	before := make(map[string]int64, len(previous.Types))
+	for _, row := range previous.Types {
+		before[row.Resource] = row.Count
+	}
+	report.Suspicious = 0
+	for i := range report.Types {
+		row := &report.Types[i]
+		count, seen := before[row.Resource]
+		if seen {
+			previousCount := count
+			row.PreviousCount = &previousCount
+		}
+		row.Growth = row.Count - count
+		row.Suspicious = row.Growth >= objectReportGrowthMinObjects &&
+			(!seen || count == 0 || float64(row.Growth) >= objectReportGrowthFraction*float64(count))
+		if row.Suspicious {
+			report.Suspicious++
+		}
+	}
+}
+
+func sortObjectTypes(rows []objectTypeReport, key func(objectTypeReport) int64) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		if key(rows[i]) != key(rows[j]) {
+			return key(rows[i]) > key(rows[j])
+		}
+		return rows[i].Resource < rows[j].Resource
+	})
+}
+
+func renderObjectReport(report objectReport, output string, top int) (string, error) {
+	if output == "json" {
+		return ui.RenderJSON(report)
+	}
+	if err := ui.ValidateOutputFormat(output); err != nil {
+		return "", err
+	}
+	compared := report.ComparedTo != ""
+	headers := []string{"RESOURCE", "COUNT", "AVG SIZE", "EST SIZE"}
+	if compared {
+		headers = append(headers, "CHANGE")
+	}
+	typeRows := func(rows []objectTypeReport) [][]string {
+		if len(rows) > top {
+			rows = rows[:top]
+		}
+		table := make([][]string, 0, len(rows))
+		for _, row := range rows {
+			line := []string{row.Resource, fmt.Sprint(row.Count), humanBytes(row.AvgBytes), humanBytes(row.EstimatedBytes)}
+			if compared {
+				line = append(line, objectGrowthLabel(row))
+			}
+			table = append(table, line)
+		}
+		return table
+	}
+	bySize := append([]objectTypeReport(nil), report.Types...)
+	sortObjectTypes(bySize, func(row objectTypeReport) int64 { return row.EstimatedBytes })
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Objects: %d across %d types (estimated %s)\n", report.TotalObjects, len(report.Types), humanBytes(report.EstimatedBytes))
+	if compared {
+		fmt.Fprintf(&b, "Compared to: %s (%d type(s) flagged)\n", report.ComparedTo, report.Suspicious)
+	}
+	fmt.Fprintf(&b, "\nTop %d by count\n", top)
+	b.WriteString(ui.Table(headers, typeRows(report.Types)))
+	fmt.Fprintf(&b, "\n\nTop %d by estimated size\n", top)
+	b.WriteString(ui.Table(headers, typeRows(bySize)))
+
+	if report.Suspicious > 0 {
+		var flagged []objectTypeReport
+		for _, row := range report.Types {
+			if row.Suspicious {
+				flagged = append(flagged, row)
+			}
+		}
+		b.WriteString("\n\nGrowing suspiciously\n")
+		b.WriteString(ui.Table(headers, typeRows(flagged)))
+	}
+
+	fmt.Fprintf(&b, "\n\nEvents: %d\n", report.Events.Count)
+	if len(report.Events.TopProducers) > 0 {
+		rows := make([][]string, 0, len(report.Events.TopProducers))
+		for _, producer := range report.Events.TopProducers {
+			rows = append(rows, []string{valueOrDash(producer.Kind), namespacedName(producer.Namespace, producer.Name), fmt.Sprint(producer.Events)})
+		}
+		b.WriteString(ui.Table([]string{"KIND", "OBJECT", "EVENTS"}, rows))
+	}
+
+	if len(report.Etcd) > 0 {
+		rows := make([][]string, 0, len(report.Etcd))
+		for _, member := range report.Etcd {
+			status := string(statusPass)
+			if member.NearQuota {
+				status = string(statusWarn)
+			}
+			rows = append(rows, []string{status, member.Endpoint, humanBytes(member.DBSizeBytes), humanBytes(member.InUseBytes),
+				humanBytes(member.QuotaBytes), fmt.Sprintf("%.1f%%", member.QuotaPercent)})
+		}
+		b.WriteString("\n\n")
+		b.WriteString(ui.Table([]string{"STATUS", "ETCD MEMBER", "DB SIZE", "IN USE", "QUOTA", "USED"}, rows))
+	}
+	for _, problem := range report.Errors {
+		fmt.Fprintf(&b, "\nWARN: %s", problem)
+	}
+	return b.String(), nil
+}
+
+func objectGrowthLabel(row objectTypeReport) string {
+	label := "new"
+	if row.PreviousCount != nil {
+		label = fmt.Sprintf("%+d", row.Growth)
+	}
+	if row.Suspicious {
+		label += " SUSPICIOUS"
+	}
+	return label
+}
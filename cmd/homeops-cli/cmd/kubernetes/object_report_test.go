@@ -0,0 +1,211 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homeops-cli/internal/testutil"
+)
+
+// objectReportItems renders n list items of exactly size bytes each.
+func objectReportItems(n, size int) string {
+	items := make([]string, n)
+	for i := range items {
+		item := fmt.Sprintf(`{"metadata":{"name":"o%d"},"pad":""}`, i)
+		items[i] = strings.Replace(item, `""`, `"`+strings.Repeat("x", size-len(item))+`"`, 1)
+	}
+	return strings.Join(items, ",")
+}
+
+func objectReportEvents(producers ...string) string {
+	items := make([]string, len(producers))
+	for i, producer := range producers {
+		kind, name, _ := strings.Cut(producer, "/")
+		items[i] = fmt.Sprintf(`{"involvedObject":{"kind":%q,"namespace":"media","name":%q}}`, kind, name)
+	}
+	return strings.Join(items, ",")
+}
+
+// objectReportFakeCluster maps raw API paths to responses. configmaps pages
+// with continue tokens only; pods reports remainingItemCount on its first
+// page, so later pages must never be requested.
+func objectReportFakeCluster() map[string]string {
+	return map[string]string{
+		"/api/v1": `{"groupVersion":"v1","resources":[
+			{"name":"configmaps","namespaced":true,"kind":"ConfigMap","verbs":["get","list"]},
+			{"name":"pods","namespaced":true,"kind":"Pod","verbs":["get","list"]},
+			{"name":"pods/log","namespaced":true,"kind":"Pod","verbs":["get"]},
+			{"name":"events","namespaced":true,"kind":"Event","verbs":["get","list"]},
+			{"name":"bindings","namespaced":true,"kind":"Binding","verbs":["create"]}]}`,
+		"/apis": `{"groups":[
+			{"name":"cilium.io","preferredVersion":{"groupVersion":"cilium.io/v2"}},
+			{"name":"metrics.k8s.io","preferredVersion":{"groupVersion":"metrics.k8s.io/v1beta1"}},
+			{"name":"broken.example.com","preferredVersion":{"groupVersion":"broken.example.com/v1"}}]}`,
+		"/apis/cilium.io/v2": `{"groupVersion":"cilium.io/v2","resources":[
+			{"name":"ciliumidentities","namespaced":false,"kind":"CiliumIdentity","verbs":["list"]}]}`,
+
+		"/api/v1/configmaps?limit=500":                 `{"metadata":{"continue":"page 2"},"items":[` + objectReportItems(3, 100) + `]}`,
+		"/api/v1/configmaps?continue=page+2&limit=500": `{"metadata":{"continue":"page3"},"items":[` + objectReportItems(3, 300) + `]}`,
+		"/api/v1/configmaps?continue=page3&limit=500":  `{"metadata":{},"items":[` + objectReportItems(1, 300) + `]}`,
+		"/api/v1/pods?limit=500":                       `{"metadata":{"continue":"next","remainingItemCount":1995},"items":[` + objectReportItems(5, 200) + `]}`,
+		"/api/v1/events?limit=500": `{"metadata":{"continue":"e2","remainingItemCount":2},"items":[` +
+			objectReportEvents("Pod/radarr", "Pod/radarr") + `]}`,
+		"/api/v1/events?continue=e2&limit=500": `{"metadata":{},"items":[` +
+			objectReportEvents("Pod/radarr", "HelmRelease/sonarr") + `]}`,
+		"/apis/cilium.io/v2/ciliumidentities?limit=500": `{"metadata":{},"items":[]}`,
+	}
+}
+
+const objectReportEtcdStatusJSON = `[
+	{"Endpoint":"https://192.168.122.11:2379","Status":{"header":{"member_id":11259375},"dbSize":1932735283,"dbSizeInUse":1073741824}},
+	{"Endpoint":"https://192.168.122.10:2379","Status":{"header":{"member_id":3054},"dbSize":536870912,"dbSizeInUse":268435456,"dbSizeQuota":8589934592}}]`
+
+func withObjectReportFakeCluster(t *testing.T, cluster map[string]string) *[]string {
+	t.Helper()
+	testutil.Swap(t, &objectReportNowFn, func() time.Time { return time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC) })
+	var paths []string
+	testutil.Swap(t, &kubectlOutputCtxFn, func(_ context.Context, args ...string) ([]byte, error) {
+		if strings.Contains(strings.Join(args, " "), "get pods -n kube-system") {
+			return []byte(healthyEtcdPodJSON), nil
+		}
+		if len(args) == 3 && args[1] == "--raw" {
+			paths = append(paths, args[2])
+			if payload, ok := cluster[args[2]]; ok {
+				return []byte(payload), nil
+			}
+			return nil, errors.New("the server could not find the requested resource")
+		}
+		return nil, errors.New("unexpected kubectl call: " + strings.Join(args, " "))
+	})
+	testutil.Swap(t, &etcdPodExecFn, func(_ context.Context, pod string, command ...string) ([]byte, error) {
+		require.Equal(t, "etcd-k8s-0", pod)
+		require.Contains(t, command, "--cluster")
+		return []byte(objectReportEtcdStatusJSON), nil
+	})
+	return &paths
+}
+
+func objectReportRow(t *testing.T, report objectReport, resource string) objectTypeReport {
+	t.Helper()
+	for _, row := range report.Types {
+		if row.Resource == resource {
+			return row
+		}
+	}
+	t.Fatalf("resource %s missing from report", resource)
+	return objectTypeReport{}
+}
+
+func TestBuildObjectReportCountsDiscoveredTypesWithPagination(t *testing.T) {
+	paths := withObjectReportFakeCluster(t, objectReportFakeCluster())
+	report := buildObjectReport(context.Background(), 0)
+
+	var resources []string
+	for _, row := range report.Types {
+		resources = append(resources, row.Resource)
+	}
+	assert.Equal(t, []string{"pods", "configmaps", "events", "ciliumidentities.cilium.io"}, resources, "sorted by count; subresources and create-only types skipped")
+	assert.NotContains(t, *paths, "/apis/metrics.k8s.io/v1beta1", "aggregated metrics are not stored in etcd")
+	assert.NotContains(t, *paths, "/api/v1/pods?continue=next&limit=500", "remainingItemCount avoids paging")
+	require.Len(t, report.Errors, 1)
+	assert.Contains(t, report.Errors[0], "discover broken.example.com/v1")
+
+	configmaps := objectReportRow(t, report, "configmaps")
+	assert.Equal(t, int64(7), configmaps.Count, "every continue page is counted")
+	assert.Equal(t, int64(180), configmaps.AvgBytes, "sampled from the first five items")
+	assert.Equal(t, int64(1260), configmaps.EstimatedBytes)
+
+	pods := objectReportRow(t, report, "pods")
+	assert.Equal(t, int64(2000), pods.Count)
+	assert.Equal(t, int64(200*2000), pods.EstimatedBytes)
+	assert.Zero(t, objectReportRow(t, report, "ciliumidentities.cilium.io").EstimatedBytes)
+	assert.Equal(t, int64(2011), report.TotalObjects)
+
+	assert.Equal(t, int64(4), report.Events.Count)
+	assert.Equal(t, []objectEventProducer{
+		{Kind: "Pod", Namespace: "media", Name: "radarr", Events: 3},
+		{Kind: "HelmRelease", Namespace: "media", Name: "sonarr", Events: 1},
+	}, report.Events.TopProducers)
+
+	require.Len(t, report.Etcd, 2)
+	assert.Equal(t, "https://192.168.122.10:2379", report.Etcd[0].Endpoint)
+	assert.Equal(t, "bee", report.Etcd[0].MemberID)
+	assert.Equal(t, int64(8<<30), report.Etcd[0].QuotaBytes, "reported quota wins")
+	assert.False(t, report.Etcd[0].NearQuota)
+	assert.Equal(t, int64(objectReportDefaultEtcdQuota), report.Etcd[1].QuotaBytes)
+	assert.True(t, report.Etcd[1].NearQuota, "1.8 GiB of the 2 GiB default")
+
+	withQuota := buildObjectReport(context.Background(), 4<<30)
+	assert.Equal(t, int64(4<<30), withQuota.Etcd[1].QuotaBytes)
+	assert.False(t, withQuota.Etcd[1].NearQuota)
+}
+
+func TestObjectReportCommandRendersAndFailsNearQuota(t *testing.T) {
+	withObjectReportFakeCluster(t, objectReportFakeCluster())
+
+	out, err := testutil.ExecuteCommand(newObjectReportCommand(), "--top", "2")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "https://192.168.122.11:2379")
+	assert.Contains(t, out, "Objects: 2011 across 4 types")
+	assert.Contains(t, out, "Top 2 by count")
+	assert.Contains(t, out, "Events: 4")
+	assert.Contains(t, out, "media/radarr")
+	assert.Contains(t, out, "WARN: discover broken.example.com/v1")
+	assert.NotContains(t, out, "ciliumidentities", "--top limits each table")
+
+	_, err = testutil.ExecuteCommand(newObjectReportCommand(), "--etcd-quota", "4Gi")
+	require.NoError(t, err)
+
+	_, err = testutil.ExecuteCommand(newObjectReportCommand(), "--etcd-quota", "lots")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid --etcd-quota")
+}
+
+func TestObjectReportCompareFlagsSuspiciousGrowth(t *testing.T) {
+	withObjectReportFakeCluster(t, objectReportFakeCluster())
+	previous := objectReport{GeneratedAt: "2026-10-01T00:00:00Z", Types: []objectTypeReport{
+		{Resource: "pods", Count: 900},
+		{Resource: "configmaps", Count: 2},
+		{Resource: "ciliumidentities.cilium.io", Count: 5},
+	}}
+	raw, err := json.Marshal(previous)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "previous.json")
+	require.NoError(t, os.WriteFile(path, raw, 0o600))
+
+	out, err := testutil.ExecuteCommand(newObjectReportCommand(), "--compare", path, "--etcd-quota", "4Gi", "-o", "json")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 resource type(s) grew suspiciously since 2026-10-01T00:00:00Z")
+
+	var report objectReport
+	require.NoError(t, json.NewDecoder(strings.NewReader(out)).Decode(&report), "JSON precedes the error line")
+	pods := objectReportRow(t, report, "pods")
+	require.NotNil(t, pods.PreviousCount)
+	assert.Equal(t, int64(1100), pods.Growth)
+	assert.True(t, pods.Suspicious)
+	configmaps := objectReportRow(t, report, "configmaps")
+	assert.Equal(t, int64(5), configmaps.Growth)
+	assert.False(t, configmaps.Suspicious, "large relative growth of a small type is not flagged")
+	events := objectReportRow(t, report, "events")
+	assert.Nil(t, events.PreviousCount)
+	assert.False(t, events.Suspicious, "new types are flagged only above the minimum")
+
+	out, err = testutil.ExecuteCommand(newObjectReportCommand(), "--compare", path, "--etcd-quota", "4Gi")
+	require.Error(t, err)
+	assert.Contains(t, out, "Growing suspiciously")
+	assert.Contains(t, out, "+1100 SUSPICIOUS")
+	assert.Contains(t, out, "new")
+
+	_, err = testutil.ExecuteCommand(newObjectReportCommand(), "--compare", filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "read --compare report")
+}
@@ -0,0 +1,476 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"homeops-cli/internal/config"
+	"homeops-cli/internal/kubeutil"
+	"homeops-cli/internal/ui"
+	"homeops-cli/internal/vmlifecycle"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	pressureDefaultSince = 24 * time.Hour
+	// pressureReservedWarnFraction flags nodes where more than this share of
+	// the configured VM memory never reaches allocatable (kernel, system and
+	// kube reserved, eviction threshold).
+	pressureReservedWarnFraction = 0.10
+
+	pressureSourcePodStatus = "pod-status"
+	pressureSourceEvent     = "event"
+)
+
+var pressureConditionTypes = []string{"MemoryPressure", "DiskPressure", "PIDPressure"}
+
+var (
+	pressureNowFn = time.Now
+	// pressureVMMemoryFn returns the memory homeops.yaml provisions a node's
+	// VM with and the config path it came from.
+	pressureVMMemoryFn = configuredVMMemory
+)
+
+type pressureNodeList struct {
+	Items []struct {
+		Metadata metadataJSON `json:"metadata"`
+		Status   struct {
+			Capacity    map[string]string `json:"capacity"`
+			Allocatable map[string]string `json:"allocatable"`
+			Conditions  []struct {
+				Type               string `json:"type"`
+				Status             string `json:"status"`
+				Reason             string `json:"reason"`
+				Message            string `json:"message"`
+				LastTransitionTime string `json:"lastTransitionTime"`
+			} `json:"conditions"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+type pressureContainerState struct {
+	Terminated *struct {
+		Reason     string `json:"reason"`
+		ExitCode   int    `json:"exitCode"`
+		FinishedAt string `json:"finishedAt"`
+	} `json:"terminated"`
+}
+
+type pressurePod struct {
+	Metadata struct {
+		Name            string                    `json:"name"`
+		Namespace       string                    `json:"namespace"`
+		Labels          map[string]string         `json:"labels"`
+		OwnerReferences []kubeutil.OwnerReference `json:"ownerReferences"`
+	} `json:"metadata"`
+	Spec struct {
+		NodeName   string `json:"nodeName"`
+		Containers []struct {
+			Name      string `json:"name"`
+			Resources struct {
+				Requests map[string]string `json:"requests"`
+			} `json:"resources"`
+		} `json:"containers"`
+	} `json:"spec"`
+	Status struct {
+		Phase             string `json:"phase"`
+		Reason            string `json:"reason"`
+		ContainerStatuses []struct {
+			Name      string                 `json:"name"`
+			State     pressureContainerState `json:"state"`
+			LastState pressureContainerState `json:"lastState"`
+		} `json:"containerStatuses"`
+	} `json:"status"`
+}
+
+type pressurePodList struct {
+	Items []pressurePod `json:"items"`
+}
+
+type pressureEventList struct {
+	Items []struct {
+		Metadata       metadataJSON `json:"metadata"`
+		Reason         string       `json:"reason"`
+		Message        string       `json:"message"`
+		LastTimestamp  string       `json:"lastTimestamp"`
+		EventTime      string       `json:"eventTime"`
+		InvolvedObject struct {
+			Kind      string `json:"kind"`
+			Namespace string `json:"namespace"`
+			Name      string `json:"name"`
+		} `json:"involvedObject"`
+		Source struct {
+			Host string `json:"host"`
+		} `json:"source"`
+	} `json:"items"`
+}
+
+type pressureCondition struct {
+	Type   string `json:"type"`
+	Status string `json:"status"`
+	Since  string `json:"since,omitempty"` // lastTransitionTime
+	Reason string `json:"reason,omitempty"`
+}
+
+type pressureNodeReport struct {
+	Name                   string              `json:"name"`
+	UnderPressure          bool                `json:"under_pressure"`
+	Conditions             []pressureCondition `json:"conditions"`
+	MemoryCapacityBytes    int64               `json:"memory_capacity_bytes"`
+	MemoryAllocatableBytes int64               `json:"memory_allocatable_bytes"`
+	MemoryRequestsBytes    int64               `json:"memory_requests_bytes"`
+	MemoryUsageBytes       *int64              `json:"memory_usage_bytes,omitempty"` // nil when metrics.k8s.io is unavailable
+	EphemeralAllocatable   int64               `json:"ephemeral_storage_allocatable_bytes"`
+	EphemeralRequests      int64               `json:"ephemeral_storage_requests_bytes"`
+	VMMemoryBytes          int64               `json:"vm_memory_bytes,omitempty"`
+	VMMemorySource         string              `json:"vm_memory_source,omitempty"`
+	ReservedBytes          int64               `json:"reserved_bytes,omitempty"` // VM memory that never reaches allocatable
+	ReservedHigh           bool                `json:"reserved_high,omitempty"`
+	OOMKills               int                 `json:"oom_kills"`
+	Evictions              int                 `json:"evictions"`
+}
+
+type pressureOOMKill struct {
+	Node      string `json:"node"`
+	Namespace string `json:"namespace,omitempty"`
+	Pod       string `json:"pod,omitempty"`
+	Container string `json:"container,omitempty"`
+	Workload  string `json:"workload,omitempty"`
+	At        string `json:"at"`
+	Source    string `json:"source"` // pod-status or event
+	Detail    string `json:"detail,omitempty"`
+}
+
+type pressureReport struct {
+	Since              string               `json:"since"`
+	NodesUnderPressure int                  `json:"nodes_under_pressure"`
+	Nodes              []pressureNodeReport `json:"nodes"`
+	OOMKills           []pressureOOMKill    `json:"oom_kills"`
+	Errors             []string             `json:"errors,omitempty"`
+}
+
+func newPressureReportCommand() *cobra.Command {
+	var output string
+	var since time.Duration
+	cmd := &cobra.Command{
+		Use:   "pressure-report",
+		Short: "Report node pressure, OOM kills, and evictions against VM memory sizing",
+		Long: `Report, per node, the memory/ephemeral-storage/PID pressure conditions with
+their transition times, memory allocatable vs. the sum of pod requests vs.
+actual usage (metrics.k8s.io), OOMKilled containers and evicted pods from the
+--since window, and the VM memory homeops.yaml provisions the node with.
+
+The reserved column is VM memory minus allocatable: what the kernel, system
+and kube reservations, and the eviction threshold hold back. More than 10%
+is flagged. Exits non-zero when any node currently reports pressure.`,
+		SilenceUsage: true,
+		Example: `  homeops-cli k8s pressure-report
+  homeops-cli k8s pressure-report --since 72h
+  homeops-cli k8s pressure-report --output json`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := ui.ValidateOutputFormat(output); err != nil {
+				return err
+			}
+			if since <= 0 {
+				return fmt.Errorf("--since must be positive")
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), kubernetesDefaultCommandTimeout)
+			defer cancel()
+			report := buildPressureReport(ctx, since)
+			rendered, err := renderPressureReport(report, output)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), rendered)
+			if report.NodesUnderPressure > 0 {
+				return fmt.Errorf("%d node(s) currently report pressure", report.NodesUnderPressure)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table or json")
+	cmd.Flags().DurationVar(&since, "since", pressureDefaultSince, "window for OOM kills and evictions")
+	return cmd
+}
+
+func buildPressureReport(ctx context.Context, since time.Duration) pressureReport {
+	report := pressureReport{Since: since.String()}
+	var nodes pressureNodeList
+	if err := kubeutil.GetClusterJSON(ctx, kubectlOutputCtxFn, "nodes", &nodes); err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return report
+	}
+	var pods pressurePodList
+	if err := kubeutil.GetJSON(ctx, kubectlOutputCtxFn, "", "pods", &pods); err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	var events pressureEventList
+	if err := kubeutil.GetJSON(ctx, kubectlOutputCtxFn, "", "events", &events); err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	usage, err := nodeMemoryUsage(ctx)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("node usage unavailable (metrics.k8s.io): %v", err))
+	}
+	aggregated, problems := aggregatePressure(nodes, pods, events, usage, pressureNowFn(), since)
+	aggregated.Errors = append(report.Errors, problems...)
+	return aggregated
+}
+
+// aggregatePressure correlates node conditions, pod requests and
+// terminations, events, and usage into per-node rows.
+func aggregatePressure(nodes pressureNodeList, pods pressurePodList, events pressureEventList, usage map[string]int64, now time.Time, since time.Duration) (pressureReport, []string) {
+	report := pressureReport{Since: since.String()}
+	var problems []string
+	cutoff := now.Add(-since)
+	byNode := map[string]*pressureNodeReport{}
+
+	for _, node := range nodes.Items {
+		row := &pressureNodeReport{Name: node.Metadata.Name}
+		for _, conditionType := range pressureConditionTypes {
+			condition := pressureCondition{Type: conditionType, Status: "Unknown"}
+			for _, c := range node.Status.Conditions {
+				if c.Type == conditionType {
+					condition = pressureCondition{Type: c.Type, Status: c.Status, Since: c.LastTransitionTime, Reason: c.Reason}
+				}
+			}
+			if condition.Status == "True" {
+				row.UnderPressure = true
+			}
+			row.Conditions = append(row.Conditions, condition)
+		}
+		row.MemoryCapacityBytes = pressureQuantity(node.Status.Capacity["memory"], "node "+row.Name+" capacity memory", &problems)
+		row.MemoryAllocatableBytes = pressureQuantity(node.Status.Allocatable["memory"], "node "+row.Name+" allocatable memory", &problems)
+		row.EphemeralAllocatable = pressureQuantity(node.Status.Allocatable["ephemeral-storage"], "node "+row.Name+" allocatable ephemeral-storage", &problems)
+		if used, ok := usage[row.Name]; ok {
+			value := used
+			row.MemoryUsageBytes = &value
+		}
+		if vmMemory, source, ok := pressureVMMemoryFn(row.Name); ok {
+			row.VMMemoryBytes, row.VMMemorySource = vmMemory, source
+			row.ReservedBytes = vmMemory - row.MemoryAllocatableBytes
+			row.ReservedHigh = float64(row.ReservedBytes) > pressureReservedWarnFraction*float64(vmMemory)
+		}
+		byNode[row.Name] = row
+	}
+
+	evicted := map[string]string{} // namespace/pod -> node
+	for _, pod := range pods.Items {
+		row := byNode[pod.Spec.NodeName]
+		key := namespacedName(pod.Metadata.Namespace, pod.Metadata.Name)
+		if pod.Status.Phase == "Failed" && pod.Status.Reason == "Evicted" {
+			evicted[key] = pod.Spec.NodeName
+			continue // evicted pods no longer hold their requests
+		}
+		if row == nil {
+			continue
+		}
+		if pod.Status.Phase != "Succeeded" && pod.Status.Phase != "Failed" {
+			for _, container := range pod.Spec.Containers {
+				label := "pod " + key + " container " + container.Name
+				row.MemoryRequestsBytes += pressureQuantity(container.Resources.Requests["memory"], label+" memory request", &problems)
+				row.EphemeralRequests += pressureQuantity(container.Resources.Requests["ephemeral-storage"], label+" ephemeral-storage request", &problems)
+			}
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			for _, state := range []pressureContainerState{status.State, status.LastState} {
+				if state.Terminated == nil || state.Terminated.Reason != "OOMKilled" {
+					continue
+				}
+				finished, err := time.Parse(time.RFC3339, state.Terminated.FinishedAt)
+				if err != nil || finished.Before(cutoff) {
+					continue
+				}
+				report.OOMKills = append(report.OOMKills, pressureOOMKill{
+					Node: row.Name, Namespace: pod.Metadata.Namespace, Pod: pod.Metadata.Name, Container: status.Name,
+					Workload: pressureWorkload(pod), At: state.Terminated.FinishedAt, Source: pressureSourcePodStatus,
+					Detail: fmt.Sprintf("exit code %d", state.Terminated.ExitCode),
+				})
+			}
+		}
+	}
+
+	for _, event := range events.Items {
+		at := pressureEventTime(event.LastTimestamp, event.EventTime, event.Metadata.CreationTimestamp)
+		if at.IsZero() || at.Before(cutoff) {
+			continue
+		}
+		switch {
+		case event.Reason == "OOMKilling" && event.InvolvedObject.Kind == "Node":
+			// Kernel OOM kills reported by the kubelet; the message names the
+			// process, not the pod.
+			report.OOMKills = append(report.OOMKills, pressureOOMKill{
+				Node: event.InvolvedObject.Name, At: at.UTC().Format(time.RFC3339), Source: pressureSourceEvent, Detail: event.Message,
+			})
+		case event.Reason == "Evicted" && event.InvolvedObject.Kind == "Pod":
+			key := namespacedName(event.InvolvedObject.Namespace, event.InvolvedObject.Name)
+			if _, seen := evicted[key]; !seen {
+				evicted[key] = event.Source.Host
+			}
+		}
+	}
+	for _, node := range evicted {
+		if row := byNode[node]; row != nil {
+			row.Evictions++
+		}
+	}
+	for _, kill := range report.OOMKills {
+		if row := byNode[kill.Node]; row != nil {
+			row.OOMKills++
+		}
+	}
+
+	for _, row := range byNode {
+		if row.UnderPressure {
+			report.NodesUnderPressure++
+		}
+		report.Nodes = append(report.Nodes, *row)
+	}
+	sort.Slice(report.Nodes, func(i, j int) bool { return report.Nodes[i].Name < report.Nodes[j].Name })
+	sort.SliceStable(report.OOMKills, func(i, j int) bool { return report.OOMKills[i].At > report.OOMKills[j].At })
+	return report, problems
+}
+
+func pressureQuantity(value, label string, problems *[]string) int64 {
+	if strings.TrimSpace(value) == "" {
+		return 0
+	}
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		*problems = append(*problems, fmt.Sprintf("parse %s %q: %v", label, value, err))
+		return 0
+	}
+	return quantity.Value()
+}
+
+func pressureEventTime(values ...string) time.Time {
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		if parsed, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return parsed
+		}
+	}
+	return time.Time{}
+}
+
+func pressureWorkload(pod pressurePod) string {
+	return podWorkload(pod.Metadata.Name, pod.Metadata.Labels, pod.Metadata.OwnerReferences)
+}
+
+// nodeMemoryUsage reads per-node memory usage from `kubectl top nodes`.
+func nodeMemoryUsage(ctx context.Context) (map[string]int64, error) {
+	raw, err := kubectlOutputCtxFn(ctx, "top", "nodes", "--no-headers")
+	if err != nil {
+		return nil, err
+	}
+	usage := map[string]int64{}
+	for lineNumber, line := range strings.Split(strings.TrimSpace(string(raw)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("parse kubectl top nodes line %d: expected 5 fields, got %d", lineNumber+1, len(fields))
+		}
+		if fields[3] == "<unknown>" {
+			continue
+		}
+		memory, err := resource.ParseQuantity(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("parse memory usage on line %d: %w", lineNumber+1, err)
+		}
+		usage[fields[0]] = memory.Value()
+	}
+	return usage, nil
+}
+
+// configuredVMMemory returns the VM memory for nodes listed in
+// cluster.nodes, from the default hypervisor's vm.memory_mb.
+func configuredVMMemory(node string) (int64, string, bool) {
+	cfg := config.Get()
+	if _, ok := cfg.NodeByName(node); !ok {
+		return 0, "", false
+	}
+	provider := vmlifecycle.DefaultProviderName()
+	var memoryMB int
+	switch provider {
+	case "proxmox":
+		memoryMB = cfg.Hypervisors.Proxmox.VM.MemoryMB
+	case "truenas":
+		memoryMB = cfg.Hypervisors.TrueNAS.VM.MemoryMB
+	case "vsphere":
+		memoryMB = cfg.Hypervisors.VSphere.VM.MemoryMB
+	}
+	if memoryMB <= 0 {
+		return 0, "", false
+	}
+	return int64(memoryMB) * 1024 * 1024, "hypervisors." + provider + ".vm.memory_mb", true
+}
+
+func renderPressureReport(report pressureReport, output string) (string, error) {
+	if output == "json" {
+		return ui.RenderJSON(report)
+	}
+	if err := ui.ValidateOutputFormat(output); err != nil {
+		return "", err
+	}
+	now := pressureNowFn()
+	var nodeRows, conditionRows [][]string
+	for _, node := range report.Nodes {
+		status := string(statusPass)
+		if node.ReservedHigh || node.OOMKills > 0 || node.Evictions > 0 {
+			status = string(statusWarn)
+		}
+		if node.UnderPressure {
+			status = string(statusFail)
+		}
+		usage := "-"
+		if node.MemoryUsageBytes != nil {
+			usage = humanBytes(*node.MemoryUsageBytes)
+		}
+		vmMemory, reserved := "-", "-"
+		if node.VMMemoryBytes > 0 {
+			vmMemory = humanBytes(node.VMMemoryBytes)
+			reserved = fmt.Sprintf("%s (%.0f%%)", humanBytes(node.ReservedBytes), 100*float64(node.ReservedBytes)/float64(node.VMMemoryBytes))
+			if node.ReservedHigh {
+				reserved += " HIGH"
+			}
+		}
+		nodeRows = append(nodeRows, []string{status, node.Name, vmMemory, humanBytes(node.MemoryAllocatableBytes), reserved,
+			humanBytes(node.MemoryRequestsBytes), usage,
+			fmt.Sprintf("%s/%s", humanBytes(node.EphemeralRequests), humanBytes(node.EphemeralAllocatable)),
+			fmt.Sprintf("%d", node.OOMKills), fmt.Sprintf("%d", node.Evictions)})
+		for _, condition := range node.Conditions {
+			since := "-"
+			if condition.Since != "" {
+				since = resourceAge(condition.Since, now) + " ago"
+			}
+			conditionRows = append(conditionRows, []string{node.Name, condition.Type, condition.Status, since, valueOrDash(condition.Reason)})
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Nodes under pressure: %d (OOM kills and evictions from the last %s)\n", report.NodesUnderPressure, report.Since)
+	b.WriteString(ui.Table([]string{"STATUS", "NODE", "VM MEMORY", "ALLOCATABLE", "RESERVED", "REQUESTS", "USAGE", "EPHEMERAL REQ/ALLOC", "OOM", "EVICTED"}, nodeRows))
+	b.WriteString("\n\n")
+	b.WriteString(ui.Table([]string{"NODE", "CONDITION", "STATUS", "SINCE", "REASON"}, conditionRows))
+	if len(report.OOMKills) > 0 {
+		var rows [][]string
+		for _, kill := range report.OOMKills {
+			rows = append(rows, []string{kill.At, kill.Node, valueOrDash(namespacedName(kill.Namespace, kill.Pod)), valueOrDash(kill.Container),
+				valueOrDash(kill.Workload), kill.Source, valueOrDash(kill.Detail)})
+		}
+		b.WriteString("\n\n")
+		b.WriteString(ui.Table([]string{"AT", "NODE", "POD", "CONTAINER", "WORKLOAD", "SOURCE", "DETAIL"}, rows))
+	}
+	for _, problem := range report.Errors {
+		fmt.Fprintf(&b, "\nWARN: %s", problem)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,194 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homeops-cli/internal/kubeutil"
+	"homeops-cli/internal/testutil"
+)
+
+const (
+	pressureTestNodes = `{"items":[
+  {"metadata":{"name":"k8s-0"},"status":{
+    "capacity":{"memory":"49152Mi"},"allocatable":{"memory":"40Gi","ephemeral-storage":"90Gi"},
+    "conditions":[
+      {"type":"MemoryPressure","status":"True","reason":"KubeletHasInsufficientMemory","lastTransitionTime":"2026-07-15T11:30:00Z"},
+      {"type":"DiskPressure","status":"False","reason":"KubeletHasNoDiskPressure","lastTransitionTime":"2026-07-01T00:00:00Z"},
+      {"type":"PIDPressure","status":"False","lastTransitionTime":"2026-07-01T00:00:00Z"},
+      {"type":"Ready","status":"True"}]}},
+  {"metadata":{"name":"k8s-1"},"status":{
+    "capacity":{"memory":"49152Mi"},"allocatable":{"memory":"46Gi","ephemeral-storage":"90Gi"},
+    "conditions":[{"type":"MemoryPressure","status":"False"},{"type":"DiskPressure","status":"False"},{"type":"PIDPressure","status":"False"}]}}]}`
+
+	pressureTestPods = `{"items":[
+  {"metadata":{"namespace":"media","name":"plex-7d9c8-abcde","labels":{"pod-template-hash":"7d9c8"},
+     "ownerReferences":[{"kind":"ReplicaSet","name":"plex-7d9c8"}]},
+   "spec":{"nodeName":"k8s-0","containers":[{"name":"app","resources":{"requests":{"memory":"8Gi","ephemeral-storage":"1Gi"}}},{"name":"sidecar","resources":{"requests":{"memory":"512Mi"}}}]},
+   "status":{"phase":"Running","containerStatuses":[
+     {"name":"app","state":{"running":{}},"lastState":{"terminated":{"reason":"OOMKilled","exitCode":137,"finishedAt":"2026-07-15T10:00:00Z"}}},
+     {"name":"sidecar","state":{"running":{}},"lastState":{"terminated":{"reason":"OOMKilled","exitCode":137,"finishedAt":"2026-07-10T10:00:00Z"}}}]}},
+  {"metadata":{"namespace":"db","name":"postgres-0","ownerReferences":[{"kind":"StatefulSet","name":"postgres"}]},
+   "spec":{"nodeName":"k8s-1","containers":[{"name":"postgres","resources":{"requests":{"memory":"4Gi"}}}]},
+   "status":{"phase":"Running"}},
+  {"metadata":{"namespace":"media","name":"done-job"},
+   "spec":{"nodeName":"k8s-1","containers":[{"name":"job","resources":{"requests":{"memory":"16Gi"}}}]},
+   "status":{"phase":"Succeeded"}},
+  {"metadata":{"namespace":"media","name":"sonarr-5f-xyz"},
+   "spec":{"nodeName":"k8s-0","containers":[{"name":"app","resources":{"requests":{"memory":"2Gi"}}}]},
+   "status":{"phase":"Failed","reason":"Evicted"}}]}`
+
+	pressureTestEvents = `{"items":[
+  {"metadata":{"creationTimestamp":"2026-07-15T09:00:00Z"},"reason":"OOMKilling","message":"Memory cgroup out of memory: Killed process 4242 (ffmpeg)",
+   "lastTimestamp":"2026-07-15T09:00:00Z","involvedObject":{"kind":"Node","name":"k8s-0"}},
+  {"metadata":{},"reason":"Evicted","message":"The node was low on resource: memory.","eventTime":"2026-07-15T11:31:00.000000Z",
+   "involvedObject":{"kind":"Pod","namespace":"media","name":"radarr-6c-qwe"},"source":{"host":"k8s-0"}},
+  {"metadata":{},"reason":"Evicted","lastTimestamp":"2026-07-15T11:32:00Z",
+   "involvedObject":{"kind":"Pod","namespace":"media","name":"sonarr-5f-xyz"},"source":{"host":"k8s-0"}},
+  {"metadata":{},"reason":"Evicted","lastTimestamp":"2026-07-01T00:00:00Z",
+   "involvedObject":{"kind":"Pod","namespace":"media","name":"ancient"},"source":{"host":"k8s-1"}}]}`
+
+	pressureTestTop = "k8s-0   3200m   20%   38912Mi   95%\nk8s-1   800m   5%   <unknown>   <unknown>\n"
+)
+
+func stubPressureCluster(t *testing.T, top func() ([]byte, error)) *[][]string {
+	t.Helper()
+	testutil.Swap(t, &pressureNowFn, func() time.Time { return time.Date(2026, 7, 15, 12, 0, 0, 0, time.UTC) })
+	testutil.Swap(t, &pressureVMMemoryFn, func(node string) (int64, string, bool) {
+		if node == "k8s-0" || node == "k8s-1" {
+			return 48 << 30, "hypervisors.proxmox.vm.memory_mb", true
+		}
+		return 0, "", false
+	})
+	var calls [][]string
+	testutil.Swap(t, &kubectlOutputCtxFn, func(_ context.Context, args ...string) ([]byte, error) {
+		calls = append(calls, append([]string{}, args...))
+		switch args[1] {
+		case "nodes":
+			if args[0] == "top" {
+				return top()
+			}
+			return []byte(pressureTestNodes), nil
+		case "pods":
+			return []byte(pressureTestPods), nil
+		case "events":
+			return []byte(pressureTestEvents), nil
+		}
+		return nil, errors.New("unexpected kubectl call")
+	})
+	return &calls
+}
+
+func pressureNodeByName(t *testing.T, report pressureReport, name string) pressureNodeReport {
+	t.Helper()
+	for _, node := range report.Nodes {
+		if node.Name == name {
+			return node
+		}
+	}
+	t.Fatalf("node %s missing from report", name)
+	return pressureNodeReport{}
+}
+
+func TestBuildPressureReportCorrelatesNodesPodsEventsAndUsage(t *testing.T) {
+	calls := stubPressureCluster(t, func() ([]byte, error) { return []byte(pressureTestTop), nil })
+
+	report := buildPressureReport(context.Background(), 24*time.Hour)
+	require.Empty(t, report.Errors)
+	assert.Equal(t, 1, report.NodesUnderPressure)
+	for _, call := range *calls {
+		assert.Contains(t, []string{"get", "top"}, call[0], "pressure-report is read-only")
+	}
+
+	hot := pressureNodeByName(t, report, "k8s-0")
+	assert.True(t, hot.UnderPressure)
+	assert.Equal(t, []pressureCondition{
+		{Type: "MemoryPressure", Status: "True", Since: "2026-07-15T11:30:00Z", Reason: "KubeletHasInsufficientMemory"},
+		{Type: "DiskPressure", Status: "False", Since: "2026-07-01T00:00:00Z", Reason: "KubeletHasNoDiskPressure"},
+		{Type: "PIDPressure", Status: "False", Since: "2026-07-01T00:00:00Z"},
+	}, hot.Conditions)
+	assert.Equal(t, int64(40<<30), hot.MemoryAllocatableBytes)
+	assert.Equal(t, int64(8<<30+512<<20), hot.MemoryRequestsBytes, "evicted pods hold no requests")
+	assert.Equal(t, int64(1<<30), hot.EphemeralRequests)
+	require.NotNil(t, hot.MemoryUsageBytes)
+	assert.Equal(t, int64(38912<<20), *hot.MemoryUsageBytes)
+	assert.Equal(t, int64(8<<30), hot.ReservedBytes)
+	assert.True(t, hot.ReservedHigh, "8Gi of a 48Gi VM is more than 10%")
+	assert.Equal(t, 2, hot.OOMKills, "one container OOM in the window plus one kernel OOM event")
+	assert.Equal(t, 2, hot.Evictions, "evicted pod status and event are de-duplicated")
+
+	cool := pressureNodeByName(t, report, "k8s-1")
+	assert.False(t, cool.UnderPressure)
+	assert.Equal(t, int64(4<<30), cool.MemoryRequestsBytes, "completed pods hold no requests")
+	assert.Nil(t, cool.MemoryUsageBytes)
+	assert.False(t, cool.ReservedHigh)
+	assert.Zero(t, cool.Evictions, "evictions outside --since are ignored")
+
+	require.Len(t, report.OOMKills, 2)
+	assert.Equal(t, pressureOOMKill{Node: "k8s-0", Namespace: "media", Pod: "plex-7d9c8-abcde", Container: "app",
+		Workload: "Deployment/plex", At: "2026-07-15T10:00:00Z", Source: pressureSourcePodStatus, Detail: "exit code 137"}, report.OOMKills[0])
+	assert.Equal(t, pressureSourceEvent, report.OOMKills[1].Source)
+	assert.Contains(t, report.OOMKills[1].Detail, "ffmpeg")
+}
+
+func TestBuildPressureReportWithoutMetricsStillReports(t *testing.T) {
+	stubPressureCluster(t, func() ([]byte, error) { return nil, errors.New("Metrics API not available") })
+
+	report := buildPressureReport(context.Background(), 150*time.Minute)
+	require.Len(t, report.Errors, 1)
+	assert.Contains(t, report.Errors[0], "metrics.k8s.io")
+	assert.Len(t, report.Nodes, 2)
+	assert.Nil(t, pressureNodeByName(t, report, "k8s-0").MemoryUsageBytes)
+	assert.Equal(t, 1, pressureNodeByName(t, report, "k8s-0").OOMKills, "the 09:00 kernel OOM event is outside the window")
+}
+
+func TestPressureWorkload(t *testing.T) {
+	var pod pressurePod
+	pod.Metadata.Name = "standalone"
+	assert.Equal(t, "Pod/standalone", pressureWorkload(pod))
+
+	pod.Metadata.OwnerReferences = []kubeutil.OwnerReference{{Kind: "ReplicaSet", Name: "orphan-rs"}}
+	assert.Equal(t, "ReplicaSet/orphan-rs", pressureWorkload(pod))
+}
+
+func TestPressureReportCommandExitsNonZeroUnderPressure(t *testing.T) {
+	stubPressureCluster(t, func() ([]byte, error) { return []byte(pressureTestTop), nil })
+
+	cmd := newPressureReportCommand()
+	cmd.SetContext(context.Background())
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{})
+	err := cmd.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 node(s) currently report pressure")
+	assert.Contains(t, out.String(), "Nodes under pressure: 1")
+	assert.Contains(t, out.String(), "8.0 GiB (17%) HIGH")
+	assert.Contains(t, out.String(), "30m0s ago")
+	assert.Contains(t, out.String(), "Deployment/plex")
+
+	out.Reset()
+	cmd = newPressureReportCommand()
+	cmd.SetContext(context.Background())
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--output", "json"})
+	require.Error(t, cmd.Execute())
+	var report pressureReport
+	decodeNetDoctorJSON(t, out.String(), &report)
+	assert.Equal(t, 1, report.NodesUnderPressure)
+	assert.Equal(t, "24h0m0s", report.Since)
+}
+
+func TestPressureReportCommandValidation(t *testing.T) {
+	for _, args := range [][]string{{"--output", "yaml"}, {"--since", "0s"}} {
+		cmd := newPressureReportCommand()
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetArgs(args)
+		require.Error(t, cmd.Execute(), args)
+	}
+}
@@ -0,0 +1,718 @@
+package kubernetes
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"homeops-cli/internal/common"
+	shareddiff "homeops-cli/internal/diff"
+	"homeops-cli/internal/ui"
+)
+
+// Per-object states in a preview. The ref column compares the working tree
+// with the git ref; the live column is kubectl's server-side dry-run verdict.
+const (
+	previewAdded     = "added"
+	previewRemoved   = "removed"
+	previewChanged   = "changed"
+	previewUnchanged = "unchanged"
+	previewInSync    = "in-sync"
+	previewSkipped   = "skipped"
+)
+
+const fluxSubstituteAnnotation = "kustomize.toolkit.fluxcd.io/substitute"
+
+var (
+	previewGitRootFn = findGitRoot
+	// previewGitArchiveFn streams a tar of pathspec at ref. git archive reads
+	// the object store directly, so the checkout and index are never touched.
+	previewGitArchiveFn = func(ctx context.Context, root, ref, pathspec string) ([]byte, error) {
+		return common.RunCommandWithContextOutput(ctx, "git", "-C", root, "archive", "--format=tar", ref, "--", pathspec)
+	}
+	previewKustomizeBuildFn = func(ctx context.Context, dir string) ([]byte, error) {
+		return runKubernetesCommandOutputCtx(ctx, "kubectl", "kustomize", dir)
+	}
+
+	fluxVariablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::=([^}]*))?\}`)
+)
+
+type previewObject struct {
+	Object string `json:"object"`
+	Ref    string `json:"ref"`
+	Live   string `json:"live"`
+	Diff   string `json:"diff,omitempty"`
+}
+
+type previewReport struct {
+	Path          string          `json:"path"`
+	Ref           string          `json:"ref"`
+	Kustomization string          `json:"kustomization,omitempty"`
+	Added         int             `json:"added"`
+	Removed       int             `json:"removed"`
+	Changed       int             `json:"changed"`
+	Unchanged     int             `json:"unchanged"`
+	Unresolved    []string        `json:"unresolved_variables,omitempty"`
+	Warnings      []string        `json:"warnings,omitempty"`
+	Objects       []previewObject `json:"objects"`
+}
+
+type previewOptions struct {
+	Path    string
+	Ref     string
+	Offline bool
+}
+
+// fluxPostBuild is the subset of a Flux Kustomization's spec.postBuild the
+// preview resolves.
+type fluxPostBuild struct {
+	Substitute     map[string]string `yaml:"substitute" json:"substitute"`
+	SubstituteFrom []struct {
+		Kind     string `yaml:"kind" json:"kind"`
+		Name     string `yaml:"name" json:"name"`
+		Optional bool   `yaml:"optional" json:"optional"`
+	} `yaml:"substituteFrom" json:"substituteFrom"`
+}
+
+type previewKustomization struct {
+	Name      string
+	Namespace string
+	// Path is spec.path, cleaned and slash-separated.
+	Path      string
+	PostBuild fluxPostBuild
+}
+
+// previewBuild is one rendered side of the comparison, keyed by object.
+type previewBuild struct {
+	objects map[string]previewRendered
+	live    []string
+}
+
+type previewRendered struct {
+	display    string
+	kind       string
+	normalized string
+}
+
+func newPreviewCommand() *cobra.Command {
+	var (
+		options previewOptions
+		output  string
+	)
+	cmd := &cobra.Command{
+		Use:          "preview",
+		Short:        "Compare a kustomization in the working tree with a git ref and the live cluster",
+		SilenceUsage: true,
+		Long: `Builds the kustomization at --path twice, once from the working tree and
+once from the committed tree at --ref (read with git archive, so the checkout is
+never touched), then applies the Flux postBuild substitutions of the owning
+ks.yaml. substituteFrom ConfigMaps and Secrets, including the ones the root
+Flux patches add to the live Kustomization, are read from the cluster.
+
+Each object is reported twice: against the ref (added, removed, changed, or
+unchanged, with a unified diff) and against the live cluster through kubectl's
+server-side dry-run with the homeops-diff field manager. Secret values are
+replaced by sha256 digests in every diff and Secrets are never sent to the
+dry-run. --offline skips all cluster access; unresolved variables then render
+empty, as Flux itself renders them.`,
+		Example: `  homeops-cli k8s preview --path kubernetes/apps/media/plex/app
+  homeops-cli k8s preview --path kubernetes/apps/media/plex/app --ref origin/main
+  homeops-cli k8s preview --path kubernetes/apps/media/plex/app --offline --output json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ui.ValidateOutputFormat(output); err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			report, err := runPreview(ctx, options)
+			if err != nil {
+				return err
+			}
+			rendered, err := renderPreviewReport(report, output)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), rendered)
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&options.Path, "path", "", "kustomization directory relative to the repository root (required)")
+	cmd.Flags().StringVar(&options.Ref, "ref", "main", "git ref to compare the working tree against")
+	cmd.Flags().BoolVar(&options.Offline, "offline", false, "skip cluster lookups and the live dry-run column")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table or json")
+	_ = cmd.MarkFlagRequired("path")
+	return cmd
+}
+
+func runPreview(ctx context.Context, options previewOptions) (previewReport, error) {
+	root, err := previewGitRootFn()
+	if err != nil {
+		return previewReport{}, err
+	}
+	relPath, err := normalizePreviewPath(root, options.Path)
+	if err != nil {
+		return previewReport{}, err
+	}
+	report := previewReport{Path: relPath, Ref: options.Ref}
+
+	refRoot, err := os.MkdirTemp("", "homeops-preview-")
+	if err != nil {
+		return report, fmt.Errorf("create temp dir: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(refRoot) }()
+	// Export the whole top-level directory so overlays that reach ../components
+	// still resolve at the ref.
+	topLevel := strings.SplitN(relPath, "/", 2)[0]
+	archive, err := previewGitArchiveFn(ctx, root, options.Ref, topLevel)
+	if err != nil {
+		return report, fmt.Errorf("read %s at %s: %w", topLevel, options.Ref, err)
+	}
+	if err := extractPreviewArchive(archive, refRoot); err != nil {
+		return report, fmt.Errorf("extract %s at %s: %w", topLevel, options.Ref, err)
+	}
+
+	treeKs, err := findPreviewKustomization(root, relPath)
+	if err != nil {
+		return report, err
+	}
+	refKs, err := findPreviewKustomization(refRoot, relPath)
+	if err != nil {
+		return report, err
+	}
+	owner := treeKs
+	if owner == nil {
+		owner = refKs
+	}
+	if owner == nil {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("no ks.yaml points at %s; postBuild substitution skipped", relPath))
+	} else {
+		report.Kustomization = owner.Namespace + "/" + owner.Name
+	}
+
+	var sources map[string]string
+	if owner != nil {
+		sources, report.Warnings = resolvePreviewSubstitutions(ctx, owner, options.Offline, report.Warnings)
+	}
+
+	unresolved := map[string]bool{}
+	tree, err := buildPreviewSide(ctx, filepath.Join(root, relPath), treeKs, sources, unresolved)
+	if err != nil {
+		return report, fmt.Errorf("build working tree: %w", err)
+	}
+	refBuild := &previewBuild{objects: map[string]previewRendered{}}
+	if info, statErr := os.Stat(filepath.Join(refRoot, relPath)); statErr == nil && info.IsDir() {
+		refBuild, err = buildPreviewSide(ctx, filepath.Join(refRoot, relPath), refKs, sources, unresolved)
+		if err != nil {
+			return report, fmt.Errorf("build %s: %w", options.Ref, err)
+		}
+	} else {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("%s does not exist at %s; every object is new", relPath, options.Ref))
+	}
+	report.Unresolved = sortedSetKeys(unresolved)
+
+	live := map[string]string{}
+	if !options.Offline && len(tree.live) > 0 {
+		live, err = previewLiveStates(ctx, strings.Join(tree.live, "---\n"))
+		if err != nil {
+			return report, err
+		}
+	}
+	assemblePreviewReport(&report, refBuild, tree, live, options.Offline)
+	return report, nil
+}
+
+func normalizePreviewPath(root, path string) (string, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return "", fmt.Errorf("--path is required")
+	}
+	if filepath.IsAbs(path) {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return "", fmt.Errorf("path %s is outside %s", path, root)
+		}
+		path = rel
+	}
+	path = filepath.ToSlash(filepath.Clean(path))
+	if path == "." || strings.HasPrefix(path, "../") {
+		return "", fmt.Errorf("path %s must be a directory inside the repository", path)
+	}
+	return path, nil
+}
+
+// extractPreviewArchive unpacks a git archive tar into dest, rejecting entries
+// that would escape it.
+func extractPreviewArchive(archive []byte, dest string) error {
+	reader := tar.NewReader(bytes.NewReader(archive))
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if name == "." || strings.HasPrefix(name, "..") || filepath.IsAbs(name) {
+			return fmt.Errorf("unsafe archive entry %q", header.Name)
+		}
+		target := filepath.Join(dest, name)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o750); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o750); err != nil {
+				return err
+			}
+			content, err := io.ReadAll(reader)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(target, content, 0o600); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// findPreviewKustomization returns the Flux Kustomization in root's
+// kubernetes/apps tree whose spec.path is relPath, or nil when none is.
+func findPreviewKustomization(root, relPath string) (*previewKustomization, error) {
+	kustomizations, err := listPreviewKustomizations(root)
+	if err != nil {
+		return nil, err
+	}
+	for i := range kustomizations {
+		if kustomizations[i].Path == relPath {
+			return &kustomizations[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// listPreviewKustomizations parses every Flux Kustomization declared in the
+// ks.yaml files under root's kubernetes/apps tree, in file order.
+func listPreviewKustomizations(root string) ([]previewKustomization, error) {
+	appsDir := filepath.Join(root, "kubernetes", "apps")
+	if _, err := os.Stat(appsDir); err != nil {
+		return nil, nil
+	}
+	ksFiles, err := findKustomizationFiles(appsDir)
+	if err != nil {
+		return nil, err
+	}
+	type ksDocument struct {
+		Kind     string `yaml:"kind"`
+		Metadata struct {
+			Name      string `yaml:"name"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"metadata"`
+		Spec struct {
+			TargetNamespace string        `yaml:"targetNamespace"`
+			Path            string        `yaml:"path"`
+			PostBuild       fluxPostBuild `yaml:"postBuild"`
+		} `yaml:"spec"`
+	}
+	var kustomizations []previewKustomization
+	for _, ksFile := range ksFiles {
+		content, err := os.ReadFile(ksFile) // #nosec G304 -- ks.yaml paths come from walking the repository
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", ksFile, err)
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		for {
+			var doc ksDocument
+			if err := decoder.Decode(&doc); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return nil, fmt.Errorf("parse %s: %w", ksFile, err)
+			}
+			if doc.Kind != "Kustomization" || strings.TrimSpace(doc.Spec.Path) == "" {
+				continue
+			}
+			namespace := strings.TrimSpace(doc.Spec.TargetNamespace)
+			if namespace == "" {
+				namespace = strings.TrimSpace(doc.Metadata.Namespace)
+			}
+			kustomizations = append(kustomizations, previewKustomization{
+				Name:      doc.Metadata.Name,
+				Namespace: namespace,
+				Path:      filepath.ToSlash(filepath.Clean(doc.Spec.Path)),
+				PostBuild: doc.Spec.PostBuild,
+			})
+		}
+	}
+	return kustomizations, nil
+}
+
+// resolvePreviewSubstitutions loads the substituteFrom sources of ks and of
+// its live counterpart (where the root Flux patches add cluster-config), in
+// order, so later sources override earlier ones as they do in Flux.
+func resolvePreviewSubstitutions(ctx context.Context, ks *previewKustomization, offline bool, warnings []string) (map[string]string, []string) {
+	values := map[string]string{}
+	if offline {
+		if len(ks.PostBuild.SubstituteFrom) > 0 {
+			warnings = append(warnings, "offline: substituteFrom sources not read")
+		}
+		return values, warnings
+	}
+
+	refs := ks.PostBuild.SubstituteFrom
+	liveOutput, err := kubectlOutputCtxFn(ctx, "get", "kustomizations.kustomize.toolkit.fluxcd.io", ks.Name, "-n", ks.Namespace, "-o", "json")
+	if err != nil {
+		warnings = append(warnings, fmt.Sprintf("live Kustomization %s/%s not found; using ks.yaml substituteFrom only", ks.Namespace, ks.Name))
+	} else {
+		var live struct {
+			Spec struct {
+				PostBuild fluxPostBuild `json:"postBuild"`
+			} `json:"spec"`
+		}
+		if err := json.Unmarshal(liveOutput, &live); err != nil {
+			warnings = append(warnings, fmt.Sprintf("parse live Kustomization %s/%s: %v", ks.Namespace, ks.Name, err))
+		} else {
+			seen := map[string]bool{}
+			for _, ref := range refs {
+				seen[ref.Kind+"/"+ref.Name] = true
+			}
+			for _, ref := range live.Spec.PostBuild.SubstituteFrom {
+				if !seen[ref.Kind+"/"+ref.Name] {
+					refs = append(refs, ref)
+				}
+			}
+		}
+	}
+
+	for _, ref := range refs {
+		data, err := fetchPreviewSubstituteSource(ctx, ref.Kind, ref.Name, ks.Namespace)
+		if err != nil {
+			if !ref.Optional {
+				warnings = append(warnings, fmt.Sprintf("substituteFrom %s/%s: %v", ref.Kind, ref.Name, err))
+			}
+			continue
+		}
+		for key, value := range data {
+			values[key] = value
+		}
+	}
+	return values, warnings
+}
+
+func fetchPreviewSubstituteSource(ctx context.Context, kind, name, namespace string) (map[string]string, error) {
+	resource := strings.ToLower(kind)
+	if resource != "configmap" && resource != "secret" {
+		return nil, fmt.Errorf("unsupported kind %s", kind)
+	}
+	output, err := kubectlOutputCtxFn(ctx, "get", resource, name, "-n", namespace, "-o", "json")
+	if err != nil {
+		return nil, err
+	}
+	var object struct {
+		Data map[string]string `json:"data"`
+	}
+	if err := json.Unmarshal(output, &object); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", resource, err)
+	}
+	if resource == "configmap" {
+		return object.Data, nil
+	}
+	decoded := make(map[string]string, len(object.Data))
+	for key, value := range object.Data {
+		raw, err := decodeBase64Fn(value)
+		if err != nil {
+			return nil, fmt.Errorf("decode %s: %w", key, err)
+		}
+		decoded[key] = string(raw)
+	}
+	return decoded, nil
+}
+
+// buildPreviewSide renders dir, substitutes Flux variables per object, and
+// indexes the result. ks.yaml inline substitute values win over
+// substituteFrom sources, matching Flux precedence.
+func buildPreviewSide(ctx context.Context, dir string, ks *previewKustomization, sources map[string]string, unresolved map[string]bool) (*previewBuild, error) {
+	output, err := previewKustomizeBuildFn(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	var variables map[string]string
+	if ks != nil {
+		variables = make(map[string]string, len(sources)+len(ks.PostBuild.Substitute))
+		for key, value := range sources {
+			variables[key] = value
+		}
+		for key, value := range ks.PostBuild.Substitute {
+			variables[key] = value
+		}
+	}
+
+	build := &previewBuild{objects: map[string]previewRendered{}}
+	decoder := yaml.NewDecoder(bytes.NewReader(output))
+	for {
+		var object map[string]any
+		if err := decoder.Decode(&object); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("parse rendered manifests: %w", err)
+		}
+		if len(object) == 0 {
+			continue
+		}
+		if variables != nil && !fluxSubstitutionDisabled(object) {
+			object, err = substituteFluxObject(object, variables, unresolved)
+			if err != nil {
+				return nil, err
+			}
+		}
+		key, display, kind := previewObjectIdentity(object)
+		if key == "" {
+			continue
+		}
+		if kind != "Secret" {
+			raw, err := marshalPreviewYAML(object)
+			if err != nil {
+				return nil, err
+			}
+			build.live = append(build.live, raw)
+		}
+		normalized, err := marshalPreviewYAML(hashSecretValues(object))
+		if err != nil {
+			return nil, err
+		}
+		build.objects[key] = previewRendered{display: display, kind: kind, normalized: normalized}
+	}
+	return build, nil
+}
+
+func fluxSubstitutionDisabled(object map[string]any) bool {
+	metadata, _ := object["metadata"].(map[string]any)
+	for _, field := range []string{"annotations", "labels"} {
+		values, _ := metadata[field].(map[string]any)
+		if value, _ := values[fluxSubstituteAnnotation].(string); value == "disabled" {
+			return true
+		}
+	}
+	return false
+}
+
+// substituteFluxObject applies ${VAR} and ${VAR:=default} the way Flux's
+// non-strict mode does: unknown variables without a default render empty.
+func substituteFluxObject(object map[string]any, variables map[string]string, unresolved map[string]bool) (map[string]any, error) {
+	raw, err := yaml.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+	substituted := fluxVariablePattern.ReplaceAllStringFunc(string(raw), func(match string) string {
+		parts := fluxVariablePattern.FindStringSubmatch(match)
+		if value, ok := variables[parts[1]]; ok {
+			return value
+		}
+		if strings.Contains(match, ":=") {
+			return parts[2]
+		}
+		unresolved[parts[1]] = true
+		return ""
+	})
+	var result map[string]any
+	if err := yaml.Unmarshal([]byte(substituted), &result); err != nil {
+		return nil, fmt.Errorf("substituted manifest is not valid YAML: %w", err)
+	}
+	return result, nil
+}
+
+func previewObjectIdentity(object map[string]any) (key, display, kind string) {
+	apiVersion, _ := object["apiVersion"].(string)
+	kind, _ = object["kind"].(string)
+	metadata, _ := object["metadata"].(map[string]any)
+	name, _ := metadata["name"].(string)
+	namespace, _ := metadata["namespace"].(string)
+	if apiVersion == "" || kind == "" || name == "" {
+		return "", "", ""
+	}
+	group, _ := splitAPIVersion(apiVersion)
+	display = kind + "/" + name
+	if namespace != "" {
+		display = kind + "/" + namespace + "/" + name
+	}
+	return strings.Join([]string{group, kind, namespace, name}, "|"), display, kind
+}
+
+// hashSecretValues folds stringData into data, as the API server does on
+// write, and replaces every value with a sha256 digest of the decoded value.
+func hashSecretValues(object map[string]any) map[string]any {
+	if kind, _ := object["kind"].(string); kind != "Secret" {
+		return object
+	}
+	hashed := make(map[string]any, len(object))
+	for key, value := range object {
+		hashed[key] = value
+	}
+	digests := map[string]any{}
+	for _, field := range []string{"data", "stringData"} {
+		values, _ := object[field].(map[string]any)
+		for key, value := range values {
+			text := fmt.Sprint(value)
+			if field == "data" {
+				if decoded, err := decodeBase64Fn(text); err == nil {
+					text = string(decoded)
+				}
+			}
+			sum := sha256.Sum256([]byte(text))
+			digests[key] = "sha256:" + hex.EncodeToString(sum[:])[:16]
+		}
+	}
+	delete(hashed, "stringData")
+	if len(digests) > 0 {
+		hashed["data"] = digests
+	}
+	return hashed
+}
+
+func marshalPreviewYAML(object map[string]any) (string, error) {
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(object); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+func previewLiveStates(ctx context.Context, manifest string) (map[string]string, error) {
+	diffOutput, err := kubectlDiffManifestFn(ctx, manifest)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := summarizeKustomizationDiff(manifest, diffOutput)
+	if err != nil {
+		return nil, fmt.Errorf("summarize kubectl diff: %w", err)
+	}
+	states := map[string]string{}
+	for _, name := range summary.Changed {
+		states[name] = previewChanged
+	}
+	for _, name := range summary.Added {
+		states[name] = previewAdded
+	}
+	return states, nil
+}
+
+func assemblePreviewReport(report *previewReport, ref, tree *previewBuild, live map[string]string, offline bool) {
+	keys := map[string]bool{}
+	for key := range ref.objects {
+		keys[key] = true
+	}
+	for key := range tree.objects {
+		keys[key] = true
+	}
+	for _, key := range sortedSetKeys(keys) {
+		before, inRef := ref.objects[key]
+		after, inTree := tree.objects[key]
+		object := previewObject{}
+		switch {
+		case !inTree:
+			object.Object = before.display
+			object.Ref = previewRemoved
+			object.Diff = previewWholeObjectDiff(before.display, before.normalized, '-')
+			report.Removed++
+		case !inRef:
+			object.Object = after.display
+			object.Ref = previewAdded
+			object.Diff = previewWholeObjectDiff(after.display, after.normalized, '+')
+			report.Added++
+		case before.normalized != after.normalized:
+			object.Object = after.display
+			object.Ref = previewChanged
+			object.Diff = shareddiff.Unified(after.display, []byte(before.normalized), []byte(after.normalized))
+			report.Changed++
+		default:
+			object.Object = after.display
+			object.Ref = previewUnchanged
+			report.Unchanged++
+		}
+		switch {
+		case offline || !inTree:
+			object.Live = "-"
+		case after.kind == "Secret":
+			object.Live = previewSkipped
+		case live[after.display] != "":
+			object.Live = live[after.display]
+		default:
+			object.Live = previewInSync
+		}
+		report.Objects = append(report.Objects, object)
+	}
+	sort.SliceStable(report.Objects, func(i, j int) bool { return report.Objects[i].Object < report.Objects[j].Object })
+}
+
+// previewWholeObjectDiff renders an added or removed object as a one-sided
+// unified diff; shareddiff.Unified would emit a spurious empty line for the
+// missing side.
+func previewWholeObjectDiff(display, text string, prefix byte) string {
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	var builder strings.Builder
+	if prefix == '+' {
+		fmt.Fprintf(&builder, "--- /dev/null\n+++ b/%s\n@@ -0,0 +1,%d @@\n", display, len(lines))
+	} else {
+		fmt.Fprintf(&builder, "--- a/%s\n+++ /dev/null\n@@ -1,%d +0,0 @@\n", display, len(lines))
+	}
+	for _, line := range lines {
+		builder.WriteByte(prefix)
+		builder.WriteString(line)
+		builder.WriteByte('\n')
+	}
+	return builder.String()
+}
+
+func renderPreviewReport(report previewReport, output string) (string, error) {
+	if output == "json" {
+		return ui.RenderJSON(report)
+	}
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Preview %s: working tree vs %s\n", report.Path, report.Ref)
+	if report.Kustomization != "" {
+		fmt.Fprintf(&builder, "Kustomization: %s\n", report.Kustomization)
+	}
+	for _, warning := range report.Warnings {
+		fmt.Fprintf(&builder, "Warning: %s\n", warning)
+	}
+	if len(report.Unresolved) > 0 {
+		fmt.Fprintf(&builder, "Unresolved variables (rendered empty): %s\n", strings.Join(report.Unresolved, ", "))
+	}
+	rows := make([][]string, 0, len(report.Objects))
+	for _, object := range report.Objects {
+		rows = append(rows, []string{object.Object, object.Ref, object.Live})
+	}
+	fmt.Fprintf(&builder, "\n%s\n\n", ui.Table([]string{"OBJECT", "VS " + strings.ToUpper(report.Ref), "VS LIVE"}, rows))
+	fmt.Fprintf(&builder, "Summary: %d added, %d removed, %d changed, %d unchanged", report.Added, report.Removed, report.Changed, report.Unchanged)
+	for _, object := range report.Objects {
+		if object.Diff != "" {
+			builder.WriteString("\n\n")
+			builder.WriteString(strings.TrimSuffix(object.Diff, "\n"))
+		}
+	}
+	return builder.String(), nil
+}
@@ -0,0 +1,182 @@
+package kubernetes
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"homeops-cli/internal/testutil"
+)
+
+// fakePreviewRepo serves testdata/preview/tree as the working tree and
+// testdata/preview/ref as the committed tree, and renders kustomizations by
+// concatenating their resources.
+func fakePreviewRepo(t *testing.T) {
+	t.Helper()
+	treeRoot, err := filepath.Abs(filepath.Join("testdata", "preview", "tree"))
+	require.NoError(t, err)
+	refRoot, err := filepath.Abs(filepath.Join("testdata", "preview", "ref"))
+	require.NoError(t, err)
+
+	testutil.Swap(t, &previewGitRootFn, func() (string, error) { return treeRoot, nil })
+	testutil.Swap(t, &previewGitArchiveFn, func(_ context.Context, root, ref, pathspec string) ([]byte, error) {
+		assert.Equal(t, treeRoot, root)
+		assert.Equal(t, "main", ref)
+		return tarPreviewDir(t, refRoot, pathspec), nil
+	})
+	testutil.Swap(t, &previewKustomizeBuildFn, func(_ context.Context, dir string) ([]byte, error) {
+		var kustomization struct {
+			Resources []string `yaml:"resources"`
+		}
+		raw, err := os.ReadFile(filepath.Join(dir, "kustomization.yaml"))
+		if err != nil {
+			return nil, err
+		}
+		require.NoError(t, yaml.Unmarshal(raw, &kustomization))
+		var manifest bytes.Buffer
+		for _, resource := range kustomization.Resources {
+			content, err := os.ReadFile(filepath.Join(dir, resource))
+			if err != nil {
+				return nil, err
+			}
+			manifest.Write(content)
+		}
+		return manifest.Bytes(), nil
+	})
+}
+
+func tarPreviewDir(t *testing.T, root, pathspec string) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	writer := tar.NewWriter(&buffer)
+	require.NoError(t, filepath.WalkDir(filepath.Join(root, pathspec), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if err := writer.WriteHeader(&tar.Header{Name: filepath.ToSlash(rel), Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			return err
+		}
+		_, err = writer.Write(content)
+		return err
+	}))
+	require.NoError(t, writer.Close())
+	return buffer.Bytes()
+}
+
+func fakePreviewCluster(t *testing.T) *string {
+	t.Helper()
+	objects := map[string]any{
+		"get kustomizations.kustomize.toolkit.fluxcd.io demo -n media -o json": map[string]any{
+			"spec": map[string]any{"postBuild": map[string]any{"substituteFrom": []map[string]any{
+				{"kind": "ConfigMap", "name": "demo-vars"},
+				{"kind": "Secret", "name": "cluster-config-secret"},
+			}}},
+		},
+		"get configmap demo-vars -n media -o json": map[string]any{"data": map[string]string{"TZ": "Europe/Oslo"}},
+		"get secret cluster-config-secret -n media -o json": map[string]any{
+			"data": map[string]string{"SECRET_DOMAIN": "ZXhhbXBsZS5jb20="},
+		},
+	}
+	testutil.Swap(t, &kubectlOutputCtxFn, func(_ context.Context, args ...string) ([]byte, error) {
+		object, ok := objects[strings.Join(args, " ")]
+		if !ok {
+			return nil, fmt.Errorf("unexpected kubectl %v", args)
+		}
+		return json.Marshal(object)
+	})
+	var sent string
+	testutil.Swap(t, &kubectlDiffManifestFn, func(_ context.Context, manifest string) (string, error) {
+		sent = manifest
+		return "diff -u -N /tmp/LIVE-1/apps.v1.Deployment.media.demo /tmp/MERGED-1/apps.v1.Deployment.media.demo\n" +
+			"@@ -10,1 +10,1 @@\n-        image: ghcr.io/example/demo:1.0.0\n+        image: ghcr.io/example/demo:1.1.0\n" +
+			"diff -u -N /tmp/LIVE-1/networking.k8s.io.v1.Ingress.media.demo /tmp/MERGED-1/networking.k8s.io.v1.Ingress.media.demo\n" +
+			"@@ -0,0 +1,3 @@\n+apiVersion: networking.k8s.io/v1\n", nil
+	})
+	return &sent
+}
+
+func assertPreviewGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", "preview", name)
+	if os.Getenv("UPDATE_GOLDEN") == "1" {
+		require.NoError(t, os.WriteFile(path, []byte(got), 0o600))
+	}
+	want, err := os.ReadFile(path)
+	require.NoError(t, err, "missing golden %s (run with UPDATE_GOLDEN=1)", path)
+	assert.Equal(t, string(want), got)
+}
+
+func TestPreviewGolden(t *testing.T) {
+	fakePreviewRepo(t)
+	sent := fakePreviewCluster(t)
+
+	table, err := testutil.ExecuteCommand(newPreviewCommand(), "--path", "./kubernetes/apps/media/demo/app")
+	require.NoError(t, err)
+	assertPreviewGolden(t, "table.golden", table)
+	assert.NotContains(t, *sent, "kind: Secret", "Secrets never reach the dry-run")
+	assert.Contains(t, *sent, "https://demo.example.com", "live cluster-config substitutions are applied")
+	assert.NotContains(t, table, "new-api-key")
+	assert.NotContains(t, table, "bmV3LWFwaS1rZXk=")
+
+	jsonOut, err := testutil.ExecuteCommand(newPreviewCommand(), "--path", "kubernetes/apps/media/demo/app", "--output", "json")
+	require.NoError(t, err)
+	assertPreviewGolden(t, "json.golden", jsonOut)
+}
+
+func TestPreviewOfflineSkipsCluster(t *testing.T) {
+	fakePreviewRepo(t)
+	testutil.Swap(t, &kubectlOutputCtxFn, func(context.Context, ...string) ([]byte, error) {
+		t.Fatal("offline preview must not query the cluster")
+		return nil, nil
+	})
+	testutil.Swap(t, &kubectlDiffManifestFn, func(context.Context, string) (string, error) {
+		t.Fatal("offline preview must not run kubectl diff")
+		return "", nil
+	})
+
+	out, err := testutil.ExecuteCommand(newPreviewCommand(), "--path", "kubernetes/apps/media/demo/app", "--offline", "--output", "json")
+	require.NoError(t, err)
+	var report previewReport
+	require.NoError(t, json.Unmarshal([]byte(out), &report))
+	assert.Equal(t, []string{"MISSING_PREFIX", "SECRET_DOMAIN", "TZ"}, report.Unresolved)
+	for _, object := range report.Objects {
+		assert.Equal(t, "-", object.Live, object.Object)
+	}
+	assert.Equal(t, 1, report.Added)
+	assert.Equal(t, 1, report.Removed)
+}
+
+func TestPreviewRejectsPathsOutsideRepo(t *testing.T) {
+	_, err := normalizePreviewPath("/repo", "../elsewhere")
+	require.Error(t, err)
+	_, err = normalizePreviewPath("/repo", ".")
+	require.Error(t, err)
+	rel, err := normalizePreviewPath("/repo", "/repo/kubernetes/apps/media/demo/app/")
+	require.NoError(t, err)
+	assert.Equal(t, "kubernetes/apps/media/demo/app", rel)
+
+	var buffer bytes.Buffer
+	writer := tar.NewWriter(&buffer)
+	require.NoError(t, writer.WriteHeader(&tar.Header{Name: "../escape.yaml", Mode: 0o644, Typeflag: tar.TypeReg}))
+	require.NoError(t, writer.Close())
+	require.Error(t, extractPreviewArchive(buffer.Bytes(), t.TempDir()))
+}
@@ -226,7 +226,7 @@ func newRightSizeCommand() *cobra.Command {
 	cmd.Flags().Float64Var(&minSavings, "min-savings", 0, "hide overprovisioned rows below this estimated savings percentage")
 	cmd.Flags().BoolVar(&applyGit, "apply-git", false, "preview surgical HelmRelease resource request edits")
 	cmd.Flags().BoolVar(&write, "write", false, "write --apply-git edits to disk (never commits)")
-	cmd.Flags().StringVar(&repoRoot, "repo-root", "", "GitOps repository root (default: git rev-parse --show-toplevel)")
+	cmd.Flags().StringVar(&repoRoot, "repo-root", "", "GitOps repository root (default: --root-dir, HOMEOPS_ROOT, or the enclosing checkout)")
 	_ = cmd.RegisterFlagCompletionFunc("namespace", completion.ValidNamespaces)
 	return cmd
 }
@@ -39,12 +39,12 @@ type rightSizeGitFile struct {
 	updated  []byte
 }
 
-var rightSizeGitRootFn = func(ctx context.Context) (string, error) {
-	output, err := common.RunCommandWithContextOutput(ctx, "git", "rev-parse", "--show-toplevel")
+var rightSizeGitRootFn = func(context.Context) (string, error) {
+	root, err := common.RepoRoot()
 	if err != nil {
 		return "", fmt.Errorf("find GitOps repository root: %w", err)
 	}
-	return strings.TrimSpace(string(output)), nil
+	return root, nil
 }
 
 func applyRightSizeReportToGit(ctx context.Context, report rightSizeReport, options rightSizeGitOptions, out io.Writer) error {
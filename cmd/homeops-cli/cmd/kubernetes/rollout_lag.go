@@ -0,0 +1,601 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"homeops-cli/cmd/completion"
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/kubeutil"
+	"homeops-cli/internal/ui"
+)
+
+// rolloutLagBumpTimeFn returns the commit time of the newest commit under
+// path that added or removed needle (git log -S), or the zero time when no
+// commit did.
+var rolloutLagBumpTimeFn = func(ctx context.Context, root, path, needle string) (time.Time, error) {
+	output, err := common.RunCommandWithContextOutput(ctx, "git", "-C", root, "log", "-1", "--format=%ct", "-S"+needle, "--", path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	raw := strings.TrimSpace(string(output))
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	seconds, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse commit time %q: %w", raw, err)
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// imageRef is a container image split into its comparable parts. Docker Hub
+// repositories are normalized so nginx and docker.io/library/nginx match.
+type imageRef struct {
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+func parseImageRef(image string) imageRef {
+	image = strings.TrimSpace(image)
+	var ref imageRef
+	if at := strings.Index(image, "@"); at >= 0 {
+		ref.Digest = image[at+1:]
+		image = image[:at]
+	}
+	if colon := strings.LastIndex(image, ":"); colon > strings.LastIndex(image, "/") {
+		ref.Tag = image[colon+1:]
+		image = image[:colon]
+	} else if ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	for _, prefix := range []string{"docker.io/", "index.docker.io/"} {
+		image = strings.TrimPrefix(image, prefix)
+	}
+	ref.Repository = strings.TrimPrefix(image, "library/")
+	return ref
+}
+
+// pinned reports whether r names a version; a tag variable that rendered
+// empty does not.
+func (r imageRef) pinned() bool { return r.Tag != "" || r.Digest != "" }
+
+// Version is the tag and a shortened digest, as shown in reports.
+func (r imageRef) Version() string {
+	version := r.Tag
+	if r.Digest != "" {
+		digest := r.Digest
+		if prefix, hexDigest, ok := strings.Cut(digest, ":"); ok && len(hexDigest) > 12 {
+			digest = prefix + ":" + hexDigest[:12]
+		}
+		version += "@" + digest
+	}
+	return version
+}
+
+// sameVersion compares digests when both sides pin one, tags otherwise. A
+// digest-only reference cannot be compared with a tag-only one and is
+// treated as in sync rather than reported on a guess.
+func (r imageRef) sameVersion(other imageRef) bool {
+	switch {
+	case r.Digest != "" && other.Digest != "":
+		return r.Digest == other.Digest
+	case r.Tag != "" && other.Tag != "":
+		return r.Tag == other.Tag
+	}
+	return true
+}
+
+// rolloutLagIntent is one image the repo declares for a namespace.
+type rolloutLagIntent struct {
+	Kustomization previewKustomization
+	Namespace     string
+	// Source is the declaring object: a rendered workload (Deployment/demo)
+	// or the HelmRelease whose values carry the image (HelmRelease/plex).
+	Source string
+	// Container is set for rendered workloads; Field is the values path for
+	// HelmRelease images.
+	Container string
+	Field     string
+	Image     imageRef
+}
+
+// rolloutLagRunning is one container image of a live workload.
+type rolloutLagRunning struct {
+	Namespace string
+	Workload  string
+	Container string
+	// Release is the Helm release that labelled the pod, if any.
+	Release string
+	Image   imageRef
+}
+
+type rolloutLagEntry struct {
+	Namespace     string   `json:"namespace"`
+	Workload      string   `json:"workload"`
+	Container     string   `json:"container"`
+	Image         string   `json:"image"`
+	Running       string   `json:"running"`
+	Git           string   `json:"git"`
+	Source        string   `json:"source"`
+	Kustomization string   `json:"kustomization"`
+	BumpedAt      string   `json:"bumped_at,omitempty"`
+	Behind        string   `json:"behind,omitempty"`
+	Suspended     []string `json:"suspended,omitempty"`
+	Notes         []string `json:"notes,omitempty"`
+
+	intent rolloutLagIntent
+}
+
+type rolloutLagReport struct {
+	Matched   int               `json:"matched"`
+	Lagging   []rolloutLagEntry `json:"lagging"`
+	Suspended []string          `json:"suspended,omitempty"`
+	Warnings  []string          `json:"warnings,omitempty"`
+}
+
+type rolloutLagPodList struct {
+	Items []struct {
+		Metadata struct {
+			Name              string                    `json:"name"`
+			Namespace         string                    `json:"namespace"`
+			Labels            map[string]string         `json:"labels"`
+			OwnerReferences   []kubeutil.OwnerReference `json:"ownerReferences"`
+			DeletionTimestamp string                    `json:"deletionTimestamp"`
+		} `json:"metadata"`
+		Spec struct {
+			Containers     []rolloutLagPodContainer `json:"containers"`
+			InitContainers []rolloutLagPodContainer `json:"initContainers"`
+		} `json:"spec"`
+		Status struct {
+			Phase string `json:"phase"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+type rolloutLagPodContainer struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+}
+
+func newRolloutLagCommand() *cobra.Command {
+	var namespace, output string
+	cmd := &cobra.Command{
+		Use:          "rollout-lag",
+		Short:        "Find workloads still running image tags that git has already bumped",
+		SilenceUsage: true,
+		Long: `Renders every Flux Kustomization under kubernetes/apps from the working tree
+with the same kubectl kustomize renderer as k8s preview, collects the images it
+declares (workload containers after kustomize images overrides, and image
+maps in HelmRelease values), and compares them with the images of the running
+pods.
+
+A container is reported when git declares a different tag, or a different
+digest when both sides pin one. The behind-for age is measured from the newest
+commit under the Kustomization's path that introduced the declared tag.
+Suspended Kustomizations and HelmReleases covering a lagging workload are
+called out, as are HelmReleases that are not Ready. Running images that git
+does not declare are ignored.`,
+		Example: `  homeops-cli k8s rollout-lag
+  homeops-cli k8s rollout-lag --namespace media --output json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ui.ValidateOutputFormat(output); err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			report, err := runRolloutLag(ctx, namespace)
+			if err != nil {
+				return err
+			}
+			rendered, err := renderRolloutLagReport(report, output)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), rendered)
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "only check one namespace (default: all)")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table or json")
+	_ = cmd.RegisterFlagCompletionFunc("namespace", completion.ValidNamespaces)
+	return cmd
+}
+
+func runRolloutLag(ctx context.Context, namespace string) (rolloutLagReport, error) {
+	var report rolloutLagReport
+	root, err := previewGitRootFn()
+	if err != nil {
+		return report, err
+	}
+	kustomizations, err := listPreviewKustomizations(root)
+	if err != nil {
+		return report, err
+	}
+	var intents []rolloutLagIntent
+	for _, ks := range kustomizations {
+		if namespace != "" && ks.Namespace != namespace {
+			continue
+		}
+		if info, statErr := os.Stat(filepath.Join(root, ks.Path)); statErr != nil || !info.IsDir() {
+			continue
+		}
+		rendered, err := previewKustomizeBuildFn(ctx, filepath.Join(root, ks.Path))
+		if err != nil {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("render %s/%s: %v", ks.Namespace, ks.Name, err))
+			continue
+		}
+		found, err := extractRolloutLagIntents(ks, rendered)
+		if err != nil {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("parse %s/%s: %v", ks.Namespace, ks.Name, err))
+			continue
+		}
+		intents = append(intents, found...)
+	}
+
+	var pods rolloutLagPodList
+	if err := kubeutil.GetJSON(ctx, kubectlOutputCtxFn, namespace, "pods", &pods); err != nil {
+		return report, err
+	}
+	var liveKustomizations fluxKustomizationList
+	if err := kubeutil.GetJSON(ctx, kubectlOutputCtxFn, "", fluxKustomizationResource, &liveKustomizations); err != nil {
+		return report, err
+	}
+	var releases fluxHelmReleaseList
+	if err := kubeutil.GetJSON(ctx, kubectlOutputCtxFn, namespace, fluxHelmReleaseResource, &releases); err != nil {
+		return report, err
+	}
+
+	report.Matched, report.Lagging = matchRolloutLag(intents, runningRolloutLagImages(pods))
+	annotateRolloutLagFlux(&report, liveKustomizations.Items, releases.Items)
+
+	now := nowFn()
+	for i := range report.Lagging {
+		entry := &report.Lagging[i]
+		needle := entry.intent.Image.Digest
+		if needle == "" {
+			needle = entry.intent.Image.Tag
+		}
+		bumped, err := rolloutLagBumpTimeFn(ctx, root, entry.intent.Kustomization.Path, needle)
+		if err != nil {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("git log for %s in %s: %v", needle, entry.intent.Kustomization.Path, err))
+			continue
+		}
+		if bumped.IsZero() {
+			continue
+		}
+		entry.BumpedAt = bumped.Format(time.RFC3339)
+		if now.After(bumped) {
+			entry.Behind = displayAge(now.Sub(bumped))
+		}
+	}
+	return report, nil
+}
+
+// extractRolloutLagIntents reads the images ks declares from its rendered
+// manifests. Inline postBuild substitute values are applied; substituteFrom
+// sources are not read, so images built from cluster variables are skipped.
+func extractRolloutLagIntents(ks previewKustomization, rendered []byte) ([]rolloutLagIntent, error) {
+	var intents []rolloutLagIntent
+	decoder := yaml.NewDecoder(bytes.NewReader(rendered))
+	for {
+		var object map[string]any
+		if err := decoder.Decode(&object); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("parse rendered manifests: %w", err)
+		}
+		if len(object) == 0 {
+			continue
+		}
+		if len(ks.PostBuild.Substitute) > 0 && !fluxSubstitutionDisabled(object) {
+			substituted, err := substituteFluxObject(object, ks.PostBuild.Substitute, map[string]bool{})
+			if err != nil {
+				return nil, err
+			}
+			object = substituted
+		}
+		kind, _ := object["kind"].(string)
+		metadata, _ := object["metadata"].(map[string]any)
+		name, _ := metadata["name"].(string)
+		namespace, _ := metadata["namespace"].(string)
+		if namespace == "" {
+			namespace = ks.Namespace
+		}
+		if name == "" {
+			continue
+		}
+		intent := rolloutLagIntent{Kustomization: ks, Namespace: namespace, Source: kind + "/" + name}
+		if kind == "HelmRelease" {
+			spec, _ := object["spec"].(map[string]any)
+			walkHelmValuesImages(spec["values"], "values", func(field string, image imageRef) {
+				found := intent
+				found.Field, found.Image = field, image
+				intents = append(intents, found)
+			})
+			continue
+		}
+		for _, container := range workloadContainers(kind, object) {
+			image, _ := container["image"].(string)
+			containerName, _ := container["name"].(string)
+			ref := parseImageRef(image)
+			if strings.TrimSpace(image) == "" || strings.Contains(image, "${") || !ref.pinned() {
+				continue
+			}
+			found := intent
+			found.Container, found.Image = containerName, ref
+			intents = append(intents, found)
+		}
+	}
+	return intents, nil
+}
+
+// workloadContainers returns the containers and init containers of a
+// workload's pod template, or nil for any other kind.
+func workloadContainers(kind string, object map[string]any) []map[string]any {
+	var template []string
+	switch kind {
+	case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "Job":
+		template = []string{"spec", "template", "spec"}
+	case "CronJob":
+		template = []string{"spec", "jobTemplate", "spec", "template", "spec"}
+	default:
+		return nil
+	}
+	current := object
+	for _, key := range template {
+		current, _ = current[key].(map[string]any)
+	}
+	var containers []map[string]any
+	for _, field := range []string{"initContainers", "containers"} {
+		items, _ := current[field].([]any)
+		for _, item := range items {
+			if container, ok := item.(map[string]any); ok {
+				containers = append(containers, container)
+			}
+		}
+	}
+	return containers
+}
+
+// walkHelmValuesImages finds image maps in HelmRelease values: a map with
+// repository and tag (plus an optional registry), or an image key holding a
+// full reference. Keys are visited in sorted order.
+func walkHelmValuesImages(node any, field string, visit func(string, imageRef)) {
+	switch value := node.(type) {
+	case map[string]any:
+		repository, _ := value["repository"].(string)
+		if tag, ok := value["tag"]; ok && repository != "" && tag != nil {
+			if registry, _ := value["registry"].(string); registry != "" {
+				repository = strings.TrimSuffix(registry, "/") + "/" + repository
+			}
+			reference := repository + ":" + fmt.Sprint(tag)
+			if digest, _ := value["digest"].(string); digest != "" {
+				reference += "@" + digest
+			}
+			if image := parseImageRef(reference); image.pinned() && !strings.Contains(reference, "${") {
+				visit(field, image)
+			}
+			return
+		}
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if image, ok := value[key].(string); ok && key == "image" {
+				if ref := parseImageRef(image); strings.Contains(image, ":") && ref.pinned() && !strings.Contains(image, "${") {
+					visit(field+".image", ref)
+				}
+				continue
+			}
+			walkHelmValuesImages(value[key], field+"."+key, visit)
+		}
+	case []any:
+		for i, item := range value {
+			walkHelmValuesImages(item, fmt.Sprintf("%s[%d]", field, i), visit)
+		}
+	}
+}
+
+// runningRolloutLagImages lists the distinct container images of every
+// active pod, keyed by the workload that owns it.
+func runningRolloutLagImages(pods rolloutLagPodList) []rolloutLagRunning {
+	seen := map[string]bool{}
+	var running []rolloutLagRunning
+	for _, pod := range pods.Items {
+		if pod.Metadata.DeletionTimestamp != "" || pod.Status.Phase == "Succeeded" || pod.Status.Phase == "Failed" {
+			continue
+		}
+		workload := podWorkload(pod.Metadata.Name, pod.Metadata.Labels, pod.Metadata.OwnerReferences)
+		release := pod.Metadata.Labels["app.kubernetes.io/instance"]
+		if release == "" {
+			release = pod.Metadata.Labels["helm.toolkit.fluxcd.io/name"]
+		}
+		for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+			key := strings.Join([]string{pod.Metadata.Namespace, workload, container.Name, container.Image}, "|")
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			running = append(running, rolloutLagRunning{
+				Namespace: pod.Metadata.Namespace,
+				Workload:  workload,
+				Container: container.Name,
+				Release:   release,
+				Image:     parseImageRef(container.Image),
+			})
+		}
+	}
+	sort.Slice(running, func(i, j int) bool {
+		a, b := running[i], running[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Workload != b.Workload {
+			return a.Workload < b.Workload
+		}
+		return a.Container < b.Container
+	})
+	return running
+}
+
+// matchRolloutLag pairs each running image with the intent for the same
+// repository in the same namespace: the rendered workload and container
+// first, then the rendered workload, then the HelmRelease that labelled the
+// pod, then the only declared version of that repository in the namespace.
+// Running images with no unambiguous intent are not counted.
+func matchRolloutLag(intents []rolloutLagIntent, running []rolloutLagRunning) (int, []rolloutLagEntry) {
+	byRepository := map[string][]rolloutLagIntent{}
+	for _, intent := range intents {
+		key := intent.Namespace + "|" + intent.Image.Repository
+		byRepository[key] = append(byRepository[key], intent)
+	}
+	matched := 0
+	var lagging []rolloutLagEntry
+	for _, live := range running {
+		intent, ok := pickRolloutLagIntent(byRepository[live.Namespace+"|"+live.Image.Repository], live)
+		if !ok {
+			continue
+		}
+		matched++
+		if intent.Image.sameVersion(live.Image) {
+			continue
+		}
+		source := intent.Source
+		if intent.Field != "" {
+			source += " " + intent.Field
+		}
+		lagging = append(lagging, rolloutLagEntry{
+			Namespace:     live.Namespace,
+			Workload:      live.Workload,
+			Container:     live.Container,
+			Image:         live.Image.Repository,
+			Running:       live.Image.Version(),
+			Git:           intent.Image.Version(),
+			Source:        source,
+			Kustomization: namespacedName(intent.Kustomization.Namespace, intent.Kustomization.Name),
+			intent:        intent,
+		})
+	}
+	return matched, lagging
+}
+
+func pickRolloutLagIntent(candidates []rolloutLagIntent, live rolloutLagRunning) (rolloutLagIntent, bool) {
+	for _, match := range []func(rolloutLagIntent) bool{
+		func(i rolloutLagIntent) bool { return i.Source == live.Workload && i.Container == live.Container },
+		func(i rolloutLagIntent) bool { return i.Source == live.Workload },
+		func(i rolloutLagIntent) bool { return live.Release != "" && i.Source == "HelmRelease/"+live.Release },
+	} {
+		for _, candidate := range candidates {
+			if match(candidate) {
+				return candidate, true
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return rolloutLagIntent{}, false
+	}
+	for _, candidate := range candidates[1:] {
+		if candidate.Image != candidates[0].Image {
+			return rolloutLagIntent{}, false
+		}
+	}
+	return candidates[0], true
+}
+
+// annotateRolloutLagFlux marks lagging entries whose Kustomization or
+// HelmRelease is suspended, and notes HelmReleases that are not Ready.
+func annotateRolloutLagFlux(report *rolloutLagReport, kustomizations []fluxKustomization, releases []fluxHelmRelease) {
+	suspended := map[string]bool{}
+	for i := range report.Lagging {
+		entry := &report.Lagging[i]
+		ks := entry.intent.Kustomization
+		for _, item := range kustomizations {
+			if item.Metadata.Name != ks.Name || (item.Metadata.Namespace != ks.Namespace && item.Spec.TargetNamespace != ks.Namespace) {
+				continue
+			}
+			name := "Kustomization " + namespacedName(item.Metadata.Namespace, item.Metadata.Name)
+			entry.Kustomization = namespacedName(item.Metadata.Namespace, item.Metadata.Name)
+			if item.Spec.Suspend {
+				entry.Suspended = append(entry.Suspended, name)
+				suspended[name] = true
+			} else if ready, message := fluxReady(item.Status.Conditions); ready != "True" {
+				entry.Notes = append(entry.Notes, name+" not ready: "+message)
+			}
+			break
+		}
+		release, isRelease := strings.CutPrefix(entry.intent.Source, "HelmRelease/")
+		if !isRelease {
+			continue
+		}
+		for _, item := range releases {
+			if item.Metadata.Name != release || item.Metadata.Namespace != entry.intent.Namespace {
+				continue
+			}
+			name := "HelmRelease " + namespacedName(item.Metadata.Namespace, item.Metadata.Name)
+			if item.Spec.Suspend {
+				entry.Suspended = append(entry.Suspended, name)
+				suspended[name] = true
+			} else if ready, message := fluxReady(item.Status.Conditions); ready != "True" {
+				entry.Notes = append(entry.Notes, name+" not ready: "+message)
+			}
+			break
+		}
+	}
+	report.Suspended = sortedSetKeys(suspended)
+}
+
+func renderRolloutLagReport(report rolloutLagReport, output string) (string, error) {
+	if output == "json" {
+		if report.Lagging == nil {
+			report.Lagging = []rolloutLagEntry{}
+		}
+		return ui.RenderJSON(report)
+	}
+	var builder strings.Builder
+	for _, warning := range report.Warnings {
+		fmt.Fprintf(&builder, "Warning: %s\n", warning)
+	}
+	if len(report.Lagging) == 0 {
+		fmt.Fprintf(&builder, "All %d matched containers run the images declared in git", report.Matched)
+		return builder.String(), nil
+	}
+	rows := make([][]string, 0, len(report.Lagging))
+	for _, entry := range report.Lagging {
+		behind := entry.Behind
+		if behind == "" {
+			behind = "unknown"
+		}
+		notes := entry.Notes
+		if len(entry.Suspended) > 0 {
+			notes = append([]string{"SUSPENDED: " + strings.Join(entry.Suspended, ", ")}, notes...)
+		}
+		rows = append(rows, []string{namespacedName(entry.Namespace, entry.Workload), entry.Container, entry.Image,
+			entry.Running, entry.Git, behind, strings.Join(notes, "; ")})
+	}
+	fmt.Fprintf(&builder, "%s\n\n", ui.Table([]string{"WORKLOAD", "CONTAINER", "IMAGE", "RUNNING", "GIT", "BEHIND FOR", "NOTES"}, rows))
+	fmt.Fprintf(&builder, "Summary: %d of %d matched containers are behind git", len(report.Lagging), report.Matched)
+	if len(report.Suspended) > 0 {
+		fmt.Fprintf(&builder, "\nSuspended, holding back a rollout: %s", strings.Join(report.Suspended, ", "))
+	}
+	return builder.String(), nil
+}
@@ -0,0 +1,181 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/testutil"
+)
+
+const rolloutLagPlexDigest = "sha256:4f1c6d2e8a3b5c7d9e0f1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d"
+
+// fakeRolloutLagRepo serves testdata/rollout-lag/repo as the working tree.
+// Each app directory renders to testdata/rollout-lag/rendered/<app>.yaml,
+// which is what kubectl kustomize produces for it (the demo images override
+// already applied).
+func fakeRolloutLagRepo(t *testing.T) string {
+	t.Helper()
+	root, err := filepath.Abs(filepath.Join("testdata", "rollout-lag", "repo"))
+	require.NoError(t, err)
+	testutil.Swap(t, &previewGitRootFn, func() (string, error) { return root, nil })
+	testutil.Swap(t, &previewKustomizeBuildFn, func(_ context.Context, dir string) ([]byte, error) {
+		return os.ReadFile(filepath.Join("testdata", "rollout-lag", "rendered", filepath.Base(filepath.Dir(dir))+".yaml"))
+	})
+	return root
+}
+
+func rolloutLagRendered(t *testing.T, app string) []byte {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", "rollout-lag", "rendered", app+".yaml"))
+	require.NoError(t, err)
+	return raw
+}
+
+func TestRolloutLagExtractsIntents(t *testing.T) {
+	root := fakeRolloutLagRepo(t)
+	kustomizations, err := listPreviewKustomizations(root)
+	require.NoError(t, err)
+	require.Len(t, kustomizations, 2)
+	demo, plex := kustomizations[0], kustomizations[1]
+	assert.Equal(t, "kubernetes/apps/media/demo/app", demo.Path)
+
+	intents, err := extractRolloutLagIntents(demo, rolloutLagRendered(t, "demo"))
+	require.NoError(t, err)
+	var got []string
+	for _, intent := range intents {
+		assert.Equal(t, "media", intent.Namespace, "targetNamespace applies to unnamespaced objects")
+		got = append(got, fmt.Sprintf("%s %s %s:%s", intent.Source, intent.Container, intent.Image.Repository, intent.Image.Tag))
+	}
+	assert.Equal(t, []string{
+		"Deployment/demo migrate ghcr.io/example/demo:1.1.0",
+		"Deployment/demo app ghcr.io/example/demo:1.1.0",
+		"Deployment/demo proxy nginx:1.27",
+	}, got, "the kustomize images override is read from the rendered workload")
+
+	intents, err = extractRolloutLagIntents(plex, rolloutLagRendered(t, "plex"))
+	require.NoError(t, err)
+	got = nil
+	for _, intent := range intents {
+		got = append(got, fmt.Sprintf("%s %s %s", intent.Source, intent.Field, intent.Image.Repository))
+	}
+	assert.Equal(t, []string{
+		"HelmRelease/plex values.controllers.plex.containers.app.image ghcr.io/home-operations/plex",
+		"HelmRelease/plex values.controllers.plex.containers.exporter.image ghcr.io/example/plex-exporter",
+		"HelmRelease/plex values.tools.image ghcr.io/example/plex-tools",
+	}, got, "postBuild substitute names the release; registry joins repository")
+	assert.Equal(t, imageRef{Repository: "ghcr.io/home-operations/plex", Tag: "1.41.6", Digest: rolloutLagPlexDigest}, intents[0].Image)
+}
+
+func TestParseImageRefAndMatching(t *testing.T) {
+	assert.Equal(t, imageRef{Repository: "nginx", Tag: "1.27"}, parseImageRef("docker.io/library/nginx:1.27"))
+	assert.Equal(t, imageRef{Repository: "registry:5000/team/app", Tag: "latest"}, parseImageRef("registry:5000/team/app"))
+	assert.Equal(t, imageRef{Repository: "ghcr.io/a/b", Digest: "sha256:abc"}, parseImageRef("ghcr.io/a/b@sha256:abc"))
+	assert.True(t, parseImageRef("a/b@sha256:abc").sameVersion(parseImageRef("a/b:1.0")), "digest-only and tag-only cannot be compared")
+	assert.False(t, parseImageRef("a/b:1.0@sha256:abc").sameVersion(parseImageRef("a/b:1.0@sha256:def")), "a re-pinned digest is a bump")
+
+	intent := func(source, tag string) rolloutLagIntent {
+		return rolloutLagIntent{Namespace: "media", Source: source, Image: imageRef{Repository: "ghcr.io/x/sidecar", Tag: tag}}
+	}
+	running := []rolloutLagRunning{{Namespace: "media", Workload: "Deployment/one", Container: "sidecar", Image: imageRef{Repository: "ghcr.io/x/sidecar", Tag: "1.0"}}}
+
+	matched, lagging := matchRolloutLag([]rolloutLagIntent{intent("HelmRelease/a", "2.0"), intent("HelmRelease/b", "3.0")}, running)
+	assert.Zero(t, matched, "two declared versions and no owner hint: ambiguous, not guessed")
+	assert.Empty(t, lagging)
+
+	running[0].Release = "b"
+	matched, lagging = matchRolloutLag([]rolloutLagIntent{intent("HelmRelease/a", "2.0"), intent("HelmRelease/b", "3.0")}, running)
+	assert.Equal(t, 1, matched)
+	require.Len(t, lagging, 1)
+	assert.Equal(t, "3.0", lagging[0].Git, "the pod's Helm release label picks the intent")
+}
+
+func TestRolloutLagReportsGitAheadOfCluster(t *testing.T) {
+	root := fakeRolloutLagRepo(t)
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	testutil.Swap(t, &nowFn, func() time.Time { return now })
+	var needles []string
+	testutil.Swap(t, &rolloutLagBumpTimeFn, func(_ context.Context, gotRoot, path, needle string) (time.Time, error) {
+		assert.Equal(t, root, gotRoot)
+		needles = append(needles, path+" "+needle)
+		if needle == "1.1.0" {
+			return now.Add(-50 * time.Hour), nil
+		}
+		return time.Time{}, nil
+	})
+
+	pod := func(namespace, name string, labels map[string]string, owner string, images ...string) map[string]any {
+		kind, ownerName, _ := strings.Cut(owner, "/")
+		var containers []map[string]any
+		for _, image := range images {
+			containerName, ref, _ := strings.Cut(image, "=")
+			containers = append(containers, map[string]any{"name": containerName, "image": ref})
+		}
+		return map[string]any{
+			"metadata": map[string]any{"name": name, "namespace": namespace, "labels": labels,
+				"ownerReferences": []map[string]any{{"kind": kind, "name": ownerName}}},
+			"spec":   map[string]any{"containers": containers},
+			"status": map[string]any{"phase": "Running"},
+		}
+	}
+	objects := map[string]any{
+		"get pods -A -o json": map[string]any{"items": []any{
+			pod("media", "demo-5d8f-x1", map[string]string{"pod-template-hash": "5d8f"}, "ReplicaSet/demo-5d8f",
+				"app=ghcr.io/example/demo:1.0.0", "proxy=docker.io/library/nginx:1.27"),
+			pod("media", "plex-0", map[string]string{"app.kubernetes.io/instance": "plex"}, "StatefulSet/plex",
+				"app=ghcr.io/home-operations/plex:1.41.5@sha256:0000000000000000000000000000000000000000000000000000000000000000",
+				"exporter=ghcr.io/example/plex-exporter:0.3.0", "cache=ghcr.io/example/untracked:1.0"),
+		}},
+		"get kustomizations.kustomize.toolkit.fluxcd.io -A -o json": map[string]any{"items": []any{
+			map[string]any{"metadata": map[string]any{"name": "demo", "namespace": "media"},
+				"status": map[string]any{"conditions": []any{map[string]any{"type": "Ready", "status": "True"}}}},
+			map[string]any{"metadata": map[string]any{"name": "plex", "namespace": "media"},
+				"status": map[string]any{"conditions": []any{map[string]any{"type": "Ready", "status": "True"}}}},
+		}},
+		"get helmreleases.helm.toolkit.fluxcd.io -A -o json": map[string]any{"items": []any{
+			map[string]any{"metadata": map[string]any{"name": "plex", "namespace": "media"}, "spec": map[string]any{"suspend": true}},
+		}},
+	}
+	testutil.Swap(t, &kubectlOutputCtxFn, func(_ context.Context, args ...string) ([]byte, error) {
+		object, ok := objects[strings.Join(args, " ")]
+		if !ok {
+			return nil, fmt.Errorf("unexpected kubectl %v", args)
+		}
+		return json.Marshal(object)
+	})
+
+	out, err := testutil.ExecuteCommand(newRolloutLagCommand())
+	require.NoError(t, err)
+	assert.Contains(t, out, "media/Deployment/demo")
+	assert.Contains(t, out, "2d")
+	assert.Contains(t, out, "SUSPENDED: HelmRelease media/plex")
+	assert.Contains(t, out, "Summary: 2 of 4 matched containers are behind git")
+	assert.Contains(t, out, "Suspended, holding back a rollout: HelmRelease media/plex")
+	assert.NotContains(t, out, "untracked")
+	assert.ElementsMatch(t, []string{
+		"kubernetes/apps/media/demo/app 1.1.0",
+		"kubernetes/apps/media/plex/app " + rolloutLagPlexDigest,
+	}, needles, "the bump is found by its digest when one is pinned")
+
+	out, err = testutil.ExecuteCommand(newRolloutLagCommand(), "--output", "json")
+	require.NoError(t, err)
+	var report rolloutLagReport
+	require.NoError(t, json.Unmarshal([]byte(out), &report))
+	require.Len(t, report.Lagging, 2)
+	demo, plex := report.Lagging[0], report.Lagging[1]
+	assert.Equal(t, []string{"1.0.0", "1.1.0", "2026-10-12T10:00:00Z", "2d"}, []string{demo.Running, demo.Git, demo.BumpedAt, demo.Behind})
+	assert.Equal(t, "media/demo", demo.Kustomization)
+	assert.Empty(t, demo.Suspended)
+	assert.Equal(t, "1.41.6@sha256:4f1c6d2e8a3b", plex.Git)
+	assert.Equal(t, "HelmRelease/plex values.controllers.plex.containers.app.image", plex.Source)
+	assert.Equal(t, []string{"HelmRelease media/plex"}, plex.Suspended)
+	assert.Empty(t, plex.Behind, "no commit in the path introduced the digest")
+}
@@ -0,0 +1,305 @@
+package kubernetes
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/sopsfile"
+	"homeops-cli/internal/ui"
+)
+
+var (
+	sopsRepoRootFn = common.RepoRoot
+	sopsTempDirFn  = secureTempDir
+	sopsEditorFn   = runEditor
+)
+
+type sopsKeyOptions struct {
+	AgeKeyFile      string
+	FromCluster     bool
+	SecretNamespace string
+	SecretName      string
+}
+
+func newSopsCommand() *cobra.Command {
+	var keys sopsKeyOptions
+	cmd := &cobra.Command{
+		Use:   "sops",
+		Short: "Edit, re-key, and verify the repo's SOPS-encrypted files",
+		Long: `Work with the repo's *.sops.yaml / *.sops.json files without a sops binary.
+
+The age identity comes from --age-key-file, then SOPS_AGE_KEY_FILE, then
+SOPS_AGE_KEY, then sops' default keys.txt under the user config directory.
+--from-cluster reads it from the age.agekey field of the cluster's sops-age
+Secret instead.`,
+	}
+	cmd.PersistentFlags().StringVar(&keys.AgeKeyFile, "age-key-file", "", "age identity file (default: $SOPS_AGE_KEY_FILE)")
+	cmd.PersistentFlags().BoolVar(&keys.FromCluster, "from-cluster", false, "read the age identity from the cluster's sops-age Secret")
+	cmd.PersistentFlags().StringVar(&keys.SecretNamespace, "secret-namespace", "flux-system", "namespace of the sops-age Secret")
+	cmd.PersistentFlags().StringVar(&keys.SecretName, "secret-name", "sops-age", "name of the sops-age Secret")
+
+	cmd.AddCommand(newSopsEditCommand(&keys), newSopsRotateKeyCommand(&keys), newSopsVerifyCommand(&keys))
+	return cmd
+}
+
+// resolveSopsIdentities loads the age identities sops itself would use, or
+// the cluster's when --from-cluster is set.
+func resolveSopsIdentities(opts sopsKeyOptions) (sopsfile.Identities, error) {
+	if opts.FromCluster {
+		output, err := kubectlOutputFn("get", "secret", opts.SecretName, "-n", opts.SecretNamespace, "-o", `jsonpath={.data.age\.agekey}`)
+		if err != nil {
+			return sopsfile.Identities{}, fmt.Errorf("failed to read secret %s/%s: %w", opts.SecretNamespace, opts.SecretName, err)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(output)))
+		if err != nil {
+			return sopsfile.Identities{}, fmt.Errorf("secret %s/%s has no valid age.agekey: %w", opts.SecretNamespace, opts.SecretName, err)
+		}
+		return sopsfile.ParseIdentities(string(decoded))
+	}
+	if opts.AgeKeyFile != "" {
+		return sopsfile.ReadIdentities(opts.AgeKeyFile)
+	}
+	if path := os.Getenv("SOPS_AGE_KEY_FILE"); path != "" {
+		return sopsfile.ReadIdentities(path)
+	}
+	if inline := os.Getenv("SOPS_AGE_KEY"); inline != "" {
+		return sopsfile.ParseIdentities(inline)
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return sopsfile.Identities{}, fmt.Errorf("no age identity: set SOPS_AGE_KEY_FILE, pass --age-key-file, or use --from-cluster")
+	}
+	ids, err := sopsfile.ReadIdentities(filepath.Join(configDir, "sops", "age", "keys.txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		return sopsfile.Identities{}, fmt.Errorf("no age identity: set SOPS_AGE_KEY_FILE, pass --age-key-file, or use --from-cluster")
+	}
+	return ids, err
+}
+
+func newSopsEditCommand(keys *sopsKeyOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "edit <file>",
+		Short: "Decrypt a file into $EDITOR and re-encrypt it on save",
+		Long: `Decrypt <file> into a private temp file (0600, on /dev/shm when available,
+removed on exit), open $EDITOR on it, and re-encrypt the result with the
+file's existing sops metadata: the same recipients, key groups,
+encrypted_regex, and data key. The keys that were added, removed, or changed
+are listed afterwards; values are never printed.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		Example: `  homeops-cli k8s sops edit kubernetes/apps/media/app/secret.sops.yaml
+  homeops-cli k8s sops edit --from-cluster kubernetes/components/common/cluster-secrets.sops.yaml`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := resolveSopsIdentities(*keys)
+			if err != nil {
+				return err
+			}
+			file, err := sopsfile.Decrypt(args[0], ids)
+			if err != nil {
+				return err
+			}
+			edited, err := editPlaintext(file)
+			if err != nil {
+				return err
+			}
+			changes, err := file.SetPlaintext(edited)
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			if changes.Empty() {
+				_, _ = fmt.Fprintf(out, "No changes to %s\n", args[0])
+				return nil
+			}
+			if err := file.Write(); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(out, "Re-encrypted %s\n", args[0])
+			for _, group := range []struct {
+				label string
+				paths []string
+			}{{"added", changes.Added}, {"removed", changes.Removed}, {"changed", changes.Modified}} {
+				for _, path := range group.paths {
+					_, _ = fmt.Fprintf(out, "  %-8s %s\n", group.label, path)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// editPlaintext round-trips the decrypted file through the user's editor.
+func editPlaintext(file *sopsfile.File) ([]byte, error) {
+	plain, err := file.Plaintext()
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(sopsTempDirFn(), "homeops-sops-*"+filepath.Ext(file.Path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if err := tmp.Chmod(0o600); err != nil {
+		_ = tmp.Close()
+		return nil, err
+	}
+	if _, err := tmp.Write(plain); err != nil {
+		_ = tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := sopsEditorFn(tmp.Name()); err != nil {
+		return nil, fmt.Errorf("editor failed, %s left unchanged: %w", file.Path, err)
+	}
+	return os.ReadFile(tmp.Name())
+}
+
+// secureTempDir prefers tmpfs so the plaintext never reaches a disk.
+func secureTempDir() string {
+	if info, err := os.Stat("/dev/shm"); err == nil && info.IsDir() {
+		if probe, err := os.CreateTemp("/dev/shm", ".homeops-probe-*"); err == nil {
+			_ = probe.Close()
+			_ = os.Remove(probe.Name())
+			return "/dev/shm"
+		}
+	}
+	return os.TempDir()
+}
+
+func runEditor(path string) error {
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+	}
+	cmd := exec.Command(editor[0], append(editor[1:], path)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+func newSopsRotateKeyCommand(keys *sopsKeyOptions) *cobra.Command {
+	var recipient, replace, match string
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "rotate-key",
+		Short: "Re-encrypt every matching file in the repo to a new age recipient",
+		Long: `Add --new-recipient to every file in the repo matching --match and, with
+--replace-recipient, remove the old recipient in the same pass. The new
+recipient takes the old one's place in each key group; without
+--replace-recipient it joins the first group that has an age key. Each
+changed file gets a fresh data key, so a removed recipient cannot read it.
+Files that cannot be decrypted with the available identity are reported and
+left untouched.`,
+		SilenceUsage: true,
+		Example: `  homeops-cli k8s sops rotate-key --new-recipient age1... --dry-run
+  homeops-cli k8s sops rotate-key --new-recipient age1new... --replace-recipient age1old...`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			pattern, err := sopsMatchPattern(match)
+			if err != nil {
+				return err
+			}
+			ids, err := resolveSopsIdentities(*keys)
+			if err != nil {
+				return err
+			}
+			root, err := sopsRepoRootFn()
+			if err != nil {
+				return err
+			}
+			results, err := sopsfile.RotateAll(root, pattern, ids, recipient, replace, dryRun)
+			if err != nil {
+				return err
+			}
+			return reportSopsResults(cmd, results, func(result sopsfile.Result) string {
+				switch {
+				case !result.Changed:
+					return "unchanged"
+				case dryRun:
+					return "would re-encrypt"
+				}
+				return "re-encrypted"
+			})
+		},
+	}
+	cmd.Flags().StringVar(&recipient, "new-recipient", "", "age public key to add")
+	cmd.Flags().StringVar(&replace, "replace-recipient", "", "age public key to remove")
+	cmd.Flags().StringVar(&match, "match", "", "regex over repo-relative paths (default: files ending .sops.yaml/.sops.json)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "report what would change without writing")
+	_ = cmd.MarkFlagRequired("new-recipient")
+	return cmd
+}
+
+func newSopsVerifyCommand(keys *sopsKeyOptions) *cobra.Command {
+	var match string
+	cmd := &cobra.Command{
+		Use:          "verify",
+		Short:        "Check every encrypted file in the repo decrypts with the available key",
+		SilenceUsage: true,
+		Example: `  homeops-cli k8s sops verify
+  homeops-cli k8s sops verify --from-cluster`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			pattern, err := sopsMatchPattern(match)
+			if err != nil {
+				return err
+			}
+			ids, err := resolveSopsIdentities(*keys)
+			if err != nil {
+				return err
+			}
+			root, err := sopsRepoRootFn()
+			if err != nil {
+				return err
+			}
+			results, err := sopsfile.Verify(root, pattern, ids)
+			if err != nil {
+				return err
+			}
+			return reportSopsResults(cmd, results, func(sopsfile.Result) string { return "ok" })
+		},
+	}
+	cmd.Flags().StringVar(&match, "match", "", "regex over repo-relative paths (default: files ending .sops.yaml/.sops.json)")
+	return cmd
+}
+
+func sopsMatchPattern(match string) (*regexp.Regexp, error) {
+	if match == "" {
+		return sopsfile.DefaultPattern, nil
+	}
+	pattern, err := regexp.Compile(match)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --match: %w", err)
+	}
+	return pattern, nil
+}
+
+// reportSopsResults prints one row per file and fails if any file errored.
+func reportSopsResults(cmd *cobra.Command, results []sopsfile.Result, status func(sopsfile.Result) string) error {
+	if len(results) == 0 {
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), "No SOPS-encrypted files matched")
+		return nil
+	}
+	rows := make([][]string, 0, len(results))
+	failed := 0
+	for _, result := range results {
+		state := status(result)
+		if result.Err != nil {
+			state = "FAILED: " + result.Err.Error()
+			failed++
+		}
+		rows = append(rows, []string{result.Path, state})
+	}
+	_, _ = fmt.Fprintln(cmd.OutOrStdout(), ui.Table([]string{"FILE", "RESULT"}, rows))
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed", failed, len(results))
+	}
+	return nil
+}
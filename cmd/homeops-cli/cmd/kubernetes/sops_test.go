@@ -0,0 +1,105 @@
+package kubernetes
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/testutil"
+)
+
+var sopsFixtures = filepath.Join("..", "..", "internal", "sopsfile", "testdata")
+
+// fakeSopsRepo copies the sopsfile fixture repo into a temp dir and points
+// the repo-root and temp-dir seams at it.
+func fakeSopsRepo(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	require.NoError(t, os.CopyFS(root, os.DirFS(filepath.Join(sopsFixtures, "repo"))))
+	testutil.Swap(t, &sopsRepoRootFn, func() (string, error) { return root, nil })
+	testutil.Swap(t, &sopsTempDirFn, t.TempDir)
+	t.Setenv("SOPS_AGE_KEY_FILE", filepath.Join(sopsFixtures, "keys.txt"))
+	return root
+}
+
+func TestSopsEditReencryptsAndListsChangedKeys(t *testing.T) {
+	root := fakeSopsRepo(t)
+	path := filepath.Join(root, "kubernetes", "apps", "media", "app", "secret.sops.yaml")
+	var tempFile string
+	testutil.Swap(t, &sopsEditorFn, func(name string) error {
+		tempFile = name
+		info, err := os.Stat(name)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+		raw, err := os.ReadFile(name)
+		require.NoError(t, err)
+		return os.WriteFile(name, []byte(strings.Replace(string(raw), "hunter2", "swordfish", 1)), 0o600)
+	})
+
+	out, err := testutil.ExecuteCommand(newSopsCommand(), "edit", path)
+	require.NoError(t, err)
+	assert.Contains(t, out, "changed  stringData.password")
+	assert.NotContains(t, out, "swordfish")
+	assert.NotContains(t, out, "hunter2")
+	assert.NoFileExists(t, tempFile, "the plaintext copy is removed")
+	assert.Equal(t, ".yaml", filepath.Ext(tempFile))
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "swordfish")
+
+	testutil.Swap(t, &sopsEditorFn, func(string) error { return nil })
+	before, err := os.ReadFile(path)
+	require.NoError(t, err)
+	out, err = testutil.ExecuteCommand(newSopsCommand(), "edit", path)
+	require.NoError(t, err)
+	assert.Contains(t, out, "No changes")
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, before, after, "an unchanged edit does not rewrite the file")
+}
+
+func TestSopsVerifyFromCluster(t *testing.T) {
+	fakeSopsRepo(t)
+	t.Setenv("SOPS_AGE_KEY_FILE", "")
+	keys, err := os.ReadFile(filepath.Join(sopsFixtures, "keys.txt"))
+	require.NoError(t, err)
+	testutil.Swap(t, &kubectlOutputFn, func(args ...string) ([]byte, error) {
+		assert.Equal(t, []string{"get", "secret", "sops-age", "-n", "flux-system", "-o", `jsonpath={.data.age\.agekey}`}, args)
+		return []byte(base64.StdEncoding.EncodeToString(keys)), nil
+	})
+
+	out, err := testutil.ExecuteCommand(newSopsCommand(), "verify", "--from-cluster")
+	require.ErrorContains(t, err, "1 of 3 files failed")
+	assert.Contains(t, out, "kubernetes/apps/legacy/foreign.sops.yaml")
+	assert.Contains(t, out, "FAILED")
+	assert.NotContains(t, out, "kustomization.yaml")
+
+	out, err = testutil.ExecuteCommand(newSopsCommand(), "verify", "--from-cluster", "--match", `apps/(media|network)/.*\.sops\.`)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(out, " ok"))
+}
+
+func TestSopsRotateKeyDryRun(t *testing.T) {
+	root := fakeSopsRepo(t)
+	path := filepath.Join(root, "kubernetes", "apps", "network", "app", "config.sops.json")
+	before, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	out, err := testutil.ExecuteCommand(newSopsCommand(), "rotate-key", "--dry-run", "--match", `apps/network/`,
+		"--new-recipient", "age1u0zgvuw5sx58uhs2fv3kn69e88wsfryt7ej8prwn3lg7sxmaayrsmwcpd0",
+		"--replace-recipient", "age10mc7dsj7p2eaypnn2nkuwk4ja7fp0upxr9zx2j55pn8pjnsvpsaqcmc2le")
+	require.NoError(t, err)
+	assert.Contains(t, out, "would re-encrypt")
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+
+	_, err = testutil.ExecuteCommand(newSopsCommand(), "rotate-key", "--new-recipient", "bogus")
+	require.ErrorContains(t, err, "invalid age recipient")
+}
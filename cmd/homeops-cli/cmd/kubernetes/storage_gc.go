@@ -0,0 +1,481 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"homeops-cli/cmd/completion"
+	"homeops-cli/internal/kubeutil"
+	"homeops-cli/internal/ui"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	storageGCDefaultSnapshotAge = 7 * 24 * time.Hour
+
+	gcOrphanedCachePVC   = "orphaned-cache-pvc"
+	gcRestoreTempPVC     = "restore-temp-pvc"
+	gcStaleSnapshot      = "stale-volumesnapshot"
+	gcReleasedPV         = "released-pv"
+	gcKindPVC            = "persistentvolumeclaim"
+	gcKindVolumeSnapshot = "volumesnapshot.snapshot.storage.k8s.io"
+	gcKindPV             = "persistentvolume"
+)
+
+// storageGCCategories is both the report grouping and the deletion order:
+// claims go before the snapshots they may have been cloned from, and PVs go
+// last so a PVC deletion that releases one is not raced.
+var storageGCCategories = []string{gcOrphanedCachePVC, gcRestoreTempPVC, gcStaleSnapshot, gcReleasedPV}
+
+type storageGCReplicationList struct {
+	Items []struct {
+		Metadata metadataJSON `json:"metadata"`
+		Status   struct {
+			LatestImage *struct {
+				Kind string `json:"kind"`
+				Name string `json:"name"`
+			} `json:"latestImage"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+type storageGCSnapshot struct {
+	Metadata struct {
+		Name              string                    `json:"name"`
+		Namespace         string                    `json:"namespace"`
+		CreationTimestamp string                    `json:"creationTimestamp"`
+		OwnerReferences   []kubeutil.OwnerReference `json:"ownerReferences"`
+	} `json:"metadata"`
+	Spec struct {
+		Source struct {
+			PersistentVolumeClaimName string `json:"persistentVolumeClaimName"`
+		} `json:"source"`
+	} `json:"spec"`
+	Status struct {
+		RestoreSize string `json:"restoreSize"`
+	} `json:"status"`
+}
+
+type storageGCSnapshotList struct {
+	Items []storageGCSnapshot `json:"items"`
+}
+
+type storageGCNamespaceList struct {
+	Items []struct {
+		Metadata metadataJSON `json:"metadata"`
+	} `json:"items"`
+}
+
+// storageGCInventory is everything storage-gc resolves ownership against.
+// It is gathered in full before any decision is made.
+type storageGCInventory struct {
+	PVCs         []storagePVC
+	Pods         storagePodList
+	Sources      storageGCReplicationList
+	Destinations storageGCReplicationList
+	Snapshots    []storageGCSnapshot
+	PVs          []storagePV
+	Namespaces   map[string]bool
+}
+
+type storageGCCandidate struct {
+	Category  string `json:"category"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Size      string `json:"size,omitempty"`
+	Bytes     int64  `json:"bytes"`
+	Reason    string `json:"reason"`
+	Deleted   bool   `json:"deleted,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type storageGCReport struct {
+	DryRun           bool                 `json:"dry_run"`
+	SnapshotAge      string               `json:"snapshot_age"`
+	Candidates       []storageGCCandidate `json:"candidates"`
+	ReclaimableBytes int64                `json:"reclaimable_bytes"`
+	ReclaimedBytes   int64                `json:"reclaimed_bytes"`
+}
+
+func newStorageGCCommand() *cobra.Command {
+	var namespace, output string
+	var dryRun bool
+	var age time.Duration
+	cmd := &cobra.Command{
+		Use:   "storage-gc",
+		Short: "Delete orphaned VolSync cache/restore PVCs, stale VolumeSnapshots, and Released PVs",
+		Long: `Finds storage left behind by VolSync and the CSI snapshotter:
+
+  orphaned-cache-pvc     volsync-*-cache PVCs whose ReplicationSource/Destination is gone
+  restore-temp-pvc       volsync-*-dest/-dst PVCs with no active ReplicationDestination
+  stale-volumesnapshot   VolumeSnapshots older than --age that no PVC, restore, or live
+                         ReplicationSource/Destination references
+  released-pv            Released PVs whose claimRef namespace no longer exists
+
+PVCs mounted by a pod are never collected. After confirmation (or --yes) the
+candidates are deleted and the reclaimed capacity is reported.`,
+		SilenceUsage: true,
+		Example: `  homeops-cli k8s storage-gc --dry-run
+  homeops-cli k8s storage-gc --namespace media --age 72h
+  homeops-cli k8s storage-gc --yes --output json`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := ui.ValidateOutputFormat(output); err != nil {
+				return err
+			}
+			if age <= 0 {
+				return fmt.Errorf("--age must be positive")
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), kubernetesDefaultCommandTimeout)
+			defer cancel()
+			inventory, err := gatherStorageGCInventory(ctx, namespace)
+			if err != nil {
+				return err
+			}
+			report := storageGCReport{
+				DryRun:      dryRun,
+				SnapshotAge: age.String(),
+				Candidates:  findStorageGCCandidates(inventory, namespace, age, storageNowFn()),
+			}
+			for _, candidate := range report.Candidates {
+				report.ReclaimableBytes += candidate.Bytes
+			}
+			return runStorageGC(ctx, cmd, report, output)
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "namespace to collect (default: all namespaces)")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table or json")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "list candidates without deleting anything")
+	cmd.Flags().DurationVar(&age, "age", storageGCDefaultSnapshotAge, "minimum age of an unreferenced VolumeSnapshot before it is collected")
+	_ = cmd.RegisterFlagCompletionFunc("namespace", completion.ValidNamespaces)
+	return cmd
+}
+
+// gatherStorageGCInventory fails on the first list error: deciding that an
+// owner "no longer exists" from a partial listing would delete live data.
+func gatherStorageGCInventory(ctx context.Context, namespace string) (storageGCInventory, error) {
+	var inventory storageGCInventory
+	var pvcs storagePVCList
+	if err := kubeutil.GetJSON(ctx, kubectlOutputCtxFn, namespace, "persistentvolumeclaims", &pvcs); err != nil {
+		return inventory, err
+	}
+	inventory.PVCs = pvcs.Items
+	if err := kubeutil.GetJSON(ctx, kubectlOutputCtxFn, namespace, "pods", &inventory.Pods); err != nil {
+		return inventory, err
+	}
+	if err := kubeutil.GetJSON(ctx, kubectlOutputCtxFn, namespace, "replicationsources.volsync.backube", &inventory.Sources); err != nil {
+		return inventory, err
+	}
+	if err := kubeutil.GetJSON(ctx, kubectlOutputCtxFn, namespace, "replicationdestinations.volsync.backube", &inventory.Destinations); err != nil {
+		return inventory, err
+	}
+	var snapshots storageGCSnapshotList
+	if err := kubeutil.GetJSON(ctx, kubectlOutputCtxFn, namespace, "volumesnapshots.snapshot.storage.k8s.io", &snapshots); err != nil {
+		return inventory, err
+	}
+	inventory.Snapshots = snapshots.Items
+	var pvs storagePVList
+	if err := kubeutil.GetClusterJSON(ctx, kubectlOutputCtxFn, "persistentvolumes", &pvs); err != nil {
+		return inventory, err
+	}
+	inventory.PVs = pvs.Items
+	var namespaces storageGCNamespaceList
+	if err := kubeutil.GetClusterJSON(ctx, kubectlOutputCtxFn, "namespaces", &namespaces); err != nil {
+		return inventory, err
+	}
+	inventory.Namespaces = map[string]bool{}
+	for _, item := range namespaces.Items {
+		inventory.Namespaces[item.Metadata.Name] = true
+	}
+	return inventory, nil
+}
+
+func findStorageGCCandidates(inventory storageGCInventory, namespace string, minSnapshotAge time.Duration, now time.Time) []storageGCCandidate {
+	mounted := map[string]bool{}
+	for _, pod := range inventory.Pods.Items {
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil {
+				mounted[namespacedName(pod.Metadata.Namespace, volume.PersistentVolumeClaim.ClaimName)] = true
+			}
+		}
+	}
+	sources := storageGCNames(inventory.Sources)
+	destinations := storageGCNames(inventory.Destinations)
+
+	var result []storageGCCandidate
+	for _, pvc := range inventory.PVCs {
+		key := namespacedName(pvc.Metadata.Namespace, pvc.Metadata.Name)
+		if mounted[key] {
+			continue
+		}
+		category, reason := classifyStorageGCPVC(pvc, sources, destinations)
+		if category == "" {
+			continue
+		}
+		size := pvc.Spec.Resources.Requests["storage"]
+		result = append(result, storageGCCandidate{
+			Category: category, Kind: gcKindPVC, Namespace: pvc.Metadata.Namespace, Name: pvc.Metadata.Name,
+			Size: size, Bytes: storageGCBytes(size), Reason: reason,
+		})
+	}
+
+	referenced := map[string]bool{}
+	for _, pvc := range inventory.PVCs {
+		for _, ref := range []*struct {
+			APIGroup string `json:"apiGroup"`
+			Kind     string `json:"kind"`
+			Name     string `json:"name"`
+		}{pvc.Spec.DataSource, pvc.Spec.DataSourceRef} {
+			if ref != nil && ref.Kind == "VolumeSnapshot" {
+				referenced[namespacedName(pvc.Metadata.Namespace, ref.Name)] = true
+			}
+		}
+	}
+	for _, list := range []storageGCReplicationList{inventory.Sources, inventory.Destinations} {
+		for _, item := range list.Items {
+			if image := item.Status.LatestImage; image != nil && image.Kind == "VolumeSnapshot" {
+				referenced[namespacedName(item.Metadata.Namespace, image.Name)] = true
+			}
+		}
+	}
+	for _, snapshot := range inventory.Snapshots {
+		key := namespacedName(snapshot.Metadata.Namespace, snapshot.Metadata.Name)
+		age := parsedAge(snapshot.Metadata.CreationTimestamp, now)
+		if referenced[key] || age < minSnapshotAge {
+			continue
+		}
+		reason, ok := storageGCSnapshotOrphaned(snapshot, sources, destinations)
+		if !ok {
+			continue
+		}
+		result = append(result, storageGCCandidate{
+			Category: gcStaleSnapshot, Kind: gcKindVolumeSnapshot, Namespace: snapshot.Metadata.Namespace, Name: snapshot.Metadata.Name,
+			Size: snapshot.Status.RestoreSize, Bytes: storageGCBytes(snapshot.Status.RestoreSize),
+			Reason: fmt.Sprintf("%s old, %s", displayAge(age), reason),
+		})
+	}
+
+	for _, pv := range inventory.PVs {
+		claim := pv.Spec.ClaimRef
+		if pv.Status.Phase != "Released" || claim == nil || claim.Namespace == "" || inventory.Namespaces[claim.Namespace] {
+			continue
+		}
+		if namespace != "" && claim.Namespace != namespace {
+			continue
+		}
+		reason := fmt.Sprintf("claim %s: namespace no longer exists", namespacedName(claim.Namespace, claim.Name))
+		if pv.Spec.PersistentVolumeReclaimPolicy == "Retain" {
+			reason += "; Retain policy, backend volume is kept"
+		}
+		size := pv.Spec.Capacity["storage"]
+		result = append(result, storageGCCandidate{
+			Category: gcReleasedPV, Kind: gcKindPV, Name: pv.Metadata.Name,
+			Size: size, Bytes: storageGCBytes(size), Reason: reason,
+		})
+	}
+
+	order := map[string]int{}
+	for i, category := range storageGCCategories {
+		order[category] = i
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Category != result[j].Category {
+			return order[result[i].Category] < order[result[j].Category]
+		}
+		return namespacedName(result[i].Namespace, result[i].Name) < namespacedName(result[j].Namespace, result[j].Name)
+	})
+	return result
+}
+
+// classifyStorageGCPVC returns the category for a VolSync cache or
+// restore-temp PVC whose owner is gone, or "" for anything else. Ownership
+// comes from the VolSync ownerReference when present and from VolSync's
+// volsync-<owner>-<suffix> naming otherwise. Application PVCs, including those
+// populated from a ReplicationDestination, never match a naming convention.
+func classifyStorageGCPVC(pvc storagePVC, sources, destinations map[string]bool) (string, string) {
+	name := pvc.Metadata.Name
+	var category, suffix string
+	switch {
+	case strings.HasPrefix(name, "volsync-") && strings.HasSuffix(name, "-cache"):
+		category, suffix = gcOrphanedCachePVC, "-cache"
+	case strings.HasPrefix(name, "volsync-") && (strings.HasSuffix(name, "-dest") || strings.HasSuffix(name, "-dst")):
+		category, suffix = gcRestoreTempPVC, "-"+name[strings.LastIndex(name, "-")+1:]
+	default:
+		return "", ""
+	}
+	if isReplicationDestinationDataSource(pvc) {
+		return "", ""
+	}
+
+	if kind, owner, ok := storageGCVolSyncOwner(pvc.Metadata.OwnerReferences); ok {
+		if (kind == "ReplicationSource" && sources[namespacedName(pvc.Metadata.Namespace, owner)]) ||
+			(kind == "ReplicationDestination" && destinations[namespacedName(pvc.Metadata.Namespace, owner)]) {
+			return "", ""
+		}
+		return category, fmt.Sprintf("owner %s %s no longer exists", kind, owner)
+	}
+
+	owner := strings.TrimSuffix(strings.TrimPrefix(name, "volsync-"), suffix)
+	for _, prefix := range []string{"src-", "dst-"} {
+		if trimmed := strings.TrimPrefix(owner, prefix); trimmed != owner && trimmed != "" {
+			if sources[namespacedName(pvc.Metadata.Namespace, trimmed)] || destinations[namespacedName(pvc.Metadata.Namespace, trimmed)] {
+				return "", ""
+			}
+		}
+	}
+	key := namespacedName(pvc.Metadata.Namespace, owner)
+	if category == gcRestoreTempPVC {
+		if destinations[key] {
+			return "", ""
+		}
+		return category, fmt.Sprintf("no ReplicationDestination %s", owner)
+	}
+	if sources[key] || destinations[key] {
+		return "", ""
+	}
+	return category, fmt.Sprintf("no ReplicationSource/Destination %s", owner)
+}
+
+// storageGCSnapshotOrphaned reports whether an unreferenced snapshot has no
+// live owner. Snapshots owned by anything other than VolSync are left alone.
+func storageGCSnapshotOrphaned(snapshot storageGCSnapshot, sources, destinations map[string]bool) (string, bool) {
+	if kind, owner, ok := storageGCVolSyncOwner(snapshot.Metadata.OwnerReferences); ok {
+		key := namespacedName(snapshot.Metadata.Namespace, owner)
+		if (kind == "ReplicationSource" && sources[key]) || (kind == "ReplicationDestination" && destinations[key]) {
+			return "", false
+		}
+		return fmt.Sprintf("owner %s %s no longer exists", kind, owner), true
+	}
+	if len(snapshot.Metadata.OwnerReferences) > 0 {
+		return "", false
+	}
+	return "not referenced by any PVC or restore", true
+}
+
+func storageGCVolSyncOwner(refs []kubeutil.OwnerReference) (string, string, bool) {
+	for _, ref := range refs {
+		if (ref.Kind == "ReplicationSource" || ref.Kind == "ReplicationDestination") && strings.Contains(ref.APIVersion, "volsync.backube") {
+			return ref.Kind, ref.Name, true
+		}
+	}
+	return "", "", false
+}
+
+func storageGCNames(list storageGCReplicationList) map[string]bool {
+	names := map[string]bool{}
+	for _, item := range list.Items {
+		names[namespacedName(item.Metadata.Namespace, item.Metadata.Name)] = true
+	}
+	return names
+}
+
+func storageGCBytes(size string) int64 {
+	if size == "" {
+		return 0
+	}
+	quantity, err := resource.ParseQuantity(size)
+	if err != nil {
+		return 0
+	}
+	return quantity.Value()
+}
+
+func runStorageGC(ctx context.Context, cmd *cobra.Command, report storageGCReport, output string) error {
+	out := cmd.OutOrStdout()
+	if output != "json" {
+		_, _ = fmt.Fprintln(out, renderStorageGCCandidates(report))
+	}
+	if len(report.Candidates) == 0 || report.DryRun {
+		if output == "json" {
+			rendered, err := ui.RenderJSON(report)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintln(out, rendered)
+		}
+		return nil
+	}
+
+	confirmed, err := confirmActionFn(fmt.Sprintf("Delete %d storage object(s), reclaiming up to %s?", len(report.Candidates), humanBytes(report.ReclaimableBytes)), false)
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		_, _ = fmt.Fprintln(out, "Storage garbage collection cancelled")
+		return nil
+	}
+
+	var failed []string
+	for i := range report.Candidates {
+		candidate := &report.Candidates[i]
+		args := []string{"delete", candidate.Kind, candidate.Name}
+		if candidate.Namespace != "" {
+			args = append(args, "-n", candidate.Namespace)
+		}
+		args = append(args, "--ignore-not-found", "--wait=false")
+		if err := commandRunCtxFn(ctx, "kubectl", args...); err != nil {
+			candidate.Error = err.Error()
+			failed = append(failed, fmt.Sprintf("%s %s: %v", candidate.Kind, namespacedName(candidate.Namespace, candidate.Name), err))
+			continue
+		}
+		candidate.Deleted = true
+		report.ReclaimedBytes += candidate.Bytes
+		if output != "json" {
+			_, _ = fmt.Fprintf(out, "Deleted %s %s\n", candidate.Kind, namespacedName(candidate.Namespace, candidate.Name))
+		}
+	}
+	if output == "json" {
+		rendered, err := ui.RenderJSON(report)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(out, rendered)
+	} else {
+		_, _ = fmt.Fprintf(out, "Reclaimed %s from %d object(s)\n", humanBytes(report.ReclaimedBytes), len(report.Candidates)-len(failed))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to delete %d object(s):\n%s", len(failed), strings.Join(failed, "\n"))
+	}
+	return nil
+}
+
+func renderStorageGCCandidates(report storageGCReport) string {
+	if len(report.Candidates) == 0 {
+		return "No storage garbage found"
+	}
+	subtotals := map[string]int64{}
+	counts := map[string]int{}
+	for _, candidate := range report.Candidates {
+		subtotals[candidate.Category] += candidate.Bytes
+		counts[candidate.Category]++
+	}
+	var rows [][]string
+	for _, category := range storageGCCategories {
+		if counts[category] == 0 {
+			continue
+		}
+		for _, candidate := range report.Candidates {
+			if candidate.Category == category {
+				rows = append(rows, []string{category, namespacedName(candidate.Namespace, candidate.Name), emptyStorageGCSize(candidate.Size), candidate.Reason})
+			}
+		}
+		rows = append(rows, []string{category, fmt.Sprintf("(%d total)", counts[category]), humanBytes(subtotals[category]), ""})
+	}
+	mode := "to delete"
+	if report.DryRun {
+		mode = "dry run, nothing deleted"
+	}
+	return fmt.Sprintf("Candidates: %d (%s reclaimable; %s)\n%s", len(report.Candidates), humanBytes(report.ReclaimableBytes), mode,
+		ui.Table([]string{"CATEGORY", "NAME", "SIZE", "REASON"}, rows))
+}
+
+func emptyStorageGCSize(size string) string {
+	if size == "" {
+		return "-"
+	}
+	return size
+}
@@ -0,0 +1,163 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homeops-cli/internal/testutil"
+)
+
+// storageGCFakeCluster answers storage-gc's list calls. Every "keep-*" object
+// is a case that must not be collected; every "gone-*" object must be.
+var storageGCFakeCluster = map[string]string{
+	"persistentvolumeclaims": `{"items":[
+		{"metadata":{"namespace":"media","name":"volsync-src-gone-cache","ownerReferences":[{"apiVersion":"volsync.backube/v1alpha1","kind":"ReplicationSource","name":"gone"}]},"spec":{"resources":{"requests":{"storage":"1Gi"}}}},
+		{"metadata":{"namespace":"media","name":"volsync-gone-named-cache"},"spec":{"resources":{"requests":{"storage":"2Gi"}}}},
+		{"metadata":{"namespace":"media","name":"volsync-src-radarr-cache","ownerReferences":[{"apiVersion":"volsync.backube/v1alpha1","kind":"ReplicationSource","name":"radarr"}]},"spec":{"resources":{"requests":{"storage":"1Gi"}}}},
+		{"metadata":{"namespace":"media","name":"volsync-dst-sonarr-dst-cache"},"spec":{"resources":{"requests":{"storage":"1Gi"}}}},
+		{"metadata":{"namespace":"media","name":"volsync-mounted-cache"},"spec":{"resources":{"requests":{"storage":"1Gi"}}}},
+		{"metadata":{"namespace":"media","name":"volsync-gone-restore-dest"},"spec":{"resources":{"requests":{"storage":"10Gi"}}}},
+		{"metadata":{"namespace":"media","name":"volsync-sonarr-dst-dest"},"spec":{"resources":{"requests":{"storage":"10Gi"}}}},
+		{"metadata":{"namespace":"media","name":"radarr"},"spec":{"resources":{"requests":{"storage":"10Gi"}},"dataSourceRef":{"apiGroup":"volsync.backube","kind":"ReplicationDestination","name":"gone-restore"}}},
+		{"metadata":{"namespace":"media","name":"clone"},"spec":{"resources":{"requests":{"storage":"5Gi"}},"dataSource":{"apiGroup":"snapshot.storage.k8s.io","kind":"VolumeSnapshot","name":"keep-pvc-source"}}}
+	]}`,
+	"pods": `{"items":[{"metadata":{"namespace":"media","name":"mover"},"spec":{"volumes":[{"persistentVolumeClaim":{"claimName":"volsync-mounted-cache"}}]}}]}`,
+	"replicationsources.volsync.backube": `{"items":[
+		{"metadata":{"namespace":"media","name":"radarr"}}
+	]}`,
+	"replicationdestinations.volsync.backube": `{"items":[
+		{"metadata":{"namespace":"media","name":"sonarr-dst"},"status":{"latestImage":{"kind":"VolumeSnapshot","name":"keep-latest-image"}}}
+	]}`,
+	"volumesnapshots.snapshot.storage.k8s.io": `{"items":[
+		{"metadata":{"namespace":"media","name":"gone-unreferenced","creationTimestamp":"2026-07-01T00:00:00Z"},"status":{"restoreSize":"4Gi"}},
+		{"metadata":{"namespace":"media","name":"gone-owner","creationTimestamp":"2026-07-01T00:00:00Z","ownerReferences":[{"apiVersion":"volsync.backube/v1alpha1","kind":"ReplicationSource","name":"gone"}]},"status":{"restoreSize":"3Gi"}},
+		{"metadata":{"namespace":"media","name":"keep-young","creationTimestamp":"2026-07-14T12:00:00Z"},"status":{"restoreSize":"1Gi"}},
+		{"metadata":{"namespace":"media","name":"keep-pvc-source","creationTimestamp":"2026-07-01T00:00:00Z"},"status":{"restoreSize":"1Gi"}},
+		{"metadata":{"namespace":"media","name":"keep-latest-image","creationTimestamp":"2026-07-01T00:00:00Z"},"status":{"restoreSize":"1Gi"}},
+		{"metadata":{"namespace":"media","name":"keep-live-owner","creationTimestamp":"2026-07-01T00:00:00Z","ownerReferences":[{"apiVersion":"volsync.backube/v1alpha1","kind":"ReplicationSource","name":"radarr"}]},"status":{"restoreSize":"1Gi"}},
+		{"metadata":{"namespace":"media","name":"keep-foreign-owner","creationTimestamp":"2026-07-01T00:00:00Z","ownerReferences":[{"apiVersion":"velero.io/v1","kind":"Backup","name":"nightly"}]},"status":{"restoreSize":"1Gi"}}
+	]}`,
+	"persistentvolumes": `{"items":[
+		{"metadata":{"name":"gone-pv"},"spec":{"capacity":{"storage":"8Gi"},"persistentVolumeReclaimPolicy":"Retain","claimRef":{"namespace":"deleted","name":"data"}},"status":{"phase":"Released"}},
+		{"metadata":{"name":"keep-released-live-ns"},"spec":{"capacity":{"storage":"8Gi"},"claimRef":{"namespace":"media","name":"old"}},"status":{"phase":"Released"}},
+		{"metadata":{"name":"keep-bound"},"spec":{"capacity":{"storage":"8Gi"},"claimRef":{"namespace":"deleted","name":"data"}},"status":{"phase":"Bound"}}
+	]}`,
+	"namespaces": `{"items":[{"metadata":{"name":"media"}},{"metadata":{"name":"flux-system"}}]}`,
+}
+
+func withStorageGCFakeCluster(t *testing.T) *[][]string {
+	t.Helper()
+	testutil.Swap(t, &storageNowFn, func() time.Time {
+		return time.Date(2026, 7, 15, 0, 0, 0, 0, time.UTC)
+	})
+	testutil.Swap(t, &kubectlOutputCtxFn, func(_ context.Context, args ...string) ([]byte, error) {
+		if payload, ok := storageGCFakeCluster[args[1]]; ok {
+			return []byte(payload), nil
+		}
+		return nil, errors.New("unexpected kubectl call: " + strings.Join(args, " "))
+	})
+	var deletes [][]string
+	testutil.Swap(t, &commandRunCtxFn, func(_ context.Context, name string, args ...string) error {
+		deletes = append(deletes, append([]string{name}, args...))
+		return nil
+	})
+	return &deletes
+}
+
+func TestFindStorageGCCandidatesResolvesOwnership(t *testing.T) {
+	withStorageGCFakeCluster(t)
+	inventory, err := gatherStorageGCInventory(context.Background(), "")
+	require.NoError(t, err)
+
+	candidates := findStorageGCCandidates(inventory, "", storageGCDefaultSnapshotAge, storageNowFn())
+	collected := map[string]string{}
+	for _, candidate := range candidates {
+		collected[candidate.Name] = candidate.Category
+	}
+	assert.Equal(t, map[string]string{
+		"volsync-src-gone-cache":    gcOrphanedCachePVC,
+		"volsync-gone-named-cache":  gcOrphanedCachePVC,
+		"volsync-gone-restore-dest": gcRestoreTempPVC,
+		"gone-unreferenced":         gcStaleSnapshot,
+		"gone-owner":                gcStaleSnapshot,
+		"gone-pv":                   gcReleasedPV,
+	}, collected)
+	for _, name := range []string{
+		"volsync-src-radarr-cache",     // live ReplicationSource owner
+		"volsync-dst-sonarr-dst-cache", // name-derived live ReplicationDestination
+		"volsync-mounted-cache",        // mounted by a pod
+		"volsync-sonarr-dst-dest",      // active ReplicationDestination
+		"radarr",                       // app PVC populated from a deleted ReplicationDestination
+		"clone",                        // plain app PVC
+	} {
+		assert.NotContains(t, collected, name)
+	}
+	assert.Equal(t, gcOrphanedCachePVC, candidates[0].Category, "grouped in deletion order")
+	assert.Equal(t, gcReleasedPV, candidates[len(candidates)-1].Category)
+	assert.Contains(t, candidates[len(candidates)-1].Reason, "Retain policy")
+
+	scoped := findStorageGCCandidates(inventory, "media", storageGCDefaultSnapshotAge, storageNowFn())
+	assert.Len(t, scoped, len(candidates)-1, "--namespace excludes PVs claimed from other namespaces")
+
+	older := findStorageGCCandidates(inventory, "", 30*24*time.Hour, storageNowFn())
+	for _, candidate := range older {
+		assert.NotEqual(t, gcStaleSnapshot, candidate.Category, "--age keeps younger snapshots")
+	}
+}
+
+func TestStorageGCDryRunDeletesNothing(t *testing.T) {
+	deletes := withStorageGCFakeCluster(t)
+	testutil.Swap(t, &confirmActionFn, func(string, bool) (bool, error) {
+		t.Fatal("dry run must not prompt")
+		return false, nil
+	})
+
+	out, err := testutil.ExecuteCommand(newStorageGCCommand(), "--dry-run")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Candidates: 6")
+	assert.Contains(t, out, "dry run, nothing deleted")
+	assert.Contains(t, out, "(2 total)")
+	assert.Empty(t, *deletes)
+}
+
+func TestStorageGCDeletesAfterConfirmation(t *testing.T) {
+	deletes := withStorageGCFakeCluster(t)
+	var prompt string
+	testutil.Swap(t, &confirmActionFn, func(message string, _ bool) (bool, error) {
+		prompt = message
+		return true, nil
+	})
+
+	out, err := testutil.ExecuteCommand(newStorageGCCommand())
+	require.NoError(t, err)
+	assert.Contains(t, prompt, "Delete 6 storage object(s)")
+	require.Len(t, *deletes, 6)
+	assert.Equal(t, []string{"kubectl", "delete", gcKindPVC, "volsync-gone-named-cache", "-n", "media", "--ignore-not-found", "--wait=false"}, (*deletes)[0])
+	assert.Equal(t, []string{"kubectl", "delete", gcKindPV, "gone-pv", "--ignore-not-found", "--wait=false"}, (*deletes)[5])
+	assert.Contains(t, out, "Reclaimed 28.0 GiB from 6 object(s)")
+}
+
+func TestStorageGCCancelledAndIncompleteInventory(t *testing.T) {
+	deletes := withStorageGCFakeCluster(t)
+	testutil.Swap(t, &confirmActionFn, func(string, bool) (bool, error) { return false, nil })
+	out, err := testutil.ExecuteCommand(newStorageGCCommand())
+	require.NoError(t, err)
+	assert.Contains(t, out, "cancelled")
+	assert.Empty(t, *deletes)
+
+	testutil.Swap(t, &kubectlOutputCtxFn, func(_ context.Context, args ...string) ([]byte, error) {
+		if args[1] == "replicationsources.volsync.backube" {
+			return nil, errors.New("forbidden")
+		}
+		return []byte(storageGCFakeCluster[args[1]]), nil
+	})
+	_, err = testutil.ExecuteCommand(newStorageGCCommand())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "replicationsources")
+	assert.Empty(t, *deletes)
+}
@@ -48,6 +48,11 @@ type storagePVC struct {
 		Resources        struct {
 			Requests map[string]string `json:"requests"`
 		} `json:"resources"`
+		DataSource *struct {
+			APIGroup string `json:"apiGroup"`
+			Kind     string `json:"kind"`
+			Name     string `json:"name"`
+		} `json:"dataSource"`
 		DataSourceRef *struct {
 			APIGroup string `json:"apiGroup"`
 			Kind     string `json:"kind"`
@@ -141,9 +146,10 @@ type storageReplicationList struct {
 type storagePV struct {
 	Metadata metadataJSON `json:"metadata"`
 	Spec     struct {
-		StorageClassName string            `json:"storageClassName"`
-		Capacity         map[string]string `json:"capacity"`
-		ClaimRef         *struct {
+		StorageClassName              string            `json:"storageClassName"`
+		Capacity                      map[string]string `json:"capacity"`
+		PersistentVolumeReclaimPolicy string            `json:"persistentVolumeReclaimPolicy"`
+		ClaimRef                      *struct {
 			Namespace string `json:"namespace"`
 			Name      string `json:"name"`
 		} `json:"claimRef"`
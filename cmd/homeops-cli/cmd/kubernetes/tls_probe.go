@@ -0,0 +1,599 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"homeops-cli/internal/config"
+	"homeops-cli/internal/kubeutil"
+	"homeops-cli/internal/ui"
+)
+
+const (
+	tlsProbeDefaultTimeout       = 10 * time.Second
+	tlsProbeDefaultWarnDays      = 21
+	tlsProbeDefaultHSTSMinMaxAge = 180 * 24 * 60 * 60
+	tlsProbeExternalGatewayHint  = "external"
+
+	tlsProbeHeaderFrameOptions = "X-Frame-Options"
+	tlsProbeHeaderCSP          = "Content-Security-Policy"
+)
+
+// tlsProbePlaceholders are subject or issuer common names of certificates a
+// gateway serves when no real one is in place. cert-manager signs its
+// temporary certificate (cert-manager.io/issue-temporary-certificate) with a
+// throwaway "cert-manager.local" CA.
+var tlsProbePlaceholders = []struct {
+	commonName string
+	detail     string
+}{
+	{"cert-manager.local", "cert-manager temporary certificate; the Certificate has not been issued yet"},
+	{"Kubernetes Ingress Controller Fake Certificate", "ingress-nginx default fake certificate"},
+	{"TRAEFIK DEFAULT CERT", "Traefik default certificate"},
+}
+
+var (
+	tlsProbeNowFn  = time.Now
+	tlsProbeDialFn = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, address)
+	}
+	// tlsProbeRootCAs is nil in production, which verifies against the
+	// system roots.
+	tlsProbeRootCAs *x509.CertPool
+)
+
+type tlsProbeOptions struct {
+	Hosts    []string
+	Public   bool
+	WarnDays int
+	Timeout  time.Duration
+	Output   string
+}
+
+// tlsProbePolicy is the effective homeops.yaml policy for one hostname.
+type tlsProbePolicy struct {
+	RequireHSTS   bool
+	HSTSMinMaxAge int
+	AllowMissing  map[string]bool
+}
+
+type tlsProbeTarget struct {
+	Hostname string
+	Address  string
+	Gateway  string
+	Policy   tlsProbePolicy
+}
+
+// tlsProbeObservation is what one connection to a hostname returned.
+type tlsProbeObservation struct {
+	Certificates []*x509.Certificate
+	HandshakeErr error
+	StatusCode   int
+	Header       http.Header
+	Err          error
+}
+
+type tlsProbeAspect struct {
+	Status doctorStatus `json:"status"`
+	Detail string       `json:"detail"`
+}
+
+type tlsProbeHostResult struct {
+	Hostname    string         `json:"hostname"`
+	Address     string         `json:"address"`
+	Gateway     string         `json:"gateway,omitempty"`
+	NotAfter    string         `json:"not_after,omitempty"`
+	Status      doctorStatus   `json:"status"`
+	Chain       tlsProbeAspect `json:"chain"`
+	Certificate tlsProbeAspect `json:"certificate"`
+	Expiry      tlsProbeAspect `json:"expiry"`
+	HSTS        tlsProbeAspect `json:"hsts"`
+	Headers     tlsProbeAspect `json:"headers"`
+}
+
+type tlsProbeReport struct {
+	Hosts   []tlsProbeHostResult `json:"hosts"`
+	Summary doctorSummary        `json:"summary"`
+	Errors  []string             `json:"errors,omitempty"`
+}
+
+func newTLSProbeCommand() *cobra.Command {
+	opts := tlsProbeOptions{WarnDays: tlsProbeDefaultWarnDays, Timeout: tlsProbeDefaultTimeout, Output: "table"}
+	cmd := &cobra.Command{
+		Use:          "tls-probe",
+		Short:        "Check certificate chains and security headers of externally exposed hostnames",
+		SilenceUsage: true,
+		Long: `Connects to every HTTPRoute hostname attached to an external Gateway, using
+the hostname for TLS SNI, and grades each one:
+
+  CHAIN    the presented chain verifies against the system roots; a missing
+           intermediate or a self-signed root is named
+  CERT     the leaf covers the hostname and is not a placeholder (cert-manager's
+           temporary certificate, an ingress controller default certificate,
+           or any self-signed certificate)
+  EXPIRY   notAfter is more than --warn-days away
+  HSTS     Strict-Transport-Security is present with max-age at least the
+           configured minimum (default 180 days)
+  HEADERS  X-Frame-Options (or CSP frame-ancestors) and Content-Security-Policy
+           are present; a missing header is a warning
+
+External Gateways are cluster.tls_probe.gateways in homeops.yaml, or every
+Gateway whose name contains "external". Connections go to the Gateway Service
+address unless --public is set, which resolves each hostname through normal
+DNS instead. cluster.tls_probe.hosts overrides the policy per hostname.
+
+Exits non-zero when any aspect FAILs.`,
+		Example: `  homeops-cli k8s tls-probe
+  homeops-cli k8s tls-probe --host home.example.com --public
+  homeops-cli k8s tls-probe --warn-days 30 --output json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := ui.ValidateOutputFormat(opts.Output); err != nil {
+				return err
+			}
+			if opts.WarnDays < 0 {
+				return fmt.Errorf("--warn-days cannot be negative")
+			}
+			if opts.Timeout <= 0 {
+				return fmt.Errorf("--timeout must be greater than zero")
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), kubernetesDefaultCommandTimeout)
+			defer cancel()
+			return runTLSProbe(ctx, opts, config.Get().Cluster.TLSProbe, cmd.OutOrStdout())
+		},
+	}
+	cmd.Flags().StringSliceVar(&opts.Hosts, "host", nil, "probe only this external hostname (repeatable)")
+	cmd.Flags().BoolVar(&opts.Public, "public", false, "connect through public DNS instead of the Gateway Service address")
+	cmd.Flags().IntVar(&opts.WarnDays, "warn-days", opts.WarnDays, "warn when a certificate expires in fewer than this many days")
+	cmd.Flags().DurationVar(&opts.Timeout, "timeout", opts.Timeout, "timeout for each hostname probe")
+	cmd.Flags().StringVarP(&opts.Output, "output", "o", opts.Output, "output format: table or json")
+	return cmd
+}
+
+func runTLSProbe(ctx context.Context, opts tlsProbeOptions, cfg config.TLSProbeConfig, out io.Writer) error {
+	targets, errs := collectTLSProbeTargets(ctx, cfg, opts.Public)
+	if len(opts.Hosts) > 0 {
+		selected, err := selectTLSProbeTargets(targets, opts.Hosts)
+		if err != nil {
+			return err
+		}
+		targets = selected
+	}
+	report := probeTLSTargets(ctx, targets, opts.Timeout, time.Duration(opts.WarnDays)*24*time.Hour)
+	report.Errors = errs
+	rendered, err := renderTLSProbeReport(report, opts.Output)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(out, rendered); err != nil {
+		return err
+	}
+	if report.Summary.Fail > 0 {
+		return fmt.Errorf("tls-probe found %d failing host(s)", report.Summary.Fail)
+	}
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("tls-probe incomplete: %d error(s)", len(report.Errors))
+	}
+	return nil
+}
+
+// collectTLSProbeTargets resolves each external HTTPRoute hostname to the
+// address of its Gateway, once per hostname.
+func collectTLSProbeTargets(ctx context.Context, cfg config.TLSProbeConfig, public bool) ([]tlsProbeTarget, []string) {
+	var errs []string
+	var routes netDoctorHTTPRouteList
+	if err := kubeutil.GetJSON(ctx, kubectlOutputCtxFn, "", netDoctorHTTPRouteResource, &routes); err != nil {
+		errs = append(errs, err.Error())
+	}
+	var gateways netDoctorGatewayList
+	if err := kubeutil.GetJSON(ctx, kubectlOutputCtxFn, "", netDoctorGatewayResource, &gateways); err != nil {
+		errs = append(errs, err.Error())
+	}
+	var services netDoctorServiceList
+	if err := kubeutil.GetJSON(ctx, kubectlOutputCtxFn, "", "services", &services); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	external := tlsProbeExternalGateways(cfg, gateways.Items)
+	for i := range routes.Items {
+		parents := routes.Items[i].Spec.ParentRefs[:0:0]
+		for _, parent := range routes.Items[i].Spec.ParentRefs {
+			namespace := parent.Namespace
+			if namespace == "" {
+				namespace = routes.Items[i].Metadata.Namespace
+			}
+			if external[netDoctorGatewayKey(namespace, parent.Name)] {
+				parents = append(parents, parent)
+			}
+		}
+		routes.Items[i].Spec.ParentRefs = parents
+	}
+	probeTargets, problems := buildNetDoctorProbeTargets(routes.Items, gateways.Items, services.Items, netDoctorEndpointSliceList{})
+	for _, problem := range problems {
+		errs = append(errs, problem.Name+": "+problem.Detail)
+	}
+
+	var targets []tlsProbeTarget
+	seen := map[string]bool{}
+	for _, probeTarget := range probeTargets {
+		hostname := normalizeDNSName(probeTarget.Hostname)
+		if seen[hostname] || strings.HasPrefix(hostname, "*") {
+			continue
+		}
+		seen[hostname] = true
+		override := cfg.Hosts[hostname]
+		if override.Skip {
+			continue
+		}
+		address := net.JoinHostPort(probeTarget.Address, strconv.Itoa(probeTarget.Port))
+		if public {
+			address = net.JoinHostPort(hostname, "443")
+		}
+		targets = append(targets, tlsProbeTarget{
+			Hostname: hostname,
+			Address:  address,
+			Gateway:  probeTarget.Gateway,
+			Policy:   tlsProbePolicyFor(cfg, override),
+		})
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Hostname < targets[j].Hostname })
+	return targets, errs
+}
+
+// tlsProbeExternalGateways returns the "namespace/name" keys of the Gateways
+// whose routes count as externally exposed.
+func tlsProbeExternalGateways(cfg config.TLSProbeConfig, gateways []netDoctorGateway) map[string]bool {
+	external := map[string]bool{}
+	if len(cfg.Gateways) > 0 {
+		for _, gateway := range cfg.Gateways {
+			external[gateway] = true
+		}
+		return external
+	}
+	for _, gateway := range gateways {
+		if strings.Contains(strings.ToLower(gateway.Metadata.Name), tlsProbeExternalGatewayHint) {
+			external[netDoctorGatewayKey(gateway.Metadata.Namespace, gateway.Metadata.Name)] = true
+		}
+	}
+	return external
+}
+
+func tlsProbePolicyFor(cfg config.TLSProbeConfig, override config.TLSProbeHost) tlsProbePolicy {
+	policy := tlsProbePolicy{RequireHSTS: true, HSTSMinMaxAge: tlsProbeDefaultHSTSMinMaxAge, AllowMissing: map[string]bool{}}
+	if cfg.HSTSMinMaxAge > 0 {
+		policy.HSTSMinMaxAge = cfg.HSTSMinMaxAge
+	}
+	if override.HSTS != nil {
+		policy.RequireHSTS = *override.HSTS
+	}
+	if override.HSTSMinMaxAge > 0 {
+		policy.HSTSMinMaxAge = override.HSTSMinMaxAge
+	}
+	for _, header := range override.AllowMissingHeaders {
+		policy.AllowMissing[http.CanonicalHeaderKey(header)] = true
+	}
+	return policy
+}
+
+func selectTLSProbeTargets(targets []tlsProbeTarget, hosts []string) ([]tlsProbeTarget, error) {
+	byHost := make(map[string]tlsProbeTarget, len(targets))
+	for _, target := range targets {
+		byHost[target.Hostname] = target
+	}
+	var selected []tlsProbeTarget
+	for _, host := range hosts {
+		target, ok := byHost[normalizeDNSName(host)]
+		if !ok {
+			return nil, fmt.Errorf("--host %s is not an external HTTPRoute hostname (or is skipped in cluster.tls_probe.hosts)", host)
+		}
+		selected = append(selected, target)
+	}
+	return selected, nil
+}
+
+func probeTLSTargets(ctx context.Context, targets []tlsProbeTarget, timeout, warnWindow time.Duration) tlsProbeReport {
+	observations := make([]tlsProbeObservation, len(targets))
+	jobs := make(chan int)
+	workerCount := netDoctorProbeConcurrency
+	if len(targets) < workerCount {
+		workerCount = len(targets)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workerCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				probeCtx, cancel := context.WithTimeout(ctx, timeout)
+				observations[index] = probeTLSHost(probeCtx, targets[index])
+				cancel()
+			}
+		}()
+	}
+	for i := range targets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var report tlsProbeReport
+	now := tlsProbeNowFn()
+	for i, target := range targets {
+		result := evaluateTLSProbe(target, observations[i], now, warnWindow)
+		switch result.Status {
+		case statusPass:
+			report.Summary.Pass++
+		case statusWarn:
+			report.Summary.Warn++
+		default:
+			report.Summary.Fail++
+		}
+		report.Hosts = append(report.Hosts, result)
+	}
+	return report
+}
+
+// probeTLSHost completes the handshake without verification so the chain is
+// captured even when it is broken, then sends one GET without following
+// redirects to read the response headers.
+func probeTLSHost(ctx context.Context, target tlsProbeTarget) tlsProbeObservation {
+	var observation tlsProbeObservation
+	transport := &http.Transport{
+		DisableKeepAlives: true,
+		DialTLSContext: func(dialCtx context.Context, _, _ string) (net.Conn, error) {
+			conn, err := tlsProbeDialFn(dialCtx, "tcp", target.Address)
+			if err != nil {
+				observation.HandshakeErr = err
+				return nil, err
+			}
+			tlsConn := tls.Client(conn, &tls.Config{ServerName: target.Hostname, InsecureSkipVerify: true, MinVersion: tls.VersionTLS12}) // #nosec G402 -- evaluateTLSChain verifies the captured chain
+			if err := tlsConn.HandshakeContext(dialCtx); err != nil {
+				_ = conn.Close()
+				observation.HandshakeErr = err
+				return nil, err
+			}
+			observation.Certificates = tlsConn.ConnectionState().PeerCertificates
+			return tlsConn, nil
+		},
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{
+		Transport:     transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+target.Hostname+"/", nil)
+	if err != nil {
+		observation.Err = err
+		return observation
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		observation.Err = err
+		return observation
+	}
+	defer func() { _ = response.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+	observation.StatusCode = response.StatusCode
+	observation.Header = response.Header
+	return observation
+}
+
+func evaluateTLSProbe(target tlsProbeTarget, observation tlsProbeObservation, now time.Time, warnWindow time.Duration) tlsProbeHostResult {
+	result := tlsProbeHostResult{Hostname: target.Hostname, Address: target.Address, Gateway: target.Gateway}
+	if len(observation.Certificates) == 0 {
+		detail := "no certificate presented"
+		if observation.HandshakeErr != nil {
+			detail = "handshake failed: " + observation.HandshakeErr.Error()
+		} else if observation.Err != nil {
+			detail = observation.Err.Error()
+		}
+		failed := tlsProbeAspect{Status: statusFail, Detail: detail}
+		result.Chain, result.Certificate, result.Expiry, result.HSTS, result.Headers = failed, failed, failed, failed, failed
+		result.Status = statusFail
+		return result
+	}
+	leaf := observation.Certificates[0]
+	result.NotAfter = leaf.NotAfter.UTC().Format(time.RFC3339)
+	result.Chain = evaluateTLSChain(observation.Certificates, now, tlsProbeRootCAs)
+	result.Certificate = evaluateTLSLeaf(leaf, target.Hostname)
+	result.Expiry = evaluateTLSExpiry(leaf, now, warnWindow)
+	if observation.StatusCode == 0 {
+		detail := "no HTTP response"
+		if observation.Err != nil {
+			detail += ": " + observation.Err.Error()
+		}
+		result.HSTS = tlsProbeAspect{Status: statusFail, Detail: detail}
+		result.Headers = tlsProbeAspect{Status: statusWarn, Detail: detail}
+	} else {
+		result.HSTS = evaluateHSTS(observation.Header, target.Policy)
+		result.Headers = evaluateSecurityHeaders(observation.Header, target.Policy)
+	}
+	result.Status = statusPass
+	for _, aspect := range []tlsProbeAspect{result.Chain, result.Certificate, result.Expiry, result.HSTS, result.Headers} {
+		if aspect.Status == statusFail {
+			result.Status = statusFail
+		} else if aspect.Status == statusWarn && result.Status == statusPass {
+			result.Status = statusWarn
+		}
+	}
+	return result
+}
+
+// evaluateTLSChain verifies the presented chain. When verification fails it
+// follows issuers through the presented certificates to name the first one
+// the server did not send.
+func evaluateTLSChain(certificates []*x509.Certificate, now time.Time, roots *x509.CertPool) tlsProbeAspect {
+	leaf := certificates[0]
+	intermediates := x509.NewCertPool()
+	for _, certificate := range certificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	if err == nil {
+		chain := chains[0]
+		return tlsProbeAspect{Status: statusPass, Detail: fmt.Sprintf("%d presented, verified to %s", len(certificates), tlsProbeName(chain[len(chain)-1].Subject.CommonName))}
+	}
+	var unknown x509.UnknownAuthorityError
+	if !errors.As(err, &unknown) {
+		return tlsProbeAspect{Status: statusFail, Detail: err.Error()}
+	}
+	last := leaf
+	for visited := 0; visited < len(certificates); visited++ {
+		if bytes.Equal(last.RawIssuer, last.RawSubject) {
+			if last == leaf {
+				return tlsProbeAspect{Status: statusFail, Detail: "self-signed leaf, no CA chain"}
+			}
+			return tlsProbeAspect{Status: statusFail, Detail: fmt.Sprintf("chain ends at untrusted root %s", tlsProbeName(last.Subject.CommonName))}
+		}
+		next := tlsProbeIssuer(last, certificates)
+		if next == nil {
+			break
+		}
+		last = next
+	}
+	detail := fmt.Sprintf("missing intermediate %s: the server did not send the issuer of %s", tlsProbeName(last.Issuer.CommonName), tlsProbeName(last.Subject.CommonName))
+	if len(last.IssuingCertificateURL) > 0 {
+		detail += " (AIA " + last.IssuingCertificateURL[0] + ")"
+	}
+	return tlsProbeAspect{Status: statusFail, Detail: detail}
+}
+
+func tlsProbeIssuer(certificate *x509.Certificate, presented []*x509.Certificate) *x509.Certificate {
+	for _, candidate := range presented {
+		if candidate != certificate && bytes.Equal(candidate.RawSubject, certificate.RawIssuer) {
+			return candidate
+		}
+	}
+	return nil
+}
+
+func evaluateTLSLeaf(leaf *x509.Certificate, hostname string) tlsProbeAspect {
+	for _, placeholder := range tlsProbePlaceholders {
+		if leaf.Subject.CommonName == placeholder.commonName || leaf.Issuer.CommonName == placeholder.commonName {
+			return tlsProbeAspect{Status: statusFail, Detail: "placeholder: " + placeholder.detail}
+		}
+	}
+	if bytes.Equal(leaf.RawIssuer, leaf.RawSubject) {
+		return tlsProbeAspect{Status: statusFail, Detail: "placeholder: self-signed certificate " + tlsProbeName(leaf.Subject.CommonName)}
+	}
+	if err := leaf.VerifyHostname(hostname); err != nil {
+		return tlsProbeAspect{Status: statusFail, Detail: fmt.Sprintf("does not cover %s (SANs: %s)", hostname, strings.Join(leaf.DNSNames, ", "))}
+	}
+	return tlsProbeAspect{Status: statusPass, Detail: "issued by " + tlsProbeName(leaf.Issuer.CommonName)}
+}
+
+func evaluateTLSExpiry(leaf *x509.Certificate, now time.Time, warnWindow time.Duration) tlsProbeAspect {
+	remaining := leaf.NotAfter.Sub(now)
+	days := int(remaining / (24 * time.Hour))
+	switch {
+	case remaining <= 0:
+		return tlsProbeAspect{Status: statusFail, Detail: "expired " + leaf.NotAfter.UTC().Format(time.RFC3339)}
+	case now.Before(leaf.NotBefore):
+		return tlsProbeAspect{Status: statusFail, Detail: "not valid until " + leaf.NotBefore.UTC().Format(time.RFC3339)}
+	case remaining < warnWindow:
+		return tlsProbeAspect{Status: statusWarn, Detail: fmt.Sprintf("expires in %dd", days)}
+	}
+	return tlsProbeAspect{Status: statusPass, Detail: fmt.Sprintf("expires in %dd", days)}
+}
+
+func evaluateHSTS(header http.Header, policy tlsProbePolicy) tlsProbeAspect {
+	value := strings.TrimSpace(header.Get("Strict-Transport-Security"))
+	if !policy.RequireHSTS {
+		if value == "" {
+			return tlsProbeAspect{Status: statusPass, Detail: "not required (cluster.tls_probe.hosts)"}
+		}
+		return tlsProbeAspect{Status: statusPass, Detail: value}
+	}
+	if value == "" {
+		return tlsProbeAspect{Status: statusFail, Detail: "Strict-Transport-Security missing"}
+	}
+	maxAge := -1
+	for _, directive := range strings.Split(value, ";") {
+		name, raw, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if strings.EqualFold(name, "max-age") {
+			if parsed, err := strconv.Atoi(strings.Trim(strings.TrimSpace(raw), `"`)); err == nil {
+				maxAge = parsed
+			}
+		}
+	}
+	if maxAge < 0 {
+		return tlsProbeAspect{Status: statusFail, Detail: fmt.Sprintf("no valid max-age in %q", value)}
+	}
+	if maxAge < policy.HSTSMinMaxAge {
+		return tlsProbeAspect{Status: statusFail, Detail: fmt.Sprintf("max-age=%d below %d", maxAge, policy.HSTSMinMaxAge)}
+	}
+	return tlsProbeAspect{Status: statusPass, Detail: value}
+}
+
+func evaluateSecurityHeaders(header http.Header, policy tlsProbePolicy) tlsProbeAspect {
+	csp := header.Get(tlsProbeHeaderCSP)
+	var missing []string
+	if header.Get(tlsProbeHeaderFrameOptions) == "" && !strings.Contains(strings.ToLower(csp), "frame-ancestors") && !policy.AllowMissing[tlsProbeHeaderFrameOptions] {
+		missing = append(missing, tlsProbeHeaderFrameOptions)
+	}
+	if csp == "" && !policy.AllowMissing[tlsProbeHeaderCSP] {
+		missing = append(missing, tlsProbeHeaderCSP)
+	}
+	if len(missing) > 0 {
+		return tlsProbeAspect{Status: statusWarn, Detail: strings.Join(missing, ", ") + " missing"}
+	}
+	return tlsProbeAspect{Status: statusPass, Detail: "present"}
+}
+
+func tlsProbeName(commonName string) string {
+	if commonName == "" {
+		return "(no CN)"
+	}
+	return strconv.Quote(commonName)
+}
+
+func renderTLSProbeReport(report tlsProbeReport, output string) (string, error) {
+	if output == "json" {
+		return ui.RenderJSON(report)
+	}
+	var rows [][]string
+	var findings []string
+	for _, host := range report.Hosts {
+		rows = append(rows, []string{host.Hostname, string(host.Status), string(host.Chain.Status), string(host.Certificate.Status),
+			string(host.Expiry.Status), host.NotAfter, string(host.HSTS.Status), string(host.Headers.Status)})
+		for _, aspect := range []struct {
+			name   string
+			aspect tlsProbeAspect
+		}{{"chain", host.Chain}, {"cert", host.Certificate}, {"expiry", host.Expiry}, {"hsts", host.HSTS}, {"headers", host.Headers}} {
+			if aspect.aspect.Status != statusPass {
+				findings = append(findings, fmt.Sprintf("  %s %s %s: %s", aspect.aspect.Status, host.Hostname, aspect.name, aspect.aspect.Detail))
+			}
+		}
+	}
+	for _, detail := range report.Errors {
+		findings = append(findings, "  ERROR "+detail)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "TLS PROBE  PASS: %d  WARN: %d  FAIL: %d\n", report.Summary.Pass, report.Summary.Warn, report.Summary.Fail)
+	b.WriteString(ui.Table([]string{"HOSTNAME", "STATUS", "CHAIN", "CERT", "EXPIRY", "NOT AFTER", "HSTS", "HEADERS"}, rows))
+	if len(findings) > 0 {
+		b.WriteString("\nFindings:\n" + strings.Join(findings, "\n"))
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
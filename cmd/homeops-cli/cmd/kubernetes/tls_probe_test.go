@@ -0,0 +1,292 @@
+package kubernetes
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/config"
+	"homeops-cli/internal/testutil"
+)
+
+// tlsProbeTestCert is a crafted certificate with its key, so it can sign the
+// next link of a chain.
+type tlsProbeTestCert struct {
+	der  []byte
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+func newTLSProbeTestCert(t *testing.T, template *x509.Certificate, parent *tlsProbeTestCert) *tlsProbeTestCert {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	if template.NotBefore.IsZero() {
+		template.NotBefore = time.Now().Add(-time.Hour)
+	}
+	if template.NotAfter.IsZero() {
+		template.NotAfter = time.Now().Add(90 * 24 * time.Hour)
+	}
+	issuer, signer := template, crypto.Signer(private)
+	if parent != nil {
+		issuer, signer = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, public, signer)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &tlsProbeTestCert{der: der, cert: cert, key: private}
+}
+
+func tlsProbeTestCA(t *testing.T, commonName string, parent *tlsProbeTestCert) *tlsProbeTestCert {
+	t.Helper()
+	return newTLSProbeTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: commonName},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}, parent)
+}
+
+func tlsProbeTestLeaf(t *testing.T, hostname string, parent *tlsProbeTestCert, notAfter time.Time) *tlsProbeTestCert {
+	t.Helper()
+	return newTLSProbeTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: hostname},
+		DNSNames:              []string{hostname},
+		NotAfter:              notAfter,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		IssuingCertificateURL: []string{"http://pki.example.test/intermediate.crt"},
+	}, parent)
+}
+
+// serveTLSProbeChain starts a TLS server on an in-memory listener presenting
+// chain (leaf first) and routes tlsProbeDialFn to it.
+func serveTLSProbeChain(t *testing.T, header http.Header, chain ...*tlsProbeTestCert) {
+	t.Helper()
+	listener := newNetDoctorPipeListener()
+	server := &httptest.Server{Listener: listener, Config: &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		for name, values := range header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(http.StatusFound)
+	})}}
+	certificate := tls.Certificate{PrivateKey: chain[0].key}
+	for _, link := range chain {
+		certificate.Certificate = append(certificate.Certificate, link.der)
+	}
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: tls.VersionTLS12}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	testutil.Swap(t, &tlsProbeDialFn, listener.dialContext)
+}
+
+func tlsProbeTestRoots(roots ...*tlsProbeTestCert) *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, root := range roots {
+		pool.AddCert(root.cert)
+	}
+	return pool
+}
+
+var tlsProbeSecureHeaders = http.Header{
+	"Strict-Transport-Security": {"max-age=31536000; includeSubDomains"},
+	"X-Frame-Options":           {"DENY"},
+	"Content-Security-Policy":   {"default-src 'self'"},
+}
+
+func probeTLSProbeTarget(t *testing.T, hostname string, policy tlsProbePolicy) tlsProbeHostResult {
+	t.Helper()
+	target := tlsProbeTarget{Hostname: hostname, Address: "127.0.0.1:443", Gateway: "network/envoy-external", Policy: policy}
+	observation := probeTLSHost(context.Background(), target)
+	return evaluateTLSProbe(target, observation, time.Now(), tlsProbeDefaultWarnDays*24*time.Hour)
+}
+
+func defaultTLSProbePolicy() tlsProbePolicy {
+	return tlsProbePolicyFor(config.TLSProbeConfig{}, config.TLSProbeHost{})
+}
+
+func TestTLSProbeChainValidation(t *testing.T) {
+	const hostname = "app.example.test"
+	root := tlsProbeTestCA(t, "test root", nil)
+	intermediate := tlsProbeTestCA(t, "test intermediate", root)
+	leaf := tlsProbeTestLeaf(t, hostname, intermediate, time.Time{})
+	testutil.Swap(t, &tlsProbeRootCAs, tlsProbeTestRoots(root))
+
+	serveTLSProbeChain(t, tlsProbeSecureHeaders, leaf, intermediate)
+	result := probeTLSProbeTarget(t, hostname, defaultTLSProbePolicy())
+	assert.Equal(t, statusPass, result.Status, "%+v", result)
+	assert.Equal(t, `2 presented, verified to "test root"`, result.Chain.Detail)
+	assert.Equal(t, leaf.cert.NotAfter.UTC().Format(time.RFC3339), result.NotAfter)
+
+	serveTLSProbeChain(t, tlsProbeSecureHeaders, leaf)
+	result = probeTLSProbeTarget(t, hostname, defaultTLSProbePolicy())
+	assert.Equal(t, statusFail, result.Chain.Status)
+	assert.Equal(t, `missing intermediate "test intermediate": the server did not send the issuer of "app.example.test" (AIA http://pki.example.test/intermediate.crt)`, result.Chain.Detail)
+	assert.Equal(t, statusPass, result.Certificate.Status, "the leaf itself is fine")
+
+	otherRoot := tlsProbeTestCA(t, "private root", nil)
+	otherIntermediate := tlsProbeTestCA(t, "private intermediate", otherRoot)
+	serveTLSProbeChain(t, tlsProbeSecureHeaders, tlsProbeTestLeaf(t, hostname, otherIntermediate, time.Time{}), otherIntermediate, otherRoot)
+	result = probeTLSProbeTarget(t, hostname, defaultTLSProbePolicy())
+	assert.Equal(t, `chain ends at untrusted root "private root"`, result.Chain.Detail)
+}
+
+func TestTLSProbeDetectsPlaceholderAndMismatchedCertificates(t *testing.T) {
+	const hostname = "app.example.test"
+	root := tlsProbeTestCA(t, "test root", nil)
+	testutil.Swap(t, &tlsProbeRootCAs, tlsProbeTestRoots(root))
+
+	temporaryCA := tlsProbeTestCA(t, "cert-manager.local", nil)
+	serveTLSProbeChain(t, tlsProbeSecureHeaders, tlsProbeTestLeaf(t, hostname, temporaryCA, time.Time{}))
+	result := probeTLSProbeTarget(t, hostname, defaultTLSProbePolicy())
+	assert.Equal(t, statusFail, result.Certificate.Status)
+	assert.Equal(t, "placeholder: cert-manager temporary certificate; the Certificate has not been issued yet", result.Certificate.Detail)
+
+	fake := newTLSProbeTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "Kubernetes Ingress Controller Fake Certificate", Organization: []string{"Acme Co"}},
+		DNSNames:    []string{"ingress.local"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, nil)
+	serveTLSProbeChain(t, tlsProbeSecureHeaders, fake)
+	result = probeTLSProbeTarget(t, hostname, defaultTLSProbePolicy())
+	assert.Equal(t, "placeholder: ingress-nginx default fake certificate", result.Certificate.Detail)
+	assert.Equal(t, "self-signed leaf, no CA chain", result.Chain.Detail)
+
+	selfSigned := newTLSProbeTestCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: hostname}, DNSNames: []string{hostname}}, nil)
+	serveTLSProbeChain(t, tlsProbeSecureHeaders, selfSigned)
+	result = probeTLSProbeTarget(t, hostname, defaultTLSProbePolicy())
+	assert.Equal(t, `placeholder: self-signed certificate "app.example.test"`, result.Certificate.Detail)
+
+	serveTLSProbeChain(t, tlsProbeSecureHeaders, tlsProbeTestLeaf(t, "other.example.test", root, time.Now().Add(5*24*time.Hour)))
+	result = probeTLSProbeTarget(t, hostname, defaultTLSProbePolicy())
+	assert.Equal(t, statusPass, result.Chain.Status)
+	assert.Equal(t, "does not cover app.example.test (SANs: other.example.test)", result.Certificate.Detail)
+	assert.Equal(t, tlsProbeAspect{Status: statusWarn, Detail: "expires in 4d"}, result.Expiry)
+}
+
+func TestTLSProbeHeaderPolicy(t *testing.T) {
+	const hostname = "app.example.test"
+	root := tlsProbeTestCA(t, "test root", nil)
+	leaf := tlsProbeTestLeaf(t, hostname, root, time.Time{})
+	testutil.Swap(t, &tlsProbeRootCAs, tlsProbeTestRoots(root))
+	disabled := false
+
+	for _, tc := range []struct {
+		name     string
+		header   http.Header
+		override config.TLSProbeHost
+		hsts     tlsProbeAspect
+		headers  tlsProbeAspect
+	}{
+		{
+			name:    "secure",
+			header:  tlsProbeSecureHeaders,
+			hsts:    tlsProbeAspect{Status: statusPass, Detail: "max-age=31536000; includeSubDomains"},
+			headers: tlsProbeAspect{Status: statusPass, Detail: "present"},
+		},
+		{
+			name:    "nothing set",
+			header:  http.Header{},
+			hsts:    tlsProbeAspect{Status: statusFail, Detail: "Strict-Transport-Security missing"},
+			headers: tlsProbeAspect{Status: statusWarn, Detail: "X-Frame-Options, Content-Security-Policy missing"},
+		},
+		{
+			name:    "short max-age, CSP frame-ancestors instead of X-Frame-Options",
+			header:  http.Header{"Strict-Transport-Security": {"max-age=86400"}, "Content-Security-Policy": {"frame-ancestors 'none'"}},
+			hsts:    tlsProbeAspect{Status: statusFail, Detail: "max-age=86400 below 15552000"},
+			headers: tlsProbeAspect{Status: statusPass, Detail: "present"},
+		},
+		{
+			name:     "per-host override",
+			header:   http.Header{"Strict-Transport-Security": {"max-age=86400"}, "X-Frame-Options": {"SAMEORIGIN"}},
+			override: config.TLSProbeHost{HSTSMinMaxAge: 3600, AllowMissingHeaders: []string{"content-security-policy"}},
+			hsts:     tlsProbeAspect{Status: statusPass, Detail: "max-age=86400"},
+			headers:  tlsProbeAspect{Status: statusPass, Detail: "present"},
+		},
+		{
+			name:     "HSTS not required",
+			header:   http.Header{"X-Frame-Options": {"DENY"}, "Content-Security-Policy": {"default-src 'self'"}},
+			override: config.TLSProbeHost{HSTS: &disabled},
+			hsts:     tlsProbeAspect{Status: statusPass, Detail: "not required (cluster.tls_probe.hosts)"},
+			headers:  tlsProbeAspect{Status: statusPass, Detail: "present"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			serveTLSProbeChain(t, tc.header, leaf)
+			result := probeTLSProbeTarget(t, hostname, tlsProbePolicyFor(config.TLSProbeConfig{}, tc.override))
+			assert.Equal(t, tc.hsts, result.HSTS)
+			assert.Equal(t, tc.headers, result.Headers)
+		})
+	}
+}
+
+func TestTLSProbeSelectsExternalGatewayHostnames(t *testing.T) {
+	const routes = `{"items":[
+		{"metadata":{"namespace":"apps","name":"home"},"spec":{"hostnames":["home.example.test","legacy.example.test"],"parentRefs":[{"name":"envoy-external","namespace":"network"}]}},
+		{"metadata":{"namespace":"apps","name":"grafana"},"spec":{"hostnames":["grafana.example.test"],"parentRefs":[{"name":"envoy-internal","namespace":"network"}]}},
+		{"metadata":{"namespace":"apps","name":"status"},"spec":{"hostnames":["status.example.test"],"parentRefs":[{"name":"envoy-internal","namespace":"network"},{"name":"envoy-external","namespace":"network"}]}}
+	]}`
+	const gateways = `{"items":[{"metadata":{"namespace":"network","name":"envoy-external"}},{"metadata":{"namespace":"network","name":"envoy-internal"}}]}`
+	const services = `{"items":[
+		{"metadata":{"namespace":"network","name":"envoy-external","labels":{"gateway.networking.k8s.io/gateway-name":"envoy-external"}},"spec":{"ports":[{"name":"https","port":443}]},"status":{"loadBalancer":{"ingress":[{"ip":"192.0.2.20"}]}}},
+		{"metadata":{"namespace":"network","name":"envoy-internal","labels":{"gateway.networking.k8s.io/gateway-name":"envoy-internal"}},"spec":{"ports":[{"name":"https","port":443}]},"status":{"loadBalancer":{"ingress":[{"ip":"192.0.2.21"}]}}}
+	]}`
+	testutil.Swap(t, &kubectlOutputCtxFn, func(_ context.Context, args ...string) ([]byte, error) {
+		switch strings.Join(args, " ") {
+		case "get " + netDoctorHTTPRouteResource + " -A -o json":
+			return []byte(routes), nil
+		case "get " + netDoctorGatewayResource + " -A -o json":
+			return []byte(gateways), nil
+		case "get services -A -o json":
+			return []byte(services), nil
+		}
+		return nil, fmt.Errorf("unexpected kubectl %v", args)
+	})
+	cfg := config.TLSProbeConfig{HSTSMinMaxAge: 600, Hosts: map[string]config.TLSProbeHost{"legacy.example.test": {Skip: true}}}
+
+	targets, errs := collectTLSProbeTargets(context.Background(), cfg, false)
+	require.Empty(t, errs)
+	require.Len(t, targets, 2, "internal-only and skipped hostnames are left out")
+	assert.Equal(t, "home.example.test", targets[0].Hostname)
+	assert.Equal(t, "192.0.2.20:443", targets[0].Address)
+	assert.Equal(t, 600, targets[0].Policy.HSTSMinMaxAge)
+	assert.Equal(t, "status.example.test", targets[1].Hostname)
+	assert.Equal(t, "network/envoy-external", targets[1].Gateway)
+
+	targets, _ = collectTLSProbeTargets(context.Background(), config.TLSProbeConfig{Gateways: []string{"network/envoy-internal"}}, true)
+	require.Len(t, targets, 2)
+	assert.Equal(t, "grafana.example.test:443", targets[0].Address, "--public dials the hostname itself")
+
+	// End to end: one host serves a temporary certificate, so the run fails.
+	root := tlsProbeTestCA(t, "test root", nil)
+	testutil.Swap(t, &tlsProbeRootCAs, tlsProbeTestRoots(root))
+	serveTLSProbeChain(t, tlsProbeSecureHeaders, tlsProbeTestLeaf(t, "home.example.test", tlsProbeTestCA(t, "cert-manager.local", nil), time.Time{}))
+	var out strings.Builder
+	err := runTLSProbe(context.Background(), tlsProbeOptions{Hosts: []string{"home.example.test"}, Timeout: 5 * time.Second, Output: "json"}, cfg, &out)
+	require.ErrorContains(t, err, "tls-probe found 1 failing host(s)")
+	var report tlsProbeReport
+	require.NoError(t, json.Unmarshal([]byte(out.String()), &report))
+	require.Len(t, report.Hosts, 1)
+	assert.Equal(t, statusFail, report.Hosts[0].Status)
+	assert.Contains(t, report.Hosts[0].Certificate.Detail, "cert-manager temporary certificate")
+
+	require.ErrorContains(t, runTLSProbe(context.Background(), tlsProbeOptions{Hosts: []string{"legacy.example.test"}, Timeout: time.Second}, cfg, &out),
+		"--host legacy.example.test is not an external HTTPRoute hostname")
+}
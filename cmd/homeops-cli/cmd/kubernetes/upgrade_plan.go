@@ -14,6 +14,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
+	"homeops-cli/internal/common"
 	shareddiff "homeops-cli/internal/diff"
 )
 
@@ -36,12 +37,8 @@ type upgradePlanSetOptions struct {
 var (
 	strictKubernetesVersionRE = regexp.MustCompile(`^v\d+\.\d+\.\d+$`)
 	planVersionLineRE         = regexp.MustCompile(`^([ \t]*version:[ \t]*)(["']?)(v\d+\.\d+\.\d+)(["']?)([ \t]*(?:#.*)?)$`)
-	upgradePlanGitRootFn      = func(ctx context.Context) (string, error) {
-		out, err := runKubernetesCommandOutputCtx(ctx, "git", "rev-parse", "--show-toplevel")
-		if err != nil {
-			return "", err
-		}
-		return strings.TrimSpace(string(out)), nil
+	upgradePlanGitRootFn      = func(context.Context) (string, error) {
+		return common.RepoRoot()
 	}
 	upgradePlanGitRunFn = func(ctx context.Context, args ...string) error {
 		return runKubernetesCommandRunCtx(ctx, "git", args...)
@@ -75,7 +72,7 @@ func newUpgradePlanSetCommand() *cobra.Command {
 			return runUpgradePlanSet(cmd.Context(), opts, cmd.OutOrStdout())
 		},
 	}
-	cmd.Flags().StringVar(&opts.RepoRoot, "repo-root", "", "git repository root (default: git rev-parse --show-toplevel)")
+	cmd.Flags().StringVar(&opts.RepoRoot, "repo-root", "", "git repository root (default: --root-dir, HOMEOPS_ROOT, or the enclosing checkout)")
 	cmd.Flags().StringVar(&opts.PlanFile, "plan-file", "", "kubeadm Plan YAML path, relative to the repository root")
 	cmd.Flags().BoolVar(&opts.Write, "write", false, "write the previewed scalar edit to the Plan file")
 	cmd.Flags().BoolVar(&opts.Commit, "commit", false, "commit only the Plan file after --write (never pushes)")
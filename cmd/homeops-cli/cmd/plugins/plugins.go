@@ -0,0 +1,409 @@
+// Package plugins discovers homeops-<name> executables, kubectl-plugin style,
+// and registers them as top-level subcommands.
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/config"
+	"homeops-cli/internal/constants"
+	"homeops-cli/internal/secrets"
+	"homeops-cli/internal/ui"
+)
+
+// Prefix is the executable name prefix that marks a plugin.
+const Prefix = "homeops-"
+
+// Environment handed to every plugin on top of the caller's environment.
+const (
+	EnvKubeconfig     = "HOMEOPS_KUBECONFIG"
+	EnvTalosconfig    = "HOMEOPS_TALOSCONFIG"
+	EnvConfig         = "HOMEOPS_CONFIG"
+	EnvRoot           = constants.EnvHomeOpsRoot
+	EnvLogLevel       = "HOMEOPS_LOG_LEVEL"
+	EnvNonInteractive = "HOMEOPS_NON_INTERACTIVE"
+	EnvAssumeYes      = "HOMEOPS_ASSUME_YES"
+	EnvDryRun         = "HOMEOPS_DRY_RUN"
+)
+
+// reservedNames are commands cobra adds on its own, which Commands() does
+// not list until execution.
+var reservedNames = []string{"help", "completion", "__complete", "__completeNoDesc"}
+
+var (
+	warnFn = func(msg string, args ...interface{}) { common.NewColorLogger().Warn(msg, args...) }
+	// isTerminalFn reports whether stdin is interactive; swapped in tests.
+	isTerminalFn = func() bool {
+		fi, err := os.Stdin.Stat()
+		return err == nil && fi.Mode()&os.ModeCharDevice != 0
+	}
+)
+
+// Plugin is one discovered homeops-<name> executable.
+type Plugin struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	// Shadowed lists later executables with the same name that lost to Path.
+	Shadowed []string `json:"shadowed,omitempty"`
+	// Conflict names the built-in command this plugin collides with; such
+	// plugins are not registered.
+	Conflict string `json:"conflict,omitempty"`
+}
+
+// ExitError carries a plugin's non-zero exit status back to main so the
+// status passes through unchanged. The plugin has already written its own
+// stderr, so nothing else is printed for it.
+type ExitError struct {
+	Name string
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("plugin %s exited with status %d", e.Name, e.Code)
+}
+
+// SearchDirs returns the plugin search path: plugins.dir from homeops.yaml
+// first, then PATH in order.
+func SearchDirs() []string {
+	var dirs []string
+	if dir := config.Get().Plugins.Dir; dir != "" {
+		if expanded, err := secrets.ExpandHome(dir); err == nil {
+			dirs = append(dirs, expanded)
+		}
+	}
+	return append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+}
+
+// Discover finds plugin executables in dirs. The first executable for a name
+// wins, as with PATH lookup; later ones are recorded as shadowed. The CLI's
+// own homeops-cli binary is never treated as a plugin.
+func Discover(dirs []string) []Plugin {
+	byName := map[string]*Plugin{}
+	seenDirs := map[string]bool{}
+	for _, dir := range dirs {
+		if dir == "" || seenDirs[dir] {
+			continue
+		}
+		seenDirs[dir] = true
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := pluginName(entry.Name())
+			if !ok {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if !isExecutable(path) {
+				continue
+			}
+			if existing, ok := byName[name]; ok {
+				existing.Shadowed = append(existing.Shadowed, path)
+				continue
+			}
+			byName[name] = &Plugin{Name: name, Path: path}
+		}
+	}
+	plugins := make([]Plugin, 0, len(byName))
+	for _, plugin := range byName {
+		plugins = append(plugins, *plugin)
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins
+}
+
+func pluginName(file string) (string, bool) {
+	if !strings.HasPrefix(file, Prefix) || file == "homeops-cli" {
+		return "", false
+	}
+	name := strings.TrimPrefix(file, Prefix)
+	if name == "" || strings.ContainsAny(name, " \t") || strings.HasPrefix(name, "-") {
+		return "", false
+	}
+	return name, true
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0o111 != 0
+}
+
+// resolve marks plugins whose name collides with a built-in command (or one
+// of its aliases) under root.
+func resolve(root *cobra.Command, plugins []Plugin) []Plugin {
+	builtins := map[string]string{}
+	for _, name := range reservedNames {
+		builtins[name] = name
+	}
+	for _, cmd := range root.Commands() {
+		if cmd.Annotations[pluginAnnotation] != "" {
+			continue
+		}
+		builtins[cmd.Name()] = cmd.Name()
+		for _, alias := range cmd.Aliases {
+			builtins[alias] = cmd.Name()
+		}
+	}
+	for i := range plugins {
+		plugins[i].Conflict = builtins[plugins[i].Name]
+	}
+	return plugins
+}
+
+// pluginAnnotation marks registered plugin commands with their executable.
+const pluginAnnotation = "homeops.plugin.path"
+
+// Register discovers plugins and adds each one as a subcommand of root. It
+// must run after the built-in commands are added so collisions are caught;
+// a colliding plugin is skipped with a warning rather than shadowing the
+// built-in.
+func Register(root *cobra.Command) {
+	for _, plugin := range resolve(root, Discover(SearchDirs())) {
+		if plugin.Conflict != "" {
+			warnFn("Ignoring plugin %s: %q is a built-in command", plugin.Path, plugin.Conflict)
+			continue
+		}
+		root.AddCommand(newPluginCommand(plugin))
+	}
+}
+
+func newPluginCommand(plugin Plugin) *cobra.Command {
+	return &cobra.Command{
+		Use:   plugin.Name,
+		Short: "Plugin: " + plugin.Path,
+		// The plugin owns its own flags and --help; leading homeops flags
+		// are picked out by splitGlobalFlags.
+		DisableFlagParsing: true,
+		Annotations:        map[string]string{pluginAnnotation: plugin.Path},
+		ValidArgsFunction: func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+			return nil, cobra.ShellCompDirectiveDefault
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			globals, rest := splitGlobalFlags(args)
+			if globals.rootDir != "" {
+				common.SetRootDirOverride(globals.rootDir)
+			}
+			if globals.configPath != "" {
+				config.SetExplicitPath(globals.configPath)
+			}
+			return run(cmd, plugin, rest, pluginEnv(globals))
+		},
+	}
+}
+
+// globalFlags are the homeops flags a plugin invocation honours.
+type globalFlags struct {
+	logLevel   string
+	configPath string
+	rootDir    string
+	assumeYes  bool
+	dryRun     bool
+}
+
+// splitGlobalFlags consumes homeops flags that precede the plugin's own
+// arguments (`homeops-cli --yes ups off` reaches here as [--yes off]) and
+// returns the rest verbatim. Parsing stops at the first other argument, and
+// a leading `--` is dropped so plugins can receive flags homeops would claim.
+func splitGlobalFlags(args []string) (globalFlags, []string) {
+	var flags globalFlags
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch name {
+		case "--":
+			return flags, args[i+1:]
+		case "--yes", "-y":
+			flags.assumeYes = !hasValue || value == "true"
+		case "--dry-run":
+			flags.dryRun = !hasValue || value == "true"
+		case "--strict-versions":
+		case "--log-level", "--config", "--root-dir":
+			if !hasValue {
+				if i+1 >= len(args) {
+					return flags, args[i:]
+				}
+				i++
+				value = args[i]
+			}
+			switch name {
+			case "--log-level":
+				flags.logLevel = value
+			case "--config":
+				flags.configPath = value
+			default:
+				flags.rootDir = value
+			}
+		default:
+			return flags, args[i:]
+		}
+	}
+	return flags, nil
+}
+
+// pluginEnv builds the plugin environment: the caller's environment plus the
+// HOMEOPS_* variables, which always override inherited values.
+func pluginEnv(flags globalFlags) []string {
+	logLevel := flags.logLevel
+	if logLevel == "" {
+		logLevel = common.GetGlobalLogLevel()
+	}
+	nonInteractive := flags.assumeYes || os.Getenv(constants.EnvHomeOpsNoInteract) == "1" || !isTerminalFn()
+	// Plugins may run outside any checkout; "" tells them there is no repo.
+	root, _ := common.RepoRoot()
+	values := map[string]string{
+		EnvKubeconfig:     resolveConfigPath(constants.EnvKubeconfig, ".kube", "config"),
+		EnvTalosconfig:    resolveConfigPath(constants.EnvTalosconfig, ".talos", "config"),
+		EnvConfig:         config.Get().Source,
+		EnvRoot:           root,
+		EnvLogLevel:       logLevel,
+		EnvNonInteractive: boolEnv(nonInteractive),
+		EnvAssumeYes:      boolEnv(flags.assumeYes),
+		EnvDryRun:         boolEnv(flags.dryRun),
+	}
+	env := make([]string, 0, len(os.Environ())+len(values))
+	for _, entry := range os.Environ() {
+		if key, _, _ := strings.Cut(entry, "="); !isPluginEnvKey(key) {
+			env = append(env, entry)
+		}
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		env = append(env, key+"="+values[key])
+	}
+	return env
+}
+
+func isPluginEnvKey(key string) bool {
+	switch key {
+	case EnvKubeconfig, EnvTalosconfig, EnvConfig, EnvRoot, EnvLogLevel, EnvNonInteractive, EnvAssumeYes, EnvDryRun:
+		return true
+	}
+	return false
+}
+
+// resolveConfigPath returns the absolute kubeconfig/talosconfig path the
+// built-in commands would use: the environment variable when set (its first
+// entry, if it is a list), else the tool's default under $HOME.
+func resolveConfigPath(envKey string, defaultParts ...string) string {
+	path := ""
+	if list := filepath.SplitList(os.Getenv(envKey)); len(list) > 0 {
+		path = list[0]
+	}
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		path = filepath.Join(append([]string{home}, defaultParts...)...)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+func boolEnv(v bool) string {
+	if v {
+		return "1"
+	}
+	return "0"
+}
+
+func run(cmd *cobra.Command, plugin Plugin, args, env []string) error {
+	process := exec.CommandContext(cmd.Context(), plugin.Path, args...) // #nosec G204 -- plugins are operator-installed executables
+	process.Env = env
+	process.Stdin = cmd.InOrStdin()
+	process.Stdout = cmd.OutOrStdout()
+	process.Stderr = cmd.ErrOrStderr()
+	if err := process.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+			return &ExitError{Name: plugin.Name, Code: exitErr.ExitCode()}
+		}
+		return fmt.Errorf("run plugin %s: %w", plugin.Name, err)
+	}
+	return nil
+}
+
+// NewCommand creates the plugins command group.
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "plugins",
+		Short: "Inspect homeops-<name> plugin executables",
+		Long: `Plugins are executables named homeops-<name> in plugins.dir (homeops.yaml)
+or on PATH. Each one appears as 'homeops-cli <name>' and receives the
+remaining arguments, inherits stdin/stdout/stderr, and exits with the
+plugin's own status. Leading homeops flags (--yes, --dry-run, --log-level,
+--config, --root-dir) are consumed and exported instead:
+
+  HOMEOPS_KUBECONFIG       resolved kubeconfig path
+  HOMEOPS_TALOSCONFIG      resolved talosconfig path
+  HOMEOPS_CONFIG           homeops.yaml in use ("" = built-in defaults)
+  HOMEOPS_ROOT             home-ops repository root ("" = none found)
+  HOMEOPS_LOG_LEVEL        debug, info, warn, or error
+  HOMEOPS_NON_INTERACTIVE  1 with --yes, HOMEOPS_NO_INTERACTIVE=1, or no TTY
+  HOMEOPS_ASSUME_YES       1 with --yes
+  HOMEOPS_DRY_RUN          1 with --dry-run
+
+A plugin whose name matches a built-in command is ignored with a warning.`,
+	}
+	cmd.AddCommand(newListCommand())
+	return cmd
+}
+
+func newListCommand() *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:          "list",
+		Short:        "List discovered plugins and their paths",
+		SilenceUsage: true,
+		Example: `  homeops-cli plugins list
+  homeops-cli plugins list --output json`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := ui.ValidateOutputFormat(output); err != nil {
+				return err
+			}
+			plugins := resolve(cmd.Root(), Discover(SearchDirs()))
+			if output == "json" {
+				rendered, err := ui.RenderJSON(plugins)
+				if err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintln(cmd.OutOrStdout(), rendered)
+				return nil
+			}
+			if len(plugins) == 0 {
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "No %s<name> plugins found in plugins.dir or PATH\n", Prefix)
+				return nil
+			}
+			var rows [][]string
+			for _, plugin := range plugins {
+				status := "OK"
+				if plugin.Conflict != "" {
+					status = fmt.Sprintf("IGNORED (built-in %q)", plugin.Conflict)
+				} else if len(plugin.Shadowed) > 0 {
+					status = "OK (shadows " + strings.Join(plugin.Shadowed, ", ") + ")"
+				}
+				rows = append(rows, []string{plugin.Name, plugin.Path, status})
+			}
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), ui.Table([]string{"NAME", "PATH", "STATUS"}, rows))
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table or json")
+	return cmd
+}
@@ -0,0 +1,188 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/config"
+	"homeops-cli/internal/testutil"
+)
+
+// writePlugin creates an executable shell script in dir.
+func writePlugin(t *testing.T, dir, file, script string) string {
+	t.Helper()
+	path := filepath.Join(dir, file)
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0o755))
+	return path
+}
+
+func testRoot(t *testing.T) *cobra.Command {
+	t.Helper()
+	root := &cobra.Command{Use: "homeops-cli"}
+	root.AddCommand(
+		&cobra.Command{Use: "k8s", Aliases: []string{"kubernetes"}, Run: func(*cobra.Command, []string) {}},
+		NewCommand(),
+	)
+	root.SetContext(context.Background())
+	return root
+}
+
+func TestDiscoverFirstMatchWinsAndSkipsNonPlugins(t *testing.T) {
+	pluginsDir, pathDir := t.TempDir(), t.TempDir()
+	ups := writePlugin(t, pluginsDir, "homeops-ups", "exit 0")
+	shadowed := writePlugin(t, pathDir, "homeops-ups", "exit 0")
+	sync := writePlugin(t, pathDir, "homeops-offsite-sync", "exit 0")
+	writePlugin(t, pathDir, "homeops-cli", "exit 0")
+	writePlugin(t, pathDir, "kubectl-foo", "exit 0")
+	require.NoError(t, os.WriteFile(filepath.Join(pathDir, "homeops-notes"), []byte("not executable"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(pathDir, "homeops-dir"), 0o755))
+
+	plugins := Discover([]string{pluginsDir, "", filepath.Join(pathDir, "missing"), pathDir, pathDir})
+	assert.Equal(t, []Plugin{
+		{Name: "offsite-sync", Path: sync},
+		{Name: "ups", Path: ups, Shadowed: []string{shadowed}},
+	}, plugins)
+}
+
+func TestSearchDirsPutsConfiguredDirBeforePath(t *testing.T) {
+	t.Cleanup(config.SetForTesting(&config.Config{Plugins: config.PluginsConfig{Dir: "/opt/homeops/plugins"}}))
+	t.Setenv("PATH", strings.Join([]string{"/usr/local/bin", "/usr/bin"}, string(os.PathListSeparator)))
+	assert.Equal(t, []string{"/opt/homeops/plugins", "/usr/local/bin", "/usr/bin"}, SearchDirs())
+}
+
+func TestRegisterRejectsBuiltinCollisions(t *testing.T) {
+	dir := t.TempDir()
+	t.Cleanup(config.SetForTesting(&config.Config{}))
+	t.Setenv("PATH", dir)
+	writePlugin(t, dir, "homeops-ups", "exit 0")
+	writePlugin(t, dir, "homeops-k8s", "exit 0")
+	writePlugin(t, dir, "homeops-kubernetes", "exit 0")
+	writePlugin(t, dir, "homeops-help", "exit 0")
+	var warnings []string
+	testutil.Swap(t, &warnFn, func(msg string, args ...interface{}) {
+		warnings = append(warnings, msg)
+	})
+
+	root := testRoot(t)
+	Register(root)
+	var names []string
+	for _, cmd := range root.Commands() {
+		names = append(names, cmd.Name())
+	}
+	assert.ElementsMatch(t, []string{"k8s", "plugins", "ups"}, names)
+	assert.Len(t, warnings, 3)
+
+	out, err := testutil.ExecuteCommand(root, "plugins", "list")
+	require.NoError(t, err)
+	assert.Contains(t, out, filepath.Join(dir, "homeops-ups"))
+	assert.Contains(t, out, `IGNORED (built-in "k8s")`)
+	assert.Contains(t, out, `IGNORED (built-in "help")`)
+	assert.NotContains(t, out, `built-in "ups"`, "registered plugins are not built-ins")
+
+	out, err = testutil.ExecuteCommand(root, "__complete", "")
+	require.NoError(t, err)
+	assert.Contains(t, out, "ups\tPlugin: ")
+}
+
+func TestPluginReceivesArgsEnvAndStdio(t *testing.T) {
+	dir := t.TempDir()
+	t.Cleanup(config.SetForTesting(&config.Config{Source: "/etc/homeops.yaml"}))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("KUBECONFIG", "/tmp/kube-a"+string(os.PathListSeparator)+"/tmp/kube-b")
+	t.Setenv("TALOSCONFIG", "")
+	t.Setenv("HOME", "/home/ops")
+	t.Setenv(EnvDryRun, "inherited")
+	t.Setenv("HOMEOPS_NO_INTERACTIVE", "")
+	testutil.Swap(t, &isTerminalFn, func() bool { return true })
+	writePlugin(t, dir, "homeops-ups", `printf 'args:'; for a in "$@"; do printf ' [%s]' "$a"; done; echo
+env | grep '^HOMEOPS_' | sort
+read line; echo "stdin:$line"
+echo "to stderr" >&2`)
+
+	repoRoot := t.TempDir()
+	t.Cleanup(func() { common.SetRootDirOverride("") })
+
+	root := testRoot(t)
+	Register(root)
+	var stdout, stderr bytes.Buffer
+	root.SetOut(&stdout)
+	root.SetErr(&stderr)
+	root.SetIn(strings.NewReader("hello\n"))
+	root.SetArgs([]string{"ups", "--yes", "--log-level", "debug", "--root-dir", repoRoot, "--dry-run", "off", "--yes", "a b", "--", "-x"})
+	require.NoError(t, root.Execute())
+
+	out := stdout.String()
+	assert.Contains(t, out, "args: [off] [--yes] [a b] [--] [-x]\n", "homeops flags are consumed only before the plugin's own args")
+	assert.Contains(t, out, "HOMEOPS_KUBECONFIG=/tmp/kube-a\n")
+	assert.Contains(t, out, "HOMEOPS_TALOSCONFIG=/home/ops/.talos/config\n")
+	assert.Contains(t, out, "HOMEOPS_CONFIG=/etc/homeops.yaml\n")
+	assert.Contains(t, out, "HOMEOPS_ROOT="+repoRoot+"\n")
+	assert.Contains(t, out, "HOMEOPS_LOG_LEVEL=debug\n")
+	assert.Contains(t, out, "HOMEOPS_ASSUME_YES=1\n")
+	assert.Contains(t, out, "HOMEOPS_NON_INTERACTIVE=1\n")
+	assert.Contains(t, out, "HOMEOPS_DRY_RUN=1\n")
+	assert.NotContains(t, out, "inherited")
+	assert.Contains(t, out, "stdin:hello\n")
+	assert.Equal(t, "to stderr\n", stderr.String())
+}
+
+func TestPluginEnvDefaults(t *testing.T) {
+	t.Cleanup(config.SetForTesting(&config.Config{}))
+	t.Setenv("KUBECONFIG", "")
+	t.Setenv("HOME", "/home/ops")
+	t.Setenv("HOMEOPS_NO_INTERACTIVE", "")
+	testutil.Swap(t, &isTerminalFn, func() bool { return true })
+
+	env := strings.Join(pluginEnv(globalFlags{}), "\n") + "\n"
+	assert.Contains(t, env, "HOMEOPS_KUBECONFIG=/home/ops/.kube/config\n")
+	assert.Contains(t, env, "HOMEOPS_CONFIG=\n")
+	assert.Contains(t, env, "HOMEOPS_NON_INTERACTIVE=0\n")
+	assert.Contains(t, env, "HOMEOPS_DRY_RUN=0\n")
+
+	t.Setenv("HOMEOPS_NO_INTERACTIVE", "1")
+	assert.Contains(t, strings.Join(pluginEnv(globalFlags{}), "\n"), "HOMEOPS_NON_INTERACTIVE=1")
+}
+
+func TestPluginExitCodePassesThrough(t *testing.T) {
+	dir := t.TempDir()
+	t.Cleanup(config.SetForTesting(&config.Config{}))
+	t.Setenv("PATH", dir)
+	writePlugin(t, dir, "homeops-ups", "exit 42")
+
+	root := testRoot(t)
+	Register(root)
+	root.SetArgs([]string{"ups"})
+	root.SetOut(&bytes.Buffer{})
+	root.SetErr(&bytes.Buffer{})
+	err := root.Execute()
+	var exitErr *ExitError
+	require.True(t, errors.As(err, &exitErr), "got %v", err)
+	assert.Equal(t, 42, exitErr.Code)
+	assert.Equal(t, "ups", exitErr.Name)
+}
+
+func TestSplitGlobalFlags(t *testing.T) {
+	flags, rest := splitGlobalFlags([]string{"--config=/tmp/h.yaml", "-y", "--strict-versions", "--root-dir", "/src/home-ops", "status"})
+	assert.Equal(t, globalFlags{configPath: "/tmp/h.yaml", rootDir: "/src/home-ops", assumeYes: true}, flags)
+	assert.Equal(t, []string{"status"}, rest)
+
+	flags, rest = splitGlobalFlags([]string{"--verbose", "--yes"})
+	assert.Equal(t, globalFlags{}, flags)
+	assert.Equal(t, []string{"--verbose", "--yes"}, rest, "unknown leading flags belong to the plugin")
+
+	_, rest = splitGlobalFlags([]string{"--", "--dry-run"})
+	assert.Equal(t, []string{"--dry-run"}, rest)
+
+	_, rest = splitGlobalFlags([]string{"--log-level"})
+	assert.Equal(t, []string{"--log-level"}, rest)
+}
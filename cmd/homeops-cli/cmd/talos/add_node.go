@@ -0,0 +1,238 @@
+package talos
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"homeops-cli/cmd/completion"
+	"homeops-cli/internal/common"
+	versionconfig "homeops-cli/internal/config"
+)
+
+// nodeTemplatesSourceDir is where the embedded talos/nodes/<ip>.yaml
+// templates live, relative to the repository root.
+const nodeTemplatesSourceDir = "cmd/homeops-cli/internal/templates/talos/nodes"
+
+// nodeTemplateSkeleton is a new node's patch. The MAC, IPv6 addresses, NTP
+// servers and discovery endpoint come from homeops.yaml at render time.
+const nodeTemplateSkeleton = `---
+machine:
+  network:
+    interfaces:
+      - deviceSelector:
+          hardwareAddr: {{ ENV.TALOS_NODE_MAC }}
+        dhcp: true
+        mtu: 9000
+{{ ENV.TALOS_NODE_ADDRESSES }}
+  time:
+    servers:
+{{ ENV.TALOS_NTP_SERVERS }}
+cluster:
+  discovery:
+    enabled: true
+    registries:
+      kubernetes:
+        disabled: true
+      service:
+        disabled: false
+        endpoint: {{ ENV.TALOS_DISCOVERY_ENDPOINT }}
+---
+apiVersion: v1alpha1
+kind: HostnameConfig
+hostname: {{ ENV.TALOS_NODE_HOSTNAME }}
+---
+apiVersion: v1alpha1
+kind: WatchdogTimerConfig
+device: /dev/watchdog0
+timeout: 5m
+---
+apiVersion: v1alpha1
+kind: UserVolumeConfig
+name: local-hostpath
+provisioning:
+  diskSelector:
+    # Select the configured device explicitly for local-hostpath storage.
+    match: disk.dev_path == "{{ ENV.TALOS_USER_VOLUME_DISK }}"
+  minSize: {{ ENV.TALOS_USER_VOLUME_MIN_SIZE }}
+  maxSize: {{ ENV.TALOS_USER_VOLUME_MAX_SIZE }}
+`
+
+var (
+	nodeHostnameLine     = regexp.MustCompile(`(?m)^hostname: .*$`)
+	nodeHardwareAddr     = regexp.MustCompile(`(?m)^(\s+hardwareAddr: ).*$`)
+	nodeInstallDiskLine  = regexp.MustCompile(`(?m)^(  install:\n(?:    .*\n)*?    disk: ).*$`)
+	nodeInstallImageLine = regexp.MustCompile(`(?m)^(  install:\n(?:    .*\n)*?    image: ).*$`)
+	nodeInstallBlock     = regexp.MustCompile(`(?m)^  install:$`)
+	nodeMachineLine      = regexp.MustCompile(`(?m)^machine:$`)
+	nodeVIPLine          = regexp.MustCompile(`(?m)^(        vip:\n          ip: ).*$`)
+	nodeInterfaceDHCP    = regexp.MustCompile(`(?m)^        dhcp: `)
+	unrenderedReference  = regexp.MustCompile(`\{\{ (ENV|SETTINGS)\.[A-Z0-9_]+ \}\}`)
+)
+
+var renderMachineConfigWithPatchContentFn = renderMachineConfigWithPatchContent
+
+type addNodeOptions struct {
+	ip       string
+	hostname string
+	mac      string
+	disk     string
+	image    string
+	vip      string
+	from     string
+	force    bool
+}
+
+func newAddNodeCommand() *cobra.Command {
+	var opts addNodeOptions
+
+	cmd := &cobra.Command{
+		Use:   "add-node",
+		Short: "Scaffold a talos/nodes/<ip>.yaml template for a new node",
+		Long: `Writes a new node patch into the templates source tree
+(cmd/homeops-cli/internal/templates/talos/nodes/<ip>.yaml), then checks that it
+renders and merges against its machine type's base template before keeping it.
+Rebuild the CLI to embed it.
+
+The patch starts from a skeleton, or with --from from an existing node's
+template. --hostname is written into it; --mac, --disk (the install disk),
+--image (the installer image, e.g. a schematic's factory.talos.dev/installer
+URL) and --vip (a shared control plane address on the node's interface) are
+optional.
+Without --mac the MAC comes from the node's cluster.nodes entry in homeops.yaml.`,
+		Example: `  homeops-cli talos add-node --ip 192.168.122.13 --hostname k8s-3 --mac 00:a0:98:00:00:04
+  homeops-cli talos add-node --ip 192.168.122.13 --hostname k8s-3 --from 192.168.122.10 --disk /dev/sdb`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return addNode(opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.ip, "ip", "", "IP address of the new node (the template's file name)")
+	cmd.Flags().StringVar(&opts.hostname, "hostname", "", "Hostname of the new node")
+	cmd.Flags().StringVar(&opts.mac, "mac", "", "MAC address selecting the node's interface (default: cluster.nodes[].vm.mac)")
+	cmd.Flags().StringVar(&opts.disk, "disk", "", "Install disk for this node, overriding the base template's")
+	cmd.Flags().StringVar(&opts.image, "image", "", "Installer image for this node, overriding the base template's")
+	cmd.Flags().StringVar(&opts.vip, "vip", "", "Shared VIP to announce on the node's interface")
+	cmd.Flags().StringVar(&opts.from, "from", "", "Clone the template of this existing node IP instead of the skeleton")
+	cmd.Flags().BoolVar(&opts.force, "force", false, "Overwrite an existing template for --ip")
+	_ = cmd.RegisterFlagCompletionFunc("from", completion.ValidNodeIPs)
+	_ = cmd.MarkFlagRequired("ip")
+	_ = cmd.MarkFlagRequired("hostname")
+
+	return cmd
+}
+
+func addNode(opts addNodeOptions) error {
+	logger := common.NewColorLogger()
+
+	for _, ip := range []struct{ flag, value string }{{"--ip", opts.ip}, {"--vip", opts.vip}, {"--from", opts.from}} {
+		if ip.value != "" && net.ParseIP(ip.value) == nil {
+			return fmt.Errorf("%s %q is not a valid IP address", ip.flag, ip.value)
+		}
+	}
+	if opts.mac != "" {
+		if _, err := net.ParseMAC(opts.mac); err != nil {
+			return fmt.Errorf("--mac %q is not a valid MAC address", opts.mac)
+		}
+	}
+
+	root, err := repoRootFn()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(root, nodeTemplatesSourceDir, opts.ip+".yaml")
+	if _, err := os.Stat(path); err == nil && !opts.force {
+		return fmt.Errorf("%s already exists — pass --force to overwrite", path)
+	}
+
+	content := nodeTemplateSkeleton
+	if opts.from != "" {
+		if content, err = getTalosTemplateFn(fmt.Sprintf("talos/nodes/%s.yaml", opts.from)); err != nil {
+			return fmt.Errorf("--from %s: %w", opts.from, err)
+		}
+	}
+	content, err = patchNodeTemplate(content, opts)
+	if err != nil {
+		return err
+	}
+
+	if strings.Contains(content, "{{ ENV.TALOS_NODE_MAC }}") {
+		if node, ok := versionconfig.Get().NodeByIP(opts.ip); !ok || node.VM.ForProvider("talos").Mac == "" {
+			return fmt.Errorf("no MAC for %s: pass --mac, or add it with vm.mac to cluster.nodes in homeops.yaml", opts.ip)
+		}
+	}
+
+	machineType := nodeTemplateMachineType(content)
+	patchTemplate := fmt.Sprintf("talos/nodes/%s.yaml", opts.ip)
+	rendered, err := renderMachineConfigWithPatchContentFn(fmt.Sprintf("talos/%s.yaml", machineType), patchTemplate, content)
+	if err != nil {
+		return fmt.Errorf("new template does not render against talos/%s.yaml: %w", machineType, err)
+	}
+	if leftover := unrenderedReference.FindString(string(rendered)); leftover != "" {
+		return fmt.Errorf("new template leaves %s unrendered", leftover)
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil { // #nosec G306 -- templates are committed source files
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	logger.Success("Wrote %s (%s)", path, machineType)
+	logger.Info("Rebuild homeops-cli to embed it, and add %s (%s) to cluster.nodes in homeops.yaml", opts.hostname, opts.ip)
+	return nil
+}
+
+// patchNodeTemplate writes the add-node flags into a node patch. It edits
+// lines rather than YAML because templates hold {{ ENV.* }} placeholders.
+func patchNodeTemplate(content string, opts addNodeOptions) (string, error) {
+	if !nodeHostnameLine.MatchString(content) {
+		return "", fmt.Errorf("template has no HostnameConfig hostname line to set")
+	}
+	content = nodeHostnameLine.ReplaceAllLiteralString(content, "hostname: "+opts.hostname)
+
+	if opts.mac != "" {
+		if !nodeHardwareAddr.MatchString(content) {
+			return "", fmt.Errorf("--mac: template has no interface hardwareAddr selector")
+		}
+		content = nodeHardwareAddr.ReplaceAllString(content, "${1}"+opts.mac)
+	}
+
+	for _, field := range []struct {
+		name    string
+		pattern *regexp.Regexp
+		value   string
+	}{{"disk", nodeInstallDiskLine, opts.disk}, {"image", nodeInstallImageLine, opts.image}} {
+		if field.value == "" {
+			continue
+		}
+		line := "    " + field.name + ": " + field.value
+		switch {
+		case field.pattern.MatchString(content):
+			content = field.pattern.ReplaceAllString(content, "${1}"+field.value)
+		case nodeInstallBlock.MatchString(content):
+			content = nodeInstallBlock.ReplaceAllLiteralString(content, "  install:\n"+line)
+		case nodeMachineLine.MatchString(content):
+			loc := nodeMachineLine.FindStringIndex(content)
+			content = content[:loc[1]] + "\n  install:\n" + line + content[loc[1]:]
+		default:
+			return "", fmt.Errorf("--%s: template has no machine document", field.name)
+		}
+	}
+
+	if opts.vip != "" {
+		switch {
+		case nodeVIPLine.MatchString(content):
+			content = nodeVIPLine.ReplaceAllString(content, "${1}"+opts.vip)
+		case nodeInterfaceDHCP.MatchString(content):
+			loc := nodeInterfaceDHCP.FindStringIndex(content)
+			content = content[:loc[0]] + "        vip:\n          ip: " + opts.vip + "\n" + content[loc[0]:]
+		default:
+			return "", fmt.Errorf("--vip: template has no network interface to announce it on")
+		}
+	}
+	return content, nil
+}
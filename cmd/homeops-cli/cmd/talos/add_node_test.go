@@ -0,0 +1,83 @@
+package talos
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/testutil"
+)
+
+func stubAddNodeRoot(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, nodeTemplatesSourceDir), 0o755))
+	testutil.Swap(t, &repoRootFn, func() (string, error) { return root, nil })
+	return filepath.Join(root, nodeTemplatesSourceDir)
+}
+
+func TestAddNodeWritesRenderableSkeleton(t *testing.T) {
+	dir := stubAddNodeRoot(t)
+
+	_, err := testutil.ExecuteCommand(newAddNodeCommand(), "--ip", "192.168.122.13", "--hostname", "k8s-3",
+		"--mac", "00:a0:98:00:00:04", "--disk", "/dev/sdb", "--vip", "192.168.122.5")
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, "192.168.122.13.yaml"))
+	require.NoError(t, err)
+	content := string(data)
+	assert.Contains(t, content, "machine:\n  install:\n    disk: /dev/sdb\n  network:")
+	assert.Contains(t, content, "hardwareAddr: 00:a0:98:00:00:04\n        vip:\n          ip: 192.168.122.5\n        dhcp: true")
+	assert.Contains(t, content, "hostname: k8s-3\n")
+
+	rendered, err := renderMachineConfigWithPatchContent("talos/controlplane.yaml", "talos/nodes/192.168.122.13.yaml", content)
+	require.NoError(t, err)
+	assert.Contains(t, string(rendered), "disk: /dev/sdb")
+	assert.NotRegexp(t, unrenderedReference, string(rendered))
+
+	_, err = testutil.ExecuteCommand(newAddNodeCommand(), "--ip", "192.168.122.13", "--hostname", "k8s-3", "--mac", "00:a0:98:00:00:04")
+	assert.ErrorContains(t, err, "already exists")
+}
+
+func TestAddNodeFromExistingNode(t *testing.T) {
+	dir := stubAddNodeRoot(t)
+
+	_, err := testutil.ExecuteCommand(newAddNodeCommand(), "--ip", "192.168.122.13", "--hostname", "k8s-3",
+		"--from", "192.168.122.10", "--mac", "00:a0:98:00:00:04", "--disk", "/dev/nvme1n1")
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, "192.168.122.13.yaml"))
+	require.NoError(t, err)
+	source, err := getTalosTemplateFn("talos/nodes/192.168.122.10.yaml")
+	require.NoError(t, err)
+	want, err := patchNodeTemplate(source, addNodeOptions{hostname: "k8s-3", mac: "00:a0:98:00:00:04", disk: "/dev/nvme1n1"})
+	require.NoError(t, err)
+	assert.Equal(t, want, string(data))
+}
+
+func TestAddNodeRejectsUnrenderableTemplate(t *testing.T) {
+	dir := stubAddNodeRoot(t)
+
+	_, err := testutil.ExecuteCommand(newAddNodeCommand(), "--ip", "192.168.122.13", "--hostname", "k8s-3")
+	assert.EqualError(t, err, "no MAC for 192.168.122.13: pass --mac, or add it with vm.mac to cluster.nodes in homeops.yaml")
+	assert.NoFileExists(t, filepath.Join(dir, "192.168.122.13.yaml"))
+
+	_, err = testutil.ExecuteCommand(newAddNodeCommand(), "--ip", "not-an-ip", "--hostname", "k8s-3")
+	assert.EqualError(t, err, `--ip "not-an-ip" is not a valid IP address`)
+}
+
+func TestPatchNodeTemplateReplacesExistingStanzas(t *testing.T) {
+	content := "machine:\n  install:\n    wipe: false\n    disk: /dev/sda\n  network:\n    interfaces:\n      - deviceSelector:\n          hardwareAddr: aa:bb:cc:dd:ee:ff\n        vip:\n          ip: 10.0.0.5\n        dhcp: true\n---\nhostname: old\n"
+	patched, err := patchNodeTemplate(content, addNodeOptions{hostname: "new", disk: "/dev/sdb", vip: "10.0.0.6"})
+	require.NoError(t, err)
+	assert.Equal(t, "machine:\n  install:\n    wipe: false\n    disk: /dev/sdb\n  network:\n    interfaces:\n      - deviceSelector:\n          hardwareAddr: aa:bb:cc:dd:ee:ff\n        vip:\n          ip: 10.0.0.6\n        dhcp: true\n---\nhostname: new\n", patched)
+}
+
+func TestPatchNodeTemplateSetsInstallImage(t *testing.T) {
+	patched, err := patchNodeTemplate("machine:\n  install:\n    disk: /dev/sda\n    image: old:v1\n---\nhostname: old\n", addNodeOptions{hostname: "new", image: "factory.talos.dev/installer/abc:{{ ENV.TALOS_VERSION }}"})
+	require.NoError(t, err)
+	assert.Equal(t, "machine:\n  install:\n    disk: /dev/sda\n    image: factory.talos.dev/installer/abc:{{ ENV.TALOS_VERSION }}\n---\nhostname: new\n", patched)
+}
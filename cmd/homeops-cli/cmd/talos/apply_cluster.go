@@ -0,0 +1,186 @@
+package talos
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/ui"
+)
+
+// applyClusterModes are the talosctl apply-config modes apply-cluster rolls
+// out with; interactive and reboot make no sense across every node at once.
+var applyClusterModes = []string{"auto", "no-reboot", "staged"}
+
+// applyClusterBoundary separates the nodes' rendered configs so their secrets
+// resolve in one op inject call. It is a YAML comment, which op passes through.
+const applyClusterBoundary = "\n# homeops-cli apply-cluster: next node\n"
+
+// Per-node results in the apply-cluster summary.
+const (
+	applyClusterApplied = "applied"
+	applyClusterDryRun  = "dry-run"
+	applyClusterFailed  = "failed"
+)
+
+var applyClusterNodesFn = getAllNodes
+
+// applyClusterResult is one node's row in the apply-cluster summary.
+type applyClusterResult struct {
+	node        string
+	machineType string
+	result      string
+	detail      string
+	config      string
+}
+
+func newApplyClusterCommand() *cobra.Command {
+	var (
+		mode   string
+		dryRun bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "apply-cluster",
+		Short: "Apply Talos config to every node",
+		Long: `Applies each talosconfig node's configuration, rendered from the embedded
+templates as apply-node renders it, so a change to a shared patch reaches every
+node with one command. 1Password references are resolved once for all nodes.
+
+--dry-run prints talosctl's diff for each node instead of applying. A node that
+fails to render or apply does not stop the others; the summary lists every
+node's result and the command exits non-zero when any failed.`,
+		Example: `  homeops-cli talos apply-cluster --mode staged
+  homeops-cli talos apply-cluster --dry-run`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !slices.Contains(applyClusterModes, mode) {
+				return fmt.Errorf("invalid --mode %q (want %s)", mode, strings.Join(applyClusterModes, ", "))
+			}
+			return applyClusterConfig(cmd.OutOrStdout(), mode, dryRun)
+		},
+	}
+
+	cmd.Flags().StringVar(&mode, "mode", "auto", "Apply mode: "+strings.Join(applyClusterModes, ", "))
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show each node's config diff instead of applying")
+
+	return cmd
+}
+
+func applyClusterConfig(out io.Writer, mode string, dryRun bool) error {
+	logger := common.NewColorLogger()
+
+	nodes, err := applyClusterNodesFn()
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("no nodes found in talosconfig")
+	}
+
+	results := make([]*applyClusterResult, 0, len(nodes))
+	var rendered []*applyClusterResult
+	for _, nodeIP := range nodes {
+		result := renderApplyClusterNode(nodeIP)
+		results = append(results, result)
+		if result.result != applyClusterFailed {
+			rendered = append(rendered, result)
+		}
+	}
+
+	if len(rendered) > 0 {
+		if err := resolveApplyClusterSecrets(logger, rendered); err != nil {
+			return err
+		}
+	}
+
+	for _, result := range rendered {
+		if dryRun {
+			logger.Info("[DRY RUN] Diffing config for %s (type: %s)", result.node, result.machineType)
+			output, err := talosDryRunApplyConfigFn(result.node, mode, result.config)
+			output = []byte(common.RedactCommandOutput(string(output)))
+			if _, werr := fmt.Fprintf(out, "=== %s ===\n%s\n", result.node, strings.TrimSpace(string(output))); werr != nil {
+				return werr
+			}
+			if err != nil {
+				result.result, result.detail = applyClusterFailed, lastLine(string(output), err)
+				continue
+			}
+			result.result, result.detail = applyClusterDryRun, "diff shown above"
+			continue
+		}
+		logger.Info("Applying configuration to node %s (type: %s, mode: %s)", result.node, result.machineType, mode)
+		output, err := talosApplyConfigFn(result.node, mode, result.config)
+		if err != nil {
+			result.result, result.detail = applyClusterFailed, lastLine(string(output), err)
+			continue
+		}
+		result.result, result.detail = applyClusterApplied, "mode "+mode
+	}
+
+	rows := make([][]string, 0, len(results))
+	var failed []string
+	for _, result := range results {
+		rows = append(rows, []string{result.node, result.machineType, result.result, result.detail})
+		if result.result == applyClusterFailed {
+			failed = append(failed, result.node)
+		}
+	}
+	if _, err := fmt.Fprintln(out, ui.Table([]string{"NODE", "TYPE", "RESULT", "DETAIL"}, rows)); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("apply-cluster failed on %d of %d nodes: %s", len(failed), len(results), strings.Join(failed, ", "))
+	}
+	if dryRun {
+		logger.Info("[DRY RUN] Diffed the config of all %d nodes; nothing was applied", len(results))
+		return nil
+	}
+	logger.Success("Configuration applied to all %d nodes", len(results))
+	return nil
+}
+
+// renderApplyClusterNode renders one node's config as apply-node does. A
+// failure is recorded on the result rather than returned.
+func renderApplyClusterNode(nodeIP string) *applyClusterResult {
+	result := &applyClusterResult{node: nodeIP}
+	machineType, err := getMachineTypeFromNodeFn(nodeIP)
+	if err != nil {
+		result.result, result.detail = applyClusterFailed, fmt.Sprintf("failed to get machine type: %v", err)
+		return result
+	}
+	result.machineType = machineType
+	config, err := renderMachineConfigFromEmbeddedFn(fmt.Sprintf("talos/%s.yaml", machineType), fmt.Sprintf("talos/nodes/%s.yaml", nodeIP))
+	if err != nil {
+		result.result, result.detail = applyClusterFailed, fmt.Sprintf("failed to render config: %v", err)
+		return result
+	}
+	result.config = string(config)
+	return result
+}
+
+// resolveApplyClusterSecrets resolves every rendered config's 1Password
+// references in a single inject, then splits the result back per node.
+func resolveApplyClusterSecrets(logger *common.ColorLogger, results []*applyClusterResult) error {
+	configs := make([]string, 0, len(results))
+	for _, result := range results {
+		configs = append(configs, result.config)
+	}
+	logger.Info("Resolving 1Password references for %d node configs...", len(results))
+	resolved, err := injectSecretsWithSignin(logger, strings.Join(configs, applyClusterBoundary))
+	if err != nil {
+		return err
+	}
+	parts := strings.Split(resolved, applyClusterBoundary)
+	if len(parts) != len(results) {
+		return fmt.Errorf("resolved configs do not split back into %d nodes (got %d)", len(results), len(parts))
+	}
+	for i, result := range results {
+		result.config = parts[i]
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package talos
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/testutil"
+)
+
+func stubApplyCluster(t *testing.T) *int {
+	t.Helper()
+	testutil.Swap(t, &applyClusterNodesFn, func() ([]string, error) {
+		return []string{"10.0.0.10", "10.0.0.11", "10.0.0.12"}, nil
+	})
+	testutil.Swap(t, &getMachineTypeFromNodeFn, func(string) (string, error) { return "controlplane", nil })
+	testutil.Swap(t, &renderMachineConfigFromEmbeddedFn, func(base, patch string) ([]byte, error) {
+		assert.Equal(t, "talos/controlplane.yaml", base)
+		return []byte("machine:\n  token: op://talos/token\n  # " + patch + "\n"), nil
+	})
+	injects := 0
+	testutil.Swap(t, &injectSecretsFn, func(rendered string) (string, error) {
+		injects++
+		return strings.ReplaceAll(rendered, "op://talos/token", "resolved"), nil
+	})
+	return &injects
+}
+
+func TestApplyClusterStagesEveryNode(t *testing.T) {
+	injects := stubApplyCluster(t)
+	var applied []string
+	testutil.Swap(t, &talosApplyConfigFn, func(nodeIP, mode, config string) ([]byte, error) {
+		assert.Equal(t, "staged", mode)
+		assert.Equal(t, "machine:\n  token: resolved\n  # talos/nodes/"+nodeIP+".yaml\n", config, "each node gets its own resolved config")
+		applied = append(applied, nodeIP)
+		return nil, nil
+	})
+
+	out, err := testutil.ExecuteCommand(newApplyClusterCommand(), "--mode", "staged")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.10", "10.0.0.11", "10.0.0.12"}, applied)
+	assert.Equal(t, 1, *injects, "secrets are resolved once for all nodes")
+	assert.Equal(t, 3, strings.Count(out, "applied"))
+
+	_, err = testutil.ExecuteCommand(newApplyClusterCommand(), "--mode", "interactive")
+	assert.EqualError(t, err, `invalid --mode "interactive" (want auto, no-reboot, staged)`)
+}
+
+func TestApplyClusterDryRunAndFailures(t *testing.T) {
+	stubApplyCluster(t)
+	testutil.Swap(t, &talosApplyConfigFn, func(string, string, string) ([]byte, error) {
+		t.Fatal("--dry-run must not apply")
+		return nil, nil
+	})
+	testutil.Swap(t, &talosDryRunApplyConfigFn, func(nodeIP, mode, _ string) ([]byte, error) {
+		assert.Equal(t, "auto", mode)
+		if nodeIP == "10.0.0.12" {
+			return []byte("connection refused"), fmt.Errorf("exit status 1")
+		}
+		return []byte("Dry run summary:\nConfig diff:\n+  token: resolved"), nil
+	})
+	testutil.Swap(t, &getMachineTypeFromNodeFn, func(nodeIP string) (string, error) {
+		if nodeIP == "10.0.0.11" {
+			return "", fmt.Errorf("node unreachable")
+		}
+		return "controlplane", nil
+	})
+
+	out, err := testutil.ExecuteCommand(newApplyClusterCommand(), "--dry-run")
+	require.EqualError(t, err, "apply-cluster failed on 2 of 3 nodes: 10.0.0.11, 10.0.0.12")
+	assert.Contains(t, out, "=== 10.0.0.10 ===\nDry run summary:")
+	assert.Contains(t, out, "failed to get machine type: node unreachable")
+	assert.Contains(t, out, "connection refused")
+	assert.Equal(t, 1, strings.Count(out, "diff shown above"))
+}
@@ -0,0 +1,502 @@
+package talos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"homeops-cli/internal/common"
+	versionconfig "homeops-cli/internal/config"
+	"homeops-cli/internal/ui"
+)
+
+// IP check statuses.
+const (
+	ipCheckPass = "PASS"
+	ipCheckWarn = "WARN"
+	ipCheckFail = "FAIL"
+)
+
+const defaultIPCheckTimeout = time.Second
+
+// ipCheckPorts are the TCP ports a squatter on a home VLAN most likely
+// answers on: SSH, HTTP(S), the Kubernetes API, and Talos apid.
+var ipCheckPorts = []int{22, 80, 443, 6443, 50000}
+
+var (
+	ipCheckPingFn = func(ctx context.Context, ip string) bool {
+		waitFlag := "1"
+		if runtime.GOOS == "darwin" {
+			waitFlag = "1000"
+		}
+		_, err := common.RunCommandWithContextOutput(ctx, "ping", "-c", "1", "-W", waitFlag, ip)
+		return err == nil
+	}
+	ipCheckDialFn = func(ctx context.Context, address string) error {
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	// ipCheckNeighborFn returns the MAC the local ARP table holds for ip, or
+	// "" when there is no complete entry. Ping and dial attempts populate the
+	// table, so it catches hosts that firewall both.
+	ipCheckNeighborFn = lookupARPNeighbor
+	ipCheckLookupHost = func(ctx context.Context, host string) ([]string, error) {
+		return net.DefaultResolver.LookupHost(ctx, host)
+	}
+	ipCheckLookupAddr  = func(ctx context.Context, ip string) ([]string, error) { return net.DefaultResolver.LookupAddr(ctx, ip) }
+	ipCheckNTPQueryFn  = querySNTP
+	ipCheckReadFileFn  = os.ReadFile
+	ipCheckArpOutputFn = func(ctx context.Context, ip string) ([]byte, error) {
+		return common.RunCommandWithContextOutput(ctx, "arp", "-n", ip)
+	}
+)
+
+type ipCheckOptions struct {
+	IP             string
+	Hostname       string
+	ExpectExisting bool
+	Timeout        time.Duration
+}
+
+type ipCheckResult struct {
+	Check  string `json:"check"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	Hint   string `json:"hint,omitempty"`
+}
+
+type ipCheckReport struct {
+	IP       string          `json:"ip"`
+	Hostname string          `json:"hostname,omitempty"`
+	Pass     int             `json:"pass"`
+	Warn     int             `json:"warn"`
+	Fail     int             `json:"fail"`
+	Checks   []ipCheckResult `json:"checks"`
+}
+
+func newCheckIPCommand() *cobra.Command {
+	var (
+		options ipCheckOptions
+		output  string
+	)
+	cmd := &cobra.Command{
+		Use:   "check-ip",
+		Short: "Check that a planned node IP is free, in DNS, and outside the DHCP pool",
+		Long: `Runs pre-deployment sanity checks for a planned node IP:
+
+  in-use       nothing answers ping, TCP 22/80/443/6443/50000, or holds an ARP entry
+  forward-dns  --hostname resolves to the IP (absent is a warning with the record to create)
+  reverse-dns  the IP's PTR record names --hostname
+  dhcp-pool    the IP is outside cluster.dhcp_pool in homeops.yaml
+  ntp          cluster.ntp_servers answer SNTP from this workstation
+
+Pass --expect-existing when the IP belongs to the node being replaced, so a
+responding address is expected rather than a conflict. Exits non-zero when any
+check FAILs. deploy-vm and flatcar deploy-vm run the same checks for node
+names listed in cluster.nodes, and accept --expect-existing too.`,
+		Example: `  homeops-cli talos check-ip --ip 192.168.122.13 --hostname k8s-3
+  homeops-cli talos check-ip --ip 192.168.122.10 --hostname k8s-0 --expect-existing
+  homeops-cli talos check-ip --ip 192.168.122.13 --output json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ui.ValidateOutputFormat(output); err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			report, err := runIPChecks(ctx, options, versionconfig.Get())
+			if err != nil {
+				return err
+			}
+			rendered, err := renderIPCheckReport(report, output)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintln(cmd.OutOrStdout(), rendered); err != nil {
+				return err
+			}
+			if report.Fail > 0 {
+				return fmt.Errorf("%d IP check(s) failed for %s", report.Fail, report.IP)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&options.IP, "ip", "", "planned node IPv4 address (required)")
+	cmd.Flags().StringVar(&options.Hostname, "hostname", "", "intended node hostname for DNS checks")
+	cmd.Flags().BoolVar(&options.ExpectExisting, "expect-existing", false, "the IP belongs to the node being replaced and may respond")
+	cmd.Flags().DurationVar(&options.Timeout, "timeout", defaultIPCheckTimeout, "timeout for each network probe")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table or json")
+	_ = cmd.MarkFlagRequired("ip")
+	return cmd
+}
+
+func runIPChecks(ctx context.Context, options ipCheckOptions, cfg *versionconfig.Config) (ipCheckReport, error) {
+	ip, err := netip.ParseAddr(strings.TrimSpace(options.IP))
+	if err != nil || !ip.Is4() {
+		return ipCheckReport{}, fmt.Errorf("--ip %q is not an IPv4 address", options.IP)
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = defaultIPCheckTimeout
+	}
+	hostname := strings.TrimSuffix(strings.TrimSpace(options.Hostname), ".")
+	report := ipCheckReport{IP: ip.String(), Hostname: hostname}
+	report.Checks = []ipCheckResult{
+		checkIPInUse(ctx, ip.String(), options.ExpectExisting, options.Timeout),
+		checkForwardDNS(ctx, ip.String(), hostname, options.Timeout),
+		checkReverseDNS(ctx, ip, hostname, options.Timeout),
+		checkDHCPPool(ip, cfg.Cluster.DHCPPool),
+		checkNTPServers(ctx, cfg.Cluster.NTPServers, options.Timeout),
+	}
+	for _, check := range report.Checks {
+		switch check.Status {
+		case ipCheckPass:
+			report.Pass++
+		case ipCheckWarn:
+			report.Warn++
+		default:
+			report.Fail++
+		}
+	}
+	return report, nil
+}
+
+// checkIPInUse probes ping and the common ports concurrently, then reads the
+// ARP table the probes just populated.
+func checkIPInUse(ctx context.Context, ip string, expectExisting bool, timeout time.Duration) ipCheckResult {
+	var (
+		mu        sync.Mutex
+		responses []string
+		wg        sync.WaitGroup
+	)
+	record := func(response string) {
+		mu.Lock()
+		responses = append(responses, response)
+		mu.Unlock()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		probeCtx, cancel := context.WithTimeout(ctx, timeout+time.Second)
+		defer cancel()
+		if ipCheckPingFn(probeCtx, ip) {
+			record("ping")
+		}
+	}()
+	for _, port := range ipCheckPorts {
+		wg.Add(1)
+		go func(port int) {
+			defer wg.Done()
+			probeCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			if ipCheckDialFn(probeCtx, net.JoinHostPort(ip, strconv.Itoa(port))) == nil {
+				record(fmt.Sprintf("tcp/%d", port))
+			}
+		}(port)
+	}
+	wg.Wait()
+	sort.Strings(responses)
+
+	arpCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	mac, _ := ipCheckNeighborFn(arpCtx, ip)
+	if mac != "" {
+		responses = append(responses, "arp "+mac)
+	}
+
+	result := ipCheckResult{Check: "in-use"}
+	switch {
+	case len(responses) > 0 && expectExisting:
+		result.Status, result.Detail = ipCheckPass, fmt.Sprintf("answers %s (expected: node being replaced)", strings.Join(responses, ", "))
+	case len(responses) > 0:
+		result.Status, result.Detail = ipCheckFail, fmt.Sprintf("answers %s", strings.Join(responses, ", "))
+		result.Hint = "something already holds this address; pick another IP, or pass --expect-existing if it is the node being replaced"
+	case expectExisting:
+		result.Status, result.Detail = ipCheckWarn, "nothing answers"
+		result.Hint = "--expect-existing was set but the node being replaced is not reachable; confirm it is really gone"
+	default:
+		result.Status, result.Detail = ipCheckPass, fmt.Sprintf("no response to ping, tcp/%s, or ARP", joinPorts(ipCheckPorts))
+	}
+	return result
+}
+
+func joinPorts(ports []int) string {
+	parts := make([]string, len(ports))
+	for i, port := range ports {
+		parts[i] = strconv.Itoa(port)
+	}
+	return strings.Join(parts, ",")
+}
+
+func checkForwardDNS(ctx context.Context, ip, hostname string, timeout time.Duration) ipCheckResult {
+	result := ipCheckResult{Check: "forward-dns"}
+	if hostname == "" {
+		result.Status, result.Detail = ipCheckWarn, "no --hostname given; skipped"
+		result.Hint = "pass --hostname to verify the A record"
+		return result
+	}
+	lookupCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	addresses, err := ipCheckLookupHost(lookupCtx, hostname)
+	var dnsErr *net.DNSError
+	switch {
+	case err != nil && errors.As(err, &dnsErr) && dnsErr.IsNotFound, err == nil && len(addresses) == 0:
+		result.Status, result.Detail = ipCheckWarn, hostname+" does not resolve"
+		result.Hint = fmt.Sprintf("create the record: %s. IN A %s", hostname, ip)
+	case err != nil:
+		result.Status, result.Detail = ipCheckWarn, fmt.Sprintf("lookup %s: %v", hostname, err)
+		result.Hint = "check the workstation resolver, then re-run"
+	case containsString(addresses, ip):
+		result.Status, result.Detail = ipCheckPass, fmt.Sprintf("%s resolves to %s", hostname, strings.Join(addresses, ", "))
+	default:
+		result.Status, result.Detail = ipCheckFail, fmt.Sprintf("%s resolves to %s, not %s", hostname, strings.Join(addresses, ", "), ip)
+		result.Hint = fmt.Sprintf("update the A record for %s to %s, or pick the IP it already points at", hostname, ip)
+	}
+	return result
+}
+
+func checkReverseDNS(ctx context.Context, ip netip.Addr, hostname string, timeout time.Duration) ipCheckResult {
+	result := ipCheckResult{Check: "reverse-dns"}
+	lookupCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	names, err := ipCheckLookupAddr(lookupCtx, ip.String())
+	for i := range names {
+		names[i] = strings.TrimSuffix(names[i], ".")
+	}
+	var dnsErr *net.DNSError
+	target := hostname
+	if target == "" {
+		target = "<hostname>"
+	}
+	switch {
+	case err != nil && errors.As(err, &dnsErr) && dnsErr.IsNotFound, err == nil && len(names) == 0:
+		result.Status, result.Detail = ipCheckWarn, "no PTR record"
+		result.Hint = fmt.Sprintf("create the record: %s IN PTR %s.", reverseDNSName(ip), target)
+	case err != nil:
+		result.Status, result.Detail = ipCheckWarn, fmt.Sprintf("reverse lookup: %v", err)
+		result.Hint = "check the workstation resolver, then re-run"
+	case hostname == "":
+		result.Status, result.Detail = ipCheckPass, "PTR "+strings.Join(names, ", ")
+	case ptrMatchesHostname(names, hostname):
+		result.Status, result.Detail = ipCheckPass, "PTR "+strings.Join(names, ", ")
+	default:
+		result.Status, result.Detail = ipCheckFail, fmt.Sprintf("PTR %s does not match %s", strings.Join(names, ", "), hostname)
+		result.Hint = fmt.Sprintf("point %s at %s.; a stale PTR usually means the IP still belongs to an old host", reverseDNSName(ip), hostname)
+	}
+	return result
+}
+
+// ptrMatchesHostname accepts an exact match, or a short --hostname matching
+// the first label of a fully qualified PTR.
+func ptrMatchesHostname(names []string, hostname string) bool {
+	for _, name := range names {
+		if strings.EqualFold(name, hostname) {
+			return true
+		}
+		if !strings.Contains(hostname, ".") && strings.EqualFold(strings.SplitN(name, ".", 2)[0], hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+func reverseDNSName(ip netip.Addr) string {
+	octets := ip.As4()
+	return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa.", octets[3], octets[2], octets[1], octets[0])
+}
+
+func checkDHCPPool(ip netip.Addr, pool versionconfig.DHCPPoolConfig) ipCheckResult {
+	result := ipCheckResult{Check: "dhcp-pool"}
+	switch {
+	case !pool.Configured():
+		result.Status, result.Detail = ipCheckWarn, "cluster.dhcp_pool is not set in homeops.yaml"
+		result.Hint = "add cluster.dhcp_pool.start/end so node IPs can be checked against the lease range"
+	case pool.Contains(ip):
+		result.Status, result.Detail = ipCheckFail, fmt.Sprintf("inside DHCP pool %s-%s", pool.Start, pool.End)
+		result.Hint = "the DHCP server may lease this address to another client; pick an IP outside the pool or shrink the pool"
+	default:
+		result.Status, result.Detail = ipCheckPass, fmt.Sprintf("outside DHCP pool %s-%s", pool.Start, pool.End)
+	}
+	return result
+}
+
+// checkNTPServers only warns: the query runs from this workstation, which may
+// not share the node VLAN's route to the servers.
+func checkNTPServers(ctx context.Context, servers []string, timeout time.Duration) ipCheckResult {
+	result := ipCheckResult{Check: "ntp"}
+	if len(servers) == 0 {
+		result.Status, result.Detail = ipCheckWarn, "cluster.ntp_servers is empty"
+		result.Hint = "set cluster.ntp_servers; Talos waits for time sync before starting etcd"
+		return result
+	}
+	var silent []string
+	for _, server := range servers {
+		queryCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := ipCheckNTPQueryFn(queryCtx, server)
+		cancel()
+		if err != nil {
+			silent = append(silent, server)
+		}
+	}
+	switch {
+	case len(silent) == 0:
+		result.Status, result.Detail = ipCheckPass, fmt.Sprintf("all %d NTP server(s) answer", len(servers))
+	default:
+		result.Status = ipCheckWarn
+		result.Detail = fmt.Sprintf("%d/%d NTP server(s) silent: %s", len(silent), len(servers), strings.Join(silent, ", "))
+		result.Hint = "check that the node VLAN can reach these servers on udp/123"
+	}
+	return result
+}
+
+// querySNTP sends a single client-mode SNTPv4 request and accepts any
+// server-mode reply.
+func querySNTP(ctx context.Context, server string) error {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "udp", net.JoinHostPort(server, "123"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	request := make([]byte, 48)
+	request[0] = 0x23 // LI 0, version 4, mode 3 (client)
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+	reply := make([]byte, 48)
+	n, err := conn.Read(reply)
+	if err != nil {
+		return err
+	}
+	if n < 48 || reply[0]&0x07 != 4 {
+		return fmt.Errorf("unexpected SNTP reply from %s", server)
+	}
+	return nil
+}
+
+func lookupARPNeighbor(ctx context.Context, ip string) (string, error) {
+	if runtime.GOOS == "linux" {
+		content, err := ipCheckReadFileFn("/proc/net/arp")
+		if err != nil {
+			return "", err
+		}
+		return parseProcNetARP(string(content), ip), nil
+	}
+	output, err := ipCheckArpOutputFn(ctx, ip)
+	if err != nil {
+		return "", nil
+	}
+	return parseArpCommand(string(output), ip), nil
+}
+
+// parseProcNetARP reads Linux's ARP table; flags 0x0 marks an incomplete
+// entry left behind by an unanswered probe.
+func parseProcNetARP(content, ip string) string {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != ip {
+			continue
+		}
+		if fields[2] == "0x0" || fields[3] == "00:00:00:00:00:00" {
+			return ""
+		}
+		return fields[3]
+	}
+	return ""
+}
+
+// parseArpCommand reads BSD/macOS `arp -n` output such as
+// "? (192.168.1.5) at 0:11:22:33:44:55 on en0 ifscope [ethernet]".
+func parseArpCommand(output, ip string) string {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		for i := 0; i+2 < len(fields); i++ {
+			if fields[i] == "("+ip+")" && fields[i+1] == "at" && fields[i+2] != "(incomplete)" {
+				return fields[i+2]
+			}
+		}
+	}
+	return ""
+}
+
+func containsString(values []string, want string) bool {
+	for _, value := range values {
+		if value == want {
+			return true
+		}
+	}
+	return false
+}
+
+func renderIPCheckReport(report ipCheckReport, output string) (string, error) {
+	if output == "json" {
+		return ui.RenderJSON(report)
+	}
+	rows := make([][]string, 0, len(report.Checks))
+	for _, check := range report.Checks {
+		rows = append(rows, []string{check.Check, check.Status, check.Detail, check.Hint})
+	}
+	target := report.IP
+	if report.Hostname != "" {
+		target = fmt.Sprintf("%s (%s)", report.IP, report.Hostname)
+	}
+	return fmt.Sprintf("IP CHECK %s\n\n%s\n\nSummary: %d pass, %d warn, %d fail", target,
+		ui.Table([]string{"CHECK", "STATUS", "DETAIL", "HINT"}, rows), report.Pass, report.Warn, report.Fail), nil
+}
+
+// deployVMIPPreflightFn runs check-ip for every VM name that is a configured
+// cluster node, before deploy-vm talks to any hypervisor. Swappable for tests.
+var deployVMIPPreflightFn = runDeployVMIPPreflight
+
+// NodeIPPreflight is the deploy-vm IP preflight for other command groups;
+// main wires it into flatcar deploy-vm, which cannot import this package.
+func NodeIPPreflight(ctx context.Context, logger *common.ColorLogger, names []string, expectExisting bool) error {
+	return deployVMIPPreflightFn(ctx, logger, names, expectExisting)
+}
+
+func runDeployVMIPPreflight(ctx context.Context, logger *common.ColorLogger, names []string, expectExisting bool) error {
+	cfg := versionconfig.Get()
+	var failures []string
+	for _, name := range names {
+		node, ok := cfg.ProvisioningNodeByName(name)
+		if !ok || node.IP == "" {
+			continue
+		}
+		report, err := runIPChecks(ctx, ipCheckOptions{IP: node.IP, Hostname: name, ExpectExisting: expectExisting}, cfg)
+		if err != nil {
+			return err
+		}
+		for _, check := range report.Checks {
+			switch check.Status {
+			case ipCheckFail:
+				failures = append(failures, fmt.Sprintf("%s %s %s: %s", name, node.IP, check.Check, check.Detail))
+			case ipCheckWarn:
+				logger.Warn("IP check %s %s %s: %s", name, node.IP, check.Check, check.Detail)
+			}
+		}
+		if report.Fail == 0 {
+			logger.Info("IP checks passed for %s (%s): %d pass, %d warn", name, node.IP, report.Pass, report.Warn)
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("planned node IP checks failed (run 'homeops-cli talos check-ip' for hints, or pass --skip-ip-check):\n  %s", strings.Join(failures, "\n  "))
+	}
+	return nil
+}
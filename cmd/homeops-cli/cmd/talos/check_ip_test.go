@@ -0,0 +1,212 @@
+package talos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/common"
+	versionconfig "homeops-cli/internal/config"
+	"homeops-cli/internal/testutil"
+)
+
+// fakeIPNetwork answers probes from fixed tables instead of the network.
+type fakeIPNetwork struct {
+	ping     map[string]bool
+	open     map[string]bool
+	arp      map[string]string
+	forward  map[string][]string
+	reverse  map[string][]string
+	ntpDown  map[string]bool
+	mu       sync.Mutex
+	probed   []string
+	deadline bool
+}
+
+func installFakeIPNetwork(t *testing.T, network *fakeIPNetwork) {
+	t.Helper()
+	notFound := func(name string) error { return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true} }
+	testutil.Swap(t, &ipCheckPingFn, func(ctx context.Context, ip string) bool {
+		_, network.deadline = ctx.Deadline()
+		return network.ping[ip]
+	})
+	testutil.Swap(t, &ipCheckDialFn, func(_ context.Context, address string) error {
+		network.mu.Lock()
+		network.probed = append(network.probed, address)
+		network.mu.Unlock()
+		if network.open[address] {
+			return nil
+		}
+		return errors.New("connection refused")
+	})
+	testutil.Swap(t, &ipCheckNeighborFn, func(_ context.Context, ip string) (string, error) { return network.arp[ip], nil })
+	testutil.Swap(t, &ipCheckLookupHost, func(_ context.Context, host string) ([]string, error) {
+		if addresses, ok := network.forward[host]; ok {
+			return addresses, nil
+		}
+		return nil, notFound(host)
+	})
+	testutil.Swap(t, &ipCheckLookupAddr, func(_ context.Context, ip string) ([]string, error) {
+		if names, ok := network.reverse[ip]; ok {
+			return names, nil
+		}
+		return nil, notFound(ip)
+	})
+	testutil.Swap(t, &ipCheckNTPQueryFn, func(_ context.Context, server string) error {
+		if network.ntpDown[server] {
+			return errors.New("i/o timeout")
+		}
+		return nil
+	})
+}
+
+func ipCheckConfig() *versionconfig.Config {
+	return &versionconfig.Config{Cluster: versionconfig.ClusterConfig{
+		DHCPPool:   versionconfig.DHCPPoolConfig{Start: "192.168.120.100", End: "192.168.120.199"},
+		NTPServers: []string{"10.0.0.1", "10.0.0.2"},
+		Nodes:      []versionconfig.Node{{Name: "k8s-3", IP: "192.168.122.13"}, {Name: "k8s-4", IP: "192.168.120.150"}},
+	}}
+}
+
+func checkStatuses(report ipCheckReport) map[string]string {
+	statuses := map[string]string{}
+	for _, check := range report.Checks {
+		statuses[check.Check] = check.Status
+	}
+	return statuses
+}
+
+func TestRunIPChecksAllClear(t *testing.T) {
+	network := &fakeIPNetwork{
+		forward: map[string][]string{"k8s-3.lan": {"192.168.122.13"}},
+		reverse: map[string][]string{"192.168.122.13": {"k8s-3.lan."}},
+	}
+	installFakeIPNetwork(t, network)
+
+	report, err := runIPChecks(context.Background(), ipCheckOptions{IP: "192.168.122.13", Hostname: "k8s-3.lan"}, ipCheckConfig())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"in-use": "PASS", "forward-dns": "PASS", "reverse-dns": "PASS", "dhcp-pool": "PASS", "ntp": "PASS"}, checkStatuses(report))
+	assert.Equal(t, 5, report.Pass)
+	assert.Len(t, network.probed, len(ipCheckPorts))
+	assert.True(t, network.deadline, "every probe runs under a timeout")
+}
+
+func TestRunIPChecksReportsConflicts(t *testing.T) {
+	installFakeIPNetwork(t, &fakeIPNetwork{
+		open:    map[string]bool{"192.168.120.150:22": true},
+		arp:     map[string]string{"192.168.120.150": "aa:bb:cc:dd:ee:ff"},
+		forward: map[string][]string{"k8s-4": {"192.168.120.42"}},
+		reverse: map[string][]string{"192.168.120.150": {"printer.lan."}},
+		ntpDown: map[string]bool{"10.0.0.2": true},
+	})
+
+	report, err := runIPChecks(context.Background(), ipCheckOptions{IP: "192.168.120.150", Hostname: "k8s-4"}, ipCheckConfig())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"in-use": "FAIL", "forward-dns": "FAIL", "reverse-dns": "FAIL", "dhcp-pool": "FAIL", "ntp": "WARN"}, checkStatuses(report))
+	assert.Equal(t, "answers tcp/22, arp aa:bb:cc:dd:ee:ff", report.Checks[0].Detail)
+	assert.Contains(t, report.Checks[0].Hint, "--expect-existing")
+	assert.Contains(t, report.Checks[1].Hint, "update the A record for k8s-4 to 192.168.120.150")
+	assert.Contains(t, report.Checks[3].Detail, "192.168.120.100-192.168.120.199")
+	assert.Contains(t, report.Checks[4].Detail, "1/2 NTP server(s) silent: 10.0.0.2")
+
+	replaced, err := runIPChecks(context.Background(), ipCheckOptions{IP: "192.168.120.150", Hostname: "k8s-4", ExpectExisting: true}, ipCheckConfig())
+	require.NoError(t, err)
+	assert.Equal(t, "PASS", replaced.Checks[0].Status)
+}
+
+func TestRunIPChecksMissingRecordsAreWarnings(t *testing.T) {
+	installFakeIPNetwork(t, &fakeIPNetwork{})
+	cfg := ipCheckConfig()
+	cfg.Cluster.DHCPPool = versionconfig.DHCPPoolConfig{}
+
+	report, err := runIPChecks(context.Background(), ipCheckOptions{IP: "192.168.122.13", Hostname: "k8s-3", ExpectExisting: true}, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"in-use": "WARN", "forward-dns": "WARN", "reverse-dns": "WARN", "dhcp-pool": "WARN", "ntp": "PASS"}, checkStatuses(report))
+	assert.Equal(t, "create the record: k8s-3. IN A 192.168.122.13", report.Checks[1].Hint)
+	assert.Equal(t, "create the record: 13.122.168.192.in-addr.arpa. IN PTR k8s-3.", report.Checks[2].Hint)
+	assert.Zero(t, report.Fail)
+
+	_, err = runIPChecks(context.Background(), ipCheckOptions{IP: "not-an-ip"}, cfg)
+	require.Error(t, err)
+}
+
+func TestCheckIPCommandJSONAndExitStatus(t *testing.T) {
+	defer versionconfig.SetForTesting(ipCheckConfig())()
+	installFakeIPNetwork(t, &fakeIPNetwork{
+		forward: map[string][]string{"k8s-3": {"192.168.122.13"}},
+		reverse: map[string][]string{"192.168.122.13": {"k8s-3.lan."}},
+	})
+
+	out, err := testutil.ExecuteCommand(newCheckIPCommand(), "--ip", "192.168.122.13", "--hostname", "k8s-3", "--output", "json")
+	require.NoError(t, err)
+	var report ipCheckReport
+	require.NoError(t, json.Unmarshal([]byte(out), &report))
+	assert.Equal(t, 5, report.Pass, "short hostname matches the first PTR label")
+
+	out, err = testutil.ExecuteCommand(newCheckIPCommand(), "--ip", "192.168.120.150")
+	require.Error(t, err)
+	assert.Contains(t, out, "inside DHCP pool")
+	assert.Contains(t, err.Error(), "1 IP check(s) failed")
+}
+
+func TestDeployVMIPPreflightOnlyChecksConfiguredNodes(t *testing.T) {
+	defer versionconfig.SetForTesting(ipCheckConfig())()
+	installFakeIPNetwork(t, &fakeIPNetwork{})
+	logger := common.NewColorLogger()
+	logger.SetQuiet(true)
+
+	require.NoError(t, runDeployVMIPPreflight(context.Background(), logger, []string{"k8s-3", "scratch"}, false))
+	err := runDeployVMIPPreflight(context.Background(), logger, []string{"k8s-4"}, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "k8s-4 192.168.120.150 dhcp-pool")
+	assert.Contains(t, err.Error(), "--skip-ip-check")
+
+	var checked []string
+	var expected []bool
+	testutil.Swap(t, &deployVMIPPreflightFn, func(_ context.Context, _ *common.ColorLogger, names []string, expectExisting bool) error {
+		checked = append(checked, names...)
+		expected = append(expected, expectExisting)
+		return errors.New("stop before any provider call")
+	})
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "proxmox", "--name", "k8s", "--node-count", "2", "--start-index", "3", "--dry-run")
+	require.Error(t, err)
+	assert.Equal(t, []string{"k8s-3", "k8s-4"}, checked)
+
+	checked, expected = nil, nil
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "proxmox", "--name", "k8s-3", "--dry-run", "--expect-existing")
+	require.Error(t, err)
+	assert.Equal(t, []string{"k8s-3"}, checked)
+	assert.Equal(t, []bool{true}, expected, "--expect-existing reaches the IP checks")
+
+	checked = nil
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "proxmox", "--name", "k8s-3", "--dry-run", "--skip-ip-check")
+	require.NoError(t, err)
+	assert.Empty(t, checked)
+}
+
+func TestARPTableParsing(t *testing.T) {
+	proc := "IP address       HW type     Flags       HW address            Mask     Device\n" +
+		"192.168.122.10   0x1         0x2         02:00:00:00:02:10     *        eth0\n" +
+		"192.168.122.13   0x1         0x0         00:00:00:00:00:00     *        eth0\n"
+	assert.Equal(t, "02:00:00:00:02:10", parseProcNetARP(proc, "192.168.122.10"))
+	assert.Empty(t, parseProcNetARP(proc, "192.168.122.13"), "incomplete entries left by our own probe are not hosts")
+	assert.Empty(t, parseProcNetARP(proc, "192.168.122.99"))
+
+	bsd := "? (192.168.122.10) at 2:0:0:0:2:10 on en0 ifscope [ethernet]\n? (192.168.122.13) at (incomplete) on en0 ifscope [ethernet]\n"
+	assert.Equal(t, "2:0:0:0:2:10", parseArpCommand(bsd, "192.168.122.10"))
+	assert.Empty(t, parseArpCommand(bsd, "192.168.122.13"))
+}
+
+func TestDHCPPoolContains(t *testing.T) {
+	pool := versionconfig.DHCPPoolConfig{Start: "192.168.120.100", End: "192.168.120.199"}
+	for ip, want := range map[string]bool{"192.168.120.100": true, "192.168.120.199": true, "192.168.120.99": false, "192.168.121.150": false} {
+		assert.Equal(t, want, pool.Contains(netip.MustParseAddr(ip)), ip)
+	}
+}
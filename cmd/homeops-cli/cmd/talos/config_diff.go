@@ -0,0 +1,75 @@
+package talos
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/ui"
+)
+
+// configDiffRedacted replaces a dry-run diff line that carries a resolved
+// secret value.
+const configDiffRedacted = "<line redacted: contains a resolved secret; --show-secrets to reveal>"
+
+var talosDryRunApplyConfigFn = func(nodeIP, mode, config string) ([]byte, error) {
+	return runTalosApplyConfigRaw(nodeIP, mode, config, "--dry-run")
+}
+
+// secretFragments splits injected values into the lines a diff can show,
+// in both plain and base64 form, dropping those too short to mask safely.
+func secretFragments(values []string) []string {
+	var fragments []string
+	for _, value := range values {
+		for _, line := range strings.Split(value, "\n") {
+			line = strings.TrimSpace(line)
+			if len(line) < common.MinRedactedSecretLength {
+				continue
+			}
+			fragments = append(fragments, line, base64.StdEncoding.EncodeToString([]byte(line)))
+		}
+	}
+	return fragments
+}
+
+// redactSecretLines masks every line of diff that contains one of values,
+// keeping its +/- marker so the shape of the change stays readable.
+func redactSecretLines(diff string, values []string) string {
+	fragments := secretFragments(values)
+	if len(fragments) == 0 {
+		return diff
+	}
+	lines := strings.Split(diff, "\n")
+	for i, line := range lines {
+		for _, fragment := range fragments {
+			if strings.Contains(line, fragment) {
+				marker := ""
+				if line != "" && (line[0] == '+' || line[0] == '-') {
+					marker = line[:1] + " "
+				}
+				lines[i] = marker + configDiffRedacted
+				break
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// colorConfigDiff colours additions green, removals red, and hunk headers
+// cyan in talosctl's dry-run diff.
+func colorConfigDiff(diff string) string {
+	lines := strings.Split(diff, "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			lines[i] = ui.Style(line, ui.StyleOptions{Bold: true})
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = ui.Style(line, ui.StyleOptions{Foreground: "39"})
+		case strings.HasPrefix(line, "+"):
+			lines[i] = ui.Style(line, ui.StyleOptions{Foreground: "42"})
+		case strings.HasPrefix(line, "-"):
+			lines[i] = ui.Style(line, ui.StyleOptions{Foreground: "196"})
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,63 @@
+package talos
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/testutil"
+)
+
+func TestRedactSecretLines(t *testing.T) {
+	token := "abcdef.0123456789abcdef"
+	diff := strings.Join([]string{
+		"@@ -1,4 +1,4 @@",
+		"   sysctls:",
+		"-    net.core.somaxconn: \"1024\"",
+		"+    net.core.somaxconn: \"4096\"",
+		"+  token: " + token,
+		"   secret: " + base64.StdEncoding.EncodeToString([]byte(token)),
+		"   short: abc",
+	}, "\n")
+	redacted := redactSecretLines(diff, []string{token, "abc"})
+	assert.Equal(t, strings.Join([]string{
+		"@@ -1,4 +1,4 @@",
+		"   sysctls:",
+		"-    net.core.somaxconn: \"1024\"",
+		"+    net.core.somaxconn: \"4096\"",
+		"+ " + configDiffRedacted,
+		configDiffRedacted,
+		"   short: abc",
+	}, "\n"), redacted, "values shorter than the redaction minimum are left alone")
+}
+
+func TestApplyNodeDryRunShowsMaskedDiff(t *testing.T) {
+	testutil.Swap(t, &getMachineTypeFromNodeFn, func(string) (string, error) { return "worker", nil })
+	testutil.Swap(t, &renderMachineConfigFromEmbeddedFn, func(string, string) ([]byte, error) {
+		return []byte("machine:\n  token: op://talos/machine/token\n  sysctls:\n    net.core.somaxconn: \"4096\"\n"), nil
+	})
+	testutil.Swap(t, &injectSecretsFn, func(rendered string) (string, error) {
+		return strings.Replace(rendered, "op://talos/machine/token", "s3cr3t-token-value", 1), nil
+	})
+	testutil.Swap(t, &talosApplyConfigFn, func(string, string, string) ([]byte, error) {
+		t.Fatal("--dry-run must not apply")
+		return nil, nil
+	})
+	testutil.Swap(t, &talosDryRunApplyConfigFn, func(nodeIP, mode, config string) ([]byte, error) {
+		assert.Equal(t, "10.0.0.20", nodeIP)
+		return []byte("Dry run summary:\nConfig diff:\n\n@@ -3 +3 @@\n-    net.core.somaxconn: \"1024\"\n+    net.core.somaxconn: \"4096\"\n   token: s3cr3t-token-value\n"), nil
+	})
+
+	out, err := testutil.ExecuteCommand(newApplyNodeCommand(), "--ip", "10.0.0.20", "--dry-run")
+	require.NoError(t, err)
+	assert.Contains(t, out, "+    net.core.somaxconn: \"4096\"")
+	assert.Contains(t, out, configDiffRedacted)
+	assert.NotContains(t, out, "s3cr3t-token-value")
+
+	out, err = testutil.ExecuteCommand(newApplyNodeCommand(), "--ip", "10.0.0.20", "--dry-run", "--show-secrets")
+	require.NoError(t, err)
+	assert.Contains(t, out, "   token: s3cr3t-token-value")
+}
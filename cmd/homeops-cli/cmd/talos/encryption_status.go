@@ -0,0 +1,173 @@
+package talos
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	versionconfig "homeops-cli/internal/config"
+	"homeops-cli/internal/ui"
+)
+
+// Encryption status verdicts.
+const (
+	encryptionOK          = "ok"
+	encryptionNeedsReset  = "needs reset"
+	encryptionUnreachable = "unreachable"
+	encryptionUnknown     = "unknown"
+)
+
+type encryptionNodeStatus struct {
+	Node      string `json:"node"`
+	IP        string `json:"ip"`
+	State     string `json:"state"`
+	Ephemeral string `json:"ephemeral"`
+	Want      string `json:"want"`
+	Verdict   string `json:"verdict"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+type encryptionStatusReport struct {
+	Provider string                 `json:"provider"`
+	Nodes    []encryptionNodeStatus `json:"nodes"`
+}
+
+// talosVolumeStatus is the part of a `talosctl get volumestatus -o json`
+// resource that says whether a volume is encrypted. Talos does not expose
+// which key kind sealed it, only the LUKS provider.
+type talosVolumeStatus struct {
+	Metadata struct {
+		ID string `json:"id"`
+	} `json:"metadata"`
+	Spec struct {
+		EncryptionProvider string `json:"encryptionProvider"`
+	} `json:"spec"`
+}
+
+func newEncryptionStatusCommand() *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "encryption-status",
+		Short: "Compare each node's STATE/EPHEMERAL encryption with cluster.talos.disk_encryption",
+		Long: `Reads the volume status of every node in cluster.nodes and reports whether
+STATE and EPHEMERAL are encrypted as cluster.talos.disk_encryption asks.
+
+Talos only encrypts or decrypts system partitions when they are formatted, so
+applying a config with a different systemDiskEncryption does not convert a
+running node. A node reported as "needs reset" must be wiped with
+talos reset-node and re-applied with talos apply-node. Talos reports whether a
+partition is encrypted, not which key sealed it, so switching between nodeid,
+static, and tpm is not detected here.
+
+Exits non-zero when any node needs a reset.`,
+		Example: `  homeops-cli talos encryption-status
+  homeops-cli talos encryption-status --output json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ui.ValidateOutputFormat(output); err != nil {
+				return err
+			}
+			report := collectEncryptionStatus(versionconfig.Get())
+			rendered, err := renderEncryptionStatusReport(report, output)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintln(cmd.OutOrStdout(), rendered); err != nil {
+				return err
+			}
+			var reset []string
+			for _, node := range report.Nodes {
+				if node.Verdict == encryptionNeedsReset {
+					reset = append(reset, node.Node)
+				}
+			}
+			if len(reset) > 0 {
+				return fmt.Errorf("%s must be reset (talos reset-node, then talos apply-node) to change disk encryption", strings.Join(reset, ", "))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table or json")
+	return cmd
+}
+
+func collectEncryptionStatus(cfg *versionconfig.Config) encryptionStatusReport {
+	enc := cfg.Cluster.Talos.DiskEncryption
+	report := encryptionStatusReport{Provider: enc.Provider}
+	if report.Provider == "" {
+		report.Provider = "off"
+	}
+	want := "plain"
+	if enc.Enabled() {
+		want = "encrypted"
+	}
+	for _, node := range cfg.Cluster.Nodes {
+		status := encryptionNodeStatus{Node: node.Name, IP: node.IP, Want: want}
+		output, err := talosctlNodeOutputFn(node.IP, "get", "volumestatus", "-o", "json")
+		if err != nil {
+			status.Verdict = encryptionUnreachable
+			status.Detail = strings.TrimSpace(err.Error())
+			report.Nodes = append(report.Nodes, status)
+			continue
+		}
+		volumes, err := parseVolumeEncryption(output)
+		if err != nil {
+			status.Verdict = encryptionUnknown
+			status.Detail = err.Error()
+			report.Nodes = append(report.Nodes, status)
+			continue
+		}
+		status.State, status.Ephemeral = volumes["STATE"], volumes["EPHEMERAL"]
+		switch {
+		case status.State == "" || status.Ephemeral == "":
+			status.Verdict = encryptionUnknown
+			status.Detail = "STATE or EPHEMERAL missing from volume status"
+		case status.State == want && status.Ephemeral == want:
+			status.Verdict = encryptionOK
+		default:
+			status.Verdict = encryptionNeedsReset
+		}
+		report.Nodes = append(report.Nodes, status)
+	}
+	return report
+}
+
+// parseVolumeEncryption maps volume IDs to "encrypted" or "plain". talosctl
+// prints one JSON object per resource, not an array.
+func parseVolumeEncryption(output []byte) (map[string]string, error) {
+	volumes := map[string]string{}
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	for {
+		var volume talosVolumeStatus
+		if err := decoder.Decode(&volume); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse volume status: %w", err)
+		}
+		switch strings.ToLower(volume.Spec.EncryptionProvider) {
+		case "", "none":
+			volumes[volume.Metadata.ID] = "plain"
+		default:
+			volumes[volume.Metadata.ID] = "encrypted"
+		}
+	}
+	return volumes, nil
+}
+
+func renderEncryptionStatusReport(report encryptionStatusReport, output string) (string, error) {
+	if output == "json" {
+		return ui.RenderJSON(report)
+	}
+	rows := make([][]string, 0, len(report.Nodes))
+	for _, node := range report.Nodes {
+		rows = append(rows, []string{node.Node, node.IP, node.State, node.Ephemeral, node.Want, node.Verdict, node.Detail})
+	}
+	return fmt.Sprintf("DISK ENCRYPTION (provider %s)\n\n%s", report.Provider,
+		ui.Table([]string{"NODE", "IP", "STATE", "EPHEMERAL", "WANT", "VERDICT", "DETAIL"}, rows)), nil
+}
@@ -0,0 +1,50 @@
+package talos
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	versionconfig "homeops-cli/internal/config"
+	"homeops-cli/internal/testutil"
+)
+
+func TestEncryptionStatusFlagsNodesNeedingReset(t *testing.T) {
+	t.Cleanup(versionconfig.SetForTesting(&versionconfig.Config{Cluster: versionconfig.ClusterConfig{
+		Nodes: []versionconfig.Node{{Name: "k8s-0", IP: "10.0.0.10"}, {Name: "k8s-1", IP: "10.0.0.11"}, {Name: "k8s-2", IP: "10.0.0.12"}},
+		Talos: versionconfig.TalosSettings{DiskEncryption: versionconfig.TalosDiskEncryption{Provider: versionconfig.DiskEncryptionTPM}},
+	}}))
+	volumes := func(state, ephemeral string) []byte {
+		return []byte(`{"node":"n","metadata":{"id":"META"},"spec":{"encryptionProvider":"none"}}
+{"node":"n","metadata":{"id":"STATE"},"spec":{"encryptionProvider":"` + state + `"}}
+{"node":"n","metadata":{"id":"EPHEMERAL"},"spec":{"encryptionProvider":"` + ephemeral + `"}}
+`)
+	}
+	testutil.Swap(t, &talosctlNodeOutputFn, func(node string, args ...string) ([]byte, error) {
+		assert.Equal(t, []string{"get", "volumestatus", "-o", "json"}, args)
+		switch node {
+		case "10.0.0.10":
+			return volumes("luks2", "luks2"), nil
+		case "10.0.0.11":
+			return volumes("luks2", "none"), nil
+		}
+		return nil, errors.New("connection refused")
+	})
+
+	out, err := testutil.ExecuteCommand(newEncryptionStatusCommand(), "--output", "json")
+	require.ErrorContains(t, err, "k8s-1 must be reset (talos reset-node, then talos apply-node)")
+	var report encryptionStatusReport
+	require.NoError(t, json.NewDecoder(strings.NewReader(out)).Decode(&report), "cobra appends the error after the report")
+	assert.Equal(t, "tpm", report.Provider)
+	require.Len(t, report.Nodes, 3)
+	var verdicts []string
+	for _, node := range report.Nodes {
+		verdicts = append(verdicts, node.Verdict)
+	}
+	assert.Equal(t, []string{encryptionOK, encryptionNeedsReset, encryptionUnreachable}, verdicts)
+	assert.Equal(t, "plain", report.Nodes[1].Ephemeral)
+}
@@ -0,0 +1,342 @@
+package talos
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"homeops-cli/internal/common"
+	versionconfig "homeops-cli/internal/config"
+	"homeops-cli/internal/constants"
+	"homeops-cli/internal/kubeutil"
+	"homeops-cli/internal/ui"
+)
+
+// Health check groups, in the order the report lists them.
+const (
+	healthGroupTalos = "talos"
+	healthGroupNodes = "nodes"
+	healthGroupEtcd  = "etcd"
+	healthGroupFlux  = "flux"
+
+	healthPass = "PASS"
+	healthFail = "FAIL"
+
+	// healthPollInterval is how long --wait sleeps between passes.
+	healthPollInterval = 10 * time.Second
+	// healthTalosWaitTimeout bounds each talosctl health run, so one pass of
+	// --wait cannot outlast the next by minutes.
+	healthTalosWaitTimeout = "2m"
+
+	fluxKustomizationResource = "kustomizations.kustomize.toolkit.fluxcd.io"
+)
+
+var (
+	healthKubectlFn = func(ctx context.Context, args ...string) ([]byte, error) {
+		return common.RunCommandWithContextOutput(ctx, "kubectl", args...)
+	}
+	healthSleepFn = sleepContext
+)
+
+type healthCheck struct {
+	Group  string `json:"group"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type talosHealthReport struct {
+	Healthy bool          `json:"healthy"`
+	Checks  []healthCheck `json:"checks"`
+}
+
+func (r *talosHealthReport) add(group, name string, healthy bool, detail string) {
+	status := healthPass
+	if !healthy {
+		status = healthFail
+	}
+	r.Checks = append(r.Checks, healthCheck{Group: group, Name: name, Status: status, Detail: detail})
+}
+
+// failures names every failing check as "<group> <name>".
+func (r talosHealthReport) failures() []string {
+	var failed []string
+	for _, check := range r.Checks {
+		if check.Status == healthFail {
+			failed = append(failed, check.Group+" "+check.Name)
+		}
+	}
+	return failed
+}
+
+type healthCondition struct {
+	Type    string `json:"type"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// healthConditionList is the part of a `kubectl get -o json` list of nodes
+// or Kustomizations the checks read.
+type healthConditionList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Spec struct {
+			Suspend bool `json:"suspend"`
+		} `json:"spec"`
+		Status struct {
+			Conditions []healthCondition `json:"conditions"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+func newHealthCommand() *cobra.Command {
+	var output string
+	var wait time.Duration
+	cmd := &cobra.Command{
+		Use:   "health",
+		Short: "Check Talos, node, etcd, and Flux health in one pass",
+		Long: `Runs talosctl health against the first control plane in cluster.nodes, then
+checks every Kubernetes node's Ready condition, the etcd status of each control
+plane, and the Flux Kustomizations in flux-system.
+
+Exits non-zero and names every failing check when anything is unhealthy.
+--wait re-checks every 10s until the cluster is healthy or the duration runs
+out, then reports the last pass.`,
+		Example: `  homeops-cli talos health
+  homeops-cli talos health --wait 15m
+  homeops-cli talos health --output json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ui.ValidateOutputFormat(output); err != nil {
+				return err
+			}
+			report := waitForTalosHealth(cmd.Context(), versionconfig.Get(), wait, common.NewColorLogger())
+			rendered, err := renderTalosHealthReport(report, output)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintln(cmd.OutOrStdout(), rendered); err != nil {
+				return err
+			}
+			if !report.Healthy {
+				return fmt.Errorf("cluster is unhealthy: %s", strings.Join(report.failures(), ", "))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table or json")
+	cmd.Flags().DurationVar(&wait, "wait", 0, "re-check until healthy or this long has passed (0 checks once)")
+	return cmd
+}
+
+// waitForTalosHealth checks once, or with wait set, until a pass is healthy
+// or wait runs out. It returns the last pass.
+func waitForTalosHealth(ctx context.Context, cfg *versionconfig.Config, wait time.Duration, logger *common.ColorLogger) talosHealthReport {
+	report := collectTalosHealth(ctx, cfg)
+	if wait <= 0 {
+		return report
+	}
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	for !report.Healthy {
+		logger.Info("Still unhealthy (%s); checking again in %s", strings.Join(report.failures(), ", "), healthPollInterval)
+		if healthSleepFn(ctx, healthPollInterval) != nil {
+			break
+		}
+		report = collectTalosHealth(ctx, cfg)
+	}
+	return report
+}
+
+func collectTalosHealth(ctx context.Context, cfg *versionconfig.Config) talosHealthReport {
+	var report talosHealthReport
+	controlPlanes := configuredControlPlanes(cfg)
+	report.addTalos(controlPlanes)
+	report.addNodes(ctx)
+	report.addEtcd(controlPlanes)
+	report.addFlux(ctx)
+	report.Healthy = len(report.failures()) == 0
+	return report
+}
+
+// configuredControlPlanes returns the cluster.nodes whose Talos patch makes
+// them control planes.
+func configuredControlPlanes(cfg *versionconfig.Config) []versionconfig.Node {
+	var controlPlanes []versionconfig.Node
+	for _, node := range cfg.Cluster.Nodes {
+		content, err := getTalosTemplateFn(fmt.Sprintf("talos/nodes/%s.yaml", node.IP))
+		if err == nil && nodeTemplateMachineType(content) == "controlplane" {
+			controlPlanes = append(controlPlanes, node)
+		}
+	}
+	return controlPlanes
+}
+
+func (r *talosHealthReport) addTalos(controlPlanes []versionconfig.Node) {
+	if len(controlPlanes) == 0 {
+		r.add(healthGroupTalos, "health", false, "no control plane in cluster.nodes")
+		return
+	}
+	node := controlPlanes[0]
+	output, err := talosctlCombinedOutputFn("talosctl", "--nodes", node.IP, "health", "--wait-timeout", healthTalosWaitTimeout)
+	if err != nil {
+		r.add(healthGroupTalos, "health", false, lastLine(common.RedactCommandOutput(string(output)), err))
+		return
+	}
+	r.add(healthGroupTalos, "health", true, "talosctl health passed via "+node.Name)
+}
+
+func (r *talosHealthReport) addNodes(ctx context.Context) {
+	var list healthConditionList
+	if err := kubeutil.GetClusterJSON(ctx, healthKubectlFn, "nodes", &list); err != nil {
+		r.add(healthGroupNodes, "nodes", false, err.Error())
+		return
+	}
+	if len(list.Items) == 0 {
+		r.add(healthGroupNodes, "nodes", false, "no nodes registered")
+		return
+	}
+	for _, item := range list.Items {
+		ready, detail := readyConditionDetail(item.Status.Conditions)
+		if ready {
+			detail = "Ready"
+		} else {
+			detail = "NotReady: " + detail
+		}
+		r.add(healthGroupNodes, item.Metadata.Name, ready, detail)
+	}
+}
+
+func (r *talosHealthReport) addEtcd(controlPlanes []versionconfig.Node) {
+	for _, node := range controlPlanes {
+		member, problem := etcdMemberStatus(node.IP)
+		if problem != "" {
+			r.add(healthGroupEtcd, node.Name, false, problem)
+			continue
+		}
+		r.add(healthGroupEtcd, node.Name, true, "member "+member)
+	}
+}
+
+// etcdMemberStatus reads a control plane's etcd status and returns its
+// member ID, or why the member is unhealthy.
+func etcdMemberStatus(nodeIP string) (member, problem string) {
+	output, err := talosctlNodeOutputFn(nodeIP, "etcd", "status")
+	if err != nil {
+		return "", strings.TrimSpace(err.Error())
+	}
+	member, learner, errors, ok := parseEtcdStatus(string(output))
+	switch {
+	case !ok:
+		return "", "no member in etcd status"
+	case errors != "":
+		return member, errors
+	case learner:
+		return member, "member " + member + " is still a learner"
+	}
+	return member, ""
+}
+
+func (r *talosHealthReport) addFlux(ctx context.Context) {
+	var list healthConditionList
+	if err := kubeutil.GetJSON(ctx, healthKubectlFn, constants.NSFluxSystem, fluxKustomizationResource, &list); err != nil {
+		r.add(healthGroupFlux, "kustomizations", false, err.Error())
+		return
+	}
+	if len(list.Items) == 0 {
+		r.add(healthGroupFlux, "kustomizations", false, "no Kustomizations in "+constants.NSFluxSystem)
+		return
+	}
+	for _, item := range list.Items {
+		if item.Spec.Suspend {
+			r.add(healthGroupFlux, item.Metadata.Name, false, "suspended")
+			continue
+		}
+		ready, detail := readyConditionDetail(item.Status.Conditions)
+		r.add(healthGroupFlux, item.Metadata.Name, ready, detail)
+	}
+}
+
+// readyConditionDetail reports whether the Ready condition is True, with its
+// reason and message.
+func readyConditionDetail(conditions []healthCondition) (bool, string) {
+	for _, condition := range conditions {
+		if condition.Type != "Ready" {
+			continue
+		}
+		parts := []string{}
+		for _, part := range []string{condition.Reason, condition.Message} {
+			if part != "" {
+				parts = append(parts, part)
+			}
+		}
+		if len(parts) == 0 {
+			parts = append(parts, "Ready="+condition.Status)
+		}
+		return condition.Status == "True", strings.Join(parts, ": ")
+	}
+	return false, "Ready condition missing"
+}
+
+// parseEtcdStatus reads the member row of `talosctl etcd status`. talosctl
+// aligns its columns, so LEARNER and ERRORS are read at their header offsets
+// (DB SIZE and IN USE contain spaces).
+func parseEtcdStatus(output string) (member string, learner bool, errors string, ok bool) {
+	var header string
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "NODE") {
+			header = line
+			continue
+		}
+		fields := strings.Fields(line)
+		if header == "" || len(fields) < 2 {
+			continue
+		}
+		if at := strings.Index(header, "LEARNER"); at >= 0 && len(line) > at {
+			learner = strings.HasPrefix(strings.TrimSpace(line[at:]), "true")
+		}
+		if at := strings.Index(header, "ERRORS"); at >= 0 && len(line) > at {
+			errors = strings.TrimSpace(line[at:])
+		}
+		return fields[1], learner, errors, true
+	}
+	return "", false, "", false
+}
+
+// lastLine returns the last non-blank line of a command's output, or err
+// when it printed nothing.
+func lastLine(output string, err error) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if line := strings.TrimSpace(lines[len(lines)-1]); line != "" {
+		return line
+	}
+	return err.Error()
+}
+
+func renderTalosHealthReport(report talosHealthReport, output string) (string, error) {
+	if output == "json" {
+		return ui.RenderJSON(report)
+	}
+	rows := make([][]string, 0, len(report.Checks))
+	for _, check := range report.Checks {
+		color := "42"
+		if check.Status == healthFail {
+			color = "196"
+		}
+		rows = append(rows, []string{ui.Style(check.Status, ui.StyleOptions{Foreground: color, Bold: true}), check.Group, check.Name, check.Detail})
+	}
+	verdict := "HEALTHY"
+	if !report.Healthy {
+		verdict = "UNHEALTHY"
+	}
+	return fmt.Sprintf("CLUSTER HEALTH: %s\n\n%s", verdict, ui.Table([]string{"STATUS", "GROUP", "NAME", "DETAIL"}, rows)), nil
+}
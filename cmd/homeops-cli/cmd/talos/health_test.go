@@ -0,0 +1,106 @@
+package talos
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	versionconfig "homeops-cli/internal/config"
+	"homeops-cli/internal/testutil"
+)
+
+const testEtcdStatus = `NODE             MEMBER             DB SIZE   IN USE            LEADER             RAFT INDEX   RAFT TERM   RAFT APPLIED INDEX   LEARNER   ERRORS
+10.0.0.10        a49c021e76e707db   17 MB     4.2 MB (24.59%)   ecebb05b59a776f1   53391        4           53391                false
+`
+
+func stubTalosHealth(t *testing.T, nodeReady func() string) {
+	t.Helper()
+	t.Cleanup(versionconfig.SetForTesting(&versionconfig.Config{Cluster: versionconfig.ClusterConfig{
+		Nodes: []versionconfig.Node{{Name: "k8s-0", IP: "10.0.0.10"}, {Name: "k8s-1", IP: "10.0.0.11"}, {Name: "k8s-2", IP: "10.0.0.12"}},
+	}}))
+	testutil.Swap(t, &getTalosTemplateFn, func(string) (string, error) { return "machine:\n  network: {}\n", nil })
+	testutil.Swap(t, &talosctlCombinedOutputFn, func(name string, args ...string) ([]byte, error) {
+		assert.Equal(t, []string{"--nodes", "10.0.0.10", "health", "--wait-timeout", "2m"}, args)
+		return []byte("waiting for all k8s nodes to report ready: OK\n"), nil
+	})
+	testutil.Swap(t, &talosctlNodeOutputFn, func(node string, args ...string) ([]byte, error) {
+		assert.Equal(t, []string{"etcd", "status"}, args)
+		return []byte(testEtcdStatus), nil
+	})
+	testutil.Swap(t, &healthKubectlFn, func(_ context.Context, args ...string) ([]byte, error) {
+		if args[1] == "nodes" {
+			return []byte(`{"items":[
+  {"metadata":{"name":"k8s-0"},"status":{"conditions":[{"type":"Ready","status":"True"}]}},
+  {"metadata":{"name":"k8s-1"},"status":{"conditions":[{"type":"Ready","status":"` + nodeReady() + `","reason":"KubeletNotReady","message":"PLEG is not healthy"}]}}
+]}`), nil
+		}
+		assert.Equal(t, []string{"get", fluxKustomizationResource, "--namespace", "flux-system", "-o", "json"}, args)
+		return []byte(`{"items":[{"metadata":{"name":"cluster-apps"},"status":{"conditions":[{"type":"Ready","status":"True","reason":"ReconciliationSucceeded"}]}}]}`), nil
+	})
+}
+
+func TestTalosHealthNamesNotReadyNode(t *testing.T) {
+	stubTalosHealth(t, func() string { return "False" })
+
+	out, err := testutil.ExecuteCommand(newHealthCommand(), "--output", "json")
+	require.EqualError(t, err, "cluster is unhealthy: nodes k8s-1")
+	var report talosHealthReport
+	require.NoError(t, json.NewDecoder(strings.NewReader(out)).Decode(&report), "cobra appends the error after the report")
+	assert.False(t, report.Healthy)
+	var checks []string
+	for _, check := range report.Checks {
+		checks = append(checks, check.Status+" "+check.Group+" "+check.Name)
+	}
+	assert.Equal(t, []string{
+		"PASS talos health", "PASS nodes k8s-0", "FAIL nodes k8s-1",
+		"PASS etcd k8s-0", "PASS etcd k8s-1", "PASS etcd k8s-2", "PASS flux cluster-apps",
+	}, checks)
+	assert.Equal(t, "NotReady: KubeletNotReady: PLEG is not healthy", report.Checks[2].Detail)
+	assert.Equal(t, "member a49c021e76e707db", report.Checks[3].Detail)
+}
+
+func TestTalosHealthWaitsUntilHealthy(t *testing.T) {
+	passes := 0
+	stubTalosHealth(t, func() string {
+		passes++
+		if passes < 3 {
+			return "False"
+		}
+		return "True"
+	})
+	var slept []time.Duration
+	testutil.Swap(t, &healthSleepFn, func(_ context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	})
+
+	out, err := testutil.ExecuteCommand(newHealthCommand(), "--wait", "5m")
+	require.NoError(t, err)
+	assert.Contains(t, out, "CLUSTER HEALTH: HEALTHY")
+	assert.Equal(t, []time.Duration{healthPollInterval, healthPollInterval}, slept)
+
+	testutil.Swap(t, &healthSleepFn, func(context.Context, time.Duration) error { return context.DeadlineExceeded })
+	passes = 0
+	_, err = testutil.ExecuteCommand(newHealthCommand(), "--wait", "5m")
+	assert.EqualError(t, err, "cluster is unhealthy: nodes k8s-1", "a timed-out wait reports the last pass")
+}
+
+func TestParseEtcdStatus(t *testing.T) {
+	member, learner, errors, ok := parseEtcdStatus(testEtcdStatus)
+	assert.True(t, ok)
+	assert.Equal(t, "a49c021e76e707db", member)
+	assert.False(t, learner)
+	assert.Empty(t, errors)
+
+	_, _, errors, ok = parseEtcdStatus(strings.TrimSuffix(testEtcdStatus, "\n") + "     etcdserver: no leader\n")
+	assert.True(t, ok)
+	assert.Equal(t, "etcdserver: no leader", errors)
+
+	_, _, _, ok = parseEtcdStatus("")
+	assert.False(t, ok)
+}
@@ -0,0 +1,143 @@
+package talos
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/iso"
+)
+
+// factoryISOURLPattern matches a Talos Image Factory ISO download,
+// https://factory.talos.dev/image/<schematic>/<version>/<file>.iso.
+var factoryISOURLPattern = regexp.MustCompile(`^/image/([0-9a-f]{64})/(v[0-9][^/]*)/([^/]+)\.iso$`)
+
+func isISOURL(isoPath string) bool {
+	return strings.HasPrefix(isoPath, "http://") || strings.HasPrefix(isoPath, "https://")
+}
+
+// selectTrueNASISOPath resolves deploy-vm --iso-path: a URL is downloaded
+// into the ISO dataset first, and either way the file must exist on the NAS
+// before the VM is created.
+func selectTrueNASISOPath(logger *common.ColorLogger, host, isoPath string) (*trueNASISOSelection, error) {
+	if !isISOURL(isoPath) {
+		return verifyTrueNASISOFile(logger, host, isoPath)
+	}
+	filename, schematicID, talosVersion, err := trueNASISOFilename(isoPath)
+	if err != nil {
+		return nil, err
+	}
+	downloadConfig := iso.GetDefaultConfig()
+	downloadConfig.ISOURL = isoPath
+	downloadConfig.ISOFilename = filename
+	logger.Info("Downloading %s to the NAS as %s...", isoPath, filename)
+	if err := newISODownloaderFn().DownloadCustomISO(downloadConfig); err != nil {
+		return nil, fmt.Errorf("failed to download --iso-path %s to TrueNAS: %w", isoPath, err)
+	}
+	selection, err := verifyTrueNASISOFile(logger, host, filepath.Join(downloadConfig.ISOStoragePath, filename))
+	if err != nil {
+		return nil, err
+	}
+	if schematicID != "" {
+		selection.SchematicID, selection.TalosVersion = schematicID, talosVersion
+	}
+	return selection, nil
+}
+
+// trueNASISOFilename names the file an ISO URL is downloaded to: a factory
+// image gets its schematic and version in the name (every factory ISO is
+// metal-amd64.iso), any other URL keeps its own .iso file name.
+func trueNASISOFilename(isoURL string) (filename, schematicID, talosVersion string, err error) {
+	parsed, err := url.Parse(isoURL)
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid --iso-path %q: %w", isoURL, err)
+	}
+	if parsed.Host == "factory.talos.dev" {
+		if m := factoryISOURLPattern.FindStringSubmatch(parsed.Path); m != nil {
+			return fmt.Sprintf("%s-%s-%s.iso", m[3], m[1][:8], m[2]), m[1], m[2], nil
+		}
+	}
+	filename = path.Base(parsed.Path)
+	if !strings.HasSuffix(strings.ToLower(filename), ".iso") {
+		return "", "", "", fmt.Errorf("invalid --iso-path %q: the URL does not name a .iso file", isoURL)
+	}
+	return filename, "", "", nil
+}
+
+// verifyTrueNASISOFile checks over SSH that isoPath is a non-empty file on
+// the NAS.
+func verifyTrueNASISOFile(logger *common.ColorLogger, host, isoPath string) (*trueNASISOSelection, error) {
+	if !strings.HasPrefix(isoPath, "/mnt/") {
+		return nil, fmt.Errorf("invalid --iso-path %q: use a file under /mnt/ on the NAS or an http(s) URL", isoPath)
+	}
+	sshClient := newTrueNASSSHClientFn(trueNASSSHConfig(host))
+	if err := sshClient.Connect(); err != nil {
+		return nil, fmt.Errorf("cannot verify --iso-path %s: failed to connect to TrueNAS over SSH: %w", isoPath, err)
+	}
+	defer func() {
+		if closeErr := sshClient.Close(); closeErr != nil {
+			logger.Warn("Failed to close SSH client: %v", closeErr)
+		}
+	}()
+	exists, size, err := sshClient.VerifyFile(isoPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot verify --iso-path %s: %w", isoPath, err)
+	}
+	if !exists || size == 0 {
+		return nil, fmt.Errorf("ISO %s does not exist on the NAS or is empty; upload it, or pass an http(s) URL to download it", isoPath)
+	}
+	versionConfig, err := repoVersions()
+	if err != nil {
+		return nil, err
+	}
+	logger.Success("Using ISO: %s (size: %d bytes)", isoPath, size)
+	return &trueNASISOSelection{
+		ISOPath:      isoPath,
+		TalosVersion: versionConfig.TalosVersion,
+		CustomISO:    true,
+	}, nil
+}
+
+func trueNASISOPathDryRunLine(isoPath string) string {
+	if !isISOURL(isoPath) {
+		return fmt.Sprintf("ISO: %s", isoPath)
+	}
+	filename, _, _, err := trueNASISOFilename(isoPath)
+	if err != nil {
+		return fmt.Sprintf("ISO: %s (%v)", isoPath, err)
+	}
+	return fmt.Sprintf("ISO: %s (downloaded to %s first)", isoPath, filepath.Join(iso.GetDefaultConfig().ISOStoragePath, filename))
+}
+
+// planTrueNASISOSelection is resolveTrueNASISOSelection for a dry run: an
+// ISO already on the NAS is verified over SSH, one that would be generated
+// or downloaded is only named, with the step that would create it.
+func planTrueNASISOSelection(logger *common.ColorLogger, host string, generateISO bool, isoPath string) (*trueNASISOSelection, []string, error) {
+	if !generateISO && !isISOURL(isoPath) {
+		selection, err := resolveTrueNASISOSelection(logger, host, false, isoPath)
+		return selection, nil, err
+	}
+	versionConfig, err := repoVersions()
+	if err != nil {
+		return nil, nil, err
+	}
+	storagePath := iso.GetDefaultConfig().ISOStoragePath
+	selection := &trueNASISOSelection{TalosVersion: versionConfig.TalosVersion, CustomISO: true}
+	if generateISO {
+		selection.ISOPath = filepath.Join(storagePath, "metal-amd64-<schematic>.iso")
+		return selection, []string{"Generate a Talos ISO from schematic.yaml and download it to " + storagePath}, nil
+	}
+	filename, schematicID, talosVersion, err := trueNASISOFilename(isoPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	selection.ISOPath = filepath.Join(storagePath, filename)
+	if schematicID != "" {
+		selection.SchematicID, selection.TalosVersion = schematicID, talosVersion
+	}
+	return selection, []string{fmt.Sprintf("Download %s to the NAS as %s", isoPath, selection.ISOPath)}, nil
+}
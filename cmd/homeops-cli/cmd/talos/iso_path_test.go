@@ -0,0 +1,69 @@
+package talos
+
+import (
+	"context"
+	"testing"
+
+	"homeops-cli/internal/constants"
+	"homeops-cli/internal/ssh"
+	"homeops-cli/internal/testutil"
+	"homeops-cli/internal/truenas"
+	"homeops-cli/internal/vmlifecycle"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const factoryISOURL = "https://factory.talos.dev/image/376567988ad370138ad8b2698212367b8edcb69b5fd68c80be1f2ec7d603b4ba/v1.9.5/metal-amd64.iso"
+
+func TestDeployVMISOPathURLIsDownloadedAndBooted(t *testing.T) {
+	manager := &fakeTrueNASVMManager{}
+	downloader := &fakeISODownloader{}
+	sshClient := &fakeTrueNASSSHClient{exists: true, size: 1 << 20}
+	testutil.Swap(t, &vmlifecycle.NewTrueNASVMManagerFn, func(string, string, int, bool) vmlifecycle.TrueNASVMManager { return manager })
+	testutil.Swap(t, &vmlifecycle.ResolveSecretKeyFn, func(string) string { return "nas-admin" })
+	testutil.Swap(t, &spinWithProgressFn, func(_ string, fn func(func(string)) error) error { return fn(func(string) {}) })
+	testutil.Swap(t, &newISODownloaderFn, func() isoDownloader { return downloader })
+	testutil.Swap(t, &newTrueNASSSHClientFn, func(ssh.SSHConfig) trueNASSSHClient { return sshClient })
+	t.Setenv(constants.EnvTrueNASHost, "nas.example.test")
+	t.Setenv(constants.EnvTrueNASAPIKey, "api-key-placeholder")
+	t.Setenv(constants.EnvSPICEPassword, "spice-placeholder")
+
+	require.NoError(t, deployVMWithPattern(context.Background(), "app01", "flashstor/VM", 8192, 4, 40, 100, "", factoryISOURL, true, false, false, false, false, false, false, false, truenas.CPUPlacement{}, truenas.ZVolOptions{}, nil, trueNASIPWait{}))
+	require.Len(t, downloader.configs, 1)
+	assert.Equal(t, factoryISOURL, downloader.configs[0].ISOURL)
+	assert.Equal(t, "metal-amd64-37656798-v1.9.5.iso", downloader.configs[0].ISOFilename, "the prepared metal-amd64.iso is not replaced")
+	require.Len(t, manager.deployed, 1)
+	deployed := manager.deployed[0]
+	assert.Equal(t, "/mnt/flashstor/ISO/metal-amd64-37656798-v1.9.5.iso", deployed.TalosISO, "the CD-ROM boots the downloaded image")
+	assert.Equal(t, "376567988ad370138ad8b2698212367b8edcb69b5fd68c80be1f2ec7d603b4ba", deployed.SchematicID)
+	assert.Equal(t, "v1.9.5", deployed.TalosVersion)
+
+	sshClient.exists = false
+	err := deployVMWithPattern(context.Background(), "app02", "flashstor/VM", 8192, 4, 40, 100, "", "/mnt/tank/isos/custom.iso", true, false, false, false, false, false, false, false, truenas.CPUPlacement{}, truenas.ZVolOptions{}, nil, trueNASIPWait{})
+	require.EqualError(t, err, "ISO /mnt/tank/isos/custom.iso does not exist on the NAS or is empty; upload it, or pass an http(s) URL to download it")
+	assert.Len(t, manager.deployed, 1, "no VM is created without its ISO")
+}
+
+func TestTrueNASISOFilename(t *testing.T) {
+	filename, schematic, version, err := trueNASISOFilename(factoryISOURL)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"metal-amd64-37656798-v1.9.5.iso", "376567988ad370138ad8b2698212367b8edcb69b5fd68c80be1f2ec7d603b4ba", "v1.9.5"}, []string{filename, schematic, version})
+
+	filename, schematic, _, err = trueNASISOFilename("https://mirror.example.test/isos/talos-1.9.iso?token=x")
+	require.NoError(t, err)
+	assert.Equal(t, "talos-1.9.iso", filename)
+	assert.Empty(t, schematic)
+
+	_, _, _, err = trueNASISOFilename("https://factory.talos.dev/image/abc/v1.9.5/metal-amd64.raw.xz")
+	require.EqualError(t, err, `invalid --iso-path "https://factory.talos.dev/image/abc/v1.9.5/metal-amd64.raw.xz": the URL does not name a .iso file`)
+}
+
+func TestDeployVMISOPathFlagChecks(t *testing.T) {
+	_, err := testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "proxmox", "--name", "k8s-0", "--iso-path", factoryISOURL, "--dry-run", "--skip-ip-check")
+	require.ErrorContains(t, err, "--iso-path is only supported with --provider truenas")
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "truenas", "--name", "k8s0", "--iso-path", factoryISOURL, "--generate-iso", "--dry-run", "--skip-ip-check")
+	require.ErrorContains(t, err, "--iso-path and --generate-iso cannot be combined")
+	_, err = verifyTrueNASISOFile(nil, "nas", "isos/custom.iso")
+	require.EqualError(t, err, `invalid --iso-path "isos/custom.iso": use a file under /mnt/ on the NAS or an http(s) URL`)
+}
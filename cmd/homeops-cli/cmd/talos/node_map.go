@@ -0,0 +1,180 @@
+package talos
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"homeops-cli/internal/vsphere"
+)
+
+// A node map (talos deploy-vm --node-map nodes.yaml) fixes each VM's MAC, so
+// a DHCP server that pins addresses by MAC hands a redeployed node the same
+// lease. It is keyed by VM name; ip and datastore are optional:
+//
+//	worker-0:
+//	  mac: 00:50:56:00:00:21
+//	  ip: 192.168.122.21
+//	worker-1:
+//	  mac: 00:50:56:00:00:22
+//	  datastore: local-nvme2
+//
+// On vSphere the ip feeds the node templates like --node-ips and the
+// datastore holds both disks unless --boot-datastore or --openebs-datastore
+// is given. On TrueNAS the datastore is the ZVol pool unless --pool is given.
+
+// vSphereStaticMACRange bounds the MACs vSphere accepts for a manually
+// assigned address; MACs are compared in lowercase colon form.
+var vSphereStaticMACRange = [2]string{"00:50:56:00:00:00", "00:50:56:3f:ff:ff"}
+
+// nodeMapEntry is one VM's fixed addresses.
+type nodeMapEntry struct {
+	MAC       string `yaml:"mac"`
+	IP        string `yaml:"ip,omitempty"`
+	Datastore string `yaml:"datastore,omitempty"`
+}
+
+// nodeMap is the parsed node map, keyed by VM name.
+type nodeMap map[string]nodeMapEntry
+
+// loadNodeMap reads and validates a node map file. Unknown keys are errors.
+func loadNodeMap(path string) (nodeMap, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- operator-supplied node map
+	if err != nil {
+		return nil, fmt.Errorf("failed to read node map %s: %w", path, err)
+	}
+	nodes, err := parseNodeMap(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node map %s: %w", path, err)
+	}
+	return nodes, nil
+}
+
+// parseNodeMap decodes and validates node map YAML. MACs are normalized to
+// lowercase colon form.
+func parseNodeMap(data []byte) (nodeMap, error) {
+	nodes := nodeMap{}
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&nodes); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no nodes listed")
+	}
+
+	var problems []string
+	macs, ips := map[string]string{}, map[string]string{}
+	for _, name := range nodes.names() {
+		entry := nodes[name]
+		hw, err := net.ParseMAC(entry.MAC)
+		switch {
+		case entry.MAC == "":
+			problems = append(problems, name+".mac: required")
+		case err != nil || len(hw) != 6:
+			problems = append(problems, fmt.Sprintf("%s.mac: %q is not a MAC address", name, entry.MAC))
+		default:
+			entry.MAC = hw.String()
+			if other, ok := macs[entry.MAC]; ok {
+				problems = append(problems, fmt.Sprintf("%s.mac: %s is already used by %s", name, entry.MAC, other))
+			}
+			macs[entry.MAC] = name
+		}
+		if entry.IP != "" {
+			if net.ParseIP(entry.IP) == nil {
+				problems = append(problems, fmt.Sprintf("%s.ip: %q is not an IP address", name, entry.IP))
+			} else if other, ok := ips[entry.IP]; ok {
+				problems = append(problems, fmt.Sprintf("%s.ip: %s is already used by %s", name, entry.IP, other))
+			}
+			ips[entry.IP] = name
+		}
+		nodes[name] = entry
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(problems, "\n"))
+	}
+	return nodes, nil
+}
+
+func (m nodeMap) names() []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// check fails when the map lists fewer nodes than the deploy creates or
+// misses one of vmNames. Entries for other VMs are ignored, so one map can
+// serve every deploy.
+func (m nodeMap) check(vmNames []string) error {
+	if len(m) < len(vmNames) {
+		return fmt.Errorf("--node-map lists %d nodes for %d VMs (%s)", len(m), len(vmNames), strings.Join(vmNames, ", "))
+	}
+	var missing []string
+	for _, name := range vmNames {
+		if _, ok := m[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("--node-map has no entry for %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// validateVSphereMACs rejects MACs vSphere would refuse to assign manually.
+func (m nodeMap) validateVSphereMACs(vmNames []string) error {
+	for _, name := range vmNames {
+		if mac := m[name].MAC; mac < vSphereStaticMACRange[0] || mac > vSphereStaticMACRange[1] {
+			return fmt.Errorf("--node-map: %s's MAC %s is outside vSphere's static range %s-%s", name, mac, vSphereStaticMACRange[0], vSphereStaticMACRange[1])
+		}
+	}
+	return nil
+}
+
+// ips returns the IPs of vmNames in order, or nil when any is missing.
+func (m nodeMap) ips(vmNames []string) []string {
+	ips := make([]string, len(vmNames))
+	for i, name := range vmNames {
+		if ips[i] = m[name].IP; ips[i] == "" {
+			return nil
+		}
+	}
+	return ips
+}
+
+// apply sets config's MAC and datastore. --boot-datastore and
+// --openebs-datastore still take precedence over the datastore.
+func (m nodeMap) apply(config *vsphere.VMConfig) {
+	entry, ok := m[config.Name]
+	if !ok {
+		return
+	}
+	config.MacAddress = entry.MAC
+	if entry.Datastore != "" {
+		config.Datastore = entry.Datastore
+	}
+}
+
+// print echoes the mapping vmNames were deployed with.
+func (m nodeMap) print(out io.Writer, vmNames []string) {
+	_, _ = fmt.Fprintln(out, "Node map:")
+	for _, name := range vmNames {
+		entry := m[name]
+		line := "  " + name + " → " + entry.MAC
+		if entry.IP != "" {
+			line += " → " + entry.IP
+		}
+		if entry.Datastore != "" {
+			line += " (datastore " + entry.Datastore + ")"
+		}
+		_, _ = fmt.Fprintln(out, line)
+	}
+}
@@ -0,0 +1,121 @@
+package talos
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/testutil"
+	"homeops-cli/internal/vmlifecycle"
+)
+
+func writeNodeMap(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "nodes.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestParseNodeMap(t *testing.T) {
+	nodes, err := parseNodeMap([]byte("worker-0:\n  mac: 00-50-56-00-00-2A\n  ip: 192.168.122.21\nworker-1:\n  mac: 00:50:56:00:00:22\n  datastore: local-nvme2\n"))
+	require.NoError(t, err)
+	assert.Equal(t, nodeMap{
+		"worker-0": {MAC: "00:50:56:00:00:2a", IP: "192.168.122.21"},
+		"worker-1": {MAC: "00:50:56:00:00:22", Datastore: "local-nvme2"},
+	}, nodes, "MACs are normalized")
+
+	_, err = parseNodeMap([]byte("worker-0:\n  mac: 00:50:56:00:00:21\n  ip: 10.0.0.1\n" +
+		"worker-1:\n  mac: 00:50:56:00:00:21\n  ip: 10.0.0.1\n" +
+		"worker-2:\n  mac: not-a-mac\n" +
+		"worker-3:\n  ip: 10.0.0.300\n"))
+	assert.EqualError(t, err, "worker-1.mac: 00:50:56:00:00:21 is already used by worker-0\n"+
+		"worker-1.ip: 10.0.0.1 is already used by worker-0\n"+
+		"worker-2.mac: \"not-a-mac\" is not a MAC address\n"+
+		"worker-3.mac: required\n"+
+		"worker-3.ip: \"10.0.0.300\" is not an IP address")
+
+	_, err = parseNodeMap([]byte("worker-0:\n  mac: 00:50:56:00:00:21\n  hostname: w0\n"))
+	assert.ErrorContains(t, err, "field hostname not found")
+	_, err = parseNodeMap(nil)
+	assert.EqualError(t, err, "no nodes listed")
+}
+
+func TestNodeMapCheck(t *testing.T) {
+	nodes := nodeMap{"worker-0": {MAC: "00:50:56:00:00:21"}, "worker-2": {MAC: "00:50:56:00:00:23"}}
+	assert.EqualError(t, nodes.check([]string{"worker-0", "worker-1", "worker-2"}), "--node-map lists 2 nodes for 3 VMs (worker-0, worker-1, worker-2)")
+	assert.EqualError(t, nodes.check([]string{"worker-0", "worker-1"}), "--node-map has no entry for worker-1")
+	assert.NoError(t, nodes.check([]string{"worker-2"}), "entries for other VMs are ignored")
+
+	nodes["worker-1"] = nodeMapEntry{MAC: "00:50:56:40:00:00"}
+	assert.EqualError(t, nodes.validateVSphereMACs([]string{"worker-0", "worker-1"}), "--node-map: worker-1's MAC 00:50:56:40:00:00 is outside vSphere's static range 00:50:56:00:00:00-00:50:56:3f:ff:ff")
+	assert.NoError(t, nodes.validateVSphereMACs([]string{"worker-0", "worker-2"}))
+}
+
+func TestDeployVMOnVSphereWithNodeMap(t *testing.T) {
+	testutil.Swap(t, &vmlifecycle.GetVSphereHostFn, func() (string, error) { return "esxi.local", nil })
+	testutil.Swap(t, &vmlifecycle.GetVSphereCredsFn, func() (string, string, string, error) { return "esxi.local", "user", "pass", nil })
+	deployer := &fakeVSphereDeployer{}
+	testutil.Swap(t, &newVSphereDeployerFn, func(host, username, password string) (vsphereVMDeployer, error) { return deployer, nil })
+	var gotIPs []string
+	testutil.Swap(t, &writeVSphereNodeTemplatesFn, func(_ io.Writer, vmNames, nodeIPs []string, _ string) error {
+		gotIPs = nodeIPs
+		return nil
+	})
+	var stdout bytes.Buffer
+	testutil.Swap[io.Writer](t, &deployVMStdout, &stdout)
+	path := writeNodeMap(t, "worker-0:\n  mac: 00:50:56:00:00:21\n  ip: 192.168.122.21\n"+
+		"worker-1:\n  mac: 00:50:56:00:00:22\n  ip: 192.168.122.22\n  datastore: local-nvme2\n"+
+		"worker-2:\n  mac: 00:50:56:00:00:23\n  ip: 192.168.122.23\n")
+	base := []string{"--provider", "vsphere", "--name", "worker", "--datastore", "fast-ds", "--network", "vl999", "--skip-ip-check", "--node-map", path}
+	args := append(base[:len(base):len(base)], "--node-count", "3")
+
+	var macs [][]string
+	for range 2 {
+		_, err := testutil.ExecuteCommand(newDeployVMCommand(), args...)
+		require.NoError(t, err)
+		var run []string
+		for _, config := range deployer.deployedConfigs {
+			run = append(run, config.MacAddress)
+		}
+		macs = append(macs, run)
+	}
+	assert.Equal(t, []string{"00:50:56:00:00:21", "00:50:56:00:00:22", "00:50:56:00:00:23"}, macs[0])
+	assert.Equal(t, macs[0], macs[1], "a redeploy with the same map gets the same MACs")
+	assert.Equal(t, "fast-ds", deployer.deployedConfigs[0].Datastore)
+	assert.Equal(t, "local-nvme2", deployer.deployedConfigs[1].Datastore)
+	assert.Equal(t, []string{"192.168.122.21", "192.168.122.22", "192.168.122.23"}, gotIPs, "the map's IPs feed the node templates")
+	assert.Contains(t, stdout.String(), "Node map:\n"+
+		"  worker-0 → 00:50:56:00:00:21 → 192.168.122.21\n"+
+		"  worker-1 → 00:50:56:00:00:22 → 192.168.122.22 (datastore local-nvme2)\n"+
+		"  worker-2 → 00:50:56:00:00:23 → 192.168.122.23\n")
+
+	deployer.deployedConfigs = nil
+	_, err := testutil.ExecuteCommand(newDeployVMCommand(), append(base, "--node-count", "4")...)
+	assert.EqualError(t, err, "--node-map lists 3 nodes for 4 VMs (worker-0, worker-1, worker-2, worker-3)")
+	assert.Empty(t, deployer.deployedConfigs)
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), append(args, "--mac-address", "00:50:56:00:00:99")...)
+	assert.EqualError(t, err, "--node-map sets each VM's MAC; drop --mac-address")
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "proxmox", "--name", "k8s-0", "--node-map", path)
+	assert.EqualError(t, err, "--node-map is only supported with --provider truenas or vsphere")
+}
+
+func TestDeployVMOnTrueNASWithNodeMap(t *testing.T) {
+	manager, stdout := dryRunTrueNASManager(t)
+	testutil.Swap(t, &spinWithFuncFn, func(_ string, fn func() error) error { return fn() })
+	path := writeNodeMap(t, "app01:\n  mac: 00:a0:98:00:00:01\n  datastore: tank\n")
+
+	_, err := testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "truenas", "--name", "app01", "--node-map", path, "--skip-ip-check", "--start=false")
+	require.NoError(t, err)
+	require.Len(t, manager.deployed, 1)
+	assert.Equal(t, "00:a0:98:00:00:01", manager.deployed[0].MacAddress)
+	assert.Equal(t, "tank", manager.deployed[0].StoragePool, "the map's datastore is the ZVol pool")
+	assert.Contains(t, stdout.String(), "Node map:\n  app01 → 00:a0:98:00:00:01 (datastore tank)\n")
+
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "truenas", "--name", "app02", "--node-map", path, "--skip-ip-check")
+	assert.EqualError(t, err, "--node-map has no entry for app02")
+}
@@ -0,0 +1,152 @@
+package talos
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/secrets"
+	"homeops-cli/internal/talos"
+)
+
+var (
+	prepareISOStatePathFn = func() (string, error) {
+		return secrets.ExpandHome("~/.config/homeops/state/talos-prepare-iso.json")
+	}
+	prepareISONowFn = time.Now
+	// prepareISOForce makes prepare-iso regenerate and re-upload even when
+	// the target already holds the ISO (--force).
+	prepareISOForce bool
+	// prepareISOResume continues the partial ISO download an interrupted
+	// prepare-iso left behind (--resume).
+	prepareISOResume bool
+)
+
+// preparedISO is the ISO prepare-iso last uploaded to one location. Hash
+// covers the rendered schematic, Talos version, architecture, and platform.
+type preparedISO struct {
+	Hash         string    `json:"hash"`
+	SchematicID  string    `json:"schematic_id"`
+	TalosVersion string    `json:"talos_version"`
+	URL          string    `json:"url"`
+	Size         int64     `json:"size"`
+	PreparedAt   time.Time `json:"prepared_at"`
+}
+
+// prepareISOState maps ISO locations to what was last uploaded there.
+type prepareISOState struct {
+	Locations map[string]preparedISO `json:"locations"`
+}
+
+// prepareISOHash identifies the ISO the factory would build.
+func prepareISOHash(schematic *talos.SchematicConfig, talosVersion, arch, platform string) (string, error) {
+	rendered, err := json.Marshal(schematic)
+	if err != nil {
+		return "", fmt.Errorf("failed to render schematic: %w", err)
+	}
+	sum := sha256.Sum256(fmt.Appendf(rendered, "\n%s\n%s\n%s", talosVersion, arch, platform))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func loadPrepareISOState() (prepareISOState, error) {
+	state := prepareISOState{Locations: map[string]preparedISO{}}
+	path, err := prepareISOStatePathFn()
+	if err != nil {
+		return state, err
+	}
+	data, err := os.ReadFile(path) // #nosec G304 -- fixed state file under the user's config directory
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return prepareISOState{Locations: map[string]preparedISO{}}, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if state.Locations == nil {
+		state.Locations = map[string]preparedISO{}
+	}
+	return state, nil
+}
+
+func savePrepareISOState(state prepareISOState) error {
+	path, err := prepareISOStatePathFn()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// cachedPreparedISO returns the ISO already at target.location when it was
+// built from hash and the file there still has the size recorded after its
+// upload. Any doubt (no record, a size check that fails, a missing or
+// truncated file) means the ISO is prepared again.
+func cachedPreparedISO(logger *common.ColorLogger, target isoPreparationTarget, hash string) (preparedISO, bool) {
+	if prepareISOForce || target.storedSize == nil {
+		return preparedISO{}, false
+	}
+	state, err := loadPrepareISOState()
+	if err != nil {
+		logger.Warn("Ignoring the prepare-iso cache: %v", err)
+		return preparedISO{}, false
+	}
+	record, ok := state.Locations[target.location]
+	if !ok || record.Hash != hash {
+		return preparedISO{}, false
+	}
+	size, err := target.storedSize()
+	if err != nil {
+		logger.Warn("Could not check %s: %v", target.location, err)
+		return preparedISO{}, false
+	}
+	if size == 0 || size != record.Size {
+		logger.Info("%s no longer holds the cached ISO (%d bytes, expected %d)", target.location, size, record.Size)
+		return preparedISO{}, false
+	}
+	return record, true
+}
+
+// recordPreparedISO saves what was uploaded to target.location so the next
+// run with the same schematic and version can skip it.
+func recordPreparedISO(logger *common.ColorLogger, target isoPreparationTarget, hash string, isoInfo *talos.ISOInfo) {
+	if target.storedSize == nil {
+		return
+	}
+	size, err := target.storedSize()
+	if err != nil || size == 0 {
+		logger.Warn("Not caching the ISO at %s: could not read its size (%v)", target.location, err)
+		return
+	}
+	state, err := loadPrepareISOState()
+	if err != nil {
+		logger.Warn("Replacing the unreadable prepare-iso cache: %v", err)
+	}
+	state.Locations[target.location] = preparedISO{
+		Hash:         hash,
+		SchematicID:  isoInfo.SchematicID,
+		TalosVersion: isoInfo.TalosVersion,
+		URL:          isoInfo.URL,
+		Size:         size,
+		PreparedAt:   prepareISONowFn().UTC(),
+	}
+	if err := savePrepareISOState(state); err != nil {
+		logger.Warn("Failed to save the prepare-iso cache: %v", err)
+	}
+}
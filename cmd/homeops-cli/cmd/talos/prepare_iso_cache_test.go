@@ -0,0 +1,63 @@
+package talos
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	internaltalos "homeops-cli/internal/talos"
+	"homeops-cli/internal/testutil"
+)
+
+func TestPrepareISOSkipsUnchangedISO(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "talos-prepare-iso.json")
+	testutil.Swap(t, &prepareISOStatePathFn, func() (string, error) { return statePath, nil })
+	testutil.Swap(t, &spinWithFuncFn, func(title string, fn func() error) error { return fn() })
+	var templateIDs []string
+	testutil.Swap(t, &updateNodeTemplatesWithSchematicFn, func(schematicID, talosVersion string) error {
+		templateIDs = append(templateIDs, schematicID)
+		return nil
+	})
+	factory := &fakeTalosFactoryClient{
+		schematic: &internaltalos.SchematicConfig{},
+		isoInfo:   &internaltalos.ISOInfo{URL: "https://factory.test/talos.iso", SchematicID: "schematic-123", TalosVersion: "v9.9.9"},
+	}
+	testutil.Swap(t, &newTalosFactoryClientFn, func() talosFactoryClient { return factory })
+
+	var stored int64
+	uploads := 0
+	target := isoPreparationTarget{
+		providerName: "vSphere",
+		platform:     "nocloud",
+		location:     "[datastore1] talos.iso",
+		uploadISO: func(*internaltalos.ISOInfo, func(string)) error {
+			uploads++
+			stored = 1 << 20
+			return nil
+		},
+		storedSize: func() (int64, error) { return stored, nil },
+	}
+
+	require.NoError(t, prepareISOForTarget(target))
+	factory.lastPlatform = ""
+	require.NoError(t, prepareISOForTarget(target))
+	assert.Equal(t, 1, uploads, "the second run reuses the uploaded ISO")
+	assert.Empty(t, factory.lastPlatform, "the second run does not ask the factory for an ISO")
+	assert.Equal(t, []string{"schematic-123", "schematic-123"}, templateIDs, "templates are still pointed at the cached schematic")
+
+	stored = 4096
+	require.NoError(t, prepareISOForTarget(target))
+	assert.Equal(t, 2, uploads, "a truncated datastore file is uploaded again")
+
+	testutil.Swap(t, &prepareISOForce, true)
+	require.NoError(t, prepareISOForTarget(target))
+	assert.Equal(t, 3, uploads, "--force ignores the cache")
+
+	testutil.Swap(t, &prepareISOForce, false)
+	factory.schematic = &internaltalos.SchematicConfig{}
+	factory.schematic.Customization.ExtraKernelArgs = []string{"console=ttyS1"}
+	require.NoError(t, prepareISOForTarget(target))
+	assert.Equal(t, 4, uploads, "a changed schematic is a new ISO")
+}
@@ -0,0 +1,232 @@
+package talos
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/iso"
+	"homeops-cli/internal/talos"
+	"homeops-cli/internal/vmlifecycle"
+)
+
+// prepareISOAllProviders is the --provider value for every provider.
+const prepareISOAllProviders = "all"
+
+// prepareISOForSpecs prepares the ISO on every --provider or --target.
+func prepareISOForSpecs(providers, specs []string) error {
+	targets, err := prepareISOTargets(providers, specs)
+	if err != nil {
+		return err
+	}
+	return prepareISOForTargetsFn(targets)
+}
+
+// prepareISOTargets builds the targets for --provider values and --target
+// specs. A location named twice is prepared once.
+func prepareISOTargets(providers, specs []string) ([]isoPreparationTarget, error) {
+	var all []string
+	for _, provider := range providers {
+		if provider == prepareISOAllProviders {
+			all = append(all, "proxmox", "truenas", "vsphere")
+			continue
+		}
+		all = append(all, provider)
+	}
+	all = append(all, specs...)
+
+	var targets []isoPreparationTarget
+	seen := make(map[string]bool)
+	for _, spec := range all {
+		target, err := prepareISOTargetFromSpec(spec)
+		if err != nil {
+			return nil, fmt.Errorf("target %q: %w", spec, err)
+		}
+		if seen[target.location] {
+			continue
+		}
+		seen[target.location] = true
+		targets = append(targets, target)
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no prepare-iso targets given")
+	}
+	return targets, nil
+}
+
+// prepareISOTargetFromSpec parses <provider>[@<host>][:<datastore>]. The
+// datastore is a Proxmox storage or vSphere datastore; only vSphere takes a
+// host.
+func prepareISOTargetFromSpec(spec string) (isoPreparationTarget, error) {
+	rest, datastore, hasDatastore := strings.Cut(spec, ":")
+	providerName, host, hasHost := strings.Cut(rest, "@")
+	provider, err := vmlifecycle.NormalizeVMProvider(providerName)
+	if err != nil {
+		return isoPreparationTarget{}, err
+	}
+	if (hasDatastore && datastore == "") || (hasHost && host == "") {
+		return isoPreparationTarget{}, fmt.Errorf("empty host or datastore; use <provider>[@<host>][:<datastore>]")
+	}
+
+	var target isoPreparationTarget
+	switch provider {
+	case "vsphere":
+		dest := defaultVSphereISODest()
+		dest.host = host
+		if hasDatastore {
+			dest.datastore = datastore
+		}
+		target = vSphereISOTarget(dest)
+	case "proxmox":
+		if hasHost {
+			return isoPreparationTarget{}, fmt.Errorf("proxmox targets take no @host")
+		}
+		storage := "local"
+		if hasDatastore {
+			storage = datastore
+		}
+		if target, err = proxmoxISOTarget(storage); err != nil {
+			return isoPreparationTarget{}, err
+		}
+	case "truenas":
+		if hasHost || hasDatastore {
+			return isoPreparationTarget{}, fmt.Errorf("truenas targets take no @host or :datastore; the ISO goes to hypervisors.truenas.iso_dir")
+		}
+		target = trueNASISOTarget()
+	default:
+		return isoPreparationTarget{}, fmt.Errorf("unsupported provider: %s", providerName)
+	}
+
+	target.name = provider
+	if hasHost {
+		target.name += "@" + host
+	}
+	if hasDatastore {
+		target.name += ":" + datastore
+	}
+	return target, nil
+}
+
+func isoTargetsLabel(targets []isoPreparationTarget) string {
+	if len(targets) == 1 {
+		return targets[0].providerName
+	}
+	names := make([]string, len(targets))
+	for i, target := range targets {
+		names[i] = target.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// uploadISOToTargets uploads the ISO to every target at once under one
+// spinner (step 3 of prepare-iso) and returns each target's error. Targets
+// with uploadLocal share one verified download, which is kept for --resume
+// when one of their uploads fails.
+func uploadISOToTargets(logger *common.ColorLogger, isoInfo *talos.ISOInfo, targets []isoPreparationTarget) []error {
+	title := targets[0].uploadSpinner
+	if len(targets) == 1 {
+		logger.Info("STEP 3: %s", targets[0].uploadStep)
+	} else {
+		logger.Info("STEP 3: Uploading ISO to %d targets...", len(targets))
+		title = fmt.Sprintf("Uploading ISO to %d targets", len(targets))
+	}
+
+	errs := make([]error, len(targets))
+	var localPath string
+	_ = spinWithProgressFn(title, func(status func(string)) error {
+		board := newISOUploadBoard(targets, status)
+		var local []int
+		for i, target := range targets {
+			if target.uploadLocal != nil {
+				local = append(local, i)
+			}
+		}
+		download := sync.OnceValues(func() (iso.Digest, error) {
+			path, digest, err := downloadVerifiedISO(isoInfo.URL, board.reporter(local...))
+			localPath = path
+			return digest, err
+		})
+
+		var wg sync.WaitGroup
+		for i, target := range targets {
+			wg.Go(func() {
+				report := board.reporter(i)
+				if target.uploadLocal == nil {
+					errs[i] = target.uploadISO(isoInfo, report)
+				} else if digest, err := download(); err != nil {
+					errs[i] = err
+				} else {
+					errs[i] = target.uploadLocal(localPath, digest, report)
+				}
+				board.finish(i, errs[i])
+			})
+		}
+		wg.Wait()
+		return nil
+	})
+
+	if localPath != "" {
+		keep := false
+		for i, target := range targets {
+			keep = keep || (target.uploadLocal != nil && errs[i] != nil)
+		}
+		if keep {
+			logger.Info("Kept %s; rerun with --resume to upload it without downloading it again", localPath)
+		} else if err := os.Remove(localPath); err != nil {
+			logger.Warn("Failed to remove temporary file %s: %v", localPath, err)
+		}
+	}
+	return errs
+}
+
+// isoUploadBoard joins each target's latest status into the spinner line,
+// e.g. "vsphere:datastore1 uploading: 40% at 9.8MiB/s | truenas done". A
+// single target's status is shown as it is.
+type isoUploadBoard struct {
+	mu     sync.Mutex
+	names  []string
+	states []string
+	status func(string)
+}
+
+func newISOUploadBoard(targets []isoPreparationTarget, status func(string)) *isoUploadBoard {
+	board := &isoUploadBoard{status: status, states: make([]string, len(targets))}
+	for i, target := range targets {
+		board.names = append(board.names, target.name)
+		board.states[i] = "waiting"
+	}
+	return board
+}
+
+// reporter returns the status func for the targets at indexes.
+func (b *isoUploadBoard) reporter(indexes ...int) func(string) {
+	return func(status string) {
+		if len(b.names) == 1 {
+			b.status(status)
+			return
+		}
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for _, i := range indexes {
+			b.states[i] = status
+		}
+		parts := make([]string, len(b.names))
+		for i, name := range b.names {
+			parts[i] = name + " " + b.states[i]
+		}
+		b.status(strings.Join(parts, " | "))
+	}
+}
+
+func (b *isoUploadBoard) finish(i int, err error) {
+	if len(b.names) == 1 {
+		return
+	}
+	if err != nil {
+		b.reporter(i)("failed")
+		return
+	}
+	b.reporter(i)("done")
+}
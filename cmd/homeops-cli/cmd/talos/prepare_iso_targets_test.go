@@ -0,0 +1,182 @@
+package talos
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	internaltalos "homeops-cli/internal/talos"
+	"homeops-cli/internal/testutil"
+	"homeops-cli/internal/vmlifecycle"
+	"homeops-cli/internal/vsphere"
+)
+
+func TestPrepareISOTargetsFromSpecs(t *testing.T) {
+	targets, err := prepareISOTargets(nil, []string{"vsphere:datastore1", "esxi@esxi-02.local:datastore2", "truenas", "vsphere:datastore1"})
+	require.NoError(t, err)
+	require.Len(t, targets, 3, "a location named twice is prepared once")
+	assert.Equal(t, "vsphere:datastore1", targets[0].name)
+	assert.Equal(t, "[datastore1] "+vsphere.DefaultISOFilename, targets[0].location)
+	assert.Equal(t, "vsphere@esxi-02.local:datastore2", targets[1].name)
+	assert.Equal(t, "esxi-02.local [datastore2] "+vsphere.DefaultISOFilename, targets[1].location)
+	assert.Equal(t, "truenas", targets[2].name)
+	assert.Equal(t, "metal", targets[2].platform)
+
+	targets, err = prepareISOTargets([]string{"all"}, nil)
+	require.NoError(t, err)
+	var names []string
+	for _, target := range targets {
+		names = append(names, target.name)
+	}
+	assert.Equal(t, []string{"proxmox", "truenas", "vsphere"}, names)
+
+	for spec, want := range map[string]string{
+		"truenas:tank":      "truenas targets take no @host or :datastore",
+		"proxmox@pve2":      "proxmox targets take no @host",
+		"vsphere:":          "empty host or datastore",
+		"hyperv:datastore1": "hyperv",
+	} {
+		_, err := prepareISOTargets(nil, []string{spec})
+		require.Error(t, err, spec)
+		assert.Contains(t, err.Error(), `target "`+spec+`": `)
+		assert.Contains(t, err.Error(), want)
+	}
+}
+
+func TestPrepareISOCommandTargetFlags(t *testing.T) {
+	var got []string
+	testutil.Swap(t, &prepareISOForTargetsFn, func(targets []isoPreparationTarget) error {
+		for _, target := range targets {
+			got = append(got, target.name)
+		}
+		return nil
+	})
+
+	_, err := testutil.ExecuteCommand(newPrepareISOCommand(), "--target", "vsphere:datastore1", "--target", "truenas")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vsphere:datastore1", "truenas"}, got)
+
+	_, err = testutil.ExecuteCommand(newPrepareISOCommand(), "--provider", "vsphere", "--target", "truenas")
+	assert.ErrorContains(t, err, "--provider and --target cannot be used together")
+}
+
+// fanOutFactory loads a fresh schematic each time, as the real client does,
+// and records the platform of every ISO it builds.
+type fanOutFactory struct {
+	platforms []string
+}
+
+func (f *fanOutFactory) LoadSchematicFromTemplate() (*internaltalos.SchematicConfig, error) {
+	return &internaltalos.SchematicConfig{}, nil
+}
+
+func (f *fanOutFactory) GenerateISOFromSchematic(config *internaltalos.SchematicConfig, talosVersion, architecture, platform string) (*internaltalos.ISOInfo, error) {
+	f.platforms = append(f.platforms, platform)
+	return &internaltalos.ISOInfo{URL: "https://factory.test/" + platform + ".iso", SchematicID: "schematic-" + platform, TalosVersion: talosVersion}, nil
+}
+
+func TestPrepareISOForTargetsFansOut(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	statePath := filepath.Join(t.TempDir(), "talos-prepare-iso.json")
+	testutil.Swap(t, &prepareISOStatePathFn, func() (string, error) { return statePath, nil })
+	testutil.Swap(t, &spinWithFuncFn, func(title string, fn func() error) error { return fn() })
+	testutil.Swap(t, &spinWithProgressFn, func(_ string, fn func(func(string)) error) error { return fn(func(string) {}) })
+	testutil.Swap(t, &updateNodeTemplatesWithSchematicFn, func(schematicID, talosVersion string) error { return nil })
+	testutil.Swap(t, &isoTransferRetryDelay, 0)
+	factory := &fanOutFactory{}
+	testutil.Swap(t, &newTalosFactoryClientFn, func() talosFactoryClient { return factory })
+	downloader := &fakeISODownloader{storedSize: int64(len("iso-bytes"))}
+	testutil.Swap(t, &newISODownloaderFn, func() isoDownloader { return downloader })
+
+	var downloads int
+	testutil.Swap(t, &httpDoFn, func(req *http.Request) (*http.Response, error) {
+		downloads++
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("iso-bytes")), ContentLength: int64(len("iso-bytes"))}, nil
+	})
+	testutil.Swap(t, &vmlifecycle.GetVSphereCredsFn, func() (string, string, string, error) { return "esxi.local", "root", "secret", nil })
+	var mu sync.Mutex
+	var uploads []string
+	failDatastore := "datastore2"
+	testutil.Swap(t, &vmlifecycle.NewVSphereClientFn, func(host, username, password string, insecure bool) vmlifecycle.VSphereClient {
+		mu.Lock()
+		defer mu.Unlock()
+		client := &fakeVSphereClient{fileSizes: map[string]int64{
+			"[datastore1] " + vsphere.DefaultISOFilename: int64(len("iso-bytes")),
+			"[datastore2] " + vsphere.DefaultISOFilename: int64(len("iso-bytes")),
+		}}
+		return &recordingVSphereClient{fakeVSphereClient: client, record: func(upload string) {
+			mu.Lock()
+			defer mu.Unlock()
+			uploads = append(uploads, upload)
+		}, failDatastore: failDatastore}
+	})
+
+	specs := []string{"vsphere:datastore1", "vsphere:datastore2", "truenas"}
+	targets, err := prepareISOTargets(nil, specs)
+	require.NoError(t, err)
+	err = prepareISOForTargets(targets)
+	require.EqualError(t, err, "ISO upload failed for 1 of 3 targets; rerun to retry them, the others are skipped as prepared")
+	assert.ElementsMatch(t, []string{"metal", "nocloud"}, factory.platforms, "each platform's ISO is generated once")
+	assert.Equal(t, 1, downloads, "both datastores upload the same local copy")
+	assert.Len(t, downloader.configs, 1, "TrueNAS pulls its ISO itself")
+
+	failDatastore = ""
+	uploads, factory.platforms = nil, nil
+	targets, err = prepareISOTargets(nil, specs)
+	require.NoError(t, err)
+	require.NoError(t, prepareISOForTargets(targets))
+	assert.Equal(t, []string{"nocloud"}, factory.platforms, "only the failed target is prepared again")
+	assert.Len(t, downloader.configs, 1)
+	var isoUploads []string
+	for _, upload := range uploads {
+		if !strings.HasSuffix(upload, ".sha256") {
+			isoUploads = append(isoUploads, upload)
+		}
+	}
+	require.Len(t, isoUploads, 1)
+	assert.Contains(t, isoUploads[0], "|datastore2|"+vsphere.DefaultISOFilename)
+
+	// A fresh run with the cache cleared leaves the same file on both datastores.
+	testutil.Swap(t, &prepareISOForce, true)
+	uploads, downloads = nil, 0
+	targets, err = prepareISOTargets(nil, specs)
+	require.NoError(t, err)
+	require.NoError(t, prepareISOForTargets(targets))
+	assert.Equal(t, 1, downloads)
+	isoUploads = nil
+	for _, upload := range uploads {
+		if !strings.HasSuffix(upload, ".sha256") {
+			isoUploads = append(isoUploads, upload)
+		}
+	}
+	require.Len(t, isoUploads, 2)
+	path, _, _ := strings.Cut(isoUploads[0], "|")
+	assert.ElementsMatch(t, []string{
+		path + "|datastore1|" + vsphere.DefaultISOFilename,
+		path + "|datastore2|" + vsphere.DefaultISOFilename,
+	}, isoUploads)
+	assert.NoFileExists(t, path, "the shared local copy is removed once every upload succeeded")
+}
+
+// recordingVSphereClient reports uploads to a shared log and fails those to
+// failDatastore.
+type recordingVSphereClient struct {
+	*fakeVSphereClient
+	record        func(string)
+	failDatastore string
+}
+
+func (c *recordingVSphereClient) UploadISOToDatastore(localFilePath, datastoreName, remoteFileName string) error {
+	c.record(localFilePath + "|" + datastoreName + "|" + remoteFileName)
+	if datastoreName == c.failDatastore {
+		return errors.New("datastore is read-only")
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package talos
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"homeops-cli/internal/common"
+	versionconfig "homeops-cli/internal/config"
+)
+
+type renderConfigOptions struct {
+	nodeIP         string
+	out            string
+	all            bool
+	resolveSecrets bool
+}
+
+func newRenderConfigCommand() *cobra.Command {
+	var opts renderConfigOptions
+
+	cmd := &cobra.Command{
+		Use:   "render-config",
+		Short: "Render a node's Talos machine config without applying it",
+		Long: `Renders the machine config apply-node would send to a node: its machine
+type's base template merged with talos/nodes/<ip>.yaml. Nothing talks to the
+node, so it works offline; the machine type is read from the node patch.
+
+Secret references are left as placeholders unless --resolve-secrets is set, so
+the output is safe to share. With --resolve-secrets it is byte-for-byte what
+apply-node applies.
+
+--all renders every node in cluster.nodes into the --out directory as <ip>.yaml.`,
+		Example: `  homeops-cli talos render-config --ip 192.168.122.10
+  homeops-cli talos render-config --ip 192.168.122.10 --out k8s-0.yaml
+  homeops-cli talos render-config --all --out rendered/`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch {
+			case opts.all && opts.nodeIP != "":
+				return fmt.Errorf("--ip and --all cannot be used together")
+			case opts.all && opts.out == "":
+				return fmt.Errorf("--all needs --out <directory>")
+			case !opts.all && opts.nodeIP == "":
+				return fmt.Errorf("--ip or --all is required")
+			}
+			return renderConfig(cmd.OutOrStdout(), opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.nodeIP, "ip", "", "Node IP address to render")
+	cmd.Flags().StringVar(&opts.out, "out", "", "Write to this file (with --all, this directory) instead of stdout")
+	cmd.Flags().BoolVar(&opts.all, "all", false, "Render every node in cluster.nodes into the --out directory")
+	cmd.Flags().BoolVar(&opts.resolveSecrets, "resolve-secrets", false, "Resolve secret references into the output (makes it sensitive)")
+
+	return cmd
+}
+
+func renderConfig(out io.Writer, opts renderConfigOptions) error {
+	logger := common.NewColorLogger()
+
+	if !opts.all {
+		config, err := renderNodeConfig(logger, opts.nodeIP, opts.resolveSecrets)
+		if err != nil {
+			return err
+		}
+		if opts.out == "" {
+			_, err := io.WriteString(out, config)
+			return err
+		}
+		if err := writeRenderedConfig(opts.out, config); err != nil {
+			return err
+		}
+		logger.Success("Rendered %s to %s", opts.nodeIP, opts.out)
+		return nil
+	}
+
+	nodes := versionconfig.Get().Cluster.Nodes
+	if len(nodes) == 0 {
+		return fmt.Errorf("no nodes in cluster.nodes in the homeops config")
+	}
+	if err := os.MkdirAll(opts.out, 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", opts.out, err)
+	}
+	for _, node := range nodes {
+		config, err := renderNodeConfig(logger, node.IP, opts.resolveSecrets)
+		if err != nil {
+			return fmt.Errorf("%s (%s): %w", node.Name, node.IP, err)
+		}
+		if err := writeRenderedConfig(filepath.Join(opts.out, node.IP+".yaml"), config); err != nil {
+			return err
+		}
+	}
+	logger.Success("Rendered %d node configs to %s", len(nodes), opts.out)
+	return nil
+}
+
+// renderNodeConfig renders nodeIP's config as apply-node does, with its
+// machine type taken from the node patch rather than the live node.
+func renderNodeConfig(logger *common.ColorLogger, nodeIP string, resolveSecrets bool) (string, error) {
+	patchTemplate := fmt.Sprintf("talos/nodes/%s.yaml", nodeIP)
+	patch, err := getTalosTemplateFn(patchTemplate)
+	if err != nil {
+		return "", fmt.Errorf("no machine config template %s: %w", patchTemplate, err)
+	}
+	rendered, err := renderMachineConfigFromEmbeddedFn(fmt.Sprintf("talos/%s.yaml", nodeTemplateMachineType(patch)), patchTemplate)
+	if err != nil {
+		return "", fmt.Errorf("failed to render config: %w", err)
+	}
+	if !resolveSecrets {
+		return string(rendered), nil
+	}
+	return injectSecretsWithSignin(logger, string(rendered))
+}
+
+// writeRenderedConfig writes 0600: with --resolve-secrets the file holds
+// plaintext secrets.
+func writeRenderedConfig(path, config string) error {
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package talos
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	versionconfig "homeops-cli/internal/config"
+	"homeops-cli/internal/testutil"
+)
+
+func stubRenderConfig(t *testing.T) {
+	t.Helper()
+	testutil.Swap(t, &getTalosTemplateFn, func(name string) (string, error) {
+		if name == "talos/nodes/10.0.0.21.yaml" {
+			return "machine:\n  type: worker\n", nil
+		}
+		return "machine:\n  type: controlplane\n", nil
+	})
+	testutil.Swap(t, &renderMachineConfigFromEmbeddedFn, func(base, patch string) ([]byte, error) {
+		return []byte("# " + base + " + " + patch + "\nmachine:\n  token: op://talos/machine/token\n"), nil
+	})
+	testutil.Swap(t, &injectSecretsFn, func(rendered string) (string, error) {
+		return strings.ReplaceAll(rendered, "op://talos/machine/token", "resolved-token"), nil
+	})
+	testutil.Swap(t, &talosctlNodeOutputFn, func(nodeIP string, args ...string) ([]byte, error) {
+		t.Fatalf("render-config must not contact node %s", nodeIP)
+		return nil, nil
+	})
+}
+
+func TestRenderConfigMatchesApplyNode(t *testing.T) {
+	stubRenderConfig(t)
+	testutil.Swap(t, &getMachineTypeFromNodeFn, func(string) (string, error) { return "worker", nil })
+	var applied string
+	testutil.Swap(t, &talosApplyConfigFn, func(nodeIP, mode, config string) ([]byte, error) {
+		applied = config
+		return nil, nil
+	})
+	_, err := testutil.ExecuteCommand(newApplyNodeCommand(), "--ip", "10.0.0.21")
+	require.NoError(t, err)
+
+	out, err := testutil.ExecuteCommand(newRenderConfigCommand(), "--ip", "10.0.0.21", "--resolve-secrets")
+	require.NoError(t, err)
+	assert.Equal(t, applied, out)
+
+	out, err = testutil.ExecuteCommand(newRenderConfigCommand(), "--ip", "10.0.0.21")
+	require.NoError(t, err)
+	assert.Equal(t, "# talos/worker.yaml + talos/nodes/10.0.0.21.yaml\nmachine:\n  token: op://talos/machine/token\n", out, "placeholders stay unless --resolve-secrets")
+}
+
+func TestRenderConfigAllWritesEveryNode(t *testing.T) {
+	stubRenderConfig(t)
+	t.Cleanup(versionconfig.SetForTesting(&versionconfig.Config{Cluster: versionconfig.ClusterConfig{Nodes: []versionconfig.Node{
+		{Name: "k8s-0", IP: "10.0.0.20"},
+		{Name: "k8s-1", IP: "10.0.0.21"},
+		{Name: "k8s-2", IP: "10.0.0.22"},
+	}}}))
+	dir := filepath.Join(t.TempDir(), "rendered")
+
+	_, err := testutil.ExecuteCommand(newRenderConfigCommand(), "--all", "--out", dir)
+	require.NoError(t, err)
+	for ip, machineType := range map[string]string{"10.0.0.20": "controlplane", "10.0.0.21": "worker", "10.0.0.22": "controlplane"} {
+		data, err := os.ReadFile(filepath.Join(dir, ip+".yaml"))
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(data), "# talos/"+machineType+".yaml + talos/nodes/"+ip+".yaml\n"), string(data))
+		info, err := os.Stat(filepath.Join(dir, ip+".yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	}
+
+	_, err = testutil.ExecuteCommand(newRenderConfigCommand(), "--all")
+	assert.EqualError(t, err, "--all needs --out <directory>")
+	_, err = testutil.ExecuteCommand(newRenderConfigCommand())
+	assert.EqualError(t, err, "--ip or --all is required")
+}
@@ -0,0 +1,400 @@
+package talos
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"homeops-cli/internal/common"
+	versionconfig "homeops-cli/internal/config"
+)
+
+const (
+	replaceNodeProbeTimeout = 3 * time.Second
+	replaceNodeBootTimeout  = 15 * time.Minute
+	replaceNodePollInterval = 5 * time.Second
+)
+
+var (
+	replaceNodeKubectlFn = func(ctx context.Context, args ...string) ([]byte, error) {
+		return common.RunCommandWithContextOutput(ctx, "kubectl", args...)
+	}
+	// talosApplyInsecureConfigFn applies a config to a node in maintenance
+	// mode, which has no client certificates yet.
+	talosApplyInsecureConfigFn = func(nodeIP, config string) ([]byte, error) {
+		cmd := common.Command("talosctl", "--nodes", nodeIP, "apply-config", "--insecure", "--file", "/dev/stdin")
+		cmd.Stdin = bytes.NewReader([]byte(config))
+		out, err := cmd.CombinedOutput()
+		return []byte(common.RedactCommandOutput(string(out))), err
+	}
+	replaceNodeWaitFn = waitForTalosMaintenance
+)
+
+// replaceNodeOptions is deploy-vm --replace-node.
+type replaceNodeOptions struct {
+	IP       string
+	Name     string
+	Provider string
+	// MAC is --mac-address; empty resolves the old node's MAC.
+	MAC string
+	// ConfigISO is true when the TrueNAS VM boots with its machine config
+	// attached, so there is nothing to apply in maintenance mode.
+	ConfigISO bool
+	DryRun    bool
+}
+
+type etcdMember struct {
+	ID       string
+	Hostname string
+	PeerURLs []string
+}
+
+// replaceNodeTarget is what verification established about the dead node.
+type replaceNodeTarget struct {
+	Node        versionconfig.Node
+	MachineType string
+	MAC         string
+	MACSource   string
+	// EtcdMember is nil for workers and for control planes that already
+	// left etcd; EtcdEndpoint is the healthy control plane used to remove it.
+	EtcdMember   *etcdMember
+	EtcdEndpoint string
+	KubeNode     bool
+}
+
+type replaceNodeStep struct {
+	Description string
+	Run         func(ctx context.Context) error
+}
+
+// replaceNodeName resolves the VM name for --replace-node: the node's own
+// name from cluster.nodes, which --name may repeat but not change.
+func replaceNodeName(cfg *versionconfig.Config, ip, name string) (string, error) {
+	node, ok := cfg.NodeByIP(ip)
+	if !ok {
+		return "", fmt.Errorf("--replace-node %s is not a node in cluster.nodes", ip)
+	}
+	if name != "" && name != node.Name {
+		return "", fmt.Errorf("--name %s does not match node %s at %s; omit --name to reuse the node's identity", name, node.Name, ip)
+	}
+	return node.Name, nil
+}
+
+// nodeTemplateMachineType reads the machine type a talos/nodes/<ip>.yaml
+// patch declares; patches without one are control planes.
+func nodeTemplateMachineType(content string) string {
+	if strings.Contains(content, "type: worker") {
+		return "worker"
+	}
+	return "controlplane"
+}
+
+// probeTalosAPI reports how a node still answers, or "" when it is gone.
+// Anything answering ping at the address would collide with the replacement,
+// so that counts too.
+func probeTalosAPI(ctx context.Context, ip string) string {
+	probeCtx, cancel := context.WithTimeout(ctx, replaceNodeProbeTimeout)
+	defer cancel()
+	if err := ipCheckDialFn(probeCtx, net.JoinHostPort(ip, "50000")); err == nil {
+		return "apid (TCP 50000) accepts connections"
+	}
+	if _, err := talosctlNodeOutputFn(ip, "version"); err == nil {
+		return "talosctl version answered"
+	}
+	if ipCheckPingFn(probeCtx, ip) {
+		return "the address answers ping; power off the old VM first"
+	}
+	return ""
+}
+
+type kubeNodeStatus struct {
+	Status struct {
+		Addresses []struct {
+			Type    string `json:"type"`
+			Address string `json:"address"`
+		} `json:"addresses"`
+		Conditions []struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+// verifyKubeNode checks that the Node object named like the dead node, if
+// any, was registered from its IP and is not Ready.
+func verifyKubeNode(ctx context.Context, node versionconfig.Node) (bool, error) {
+	output, err := replaceNodeKubectlFn(ctx, "get", "node", node.Name, "-o", "json", "--ignore-not-found")
+	if err != nil {
+		return false, fmt.Errorf("failed to read Node %s: %w", node.Name, err)
+	}
+	if len(bytes.TrimSpace(output)) == 0 {
+		return false, nil
+	}
+	var status kubeNodeStatus
+	if err := json.Unmarshal(output, &status); err != nil {
+		return false, fmt.Errorf("failed to parse Node %s: %w", node.Name, err)
+	}
+	var internal []string
+	for _, address := range status.Status.Addresses {
+		if address.Type == "InternalIP" {
+			internal = append(internal, address.Address)
+		}
+	}
+	owned := false
+	for _, address := range internal {
+		if node.HasAddress(address) {
+			owned = true
+		}
+	}
+	if len(internal) > 0 && !owned {
+		return false, fmt.Errorf("node object %s has InternalIP %s, not %s; refusing to delete a Node this replacement does not own", node.Name, strings.Join(internal, ","), node.IP)
+	}
+	for _, condition := range status.Status.Conditions {
+		if condition.Type == "Ready" && condition.Status == "True" {
+			return false, fmt.Errorf("node object %s is still Ready; refusing to replace a live node", node.Name)
+		}
+	}
+	return true, nil
+}
+
+// parseEtcdMembers reads the `talosctl etcd members` table:
+// NODE ID HOSTNAME PEER-URLS CLIENT-URLS LEARNER.
+func parseEtcdMembers(output string) []etcdMember {
+	var members []etcdMember
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] == "NODE" {
+			continue
+		}
+		members = append(members, etcdMember{ID: fields[1], Hostname: fields[2], PeerURLs: strings.Split(fields[3], ",")})
+	}
+	return members
+}
+
+func (m etcdMember) hasPeer(node versionconfig.Node) bool {
+	for _, peer := range m.PeerURLs {
+		peer = strings.TrimPrefix(strings.TrimPrefix(peer, "https://"), "http://")
+		if host, _, err := net.SplitHostPort(peer); err == nil && node.HasAddress(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// findDeadEtcdMember asks the other control planes for the member list and
+// returns the dead node's member. A member that matches by hostname but
+// peers from another address, or by address under another hostname, is an
+// identity conflict and stops the replacement.
+func findDeadEtcdMember(cfg *versionconfig.Config, node versionconfig.Node) (*etcdMember, string, error) {
+	var lastErr error
+	for _, other := range cfg.Cluster.Nodes {
+		if other.IP == node.IP {
+			continue
+		}
+		content, err := getTalosTemplateFn(fmt.Sprintf("talos/nodes/%s.yaml", other.IP))
+		if err != nil || nodeTemplateMachineType(content) != "controlplane" {
+			continue
+		}
+		output, err := talosctlNodeOutputFn(other.IP, "etcd", "members")
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for _, member := range parseEtcdMembers(string(output)) {
+			byName, byPeer := member.Hostname == node.Name, member.hasPeer(node)
+			switch {
+			case byName && byPeer:
+				member := member
+				return &member, other.IP, nil
+			case byName:
+				return nil, "", fmt.Errorf("etcd member %s (%s) peers from %s, not %s; refusing to remove it", member.ID, member.Hostname, strings.Join(member.PeerURLs, ","), node.IP)
+			case byPeer:
+				return nil, "", fmt.Errorf("etcd member %s at %s is named %s, not %s; refusing to remove it", member.ID, node.IP, member.Hostname, node.Name)
+			}
+		}
+		return nil, other.IP, nil
+	}
+	if lastErr != nil {
+		return nil, "", fmt.Errorf("no other control plane answered etcd members: %w", lastErr)
+	}
+	return nil, "", fmt.Errorf("no other control plane in cluster.nodes to remove %s's etcd membership through", node.Name)
+}
+
+// verifyReplaceNode establishes that the node at opts.IP is a configured
+// node that is really gone and that the Node object and etcd member about to
+// be removed belong to it.
+func verifyReplaceNode(ctx context.Context, cfg *versionconfig.Config, opts replaceNodeOptions) (replaceNodeTarget, error) {
+	name, err := replaceNodeName(cfg, opts.IP, opts.Name)
+	if err != nil {
+		return replaceNodeTarget{}, err
+	}
+	node, _ := cfg.NodeByName(name)
+	content, err := getTalosTemplateFn(fmt.Sprintf("talos/nodes/%s.yaml", node.IP))
+	if err != nil {
+		return replaceNodeTarget{}, fmt.Errorf("no machine config template talos/nodes/%s.yaml for the replacement: %w", node.IP, err)
+	}
+	target := replaceNodeTarget{Node: node, MachineType: nodeTemplateMachineType(content)}
+
+	if alive := probeTalosAPI(ctx, node.IP); alive != "" {
+		return replaceNodeTarget{}, fmt.Errorf("node %s (%s) still answers: %s; refusing to replace a node that responds (see 'talos reset-node' or 'talos reboot-node')", node.Name, node.IP, alive)
+	}
+	if target.KubeNode, err = verifyKubeNode(ctx, node); err != nil {
+		return replaceNodeTarget{}, err
+	}
+	if target.MachineType == "controlplane" {
+		if target.EtcdMember, target.EtcdEndpoint, err = findDeadEtcdMember(cfg, node); err != nil {
+			return replaceNodeTarget{}, err
+		}
+	}
+
+	profileProvider := "talos"
+	if opts.Provider == "vsphere" {
+		profileProvider = "vsphere"
+	}
+	switch {
+	case opts.MAC != "":
+		target.MAC, target.MACSource = opts.MAC, "--mac-address"
+	case node.VM.ForProvider(profileProvider).Mac != "":
+		target.MAC, target.MACSource = node.VM.ForProvider(profileProvider).Mac, "cluster.nodes vm.mac"
+	default:
+		if mac, err := ipCheckNeighborFn(ctx, node.IP); err == nil && mac != "" {
+			target.MAC, target.MACSource = mac, "this workstation's ARP cache"
+		}
+	}
+	return target, nil
+}
+
+func replaceNodeSteps(target replaceNodeTarget, opts replaceNodeOptions, deploy func(mac string) error) []replaceNodeStep {
+	node := target.Node
+	var steps []replaceNodeStep
+	if member := target.EtcdMember; member != nil {
+		steps = append(steps, replaceNodeStep{
+			Description: fmt.Sprintf("remove etcd member %s (%s) through %s", member.ID, member.Hostname, target.EtcdEndpoint),
+			Run: func(context.Context) error {
+				if output, err := talosctlNodeOutputFn(target.EtcdEndpoint, "etcd", "remove-member", member.ID); err != nil {
+					return fmt.Errorf("failed to remove etcd member %s: %w (%s)", member.ID, err, strings.TrimSpace(string(output)))
+				}
+				return nil
+			},
+		})
+	}
+	if target.KubeNode {
+		steps = append(steps, replaceNodeStep{
+			Description: fmt.Sprintf("delete Node object %s", node.Name),
+			Run: func(ctx context.Context) error {
+				if _, err := replaceNodeKubectlFn(ctx, "delete", "node", node.Name, "--ignore-not-found=true"); err != nil {
+					return fmt.Errorf("failed to delete Node %s: %w", node.Name, err)
+				}
+				return nil
+			},
+		})
+	}
+	deployDetail := fmt.Sprintf("deploy VM %s on %s", node.Name, opts.Provider)
+	switch {
+	case opts.Provider == "proxmox":
+		deployDetail += " (MAC from the Proxmox node preset)"
+	case target.MAC != "":
+		deployDetail += fmt.Sprintf(" with MAC %s from %s", target.MAC, target.MACSource)
+	default:
+		deployDetail += " with a new MAC (no previous MAC known; update the DHCP reservation)"
+	}
+	steps = append(steps, replaceNodeStep{Description: deployDetail, Run: func(context.Context) error { return deploy(target.MAC) }})
+	if opts.ConfigISO {
+		steps = append(steps, replaceNodeStep{
+			Description: fmt.Sprintf("wait for %s to answer the Talos API (configured from its config ISO)", node.IP),
+			Run:         func(ctx context.Context) error { return replaceNodeWaitFn(ctx, node.IP) },
+		})
+		return steps
+	}
+	steps = append(steps,
+		replaceNodeStep{
+			Description: fmt.Sprintf("wait for %s to boot into maintenance mode", node.IP),
+			Run:         func(ctx context.Context) error { return replaceNodeWaitFn(ctx, node.IP) },
+		},
+		replaceNodeStep{
+			Description: fmt.Sprintf("apply talos/nodes/%s.yaml (%s) in maintenance mode", node.IP, target.MachineType),
+			Run:         func(context.Context) error { return applyReplacementConfig(target) },
+		},
+	)
+	return steps
+}
+
+func applyReplacementConfig(target replaceNodeTarget) error {
+	logger := common.NewColorLogger()
+	nodeTemplate := fmt.Sprintf("talos/nodes/%s.yaml", target.Node.IP)
+	rendered, err := renderMachineConfigFromEmbeddedFn(fmt.Sprintf("talos/%s.yaml", target.MachineType), nodeTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to render config: %w", err)
+	}
+	resolved, err := injectSecretsWithSignin(logger, string(rendered))
+	if err != nil {
+		return err
+	}
+	if output, err := talosApplyInsecureConfigFn(target.Node.IP, resolved); err != nil {
+		return fmt.Errorf("failed to apply config to %s: %w\n%s", target.Node.IP, err, string(output))
+	}
+	return nil
+}
+
+// waitForTalosMaintenance polls apid until the new VM answers.
+func waitForTalosMaintenance(ctx context.Context, ip string) error {
+	ctx, cancel := context.WithTimeout(ctx, replaceNodeBootTimeout)
+	defer cancel()
+	for {
+		probeCtx, probeCancel := context.WithTimeout(ctx, replaceNodeProbeTimeout)
+		err := ipCheckDialFn(probeCtx, net.JoinHostPort(ip, "50000"))
+		probeCancel()
+		if err == nil {
+			return nil
+		}
+		if err := upgradeSleepFn(ctx, replaceNodePollInterval); err != nil {
+			return fmt.Errorf("%s did not answer on TCP 50000 within %s", ip, replaceNodeBootTimeout)
+		}
+	}
+}
+
+// runReplaceNode verifies the dead node, prints the plan, and after
+// confirmation runs it: etcd member and Node object first so the
+// replacement joins as the same node, then the VM, then its config.
+func runReplaceNode(ctx context.Context, opts replaceNodeOptions, out io.Writer, deploy func(mac string) error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	logger := common.NewColorLogger()
+	target, err := verifyReplaceNode(ctx, versionconfig.Get(), opts)
+	if err != nil {
+		return err
+	}
+	steps := replaceNodeSteps(target, opts, deploy)
+	prefix := ""
+	if opts.DryRun {
+		prefix = "DRY-RUN "
+	}
+	_, _ = fmt.Fprintf(out, "%sReplace %s %s (%s): the Talos API does not answer\n", prefix, target.MachineType, target.Node.Name, target.Node.IP)
+	for i, step := range steps {
+		_, _ = fmt.Fprintf(out, "  %d. %s\n", i+1, step.Description)
+	}
+	if opts.DryRun {
+		return nil
+	}
+	confirmed, err := confirmActionFn(fmt.Sprintf("Is %s gone for good? Its etcd member and Node object are removed before the replacement is deployed", target.Node.Name), false)
+	if err != nil {
+		return fmt.Errorf("confirmation failed: %w", err)
+	}
+	if !confirmed {
+		return fmt.Errorf("replacement of %s cancelled", target.Node.Name)
+	}
+	for i, step := range steps {
+		logger.Info("Step %d/%d: %s", i+1, len(steps), step.Description)
+		if err := step.Run(ctx); err != nil {
+			return fmt.Errorf("replace %s, step %d (%s): %w", target.Node.Name, i+1, step.Description, err)
+		}
+	}
+	logger.Success("Replaced %s: it rejoins with its old name, IP, and machine config", target.Node.Name)
+	return nil
+}
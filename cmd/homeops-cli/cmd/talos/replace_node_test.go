@@ -0,0 +1,191 @@
+package talos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	versionconfig "homeops-cli/internal/config"
+	"homeops-cli/internal/testutil"
+)
+
+const replaceNodeEtcdMembers = `NODE        ID                 HOSTNAME   PEER URLS                 CLIENT URLS               LEARNER
+10.0.0.11   2e1e6b0eca3c1d4e   k8s-0      https://10.0.0.10:2380    https://10.0.0.10:2379    false
+10.0.0.11   5a7c9d1e3f2b4c6a   k8s-1      https://10.0.0.11:2380    https://10.0.0.11:2379    false
+`
+
+// fakeReplaceNodeCluster wires a dead k8s-0 (10.0.0.10), a healthy control
+// plane k8s-1, and a worker k8s-3. Every layer replaceNode touches records its
+// call into the returned log, in order.
+type fakeReplaceNodeCluster struct {
+	calls     []string
+	alive     map[string]bool
+	pings     map[string]bool
+	kubeNode  string
+	etcd      string
+	arp       string
+	confirmed bool
+}
+
+func newFakeReplaceNodeCluster(t *testing.T) *fakeReplaceNodeCluster {
+	t.Helper()
+	fake := &fakeReplaceNodeCluster{
+		alive:     map[string]bool{"10.0.0.11": true, "10.0.0.13": true},
+		pings:     map[string]bool{},
+		kubeNode:  `{"status":{"addresses":[{"type":"InternalIP","address":"10.0.0.10"}],"conditions":[{"type":"Ready","status":"Unknown"}]}}`,
+		etcd:      replaceNodeEtcdMembers,
+		confirmed: true,
+	}
+	t.Cleanup(versionconfig.SetForTesting(&versionconfig.Config{Cluster: versionconfig.ClusterConfig{Nodes: []versionconfig.Node{
+		{Name: "k8s-0", IP: "10.0.0.10", VM: versionconfig.VMProfile{Mac: "00:a0:98:00:00:10"}},
+		{Name: "k8s-1", IP: "10.0.0.11"},
+		{Name: "k8s-3", IP: "10.0.0.13"},
+	}}}))
+	testutil.Swap(t, &getTalosTemplateFn, func(name string) (string, error) {
+		switch name {
+		case "talos/nodes/10.0.0.10.yaml", "talos/nodes/10.0.0.11.yaml":
+			return "machine:\n  type: controlplane\n", nil
+		case "talos/nodes/10.0.0.13.yaml":
+			return "machine:\n  type: worker\n", nil
+		}
+		return "", fmt.Errorf("template %s not found", name)
+	})
+	testutil.Swap(t, &ipCheckDialFn, func(_ context.Context, address string) error {
+		if fake.alive[strings.TrimSuffix(address, ":50000")] {
+			return nil
+		}
+		return errors.New("connection refused")
+	})
+	testutil.Swap(t, &ipCheckPingFn, func(_ context.Context, ip string) bool { return fake.pings[ip] })
+	testutil.Swap(t, &ipCheckNeighborFn, func(context.Context, string) (string, error) { return fake.arp, nil })
+	testutil.Swap(t, &talosctlNodeOutputFn, func(node string, args ...string) ([]byte, error) {
+		call := "talosctl " + node + " " + strings.Join(args, " ")
+		switch {
+		case !fake.alive[node]:
+			return nil, errors.New("connection refused")
+		case args[0] == "etcd" && args[1] == "members":
+			return []byte(fake.etcd), nil
+		case args[0] == "etcd" && args[1] == "remove-member":
+			fake.calls = append(fake.calls, call)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unexpected %s", call)
+	})
+	testutil.Swap(t, &replaceNodeKubectlFn, func(_ context.Context, args ...string) ([]byte, error) {
+		if args[0] == "get" {
+			return []byte(fake.kubeNode), nil
+		}
+		fake.calls = append(fake.calls, "kubectl "+strings.Join(args, " "))
+		return nil, nil
+	})
+	testutil.Swap(t, &replaceNodeWaitFn, func(_ context.Context, ip string) error {
+		fake.calls = append(fake.calls, "wait "+ip)
+		return nil
+	})
+	testutil.Swap(t, &renderMachineConfigFromEmbeddedFn, func(base, patch string) ([]byte, error) {
+		return []byte("rendered " + base + " + " + patch), nil
+	})
+	testutil.Swap(t, &injectSecretsFn, func(content string) (string, error) { return content, nil })
+	testutil.Swap(t, &talosApplyInsecureConfigFn, func(nodeIP, config string) ([]byte, error) {
+		fake.calls = append(fake.calls, "apply --insecure "+nodeIP+": "+config)
+		return nil, nil
+	})
+	testutil.Swap(t, &confirmActionFn, func(string, bool) (bool, error) {
+		fake.calls = append(fake.calls, "confirm")
+		return fake.confirmed, nil
+	})
+	return fake
+}
+
+func (f *fakeReplaceNodeCluster) deploy(mac string) error {
+	f.calls = append(f.calls, "deploy mac="+mac)
+	return nil
+}
+
+func TestReplaceNodeRunsStepsInOrder(t *testing.T) {
+	fake := newFakeReplaceNodeCluster(t)
+	var out strings.Builder
+	opts := replaceNodeOptions{IP: "10.0.0.10", Provider: "proxmox", DryRun: true}
+	require.NoError(t, runReplaceNode(context.Background(), opts, &out, fake.deploy))
+	assert.Empty(t, fake.calls, "dry-run verifies but neither confirms nor changes anything")
+	assert.Equal(t, `DRY-RUN Replace controlplane k8s-0 (10.0.0.10): the Talos API does not answer
+  1. remove etcd member 2e1e6b0eca3c1d4e (k8s-0) through 10.0.0.11
+  2. delete Node object k8s-0
+  3. deploy VM k8s-0 on proxmox (MAC from the Proxmox node preset)
+  4. wait for 10.0.0.10 to boot into maintenance mode
+  5. apply talos/nodes/10.0.0.10.yaml (controlplane) in maintenance mode
+`, out.String())
+
+	opts.DryRun, opts.Provider = false, "vsphere"
+	require.NoError(t, runReplaceNode(context.Background(), opts, &out, fake.deploy))
+	assert.Equal(t, []string{
+		"confirm",
+		"talosctl 10.0.0.11 etcd remove-member 2e1e6b0eca3c1d4e",
+		"kubectl delete node k8s-0 --ignore-not-found=true",
+		"deploy mac=00:a0:98:00:00:10",
+		"wait 10.0.0.10",
+		"apply --insecure 10.0.0.10: rendered talos/controlplane.yaml + talos/nodes/10.0.0.10.yaml",
+	}, fake.calls, "the old identity is cleared before the replacement boots")
+
+	fake.calls = nil
+	fake.confirmed = false
+	require.ErrorContains(t, runReplaceNode(context.Background(), opts, &out, fake.deploy), "cancelled")
+	assert.Equal(t, []string{"confirm"}, fake.calls)
+}
+
+func TestReplaceNodeWorkerWithConfigISO(t *testing.T) {
+	fake := newFakeReplaceNodeCluster(t)
+	fake.alive["10.0.0.13"] = false
+	fake.arp = "00:a0:98:00:00:13"
+	fake.kubeNode = ""
+	var out strings.Builder
+	require.NoError(t, runReplaceNode(context.Background(), replaceNodeOptions{IP: "10.0.0.13", Provider: "truenas", ConfigISO: true}, &out, fake.deploy))
+	assert.Equal(t, []string{
+		"confirm",
+		"deploy mac=00:a0:98:00:00:13",
+		"wait 10.0.0.13",
+	}, fake.calls, "workers have no etcd member, a missing Node needs no delete, and the config ISO replaces apply")
+	assert.Contains(t, out.String(), "with MAC 00:a0:98:00:00:13 from this workstation's ARP cache")
+}
+
+func TestVerifyReplaceNodeSafetyChecks(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		setup func(*fakeReplaceNodeCluster)
+		opts  replaceNodeOptions
+		err   string
+	}{
+		{name: "unknown IP", opts: replaceNodeOptions{IP: "10.0.0.99"}, err: "not a node in cluster.nodes"},
+		{name: "renamed", opts: replaceNodeOptions{IP: "10.0.0.10", Name: "k8s-9"}, err: "--name k8s-9 does not match node k8s-0"},
+		{name: "apid answers", setup: func(f *fakeReplaceNodeCluster) { f.alive["10.0.0.10"] = true }, err: "still answers: apid (TCP 50000) accepts connections"},
+		{name: "address answers ping", setup: func(f *fakeReplaceNodeCluster) { f.pings["10.0.0.10"] = true }, err: "power off the old VM first"},
+		{name: "Node still Ready", setup: func(f *fakeReplaceNodeCluster) {
+			f.kubeNode = `{"status":{"addresses":[{"type":"InternalIP","address":"10.0.0.10"}],"conditions":[{"type":"Ready","status":"True"}]}}`
+		}, err: "node object k8s-0 is still Ready"},
+		{name: "Node from another IP", setup: func(f *fakeReplaceNodeCluster) {
+			f.kubeNode = `{"status":{"addresses":[{"type":"InternalIP","address":"10.0.0.50"}]}}`
+		}, err: "has InternalIP 10.0.0.50, not 10.0.0.10"},
+		{name: "etcd member peers elsewhere", setup: func(f *fakeReplaceNodeCluster) {
+			f.etcd = strings.Replace(replaceNodeEtcdMembers, "https://10.0.0.10:2380", "https://10.0.0.50:2380", 1)
+		}, err: "peers from https://10.0.0.50:2380, not 10.0.0.10"},
+		{name: "no control plane left", setup: func(f *fakeReplaceNodeCluster) { f.alive["10.0.0.11"] = false }, err: "no other control plane answered etcd members"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newFakeReplaceNodeCluster(t)
+			if tc.setup != nil {
+				tc.setup(fake)
+			}
+			if tc.opts.IP == "" {
+				tc.opts.IP = "10.0.0.10"
+			}
+			_, err := verifyReplaceNode(context.Background(), versionconfig.Get(), tc.opts)
+			require.ErrorContains(t, err, tc.err)
+			assert.Empty(t, fake.calls)
+		})
+	}
+}
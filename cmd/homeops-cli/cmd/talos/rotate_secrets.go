@@ -0,0 +1,795 @@
+package talos
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"homeops-cli/cmd/kubernetes"
+	"homeops-cli/internal/common"
+	versionconfig "homeops-cli/internal/config"
+	"homeops-cli/internal/constants"
+	"homeops-cli/internal/secrets"
+)
+
+// Rotation phases, in the order they run. Dual trust means a node accepts
+// both the old and the new CA while it moves from one to the other, so no
+// phase ever leaves a client or peer certificate without a trusted issuer.
+const (
+	phaseTrustNewCA     = "trust-new-ca"
+	phaseIssueFromNewCA = "issue-from-new-ca"
+	phaseRotateTokens   = "rotate-tokens"
+	phaseUpdateStore    = "update-store"
+	phaseDropOldCA      = "drop-old-ca"
+)
+
+var rotatePhaseDescriptions = map[string]string{
+	phaseTrustNewCA:     "add the new machine and Kubernetes CAs to acceptedCAs; nodes keep issuing from the current CAs",
+	phaseIssueFromNewCA: "issue from the new CAs with the old ones in acceptedCAs, then refresh talosconfig and kubeconfig",
+	phaseRotateTokens:   "replace the machine (trustd) token and the cluster bootstrap token",
+	phaseUpdateStore:    "write the new material to its configured secret backings",
+	phaseDropOldCA:      "remove the old CAs from acceptedCAs",
+}
+
+// rotateMaterialKeys lists the secret keys each --only material replaces.
+var rotateMaterialKeys = map[string][]string{
+	"ca": {
+		versionconfig.KeyTalosMachineCACrt, versionconfig.KeyTalosMachineCAKey,
+		versionconfig.KeyTalosClusterCACrt, versionconfig.KeyTalosClusterCAKey,
+	},
+	"token": {versionconfig.KeyTalosMachineToken, versionconfig.KeyTalosClusterToken},
+}
+
+var (
+	rotateNowFn       = time.Now
+	rotateStatePathFn = func() (string, error) {
+		return secrets.ExpandHome("~/.config/homeops/state/talos-rotate-secrets.json")
+	}
+	rotateClusterNodesFn = getAllNodes
+	rotateGenSecretsFn   = generateTalosSecretsBundle
+	rotateNodeHealthFn   = func(nodeIP string) error {
+		return spinCommandFn(fmt.Sprintf("Waiting for node %s to report healthy", nodeIP),
+			"talosctl", "--nodes", nodeIP, "health", "--server=false", "--wait-timeout", "10m")
+	}
+	rotateBackupGateFn         = kubernetes.RequireFreshEtcdBackup
+	rotateWriteSecretsFn       = secrets.WriteAll
+	rotateRefreshTalosconfigFn = refreshTalosconfig
+)
+
+type rotateNode struct {
+	IP   string `json:"ip"`
+	Type string `json:"type"`
+}
+
+// rotateSecretsState is the persisted progress of a rotation. It holds the
+// generated material, so it is written 0600 and removed once the rotation
+// completes; until then it is the only copy of the new keys outside the nodes.
+type rotateSecretsState struct {
+	Materials  []string          `json:"materials"`
+	Phases     []string          `json:"phases"`
+	Completed  []string          `json:"completed"`
+	PhaseNodes []string          `json:"phase_nodes,omitempty"`
+	Nodes      []rotateNode      `json:"nodes"`
+	New        map[string]string `json:"new"`
+	OldCACrts  map[string]string `json:"old_ca_crts,omitempty"`
+	StartedAt  time.Time         `json:"started_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+	Stopped    string            `json:"stopped,omitempty"`
+}
+
+func (s *rotateSecretsState) pendingPhases() []string {
+	return s.Phases[len(s.Completed):]
+}
+
+func (s *rotateSecretsState) rotates(material string) bool {
+	for _, m := range s.Materials {
+		if m == material {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *rotateSecretsState) phaseIndex(phase string) int {
+	for i, p := range s.Phases {
+		if p == phase {
+			return i
+		}
+	}
+	return -1
+}
+
+// overrides returns the secret keys whose new value a phase renders: the CA
+// from issue-from-new-ca onwards, the tokens from rotate-tokens onwards.
+func (s *rotateSecretsState) overrides(phase string) map[string]string {
+	index := s.phaseIndex(phase)
+	switchPhase := map[string]string{"ca": phaseIssueFromNewCA, "token": phaseRotateTokens}
+	values := map[string]string{}
+	for _, material := range s.Materials {
+		if index < s.phaseIndex(switchPhase[material]) {
+			continue
+		}
+		for _, key := range rotateMaterialKeys[material] {
+			values[key] = s.New[key]
+		}
+	}
+	return values
+}
+
+// acceptedCAs returns the machine and cluster CA certificates a phase adds to
+// acceptedCAs: the new ones while nodes still issue from the old, the old
+// ones while nodes issue from the new, none once the rotation drops them.
+func (s *rotateSecretsState) acceptedCAs(phase string) (machine, cluster string) {
+	if !s.rotates("ca") || phase == phaseDropOldCA {
+		return "", ""
+	}
+	if phase == phaseTrustNewCA {
+		return s.New[versionconfig.KeyTalosMachineCACrt], s.New[versionconfig.KeyTalosClusterCACrt]
+	}
+	return s.OldCACrts[versionconfig.KeyTalosMachineCACrt], s.OldCACrts[versionconfig.KeyTalosClusterCACrt]
+}
+
+// secretKeys lists every key the rotation replaces, in material order.
+func (s *rotateSecretsState) secretKeys() []string {
+	var keys []string
+	for _, material := range s.Materials {
+		keys = append(keys, rotateMaterialKeys[material]...)
+	}
+	return keys
+}
+
+// registerSecrets makes the generated material redactable again after a
+// resume loads it from disk.
+func (s *rotateSecretsState) registerSecrets() {
+	for key, value := range s.New {
+		common.RegisterSecret("rotate:"+key, value)
+	}
+}
+
+func loadRotateSecretsState() (*rotateSecretsState, error) {
+	path, err := rotateStatePathFn()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rotation progress %s: %w", path, err)
+	}
+	var state rotateSecretsState
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse rotation progress %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+func saveRotateSecretsState(state *rotateSecretsState) error {
+	path, err := rotateStatePathFn()
+	if err != nil {
+		return err
+	}
+	state.UpdatedAt = rotateNowFn().UTC()
+	raw, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, raw, 0600); err != nil {
+		return fmt.Errorf("failed to write rotation progress to %s: %w", path, err)
+	}
+	return nil
+}
+
+func clearRotateSecretsState() error {
+	path, err := rotateStatePathFn()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove rotation progress %s: %w", path, err)
+	}
+	return nil
+}
+
+type rotateSecretsOptions struct {
+	Only         []string
+	Plan         bool
+	Resume       bool
+	MaxBackupAge time.Duration
+}
+
+func newRotateSecretsCommand() *cobra.Command {
+	var opts rotateSecretsOptions
+
+	cmd := &cobra.Command{
+		Use:   "rotate-secrets",
+		Short: "Rotate the Talos CAs and join tokens across every node",
+		Long: `Generate new Talos secrets with 'talosctl gen secrets' and re-key every node
+through the same render pipeline as apply-node, control planes first.
+
+A CA rotation keeps dual trust throughout: nodes first accept the new CAs,
+then issue from them while still accepting the old ones, and only drop the old
+CAs after talosconfig, kubeconfig, and the configured secret backings hold the
+new material. Every node must report healthy before the next one is touched.
+
+The new material is written back to the op:// or file:// backing of each
+secret key, so those keys must not be env://, cmd://, or literal://. The
+rotation refuses to start unless an etcd snapshot younger than
+--max-backup-age exists (see 'k8s etcd backup').
+
+Progress is saved after every node, so a failed or interrupted rotation
+continues with --resume. --plan prints the phases and nodes without changing
+anything. --only secretbox is refused: Talos holds a single secretbox key, so
+replacing it would leave every Secret already encrypted at rest unreadable.`,
+		Example: `  homeops-cli talos rotate-secrets --plan
+  homeops-cli talos rotate-secrets --only token
+  homeops-cli talos rotate-secrets --resume`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.MaxBackupAge <= 0 {
+				return fmt.Errorf("--max-backup-age must be positive")
+			}
+			return runRotateSecrets(cmd.Context(), common.NewColorLogger(), cmd.OutOrStdout(), opts)
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&opts.Only, "only", nil, "Material to rotate: ca, token (default: both)")
+	cmd.Flags().BoolVar(&opts.Plan, "plan", false, "Print the rotation phases and nodes without changing anything")
+	cmd.Flags().BoolVar(&opts.Resume, "resume", false, "Continue the saved rotation from where it stopped")
+	cmd.Flags().DurationVar(&opts.MaxBackupAge, "max-backup-age", 24*time.Hour, "Refuse to start unless an etcd snapshot is younger than this")
+	cmd.MarkFlagsMutuallyExclusive("only", "resume")
+
+	return cmd
+}
+
+// parseRotateMaterials validates --only and returns the materials in
+// rotation order.
+func parseRotateMaterials(only []string) ([]string, error) {
+	if len(only) == 0 {
+		return []string{"ca", "token"}, nil
+	}
+	selected := map[string]bool{}
+	for _, value := range only {
+		switch value = strings.TrimSpace(value); value {
+		case "ca", "token":
+			selected[value] = true
+		case "secretbox":
+			return nil, fmt.Errorf("--only secretbox is not supported: Talos holds a single secretbox key, so replacing it would leave every Secret already encrypted at rest unreadable")
+		default:
+			return nil, fmt.Errorf("unknown --only value %q: want ca or token", value)
+		}
+	}
+	var materials []string
+	for _, material := range []string{"ca", "token"} {
+		if selected[material] {
+			materials = append(materials, material)
+		}
+	}
+	return materials, nil
+}
+
+func rotationPhases(materials []string) []string {
+	var phases []string
+	rotatesCA := false
+	for _, material := range materials {
+		switch material {
+		case "ca":
+			rotatesCA = true
+			phases = append(phases, phaseTrustNewCA, phaseIssueFromNewCA)
+		case "token":
+			phases = append(phases, phaseRotateTokens)
+		}
+	}
+	phases = append(phases, phaseUpdateStore)
+	if rotatesCA {
+		phases = append(phases, phaseDropOldCA)
+	}
+	return phases
+}
+
+// rotationNodes returns the talosconfig nodes with their machine types,
+// control planes first.
+func rotationNodes() ([]rotateNode, error) {
+	ips, err := rotateClusterNodesFn()
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no nodes found in talosconfig")
+	}
+	nodes := make([]rotateNode, 0, len(ips))
+	for _, ip := range ips {
+		machineType, err := getMachineTypeFromNodeFn(ip)
+		if err != nil {
+			return nil, fmt.Errorf("node %s: %w", ip, err)
+		}
+		nodes = append(nodes, rotateNode{IP: ip, Type: machineType})
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].Type == "controlplane" && nodes[j].Type != "controlplane"
+	})
+	if nodes[0].Type != "controlplane" {
+		return nil, fmt.Errorf("no control plane node found in talosconfig")
+	}
+	return nodes, nil
+}
+
+// rotationBackings resolves the writable backing of every rotated key, so an
+// env:// or misspelled key fails before any node is touched.
+func rotationBackings(keys []string) (map[string]string, error) {
+	cfg := versionconfig.Get()
+	backings := make(map[string]string, len(keys))
+	owners := map[string]string{}
+	for _, key := range keys {
+		backing, err := secrets.WritableBacking(cfg.SecretRef(key))
+		if err != nil {
+			return nil, fmt.Errorf("secret %s: %w", key, err)
+		}
+		if other, ok := owners[backing]; ok {
+			return nil, fmt.Errorf("secrets %s and %s share the backing %s", other, key, backing)
+		}
+		owners[backing] = key
+		backings[key] = backing
+	}
+	return backings, nil
+}
+
+func runRotateSecrets(ctx context.Context, logger *common.ColorLogger, out io.Writer, opts rotateSecretsOptions) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	materials, err := parseRotateMaterials(opts.Only)
+	if err != nil {
+		return err
+	}
+	state, err := loadRotateSecretsState()
+	if err != nil {
+		return err
+	}
+	if opts.Resume && state == nil {
+		return fmt.Errorf("no saved rotate-secrets run to resume")
+	}
+	if !opts.Resume && state != nil && !opts.Plan {
+		return fmt.Errorf("a rotation of %s is in progress (next phase %s); finish it with --resume, since starting over would discard material the cluster already trusts",
+			strings.Join(state.Materials, ", "), state.pendingPhases()[0])
+	}
+
+	if opts.Plan {
+		if !opts.Resume {
+			nodes, err := rotationNodes()
+			if err != nil {
+				return err
+			}
+			if state != nil {
+				logger.Warn("A rotation of %s is in progress; --plan --resume shows its remaining phases", strings.Join(state.Materials, ", "))
+			}
+			state = &rotateSecretsState{Materials: materials, Phases: rotationPhases(materials), Nodes: nodes}
+		}
+		_, backingErr := rotationBackings(state.secretKeys())
+		renderRotationPlan(out, state, backingErr, rotateBackupGateFn(ctx, opts.MaxBackupAge))
+		return nil
+	}
+
+	if _, err := rotationBackings(materialKeys(materials, state)); err != nil {
+		return err
+	}
+	if err := rotateBackupGateFn(ctx, opts.MaxBackupAge); err != nil {
+		return err
+	}
+
+	if state != nil {
+		state.registerSecrets()
+		logger.Info("Resuming rotation of %s: %d of %d phase(s) done", strings.Join(state.Materials, ", "), len(state.Completed), len(state.Phases))
+	} else {
+		nodes, err := rotationNodes()
+		if err != nil {
+			return err
+		}
+		phases := rotationPhases(materials)
+		confirmed, err := confirmActionFn(fmt.Sprintf("Rotate Talos %s on %d node(s) in %d phase(s)?", strings.Join(materials, " and "), len(nodes), len(phases)), false)
+		if err != nil {
+			return fmt.Errorf("confirmation failed: %w", err)
+		}
+		if !confirmed {
+			logger.Info("Secret rotation cancelled")
+			return nil
+		}
+		state, err = newRotateSecretsState(materials, phases, nodes)
+		if err != nil {
+			return err
+		}
+	}
+	state.Stopped = ""
+	if err := saveRotateSecretsState(state); err != nil {
+		return err
+	}
+
+	for _, phase := range state.pendingPhases() {
+		logger.Info("Phase %s: %s", phase, rotatePhaseDescriptions[phase])
+		if err := runRotationPhase(logger, state, phase); err != nil {
+			return err
+		}
+		state.Completed = append(state.Completed, phase)
+		state.PhaseNodes = nil
+		if err := saveRotateSecretsState(state); err != nil {
+			return err
+		}
+	}
+
+	if err := clearRotateSecretsState(); err != nil {
+		return err
+	}
+	logger.Success("Rotated Talos %s on %d node(s)", strings.Join(state.Materials, " and "), len(state.Nodes))
+	return nil
+}
+
+func materialKeys(materials []string, state *rotateSecretsState) []string {
+	if state != nil {
+		return state.secretKeys()
+	}
+	return (&rotateSecretsState{Materials: materials}).secretKeys()
+}
+
+// newRotateSecretsState generates the new material and records the current
+// CA certificates that dual trust keeps accepting until drop-old-ca.
+func newRotateSecretsState(materials, phases []string, nodes []rotateNode) (*rotateSecretsState, error) {
+	bundle, err := rotateGenSecretsFn()
+	if err != nil {
+		return nil, err
+	}
+	state := &rotateSecretsState{Materials: materials, Phases: phases, Nodes: nodes, StartedAt: rotateNowFn().UTC()}
+	state.New, err = rotationValuesFromBundle(bundle, state.secretKeys())
+	if err != nil {
+		return nil, err
+	}
+	state.registerSecrets()
+	if state.rotates("ca") {
+		state.OldCACrts = map[string]string{}
+		for _, key := range []string{versionconfig.KeyTalosMachineCACrt, versionconfig.KeyTalosClusterCACrt} {
+			current, err := versionconfig.Get().ResolveSecret(key)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the current CA to keep trusting it: %w", err)
+			}
+			state.OldCACrts[key] = strings.TrimSpace(current)
+		}
+	}
+	return state, nil
+}
+
+// talosSecretsBundle is the subset of a 'talosctl gen secrets' file the
+// rotation uses. Certificates and keys are base64-encoded PEM, the same
+// encoding the machine config templates expect.
+type talosSecretsBundle struct {
+	Secrets struct {
+		BootstrapToken string `yaml:"bootstraptoken"`
+	} `yaml:"secrets"`
+	TrustdInfo struct {
+		Token string `yaml:"token"`
+	} `yaml:"trustdinfo"`
+	Certs struct {
+		OS  talosBundleCert `yaml:"os"`
+		K8s talosBundleCert `yaml:"k8s"`
+	} `yaml:"certs"`
+}
+
+type talosBundleCert struct {
+	Crt string `yaml:"crt"`
+	Key string `yaml:"key"`
+}
+
+func rotationValuesFromBundle(raw []byte, keys []string) (map[string]string, error) {
+	var bundle talosSecretsBundle
+	if err := yaml.Unmarshal(raw, &bundle); err != nil {
+		return nil, fmt.Errorf("failed to parse the generated secrets bundle")
+	}
+	all := map[string]string{
+		versionconfig.KeyTalosMachineCACrt: bundle.Certs.OS.Crt,
+		versionconfig.KeyTalosMachineCAKey: bundle.Certs.OS.Key,
+		versionconfig.KeyTalosClusterCACrt: bundle.Certs.K8s.Crt,
+		versionconfig.KeyTalosClusterCAKey: bundle.Certs.K8s.Key,
+		versionconfig.KeyTalosMachineToken: bundle.TrustdInfo.Token,
+		versionconfig.KeyTalosClusterToken: bundle.Secrets.BootstrapToken,
+	}
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		value := strings.TrimSpace(all[key])
+		if value == "" {
+			return nil, fmt.Errorf("generated secrets bundle has no value for %s", key)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+func generateTalosSecretsBundle() ([]byte, error) {
+	dir, err := os.MkdirTemp("", "homeops-talos-secrets-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a directory for the secrets bundle: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "secrets.yaml")
+	if output, err := runTalosctlCombinedOutput("gen", "secrets", "--output-file", path); err != nil {
+		return nil, fmt.Errorf("talosctl gen secrets failed: %w\n%s", err, common.RedactCommandOutput(string(output)))
+	}
+	return os.ReadFile(path) // #nosec G304 -- path is inside a private temp directory created above
+}
+
+func runRotationPhase(logger *common.ColorLogger, state *rotateSecretsState, phase string) error {
+	if phase == phaseUpdateStore {
+		if err := storeRotatedSecrets(state); err != nil {
+			_ = stopRotation(logger, state, "updating the secret backings failed")
+			return fmt.Errorf("%w (fix it, then rerun with --resume)", err)
+		}
+		logger.Success("Stored the new %s in the configured secret backings", strings.Join(state.Materials, " and "))
+		return nil
+	}
+
+	done := make(map[string]bool, len(state.PhaseNodes))
+	for _, ip := range state.PhaseNodes {
+		done[ip] = true
+	}
+	for _, node := range state.Nodes {
+		if done[node.IP] {
+			continue
+		}
+		config, err := renderRotationConfig(logger, state, node, phase)
+		if err != nil {
+			_ = stopRotation(logger, state, fmt.Sprintf("rendering %s for %s failed", phase, node.IP))
+			return fmt.Errorf("node %s: %w (fix it, then rerun with --resume)", node.IP, err)
+		}
+		logger.Info("Applying %s to %s (%s)", phase, node.IP, node.Type)
+		if output, err := talosApplyConfigFn(node.IP, "auto", config); err != nil {
+			_ = stopRotation(logger, state, fmt.Sprintf("applying %s to %s failed", phase, node.IP))
+			return fmt.Errorf("failed to apply config to %s: %w\n%s (fix it, then rerun with --resume)", node.IP, err, common.RedactCommandOutput(string(output)))
+		}
+		if err := rotateNodeHealthFn(node.IP); err != nil {
+			_ = stopRotation(logger, state, fmt.Sprintf("%s did not report healthy after %s", node.IP, phase))
+			return fmt.Errorf("node %s did not report healthy after %s: %w (fix it, then rerun with --resume)", node.IP, phase, err)
+		}
+		state.PhaseNodes = append(state.PhaseNodes, node.IP)
+		if err := saveRotateSecretsState(state); err != nil {
+			return err
+		}
+	}
+
+	if phase == phaseIssueFromNewCA {
+		if err := refreshRotatedClientConfigs(logger, state.Nodes[0].IP); err != nil {
+			_ = stopRotation(logger, state, "refreshing talosconfig or kubeconfig failed")
+			return fmt.Errorf("%w (fix it, then rerun with --resume)", err)
+		}
+	}
+	return nil
+}
+
+// renderRotationConfig renders a node's config the way apply-node does, with
+// the rotated secret:// references replaced by the phase's values and the
+// phase's acceptedCAs added.
+func renderRotationConfig(logger *common.ColorLogger, state *rotateSecretsState, node rotateNode, phase string) (string, error) {
+	rendered, err := renderMachineConfigFromEmbeddedFn(fmt.Sprintf("talos/%s.yaml", node.Type), fmt.Sprintf("talos/nodes/%s.yaml", node.IP))
+	if err != nil {
+		return "", fmt.Errorf("failed to render config: %w", err)
+	}
+	overrides := state.overrides(phase)
+	content := secrets.RefRegex.ReplaceAllStringFunc(string(rendered), func(ref string) string {
+		if value, ok := overrides[strings.TrimPrefix(ref, "secret://")]; ok && strings.HasPrefix(ref, "secret://") {
+			return value
+		}
+		return ref
+	})
+	resolved, err := injectSecretsWithSignin(logger, content)
+	if err != nil {
+		return "", err
+	}
+	machineCA, clusterCA := state.acceptedCAs(phase)
+	if machineCA == "" && clusterCA == "" {
+		return resolved, nil
+	}
+	return withAcceptedCAs(resolved, machineCA, clusterCA)
+}
+
+// withAcceptedCAs sets machine.acceptedCAs and cluster.acceptedCAs in the
+// v1alpha1 document of a multi-document machine config.
+func withAcceptedCAs(content, machineCA, clusterCA string) (string, error) {
+	decoder := yaml.NewDecoder(strings.NewReader(content))
+	var docs []*yaml.Node
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return "", fmt.Errorf("rendered config is not valid YAML: %w", err)
+		}
+		docs = append(docs, &doc)
+	}
+	patched := false
+	for _, doc := range docs {
+		if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+			continue
+		}
+		root := doc.Content[0]
+		machine := yamlMappingValue(root, "machine")
+		if machine == nil {
+			continue
+		}
+		if machineCA != "" {
+			setYAMLMappingValue(machine, "acceptedCAs", acceptedCAsNode(machineCA))
+		}
+		if cluster := yamlMappingValue(root, "cluster"); cluster != nil && clusterCA != "" {
+			setYAMLMappingValue(cluster, "acceptedCAs", acceptedCAsNode(clusterCA))
+		}
+		patched = true
+	}
+	if !patched {
+		return "", fmt.Errorf("rendered config has no v1alpha1 machine document")
+	}
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	for _, doc := range docs {
+		if err := encoder.Encode(doc); err != nil {
+			return "", fmt.Errorf("failed to encode rendered config: %w", err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("failed to encode rendered config: %w", err)
+	}
+	return buf.String(), nil
+}
+
+func yamlMappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key && mapping.Content[i+1].Kind == yaml.MappingNode {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func setYAMLMappingValue(mapping *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content[i+1] = value
+			return
+		}
+	}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+func acceptedCAsNode(crt string) *yaml.Node {
+	entry := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: "crt"},
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: crt},
+	}}
+	return &yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{entry}}
+}
+
+// refreshRotatedClientConfigs re-issues talosconfig and kubeconfig once the
+// nodes issue from the new CAs; the old client certificates stop working at
+// drop-old-ca.
+func refreshRotatedClientConfigs(logger *common.ColorLogger, controlPlane string) error {
+	if err := rotateRefreshTalosconfigFn(logger, controlPlane); err != nil {
+		return err
+	}
+	rootDir, err := repoRootFn()
+	if err != nil {
+		return err
+	}
+	logger.Info("Regenerating kubeconfig from node %s", controlPlane)
+	if output, err := generateKubeconfigFn(controlPlane, rootDir); err != nil {
+		return fmt.Errorf("failed to generate kubeconfig: %w\n%s", err, common.RedactCommandOutput(string(output)))
+	}
+	return pushKubeconfigToStore(logger)
+}
+
+// talosconfigPath mirrors talosctl: the first TALOSCONFIG entry, else
+// ~/.talos/config.
+func talosconfigPath() (string, error) {
+	if list := filepath.SplitList(os.Getenv(constants.EnvTalosconfig)); len(list) > 0 && list[0] != "" {
+		return list[0], nil
+	}
+	return secrets.ExpandHome("~/.talos/config")
+}
+
+// refreshTalosconfig asks the cluster for a new os:admin client certificate,
+// keeps the current endpoints and nodes, and swaps it in, leaving the old
+// file next to it with a .pre-rotate suffix.
+func refreshTalosconfig(logger *common.ColorLogger, controlPlane string) error {
+	info, err := getTalosConfigInfo()
+	if err != nil {
+		return fmt.Errorf("failed to read talosconfig: %w", err)
+	}
+	path, err := talosconfigPath()
+	if err != nil {
+		return err
+	}
+	fresh := path + ".rotate-new"
+	_ = os.Remove(fresh)
+	logger.Info("Issuing a new talosconfig from node %s", controlPlane)
+	if output, err := talosctlNodeOutputFn(controlPlane, "config", "new", "--roles", "os:admin", fresh); err != nil {
+		return fmt.Errorf("failed to issue a new talosconfig: %w\n%s", err, common.RedactCommandOutput(string(output)))
+	}
+	if len(info.Endpoints) > 0 {
+		if output, err := talosctlOutputFn("talosctl", append([]string{"--talosconfig", fresh, "config", "endpoint"}, info.Endpoints...)...); err != nil {
+			return fmt.Errorf("failed to set talosconfig endpoints: %w\n%s", err, common.RedactCommandOutput(string(output)))
+		}
+	}
+	if len(info.Nodes) > 0 {
+		if output, err := talosctlOutputFn("talosctl", append([]string{"--talosconfig", fresh, "config", "node"}, info.Nodes...)...); err != nil {
+			return fmt.Errorf("failed to set talosconfig nodes: %w\n%s", err, common.RedactCommandOutput(string(output)))
+		}
+	}
+	if err := os.Rename(path, path+".pre-rotate"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to back up talosconfig %s: %w", path, err)
+	}
+	if err := os.Rename(fresh, path); err != nil {
+		return fmt.Errorf("failed to install the new talosconfig at %s: %w", path, err)
+	}
+	logger.Success("talosconfig refreshed at %s (previous copy: %s.pre-rotate)", path, path)
+	return nil
+}
+
+func storeRotatedSecrets(state *rotateSecretsState) error {
+	backings, err := rotationBackings(state.secretKeys())
+	if err != nil {
+		return err
+	}
+	values := make(map[string]string, len(backings))
+	for key, backing := range backings {
+		values[backing] = state.New[key]
+	}
+	return rotateWriteSecretsFn(values)
+}
+
+// stopRotation records why the rotation stopped and how to continue it.
+func stopRotation(logger *common.ColorLogger, state *rotateSecretsState, reason string) error {
+	state.Stopped = reason
+	if err := saveRotateSecretsState(state); err != nil {
+		return err
+	}
+	logger.Warn("Rotation stopped: %s", reason)
+	logger.Warn("Pending phase(s): %s", strings.Join(state.pendingPhases(), ", "))
+	logger.Info("Continue with: homeops-cli talos rotate-secrets --resume")
+	return nil
+}
+
+func renderRotationPlan(out io.Writer, state *rotateSecretsState, backingErr, gateErr error) {
+	_, _ = fmt.Fprintf(out, "Rotation of %s across %d node(s), control planes first:\n", strings.Join(state.Materials, " and "), len(state.Nodes))
+	completed := len(state.Completed)
+	for i, phase := range state.Phases {
+		marker := ""
+		if i < completed {
+			marker = " (done)"
+		}
+		_, _ = fmt.Fprintf(out, "  %d. %s%s: %s\n", i+1, phase, marker, rotatePhaseDescriptions[phase])
+		if phase == phaseUpdateStore || i < completed {
+			continue
+		}
+		for _, node := range state.Nodes {
+			_, _ = fmt.Fprintf(out, "       apply-config --mode auto -> %s (%s), then wait for health\n", node.IP, node.Type)
+		}
+	}
+	if backingErr != nil {
+		_, _ = fmt.Fprintf(out, "Secret backings: would refuse: %v\n", backingErr)
+	} else {
+		_, _ = fmt.Fprintln(out, "Secret backings: writable")
+	}
+	if gateErr != nil {
+		_, _ = fmt.Fprintf(out, "Backup gate: would refuse: %v\n", gateErr)
+	} else {
+		_, _ = fmt.Fprintln(out, "Backup gate: a fresh etcd snapshot exists")
+	}
+}
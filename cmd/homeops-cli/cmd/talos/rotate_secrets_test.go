@@ -0,0 +1,365 @@
+package talos
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"homeops-cli/internal/common"
+	versionconfig "homeops-cli/internal/config"
+	"homeops-cli/internal/constants"
+	"homeops-cli/internal/testutil"
+)
+
+const rotateTemplate = `version: v1alpha1
+machine:
+  type: controlplane
+  token: secret://talos_machine_token
+  ca:
+    crt: secret://talos_machine_ca_crt
+    key: secret://talos_machine_ca_key
+cluster:
+  id: secret://talos_cluster_id
+  token: secret://talos_cluster_token
+  ca:
+    crt: secret://talos_cluster_ca_crt
+    key: secret://talos_cluster_ca_key
+---
+apiVersion: v1alpha1
+kind: HostnameConfig
+hostname: node
+`
+
+const rotateBundle = `cluster:
+  id: unused-cluster-id
+secrets:
+  bootstraptoken: newboot.0123456789abcdef
+  secretboxencryptionsecret: unused-secretbox
+trustdinfo:
+  token: newtrd.0123456789abcdef
+certs:
+  os:
+    crt: NEW-MACHINE-CA-CRT
+    key: NEW-MACHINE-CA-KEY
+  k8s:
+    crt: NEW-CLUSTER-CA-CRT
+    key: NEW-CLUSTER-CA-KEY
+`
+
+var rotateOldValues = map[string]string{
+	versionconfig.KeyTalosMachineCACrt: "OLD-MACHINE-CA-CRT",
+	versionconfig.KeyTalosMachineCAKey: "OLD-MACHINE-CA-KEY",
+	versionconfig.KeyTalosClusterCACrt: "OLD-CLUSTER-CA-CRT",
+	versionconfig.KeyTalosClusterCAKey: "OLD-CLUSTER-CA-KEY",
+	versionconfig.KeyTalosMachineToken: "oldtrd.0123456789abcdef",
+	versionconfig.KeyTalosClusterToken: "oldboot.0123456789abcdef",
+}
+
+type rotateApply struct {
+	node   string
+	config string
+}
+
+// fakeRotation stands in for the cluster: the rotated secrets live in
+// file:// backings under a temp dir, and every talosctl step is recorded.
+type fakeRotation struct {
+	secretDir    string
+	events       []string
+	applies      []rotateApply
+	generated    int
+	gateErr      error
+	failHealthOn string
+}
+
+func installFakeRotation(t *testing.T, rotation *fakeRotation) {
+	t.Helper()
+	rotation.secretDir = t.TempDir()
+	secretRefs := map[string]string{versionconfig.KeyTalosClusterID: "literal://cluster-id"}
+	for key, value := range rotateOldValues {
+		path := filepath.Join(rotation.secretDir, key)
+		require.NoError(t, os.WriteFile(path, []byte(value+"\n"), 0600))
+		secretRefs[key] = "file://" + path
+	}
+	t.Cleanup(versionconfig.SetForTesting(&versionconfig.Config{Secrets: secretRefs}))
+
+	statePath := filepath.Join(t.TempDir(), "talos-rotate-secrets.json")
+	testutil.Swap(t, &rotateStatePathFn, func() (string, error) { return statePath, nil })
+	testutil.Swap(t, &rotateNowFn, func() time.Time { return time.Date(2026, 10, 14, 22, 0, 0, 0, time.UTC) })
+	testutil.Swap(t, &rotateClusterNodesFn, func() ([]string, error) { return []string{"10.0.0.20", "10.0.0.10"}, nil })
+	testutil.Swap(t, &getMachineTypeFromNodeFn, func(node string) (string, error) {
+		if node == "10.0.0.10" {
+			return "controlplane", nil
+		}
+		return "worker", nil
+	})
+	testutil.Swap(t, &confirmActionFn, func(string, bool) (bool, error) { return true, nil })
+	testutil.Swap(t, &rotateBackupGateFn, func(context.Context, time.Duration) error { return rotation.gateErr })
+	testutil.Swap(t, &rotateGenSecretsFn, func() ([]byte, error) {
+		rotation.generated++
+		return []byte(rotateBundle), nil
+	})
+	testutil.Swap(t, &renderMachineConfigFromEmbeddedFn, func(base, patch string) ([]byte, error) {
+		return []byte(rotateTemplate), nil
+	})
+	testutil.Swap(t, &talosApplyConfigFn, func(node, mode, config string) ([]byte, error) {
+		assert.Equal(t, "auto", mode)
+		rotation.events = append(rotation.events, "apply "+node)
+		rotation.applies = append(rotation.applies, rotateApply{node: node, config: config})
+		return nil, nil
+	})
+	testutil.Swap(t, &rotateNodeHealthFn, func(node string) error {
+		if node == rotation.failHealthOn {
+			rotation.failHealthOn = ""
+			return errors.New("etcd is not healthy")
+		}
+		rotation.events = append(rotation.events, "health "+node)
+		return nil
+	})
+	testutil.Swap(t, &rotateRefreshTalosconfigFn, func(_ *common.ColorLogger, node string) error {
+		rotation.events = append(rotation.events, "talosconfig "+node)
+		return nil
+	})
+	root := t.TempDir()
+	testutil.Swap(t, &repoRootFn, func() (string, error) { return root, nil })
+	testutil.Swap(t, &generateKubeconfigFn, func(node, rootDir string) ([]byte, error) {
+		rotation.events = append(rotation.events, "kubeconfig "+node)
+		return nil, nil
+	})
+	testutil.Swap(t, &pushKubeconfigFn, func(string, *common.ColorLogger) error {
+		rotation.events = append(rotation.events, "push kubeconfig")
+		return nil
+	})
+}
+
+func (r *fakeRotation) storedValue(t *testing.T, key string) string {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join(r.secretDir, key))
+	require.NoError(t, err)
+	return strings.TrimSpace(string(raw))
+}
+
+// appliedMachineDoc decodes the v1alpha1 document of an applied config.
+type appliedMachineDoc struct {
+	Machine struct {
+		Token       string              `yaml:"token"`
+		CA          map[string]string   `yaml:"ca"`
+		AcceptedCAs []map[string]string `yaml:"acceptedCAs"`
+	} `yaml:"machine"`
+	Cluster struct {
+		ID          string              `yaml:"id"`
+		Token       string              `yaml:"token"`
+		CA          map[string]string   `yaml:"ca"`
+		AcceptedCAs []map[string]string `yaml:"acceptedCAs"`
+	} `yaml:"cluster"`
+}
+
+func decodeAppliedConfig(t *testing.T, config string) appliedMachineDoc {
+	t.Helper()
+	var doc appliedMachineDoc
+	require.NoError(t, yaml.NewDecoder(strings.NewReader(config)).Decode(&doc))
+	assert.Contains(t, config, "kind: HostnameConfig", "extra documents survive the acceptedCAs patch")
+	return doc
+}
+
+func TestRotateSecretsFullRotationKeepsDualTrust(t *testing.T) {
+	rotation := &fakeRotation{}
+	installFakeRotation(t, rotation)
+
+	require.NoError(t, runRotateSecrets(context.Background(), quietUpgradeLogger(), &bytes.Buffer{}, rotateSecretsOptions{MaxBackupAge: 24 * time.Hour}))
+
+	assert.Equal(t, []string{
+		"apply 10.0.0.10", "health 10.0.0.10", "apply 10.0.0.20", "health 10.0.0.20",
+		"apply 10.0.0.10", "health 10.0.0.10", "apply 10.0.0.20", "health 10.0.0.20",
+		"talosconfig 10.0.0.10", "kubeconfig 10.0.0.10", "push kubeconfig",
+		"apply 10.0.0.10", "health 10.0.0.10", "apply 10.0.0.20", "health 10.0.0.20",
+		"apply 10.0.0.10", "health 10.0.0.10", "apply 10.0.0.20", "health 10.0.0.20",
+	}, rotation.events, "control planes first, client configs refreshed before the old CA is dropped")
+	require.Len(t, rotation.applies, 8)
+	assert.Equal(t, 1, rotation.generated)
+
+	trust := decodeAppliedConfig(t, rotation.applies[0].config)
+	assert.Equal(t, "OLD-MACHINE-CA-CRT", trust.Machine.CA["crt"], "trust-new-ca keeps issuing from the old CA")
+	assert.Equal(t, []map[string]string{{"crt": "NEW-MACHINE-CA-CRT"}}, trust.Machine.AcceptedCAs)
+	assert.Equal(t, []map[string]string{{"crt": "NEW-CLUSTER-CA-CRT"}}, trust.Cluster.AcceptedCAs)
+	assert.Equal(t, "cluster-id", trust.Cluster.ID, "unrotated references still resolve")
+
+	issue := decodeAppliedConfig(t, rotation.applies[2].config)
+	assert.Equal(t, "NEW-MACHINE-CA-CRT", issue.Machine.CA["crt"])
+	assert.Equal(t, "NEW-MACHINE-CA-KEY", issue.Machine.CA["key"])
+	assert.Equal(t, "NEW-CLUSTER-CA-CRT", issue.Cluster.CA["crt"])
+	assert.Equal(t, []map[string]string{{"crt": "OLD-MACHINE-CA-CRT"}}, issue.Machine.AcceptedCAs)
+	assert.Equal(t, []map[string]string{{"crt": "OLD-CLUSTER-CA-CRT"}}, issue.Cluster.AcceptedCAs)
+	assert.Equal(t, "oldtrd.0123456789abcdef", issue.Machine.Token, "tokens change in their own phase")
+
+	tokens := decodeAppliedConfig(t, rotation.applies[4].config)
+	assert.Equal(t, "newtrd.0123456789abcdef", tokens.Machine.Token)
+	assert.Equal(t, "newboot.0123456789abcdef", tokens.Cluster.Token)
+	assert.Equal(t, []map[string]string{{"crt": "OLD-MACHINE-CA-CRT"}}, tokens.Machine.AcceptedCAs)
+
+	drop := rotation.applies[6].config
+	assert.NotContains(t, drop, "acceptedCAs")
+	assert.NotContains(t, drop, "OLD-")
+
+	for key, want := range map[string]string{
+		versionconfig.KeyTalosMachineCACrt: "NEW-MACHINE-CA-CRT",
+		versionconfig.KeyTalosClusterCAKey: "NEW-CLUSTER-CA-KEY",
+		versionconfig.KeyTalosMachineToken: "newtrd.0123456789abcdef",
+		versionconfig.KeyTalosClusterToken: "newboot.0123456789abcdef",
+	} {
+		assert.Equal(t, want, rotation.storedValue(t, key), key)
+	}
+	state, err := loadRotateSecretsState()
+	require.NoError(t, err)
+	assert.Nil(t, state, "a finished rotation removes its progress and generated material")
+}
+
+func TestRotateSecretsTokenOnlySkipsCAPhases(t *testing.T) {
+	rotation := &fakeRotation{}
+	installFakeRotation(t, rotation)
+
+	require.NoError(t, runRotateSecrets(context.Background(), quietUpgradeLogger(), &bytes.Buffer{}, rotateSecretsOptions{Only: []string{"token"}, MaxBackupAge: time.Hour}))
+
+	require.Len(t, rotation.applies, 2)
+	applied := decodeAppliedConfig(t, rotation.applies[0].config)
+	assert.Equal(t, "newtrd.0123456789abcdef", applied.Machine.Token)
+	assert.Equal(t, "OLD-MACHINE-CA-CRT", applied.Machine.CA["crt"])
+	assert.Empty(t, applied.Machine.AcceptedCAs)
+	assert.NotContains(t, rotation.events, "talosconfig 10.0.0.10")
+	assert.Equal(t, "OLD-MACHINE-CA-CRT", rotation.storedValue(t, versionconfig.KeyTalosMachineCACrt))
+	assert.Equal(t, "newboot.0123456789abcdef", rotation.storedValue(t, versionconfig.KeyTalosClusterToken))
+}
+
+func TestRotateSecretsResumesFromFailedNode(t *testing.T) {
+	rotation := &fakeRotation{failHealthOn: "10.0.0.20"}
+	installFakeRotation(t, rotation)
+	opts := rotateSecretsOptions{MaxBackupAge: time.Hour}
+
+	err := runRotateSecrets(context.Background(), quietUpgradeLogger(), &bytes.Buffer{}, opts)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "10.0.0.20 did not report healthy after trust-new-ca")
+	assert.Contains(t, err.Error(), "--resume")
+
+	state, err := loadRotateSecretsState()
+	require.NoError(t, err)
+	require.NotNil(t, state)
+	assert.Empty(t, state.Completed)
+	assert.Equal(t, []string{"10.0.0.10"}, state.PhaseNodes)
+	assert.Contains(t, state.Stopped, "10.0.0.20")
+	assert.Equal(t, "OLD-MACHINE-CA-CRT", rotation.storedValue(t, versionconfig.KeyTalosMachineCACrt), "the store is untouched until update-store")
+
+	err = runRotateSecrets(context.Background(), quietUpgradeLogger(), &bytes.Buffer{}, opts)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "finish it with --resume")
+
+	rotation.events, rotation.applies = nil, nil
+	opts.Resume = true
+	require.NoError(t, runRotateSecrets(context.Background(), quietUpgradeLogger(), &bytes.Buffer{}, opts))
+	assert.Equal(t, "apply 10.0.0.20", rotation.events[0], "resume retries the failed node, not the finished one")
+	assert.Len(t, rotation.applies, 7)
+	assert.Equal(t, 1, rotation.generated, "resume reuses the saved material")
+	trust := decodeAppliedConfig(t, rotation.applies[0].config)
+	assert.Equal(t, []map[string]string{{"crt": "NEW-MACHINE-CA-CRT"}}, trust.Machine.AcceptedCAs)
+	assert.Equal(t, "NEW-MACHINE-CA-CRT", rotation.storedValue(t, versionconfig.KeyTalosMachineCACrt))
+
+	err = runRotateSecrets(context.Background(), quietUpgradeLogger(), &bytes.Buffer{}, opts)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no saved rotate-secrets run")
+}
+
+func TestRotateSecretsRefusesStaleBackup(t *testing.T) {
+	rotation := &fakeRotation{gateErr: errors.New("etcd backup gate: latest local snapshot is older than 24h0m0s")}
+	installFakeRotation(t, rotation)
+
+	err := runRotateSecrets(context.Background(), quietUpgradeLogger(), &bytes.Buffer{}, rotateSecretsOptions{MaxBackupAge: 24 * time.Hour})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "older than 24h0m0s")
+	assert.Zero(t, rotation.generated)
+	assert.Empty(t, rotation.applies)
+	state, err := loadRotateSecretsState()
+	require.NoError(t, err)
+	assert.Nil(t, state)
+}
+
+func TestRotateSecretsRefusesUnwritableBackingsAndSecretbox(t *testing.T) {
+	rotation := &fakeRotation{}
+	installFakeRotation(t, rotation)
+	refs := versionconfig.Get().Secrets
+	refs[versionconfig.KeyTalosClusterToken] = "env://TALOS_CLUSTER_TOKEN"
+
+	err := runRotateSecrets(context.Background(), quietUpgradeLogger(), &bytes.Buffer{}, rotateSecretsOptions{Only: []string{"token"}, MaxBackupAge: time.Hour})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "talos_cluster_token")
+	assert.Contains(t, err.Error(), "env:// backings cannot be updated")
+	assert.Zero(t, rotation.generated)
+
+	_, err = testutil.ExecuteCommand(newRotateSecretsCommand(), "--only", "secretbox")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "single secretbox key")
+}
+
+func TestRotateSecretsPlanChangesNothing(t *testing.T) {
+	rotation := &fakeRotation{gateErr: errors.New("etcd backup gate: no local etcd snapshot found")}
+	installFakeRotation(t, rotation)
+
+	var out bytes.Buffer
+	require.NoError(t, runRotateSecrets(context.Background(), quietUpgradeLogger(), &out, rotateSecretsOptions{Plan: true, MaxBackupAge: time.Hour}))
+
+	plan := out.String()
+	for _, want := range []string{
+		"Rotation of ca and token across 2 node(s)",
+		"1. trust-new-ca", "2. issue-from-new-ca", "3. rotate-tokens", "4. update-store", "5. drop-old-ca",
+		"-> 10.0.0.10 (controlplane)", "-> 10.0.0.20 (worker)",
+		"Secret backings: writable",
+		"Backup gate: would refuse: etcd backup gate: no local etcd snapshot found",
+	} {
+		assert.Contains(t, plan, want)
+	}
+	assert.Less(t, strings.Index(plan, "10.0.0.10"), strings.Index(plan, "10.0.0.20"))
+	assert.Zero(t, rotation.generated)
+	assert.Empty(t, rotation.applies)
+	state, err := loadRotateSecretsState()
+	require.NoError(t, err)
+	assert.Nil(t, state)
+}
+
+func TestRefreshTalosconfigKeepsEndpointsAndBacksUpOldFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(path, []byte("old talosconfig"), 0600))
+	t.Setenv(constants.EnvTalosconfig, path)
+
+	var calls []string
+	testutil.Swap(t, &talosctlOutputFn, func(name string, args ...string) ([]byte, error) {
+		calls = append(calls, strings.Join(args, " "))
+		if strings.Join(args, " ") == "config info --output json" {
+			return []byte(`{"endpoints":["10.0.0.10","10.0.0.11"],"nodes":["10.0.0.10"]}`), nil
+		}
+		return nil, nil
+	})
+	testutil.Swap(t, &talosctlNodeOutputFn, func(node string, args ...string) ([]byte, error) {
+		assert.Equal(t, "10.0.0.10", node)
+		require.Equal(t, []string{"config", "new", "--roles", "os:admin", path + ".rotate-new"}, args)
+		return nil, os.WriteFile(args[len(args)-1], []byte("new talosconfig"), 0600)
+	})
+
+	require.NoError(t, refreshTalosconfig(quietUpgradeLogger(), "10.0.0.10"))
+	assert.Equal(t, []string{
+		"config info --output json",
+		"--talosconfig " + path + ".rotate-new config endpoint 10.0.0.10 10.0.0.11",
+		"--talosconfig " + path + ".rotate-new config node 10.0.0.10",
+	}, calls)
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new talosconfig", string(current))
+	backup, err := os.ReadFile(path + ".pre-rotate")
+	require.NoError(t, err)
+	assert.Equal(t, "old talosconfig", string(backup))
+}
@@ -0,0 +1,218 @@
+package talos
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	versionconfig "homeops-cli/internal/config"
+	"homeops-cli/internal/ui"
+)
+
+// Schematic status verdicts.
+const (
+	schematicMatch       = "match"
+	schematicDrifted     = "drifted"
+	schematicUnreachable = "unreachable"
+	schematicUnknown     = "unknown"
+)
+
+// factoryInstallerImage matches an Image Factory installer image
+// (installer, metal-installer, installer-secureboot, ...) and captures its
+// schematic ID.
+var factoryInstallerImage = regexp.MustCompile(`^factory\.talos\.dev/[a-z0-9-]*installer[a-z0-9-]*/([0-9a-f]{64}):`)
+
+type schematicNodeStatus struct {
+	Node     string `json:"node"`
+	IP       string `json:"ip"`
+	Template string `json:"template"`
+	Booted   string `json:"booted"`
+	Config   string `json:"config"`
+	Verdict  string `json:"verdict"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+type schematicStatusReport struct {
+	Nodes []schematicNodeStatus `json:"nodes"`
+}
+
+// talosExtensionStatus is the part of a `talosctl get extensions -o json`
+// resource that names the extension. Factory images carry a "schematic"
+// extension whose version is the schematic ID the node booted.
+type talosExtensionStatus struct {
+	Spec struct {
+		Metadata struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"metadata"`
+	} `json:"spec"`
+}
+
+func newSchematicStatusCommand() *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "schematic-status",
+		Short: "Compare the schematic each node boots with the one its templates install",
+		Long: `For every node in cluster.nodes, reads the schematic ID from the installer
+image in the node's rendered templates (its machine type's base template
+merged with talos/nodes/<ip>.yaml, as apply-node renders it) and compares it
+with the schematic the node booted, from talosctl get extensions.
+
+prepare-iso rewrites the templates with a new schematic, but running nodes
+keep booting the old one until they are upgraded. A node reported as
+"drifted" needs talos upgrade-node. The CONFIG column is the installer image
+in the node's running machine config; if it is also stale, apply-node first.
+
+Exits non-zero when any node has drifted.`,
+		Example: `  homeops-cli talos schematic-status
+  homeops-cli talos schematic-status --output json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ui.ValidateOutputFormat(output); err != nil {
+				return err
+			}
+			report := collectSchematicStatus(versionconfig.Get())
+			rendered, err := renderSchematicStatusReport(report, output)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintln(cmd.OutOrStdout(), rendered); err != nil {
+				return err
+			}
+			var drifted []string
+			for _, node := range report.Nodes {
+				if node.Verdict == schematicDrifted {
+					drifted = append(drifted, node.Node)
+				}
+			}
+			if len(drifted) > 0 {
+				return fmt.Errorf("%s boot a different schematic than the templates (talos upgrade-node moves a node to it)", strings.Join(drifted, ", "))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table or json")
+	return cmd
+}
+
+func collectSchematicStatus(cfg *versionconfig.Config) schematicStatusReport {
+	var report schematicStatusReport
+	for _, node := range cfg.Cluster.Nodes {
+		status := schematicNodeStatus{Node: node.Name, IP: node.IP}
+		template, err := templateSchematic(node.IP)
+		if err != nil {
+			status.Verdict = schematicUnknown
+			status.Detail = err.Error()
+			report.Nodes = append(report.Nodes, status)
+			continue
+		}
+		status.Template = template
+
+		output, err := talosctlNodeOutputFn(node.IP, "get", "extensions", "-o", "json")
+		if err != nil {
+			status.Verdict = schematicUnreachable
+			status.Detail = strings.TrimSpace(err.Error())
+			report.Nodes = append(report.Nodes, status)
+			continue
+		}
+		if status.Booted, err = parseBootedSchematic(output); err != nil {
+			status.Verdict = schematicUnknown
+			status.Detail = err.Error()
+			report.Nodes = append(report.Nodes, status)
+			continue
+		}
+		if image, err := runningInstallImage(node.IP); err == nil {
+			status.Config, _ = imageSchematic(image)
+		}
+
+		switch {
+		case status.Booted == "":
+			status.Verdict = schematicUnknown
+			status.Detail = "node did not boot a factory image (no schematic extension)"
+		case status.Booted == status.Template:
+			status.Verdict = schematicMatch
+		default:
+			status.Verdict = schematicDrifted
+		}
+		if status.Config != "" && status.Config != status.Template {
+			status.Detail = "running config installs a different schematic; apply-node before upgrading"
+		}
+		report.Nodes = append(report.Nodes, status)
+	}
+	return report
+}
+
+// templateSchematic renders nodeIP's config as render-config does and
+// returns the schematic ID of its installer image.
+func templateSchematic(nodeIP string) (string, error) {
+	patchTemplate := fmt.Sprintf("talos/nodes/%s.yaml", nodeIP)
+	patch, err := getTalosTemplateFn(patchTemplate)
+	if err != nil {
+		return "", fmt.Errorf("no machine config template %s: %w", patchTemplate, err)
+	}
+	rendered, err := renderMachineConfigFromEmbeddedFn(fmt.Sprintf("talos/%s.yaml", nodeTemplateMachineType(patch)), patchTemplate)
+	if err != nil {
+		return "", fmt.Errorf("failed to render config: %w", err)
+	}
+	image, err := installImage(string(rendered), nodeIP+" config")
+	if err != nil {
+		return "", err
+	}
+	return imageSchematic(image)
+}
+
+// imageSchematic returns the schematic ID of a factory.talos.dev installer
+// image.
+func imageSchematic(image string) (string, error) {
+	match := factoryInstallerImage.FindStringSubmatch(image)
+	if match == nil {
+		return "", fmt.Errorf("installer image %s is not a factory.talos.dev image", image)
+	}
+	return match[1], nil
+}
+
+// parseBootedSchematic returns the version of the "schematic" extension, or
+// "" when the node has none. talosctl prints one JSON object per resource,
+// not an array.
+func parseBootedSchematic(output []byte) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	for {
+		var extension talosExtensionStatus
+		if err := decoder.Decode(&extension); err != nil {
+			if errors.Is(err, io.EOF) {
+				return "", nil
+			}
+			return "", fmt.Errorf("failed to parse extensions: %w", err)
+		}
+		if extension.Spec.Metadata.Name == "schematic" {
+			return extension.Spec.Metadata.Version, nil
+		}
+	}
+}
+
+func renderSchematicStatusReport(report schematicStatusReport, output string) (string, error) {
+	if output == "json" {
+		return ui.RenderJSON(report)
+	}
+	rows := make([][]string, 0, len(report.Nodes))
+	for _, node := range report.Nodes {
+		rows = append(rows, []string{node.Node, node.IP, shortSchematic(node.Template), shortSchematic(node.Booted), shortSchematic(node.Config), node.Verdict, node.Detail})
+	}
+	return fmt.Sprintf("TALOS SCHEMATICS\n\n%s",
+		ui.Table([]string{"NODE", "IP", "TEMPLATE", "BOOTED", "CONFIG", "VERDICT", "DETAIL"}, rows)), nil
+}
+
+// shortSchematic abbreviates a 64-character schematic ID for the table;
+// --output json carries the full IDs.
+func shortSchematic(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
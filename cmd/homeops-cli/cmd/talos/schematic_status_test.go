@@ -0,0 +1,68 @@
+package talos
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	versionconfig "homeops-cli/internal/config"
+	"homeops-cli/internal/testutil"
+)
+
+func TestSchematicStatusFlagsDriftedNodes(t *testing.T) {
+	current := strings.Repeat("a", 64)
+	older := strings.Repeat("b", 64)
+	t.Cleanup(versionconfig.SetForTesting(&versionconfig.Config{Cluster: versionconfig.ClusterConfig{
+		Nodes: []versionconfig.Node{{Name: "k8s-0", IP: "10.0.0.10"}, {Name: "k8s-1", IP: "10.0.0.11"}, {Name: "k8s-2", IP: "10.0.0.12"}},
+	}}))
+	testutil.Swap(t, &getTalosTemplateFn, func(string) (string, error) { return "machine:\n  type: controlplane\n", nil })
+	testutil.Swap(t, &renderMachineConfigFromEmbeddedFn, func(base, patch string) ([]byte, error) {
+		assert.Equal(t, "talos/controlplane.yaml", base)
+		return []byte("machine:\n  install:\n    image: factory.talos.dev/installer/" + current + ":v1.13.6\n"), nil
+	})
+	extensions := func(schematic string) []byte {
+		return []byte(`{"node":"n","metadata":{"id":"0"},"spec":{"metadata":{"name":"iscsi-tools","version":"v0.2.0"}}}
+{"node":"n","metadata":{"id":"1"},"spec":{"metadata":{"name":"schematic","version":"` + schematic + `"}}}
+`)
+	}
+	testutil.Swap(t, &talosctlNodeOutputFn, func(node string, args ...string) ([]byte, error) {
+		if args[1] == "machineconfig" {
+			return []byte("machine:\n  install:\n    image: factory.talos.dev/installer/" + current + ":v1.13.6\n"), nil
+		}
+		assert.Equal(t, []string{"get", "extensions", "-o", "json"}, args)
+		switch node {
+		case "10.0.0.10":
+			return extensions(current), nil
+		case "10.0.0.11":
+			return extensions(older), nil
+		}
+		return nil, errors.New("connection refused")
+	})
+
+	out, err := testutil.ExecuteCommand(newSchematicStatusCommand(), "--output", "json")
+	require.ErrorContains(t, err, "k8s-1 boot a different schematic than the templates")
+	var report schematicStatusReport
+	require.NoError(t, json.NewDecoder(strings.NewReader(out)).Decode(&report), "cobra appends the error after the report")
+	require.Len(t, report.Nodes, 3)
+	var verdicts []string
+	for _, node := range report.Nodes {
+		verdicts = append(verdicts, node.Verdict)
+	}
+	assert.Equal(t, []string{schematicMatch, schematicDrifted, schematicUnreachable}, verdicts)
+	assert.Equal(t, schematicNodeStatus{Node: "k8s-1", IP: "10.0.0.11", Template: current, Booted: older, Config: current, Verdict: schematicDrifted}, report.Nodes[1])
+}
+
+func TestImageSchematic(t *testing.T) {
+	id := strings.Repeat("c", 64)
+	for _, image := range []string{"factory.talos.dev/installer/" + id + ":v1.13.6", "factory.talos.dev/metal-installer-secureboot/" + id + ":v1.13.6"} {
+		got, err := imageSchematic(image)
+		require.NoError(t, err)
+		assert.Equal(t, id, got)
+	}
+	_, err := imageSchematic("ghcr.io/siderolabs/installer:v1.13.6")
+	assert.EqualError(t, err, "installer image ghcr.io/siderolabs/installer:v1.13.6 is not a factory.talos.dev image")
+}
@@ -3,18 +3,23 @@ package talos
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"homeops-cli/cmd/completion"
 	"homeops-cli/cmd/vm"
+	"homeops-cli/internal/cloudinit"
 	"homeops-cli/internal/cmdutil"
 	"homeops-cli/internal/common"
 	versionconfig "homeops-cli/internal/config"
@@ -29,6 +34,7 @@ import (
 	"homeops-cli/internal/templates"
 	"homeops-cli/internal/truenas"
 	"homeops-cli/internal/ui"
+	"homeops-cli/internal/versioncheck"
 	"homeops-cli/internal/vmlifecycle"
 	"homeops-cli/internal/vsphere"
 	localyaml "homeops-cli/internal/yaml"
@@ -50,7 +56,8 @@ var (
 	proxmoxDefaultVMConfig            = proxmox.GetDefaultVMConfig
 	getTalosNodeIPsFn                 = talos.GetNodeIPs
 	getTalosTemplateFn                = templates.GetTalosTemplate
-	workingDirectoryFn                = common.GetWorkingDirectory
+	repoRootFn                        = common.RepoRoot
+	checkVersionsFn                   = versioncheck.Check
 	getMachineTypeFromNodeFn          = getMachineTypeFromNode
 	renderMachineConfigFromEmbeddedFn = renderMachineConfigFromEmbedded
 	injectSecretsFn                   = secrets.Inject
@@ -58,12 +65,7 @@ var (
 	talosctlOutputFn                  = common.Output
 	talosctlCombinedOutputFn          = common.CombinedOutput
 	talosApplyConfigFn                = func(nodeIP, mode, config string) ([]byte, error) {
-		cmd := common.Command("talosctl", "--nodes", nodeIP, "apply-config", "--mode", mode, "--file", "/dev/stdin")
-		cmd.Stdin = bytes.NewReader([]byte(config))
-		out, err := cmd.CombinedOutput()
-		// Redact before returning — apply-config error output may echo a snippet of
-		// the machineconfig that contains secrets.
-		return []byte(common.RedactCommandOutput(string(out))), err
+		return runTalosApplyConfig(nodeIP, mode, config)
 	}
 	talosctlNodeOutputFn = func(nodeIP string, args ...string) ([]byte, error) {
 		commandArgs := append([]string{"--nodes", nodeIP}, args...)
@@ -97,14 +99,20 @@ var (
 	prepareISOForProxmoxFn             = prepareISOForProxmox
 	prepareISOForVSphereFn             = prepareISOForVSphere
 	prepareISOForTargetFn              = prepareISOForTarget
+	prepareISOForTargetsFn             = prepareISOForTargets
 	spinWithFuncFn                     = ui.SpinWithFunc
+	spinWithProgressFn                 = ui.SpinWithProgress
 	spinCommandFn                      = ui.Spin
 	updateNodeTemplatesWithSchematicFn = updateNodeTemplatesWithSchematic
-	uploadISOToVSphereFn               = uploadISOToVSphere
-	// isoDownloadClient bounds ISO downloads: without a timeout a stalled
-	// mirror hangs prepare-iso/deploy-vm forever. 30m accommodates slow links.
+	uploadISOFileToVSphereFn           = uploadISOFileToVSphere
+	downloadISOToTempFn                = downloadISOToTemp
+	// isoDownloadClient bounds each ISO download request: without a timeout
+	// a stalled mirror hangs prepare-iso/deploy-vm forever. 30m accommodates
+	// slow links.
 	isoDownloadClient        = &http.Client{Timeout: 30 * time.Minute}
-	httpGetFn                = isoDownloadClient.Get
+	httpDoFn                 = isoDownloadClient.Do
+	isoTransferRetryDelay    = 2 * time.Second
+	vSphereUploadAttempts    = 3
 	controlplaneTemplatePath = "cmd/homeops-cli/internal/templates/talos/controlplane.yaml"
 	newISODownloaderFn       = func() isoDownloader {
 		return iso.NewDownloader()
@@ -134,17 +142,23 @@ type talosFactoryClient interface {
 
 type isoDownloader interface {
 	DownloadCustomISO(iso.DownloadConfig) error
+	StoredISOSize(iso.DownloadConfig) (int64, error)
 }
 
 type trueNASSSHClient interface {
 	Connect() error
 	Close() error
 	VerifyFile(string) (bool, int64, error)
+	ExecuteCommand(string) (string, error)
+	UploadBytes([]byte, string) error
 }
 
 type vsphereVMDeployer interface {
 	CreateVM(vsphere.VMConfig) error
 	DeployVMsConcurrently([]vsphere.VMConfig) error
+	PreflightDatastores([]vsphere.VMConfig) error
+	SpreadAcrossHosts([]vsphere.VMConfig) ([]vsphere.VMConfig, error)
+	AddAntiAffinityRule(string, []string) error
 	Close() error
 }
 
@@ -161,6 +175,18 @@ func (d *defaultVSphereDeployer) DeployVMsConcurrently(configs []vsphere.VMConfi
 	return d.client.DeployVMsConcurrently(configs)
 }
 
+func (d *defaultVSphereDeployer) PreflightDatastores(configs []vsphere.VMConfig) error {
+	return d.client.PreflightDatastores(configs)
+}
+
+func (d *defaultVSphereDeployer) SpreadAcrossHosts(configs []vsphere.VMConfig) ([]vsphere.VMConfig, error) {
+	return d.client.SpreadAcrossHosts(configs)
+}
+
+func (d *defaultVSphereDeployer) AddAntiAffinityRule(ruleName string, vmNames []string) error {
+	return d.client.AddAntiAffinityRule(ruleName, vmNames)
+}
+
 func (d *defaultVSphereDeployer) Close() error {
 	return d.client.Close()
 }
@@ -182,8 +208,13 @@ Use ` + "`homeops-cli vm`" + ` for VM lifecycle operations such as list, start,
 	// Add subcommands
 	cmd.AddCommand(
 		newApplyNodeCommand(),
+		newApplyClusterCommand(),
+		newRenderConfigCommand(),
+		newAddNodeCommand(),
 		newUpgradeNodeCommand(),
+		newUpgradeClusterCommand(),
 		newUpgradeK8sCommand(),
+		newRotateSecretsCommand(),
 		newRebootNodeCommand(),
 		newShutdownClusterCommand(),
 		newResetNodeCommand(),
@@ -191,6 +222,10 @@ Use ` + "`homeops-cli vm`" + ` for VM lifecycle operations such as list, start,
 		newKubeconfigCommand(),
 		newPrepareISOCommand(),
 		newDeployVMCommand(),
+		newCheckIPCommand(),
+		newEncryptionStatusCommand(),
+		newSchematicStatusCommand(),
+		newHealthCommand(),
 		vm.NewManageVMCommand(),
 		vm.NewVMLifecycleRootGuidanceCommand("list"),
 		vm.NewVMLifecycleRootGuidanceCommand("start"),
@@ -246,23 +281,30 @@ func runTalosctlCombinedOutput(args ...string) ([]byte, error) {
 
 func newApplyNodeCommand() *cobra.Command {
 	var (
-		nodeIP string
-		mode   string
-		dryRun bool
+		nodeIP      string
+		mode        string
+		dryRun      bool
+		showSecrets bool
 	)
 
 	cmd := &cobra.Command{
 		Use:   "apply-node",
 		Short: "Apply Talos config to a node",
-		Long:  `Apply Talos configuration to a node. If --ip is not specified, presents an interactive selector.`,
+		Long: `Apply Talos configuration to a node. If --ip is not specified, presents an interactive selector.
+
+--dry-run renders and resolves the config, then prints the diff talosctl
+apply-config --dry-run reports against the node's running config without
+applying it. Diff lines containing a resolved secret are masked unless
+--show-secrets is set.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return applyNodeConfig(nodeIP, mode, dryRun)
+			return applyNodeConfig(cmd.OutOrStdout(), nodeIP, mode, dryRun, showSecrets)
 		},
 	}
 
 	cmd.Flags().StringVar(&nodeIP, "ip", "", "Node IP address (optional - will prompt if not provided)")
 	cmd.Flags().StringVar(&mode, "mode", "auto", "Apply mode (auto, interactive, etc.)")
-	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Render and validate, but do not apply the configuration")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the config diff against the node, but do not apply the configuration")
+	cmd.Flags().BoolVar(&showSecrets, "show-secrets", false, "With --dry-run, do not mask diff lines containing resolved secrets")
 
 	// Add completion for IP flag
 	_ = cmd.RegisterFlagCompletionFunc("ip", completion.ValidNodeIPs)
@@ -270,7 +312,7 @@ func newApplyNodeCommand() *cobra.Command {
 	return cmd
 }
 
-func applyNodeConfig(nodeIP, mode string, dryRun bool) error {
+func applyNodeConfig(out io.Writer, nodeIP, mode string, dryRun, showSecrets bool) error {
 	logger := common.NewColorLogger()
 
 	// If node IP is not provided, prompt for selection
@@ -303,25 +345,10 @@ func applyNodeConfig(nodeIP, mode string, dryRun bool) error {
 		return fmt.Errorf("failed to render config: %w", err)
 	}
 
-	// Resolve 1Password references in the rendered config with signin-once retry
 	logger.Info("Resolving 1Password references in Talos configuration...")
-	resolvedConfig, err := injectSecretsFn(string(renderedConfig))
+	resolvedConfig, err := injectSecretsWithSignin(logger, string(renderedConfig))
 	if err != nil {
-		errStr := strings.ToLower(err.Error())
-		if strings.Contains(errStr, "not authenticated") || strings.Contains(errStr, "not signed in") || strings.Contains(errStr, "please run 'op signin'") {
-			logger.Info("Attempting 1Password CLI signin due to authentication error...")
-			if err2 := ensure1PasswordAuthFn(); err2 != nil {
-				return fmt.Errorf("1Password signin failed: %w (original: %v)", err2, err)
-			}
-			// Retry once after successful signin
-			if retryResolved, retryErr := injectSecretsFn(string(renderedConfig)); retryErr == nil {
-				resolvedConfig = retryResolved
-			} else {
-				return fmt.Errorf("secret resolution failed after signin: %w", retryErr)
-			}
-		} else {
-			return fmt.Errorf("failed to resolve 1Password references: %w", err)
-		}
+		return err
 	}
 
 	if dryRun {
@@ -330,20 +357,70 @@ func applyNodeConfig(nodeIP, mode string, dryRun bool) error {
 		if err := yaml.Unmarshal([]byte(resolvedConfig), &data); err != nil {
 			return fmt.Errorf("rendered config failed YAML validation: %w", err)
 		}
-		logger.Info("[DRY RUN] Would apply config to %s (type: %s)", nodeIP, machineType)
+		output, err := talosDryRunApplyConfigFn(nodeIP, mode, resolvedConfig)
+		diff := string(output)
+		if !showSecrets {
+			diff = common.RedactCommandOutput(redactSecretLines(diff, secrets.InjectedValues(string(renderedConfig), resolvedConfig)))
+		}
+		if err != nil {
+			return fmt.Errorf("dry-run apply failed: %w\n%s", err, diff)
+		}
+		if _, err := fmt.Fprintln(out, colorConfigDiff(strings.TrimRight(diff, "\n"))); err != nil {
+			return err
+		}
+		logger.Info("[DRY RUN] Config for %s (type: %s) was not applied", nodeIP, machineType)
 		return nil
 	}
 
 	// Apply the configuration
 	output, err := talosApplyConfigFn(nodeIP, mode, resolvedConfig)
 	if err != nil {
-		return fmt.Errorf("failed to apply config: %w\n%s", err, output)
+		return fmt.Errorf("failed to apply config: %w\n%s", err, common.RedactCommandOutput(string(output)))
 	}
 
 	logger.Success("Configuration applied successfully to %s", nodeIP)
 	return nil
 }
 
+// runTalosApplyConfig pipes config to talosctl apply-config on nodeIP.
+func runTalosApplyConfig(nodeIP, mode, config string) ([]byte, error) {
+	out, err := runTalosApplyConfigRaw(nodeIP, mode, config)
+	// Redact before returning — apply-config error output may echo a snippet of
+	// the machineconfig that contains secrets.
+	return []byte(common.RedactCommandOutput(string(out))), err
+}
+
+// runTalosApplyConfigRaw is runTalosApplyConfig without redaction; callers
+// must redact the output before showing it.
+func runTalosApplyConfigRaw(nodeIP, mode, config string, extraArgs ...string) ([]byte, error) {
+	args := append([]string{"--nodes", nodeIP, "apply-config", "--mode", mode, "--file", "/dev/stdin"}, extraArgs...)
+	cmd := common.Command("talosctl", args...)
+	cmd.Stdin = bytes.NewReader([]byte(config))
+	return cmd.CombinedOutput()
+}
+
+// injectSecretsWithSignin resolves the secret references in a rendered
+// config, signing in to 1Password and retrying once on an auth error.
+func injectSecretsWithSignin(logger *common.ColorLogger, rendered string) (string, error) {
+	resolved, err := injectSecretsFn(rendered)
+	if err == nil {
+		return resolved, nil
+	}
+	errStr := strings.ToLower(err.Error())
+	if !strings.Contains(errStr, "not authenticated") && !strings.Contains(errStr, "not signed in") && !strings.Contains(errStr, "please run 'op signin'") {
+		return "", fmt.Errorf("failed to resolve 1Password references: %w", err)
+	}
+	logger.Info("Attempting 1Password CLI signin due to authentication error...")
+	if err2 := ensure1PasswordAuthFn(); err2 != nil {
+		return "", fmt.Errorf("1Password signin failed: %w (original: %v)", err2, err)
+	}
+	resolved, err = injectSecretsFn(rendered)
+	if err != nil {
+		return "", fmt.Errorf("secret resolution failed after signin: %w", err)
+	}
+	return resolved, nil
+}
+
 func getMachineTypeFromNode(nodeIP string) (string, error) {
 	output, err := talosctlNodeOutputFn(nodeIP, "get", "machinetypes", "--output=jsonpath={.spec}")
 	if err != nil {
@@ -352,6 +429,25 @@ func getMachineTypeFromNode(nodeIP string) (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
+// repoVersions loads the version plans from the repository. They are
+// repo-relative, so running outside a checkout fails instead of silently
+// falling back to built-in versions.
+func repoVersions() (*versionconfig.VersionConfig, error) {
+	rootDir, err := repoRootFn()
+	if err != nil {
+		return nil, err
+	}
+	return versionconfig.GetVersions(rootDir), nil
+}
+
+func checkRepoVersions(ctx context.Context, components ...versioncheck.Component) error {
+	rootDir, err := repoRootFn()
+	if err != nil {
+		return err
+	}
+	return checkVersionsFn(ctx, rootDir, components...)
+}
+
 func renderMachineConfigFromEmbedded(baseTemplate, patchTemplate string) ([]byte, error) {
 	return renderMachineConfigFromEmbeddedWithSchematic(baseTemplate, patchTemplate, "")
 }
@@ -364,37 +460,90 @@ func renderMachineConfigFromEmbeddedWithSchematic(baseTemplate, patchTemplate, s
 	// Create unified template renderer
 	renderer := templates.NewTemplateRenderer(common.GetWorkingDirectory(), logger, metricsCollector)
 
+	env, err := talosRenderEnv(schematicID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use the unified renderer for Talos config rendering and merging
+	return renderer.RenderTalosConfigWithMerge(baseTemplate, patchTemplate, env)
+}
+
+// renderMachineConfigWithPatchContent renders baseTemplate merged with a node
+// patch that exists only as content, as if embedded at patchTemplate.
+func renderMachineConfigWithPatchContent(baseTemplate, patchTemplate, patchContent string) ([]byte, error) {
+	logger := common.NewColorLogger()
+	metricsCollector := metrics.NewPerformanceCollector()
+	defer metricsCollector.LogReport(logger)
+
+	renderer := templates.NewTemplateRenderer(common.GetWorkingDirectory(), logger, metricsCollector)
+	env, err := talosRenderEnv("")
+	if err != nil {
+		return nil, err
+	}
+	return renderer.RenderTalosConfigWithPatchContent(baseTemplate, patchTemplate, patchContent, env)
+}
+
+// talosRenderEnv is the ENV the Talos templates render with.
+func talosRenderEnv(schematicID string) (map[string]string, error) {
 	// Prepare environment variables for template rendering
 	env := make(map[string]string)
 	env["SCHEMATIC_ID"] = schematicID
 
 	// Add other common environment variables
-	versionConfig := versionconfig.GetVersions(common.GetWorkingDirectory())
+	versionConfig, err := repoVersions()
+	if err != nil {
+		return nil, err
+	}
 	env["KUBERNETES_VERSION"] = versionConfig.KubernetesVersion
 	env["TALOS_VERSION"] = versionConfig.TalosVersion
 	env["TALOS_KUBERNETES_VERSION"] = versionConfig.TalosKubernetesVersion
-
-	// Use the unified renderer for Talos config rendering and merging
-	return renderer.RenderTalosConfigWithMerge(baseTemplate, patchTemplate, env)
+	return env, nil
 }
 
 func newUpgradeNodeCommand() *cobra.Command {
 	var (
-		nodeIP string
-		mode   string
+		nodeIP       string
+		window       string
+		ignoreWindow bool
+		opts         upgradeNodeOptions
 	)
 
 	cmd := &cobra.Command{
 		Use:   "upgrade-node",
 		Short: "Upgrade Talos on a single node",
-		Long:  `Upgrade Talos on a node. If --ip is not specified, presents an interactive selector.`,
+		Long: `Upgrade Talos on a node. If --ip is not specified, presents an interactive selector.
+
+The target installer image is machine.install.image from the node's rendered
+config (its machine type's base template merged with talos/nodes/<ip>.yaml, as
+apply-node renders it), so a node whose patch overrides the image gets its own
+schematic. --image upgrades to an explicit image instead. The node is skipped
+when the image in its running machine config already matches, unless --force.
+
+With --window the upgrade refuses to start outside the maintenance window
+unless --ignore-window is set.
+
+Before the upgrade an etcd snapshot is taken from the first control plane and
+saved to state.etcd_backup.dir; the upgrade does not start if it fails.
+--skip-snapshot upgrades without one.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return upgradeNode(nodeIP, mode)
+			if err := checkUpgradeWindow(window, ignoreWindow); err != nil {
+				return err
+			}
+			if err := checkRepoVersions(cmd.Context(), versioncheck.Talos); err != nil {
+				return err
+			}
+			return upgradeNode(cmd.Context(), nodeIP, opts)
 		},
 	}
 
 	cmd.Flags().StringVar(&nodeIP, "ip", "", "Node IP address (optional - will prompt if not provided)")
-	cmd.Flags().StringVar(&mode, "mode", "powercycle", "Reboot mode")
+	cmd.Flags().StringVar(&opts.Mode, "mode", "powercycle", "Reboot mode")
+	cmd.Flags().StringVar(&opts.Image, "image", "", "Installer image to upgrade to (default: machine.install.image from the node's rendered config)")
+	cmd.Flags().BoolVar(&opts.Force, "force", false, "Upgrade even when the node already runs the target image")
+	cmd.Flags().StringVar(&window, "window", "", `Maintenance window in local time, e.g. "22:00-06:00"`)
+	cmd.Flags().BoolVar(&ignoreWindow, "ignore-window", false, "Upgrade even when outside --window")
+	addUpgradeSnapshotFlags(cmd, &opts.Snapshot)
 
 	// Add completion for IP flag
 	_ = cmd.RegisterFlagCompletionFunc("ip", completion.ValidNodeIPs)
@@ -402,7 +551,15 @@ func newUpgradeNodeCommand() *cobra.Command {
 	return cmd
 }
 
-func upgradeNode(nodeIP, mode string) error {
+// upgradeNodeOptions holds the upgrade-node flags.
+type upgradeNodeOptions struct {
+	Mode     string
+	Image    string
+	Force    bool
+	Snapshot upgradeSnapshotOptions
+}
+
+func upgradeNode(ctx context.Context, nodeIP string, opts upgradeNodeOptions) error {
 	logger := common.NewColorLogger()
 
 	// If node IP is not provided, prompt for selection
@@ -417,39 +574,97 @@ func upgradeNode(nodeIP, mode string) error {
 		nodeIP = selectedNode
 	}
 
-	// Get factory image from controlplane config instead of individual node configs
+	image := opts.Image
+	if image == "" {
+		var err error
+		if image, err = nodeInstallImage(nodeIP); err != nil {
+			return err
+		}
+	}
+	current, err := runningInstallImage(nodeIP)
+	if err != nil {
+		logger.Warn("Could not read the running image on %s: %v", nodeIP, err)
+		current = "unknown"
+	}
+	logger.Info("Node %s: running %s, target %s", nodeIP, current, image)
+	if current == image && !opts.Force {
+		logger.Success("Node %s already runs %s; skipping (--force to upgrade anyway)", nodeIP, image)
+		return nil
+	}
+
+	if err := snapshotBeforeUpgrade(ctx, logger, opts.Snapshot); err != nil {
+		return err
+	}
+	return upgradeNodeToImage(logger, nodeIP, image, opts.Mode)
+}
+
+// nodeInstallImage renders a node's merged config the way apply-node does and
+// returns its installer image, so a patch that overrides the image wins over
+// the base template.
+func nodeInstallImage(nodeIP string) (string, error) {
+	machineType, err := getMachineTypeFromNodeFn(nodeIP)
+	if err != nil {
+		return "", fmt.Errorf("failed to get machine type: %w", err)
+	}
+	rendered, err := renderMachineConfigFromEmbeddedFn(fmt.Sprintf("talos/%s.yaml", machineType), fmt.Sprintf("talos/nodes/%s.yaml", nodeIP))
+	if err != nil {
+		return "", fmt.Errorf("failed to render config for %s: %w", nodeIP, err)
+	}
+	return installImage(string(rendered), nodeIP+" config")
+}
+
+// runningInstallImage reads machine.install.image from the machine config the
+// node is running.
+func runningInstallImage(nodeIP string) (string, error) {
+	output, err := talosctlNodeOutputFn(nodeIP, "get", "machineconfig", "--output=jsonpath={.spec}")
+	if err != nil {
+		return "", fmt.Errorf("failed to get machine config: %w", err)
+	}
+	return installImage(string(output), "running config")
+}
+
+// upgradeFactoryImage reads the installer image from the controlplane config
+// instead of individual node configs.
+func upgradeFactoryImage() (string, error) {
 	controlplaneTemplate := "talos/controlplane.yaml"
 	configOutput, err := getTalosTemplateFn(controlplaneTemplate)
 	if err != nil {
-		return fmt.Errorf("failed to get controlplane config: %w", err)
+		return "", fmt.Errorf("failed to get controlplane config: %w", err)
 	}
+	return installImage(configOutput, "controlplane config")
+}
 
-	// Extract factory image using Go YAML processor
+// installImage extracts machine.install.image from a Talos machine config;
+// source names the config in errors.
+func installImage(config, source string) (string, error) {
 	metricsCollector := metrics.NewPerformanceCollector()
 	defer metricsCollector.LogReport(common.NewColorLogger())
 	processor := localyaml.NewProcessor(nil, metricsCollector)
 
 	// Parse YAML content into a map
-	configData, err := processor.ParseString(configOutput)
+	configData, err := processor.ParseString(config)
 	if err != nil {
-		return fmt.Errorf("failed to parse controlplane config: %w", err)
+		return "", fmt.Errorf("failed to parse %s: %w", source, err)
 	}
 
 	// Extract factory image using GetValue
 	factoryImageValue, err := processor.GetValue(configData, "machine.install.image")
 	if err != nil {
-		return fmt.Errorf("failed to get factory image: %w", err)
+		return "", fmt.Errorf("failed to get factory image from %s: %w", source, err)
 	}
 
 	factoryImage, ok := factoryImageValue.(string)
 	if !ok {
-		return fmt.Errorf("factory image is not a string: %v", factoryImageValue)
+		return "", fmt.Errorf("factory image in %s is not a string: %v", source, factoryImageValue)
 	}
+	return factoryImage, nil
+}
 
+func upgradeNodeToImage(logger *common.ColorLogger, nodeIP, factoryImage, mode string) error {
 	logger.Info("Upgrading node %s to image: %s", nodeIP, factoryImage)
 
 	// Perform upgrade with spinner
-	err = spinCommandFn(fmt.Sprintf("Upgrading node %s", nodeIP),
+	err := spinCommandFn(fmt.Sprintf("Upgrading node %s", nodeIP),
 		"talosctl", "--nodes", nodeIP, "upgrade",
 		"--image", factoryImage,
 		"--reboot-mode", mode,
@@ -464,20 +679,25 @@ func upgradeNode(nodeIP, mode string) error {
 }
 
 func newUpgradeK8sCommand() *cobra.Command {
+	var snapshot upgradeSnapshotOptions
 	cmd := &cobra.Command{
-		Use:     "upgrade-k8s",
-		Short:   "Legacy direct Talos Kubernetes upgrade path",
-		Long:    `Runs 'talosctl upgrade-k8s' directly against the cluster. This bypasses the GitOps-driven kubeadm upgrade flow used by the current Flatcar/kubeadm path, so treat it as a legacy Talos-oriented escape hatch rather than the primary upgrade workflow.`,
+		Use:   "upgrade-k8s",
+		Short: "Legacy direct Talos Kubernetes upgrade path",
+		Long: `Runs 'talosctl upgrade-k8s' directly against the cluster. This bypasses the GitOps-driven kubeadm upgrade flow used by the current Flatcar/kubeadm path, so treat it as a legacy Talos-oriented escape hatch rather than the primary upgrade workflow.
+
+Before the upgrade an etcd snapshot is saved to state.etcd_backup.dir, as for
+upgrade-node; --skip-snapshot upgrades without one.`,
 		Example: `  homeops-cli talos upgrade-k8s`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return upgradeK8s()
+			return upgradeK8s(cmd.Context(), snapshot)
 		},
 	}
+	addUpgradeSnapshotFlags(cmd, &snapshot)
 
 	return cmd
 }
 
-func upgradeK8s() error {
+func upgradeK8s(ctx context.Context, snapshot upgradeSnapshotOptions) error {
 	logger := common.NewColorLogger()
 
 	// Get a random node
@@ -486,12 +706,18 @@ func upgradeK8s() error {
 		return err
 	}
 
-	versionConfig := versionconfig.GetVersions(common.GetWorkingDirectory())
+	versionConfig, err := repoVersions()
+	if err != nil {
+		return err
+	}
 	k8sVersion := vmlifecycle.GetEnvOrDefault("KUBERNETES_VERSION", versionConfig.KubernetesVersion)
 	if k8sVersion == "" {
 		return fmt.Errorf("KUBERNETES_VERSION environment variable not set")
 	}
 
+	if err := snapshotBeforeUpgrade(ctx, logger, snapshot); err != nil {
+		return err
+	}
 	logger.Info("Upgrading Kubernetes to version %s via node %s", k8sVersion, node)
 
 	// Perform Kubernetes upgrade with spinner
@@ -575,7 +801,7 @@ func rebootNode(nodeIP, mode string, force bool) error {
 
 	output, err := runTalosctlCombinedOutput("--nodes", nodeIP, "reboot", "--mode", mode)
 	if err != nil {
-		return fmt.Errorf("reboot failed: %w\n%s", err, output)
+		return fmt.Errorf("reboot failed: %w\n%s", err, common.RedactCommandOutput(string(output)))
 	}
 
 	logger.Success("Node %s reboot initiated", nodeIP)
@@ -624,7 +850,7 @@ func shutdownCluster() error {
 
 	output, err := runTalosctlCombinedOutput("shutdown", "--nodes", strings.Join(nodes, ","), "--force")
 	if err != nil {
-		return fmt.Errorf("shutdown failed: %w\n%s", err, output)
+		return fmt.Errorf("shutdown failed: %w\n%s", err, common.RedactCommandOutput(string(output)))
 	}
 
 	logger.Success("Cluster shutdown initiated")
@@ -695,7 +921,7 @@ func resetNode(nodeIP string, force bool) error {
 
 	output, err := runTalosctlCombinedOutput("reset", "--nodes", nodeIP, "--graceful=false")
 	if err != nil {
-		return fmt.Errorf("reset failed: %w\n%s", err, output)
+		return fmt.Errorf("reset failed: %w\n%s", err, common.RedactCommandOutput(string(output)))
 	}
 
 	logger.Success("Node %s reset initiated", nodeIP)
@@ -743,7 +969,7 @@ func resetCluster() error {
 
 	output, err := runTalosctlCombinedOutput("reset", "--nodes", strings.Join(nodes, ","), "--graceful=false")
 	if err != nil {
-		return fmt.Errorf("reset failed: %w\n%s", err, output)
+		return fmt.Errorf("reset failed: %w\n%s", err, common.RedactCommandOutput(string(output)))
 	}
 
 	logger.Success("Cluster reset initiated")
@@ -793,12 +1019,15 @@ func generateKubeconfig() error {
 		return err
 	}
 
-	rootDir := workingDirectoryFn()
+	rootDir, err := repoRootFn()
+	if err != nil {
+		return err
+	}
 	logger.Info("Generating kubeconfig from node %s", node)
 
 	output, err := generateKubeconfigFn(node, rootDir)
 	if err != nil {
-		return fmt.Errorf("failed to generate kubeconfig: %w\n%s", err, output)
+		return fmt.Errorf("failed to generate kubeconfig: %w\n%s", err, common.RedactCommandOutput(string(output)))
 	}
 
 	logger.Success("Kubeconfig generated successfully")
@@ -806,7 +1035,10 @@ func generateKubeconfig() error {
 }
 
 func pushKubeconfigToStore(logger *common.ColorLogger) error {
-	rootDir := workingDirectoryFn()
+	rootDir, err := repoRootFn()
+	if err != nil {
+		return err
+	}
 	kubeconfigPath := filepath.Join(rootDir, "kubeconfig")
 
 	store := state.NewKubeconfigStore(versionconfig.Get().State.Kubeconfig)
@@ -820,7 +1052,10 @@ func pushKubeconfigToStore(logger *common.ColorLogger) error {
 }
 
 func pullKubeconfigFromStore(logger *common.ColorLogger) error {
-	rootDir := workingDirectoryFn()
+	rootDir, err := repoRootFn()
+	if err != nil {
+		return err
+	}
 	kubeconfigPath := filepath.Join(rootDir, "kubeconfig")
 
 	logger.Info("Pulling kubeconfig from %s...", state.NewKubeconfigStore(versionconfig.Get().State.Kubeconfig).Describe())
@@ -832,7 +1067,7 @@ func pullKubeconfigFromStore(logger *common.ColorLogger) error {
 	return nil
 }
 
-func promptDeployVMOptions(name, provider *string, memory, vcpus, diskSize, openebsSize *int, generateISO, dryRun *bool, datastore, network *string, nodeCount, concurrent, startIndex *int) error {
+func promptDeployVMOptions(name, provider *string, memory, vcpus, diskSize, openebsSize *int, generateISO, dryRun *bool, datastore, network *string, disks *vsphereDiskOptions, nodeCount, concurrent, startIndex *int) error {
 	logger := common.NewColorLogger()
 
 	// Step 1: Select deployment pattern
@@ -912,6 +1147,12 @@ func promptDeployVMOptions(name, provider *string, memory, vcpus, diskSize, open
 		} else {
 			*network = vsphereVMDefaults.NetworkBridge
 		}
+
+		if isCustom {
+			if err := promptVSphereDiskOptions(*datastore, disks); err != nil {
+				return err
+			}
+		}
 	}
 
 	// Step 6: Ask about ISO generation
@@ -1078,6 +1319,38 @@ func logDefaultDeployResources(logger *common.ColorLogger, provider string) {
 	logger.Info("Default resources: 16 vCPUs, 48GB RAM, 250GB boot, 1TB OpenEBS")
 }
 
+// promptVSphereDiskOptions asks for the provisioning mode and per-disk
+// datastores in custom mode; blank datastores fall back to datastore.
+func promptVSphereDiskOptions(datastore string, disks *vsphereDiskOptions) error {
+	provisioningOptions := []string{
+		"thin - Allocate space on write (default)",
+		"thick - Reserve space up front, zero on first write",
+		"eagerZeroedThick - Reserve and zero space at creation",
+	}
+	selected, err := chooseOptionFn("Select disk provisioning:", provisioningOptions)
+	if err != nil {
+		return err
+	}
+	provisioning, err := vsphere.ParseDiskProvisioning(strings.SplitN(selected, " ", 2)[0])
+	if err != nil {
+		return err
+	}
+	disks.Provisioning = provisioning
+
+	bootInput, err := inputPromptFn("Enter boot disk datastore (blank uses the datastore above):", datastore)
+	if err != nil {
+		return err
+	}
+	disks.BootDatastore = strings.TrimSpace(bootInput)
+
+	openebsInput, err := inputPromptFn("Enter OpenEBS disk datastore (blank uses the datastore above):", datastore)
+	if err != nil {
+		return err
+	}
+	disks.OpenEBSDatastore = strings.TrimSpace(openebsInput)
+	return nil
+}
+
 func deployVMProviderOptions() []string {
 	return []string{
 		"Proxmox - Deploy to Proxmox VE (default)",
@@ -1094,17 +1367,46 @@ func newDeployVMCommand() *cobra.Command {
 		diskSize       int
 		openebsSize    int
 		macAddress     string
+		isoPath        string
 		pool           string
 		skipZVolCreate bool
 		generateISO    bool
+		serialLog      bool
+		noConfigISO    bool
+		keepOnFailure  bool
+		update         bool
+		start          bool
+		autostart      bool
+		specPath       string
+		dataDisks      []string
+		sparse         bool
+		volBlockSize   string
+		compression    string
+		waitForIP      bool
+		ipWait         trueNASIPWait
+		cpu            truenas.CPUPlacement
+		skipIPCheck    bool
+		expectExisting bool
+		replaceNode    string
 		provider       string
 		dryRun         bool
+		verify         bool
 		// vSphere specific flags
-		datastore  string
-		network    string
-		concurrent int
-		nodeCount  int
-		startIndex int
+		datastore        string
+		network          string
+		diskProvisioning string
+		diskController   string
+		vsphereDisks     vsphereDiskOptions
+		placement        vspherePlacementOptions
+		firmware         string
+		extraConfig      []string
+		vmOptions        vsphereVMOptions
+		concurrent       int
+		nodeCount        int
+		startIndex       int
+		nodeIPs          []string
+		skipTemplates    bool
+		nodeMapPath      string
 	)
 
 	cmd := &cobra.Command{
@@ -1113,27 +1415,191 @@ func newDeployVMCommand() *cobra.Command {
 		Example: `  # Deploy a Talos VM on Proxmox (default provider)
   homeops-cli talos deploy-vm --name k8s-0
 
-  # Deploy on TrueNAS with a generated custom ISO
-  homeops-cli talos deploy-vm --provider truenas --name k8s-0 --generate-iso`,
+  # Deploy on TrueNAS with a generated custom ISO (no dashes on TrueNAS)
+  homeops-cli talos deploy-vm --provider truenas --name k8s0 --generate-iso
+
+  # Deploy on TrueNAS with the serial console logged to the NAS
+  homeops-cli talos deploy-vm --provider truenas --name k8s0 --serial-log
+
+  # Deploy on TrueNAS pinned to NUMA node 0 (see 'vm truenas host-topology')
+  homeops-cli talos deploy-vm --provider truenas --name k8s0 --vcpus 8 --cpuset 0-7 --nodeset 0 --pin-vcpus`,
 		Long: `Deploy a new Talos VM on TrueNAS, vSphere/ESXi, or Proxmox VE.
 
 Defaults to hypervisors.default from homeops.yaml (portable default: Proxmox VE). Use --provider truenas for TrueNAS or --provider vsphere/esxi for vSphere/ESXi.
 
 For TrueNAS: Uses proper ZVol naming convention and SPICE console.
 For vSphere/ESXi: Deploys to specified datastore with enhanced VM configuration.
+--disk-provisioning picks thin (default), thick, or eagerZeroedThick VMDKs for
+every disk, or per disk as boot=thin,openebs=eagerZeroedThick, and
+--boot-datastore / --openebs-datastore place each disk on its own datastore
+(both default to --datastore; k8s-N nodes default to their node preset). Every
+datastore is checked for existence and, for thick modes, free space before any
+VM is created. --disk-controller attaches the disks to NVMe (default), PVSCSI,
+or LSI Logic controllers, one per disk; node templates install Talos to
+/dev/nvme0n1 or /dev/sda to match. k8s-N nodes always use NVMe.
+--firmware (efi default, or bios), --secure-boot, --cpu-hot-add,
+--memory-hot-add, and --extra-config key=value (repeatable) set the VM options
+of generic vSphere VMs; an --extra-config key the CLI also sets, such as
+disk.EnableUUID, takes the given value. k8s-N nodes keep their production VMX.
 For Proxmox: Uses predefined node configs (k8s-0, k8s-1, k8s-2) with UEFI, NUMA, and disk passthrough.
 
 Use --generate-iso to create a custom ISO using the schematic.yaml configuration.
 
+--iso-path (TrueNAS) boots another ISO instead of the prepared one: a file
+on the NAS, checked to exist before the VM is created, or an http(s) URL
+that is downloaded into hypervisors.truenas.iso_dir first. A Talos Image
+Factory URL is stored as metal-amd64-<schematic>-<version>.iso, so it does
+not replace the prepared ISO. A --spec's iso is used the same way.
+
+Use --serial-log (TrueNAS, SCALE 24.04+) to attach an extra serial port that
+logs to /mnt/<pool>/vm-logs/<name>.log on the NAS; read it back with
+'homeops-cli vm truenas console-log --name <name>'. The guest sees the port
+as ttyS1, so add console=ttyS1 to the schematic's kernel args to capture
+kernel output there.
+
+On TrueNAS, a VM named after a node in cluster.nodes gets its machine config
+rendered (as 'talos apply-node' would) into a small metal-iso config ISO,
+uploaded next to the boot ISO and attached as a second CD-ROM. The TrueNAS
+ISO from prepare-iso or --generate-iso boots with talos.config=metal-iso, so
+the node configures itself on first boot and no apply-node step is needed.
+An ISO prepared before this ignores the volume and waits in maintenance mode.
+'vm delete' removes the config ISO with the VM. --no-config-iso skips it.
+
+If a TrueNAS deploy fails part way (a device the middleware rejects), the VM,
+its devices, and the ZVols this run created are deleted again in reverse
+order and each removal is logged, so a retry does not stop at "VM already
+exists". --keep-on-failure leaves them in place for inspection.
+
+--update (TrueNAS) re-runs a deploy against a VM that already exists: memory,
+vCPUs, CPU placement, devices (ISO, NIC bridge, disks, display), and ZVol
+sizes are compared with the live VM and only the differences are applied, so
+an unchanged re-run is a no-op. ZVols can only grow; asking for a smaller
+one fails before anything is changed. The IP preflight treats the node's
+address answering as expected.
+
+--verify (TrueNAS) changes nothing: it compares the existing VM's fields,
+devices, and ZVol sizes with what the same flags would deploy, prints each
+difference, and exits non-zero if there is any.
+
+--spec vm.yaml (TrueNAS) reads the VM from a file: name, memory_mb, vcpus,
+pool, iso, any number of disks (zvol, size_gb, sparse, blocksize, order),
+NICs (bridge, mac, model, order), and display (enabled, bind, resolution).
+Flags given on the command line override the spec: --name, --memory,
+--vcpus, --pool, and --mac-address (first NIC), --disk-size and
+--openebs-size (first and second disk).
+
+ZVols are created sparse (no refreservation); --sparse=false reserves their
+full size. --volblocksize and --compression (TrueNAS) take one value for
+every disk, or disk=value pairs such as boot=16K,openebs=64K. A spec disk's
+own blocksize or compression wins.
+
+--data-disk name=ceph,size=2000 (TrueNAS, repeatable) replaces the default
+OpenEBS disk with the data disks given, attached after the boot disk in flag
+order (device orders 1004, then 1010+index). zvol= (default
+<pool>/VM/<name>-<disk>) and serial= are optional. Adding --openebs-size
+keeps the OpenEBS disk as the first data disk. A --spec that lists disks
+cannot be combined with --data-disk.
+
+A new TrueNAS VM is started once its devices exist and the deploy waits up
+to 60s for it to report RUNNING, naming its state if it does not;
+--start=false leaves it powered off. --autostart sets it to boot with the
+NAS. --update leaves the power state of an existing VM alone.
+
+--wait-for-ip (TrueNAS) then waits up to --wait-for-ip-timeout (starting the
+VM first under --start=false) for its first NIC's MAC to get an address, and
+prints "Node IP: <ip>" as the last line of output for scripts. The default
+--ip-discovery arp pings cluster.dhcp_pool (or the VM bridge's subnet, /22 or
+smaller) from the NAS over SSH and reads its neighbor table; dhcp searches
+the leases served at hypervisors.truenas.ip_discovery.leases_url.
+
+Use --cpuset/--nodeset (TrueNAS) to keep a VM's vCPUs and memory on given host
+CPUs and NUMA nodes, --pin-vcpus to pin each vCPU to one CPU of the cpuset
+(exactly one CPU per vCPU), and --cpu-mode/--cpu-model to replace the default
+HOST-PASSTHROUGH. 'homeops-cli vm truenas host-topology' prints the NAS's NUMA
+layout and the CPUs other VMs are already pinned to; a cpuset overlapping
+another homeops-managed VM is warned about but allowed.
+
+VM names are checked against each provider's naming rules before anything is
+created: TrueNAS allows only letters, digits, and underscores; Proxmox requires
+a DNS name (max 63 characters); vSphere allows letters, digits, dots, dashes,
+and underscores (max 80 characters). hypervisors.<provider>.naming in
+homeops.yaml can additionally require a prefix, a pattern, or a shorter limit.
+Batch deployments validate every derived name (base-index) up front.
+
+When a VM name matches a node in cluster.nodes (or cluster.test_node), its
+planned IP goes through the same checks as 'talos check-ip' first: a FAIL
+(address already answering, DNS pointing elsewhere, inside cluster.dhcp_pool)
+aborts the run, warnings are logged. --expect-existing keeps the checks but
+treats a responding address as the node being redeployed; --skip-ip-check
+bypasses them entirely.
+
+A vSphere deploy of several VMs (--node-count > 1, or any with --node-ips)
+then scaffolds talos/nodes/<ip>.yaml for each new VM as 'talos add-node'
+does: hostname, MAC, the NVMe install disk, and the installer image of the
+schematic prepare-iso last uploaded. Each VM's IP comes from --node-ips (in
+VM order), then its cluster.nodes entry, then the address VMware Tools
+reports (waiting up to 5m). An existing template is kept. The VM, IP, and
+template of each node are printed; --skip-templates leaves this step out.
+
+--node-map <file> (TrueNAS and vSphere) fixes each VM's MAC, so a DHCP
+server pinning leases by MAC gives a redeployed node the same address. The
+YAML file maps VM names to a mac and an optional ip and datastore; every VM
+the deploy creates needs an entry, with valid, unique MACs (vSphere:
+00:50:56:00:00:00-00:50:56:3f:ff:ff). On vSphere the IPs stand in for
+--node-ips; on TrueNAS the datastore is the pool unless --pool is given. The
+mapping is printed at the end.
+
+--host, --resource-pool, and --folder (vSphere) place generic VMs on one ESXi
+host, in a resource pool, or in a folder under the datacenter's VM folder;
+by default the datacenter's default resource pool picks. --anti-affinity
+spreads a batch one VM per host, round-robin over the connected hosts outside
+maintenance mode (of --resource-pool's cluster, else the datacenter), and
+under vCenter also adds the VMs to a DRS anti-affinity rule on their cluster,
+homeops-<name>-anti-affinity, so DRS keeps them apart. With a single host
+(a standalone ESXi) it warns and deploys as before. k8s-* VMs are deployed
+over SSH with host-specific presets and take none of these flags.
+
+--replace-node <ip> rebuilds a dead node in place of a new one. It refuses
+unless the IP belongs to a node in cluster.nodes that no longer answers the
+Talos API (or ping), its Node object is not Ready and was registered from
+that IP, and its etcd member, if any, matches both name and peer address.
+After confirmation it removes the etcd member (through another control plane)
+and the Node object, deploys the VM under the node's name with its previous
+MAC (--mac-address, then cluster.nodes vm.mac, then the local ARP cache), and
+applies talos/nodes/<ip>.yaml in maintenance mode (on TrueNAS the config ISO
+does this instead). --dry-run prints the verified plan.
+
 If no flags are provided, presents an interactive menu with default and custom patterns.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			logger := common.NewColorLogger()
 			usedInteractive := false
+			if err := checkRepoVersions(cmd.Context(), versioncheck.Talos); err != nil {
+				return err
+			}
+
+			if replaceNode != "" {
+				if cmd.Flags().Changed("node-count") && nodeCount != 1 {
+					return fmt.Errorf("--replace-node deploys exactly one VM; drop --node-count")
+				}
+				nodeName, err := replaceNodeName(versionconfig.Get(), replaceNode, name)
+				if err != nil {
+					return err
+				}
+				name = nodeName
+			}
+
+			var spec *truenas.VMSpec
+			if specPath != "" {
+				var err error
+				if spec, err = truenas.LoadVMSpec(specPath); err != nil {
+					return err
+				}
+			}
 
 			// Check if running in interactive mode (no flags set)
-			if name == "" && !cmd.Flags().Changed("provider") && !cmd.Flags().Changed("dry-run") {
+			if name == "" && spec == nil && !cmd.Flags().Changed("provider") && !cmd.Flags().Changed("dry-run") {
 				// Show interactive prompts
-				err := promptDeployVMOptions(&name, &provider, &memory, &vcpus, &diskSize, &openebsSize, &generateISO, &dryRun, &datastore, &network, &nodeCount, &concurrent, &startIndex)
+				err := promptDeployVMOptions(&name, &provider, &memory, &vcpus, &diskSize, &openebsSize, &generateISO, &dryRun, &datastore, &network, &vsphereDisks, &nodeCount, &concurrent, &startIndex)
 				if err != nil {
 					if ui.IsCancellation(err) {
 						return nil
@@ -1151,25 +1617,260 @@ If no flags are provided, presents an interactive menu with default and custom p
 				return err
 			}
 			provider = normalizedProvider
+			if spec != nil {
+				if provider != "truenas" {
+					return fmt.Errorf("--spec is only supported with --provider truenas")
+				}
+				if err := applyVMSpecFlags(cmd, spec, &name, &pool, &memory, &vcpus, diskSize, openebsSize, macAddress); err != nil {
+					return fmt.Errorf("invalid VM spec %s: %w", specPath, err)
+				}
+			}
 
 			// Validate required name
 			if name == "" {
 				return fmt.Errorf("VM name is required (use --name flag or interactive mode)")
 			}
+			if err := validateDeploymentVMNames(provider, name, nodeCount, startIndex); err != nil {
+				return err
+			}
 
 			// Show dry-run mode indicator
 			if dryRun {
 				logger.Info("🔍 DRY-RUN MODE - No changes will be made")
 			}
 
+			if serialLog && provider != "truenas" {
+				return fmt.Errorf("--serial-log is only supported with --provider truenas")
+			}
+			if noConfigISO && provider != "truenas" {
+				return fmt.Errorf("--no-config-iso is only supported with --provider truenas")
+			}
+			if keepOnFailure && provider != "truenas" {
+				return fmt.Errorf("--keep-on-failure is only supported with --provider truenas")
+			}
+			if update && provider != "truenas" {
+				return fmt.Errorf("--update is only supported with --provider truenas")
+			}
+			if isoPath == "" && spec != nil && !generateISO {
+				isoPath = spec.ISO
+			}
+			if isoPath != "" {
+				if provider != "truenas" {
+					return fmt.Errorf("--iso-path is only supported with --provider truenas")
+				}
+				if generateISO {
+					return fmt.Errorf("--iso-path and --generate-iso cannot be combined")
+				}
+			}
+			if (cmd.Flags().Changed("start") || autostart) && provider != "truenas" {
+				return fmt.Errorf("--start and --autostart are only supported with --provider truenas")
+			}
+			if len(dataDisks) > 0 {
+				if provider != "truenas" {
+					return fmt.Errorf("--data-disk is only supported with --provider truenas")
+				}
+				var err error
+				if spec, err = applyDataDiskFlags(cmd, spec, name, pool, diskSize, openebsSize, dataDisks); err != nil {
+					return err
+				}
+			}
+			zvol := truenas.ZVolOptions{Thick: !sparse}
+			if cmd.Flags().Changed("sparse") || volBlockSize != "" || compression != "" {
+				if provider != "truenas" {
+					return fmt.Errorf("--sparse, --volblocksize, and --compression are only supported with --provider truenas")
+				}
+				var err error
+				if zvol.BlockSize, err = truenas.ParseZVolOption("volblocksize", volBlockSize); err != nil {
+					return err
+				}
+				if zvol.Compression, err = truenas.ParseZVolOption("compression", compression); err != nil {
+					return err
+				}
+			}
+			if !waitForIP {
+				ipWait = trueNASIPWait{}
+			} else if provider != "truenas" {
+				return fmt.Errorf("--wait-for-ip is only supported with --provider truenas")
+			} else if ipWait.Timeout <= 0 {
+				return fmt.Errorf("--wait-for-ip-timeout must be greater than 0")
+			}
+			if cpu != (truenas.CPUPlacement{}) {
+				if provider != "truenas" {
+					return fmt.Errorf("--cpuset, --nodeset, --pin-vcpus, --cpu-mode, and --cpu-model are only supported with --provider truenas")
+				}
+				if err := cpu.Validate(vcpus); err != nil {
+					return err
+				}
+			}
+			if cmd.Flags().Changed("disk-provisioning") || cmd.Flags().Changed("disk-controller") || cmd.Flags().Changed("boot-datastore") || cmd.Flags().Changed("openebs-datastore") {
+				if provider != "vsphere" {
+					return fmt.Errorf("--disk-provisioning, --disk-controller, --boot-datastore, and --openebs-datastore are only supported with --provider vsphere")
+				}
+				modes, err := vsphere.ParseDiskProvisioningOption(diskProvisioning)
+				if err != nil {
+					return err
+				}
+				vsphereDisks.Provisioning, vsphereDisks.BootProvisioning, vsphereDisks.OpenEBSProvisioning = modes[""], modes["boot"], modes["openebs"]
+				if vsphereDisks.Controller, err = vsphere.ParseDiskController(diskController); err != nil {
+					return err
+				}
+				if vsphereDisks.Controller != vsphere.DiskControllerNVMe && strings.HasPrefix(name, "k8s") {
+					return fmt.Errorf("--disk-controller %s does not apply to k8s-* VMs, whose VMX attaches both disks to NVMe controllers", vsphereDisks.Controller)
+				}
+			}
+			if (len(nodeIPs) > 0 || skipTemplates) && provider != "vsphere" {
+				return fmt.Errorf("--node-ips and --skip-templates are only supported with --provider vsphere")
+			}
+			if len(nodeIPs) > 0 && skipTemplates {
+				return fmt.Errorf("--node-ips only sets the node templates' IPs; drop --skip-templates")
+			}
+			if placement.set() {
+				if provider != "vsphere" {
+					return fmt.Errorf("--host, --resource-pool, --folder, and --anti-affinity are only supported with --provider vsphere")
+				}
+				if strings.HasPrefix(name, "k8s") {
+					return fmt.Errorf("--host, --resource-pool, --folder, and --anti-affinity do not apply to k8s-* VMs, which deploy over SSH with host-specific presets")
+				}
+				if err := placement.validate(nodeCount); err != nil {
+					return err
+				}
+			}
+			if cmd.Flags().Changed("firmware") || cmd.Flags().Changed("secure-boot") || cmd.Flags().Changed("cpu-hot-add") || cmd.Flags().Changed("memory-hot-add") || cmd.Flags().Changed("extra-config") {
+				if provider != "vsphere" {
+					return fmt.Errorf("--firmware, --secure-boot, --cpu-hot-add, --memory-hot-add, and --extra-config are only supported with --provider vsphere")
+				}
+				if strings.HasPrefix(name, "k8s") {
+					return fmt.Errorf("--firmware, --secure-boot, --cpu-hot-add, --memory-hot-add, and --extra-config do not apply to k8s-* VMs, whose VMX matches the production nodes")
+				}
+				var err error
+				if vmOptions.Firmware, err = vsphere.ParseFirmware(firmware); err != nil {
+					return err
+				}
+				if vmOptions.SecureBoot && vmOptions.Firmware != vsphere.FirmwareEFI {
+					return fmt.Errorf("--secure-boot needs --firmware efi")
+				}
+				if vmOptions.ExtraConfig, err = vsphere.ParseExtraConfig(extraConfig); err != nil {
+					return err
+				}
+			}
+			var nodes nodeMap
+			if nodeMapPath != "" {
+				switch {
+				case provider != "truenas" && provider != "vsphere":
+					return fmt.Errorf("--node-map is only supported with --provider truenas or vsphere")
+				case replaceNode != "":
+					return fmt.Errorf("--node-map cannot be combined with --replace-node, which reuses the dead node's MAC")
+				case cmd.Flags().Changed("mac-address"):
+					return fmt.Errorf("--node-map sets each VM's MAC; drop --mac-address")
+				case len(nodeIPs) > 0:
+					return fmt.Errorf("--node-map sets each VM's IP; drop --node-ips")
+				case provider == "vsphere" && strings.HasPrefix(name, "k8s"):
+					return fmt.Errorf("--node-map does not apply to k8s-* VMs, which take their MACs from their node presets")
+				}
+				if nodes, err = loadNodeMap(nodeMapPath); err != nil {
+					return err
+				}
+				vmNames, err := deploymentVMNames(provider, name, nodeCount, startIndex)
+				if err != nil {
+					return err
+				}
+				if err := nodes.check(vmNames); err != nil {
+					return err
+				}
+				if provider == "vsphere" {
+					if err := nodes.validateVSphereMACs(vmNames); err != nil {
+						return err
+					}
+					nodeIPs = nodes.ips(vmNames)
+				} else {
+					entry := nodes[name]
+					macAddress = entry.MAC
+					if spec != nil && len(spec.NICs) > 0 {
+						spec.NICs[0].MAC = entry.MAC
+					}
+					if entry.Datastore != "" && !cmd.Flags().Changed("pool") {
+						pool = entry.Datastore
+					}
+				}
+			}
+			if replaceNode != "" {
+				// The dead node's address is expected to be stale in ARP and
+				// DNS; verifyReplaceNode does its own reachability checks.
+				deploy := func(mac string) error {
+					switch provider {
+					case "truenas":
+						return deployVMWithPattern(cmd.Context(), name, pool, memory, vcpus, diskSize, openebsSize, mac, isoPath, skipZVolCreate, generateISO, serialLog, !noConfigISO, keepOnFailure, update, start, autostart, cpu, zvol, spec, ipWait)
+					case "proxmox":
+						return deployVMOnProxmoxDryRun(name, memory, vcpus, diskSize, openebsSize, generateISO, concurrent, 1, startIndex, false)
+					default:
+						return deployVMOnVSphere(name, memory, vcpus, diskSize, openebsSize, mac, datastore, network, vsphereDisks, placement, nil, vmOptions, generateISO, concurrent, 1, startIndex)
+					}
+				}
+				return runReplaceNode(cmd.Context(), replaceNodeOptions{
+					IP: replaceNode, Name: name, Provider: provider, MAC: macAddress,
+					ConfigISO: provider == "truenas" && !noConfigISO, DryRun: dryRun,
+				}, cmd.OutOrStdout(), deploy)
+			}
+			if verify {
+				switch {
+				case provider != "truenas":
+					return fmt.Errorf("--verify is only supported with --provider truenas")
+				case dryRun || update || generateISO:
+					return fmt.Errorf("--verify cannot be combined with --dry-run, --update, or --generate-iso")
+				}
+				return deployTrueNASVMPattern(cmd.Context(), name, pool, memory, vcpus, diskSize, openebsSize, macAddress, isoPath, skipZVolCreate, false, serialLog, !noConfigISO, keepOnFailure, false, start, autostart, cpu, zvol, spec, trueNASIPWait{}, &truenas.DryRunPlan{}, true)
+			}
+			if !skipIPCheck {
+				vmNames, err := deploymentVMNames(provider, name, nodeCount, startIndex)
+				if err != nil {
+					return err
+				}
+				// An updated VM is expected to answer on its IP already.
+				if err := deployVMIPPreflightFn(cmd.Context(), logger, vmNames, expectExisting || update); err != nil {
+					return err
+				}
+			}
+
 			// Deploy to appropriate provider
 			switch provider {
 			case "truenas":
-				return deployVMWithPatternDryRun(name, pool, memory, vcpus, diskSize, openebsSize, macAddress, skipZVolCreate, generateISO, dryRun)
+				// "Node IP: <ip>" from --wait-for-ip stays the last line.
+				if nodes != nil && ipWait.Timeout > 0 {
+					nodes.print(deployVMStdout, []string{name})
+				}
+				if err := deployVMWithPatternDryRun(cmd.Context(), name, pool, memory, vcpus, diskSize, openebsSize, macAddress, isoPath, skipZVolCreate, generateISO, serialLog, !noConfigISO, keepOnFailure, update, start, autostart, cpu, zvol, spec, ipWait, dryRun); err != nil {
+					return err
+				}
+				if nodes != nil && ipWait.Timeout <= 0 {
+					nodes.print(deployVMStdout, []string{name})
+				}
+				return nil
 			case "proxmox":
 				return deployVMOnProxmoxDryRun(name, memory, vcpus, diskSize, openebsSize, generateISO, concurrent, nodeCount, startIndex, dryRun)
 			default:
-				return deployVMOnVSphereDryRun(name, memory, vcpus, diskSize, openebsSize, macAddress, datastore, network, generateISO, concurrent, nodeCount, startIndex, dryRun)
+				vmNames, err := deploymentVMNames(provider, name, nodeCount, startIndex)
+				if err != nil {
+					return err
+				}
+				if err := validateNodeIPs(nodeIPs, vmNames); err != nil {
+					return err
+				}
+				writeTemplates := !skipTemplates && (len(vmNames) > 1 || len(nodeIPs) > 0)
+				if err := deployVMOnVSphereDryRun(name, memory, vcpus, diskSize, openebsSize, macAddress, datastore, network, vsphereDisks, placement, nodes, vmOptions, generateISO, concurrent, nodeCount, startIndex, dryRun); err != nil {
+					return err
+				}
+				var templatesErr error
+				switch {
+				case !writeTemplates:
+				case dryRun:
+					logger.Info("Would scaffold talos/nodes/<ip>.yaml for %s", strings.Join(vmNames, ", "))
+				default:
+					templatesErr = writeVSphereNodeTemplatesFn(deployVMStdout, vmNames, nodeIPs, vsphereDisks.Controller.TalosInstallDisk())
+				}
+				if nodes != nil {
+					nodes.print(deployVMStdout, vmNames)
+				}
+				return templatesErr
 			}
 		},
 	}
@@ -1184,16 +1885,60 @@ If no flags are provided, presents an interactive menu with default and custom p
 	cmd.Flags().StringVar(&macAddress, "mac-address", "", "MAC address (optional)")
 	cmd.Flags().BoolVar(&skipZVolCreate, "skip-zvol-create", false, "Skip ZVol creation (TrueNAS only)")
 	cmd.Flags().BoolVar(&generateISO, "generate-iso", false, "Generate custom ISO using schematic.yaml")
+	cmd.Flags().StringVar(&isoPath, "iso-path", "", "Boot ISO: a file on the NAS, or an http(s) URL downloaded into hypervisors.truenas.iso_dir first (TrueNAS only; default: the prepared ISO)")
+	cmd.Flags().BoolVar(&serialLog, "serial-log", false, "Attach a serial port logging to /mnt/<pool>/vm-logs/<name>.log (TrueNAS SCALE 24.04+ only)")
+	cmd.Flags().BoolVar(&noConfigISO, "no-config-iso", false, "Do not attach the node's machine config as a metal-iso CD-ROM; apply it with 'talos apply-node' instead (TrueNAS only)")
+	cmd.Flags().BoolVar(&keepOnFailure, "keep-on-failure", false, "Leave a partially created VM and its ZVols in place when the deploy fails instead of rolling them back (TrueNAS only)")
+	cmd.Flags().StringVar(&specPath, "spec", "", "Read the VM (disks, NICs, display, ISO) from a YAML spec file; flags override it (TrueNAS only)")
+	cmd.Flags().StringArrayVar(&dataDisks, "data-disk", nil, "Attach a data disk after the boot disk, name=<name>,size=<GB>[,zvol=<dataset>][,serial=<serial>]; repeatable, replaces the OpenEBS disk unless --openebs-size is given (TrueNAS only)")
+	cmd.Flags().BoolVar(&sparse, "sparse", true, "Create thin-provisioned (sparse) ZVols; --sparse=false reserves their full size (TrueNAS only)")
+	cmd.Flags().StringVar(&volBlockSize, "volblocksize", "", "ZVol volblocksize for every disk (16K) or per disk (boot=16K,openebs=64K); default: the pool's (TrueNAS only)")
+	cmd.Flags().StringVar(&compression, "compression", "", "ZVol compression for every disk (LZ4) or per disk (boot=LZ4,openebs=ZSTD); default: inherited (TrueNAS only)")
+	cmd.Flags().BoolVar(&start, "start", true, "Start the VM once it is created and wait up to 60s for it to be RUNNING (TrueNAS only)")
+	cmd.Flags().BoolVar(&autostart, "autostart", false, "Set the VM to start when the NAS boots (TrueNAS only)")
+	cmd.Flags().BoolVar(&waitForIP, "wait-for-ip", false, "Start the VM after deploying it and wait for its NIC's DHCP address, printed last as 'Node IP: <ip>' (TrueNAS only)")
+	cmd.Flags().DurationVar(&ipWait.Timeout, "wait-for-ip-timeout", 5*time.Minute, "How long --wait-for-ip waits for the address")
+	cmd.Flags().StringVar(&ipWait.Method, "ip-discovery", "", "How --wait-for-ip finds the address: arp or dhcp (default: hypervisors.truenas.ip_discovery.method from homeops.yaml, else arp)")
+	cmd.Flags().BoolVar(&update, "update", false, "If the VM already exists, update its memory, vCPUs, devices, and ZVol sizes in place instead of failing (TrueNAS only)")
+	cmd.Flags().StringVar(&cpu.CPUSet, "cpuset", "", "Host CPUs the vCPUs may run on, e.g. 0-7 (TrueNAS only)")
+	cmd.Flags().StringVar(&cpu.NodeSet, "nodeset", "", "NUMA nodes to allocate guest memory from, e.g. 0 (TrueNAS only)")
+	cmd.Flags().BoolVar(&cpu.PinVCPUs, "pin-vcpus", false, "Pin each vCPU to one CPU of --cpuset (TrueNAS only)")
+	cmd.Flags().StringVar(&cpu.CPUMode, "cpu-mode", "", "CPU mode: HOST-PASSTHROUGH (default), HOST-MODEL, or CUSTOM (TrueNAS only)")
+	cmd.Flags().StringVar(&cpu.CPUModel, "cpu-model", "", "CPU model for --cpu-mode CUSTOM, from vm.cpu_model_choices (TrueNAS only)")
+	_ = cmd.RegisterFlagCompletionFunc("cpu-mode", completion.ValidCPUModes)
+	_ = cmd.RegisterFlagCompletionFunc("cpu-model", completion.ValidTrueNASCPUModels)
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Perform a dry run without creating the VM")
+	cmdutil.AddTrueNASTLSFlags(cmd.Flags())
+	cmd.Flags().BoolVar(&verify, "verify", false, "Compare the existing VM with the layout these flags would deploy, print every difference, and fail if there is any (TrueNAS only)")
+	cmd.Flags().BoolVar(&skipIPCheck, "skip-ip-check", false, "Skip the check-ip preflight for VM names listed in cluster.nodes")
+	cmd.Flags().BoolVar(&expectExisting, "expect-existing", false, "In the check-ip preflight, expect the planned IPs to answer (the nodes being redeployed)")
+	cmd.Flags().StringVar(&replaceNode, "replace-node", "", "Replace the dead node at this IP, reusing its name, IP, MAC, and machine config")
+	_ = cmd.RegisterFlagCompletionFunc("replace-node", completion.ValidNodeIPs)
 
 	// vSphere specific flags
 	cmd.Flags().StringVar(&datastore, "datastore", "", "Datastore name (vSphere; default: hypervisors.vsphere.vm.openebs_storage from homeops.yaml)")
 	cmd.Flags().StringVar(&network, "network", "", "Network port group name (vSphere only; default: hypervisors.vsphere.vm.network_bridge from homeops.yaml)")
+	cmd.Flags().StringVar(&diskProvisioning, "disk-provisioning", "thin", "Disk provisioning for every disk (thin) or per disk (boot=thin,openebs=eagerZeroedThick): thin, thick, or eagerZeroedThick (vSphere only)")
+	cmd.Flags().StringVar(&diskController, "disk-controller", "nvme", "Controller type the disks attach to: nvme, pvscsi, or lsilogic (vSphere only)")
+	cmd.Flags().StringVar(&vsphereDisks.BootDatastore, "boot-datastore", "", "Datastore for the boot disk and VM home (vSphere only; default: --datastore)")
+	cmd.Flags().StringVar(&vsphereDisks.OpenEBSDatastore, "openebs-datastore", "", "Datastore for the OpenEBS disk (vSphere only; default: --datastore)")
+	cmd.Flags().StringVar(&placement.Host, "host", "", "ESXi host to create the VMs on, by name or inventory path (vSphere only; default: the resource pool picks)")
+	cmd.Flags().StringVar(&placement.ResourcePool, "resource-pool", "", "Resource pool path, e.g. cluster/Resources/k8s (vSphere only; default: the datacenter's default pool)")
+	cmd.Flags().StringVar(&placement.Folder, "folder", "", "VM folder, relative to the datacenter's VM folder (vSphere only)")
+	cmd.Flags().BoolVar(&placement.AntiAffinity, "anti-affinity", false, "Spread the VMs one per host, plus a DRS anti-affinity rule under vCenter (vSphere only; needs --node-count > 1)")
+	cmd.Flags().StringVar(&firmware, "firmware", "efi", "Boot firmware: efi or bios (vSphere only)")
+	cmd.Flags().BoolVar(&vmOptions.SecureBoot, "secure-boot", false, "Enable UEFI secure boot (vSphere only; needs --firmware efi)")
+	cmd.Flags().BoolVar(&vmOptions.CPUHotAdd, "cpu-hot-add", false, "Allow adding vCPUs while the VM runs (vSphere only)")
+	cmd.Flags().BoolVar(&vmOptions.MemoryHotAdd, "memory-hot-add", false, "Allow adding memory while the VM runs (vSphere only)")
+	cmd.Flags().StringArrayVar(&extraConfig, "extra-config", nil, "Add a .vmx key=value, e.g. guestinfo.role=worker; repeatable, overrides a key the CLI sets (vSphere only)")
 	cmd.Flags().IntVar(&concurrent, "concurrency", 3, "Number of concurrent VM deployments (Proxmox and vSphere)")
 	cmd.Flags().IntVar(&concurrent, "concurrent", 3, "Number of concurrent VM deployments (deprecated: use --concurrency)")
 	_ = cmd.Flags().MarkDeprecated("concurrent", "use --concurrency")
 	cmd.Flags().IntVar(&nodeCount, "node-count", 1, "Number of VMs to deploy (Proxmox and vSphere)")
 	cmd.Flags().IntVar(&startIndex, "start-index", 0, "Starting index for generated VM names in batch deployments")
+	cmd.Flags().StringSliceVar(&nodeIPs, "node-ips", nil, "IPs of the deployed VMs, in VM order, for their node templates (vSphere only; default: cluster.nodes, then VMware Tools)")
+	cmd.Flags().StringVar(&nodeMapPath, "node-map", "", "YAML file mapping each VM name to a fixed mac, and optionally ip and datastore (TrueNAS and vSphere)")
+	cmd.Flags().BoolVar(&skipTemplates, "skip-templates", false, "Do not scaffold talos/nodes/<ip>.yaml templates for the deployed VMs (vSphere only)")
 
 	return cmd
 }
@@ -1227,6 +1972,26 @@ func applyTalosDeployVMConfigDefaults(cmd *cobra.Command, provider, pool *string
 	})
 }
 
+// validateDeploymentVMNames checks every VM name a deployment would create
+// against the provider's naming policy before anything is provisioned.
+// TrueNAS deployments are always single-VM.
+func validateDeploymentVMNames(provider, baseName string, nodeCount, startIndex int) error {
+	vmNames, err := deploymentVMNames(provider, baseName, nodeCount, startIndex)
+	if err != nil {
+		return err
+	}
+	return vmlifecycle.ValidateVMNamesFor(provider, vmNames)
+}
+
+// deploymentVMNames lists the VMs a deploy-vm run creates; TrueNAS deploys a
+// single VM and ignores --node-count.
+func deploymentVMNames(provider, baseName string, nodeCount, startIndex int) ([]string, error) {
+	if provider == "truenas" {
+		return []string{strings.TrimSpace(baseName)}, nil
+	}
+	return buildDeploymentVMNames(baseName, nodeCount, startIndex)
+}
+
 func buildDeploymentVMNames(baseName string, nodeCount, startIndex int) ([]string, error) {
 	baseName = strings.TrimSpace(baseName)
 	if baseName == "" {
@@ -1256,8 +2021,181 @@ func buildDeploymentVMNames(baseName string, nodeCount, startIndex int) ([]strin
 	return vmNames, nil
 }
 
-func buildGenericVSphereVMConfig(name string, memory, vcpus, diskSize, openebsSize int, macAddress, datastore, network, isoPath string) vsphere.VMConfig {
-	return vsphere.VMConfig{
+// vsphereDiskOptions carries --disk-provisioning, --disk-controller,
+// --boot-datastore, and --openebs-datastore. Empty datastores keep the VM's
+// default placement: --datastore for generic VMs, the node preset for k8s
+// nodes. BootProvisioning and OpenEBSProvisioning override Provisioning for
+// one disk.
+type vsphereDiskOptions struct {
+	Provisioning        vsphere.DiskProvisioning
+	BootProvisioning    vsphere.DiskProvisioning
+	OpenEBSProvisioning vsphere.DiskProvisioning
+	Controller          vsphere.DiskController
+	BootDatastore       string
+	OpenEBSDatastore    string
+}
+
+func (o vsphereDiskOptions) apply(config *vsphere.VMConfig) {
+	if o.Provisioning != "" {
+		config.DiskProvisioning = o.Provisioning
+	}
+	if o.BootProvisioning != "" {
+		config.BootProvisioning = o.BootProvisioning
+	}
+	if o.OpenEBSProvisioning != "" {
+		config.OpenEBSProvisioning = o.OpenEBSProvisioning
+	}
+	if o.Controller != "" {
+		config.DiskController = o.Controller
+	}
+	if o.BootDatastore != "" {
+		config.BootDatastore = o.BootDatastore
+	}
+	if o.OpenEBSDatastore != "" {
+		config.OpenEBSDatastore = o.OpenEBSDatastore
+	}
+}
+
+// vspherePlacementOptions carries --host, --resource-pool, --folder, and
+// --anti-affinity. Empty fields keep the datacenter's default resource pool
+// and VM folder.
+type vspherePlacementOptions struct {
+	Host         string
+	ResourcePool string
+	Folder       string
+	AntiAffinity bool
+}
+
+func (o vspherePlacementOptions) apply(config *vsphere.VMConfig) {
+	config.Host = o.Host
+	config.ResourcePool = o.ResourcePool
+	config.Folder = o.Folder
+}
+
+func (o vspherePlacementOptions) set() bool {
+	return o != vspherePlacementOptions{}
+}
+
+// validate rejects placements a deploy of vmCount VMs cannot honour.
+func (o vspherePlacementOptions) validate(vmCount int) error {
+	if o.AntiAffinity && o.Host != "" {
+		return fmt.Errorf("--anti-affinity picks a host per VM; drop --host")
+	}
+	if o.AntiAffinity && vmCount < 2 {
+		return fmt.Errorf("--anti-affinity needs --node-count of 2 or more")
+	}
+	return nil
+}
+
+// dryRunLines describes the placement for the deploy summary.
+func (o vspherePlacementOptions) dryRunLines() []string {
+	var lines []string
+	if o.Host != "" {
+		lines = append(lines, fmt.Sprintf("Host: %s", o.Host))
+	}
+	if o.ResourcePool != "" {
+		lines = append(lines, fmt.Sprintf("Resource Pool: %s", o.ResourcePool))
+	}
+	if o.Folder != "" {
+		lines = append(lines, fmt.Sprintf("VM Folder: %s", o.Folder))
+	}
+	if o.AntiAffinity {
+		lines = append(lines, "Anti-Affinity: one VM per host, plus a DRS rule under vCenter")
+	}
+	return lines
+}
+
+// vsphereVMOptions carries --firmware, --secure-boot, --cpu-hot-add,
+// --memory-hot-add, and --extra-config for the VMs' config spec. An empty
+// Firmware means EFI.
+type vsphereVMOptions struct {
+	Firmware     vsphere.Firmware
+	SecureBoot   bool
+	CPUHotAdd    bool
+	MemoryHotAdd bool
+	ExtraConfig  map[string]string
+}
+
+func vsphereVMOptionsFromConfig(config vsphere.VMConfig) vsphereVMOptions {
+	return vsphereVMOptions{Firmware: config.Firmware, SecureBoot: config.SecureBoot, CPUHotAdd: config.CPUHotAdd, MemoryHotAdd: config.MemoryHotAdd, ExtraConfig: config.ExtraConfig}
+}
+
+func (o vsphereVMOptions) apply(config *vsphere.VMConfig) {
+	config.Firmware = o.Firmware
+	config.SecureBoot = o.SecureBoot
+	config.CPUHotAdd = o.CPUHotAdd
+	config.MemoryHotAdd = o.MemoryHotAdd
+	config.ExtraConfig = o.ExtraConfig
+}
+
+// dryRunLines describes the VM options for the deploy summary and log.
+func (o vsphereVMOptions) dryRunLines() []string {
+	firmware := o.Firmware
+	if firmware == "" {
+		firmware = vsphere.FirmwareEFI
+	}
+	lines := []string{fmt.Sprintf("Firmware: %s", firmware)}
+	if firmware == vsphere.FirmwareEFI {
+		lines = append(lines, fmt.Sprintf("UEFI Secure Boot: %v", o.SecureBoot))
+	}
+	if o.CPUHotAdd {
+		lines = append(lines, "CPU Hot-Add: enabled")
+	}
+	if o.MemoryHotAdd {
+		lines = append(lines, "Memory Hot-Add: enabled")
+	}
+	for _, key := range slices.Sorted(maps.Keys(o.ExtraConfig)) {
+		lines = append(lines, fmt.Sprintf("Extra Config: %s = %q", key, o.ExtraConfig[key]))
+	}
+	return lines
+}
+
+// vSphereAntiAffinityRuleName names the DRS rule for VMs sharing baseName, so
+// a later batch joins the earlier one's rule.
+func vSphereAntiAffinityRuleName(baseName string) string {
+	return "homeops-" + baseName + "-anti-affinity"
+}
+
+// formatVSphereDisks renders each disk's size, datastore, and provisioning,
+// e.g. "boot 250 GB on local-nvme1 (thin), openebs 800 GB on truenas-iscsi (thin)".
+func formatVSphereDisks(config vsphere.VMConfig) string {
+	parts := make([]string, 0, 2)
+	for _, disk := range config.DiskPlacements() {
+		parts = append(parts, fmt.Sprintf("%s %d GB on %s (%s)", disk.Disk, disk.SizeGB, disk.Datastore, disk.Provisioning))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// vsphereDiskControllerLine names the disks' controller type and the install
+// disk Talos sees behind it.
+func vsphereDiskControllerLine(controller vsphere.DiskController) string {
+	if controller == "" {
+		controller = vsphere.DiskControllerNVMe
+	}
+	return fmt.Sprintf("Disk Controller: %s (install disk %s)", controller, controller.TalosInstallDisk())
+}
+
+// vsphereDiskLines lists the disk placement once when every VM shares it and
+// per VM otherwise (k8s node presets differ in boot datastore).
+func vsphereDiskLines(configs []vsphere.VMConfig) []string {
+	if len(configs) == 0 {
+		return nil
+	}
+	shared := formatVSphereDisks(configs[0])
+	for _, config := range configs[1:] {
+		if formatVSphereDisks(config) != shared {
+			lines := make([]string, 0, len(configs))
+			for _, config := range configs {
+				lines = append(lines, fmt.Sprintf("Disks (%s): %s", config.Name, formatVSphereDisks(config)))
+			}
+			return lines
+		}
+	}
+	return []string{fmt.Sprintf("Disks: %s", shared)}
+}
+
+func buildGenericVSphereVMConfig(name string, memory, vcpus, diskSize, openebsSize int, macAddress, datastore, network string, disks vsphereDiskOptions, isoPath string) vsphere.VMConfig {
+	config := vsphere.VMConfig{
 		Name:                 name,
 		Memory:               memory,
 		VCPUs:                vcpus,
@@ -1270,13 +2208,15 @@ func buildGenericVSphereVMConfig(name string, memory, vcpus, diskSize, openebsSi
 		PowerOn:              true,
 		EnableIOMMU:          true,
 		ExposeCounters:       true,
-		ThinProvisioned:      true,
+		DiskProvisioning:     vsphere.DiskProvisioningThin,
 		EnablePrecisionClock: true,
 		EnableWatchdog:       true,
 	}
+	disks.apply(&config)
+	return config
 }
 
-func buildGenericVSphereVMConfigs(baseName string, memory, vcpus, diskSize, openebsSize int, macAddress, datastore, network, isoPath string, nodeCount, startIndex int) ([]vsphere.VMConfig, error) {
+func buildGenericVSphereVMConfigs(baseName string, memory, vcpus, diskSize, openebsSize int, macAddress, datastore, network string, disks vsphereDiskOptions, isoPath string, nodeCount, startIndex int) ([]vsphere.VMConfig, error) {
 	vmNames, err := buildVSphereVMNames(baseName, nodeCount, startIndex)
 	if err != nil {
 		return nil, err
@@ -1288,13 +2228,13 @@ func buildGenericVSphereVMConfigs(baseName string, memory, vcpus, diskSize, open
 		if idx == 0 && nodeCount == 1 {
 			configMAC = macAddress
 		}
-		configs = append(configs, buildGenericVSphereVMConfig(vmName, memory, vcpus, diskSize, openebsSize, configMAC, datastore, network, isoPath))
+		configs = append(configs, buildGenericVSphereVMConfig(vmName, memory, vcpus, diskSize, openebsSize, configMAC, datastore, network, disks, isoPath))
 	}
 
 	return configs, nil
 }
 
-func buildK8sVSphereVMConfigs(baseName string, memory, vcpus, diskSize, openebsSize int, network string, nodeCount, startIndex int) ([]vsphere.VMConfig, error) {
+func buildK8sVSphereVMConfigs(baseName string, memory, vcpus, diskSize, openebsSize int, network string, disks vsphereDiskOptions, nodeCount, startIndex int) ([]vsphere.VMConfig, error) {
 	vmNames, err := buildVSphereVMNames(baseName, nodeCount, startIndex)
 	if err != nil {
 		return nil, err
@@ -1314,6 +2254,7 @@ func buildK8sVSphereVMConfigs(baseName string, memory, vcpus, diskSize, openebsS
 		config.Network = network
 		config.ISO = vsphere.DefaultISOPath()
 		config.PowerOn = true
+		disks.apply(&config)
 		configs = append(configs, config)
 	}
 
@@ -1359,7 +2300,7 @@ func emitVMDeploymentDryRunSummary(logger *common.ColorLogger, summary vmDeploym
 	logger.Success("[DRY RUN] VM deployment preview complete - no changes made")
 }
 
-func buildTrueNASDryRunSummary(name, pool string, memory, vcpus, diskSize, openebsSize int, macAddress string, skipZVolCreate bool) vmDeploymentDryRunSummary {
+func buildTrueNASDryRunSummary(name, pool string, memory, vcpus, diskSize, openebsSize int, macAddress string, skipZVolCreate, serialLog, configISO bool, cpu truenas.CPUPlacement) vmDeploymentDryRunSummary {
 	lines := []string{
 		fmt.Sprintf("Pool: %s", pool),
 		fmt.Sprintf("Memory: %d MB (%d GB)", memory, memory/1024),
@@ -1373,6 +2314,27 @@ func buildTrueNASDryRunSummary(name, pool string, memory, vcpus, diskSize, opene
 	if skipZVolCreate {
 		lines = append(lines, "Skip ZVol Creation: Yes")
 	}
+	if serialLog {
+		lines = append(lines, fmt.Sprintf("Serial Log: %s (requires TrueNAS SCALE 24.04+)", truenas.SerialLogPath(pool, name)))
+	}
+	if configISO {
+		if node, ok := versionconfig.Get().ProvisioningNodeByName(name); ok {
+			lines = append(lines, fmt.Sprintf("Config ISO: %s (machine config for %s, no apply-node step)", trueNASConfigISOPath(name), node.IP))
+		}
+	}
+	if cpu.CPUSet != "" {
+		lines = append(lines, fmt.Sprintf("CPU Set: %s (pinned: %t)", cpu.CPUSet, cpu.PinVCPUs))
+	}
+	if cpu.NodeSet != "" {
+		lines = append(lines, fmt.Sprintf("NUMA Node Set: %s", cpu.NodeSet))
+	}
+	if cpu.CPUMode != "" {
+		mode := cpu.CPUMode
+		if cpu.CPUModel != "" {
+			mode += " (" + cpu.CPUModel + ")"
+		}
+		lines = append(lines, fmt.Sprintf("CPU Mode: %s", mode))
+	}
 
 	return vmDeploymentDryRunSummary{
 		Provider: "TrueNAS",
@@ -1392,7 +2354,7 @@ func appendBatchDeploymentLines(lines []string, nodeCount, startIndex, concurren
 	return lines
 }
 
-func buildVSphereDryRunSummary(baseName string, memory, vcpus, diskSize, openebsSize int, macAddress, datastore, network string, concurrent, nodeCount, startIndex int) (vmDeploymentDryRunSummary, error) {
+func buildVSphereDryRunSummary(baseName string, memory, vcpus, diskSize, openebsSize int, macAddress, datastore, network string, disks vsphereDiskOptions, placement vspherePlacementOptions, nodes nodeMap, vmOptions vsphereVMOptions, concurrent, nodeCount, startIndex int) (vmDeploymentDryRunSummary, error) {
 	vmNames, err := buildVSphereVMNames(baseName, nodeCount, startIndex)
 	if err != nil {
 		return vmDeploymentDryRunSummary{}, err
@@ -1404,7 +2366,7 @@ func buildVSphereDryRunSummary(baseName string, memory, vcpus, diskSize, openebs
 	}
 
 	if strings.HasPrefix(baseName, "k8s") {
-		configs, err := buildK8sVSphereVMConfigs(baseName, memory, vcpus, diskSize, openebsSize, network, nodeCount, startIndex)
+		configs, err := buildK8sVSphereVMConfigs(baseName, memory, vcpus, diskSize, openebsSize, network, disks, nodeCount, startIndex)
 		if err != nil {
 			return vmDeploymentDryRunSummary{}, err
 		}
@@ -1412,8 +2374,6 @@ func buildVSphereDryRunSummary(baseName string, memory, vcpus, diskSize, openebs
 			nodeConfig, _ := vsphere.GetK8sNodeConfig(configs[0].Name)
 			summary.Lines = append(summary.Lines,
 				"Deployment Mode: SSH-based (production k8s node)",
-				fmt.Sprintf("Boot Datastore: %s", nodeConfig.BootDatastore),
-				"OpenEBS Datastore: truenas-iscsi",
 				fmt.Sprintf("Legacy OSD RDM: %s", nodeConfig.RDMPath),
 				fmt.Sprintf("SR-IOV PCI Device: %s", nodeConfig.PCIDevice),
 			)
@@ -1428,28 +2388,31 @@ func buildVSphereDryRunSummary(baseName string, memory, vcpus, diskSize, openebs
 			summary.Lines = append(summary.Lines,
 				fmt.Sprintf("Memory: %d MB (%d GB) - pinned reservation", memory, memory/1024),
 				fmt.Sprintf("vCPUs: %d", vcpus),
-				fmt.Sprintf("Boot Disk: %d GB", diskSize),
-				fmt.Sprintf("OpenEBS Disk: %d GB", openebsSize),
-				fmt.Sprintf("Network: %s (SR-IOV passthrough)", network),
 			)
+			summary.Lines = append(summary.Lines, vsphereDiskLines(configs)...)
+			summary.Lines = append(summary.Lines, fmt.Sprintf("Network: %s (SR-IOV passthrough)", network))
 		}
 	} else {
-		configs, err := buildGenericVSphereVMConfigs(baseName, memory, vcpus, diskSize, openebsSize, macAddress, datastore, network, vsphere.DefaultISOPath(), nodeCount, startIndex)
+		configs, err := buildGenericVSphereVMConfigs(baseName, memory, vcpus, diskSize, openebsSize, macAddress, datastore, network, disks, vsphere.DefaultISOPath(), nodeCount, startIndex)
 		if err != nil {
 			return vmDeploymentDryRunSummary{}, err
 		}
+		for i := range configs {
+			nodes.apply(&configs[i])
+		}
 		summary.Lines = append(summary.Lines,
 			"Deployment Mode: govmomi (generic VM)",
-			fmt.Sprintf("Datastore: %s", datastore),
 			fmt.Sprintf("Network: %s (vmxnet3)", network),
 			fmt.Sprintf("Memory: %d MB (%d GB)", memory, memory/1024),
 			fmt.Sprintf("vCPUs: %d", vcpus),
-			fmt.Sprintf("Boot Disk: %d GB", diskSize),
-			fmt.Sprintf("OpenEBS Disk: %d GB", openebsSize),
 		)
+		summary.Lines = append(summary.Lines, vsphereDiskLines(configs)...)
+		summary.Lines = append(summary.Lines, vsphereDiskControllerLine(disks.Controller))
 		if len(configs) == 1 && configs[0].MacAddress != "" {
 			summary.Lines = append(summary.Lines, fmt.Sprintf("MAC Address: %s", configs[0].MacAddress))
 		}
+		summary.Lines = append(summary.Lines, placement.dryRunLines()...)
+		summary.Lines = append(summary.Lines, vmOptions.dryRunLines()...)
 	}
 
 	summary.Lines = appendBatchDeploymentLines(summary.Lines, len(vmNames), startIndex, concurrent)
@@ -1521,8 +2484,8 @@ func buildVSphereDeploymentPlan(mode string, configs []vsphere.VMConfig, nodeCon
 	}
 }
 
-func buildGenericVSphereDeploymentPlan(baseName string, memory, vcpus, diskSize, openebsSize int, macAddress, datastore, network, isoPath string, concurrent, nodeCount, startIndex int) (*vsphereDeploymentPlan, error) {
-	configs, err := buildGenericVSphereVMConfigs(baseName, memory, vcpus, diskSize, openebsSize, macAddress, datastore, network, isoPath, nodeCount, startIndex)
+func buildGenericVSphereDeploymentPlan(baseName string, memory, vcpus, diskSize, openebsSize int, macAddress, datastore, network string, disks vsphereDiskOptions, isoPath string, concurrent, nodeCount, startIndex int) (*vsphereDeploymentPlan, error) {
+	configs, err := buildGenericVSphereVMConfigs(baseName, memory, vcpus, diskSize, openebsSize, macAddress, datastore, network, disks, isoPath, nodeCount, startIndex)
 	if err != nil {
 		return nil, err
 	}
@@ -1530,8 +2493,8 @@ func buildGenericVSphereDeploymentPlan(baseName string, memory, vcpus, diskSize,
 	return buildVSphereDeploymentPlan("generic", configs, nil, concurrent, isoPath), nil
 }
 
-func buildK8sVSphereDeploymentPlan(baseName string, memory, vcpus, diskSize, openebsSize int, network string, concurrent, nodeCount, startIndex int) (*vsphereDeploymentPlan, error) {
-	configs, err := buildK8sVSphereVMConfigs(baseName, memory, vcpus, diskSize, openebsSize, network, nodeCount, startIndex)
+func buildK8sVSphereDeploymentPlan(baseName string, memory, vcpus, diskSize, openebsSize int, network string, disks vsphereDiskOptions, concurrent, nodeCount, startIndex int) (*vsphereDeploymentPlan, error) {
+	configs, err := buildK8sVSphereVMConfigs(baseName, memory, vcpus, diskSize, openebsSize, network, disks, nodeCount, startIndex)
 	if err != nil {
 		return nil, err
 	}
@@ -1602,12 +2565,15 @@ func resolveTrueNASDeploymentAccess(logger *common.ColorLogger) (host, apiKey, s
 	return host, apiKey, spicePassword, nil
 }
 
-func connectedTrueNASVMManager(logger *common.ColorLogger, host, apiKey string) (vmlifecycle.TrueNASVMManager, error) {
+func connectedTrueNASVMManager(ctx context.Context, logger *common.ColorLogger, host, apiKey string) (vmlifecycle.TrueNASVMManager, error) {
 	logger.Debug("Creating VM manager for TrueNAS host: %s", host)
 	vmManager := vmlifecycle.NewTrueNASVMManagerFn(host, apiKey, 443, true)
 	if vmManager == nil {
 		return nil, fmt.Errorf("failed to create VM manager")
 	}
+	// Ctrl+C cancels ctx, which aborts the in-flight vm.create or zvol call
+	// instead of waiting out its timeout.
+	vmlifecycle.BindContext(ctx, vmManager)
 
 	logger.Debug("Connecting to TrueNAS API")
 	if err := vmManager.Connect(); err != nil {
@@ -1617,8 +2583,18 @@ func connectedTrueNASVMManager(logger *common.ColorLogger, host, apiKey string)
 	return vmManager, nil
 }
 
+// trueNASJobReporter is implemented by managers that report the progress of
+// the middleware jobs they wait for.
+type trueNASJobReporter interface {
+	SetJobProgress(truenas.JobProgressFunc)
+}
+
 func executeTrueNASVMDeployment(logger *common.ColorLogger, vmManager vmlifecycle.TrueNASVMManager, config truenas.VMConfig) error {
-	if err := spinWithFuncFn(fmt.Sprintf("Deploying VM %s", config.Name), func() error {
+	if err := spinWithProgressFn(fmt.Sprintf("Deploying VM %s", config.Name), func(status func(string)) error {
+		if reporter, ok := vmManager.(trueNASJobReporter); ok {
+			reporter.SetJobProgress(func(job truenas.Job) { status(job.ProgressText()) })
+			defer reporter.SetJobProgress(nil)
+		}
 		logger.Debug("Calling vmManager.DeployVM with configuration")
 		if err := vmManager.DeployVM(config); err != nil {
 			return fmt.Errorf("VM deployment failed: %w", err)
@@ -1666,10 +2642,26 @@ func logTrueNASDeploymentSuccess(logger *common.ColorLogger, config truenas.VMCo
 		logger.Info("  Schematic ID: %s", config.SchematicID)
 		logger.Info("  Talos Ver:    %s", config.TalosVersion)
 	}
-	logger.Info("ZVol naming pattern:")
-	logger.Info("  Boot disk:   %s/%s-boot (%dGB)", config.StoragePool, config.Name, config.DiskSize)
-	if config.OpenEBSSize > 0 {
-		logger.Info("  OpenEBS disk: %s/%s-openebs (%dGB)", config.StoragePool, config.Name, config.OpenEBSSize)
+	if config.SerialLog != nil {
+		logger.Info("  Serial Log:   %s", config.SerialLog.Path)
+	}
+	if config.ConfigISO != "" {
+		logger.Info("  Config ISO:   %s", config.ConfigISO)
+	}
+	if len(config.Disks) > 0 {
+		logger.Info("Disks:")
+		for _, disk := range config.Disks {
+			logger.Info("  %s: %s (%dGB)", disk.Name, disk.ZVol, disk.SizeGB)
+		}
+	} else {
+		logger.Info("ZVol naming pattern:")
+		logger.Info("  Boot disk:   %s/%s-boot (%dGB)", config.StoragePool, config.Name, config.DiskSize)
+		if config.OpenEBSSize > 0 {
+			logger.Info("  OpenEBS disk: %s/%s-openebs (%dGB)", config.StoragePool, config.Name, config.OpenEBSSize)
+		}
+	}
+	if config.ConfigISO != "" {
+		logger.Success("Machine config attached: %s configures itself on first boot, no 'talos apply-node' step is needed", config.Name)
 	}
 }
 
@@ -1687,8 +2679,12 @@ func prepareGeneratedTrueNASISO(logger *common.ColorLogger) (*trueNASISOSelectio
 	if err != nil {
 		return nil, fmt.Errorf("failed to load schematic template: %w", err)
 	}
+	addSchematicKernelArgs(schematic, truenas.TalosConfigKernelArg)
 
-	versionConfig := versionconfig.GetVersions(workingDirectoryFn())
+	versionConfig, err := repoVersions()
+	if err != nil {
+		return nil, err
+	}
 	logger.Debug("Generating ISO with parameters: version=%s, arch=amd64, platform=metal", versionConfig.TalosVersion)
 	isoInfo, err := factoryClient.GenerateISOFromSchematic(schematic, versionConfig.TalosVersion, "amd64", "metal")
 	if err != nil {
@@ -1729,17 +2725,152 @@ func prepareGeneratedTrueNASISO(logger *common.ColorLogger) (*trueNASISOSelectio
 	return selection, nil
 }
 
-func verifyPreparedTrueNASISO(logger *common.ColorLogger, host string) (*trueNASISOSelection, error) {
-	standardISOPath := versionconfig.Get().TrueNASISOPath()
-	logger.Debug("Checking for prepared ISO at: %s", standardISOPath)
-
-	sshConfig := ssh.SSHConfig{
+func trueNASSSHConfig(host string) ssh.SSHConfig {
+	return ssh.SSHConfig{
 		Host:     host,
 		Username: vmlifecycle.ResolveSecretKey(versionconfig.KeyTrueNASUsername),
 		Port:     "22",
 		KeyPath:  versionconfig.Get().Hypervisors.TrueNAS.SSHKey,
 	}
-	sshClient := newTrueNASSSHClientFn(sshConfig)
+}
+
+// prepareTrueNASSerialLog checks the NAS can take a serial log port and
+// creates the log directory so qemu can open the file at VM start.
+func prepareTrueNASSerialLog(logger *common.ColorLogger, vmManager vmlifecycle.TrueNASVMManager, host, pool, name string) (*truenas.SerialLogDevice, error) {
+	device, version, err := trueNASSerialLogDevice(vmManager, pool, name)
+	if err != nil {
+		return nil, err
+	}
+
+	sshClient := newTrueNASSSHClientFn(trueNASSSHConfig(host))
+	if err := sshClient.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to TrueNAS over SSH to create %s: %w", device.Dir(), err)
+	}
+	defer func() {
+		if closeErr := sshClient.Close(); closeErr != nil {
+			logger.Warn("Failed to close SSH client: %v", closeErr)
+		}
+	}()
+	if output, err := sshClient.ExecuteCommand("sudo mkdir -p " + common.ShellQuote(device.Dir())); err != nil {
+		return nil, fmt.Errorf("failed to create serial log directory %s: %w (%s)", device.Dir(), err, common.RedactCommandOutput(output))
+	}
+
+	logger.Info("Serial console will be logged to %s (TrueNAS %s)", device.Path, version)
+	return &device, nil
+}
+
+// trueNASSerialLogDevice is the serial log device of VM name, once the NAS's
+// version is known to support one.
+func trueNASSerialLogDevice(vmManager vmlifecycle.TrueNASVMManager, pool, name string) (truenas.SerialLogDevice, truenas.MiddlewareVersion, error) {
+	version, err := vmManager.MiddlewareVersion()
+	if err != nil {
+		return truenas.SerialLogDevice{}, version, fmt.Errorf("failed to detect TrueNAS version for --serial-log: %w", err)
+	}
+	if err := truenas.CheckSerialLogSupport(version); err != nil {
+		return truenas.SerialLogDevice{}, version, err
+	}
+	device, err := truenas.NewSerialLogDevice(pool, name)
+	return device, version, err
+}
+
+// addSchematicKernelArgs appends the args the schematic does not already
+// carry. TrueNAS ISOs get talos.config=metal-iso so a node reads its machine
+// config from the config CD-ROM deploy-vm attaches.
+func addSchematicKernelArgs(schematic *talos.SchematicConfig, args ...string) {
+	for _, arg := range args {
+		if !slices.Contains(schematic.Customization.ExtraKernelArgs, arg) {
+			schematic.Customization.ExtraKernelArgs = append(schematic.Customization.ExtraKernelArgs, arg)
+		}
+	}
+}
+
+// trueNASConfigISOPath puts a VM's config ISO in the boot ISO's directory.
+func trueNASConfigISOPath(name string) string {
+	return truenas.ConfigISOPath(path.Dir(versionconfig.Get().TrueNASISOPath()), name)
+}
+
+// prepareTrueNASConfigISO renders the machine config of the cluster node
+// named like the VM, packs it into a metal-iso config ISO, and uploads it
+// to the NAS for config to attach. VMs that are not cluster nodes, or whose
+// node has no template, are left for a manual apply-node.
+func prepareTrueNASConfigISO(logger *common.ColorLogger, host string, config *truenas.VMConfig) error {
+	node, nodeTemplate, machineType, ok := trueNASConfigISONode(logger, config.Name)
+	if !ok {
+		return nil
+	}
+
+	logger.Info("Rendering %s machine config for %s (%s) into a config ISO", machineType, node.Name, node.IP)
+	rendered, err := renderMachineConfigFromEmbeddedFn(fmt.Sprintf("talos/%s.yaml", machineType), nodeTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to render machine config for %s: %w", node.IP, err)
+	}
+	resolved, err := injectSecretsWithSignin(logger, string(rendered))
+	if err != nil {
+		return err
+	}
+	image, err := cloudinit.BuildTalosConfigISO([]byte(resolved))
+	if err != nil {
+		return fmt.Errorf("failed to build config ISO for %s: %w", config.Name, err)
+	}
+
+	isoPath := trueNASConfigISOPath(config.Name)
+	sshClient := newTrueNASSSHClientFn(trueNASSSHConfig(host))
+	if err := sshClient.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to TrueNAS over SSH to upload %s: %w", isoPath, err)
+	}
+	defer func() {
+		if closeErr := sshClient.Close(); closeErr != nil {
+			logger.Warn("Failed to close SSH client: %v", closeErr)
+		}
+	}()
+	if err := sshClient.UploadBytes(image, isoPath); err != nil {
+		return fmt.Errorf("failed to upload config ISO: %w", err)
+	}
+	// The volume carries the cluster's secrets in the clear.
+	if output, err := sshClient.ExecuteCommand("sudo chmod 600 " + common.ShellQuote(isoPath)); err != nil {
+		return fmt.Errorf("failed to restrict permissions on %s: %w (%s)", isoPath, err, common.RedactCommandOutput(output))
+	}
+
+	attachTrueNASConfigISO(logger, node, isoPath, config)
+	logger.Success("Uploaded machine config ISO %s (%d bytes)", isoPath, len(image))
+	return nil
+}
+
+// trueNASConfigISONode is the cluster.nodes entry of VM name with its
+// machine config template and type; ok is false when there is no config to
+// attach.
+func trueNASConfigISONode(logger *common.ColorLogger, name string) (node versionconfig.Node, nodeTemplate, machineType string, ok bool) {
+	node, ok = versionconfig.Get().ProvisioningNodeByName(name)
+	if !ok {
+		logger.Info("VM %s is not in cluster.nodes: no machine config attached, apply one with 'talos apply-node' after boot", name)
+		return node, "", "", false
+	}
+	nodeTemplate = fmt.Sprintf("talos/nodes/%s.yaml", node.IP)
+	content, err := getTalosTemplateFn(nodeTemplate)
+	if err != nil {
+		logger.Warn("No machine config template for %s (%v): apply one with 'talos apply-node' after boot", node.IP, err)
+		return node, "", "", false
+	}
+	return node, nodeTemplate, nodeTemplateMachineType(content), true
+}
+
+// attachTrueNASConfigISO points config at the config ISO of node.
+func attachTrueNASConfigISO(logger *common.ColorLogger, node versionconfig.Node, isoPath string, config *truenas.VMConfig) {
+	// The rendered config selects its interface by the node's MAC, so the
+	// VM must carry that MAC for the config to bring the network up.
+	if mac := node.VM.ForProvider("talos").Mac; config.MacAddress == "" && mac != "" {
+		config.MacAddress = mac
+	} else if mac != "" && !strings.EqualFold(config.MacAddress, mac) {
+		logger.Warn("--mac-address %s differs from %s's configured MAC %s; the injected config will not match the VM's NIC", config.MacAddress, node.Name, mac)
+	}
+	config.ConfigISO = isoPath
+}
+
+func verifyPreparedTrueNASISO(logger *common.ColorLogger, host string) (*trueNASISOSelection, error) {
+	standardISOPath := versionconfig.Get().TrueNASISOPath()
+	logger.Debug("Checking for prepared ISO at: %s", standardISOPath)
+
+	sshClient := newTrueNASSSHClientFn(trueNASSSHConfig(host))
 
 	if err := sshClient.Connect(); err != nil {
 		logger.Warn("Cannot verify prepared ISO due to SSH connection failure: %v", err)
@@ -1761,7 +2892,10 @@ func verifyPreparedTrueNASISO(logger *common.ColorLogger, host string) (*trueNAS
 		return nil, fmt.Errorf("no prepared ISO found. Please run 'homeops-cli talos prepare-iso' first to prepare the ISO, or use the --generate-iso flag to generate a new one")
 	}
 
-	versionConfig := versionconfig.GetVersions(workingDirectoryFn())
+	versionConfig, err := repoVersions()
+	if err != nil {
+		return nil, err
+	}
 	logger.Success("Using prepared ISO: %s (size: %d bytes)", standardISOPath, size)
 	logger.Info("Prepared ISO found - proceeding with VM deployment...")
 
@@ -1772,11 +2906,14 @@ func verifyPreparedTrueNASISO(logger *common.ColorLogger, host string) (*trueNAS
 	}, nil
 }
 
-func resolveTrueNASISOSelection(logger *common.ColorLogger, host string, generateISO bool) (*trueNASISOSelection, error) {
-	logger.Debug("Determining ISO configuration (generateISO=%t)", generateISO)
+func resolveTrueNASISOSelection(logger *common.ColorLogger, host string, generateISO bool, isoPath string) (*trueNASISOSelection, error) {
+	logger.Debug("Determining ISO configuration (generateISO=%t, isoPath=%s)", generateISO, isoPath)
 	if generateISO {
 		return prepareGeneratedTrueNASISO(logger)
 	}
+	if isoPath != "" {
+		return selectTrueNASISOPath(logger, host, isoPath)
+	}
 
 	return verifyPreparedTrueNASISO(logger, host)
 }
@@ -1810,46 +2947,51 @@ func logVSphereGenericSingleVMConfig(logger *common.ColorLogger, config vsphere.
 	logger.Info("Enhanced Configuration:")
 	logger.Info("  Memory: %d MB", config.Memory)
 	logger.Info("  vCPUs: %d", config.VCPUs)
-	logger.Info("  Boot Disk: %d GB (thin provisioned: %v)", config.DiskSize, config.ThinProvisioned)
-	logger.Info("  OpenEBS Disk: %d GB (thin provisioned: %v)", config.OpenEBSSize, config.ThinProvisioned)
-	logger.Info("  Datastore: %s", config.Datastore)
+	logger.Info("  Disks: %s", formatVSphereDisks(config))
 	logger.Info("  Network: %s (vmxnet3)", config.Network)
 	logger.Info("  ISO: %s", config.ISO)
 	if config.MacAddress != "" {
 		logger.Info("  MAC Address: %s", config.MacAddress)
 	}
+	if config.Host != "" {
+		logger.Info("  Host: %s", config.Host)
+	}
 	logger.Info("  IOMMU Enabled: %v", config.EnableIOMMU)
 	logger.Info("  CPU Counters Exposed: %v", config.ExposeCounters)
 	logger.Info("  Precision Clock: %v", config.EnablePrecisionClock)
 	logger.Info("  Watchdog Timer: %v", config.EnableWatchdog)
-	logger.Info("  EFI Firmware: enabled")
-	logger.Info("  UEFI Secure Boot: disabled")
-	logger.Info("  NVME Controllers: 2 (separate for each disk)")
+	for _, line := range vsphereVMOptionsFromConfig(config).dryRunLines() {
+		logger.Info("  %s", line)
+	}
+	logger.Info("  %s", vsphereDiskControllerLine(config.DiskController))
 }
 
-func logVSphereGenericParallelPlan(logger *common.ColorLogger, plan *vsphereDeploymentPlan, memory, vcpus, diskSize, openebsSize int, datastore, network string) {
+func logVSphereGenericParallelPlan(logger *common.ColorLogger, plan *vsphereDeploymentPlan, memory, vcpus int, network string) {
 	logger.Info("Deploying %d VMs in parallel (max concurrent: %d)", len(plan.Configs), plan.Concurrent)
 	logger.Info("Enhanced VM Configuration (for all VMs):")
 	logger.Info("  Memory: %d MB", memory)
 	logger.Info("  vCPUs: %d", vcpus)
-	logger.Info("  Boot Disk: %d GB (thin provisioned)", diskSize)
-	logger.Info("  OpenEBS Disk: %d GB (thin provisioned)", openebsSize)
-	logger.Info("  Datastore: %s", datastore)
+	logger.Info("  Disks: %s", formatVSphereDisks(plan.Configs[0]))
+	logger.Info("  %s", vsphereDiskControllerLine(plan.Configs[0].DiskController))
 	logger.Info("  Network: %s (vmxnet3)", network)
 	logger.Info("  ISO: %s", plan.ISOPath)
 	logger.Info("  IOMMU Enabled: true")
 	logger.Info("  CPU Counters Exposed: true")
 	logger.Info("  Precision Clock: enabled")
 	logger.Info("  Watchdog Timer: enabled")
-	logger.Info("  EFI Firmware: enabled")
-	logger.Info("  UEFI Secure Boot: disabled")
+	for _, line := range vsphereVMOptionsFromConfig(plan.Configs[0]).dryRunLines() {
+		logger.Info("  %s", line)
+	}
 	logger.Info("  NVME Controllers: 2 (separate for each disk)")
 	logger.Info("")
 	logger.Info("VMs to deploy:")
 	for _, config := range plan.Configs {
-		if config.MacAddress != "" {
+		switch {
+		case config.MacAddress != "":
 			logger.Info("  - %s (MAC: %s)", config.Name, config.MacAddress)
-		} else {
+		case config.Host != "":
+			logger.Info("  - %s (host: %s)", config.Name, config.Host)
+		default:
 			logger.Info("  - %s", config.Name)
 		}
 	}
@@ -1863,33 +3005,187 @@ func executeVSphereGenericDeploymentPlan(logger *common.ColorLogger, client vsph
 		return nil
 	}
 
+	if err := client.PreflightDatastores(plan.Configs); err != nil {
+		return err
+	}
 	if err := client.DeployVMsConcurrently(plan.Configs); err != nil {
 		return fmt.Errorf("parallel deployment failed: %w", err)
 	}
 	return nil
 }
 
-func deployVMWithPatternDryRun(name, pool string, memory, vcpus, diskSize, openebsSize int, macAddress string, skipZVolCreate, generateISO, dryRun bool) error {
+func deployVMWithPatternDryRun(ctx context.Context, name, pool string, memory, vcpus, diskSize, openebsSize int, macAddress, isoPath string, skipZVolCreate, generateISO, serialLog, configISO, keepOnFailure, update, start, autostart bool, cpu truenas.CPUPlacement, zvol truenas.ZVolOptions, spec *truenas.VMSpec, wait trueNASIPWait, dryRun bool) error {
 	if dryRun {
 		logger := common.NewColorLogger()
-		summary := buildTrueNASDryRunSummary(name, pool, memory, vcpus, diskSize, openebsSize, macAddress, skipZVolCreate)
+		summary := buildTrueNASDryRunSummary(name, pool, memory, vcpus, diskSize, openebsSize, macAddress, skipZVolCreate, serialLog, configISO, cpu)
+		summary.Lines = vmSpecDryRunLines(summary.Lines, spec)
+		summary.Lines = append(summary.Lines, zvolOptionDryRunLines(zvol)...)
+		if isoPath != "" {
+			summary.Lines = append(summary.Lines, trueNASISOPathDryRunLine(isoPath))
+		}
+		if start {
+			summary.Lines = append(summary.Lines, "Start: Yes (wait up to 60s for RUNNING)")
+		}
+		if autostart {
+			summary.Lines = append(summary.Lines, "Autostart: Yes")
+		}
+		if wait.Timeout > 0 {
+			summary.Lines = append(summary.Lines, fmt.Sprintf("Wait For IP: start the VM and wait up to %s for its address", wait.Timeout))
+		}
 		emitVMDeploymentDryRunSummary(logger, summary, generateISO)
+		return deployTrueNASVMPattern(ctx, name, pool, memory, vcpus, diskSize, openebsSize, macAddress, isoPath, skipZVolCreate, generateISO, serialLog, configISO, keepOnFailure, update, start, autostart, cpu, zvol, spec, wait, &truenas.DryRunPlan{}, false)
+	}
+	return deployVMWithPattern(ctx, name, pool, memory, vcpus, diskSize, openebsSize, macAddress, isoPath, skipZVolCreate, generateISO, serialLog, configISO, keepOnFailure, update, start, autostart, cpu, zvol, spec, wait)
+}
+
+// reportTrueNASVMDrift prints every difference between VM config.Name and
+// config, failing when there is any.
+func reportTrueNASVMDrift(vmManager vmlifecycle.TrueNASVMManager, config truenas.VMConfig) error {
+	drift, err := vmManager.VerifyVM(config)
+	if err != nil {
+		return fmt.Errorf("failed to verify VM %s: %w", config.Name, err)
+	}
+	if len(drift) == 0 {
+		_, _ = fmt.Fprintf(deployVMStdout, "VM %s matches its expected layout: no drift\n", config.Name)
 		return nil
 	}
-	return deployVMWithPattern(name, pool, memory, vcpus, diskSize, openebsSize, macAddress, skipZVolCreate, generateISO)
+	rows := make([][]string, 0, len(drift))
+	for _, entry := range drift {
+		rows = append(rows, []string{entry.Field, entry.Want, entry.Live})
+	}
+	_, _ = fmt.Fprintln(deployVMStdout, ui.Table([]string{"FIELD", "EXPECTED", "LIVE"}, rows))
+	return fmt.Errorf("VM %s has drifted from its expected layout: %d differences", config.Name, len(drift))
+}
+
+// printTrueNASDryRunPlan prints the plan of a deploy dry run.
+func printTrueNASDryRunPlan(action string, lines []string) {
+	_, _ = fmt.Fprintf(deployVMStdout, "Dry run: %s would:\n", action)
+	for _, line := range lines {
+		_, _ = fmt.Fprintln(deployVMStdout, "  "+line)
+	}
+	_, _ = fmt.Fprintln(deployVMStdout, "Nothing was changed.")
 }
 
-func deployVMOnVSphereDryRun(baseName string, memory, vcpus, diskSize, openebsSize int, macAddress, datastore, network string, generateISO bool, concurrent, nodeCount, startIndex int, dryRun bool) error {
+// zvolOptionDryRunLines lists the --sparse, --volblocksize and --compression
+// settings that differ from the defaults.
+func zvolOptionDryRunLines(zvol truenas.ZVolOptions) []string {
+	var lines []string
+	if zvol.Thick {
+		lines = append(lines, "ZVol Provisioning: thick (full refreservation)")
+	}
+	for _, option := range []struct {
+		label  string
+		values map[string]string
+	}{{"ZVol Block Size", zvol.BlockSize}, {"ZVol Compression", zvol.Compression}} {
+		for _, disk := range slices.Sorted(maps.Keys(option.values)) {
+			if disk == "" {
+				lines = append(lines, fmt.Sprintf("%s: %s", option.label, option.values[disk]))
+			} else {
+				lines = append(lines, fmt.Sprintf("%s (%s): %s", option.label, disk, option.values[disk]))
+			}
+		}
+	}
+	return lines
+}
+
+// applyVMSpecFlags fills the deploy-vm flags left unset from spec and lets
+// the ones given override the spec's devices: --mac-address the first NIC,
+// --disk-size and --openebs-size the first and second disk.
+func applyVMSpecFlags(cmd *cobra.Command, spec *truenas.VMSpec, name, pool *string, memory, vcpus *int, diskSize, openebsSize int, macAddress string) error {
+	if *name == "" {
+		*name = spec.Name
+	}
+	if spec.Pool != "" && !cmd.Flags().Changed("pool") {
+		*pool = spec.Pool
+	}
+	if spec.MemoryMB > 0 && !cmd.Flags().Changed("memory") {
+		*memory = spec.MemoryMB
+	}
+	if spec.VCPUs > 0 && !cmd.Flags().Changed("vcpus") {
+		*vcpus = spec.VCPUs
+	}
+	if cmd.Flags().Changed("mac-address") && len(spec.NICs) > 0 {
+		spec.NICs[0].MAC = macAddress
+	}
+	for i, flag := range []struct {
+		name string
+		size int
+	}{{"disk-size", diskSize}, {"openebs-size", openebsSize}} {
+		if cmd.Flags().Changed(flag.name) && i < len(spec.Disks) {
+			spec.Disks[i].SizeGB = flag.size
+		}
+	}
+	return spec.Validate()
+}
+
+// applyDataDiskFlags turns the --data-disk values into the disk layout of
+// spec (a new one when there is no --spec): the boot disk of --disk-size, then
+// an OpenEBS disk if --openebs-size was given, then the data disks.
+func applyDataDiskFlags(cmd *cobra.Command, spec *truenas.VMSpec, name, pool string, diskSize, openebsSize int, values []string) (*truenas.VMSpec, error) {
+	if spec != nil && len(spec.Disks) > 0 {
+		return nil, fmt.Errorf("--data-disk cannot be combined with a --spec that lists disks; add the disks to the spec instead")
+	}
+	var data []truenas.VMSpecDisk
+	if cmd.Flags().Changed("openebs-size") && openebsSize > 0 {
+		data = append(data, truenas.VMSpecDisk{Name: "openebs", SizeGB: openebsSize})
+	}
+	for _, value := range values {
+		disk, err := truenas.ParseDataDisk(value)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, disk)
+	}
+	layout, err := truenas.DataDiskSpec(name, pool, diskSize, data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --data-disk layout: %w", err)
+	}
+	if spec == nil {
+		return layout, nil
+	}
+	spec.Disks = layout.Disks
+	return spec, nil
+}
+
+// vmSpecDryRunLines replaces the boot/OpenEBS disk lines with the spec's
+// disks and lists its NICs.
+func vmSpecDryRunLines(lines []string, spec *truenas.VMSpec) []string {
+	if spec == nil {
+		return lines
+	}
+	var out []string
+	for _, line := range lines {
+		if len(spec.Disks) > 0 && (strings.HasPrefix(line, "Boot Disk:") || strings.HasPrefix(line, "OpenEBS Disk:")) {
+			continue
+		}
+		out = append(out, line)
+	}
+	config := truenas.VMConfig{}
+	spec.Apply(&config)
+	for _, disk := range config.Disks {
+		out = append(out, fmt.Sprintf("Disk %s: %s (%d GB, order %d)", disk.Name, disk.ZVol, disk.SizeGB, disk.Order))
+	}
+	for i, nic := range config.NICs {
+		bridge := nic.Bridge
+		if bridge == "" {
+			bridge = "default bridge"
+		}
+		out = append(out, fmt.Sprintf("NIC %d: %s on %s (order %d)", i, nic.Model, bridge, nic.Order))
+	}
+	return out
+}
+
+func deployVMOnVSphereDryRun(baseName string, memory, vcpus, diskSize, openebsSize int, macAddress, datastore, network string, disks vsphereDiskOptions, placement vspherePlacementOptions, nodes nodeMap, vmOptions vsphereVMOptions, generateISO bool, concurrent, nodeCount, startIndex int, dryRun bool) error {
 	if dryRun {
 		logger := common.NewColorLogger()
-		summary, err := buildVSphereDryRunSummary(baseName, memory, vcpus, diskSize, openebsSize, macAddress, datastore, network, concurrent, nodeCount, startIndex)
+		summary, err := buildVSphereDryRunSummary(baseName, memory, vcpus, diskSize, openebsSize, macAddress, datastore, network, disks, placement, nodes, vmOptions, concurrent, nodeCount, startIndex)
 		if err != nil {
 			return err
 		}
 		emitVMDeploymentDryRunSummary(logger, summary, generateISO)
 		return nil
 	}
-	return deployVMOnVSphere(baseName, memory, vcpus, diskSize, openebsSize, macAddress, datastore, network, generateISO, concurrent, nodeCount, startIndex)
+	return deployVMOnVSphere(baseName, memory, vcpus, diskSize, openebsSize, macAddress, datastore, network, disks, placement, nodes, vmOptions, generateISO, concurrent, nodeCount, startIndex)
 }
 
 // deployVMOnProxmoxDryRun handles Proxmox VM deployment with dry-run support
@@ -2065,34 +3361,68 @@ func deployProxmoxVMsConcurrently(host, tokenID, secret, nodeName string, config
 
 // prepareISOForProxmox handles Proxmox-specific ISO preparation
 func prepareISOForProxmox() error {
-	versionConfig := versionconfig.GetVersions(common.GetWorkingDirectory())
+	target, err := proxmoxISOTarget("local")
+	if err != nil {
+		return err
+	}
+	return prepareISOForTargetFn(target)
+}
+
+// proxmoxISOTarget uploads the ISO to a Proxmox storage.
+func proxmoxISOTarget(storage string) (isoPreparationTarget, error) {
+	versionConfig, err := repoVersions()
+	if err != nil {
+		return isoPreparationTarget{}, err
+	}
 	isoFilename := fmt.Sprintf("talos-%s-nocloud-amd64.iso", versionConfig.TalosVersion)
-	target := isoPreparationTarget{
+	return isoPreparationTarget{
+		name:           "proxmox",
 		providerName:   "Proxmox",
 		platform:       "nocloud",
 		uploadStep:     "Uploading ISO to Proxmox storage...",
 		uploadSpinner:  "Uploading ISO to Proxmox",
-		location:       proxmox.GetISOPath("local", isoFilename),
+		location:       proxmox.GetISOPath(storage, isoFilename),
 		deployCommand:  "homeops-cli talos deploy-vm --provider proxmox --name <vm_name> [other flags]",
-		summaryMessage: "Custom ISO generated and uploaded to Proxmox local storage",
-		uploadISO: func(isoInfo *talos.ISOInfo) error {
+		summaryMessage: fmt.Sprintf("Custom ISO generated and uploaded to Proxmox %s storage", storage),
+		uploadISO: func(isoInfo *talos.ISOInfo, _ func(string)) error {
 			return vmlifecycle.WithProxmoxVMManager(common.NewColorLogger(), func(vmManager vmlifecycle.ProxmoxVMManager) error {
-				if err := vmManager.UploadISOFromURL(isoInfo.URL, isoFilename, "local"); err != nil {
+				if err := vmManager.UploadISOFromURL(isoInfo.URL, isoFilename, storage); err != nil {
 					return fmt.Errorf("failed to upload custom ISO to Proxmox: %w", err)
 				}
 				return nil
 			})
 		},
-	}
+		storedSize: func() (int64, error) {
+			var size int64
+			err := vmlifecycle.WithProxmoxVMManager(common.NewColorLogger(), func(vmManager vmlifecycle.ProxmoxVMManager) error {
+				var err error
+				size, err = vmManager.ISOSize(isoFilename, storage)
+				return err
+			})
+			return size, err
+		},
+	}, nil
+}
 
-	return prepareISOForTargetFn(target)
+func deployVMWithPattern(ctx context.Context, name, pool string, memory, vcpus, diskSize, openebsSize int, macAddress, isoPath string, skipZVolCreate, generateISO, serialLog, configISO, keepOnFailure, update, start, autostart bool, cpu truenas.CPUPlacement, zvol truenas.ZVolOptions, spec *truenas.VMSpec, wait trueNASIPWait) error {
+	return deployTrueNASVMPattern(ctx, name, pool, memory, vcpus, diskSize, openebsSize, macAddress, isoPath, skipZVolCreate, generateISO, serialLog, configISO, keepOnFailure, update, start, autostart, cpu, zvol, spec, wait, nil, false)
 }
 
-func deployVMWithPattern(name, pool string, memory, vcpus, diskSize, openebsSize int, macAddress string, skipZVolCreate, generateISO bool) error {
+// deployTrueNASVMPattern is deployVMWithPattern, or with a plan its dry run:
+// the NAS is read as for a deploy, the middleware's mutating calls are
+// recorded in plan, the ISO, serial log and config ISO steps are listed
+// instead of run, and the resulting plan is printed. With verify the
+// configuration built for the dry run is compared with the existing VM
+// instead of being deployed.
+func deployTrueNASVMPattern(ctx context.Context, name, pool string, memory, vcpus, diskSize, openebsSize int, macAddress, isoPath string, skipZVolCreate, generateISO, serialLog, configISO, keepOnFailure, update, start, autostart bool, cpu truenas.CPUPlacement, zvol truenas.ZVolOptions, spec *truenas.VMSpec, wait trueNASIPWait, plan *truenas.DryRunPlan, verify bool) error {
 	logger := common.NewColorLogger()
-	logger.Info("Starting VM deployment: %s", name)
-	logger.Debug("VM Configuration: pool=%s, memory=%dMB, vcpus=%d, diskSize=%dGB, openebsSize=%dGB, macAddress=%s, skipZVolCreate=%t, generateISO=%t",
-		pool, memory, vcpus, diskSize, openebsSize, macAddress, skipZVolCreate, generateISO)
+	if verify {
+		logger.Info("Verifying VM layout: %s", name)
+	} else {
+		logger.Info("Starting VM deployment: %s", name)
+	}
+	logger.Debug("VM Configuration: pool=%s, memory=%dMB, vcpus=%d, diskSize=%dGB, openebsSize=%dGB, macAddress=%s, skipZVolCreate=%t, generateISO=%t, serialLog=%t",
+		pool, memory, vcpus, diskSize, openebsSize, macAddress, skipZVolCreate, generateISO, serialLog)
 
 	// Validate input parameters
 	if name == "" {
@@ -2114,7 +3444,6 @@ func deployVMWithPattern(name, pool string, memory, vcpus, diskSize, openebsSize
 		return fmt.Errorf("openebs size cannot be negative, got %d", openebsSize)
 	}
 
-	// Validate VM name - no dashes allowed
 	logger.Debug("Validating VM name: %s", name)
 	if err := vmlifecycle.ValidateVMName(name); err != nil {
 		return fmt.Errorf("VM name validation failed: %w", err)
@@ -2125,13 +3454,34 @@ func deployVMWithPattern(name, pool string, memory, vcpus, diskSize, openebsSize
 		return err
 	}
 
-	isoSelection, err := resolveTrueNASISOSelection(logger, host, generateISO)
+	// track times a phase of a real deploy in metrics.Spans for --metrics-file.
+	track := func(phase string, fn func() error) error {
+		if plan != nil || verify {
+			return fn()
+		}
+		return metrics.Spans.TrackOperation(phase, fn)
+	}
+
+	// steps are what a dry run leaves out besides the middleware calls.
+	var isoSelection *trueNASISOSelection
+	var steps []string
+	if plan != nil {
+		isoSelection, steps, err = planTrueNASISOSelection(logger, host, generateISO, isoPath)
+	} else {
+		err = track("iso", func() (err error) {
+			isoSelection, err = resolveTrueNASISOSelection(logger, host, generateISO, isoPath)
+			return err
+		})
+	}
 	if err != nil {
 		return err
 	}
 
-	vmManager, err := connectedTrueNASVMManager(logger, host, apiKey)
-	if err != nil {
+	var vmManager vmlifecycle.TrueNASVMManager
+	if err := track("connect", func() (err error) {
+		vmManager, err = connectedTrueNASVMManager(ctx, logger, host, apiKey)
+		return err
+	}); err != nil {
 		return err
 	}
 
@@ -2143,6 +3493,9 @@ func deployVMWithPattern(name, pool string, memory, vcpus, diskSize, openebsSize
 			logger.Debug("VM manager connection closed successfully")
 		}
 	}()
+	if plan != nil && !vmlifecycle.BindDryRun(plan, vmManager) {
+		return fmt.Errorf("this TrueNAS connection cannot plan a deploy without making it")
+	}
 
 	// Build VM configuration with auto-generated ZVol paths matching the pattern from working scripts
 	logger.Debug("Building VM configuration")
@@ -2150,26 +3503,92 @@ func deployVMWithPattern(name, pool string, memory, vcpus, diskSize, openebsSize
 	logger.Debug("Network bridge: %s", networkBridge)
 
 	config := buildTrueNASVMConfig(name, memory, vcpus, diskSize, openebsSize, host, apiKey, isoSelection.ISOPath, networkBridge, pool, macAddress, spicePassword, isoSelection.SchematicID, isoSelection.TalosVersion, skipZVolCreate, isoSelection.CustomISO)
+	config.CPU = cpu
+	config.ZVol = zvol
+	config.KeepOnFailure = keepOnFailure
+	config.Update = update
+	config.Start = start
+	config.Autostart = autostart
+	if spec != nil {
+		spec.Apply(&config)
+	}
+	// Talos seals a tpm disk encryption key to the VM's vTPM; without one
+	// STATE and EPHEMERAL cannot be encrypted on first boot.
+	if versionconfig.Get().Cluster.Talos.DiskEncryption.Provider == versionconfig.DiskEncryptionTPM {
+		config.TPM = true
+		logger.Info("Attaching a virtual TPM for cluster.talos.disk_encryption.provider tpm")
+	}
+	switch {
+	case serialLog && plan != nil:
+		device, _, err := trueNASSerialLogDevice(vmManager, pool, name)
+		if err != nil {
+			return err
+		}
+		config.SerialLog = &device
+		steps = append(steps, "Create serial log directory "+device.Dir()+" over SSH")
+	case serialLog:
+		if config.SerialLog, err = prepareTrueNASSerialLog(logger, vmManager, host, pool, name); err != nil {
+			return err
+		}
+	}
+	switch {
+	case configISO && plan != nil:
+		if node, _, machineType, ok := trueNASConfigISONode(logger, name); ok {
+			isoPath := trueNASConfigISOPath(name)
+			attachTrueNASConfigISO(logger, node, isoPath, &config)
+			steps = append(steps, fmt.Sprintf("Render the %s machine config of %s (%s) and upload it as config ISO %s", machineType, node.Name, node.IP, isoPath))
+		}
+	case configISO:
+		if err := prepareTrueNASConfigISO(logger, host, &config); err != nil {
+			return err
+		}
+	}
 
 	logger.Debug("VM configuration built successfully")
 	logger.Debug("Configuration summary: Name=%s, Memory=%dMB, vCPUs=%d, ISO=%s, Bridge=%s, Pool=%s",
 		name, memory, vcpus, isoSelection.ISOPath, networkBridge, pool)
 
+	if verify {
+		return reportTrueNASVMDrift(vmManager, config)
+	}
+
 	// STEP 3: Deploy the VM (ISO is now ready on TrueNAS)
 	logger.Info("STEP 3: Starting VM deployment process...")
 
-	if err := executeTrueNASVMDeployment(logger, vmManager, config); err != nil {
+	if err := track("deploy", func() error {
+		return executeTrueNASVMDeployment(logger, vmManager, config)
+	}); err != nil {
 		return err
 	}
+	if plan != nil {
+		lines := append(steps, plan.Lines()...)
+		if wait.Timeout > 0 {
+			lines = append(lines, fmt.Sprintf("Wait up to %s for the node's IP", wait.Timeout))
+		}
+		printTrueNASDryRunPlan(fmt.Sprintf("deploying VM '%s' on TrueNAS", name), lines)
+		return nil
+	}
 	logTrueNASDeploymentSuccess(logger, config)
 
+	if wait.Timeout > 0 {
+		var ip string
+		if err := track("wait-for-ip", func() (err error) {
+			ip, err = waitForTrueNASNodeIP(ctx, logger, vmManager, host, config, wait)
+			return err
+		}); err != nil {
+			return err
+		}
+		logger.Success("%s is up at %s", name, ip)
+		_, _ = fmt.Fprintf(deployVMStdout, "Node IP: %s\n", ip)
+	}
+
 	logger.Debug("VM deployment function completed successfully")
 	return nil
 }
 
 // newPrepareISOCommand creates the prepare-iso command
 func newPrepareISOCommand() *cobra.Command {
-	var provider string
+	var providers, targets []string
 
 	cmd := &cobra.Command{
 		Use:   "prepare-iso",
@@ -2182,27 +3601,72 @@ This command will:
 4. Update the node configuration templates with the new schematic ID
 
 This separates ISO preparation from VM deployment, allowing you to prepare the ISO once
-and deploy multiple VMs using the same custom configuration.`,
+and deploy multiple VMs using the same custom configuration.
+
+Steps 2 and 3 are skipped when the provider already holds an ISO built from the
+same schematic, Talos version, and platform: the last upload to each location is
+recorded in ~/.config/homeops/state/talos-prepare-iso.json, and the stored file
+must still have the recorded size. --force regenerates and re-uploads anyway.
+
+The ISO is downloaded in chunks that are retried on their own, with progress in
+the upload spinner. A failed or killed run keeps the partial download (locally
+for vSphere, on the NAS for TrueNAS); --resume continues it instead of starting
+over.
+
+--provider can be repeated, or be "all" for proxmox, truenas and vsphere.
+--target <provider>[@<host>][:<datastore>] also picks where the ISO goes: a
+Proxmox storage or vSphere datastore, and for vSphere an ESXi host other than
+the configured one, reached with the same credentials. The ISO is generated
+once per platform and uploaded to every target concurrently; vSphere targets
+share one download.`,
+		Example: `  homeops-cli talos prepare-iso --provider all
+  homeops-cli talos prepare-iso --target vsphere:datastore1 --target vsphere@esxi-02.local:datastore1 --target truenas`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cmdutil.ResolveStringFlagDefault(cmd, "provider", &provider, vmlifecycle.DefaultProviderName)
-			return prepareISOWithProvider(provider)
+			if len(targets) > 0 && cmd.Flags().Changed("provider") {
+				return fmt.Errorf("--provider and --target cannot be used together")
+			}
+			if len(targets) == 0 && len(providers) == 0 {
+				providers = []string{vmlifecycle.DefaultProviderName()}
+			}
+			if err := checkRepoVersions(cmd.Context(), versioncheck.Talos); err != nil {
+				return err
+			}
+			if len(targets) == 0 && len(providers) == 1 && providers[0] != prepareISOAllProviders {
+				return prepareISOWithProvider(providers[0])
+			}
+			return prepareISOForSpecs(providers, targets)
 		},
 	}
 
-	cmd.Flags().StringVar(&provider, "provider", "", "Storage provider: proxmox, truenas, or vsphere/esxi (default: hypervisors.default from homeops.yaml)")
+	cmd.Flags().StringSliceVar(&providers, "provider", nil, "Storage provider: proxmox, truenas, vsphere/esxi, or all; repeatable (default: hypervisors.default from homeops.yaml)")
+	cmd.Flags().StringArrayVar(&targets, "target", nil, "Upload target <provider>[@<host>][:<datastore>], e.g. vsphere:datastore2; repeatable")
+	cmd.Flags().BoolVar(&prepareISOForce, "force", false, "Regenerate and re-upload the ISO even when the provider already holds it")
+	cmd.Flags().BoolVar(&prepareISOResume, "resume", false, "Continue the partial ISO download left by an interrupted run instead of starting over")
 
 	return cmd
 }
 
 type isoPreparationTarget struct {
-	providerName   string
-	platform       string
-	uploadStep     string
-	uploadSpinner  string
-	location       string
-	deployCommand  string
-	uploadISO      func(*talos.ISOInfo) error
+	// name is the target as --target spells it, e.g. vsphere:datastore1.
+	name          string
+	providerName  string
+	platform      string
+	uploadStep    string
+	uploadSpinner string
+	location      string
+	deployCommand string
+	// uploadISO puts the ISO at location; status updates the upload spinner.
+	uploadISO func(isoInfo *talos.ISOInfo, status func(string)) error
+	// uploadLocal, set instead of uploadISO, uploads a local copy of the ISO
+	// that was downloaded and verified once for every such target.
+	uploadLocal    func(path string, digest iso.Digest, status func(string)) error
 	summaryMessage string
+	// kernelArgs are added to the schematic's extraKernelArgs for this
+	// provider only.
+	kernelArgs []string
+	// storedSize returns the size of the ISO at location, 0 when it is
+	// missing. Targets without it are prepared on every run.
+	storedSize func() (int64, error)
 }
 
 // prepareISOWithProvider handles the ISO generation and upload process for different providers
@@ -2225,10 +3689,29 @@ func prepareISOWithProvider(provider string) error {
 }
 
 func prepareISOForTarget(target isoPreparationTarget) error {
+	return prepareISOForTargets([]isoPreparationTarget{target})
+}
+
+// isoBuild is one factory ISO and the targets that take it.
+type isoBuild struct {
+	schematic *talos.SchematicConfig
+	platform  string
+	hash      string
+	targets   []int
+}
+
+// prepareISOForTargets prepares the ISO on every target. Targets with the
+// same platform and kernel args share one factory build, and its uploads run
+// concurrently. The node templates get the first target's schematic, and
+// only once every target holds its ISO.
+func prepareISOForTargets(targets []isoPreparationTarget) error {
 	logger := common.NewColorLogger()
-	logger.Info("Starting custom Talos ISO preparation for %s...", target.providerName)
+	logger.Info("Starting custom Talos ISO preparation for %s...", isoTargetsLabel(targets))
 
-	versionConfig := versionconfig.GetVersions(common.GetWorkingDirectory())
+	versionConfig, err := repoVersions()
+	if err != nil {
+		return err
+	}
 	logger.Debug("Using versions: Kubernetes=%s, Talos=%s", versionConfig.KubernetesVersion, versionConfig.TalosVersion)
 
 	logger.Debug("Creating Talos factory client")
@@ -2238,46 +3721,69 @@ func prepareISOForTarget(target isoPreparationTarget) error {
 	}
 
 	logger.Info("STEP 1: Loading schematic configuration...")
-	schematic, err := factoryClient.LoadSchematicFromTemplate()
-	if err != nil {
-		return fmt.Errorf("failed to load schematic template: %w", err)
+	var builds []*isoBuild
+	for i, target := range targets {
+		schematic, err := factoryClient.LoadSchematicFromTemplate()
+		if err != nil {
+			return fmt.Errorf("failed to load schematic template: %w", err)
+		}
+		addSchematicKernelArgs(schematic, target.kernelArgs...)
+		hash, err := prepareISOHash(schematic, versionConfig.TalosVersion, "amd64", target.platform)
+		if err != nil {
+			return err
+		}
+		index := slices.IndexFunc(builds, func(b *isoBuild) bool { return b.hash == hash })
+		if index < 0 {
+			builds = append(builds, &isoBuild{schematic: schematic, platform: target.platform, hash: hash})
+			index = len(builds) - 1
+		}
+		builds[index].targets = append(builds[index].targets, i)
 	}
 	logger.Success("Schematic configuration loaded successfully")
 
-	logger.Info("STEP 2: Generating custom Talos ISO...")
-	var isoInfo *talos.ISOInfo
-	err = spinWithFuncFn("Generating custom Talos ISO", func() error {
-		logger.Debug("Generating ISO with parameters: version=%s, arch=amd64, platform=%s", versionConfig.TalosVersion, target.platform)
-		var genErr error
-		isoInfo, genErr = factoryClient.GenerateISOFromSchematic(schematic, versionConfig.TalosVersion, "amd64", target.platform)
-		if genErr != nil {
-			return fmt.Errorf("ISO generation failed: %w", genErr)
+	results := make([]*talos.ISOInfo, len(targets))
+	failed := 0
+	for _, build := range builds {
+		var pending []isoPreparationTarget
+		var pendingIndex []int
+		for _, i := range build.targets {
+			if cached, ok := cachedPreparedISO(logger, targets[i], build.hash); ok {
+				results[i] = &talos.ISOInfo{URL: cached.URL, SchematicID: cached.SchematicID, TalosVersion: cached.TalosVersion}
+				logger.Success("%s already holds this ISO (schematic %s, Talos %s); skipping generation and upload (--force to redo)",
+					targets[i].location, cached.SchematicID, cached.TalosVersion)
+				continue
+			}
+			pending = append(pending, targets[i])
+			pendingIndex = append(pendingIndex, i)
 		}
-		if isoInfo == nil {
-			return fmt.Errorf("ISO generation returned nil result")
+		if len(pending) == 0 {
+			continue
 		}
-		return nil
-	})
-	if err != nil {
-		return err
-	}
-
-	logger.Success("Custom ISO generated successfully")
-	logger.Info("ISO Details:")
-	logger.Info("  URL: %s", isoInfo.URL)
-	logger.Info("  Schematic ID: %s", isoInfo.SchematicID)
-	logger.Info("  Talos Version: %s", isoInfo.TalosVersion)
 
-	logger.Info("STEP 3: %s", target.uploadStep)
-	err = spinWithFuncFn(target.uploadSpinner, func() error {
-		return target.uploadISO(isoInfo)
-	})
-	if err != nil {
-		return err
+		isoInfo, err := generateISO(logger, factoryClient, build.schematic, versionConfig.TalosVersion, build.platform)
+		if err != nil {
+			return err
+		}
+		for k, uploadErr := range uploadISOToTargets(logger, isoInfo, pending) {
+			target := pending[k]
+			if uploadErr != nil {
+				if len(targets) == 1 {
+					return uploadErr
+				}
+				logger.Error("Upload to %s failed: %v", target.name, uploadErr)
+				failed++
+				continue
+			}
+			logger.Success("Custom ISO uploaded to %s successfully", target.providerName)
+			logger.Info("ISO Location: %s", target.location)
+			recordPreparedISO(logger, target, build.hash, isoInfo)
+			results[pendingIndex[k]] = isoInfo
+		}
 	}
-
-	logger.Success("Custom ISO uploaded to %s successfully", target.providerName)
-	logger.Info("ISO Location: %s", target.location)
+	if failed > 0 {
+		return fmt.Errorf("ISO upload failed for %d of %d targets; rerun to retry them, the others are skipped as prepared", failed, len(targets))
+	}
+	isoInfo := results[0]
 
 	logger.Info("STEP 4: Updating node configuration templates...")
 	if err := updateNodeTemplatesWithSchematicFn(isoInfo.SchematicID, isoInfo.TalosVersion); err != nil {
@@ -2289,6 +3795,15 @@ func prepareISOForTarget(target isoPreparationTarget) error {
 
 	logger.Success("ISO preparation completed successfully!")
 	logger.Info("Summary:")
+	if len(targets) > 1 {
+		for i, target := range targets {
+			logger.Info("  - %s: %s (schematic %s)", target.name, target.location, results[i].SchematicID)
+		}
+		logger.Info("  - Talos Version: %s", isoInfo.TalosVersion)
+		logger.Info("  - Node templates updated with schematic ID %s (from %s)", isoInfo.SchematicID, targets[0].name)
+		return nil
+	}
+	target := targets[0]
 	logger.Info("  - %s", target.summaryMessage)
 	logger.Info("  - Schematic ID: %s", isoInfo.SchematicID)
 	logger.Info("  - Talos Version: %s", isoInfo.TalosVersion)
@@ -2301,9 +3816,43 @@ func prepareISOForTarget(target isoPreparationTarget) error {
 	return nil
 }
 
+// generateISO builds the ISO at the factory (step 2 of prepare-iso).
+func generateISO(logger *common.ColorLogger, factoryClient talosFactoryClient, schematic *talos.SchematicConfig, talosVersion, platform string) (*talos.ISOInfo, error) {
+	logger.Info("STEP 2: Generating custom Talos ISO...")
+	var isoInfo *talos.ISOInfo
+	err := spinWithFuncFn("Generating custom Talos ISO", func() error {
+		logger.Debug("Generating ISO with parameters: version=%s, arch=amd64, platform=%s", talosVersion, platform)
+		var genErr error
+		isoInfo, genErr = factoryClient.GenerateISOFromSchematic(schematic, talosVersion, "amd64", platform)
+		if genErr != nil {
+			return fmt.Errorf("ISO generation failed: %w", genErr)
+		}
+		if isoInfo == nil {
+			return fmt.Errorf("ISO generation returned nil result")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Success("Custom ISO generated successfully")
+	logger.Info("ISO Details:")
+	logger.Info("  URL: %s", isoInfo.URL)
+	logger.Info("  Schematic ID: %s", isoInfo.SchematicID)
+	logger.Info("  Talos Version: %s", isoInfo.TalosVersion)
+	return isoInfo, nil
+}
+
 // prepareISOForTrueNAS handles TrueNAS-specific ISO preparation
 func prepareISOForTrueNAS() error {
-	target := isoPreparationTarget{
+	return prepareISOForTargetFn(trueNASISOTarget())
+}
+
+// trueNASISOTarget has the NAS download the ISO into hypervisors.truenas.
+func trueNASISOTarget() isoPreparationTarget {
+	return isoPreparationTarget{
+		name:           "truenas",
 		providerName:   "TrueNAS",
 		platform:       "metal",
 		uploadStep:     "Uploading ISO to TrueNAS...",
@@ -2311,10 +3860,12 @@ func prepareISOForTrueNAS() error {
 		location:       versionconfig.Get().TrueNASISOPath(),
 		deployCommand:  "homeops-cli talos deploy-vm --provider truenas --name <vm_name> [other flags]",
 		summaryMessage: "Custom ISO generated and uploaded to TrueNAS",
-		uploadISO: func(isoInfo *talos.ISOInfo) error {
+		kernelArgs:     []string{truenas.TalosConfigKernelArg},
+		uploadISO: func(isoInfo *talos.ISOInfo, _ func(string)) error {
 			downloader := newISODownloaderFn()
 			downloadConfig := iso.GetDefaultConfig()
 			downloadConfig.ISOURL = isoInfo.URL
+			downloadConfig.Resume = prepareISOResume
 			downloadConfig.ISOFilename = filepath.Base(versionconfig.Get().TrueNASISOPath())
 
 			if err := downloader.DownloadCustomISO(downloadConfig); err != nil {
@@ -2322,110 +3873,190 @@ func prepareISOForTrueNAS() error {
 			}
 			return nil
 		},
+		storedSize: func() (int64, error) {
+			downloadConfig := iso.GetDefaultConfig()
+			downloadConfig.ISOFilename = filepath.Base(versionconfig.Get().TrueNASISOPath())
+			return newISODownloaderFn().StoredISOSize(downloadConfig)
+		},
 	}
-
-	return prepareISOForTargetFn(target)
 }
 
 // prepareISOForVSphere handles vSphere-specific ISO preparation
 func prepareISOForVSphere() error {
-	target := isoPreparationTarget{
+	return prepareISOForTargetFn(vSphereISOTarget(defaultVSphereISODest()))
+}
+
+// vSphereISODest is where a vSphere target keeps the ISO.
+type vSphereISODest struct {
+	host      string // "" is the configured vSphere host
+	datastore string
+	file      string
+}
+
+func defaultVSphereISODest() vSphereISODest {
+	dest := vSphereISODest{datastore: vsphere.DefaultISODatastore, file: vsphere.DefaultISOFilename}
+	cfg := versionconfig.Get().Hypervisors.VSphere
+	if cfg.ISODatastore != "" {
+		dest.datastore = cfg.ISODatastore
+	}
+	if cfg.ISOFile != "" {
+		dest.file = cfg.ISOFile
+	}
+	return dest
+}
+
+func (d vSphereISODest) location() string {
+	if d.host == "" {
+		return vsphere.BuildISOPath(d.datastore, d.file)
+	}
+	return d.host + " " + vsphere.BuildISOPath(d.datastore, d.file)
+}
+
+// vSphereISOTarget uploads the ISO to dest.
+func vSphereISOTarget(dest vSphereISODest) isoPreparationTarget {
+	return isoPreparationTarget{
+		name:           "vsphere",
 		providerName:   "vSphere",
 		platform:       "nocloud",
 		uploadStep:     "Uploading ISO to vSphere datastore...",
 		uploadSpinner:  "Uploading ISO to vSphere",
-		location:       vsphere.DefaultISOPath(),
+		location:       dest.location(),
 		deployCommand:  "homeops-cli talos deploy-vm --provider vsphere --name <vm_name> [other flags]",
-		summaryMessage: "Custom ISO generated and uploaded to vSphere datastore1",
-		uploadISO: func(isoInfo *talos.ISOInfo) error {
-			return uploadISOToVSphereFn(isoInfo.URL)
+		summaryMessage: fmt.Sprintf("Custom ISO generated and uploaded to vSphere %s", dest.datastore),
+		uploadLocal: func(path string, digest iso.Digest, status func(string)) error {
+			return uploadISOFileToVSphereFn(path, digest, dest, status)
+		},
+		// A partial datastore upload leaves a short file, so the size must
+		// match what was recorded before the cache is trusted.
+		storedSize: func() (int64, error) {
+			var size int64
+			err := vmlifecycle.WithVSphereClientOnHost(common.NewColorLogger(), dest.host, func(client vmlifecycle.VSphereClient) error {
+				var err error
+				size, err = client.DatastoreFileSize(dest.datastore, dest.file)
+				return err
+			})
+			return size, err
 		},
 	}
+}
 
-	return prepareISOForTargetFn(target)
+// vSphereUploadReporter is a VSphereClient that can report datastore upload
+// progress.
+type vSphereUploadReporter interface {
+	SetUploadProgress(func(percent float32, rate string))
 }
 
-// uploadISOToVSphere downloads ISO from URL and uploads it to vSphere datastore
-func uploadISOToVSphere(isoURL string) error {
+// downloadVerifiedISO downloads the ISO at isoURL for a local upload. The
+// download is hashed as it streams, and the file must still match that
+// digest when it is handed over; a corrupt file is removed.
+func downloadVerifiedISO(isoURL string, status func(string)) (string, iso.Digest, error) {
 	logger := common.NewColorLogger()
 
-	// Download ISO to temporary file
 	logger.Info("Downloading ISO from factory...")
-	tempFile, err := downloadISOToTemp(isoURL)
+	tempFile, digest, err := downloadISOToTempFn(isoURL, prepareISOResume, status)
 	if err != nil {
-		return fmt.Errorf("failed to download ISO: %w", err)
+		return "", iso.Digest{}, fmt.Errorf("failed to download ISO: %w", err)
 	}
-	defer func() {
-		if err := os.Remove(tempFile); err != nil {
-			logger.Warn("Failed to remove temporary file %s: %v", tempFile, err)
-		}
-	}()
+	logger.Success("ISO downloaded to temporary file: %s (SHA256 %s)", tempFile, digest.SHA256)
+	if err := iso.VerifyFile(tempFile, digest); err != nil {
+		_ = os.Remove(tempFile)
+		return "", iso.Digest{}, fmt.Errorf("refusing to upload: %w", err)
+	}
+	return tempFile, digest, nil
+}
 
-	logger.Success("ISO downloaded to temporary file: %s", tempFile)
+// uploadISOFileToVSphere uploads the verified ISO at path to dest. The
+// datastore copy must have the digest's size after.
+func uploadISOFileToVSphere(path string, digest iso.Digest, dest vSphereISODest, status func(string)) error {
+	logger := common.NewColorLogger()
 
-	// Upload to vSphere datastore
-	return vmlifecycle.WithVSphereClient(logger, func(client vmlifecycle.VSphereClient) error {
-		logger.Info("Uploading ISO to vSphere datastore1...")
-		if err := client.UploadISOToDatastore(tempFile, vsphere.DefaultISODatastore, vsphere.DefaultISOFilename); err != nil {
-			return fmt.Errorf("failed to upload ISO to datastore: %w", err)
+	return vmlifecycle.WithVSphereClientOnHost(logger, dest.host, func(client vmlifecycle.VSphereClient) error {
+		if reporter, ok := client.(vSphereUploadReporter); ok {
+			reporter.SetUploadProgress(func(percent float32, rate string) {
+				status(fmt.Sprintf("uploading: %.0f%% at %s", percent, rate))
+			})
+			defer reporter.SetUploadProgress(nil)
+		}
+		// The datastore file API takes the file in one PUT, so a failed
+		// upload is retried whole, from the verified local copy.
+		logger.Info("Uploading ISO to %s...", dest.location())
+		for attempt := 1; ; attempt++ {
+			err := client.UploadISOToDatastore(path, dest.datastore, dest.file)
+			if err == nil {
+				break
+			}
+			if attempt == vSphereUploadAttempts {
+				return fmt.Errorf("failed to upload ISO to datastore after %d attempts: %w", attempt, err)
+			}
+			logger.Warn("Upload attempt %d of %d failed: %v; retrying", attempt, vSphereUploadAttempts, err)
+			time.Sleep(time.Duration(attempt) * isoTransferRetryDelay)
 		}
 
-		logger.Success("ISO uploaded to vSphere datastore successfully")
+		size, err := client.DatastoreFileSize(dest.datastore, dest.file)
+		if err != nil {
+			return fmt.Errorf("failed to verify uploaded ISO: %w", err)
+		}
+		if size != digest.Size {
+			return fmt.Errorf("uploaded ISO %s is %d bytes, expected %d (SHA256 %s)", dest.location(), size, digest.Size, digest.SHA256)
+		}
+		if err := uploadISOChecksumFile(client, digest, dest); err != nil {
+			logger.Warn("Failed to store the ISO checksum on the datastore: %v", err)
+		}
+
+		logger.Success("ISO uploaded to %s successfully", dest.location())
 		return nil
 	})
 }
 
-// downloadISOToTemp downloads ISO from URL to a temporary file and returns the file path
-func downloadISOToTemp(isoURL string) (string, error) {
-	logger := common.NewColorLogger()
-
-	// Create temporary file
-	tempFile, err := os.CreateTemp("", "talos-*.iso")
+// uploadISOChecksumFile stores the ISO's digest next to it on the datastore
+// in sha256sum format, so the copy can be checked later.
+func uploadISOChecksumFile(client vmlifecycle.VSphereClient, digest iso.Digest, dest vSphereISODest) error {
+	file, err := os.CreateTemp("", "talos-*.iso.sha256")
 	if err != nil {
-		return "", fmt.Errorf("failed to create temporary file: %w", err)
+		return err
 	}
-	if err := tempFile.Close(); err != nil {
-		return "", fmt.Errorf("failed to close temporary file: %w", err)
+	defer func() { _ = os.Remove(file.Name()) }()
+	if _, err := fmt.Fprintf(file, "%s  %s\n", digest.SHA256, dest.file); err != nil {
+		_ = file.Close()
+		return err
 	}
-
-	tempPath := tempFile.Name()
-	logger.Debug("Created temporary file: %s", tempPath)
-
-	// Download ISO
-	logger.Debug("Downloading from URL: %s", isoURL)
-	resp, err := httpGetFn(isoURL)
-	if err != nil {
-		_ = os.Remove(tempPath)
-		return "", fmt.Errorf("failed to download ISO: %w", err)
+	if err := file.Close(); err != nil {
+		return err
 	}
-	defer func() { _ = resp.Body.Close() }()
+	return client.UploadISOToDatastore(file.Name(), dest.datastore, dest.file+".sha256")
+}
 
-	if resp.StatusCode != http.StatusOK {
-		_ = os.Remove(tempPath)
-		return "", fmt.Errorf("failed to download ISO: HTTP %d", resp.StatusCode)
-	}
+// downloadISOToTemp downloads ISO from URL to a file in the temp directory
+// named after the URL and returns its path and the digest of its bytes. The
+// download is fetched in Range chunks, each retried on its own, and reports
+// its progress to status. With resume a partial file left by an earlier run
+// is continued rather than fetched again; it is kept on failure for that.
+func downloadISOToTemp(isoURL string, resume bool, status func(string)) (string, iso.Digest, error) {
+	logger := common.NewColorLogger()
 
-	// Create file for writing
-	outFile, err := os.Create(tempPath) // #nosec G304 -- ISO destination is an os.CreateTemp path created by this process
-	if err != nil {
-		_ = os.Remove(tempPath)
-		return "", fmt.Errorf("failed to create output file: %w", err)
+	sum := sha256.Sum256([]byte(isoURL))
+	tempPath := filepath.Join(os.TempDir(), fmt.Sprintf("talos-%x.iso", sum[:8]))
+	if info, err := os.Stat(tempPath); err == nil && resume {
+		logger.Info("Resuming the ISO download after the %d MB in %s", info.Size()/(1024*1024), tempPath)
 	}
-	defer func() { _ = outFile.Close() }()
 
-	// Copy with progress tracking
-	size := resp.ContentLength
-	if size > 0 {
-		logger.Info("Downloading %d MB ISO...", size/(1024*1024))
+	logger.Debug("Downloading from URL: %s to %s", isoURL, tempPath)
+	download := iso.ChunkedDownload{
+		Do:         httpDoFn,
+		RetryDelay: isoTransferRetryDelay,
+		Progress:   func(p iso.Progress) { status("downloading: " + p.String()) },
 	}
-
-	_, err = io.Copy(outFile, resp.Body)
+	digest, err := download.ToFile(isoURL, tempPath, resume)
 	if err != nil {
+		if info, statErr := os.Stat(tempPath); statErr == nil && info.Size() > 0 {
+			return "", iso.Digest{}, fmt.Errorf("%w (%d bytes kept in %s; rerun with --resume to continue)", err, info.Size(), tempPath)
+		}
 		_ = os.Remove(tempPath)
-		return "", fmt.Errorf("failed to write ISO data: %w", err)
+		return "", iso.Digest{}, err
 	}
+	logger.Info("Downloaded %d MB ISO", digest.Size/(1024*1024))
 
-	return tempPath, nil
+	return tempPath, digest, nil
 }
 
 // updateNodeTemplatesWithSchematic updates the controlplane template with the new schematic ID
@@ -2482,7 +4113,7 @@ func updateNodeTemplatesWithSchematic(schematicID, talosVersion string) error {
 }
 
 // deployVMOnVSphere deploys one or more VMs on vSphere/ESXi
-func deployVMOnVSphere(baseName string, memory, vcpus, diskSize, openebsSize int, macAddress, datastore, network string, generateISO bool, concurrent, nodeCount, startIndex int) error {
+func deployVMOnVSphere(baseName string, memory, vcpus, diskSize, openebsSize int, macAddress, datastore, network string, disks vsphereDiskOptions, placement vspherePlacementOptions, nodes nodeMap, vmOptions vsphereVMOptions, generateISO bool, concurrent, nodeCount, startIndex int) error {
 	logger := common.NewColorLogger()
 	logger.Info("Starting vSphere/ESXi VM deployment with enhanced configuration")
 
@@ -2495,16 +4126,16 @@ func deployVMOnVSphere(baseName string, memory, vcpus, diskSize, openebsSize int
 	// Batch deployments commonly use the shared base name "k8s", which expands to k8s-0, k8s-1, ...
 	isK8sNode := strings.HasPrefix(baseName, "k8s")
 	if isK8sNode {
-		return deployK8sVMViaSSH(baseName, host, memory, vcpus, diskSize, openebsSize, network, generateISO, nodeCount, startIndex)
+		return deployK8sVMViaSSH(baseName, host, memory, vcpus, diskSize, openebsSize, network, disks, generateISO, nodeCount, startIndex)
 	}
 
 	// For non-k8s VMs, use the standard govmomi approach
-	return deployGenericVMOnVSphere(baseName, host, memory, vcpus, diskSize, openebsSize, macAddress, datastore, network, generateISO, concurrent, nodeCount, startIndex)
+	return deployGenericVMOnVSphere(baseName, host, memory, vcpus, diskSize, openebsSize, macAddress, datastore, network, disks, placement, nodes, vmOptions, generateISO, concurrent, nodeCount, startIndex)
 }
 
 // deployK8sVMViaSSH deploys k8s VMs using SSH for exact configuration control
 // This ensures the VMs match the existing manually-deployed production VMs exactly
-func deployK8sVMViaSSH(baseName string, host string, memory, vcpus, diskSize, openebsSize int, network string, _generateISO bool, nodeCount, startIndex int) error {
+func deployK8sVMViaSSH(baseName string, host string, memory, vcpus, diskSize, openebsSize int, network string, disks vsphereDiskOptions, _generateISO bool, nodeCount, startIndex int) error {
 	logger := common.NewColorLogger()
 	logger.Info("Deploying k8s VM(s) via SSH with production configuration")
 
@@ -2515,7 +4146,7 @@ func deployK8sVMViaSSH(baseName string, host string, memory, vcpus, diskSize, op
 	}
 	defer esxiClient.Close() // Clean up SSH key file
 
-	plan, err := buildK8sVSphereDeploymentPlan(baseName, memory, vcpus, diskSize, openebsSize, network, nodeCount, nodeCount, startIndex)
+	plan, err := buildK8sVSphereDeploymentPlan(baseName, memory, vcpus, diskSize, openebsSize, network, disks, nodeCount, nodeCount, startIndex)
 	if err != nil {
 		return err
 	}
@@ -2525,16 +4156,13 @@ func deployK8sVMViaSSH(baseName string, host string, memory, vcpus, diskSize, op
 		nodeConfig := plan.NodeConfigs[idx]
 
 		logger.Info("Deploying %s with production configuration:", config.Name)
-		logger.Info("  Boot Datastore: %s", nodeConfig.BootDatastore)
-		logger.Info("  OpenEBS Datastore: truenas-iscsi")
 		logger.Info("  Legacy OSD RDM: %s", nodeConfig.RDMPath)
 		logger.Info("  SR-IOV PCI: %s", nodeConfig.PCIDevice)
 		logger.Info("  MAC Address: %s", nodeConfig.MacAddress)
 		logger.Info("  CPU Affinity: %s", nodeConfig.CPUAffinity)
 		logger.Info("  Memory: %d MB (%d GB) - pinned reservation", config.Memory, config.Memory/1024)
 		logger.Info("  vCPUs: %d", config.VCPUs)
-		logger.Info("  Boot Disk: %d GB", config.DiskSize)
-		logger.Info("  OpenEBS Disk: %d GB", config.OpenEBSSize)
+		logger.Info("  Disks: %s", formatVSphereDisks(config))
 
 		// Create the VM
 		if err := esxiClient.CreateK8sVM(config); err != nil {
@@ -2548,7 +4176,7 @@ func deployK8sVMViaSSH(baseName string, host string, memory, vcpus, diskSize, op
 }
 
 // deployGenericVMOnVSphere deploys non-k8s VMs using govmomi (legacy behavior)
-func deployGenericVMOnVSphere(baseName string, host string, memory, vcpus, diskSize, openebsSize int, macAddress, datastore, network string, generateISO bool, concurrent, nodeCount, startIndex int) error {
+func deployGenericVMOnVSphere(baseName string, host string, memory, vcpus, diskSize, openebsSize int, macAddress, datastore, network string, disks vsphereDiskOptions, placement vspherePlacementOptions, nodes nodeMap, vmOptions vsphereVMOptions, generateISO bool, concurrent, nodeCount, startIndex int) error {
 	logger := common.NewColorLogger()
 
 	_, username, password, err := vmlifecycle.GetVSphereCredsFn()
@@ -2577,20 +4205,38 @@ func deployGenericVMOnVSphere(baseName string, host string, memory, vcpus, diskS
 		isoPath = vsphere.DefaultISOPath()
 	}
 
-	plan, err := buildGenericVSphereDeploymentPlan(baseName, memory, vcpus, diskSize, openebsSize, macAddress, datastore, network, isoPath, concurrent, nodeCount, startIndex)
+	plan, err := buildGenericVSphereDeploymentPlan(baseName, memory, vcpus, diskSize, openebsSize, macAddress, datastore, network, disks, isoPath, concurrent, nodeCount, startIndex)
 	if err != nil {
 		return err
 	}
+	for i := range plan.Configs {
+		placement.apply(&plan.Configs[i])
+		nodes.apply(&plan.Configs[i])
+		vmOptions.apply(&plan.Configs[i])
+	}
+	antiAffinity := placement.AntiAffinity && len(plan.Configs) > 1
+	if antiAffinity {
+		if plan.Configs, err = client.SpreadAcrossHosts(plan.Configs); err != nil {
+			return fmt.Errorf("failed to spread the VMs across hosts: %w", err)
+		}
+	}
 
 	if len(plan.Configs) == 1 {
 		logVSphereGenericSingleVMConfig(logger, plan.Configs[0])
 	} else {
-		logVSphereGenericParallelPlan(logger, plan, memory, vcpus, diskSize, openebsSize, datastore, network)
+		logVSphereGenericParallelPlan(logger, plan, memory, vcpus, network)
 	}
 
 	if err := executeVSphereGenericDeploymentPlan(logger, client, plan); err != nil {
 		return err
 	}
+	if antiAffinity {
+		// The VMs are already on separate hosts; without the rule DRS may
+		// later move them together.
+		if err := client.AddAntiAffinityRule(vSphereAntiAffinityRuleName(baseName), plan.VMNames); err != nil {
+			logger.Warn("VMs deployed, but the DRS anti-affinity rule was not added: %v", err)
+		}
+	}
 
 	if len(plan.Configs) == 1 {
 		logger.Success("VM %s deployed successfully with enhanced configuration!", plan.Configs[0].Name)
@@ -1,15 +1,19 @@
 package talos
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -22,6 +26,7 @@ import (
 	versionconfig "homeops-cli/internal/config"
 	"homeops-cli/internal/constants"
 	"homeops-cli/internal/iso"
+	"homeops-cli/internal/metrics"
 	vmprov "homeops-cli/internal/provider"
 	"homeops-cli/internal/proxmox"
 	"homeops-cli/internal/ssh"
@@ -39,7 +44,15 @@ type fakeVSphereDeployer struct {
 	createErr       error
 	deployErr       error
 	closeErr        error
-	closeCalls      int
+
+	preflightConfigs []vsphere.VMConfig
+	preflightErr     error
+	closeCalls       int
+
+	// spreadHosts are handed out round-robin by SpreadAcrossHosts.
+	spreadHosts []string
+	rules       map[string][]string
+	ruleErr     error
 }
 
 func stubUnavailable1PasswordCLI(t *testing.T) {
@@ -61,6 +74,29 @@ func (f *fakeVSphereDeployer) DeployVMsConcurrently(configs []vsphere.VMConfig)
 	return f.deployErr
 }
 
+func (f *fakeVSphereDeployer) PreflightDatastores(configs []vsphere.VMConfig) error {
+	f.preflightConfigs = append([]vsphere.VMConfig(nil), configs...)
+	return f.preflightErr
+}
+
+func (f *fakeVSphereDeployer) SpreadAcrossHosts(configs []vsphere.VMConfig) ([]vsphere.VMConfig, error) {
+	spread := append([]vsphere.VMConfig(nil), configs...)
+	for i := range spread {
+		if len(f.spreadHosts) > 0 {
+			spread[i].Host = f.spreadHosts[i%len(f.spreadHosts)]
+		}
+	}
+	return spread, nil
+}
+
+func (f *fakeVSphereDeployer) AddAntiAffinityRule(ruleName string, vmNames []string) error {
+	if f.rules == nil {
+		f.rules = make(map[string][]string)
+	}
+	f.rules[ruleName] = vmNames
+	return f.ruleErr
+}
+
 func (f *fakeVSphereDeployer) Close() error {
 	f.closeCalls++
 	return f.closeErr
@@ -109,8 +145,9 @@ func (f *fakeTalosFactoryClient) GenerateISOFromSchematic(config *internaltalos.
 }
 
 type fakeISODownloader struct {
-	configs []iso.DownloadConfig
-	err     error
+	configs    []iso.DownloadConfig
+	err        error
+	storedSize int64
 }
 
 func (f *fakeISODownloader) DownloadCustomISO(config iso.DownloadConfig) error {
@@ -118,12 +155,19 @@ func (f *fakeISODownloader) DownloadCustomISO(config iso.DownloadConfig) error {
 	return f.err
 }
 
+func (f *fakeISODownloader) StoredISOSize(config iso.DownloadConfig) (int64, error) {
+	return f.storedSize, nil
+}
+
 type fakeTrueNASSSHClient struct {
 	connectErr error
 	verifyErr  error
 	exists     bool
 	size       int64
 	closeCalls int
+	commands   []string
+	uploads    map[string][]byte
+	output     func(command string) string
 }
 
 func (f *fakeTrueNASSSHClient) Connect() error { return f.connectErr }
@@ -134,6 +178,20 @@ func (f *fakeTrueNASSSHClient) Close() error {
 func (f *fakeTrueNASSSHClient) VerifyFile(string) (bool, int64, error) {
 	return f.exists, f.size, f.verifyErr
 }
+func (f *fakeTrueNASSSHClient) ExecuteCommand(command string) (string, error) {
+	f.commands = append(f.commands, command)
+	if f.output != nil {
+		return f.output(command), nil
+	}
+	return "", nil
+}
+func (f *fakeTrueNASSSHClient) UploadBytes(content []byte, remotePath string) error {
+	if f.uploads == nil {
+		f.uploads = map[string][]byte{}
+	}
+	f.uploads[remotePath] = content
+	return nil
+}
 
 type fakeTrueNASVMManager struct {
 	connectCalls int
@@ -153,21 +211,69 @@ type fakeTrueNASVMManager struct {
 	ips          []string
 	consoleURL   string
 	cleanupPairs []string
+	version      string
+	serialLogs   map[string]string
+	configISOs   map[string]string
+	cpuCalls     []truenas.CPUPlacementUpdate
+	cpuModels    []string
+	topology     truenas.HostTopology
+	topologySSH  bool
+	policyCalls  []string
+	policyTasks  []truenas.VMSnapshotTask
+	prunePlan    truenas.SnapshotPrunePlan
+	pruned       []truenas.SnapshotPrunePlan
 	connectErr   error
 	closeErr     error
+	ctx          context.Context
+	deployJobs   []truenas.Job
+	jobProgress  truenas.JobProgressFunc
+	summaries    []vmprov.VMSummary
+	nicMACs      map[string][]string
+	dryRun       *truenas.DryRunPlan
+	verified     []truenas.VMConfig
+	drift        []truenas.VMDrift
 }
 
 func (f *fakeTrueNASVMManager) Connect() error { f.connectCalls++; return f.connectErr }
 func (f *fakeTrueNASVMManager) Close() error   { f.closeCalls++; return f.closeErr }
+func (f *fakeTrueNASVMManager) SetContext(ctx context.Context) {
+	f.ctx = ctx
+}
+func (f *fakeTrueNASVMManager) SetJobProgress(fn truenas.JobProgressFunc) {
+	f.jobProgress = fn
+}
+func (f *fakeTrueNASVMManager) SetDryRun(plan *truenas.DryRunPlan) {
+	f.dryRun = plan
+}
 func (f *fakeTrueNASVMManager) DeployVM(config truenas.VMConfig) error {
+	if f.dryRun != nil {
+		f.dryRun.Calls = append(f.dryRun.Calls, truenas.PlannedCall{Method: "vm.create", Params: []interface{}{map[string]interface{}{"name": config.Name}}})
+		return f.deployErr
+	}
 	f.deployed = append(f.deployed, config)
+	for _, job := range f.deployJobs {
+		f.jobProgress(job)
+	}
 	return f.deployErr
 }
+func (f *fakeTrueNASVMManager) VerifyVM(config truenas.VMConfig) ([]truenas.VMDrift, error) {
+	f.verified = append(f.verified, config)
+	return f.drift, nil
+}
 func (f *fakeTrueNASVMManager) ListVMs() error { f.listCalls++; return nil }
 func (f *fakeTrueNASVMManager) VMSummaries() ([]vmprov.VMSummary, error) {
 	f.listCalls++
+	if f.summaries != nil {
+		return f.summaries, nil
+	}
 	return []vmprov.VMSummary{{Name: "tn-vm", ID: "1", Status: "RUNNING", MemoryMB: 4096, CPUs: 2}}, nil
 }
+func (f *fakeTrueNASVMManager) VMNICMACs(name string) ([]string, error) {
+	if macs, ok := f.nicMACs[name]; ok {
+		return macs, nil
+	}
+	return nil, fmt.Errorf("VM %s has no NIC with a MAC address", name)
+}
 func (f *fakeTrueNASVMManager) StartVM(name string) error {
 	f.started = append(f.started, name)
 	return nil
@@ -188,11 +294,65 @@ func (f *fakeTrueNASVMManager) CleanupOrphanedZVols(vmName, storagePool string)
 	f.cleanupPairs = append(f.cleanupPairs, vmName+":"+storagePool)
 	return nil
 }
+func (f *fakeTrueNASVMManager) MiddlewareVersion() (truenas.MiddlewareVersion, error) {
+	return truenas.ParseMiddlewareVersion(f.version)
+}
+func (f *fakeTrueNASVMManager) SerialLogPath(name string) (string, error) {
+	if logPath, ok := f.serialLogs[name]; ok {
+		return logPath, nil
+	}
+	return "", fmt.Errorf("VM '%s' has no serial console log attached", name)
+}
+func (f *fakeTrueNASVMManager) ConfigISOPath(name string) (string, error) {
+	return f.configISOs[name], nil
+}
+func (f *fakeTrueNASVMManager) VMZVols(string) ([]string, error) {
+	return nil, nil
+}
+func (f *fakeTrueNASVMManager) ConsoleInfo(string) (*truenas.ConsoleInfo, error) {
+	return &truenas.ConsoleInfo{}, nil
+}
+
+func (f *fakeTrueNASVMManager) SetVMCPUPlacement(name string, update truenas.CPUPlacementUpdate) error {
+	f.setCalls = append(f.setCalls, "cpu:"+name)
+	f.cpuCalls = append(f.cpuCalls, update)
+	return nil
+}
+func (f *fakeTrueNASVMManager) CPUModelChoices() ([]string, error) { return f.cpuModels, nil }
+func (f *fakeTrueNASVMManager) HostTopology(sshExec truenas.SSHRunner) (truenas.HostTopology, error) {
+	f.topologySSH = sshExec != nil
+	return f.topology, nil
+}
+func (f *fakeTrueNASVMManager) ApplySnapshotPolicy(name string, policy truenas.SnapshotPolicy) error {
+	f.policyCalls = append(f.policyCalls, fmt.Sprintf("%s:%d:%d:%d", name, policy.KeepHourly, policy.KeepDaily, policy.KeepWeekly))
+	return nil
+}
+func (f *fakeTrueNASVMManager) SnapshotPolicies(name string) ([]truenas.VMSnapshotTask, error) {
+	return f.policyTasks, nil
+}
+func (f *fakeTrueNASVMManager) PlanSnapshotPrune(name string) (truenas.SnapshotPrunePlan, error) {
+	plan := f.prunePlan
+	plan.VM = name
+	return plan, nil
+}
+func (f *fakeTrueNASVMManager) PruneVMSnapshots(plan truenas.SnapshotPrunePlan) error {
+	f.pruned = append(f.pruned, plan)
+	return nil
+}
+func (f *fakeTrueNASVMManager) VMUsage(truenas.SSHRunner, time.Time, time.Duration) (truenas.VMUsageReport, error) {
+	return truenas.VMUsageReport{}, nil
+}
 
 func (f *fakeTrueNASVMManager) RestartVM(name string) error {
 	f.restarted = append(f.restarted, name)
 	return nil
 }
+func (f *fakeTrueNASVMManager) PowerCycleVM(name string) error {
+	f.restarted = append(f.restarted, name)
+	return nil
+}
+func (f *fakeTrueNASVMManager) SuspendVM(string) error { return nil }
+func (f *fakeTrueNASVMManager) ResumeVM(string) error  { return nil }
 func (f *fakeTrueNASVMManager) SetVMResources(name string, memoryMB, vcpus int) error {
 	f.setCalls = append(f.setCalls, fmt.Sprintf("%s:%d:%d", name, memoryMB, vcpus))
 	return nil
@@ -253,6 +413,7 @@ type fakeProxmoxVMManager struct {
 	closeErr    error
 	deployErr   error
 	deployFunc  func(proxmox.VMConfig) error
+	isoSizes    map[string]int64
 }
 
 func (f *fakeProxmoxVMManager) Close() error   { f.closeCalls++; return f.closeErr }
@@ -281,6 +442,9 @@ func (f *fakeProxmoxVMManager) UploadISOFromURL(isoURL, filename, storageName st
 	f.uploads = append(f.uploads, isoURL+"|"+filename+"|"+storageName)
 	return nil
 }
+func (f *fakeProxmoxVMManager) ISOSize(filename, storageName string) (int64, error) {
+	return f.isoSizes[storageName+":iso/"+filename], nil
+}
 func (f *fakeProxmoxVMManager) DeployVM(config proxmox.VMConfig) error {
 	if f.deployFunc != nil {
 		return f.deployFunc(config)
@@ -410,7 +574,7 @@ func TestBuildVSphereVMNames(t *testing.T) {
 }
 
 func TestBuildGenericVSphereVMConfigs(t *testing.T) {
-	configs, err := buildGenericVSphereVMConfigs("worker", 8192, 4, 40, 100, "00:11:22:33:44:55", "fast-ds", "vl999", vsphere.DefaultISOPath(), 1, 0)
+	configs, err := buildGenericVSphereVMConfigs("worker", 8192, 4, 40, 100, "00:11:22:33:44:55", "fast-ds", "vl999", vsphereDiskOptions{}, vsphere.DefaultISOPath(), 1, 0)
 	require.NoError(t, err)
 	require.Len(t, configs, 1)
 	assert.Equal(t, "worker", configs[0].Name)
@@ -418,16 +582,27 @@ func TestBuildGenericVSphereVMConfigs(t *testing.T) {
 	assert.Equal(t, vsphere.DefaultISOPath(), configs[0].ISO)
 	assert.True(t, configs[0].PowerOn)
 
-	configs, err = buildGenericVSphereVMConfigs("worker", 8192, 4, 40, 100, "00:11:22:33:44:55", "fast-ds", "vl999", vsphere.DefaultISOPath(), 2, 0)
+	configs, err = buildGenericVSphereVMConfigs("worker", 8192, 4, 40, 100, "00:11:22:33:44:55", "fast-ds", "vl999", vsphereDiskOptions{}, vsphere.DefaultISOPath(), 2, 0)
 	require.NoError(t, err)
 	require.Len(t, configs, 2)
 	assert.Equal(t, []string{"worker-0", "worker-1"}, []string{configs[0].Name, configs[1].Name})
 	assert.Empty(t, configs[0].MacAddress)
 	assert.Empty(t, configs[1].MacAddress)
+	assert.Equal(t, vsphere.DiskProvisioningThin, configs[0].DiskProvisioning)
+	assert.Equal(t, "fast-ds", configs[0].BootDiskDatastore())
+	assert.Equal(t, "fast-ds", configs[0].OpenEBSDiskDatastore())
+
+	disks := vsphereDiskOptions{Provisioning: vsphere.DiskProvisioningThick, OpenEBSDatastore: "bulk-ds"}
+	configs, err = buildGenericVSphereVMConfigs("worker", 8192, 4, 40, 100, "", "fast-ds", "vl999", disks, vsphere.DefaultISOPath(), 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, vsphere.DiskProvisioningThick, configs[0].DiskProvisioning)
+	assert.Equal(t, "fast-ds", configs[0].BootDiskDatastore(), "boot disk defaults to --datastore")
+	assert.Equal(t, "bulk-ds", configs[0].OpenEBSDiskDatastore())
+	assert.Equal(t, "boot 40 GB on fast-ds (thick), openebs 100 GB on bulk-ds (thick)", formatVSphereDisks(configs[0]))
 }
 
 func TestBuildGenericVSphereDeploymentPlan(t *testing.T) {
-	plan, err := buildGenericVSphereDeploymentPlan("worker", 8192, 4, 40, 100, "00:11:22:33:44:55", "fast-ds", "vl999", vsphere.DefaultISOPath(), 5, 2, 3)
+	plan, err := buildGenericVSphereDeploymentPlan("worker", 8192, 4, 40, 100, "00:11:22:33:44:55", "fast-ds", "vl999", vsphereDiskOptions{}, vsphere.DefaultISOPath(), 5, 2, 3)
 	require.NoError(t, err)
 	assert.Equal(t, "generic", plan.Mode)
 	assert.Equal(t, []string{"worker-3", "worker-4"}, plan.VMNames)
@@ -437,7 +612,7 @@ func TestBuildGenericVSphereDeploymentPlan(t *testing.T) {
 }
 
 func TestBuildK8sVSphereVMConfigs(t *testing.T) {
-	configs, err := buildK8sVSphereVMConfigs("k8s", 49152, 16, 250, 800, "vl999", 2, 0)
+	configs, err := buildK8sVSphereVMConfigs("k8s", 49152, 16, 250, 800, "vl999", vsphereDiskOptions{}, 2, 0)
 	require.NoError(t, err)
 	require.Len(t, configs, 2)
 	assert.Equal(t, []string{"k8s-0", "k8s-1"}, []string{configs[0].Name, configs[1].Name})
@@ -445,13 +620,18 @@ func TestBuildK8sVSphereVMConfigs(t *testing.T) {
 	assert.Equal(t, "local-nvme1", configs[0].BootDatastore)
 	assert.Equal(t, "local-nvme1", configs[1].BootDatastore)
 
-	_, err = buildK8sVSphereVMConfigs("k8s-0", 49152, 16, 250, 800, "vl999", 2, 0)
+	configs, err = buildK8sVSphereVMConfigs("k8s-0", 49152, 16, 250, 800, "vl999", vsphereDiskOptions{Provisioning: vsphere.DiskProvisioningEagerZeroedThick, BootDatastore: "local-nvme2"}, 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "local-nvme2", configs[0].BootDatastore, "--boot-datastore overrides the node preset")
+	assert.Equal(t, vsphere.DiskProvisioningEagerZeroedThick, configs[0].DiskProvisioning)
+
+	_, err = buildK8sVSphereVMConfigs("k8s-0", 49152, 16, 250, 800, "vl999", vsphereDiskOptions{}, 2, 0)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "multi-node deployment cannot start from a numbered k8s node name")
 }
 
 func TestBuildK8sVSphereDeploymentPlan(t *testing.T) {
-	plan, err := buildK8sVSphereDeploymentPlan("k8s", 49152, 16, 250, 800, "vl999", 4, 2, 1)
+	plan, err := buildK8sVSphereDeploymentPlan("k8s", 49152, 16, 250, 800, "vl999", vsphereDiskOptions{}, 4, 2, 1)
 	require.NoError(t, err)
 	assert.Equal(t, "k8s", plan.Mode)
 	assert.Equal(t, []string{"k8s-1", "k8s-2"}, plan.VMNames)
@@ -485,11 +665,11 @@ func TestBuildTrueNASVMConfig(t *testing.T) {
 func TestTrueNASDeploymentHelpers(t *testing.T) {
 	oldSecret := vmlifecycle.ResolveSecretKeyFn
 	oldManagerFactory := vmlifecycle.NewTrueNASVMManagerFn
-	oldSpin := spinWithFuncFn
+	oldSpin := spinWithProgressFn
 	t.Cleanup(func() {
 		vmlifecycle.ResolveSecretKeyFn = oldSecret
 		vmlifecycle.NewTrueNASVMManagerFn = oldManagerFactory
-		spinWithFuncFn = oldSpin
+		spinWithProgressFn = oldSpin
 	})
 
 	t.Run("required spice password errors when missing", func(t *testing.T) {
@@ -534,10 +714,13 @@ func TestTrueNASDeploymentHelpers(t *testing.T) {
 			return manager
 		}
 
-		vmManager, err := connectedTrueNASVMManager(common.NewColorLogger(), "truenas.local", "api-key")
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		vmManager, err := connectedTrueNASVMManager(ctx, common.NewColorLogger(), "truenas.local", "api-key")
 		require.NoError(t, err)
 		assert.Same(t, manager, vmManager)
 		assert.Equal(t, 1, manager.connectCalls)
+		assert.Equal(t, ctx, manager.ctx, "the command context bounds every TrueNAS call")
 	})
 
 	t.Run("truenas network bridge falls back to default", func(t *testing.T) {
@@ -549,10 +732,12 @@ func TestTrueNASDeploymentHelpers(t *testing.T) {
 	t.Run("execute truenas deployment uses spinner seam", func(t *testing.T) {
 		manager := &fakeTrueNASVMManager{}
 		var spinnerTitle string
-		spinWithFuncFn = func(title string, fn func() error) error {
+		var statuses []string
+		spinWithProgressFn = func(title string, fn func(func(string)) error) error {
 			spinnerTitle = title
-			return fn()
+			return fn(func(status string) { statuses = append(statuses, status) })
 		}
+		manager.deployJobs = []truenas.Job{{Method: "vm.create", State: truenas.JobRunning, Progress: truenas.JobProgress{Percent: 50, Description: "Creating VM"}}}
 		config := truenas.VMConfig{Name: "tnvm"}
 
 		err := executeTrueNASVMDeployment(common.NewColorLogger(), manager, config)
@@ -560,11 +745,13 @@ func TestTrueNASDeploymentHelpers(t *testing.T) {
 		assert.Equal(t, "Deploying VM tnvm", spinnerTitle)
 		require.Len(t, manager.deployed, 1)
 		assert.Equal(t, "tnvm", manager.deployed[0].Name)
+		assert.Equal(t, []string{"vm.create 50% (Creating VM)"}, statuses, "job progress is shown in the spinner")
+		assert.Nil(t, manager.jobProgress, "progress reporting is detached once the deploy returns")
 	})
 
 	t.Run("execute truenas deployment surfaces deploy error", func(t *testing.T) {
 		manager := &fakeTrueNASVMManager{deployErr: errors.New("deploy failed")}
-		spinWithFuncFn = func(title string, fn func() error) error { return fn() }
+		spinWithProgressFn = func(_ string, fn func(func(string)) error) error { return fn(func(string) {}) }
 
 		err := executeTrueNASVMDeployment(common.NewColorLogger(), manager, truenas.VMConfig{Name: "tnvm"})
 		require.Error(t, err)
@@ -577,16 +764,16 @@ func TestResolveTrueNASISOSelection(t *testing.T) {
 	oldDownloader := newISODownloaderFn
 	oldSSHClient := newTrueNASSSHClientFn
 	oldSecret := vmlifecycle.ResolveSecretKeyFn
-	oldWorkdir := workingDirectoryFn
+	oldWorkdir := repoRootFn
 	t.Cleanup(func() {
 		newTalosFactoryClientFn = oldFactory
 		newISODownloaderFn = oldDownloader
 		newTrueNASSSHClientFn = oldSSHClient
 		vmlifecycle.ResolveSecretKeyFn = oldSecret
-		workingDirectoryFn = oldWorkdir
+		repoRootFn = oldWorkdir
 	})
 
-	workingDirectoryFn = func() string { return "." }
+	repoRootFn = func() (string, error) { return ".", nil }
 	vmlifecycle.ResolveSecretKeyFn = func(ref string) string {
 		switch ref {
 		case versionconfig.KeyTrueNASHost:
@@ -679,7 +866,7 @@ func TestDeployGenericVMOnVSphereSingleUsesSeam(t *testing.T) {
 		return fake, nil
 	}
 
-	err := deployGenericVMOnVSphere("worker", "esxi.local", 8192, 4, 50, 100, "00:11:22:33:44:55", "fast-ds", "vl999", false, 2, 1, 0)
+	err := deployGenericVMOnVSphere("worker", "esxi.local", 8192, 4, 50, 100, "00:11:22:33:44:55", "fast-ds", "vl999", vsphereDiskOptions{}, vspherePlacementOptions{}, nil, vsphereVMOptions{}, false, 2, 1, 0)
 	require.NoError(t, err)
 	require.Len(t, fake.createdConfigs, 1)
 	assert.Equal(t, "worker", fake.createdConfigs[0].Name)
@@ -705,7 +892,7 @@ func TestDeployGenericVMOnVSphereMultiUsesConcurrentDeploy(t *testing.T) {
 		return fake, nil
 	}
 
-	err := deployGenericVMOnVSphere("worker", "esxi.local", 8192, 4, 50, 100, "00:11:22:33:44:55", "fast-ds", "vl999", false, 2, 3, 0)
+	err := deployGenericVMOnVSphere("worker", "esxi.local", 8192, 4, 50, 100, "00:11:22:33:44:55", "fast-ds", "vl999", vsphereDiskOptions{}, vspherePlacementOptions{}, nil, vsphereVMOptions{}, false, 2, 3, 0)
 	require.NoError(t, err)
 	assert.Empty(t, fake.createdConfigs)
 	require.Len(t, fake.deployedConfigs, 3)
@@ -715,7 +902,58 @@ func TestDeployGenericVMOnVSphereMultiUsesConcurrentDeploy(t *testing.T) {
 		fake.deployedConfigs[2].Name,
 	})
 	assert.Empty(t, fake.deployedConfigs[0].MacAddress)
+	assert.Len(t, fake.preflightConfigs, 3, "the whole batch is preflighted before any VM is created")
 	assert.Equal(t, 1, fake.closeCalls)
+
+	fake = &fakeVSphereDeployer{preflightErr: errors.New(`preflight: datastore "fast-ds" has 1.0 GiB free`)}
+	err = deployGenericVMOnVSphere("worker", "esxi.local", 8192, 4, 50, 100, "", "fast-ds", "vl999", vsphereDiskOptions{Provisioning: vsphere.DiskProvisioningThick}, vspherePlacementOptions{}, nil, vsphereVMOptions{}, false, 2, 3, 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "fast-ds")
+	assert.Empty(t, fake.deployedConfigs)
+}
+
+func TestDeployVMOnVSphereAntiAffinity(t *testing.T) {
+	testutil.Swap(t, &vmlifecycle.GetVSphereHostFn, func() (string, error) { return "vcenter.local", nil })
+	testutil.Swap(t, &vmlifecycle.GetVSphereCredsFn, func() (string, string, string, error) { return "vcenter.local", "user", "pass", nil })
+	fake := &fakeVSphereDeployer{spreadHosts: []string{"esxi-01", "esxi-02", "esxi-03"}}
+	testutil.Swap(t, &newVSphereDeployerFn, func(host, username, password string) (vsphereVMDeployer, error) { return fake, nil })
+	args := []string{"--provider", "vsphere", "--name", "worker", "--datastore", "fast-ds", "--network", "vl999", "--skip-ip-check", "--skip-templates"}
+
+	_, err := testutil.ExecuteCommand(newDeployVMCommand(), append(args, "--node-count", "3", "--anti-affinity", "--folder", "k8s")...)
+	require.NoError(t, err)
+	require.Len(t, fake.deployedConfigs, 3)
+	for i, host := range []string{"esxi-01", "esxi-02", "esxi-03"} {
+		assert.Equal(t, host, fake.deployedConfigs[i].Host, "one VM per host")
+		assert.Equal(t, "k8s", fake.deployedConfigs[i].Folder)
+	}
+	assert.Equal(t, map[string][]string{"homeops-worker-anti-affinity": {"worker-0", "worker-1", "worker-2"}}, fake.rules)
+
+	// A failed rule does not fail VMs that are already spread.
+	fake.ruleErr = errors.New("no DRS license")
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), append(args, "--node-count", "3", "--anti-affinity")...)
+	require.NoError(t, err)
+
+	fake = &fakeVSphereDeployer{}
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), append(args, "--host", "esxi-02")...)
+	require.NoError(t, err)
+	require.Len(t, fake.createdConfigs, 1)
+	assert.Equal(t, "esxi-02", fake.createdConfigs[0].Host)
+	assert.Nil(t, fake.rules)
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--node-count", "3", "--anti-affinity", "--host", "esxi-01"}, "--anti-affinity picks a host per VM; drop --host"},
+		{[]string{"--anti-affinity"}, "--anti-affinity needs --node-count of 2 or more"},
+	} {
+		_, err := testutil.ExecuteCommand(newDeployVMCommand(), append(args, tc.args...)...)
+		assert.EqualError(t, err, tc.want)
+	}
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "vsphere", "--name", "k8s", "--node-count", "3", "--anti-affinity", "--skip-ip-check")
+	assert.ErrorContains(t, err, "do not apply to k8s-* VMs")
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "proxmox", "--name", "k8s-0", "--host", "pve2")
+	assert.EqualError(t, err, "--host, --resource-pool, --folder, and --anti-affinity are only supported with --provider vsphere")
 }
 
 func TestDeployK8sVMViaSSHUsesSeam(t *testing.T) {
@@ -731,7 +969,7 @@ func TestDeployK8sVMViaSSHUsesSeam(t *testing.T) {
 		return fake, nil
 	}
 
-	err := deployK8sVMViaSSH("k8s", "esxi.local", 49152, 16, 250, 800, "vl999", false, 2, 0)
+	err := deployK8sVMViaSSH("k8s", "esxi.local", 49152, 16, 250, 800, "vl999", vsphereDiskOptions{}, false, 2, 0)
 	require.NoError(t, err)
 	require.Len(t, fake.configs, 2)
 	assert.Equal(t, []string{"k8s-0", "k8s-1"}, []string{fake.configs[0].Name, fake.configs[1].Name})
@@ -765,7 +1003,7 @@ func TestDeployVMOnVSphereRoutesK8sBaseNamesToSSH(t *testing.T) {
 		return nil, nil
 	}
 
-	err := deployVMOnVSphere("k8s", 49152, 16, 250, 800, "", "fast-ds", "vl999", false, 2, 2, 0)
+	err := deployVMOnVSphere("k8s", 49152, 16, 250, 800, "", "fast-ds", "vl999", vsphereDiskOptions{}, vspherePlacementOptions{}, nil, vsphereVMOptions{}, false, 2, 2, 0)
 	require.NoError(t, err)
 	require.Len(t, fakeSSH.configs, 2)
 }
@@ -826,7 +1064,7 @@ func TestCommandProviderDefaults(t *testing.T) {
 	prepareISOCmd := newPrepareISOCommand()
 	prepareISOProviderFlag := prepareISOCmd.Flags().Lookup("provider")
 	require.NotNil(t, prepareISOProviderFlag)
-	assert.Empty(t, prepareISOProviderFlag.DefValue)
+	assert.Equal(t, "[]", prepareISOProviderFlag.DefValue)
 	assert.Contains(t, prepareISOProviderFlag.Usage, "hypervisors.default")
 	assert.Contains(t, prepareISOCmd.Long, "Upload the ISO to Proxmox storage, TrueNAS storage, or a vSphere datastore")
 }
@@ -867,18 +1105,51 @@ func TestRootVMLifecycleAliasReturnsManageVMGuidance(t *testing.T) {
 	assert.Contains(t, err.Error(), "--provider truenas")
 }
 
+// dryRunTrueNASManager serves a TrueNAS deploy dry run from a fake manager
+// and returns it with the printed plan.
+func dryRunTrueNASManager(t *testing.T) (*fakeTrueNASVMManager, *bytes.Buffer) {
+	t.Helper()
+	manager := &fakeTrueNASVMManager{}
+	var stdout bytes.Buffer
+	testutil.Swap(t, &vmlifecycle.NewTrueNASVMManagerFn, func(string, string, int, bool) vmlifecycle.TrueNASVMManager { return manager })
+	testutil.Swap(t, &spinWithProgressFn, func(_ string, fn func(func(string)) error) error { return fn(func(string) {}) })
+	testutil.Swap(t, &newTrueNASSSHClientFn, func(ssh.SSHConfig) trueNASSSHClient { return &fakeTrueNASSSHClient{exists: true, size: 1 << 20} })
+	testutil.Swap[io.Writer](t, &deployVMStdout, &stdout)
+	t.Setenv(constants.EnvTrueNASHost, "nas.example.test")
+	t.Setenv(constants.EnvTrueNASAPIKey, "api-key-placeholder")
+	t.Setenv(constants.EnvSPICEPassword, "spice-placeholder")
+	return manager, &stdout
+}
+
+func TestDeployVMDryRunPrintsThePlanWithoutDeploying(t *testing.T) {
+	manager, stdout := dryRunTrueNASManager(t)
+	require.NoError(t, deployVMWithPatternDryRun(context.Background(), "app01", "flashstor/VM", 8192, 4, 40, 100, "", factoryISOURL, false, false, false, false, false, false, false, false, truenas.CPUPlacement{}, truenas.ZVolOptions{}, nil, trueNASIPWait{Timeout: time.Minute}, true))
+	assert.Empty(t, manager.deployed)
+	require.NotNil(t, manager.dryRun, "the deploy is walked with mutating calls recorded")
+	assert.Equal(t, 1, manager.connectCalls, "reads still go to the NAS")
+	assert.Equal(t, "Dry run: deploying VM 'app01' on TrueNAS would:\n"+
+		"  Download "+factoryISOURL+" to the NAS as /mnt/flashstor/ISO/metal-amd64-37656798-v1.9.5.iso\n"+
+		"  Create VM app01:\n"+
+		"      {\n"+
+		"        \"name\": \"app01\"\n"+
+		"      }\n"+
+		"  Wait up to 1m0s for the node's IP\n"+
+		"Nothing was changed.\n", stdout.String())
+}
+
 func TestDeployDryRunPaths(t *testing.T) {
-	require.NoError(t, deployVMWithPatternDryRun("app01", "flashstor/VM", 8192, 4, 40, 100, "", false, true, true))
+	dryRunTrueNASManager(t)
+	require.NoError(t, deployVMWithPatternDryRun(context.Background(), "app01", "flashstor/VM", 8192, 4, 40, 100, "", "", false, true, false, true, false, false, false, false, truenas.CPUPlacement{}, truenas.ZVolOptions{}, nil, trueNASIPWait{}, true))
 	require.NoError(t, deployVMOnProxmoxDryRun("k8s-0", 0, 0, 0, 0, true, 1, 1, 0, true))
 	require.NoError(t, deployVMOnProxmoxDryRun("worker01", 8192, 4, 40, 100, false, 1, 1, 0, true))
 	require.NoError(t, deployVMOnProxmoxDryRun("k8s", 0, 0, 0, 0, false, 2, 3, 0, true))
-	require.NoError(t, deployVMOnVSphereDryRun("worker", 8192, 4, 40, 100, "00:11:22:33:44:55", "fast-ds", "vl999", true, 2, 1, 0, true))
-	require.NoError(t, deployVMOnVSphereDryRun("k8s", 49152, 16, 250, 800, "", "fast-ds", "vl999", false, 2, 2, 0, true))
+	require.NoError(t, deployVMOnVSphereDryRun("worker", 8192, 4, 40, 100, "00:11:22:33:44:55", "fast-ds", "vl999", vsphereDiskOptions{}, vspherePlacementOptions{}, nil, vsphereVMOptions{}, true, 2, 1, 0, true))
+	require.NoError(t, deployVMOnVSphereDryRun("k8s", 49152, 16, 250, 800, "", "fast-ds", "vl999", vsphereDiskOptions{}, vspherePlacementOptions{}, nil, vsphereVMOptions{}, false, 2, 2, 0, true))
 }
 
 func TestDryRunSummaryBuilders(t *testing.T) {
 	t.Run("truenas summary includes optional fields", func(t *testing.T) {
-		summary := buildTrueNASDryRunSummary("app01", "flashstor/VM", 8192, 4, 40, 100, "00:11:22:33:44:55", true)
+		summary := buildTrueNASDryRunSummary("app01", "flashstor/VM", 8192, 4, 40, 100, "00:11:22:33:44:55", true, false, true, truenas.CPUPlacement{})
 		assert.Equal(t, "TrueNAS", summary.Provider)
 		assert.Equal(t, []string{"app01"}, summary.VMNames)
 		assert.Contains(t, summary.Lines, "Pool: flashstor/VM")
@@ -887,7 +1158,7 @@ func TestDryRunSummaryBuilders(t *testing.T) {
 	})
 
 	t.Run("vsphere batch summary includes offset and concurrency", func(t *testing.T) {
-		summary, err := buildVSphereDryRunSummary("worker", 8192, 4, 40, 100, "", "fast-ds", "vl999", 2, 3, 4)
+		summary, err := buildVSphereDryRunSummary("worker", 8192, 4, 40, 100, "", "fast-ds", "vl999", vsphereDiskOptions{}, vspherePlacementOptions{}, nil, vsphereVMOptions{}, 2, 3, 4)
 		require.NoError(t, err)
 		assert.Equal(t, "vSphere/ESXi", summary.Provider)
 		assert.Equal(t, []string{"worker-4", "worker-5", "worker-6"}, summary.VMNames)
@@ -895,6 +1166,49 @@ func TestDryRunSummaryBuilders(t *testing.T) {
 		assert.Contains(t, summary.Lines, "Node Count: 3")
 		assert.Contains(t, summary.Lines, "Start Index: 4")
 		assert.Contains(t, summary.Lines, "Concurrent Deployments: 2")
+		assert.Contains(t, summary.Lines, "Disks: boot 40 GB on fast-ds (thin), openebs 100 GB on fast-ds (thin)")
+		assert.Contains(t, summary.Lines, "Disk Controller: nvme (install disk /dev/nvme0n1)")
+
+		disks := vsphereDiskOptions{OpenEBSProvisioning: vsphere.DiskProvisioningEagerZeroedThick, Controller: vsphere.DiskControllerLSILogic}
+		summary, err = buildVSphereDryRunSummary("worker", 8192, 4, 40, 100, "", "fast-ds", "vl999", disks, vspherePlacementOptions{}, nil, vsphereVMOptions{}, 2, 3, 4)
+		require.NoError(t, err)
+		assert.Contains(t, summary.Lines, "Disks: boot 40 GB on fast-ds (thin), openebs 100 GB on fast-ds (eagerZeroedThick)")
+		assert.Contains(t, summary.Lines, "Disk Controller: lsilogic (install disk /dev/sda)")
+	})
+
+	t.Run("vsphere summary shows host placement", func(t *testing.T) {
+		placement := vspherePlacementOptions{ResourcePool: "DC0_C0/Resources", Folder: "k8s", AntiAffinity: true}
+		summary, err := buildVSphereDryRunSummary("worker", 8192, 4, 40, 100, "", "fast-ds", "vl999", vsphereDiskOptions{}, placement, nil, vsphereVMOptions{}, 3, 3, 0)
+		require.NoError(t, err)
+		assert.Contains(t, summary.Lines, "Resource Pool: DC0_C0/Resources")
+		assert.Contains(t, summary.Lines, "VM Folder: k8s")
+		assert.Contains(t, summary.Lines, "Anti-Affinity: one VM per host, plus a DRS rule under vCenter")
+	})
+
+	t.Run("vsphere summary shows VM options", func(t *testing.T) {
+		summary, err := buildVSphereDryRunSummary("worker", 8192, 4, 40, 100, "", "fast-ds", "vl999", vsphereDiskOptions{}, vspherePlacementOptions{}, nil, vsphereVMOptions{}, 2, 1, 0)
+		require.NoError(t, err)
+		assert.Contains(t, summary.Lines, "Firmware: efi")
+		assert.Contains(t, summary.Lines, "UEFI Secure Boot: false")
+
+		options := vsphereVMOptions{Firmware: vsphere.FirmwareBIOS, CPUHotAdd: true, ExtraConfig: map[string]string{"guestinfo.role": "worker"}}
+		summary, err = buildVSphereDryRunSummary("worker", 8192, 4, 40, 100, "", "fast-ds", "vl999", vsphereDiskOptions{}, vspherePlacementOptions{}, nil, options, 2, 1, 0)
+		require.NoError(t, err)
+		assert.Contains(t, summary.Lines, "Firmware: bios")
+		assert.NotContains(t, summary.Lines, "UEFI Secure Boot: false")
+		assert.Contains(t, summary.Lines, "CPU Hot-Add: enabled")
+		assert.Contains(t, summary.Lines, `Extra Config: guestinfo.role = "worker"`)
+	})
+
+	t.Run("vsphere summary shows per-disk placement", func(t *testing.T) {
+		disks := vsphereDiskOptions{Provisioning: vsphere.DiskProvisioningEagerZeroedThick, OpenEBSDatastore: "bulk-ds"}
+		summary, err := buildVSphereDryRunSummary("k8s", 49152, 16, 250, 800, "", "fast-ds", "vl999", disks, vspherePlacementOptions{}, nil, vsphereVMOptions{}, 2, 2, 0)
+		require.NoError(t, err)
+		assert.Contains(t, summary.Lines, "Disks: boot 250 GB on local-nvme1 (eagerZeroedThick), openebs 800 GB on bulk-ds (eagerZeroedThick)")
+
+		summary, err = buildVSphereDryRunSummary("k8s-1", 49152, 16, 250, 800, "", "fast-ds", "vl999", vsphereDiskOptions{BootDatastore: "local-nvme2"}, vspherePlacementOptions{}, nil, vsphereVMOptions{}, 2, 1, 0)
+		require.NoError(t, err)
+		assert.Contains(t, summary.Lines, "Disks: boot 250 GB on local-nvme2 (thin), openebs 800 GB on truenas-iscsi (thin)")
 	})
 
 	t.Run("proxmox predefined batch summary includes presets and offset", func(t *testing.T) {
@@ -929,6 +1243,109 @@ func TestDeployVMCommandProviderValidation(t *testing.T) {
 	assert.Contains(t, err.Error(), "unsupported provider: unknown")
 }
 
+func TestDeployVMCommandValidatesDiskFlags(t *testing.T) {
+	_, err := testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "proxmox", "--name", "worker", "--openebs-datastore", "bulk-ds", "--dry-run", "--skip-ip-check")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "only supported with --provider vsphere")
+
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "vsphere", "--name", "worker", "--disk-provisioning", "sparse", "--dry-run", "--skip-ip-check")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid disk provisioning "sparse"`)
+}
+
+func TestDeployVMOnVSphereVMOptions(t *testing.T) {
+	testutil.Swap(t, &vmlifecycle.GetVSphereHostFn, func() (string, error) { return "esxi.local", nil })
+	testutil.Swap(t, &vmlifecycle.GetVSphereCredsFn, func() (string, string, string, error) { return "esxi.local", "user", "pass", nil })
+	deployer := &fakeVSphereDeployer{}
+	testutil.Swap(t, &newVSphereDeployerFn, func(host, username, password string) (vsphereVMDeployer, error) { return deployer, nil })
+	args := []string{"--provider", "vsphere", "--name", "worker", "--datastore", "fast-ds", "--network", "vl999", "--skip-ip-check", "--skip-templates", "--node-count", "2"}
+
+	_, err := testutil.ExecuteCommand(newDeployVMCommand(), append(args, "--secure-boot", "--cpu-hot-add", "--memory-hot-add",
+		"--extra-config", "guestinfo.role=worker", "--extra-config", "sched.mem.min=0")...)
+	require.NoError(t, err)
+	require.Len(t, deployer.deployedConfigs, 2)
+	config := deployer.deployedConfigs[1]
+	assert.Equal(t, vsphere.FirmwareEFI, config.Firmware)
+	assert.True(t, config.SecureBoot)
+	assert.True(t, config.CPUHotAdd)
+	assert.True(t, config.MemoryHotAdd)
+	assert.Equal(t, map[string]string{"guestinfo.role": "worker", "sched.mem.min": "0"}, config.ExtraConfig)
+
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), append(args, "--firmware", "BIOS")...)
+	require.NoError(t, err)
+	assert.Equal(t, vsphere.FirmwareBIOS, deployer.deployedConfigs[0].Firmware)
+	assert.False(t, deployer.deployedConfigs[0].CPUHotAdd, "hot-add stays off unless asked for")
+
+	deployer.deployedConfigs = nil
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), append(args, "--firmware", "bios", "--secure-boot")...)
+	assert.EqualError(t, err, "--secure-boot needs --firmware efi")
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), append(args, "--extra-config", "guestinfo.role")...)
+	assert.EqualError(t, err, `invalid --extra-config "guestinfo.role": want key=value with a .vmx key such as guestinfo.role`)
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "vsphere", "--name", "k8s-0", "--cpu-hot-add", "--dry-run", "--skip-ip-check")
+	assert.EqualError(t, err, "--firmware, --secure-boot, --cpu-hot-add, --memory-hot-add, and --extra-config do not apply to k8s-* VMs, whose VMX matches the production nodes")
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "proxmox", "--name", "worker", "--firmware", "efi", "--skip-ip-check")
+	assert.EqualError(t, err, "--firmware, --secure-boot, --cpu-hot-add, --memory-hot-add, and --extra-config are only supported with --provider vsphere")
+	assert.Empty(t, deployer.deployedConfigs)
+}
+
+func TestDeployVMOnVSphereDiskControllerAndPerDiskProvisioning(t *testing.T) {
+	testutil.Swap(t, &vmlifecycle.GetVSphereHostFn, func() (string, error) { return "esxi.local", nil })
+	testutil.Swap(t, &vmlifecycle.GetVSphereCredsFn, func() (string, string, string, error) { return "esxi.local", "user", "pass", nil })
+	deployer := &fakeVSphereDeployer{}
+	testutil.Swap(t, &newVSphereDeployerFn, func(host, username, password string) (vsphereVMDeployer, error) { return deployer, nil })
+	var installDisk string
+	testutil.Swap(t, &writeVSphereNodeTemplatesFn, func(_ io.Writer, _, _ []string, disk string) error {
+		installDisk = disk
+		return nil
+	})
+	args := []string{"--provider", "vsphere", "--name", "worker", "--datastore", "fast-ds", "--network", "vl999", "--skip-ip-check", "--node-count", "2"}
+
+	_, err := testutil.ExecuteCommand(newDeployVMCommand(), append(args, "--disk-controller", "PVSCSI", "--disk-provisioning", "boot=thin,openebs=eagerZeroedThick")...)
+	require.NoError(t, err)
+	require.Len(t, deployer.deployedConfigs, 2)
+	config := deployer.deployedConfigs[1]
+	assert.Equal(t, vsphere.DiskControllerPVSCSI, config.DiskController)
+	assert.Equal(t, vsphere.DiskProvisioningThin, config.BootDiskProvisioning())
+	assert.Equal(t, vsphere.DiskProvisioningEagerZeroedThick, config.OpenEBSDiskProvisioning())
+	assert.Equal(t, "/dev/sda", installDisk, "templates install to the first SCSI disk")
+
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), args...)
+	require.NoError(t, err)
+	assert.Equal(t, "/dev/nvme0n1", installDisk)
+
+	deployer.deployedConfigs = nil
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), append(args, "--disk-controller", "ide")...)
+	assert.EqualError(t, err, `invalid disk controller "ide" (valid: nvme, pvscsi, lsilogic)`)
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), append(args, "--disk-provisioning", "ceph=thick")...)
+	assert.EqualError(t, err, `invalid --disk-provisioning "ceph=thick": the VM has no disk "ceph" (disks: boot, openebs)`)
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "vsphere", "--name", "k8s-0", "--disk-controller", "lsilogic", "--dry-run", "--skip-ip-check")
+	assert.EqualError(t, err, "--disk-controller lsilogic does not apply to k8s-* VMs, whose VMX attaches both disks to NVMe controllers")
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "truenas", "--name", "app01", "--disk-controller", "nvme", "--skip-ip-check")
+	assert.EqualError(t, err, "--disk-provisioning, --disk-controller, --boot-datastore, and --openebs-datastore are only supported with --provider vsphere")
+	assert.Empty(t, deployer.deployedConfigs)
+}
+
+func TestDeployVMCommandValidatesEveryDerivedNameUpFront(t *testing.T) {
+	defer versionconfig.SetForTesting(&versionconfig.Config{
+		Hypervisors: versionconfig.HypervisorsConfig{
+			Proxmox: versionconfig.ProxmoxConfig{Naming: versionconfig.NamingPolicy{Pattern: `^k8s-[0-2]$`}},
+		},
+	})()
+	testutil.Swap(t, &vmlifecycle.GetProxmoxCredentialsFn, func() (string, string, string, string, error) {
+		t.Fatal("no provider call may happen before every VM name is validated")
+		return "", "", "", "", nil
+	})
+
+	_, err := testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "proxmox", "--name", "k8s", "--node-count", "3", "--start-index", "1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "VM name 'k8s-3' violates the proxmox naming policy")
+	assert.NotContains(t, err.Error(), "'k8s-2'")
+
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "truenas", "--name", "k8s-0", "--pool", "flashstor/VM", "--dry-run")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "suggested: 'k8s_0'")
+}
+
 func TestPrepareISOWithProviderDispatch(t *testing.T) {
 	oldTrueNAS := prepareISOForTrueNASFn
 	oldProxmox := prepareISOForProxmoxFn
@@ -996,7 +1413,7 @@ func TestPrepareISOForTarget(t *testing.T) {
 		location:       "/tmp/test.iso",
 		deployCommand:  "homeops-cli talos deploy-vm --provider test",
 		summaryMessage: "Uploaded to test provider",
-		uploadISO: func(info *internaltalos.ISOInfo) error {
+		uploadISO: func(info *internaltalos.ISOInfo, _ func(string)) error {
 			uploadedURL = info.URL
 			return nil
 		},
@@ -1014,12 +1431,12 @@ func TestPrepareISOProviderTargets(t *testing.T) {
 	oldPrepareTarget := prepareISOForTargetFn
 	oldSecret := vmlifecycle.ResolveSecretKeyFn
 	oldDownloader := newISODownloaderFn
-	oldUploadVSphere := uploadISOToVSphereFn
+	oldUploadVSphere := uploadISOFileToVSphereFn
 	t.Cleanup(func() {
 		prepareISOForTargetFn = oldPrepareTarget
 		vmlifecycle.ResolveSecretKeyFn = oldSecret
 		newISODownloaderFn = oldDownloader
-		uploadISOToVSphereFn = oldUploadVSphere
+		uploadISOFileToVSphereFn = oldUploadVSphere
 	})
 
 	t.Run("truenas target configures downloader", func(t *testing.T) {
@@ -1032,7 +1449,7 @@ func TestPrepareISOProviderTargets(t *testing.T) {
 		prepareISOForTargetFn = func(target isoPreparationTarget) error {
 			assert.Equal(t, "TrueNAS", target.providerName)
 			assert.Equal(t, "metal", target.platform)
-			return target.uploadISO(&internaltalos.ISOInfo{URL: "https://example.com/metal.iso"})
+			return target.uploadISO(&internaltalos.ISOInfo{URL: "https://example.com/metal.iso"}, func(string) {})
 		}
 
 		require.NoError(t, prepareISOForTrueNAS())
@@ -1041,6 +1458,11 @@ func TestPrepareISOProviderTargets(t *testing.T) {
 		assert.Equal(t, "root", fakeDownloader.configs[0].TrueNASUsername)
 		assert.Equal(t, "https://example.com/metal.iso", fakeDownloader.configs[0].ISOURL)
 		assert.Equal(t, filepath.Base("/mnt/flashstor/ISO/metal-amd64.iso"), fakeDownloader.configs[0].ISOFilename)
+		assert.False(t, fakeDownloader.configs[0].Resume)
+
+		testutil.Swap(t, &prepareISOResume, true)
+		require.NoError(t, prepareISOForTrueNAS())
+		assert.True(t, fakeDownloader.configs[1].Resume, "--resume continues the partial ISO on the NAS")
 	})
 
 	t.Run("proxmox target metadata is stable", func(t *testing.T) {
@@ -1055,19 +1477,22 @@ func TestPrepareISOProviderTargets(t *testing.T) {
 	})
 
 	t.Run("vsphere target uploads via seam", func(t *testing.T) {
-		var uploadedURL string
-		uploadISOToVSphereFn = func(url string) error {
-			uploadedURL = url
+		var uploadedPath string
+		var uploadedDest vSphereISODest
+		uploadISOFileToVSphereFn = func(path string, _ iso.Digest, dest vSphereISODest, _ func(string)) error {
+			uploadedPath, uploadedDest = path, dest
 			return nil
 		}
 		prepareISOForTargetFn = func(target isoPreparationTarget) error {
 			assert.Equal(t, "vSphere", target.providerName)
 			assert.Equal(t, "nocloud", target.platform)
-			return target.uploadISO(&internaltalos.ISOInfo{URL: "https://example.com/nocloud.iso"})
+			assert.Nil(t, target.uploadISO, "vSphere uploads the run's shared local copy")
+			return target.uploadLocal("/tmp/talos.iso", iso.Digest{}, func(string) {})
 		}
 
 		require.NoError(t, prepareISOForVSphere())
-		assert.Equal(t, "https://example.com/nocloud.iso", uploadedURL)
+		assert.Equal(t, "/tmp/talos.iso", uploadedPath)
+		assert.Equal(t, vSphereISODest{datastore: vsphere.DefaultISODatastore, file: vsphere.DefaultISOFilename}, uploadedDest)
 	})
 }
 
@@ -1207,7 +1632,7 @@ func TestDeployVMWithPatternRejectsInvalidInputBeforeSideEffects(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			err := deployVMWithPattern(tc.vmName, tc.pool, tc.memory, tc.vcpus, tc.diskSize, tc.openebsSize, "", false, false)
+			err := deployVMWithPattern(context.Background(), tc.vmName, tc.pool, tc.memory, tc.vcpus, tc.diskSize, tc.openebsSize, "", "", false, false, false, true, false, false, false, false, truenas.CPUPlacement{}, truenas.ZVolOptions{}, nil, trueNASIPWait{})
 
 			require.Error(t, err)
 			assert.Contains(t, err.Error(), tc.want)
@@ -1235,19 +1660,21 @@ func TestDeployVMWithPatternUsesPreparedISOAndDeploysViaSeams(t *testing.T) {
 		}
 		return ""
 	})
-	testutil.Swap(t, &spinWithFuncFn, func(title string, fn func() error) error {
+	testutil.Swap(t, &spinWithProgressFn, func(title string, fn func(func(string)) error) error {
 		assert.Equal(t, "Deploying VM app01", title)
-		return fn()
+		return fn(func(string) {})
 	})
-	testutil.Swap(t, &workingDirectoryFn, func() string { return "." })
+	testutil.Swap(t, &repoRootFn, func() (string, error) { return ".", nil })
 	t.Setenv(constants.EnvTrueNASHost, "nas.example.test")
 	t.Setenv(constants.EnvTrueNASAPIKey, "api-key-placeholder")
 	t.Setenv(constants.EnvSPICEPassword, "spice-placeholder")
 	t.Setenv("NETWORK_BRIDGE", "br-test")
+	testutil.Swap(t, &metrics.Spans, metrics.NewPerformanceCollector())
 
-	err := deployVMWithPattern("app01", "flashstor", 8192, 4, 40, 100, "00:11:22:33:44:55", true, false)
+	err := deployVMWithPattern(context.Background(), "app01", "flashstor", 8192, 4, 40, 100, "00:11:22:33:44:55", "", true, false, false, true, false, false, false, false, truenas.CPUPlacement{}, truenas.ZVolOptions{}, nil, trueNASIPWait{})
 
 	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"iso", "connect", "deploy"}, metrics.Spans.GetOperationNames(), "each deploy phase is timed")
 	assert.Equal(t, 1, manager.connectCalls)
 	assert.Equal(t, 1, manager.closeCalls)
 	require.Len(t, manager.deployed, 1)
@@ -1261,6 +1688,154 @@ func TestDeployVMWithPatternUsesPreparedISOAndDeploysViaSeams(t *testing.T) {
 	assert.True(t, got.UseSpice)
 	assert.True(t, got.CustomISO)
 	assert.True(t, got.SkipZVolCreate)
+	assert.Nil(t, got.SerialLog)
+}
+
+func TestDeployVMWithPatternSerialLog(t *testing.T) {
+	fakeSSH := &fakeTrueNASSSHClient{exists: true, size: 4096}
+	testutil.Swap(t, &newTrueNASSSHClientFn, func(ssh.SSHConfig) trueNASSSHClient { return fakeSSH })
+	manager := &fakeTrueNASVMManager{}
+	testutil.Swap(t, &vmlifecycle.NewTrueNASVMManagerFn, func(string, string, int, bool) vmlifecycle.TrueNASVMManager { return manager })
+	testutil.Swap(t, &vmlifecycle.ResolveSecretKeyFn, func(string) string { return "nas-admin" })
+	testutil.Swap(t, &spinWithProgressFn, func(_ string, fn func(func(string)) error) error { return fn(func(string) {}) })
+	testutil.Swap(t, &repoRootFn, func() (string, error) { return ".", nil })
+	t.Setenv(constants.EnvTrueNASHost, "nas.example.test")
+	t.Setenv(constants.EnvTrueNASAPIKey, "api-key-placeholder")
+	t.Setenv(constants.EnvSPICEPassword, "spice-placeholder")
+
+	manager.version = "TrueNAS-SCALE-23.10.2"
+	err := deployVMWithPattern(context.Background(), "app01", "flashstor/VM", 8192, 4, 40, 100, "", "", true, false, true, true, false, false, false, false, truenas.CPUPlacement{}, truenas.ZVolOptions{}, nil, trueNASIPWait{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "24.04 or newer")
+	assert.Empty(t, manager.deployed, "an unsupported NAS must fail before anything is created")
+
+	manager.version = "TrueNAS-SCALE-24.10.2"
+	require.NoError(t, deployVMWithPattern(context.Background(), "app01", "flashstor/VM", 8192, 4, 40, 100, "", "", true, false, true, true, false, false, false, false, truenas.CPUPlacement{}, truenas.ZVolOptions{}, nil, trueNASIPWait{}))
+	require.Len(t, manager.deployed, 1)
+	require.NotNil(t, manager.deployed[0].SerialLog)
+	assert.Equal(t, "/mnt/flashstor/vm-logs/app01.log", manager.deployed[0].SerialLog.Path)
+	assert.Equal(t, []string{"sudo mkdir -p '/mnt/flashstor/vm-logs'"}, fakeSSH.commands)
+
+	summary := buildTrueNASDryRunSummary("app01", "flashstor/VM", 8192, 4, 40, 100, "", false, true, true, truenas.CPUPlacement{})
+	assert.Contains(t, summary.Lines, "Serial Log: /mnt/flashstor/vm-logs/app01.log (requires TrueNAS SCALE 24.04+)")
+}
+
+func TestDeployVMSpecFlagsOverrideTheSpec(t *testing.T) {
+	spec, err := truenas.ParseVMSpec([]byte(`
+name: storage_0
+memory_mb: 32768
+vcpus: 8
+pool: tank
+disks:
+  - {zvol: tank/VM/storage_0-boot, size_gb: 64}
+  - {zvol: tank/VM/storage_0-openebs, size_gb: 500}
+nics:
+  - {bridge: br0, mac: "00:0c:29:00:00:01"}
+  - {bridge: br-storage}
+`))
+	require.NoError(t, err)
+	cmd := newDeployVMCommand()
+	require.NoError(t, cmd.ParseFlags([]string{"--vcpus", "12", "--openebs-size", "750", "--mac-address", "00:0c:29:00:00:99"}))
+
+	name, pool, memory, vcpus := "", "flashstor/VM", 8192, 12
+	require.NoError(t, applyVMSpecFlags(cmd, spec, &name, &pool, &memory, &vcpus, 250, 750, "00:0c:29:00:00:99"))
+	assert.Equal(t, "storage_0", name)
+	assert.Equal(t, "tank", pool)
+	assert.Equal(t, 32768, memory, "the spec beats homeops.yaml defaults")
+	assert.Equal(t, 12, vcpus, "a flag beats the spec")
+	assert.Equal(t, 64, spec.Disks[0].SizeGB)
+	assert.Equal(t, 750, spec.Disks[1].SizeGB)
+	assert.Equal(t, "00:0c:29:00:00:99", spec.NICs[0].MAC)
+
+	lines := vmSpecDryRunLines(buildTrueNASDryRunSummary(name, pool, memory, vcpus, 250, 750, "", false, false, false, truenas.CPUPlacement{}).Lines, spec)
+	assert.NotContains(t, lines, "Boot Disk: 250 GB")
+	assert.Contains(t, lines, "Disk disk1: tank/VM/storage_0-openebs (750 GB, order 1004)")
+	assert.Contains(t, lines, "NIC 1: VIRTIO on br-storage (order 1021)")
+
+	cmd = newDeployVMCommand()
+	require.NoError(t, cmd.ParseFlags([]string{"--disk-size", "0"}))
+	require.ErrorContains(t, applyVMSpecFlags(cmd, spec, &name, &pool, &memory, &vcpus, 0, 750, ""), "disks[0].size_gb: must be greater than 0")
+
+	specPath := filepath.Join(t.TempDir(), "vm.yaml")
+	require.NoError(t, os.WriteFile(specPath, []byte("name: k8s-0\n"), 0o600))
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "proxmox", "--spec", specPath, "--dry-run", "--skip-ip-check")
+	require.ErrorContains(t, err, "--spec is only supported with --provider truenas")
+}
+
+func TestDeployVMDataDiskFlagsBuildTheDiskLayout(t *testing.T) {
+	cmd := newDeployVMCommand()
+	require.NoError(t, cmd.ParseFlags([]string{"--data-disk", "name=ceph,size=2000,zvol=tank/VM/k8s-0-ceph", "--data-disk", "name=scratch,size=50"}))
+	dataDisks, err := cmd.Flags().GetStringArray("data-disk")
+	require.NoError(t, err)
+
+	spec, err := applyDataDiskFlags(cmd, nil, "k8s-0", "flashstor/VM", 250, 1000, dataDisks)
+	require.NoError(t, err)
+	lines := vmSpecDryRunLines(buildTrueNASDryRunSummary("k8s-0", "flashstor/VM", 8192, 4, 250, 1000, "", false, false, false, truenas.CPUPlacement{}).Lines, spec)
+	assert.NotContains(t, lines, "OpenEBS Disk: 1000 GB", "the homeops.yaml OpenEBS default is not attached")
+	assert.Contains(t, lines, "Disk boot: flashstor/VM/k8s-0-boot (250 GB, order 1001)")
+	assert.Contains(t, lines, "Disk ceph: tank/VM/k8s-0-ceph (2000 GB, order 1004)")
+	assert.Contains(t, lines, "Disk scratch: flashstor/VM/k8s-0-scratch (50 GB, order 1012)")
+
+	cmd = newDeployVMCommand()
+	require.NoError(t, cmd.ParseFlags([]string{"--openebs-size", "500", "--data-disk", "name=ceph,size=2000"}))
+	spec, err = applyDataDiskFlags(cmd, &truenas.VMSpec{Name: "k8s-0"}, "k8s-0", "flashstor", 250, 500, []string{"name=ceph,size=2000"})
+	require.NoError(t, err)
+	assert.Equal(t, "k8s-0", spec.Name, "the --spec is kept")
+	require.Len(t, spec.Disks, 3)
+	assert.Equal(t, truenas.VMSpecDisk{Name: "openebs", ZVol: "flashstor/VM/k8s-0-openebs", SizeGB: 500}, spec.Disks[1], "--openebs-size is sugar for the first data disk")
+
+	_, err = applyDataDiskFlags(cmd, &truenas.VMSpec{Disks: []truenas.VMSpecDisk{{ZVol: "a/b", SizeGB: 1}}}, "k8s-0", "flashstor", 250, 500, []string{"name=ceph,size=2000"})
+	require.ErrorContains(t, err, "--data-disk cannot be combined with a --spec that lists disks")
+	_, err = applyDataDiskFlags(newDeployVMCommand(), nil, "k8s-0", "flashstor", 250, 500, []string{"name=ceph,size=1", "name=ceph,size=2"})
+	require.ErrorContains(t, err, "invalid --data-disk layout: disks[2].name: ceph is already used by disks[1]")
+
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "proxmox", "--name", "k8s-0", "--data-disk", "name=ceph,size=10", "--dry-run", "--skip-ip-check")
+	require.ErrorContains(t, err, "--data-disk is only supported with --provider truenas")
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "proxmox", "--name", "k8s-0", "--sparse=false", "--dry-run", "--skip-ip-check")
+	require.ErrorContains(t, err, "--sparse, --volblocksize, and --compression are only supported with --provider truenas")
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "proxmox", "--name", "k8s-0", "--autostart", "--dry-run", "--skip-ip-check")
+	require.ErrorContains(t, err, "--start and --autostart are only supported with --provider truenas")
+	assert.Equal(t, []string{"ZVol Provisioning: thick (full refreservation)", "ZVol Block Size: 16K", "ZVol Compression (openebs): ZSTD"},
+		zvolOptionDryRunLines(truenas.ZVolOptions{Thick: true, BlockSize: map[string]string{"": "16K"}, Compression: map[string]string{"openebs": "ZSTD"}}))
+}
+
+func TestDeployVMWithPatternCPUPlacement(t *testing.T) {
+	testutil.Swap(t, &newTrueNASSSHClientFn, func(ssh.SSHConfig) trueNASSSHClient { return &fakeTrueNASSSHClient{exists: true, size: 4096} })
+	manager := &fakeTrueNASVMManager{}
+	testutil.Swap(t, &vmlifecycle.NewTrueNASVMManagerFn, func(string, string, int, bool) vmlifecycle.TrueNASVMManager { return manager })
+	testutil.Swap(t, &vmlifecycle.ResolveSecretKeyFn, func(string) string { return "nas-admin" })
+	testutil.Swap(t, &spinWithProgressFn, func(_ string, fn func(func(string)) error) error { return fn(func(string) {}) })
+	testutil.Swap(t, &repoRootFn, func() (string, error) { return ".", nil })
+	t.Setenv(constants.EnvTrueNASHost, "nas.example.test")
+	t.Setenv(constants.EnvTrueNASAPIKey, "api-key-placeholder")
+	t.Setenv(constants.EnvSPICEPassword, "spice-placeholder")
+
+	cpu := truenas.CPUPlacement{CPUSet: "0-3", NodeSet: "0", PinVCPUs: true, CPUMode: truenas.CPUModeHostModel}
+	require.NoError(t, deployVMWithPattern(context.Background(), "app01", "flashstor/VM", 8192, 4, 40, 100, "", "", true, false, false, true, true, true, true, true, cpu, truenas.ZVolOptions{Thick: true, BlockSize: map[string]string{"": "16K"}}, nil, trueNASIPWait{}))
+	require.Len(t, manager.deployed, 1)
+	assert.Equal(t, cpu, manager.deployed[0].CPU)
+	assert.Equal(t, truenas.ZVolOptions{Thick: true, BlockSize: map[string]string{"": "16K"}}, manager.deployed[0].ZVol, "--sparse=false and --volblocksize reach the manager")
+	assert.True(t, manager.deployed[0].KeepOnFailure, "--keep-on-failure reaches the manager")
+	assert.True(t, manager.deployed[0].Update, "--update reaches the manager")
+	assert.True(t, manager.deployed[0].Start && manager.deployed[0].Autostart, "--start and --autostart reach the manager")
+
+	spec := &truenas.VMSpec{Disks: []truenas.VMSpecDisk{{ZVol: "flashstor/VM/app01-a", SizeGB: 10}, {ZVol: "flashstor/VM/app01-b", SizeGB: 20}}}
+	require.NoError(t, deployVMWithPattern(context.Background(), "app01", "flashstor/VM", 8192, 4, 40, 100, "", "", true, false, false, true, false, false, false, false, cpu, truenas.ZVolOptions{}, spec, trueNASIPWait{}))
+	require.Len(t, manager.deployed, 2)
+	assert.Len(t, manager.deployed[1].Disks, 2, "the spec's disks reach the manager")
+
+	summary := buildTrueNASDryRunSummary("app01", "flashstor/VM", 8192, 4, 40, 100, "", false, false, true, cpu)
+	assert.Contains(t, summary.Lines, "CPU Set: 0-3 (pinned: true)")
+	assert.Contains(t, summary.Lines, "NUMA Node Set: 0")
+	assert.Contains(t, summary.Lines, "CPU Mode: HOST-MODEL")
+
+	_, err := testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "proxmox", "--name", "k8s-0", "--cpuset", "0-3", "--dry-run", "--skip-ip-check")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "only supported with --provider truenas")
+
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "truenas", "--name", "app01", "--vcpus", "4", "--cpuset", "0-1", "--pin-vcpus", "--dry-run", "--skip-ip-check")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "exactly one CPU per vCPU")
 }
 
 func TestApplyNodeConfigFlows(t *testing.T) {
@@ -1306,7 +1881,12 @@ func TestApplyNodeConfigFlows(t *testing.T) {
 			return nil, nil
 		}
 
-		require.NoError(t, applyNodeConfig("10.0.0.30", "auto", true))
+		testutil.Swap(t, &talosDryRunApplyConfigFn, func(nodeIP, mode, config string) ([]byte, error) {
+			assert.Equal(t, "machine:\n  token: resolved\n", config)
+			return []byte("Config diff:\n"), nil
+		})
+
+		require.NoError(t, applyNodeConfig(io.Discard, "10.0.0.30", "auto", true, false))
 		assert.Equal(t, 2, injectCalls)
 		assert.Equal(t, 1, authCalls)
 	})
@@ -1325,7 +1905,7 @@ func TestApplyNodeConfigFlows(t *testing.T) {
 			return []byte("ok"), nil
 		}
 
-		require.NoError(t, applyNodeConfig("10.0.0.30", "interactive", false))
+		require.NoError(t, applyNodeConfig(io.Discard, "10.0.0.30", "interactive", false, false))
 		assert.Equal(t, "10.0.0.30", appliedNode)
 		assert.Equal(t, "interactive", appliedMode)
 		assert.Contains(t, appliedConfig, "resolved")
@@ -1350,15 +1930,12 @@ func TestTalosUpgradeAndLifecycleFlows(t *testing.T) {
 		talosctlCombinedOutputFn = oldCombined
 	})
 
-	t.Run("upgrade node uses extracted factory image", func(t *testing.T) {
+	t.Run("upgrade node uses the image from its rendered config", func(t *testing.T) {
 		getTalosNodeIPsFn = func() ([]string, error) { return []string{"10.0.0.40"}, nil }
 		chooseTalosNodeFn = func(prompt string, options []string) (string, error) {
 			return "10.0.0.40", nil
 		}
-		getTalosTemplateFn = func(name string) (string, error) {
-			assert.Equal(t, "talos/controlplane.yaml", name)
-			return "machine:\n  install:\n    image: factory.talos.dev/installer/schematic:v1.9.0\n", nil
-		}
+		stubUpgradeNodeImage(t, "factory.talos.dev/installer/schematic:v1.8.0")
 		spinCommandFn = func(title string, command string, args ...string) error {
 			assert.Equal(t, "Upgrading node 10.0.0.40", title)
 			assert.Equal(t, "talosctl", command)
@@ -1366,7 +1943,7 @@ func TestTalosUpgradeAndLifecycleFlows(t *testing.T) {
 			return nil
 		}
 
-		require.NoError(t, upgradeNode("", "powercycle"))
+		require.NoError(t, upgradeNode(context.Background(), "", upgradeNodeOptions{Mode: "powercycle", Snapshot: upgradeSnapshotOptions{Skip: true}}))
 	})
 
 	t.Run("upgrade kubernetes uses first endpoint", func(t *testing.T) {
@@ -1381,7 +1958,7 @@ func TestTalosUpgradeAndLifecycleFlows(t *testing.T) {
 		cleanup := testutil.SetEnv(t, "KUBERNETES_VERSION", "v1.32.0")
 		defer cleanup()
 
-		require.NoError(t, upgradeK8s())
+		require.NoError(t, upgradeK8s(context.Background(), upgradeSnapshotOptions{Skip: true}))
 	})
 
 	t.Run("reboot reset and shutdown use talosctl combined output", func(t *testing.T) {
@@ -1475,7 +2052,7 @@ func TestTalosCommandWrappersAndEdges(t *testing.T) {
 		cleanup := testutil.SetEnv(t, "KUBERNETES_VERSION", "v1.33.0")
 		defer cleanup()
 
-		_, err := testutil.ExecuteCommand(newUpgradeK8sCommand())
+		_, err := testutil.ExecuteCommand(newUpgradeK8sCommand(), "--skip-snapshot")
 		require.NoError(t, err)
 	})
 
@@ -1502,13 +2079,13 @@ func TestTalosCommandWrappersAndEdges(t *testing.T) {
 }
 
 func TestKubeconfigFlows(t *testing.T) {
-	oldWorkdir := workingDirectoryFn
+	oldWorkdir := repoRootFn
 	oldGen := generateKubeconfigFn
 	oldPush := pushKubeconfigFn
 	oldPull := pullKubeconfigFn
 	oldTalosctlOutput := talosctlOutputFn
 	t.Cleanup(func() {
-		workingDirectoryFn = oldWorkdir
+		repoRootFn = oldWorkdir
 		generateKubeconfigFn = oldGen
 		pushKubeconfigFn = oldPush
 		pullKubeconfigFn = oldPull
@@ -1516,7 +2093,7 @@ func TestKubeconfigFlows(t *testing.T) {
 	})
 
 	workdir := t.TempDir()
-	workingDirectoryFn = func() string { return workdir }
+	repoRootFn = func() (string, error) { return workdir, nil }
 	talosctlOutputFn = func(name string, args ...string) ([]byte, error) {
 		return []byte(`{"endpoints":["10.0.0.200"],"nodes":["10.0.0.200"]}`), nil
 	}
@@ -1597,9 +2174,10 @@ func TestPromptDeployVMOptions(t *testing.T) {
 			memory, vcpus, diskSize, openebsSize int
 			nodeCount, concurrent, startIndex    int
 			generateISO, dryRun                  bool
+			disks                                vsphereDiskOptions
 		)
 
-		require.NoError(t, promptDeployVMOptions(&name, &provider, &memory, &vcpus, &diskSize, &openebsSize, &generateISO, &dryRun, &datastore, &network, &nodeCount, &concurrent, &startIndex))
+		require.NoError(t, promptDeployVMOptions(&name, &provider, &memory, &vcpus, &diskSize, &openebsSize, &generateISO, &dryRun, &datastore, &network, &disks, &nodeCount, &concurrent, &startIndex))
 		assert.Equal(t, "k8s", name)
 		assert.Equal(t, "proxmox", provider)
 		assert.Equal(t, 16, vcpus)
@@ -1617,6 +2195,7 @@ func TestPromptDeployVMOptions(t *testing.T) {
 		chooseResponses := []string{
 			"Custom - Choose your own configuration",
 			"vSphere/ESXi - Deploy to vSphere or ESXi",
+			"eagerZeroedThick - Reserve and zero space at creation",
 			"Yes - Generate custom ISO using schematic.yaml",
 			"Dry-Run - Preview what would be done without creating the VM",
 		}
@@ -1631,6 +2210,8 @@ func TestPromptDeployVMOptions(t *testing.T) {
 			"1200",
 			"fast-ds",
 			"prod-net",
+			"",
+			"bulk-ds",
 		}
 		chooseIdx := 0
 		inputIdx := 0
@@ -1650,9 +2231,10 @@ func TestPromptDeployVMOptions(t *testing.T) {
 			memory, vcpus, diskSize, openebsSize int
 			nodeCount, concurrent, startIndex    int
 			generateISO, dryRun                  bool
+			disks                                vsphereDiskOptions
 		)
 
-		require.NoError(t, promptDeployVMOptions(&name, &provider, &memory, &vcpus, &diskSize, &openebsSize, &generateISO, &dryRun, &datastore, &network, &nodeCount, &concurrent, &startIndex))
+		require.NoError(t, promptDeployVMOptions(&name, &provider, &memory, &vcpus, &diskSize, &openebsSize, &generateISO, &dryRun, &datastore, &network, &disks, &nodeCount, &concurrent, &startIndex))
 		assert.Equal(t, "workers", name)
 		assert.Equal(t, "vsphere", provider)
 		assert.Equal(t, 5, nodeCount)
@@ -1664,6 +2246,7 @@ func TestPromptDeployVMOptions(t *testing.T) {
 		assert.Equal(t, 1200, openebsSize)
 		assert.Equal(t, "fast-ds", datastore)
 		assert.Equal(t, "prod-net", network)
+		assert.Equal(t, vsphereDiskOptions{Provisioning: vsphere.DiskProvisioningEagerZeroedThick, OpenEBSDatastore: "bulk-ds"}, disks)
 		assert.True(t, generateISO)
 		assert.True(t, dryRun)
 	})
@@ -1703,9 +2286,10 @@ func TestPromptDeployVMOptions(t *testing.T) {
 			memory, vcpus, diskSize, openebsSize int
 			nodeCount, concurrent, startIndex    int
 			generateISO, dryRun                  bool
+			disks                                vsphereDiskOptions
 		)
 
-		require.NoError(t, promptDeployVMOptions(&name, &provider, &memory, &vcpus, &diskSize, &openebsSize, &generateISO, &dryRun, &datastore, &network, &nodeCount, &concurrent, &startIndex))
+		require.NoError(t, promptDeployVMOptions(&name, &provider, &memory, &vcpus, &diskSize, &openebsSize, &generateISO, &dryRun, &datastore, &network, &disks, &nodeCount, &concurrent, &startIndex))
 		assert.Equal(t, "workers", name)
 		assert.Equal(t, "proxmox", provider)
 		assert.Equal(t, 4, nodeCount)
@@ -1752,9 +2336,10 @@ func TestPromptDeployVMOptions(t *testing.T) {
 			memory, vcpus, diskSize, openebsSize int
 			nodeCount, concurrent, startIndex    int
 			generateISO, dryRun                  bool
+			disks                                vsphereDiskOptions
 		)
 
-		require.NoError(t, promptDeployVMOptions(&name, &provider, &memory, &vcpus, &diskSize, &openebsSize, &generateISO, &dryRun, &datastore, &network, &nodeCount, &concurrent, &startIndex))
+		require.NoError(t, promptDeployVMOptions(&name, &provider, &memory, &vcpus, &diskSize, &openebsSize, &generateISO, &dryRun, &datastore, &network, &disks, &nodeCount, &concurrent, &startIndex))
 		assert.Equal(t, "solo", name)
 		assert.Equal(t, "proxmox", provider)
 		assert.Equal(t, 1, nodeCount)
@@ -1936,13 +2521,16 @@ func (m *proxmoxVMManagerWithCloseCounterImpl) Close() error {
 }
 
 func TestDownloadISOToTemp(t *testing.T) {
-	oldHTTPGet := httpGetFn
+	t.Setenv("TMPDIR", t.TempDir())
+	testutil.Swap(t, &isoTransferRetryDelay, 0)
+	oldHTTPDo := httpDoFn
 	t.Cleanup(func() {
-		httpGetFn = oldHTTPGet
+		httpDoFn = oldHTTPDo
 	})
+	noStatus := func(string) {}
 
 	t.Run("downloads iso to temp file", func(t *testing.T) {
-		httpGetFn = func(url string) (*http.Response, error) {
+		httpDoFn = func(req *http.Request) (*http.Response, error) {
 			return &http.Response{
 				StatusCode:    http.StatusOK,
 				Body:          io.NopCloser(strings.NewReader("iso-bytes")),
@@ -1950,24 +2538,79 @@ func TestDownloadISOToTemp(t *testing.T) {
 			}, nil
 		}
 
-		path, err := downloadISOToTemp("https://example.com/talos.iso")
+		path, digest, err := downloadISOToTemp("https://example.com/talos.iso", false, noStatus)
 		require.NoError(t, err)
 		t.Cleanup(func() { _ = os.Remove(path) })
 
 		content, err := os.ReadFile(path)
 		require.NoError(t, err)
 		assert.Equal(t, "iso-bytes", string(content))
+		assert.Equal(t, iso.Digest{SHA256: "4bc485f29c8bda3640b8d904070e38e722d7acd9cba16f7a0ea8bedce2528178", Size: 9}, digest)
+	})
+
+	t.Run("rejects a truncated body", func(t *testing.T) {
+		httpDoFn = func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode:    http.StatusOK,
+				Body:          io.NopCloser(strings.NewReader("iso")),
+				ContentLength: int64(len("iso-bytes")),
+			}, nil
+		}
+
+		_, _, err := downloadISOToTemp("https://example.com/truncated.iso", false, noStatus)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "chunk at byte 0 failed (attempt 5 of 5): download truncated: got 3 of 9 bytes (3 bytes kept in ")
+	})
+
+	t.Run("resumes an interrupted download", func(t *testing.T) {
+		content := bytes.Repeat([]byte("talos-iso-bytes!"), 4096)
+		var sent atomic.Int64
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var start int
+			_, _ = fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-", &start)
+			sent.Add(int64(len(content) - start))
+			http.ServeContent(w, r, "talos.iso", time.Time{}, bytes.NewReader(content))
+		}))
+		t.Cleanup(srv.Close)
+
+		// The first run dies once 60% of the ISO has arrived.
+		cut := len(content) * 6 / 10
+		httpDoFn = func(req *http.Request) (*http.Response, error) {
+			if sent.Load() > 0 {
+				return nil, errors.New("interrupted")
+			}
+			resp, err := srv.Client().Do(req)
+			if err == nil {
+				resp.Body = io.NopCloser(io.LimitReader(resp.Body, int64(cut)))
+			}
+			return resp, err
+		}
+		_, _, err := downloadISOToTemp(srv.URL+"/talos.iso", false, noStatus)
+		require.ErrorContains(t, err, fmt.Sprintf("(%d bytes kept in ", cut))
+
+		sent.Store(0)
+		httpDoFn = srv.Client().Do
+		var statuses []string
+		path, digest, err := downloadISOToTemp(srv.URL+"/talos.iso", true, func(s string) { statuses = append(statuses, s) })
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(content)), digest.Size)
+		assert.Equal(t, int64(len(content)-cut), sent.Load(), "the first 60% is not downloaded again")
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, content, data)
+		require.NotEmpty(t, statuses)
+		assert.True(t, strings.HasPrefix(statuses[len(statuses)-1], "downloading: 100% of 0 MB at "), statuses[len(statuses)-1])
 	})
 
 	t.Run("returns http error", func(t *testing.T) {
-		httpGetFn = func(url string) (*http.Response, error) {
+		httpDoFn = func(req *http.Request) (*http.Response, error) {
 			return &http.Response{
 				StatusCode: http.StatusBadGateway,
 				Body:       io.NopCloser(strings.NewReader("bad gateway")),
 			}, nil
 		}
 
-		_, err := downloadISOToTemp("https://example.com/talos.iso")
+		_, _, err := downloadISOToTemp("https://example.com/talos.iso", false, noStatus)
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "HTTP 502")
 	})
@@ -2414,11 +3057,13 @@ type fakeVSphereClient struct {
 	listErr      error
 	infoErr      error
 	uploadErr    error
+	uploadErrs   []error // returned, in order, before uploadErr
 	powerOnErr   error
 	powerOffErr  error
 	deleteErr    error
 	listVMs      []*object.VirtualMachine
 	infoResponse *mo.VirtualMachine
+	fileSizes    map[string]int64
 }
 
 func (f *fakeVSphereClient) Connect(host, username, password string, insecure bool) error {
@@ -2446,8 +3091,16 @@ func (f *fakeVSphereClient) GetVMInfo(vm *object.VirtualMachine) (*mo.VirtualMac
 }
 func (f *fakeVSphereClient) UploadISOToDatastore(localFilePath, datastoreName, remoteFileName string) error {
 	f.uploads = append(f.uploads, localFilePath+"|"+datastoreName+"|"+remoteFileName)
+	if len(f.uploadErrs) > 0 {
+		err := f.uploadErrs[0]
+		f.uploadErrs = f.uploadErrs[1:]
+		return err
+	}
 	return f.uploadErr
 }
+func (f *fakeVSphereClient) DatastoreFileSize(datastoreName, remoteFileName string) (int64, error) {
+	return f.fileSizes["["+datastoreName+"] "+remoteFileName], nil
+}
 func (f *fakeVSphereClient) PowerOnVM(vm *object.VirtualMachine) error {
 	f.poweredOn++
 	return f.powerOnErr
@@ -2470,15 +3123,18 @@ func TestHypervisorPrepareISOFlows(t *testing.T) {
 	oldVSphereFactory := vmlifecycle.NewVSphereClientFn
 	oldVSphereCreds := vmlifecycle.GetVSphereCredsFn
 	oldPrepareTarget := prepareISOForTargetFn
-	oldHTTPGet := httpGetFn
+	oldHTTPDo := httpDoFn
 	t.Cleanup(func() {
 		vmlifecycle.NewProxmoxVMManagerFn = oldProxmoxFactory
 		vmlifecycle.GetProxmoxCredentialsFn = oldProxmoxCreds
 		vmlifecycle.NewVSphereClientFn = oldVSphereFactory
 		vmlifecycle.GetVSphereCredsFn = oldVSphereCreds
 		prepareISOForTargetFn = oldPrepareTarget
-		httpGetFn = oldHTTPGet
+		httpDoFn = oldHTTPDo
 	})
+	t.Setenv("TMPDIR", t.TempDir())
+	testutil.Swap(t, &isoTransferRetryDelay, 0)
+	noStatus := func(string) {}
 
 	t.Run("proxmox upload plumbing", func(t *testing.T) {
 		manager := &fakeProxmoxVMManager{}
@@ -2490,7 +3146,7 @@ func TestHypervisorPrepareISOFlows(t *testing.T) {
 		}
 		prepareISOForTargetFn = func(target isoPreparationTarget) error {
 			assert.Equal(t, "Proxmox", target.providerName)
-			return target.uploadISO(&internaltalos.ISOInfo{URL: "https://example.com/proxmox.iso"})
+			return target.uploadISO(&internaltalos.ISOInfo{URL: "https://example.com/proxmox.iso"}, noStatus)
 		}
 		require.NoError(t, prepareISOForProxmox())
 		require.Len(t, manager.uploads, 1)
@@ -2498,26 +3154,62 @@ func TestHypervisorPrepareISOFlows(t *testing.T) {
 	})
 
 	t.Run("vsphere upload plumbing", func(t *testing.T) {
-		client := &fakeVSphereClient{}
+		client := &fakeVSphereClient{fileSizes: map[string]int64{"[" + vsphere.DefaultISODatastore + "] " + vsphere.DefaultISOFilename: int64(len("iso-bytes"))}}
 		vmlifecycle.GetVSphereCredsFn = func() (string, string, string, error) {
 			return "esxi.local", "root", "secret", nil
 		}
 		vmlifecycle.NewVSphereClientFn = func(host, username, password string, insecure bool) vmlifecycle.VSphereClient {
 			return client
 		}
-		httpGetFn = func(url string) (*http.Response, error) {
+		httpDoFn = func(req *http.Request) (*http.Response, error) {
 			return &http.Response{
 				StatusCode:    http.StatusOK,
 				Body:          io.NopCloser(strings.NewReader("iso-bytes")),
 				ContentLength: int64(len("iso-bytes")),
 			}, nil
 		}
-		require.NoError(t, uploadISOToVSphere("https://example.com/vsphere.iso"))
+		dest := defaultVSphereISODest()
+		path, digest, err := downloadVerifiedISO("https://example.com/vsphere.iso", noStatus)
+		require.NoError(t, err)
+		require.NoError(t, uploadISOFileToVSphere(path, digest, dest, noStatus))
 		assert.Equal(t, 1, client.connectCalls)
 		assert.Equal(t, 1, client.closeCalls)
-		require.Len(t, client.uploads, 1)
+		require.Len(t, client.uploads, 2)
 		assert.Contains(t, client.uploads[0], vsphere.DefaultISODatastore)
 		assert.Contains(t, client.uploads[0], vsphere.DefaultISOFilename)
+		assert.True(t, strings.HasSuffix(client.uploads[1], "|"+vsphere.DefaultISOFilename+".sha256"), client.uploads[1])
+
+		client.uploads = nil
+		client.fileSizes = nil
+		err = uploadISOFileToVSphere(path, digest, dest, noStatus)
+		assert.ErrorContains(t, err, "is 0 bytes, expected 9", "a short datastore copy fails the upload")
+		assert.Len(t, client.uploads, 1, "no checksum file is stored for a short copy")
+
+		client.uploads = nil
+		client.uploadErrs = []error{errors.New("connection reset"), errors.New("connection reset")}
+		client.fileSizes = map[string]int64{"[" + vsphere.DefaultISODatastore + "] " + vsphere.DefaultISOFilename: int64(len("iso-bytes"))}
+		require.NoError(t, uploadISOFileToVSphere(path, digest, dest, noStatus))
+		assert.Len(t, client.uploads, 4, "two failed ISO uploads are retried before the ISO and checksum land")
+	})
+
+	t.Run("vsphere upload aborts on a corrupted temp file", func(t *testing.T) {
+		client := &fakeVSphereClient{}
+		vmlifecycle.NewVSphereClientFn = func(host, username, password string, insecure bool) vmlifecycle.VSphereClient {
+			return client
+		}
+		testutil.Swap(t, &downloadISOToTempFn, func(isoURL string, resume bool, status func(string)) (string, iso.Digest, error) {
+			path, digest, err := downloadISOToTemp(isoURL, resume, status)
+			if err == nil {
+				err = os.WriteFile(path, []byte("iso-bytez"), 0o600)
+			}
+			return path, digest, err
+		})
+		path, _, err := downloadVerifiedISO("https://example.com/vsphere.iso", noStatus)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "refusing to upload: ISO ")
+		assert.Empty(t, path)
+		assert.Contains(t, err.Error(), "expected 4bc485f29c8bda3640b8d904070e38e722d7acd9cba16f7a0ea8bedce2528178 (9 bytes)")
+		assert.Empty(t, client.uploads)
 	})
 }
 
@@ -2525,10 +3217,106 @@ func TestHypervisorPrepareISOFlows(t *testing.T) {
 // provider's flag set in dry-run mode (command-level wiring; the dry-run plan
 // logic itself is covered by TestDeployDryRunPaths).
 func TestDeployVMCommandDryRunFlags(t *testing.T) {
+	testutil.Swap(t, &deployVMIPPreflightFn, func(context.Context, *common.ColorLogger, []string, bool) error { return nil })
 	_, err := testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "proxmox", "--name", "k8s-0", "--dry-run")
 	require.NoError(t, err)
+	dryRunTrueNASManager(t)
 	_, err = testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "truenas", "--name", "app01", "--pool", "flashstor/VM", "--dry-run")
 	require.NoError(t, err)
 	_, err = testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "esxi", "--name", "worker", "--datastore", "fast-ds", "--network", "vl999", "--dry-run")
 	require.NoError(t, err)
 }
+
+func TestDeployVMVerifyReportsDriftWithoutDeploying(t *testing.T) {
+	manager, stdout := dryRunTrueNASManager(t)
+	args := []string{"--provider", "truenas", "--name", "app01", "--pool", "flashstor/VM", "--memory", "8192", "--iso-path", "/mnt/flashstor/ISO/talos.iso", "--verify"}
+	_, err := testutil.ExecuteCommand(newDeployVMCommand(), args...)
+	require.NoError(t, err)
+	assert.Empty(t, manager.deployed)
+	assert.Empty(t, manager.dryRun.Calls, "verifying makes no middleware changes")
+	require.Len(t, manager.verified, 1)
+	assert.Equal(t, 8192, manager.verified[0].Memory)
+	assert.Equal(t, "/mnt/flashstor/ISO/talos.iso", manager.verified[0].TalosISO)
+	assert.Equal(t, "VM app01 matches its expected layout: no drift\n", stdout.String())
+
+	stdout.Reset()
+	manager.drift = []truenas.VMDrift{{Field: "memory", Want: "8192", Live: "4096"}, {Field: "display device", Want: "order 1002", Live: "missing"}}
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), args...)
+	require.EqualError(t, err, "VM app01 has drifted from its expected layout: 2 differences")
+	assert.Contains(t, stdout.String(), "FIELD")
+	assert.Regexp(t, `memory\s+8192\s+4096`, stdout.String())
+	assert.Regexp(t, `display device\s+order 1002\s+missing`, stdout.String())
+
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), append(args, "--dry-run")...)
+	require.EqualError(t, err, "--verify cannot be combined with --dry-run, --update, or --generate-iso")
+}
+
+func TestDeployVMWithPatternAttachesConfigISO(t *testing.T) {
+	cfg := *versionconfig.Get()
+	cfg.Cluster.Nodes = []versionconfig.Node{{Name: "k8s_0", IP: "192.168.122.10", VM: versionconfig.VMProfile{Mac: "00:a0:98:00:00:10"}}}
+	t.Cleanup(versionconfig.SetForTesting(&cfg))
+	fakeSSH := &fakeTrueNASSSHClient{exists: true, size: 4096}
+	testutil.Swap(t, &newTrueNASSSHClientFn, func(ssh.SSHConfig) trueNASSSHClient { return fakeSSH })
+	manager := &fakeTrueNASVMManager{}
+	testutil.Swap(t, &vmlifecycle.NewTrueNASVMManagerFn, func(string, string, int, bool) vmlifecycle.TrueNASVMManager { return manager })
+	testutil.Swap(t, &vmlifecycle.ResolveSecretKeyFn, func(string) string { return "nas-admin" })
+	testutil.Swap(t, &spinWithProgressFn, func(_ string, fn func(func(string)) error) error { return fn(func(string) {}) })
+	testutil.Swap(t, &repoRootFn, func() (string, error) { return ".", nil })
+	testutil.Swap(t, &getTalosTemplateFn, func(name string) (string, error) {
+		assert.Equal(t, "talos/nodes/192.168.122.10.yaml", name)
+		return "machine:\n  type: worker\n", nil
+	})
+	testutil.Swap(t, &renderMachineConfigFromEmbeddedFn, func(base, patch string) ([]byte, error) {
+		assert.Equal(t, "talos/worker.yaml", base)
+		assert.Equal(t, "talos/nodes/192.168.122.10.yaml", patch)
+		return []byte("machine:\n  token: op://vault/talos/token\n"), nil
+	})
+	testutil.Swap(t, &injectSecretsFn, func(rendered string) (string, error) {
+		return strings.Replace(rendered, "op://vault/talos/token", "resolved-token", 1), nil
+	})
+	t.Setenv(constants.EnvTrueNASHost, "nas.example.test")
+	t.Setenv(constants.EnvTrueNASAPIKey, "api-key-placeholder")
+	t.Setenv(constants.EnvSPICEPassword, "spice-placeholder")
+
+	require.NoError(t, deployVMWithPattern(context.Background(), "k8s_0", "flashstor/VM", 8192, 4, 40, 100, "", "", true, false, false, true, false, false, false, false, truenas.CPUPlacement{}, truenas.ZVolOptions{}, nil, trueNASIPWait{}))
+	require.Len(t, manager.deployed, 1)
+	got := manager.deployed[0]
+	configISO := path.Join(path.Dir(cfg.TrueNASISOPath()), "k8s_0-talos-config.iso")
+	assert.Equal(t, configISO, got.ConfigISO)
+	assert.Equal(t, "00:a0:98:00:00:10", got.MacAddress, "the VM takes the MAC the rendered config selects")
+	require.Contains(t, fakeSSH.uploads, configISO)
+	image := fakeSSH.uploads[configISO]
+	assert.Contains(t, string(image[32768:34816]), "metal-iso")
+	assert.Contains(t, string(image), "resolved-token", "the ISO carries the config with secrets resolved")
+	assert.Equal(t, []string{"sudo chmod 600 " + common.ShellQuote(configISO)}, fakeSSH.commands)
+
+	summary := buildTrueNASDryRunSummary("k8s_0", "flashstor/VM", 8192, 4, 40, 100, "", false, false, true, truenas.CPUPlacement{})
+	assert.Contains(t, summary.Lines, "Config ISO: "+configISO+" (machine config for 192.168.122.10, no apply-node step)")
+
+	// Opting out, and VMs that are not cluster nodes, deploy without one.
+	require.NoError(t, deployVMWithPattern(context.Background(), "k8s_0", "flashstor/VM", 8192, 4, 40, 100, "", "", true, false, false, false, false, false, false, false, truenas.CPUPlacement{}, truenas.ZVolOptions{}, nil, trueNASIPWait{}))
+	require.NoError(t, deployVMWithPattern(context.Background(), "scratch", "flashstor/VM", 8192, 4, 40, 100, "", "", true, false, false, true, false, false, false, false, truenas.CPUPlacement{}, truenas.ZVolOptions{}, nil, trueNASIPWait{}))
+	require.Len(t, manager.deployed, 3)
+	assert.Empty(t, manager.deployed[1].ConfigISO)
+	assert.Empty(t, manager.deployed[2].ConfigISO)
+	assert.Len(t, fakeSSH.uploads, 1)
+}
+
+func TestPrepareISOAddsConfigKernelArgForTrueNASOnly(t *testing.T) {
+	schematic := &internaltalos.SchematicConfig{}
+	schematic.Customization.ExtraKernelArgs = []string{"mitigations=off"}
+	addSchematicKernelArgs(schematic, truenas.TalosConfigKernelArg)
+	addSchematicKernelArgs(schematic, truenas.TalosConfigKernelArg)
+	assert.Equal(t, []string{"mitigations=off", "talos.config=metal-iso"}, schematic.Customization.ExtraKernelArgs)
+
+	var targets []isoPreparationTarget
+	testutil.Swap(t, &prepareISOForTargetFn, func(target isoPreparationTarget) error {
+		targets = append(targets, target)
+		return nil
+	})
+	require.NoError(t, prepareISOForTrueNAS())
+	require.NoError(t, prepareISOForVSphere())
+	require.Len(t, targets, 2)
+	assert.Equal(t, []string{truenas.TalosConfigKernelArg}, targets[0].kernelArgs)
+	assert.Empty(t, targets[1].kernelArgs, "nocloud ISOs get their config elsewhere")
+}
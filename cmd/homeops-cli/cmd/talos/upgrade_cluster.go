@@ -0,0 +1,604 @@
+package talos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"homeops-cli/cmd/kubernetes"
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/kubeutil"
+	"homeops-cli/internal/secrets"
+	"homeops-cli/internal/versioncheck"
+)
+
+var (
+	upgradeNowFn       = time.Now
+	upgradeSleepFn     = sleepContext
+	upgradeStatePathFn = func() (string, error) {
+		return secrets.ExpandHome("~/.config/homeops/state/talos-upgrade-cluster.json")
+	}
+	upgradeClusterNodes = getAllNodes
+	upgradeClusterImage = upgradeFactoryImage
+	upgradeClusterNode  = upgradeNodeToImage
+	upgradeNodeHealthFn = func(nodeIP string) error {
+		return spinCommandFn(fmt.Sprintf("Waiting for node %s to report healthy", nodeIP),
+			"talosctl", "--nodes", nodeIP, "health", "--server=false", "--wait-timeout", "10m")
+	}
+	upgradeDrainImpactFn = kubernetes.DrainImpactRisks
+	upgradeKubectlFn     = func(ctx context.Context, args ...string) ([]byte, error) {
+		return common.RunCommandWithContextOutput(ctx, "kubectl", args...)
+	}
+)
+
+const (
+	// upgradeCheckInterval is how often upgrade-cluster re-checks a node's
+	// Ready condition and etcd while waiting on them.
+	upgradeCheckInterval      = 10 * time.Second
+	upgradeDefaultStepTimeout = 15 * time.Minute
+)
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// maintenanceWindow is a daily local-time window; End before Start means the
+// window crosses midnight.
+type maintenanceWindow struct {
+	spec       string
+	start, end time.Duration
+}
+
+// parseMaintenanceWindow parses "HH:MM-HH:MM". An empty spec means no window.
+func parseMaintenanceWindow(spec string) (*maintenanceWindow, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+	from, to, ok := strings.Cut(spec, "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid maintenance window %q: want HH:MM-HH:MM", spec)
+	}
+	start, err := parseClockTime(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maintenance window %q: %w", spec, err)
+	}
+	end, err := parseClockTime(to)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maintenance window %q: %w", spec, err)
+	}
+	if start == end {
+		return nil, fmt.Errorf("invalid maintenance window %q: start and end are equal", spec)
+	}
+	return &maintenanceWindow{spec: spec, start: start, end: end}, nil
+}
+
+func parseClockTime(value string) (time.Duration, error) {
+	parsed, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a HH:MM time", strings.TrimSpace(value))
+	}
+	return time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute, nil
+}
+
+func (w *maintenanceWindow) String() string { return w.spec }
+
+// Contains reports whether t falls inside the window.
+func (w *maintenanceWindow) Contains(t time.Time) bool {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+	if w.start < w.end {
+		return offset >= w.start && offset < w.end
+	}
+	return offset >= w.start || offset < w.end
+}
+
+// NextOpen returns t when the window is open, otherwise the next time it opens.
+func (w *maintenanceWindow) NextOpen(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+	hour, minute := int(w.start/time.Hour), int(w.start%time.Hour/time.Minute)
+	open := time.Date(t.Year(), t.Month(), t.Day(), hour, minute, 0, 0, t.Location())
+	if open.Before(t) {
+		open = time.Date(t.Year(), t.Month(), t.Day()+1, hour, minute, 0, 0, t.Location())
+	}
+	return open
+}
+
+// checkUpgradeWindow refuses a single-node upgrade outside --window.
+func checkUpgradeWindow(spec string, ignore bool) error {
+	window, err := parseMaintenanceWindow(spec)
+	if err != nil || window == nil || ignore {
+		return err
+	}
+	now := upgradeNowFn()
+	if window.Contains(now) {
+		return nil
+	}
+	return fmt.Errorf("outside maintenance window %s (next opens %s); pass --ignore-window to upgrade anyway",
+		window, window.NextOpen(now).Format("2006-01-02 15:04"))
+}
+
+// upgradeRolloutState is the persisted progress of a rolling upgrade, written
+// after every node so --resume continues from the next pending one.
+type upgradeRolloutState struct {
+	Image     string    `json:"image"`
+	Mode      string    `json:"mode"`
+	Nodes     []string  `json:"nodes"`
+	Completed []string  `json:"completed"`
+	StartedAt time.Time `json:"started_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Stopped   string    `json:"stopped,omitempty"`
+}
+
+func (s *upgradeRolloutState) pending() []string {
+	done := make(map[string]bool, len(s.Completed))
+	for _, node := range s.Completed {
+		done[node] = true
+	}
+	var pending []string
+	for _, node := range s.Nodes {
+		if !done[node] {
+			pending = append(pending, node)
+		}
+	}
+	return pending
+}
+
+func loadUpgradeRolloutState() (*upgradeRolloutState, error) {
+	path, err := upgradeStatePathFn()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upgrade progress %s: %w", path, err)
+	}
+	var state upgradeRolloutState
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse upgrade progress %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+func saveUpgradeRolloutState(state *upgradeRolloutState) error {
+	path, err := upgradeStatePathFn()
+	if err != nil {
+		return err
+	}
+	state.UpdatedAt = upgradeNowFn().UTC()
+	raw, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, raw, 0600); err != nil {
+		return fmt.Errorf("failed to write upgrade progress to %s: %w", path, err)
+	}
+	return nil
+}
+
+func clearUpgradeRolloutState() error {
+	path, err := upgradeStatePathFn()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove upgrade progress %s: %w", path, err)
+	}
+	return nil
+}
+
+type upgradeClusterOptions struct {
+	Mode         string
+	Window       *maintenanceWindow
+	IgnoreWindow bool
+	PauseBetween time.Duration
+	MaxDuration  time.Duration
+	Resume       bool
+	DrainImpact  bool
+	SkipDrain    bool
+	// MaxUnavailable is how many Kubernetes nodes may be NotReady or
+	// cordoned at once, counting the node being upgraded.
+	MaxUnavailable int
+	// StepTimeout bounds each drain and each wait for a node to return
+	// Ready and etcd to recover.
+	StepTimeout time.Duration
+}
+
+func newUpgradeClusterCommand() *cobra.Command {
+	var (
+		opts   upgradeClusterOptions
+		window string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "upgrade-cluster",
+		Short: "Upgrade Talos on every node, one node at a time",
+		Long: `Upgrade Talos on every node in talosconfig order, waiting for each node to
+report healthy before moving on.
+
+--window pauses the rollout while outside the maintenance window and resumes it
+when the window reopens (--ignore-window disables this). --pause-between adds a
+settle time after each healthy node. --max-duration stops the rollout cleanly
+between nodes once exceeded. Progress is saved after every node, so a stopped,
+failed, or interrupted rollout continues from the next pending node with
+--resume.
+
+Before each node the rollout checks that every control plane's etcd member is
+healthy and refuses to continue when one is not, so an upgrade never costs etcd
+its quorum. The node is then cordoned and drained (--skip-drain leaves it
+scheduled), upgraded, and uncordoned once it is Ready and etcd is healthy
+again. --max-unavailable is how many nodes may be NotReady or cordoned at once,
+counting the node being upgraded; a rollout that would exceed it stops. Each
+drain and wait is bounded by --step-timeout; one that runs out stops the
+rollout and names the node.
+
+--drain-impact runs 'k8s drain-impact' before each node and asks before
+upgrading a node whose drain would cause downtime or be blocked by a PDB;
+declining stops the rollout there.`,
+		Example: `  homeops-cli talos upgrade-cluster --window 22:00-06:00 --pause-between 10m --max-duration 6h
+  homeops-cli talos upgrade-cluster --resume --window 22:00-06:00`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			parsed, err := parseMaintenanceWindow(window)
+			if err != nil {
+				return err
+			}
+			opts.Window = parsed
+			if opts.PauseBetween < 0 || opts.MaxDuration < 0 {
+				return fmt.Errorf("--pause-between and --max-duration must not be negative")
+			}
+			if opts.MaxUnavailable < 1 {
+				return fmt.Errorf("--max-unavailable must be at least 1")
+			}
+			if opts.StepTimeout <= 0 {
+				return fmt.Errorf("--step-timeout must be positive")
+			}
+			if err := checkRepoVersions(cmd.Context(), versioncheck.Talos); err != nil {
+				return err
+			}
+			return runUpgradeCluster(cmd.Context(), common.NewColorLogger(), opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Mode, "mode", "powercycle", "Reboot mode")
+	cmd.Flags().StringVar(&window, "window", "", `Maintenance window in local time, e.g. "22:00-06:00"`)
+	cmd.Flags().BoolVar(&opts.IgnoreWindow, "ignore-window", false, "Do not pause outside --window")
+	cmd.Flags().DurationVar(&opts.PauseBetween, "pause-between", 0, "Wait after each node is healthy before starting the next")
+	cmd.Flags().DurationVar(&opts.MaxDuration, "max-duration", 0, "Stop between nodes once the rollout has run this long (0 = unlimited)")
+	cmd.Flags().BoolVar(&opts.Resume, "resume", false, "Continue the saved rollout from the next pending node")
+	cmd.Flags().BoolVar(&opts.DrainImpact, "drain-impact", false, "Check drain impact before each node and confirm when it would cause downtime")
+	cmd.Flags().BoolVar(&opts.SkipDrain, "skip-drain", false, "Upgrade nodes without cordoning and draining them")
+	cmd.Flags().IntVar(&opts.MaxUnavailable, "max-unavailable", 1, "Nodes that may be NotReady or cordoned at once, counting the one being upgraded")
+	cmd.Flags().DurationVar(&opts.StepTimeout, "step-timeout", upgradeDefaultStepTimeout, "Longest a drain, or a wait for a node to be Ready and etcd healthy, may take")
+
+	return cmd
+}
+
+func runUpgradeCluster(ctx context.Context, logger *common.ColorLogger, opts upgradeClusterOptions) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	opts.MaxUnavailable = max(opts.MaxUnavailable, 1)
+	if opts.StepTimeout <= 0 {
+		opts.StepTimeout = upgradeDefaultStepTimeout
+	}
+	state, err := loadUpgradeRolloutState()
+	if err != nil {
+		return err
+	}
+	if opts.Resume {
+		if state == nil {
+			return fmt.Errorf("no saved upgrade-cluster rollout to resume")
+		}
+		logger.Info("Resuming rollout to %s: %d of %d node(s) done", state.Image, len(state.Completed), len(state.Nodes))
+	} else {
+		if state != nil && len(state.pending()) > 0 {
+			logger.Warn("Discarding saved rollout with %d pending node(s); use --resume to continue it instead", len(state.pending()))
+		}
+		nodes, err := upgradeClusterNodes()
+		if err != nil {
+			return err
+		}
+		if len(nodes) == 0 {
+			return fmt.Errorf("no nodes found in talosconfig")
+		}
+		image, err := upgradeClusterImage()
+		if err != nil {
+			return err
+		}
+		state = &upgradeRolloutState{Image: image, Mode: opts.Mode, Nodes: nodes, StartedAt: upgradeNowFn().UTC()}
+	}
+	state.Stopped = ""
+	if err := saveUpgradeRolloutState(state); err != nil {
+		return err
+	}
+
+	controlPlanes, err := upgradeControlPlanes(state.Nodes)
+	if err != nil {
+		return err
+	}
+
+	started := upgradeNowFn()
+	var deadline time.Time
+	if opts.MaxDuration > 0 {
+		deadline = started.Add(opts.MaxDuration)
+	}
+	pastDeadline := func(t time.Time) bool { return !deadline.IsZero() && !t.Before(deadline) }
+
+	pending := state.pending()
+	for index, node := range pending {
+		now := upgradeNowFn()
+		if pastDeadline(now) {
+			return stopUpgradeRollout(logger, state, fmt.Sprintf("--max-duration %s reached", opts.MaxDuration))
+		}
+		if opts.Window != nil && !opts.IgnoreWindow {
+			if open := opts.Window.NextOpen(now); open.After(now) {
+				if pastDeadline(open) {
+					return stopUpgradeRollout(logger, state, fmt.Sprintf("window %s reopens after --max-duration", opts.Window))
+				}
+				logger.Info("Outside maintenance window %s; pausing until %s", opts.Window, open.Format("2006-01-02 15:04"))
+				if err := upgradeSleepFn(ctx, open.Sub(now)); err != nil {
+					_ = stopUpgradeRollout(logger, state, "interrupted while waiting for the window")
+					return err
+				}
+			}
+		}
+
+		if opts.DrainImpact {
+			proceed, err := confirmUpgradeDrainImpact(ctx, logger, node)
+			if err != nil {
+				return err
+			}
+			if !proceed {
+				return stopUpgradeRollout(logger, state, fmt.Sprintf("draining %s would disrupt workloads", node))
+			}
+		}
+
+		if problems := etcdProblems(controlPlanes); len(problems) > 0 {
+			_ = stopUpgradeRollout(logger, state, "etcd is degraded")
+			return fmt.Errorf("refusing to upgrade %s while etcd is degraded: %s (fix it, then rerun with --resume)", node, strings.Join(problems, "; "))
+		}
+		name, err := prepareUpgradeNode(ctx, logger, node, opts)
+		if err != nil {
+			_ = stopUpgradeRollout(logger, state, fmt.Sprintf("preparing %s failed", node))
+			return fmt.Errorf("node %s: %w (fix it, then rerun with --resume)", node, err)
+		}
+
+		if err := upgradeClusterNode(logger, node, state.Image, state.Mode); err != nil {
+			_ = stopUpgradeRollout(logger, state, fmt.Sprintf("upgrade of %s failed", node))
+			return fmt.Errorf("node %s: %w (fix it, then rerun with --resume)", node, err)
+		}
+		if err := upgradeNodeHealthFn(node); err != nil {
+			_ = stopUpgradeRollout(logger, state, fmt.Sprintf("%s did not report healthy", node))
+			return fmt.Errorf("node %s did not report healthy after upgrade: %w (fix it, then rerun with --resume)", node, err)
+		}
+		if err := recoverUpgradeNode(ctx, logger, name, controlPlanes, opts); err != nil {
+			_ = stopUpgradeRollout(logger, state, fmt.Sprintf("%s did not recover", node))
+			return fmt.Errorf("node %s: %w (fix it, then rerun with --resume)", node, err)
+		}
+		state.Completed = append(state.Completed, node)
+		if err := saveUpgradeRolloutState(state); err != nil {
+			return err
+		}
+
+		if index < len(pending)-1 && opts.PauseBetween > 0 {
+			if pastDeadline(upgradeNowFn().Add(opts.PauseBetween)) {
+				return stopUpgradeRollout(logger, state, fmt.Sprintf("--max-duration %s reached", opts.MaxDuration))
+			}
+			logger.Info("Node %s healthy; pausing %s before the next node", node, opts.PauseBetween)
+			if err := upgradeSleepFn(ctx, opts.PauseBetween); err != nil {
+				_ = stopUpgradeRollout(logger, state, "interrupted during --pause-between")
+				return err
+			}
+		}
+	}
+
+	if err := clearUpgradeRolloutState(); err != nil {
+		return err
+	}
+	logger.Success("Upgraded %d node(s) to %s", len(state.Nodes), state.Image)
+	return nil
+}
+
+// upgradeControlPlanes returns the rollout's control planes, whose etcd
+// members must stay healthy throughout.
+func upgradeControlPlanes(nodes []string) ([]string, error) {
+	var controlPlanes []string
+	for _, node := range nodes {
+		machineType, err := getMachineTypeFromNodeFn(node)
+		if err != nil {
+			return nil, fmt.Errorf("node %s: %w", node, err)
+		}
+		if machineType == "controlplane" {
+			controlPlanes = append(controlPlanes, node)
+		}
+	}
+	return controlPlanes, nil
+}
+
+// etcdProblems lists every control plane whose etcd member is unhealthy.
+func etcdProblems(controlPlanes []string) []string {
+	var problems []string
+	for _, node := range controlPlanes {
+		if _, problem := etcdMemberStatus(node); problem != "" {
+			problems = append(problems, node+": "+problem)
+		}
+	}
+	return problems
+}
+
+// upgradeKubeNode is the part of a Kubernetes node the rollout reads.
+type upgradeKubeNode struct {
+	Name          string
+	Addresses     []string
+	Ready         bool
+	Unschedulable bool
+}
+
+func listUpgradeKubeNodes(ctx context.Context) ([]upgradeKubeNode, error) {
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Spec struct {
+				Unschedulable bool `json:"unschedulable"`
+			} `json:"spec"`
+			Status struct {
+				Addresses []struct {
+					Address string `json:"address"`
+				} `json:"addresses"`
+				Conditions []healthCondition `json:"conditions"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := kubeutil.GetClusterJSON(ctx, upgradeKubectlFn, "nodes", &list); err != nil {
+		return nil, err
+	}
+	nodes := make([]upgradeKubeNode, 0, len(list.Items))
+	for _, item := range list.Items {
+		node := upgradeKubeNode{Name: item.Metadata.Name, Unschedulable: item.Spec.Unschedulable}
+		for _, address := range item.Status.Addresses {
+			node.Addresses = append(node.Addresses, address.Address)
+		}
+		node.Ready, _ = readyConditionDetail(item.Status.Conditions)
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// prepareUpgradeNode checks the --max-unavailable budget and cordons and
+// drains the Talos node at ip, returning its Kubernetes name.
+func prepareUpgradeNode(ctx context.Context, logger *common.ColorLogger, ip string, opts upgradeClusterOptions) (string, error) {
+	nodes, err := listUpgradeKubeNodes(ctx)
+	if err != nil {
+		return "", err
+	}
+	name := ""
+	var unavailable []string
+	for _, node := range nodes {
+		if slices.Contains(node.Addresses, ip) {
+			name = node.Name
+			continue
+		}
+		if !node.Ready || node.Unschedulable {
+			unavailable = append(unavailable, node.Name)
+		}
+	}
+	if name == "" {
+		return "", fmt.Errorf("no Kubernetes node has address %s", ip)
+	}
+	if len(unavailable)+1 > opts.MaxUnavailable {
+		return "", fmt.Errorf("upgrading %s would leave %d node(s) unavailable (already unavailable: %s), more than --max-unavailable %d",
+			name, len(unavailable)+1, strings.Join(unavailable, ", "), opts.MaxUnavailable)
+	}
+	if opts.SkipDrain {
+		return name, nil
+	}
+	logger.Info("Draining %s (%s)", name, ip)
+	drainCtx, cancel := context.WithTimeout(ctx, opts.StepTimeout)
+	defer cancel()
+	if _, err := upgradeKubectlFn(drainCtx, "drain", name, "--ignore-daemonsets", "--delete-emptydir-data",
+		"--timeout", opts.StepTimeout.String()); err != nil {
+		return "", fmt.Errorf("drain %s: %w", name, err)
+	}
+	return name, nil
+}
+
+// recoverUpgradeNode waits for an upgraded node to be Ready and for every
+// etcd member to be healthy, then uncordons it.
+func recoverUpgradeNode(ctx context.Context, logger *common.ColorLogger, name string, controlPlanes []string, opts upgradeClusterOptions) error {
+	deadline := upgradeNowFn().Add(opts.StepTimeout)
+	for {
+		nodes, err := listUpgradeKubeNodes(ctx)
+		ready := false
+		for _, node := range nodes {
+			ready = ready || (node.Name == name && node.Ready)
+		}
+		var problems []string
+		if ready {
+			problems = etcdProblems(controlPlanes)
+		}
+		if ready && len(problems) == 0 {
+			break
+		}
+		if !upgradeNowFn().Before(deadline) {
+			switch {
+			case !ready && err != nil:
+				return fmt.Errorf("not Ready after --step-timeout %s: %w", opts.StepTimeout, err)
+			case !ready:
+				return fmt.Errorf("not Ready after --step-timeout %s", opts.StepTimeout)
+			default:
+				return fmt.Errorf("etcd not healthy after --step-timeout %s: %s", opts.StepTimeout, strings.Join(problems, "; "))
+			}
+		}
+		if err := upgradeSleepFn(ctx, upgradeCheckInterval); err != nil {
+			return err
+		}
+	}
+	if opts.SkipDrain {
+		return nil
+	}
+	if _, err := upgradeKubectlFn(ctx, "uncordon", name); err != nil {
+		return fmt.Errorf("uncordon %s: %w", name, err)
+	}
+	logger.Info("Node %s is Ready and uncordoned", name)
+	return nil
+}
+
+// confirmUpgradeDrainImpact reports the workloads a drain of node would take
+// down or be blocked by, and asks whether to upgrade it anyway. A failed
+// check is treated like a risk rather than silently skipped.
+func confirmUpgradeDrainImpact(ctx context.Context, logger *common.ColorLogger, node string) (bool, error) {
+	risks, err := upgradeDrainImpactFn(ctx, node)
+	if err != nil {
+		risks = []string{fmt.Sprintf("drain-impact check failed: %v", err)}
+	}
+	if len(risks) == 0 {
+		logger.Info("Drain impact for %s: all workloads are safe to evict", node)
+		return true, nil
+	}
+	for _, risk := range risks {
+		logger.Warn("Drain impact for %s: %s", node, risk)
+	}
+	confirmed, err := confirmActionFn(fmt.Sprintf("Upgrade %s anyway?", node), false)
+	if err != nil {
+		return false, fmt.Errorf("confirmation failed: %w", err)
+	}
+	return confirmed, nil
+}
+
+// stopUpgradeRollout records why the rollout stopped and reports the nodes
+// still pending.
+func stopUpgradeRollout(logger *common.ColorLogger, state *upgradeRolloutState, reason string) error {
+	state.Stopped = reason
+	if err := saveUpgradeRolloutState(state); err != nil {
+		return err
+	}
+	logger.Warn("Rollout stopped: %s", reason)
+	logger.Warn("Pending node(s): %s", strings.Join(state.pending(), ", "))
+	logger.Info("Continue with: homeops-cli talos upgrade-cluster --resume")
+	return nil
+}
@@ -0,0 +1,405 @@
+package talos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/common"
+	versionconfig "homeops-cli/internal/config"
+	"homeops-cli/internal/testutil"
+)
+
+// fakeUpgradeRollout drives runUpgradeCluster on a fake clock: sleeping and
+// upgrading a node advance time instead of waiting.
+type fakeUpgradeRollout struct {
+	now      time.Time
+	perNode  time.Duration
+	sleeps   []time.Duration
+	upgraded []string
+	failOn   string
+	// kubectl records drain and uncordon calls as "<verb> <node>".
+	kubectl []string
+	// notReady and etcdErrors are keyed by node IP.
+	notReady   map[string]bool
+	etcdErrors map[string]string
+}
+
+func installFakeUpgradeRollout(t *testing.T, rollout *fakeUpgradeRollout, nodes []string) {
+	t.Helper()
+	statePath := filepath.Join(t.TempDir(), "talos-upgrade-cluster.json")
+	testutil.Swap(t, &upgradeStatePathFn, func() (string, error) { return statePath, nil })
+	testutil.Swap(t, &upgradeNowFn, func() time.Time { return rollout.now })
+	testutil.Swap(t, &upgradeSleepFn, func(_ context.Context, d time.Duration) error {
+		rollout.sleeps = append(rollout.sleeps, d)
+		rollout.now = rollout.now.Add(d)
+		return nil
+	})
+	testutil.Swap(t, &upgradeClusterNodes, func() ([]string, error) { return nodes, nil })
+	testutil.Swap(t, &upgradeClusterImage, func() (string, error) { return "factory.talos.dev/installer/abc:v1.9.0", nil })
+	testutil.Swap(t, &upgradeClusterNode, func(_ *common.ColorLogger, node, image, mode string) error {
+		if node == rollout.failOn {
+			return errors.New("talosctl upgrade timed out")
+		}
+		rollout.upgraded = append(rollout.upgraded, node)
+		rollout.now = rollout.now.Add(rollout.perNode)
+		return nil
+	})
+	testutil.Swap(t, &upgradeNodeHealthFn, func(string) error { return nil })
+	testutil.Swap(t, &getMachineTypeFromNodeFn, func(string) (string, error) { return "controlplane", nil })
+	testutil.Swap(t, &talosctlNodeOutputFn, func(node string, args ...string) ([]byte, error) {
+		require.Equal(t, []string{"etcd", "status"}, args)
+		return []byte(testEtcdStatus[:len(testEtcdStatus)-1] + "     " + rollout.etcdErrors[node] + "\n"), nil
+	})
+	testutil.Swap(t, &upgradeKubectlFn, func(_ context.Context, args ...string) ([]byte, error) {
+		if args[0] != "get" {
+			rollout.kubectl = append(rollout.kubectl, args[0]+" "+args[1])
+			return nil, nil
+		}
+		var items []string
+		for _, node := range nodes {
+			ready := "True"
+			if rollout.notReady[node] {
+				ready = "False"
+			}
+			items = append(items, fmt.Sprintf(`{"metadata":{"name":"node-%s"},"status":{"addresses":[{"type":"InternalIP","address":%q}],"conditions":[{"type":"Ready","status":%q}]}}`,
+				node[len(node)-2:], node, ready))
+		}
+		return []byte(`{"items":[` + strings.Join(items, ",") + `]}`), nil
+	})
+}
+
+func quietUpgradeLogger() *common.ColorLogger {
+	logger := common.NewColorLogger()
+	logger.SetQuiet(true)
+	return logger
+}
+
+func mustWindow(t *testing.T, spec string) *maintenanceWindow {
+	t.Helper()
+	window, err := parseMaintenanceWindow(spec)
+	require.NoError(t, err)
+	return window
+}
+
+func upgradeClock(clock string) time.Time {
+	parsed, _ := time.Parse("2006-01-02 15:04", clock)
+	return parsed
+}
+
+func TestMaintenanceWindowParsingAndMidnight(t *testing.T) {
+	overnight := mustWindow(t, "22:00-06:00")
+	for clock, want := range map[string]bool{
+		"2026-10-14 22:00": true, "2026-10-14 23:59": true, "2026-10-15 00:00": true,
+		"2026-10-15 05:59": true, "2026-10-15 06:00": false, "2026-10-14 12:00": false, "2026-10-14 21:59": false,
+	} {
+		assert.Equal(t, want, overnight.Contains(upgradeClock(clock)), clock)
+	}
+	assert.Equal(t, upgradeClock("2026-10-14 22:00"), overnight.NextOpen(upgradeClock("2026-10-14 12:00")))
+	assert.Equal(t, upgradeClock("2026-10-15 01:00"), overnight.NextOpen(upgradeClock("2026-10-15 01:00")), "open windows return now")
+
+	daytime := mustWindow(t, "09:30-17:00")
+	assert.True(t, daytime.Contains(upgradeClock("2026-10-14 09:30")))
+	assert.False(t, daytime.Contains(upgradeClock("2026-10-14 17:00")))
+	assert.Equal(t, upgradeClock("2026-10-15 09:30"), daytime.NextOpen(upgradeClock("2026-10-14 18:00")), "after today's window it opens tomorrow")
+
+	none, err := parseMaintenanceWindow("")
+	require.NoError(t, err)
+	assert.Nil(t, none)
+	for _, bad := range []string{"22:00", "22:00-22:00", "25:00-06:00", "ten-six"} {
+		_, err := parseMaintenanceWindow(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestCheckUpgradeWindowRefusesOutsideWindow(t *testing.T) {
+	testutil.Swap(t, &upgradeNowFn, func() time.Time { return upgradeClock("2026-10-14 12:00") })
+	err := checkUpgradeWindow("22:00-06:00", false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "next opens 2026-10-14 22:00")
+	assert.Contains(t, err.Error(), "--ignore-window")
+	require.NoError(t, checkUpgradeWindow("22:00-06:00", true))
+	require.NoError(t, checkUpgradeWindow("", false))
+
+	_, err = testutil.ExecuteCommand(newUpgradeNodeCommand(), "--ip", "10.0.0.40", "--window", "22:00-06:00")
+	require.Error(t, err)
+}
+
+func TestUpgradeClusterWaitsForWindowAndPacesNodes(t *testing.T) {
+	rollout := &fakeUpgradeRollout{now: upgradeClock("2026-10-14 21:00"), perNode: 15 * time.Minute}
+	installFakeUpgradeRollout(t, rollout, []string{"10.0.0.10", "10.0.0.11", "10.0.0.12"})
+
+	err := runUpgradeCluster(context.Background(), quietUpgradeLogger(), upgradeClusterOptions{
+		Mode: "powercycle", Window: mustWindow(t, "22:00-06:00"), PauseBetween: 10 * time.Minute,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.10", "10.0.0.11", "10.0.0.12"}, rollout.upgraded)
+	assert.Equal(t, []time.Duration{time.Hour, 10 * time.Minute, 10 * time.Minute}, rollout.sleeps,
+		"one wait for the window, then a pause between nodes but not after the last")
+
+	state, err := loadUpgradeRolloutState()
+	require.NoError(t, err)
+	assert.Nil(t, state, "a finished rollout clears its progress")
+}
+
+func TestUpgradeClusterPausesWhenWindowClosesMidRollout(t *testing.T) {
+	rollout := &fakeUpgradeRollout{now: upgradeClock("2026-10-15 05:40"), perNode: 30 * time.Minute}
+	installFakeUpgradeRollout(t, rollout, []string{"10.0.0.10", "10.0.0.11"})
+
+	require.NoError(t, runUpgradeCluster(context.Background(), quietUpgradeLogger(), upgradeClusterOptions{Window: mustWindow(t, "22:00-06:00")}))
+	assert.Equal(t, []time.Duration{15*time.Hour + 50*time.Minute}, rollout.sleeps, "the second node waits from 06:10 until 22:00")
+
+	rollout.now, rollout.sleeps, rollout.upgraded = upgradeClock("2026-10-15 12:00"), nil, nil
+	require.NoError(t, runUpgradeCluster(context.Background(), quietUpgradeLogger(), upgradeClusterOptions{Window: mustWindow(t, "22:00-06:00"), IgnoreWindow: true}))
+	assert.Empty(t, rollout.sleeps)
+	assert.Len(t, rollout.upgraded, 2)
+}
+
+func TestUpgradeClusterMaxDurationPersistsAndResumes(t *testing.T) {
+	rollout := &fakeUpgradeRollout{now: upgradeClock("2026-10-14 22:00"), perNode: 20 * time.Minute}
+	nodes := []string{"10.0.0.10", "10.0.0.11", "10.0.0.12"}
+	installFakeUpgradeRollout(t, rollout, nodes)
+
+	require.NoError(t, runUpgradeCluster(context.Background(), quietUpgradeLogger(), upgradeClusterOptions{
+		Mode: "powercycle", PauseBetween: 5 * time.Minute, MaxDuration: 45 * time.Minute,
+	}))
+	assert.Equal(t, []string{"10.0.0.10", "10.0.0.11"}, rollout.upgraded, "stops before a pause would cross the deadline")
+	state, err := loadUpgradeRolloutState()
+	require.NoError(t, err)
+	require.NotNil(t, state)
+	assert.Equal(t, []string{"10.0.0.12"}, state.pending())
+	assert.Contains(t, state.Stopped, "--max-duration 45m0s reached")
+
+	testutil.Swap(t, &upgradeClusterNodes, func() ([]string, error) {
+		t.Fatal("--resume uses the saved node list")
+		return nil, nil
+	})
+	rollout.upgraded = nil
+	require.NoError(t, runUpgradeCluster(context.Background(), quietUpgradeLogger(), upgradeClusterOptions{Resume: true}))
+	assert.Equal(t, []string{"10.0.0.12"}, rollout.upgraded)
+
+	err = runUpgradeCluster(context.Background(), quietUpgradeLogger(), upgradeClusterOptions{Resume: true})
+	require.Error(t, err, "nothing left to resume")
+}
+
+func TestUpgradeClusterFailureKeepsFailedNodePending(t *testing.T) {
+	rollout := &fakeUpgradeRollout{now: upgradeClock("2026-10-14 22:00"), failOn: "10.0.0.11"}
+	installFakeUpgradeRollout(t, rollout, []string{"10.0.0.10", "10.0.0.11", "10.0.0.12"})
+
+	err := runUpgradeCluster(context.Background(), quietUpgradeLogger(), upgradeClusterOptions{Mode: "default"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--resume")
+	state, err := loadUpgradeRolloutState()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.11", "10.0.0.12"}, state.pending())
+	assert.Equal(t, "default", state.Mode)
+
+	rollout.failOn, rollout.upgraded = "", nil
+	require.NoError(t, runUpgradeCluster(context.Background(), quietUpgradeLogger(), upgradeClusterOptions{Resume: true}))
+	assert.Equal(t, []string{"10.0.0.11", "10.0.0.12"}, rollout.upgraded)
+}
+
+func TestUpgradeClusterDrainImpactPausesOnDowntime(t *testing.T) {
+	rollout := &fakeUpgradeRollout{now: upgradeClock("2026-10-14 22:00")}
+	installFakeUpgradeRollout(t, rollout, []string{"10.0.0.10", "10.0.0.11", "10.0.0.12"})
+	var checked []string
+	testutil.Swap(t, &upgradeDrainImpactFn, func(_ context.Context, node string) ([]string, error) {
+		checked = append(checked, node)
+		switch node {
+		case "10.0.0.11":
+			return []string{"media/Deployment/radarr: will cause downtime"}, nil
+		case "10.0.0.12":
+			return nil, errors.New("kubectl unreachable")
+		}
+		return nil, nil
+	})
+	var prompts []string
+	answers := []bool{true, false}
+	testutil.Swap(t, &confirmActionFn, func(message string, defaultYes bool) (bool, error) {
+		assert.False(t, defaultYes)
+		prompts = append(prompts, message)
+		answer := answers[0]
+		answers = answers[1:]
+		return answer, nil
+	})
+
+	require.NoError(t, runUpgradeCluster(context.Background(), quietUpgradeLogger(), upgradeClusterOptions{DrainImpact: true}))
+	assert.Equal(t, []string{"10.0.0.10", "10.0.0.11", "10.0.0.12"}, checked)
+	assert.Equal(t, []string{"Upgrade 10.0.0.11 anyway?", "Upgrade 10.0.0.12 anyway?"}, prompts, "a safe node is not prompted; a failed check is")
+	assert.Equal(t, []string{"10.0.0.10", "10.0.0.11"}, rollout.upgraded)
+
+	state, err := loadUpgradeRolloutState()
+	require.NoError(t, err)
+	require.NotNil(t, state)
+	assert.Equal(t, "draining 10.0.0.12 would disrupt workloads", state.Stopped)
+	assert.Equal(t, []string{"10.0.0.12"}, state.pending())
+}
+
+func TestUpgradeClusterDrainsAndUncordonsEachNode(t *testing.T) {
+	rollout := &fakeUpgradeRollout{now: upgradeClock("2026-10-14 22:00")}
+	installFakeUpgradeRollout(t, rollout, []string{"10.0.0.10", "10.0.0.11"})
+
+	require.NoError(t, runUpgradeCluster(context.Background(), quietUpgradeLogger(), upgradeClusterOptions{}))
+	assert.Equal(t, []string{"drain node-10", "uncordon node-10", "drain node-11", "uncordon node-11"}, rollout.kubectl)
+
+	rollout.kubectl, rollout.upgraded = nil, nil
+	require.NoError(t, runUpgradeCluster(context.Background(), quietUpgradeLogger(), upgradeClusterOptions{SkipDrain: true}))
+	assert.Empty(t, rollout.kubectl, "--skip-drain neither cordons nor drains")
+	assert.Len(t, rollout.upgraded, 2)
+}
+
+func TestUpgradeClusterRefusesWhenEtcdDegrades(t *testing.T) {
+	rollout := &fakeUpgradeRollout{now: upgradeClock("2026-10-14 22:00"), etcdErrors: map[string]string{}}
+	nodes := []string{"10.0.0.10", "10.0.0.11", "10.0.0.12"}
+	installFakeUpgradeRollout(t, rollout, nodes)
+	upgrade := upgradeClusterNode
+	testutil.Swap(t, &upgradeClusterNode, func(logger *common.ColorLogger, node, image, mode string) error {
+		// The first node comes back, but its member never rejoins.
+		rollout.etcdErrors["10.0.0.10"] = "etcdserver: member not started"
+		return upgrade(logger, node, image, mode)
+	})
+
+	err := runUpgradeCluster(context.Background(), quietUpgradeLogger(), upgradeClusterOptions{StepTimeout: time.Minute})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "node 10.0.0.10: etcd not healthy after --step-timeout 1m0s: 10.0.0.10: etcdserver: member not started")
+	assert.Equal(t, []string{"10.0.0.10"}, rollout.upgraded)
+	assert.Equal(t, []string{"drain node-10"}, rollout.kubectl, "a node that did not recover stays cordoned")
+	state, err := loadUpgradeRolloutState()
+	require.NoError(t, err)
+	assert.Equal(t, nodes, state.pending())
+
+	err = runUpgradeCluster(context.Background(), quietUpgradeLogger(), upgradeClusterOptions{Resume: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "refusing to upgrade 10.0.0.10 while etcd is degraded")
+	assert.Equal(t, "etcd is degraded", mustUpgradeState(t).Stopped)
+}
+
+func TestUpgradeClusterStopsOnUnavailableBudgetAndReadyTimeout(t *testing.T) {
+	rollout := &fakeUpgradeRollout{now: upgradeClock("2026-10-14 22:00"), notReady: map[string]bool{"10.0.0.12": true}}
+	installFakeUpgradeRollout(t, rollout, []string{"10.0.0.10", "10.0.0.11", "10.0.0.12"})
+
+	err := runUpgradeCluster(context.Background(), quietUpgradeLogger(), upgradeClusterOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "upgrading node-10 would leave 2 node(s) unavailable (already unavailable: node-12), more than --max-unavailable 1")
+	assert.Empty(t, rollout.upgraded)
+
+	testutil.Swap(t, &upgradeClusterNode, func(_ *common.ColorLogger, node, _, _ string) error {
+		rollout.notReady[node] = true
+		rollout.upgraded = append(rollout.upgraded, node)
+		return nil
+	})
+	err = runUpgradeCluster(context.Background(), quietUpgradeLogger(), upgradeClusterOptions{MaxUnavailable: 2, StepTimeout: time.Minute})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "node 10.0.0.10: not Ready after --step-timeout 1m0s")
+	assert.Equal(t, []time.Duration{upgradeCheckInterval, upgradeCheckInterval, upgradeCheckInterval, upgradeCheckInterval, upgradeCheckInterval, upgradeCheckInterval}, rollout.sleeps)
+	assert.Equal(t, "10.0.0.10 did not recover", mustUpgradeState(t).Stopped)
+}
+
+func mustUpgradeState(t *testing.T) *upgradeRolloutState {
+	t.Helper()
+	state, err := loadUpgradeRolloutState()
+	require.NoError(t, err)
+	require.NotNil(t, state)
+	return state
+}
+
+func TestUpgradeNodeSnapshotsEtcdFirst(t *testing.T) {
+	dir := t.TempDir()
+	t.Cleanup(versionconfig.SetForTesting(&versionconfig.Config{
+		Cluster: versionconfig.ClusterConfig{Nodes: []versionconfig.Node{{Name: "k8s-0", IP: "10.0.0.10"}, {Name: "k8s-1", IP: "10.0.0.11"}, {Name: "k8s-2", IP: "10.0.0.12"}}},
+		State:   versionconfig.StateConfig{EtcdBackup: versionconfig.EtcdBackupConfig{Dir: dir}},
+	}))
+	testutil.Swap(t, &getTalosTemplateFn, func(string) (string, error) {
+		return "machine:\n  type: controlplane\n", nil
+	})
+	stubUpgradeNodeImage(t, "factory.talos.dev/installer/schematic:v1.8.0")
+	snapshot := []byte("etcd")
+	testutil.Swap(t, &takeTalosEtcdSnapshotFn, func(nodeIP, path string) error {
+		assert.Equal(t, "10.0.0.10", nodeIP)
+		return os.WriteFile(path, snapshot, 0o600)
+	})
+	var upgraded []string
+	testutil.Swap(t, &spinCommandFn, func(_ string, _ string, args ...string) error {
+		upgraded = append(upgraded, args[1])
+		files, err := filepath.Glob(filepath.Join(dir, "etcd-snapshot-k8s-0-*.db"))
+		require.NoError(t, err)
+		assert.Len(t, files, 1, "the snapshot is on disk before the node is touched")
+		return nil
+	})
+
+	_, err := testutil.ExecuteCommand(newUpgradeNodeCommand(), "--ip", "10.0.0.11")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.11"}, upgraded)
+	require.NoError(t, os.Rename(dir, dir+"-first"), "the next snapshots may land in the same second")
+	require.NoError(t, os.Mkdir(dir, 0o700))
+
+	snapshot = nil
+	_, err = testutil.ExecuteCommand(newUpgradeNodeCommand(), "--ip", "10.0.0.11")
+	require.ErrorContains(t, err, "pre-upgrade etcd snapshot failed (pass --skip-snapshot to upgrade without one): etcd snapshot from k8s-0 is empty")
+	assert.Len(t, upgraded, 1, "an empty snapshot stops the upgrade")
+
+	testutil.Swap(t, &spinCommandFn, func(_ string, _ string, args ...string) error {
+		upgraded = append(upgraded, args[1])
+		return nil
+	})
+	_, err = testutil.ExecuteCommand(newUpgradeNodeCommand(), "--ip", "10.0.0.11", "--skip-snapshot")
+	require.NoError(t, err)
+	assert.Len(t, upgraded, 2)
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	require.NoError(t, err)
+	assert.Empty(t, files, "--skip-snapshot takes none")
+}
+
+// stubUpgradeNodeImage renders every node's config with the schematic image,
+// except 10.0.0.41, whose patch overrides it, and reports running as the
+// image each node runs.
+func stubUpgradeNodeImage(t *testing.T, running string) {
+	t.Helper()
+	testutil.Swap(t, &getMachineTypeFromNodeFn, func(string) (string, error) { return "controlplane", nil })
+	testutil.Swap(t, &renderMachineConfigFromEmbeddedFn, func(base, patch string) ([]byte, error) {
+		assert.Equal(t, "talos/controlplane.yaml", base)
+		if patch == "talos/nodes/10.0.0.41.yaml" {
+			return []byte("machine:\n  install:\n    image: factory.talos.dev/installer/gpu:v1.9.0\n"), nil
+		}
+		return []byte("machine:\n  install:\n    image: factory.talos.dev/installer/schematic:v1.9.0\n"), nil
+	})
+	testutil.Swap(t, &talosctlNodeOutputFn, func(_ string, args ...string) ([]byte, error) {
+		assert.Equal(t, []string{"get", "machineconfig", "--output=jsonpath={.spec}"}, args)
+		return []byte("version: v1alpha1\nmachine:\n  install:\n    image: " + running + "\n"), nil
+	})
+}
+
+func TestUpgradeNodeResolvesImagePerNode(t *testing.T) {
+	stubUpgradeNodeImage(t, "factory.talos.dev/installer/gpu:v1.8.0")
+	var upgraded []string
+	testutil.Swap(t, &spinCommandFn, func(_ string, _ string, args ...string) error {
+		upgraded = append(upgraded, args[1]+" "+args[4])
+		return nil
+	})
+	upgrade := func(args ...string) {
+		t.Helper()
+		_, err := testutil.ExecuteCommand(newUpgradeNodeCommand(), append(args, "--skip-snapshot")...)
+		require.NoError(t, err)
+	}
+
+	upgrade("--ip", "10.0.0.41")
+	upgrade("--ip", "10.0.0.40")
+	assert.Equal(t, []string{
+		"10.0.0.41 factory.talos.dev/installer/gpu:v1.9.0",
+		"10.0.0.40 factory.talos.dev/installer/schematic:v1.9.0",
+	}, upgraded, "a node whose patch overrides the installer upgrades to its own image")
+
+	upgraded = nil
+	upgrade("--ip", "10.0.0.40", "--image", "factory.talos.dev/installer/gpu:v1.8.0")
+	assert.Empty(t, upgraded, "a node already running the target image is skipped")
+	upgrade("--ip", "10.0.0.40", "--image", "factory.talos.dev/installer/gpu:v1.8.0", "--force")
+	assert.Equal(t, []string{"10.0.0.40 factory.talos.dev/installer/gpu:v1.8.0"}, upgraded)
+}
@@ -0,0 +1,62 @@
+package talos
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"homeops-cli/cmd/kubernetes"
+	"homeops-cli/internal/common"
+	versionconfig "homeops-cli/internal/config"
+)
+
+var (
+	saveEtcdSnapshotFn      = kubernetes.SaveEtcdSnapshot
+	takeTalosEtcdSnapshotFn = func(nodeIP, path string) error {
+		output, err := talosctlCombinedOutputFn("talosctl", "--nodes", nodeIP, "etcd", "snapshot", path)
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, strings.TrimSpace(common.RedactCommandOutput(string(output))))
+		}
+		return nil
+	}
+)
+
+// upgradeSnapshotOptions controls the etcd snapshot upgrade-node and
+// upgrade-k8s take before touching the cluster.
+type upgradeSnapshotOptions struct {
+	Skip   bool
+	Upload bool
+}
+
+func addUpgradeSnapshotFlags(cmd *cobra.Command, opts *upgradeSnapshotOptions) {
+	cmd.Flags().BoolVar(&opts.Skip, "skip-snapshot", false, "Upgrade without taking an etcd snapshot first")
+	cmd.Flags().BoolVar(&opts.Upload, "upload-snapshot", false, "Copy the snapshot to state.etcd_backup.upload (always when its auto is set)")
+}
+
+// snapshotBeforeUpgrade saves an etcd snapshot from the first control plane
+// in cluster.nodes to state.etcd_backup.dir. An upgrade does not start
+// without one unless --skip-snapshot is set.
+func snapshotBeforeUpgrade(ctx context.Context, logger *common.ColorLogger, opts upgradeSnapshotOptions) error {
+	if opts.Skip {
+		logger.Warn("Skipping the pre-upgrade etcd snapshot (--skip-snapshot)")
+		return nil
+	}
+	cfg := versionconfig.Get()
+	controlPlanes := configuredControlPlanes(cfg)
+	if len(controlPlanes) == 0 {
+		return fmt.Errorf("no control plane in cluster.nodes to take an etcd snapshot from; pass --skip-snapshot to upgrade without one")
+	}
+	node := controlPlanes[0]
+	upload := opts.Upload || cfg.State.EtcdBackup.Upload.Auto
+	logger.Info("Taking an etcd snapshot from %s before upgrading", node.Name)
+	path, err := saveEtcdSnapshotFn(ctx, node.Name, upload, func(path string) error {
+		return takeTalosEtcdSnapshotFn(node.IP, path)
+	})
+	if err != nil {
+		return fmt.Errorf("pre-upgrade etcd snapshot failed (pass --skip-snapshot to upgrade without one): %w", err)
+	}
+	logger.Success("Saved etcd snapshot to %s", path)
+	return nil
+}
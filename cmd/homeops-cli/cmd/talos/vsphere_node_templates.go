@@ -0,0 +1,249 @@
+package talos
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"homeops-cli/internal/common"
+	versionconfig "homeops-cli/internal/config"
+	"homeops-cli/internal/vmlifecycle"
+)
+
+// A vSphere deploy of several VMs ends by scaffolding a talos/nodes/<ip>.yaml
+// for each new VM with the add-node machinery, so the nodes can be applied
+// without writing their patches by hand. A VM's IP comes from --node-ips, then
+// its cluster.nodes entry, then the address VMware Tools reports (Talos needs
+// the vmtoolsd-guest-agent extension for that). Its MAC is read from the VM.
+
+var (
+	writeVSphereNodeTemplatesFn = writeVSphereNodeTemplates
+	addNodeFn                   = addNode
+	vSphereVMAddressesFn        = vSphereVMAddresses
+	// vSphereNodeIPTimeout bounds the wait for VMware Tools to report IPs.
+	vSphereNodeIPTimeout = 5 * time.Minute
+)
+
+// vSphereVMAddress is what vSphere knows about a VM's first NIC.
+type vSphereVMAddress struct {
+	IP  string // "" until VMware Tools reports an IPv4 address
+	MAC string
+}
+
+// vSphereNodeTemplate is one deployed VM and the template written for it.
+type vSphereNodeTemplate struct {
+	vmName string
+	ip     string
+	mac    string
+	status string
+}
+
+// validateNodeIPs checks --node-ips against the VMs a deploy creates.
+func validateNodeIPs(nodeIPs, vmNames []string) error {
+	if len(nodeIPs) == 0 {
+		return nil
+	}
+	if len(nodeIPs) != len(vmNames) {
+		return fmt.Errorf("--node-ips lists %d addresses for %d VMs (%s)", len(nodeIPs), len(vmNames), strings.Join(vmNames, ", "))
+	}
+	for _, ip := range nodeIPs {
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("--node-ips: %q is not a valid IP address", ip)
+		}
+	}
+	return nil
+}
+
+// writeVSphereNodeTemplates scaffolds a node template for every VM in
+// vmNames that has none yet and prints the VM → IP → template mapping.
+// nodeIPs, when given, holds each VM's IP in order; installDisk is the boot
+// disk as Talos names it behind the VMs' disk controller.
+func writeVSphereNodeTemplates(out io.Writer, vmNames, nodeIPs []string, installDisk string) error {
+	logger := common.NewColorLogger()
+	nodes := make([]vSphereNodeTemplate, len(vmNames))
+	for i, name := range vmNames {
+		nodes[i].vmName = name
+		if len(nodeIPs) > 0 {
+			nodes[i].ip = nodeIPs[i]
+		} else if node, ok := versionconfig.Get().ProvisioningNodeByName(name); ok {
+			nodes[i].ip = node.IP
+		}
+	}
+	if err := resolveVSphereNodeAddresses(logger, nodes); err != nil {
+		return err
+	}
+
+	image := preparedVSphereInstallerImage()
+	if image == "" {
+		logger.Info("No prepare-iso record for the vSphere ISO; the node templates keep the base template's installer image")
+	}
+	failed := 0
+	for i := range nodes {
+		node := &nodes[i]
+		template := fmt.Sprintf("talos/nodes/%s.yaml", node.ip)
+		if nodeTemplateExists(template) {
+			node.status = "existing template kept"
+			continue
+		}
+		err := addNodeFn(addNodeOptions{ip: node.ip, hostname: node.vmName, mac: node.mac, disk: installDisk, image: image})
+		if err != nil {
+			logger.Error("Node template for %s: %v", node.vmName, err)
+			node.status = "not written: " + err.Error()
+			failed++
+			continue
+		}
+		node.status = "written"
+	}
+
+	_, _ = fmt.Fprintln(out, "Node templates:")
+	for _, node := range nodes {
+		_, _ = fmt.Fprintf(out, "  %s → %s → talos/nodes/%s.yaml (%s)\n", node.vmName, node.ip, node.ip, node.status)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d node templates could not be written; fix the cause and run 'homeops-cli talos add-node' for them", failed, len(nodes))
+	}
+	return nil
+}
+
+// nodeTemplateExists reports whether template is embedded or already in the
+// source tree (written by an earlier run but not yet embedded).
+func nodeTemplateExists(template string) bool {
+	if _, err := getTalosTemplateFn(template); err == nil {
+		return true
+	}
+	root, err := repoRootFn()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(root, nodeTemplatesSourceDir, filepath.Base(template)))
+	return err == nil
+}
+
+// resolveVSphereNodeAddresses fills in each VM's MAC, and the IP of any VM
+// without one, polling until VMware Tools reports them all or
+// vSphereNodeIPTimeout passes.
+func resolveVSphereNodeAddresses(logger *common.ColorLogger, nodes []vSphereNodeTemplate) error {
+	deadline := time.Now().Add(vSphereNodeIPTimeout)
+	waiting := false
+	for {
+		var pending []string
+		for _, node := range nodes {
+			if node.ip == "" || node.mac == "" {
+				pending = append(pending, node.vmName)
+			}
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		addresses, err := vSphereVMAddressesFn(pending)
+		if err != nil {
+			return fmt.Errorf("failed to read the new VMs' addresses from vSphere: %w", err)
+		}
+		var missing []string
+		for i := range nodes {
+			address, ok := addresses[nodes[i].vmName]
+			if !ok {
+				continue
+			}
+			if nodes[i].mac == "" {
+				nodes[i].mac = address.MAC
+			}
+			if nodes[i].ip == "" {
+				nodes[i].ip = address.IP
+			}
+			if nodes[i].ip == "" {
+				missing = append(missing, nodes[i].vmName)
+			}
+		}
+		if len(missing) == 0 {
+			// A VM with no MAC in vSphere falls back to cluster.nodes in add-node.
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("VMware Tools reported no IP for %s within %s; pass --node-ips, or --skip-templates and run 'homeops-cli talos add-node' later", strings.Join(missing, ", "), vSphereNodeIPTimeout)
+		}
+		if !waiting {
+			logger.Info("Waiting up to %s for VMware Tools to report the IPs of %s...", vSphereNodeIPTimeout, strings.Join(missing, ", "))
+			waiting = true
+		}
+		time.Sleep(ipWaitPollInterval)
+	}
+}
+
+// vSphereVMAddresses reads the first NIC of each named VM over one vSphere
+// connection.
+func vSphereVMAddresses(names []string) (map[string]vSphereVMAddress, error) {
+	addresses := make(map[string]vSphereVMAddress, len(names))
+	err := vmlifecycle.WithVSphereClient(common.NewColorLogger(), func(client vmlifecycle.VSphereClient) error {
+		for _, name := range names {
+			vm, err := client.FindVM(name)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			info, err := client.GetVMInfo(vm)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			addresses[name] = firstNICAddress(info)
+		}
+		return nil
+	})
+	return addresses, err
+}
+
+// firstNICAddress picks the VM's first NIC's MAC and the IPv4 address the
+// guest reports on it.
+func firstNICAddress(info *mo.VirtualMachine) vSphereVMAddress {
+	var address vSphereVMAddress
+	if info == nil {
+		return address
+	}
+	if info.Config != nil {
+		for _, device := range info.Config.Hardware.Device {
+			if nic, ok := device.(types.BaseVirtualEthernetCard); ok {
+				address.MAC = nic.GetVirtualEthernetCard().MacAddress
+				break
+			}
+		}
+	}
+	if info.Guest == nil {
+		return address
+	}
+	for _, nic := range info.Guest.Net {
+		if address.MAC != "" && !strings.EqualFold(nic.MacAddress, address.MAC) {
+			continue
+		}
+		for _, ip := range nic.IpAddress {
+			if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() != nil {
+				address.IP = ip
+				return address
+			}
+		}
+	}
+	if parsed := net.ParseIP(info.Guest.IpAddress); parsed != nil && parsed.To4() != nil {
+		address.IP = info.Guest.IpAddress
+	}
+	return address
+}
+
+// preparedVSphereInstallerImage is the installer image for the schematic of
+// the ISO prepare-iso last put on the default vSphere datastore, which the
+// new VMs booted; "" when there is no record.
+func preparedVSphereInstallerImage() string {
+	state, err := loadPrepareISOState()
+	if err != nil {
+		return ""
+	}
+	record, ok := state.Locations[defaultVSphereISODest().location()]
+	if !ok || record.SchematicID == "" {
+		return ""
+	}
+	return fmt.Sprintf("factory.talos.dev/installer/%s:{{ ENV.TALOS_VERSION }}", record.SchematicID)
+}
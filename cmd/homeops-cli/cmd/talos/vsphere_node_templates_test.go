@@ -0,0 +1,136 @@
+package talos
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	versionconfig "homeops-cli/internal/config"
+	"homeops-cli/internal/testutil"
+	"homeops-cli/internal/vmlifecycle"
+)
+
+func TestWriteVSphereNodeTemplatesScaffoldsEachVM(t *testing.T) {
+	dir := stubAddNodeRoot(t)
+	cfg := *versionconfig.Get()
+	cfg.Cluster.Nodes = append(cfg.Cluster.Nodes, versionconfig.Node{Name: "worker-2", IP: "192.168.122.23"})
+	t.Cleanup(versionconfig.SetForTesting(&cfg))
+	statePath := filepath.Join(t.TempDir(), "talos-prepare-iso.json")
+	testutil.Swap(t, &prepareISOStatePathFn, func() (string, error) { return statePath, nil })
+	require.NoError(t, savePrepareISOState(prepareISOState{Locations: map[string]preparedISO{
+		defaultVSphereISODest().location(): {SchematicID: "schematic-123"},
+	}}))
+	testutil.Swap(t, &ipWaitPollInterval, 0)
+
+	// VMware Tools reports worker-1's address on the second poll; worker-2's
+	// comes from cluster.nodes.
+	polls := 0
+	testutil.Swap(t, &vSphereVMAddressesFn, func(names []string) (map[string]vSphereVMAddress, error) {
+		polls++
+		addresses := map[string]vSphereVMAddress{
+			"worker-0": {IP: "192.168.122.21", MAC: "00:50:56:00:00:21"},
+			"worker-1": {MAC: "00:50:56:00:00:22"},
+			"worker-2": {MAC: "00:50:56:00:00:23"},
+		}
+		if polls == 2 {
+			assert.Equal(t, []string{"worker-1"}, names, "only the VM without an IP is polled again")
+			addresses["worker-1"] = vSphereVMAddress{IP: "192.168.122.22", MAC: "00:50:56:00:00:22"}
+		}
+		return addresses, nil
+	})
+
+	var out bytes.Buffer
+	require.NoError(t, writeVSphereNodeTemplates(&out, []string{"worker-0", "worker-1", "worker-2"}, nil, "/dev/nvme0n1"))
+	assert.Equal(t, 2, polls)
+
+	for i, ip := range []string{"192.168.122.21", "192.168.122.22", "192.168.122.23"} {
+		data, err := os.ReadFile(filepath.Join(dir, ip+".yaml"))
+		require.NoError(t, err, ip)
+		content := string(data)
+		assert.Contains(t, content, "hostname: worker-"+string(rune('0'+i))+"\n")
+		assert.Contains(t, content, "hardwareAddr: 00:50:56:00:00:2"+string(rune('1'+i)))
+		assert.Contains(t, content, "  install:\n    image: factory.talos.dev/installer/schematic-123:{{ ENV.TALOS_VERSION }}\n    disk: /dev/nvme0n1\n")
+	}
+	assert.Equal(t, "Node templates:\n"+
+		"  worker-0 → 192.168.122.21 → talos/nodes/192.168.122.21.yaml (written)\n"+
+		"  worker-1 → 192.168.122.22 → talos/nodes/192.168.122.22.yaml (written)\n"+
+		"  worker-2 → 192.168.122.23 → talos/nodes/192.168.122.23.yaml (written)\n", out.String())
+
+	// A rerun keeps what the first one wrote.
+	out.Reset()
+	testutil.Swap(t, &addNodeFn, func(opts addNodeOptions) error {
+		t.Errorf("add-node called for %s", opts.ip)
+		return nil
+	})
+	require.NoError(t, writeVSphereNodeTemplates(&out, []string{"worker-0", "worker-1", "worker-2"}, []string{"192.168.122.21", "192.168.122.22", "192.168.122.10"}, "/dev/nvme0n1"))
+	assert.Contains(t, out.String(), "worker-0 → 192.168.122.21 → talos/nodes/192.168.122.21.yaml (existing template kept)")
+	assert.Contains(t, out.String(), "worker-2 → 192.168.122.10 → talos/nodes/192.168.122.10.yaml (existing template kept)", "an embedded template is kept too")
+}
+
+func TestWriteVSphereNodeTemplatesNeedsAnIP(t *testing.T) {
+	stubAddNodeRoot(t)
+	testutil.Swap(t, &vSphereNodeIPTimeout, 0)
+	testutil.Swap(t, &vSphereVMAddressesFn, func(names []string) (map[string]vSphereVMAddress, error) {
+		return map[string]vSphereVMAddress{"worker-0": {MAC: "00:50:56:00:00:21"}}, nil
+	})
+	err := writeVSphereNodeTemplates(&bytes.Buffer{}, []string{"worker-0"}, nil, "/dev/nvme0n1")
+	assert.EqualError(t, err, "VMware Tools reported no IP for worker-0 within 0s; pass --node-ips, or --skip-templates and run 'homeops-cli talos add-node' later")
+}
+
+func TestFirstNICAddress(t *testing.T) {
+	info := &mo.VirtualMachine{
+		Config: &types.VirtualMachineConfigInfo{Hardware: types.VirtualHardware{Device: []types.BaseVirtualDevice{
+			&types.VirtualDisk{},
+			&types.VirtualVmxnet3{VirtualVmxnet: types.VirtualVmxnet{VirtualEthernetCard: types.VirtualEthernetCard{MacAddress: "00:50:56:00:00:21"}}},
+		}}},
+		Guest: &types.GuestInfo{IpAddress: "10.0.0.9", Net: []types.GuestNicInfo{
+			{MacAddress: "00:50:56:ff:ff:ff", IpAddress: []string{"10.0.0.9"}},
+			{MacAddress: "00:50:56:00:00:21", IpAddress: []string{"fe80::1", "192.168.122.21"}},
+		}},
+	}
+	assert.Equal(t, vSphereVMAddress{IP: "192.168.122.21", MAC: "00:50:56:00:00:21"}, firstNICAddress(info))
+
+	info.Guest = nil
+	assert.Equal(t, vSphereVMAddress{MAC: "00:50:56:00:00:21"}, firstNICAddress(info), "no IP before VMware Tools reports one")
+}
+
+func TestDeployVMOnVSphereWritesNodeTemplates(t *testing.T) {
+	testutil.Swap(t, &vmlifecycle.GetVSphereHostFn, func() (string, error) { return "esxi.local", nil })
+	testutil.Swap(t, &vmlifecycle.GetVSphereCredsFn, func() (string, string, string, error) { return "esxi.local", "user", "pass", nil })
+	deployer := &fakeVSphereDeployer{}
+	testutil.Swap(t, &newVSphereDeployerFn, func(host, username, password string) (vsphereVMDeployer, error) { return deployer, nil })
+	var gotNames, gotIPs []string
+	calls := 0
+	testutil.Swap(t, &writeVSphereNodeTemplatesFn, func(_ io.Writer, vmNames, nodeIPs []string, _ string) error {
+		calls++
+		gotNames, gotIPs = vmNames, nodeIPs
+		return nil
+	})
+	args := []string{"--provider", "vsphere", "--name", "worker", "--datastore", "fast-ds", "--network", "vl999", "--skip-ip-check", "--node-count", "3"}
+
+	_, err := testutil.ExecuteCommand(newDeployVMCommand(), append(args, "--node-ips", "192.168.122.21,192.168.122.22,192.168.122.23")...)
+	require.NoError(t, err)
+	assert.Len(t, deployer.deployedConfigs, 3)
+	assert.Equal(t, []string{"worker-0", "worker-1", "worker-2"}, gotNames)
+	assert.Equal(t, []string{"192.168.122.21", "192.168.122.22", "192.168.122.23"}, gotIPs)
+
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), append(args, "--skip-templates")...)
+	require.NoError(t, err)
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), append(args, "--dry-run")...)
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls, "--skip-templates and --dry-run write no templates")
+
+	deployer.deployedConfigs = nil
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), append(args, "--node-ips", "192.168.122.21")...)
+	assert.EqualError(t, err, "--node-ips lists 1 addresses for 3 VMs (worker-0, worker-1, worker-2)")
+	assert.Empty(t, deployer.deployedConfigs, "a bad --node-ips fails before anything is deployed")
+	_, err = testutil.ExecuteCommand(newDeployVMCommand(), "--provider", "proxmox", "--name", "k8s-0", "--skip-templates")
+	assert.EqualError(t, err, "--node-ips and --skip-templates are only supported with --provider vsphere")
+}
@@ -0,0 +1,244 @@
+package talos
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"homeops-cli/internal/common"
+	versionconfig "homeops-cli/internal/config"
+	"homeops-cli/internal/truenas"
+	"homeops-cli/internal/vmlifecycle"
+)
+
+// deploy-vm --wait-for-ip (TrueNAS) starts the new VM and polls for the
+// address its first NIC's MAC was leased, so the next command can run
+// without a trip to the SPICE console or the DHCP server. The middleware has
+// no guest agent, so the MAC is looked up on the network instead, by
+// hypervisors.truenas.ip_discovery.method (or --ip-discovery):
+//
+//   - arp (default): over SSH, the NAS pings cluster.dhcp_pool (or every
+//     host of the VM bridge's subnet) and its neighbor table is searched.
+//   - dhcp: ip_discovery.leases_url is fetched and searched.
+//
+// The address is printed as the last line, "Node IP: <ip>", for scripts.
+
+// trueNASIPWait is the --wait-for-ip setting; a zero Timeout does not wait.
+type trueNASIPWait struct {
+	Timeout time.Duration
+	Method  string // arp or dhcp; "" uses hypervisors.truenas.ip_discovery.method
+}
+
+// trueNASNICMACLister is the part of *truenas.VMManager --wait-for-ip needs
+// beyond vmlifecycle.TrueNASVMManager.
+type trueNASNICMACLister interface {
+	VMNICMACs(string) ([]string, error)
+}
+
+// maxARPProbeHosts bounds the subnet the NAS pings; a larger one needs
+// cluster.dhcp_pool.
+const maxARPProbeHosts = 1024
+
+var (
+	ipWaitPollInterval = 5 * time.Second
+	// deployVMStdout receives the final "Node IP:" line.
+	deployVMStdout    io.Writer = os.Stdout
+	dhcpLeasesClient            = &http.Client{Timeout: 15 * time.Second}
+	fetchDHCPLeasesFn           = func(ctx context.Context, url string) (string, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := dhcpLeasesClient.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("GET %s: %s", url, resp.Status)
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+		return string(body), err
+	}
+	ipv4Pattern = regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}\b`)
+)
+
+// waitForTrueNASNodeIP starts the VM if it is not running and returns the
+// IPv4 address its first NIC got within wait.Timeout.
+func waitForTrueNASNodeIP(ctx context.Context, logger *common.ColorLogger, vmManager vmlifecycle.TrueNASVMManager, host string, config truenas.VMConfig, wait trueNASIPWait) (string, error) {
+	lister, ok := vmManager.(trueNASNICMACLister)
+	if !ok {
+		return "", fmt.Errorf("--wait-for-ip: this TrueNAS manager cannot list VM NICs")
+	}
+	macs, err := lister.VMNICMACs(config.Name)
+	if err != nil {
+		return "", fmt.Errorf("--wait-for-ip: %w", err)
+	}
+	mac := macs[0]
+
+	if running, err := trueNASVMRunning(vmManager, config.Name); err != nil {
+		return "", err
+	} else if !running {
+		if err := vmManager.StartVM(config.Name); err != nil {
+			return "", fmt.Errorf("--wait-for-ip: %w", err)
+		}
+	}
+
+	discovery := versionconfig.Get().Hypervisors.TrueNAS.IPDiscovery
+	method := wait.Method
+	if method == "" {
+		method = discovery.Method
+	}
+	var lookup func(context.Context) (string, error)
+	switch method {
+	case "", "arp":
+		method = "arp"
+		sshClient := newTrueNASSSHClientFn(trueNASSSHConfig(host))
+		if err := sshClient.Connect(); err != nil {
+			return "", fmt.Errorf("--wait-for-ip: failed to connect to TrueNAS over SSH: %w", err)
+		}
+		defer func() {
+			if closeErr := sshClient.Close(); closeErr != nil {
+				logger.Warn("Failed to close SSH client: %v", closeErr)
+			}
+		}()
+		lookup = arpIPLookup(sshClient, config.NetworkBridge, mac)
+	case "dhcp":
+		if discovery.LeasesURL == "" {
+			return "", fmt.Errorf("--wait-for-ip: --ip-discovery dhcp needs hypervisors.truenas.ip_discovery.leases_url in homeops.yaml")
+		}
+		lookup = func(ctx context.Context) (string, error) {
+			leases, err := fetchDHCPLeasesFn(ctx, discovery.LeasesURL)
+			if err != nil {
+				return "", err
+			}
+			return findIPForMAC(leases, mac), nil
+		}
+	default:
+		return "", fmt.Errorf("--ip-discovery %q is not supported (use arp or dhcp)", method)
+	}
+
+	logger.Info("Waiting up to %s for %s (MAC %s) to get an IP address (%s)...", wait.Timeout, config.Name, mac, method)
+	ctx, cancel := context.WithTimeout(ctx, wait.Timeout)
+	defer cancel()
+	for {
+		ip, err := lookup(ctx)
+		if ip != "" {
+			return ip, nil
+		}
+		if err != nil {
+			logger.Debug("IP lookup for %s failed: %v", mac, err)
+		}
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return "", fmt.Errorf("no IP address for %s (MAC %s) within %s via %s; check the console ('homeops-cli vm console --provider truenas %s')", config.Name, mac, wait.Timeout, method, config.Name)
+			}
+			return "", ctx.Err()
+		case <-time.After(ipWaitPollInterval):
+		}
+	}
+}
+
+func trueNASVMRunning(vmManager vmlifecycle.TrueNASVMManager, name string) (bool, error) {
+	summaries, err := vmManager.VMSummaries()
+	if err != nil {
+		return false, fmt.Errorf("--wait-for-ip: %w", err)
+	}
+	for _, summary := range summaries {
+		if summary.Name == name {
+			return strings.EqualFold(summary.Status, "RUNNING"), nil
+		}
+	}
+	return false, fmt.Errorf("--wait-for-ip: VM %s not found", name)
+}
+
+// arpIPLookup pings the probe targets from the NAS so every live guest lands
+// in its neighbor table, then searches the table for mac.
+func arpIPLookup(sshClient trueNASSSHClient, bridge, mac string) func(context.Context) (string, error) {
+	var targets []string
+	return func(context.Context) (string, error) {
+		if targets == nil {
+			var err error
+			if targets, err = arpProbeTargets(sshClient, bridge); err != nil {
+				return "", err
+			}
+		}
+		output, err := sshClient.ExecuteCommand(fmt.Sprintf(
+			"for ip in %s; do ping -c 1 -W 1 \"$ip\" >/dev/null 2>&1 & done; wait; ip -4 neigh show dev %s",
+			strings.Join(targets, " "), common.ShellQuote(bridge)))
+		if err != nil {
+			return "", err
+		}
+		return findIPForMAC(output, mac), nil
+	}
+}
+
+// arpProbeTargets is cluster.dhcp_pool or, without one, every host address
+// of the bridge's IPv4 subnet on the NAS.
+func arpProbeTargets(sshClient trueNASSSHClient, bridge string) ([]string, error) {
+	if pool := versionconfig.Get().Cluster.DHCPPool; pool.Configured() {
+		start, startErr := netip.ParseAddr(pool.Start)
+		end, endErr := netip.ParseAddr(pool.End)
+		if startErr != nil || endErr != nil {
+			return nil, fmt.Errorf("invalid cluster.dhcp_pool %s-%s", pool.Start, pool.End)
+		}
+		return addrRange(start, end)
+	}
+	output, err := sshClient.ExecuteCommand("ip -4 -o addr show dev " + common.ShellQuote(bridge))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the address of %s on the NAS: %w", bridge, err)
+	}
+	for _, field := range strings.Fields(output) {
+		prefix, err := netip.ParsePrefix(field)
+		if err != nil || !prefix.Addr().Is4() {
+			continue
+		}
+		if prefix.Bits() < 22 {
+			return nil, fmt.Errorf("subnet %s of %s is too large to probe; set cluster.dhcp_pool in homeops.yaml", prefix.Masked(), bridge)
+		}
+		first := prefix.Masked().Addr().Next()
+		last := first
+		for next := first.Next(); prefix.Contains(next.Next()); next = next.Next() {
+			last = next // stops short of the broadcast address
+		}
+		return addrRange(first, last)
+	}
+	return nil, fmt.Errorf("%s has no IPv4 address on the NAS; set cluster.dhcp_pool in homeops.yaml", bridge)
+}
+
+func addrRange(start, end netip.Addr) ([]string, error) {
+	var addrs []string
+	for addr := start; addr.Compare(end) <= 0; addr = addr.Next() {
+		if len(addrs) == maxARPProbeHosts {
+			return nil, fmt.Errorf("%s-%s has more than %d addresses to probe", start, end, maxARPProbeHosts)
+		}
+		addrs = append(addrs, addr.String())
+	}
+	return addrs, nil
+}
+
+// findIPForMAC returns the first IPv4 address on a line of text that names
+// mac (any case, ':' or '-' separated), skipping `ip neigh` entries that
+// never resolved.
+func findIPForMAC(text, mac string) string {
+	mac = strings.ToLower(strings.ReplaceAll(mac, "-", ":"))
+	for _, line := range strings.Split(text, "\n") {
+		normalized := strings.ToLower(strings.ReplaceAll(line, "-", ":"))
+		if !strings.Contains(normalized, mac) || strings.Contains(line, "FAILED") || strings.Contains(line, "INCOMPLETE") {
+			continue
+		}
+		for _, candidate := range ipv4Pattern.FindAllString(line, -1) {
+			if addr, err := netip.ParseAddr(candidate); err == nil && addr.Is4() {
+				return candidate
+			}
+		}
+	}
+	return ""
+}
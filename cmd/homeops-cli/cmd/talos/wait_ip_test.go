@@ -0,0 +1,116 @@
+package talos
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"homeops-cli/internal/common"
+	versionconfig "homeops-cli/internal/config"
+	"homeops-cli/internal/constants"
+	vmprov "homeops-cli/internal/provider"
+	"homeops-cli/internal/ssh"
+	"homeops-cli/internal/testutil"
+	"homeops-cli/internal/truenas"
+	"homeops-cli/internal/vmlifecycle"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeployVMWaitForIPEndsWithTheNodeIP(t *testing.T) {
+	t.Cleanup(versionconfig.SetForTesting(&versionconfig.Config{Cluster: versionconfig.ClusterConfig{
+		DHCPPool: versionconfig.DHCPPoolConfig{Start: "192.168.122.100", End: "192.168.122.102"},
+	}}))
+	manager := &fakeTrueNASVMManager{
+		summaries: []vmprov.VMSummary{{Name: "app01", ID: "7", Status: "STOPPED"}},
+		nicMACs:   map[string][]string{"app01": {"00:A0:98:12:34:56"}},
+	}
+	polls := 0
+	sshClient := &fakeTrueNASSSHClient{exists: true, size: 1 << 20, output: func(string) string {
+		if polls++; polls < 2 {
+			return "192.168.122.100 lladdr 00:a0:98:00:00:01 REACHABLE\n"
+		}
+		return "192.168.122.102 FAILED\n192.168.122.101 lladdr 00:a0:98:12:34:56 REACHABLE\n"
+	}}
+	var stdout bytes.Buffer
+	testutil.Swap(t, &vmlifecycle.NewTrueNASVMManagerFn, func(string, string, int, bool) vmlifecycle.TrueNASVMManager { return manager })
+	testutil.Swap(t, &vmlifecycle.ResolveSecretKeyFn, func(string) string { return "nas-admin" })
+	testutil.Swap(t, &spinWithProgressFn, func(_ string, fn func(func(string)) error) error { return fn(func(string) {}) })
+	testutil.Swap(t, &newTrueNASSSHClientFn, func(ssh.SSHConfig) trueNASSSHClient { return sshClient })
+	testutil.Swap(t, &ipWaitPollInterval, time.Millisecond)
+	testutil.Swap[io.Writer](t, &deployVMStdout, &stdout)
+	t.Setenv(constants.EnvTrueNASHost, "nas.example.test")
+	t.Setenv(constants.EnvTrueNASAPIKey, "api-key-placeholder")
+	t.Setenv(constants.EnvSPICEPassword, "spice-placeholder")
+
+	wait := trueNASIPWait{Timeout: time.Minute}
+	require.NoError(t, deployVMWithPattern(context.Background(), "app01", "flashstor/VM", 8192, 4, 40, 100, "", "", true, false, false, false, false, false, false, false, truenas.CPUPlacement{}, truenas.ZVolOptions{}, nil, wait))
+	assert.Equal(t, []string{"app01"}, manager.started, "a stopped VM is started before waiting")
+	assert.Equal(t, "Node IP: 192.168.122.101\n", stdout.String())
+	require.Len(t, sshClient.commands, 2)
+	assert.Equal(t, `for ip in 192.168.122.100 192.168.122.101 192.168.122.102; do ping -c 1 -W 1 "$ip" >/dev/null 2>&1 & done; wait; ip -4 neigh show dev 'br0'`, sshClient.commands[0])
+	assert.Equal(t, 2, sshClient.closeCalls, "the ISO check and the wait each close their SSH session")
+}
+
+func TestWaitForTrueNASNodeIPFromDHCPLeases(t *testing.T) {
+	t.Cleanup(versionconfig.SetForTesting(&versionconfig.Config{Hypervisors: versionconfig.HypervisorsConfig{
+		TrueNAS: versionconfig.TrueNASConfig{IPDiscovery: versionconfig.IPDiscoveryConfig{Method: "dhcp", LeasesURL: "http://router.test/leases"}},
+	}}))
+	manager := &fakeTrueNASVMManager{
+		summaries: []vmprov.VMSummary{{Name: "app01", Status: "RUNNING"}},
+		nicMACs:   map[string][]string{"app01": {"00:a0:98:12:34:56"}},
+	}
+	var urls []string
+	testutil.Swap(t, &fetchDHCPLeasesFn, func(_ context.Context, url string) (string, error) {
+		urls = append(urls, url)
+		return "1760000000 00:a0:98:12:34:56 192.168.122.57 talos-abc *\n", nil
+	})
+	config := truenas.VMConfig{Name: "app01", NetworkBridge: "br0"}
+	ip, err := waitForTrueNASNodeIP(context.Background(), common.NewColorLogger(), manager, "nas", config, trueNASIPWait{Timeout: time.Minute})
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.122.57", ip)
+	assert.Equal(t, []string{"http://router.test/leases"}, urls)
+	assert.Empty(t, manager.started, "a running VM is not started again")
+
+	testutil.Swap(t, &ipWaitPollInterval, time.Millisecond)
+	testutil.Swap(t, &fetchDHCPLeasesFn, func(context.Context, string) (string, error) { return "", nil })
+	_, err = waitForTrueNASNodeIP(context.Background(), common.NewColorLogger(), manager, "nas", config, trueNASIPWait{Timeout: 20 * time.Millisecond})
+	require.EqualError(t, err, "no IP address for app01 (MAC 00:a0:98:12:34:56) within 20ms via dhcp; check the console ('homeops-cli vm console --provider truenas app01')")
+
+	_, err = waitForTrueNASNodeIP(context.Background(), common.NewColorLogger(), manager, "nas", config, trueNASIPWait{Timeout: time.Minute, Method: "mdns"})
+	require.EqualError(t, err, `--ip-discovery "mdns" is not supported (use arp or dhcp)`)
+}
+
+func TestARPProbeTargetsFallBackToTheBridgeSubnet(t *testing.T) {
+	t.Cleanup(versionconfig.SetForTesting(&versionconfig.Config{}))
+	sshClient := &fakeTrueNASSSHClient{output: func(string) string {
+		return "5: br0    inet 192.168.122.10/29 brd 192.168.122.15 scope global br0       valid_lft forever preferred_lft forever\n"
+	}}
+	targets, err := arpProbeTargets(sshClient, "br0")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"192.168.122.9", "192.168.122.10", "192.168.122.11", "192.168.122.12", "192.168.122.13", "192.168.122.14"}, targets)
+	assert.Equal(t, []string{"ip -4 -o addr show dev 'br0'"}, sshClient.commands)
+
+	sshClient.output = func(string) string { return "5: br0    inet 10.0.0.2/16 brd 10.0.255.255 scope global br0\n" }
+	_, err = arpProbeTargets(sshClient, "br0")
+	require.EqualError(t, err, "subnet 10.0.0.0/16 of br0 is too large to probe; set cluster.dhcp_pool in homeops.yaml")
+}
+
+func TestFindIPForMAC(t *testing.T) {
+	for name, tc := range map[string]struct {
+		text string
+		want string
+	}{
+		"ip neigh":         {"192.168.122.5 lladdr 00:a0:98:12:34:56 STALE", "192.168.122.5"},
+		"upper-case dash":  {"00-A0-98-12-34-56,192.168.122.6,talos", "192.168.122.6"},
+		"json lease":       {`{"hw-address":"00:a0:98:12:34:56","ip-address":"192.168.122.7"}`, "192.168.122.7"},
+		"unresolved entry": {"192.168.122.8 lladdr 00:a0:98:12:34:56 FAILED", ""},
+		"other mac":        {"192.168.122.9 lladdr 00:a0:98:12:34:57 REACHABLE", ""},
+	} {
+		assert.Equal(t, tc.want, findIPForMAC(strings.Repeat("noise\n", 2)+tc.text, "00:a0:98:12:34:56"), name)
+	}
+}
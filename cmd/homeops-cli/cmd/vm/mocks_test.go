@@ -5,9 +5,11 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	vmprov "homeops-cli/internal/provider"
 	"homeops-cli/internal/proxmox"
+	"homeops-cli/internal/testutil"
 	"homeops-cli/internal/truenas"
 	"homeops-cli/internal/vmlifecycle"
 
@@ -32,6 +34,8 @@ type fakeTrueNASVMManager struct {
 	started      []string
 	stopped      []string
 	restarted    []string
+	suspended    []string
+	resumed      []string
 	deleted      []string
 	infoNames    []string
 	setCalls     []string
@@ -40,7 +44,21 @@ type fakeTrueNASVMManager struct {
 	cloneCalls   []string
 	ips          []string
 	consoleURL   string
+	console      *truenas.ConsoleInfo
 	cleanupPairs []string
+	serialLogs   map[string]string
+	configISOs   map[string]string
+	zvols        map[string][]string
+	cpuCalls     []truenas.CPUPlacementUpdate
+	cpuModels    []string
+	topology     truenas.HostTopology
+	topologySSH  bool
+	policyCalls  []string
+	policyTasks  []truenas.VMSnapshotTask
+	prunePlan    truenas.SnapshotPrunePlan
+	pruned       []truenas.SnapshotPrunePlan
+	usage        truenas.VMUsageReport
+	usageWindows []time.Duration
 	connectErr   error
 	closeErr     error
 }
@@ -51,6 +69,9 @@ func (f *fakeTrueNASVMManager) DeployVM(config truenas.VMConfig) error {
 	f.deployed = append(f.deployed, config)
 	return f.deployErr
 }
+func (f *fakeTrueNASVMManager) VerifyVM(truenas.VMConfig) ([]truenas.VMDrift, error) {
+	return nil, nil
+}
 func (f *fakeTrueNASVMManager) ListVMs() error { f.listCalls++; return nil }
 func (f *fakeTrueNASVMManager) VMSummaries() ([]vmprov.VMSummary, error) {
 	f.listCalls++
@@ -76,11 +97,75 @@ func (f *fakeTrueNASVMManager) CleanupOrphanedZVols(vmName, storagePool string)
 	f.cleanupPairs = append(f.cleanupPairs, vmName+":"+storagePool)
 	return nil
 }
+func (f *fakeTrueNASVMManager) MiddlewareVersion() (truenas.MiddlewareVersion, error) {
+	return truenas.ParseMiddlewareVersion("TrueNAS-SCALE-24.10.2")
+}
+func (f *fakeTrueNASVMManager) SerialLogPath(name string) (string, error) {
+	if logPath, ok := f.serialLogs[name]; ok {
+		return logPath, nil
+	}
+	return "", fmt.Errorf("VM '%s' has no serial console log attached", name)
+}
+func (f *fakeTrueNASVMManager) ConfigISOPath(name string) (string, error) {
+	return f.configISOs[name], nil
+}
+func (f *fakeTrueNASVMManager) VMZVols(name string) ([]string, error) {
+	return f.zvols[name], nil
+}
+func (f *fakeTrueNASVMManager) ConsoleInfo(name string) (*truenas.ConsoleInfo, error) {
+	if f.console == nil {
+		return nil, fmt.Errorf("VM %s has no display device", name)
+	}
+	return f.console, nil
+}
+
+func (f *fakeTrueNASVMManager) SetVMCPUPlacement(name string, update truenas.CPUPlacementUpdate) error {
+	f.setCalls = append(f.setCalls, "cpu:"+name)
+	f.cpuCalls = append(f.cpuCalls, update)
+	return nil
+}
+func (f *fakeTrueNASVMManager) CPUModelChoices() ([]string, error) { return f.cpuModels, nil }
+func (f *fakeTrueNASVMManager) HostTopology(sshExec truenas.SSHRunner) (truenas.HostTopology, error) {
+	f.topologySSH = sshExec != nil
+	return f.topology, nil
+}
+func (f *fakeTrueNASVMManager) ApplySnapshotPolicy(name string, policy truenas.SnapshotPolicy) error {
+	f.policyCalls = append(f.policyCalls, fmt.Sprintf("%s:%d:%d:%d", name, policy.KeepHourly, policy.KeepDaily, policy.KeepWeekly))
+	return nil
+}
+func (f *fakeTrueNASVMManager) SnapshotPolicies(name string) ([]truenas.VMSnapshotTask, error) {
+	return f.policyTasks, nil
+}
+func (f *fakeTrueNASVMManager) PlanSnapshotPrune(name string) (truenas.SnapshotPrunePlan, error) {
+	plan := f.prunePlan
+	plan.VM = name
+	return plan, nil
+}
+func (f *fakeTrueNASVMManager) PruneVMSnapshots(plan truenas.SnapshotPrunePlan) error {
+	f.pruned = append(f.pruned, plan)
+	return nil
+}
+func (f *fakeTrueNASVMManager) VMUsage(sshExec truenas.SSHRunner, end time.Time, window time.Duration) (truenas.VMUsageReport, error) {
+	f.usageWindows = append(f.usageWindows, window)
+	return f.usage, nil
+}
 
 func (f *fakeTrueNASVMManager) RestartVM(name string) error {
 	f.restarted = append(f.restarted, name)
 	return nil
 }
+func (f *fakeTrueNASVMManager) PowerCycleVM(name string) error {
+	f.restarted = append(f.restarted, "power-cycle:"+name)
+	return nil
+}
+func (f *fakeTrueNASVMManager) SuspendVM(name string) error {
+	f.suspended = append(f.suspended, name)
+	return nil
+}
+func (f *fakeTrueNASVMManager) ResumeVM(name string) error {
+	f.resumed = append(f.resumed, name)
+	return nil
+}
 func (f *fakeTrueNASVMManager) SetVMResources(name string, memoryMB, vcpus int) error {
 	f.setCalls = append(f.setCalls, fmt.Sprintf("%s:%d:%d", name, memoryMB, vcpus))
 	return nil
@@ -169,6 +254,9 @@ func (f *fakeProxmoxVMManager) UploadISOFromURL(isoURL, filename, storageName st
 	f.uploads = append(f.uploads, isoURL+"|"+filename+"|"+storageName)
 	return nil
 }
+func (f *fakeProxmoxVMManager) ISOSize(filename, storageName string) (int64, error) {
+	return 0, nil
+}
 func (f *fakeProxmoxVMManager) DeployVM(config proxmox.VMConfig) error {
 	if f.deployFunc != nil {
 		return f.deployFunc(config)
@@ -255,6 +343,14 @@ func (f *fakeVMLifecycle) StopVM(name string, force bool) error {
 	return nil
 }
 
+func (f *fakeVMLifecycle) SetStopTimeout(timeout time.Duration) {
+	*f.calls = append(*f.calls, fmt.Sprintf("stop-timeout-%s:%s", f.provider, timeout))
+}
+
+func (f *fakeVMLifecycle) KeepZVols() {
+	*f.calls = append(*f.calls, "keep-zvols-"+f.provider)
+}
+
 func (f *fakeVMLifecycle) DeleteVM(name string) error {
 	*f.calls = append(*f.calls, "delete-"+f.provider+":"+name)
 	return nil
@@ -330,6 +426,7 @@ func injectFakeVMLifecycle(t *testing.T) (*[]string, *int) {
 	t.Helper()
 	oldFactory := vmlifecycle.NewVMLifecycleFn
 	t.Cleanup(func() { vmlifecycle.NewVMLifecycleFn = oldFactory })
+	testutil.Swap(t, &trueNASVMZVolsFn, func(string) ([]string, error) { return nil, nil })
 
 	calls := &[]string{}
 	closed := new(int)
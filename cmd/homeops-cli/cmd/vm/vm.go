@@ -4,16 +4,20 @@
 package vm
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"maps"
 	"slices"
 	"strings"
+	"time"
 
 	"homeops-cli/internal/cmdutil"
 	"homeops-cli/internal/common"
 	versionconfig "homeops-cli/internal/config"
 	vmprov "homeops-cli/internal/provider"
+	"homeops-cli/internal/truenas"
 	"homeops-cli/internal/ui"
 	"homeops-cli/internal/vmlifecycle"
 
@@ -69,10 +73,12 @@ func NewVMLifecycleRootGuidanceCommand(action string) *cobra.Command {
 // vmVerbGroups organizes the lifecycle verbs in help output.
 var vmVerbGroups = map[string]string{
 	"create": "provision", "template": "provision", "clone": "provision",
-	"set": "day2", "resize-disk": "day2", "snapshot": "day2", "cleanup-zvols": "day2",
-	"list": "power", "start": "power", "stop": "power", "poweron": "power",
-	"poweroff": "power", "restart": "power", "delete": "power", "info": "power",
-	"ip": "access", "ssh": "access", "console": "access",
+	"set": "day2", "resize-disk": "day2", "snapshot": "day2", "cleanup-zvols": "day2", "host-topology": "day2",
+	"snapshot-policy": "day2",
+	"usage":           "day2",
+	"list":            "power", "start": "power", "stop": "power", "poweron": "power",
+	"poweroff": "power", "restart": "power", "suspend": "power", "resume": "power", "delete": "power", "info": "power",
+	"ip": "access", "ssh": "access", "console": "access", "console-info": "access", "console-log": "access",
 }
 
 func addVMVerbGroups(cmd *cobra.Command, subcommands []*cobra.Command) {
@@ -109,17 +115,18 @@ hypervisors.default from homeops.yaml.`,
   homeops-cli vm vsphere info --name vc0
   homeops-cli vm list                  # shorthand: hypervisors.default`,
 	}
+	cmdutil.AddTrueNASTLSFlags(cmd.PersistentFlags())
 	// Provider-first groups are the visible structure.
 	for _, p := range []string{"proxmox", "truenas", "vsphere"} {
 		cmd.AddCommand(newProviderScopedVMGroup(p))
 	}
-	// Flat verbs stay as hidden shorthands for the default provider. cleanup-zvols
-	// is a TrueNAS-only operation (it has no --provider flag and always talks to
-	// the NAS); exposing it as a flat default-provider shorthand would silently
-	// hit TrueNAS even when hypervisors.default is proxmox/vsphere, so keep it
-	// reachable only under `vm truenas cleanup-zvols`.
+	// Flat verbs stay as hidden shorthands for the default provider. cleanup-zvols,
+	// console-info, console-log, host-topology, snapshot-policy, suspend/resume, and usage are TrueNAS-only operations (they always
+	// talk to the NAS); exposing them as flat default-provider
+	// shorthands would silently hit TrueNAS even when hypervisors.default is
+	// proxmox/vsphere, so keep them reachable only under `vm truenas`.
 	for _, sub := range vmLifecycleSubcommands() {
-		if sub.Name() == "cleanup-zvols" {
+		if trueNASOnlyVerbs[sub.Name()] {
 			continue
 		}
 		sub.Hidden = true
@@ -156,6 +163,8 @@ func lifecycleSubcommandSet() []*cobra.Command {
 		newSetVMCommand(),
 		newResizeDiskCommand(),
 		newRestartVMCommand(),
+		newSuspendVMCommand(),
+		newResumeVMCommand(),
 		newListVMsCommand(),
 		newStartVMCommand(),
 		newStopVMCommand(),
@@ -164,9 +173,17 @@ func lifecycleSubcommandSet() []*cobra.Command {
 		newDeleteVMCommand(),
 		newInfoVMCommand(),
 		newCleanupZVolsCommand(),
+		newConsoleLogCommand(),
+		newConsoleInfoCommand(),
+		newHostTopologyCommand(),
+		newSnapshotPolicyCommand(),
+		newVMUsageCommand(),
 	}
 }
 
+// trueNASOnlyVerbs are only registered under `vm truenas`.
+var trueNASOnlyVerbs = map[string]bool{"cleanup-zvols": true, "console-info": true, "console-log": true, "host-topology": true, "resume": true, "snapshot-policy": true, "suspend": true, "usage": true}
+
 // newProviderScopedVMGroup builds one provider's verb set with --provider
 // pinned to that hypervisor (and the flag hidden), e.g. `vm truenas list`.
 func newProviderScopedVMGroup(provider string) *cobra.Command {
@@ -180,7 +197,7 @@ func newProviderScopedVMGroup(provider string) *cobra.Command {
 	var subcommands []*cobra.Command
 	for _, sub := range vmLifecycleSubcommands() {
 		// TrueNAS-only verbs don't belong under the other providers.
-		if sub.Name() == "cleanup-zvols" && provider != "truenas" {
+		if trueNASOnlyVerbs[sub.Name()] && provider != "truenas" {
 			continue
 		}
 		// --provider may be a local flag (most verbs) or a persistent one
@@ -221,15 +238,26 @@ Provider prerequisites:
   vSphere/ESXi: host, username, and password from environment or 1Password`,
 	}
 
+	cmdutil.AddTrueNASTLSFlags(cmd.PersistentFlags())
 	cmd.AddCommand(
 		newListVMsCommand(),
 		newStartVMCommand(),
 		newStopVMCommand(),
 		newPowerOnVMCommand(),
 		newPowerOffVMCommand(),
+		newRestartVMCommand(),
+		newSuspendVMCommand(),
+		newResumeVMCommand(),
 		newDeleteVMCommand(),
 		newInfoVMCommand(),
+		newSnapshotCommand(),
+		newCloneVMCommand(),
 		newCleanupZVolsCommand(),
+		newConsoleLogCommand(),
+		newConsoleInfoCommand(),
+		newHostTopologyCommand(),
+		newSnapshotPolicyCommand(),
+		newVMUsageCommand(),
 	)
 
 	return cmd
@@ -247,12 +275,12 @@ func newListVMsCommand() *cobra.Command {
   homeops-cli vm list --all-providers`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if allProviders {
-				return listAllProviderVMs(output)
+				return listAllProviderVMs(cmd.Context(), output)
 			}
 			if err := vmlifecycle.EnsureVMLifecycleProviderFn(provider, "list"); err != nil {
 				return err
 			}
-			return listVMs(provider, output)
+			return listVMs(cmd.Context(), provider, output)
 		},
 	}
 
@@ -273,7 +301,7 @@ func newListAllVMsCommand() *cobra.Command {
 		Example: `  homeops-cli vm list-all
   homeops-cli vm list-all --output json`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return listAllProviderVMs(output)
+			return listAllProviderVMs(cmd.Context(), output)
 		},
 	}
 	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table, json, or yaml")
@@ -301,13 +329,13 @@ type allProviderVMInventory struct {
 	ProviderErrors []providerError     `json:"provider_errors,omitempty" yaml:"provider_errors,omitempty"`
 }
 
-func listVMs(provider, output string) error {
+func listVMs(ctx context.Context, provider, output string) error {
 	normalizedProvider, err := vmlifecycle.NormalizeVMProvider(provider)
 	if err != nil {
 		return err
 	}
 
-	return vmlifecycle.WithVMLifecycle(normalizedProvider, func(lifecycle vmprov.VMLifecycle) error {
+	return vmlifecycle.WithVMLifecycleContext(ctx, normalizedProvider, func(lifecycle vmprov.VMLifecycle) error {
 		summaries, err := lifecycle.VMSummaries()
 		if err != nil {
 			return err
@@ -316,8 +344,8 @@ func listVMs(provider, output string) error {
 	})
 }
 
-func listAllProviderVMs(output string) error {
-	inventory, err := collectAllProviderVMs()
+func listAllProviderVMs(ctx context.Context, output string) error {
+	inventory, err := collectAllProviderVMs(ctx)
 	if err != nil {
 		return err
 	}
@@ -331,10 +359,10 @@ func listAllProviderVMs(output string) error {
 	return nil
 }
 
-func collectAllProviderVMs() (allProviderVMInventory, error) {
+func collectAllProviderVMs(ctx context.Context) (allProviderVMInventory, error) {
 	var inventory allProviderVMInventory
 	for _, provider := range []string{"proxmox", "truenas", "vsphere"} {
-		err := vmlifecycle.WithVMLifecycle(provider, func(lifecycle vmprov.VMLifecycle) error {
+		err := vmlifecycle.WithVMLifecycleContext(ctx, provider, func(lifecycle vmprov.VMLifecycle) error {
 			summaries, err := lifecycle.VMSummaries()
 			if err != nil {
 				return err
@@ -439,24 +467,28 @@ func renderVMInventory(inventory vmInventory, output string) error {
 
 func newStartVMCommand() *cobra.Command {
 	var (
-		name     string
+		targets  vmTargets
 		provider string
 	)
 
 	cmd := &cobra.Command{
 		Use:   "start",
 		Short: "Start a VM on Proxmox, TrueNAS, or vSphere/ESXi",
-		Long:  `Start a VM on Proxmox, TrueNAS, or vSphere/ESXi. If --name is not specified, presents an interactive selector.`,
+		Long:  `Start a VM on Proxmox, TrueNAS, or vSphere/ESXi. If --name is not specified, presents an interactive selector. --name k8s0,k8s1 or --match 'k8s*' starts several VMs, --parallel at a time.`,
+		Example: `  homeops-cli vm start --name k8s0
+  homeops-cli vm truenas start --match 'k8s*'`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := vmlifecycle.EnsureVMLifecycleProviderFn(provider, "start"); err != nil {
 				return err
 			}
-			return startVMWithProvider(name, provider)
+			return runVMTargets(cmd.Context(), provider, "start", targets, func(name string) error {
+				return startVMWithProvider(cmd.Context(), name, provider)
+			})
 		},
 	}
 
 	addProviderFlag(cmd, &provider)
-	cmd.Flags().StringVar(&name, "name", "", "VM name (optional - will prompt if not provided)")
+	addVMTargetFlags(cmd, &targets, true)
 
 	// Add completion for name flag
 	_ = cmd.RegisterFlagCompletionFunc("name", vmNameCompletion)
@@ -465,46 +497,96 @@ func newStartVMCommand() *cobra.Command {
 }
 
 // startVMWithProvider starts a VM on the specified provider with interactive selector
-func startVMWithProvider(name, provider string) error {
-	return vmlifecycle.RunVMLifecycleAction(name, provider, "start", func(lifecycle vmprov.VMLifecycle, vmName string) error {
+func startVMWithProvider(ctx context.Context, name, provider string) error {
+	return vmlifecycle.RunVMLifecycleActionContext(ctx, name, provider, "start", func(lifecycle vmprov.VMLifecycle, vmName string) error {
 		return lifecycle.StartVM(vmName)
 	})
 }
 
-// stopVMWithProvider stops a VM on the specified provider with interactive selector
-func stopVMWithProvider(name, provider string) error {
-	return vmlifecycle.RunVMLifecycleAction(name, provider, "stop", func(lifecycle vmprov.VMLifecycle, vmName string) error {
-		return lifecycle.StopVM(vmName, false)
+// stopVMWithProvider stops a VM on the specified provider with interactive
+// selector. On TrueNAS a stop without force waits up to stopTimeout (zero is
+// the default) for the guest to shut down before forcing it off.
+func stopVMWithProvider(ctx context.Context, name, provider string, force bool, stopTimeout time.Duration) error {
+	return vmlifecycle.RunVMLifecycleActionContext(ctx, name, provider, "stop", func(lifecycle vmprov.VMLifecycle, vmName string) error {
+		vmlifecycle.BindStopTimeout(stopTimeout, lifecycle)
+		return lifecycle.StopVM(vmName, force)
 	})
 }
 
-// infoVMWithProvider gets VM info from the specified provider with interactive selector
-func infoVMWithProvider(name, provider string) error {
-	return vmlifecycle.RunVMLifecycleAction(name, provider, "get info", func(lifecycle vmprov.VMLifecycle, vmName string) error {
-		return lifecycle.GetVMInfo(vmName)
+// infoVMWithProvider gets VM info from the specified provider with interactive selector.
+// The table output is the provider's own report; json and yaml emit the VM's
+// entry of `vm list --output json|yaml`.
+func infoVMWithProvider(ctx context.Context, name, provider, output string) error {
+	switch output {
+	case "", "table", "json", "yaml":
+	default:
+		return fmt.Errorf("unsupported output format %q (table, json, yaml)", output)
+	}
+	return vmlifecycle.RunVMLifecycleActionContext(ctx, name, provider, "get info", func(lifecycle vmprov.VMLifecycle, vmName string) error {
+		if output == "" || output == "table" {
+			return lifecycle.GetVMInfo(vmName)
+		}
+		summaries, err := lifecycle.VMSummaries()
+		if err != nil {
+			return err
+		}
+		for _, summary := range summaries {
+			if summary.Name == vmName {
+				rendered, err := renderVMSummary(summary, output)
+				if err != nil {
+					return err
+				}
+				fmt.Println(rendered)
+				return nil
+			}
+		}
+		return fmt.Errorf("VM %s not found", vmName)
 	})
 }
 
+func renderVMSummary(summary vmprov.VMSummary, output string) (string, error) {
+	if output == "yaml" {
+		raw, err := yaml.Marshal(summary)
+		return strings.TrimSuffix(string(raw), "\n"), err
+	}
+	raw, err := json.MarshalIndent(summary, "", "  ")
+	return string(raw), err
+}
+
 func newStopVMCommand() *cobra.Command {
 	var (
-		name     string
-		provider string
+		targets     vmTargets
+		provider    string
+		force       bool
+		stopTimeout time.Duration
 	)
 
 	cmd := &cobra.Command{
 		Use:   "stop",
 		Short: "Stop a VM on Proxmox, TrueNAS, or vSphere/ESXi",
-		Long:  `Stop a VM on Proxmox, TrueNAS, or vSphere/ESXi. If --name is not specified, presents an interactive selector.`,
+		Long: `Stop a VM on Proxmox, TrueNAS, or vSphere/ESXi. If --name is not specified, presents an interactive selector.
+
+On TrueNAS the guest is sent an ACPI shutdown and given --stop-timeout to
+power down; a VM still running then is forced off. --force powers it off at
+once, like 'vm poweroff' without the prompt.
+
+--name k8s0,k8s1 or --match 'k8s*' stops several VMs, --parallel at a time.`,
+		Example: `  homeops-cli vm stop --name k8s0
+  homeops-cli vm truenas stop --match 'k8s*' --stop-timeout 5m`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := vmlifecycle.EnsureVMLifecycleProviderFn(provider, "stop"); err != nil {
 				return err
 			}
-			return stopVMWithProvider(name, provider)
+			return runVMTargets(cmd.Context(), provider, "stop", targets, func(name string) error {
+				return stopVMWithProvider(cmd.Context(), name, provider, force, stopTimeout)
+			})
 		},
 	}
 
 	addProviderFlag(cmd, &provider)
-	cmd.Flags().StringVar(&name, "name", "", "VM name (optional - will prompt if not provided)")
+	addVMTargetFlags(cmd, &targets, true)
+	cmd.Flags().BoolVar(&force, "force", false, "power the VM off without waiting for a clean shutdown")
+	cmd.Flags().DurationVar(&stopTimeout, "stop-timeout", truenas.DefaultVMStopTimeout, "how long to wait for a clean shutdown before forcing the VM off (TrueNAS)")
 
 	// Add completion for name flag
 	_ = cmd.RegisterFlagCompletionFunc("name", vmNameCompletion)
@@ -514,26 +596,79 @@ func newStopVMCommand() *cobra.Command {
 
 func newDeleteVMCommand() *cobra.Command {
 	var (
-		name     string
-		force    bool
-		provider string
+		targets         vmTargets
+		force           bool
+		removeSerialLog bool
+		keepZVols       bool
+		dryRun          bool
+		provider        string
+		stopTimeout     time.Duration
 	)
 
 	cmd := &cobra.Command{
 		Use:   "delete",
 		Short: "Delete a VM on Proxmox, TrueNAS, or vSphere/ESXi",
-		Long:  `Delete a VM on Proxmox, TrueNAS (with ZVols), or vSphere/ESXi. If --name is not specified, presents an interactive selector.`,
+		Long: `Delete a VM on Proxmox, TrueNAS (with ZVols and any Talos config ISO), or vSphere/ESXi. If --name is not specified, presents an interactive selector.
+
+The TrueNAS ZVols deleted are exactly those the VM's disks are backed by
+(/dev/zvol/... paths, including custom --data-disk ZVols), with their
+snapshots, and the confirmation lists them; --keep-zvol leaves them in place.
+
+A running TrueNAS VM is stopped first as 'vm stop' does, with --stop-timeout
+for the clean shutdown; a VM that is still running after the forced power
+off is not deleted.
+
+--name k8s0,k8s1 or --match 'k8s*' deletes several VMs, --parallel at a time,
+after one confirmation listing them (or none with --force).
+
+--dry-run (TrueNAS) looks the VM up and prints its ID, the VM stop and
+delete, each ZVol that would be destroyed and the files removed with it,
+without changing anything.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := vmlifecycle.EnsureVMLifecycleProviderFn(provider, "delete"); err != nil {
 				return err
 			}
-			return deleteVMWithConfirmation(name, provider, force)
+			if dryRun {
+				// One VM at a time, so the plans print whole.
+				planTargets := targets
+				planTargets.parallel = 1
+				return runVMTargets(cmd.Context(), provider, "delete --dry-run", planTargets, func(name string) error {
+					return deleteVMDryRun(cmd.Context(), cmd.OutOrStdout(), name, provider, removeSerialLog, keepZVols)
+				})
+			}
+			if !targets.bulk() {
+				return deleteVMWithConfirmation(cmd.Context(), targets.name, provider, force, removeSerialLog, keepZVols, stopTimeout)
+			}
+			names, err := resolveVMTargets(cmd.Context(), provider, targets)
+			if err != nil {
+				return err
+			}
+			if !force {
+				disks := "and their disks"
+				if keepZVols {
+					disks = "keeping their disks"
+				}
+				confirmed, err := confirmActionFn(fmt.Sprintf("Delete %d VMs (%s) %s? This is destructive!", len(names), strings.Join(names, ", "), disks), false)
+				if err != nil {
+					return fmt.Errorf("confirmation failed: %w", err)
+				}
+				if !confirmed {
+					return fmt.Errorf("deletion cancelled")
+				}
+			}
+			return runVMBulk("delete", names, targets.parallel, func(name string) error {
+				return deleteVMWithConfirmation(cmd.Context(), name, provider, true, removeSerialLog, keepZVols, stopTimeout)
+			})
 		},
 	}
 
 	addProviderFlag(cmd, &provider)
-	cmd.Flags().StringVar(&name, "name", "", "VM name (optional - will prompt if not provided)")
+	addVMTargetFlags(cmd, &targets, true)
 	cmd.Flags().BoolVar(&force, "force", false, "Force deletion without confirmation")
+	cmd.Flags().BoolVar(&removeSerialLog, "remove-serial-log", false, "Also delete the VM's serial console log on the NAS (TrueNAS only)")
+	cmd.Flags().BoolVar(&keepZVols, "keep-zvol", false, "Keep the ZVols behind the VM's disks (TrueNAS only)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the VM, ZVols and files that would be deleted without deleting anything (TrueNAS only)")
+	cmd.Flags().DurationVar(&stopTimeout, "stop-timeout", truenas.DefaultVMStopTimeout, "how long to wait for a running VM to shut down cleanly before forcing it off (TrueNAS)")
 
 	// Add completion for name flag
 	_ = cmd.RegisterFlagCompletionFunc("name", vmNameCompletion)
@@ -541,7 +676,7 @@ func newDeleteVMCommand() *cobra.Command {
 	return cmd
 }
 
-func deleteVMWithConfirmation(name, provider string, force bool) error {
+func deleteVMWithConfirmation(ctx context.Context, name, provider string, force, removeSerialLog, keepZVols bool, stopTimeout time.Duration) error {
 	normalizedProvider, err := vmlifecycle.NormalizeVMProvider(provider)
 	if err != nil {
 		return err
@@ -554,19 +689,40 @@ func deleteVMWithConfirmation(name, provider string, force bool) error {
 	if name == "" {
 		return nil
 	}
+	if removeSerialLog && normalizedProvider != "truenas" {
+		return fmt.Errorf("--remove-serial-log is only supported with --provider truenas")
+	}
+	if keepZVols && normalizedProvider != "truenas" {
+		return fmt.Errorf("--keep-zvol is only supported with --provider truenas")
+	}
 
-	// Add confirmation for deletion
-	if !force {
-		var message string
-		switch normalizedProvider {
-		case "vsphere":
-			message = fmt.Sprintf("Delete VM '%s' on vSphere/ESXi? This is destructive!", name)
-		case "proxmox":
-			message = fmt.Sprintf("Delete VM '%s' on Proxmox? This is destructive!", name)
-		default:
-			message = fmt.Sprintf("Delete VM '%s' and all its ZVols on TrueNAS? This is destructive!", name)
+	// The datasets to be removed are named before anything is deleted.
+	var zvols []string
+	if normalizedProvider == "truenas" && !keepZVols {
+		if zvols, err = trueNASVMZVolsFn(name); err != nil {
+			return fmt.Errorf("failed to list the ZVols of VM '%s': %w", name, err)
 		}
+	}
 
+	// Add confirmation for deletion
+	var message string
+	switch {
+	case normalizedProvider == "vsphere":
+		message = fmt.Sprintf("Delete VM '%s' on vSphere/ESXi? This is destructive!", name)
+	case normalizedProvider == "proxmox":
+		message = fmt.Sprintf("Delete VM '%s' on Proxmox? This is destructive!", name)
+	case keepZVols:
+		message = fmt.Sprintf("Delete VM '%s' on TrueNAS, keeping its ZVols? This is destructive!", name)
+	case len(zvols) == 0:
+		message = fmt.Sprintf("Delete VM '%s' on TrueNAS? It has no ZVol disks. This is destructive!", name)
+	default:
+		message = fmt.Sprintf("Delete VM '%s' and its ZVols (%s) on TrueNAS? This is destructive!", name, strings.Join(zvols, ", "))
+	}
+	if force {
+		if len(zvols) > 0 {
+			common.NewColorLogger().Info("Deleting VM '%s' and its ZVols: %s", name, strings.Join(zvols, ", "))
+		}
+	} else {
 		confirmed, err := confirmActionFn(message, false)
 		if err != nil {
 			return fmt.Errorf("confirmation failed: %w", err)
@@ -576,31 +732,129 @@ func deleteVMWithConfirmation(name, provider string, force bool) error {
 		}
 	}
 
-	return vmlifecycle.WithVMLifecycle(normalizedProvider, func(lifecycle vmprov.VMLifecycle) error {
+	// The log path lives in the VM definition, so look it up before deleting.
+	var serialLogPath string
+	if removeSerialLog {
+		if serialLogPath, err = trueNASSerialLogPathFn(name); err != nil {
+			return err
+		}
+	}
+	// So does the Talos config ISO, which holds the node's secrets and is
+	// always removed with the VM.
+	var configISOPath string
+	if normalizedProvider == "truenas" {
+		if configISOPath, err = trueNASConfigISOPathFn(name); err != nil {
+			common.NewColorLogger().Warn("Could not check VM '%s' for a Talos config ISO: %v", name, err)
+		}
+	}
+
+	if err := vmlifecycle.WithVMLifecycleContext(ctx, normalizedProvider, func(lifecycle vmprov.VMLifecycle) error {
+		vmlifecycle.BindStopTimeout(stopTimeout, lifecycle)
+		vmlifecycle.BindKeepZVols(keepZVols, lifecycle)
 		return lifecycle.DeleteVM(name)
-	})
+	}); err != nil {
+		return err
+	}
+
+	if serialLogPath != "" {
+		if err := removeTrueNASFileFn(serialLogPath); err != nil {
+			return fmt.Errorf("VM '%s' deleted but removing serial log %s failed: %w", name, serialLogPath, err)
+		}
+		common.NewColorLogger().Info("Removed serial console log %s", serialLogPath)
+	}
+	if configISOPath != "" {
+		if err := removeTrueNASFileFn(configISOPath); err != nil {
+			return fmt.Errorf("VM '%s' deleted but removing Talos config ISO %s failed: %w", name, configISOPath, err)
+		}
+		common.NewColorLogger().Info("Removed Talos config ISO %s", configISOPath)
+	}
+	return nil
+}
+
+// deleteVMDryRun prints what delete would remove for name on TrueNAS. The VM
+// and its disks are read from the NAS; the stop, delete and dataset calls
+// are recorded instead of made.
+func deleteVMDryRun(ctx context.Context, out io.Writer, name, provider string, removeSerialLog, keepZVols bool) error {
+	normalizedProvider, err := vmlifecycle.NormalizeVMProvider(provider)
+	if err != nil {
+		return err
+	}
+	if normalizedProvider != "truenas" {
+		return fmt.Errorf("--dry-run is only supported with --provider truenas")
+	}
+	name, err = vmlifecycle.ChooseVMNameForProvider(name, normalizedProvider, "delete")
+	if err != nil || name == "" {
+		return err
+	}
+
+	plan := &truenas.DryRunPlan{}
+	if err := vmlifecycle.WithVMLifecycleContext(ctx, normalizedProvider, func(lifecycle vmprov.VMLifecycle) error {
+		if !vmlifecycle.BindDryRun(plan, lifecycle) {
+			return fmt.Errorf("this TrueNAS connection cannot plan a delete without making it")
+		}
+		vmlifecycle.BindKeepZVols(keepZVols, lifecycle)
+		return lifecycle.DeleteVM(name)
+	}); err != nil {
+		return err
+	}
+	lines := plan.Lines()
+	if removeSerialLog {
+		serialLogPath, err := trueNASSerialLogPathFn(name)
+		if err != nil {
+			return err
+		}
+		if serialLogPath != "" {
+			lines = append(lines, "Remove serial console log "+serialLogPath)
+		}
+	}
+	if configISOPath, err := trueNASConfigISOPathFn(name); err != nil {
+		common.NewColorLogger().Warn("Could not check VM '%s' for a Talos config ISO: %v", name, err)
+	} else if configISOPath != "" {
+		lines = append(lines, "Remove Talos config ISO "+configISOPath)
+	}
+
+	_, _ = fmt.Fprintf(out, "Dry run: deleting VM '%s' on TrueNAS would:\n", name)
+	for _, line := range lines {
+		_, _ = fmt.Fprintln(out, "  "+line)
+	}
+	_, _ = fmt.Fprintln(out, "Nothing was changed.")
+	return nil
 }
 
 func newInfoVMCommand() *cobra.Command {
 	var (
-		name     string
+		targets  vmTargets
 		provider string
+		output   string
 	)
 
 	cmd := &cobra.Command{
 		Use:   "info",
 		Short: "Get detailed information about a VM on Proxmox, TrueNAS, or vSphere/ESXi",
-		Long:  `Get detailed information about a VM on Proxmox, TrueNAS, or vSphere/ESXi. If --name is not specified, presents an interactive selector.`,
+		Long: `Get detailed information about a VM on Proxmox, TrueNAS, or vSphere/ESXi. If --name is not specified, presents an interactive selector.
+
+--output json|yaml prints the VM's entry of 'vm list --output json|yaml'
+instead: name, id, status, memory_mb, cpus, details, and on TrueNAS its typed
+devices and the ZVols behind its disks.
+
+--name k8s0,k8s1 or --match 'k8s*' reports on several VMs, one at a time so
+their reports do not interleave.`,
+		Example: `  homeops-cli vm info --provider truenas --name k8s_0
+  homeops-cli vm info --provider truenas --name k8s_0 --output json | jq '.zvols'
+  homeops-cli vm info --provider truenas --match 'k8s_*'`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := vmlifecycle.EnsureVMLifecycleProviderFn(provider, "info"); err != nil {
 				return err
 			}
-			return infoVMWithProvider(name, provider)
+			return runVMTargets(cmd.Context(), provider, "info", targets, func(name string) error {
+				return infoVMWithProvider(cmd.Context(), name, provider, output)
+			})
 		},
 	}
 
 	addProviderFlag(cmd, &provider)
-	cmd.Flags().StringVar(&name, "name", "", "VM name (optional - will prompt if not provided)")
+	addVMTargetFlags(cmd, &targets, false)
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table, json, or yaml")
 
 	// Add completion for name flag
 	_ = cmd.RegisterFlagCompletionFunc("name", vmNameCompletion)
@@ -633,7 +887,7 @@ func newCleanupZVolsCommand() *cobra.Command {
 					return fmt.Errorf("cleanup cancelled")
 				}
 			}
-			return cleanupOrphanedZVols(vmName, storagePool)
+			return cleanupOrphanedZVols(cmd.Context(), vmName, storagePool)
 		},
 	}
 
@@ -646,10 +900,10 @@ func newCleanupZVolsCommand() *cobra.Command {
 }
 
 // cleanupOrphanedZVols deletes orphaned ZVols for a VM that no longer exists
-func cleanupOrphanedZVols(vmName, storagePool string) error {
+func cleanupOrphanedZVols(ctx context.Context, vmName, storagePool string) error {
 	logger := common.NewColorLogger()
 	logger.Info("Starting cleanup of orphaned ZVols for VM: %s", vmName)
-	return vmlifecycle.WithTrueNASVMManager(logger, func(vmManager vmlifecycle.TrueNASVMManager) error {
+	return vmlifecycle.WithTrueNASVMManagerContext(ctx, logger, func(vmManager vmlifecycle.TrueNASVMManager) error {
 		if err := vmManager.CleanupOrphanedZVols(vmName, storagePool); err != nil {
 			return fmt.Errorf("failed to cleanup orphaned ZVols: %w", err)
 		}
@@ -673,7 +927,7 @@ func newPowerOnVMCommand() *cobra.Command {
 			if err := vmlifecycle.EnsureVMLifecycleProviderFn(provider, "poweron"); err != nil {
 				return err
 			}
-			return powerOnVM(name, provider)
+			return powerOnVM(cmd.Context(), name, provider)
 		},
 	}
 
@@ -701,7 +955,7 @@ func newPowerOffVMCommand() *cobra.Command {
 			if err := vmlifecycle.EnsureVMLifecycleProviderFn(provider, "poweroff"); err != nil {
 				return err
 			}
-			return powerOffVM(name, provider, force)
+			return powerOffVM(cmd.Context(), name, provider, force)
 		},
 	}
 
@@ -716,8 +970,8 @@ func newPowerOffVMCommand() *cobra.Command {
 }
 
 // powerOnVM powers on a VM on the specified provider with interactive selector
-func powerOnVM(name, provider string) error {
-	return vmlifecycle.RunVMLifecycleAction(name, provider, "power on", func(lifecycle vmprov.VMLifecycle, vmName string) error {
+func powerOnVM(ctx context.Context, name, provider string) error {
+	return vmlifecycle.RunVMLifecycleActionContext(ctx, name, provider, "power on", func(lifecycle vmprov.VMLifecycle, vmName string) error {
 		return lifecycle.StartVM(vmName)
 	})
 }
@@ -726,8 +980,8 @@ func powerOnVM(name, provider string) error {
 // selector. The force-stop is destructive (no clean guest shutdown), so it is
 // gated behind a confirmation unless force is set (the global --yes also
 // satisfies confirmActionFn).
-func powerOffVM(name, provider string, force bool) error {
-	return vmlifecycle.RunVMLifecycleAction(name, provider, "power off", func(lifecycle vmprov.VMLifecycle, vmName string) error {
+func powerOffVM(ctx context.Context, name, provider string, force bool) error {
+	return vmlifecycle.RunVMLifecycleActionContext(ctx, name, provider, "power off", func(lifecycle vmprov.VMLifecycle, vmName string) error {
 		if !force {
 			ok, err := confirmActionFn(fmt.Sprintf("Force power off VM %q? The guest is not shut down cleanly and may lose unsaved data.", vmName), false)
 			if err != nil {
@@ -0,0 +1,139 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	vmprov "homeops-cli/internal/provider"
+	"homeops-cli/internal/ui"
+	"homeops-cli/internal/vmlifecycle"
+)
+
+// start, stop, restart, delete and info act on several VMs at once when
+// --name lists them (k8s0,k8s1,k8s2) or --match selects them by glob
+// (k8s*). Each VM runs on its own provider connection, --parallel at a
+// time, and a VM/RESULT table follows once all have finished.
+
+// vmTargets is the VM selection of one lifecycle verb.
+type vmTargets struct {
+	name     string
+	match    string
+	parallel int
+}
+
+// bulkVMStdout receives the per-VM result summary.
+var bulkVMStdout io.Writer = os.Stdout
+
+// bulk reports whether the selection names more than one VM, or a pattern.
+func (t vmTargets) bulk() bool {
+	return t.match != "" || strings.Contains(t.name, ",")
+}
+
+// addVMTargetFlags registers --name and --match on a lifecycle verb, and
+// --parallel unless its VMs are handled one at a time.
+func addVMTargetFlags(cmd *cobra.Command, targets *vmTargets, parallel bool) {
+	cmd.Flags().StringVar(&targets.name, "name", "", "VM name, or a comma-separated list (optional - will prompt if not provided)")
+	cmd.Flags().StringVar(&targets.match, "match", "", "act on every VM whose name matches this glob, e.g. 'k8s*'")
+	targets.parallel = 1
+	if parallel {
+		cmd.Flags().IntVar(&targets.parallel, "parallel", 3, "VMs to act on at once with a list or --match")
+	}
+}
+
+// resolveVMTargets is the listed names followed by the provider's VMs that
+// match the pattern (sorted), without duplicates.
+func resolveVMTargets(ctx context.Context, provider string, targets vmTargets) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(targets.name, ",") {
+		if name = strings.TrimSpace(name); name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	if targets.match == "" {
+		return names, nil
+	}
+	if _, err := path.Match(targets.match, ""); err != nil {
+		return nil, fmt.Errorf("invalid --match %q: %w", targets.match, err)
+	}
+	normalized, err := vmlifecycle.NormalizeVMProvider(provider)
+	if err != nil {
+		return nil, err
+	}
+	var summaries []vmprov.VMSummary
+	if err := vmlifecycle.WithVMLifecycleContext(ctx, normalized, func(lifecycle vmprov.VMLifecycle) error {
+		summaries, err = lifecycle.VMSummaries()
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	var matched []string
+	for _, summary := range summaries {
+		if ok, _ := path.Match(targets.match, summary.Name); ok && !slices.Contains(names, summary.Name) {
+			matched = append(matched, summary.Name)
+		}
+	}
+	if len(matched) == 0 && len(names) == 0 {
+		return nil, fmt.Errorf("no VMs on %s match %q", normalized, targets.match)
+	}
+	slices.Sort(matched)
+	return append(names, matched...), nil
+}
+
+// runVMBulk applies op to every VM, parallel at a time, then prints each
+// VM's result. It fails when any VM did.
+func runVMBulk(action string, names []string, parallel int, op func(name string) error) error {
+	if parallel <= 0 {
+		parallel = 1
+	}
+	errs := make([]error, len(names))
+	semaphore := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		// Taken before the goroutine starts, so VMs begin in order and
+		// --parallel 1 is a rolling operation.
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			errs[i] = op(name)
+		}()
+	}
+	wg.Wait()
+
+	rows := make([][]string, len(names))
+	failed := 0
+	for i, name := range names {
+		rows[i] = []string{name, "ok"}
+		if errs[i] != nil {
+			rows[i][1] = errs[i].Error()
+			failed++
+		}
+	}
+	_, _ = fmt.Fprintln(bulkVMStdout, ui.Table([]string{"VM", "RESULT"}, rows))
+	if failed > 0 {
+		return fmt.Errorf("%s failed for %d of %d VMs", action, failed, len(names))
+	}
+	return nil
+}
+
+// runVMTargets runs op for the single VM of a plain --name (or the picker),
+// or through runVMBulk for a list or --match.
+func runVMTargets(ctx context.Context, provider, action string, targets vmTargets, op func(name string) error) error {
+	if !targets.bulk() {
+		return op(targets.name)
+	}
+	names, err := resolveVMTargets(ctx, provider, targets)
+	if err != nil {
+		return err
+	}
+	return runVMBulk(action, names, targets.parallel, op)
+}
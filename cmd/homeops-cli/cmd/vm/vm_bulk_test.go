@@ -0,0 +1,105 @@
+package vm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	vmprov "homeops-cli/internal/provider"
+	"homeops-cli/internal/testutil"
+	"homeops-cli/internal/vmlifecycle"
+)
+
+// bulkLifecycle is a TrueNAS inventory of three Talos nodes and a dev VM
+// that records, safely across goroutines, which VMs were stopped or deleted.
+type bulkLifecycle struct {
+	fakeListLifecycle
+	mu      *sync.Mutex
+	acted   *[]string
+	failFor string
+}
+
+func (f *bulkLifecycle) VMSummaries() ([]vmprov.VMSummary, error) {
+	return []vmprov.VMSummary{{Name: "k8s2"}, {Name: "k8s0"}, {Name: "dev0"}, {Name: "k8s1"}}, nil
+}
+
+func (f *bulkLifecycle) record(action, name string) error {
+	if name == f.failFor {
+		return fmt.Errorf("VM '%s' not found", name)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	*f.acted = append(*f.acted, action+":"+name)
+	return nil
+}
+
+func (f *bulkLifecycle) StopVM(name string, _ bool) error { return f.record("stop", name) }
+func (f *bulkLifecycle) DeleteVM(name string) error       { return f.record("delete", name) }
+
+func injectBulkLifecycle(t *testing.T, failFor string) (*[]string, *bytes.Buffer) {
+	t.Helper()
+	acted := &[]string{}
+	mu := &sync.Mutex{}
+	testutil.Swap(t, &vmlifecycle.NewVMLifecycleFn, func(provider string) (vmprov.VMLifecycle, error) {
+		return &bulkLifecycle{fakeListLifecycle: fakeListLifecycle{provider: provider}, mu: mu, acted: acted, failFor: failFor}, nil
+	})
+	testutil.Swap(t, &vmlifecycle.EnsureVMLifecycleProviderFn, func(string, string) error { return nil })
+	var summary bytes.Buffer
+	testutil.Swap[io.Writer](t, &bulkVMStdout, &summary)
+	return acted, &summary
+}
+
+func TestStopMatchStopsEveryMatchingVM(t *testing.T) {
+	acted, summary := injectBulkLifecycle(t, "")
+	_, err := testutil.ExecuteCommand(newStopVMCommand(), "--provider", "truenas", "--match", "k8s*")
+	require.NoError(t, err)
+	slices.Sort(*acted)
+	assert.Equal(t, []string{"stop:k8s0", "stop:k8s1", "stop:k8s2"}, *acted)
+	assert.Contains(t, summary.String(), "k8s0")
+	assert.NotContains(t, summary.String(), "dev0")
+
+	names, err := resolveVMTargets(context.Background(), "truenas", vmTargets{name: "dev0, k8s1,dev0", match: "k8s?"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dev0", "k8s1", "k8s0", "k8s2"}, names, "listed names first, then the sorted matches")
+	_, err = resolveVMTargets(context.Background(), "truenas", vmTargets{match: "web*"})
+	require.EqualError(t, err, `no VMs on truenas match "web*"`)
+	_, err = resolveVMTargets(context.Background(), "truenas", vmTargets{match: "k8s["})
+	require.ErrorContains(t, err, `invalid --match "k8s["`)
+}
+
+func TestBulkResultSummaryReportsEachVM(t *testing.T) {
+	acted, summary := injectBulkLifecycle(t, "k8s1")
+	_, err := testutil.ExecuteCommand(newStopVMCommand(), "--provider", "truenas", "--name", "k8s0,k8s1,k8s2", "--parallel", "1")
+	require.EqualError(t, err, "stop failed for 1 of 3 VMs")
+	assert.Equal(t, []string{"stop:k8s0", "stop:k8s2"}, *acted, "one failure does not stop the others")
+	assert.Regexp(t, `k8s1\s+VM 'k8s1' not found`, summary.String())
+	assert.Regexp(t, `k8s2\s+ok`, summary.String())
+}
+
+func TestDeleteMatchNeedsForceOrConfirmation(t *testing.T) {
+	acted, _ := injectBulkLifecycle(t, "")
+	var prompt string
+	testutil.Swap(t, &confirmActionFn, func(message string, _ bool) (bool, error) {
+		prompt = message
+		return false, nil
+	})
+	testutil.Swap(t, &trueNASConfigISOPathFn, func(string) (string, error) { return "", nil })
+	testutil.Swap(t, &trueNASVMZVolsFn, func(string) ([]string, error) { return nil, nil })
+
+	_, err := testutil.ExecuteCommand(newDeleteVMCommand(), "--provider", "truenas", "--match", "k8s*")
+	require.EqualError(t, err, "deletion cancelled")
+	assert.Equal(t, "Delete 3 VMs (k8s0, k8s1, k8s2) and their disks? This is destructive!", prompt)
+	assert.Empty(t, *acted)
+
+	_, err = testutil.ExecuteCommand(newDeleteVMCommand(), "--provider", "truenas", "--match", "k8s*", "--force")
+	require.NoError(t, err)
+	slices.Sort(*acted)
+	assert.Equal(t, []string{"delete:k8s0", "delete:k8s1", "delete:k8s2"}, *acted)
+}
@@ -1,11 +1,15 @@
 package vm
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"homeops-cli/internal/constants"
 	vmprov "homeops-cli/internal/provider"
 	"homeops-cli/internal/testutil"
+	"homeops-cli/internal/truenas"
 	"homeops-cli/internal/vmlifecycle"
 
 	"github.com/stretchr/testify/assert"
@@ -31,8 +35,8 @@ func TestVMLifecycleProviderCapabilityError(t *testing.T) {
 func TestPowerOffVMDispatchesForceStop(t *testing.T) {
 	calls, _ := injectFakeVMLifecycle(t)
 
-	require.NoError(t, powerOffVM("tn-vm", "truenas", true))
-	require.NoError(t, powerOffVM("px-vm", "proxmox", true))
+	require.NoError(t, powerOffVM(context.Background(), "tn-vm", "truenas", true))
+	require.NoError(t, powerOffVM(context.Background(), "px-vm", "proxmox", true))
 
 	assert.Equal(t, []string{
 		"stop-truenas:tn-vm:true",
@@ -43,23 +47,23 @@ func TestPowerOffVMDispatchesForceStop(t *testing.T) {
 func TestProviderLifecycleDispatch(t *testing.T) {
 	calls, closed := injectFakeVMLifecycle(t)
 
-	require.NoError(t, startVMWithProvider("tn-vm", "truenas"))
-	require.NoError(t, startVMWithProvider("px-vm", "proxmox"))
-	require.NoError(t, startVMWithProvider("esx-vm", "vsphere"))
-	require.NoError(t, stopVMWithProvider("tn-vm", "truenas"))
-	require.NoError(t, stopVMWithProvider("px-vm", "proxmox"))
-	require.NoError(t, stopVMWithProvider("esx-vm", "vsphere"))
-	require.NoError(t, infoVMWithProvider("tn-vm", "truenas"))
-	require.NoError(t, infoVMWithProvider("px-vm", "proxmox"))
-	require.NoError(t, infoVMWithProvider("esx-vm", "vsphere"))
-	require.NoError(t, deleteVMWithConfirmation("tn-vm", "truenas", true))
-	require.NoError(t, deleteVMWithConfirmation("px-vm", "proxmox", true))
-	require.NoError(t, deleteVMWithConfirmation("esx-vm", "vsphere", true))
-	require.NoError(t, powerOnVM("tn-vm", "truenas"))
-	require.NoError(t, powerOnVM("px-vm", "proxmox"))
-	require.NoError(t, powerOnVM("esx-vm", "vsphere"))
-	require.NoError(t, powerOffVM("esx-vm", "vsphere", true))
-	require.NoError(t, listVMs("proxmox", "table"))
+	require.NoError(t, startVMWithProvider(context.Background(), "tn-vm", "truenas"))
+	require.NoError(t, startVMWithProvider(context.Background(), "px-vm", "proxmox"))
+	require.NoError(t, startVMWithProvider(context.Background(), "esx-vm", "vsphere"))
+	require.NoError(t, stopVMWithProvider(context.Background(), "tn-vm", "truenas", false, 0))
+	require.NoError(t, stopVMWithProvider(context.Background(), "px-vm", "proxmox", false, 0))
+	require.NoError(t, stopVMWithProvider(context.Background(), "esx-vm", "vsphere", false, 0))
+	require.NoError(t, infoVMWithProvider(context.Background(), "tn-vm", "truenas", "table"))
+	require.NoError(t, infoVMWithProvider(context.Background(), "px-vm", "proxmox", "table"))
+	require.NoError(t, infoVMWithProvider(context.Background(), "esx-vm", "vsphere", "table"))
+	require.NoError(t, deleteVMWithConfirmation(context.Background(), "tn-vm", "truenas", true, false, false, 0))
+	require.NoError(t, deleteVMWithConfirmation(context.Background(), "px-vm", "proxmox", true, false, false, 0))
+	require.NoError(t, deleteVMWithConfirmation(context.Background(), "esx-vm", "vsphere", true, false, false, 0))
+	require.NoError(t, powerOnVM(context.Background(), "tn-vm", "truenas"))
+	require.NoError(t, powerOnVM(context.Background(), "px-vm", "proxmox"))
+	require.NoError(t, powerOnVM(context.Background(), "esx-vm", "vsphere"))
+	require.NoError(t, powerOffVM(context.Background(), "esx-vm", "vsphere", true))
+	require.NoError(t, listVMs(context.Background(), "proxmox", "table"))
 
 	assert.Equal(t, []string{
 		"start-truenas:tn-vm",
@@ -97,6 +101,8 @@ func TestVMLifecycleCommandWrappers(t *testing.T) {
 	require.NoError(t, err)
 	_, err = testutil.ExecuteCommand(newStopVMCommand(), "--provider", "proxmox", "--name", "px-vm")
 	require.NoError(t, err)
+	_, err = testutil.ExecuteCommand(newStopVMCommand(), "--provider", "truenas", "--name", "tn-vm", "--force", "--stop-timeout", "30s")
+	require.NoError(t, err)
 	_, err = testutil.ExecuteCommand(newDeleteVMCommand(), "--provider", "proxmox", "--name", "px-vm", "--force")
 	require.NoError(t, err)
 	_, err = testutil.ExecuteCommand(newInfoVMCommand(), "--provider", "proxmox", "--name", "px-vm")
@@ -110,8 +116,13 @@ func TestVMLifecycleCommandWrappers(t *testing.T) {
 		"check:proxmox:start",
 		"start-proxmox:px-vm",
 		"check:proxmox:stop",
+		"stop-timeout-proxmox:2m0s",
 		"stop-proxmox:px-vm:false",
+		"check:truenas:stop",
+		"stop-timeout-truenas:30s",
+		"stop-truenas:tn-vm:true",
 		"check:proxmox:delete",
+		"stop-timeout-proxmox:2m0s",
 		"delete-proxmox:px-vm",
 		"check:proxmox:info",
 		"info-proxmox:px-vm",
@@ -145,11 +156,39 @@ func TestDeleteAndCleanupConfirmationFlows(t *testing.T) {
 			return &fakeVMLifecycle{provider: normalizedProvider, calls: calls}, nil
 		}
 
-		require.NoError(t, deleteVMWithConfirmation("tn-vm", "truenas", false))
-		assert.Contains(t, message, "all its ZVols on TrueNAS")
+		testutil.Swap(t, &trueNASVMZVolsFn, func(string) ([]string, error) {
+			return []string{"flashstor/VM/tn-vm-boot", "tank/VM/tn-vm-ceph"}, nil
+		})
+
+		require.NoError(t, deleteVMWithConfirmation(context.Background(), "tn-vm", "truenas", false, false, false, 0))
+		assert.Equal(t, "Delete VM 'tn-vm' and its ZVols (flashstor/VM/tn-vm-boot, tank/VM/tn-vm-ceph) on TrueNAS? This is destructive!", message)
 		assert.Equal(t, []string{"delete-truenas:tn-vm"}, *calls)
 	})
 
+	t.Run("delete vm keep-zvol leaves the datasets", func(t *testing.T) {
+		var message string
+		confirmActionFn = func(msg string, defaultYes bool) (bool, error) {
+			message = msg
+			return true, nil
+		}
+		calls := &[]string{}
+		vmlifecycle.NewVMLifecycleFn = func(normalizedProvider string) (vmprov.VMLifecycle, error) {
+			return &fakeVMLifecycle{provider: normalizedProvider, calls: calls}, nil
+		}
+		testutil.Swap(t, &trueNASVMZVolsFn, func(string) ([]string, error) { return nil, errors.New("not looked up") })
+		testutil.Swap(t, &trueNASConfigISOPathFn, func(string) (string, error) { return "", nil })
+
+		require.NoError(t, deleteVMWithConfirmation(context.Background(), "tn-vm", "truenas", false, false, true, 0))
+		assert.Equal(t, "Delete VM 'tn-vm' on TrueNAS, keeping its ZVols? This is destructive!", message)
+		assert.Equal(t, []string{"keep-zvols-truenas", "delete-truenas:tn-vm"}, *calls)
+
+		err := deleteVMWithConfirmation(context.Background(), "tn-vm", "truenas", true, false, false, 0)
+		require.EqualError(t, err, "failed to list the ZVols of VM 'tn-vm': not looked up")
+		assert.Len(t, *calls, 2, "a VM whose ZVols cannot be listed is not deleted")
+
+		require.EqualError(t, deleteVMWithConfirmation(context.Background(), "px-vm", "proxmox", true, false, true, 0), "--keep-zvol is only supported with --provider truenas")
+	})
+
 	t.Run("cleanup zvol command force wrapper", func(t *testing.T) {
 		manager := &fakeTrueNASVMManager{}
 		vmlifecycle.GetTrueNASCredentialsFn = func() (string, string, error) {
@@ -165,6 +204,53 @@ func TestDeleteAndCleanupConfirmationFlows(t *testing.T) {
 	})
 }
 
+// planningVMLifecycle is a TrueNAS lifecycle whose delete, like the real
+// manager's under a dry run, only plans its calls.
+type planningVMLifecycle struct {
+	fakeVMLifecycle
+	plan *truenas.DryRunPlan
+}
+
+func (p *planningVMLifecycle) SetDryRun(plan *truenas.DryRunPlan) { p.plan = plan }
+
+func (p *planningVMLifecycle) DeleteVM(name string) error {
+	p.plan.Calls = append(p.plan.Calls, truenas.PlannedCall{Method: "vm.delete", Params: []interface{}{12}})
+	for _, zvol := range []string{"boot", "openebs", "ceph"} {
+		p.plan.Calls = append(p.plan.Calls, truenas.PlannedCall{Method: "pool.dataset.delete", Params: []interface{}{"flashstor/VM/" + name + "-" + zvol, map[string]interface{}{"recursive": true}}})
+	}
+	return nil
+}
+
+func TestDeleteDryRunListsWhatWouldBeRemoved(t *testing.T) {
+	testutil.Swap(t, &vmlifecycle.EnsureVMLifecycleProviderFn, func(string, string) error { return nil })
+	testutil.Swap(t, &vmlifecycle.NewVMLifecycleFn, func(provider string) (vmprov.VMLifecycle, error) {
+		return &planningVMLifecycle{fakeVMLifecycle: fakeVMLifecycle{provider: provider, calls: &[]string{}}}, nil
+	})
+	testutil.Swap(t, &trueNASConfigISOPathFn, func(string) (string, error) { return "/mnt/flashstor/ISO/k8s0-config.iso", nil })
+	testutil.Swap(t, &confirmActionFn, func(string, bool) (bool, error) {
+		t.Fatal("a dry run asks for no confirmation")
+		return false, nil
+	})
+
+	output, err := testutil.ExecuteCommand(newDeleteVMCommand(), "--provider", "truenas", "--name", "k8s0", "--dry-run")
+	require.NoError(t, err)
+	assert.Equal(t, "Dry run: deleting VM 'k8s0' on TrueNAS would:\n"+
+		"  Delete VM ID 12\n"+
+		"  Destroy dataset flashstor/VM/k8s0-boot and its snapshots\n"+
+		"  Destroy dataset flashstor/VM/k8s0-openebs and its snapshots\n"+
+		"  Destroy dataset flashstor/VM/k8s0-ceph and its snapshots\n"+
+		"  Remove Talos config ISO /mnt/flashstor/ISO/k8s0-config.iso\n"+
+		"Nothing was changed.\n", output)
+
+	testutil.Swap(t, &vmlifecycle.NewVMLifecycleFn, func(provider string) (vmprov.VMLifecycle, error) {
+		return &fakeVMLifecycle{provider: provider, calls: &[]string{}}, nil
+	})
+	_, err = testutil.ExecuteCommand(newDeleteVMCommand(), "--provider", "truenas", "--name", "k8s0", "--dry-run")
+	require.EqualError(t, err, "this TrueNAS connection cannot plan a delete without making it")
+	_, err = testutil.ExecuteCommand(newDeleteVMCommand(), "--provider", "proxmox", "--name", "k8s0", "--dry-run")
+	require.EqualError(t, err, "--dry-run is only supported with --provider truenas")
+}
+
 func TestHypervisorWrapperFlows(t *testing.T) {
 	oldTrueNASFactory := vmlifecycle.NewTrueNASVMManagerFn
 	oldProxmoxFactory := vmlifecycle.NewProxmoxVMManagerFn
@@ -196,15 +282,17 @@ func TestHypervisorWrapperFlows(t *testing.T) {
 		})
 		defer cleanup()
 
-		require.NoError(t, listVMs("truenas", "table"))
-		require.NoError(t, startVMWithProvider("tn-vm", "truenas"))
-		require.NoError(t, powerOffVM("tn-vm", "truenas", true))
-		require.NoError(t, deleteVMWithConfirmation("tn-vm", "truenas", true))
-		require.NoError(t, infoVMWithProvider("tn-vm", "truenas"))
-		require.NoError(t, cleanupOrphanedZVols("tn-vm", "flashstor"))
+		require.NoError(t, listVMs(context.Background(), "truenas", "table"))
+		require.NoError(t, startVMWithProvider(context.Background(), "tn-vm", "truenas"))
+		require.NoError(t, powerOffVM(context.Background(), "tn-vm", "truenas", true))
+		require.NoError(t, deleteVMWithConfirmation(context.Background(), "tn-vm", "truenas", true, false, false, 0))
+		require.NoError(t, infoVMWithProvider(context.Background(), "tn-vm", "truenas", "table"))
+		require.NoError(t, cleanupOrphanedZVols(context.Background(), "tn-vm", "flashstor"))
 
-		assert.Equal(t, 6, manager.connectCalls)
-		assert.Equal(t, 6, manager.closeCalls)
+		// delete connects three times: once each to look up the ZVols and
+		// the Talos config ISO.
+		assert.Equal(t, 8, manager.connectCalls)
+		assert.Equal(t, 8, manager.closeCalls)
 		assert.Equal(t, 1, manager.listCalls)
 		assert.Equal(t, []string{"tn-vm"}, manager.started)
 		assert.Equal(t, []string{"tn-vm:true"}, manager.stopped)
@@ -227,11 +315,11 @@ func TestHypervisorWrapperFlows(t *testing.T) {
 			return manager, nil
 		}
 
-		require.NoError(t, listVMs("proxmox", "table"))
-		require.NoError(t, startVMWithProvider("px-vm", "proxmox"))
-		require.NoError(t, powerOffVM("px-vm", "proxmox", true))
-		require.NoError(t, deleteVMWithConfirmation("px-vm", "proxmox", true))
-		require.NoError(t, infoVMWithProvider("px-vm", "proxmox"))
+		require.NoError(t, listVMs(context.Background(), "proxmox", "table"))
+		require.NoError(t, startVMWithProvider(context.Background(), "px-vm", "proxmox"))
+		require.NoError(t, powerOffVM(context.Background(), "px-vm", "proxmox", true))
+		require.NoError(t, deleteVMWithConfirmation(context.Background(), "px-vm", "proxmox", true, false, false, 0))
+		require.NoError(t, infoVMWithProvider(context.Background(), "px-vm", "proxmox", "table"))
 
 		assert.Equal(t, 5, manager.closeCalls)
 		assert.Equal(t, 1, manager.listCalls)
@@ -256,11 +344,11 @@ func TestHypervisorWrapperFlows(t *testing.T) {
 			return &fakeVMLifecycle{provider: "vsphere", calls: calls}, nil
 		}
 
-		require.NoError(t, listVMs("vsphere", "table"))
-		require.NoError(t, infoVMWithProvider("esx-vm", "vsphere"))
-		require.NoError(t, powerOnVM("esx-vm", "vsphere"))
-		require.NoError(t, powerOffVM("esx-vm", "vsphere", true))
-		require.NoError(t, deleteVMWithConfirmation("esx-vm", "vsphere", true))
+		require.NoError(t, listVMs(context.Background(), "vsphere", "table"))
+		require.NoError(t, infoVMWithProvider(context.Background(), "esx-vm", "vsphere", "table"))
+		require.NoError(t, powerOnVM(context.Background(), "esx-vm", "vsphere"))
+		require.NoError(t, powerOffVM(context.Background(), "esx-vm", "vsphere", true))
+		require.NoError(t, deleteVMWithConfirmation(context.Background(), "esx-vm", "vsphere", true, false, false, 0))
 
 		assert.Equal(t, 5, constructed, "each lifecycle op constructs and closes a manager")
 		assert.Equal(t, []string{
@@ -272,3 +360,51 @@ func TestHypervisorWrapperFlows(t *testing.T) {
 		}, *calls)
 	})
 }
+
+func TestTrueNASRestartForceSuspendAndResume(t *testing.T) {
+	manager := &fakeTrueNASVMManager{}
+	testutil.Swap(t, &vmlifecycle.GetTrueNASCredentialsFn, func() (string, string, error) { return "truenas.local", "api-key", nil })
+	testutil.Swap(t, &vmlifecycle.NewTrueNASVMManagerFn, func(string, string, int, bool) vmlifecycle.TrueNASVMManager { return manager })
+
+	_, err := testutil.ExecuteCommand(newRestartVMCommand(), "--provider", "truenas", "--name", "k8s0", "--force")
+	require.NoError(t, err)
+	_, err = testutil.ExecuteCommand(newSuspendVMCommand(), "--name", "k8s0")
+	require.NoError(t, err)
+	_, err = testutil.ExecuteCommand(newResumeVMCommand(), "--name", "k8s0")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"power-cycle:k8s0"}, manager.restarted)
+	assert.Equal(t, []string{"k8s0"}, manager.suspended)
+	assert.Equal(t, []string{"k8s0"}, manager.resumed)
+
+	_, err = testutil.ExecuteCommand(newRestartVMCommand(), "--provider", "proxmox", "--name", "dev-vm", "--force")
+	require.EqualError(t, err, "--force is only supported with --provider truenas")
+
+	found, _, err := newProviderScopedVMGroup("truenas").Find([]string{"suspend"})
+	require.NoError(t, err)
+	assert.Equal(t, "suspend", found.Name())
+	found, _, _ = newProviderScopedVMGroup("proxmox").Find([]string{"resume"})
+	assert.NotEqual(t, "resume", found.Name(), "suspend and resume are TrueNAS only")
+}
+
+func TestSnapshotCreateDefaultsToATimestampAndRollbackForcePowersOff(t *testing.T) {
+	calls, _ := injectFakeVMLifecycle(t)
+	testutil.Swap(t, &snapshotNowFn, func() time.Time { return time.Date(2026, 10, 14, 13, 26, 0, 0, time.UTC) })
+	testutil.Swap(t, &confirmActionFn, func(string, bool) (bool, error) { return true, nil })
+
+	_, err := testutil.ExecuteCommand(newSnapshotCommand(), "create", "--provider", "truenas", "--name", "k8s0")
+	require.NoError(t, err)
+	_, err = testutil.ExecuteCommand(newSnapshotCommand(), "rollback", "--provider", "truenas", "--name", "k8s0", "--snap", "20261014-132600")
+	require.NoError(t, err)
+	_, err = testutil.ExecuteCommand(newSnapshotCommand(), "rollback", "--provider", "truenas", "--name", "k8s0", "--snap", "20261014-132600", "--force")
+	require.NoError(t, err)
+	_, err = testutil.ExecuteCommand(newSnapshotCommand(), "rollback", "--provider", "proxmox", "--name", "dev0", "--snap", "pre", "--force")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"snap-create-truenas:k8s0:20261014-132600",
+		"snap-rollback-truenas:k8s0:20261014-132600",
+		"stop-truenas:k8s0:true",
+		"snap-rollback-truenas:k8s0:20261014-132600",
+		"snap-rollback-proxmox:dev0:pre",
+	}, *calls, "only a forced TrueNAS rollback powers the VM off")
+}
@@ -0,0 +1,211 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/ssh"
+	"homeops-cli/internal/truenas"
+	"homeops-cli/internal/vmlifecycle"
+)
+
+// trueNASSerialLogPathFn looks up the serial log file attached to a TrueNAS
+// VM at deploy time. Swappable for tests.
+var trueNASSerialLogPathFn = func(name string) (string, error) {
+	var logPath string
+	err := vmlifecycle.WithTrueNASVMManager(common.NewColorLogger(), func(m vmlifecycle.TrueNASVMManager) error {
+		var err error
+		logPath, err = m.SerialLogPath(name)
+		return err
+	})
+	return logPath, err
+}
+
+// trueNASConfigISOPathFn looks up the Talos config ISO attached to a TrueNAS
+// VM by `talos deploy-vm` ("" when there is none). Swappable for tests.
+var trueNASConfigISOPathFn = func(name string) (string, error) {
+	var isoPath string
+	err := vmlifecycle.WithTrueNASVMManager(common.NewColorLogger(), func(m vmlifecycle.TrueNASVMManager) error {
+		var err error
+		isoPath, err = m.ConfigISOPath(name)
+		return err
+	})
+	return isoPath, err
+}
+
+// trueNASVMZVolsFn lists the datasets behind a TrueNAS VM's ZVol disks,
+// which delete removes with it. Swappable for tests.
+var trueNASVMZVolsFn = func(name string) ([]string, error) {
+	var zvols []string
+	err := vmlifecycle.WithTrueNASVMManager(common.NewColorLogger(), func(m vmlifecycle.TrueNASVMManager) error {
+		var err error
+		zvols, err = m.VMZVols(name)
+		return err
+	})
+	return zvols, err
+}
+
+// trueNASConsoleInfoFn reads a TrueNAS VM's display device endpoints and
+// console URLs. Swappable for tests.
+var trueNASConsoleInfoFn = func(name string) (*truenas.ConsoleInfo, error) {
+	var console *truenas.ConsoleInfo
+	err := vmlifecycle.WithTrueNASVMManager(common.NewColorLogger(), func(m vmlifecycle.TrueNASVMManager) error {
+		var err error
+		console, err = m.ConsoleInfo(name)
+		return err
+	})
+	return console, err
+}
+
+// openBrowserFn opens url in the desktop's default browser. Swappable for
+// tests.
+var openBrowserFn = func(url string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return common.Command("open", url).Start()
+	case "windows":
+		return common.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+	default:
+		return common.Command("xdg-open", url).Start()
+	}
+}
+
+// tailTrueNASFileFn tails a file on the NAS over SSH. Swappable for tests.
+var tailTrueNASFileFn = func(ctx context.Context, remotePath string, lines int, follow bool, stdout io.Writer) error {
+	client, err := connectTrueNASSSH()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Close() }()
+	return client.TailFile(ctx, remotePath, lines, follow, stdout)
+}
+
+// removeTrueNASFileFn deletes a file on the NAS over SSH. Swappable for tests.
+var removeTrueNASFileFn = func(remotePath string) error {
+	client, err := connectTrueNASSSH()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Close() }()
+	return client.RemoveFile(remotePath)
+}
+
+func connectTrueNASSSH() (*ssh.SSHClient, error) {
+	host, _, err := vmlifecycle.GetTrueNASCredentialsFn()
+	if err != nil {
+		return nil, err
+	}
+	client := ssh.NewSSHClient(trueNASSSHConfig(host, trueNASSSHUser()))
+	if err := client.Connect(); err != nil {
+		return nil, fmt.Errorf("connect to NAS over SSH: %w", err)
+	}
+	return client, nil
+}
+
+// newConsoleLogCommand tails the serial console log of a TrueNAS VM deployed
+// with `talos deploy-vm --serial-log`.
+func newConsoleLogCommand() *cobra.Command {
+	var (
+		name   string
+		follow bool
+		lines  int
+	)
+	cmd := &cobra.Command{
+		Use:   "console-log",
+		Short: "Show a TrueNAS VM's serial console log",
+		Long: `Print the serial console log captured on the NAS for a VM deployed with
+'talos deploy-vm --serial-log'. The log survives VM restarts, so it shows
+boot failures that happen before the network (and SSH/talosctl) come up.`,
+		Example: `  homeops-cli vm truenas console-log --name k8s0
+  homeops-cli vm truenas console-log --name k8s0 --follow
+  homeops-cli vm truenas console-log --name k8s0 --lines 500`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if lines <= 0 {
+				return fmt.Errorf("--lines must be greater than 0, got %d", lines)
+			}
+			resolvedName, err := resolveVMNameForAction(name, "truenas", "show the console log for")
+			if err != nil || resolvedName == "" {
+				return err
+			}
+			logPath, err := trueNASSerialLogPathFn(resolvedName)
+			if err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			return tailTrueNASFileFn(ctx, logPath, lines, follow, cmd.OutOrStdout())
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "VM name (optional - will prompt if not provided)")
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "Keep streaming new output until interrupted")
+	cmd.Flags().IntVarP(&lines, "lines", "n", 100, "Number of trailing lines to show")
+	return cmd
+}
+
+func newConsoleInfoCommand() *cobra.Command {
+	var (
+		name string
+		open bool
+	)
+	cmd := &cobra.Command{
+		Use:   "console-info",
+		Short: "Show a TrueNAS VM's SPICE and web console endpoints",
+		Long: `Print the type, bind address, and ports of a VM's display device, with the
+spice:// URL for a native client (remote-viewer) and the https:// URL of the
+web console. The NAS assigns the ports when the VM starts, so a stopped VM
+may have none yet. The URLs use hypervisors.truenas.spice_host when set.
+
+--open launches the web console in the default browser.`,
+		Example: `  homeops-cli vm truenas console-info --name k8s0
+  homeops-cli vm truenas console-info --name k8s0 --open
+  remote-viewer "$(homeops-cli vm truenas console-info --name k8s0 | awk '/^SPICE:/ {print $2}')"`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resolvedName, err := resolveVMNameForAction(name, "truenas", "show the console of")
+			if err != nil || resolvedName == "" {
+				return err
+			}
+			console, err := trueNASConsoleInfoFn(resolvedName)
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			_, _ = fmt.Fprintf(out, "Type:     %s\nBind:     %s\nPort:     %s\nWeb port: %s\n",
+				console.Type, console.Bind, consolePort(console.Port), consolePort(console.WebPort))
+			if console.SpiceURL != "" {
+				_, _ = fmt.Fprintf(out, "SPICE:    %s\n", console.SpiceURL)
+			}
+			if console.WebURL != "" {
+				_, _ = fmt.Fprintf(out, "Web:      %s\n", console.WebURL)
+			}
+			if !open {
+				return nil
+			}
+			if console.WebURL == "" {
+				return fmt.Errorf("VM %s has no web console port to open (is the VM running, with web access enabled on its display?)", resolvedName)
+			}
+			if err := openBrowserFn(console.WebURL); err != nil {
+				return fmt.Errorf("failed to open %s in a browser: %w", console.WebURL, err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "VM name (optional - will prompt if not provided)")
+	cmd.Flags().BoolVar(&open, "open", false, "Open the web console in the default browser")
+	return cmd
+}
+
+// consolePort renders a display port the NAS has not assigned as "-".
+func consolePort(port int) string {
+	if port <= 0 {
+		return "-"
+	}
+	return strconv.Itoa(port)
+}
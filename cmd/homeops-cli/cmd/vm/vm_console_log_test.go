@@ -0,0 +1,134 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/testutil"
+	"homeops-cli/internal/truenas"
+	"homeops-cli/internal/vmlifecycle"
+)
+
+func TestConsoleLogCommandTailsSerialLog(t *testing.T) {
+	manager := &fakeTrueNASVMManager{serialLogs: map[string]string{"k8s0": "/mnt/flashstor/vm-logs/k8s0.log"}}
+	testutil.Swap(t, &vmlifecycle.GetTrueNASCredentialsFn, func() (string, string, error) { return "truenas.local", "api-key", nil })
+	testutil.Swap(t, &vmlifecycle.NewTrueNASVMManagerFn, func(string, string, int, bool) vmlifecycle.TrueNASVMManager { return manager })
+	var tailed []string
+	testutil.Swap(t, &tailTrueNASFileFn, func(_ context.Context, remotePath string, lines int, follow bool, stdout io.Writer) error {
+		tailed = append(tailed, remotePath)
+		assert.Equal(t, 250, lines)
+		assert.True(t, follow)
+		_, err := io.WriteString(stdout, "[    0.000000] Linux version 6.12\n")
+		return err
+	})
+
+	out, err := testutil.ExecuteCommand(newConsoleLogCommand(), "--name", "k8s0", "-n", "250", "--follow")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Linux version")
+	assert.Equal(t, []string{"/mnt/flashstor/vm-logs/k8s0.log"}, tailed)
+
+	_, err = testutil.ExecuteCommand(newConsoleLogCommand(), "--name", "k8s1")
+	require.Error(t, err, "VMs deployed without --serial-log have nothing to tail")
+	_, err = testutil.ExecuteCommand(newConsoleLogCommand(), "--name", "k8s0", "--lines", "0")
+	require.Error(t, err)
+	assert.Len(t, tailed, 1)
+
+	truenas := newProviderScopedVMGroup("truenas")
+	found, _, err := truenas.Find([]string{"console-log"})
+	require.NoError(t, err)
+	assert.Equal(t, "console-log", found.Name())
+	proxmox := newProviderScopedVMGroup("proxmox")
+	found, _, _ = proxmox.Find([]string{"console-log"})
+	assert.NotEqual(t, "console-log", found.Name(), "console-log is TrueNAS only")
+}
+
+func TestDeleteVMRemovesSerialLogWhenAsked(t *testing.T) {
+	calls, _ := injectFakeVMLifecycle(t)
+	testutil.Swap(t, &trueNASConfigISOPathFn, func(string) (string, error) { return "", nil })
+	testutil.Swap(t, &trueNASSerialLogPathFn, func(name string) (string, error) {
+		return "/mnt/flashstor/vm-logs/" + name + ".log", nil
+	})
+	var removed []string
+	testutil.Swap(t, &removeTrueNASFileFn, func(remotePath string) error {
+		removed = append(removed, remotePath)
+		return nil
+	})
+
+	require.NoError(t, deleteVMWithConfirmation(context.Background(), "tn-vm", "truenas", true, false, false, 0))
+	assert.Empty(t, removed)
+
+	require.NoError(t, deleteVMWithConfirmation(context.Background(), "tn-vm", "truenas", true, true, false, 0))
+	assert.Equal(t, []string{"/mnt/flashstor/vm-logs/tn-vm.log"}, removed)
+	assert.Len(t, *calls, 2)
+
+	require.Error(t, deleteVMWithConfirmation(context.Background(), "px-vm", "proxmox", true, true, false, 0))
+	assert.Len(t, *calls, 2, "rejected before anything is deleted")
+}
+
+func TestDeleteVMRemovesTalosConfigISO(t *testing.T) {
+	calls, _ := injectFakeVMLifecycle(t)
+	testutil.Swap(t, &trueNASConfigISOPathFn, func(name string) (string, error) {
+		return "/mnt/flashstor/ISO/" + name + "-talos-config.iso", nil
+	})
+	var removed []string
+	testutil.Swap(t, &removeTrueNASFileFn, func(remotePath string) error {
+		removed = append(removed, remotePath)
+		return nil
+	})
+
+	require.NoError(t, deleteVMWithConfirmation(context.Background(), "k8s_0", "truenas", true, false, false, 0))
+	assert.Equal(t, []string{"/mnt/flashstor/ISO/k8s_0-talos-config.iso"}, removed, "removed without any flag: it holds the node's secrets")
+	assert.Len(t, *calls, 1)
+
+	require.NoError(t, deleteVMWithConfirmation(context.Background(), "px-vm", "proxmox", true, false, false, 0))
+	assert.Len(t, removed, 1, "only TrueNAS VMs carry a config ISO")
+
+	testutil.Swap(t, &trueNASConfigISOPathFn, func(string) (string, error) { return "", errors.New("vm.device.query failed") })
+	require.NoError(t, deleteVMWithConfirmation(context.Background(), "k8s_1", "truenas", true, false, false, 0), "a failed lookup does not block the delete")
+	assert.Len(t, removed, 1)
+
+	testutil.Swap(t, &trueNASConfigISOPathFn, func(name string) (string, error) { return "/mnt/flashstor/ISO/k8s_2-talos-config.iso", nil })
+	testutil.Swap(t, &removeTrueNASFileFn, func(string) error { return errors.New("ssh down") })
+	err := deleteVMWithConfirmation(context.Background(), "k8s_2", "truenas", true, false, false, 0)
+	require.ErrorContains(t, err, "deleted but removing Talos config ISO")
+}
+
+func TestConsoleInfoCommandPrintsURLsAndOpensTheWebConsole(t *testing.T) {
+	manager := &fakeTrueNASVMManager{console: &truenas.ConsoleInfo{
+		DisplayInfo: truenas.DisplayInfo{Type: "SPICE", Bind: "0.0.0.0", Port: 5900, WebPort: 5901},
+		Host:        "nas.local",
+		SpiceURL:    "spice://nas.local:5900",
+		WebURL:      "https://nas.local:5901/spice_auto.html",
+	}}
+	testutil.Swap(t, &vmlifecycle.GetTrueNASCredentialsFn, func() (string, string, error) { return "truenas.local", "api-key", nil })
+	testutil.Swap(t, &vmlifecycle.NewTrueNASVMManagerFn, func(string, string, int, bool) vmlifecycle.TrueNASVMManager { return manager })
+	var opened []string
+	testutil.Swap(t, &openBrowserFn, func(url string) error {
+		opened = append(opened, url)
+		return nil
+	})
+
+	out, err := testutil.ExecuteCommand(newConsoleInfoCommand(), "--name", "k8s0")
+	require.NoError(t, err)
+	assert.Equal(t, "Type:     SPICE\nBind:     0.0.0.0\nPort:     5900\nWeb port: 5901\nSPICE:    spice://nas.local:5900\nWeb:      https://nas.local:5901/spice_auto.html\n", out)
+	assert.Empty(t, opened)
+
+	_, err = testutil.ExecuteCommand(newConsoleInfoCommand(), "--name", "k8s0", "--open")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://nas.local:5901/spice_auto.html"}, opened)
+
+	manager.console = &truenas.ConsoleInfo{DisplayInfo: truenas.DisplayInfo{Type: "SPICE", Bind: "0.0.0.0"}, Host: "nas.local"}
+	out, err = testutil.ExecuteCommand(newConsoleInfoCommand(), "--name", "k8s0", "--open")
+	require.EqualError(t, err, "VM k8s0 has no web console port to open (is the VM running, with web access enabled on its display?)")
+	assert.Contains(t, out, "Port:     -\nWeb port: -\n")
+	assert.Len(t, opened, 1)
+
+	proxmox := newProviderScopedVMGroup("proxmox")
+	found, _, _ := proxmox.Find([]string{"console-info"})
+	assert.NotEqual(t, "console-info", found.Name(), "console-info is TrueNAS only")
+}
@@ -253,9 +253,6 @@ func createTrueNASVM(logger *common.ColorLogger, spec createSpec) error {
 	if spec.mtu != 0 {
 		return vmprov.Unsupported("truenas", "MTU is a property of the TrueNAS bridge interface, not the VM NIC")
 	}
-	if err := vmlifecycle.ValidateVMName(spec.name); err != nil {
-		return err
-	}
 	cfg := versionconfig.Get()
 
 	pool := spec.storage
@@ -416,6 +413,9 @@ Per provider:
 			if err != nil {
 				return err
 			}
+			if err := vmlifecycle.ValidateVMNameFor(normalized, name); err != nil {
+				return err
+			}
 
 			spec := createSpec{
 				name: name, memory: memory, cores: cores, diskGB: diskGB,
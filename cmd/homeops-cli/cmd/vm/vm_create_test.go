@@ -300,6 +300,9 @@ func TestVMHardwareLifecycleDispatch(t *testing.T) {
 	resize := newResizeDiskCommand()
 	resize.SetArgs([]string{"--provider", "truenas", "--name", "web0", "--disk", "openebs", "--grow", "20G"})
 	require.NoError(t, resize.Execute())
+	resize = newResizeDiskCommand()
+	resize.SetArgs([]string{"--provider", "truenas", "--name", "k8s0", "--disk", "rook", "--size", "1200"})
+	require.NoError(t, resize.Execute())
 
 	restart := newRestartVMCommand()
 	restart.SetArgs([]string{"--provider", "vsphere", "--name", "vc0"})
@@ -315,12 +318,25 @@ func TestVMHardwareLifecycleDispatch(t *testing.T) {
 
 	assert.Equal(t, []string{
 		"resize-truenas:web0:openebs:+20G",
+		"resize-truenas:k8s0:rook:1200G",
 		"restart-vsphere:vc0",
 		"clone-proxmox:a:b:123:false",
 		"snap-create-truenas:web0:pre",
 	}, *calls)
 }
 
+func TestVMCloneValidatesTargetName(t *testing.T) {
+	defer versionconfig.SetForTesting(nil)()
+	calls, _ := injectFakeVMLifecycle(t)
+
+	clone := newCloneVMCommand()
+	clone.SetArgs([]string{"--provider", "truenas", "--name", "web0", "--to", "web-1"})
+	err := clone.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "suggested: 'web_1'")
+	assert.Empty(t, *calls)
+}
+
 func TestVMConsoleAndIPDispatch(t *testing.T) {
 	defer versionconfig.SetForTesting(nil)()
 	calls, _ := injectFakeVMLifecycle(t)
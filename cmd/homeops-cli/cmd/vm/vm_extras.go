@@ -3,6 +3,7 @@ package vm
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -29,6 +30,9 @@ var vmIPAddressesFn = func(provider, name string) ([]string, error) {
 	return ips, err
 }
 
+// snapshotNowFn names a snapshot created without --snap. Swappable for tests.
+var snapshotNowFn = time.Now
+
 // newSnapshotCommand groups snapshot operations.
 func newSnapshotCommand() *cobra.Command {
 	var name, snap, provider string
@@ -38,26 +42,40 @@ func newSnapshotCommand() *cobra.Command {
 		Short: "Manage VM snapshots (create, list, rollback, delete)",
 		Long: `Manage VM snapshots on any provider. Proxmox and vSphere snapshot the VM
 natively; TrueNAS snapshots every zvol backing the VM under one ZFS snapshot
-name (crash-consistent while the VM runs).`,
+name (crash-consistent while the VM runs).
+
+create without --snap names the snapshot after the current time
+(20261014-132600). A running TrueNAS VM is not rolled back unless --force,
+which powers it off first.`,
 		Example: `  homeops-cli vm snapshot create --name dev-vm --snap pre-upgrade
+  homeops-cli vm truenas snapshot create --name k8s0
+  homeops-cli vm truenas snapshot rollback --name k8s0 --snap pre-upgrade --force
   homeops-cli vm snapshot list --name dev-vm
   homeops-cli vm snapshot rollback --name dev-vm --snap pre-upgrade
   homeops-cli vm snapshot delete --provider truenas --name dev-vm --snap pre-upgrade`,
 	}
 
 	// resolveTarget resolves the VM name (live picker when --name is omitted on
-	// a terminal) and, when needSnap, the snapshot name. A returned empty name
-	// means the user cancelled the picker — callers should do nothing.
-	resolveTarget := func(action string, needSnap bool) (string, string, error) {
+	// a terminal) and, when needSnap, the snapshot name, which defaults to
+	// defaultSnap when it is not "". A returned empty name means the user
+	// cancelled the picker — callers should do nothing.
+	resolveTarget := func(action string, needSnap bool, defaultSnap string) (string, string, error) {
 		resolvedName, err := resolveVMNameForAction(name, provider, action)
 		if err != nil || resolvedName == "" {
 			return "", "", err
 		}
 		resolvedSnap := snap
 		if needSnap {
-			if resolvedSnap, err = promptStringIfInteractive(snap, "Snapshot name:", "pre-upgrade"); err != nil {
+			placeholder := defaultSnap
+			if placeholder == "" {
+				placeholder = "pre-upgrade"
+			}
+			if resolvedSnap, err = promptStringIfInteractive(snap, "Snapshot name:", placeholder); err != nil {
 				return "", "", err
 			}
+			if resolvedSnap == "" {
+				resolvedSnap = defaultSnap
+			}
 			if resolvedSnap == "" {
 				return "", "", fmt.Errorf("--snap is required")
 			}
@@ -69,7 +87,7 @@ name (crash-consistent while the VM runs).`,
 		Use:   "create",
 		Short: "Create a snapshot",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			vmName, snapName, err := resolveTarget("snapshot", true)
+			vmName, snapName, err := resolveTarget("snapshot", true, snapshotNowFn().Format("20060102-150405"))
 			if err != nil || vmName == "" {
 				return err
 			}
@@ -80,7 +98,7 @@ name (crash-consistent while the VM runs).`,
 		Use:   "list",
 		Short: "List snapshots",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			vmName, _, err := resolveTarget("list snapshots for", false)
+			vmName, _, err := resolveTarget("list snapshots for", false, "")
 			if err != nil || vmName == "" {
 				return err
 			}
@@ -91,7 +109,7 @@ name (crash-consistent while the VM runs).`,
 		Use:   "rollback",
 		Short: "Roll back to a snapshot (DESTRUCTIVE: state after the snapshot is lost)",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			vmName, snapName, err := resolveTarget("roll back", true)
+			vmName, snapName, err := resolveTarget("roll back", true, "")
 			if err != nil || vmName == "" {
 				return err
 			}
@@ -104,14 +122,27 @@ name (crash-consistent while the VM runs).`,
 					return fmt.Errorf("rollback cancelled by user")
 				}
 			}
-			return runLifecycleOp(provider, func(lc vmprov.VMLifecycle) error { return lc.RollbackVM(vmName, snapName) })
+			normalized, err := vmlifecycle.NormalizeVMProvider(provider)
+			if err != nil {
+				return err
+			}
+			return runLifecycleOp(provider, func(lc vmprov.VMLifecycle) error {
+				// TrueNAS rolls the zvols back under the guest, so a running
+				// VM is refused unless --force powers it off first.
+				if force && normalized == "truenas" {
+					if err := lc.StopVM(vmName, true); err != nil {
+						return err
+					}
+				}
+				return lc.RollbackVM(vmName, snapName)
+			})
 		},
 	}
 	del := &cobra.Command{
 		Use:   "delete",
 		Short: "Delete a snapshot (DESTRUCTIVE: the recovery point is removed)",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			vmName, snapName, err := resolveTarget("delete a snapshot on", true)
+			vmName, snapName, err := resolveTarget("delete a snapshot on", true, "")
 			if err != nil || vmName == "" {
 				return err
 			}
@@ -129,9 +160,9 @@ name (crash-consistent while the VM runs).`,
 	}
 
 	cmd.PersistentFlags().StringVar(&name, "name", "", "VM name (prompts if omitted)")
-	cmd.PersistentFlags().StringVar(&snap, "snap", "", "snapshot name (prompts if omitted)")
+	cmd.PersistentFlags().StringVar(&snap, "snap", "", "snapshot name (prompts if omitted; create defaults to a timestamp)")
 	addPersistentProviderFlag(cmd, &provider)
-	cmd.PersistentFlags().BoolVar(&force, "force", false, "skip the confirmation prompt (rollback, delete)")
+	cmd.PersistentFlags().BoolVar(&force, "force", false, "skip the confirmation prompt (rollback, delete); rollback powers a running TrueNAS VM off first")
 	cmd.AddCommand(create, list, rollback, del)
 	return cmd
 }
@@ -146,9 +177,14 @@ func newCloneVMCommand() *cobra.Command {
 		Short: "Clone a VM (full clone by default)",
 		Long: `Clone a VM to a new name. Proxmox makes a full copy unless --linked;
 TrueNAS clones are always ZFS snapshot clones (space-efficient); vSphere
-makes full clones.`,
+makes full clones.
+
+A TrueNAS VM must be stopped. Each ZVol disk is snapshotted and cloned as
+<to>-<disk> next to the original, and the VM's settings and devices are
+copied with new disk serials and NIC MACs. A Talos config ISO is not
+copied.`,
 		Example: `  homeops-cli vm clone --name template-vm --to dev-vm2
-  homeops-cli vm clone --provider truenas --name web0 --to web1`,
+  homeops-cli vm clone --provider truenas --name k8s0 --to k8stest`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			name, err := resolveVMNameForAction(name, provider, "clone")
 			if err != nil {
@@ -163,6 +199,9 @@ makes full clones.`,
 			if to == "" {
 				return fmt.Errorf("--to is required")
 			}
+			if err := vmlifecycle.ValidateVMNameFor(provider, to); err != nil {
+				return err
+			}
 			return runLifecycleOp(provider, func(lc vmprov.VMLifecycle) error {
 				return lc.Clone(name, to, vmprov.CloneOptions{VMID: vmid, Linked: linked})
 			})
@@ -196,7 +235,7 @@ func vmNameFromArgsOrPrompt(args []string, provider, action string) (string, err
 func resolveVMIP(provider, name string) (string, error) {
 	ips, err := vmIPAddressesFn(provider, name)
 	if err == nil && len(ips) > 0 {
-		return ips[0], nil
+		return versionconfig.PreferredAddress(ips), nil
 	}
 	if node, ok := versionconfig.Get().NodeByName(name); ok && node.IP != "" {
 		return node.IP, nil
@@ -224,12 +263,14 @@ func newVMIPCommand() *cobra.Command {
 			ips, err := vmIPAddressesFn(provider, name)
 			if err != nil {
 				if node, ok := versionconfig.Get().NodeByName(name); ok && node.IP != "" {
-					fmt.Println(node.IP)
+					for _, ip := range node.Addresses() {
+						fmt.Println(ip)
+					}
 					return nil
 				}
 				return err
 			}
-			for _, ip := range ips {
+			for _, ip := range versionconfig.OrderAddresses(ips) {
 				fmt.Println(ip)
 			}
 			return nil
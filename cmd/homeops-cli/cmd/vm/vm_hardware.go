@@ -2,11 +2,15 @@ package vm
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
 
+	"homeops-cli/cmd/completion"
+	"homeops-cli/internal/common"
 	vmprov "homeops-cli/internal/provider"
+	"homeops-cli/internal/truenas"
 	"homeops-cli/internal/vmlifecycle"
 )
 
@@ -34,16 +38,34 @@ func addPersistentProviderFlag(cmd *cobra.Command, provider *string) {
 	cmd.PersistentFlags().StringVar(provider, "provider", "", providerFlagUsage)
 }
 
-// newSetVMCommand updates VM hardware (memory/cores).
+// newSetVMCommand updates VM hardware (memory/cores) and, on TrueNAS, CPU
+// placement.
 func newSetVMCommand() *cobra.Command {
 	var name, provider string
 	var memory, cores int
+	var cpu truenas.CPUPlacement
 	cmd := &cobra.Command{
 		Use:   "set",
-		Short: "Update a VM's hardware (memory, cores)",
+		Short: "Update a VM's hardware (memory, cores, CPU placement)",
+		Long: `Update a VM's memory and vCPU count. On TrueNAS, --cpuset, --nodeset,
+--pin-vcpus, --cpu-mode, and --cpu-model change where the vCPUs run (see
+'vm truenas host-topology'); only the flags passed are changed, and an empty
+--cpuset or --nodeset clears it. A running VM picks the change up on its next
+restart.`,
 		Example: `  homeops-cli vm set --name dev-vm --memory 8192
-  homeops-cli vm set --provider truenas --name dev-vm --cores 4 --memory 16384`,
+  homeops-cli vm set --provider truenas --name dev-vm --cores 4 --memory 16384
+  homeops-cli vm truenas set --name k8s0 --cpuset 0-7 --nodeset 0 --pin-vcpus`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			update := cpuPlacementUpdateFromFlags(cmd, cpu)
+			if !update.IsZero() {
+				normalized, err := vmlifecycle.NormalizeVMProvider(provider)
+				if err != nil {
+					return err
+				}
+				if normalized != "truenas" {
+					return fmt.Errorf("--cpuset, --nodeset, --pin-vcpus, --cpu-mode, and --cpu-model are only supported with --provider truenas")
+				}
+			}
 			name, err := resolveVMNameForAction(name, provider, "configure")
 			if err != nil {
 				return err
@@ -51,39 +73,88 @@ func newSetVMCommand() *cobra.Command {
 			if name == "" {
 				return nil // picker cancelled
 			}
-			if memory, err = promptIntIfInteractive(memory, "New memory in MB (blank = unchanged):", "8192"); err != nil {
-				return err
-			}
-			if cores, err = promptIntIfInteractive(cores, "New CPU cores (blank = unchanged):", "4"); err != nil {
-				return err
+			if update.IsZero() {
+				if memory, err = promptIntIfInteractive(memory, "New memory in MB (blank = unchanged):", "8192"); err != nil {
+					return err
+				}
+				if cores, err = promptIntIfInteractive(cores, "New CPU cores (blank = unchanged):", "4"); err != nil {
+					return err
+				}
 			}
-			if memory == 0 && cores == 0 {
+			if memory == 0 && cores == 0 && update.IsZero() {
 				return fmt.Errorf("nothing to change: pass --memory and/or --cores")
 			}
-			return runLifecycleOp(provider, func(lc vmprov.VMLifecycle) error {
-				return lc.SetVMResources(name, memory, cores)
+			if memory != 0 || cores != 0 {
+				if err := runLifecycleOp(provider, func(lc vmprov.VMLifecycle) error {
+					return lc.SetVMResources(name, memory, cores)
+				}); err != nil {
+					return err
+				}
+			}
+			if update.IsZero() {
+				return nil
+			}
+			return vmlifecycle.WithTrueNASVMManager(common.NewColorLogger(), func(m vmlifecycle.TrueNASVMManager) error {
+				return m.SetVMCPUPlacement(name, update)
 			})
 		},
 	}
 	cmd.Flags().StringVar(&name, "name", "", "VM name (prompts if omitted)")
 	cmd.Flags().IntVar(&memory, "memory", 0, "new memory in MB (0 = unchanged)")
 	cmd.Flags().IntVar(&cores, "cores", 0, "new CPU cores/vCPUs (0 = unchanged)")
+	cmd.Flags().StringVar(&cpu.CPUSet, "cpuset", "", "host CPUs the vCPUs may run on, e.g. 0-7; empty clears (TrueNAS only)")
+	cmd.Flags().StringVar(&cpu.NodeSet, "nodeset", "", "NUMA nodes to allocate guest memory from, e.g. 0; empty clears (TrueNAS only)")
+	cmd.Flags().BoolVar(&cpu.PinVCPUs, "pin-vcpus", false, "pin each vCPU to one CPU of the cpuset (TrueNAS only)")
+	cmd.Flags().StringVar(&cpu.CPUMode, "cpu-mode", "", "CPU mode: HOST-PASSTHROUGH, HOST-MODEL, or CUSTOM (TrueNAS only)")
+	cmd.Flags().StringVar(&cpu.CPUModel, "cpu-model", "", "CPU model for --cpu-mode CUSTOM (TrueNAS only)")
+	_ = cmd.RegisterFlagCompletionFunc("cpu-mode", completion.ValidCPUModes)
+	_ = cmd.RegisterFlagCompletionFunc("cpu-model", completion.ValidTrueNASCPUModels)
 	addProviderFlag(cmd, &provider)
 	return cmd
 }
 
+// cpuPlacementUpdateFromFlags keeps only the placement flags actually passed,
+// so an unset flag leaves the VM's current value alone.
+func cpuPlacementUpdateFromFlags(cmd *cobra.Command, cpu truenas.CPUPlacement) truenas.CPUPlacementUpdate {
+	var update truenas.CPUPlacementUpdate
+	if cmd.Flags().Changed("cpuset") {
+		update.CPUSet = &cpu.CPUSet
+	}
+	if cmd.Flags().Changed("nodeset") {
+		update.NodeSet = &cpu.NodeSet
+	}
+	if cmd.Flags().Changed("pin-vcpus") {
+		update.PinVCPUs = &cpu.PinVCPUs
+	}
+	if cmd.Flags().Changed("cpu-mode") {
+		update.CPUMode = &cpu.CPUMode
+	}
+	if cmd.Flags().Changed("cpu-model") {
+		update.CPUModel = &cpu.CPUModel
+	}
+	return update
+}
+
 // newResizeDiskCommand grows a VM disk.
 func newResizeDiskCommand() *cobra.Command {
 	var name, disk, grow, size, provider string
 	cmd := &cobra.Command{
 		Use:   "resize-disk",
 		Short: "Grow a VM disk (disks can never shrink)",
+		Long: `Grow a VM disk to --size, or by --grow. A bare number is GB. Disks only
+grow: a size that is not larger than the disk fails.
+
+On TrueNAS --disk is boot, openebs, a data disk name (rook), the ZVol path,
+or the disk's position in boot order (1, 2, ...). The ZVol's volsize is
+raised, and its device updated when it records a zvol_volsize. A running
+guest sees the new size after it rescans the disk or reboots.`,
 		Example: `  # Grow the boot disk by 20G
   homeops-cli vm resize-disk --name dev-vm --grow 20G
 
   # Set an absolute size on a specific disk
   homeops-cli vm resize-disk --name dev-vm --disk scsi1 --size 200G
-  homeops-cli vm resize-disk --provider truenas --name dev-vm --disk openebs --grow 100G`,
+  homeops-cli vm resize-disk --provider truenas --name dev-vm --disk openebs --grow 100G
+  homeops-cli vm truenas resize-disk --name k8s0 --disk rook --size 1200`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			name, err := resolveVMNameForAction(name, provider, "resize a disk on")
 			if err != nil {
@@ -103,9 +174,9 @@ func newResizeDiskCommand() *cobra.Command {
 			case grow != "" && size != "":
 				return fmt.Errorf("pass --grow or --size, not both")
 			case grow != "":
-				spec = "+" + strings.TrimPrefix(grow, "+")
+				spec = "+" + gigabytesIfBare(strings.TrimPrefix(grow, "+"))
 			case size != "":
-				spec = size
+				spec = gigabytesIfBare(size)
 			default:
 				return fmt.Errorf("pass --grow <N>G or --size <N>G")
 			}
@@ -115,34 +186,116 @@ func newResizeDiskCommand() *cobra.Command {
 		},
 	}
 	cmd.Flags().StringVar(&name, "name", "", "VM name (prompts if omitted)")
-	cmd.Flags().StringVar(&disk, "disk", "", "disk to resize (default: boot disk; proxmox: scsi0/scsi1..., truenas: boot/openebs/zvol path, vsphere: scsiN or device label)")
-	cmd.Flags().StringVar(&grow, "grow", "", "grow by this much (e.g. 20G)")
-	cmd.Flags().StringVar(&size, "size", "", "grow to this absolute size (e.g. 200G)")
+	cmd.Flags().StringVar(&disk, "disk", "", "disk to resize (default: boot disk; proxmox: scsi0/scsi1..., truenas: boot/openebs/<data disk>/zvol path/position, vsphere: scsiN or device label)")
+	cmd.Flags().StringVar(&grow, "grow", "", "grow by this much (e.g. 20G; a bare number is GB)")
+	cmd.Flags().StringVar(&size, "size", "", "grow to this absolute size (e.g. 200G; a bare number is GB)")
 	addProviderFlag(cmd, &provider)
 	return cmd
 }
 
+// gigabytesIfBare reads a size without a unit as GB: no disk is sized in
+// bytes.
+func gigabytesIfBare(size string) string {
+	if _, err := strconv.ParseUint(size, 10, 64); err == nil {
+		return size + "G"
+	}
+	return size
+}
+
 // newRestartVMCommand reboots a VM.
 func newRestartVMCommand() *cobra.Command {
-	var name, provider string
+	var provider string
+	var force bool
+	var targets vmTargets
 	cmd := &cobra.Command{
-		Use:     "restart",
-		Short:   "Restart (reboot) a VM",
-		Example: `  homeops-cli vm restart --name dev-vm`,
+		Use:   "restart",
+		Short: "Restart (reboot) a VM",
+		Long: `Restart (reboot) a VM. On TrueNAS the restart is waited for until the VM
+reports RUNNING again, and a stopped VM is simply started. --force (TrueNAS
+only) power-cycles a wedged guest that ignores the shutdown: the VM is forced
+off and started. --name k8s0,k8s1 or --match 'k8s*' restarts several VMs,
+--parallel at a time.`,
+		Example: `  homeops-cli vm restart --name dev-vm
+  homeops-cli vm truenas restart --name k8s0 --force
+  homeops-cli vm truenas restart --match 'k8s*' --parallel 1`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			name, err := resolveVMNameForAction(name, provider, "restart")
-			if err != nil {
+			if force {
+				normalized, err := vmlifecycle.NormalizeVMProvider(provider)
+				if err != nil {
+					return err
+				}
+				if normalized != "truenas" {
+					return fmt.Errorf("--force is only supported with --provider truenas")
+				}
+			}
+			return runVMTargets(cmd.Context(), provider, "restart", targets, func(name string) error {
+				name, err := resolveVMNameForAction(name, provider, "restart")
+				if err != nil {
+					return err
+				}
+				if name == "" {
+					return nil // picker cancelled
+				}
+				if force {
+					return vmlifecycle.WithTrueNASVMManager(common.NewColorLogger(), func(m vmlifecycle.TrueNASVMManager) error {
+						return m.PowerCycleVM(name)
+					})
+				}
+				return runLifecycleOp(provider, func(lc vmprov.VMLifecycle) error {
+					return lc.RestartVM(name)
+				})
+			})
+		},
+	}
+	addVMTargetFlags(cmd, &targets, true)
+	cmd.Flags().BoolVar(&force, "force", false, "power the VM off and start it instead of a clean restart (TrueNAS only)")
+	addProviderFlag(cmd, &provider)
+	return cmd
+}
+
+// newSuspendVMCommand pauses a running TrueNAS VM.
+func newSuspendVMCommand() *cobra.Command {
+	var name string
+	cmd := &cobra.Command{
+		Use:   "suspend",
+		Short: "Pause a running VM, keeping its memory",
+		Long: `Pause a running TrueNAS VM's vCPUs (vm.suspend); its memory stays allocated
+on the NAS and 'vm truenas resume' continues it where it stopped. Suspending
+a suspended VM does nothing.`,
+		Example: `  homeops-cli vm truenas suspend --name k8s0`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			vmName, err := resolveVMNameForAction(name, "truenas", "suspend")
+			if err != nil || vmName == "" {
 				return err
 			}
-			if name == "" {
-				return nil // picker cancelled
+			return vmlifecycle.WithTrueNASVMManager(common.NewColorLogger(), func(m vmlifecycle.TrueNASVMManager) error {
+				return m.SuspendVM(vmName)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "VM name (prompts if omitted)")
+	return cmd
+}
+
+// newResumeVMCommand continues a suspended TrueNAS VM.
+func newResumeVMCommand() *cobra.Command {
+	var name string
+	cmd := &cobra.Command{
+		Use:   "resume",
+		Short: "Continue a suspended VM",
+		Long: `Continue a TrueNAS VM paused with 'vm truenas suspend' (vm.resume).
+Resuming a running VM does nothing; a stopped VM needs 'vm start'.`,
+		Example: `  homeops-cli vm truenas resume --name k8s0`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			vmName, err := resolveVMNameForAction(name, "truenas", "resume")
+			if err != nil || vmName == "" {
+				return err
 			}
-			return runLifecycleOp(provider, func(lc vmprov.VMLifecycle) error {
-				return lc.RestartVM(name)
+			return vmlifecycle.WithTrueNASVMManager(common.NewColorLogger(), func(m vmlifecycle.TrueNASVMManager) error {
+				return m.ResumeVM(vmName)
 			})
 		},
 	}
 	cmd.Flags().StringVar(&name, "name", "", "VM name (prompts if omitted)")
-	addProviderFlag(cmd, &provider)
 	return cmd
 }
@@ -0,0 +1,118 @@
+package vm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/truenas"
+	"homeops-cli/internal/ui"
+	"homeops-cli/internal/vmlifecycle"
+)
+
+// trueNASHostTopologyFn reads the NAS CPU layout. SSH is best effort: without
+// it the NUMA breakdown degrades to a single node. Swappable for tests.
+var trueNASHostTopologyFn = func() (truenas.HostTopology, error) {
+	logger := common.NewColorLogger()
+	var sshExec truenas.SSHRunner
+	if client, err := connectTrueNASSSH(); err != nil {
+		logger.Warn("SSH to the NAS failed (%v); NUMA layout will not be available", err)
+	} else {
+		defer func() { _ = client.Close() }()
+		sshExec = client
+	}
+	var topology truenas.HostTopology
+	err := vmlifecycle.WithTrueNASVMManager(logger, func(m vmlifecycle.TrueNASVMManager) error {
+		var err error
+		topology, err = m.HostTopology(sshExec)
+		return err
+	})
+	return topology, err
+}
+
+// newHostTopologyCommand prints the NAS's NUMA nodes and the CPUs existing VMs
+// are pinned to, for choosing --cpuset/--nodeset.
+func newHostTopologyCommand() *cobra.Command {
+	var provider, output string
+	cmd := &cobra.Command{
+		Use:   "host-topology",
+		Short: "Show the NAS CPU/NUMA layout and existing VM CPU pinning",
+		Long: `Print the NAS's logical CPUs per NUMA node and the cpuset/nodeset every VM
+already has, to pick --cpuset/--nodeset for 'talos deploy-vm' or 'vm set'
+without overlapping other VMs. The TrueNAS API reports only CPU counts, so the
+per-node lists come from lscpu over SSH; without SSH all CPUs are shown as
+node 0.`,
+		Example: `  homeops-cli vm truenas host-topology
+  homeops-cli vm truenas host-topology -o json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			normalized, err := vmlifecycle.NormalizeVMProvider(provider)
+			if err != nil {
+				return err
+			}
+			if normalized != "truenas" {
+				return fmt.Errorf("host-topology is only supported with --provider truenas")
+			}
+			if output != "table" && output != "json" && output != "yaml" {
+				return fmt.Errorf("unsupported output format %q (table, json, yaml)", output)
+			}
+			topology, err := trueNASHostTopologyFn()
+			if err != nil {
+				return err
+			}
+			rendered, err := renderHostTopology(topology, output)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), rendered)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&provider, "provider", "truenas", "Virtualization provider (only truenas exposes its host topology)")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table, json, or yaml")
+	return cmd
+}
+
+func renderHostTopology(topology truenas.HostTopology, output string) (string, error) {
+	switch output {
+	case "json":
+		return ui.RenderJSON(topology)
+	case "yaml":
+		raw, err := yaml.Marshal(topology)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(string(raw), "\n"), nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d logical CPUs, %d physical cores (source: %s)\n\n", topology.Model, topology.CPUs, topology.PhysicalCores, topology.Source)
+	nodeRows := make([][]string, 0, len(topology.Nodes))
+	for _, node := range topology.Nodes {
+		nodeRows = append(nodeRows, []string{strconv.Itoa(node.ID), node.CPUs, strconv.Itoa(node.Cores), strconv.Itoa(node.LogicalCPUs)})
+	}
+	b.WriteString(ui.Table([]string{"NODE", "CPUS", "CORES", "LOGICAL CPUS"}, nodeRows))
+	if len(topology.VMs) == 0 {
+		return b.String(), nil
+	}
+	vmRows := make([][]string, 0, len(topology.VMs))
+	for _, v := range topology.VMs {
+		managed := "no"
+		if v.Managed {
+			managed = "yes"
+		}
+		vmRows = append(vmRows, []string{v.Name, strconv.Itoa(v.VCPUs), dashIfEmpty(v.CPUSet), dashIfEmpty(v.NodeSet), strconv.FormatBool(v.PinVCPUs), managed})
+	}
+	b.WriteString("\n\n")
+	b.WriteString(ui.Table([]string{"VM", "VCPUS", "CPUSET", "NODESET", "PINNED", "HOMEOPS"}, vmRows))
+	return b.String(), nil
+}
+
+func dashIfEmpty(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,68 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/testutil"
+	"homeops-cli/internal/truenas"
+	"homeops-cli/internal/vmlifecycle"
+)
+
+func TestHostTopologyCommandRendersNodesAndPlacements(t *testing.T) {
+	testutil.Swap(t, &trueNASHostTopologyFn, func() (truenas.HostTopology, error) {
+		return truenas.HostTopology{
+			Model: "AMD EPYC 7302P", CPUs: 32, PhysicalCores: 16, Source: "lscpu",
+			Nodes: []truenas.NUMANode{
+				{ID: 0, CPUs: "0-7,16-23", Cores: 8, LogicalCPUs: 16},
+				{ID: 1, CPUs: "8-15,24-31", Cores: 8, LogicalCPUs: 16},
+			},
+			VMs: []truenas.VMCPUAssignment{{Name: "k8s0", VCPUs: 8, CPUSet: "0-7", NodeSet: "0", PinVCPUs: true, Managed: true}},
+		}, nil
+	})
+
+	out, err := testutil.ExecuteCommand(newHostTopologyCommand())
+	require.NoError(t, err)
+	assert.Contains(t, out, "AMD EPYC 7302P: 32 logical CPUs, 16 physical cores (source: lscpu)")
+	assert.Contains(t, out, "8-15,24-31")
+	assert.Contains(t, out, "k8s0")
+
+	out, err = testutil.ExecuteCommand(newHostTopologyCommand(), "-o", "json")
+	require.NoError(t, err)
+	assert.Contains(t, out, `"numa_nodes"`)
+
+	_, err = testutil.ExecuteCommand(newHostTopologyCommand(), "--provider", "proxmox")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "only supported with --provider truenas")
+
+	found, _, err := newProviderScopedVMGroup("truenas").Find([]string{"host-topology"})
+	require.NoError(t, err)
+	assert.Equal(t, "host-topology", found.Name())
+	found, _, _ = newProviderScopedVMGroup("vsphere").Find([]string{"host-topology"})
+	assert.NotEqual(t, "host-topology", found.Name(), "host-topology is TrueNAS only")
+}
+
+func TestSetVMCommandCPUPlacementSendsOnlyPassedFlags(t *testing.T) {
+	manager := &fakeTrueNASVMManager{}
+	testutil.Swap(t, &vmlifecycle.GetTrueNASCredentialsFn, func() (string, string, error) { return "truenas.local", "api-key", nil })
+	testutil.Swap(t, &vmlifecycle.NewTrueNASVMManagerFn, func(string, string, int, bool) vmlifecycle.TrueNASVMManager { return manager })
+
+	_, err := testutil.ExecuteCommand(newSetVMCommand(), "--provider", "truenas", "--name", "k8s0", "--cpuset", "0-7", "--pin-vcpus")
+	require.NoError(t, err)
+	require.Len(t, manager.cpuCalls, 1)
+	update := manager.cpuCalls[0]
+	require.NotNil(t, update.CPUSet)
+	assert.Equal(t, "0-7", *update.CPUSet)
+	require.NotNil(t, update.PinVCPUs)
+	assert.True(t, *update.PinVCPUs)
+	assert.Nil(t, update.NodeSet, "unset flags leave the VM's value alone")
+	assert.Nil(t, update.CPUMode)
+	assert.Equal(t, []string{"cpu:k8s0"}, manager.setCalls, "memory and cores are not touched")
+
+	_, err = testutil.ExecuteCommand(newSetVMCommand(), "--provider", "proxmox", "--name", "dev-vm", "--cpuset", "0-3")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "only supported with --provider truenas")
+	assert.Len(t, manager.cpuCalls, 1)
+}
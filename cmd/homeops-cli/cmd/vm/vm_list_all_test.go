@@ -1,6 +1,8 @@
 package vm
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"testing"
 
@@ -9,6 +11,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	vmprov "homeops-cli/internal/provider"
+	"homeops-cli/internal/testutil"
 	"homeops-cli/internal/vmlifecycle"
 )
 
@@ -58,7 +61,7 @@ func TestListAllProvidersContinuesAfterProviderError(t *testing.T) {
 		return &fakeListLifecycle{provider: provider, closed: &closed}, nil
 	}
 
-	inventory, err := collectAllProviderVMs()
+	inventory, err := collectAllProviderVMs(context.Background())
 	require.NoError(t, err)
 	assert.Len(t, inventory.VMs, 2)
 	assert.Len(t, inventory.ProviderErrors, 1)
@@ -125,3 +128,24 @@ func TestListAllCommandUsesAllProviderInventory(t *testing.T) {
 
 	require.NoError(t, err)
 }
+
+func TestInfoVMStructuredOutputRendersTheSummary(t *testing.T) {
+	testutil.Swap(t, &vmlifecycle.NewVMLifecycleFn, func(provider string) (vmprov.VMLifecycle, error) {
+		return &fakeListLifecycle{provider: provider}, nil
+	})
+
+	stdout, _, err := testutil.CaptureOutput(func() {
+		require.NoError(t, infoVMWithProvider(context.Background(), "truenas-vm", "truenas", "json"))
+	})
+	require.NoError(t, err)
+	var summary vmprov.VMSummary
+	require.NoError(t, json.Unmarshal([]byte(stdout), &summary))
+	assert.Equal(t, "truenas-1", summary.ID)
+
+	rendered, err := renderVMSummary(vmprov.VMSummary{Name: "cp-0", ZVols: []string{"flashstor/VM/cp-0-boot"}}, "yaml")
+	require.NoError(t, err)
+	assert.Contains(t, rendered, "zvols:\n    - flashstor/VM/cp-0-boot")
+
+	require.EqualError(t, infoVMWithProvider(context.Background(), "missing", "truenas", "json"), "VM missing not found")
+	require.EqualError(t, infoVMWithProvider(context.Background(), "truenas-vm", "truenas", "xml"), `unsupported output format "xml" (table, json, yaml)`)
+}
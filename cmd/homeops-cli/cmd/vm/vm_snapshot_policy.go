@@ -0,0 +1,191 @@
+package vm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/truenas"
+	"homeops-cli/internal/ui"
+	"homeops-cli/internal/vmlifecycle"
+)
+
+// newSnapshotPolicyCommand manages the periodic snapshot tasks and retention
+// of a TrueNAS VM's zvols.
+func newSnapshotPolicyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot-policy",
+		Short: "Schedule VM zvol snapshots and prune them to a retention policy",
+		Long: `Give a TrueNAS VM's zvols a lifecycle. apply creates (or updates) one
+TrueNAS periodic snapshot task per zvol for each of the hourly, daily, and
+weekly tiers, with a lifetime equal to the keep count; a keep count of 0
+removes that tier's task. Tasks created outside homeops-cli are shown but
+never changed.
+
+prune applies the same retention right away: for each tier it keeps the
+newest snapshot of each of the newest N hours/days/ISO weeks and deletes the
+rest. Only snapshots the policy's tasks took (homeops-<tier>-...) are
+candidates; manual and pre-upgrade snapshots are left alone, and a snapshot
+that backs a ZFS clone (e.g. from 'vm clone') is never deleted.`,
+		Example: `  homeops-cli vm truenas snapshot-policy apply --name k8s0 --keep-hourly 0 --keep-daily 7 --keep-weekly 4
+  homeops-cli vm truenas snapshot-policy show
+  homeops-cli vm truenas snapshot-policy prune --name k8s0 --dry-run
+  homeops-cli vm truenas snapshot-policy prune --name k8s0 --yes`,
+	}
+	cmd.AddCommand(newSnapshotPolicyApplyCommand(), newSnapshotPolicyShowCommand(), newSnapshotPolicyPruneCommand())
+	return cmd
+}
+
+func newSnapshotPolicyApplyCommand() *cobra.Command {
+	var name string
+	var policy truenas.SnapshotPolicy
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Create or update the VM's periodic snapshot tasks",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			vmName, err := resolveVMNameForAction(name, "truenas", "apply a snapshot policy to")
+			if err != nil || vmName == "" {
+				return err
+			}
+			if err := policy.Validate(); err != nil {
+				return err
+			}
+			return vmlifecycle.WithTrueNASVMManager(common.NewColorLogger(), func(m vmlifecycle.TrueNASVMManager) error {
+				return m.ApplySnapshotPolicy(vmName, policy)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "VM name (prompts if omitted)")
+	cmd.Flags().IntVar(&policy.KeepHourly, "keep-hourly", 0, "hourly snapshots to keep (0 = no hourly task)")
+	cmd.Flags().IntVar(&policy.KeepDaily, "keep-daily", 7, "daily snapshots to keep (0 = no daily task)")
+	cmd.Flags().IntVar(&policy.KeepWeekly, "keep-weekly", 4, "weekly snapshots to keep (0 = no weekly task)")
+	return cmd
+}
+
+func newSnapshotPolicyShowCommand() *cobra.Command {
+	var name, output string
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Show the snapshot tasks covering each VM's zvols",
+		Example: `  homeops-cli vm truenas snapshot-policy show
+  homeops-cli vm truenas snapshot-policy show --name k8s0 -o json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "table" && output != "json" && output != "yaml" {
+				return fmt.Errorf("unsupported output format %q (table, json, yaml)", output)
+			}
+			var tasks []truenas.VMSnapshotTask
+			if err := vmlifecycle.WithTrueNASVMManager(common.NewColorLogger(), func(m vmlifecycle.TrueNASVMManager) error {
+				var err error
+				tasks, err = m.SnapshotPolicies(name)
+				return err
+			}); err != nil {
+				return err
+			}
+			rendered, err := renderSnapshotPolicies(tasks, output)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), rendered)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "VM name (default: every VM)")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table, json, or yaml")
+	return cmd
+}
+
+func renderSnapshotPolicies(tasks []truenas.VMSnapshotTask, output string) (string, error) {
+	switch output {
+	case "json":
+		return ui.RenderJSON(tasks)
+	case "yaml":
+		raw, err := yaml.Marshal(tasks)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(string(raw), "\n"), nil
+	}
+	if len(tasks) == 0 {
+		return "No periodic snapshot tasks cover any VM zvol", nil
+	}
+	rows := make([][]string, 0, len(tasks))
+	for _, entry := range tasks {
+		tier := entry.Tier
+		if tier == "" {
+			tier = "external"
+		}
+		task := entry.Task
+		rows = append(rows, []string{
+			entry.VM, entry.ZVol, tier, task.Schedule.Cron(),
+			fmt.Sprintf("%d %s", task.LifetimeValue, strings.ToLower(task.LifetimeUnit)),
+			strconv.FormatBool(task.Enabled), strconv.Itoa(task.ID),
+		})
+	}
+	return ui.Table([]string{"VM", "ZVOL", "TIER", "SCHEDULE", "RETENTION", "ENABLED", "TASK"}, rows), nil
+}
+
+func newSnapshotPolicyPruneCommand() *cobra.Command {
+	var name string
+	var dryRun, yes bool
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete the VM's snapshots outside its policy now (DESTRUCTIVE)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			vmName, err := resolveVMNameForAction(name, "truenas", "prune snapshots of")
+			if err != nil || vmName == "" {
+				return err
+			}
+			return vmlifecycle.WithTrueNASVMManager(common.NewColorLogger(), func(m vmlifecycle.TrueNASVMManager) error {
+				plan, err := m.PlanSnapshotPrune(vmName)
+				if err != nil {
+					return err
+				}
+				out := cmd.OutOrStdout()
+				_, _ = fmt.Fprintln(out, renderSnapshotPrunePlan(plan))
+				if len(plan.Delete) == 0 || dryRun {
+					return nil
+				}
+				if !yes {
+					ok, err := confirmActionFn(fmt.Sprintf("Delete %d snapshots of VM %s? This cannot be undone.", len(plan.Delete), vmName), false)
+					if err != nil {
+						return err
+					}
+					if !ok {
+						return fmt.Errorf("snapshot prune cancelled by user")
+					}
+				}
+				return m.PruneVMSnapshots(plan)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "VM name (prompts if omitted)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "show what would be deleted without deleting")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip the confirmation prompt")
+	return cmd
+}
+
+func renderSnapshotPrunePlan(plan truenas.SnapshotPrunePlan) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "VM %s: %d to delete, %d kept by policy, %d protected, %d not managed by the policy", plan.VM, len(plan.Delete), plan.Kept, len(plan.Protected), plan.Unmanaged)
+	if len(plan.Delete) > 0 {
+		rows := make([][]string, 0, len(plan.Delete))
+		for _, snap := range plan.Delete {
+			rows = append(rows, []string{snap.SnapshotName, snap.Dataset})
+		}
+		b.WriteString("\n\n")
+		b.WriteString(ui.Table([]string{"DELETE", "DATASET"}, rows))
+	}
+	if len(plan.Protected) > 0 {
+		rows := make([][]string, 0, len(plan.Protected))
+		for _, protected := range plan.Protected {
+			rows = append(rows, []string{protected.Snapshot.SnapshotName, protected.Snapshot.Dataset, protected.Reason})
+		}
+		b.WriteString("\n\n")
+		b.WriteString(ui.Table([]string{"PROTECTED", "DATASET", "REASON"}, rows))
+	}
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/testutil"
+	"homeops-cli/internal/truenas"
+	"homeops-cli/internal/vmlifecycle"
+)
+
+func swapSnapshotPolicyManager(t *testing.T, manager *fakeTrueNASVMManager) {
+	t.Helper()
+	testutil.Swap(t, &vmlifecycle.GetTrueNASCredentialsFn, func() (string, string, error) { return "truenas.local", "api-key", nil })
+	testutil.Swap(t, &vmlifecycle.NewTrueNASVMManagerFn, func(string, string, int, bool) vmlifecycle.TrueNASVMManager { return manager })
+}
+
+func TestSnapshotPolicyApplyAndShow(t *testing.T) {
+	manager := &fakeTrueNASVMManager{policyTasks: []truenas.VMSnapshotTask{{
+		VM: "k8s0", ZVol: "flashstor/VM/k8s0-boot", Tier: "daily",
+		Task: truenas.SnapshotTask{ID: 11, LifetimeValue: 7, LifetimeUnit: "DAY", Enabled: true,
+			Schedule: truenas.SnapshotSchedule{Minute: "0", Hour: "0", Dom: "*", Month: "*", Dow: "*"}},
+	}}}
+	swapSnapshotPolicyManager(t, manager)
+
+	_, err := testutil.ExecuteCommand(newSnapshotPolicyCommand(), "apply", "--name", "k8s0", "--keep-weekly", "2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"k8s0:0:7:2"}, manager.policyCalls)
+
+	_, err = testutil.ExecuteCommand(newSnapshotPolicyCommand(), "apply", "--name", "k8s0", "--keep-daily", "-1")
+	require.ErrorContains(t, err, "--keep-daily must be >= 0")
+	assert.Len(t, manager.policyCalls, 1)
+
+	out, err := testutil.ExecuteCommand(newSnapshotPolicyCommand(), "show")
+	require.NoError(t, err)
+	assert.Contains(t, out, "flashstor/VM/k8s0-boot")
+	assert.Contains(t, out, "0 0 * * *")
+	assert.Contains(t, out, "7 day")
+}
+
+func TestSnapshotPolicyPruneRequiresConfirmation(t *testing.T) {
+	manager := &fakeTrueNASVMManager{prunePlan: truenas.SnapshotPrunePlan{
+		Delete: []truenas.ZFSSnapshot{{ID: "flashstor/VM/k8s0-boot@homeops-daily-2026-10-01_00-00", Dataset: "flashstor/VM/k8s0-boot", SnapshotName: "homeops-daily-2026-10-01_00-00"}},
+		Protected: []truenas.ProtectedSnapshot{{
+			Snapshot: truenas.ZFSSnapshot{Dataset: "flashstor/VM/k8s0-boot", SnapshotName: "homeops-daily-2026-09-30_00-00"},
+			Reason:   "backs clone flashstor/VM/k8s1-boot",
+		}},
+		Kept: 7,
+	}}
+	swapSnapshotPolicyManager(t, manager)
+	var prompts []string
+	testutil.Swap(t, &confirmActionFn, func(msg string, _ bool) (bool, error) {
+		prompts = append(prompts, msg)
+		return false, nil
+	})
+
+	out, err := testutil.ExecuteCommand(newSnapshotPolicyCommand(), "prune", "--name", "k8s0", "--dry-run")
+	require.NoError(t, err)
+	assert.Contains(t, out, "homeops-daily-2026-10-01_00-00")
+	assert.Contains(t, out, "backs clone flashstor/VM/k8s1-boot")
+	assert.Empty(t, prompts)
+
+	_, err = testutil.ExecuteCommand(newSnapshotPolicyCommand(), "prune", "--name", "k8s0")
+	require.ErrorContains(t, err, "cancelled")
+	assert.Equal(t, []string{"Delete 1 snapshots of VM k8s0? This cannot be undone."}, prompts)
+	assert.Empty(t, manager.pruned)
+
+	_, err = testutil.ExecuteCommand(newSnapshotPolicyCommand(), "prune", "--name", "k8s0", "--yes")
+	require.NoError(t, err)
+	require.Len(t, manager.pruned, 1)
+	assert.Equal(t, "k8s0", manager.pruned[0].VM)
+	assert.Len(t, prompts, 1, "--yes skips the prompt")
+
+	found, _, _ := newProviderScopedVMGroup("proxmox").Find([]string{"snapshot-policy"})
+	assert.NotEqual(t, "snapshot-policy", found.Name(), "snapshot-policy is TrueNAS only")
+}
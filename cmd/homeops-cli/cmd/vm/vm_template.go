@@ -119,6 +119,9 @@ Per provider:
 					return nil // cancelled
 				}
 			}
+			if err := vmlifecycle.ValidateVMNameFor(normalized, name); err != nil {
+				return err
+			}
 			imageRef, ciUser, err := resolveCloudImage(osKey, image, user)
 			if err != nil {
 				return err
@@ -0,0 +1,340 @@
+package vm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/truenas"
+	"homeops-cli/internal/ui"
+	"homeops-cli/internal/vmlifecycle"
+)
+
+// trueNASVMUsageFn reads per-VM usage from the NAS reporting plugin. SSH is
+// best effort: without it disk graphs only match zvols reported by dataset
+// path. Swappable for tests.
+var trueNASVMUsageFn = func(end time.Time, window time.Duration) (truenas.VMUsageReport, error) {
+	logger := common.NewColorLogger()
+	var sshExec truenas.SSHRunner
+	if client, err := connectTrueNASSSH(); err != nil {
+		logger.Warn("SSH to the NAS failed (%v); zvol I/O may be incomplete", err)
+	} else {
+		defer func() { _ = client.Close() }()
+		sshExec = client
+	}
+	var report truenas.VMUsageReport
+	err := vmlifecycle.WithTrueNASVMManager(logger, func(m vmlifecycle.TrueNASVMManager) error {
+		var err error
+		report, err = m.VMUsage(sshExec, end, window)
+		return err
+	})
+	return report, err
+}
+
+// guestMemory is what a Kubernetes node reports about its own memory.
+type guestMemory struct {
+	UsedBytes     int64
+	CapacityBytes int64
+}
+
+// guestNodeMemoryFn reads working-set usage from `kubectl top nodes` and
+// capacity from the Node objects. Swappable for tests.
+var guestNodeMemoryFn = func(ctx context.Context) (map[string]guestMemory, error) {
+	raw, err := common.RunCommandWithContextOutput(ctx, "kubectl", "get", "nodes", "-o", "json")
+	if err != nil {
+		return nil, fmt.Errorf("kubectl get nodes: %w", err)
+	}
+	top, err := common.RunCommandWithContextOutput(ctx, "kubectl", "top", "nodes", "--no-headers")
+	if err != nil {
+		return nil, fmt.Errorf("kubectl top nodes: %w", err)
+	}
+	return parseGuestNodeMemory(raw, top)
+}
+
+func parseGuestNodeMemory(nodesJSON, top []byte) (map[string]guestMemory, error) {
+	var nodes struct {
+		Items []struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Status struct {
+				Capacity map[string]string `json:"capacity"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(nodesJSON, &nodes); err != nil {
+		return nil, fmt.Errorf("parse kubectl get nodes: %w", err)
+	}
+	guests := map[string]guestMemory{}
+	for _, node := range nodes.Items {
+		var guest guestMemory
+		if capacity, err := resource.ParseQuantity(node.Status.Capacity["memory"]); err == nil {
+			guest.CapacityBytes = capacity.Value()
+		}
+		guests[node.Metadata.Name] = guest
+	}
+	for lineNumber, line := range strings.Split(strings.TrimSpace(string(top)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("parse kubectl top nodes line %d: expected 5 fields, got %d", lineNumber+1, len(fields))
+		}
+		if fields[3] == "<unknown>" {
+			continue
+		}
+		used, err := resource.ParseQuantity(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("parse memory usage on line %d: %w", lineNumber+1, err)
+		}
+		guest := guests[fields[0]]
+		guest.UsedBytes = used.Value()
+		guests[fields[0]] = guest
+	}
+	return guests, nil
+}
+
+// vmUsageRow is one VM joined with the Kubernetes node it runs.
+type vmUsageRow struct {
+	truenas.VMUsage `yaml:",inline"`
+	Node            string   `json:"node,omitempty" yaml:"node,omitempty"`
+	GuestUsedBytes  int64    `json:"guest_used_bytes,omitempty" yaml:"guest_used_bytes,omitempty"`
+	GuestTotalBytes int64    `json:"guest_total_bytes,omitempty" yaml:"guest_total_bytes,omitempty"`
+	Notes           []string `json:"notes,omitempty" yaml:"notes,omitempty"`
+}
+
+type vmUsageView struct {
+	Source               string       `json:"source" yaml:"source"`
+	WindowSeconds        int          `json:"window_seconds" yaml:"window_seconds"`
+	End                  time.Time    `json:"end" yaml:"end"`
+	PhysicalMemoryBytes  int64        `json:"physical_memory_bytes" yaml:"physical_memory_bytes"`
+	CommittedMemoryBytes int64        `json:"committed_memory_bytes" yaml:"committed_memory_bytes"`
+	Overcommitted        bool         `json:"overcommitted" yaml:"overcommitted"`
+	VMs                  []vmUsageRow `json:"vms" yaml:"vms"`
+}
+
+const (
+	// balloonedRatio flags a guest that sees less memory than configured.
+	balloonedRatio = 0.95
+	// hostHeldRatio flags host-side memory exceeding the guest's working set
+	// by more than this share of the configured memory: pages the guest
+	// freed (often page cache) that the host has not reclaimed.
+	hostHeldRatio = 0.25
+)
+
+// correlateVMUsage pairs VMs with Kubernetes nodes by name, ignoring '-'
+// and '_' (TrueNAS VM names cannot contain dashes, so k8s-0 runs as k8s0),
+// and notes where host and guest disagree about memory.
+func correlateVMUsage(report truenas.VMUsageReport, guests map[string]guestMemory) vmUsageView {
+	view := vmUsageView{
+		Source: report.Source, WindowSeconds: report.WindowSeconds, End: report.End,
+		PhysicalMemoryBytes: report.PhysicalMemoryBytes, CommittedMemoryBytes: report.CommittedMemoryBytes,
+		Overcommitted: report.PhysicalMemoryBytes > 0 && report.CommittedMemoryBytes > report.PhysicalMemoryBytes,
+	}
+	nodesByKey := map[string]string{}
+	for node := range guests {
+		nodesByKey[vmUsageNameKey(node)] = node
+	}
+	for _, usage := range report.VMs {
+		row := vmUsageRow{VMUsage: usage}
+		if node, ok := nodesByKey[vmUsageNameKey(usage.Name)]; ok {
+			guest := guests[node]
+			row.Node, row.GuestUsedBytes, row.GuestTotalBytes = node, guest.UsedBytes, guest.CapacityBytes
+			if guest.CapacityBytes > 0 && float64(guest.CapacityBytes) < float64(usage.MemoryBytes)*balloonedRatio {
+				row.Notes = append(row.Notes, fmt.Sprintf("ballooned: guest sees %s of %s", formatUsageBytes(guest.CapacityBytes), formatUsageBytes(usage.MemoryBytes)))
+			}
+			if usage.HostMemoryBytes != nil && guest.UsedBytes > 0 {
+				held := int64(usage.HostMemoryBytes.Current) - guest.UsedBytes
+				if float64(held) > float64(usage.MemoryBytes)*hostHeldRatio {
+					row.Notes = append(row.Notes, fmt.Sprintf("host holds %s more than the guest uses", formatUsageBytes(held)))
+				}
+			}
+		}
+		if len(usage.UnmappedZVols) > 0 {
+			row.Notes = append(row.Notes, "no I/O graph for "+strings.Join(usage.UnmappedZVols, ", "))
+		}
+		view.VMs = append(view.VMs, row)
+	}
+	return view
+}
+
+func vmUsageNameKey(name string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(name))
+}
+
+// newVMUsageCommand reports CPU, memory, and zvol I/O of homeops-managed
+// TrueNAS VMs for capacity planning.
+func newVMUsageCommand() *cobra.Command {
+	var (
+		provider, output string
+		window, interval time.Duration
+		watch, noGuest   bool
+	)
+	cmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Show CPU, memory, and zvol I/O of homeops-managed TrueNAS VMs",
+		Long: `Read per-VM CPU, memory, and zvol read/write throughput from the NAS
+reporting plugin (reporting.netdata_get_data on SCALE 23.10 and newer,
+reporting.get_data on older releases) and show the latest sample next to the
+average over --window. CPU is a percentage of the VM's vCPUs.
+
+VMs are matched to Kubernetes nodes by name to compare host-side memory with
+what the guest reports: a guest seeing less memory than configured is
+ballooned, and host memory well above the guest's working set is memory the
+guest freed but the host still holds. The footer compares the configured
+memory of running VMs with the NAS RAM.`,
+		Example: `  homeops-cli vm truenas usage
+  homeops-cli vm truenas usage --watch --interval 5s
+  homeops-cli vm truenas usage --window 15m -o json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			normalized, err := vmlifecycle.NormalizeVMProvider(provider)
+			if err != nil {
+				return err
+			}
+			if normalized != "truenas" {
+				return fmt.Errorf("usage is only supported with --provider truenas")
+			}
+			if output != "table" && output != "json" && output != "yaml" {
+				return fmt.Errorf("unsupported output format %q (table, json, yaml)", output)
+			}
+			if watch && output != "table" {
+				return fmt.Errorf("--watch only supports table output")
+			}
+			if window <= 0 || interval <= 0 {
+				return fmt.Errorf("--window and --interval must be positive")
+			}
+			ctx := cmd.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			out := cmd.OutOrStdout()
+			if !watch {
+				return printVMUsage(ctx, out, window, output, !noGuest)
+			}
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				if isTerminalWriter(out) {
+					_, _ = fmt.Fprint(out, "\033[H\033[2J")
+				}
+				if err := printVMUsage(ctx, out, window, output, !noGuest); err != nil {
+					return err
+				}
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-ticker.C:
+				}
+			}
+		},
+	}
+	cmd.Flags().StringVar(&provider, "provider", "truenas", "Virtualization provider (only truenas exposes per-VM reporting)")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table, json, or yaml")
+	cmd.Flags().DurationVar(&window, "window", 5*time.Minute, "Averaging window ending now")
+	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "Refresh the table in place until interrupted")
+	cmd.Flags().DurationVar(&interval, "interval", 10*time.Second, "Refresh interval for --watch")
+	cmd.Flags().BoolVar(&noGuest, "no-guest", false, "Skip the Kubernetes node memory comparison")
+	return cmd
+}
+
+func printVMUsage(ctx context.Context, out io.Writer, window time.Duration, output string, withGuest bool) error {
+	report, err := trueNASVMUsageFn(time.Now(), window)
+	if err != nil {
+		return err
+	}
+	var guests map[string]guestMemory
+	if withGuest {
+		if guests, err = guestNodeMemoryFn(ctx); err != nil {
+			common.NewColorLogger().Warn("Guest memory unavailable (%v); showing host-side usage only", err)
+		}
+	}
+	rendered, err := renderVMUsage(correlateVMUsage(report, guests), output)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(out, rendered)
+	return nil
+}
+
+func renderVMUsage(view vmUsageView, output string) (string, error) {
+	switch output {
+	case "json":
+		return ui.RenderJSON(view)
+	case "yaml":
+		raw, err := yaml.Marshal(view)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(string(raw), "\n"), nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Now vs %s average, from %s at %s\n\n", time.Duration(view.WindowSeconds)*time.Second, view.Source, view.End.Format(time.RFC3339))
+	rows := make([][]string, 0, len(view.VMs))
+	for _, row := range view.VMs {
+		guest := "-"
+		if row.Node != "" && (row.GuestUsedBytes > 0 || row.GuestTotalBytes > 0) {
+			guest = fmt.Sprintf("%s / %s", formatUsageBytes(row.GuestUsedBytes), formatUsageBytes(row.GuestTotalBytes))
+		}
+		rows = append(rows, []string{
+			row.Name, row.State, dashIfEmpty(row.Node),
+			formatUsageSample(row.CPUPercent, func(v float64) string { return fmt.Sprintf("%.0f%%", v) }),
+			formatUsageSample(row.HostMemoryBytes, func(v float64) string { return formatUsageBytes(int64(v)) }),
+			guest,
+			formatUsageSample(row.ReadBytesPerSec, formatUsageRate),
+			formatUsageSample(row.WriteBytesPerSec, formatUsageRate),
+			dashIfEmpty(strings.Join(row.Notes, "; ")),
+		})
+	}
+	b.WriteString(ui.Table([]string{"VM", "STATE", "NODE", "CPU", "HOST MEM", "GUEST MEM (USED / TOTAL)", "READ", "WRITE", "NOTES"}, rows))
+	if view.PhysicalMemoryBytes > 0 {
+		fmt.Fprintf(&b, "\n\nRunning VMs are configured with %s of %s NAS memory", formatUsageBytes(view.CommittedMemoryBytes), formatUsageBytes(view.PhysicalMemoryBytes))
+		if view.Overcommitted {
+			b.WriteString(" (OVERCOMMITTED)")
+		}
+	}
+	return b.String(), nil
+}
+
+// formatUsageSample renders "now / average", or "-" without data.
+func formatUsageSample(sample *truenas.UsageSample, format func(float64) string) string {
+	if sample == nil {
+		return "-"
+	}
+	return format(sample.Current) + " / " + format(sample.Average)
+}
+
+func formatUsageRate(bytesPerSec float64) string {
+	return formatUsageBytes(int64(bytesPerSec)) + "/s"
+}
+
+func formatUsageBytes(size int64) string {
+	const unit = int64(1024)
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size)
+	units := []string{"KiB", "MiB", "GiB", "TiB"}
+	for _, suffix := range units {
+		value /= float64(unit)
+		if value < float64(unit) || suffix == units[len(units)-1] {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+	}
+	return fmt.Sprintf("%d B", size)
+}
+
+func isTerminalWriter(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && isatty.IsTerminal(f.Fd())
+}
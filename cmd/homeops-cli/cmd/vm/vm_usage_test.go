@@ -0,0 +1,109 @@
+package vm
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/testutil"
+	"homeops-cli/internal/truenas"
+)
+
+const (
+	usageTestNodes = `{"items":[
+  {"metadata":{"name":"k8s-0"},"status":{"capacity":{"memory":"16Gi"}}},
+  {"metadata":{"name":"k8s-1"},"status":{"capacity":{"memory":"12Gi"}}},
+  {"metadata":{"name":"k8s-2"},"status":{"capacity":{"memory":"16Gi"}}}
+]}`
+	usageTestTop = `k8s-0   850m   21%   6Gi   37%
+k8s-1   400m   10%   5Gi   41%
+k8s-2   <unknown>   <unknown>   <unknown>   <unknown>
+`
+)
+
+func usageTestReport() truenas.VMUsageReport {
+	return truenas.VMUsageReport{
+		Source: "reporting.netdata_get_data", WindowSeconds: 300, End: time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC),
+		PhysicalMemoryBytes: 32 << 30, CommittedMemoryBytes: 40 << 30,
+		VMs: []truenas.VMUsage{
+			{Name: "k8s0", State: "RUNNING", VCPUs: 4, MemoryBytes: 16 << 30, CPUPercent: &truenas.UsageSample{Current: 45, Average: 37.5},
+				HostMemoryBytes: &truenas.UsageSample{Current: 12 << 30, Average: 10 << 30}, ReadBytesPerSec: &truenas.UsageSample{Current: 2 << 20, Average: 1 << 20}},
+			{Name: "k8s1", State: "RUNNING", VCPUs: 4, MemoryBytes: 16 << 30, HostMemoryBytes: &truenas.UsageSample{Current: 6 << 30, Average: 6 << 30},
+				UnmappedZVols: []string{"flashstor/VM/k8s1-openebs"}},
+			{Name: "dev0", State: "STOPPED", VCPUs: 2, MemoryBytes: 4 << 30},
+		},
+	}
+}
+
+func TestParseGuestNodeMemory(t *testing.T) {
+	guests, err := parseGuestNodeMemory([]byte(usageTestNodes), []byte(usageTestTop))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]guestMemory{
+		"k8s-0": {UsedBytes: 6 << 30, CapacityBytes: 16 << 30},
+		"k8s-1": {UsedBytes: 5 << 30, CapacityBytes: 12 << 30},
+		"k8s-2": {CapacityBytes: 16 << 30},
+	}, guests, "nodes without metrics keep their capacity")
+
+	_, err = parseGuestNodeMemory([]byte(usageTestNodes), []byte("k8s-0 850m 21%\n"))
+	require.ErrorContains(t, err, "expected 5 fields, got 3")
+}
+
+func TestCorrelateVMUsageFlagsMemoryDisagreement(t *testing.T) {
+	guests, err := parseGuestNodeMemory([]byte(usageTestNodes), []byte(usageTestTop))
+	require.NoError(t, err)
+	view := correlateVMUsage(usageTestReport(), guests)
+
+	assert.True(t, view.Overcommitted)
+	require.Len(t, view.VMs, 3)
+	assert.Equal(t, "k8s-0", view.VMs[0].Node, "dashes are ignored when matching VM and node names")
+	assert.Equal(t, []string{"host holds 6.0 GiB more than the guest uses"}, view.VMs[0].Notes)
+	assert.Equal(t, []string{"ballooned: guest sees 12.0 GiB of 16.0 GiB", "no I/O graph for flashstor/VM/k8s1-openebs"}, view.VMs[1].Notes)
+	assert.Empty(t, view.VMs[2].Node, "dev0 is not a Kubernetes node")
+
+	assert.Empty(t, correlateVMUsage(usageTestReport(), nil).VMs[0].Notes, "without guest data only host-side values are shown")
+}
+
+func TestVMUsageCommandRendersTable(t *testing.T) {
+	var windows []time.Duration
+	testutil.Swap(t, &trueNASVMUsageFn, func(_ time.Time, window time.Duration) (truenas.VMUsageReport, error) {
+		windows = append(windows, window)
+		return usageTestReport(), nil
+	})
+	testutil.Swap(t, &guestNodeMemoryFn, func(context.Context) (map[string]guestMemory, error) {
+		return parseGuestNodeMemory([]byte(usageTestNodes), []byte(usageTestTop))
+	})
+
+	out, err := testutil.ExecuteCommand(newVMUsageCommand(), "--window", "15m")
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{15 * time.Minute}, windows)
+	assert.Contains(t, out, "from reporting.netdata_get_data")
+	assert.Contains(t, out, "45% / 38%")
+	assert.Contains(t, out, "12.0 GiB / 10.0 GiB")
+	assert.Contains(t, out, "6.0 GiB / 16.0 GiB")
+	assert.Contains(t, out, "2.0 MiB/s / 1.0 MiB/s")
+	assert.Contains(t, out, "Running VMs are configured with 40.0 GiB of 32.0 GiB NAS memory (OVERCOMMITTED)")
+
+	out, err = testutil.ExecuteCommand(newVMUsageCommand(), "-o", "json", "--no-guest")
+	require.NoError(t, err)
+	var view vmUsageView
+	require.NoError(t, json.NewDecoder(strings.NewReader(out)).Decode(&view))
+	assert.Equal(t, "k8s0", view.VMs[0].Name)
+	assert.Empty(t, view.VMs[0].Node)
+
+	_, err = testutil.ExecuteCommand(newVMUsageCommand(), "--watch", "-o", "json")
+	require.ErrorContains(t, err, "--watch only supports table output")
+	_, err = testutil.ExecuteCommand(newVMUsageCommand(), "--provider", "proxmox")
+	require.ErrorContains(t, err, "only supported with --provider truenas")
+
+	found, _, err := newProviderScopedVMGroup("truenas").Find([]string{"usage"})
+	require.NoError(t, err)
+	assert.Equal(t, "usage", found.Name())
+	found, _, err = NewManageVMCommand().Find([]string{"usage"})
+	require.NoError(t, err)
+	assert.Equal(t, "usage", found.Name())
+}
@@ -0,0 +1,404 @@
+package volsync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"homeops-cli/cmd/completion"
+	"homeops-cli/internal/kubeutil"
+	"homeops-cli/internal/ui"
+)
+
+// Audit finding types, one per way the backup wiring can break.
+const (
+	auditMissingSourcePVC   = "missing-source-pvc"
+	auditSourceNotBound     = "source-pvc-not-bound"
+	auditIdleSourcePVC      = "idle-source-pvc"
+	auditMissingSecret      = "missing-repository-secret"
+	auditSecretKeys         = "repository-secret-keys"
+	auditSharedRepository   = "shared-repository"
+	auditUnprotectedRestore = "no-replicationsource"
+)
+
+type volsyncAuditFinding struct {
+	Type      string        `json:"type"`
+	Severity  volsyncStatus `json:"severity"`
+	Namespace string        `json:"namespace"`
+	Object    string        `json:"object"`
+	Detail    string        `json:"detail"`
+	Hint      string        `json:"hint"`
+}
+
+type volsyncAuditReport struct {
+	Sources  int                   `json:"sources"`
+	Warn     int                   `json:"warn"`
+	Fail     int                   `json:"fail"`
+	Findings []volsyncAuditFinding `json:"findings"`
+}
+
+func (r *volsyncAuditReport) add(finding volsyncAuditFinding) {
+	r.Findings = append(r.Findings, finding)
+	if finding.Severity == volsyncFail {
+		r.Fail++
+	} else {
+		r.Warn++
+	}
+}
+
+func newAuditCommand() *cobra.Command {
+	var namespace, output string
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Audit ReplicationSource wiring for renamed PVCs, broken secrets, and unprotected restores",
+		Long: `Cross-reference every ReplicationSource with the PVCs, pods, and repository
+Secrets it depends on. Findings cover a sourcePVC that no longer exists or is not
+Bound, a source PVC no running pod mounts, a missing or incomplete repository
+Secret, two sources writing to the same repository identity, and PVCs restored
+from a ReplicationDestination that nothing backs up any more. The command exits
+non-zero when any FAIL finding is reported.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runVolsyncAudit(cmd.Context(), namespace, output, cmd.OutOrStdout())
+		},
+	}
+	cmd.Flags().StringVarP(&namespace, "namespace", "n", "", "namespace to audit (default: all namespaces)")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table or json")
+	_ = cmd.RegisterFlagCompletionFunc("namespace", completion.ValidNamespaces)
+	return cmd
+}
+
+func runVolsyncAudit(ctx context.Context, namespace, output string, out io.Writer) error {
+	if err := ui.ValidateOutputFormat(output); err != nil {
+		return err
+	}
+	report, err := buildVolsyncAuditReport(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	rendered, err := renderVolsyncAuditReport(report, output)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(out, rendered)
+	if report.Fail > 0 {
+		return fmt.Errorf("volsync audit found %d failing finding(s)", report.Fail)
+	}
+	return nil
+}
+
+type auditSourceList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Spec struct {
+			SourcePVC string `json:"sourcePVC"`
+			Kopia     *struct {
+				Repository string `json:"repository"`
+				Username   string `json:"username"`
+				Hostname   string `json:"hostname"`
+			} `json:"kopia"`
+			Restic *struct {
+				Repository string `json:"repository"`
+			} `json:"restic"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+type auditPVCList struct {
+	Items []struct {
+		Metadata struct {
+			Name            string                    `json:"name"`
+			Namespace       string                    `json:"namespace"`
+			Labels          map[string]string         `json:"labels"`
+			OwnerReferences []kubeutil.OwnerReference `json:"ownerReferences"`
+		} `json:"metadata"`
+		Spec struct {
+			DataSourceRef struct {
+				Kind string `json:"kind"`
+				Name string `json:"name"`
+			} `json:"dataSourceRef"`
+		} `json:"spec"`
+		Status struct {
+			Phase string `json:"phase"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+type auditPodList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string            `json:"name"`
+			Namespace string            `json:"namespace"`
+			Labels    map[string]string `json:"labels"`
+		} `json:"metadata"`
+		Spec struct {
+			Volumes []struct {
+				PersistentVolumeClaim struct {
+					ClaimName string `json:"claimName"`
+				} `json:"persistentVolumeClaim"`
+			} `json:"volumes"`
+		} `json:"spec"`
+		Status struct {
+			Phase string `json:"phase"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+type auditSecret struct {
+	Data       map[string]string `json:"data"`
+	StringData map[string]string `json:"stringData"`
+}
+
+// auditRepositoryKeys lists the Secret keys each mover needs to open its
+// repository.
+var auditRepositoryKeys = map[string][]string{
+	"kopia":  {"KOPIA_PASSWORD", "KOPIA_REPOSITORY"},
+	"restic": {"RESTIC_PASSWORD", "RESTIC_REPOSITORY"},
+}
+
+func buildVolsyncAuditReport(ctx context.Context, namespace string) (volsyncAuditReport, error) {
+	var report volsyncAuditReport
+	var sources auditSourceList
+	if err := kubeutil.GetJSON(ctx, verifyOutputFn, namespace, "replicationsources", &sources); err != nil {
+		return report, err
+	}
+	var pvcs auditPVCList
+	if err := kubeutil.GetJSON(ctx, verifyOutputFn, namespace, "pvc", &pvcs); err != nil {
+		return report, err
+	}
+	var pods auditPodList
+	if err := kubeutil.GetJSON(ctx, verifyOutputFn, namespace, "pods", &pods); err != nil {
+		return report, err
+	}
+	report.Sources = len(sources.Items)
+
+	phases := map[string]string{}
+	for _, pvc := range pvcs.Items {
+		phases[pvc.Metadata.Namespace+"/"+pvc.Metadata.Name] = pvc.Status.Phase
+	}
+	mounted := map[string]bool{}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != "Running" || isVolsyncMoverPod(pod.Metadata.Name, pod.Metadata.Labels) {
+			continue
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if claim := volume.PersistentVolumeClaim.ClaimName; claim != "" {
+				mounted[pod.Metadata.Namespace+"/"+claim] = true
+			}
+		}
+	}
+
+	secrets := map[string]*auditSecret{}
+	secretErrs := map[string]error{}
+	repositories := map[string][]string{}
+	backedUp := map[string]bool{}
+	for _, source := range sources.Items {
+		ns, name := source.Metadata.Namespace, source.Metadata.Name
+		object := "replicationsource/" + name
+		pvcKey := ns + "/" + source.Spec.SourcePVC
+		backedUp[pvcKey] = true
+
+		phase, exists := phases[pvcKey]
+		switch {
+		case source.Spec.SourcePVC == "" || !exists:
+			report.add(volsyncAuditFinding{
+				Type: auditMissingSourcePVC, Severity: volsyncFail, Namespace: ns, Object: object,
+				Detail: fmt.Sprintf("sourcePVC %q does not exist", source.Spec.SourcePVC),
+				Hint:   "point spec.sourcePVC at the renamed PVC, or restore the PVC before the next sync",
+			})
+		case phase != "Bound":
+			report.add(volsyncAuditFinding{
+				Type: auditSourceNotBound, Severity: volsyncFail, Namespace: ns, Object: object,
+				Detail: fmt.Sprintf("sourcePVC %q is %s, not Bound", source.Spec.SourcePVC, valueOr(phase, "Unknown")),
+				Hint:   fmt.Sprintf("kubectl describe pvc %s -n %s", source.Spec.SourcePVC, ns),
+			})
+		case !mounted[pvcKey]:
+			report.add(volsyncAuditFinding{
+				Type: auditIdleSourcePVC, Severity: volsyncWarn, Namespace: ns, Object: object,
+				Detail: fmt.Sprintf("no running pod mounts %q; backing up possibly-idle data", source.Spec.SourcePVC),
+				Hint:   "check that the app still uses this PVC, or that its workload is scaled up",
+			})
+		}
+
+		mover, repository := "", ""
+		identity := ""
+		if source.Spec.Kopia != nil {
+			mover, repository = "kopia", source.Spec.Kopia.Repository
+			identity = valueOr(source.Spec.Kopia.Username, name) + "@" + valueOr(source.Spec.Kopia.Hostname, ns)
+		} else if source.Spec.Restic != nil {
+			mover, repository = "restic", source.Spec.Restic.Repository
+		}
+		if mover == "" {
+			continue
+		}
+		if repository == "" {
+			report.add(volsyncAuditFinding{
+				Type: auditMissingSecret, Severity: volsyncFail, Namespace: ns, Object: object,
+				Detail: fmt.Sprintf("spec.%s.repository is empty", mover),
+				Hint:   fmt.Sprintf("set spec.%s.repository to the app's repository Secret", mover),
+			})
+			continue
+		}
+		secretKey := ns + "/" + repository
+		secret, fetched := secrets[secretKey]
+		if !fetched {
+			secret, secretErrs[secretKey] = fetchAuditSecret(ctx, ns, repository)
+			secrets[secretKey] = secret
+		}
+		if secret == nil {
+			detail := fmt.Sprintf("repository Secret %q does not exist", repository)
+			if err := secretErrs[secretKey]; err != nil && !strings.Contains(err.Error(), "NotFound") {
+				detail = fmt.Sprintf("repository Secret %q cannot be read: %v", repository, err)
+			}
+			report.add(volsyncAuditFinding{
+				Type: auditMissingSecret, Severity: volsyncFail, Namespace: ns, Object: object,
+				Detail: detail,
+				Hint:   fmt.Sprintf("check the ExternalSecret that renders %s in %s", repository, ns),
+			})
+			continue
+		}
+		values := secret.values()
+		var missing []string
+		for _, key := range auditRepositoryKeys[mover] {
+			if values[key] == "" {
+				missing = append(missing, key)
+			}
+		}
+		if len(missing) > 0 {
+			report.add(volsyncAuditFinding{
+				Type: auditSecretKeys, Severity: volsyncFail, Namespace: ns, Object: object,
+				Detail: fmt.Sprintf("repository Secret %q is missing %s", repository, strings.Join(missing, ", ")),
+				Hint:   fmt.Sprintf("add the missing keys to the ExternalSecret template for %s", repository),
+			})
+			continue
+		}
+		// Kopia sources share one repository by design and are separated by
+		// their username@hostname identity; restic has no such separation.
+		location := values[auditRepositoryKeys[mover][1]]
+		fingerprint := fmt.Sprintf("%s|%x|%s", mover, sha256.Sum256([]byte(location)), identity)
+		repositories[fingerprint] = append(repositories[fingerprint], ns+"/"+name)
+	}
+
+	for _, owners := range repositories {
+		if len(owners) < 2 {
+			continue
+		}
+		sort.Strings(owners)
+		for _, owner := range owners {
+			ns, name, _ := strings.Cut(owner, "/")
+			report.add(volsyncAuditFinding{
+				Type: auditSharedRepository, Severity: volsyncFail, Namespace: ns, Object: "replicationsource/" + name,
+				Detail: "shares its repository path with " + strings.Join(otherOwners(owners, owner), ", "),
+				Hint:   "give each ReplicationSource its own repository path or kopia username/hostname",
+			})
+		}
+	}
+
+	for _, pvc := range pvcs.Items {
+		key := pvc.Metadata.Namespace + "/" + pvc.Metadata.Name
+		if backedUp[key] || pvc.Spec.DataSourceRef.Kind != "ReplicationDestination" ||
+			kubeutil.IsVolSyncPlumbingPVC(pvc.Metadata.Name, pvc.Metadata.Labels, pvc.Metadata.OwnerReferences) ||
+			kubeutil.IsPodOwnedPVC(pvc.Metadata.OwnerReferences) {
+			continue
+		}
+		report.add(volsyncAuditFinding{
+			Type: auditUnprotectedRestore, Severity: volsyncFail, Namespace: pvc.Metadata.Namespace, Object: "pvc/" + pvc.Metadata.Name,
+			Detail: fmt.Sprintf("restores from ReplicationDestination %q but no ReplicationSource backs it up", pvc.Spec.DataSourceRef.Name),
+			Hint:   "re-add the volsync component for this app, or rename its ReplicationSource sourcePVC to match",
+		})
+	}
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Object != b.Object {
+			return a.Object < b.Object
+		}
+		return a.Type < b.Type
+	})
+	return report, nil
+}
+
+// fetchAuditSecret reads one repository Secret. Its values are only ever
+// reported as the presence of a key or compared by hash.
+func fetchAuditSecret(ctx context.Context, namespace, name string) (*auditSecret, error) {
+	var secret auditSecret
+	if err := kubeutil.GetJSONWithArgs(ctx, verifyOutputFn, "secret "+name, &secret,
+		"get", "secret", name, "--namespace", namespace, "-o", "json"); err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+func (s *auditSecret) values() map[string]string {
+	values := make(map[string]string, len(s.Data)+len(s.StringData))
+	for key, encoded := range s.Data {
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			decoded = []byte(encoded)
+		}
+		values[key] = string(decoded)
+	}
+	for key, value := range s.StringData {
+		values[key] = value
+	}
+	return values
+}
+
+// isVolsyncMoverPod skips the short-lived mover pods VolSync starts against
+// the source PVC; they do not prove the app is using it.
+func isVolsyncMoverPod(name string, labels map[string]string) bool {
+	return strings.EqualFold(labels["app.kubernetes.io/created-by"], "volsync") || strings.HasPrefix(name, "volsync-")
+}
+
+func otherOwners(owners []string, self string) []string {
+	others := make([]string, 0, len(owners)-1)
+	for _, owner := range owners {
+		if owner != self {
+			others = append(others, owner)
+		}
+	}
+	return others
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+func renderVolsyncAuditReport(report volsyncAuditReport, output string) (string, error) {
+	switch output {
+	case "", "table":
+		var b strings.Builder
+		fmt.Fprintf(&b, "Audited %d ReplicationSource(s): WARN=%d FAIL=%d\n", report.Sources, report.Warn, report.Fail)
+		if len(report.Findings) == 0 {
+			b.WriteString("No wiring problems found.")
+			return b.String(), nil
+		}
+		rows := make([][]string, 0, len(report.Findings))
+		for _, f := range report.Findings {
+			rows = append(rows, []string{string(f.Severity), f.Type, f.Namespace, f.Object, f.Detail, f.Hint})
+		}
+		b.WriteString(ui.Table([]string{"SEVERITY", "TYPE", "NAMESPACE", "OBJECT", "DETAIL", "HINT"}, rows))
+		return b.String(), nil
+	case "json":
+		if report.Findings == nil {
+			report.Findings = []volsyncAuditFinding{}
+		}
+		return ui.RenderJSON(report)
+	default:
+		return "", ui.ValidateOutputFormat(output)
+	}
+}
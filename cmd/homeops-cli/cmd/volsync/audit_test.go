@@ -0,0 +1,154 @@
+package volsync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/testutil"
+)
+
+// fakeAuditCluster answers list calls by kind and secret reads by name; an
+// unknown secret reads as NotFound.
+func fakeAuditCluster(t *testing.T, lists map[string]string, secrets map[string]string) {
+	t.Helper()
+	testutil.Swap(t, &verifyOutputFn, func(_ context.Context, args ...string) ([]byte, error) {
+		require.GreaterOrEqual(t, len(args), 2)
+		if args[1] == "secret" {
+			if body, ok := secrets[args[2]]; ok {
+				return []byte(body), nil
+			}
+			return nil, errors.New(`Error from server (NotFound): secrets "` + args[2] + `" not found`)
+		}
+		if body, ok := lists[args[1]]; ok {
+			return []byte(body), nil
+		}
+		return []byte(`{"items":[]}`), nil
+	})
+}
+
+const auditHealthySecret = `{"data":{"KOPIA_PASSWORD":"czNjcjN0","KOPIA_REPOSITORY":"ZmlsZXN5c3RlbTovLy9tbnQvcmVwb3NpdG9yeQ=="}}`
+
+func findingTypes(report volsyncAuditReport) map[string]string {
+	types := map[string]string{}
+	for _, finding := range report.Findings {
+		types[finding.Object] += finding.Type + ":" + string(finding.Severity) + " "
+	}
+	return types
+}
+
+func TestVolsyncAuditHealthyWiringHasNoFindings(t *testing.T) {
+	fakeAuditCluster(t, map[string]string{
+		"replicationsources": `{"items":[
+		  {"metadata":{"name":"radarr","namespace":"media"},"spec":{"sourcePVC":"radarr","kopia":{"repository":"radarr-volsync-secret"}}},
+		  {"metadata":{"name":"sonarr","namespace":"media"},"spec":{"sourcePVC":"sonarr","kopia":{"repository":"sonarr-volsync-secret"}}}
+		]}`,
+		"pvc": `{"items":[
+		  {"metadata":{"name":"radarr","namespace":"media"},"spec":{"dataSourceRef":{"kind":"ReplicationDestination","name":"radarr-dst"}},"status":{"phase":"Bound"}},
+		  {"metadata":{"name":"sonarr","namespace":"media"},"status":{"phase":"Bound"}},
+		  {"metadata":{"name":"volsync-radarr-dst-cache","namespace":"media"},"spec":{"dataSourceRef":{"kind":"ReplicationDestination","name":"radarr-dst"}},"status":{"phase":"Bound"}}
+		]}`,
+		"pods": `{"items":[
+		  {"metadata":{"name":"radarr-0","namespace":"media"},"spec":{"volumes":[{"persistentVolumeClaim":{"claimName":"radarr"}}]},"status":{"phase":"Running"}},
+		  {"metadata":{"name":"sonarr-0","namespace":"media"},"spec":{"volumes":[{"persistentVolumeClaim":{"claimName":"sonarr"}}]},"status":{"phase":"Running"}}
+		]}`,
+	}, map[string]string{"radarr-volsync-secret": auditHealthySecret, "sonarr-volsync-secret": auditHealthySecret})
+
+	report, err := buildVolsyncAuditReport(context.Background(), "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Sources)
+	assert.Empty(t, report.Findings, "kopia sources sharing one repository under distinct identities are expected")
+}
+
+func TestVolsyncAuditReportsEachFindingType(t *testing.T) {
+	fakeAuditCluster(t, map[string]string{
+		"replicationsources": `{"items":[
+		  {"metadata":{"name":"renamed","namespace":"media"},"spec":{"sourcePVC":"renamed-old","kopia":{"repository":"renamed-volsync-secret"}}},
+		  {"metadata":{"name":"pending","namespace":"media"},"spec":{"sourcePVC":"pending","kopia":{"repository":"pending-volsync-secret"}}},
+		  {"metadata":{"name":"idle","namespace":"media"},"spec":{"sourcePVC":"idle","kopia":{"repository":"idle-volsync-secret"}}},
+		  {"metadata":{"name":"nosecret","namespace":"media"},"spec":{"sourcePVC":"nosecret","kopia":{"repository":"nosecret-volsync-secret"}}},
+		  {"metadata":{"name":"partial","namespace":"media"},"spec":{"sourcePVC":"partial","restic":{"repository":"partial-restic"}}},
+		  {"metadata":{"name":"copy-a","namespace":"tools"},"spec":{"sourcePVC":"copy-a","kopia":{"repository":"shared","username":"copy","hostname":"tools"}}},
+		  {"metadata":{"name":"copy-b","namespace":"tools"},"spec":{"sourcePVC":"copy-b","kopia":{"repository":"shared","username":"copy","hostname":"tools"}}}
+		]}`,
+		"pvc": `{"items":[
+		  {"metadata":{"name":"renamed","namespace":"media"},"spec":{"dataSourceRef":{"kind":"ReplicationDestination","name":"renamed-dst"}},"status":{"phase":"Bound"}},
+		  {"metadata":{"name":"pending","namespace":"media"},"status":{"phase":"Pending"}},
+		  {"metadata":{"name":"idle","namespace":"media"},"status":{"phase":"Bound"}},
+		  {"metadata":{"name":"nosecret","namespace":"media"},"status":{"phase":"Bound"}},
+		  {"metadata":{"name":"partial","namespace":"media"},"status":{"phase":"Bound"}},
+		  {"metadata":{"name":"copy-a","namespace":"tools"},"status":{"phase":"Bound"}},
+		  {"metadata":{"name":"copy-b","namespace":"tools"},"status":{"phase":"Bound"}}
+		]}`,
+		"pods": `{"items":[
+		  {"metadata":{"name":"renamed-0","namespace":"media"},"spec":{"volumes":[{"persistentVolumeClaim":{"claimName":"renamed"}}]},"status":{"phase":"Running"}},
+		  {"metadata":{"name":"nosecret-0","namespace":"media"},"spec":{"volumes":[{"persistentVolumeClaim":{"claimName":"nosecret"}}]},"status":{"phase":"Running"}},
+		  {"metadata":{"name":"partial-0","namespace":"media"},"spec":{"volumes":[{"persistentVolumeClaim":{"claimName":"partial"}}]},"status":{"phase":"Running"}},
+		  {"metadata":{"name":"volsync-src-idle-x","namespace":"media","labels":{"app.kubernetes.io/created-by":"volsync"}},"spec":{"volumes":[{"persistentVolumeClaim":{"claimName":"idle"}}]},"status":{"phase":"Running"}},
+		  {"metadata":{"name":"copy-0","namespace":"tools"},"spec":{"volumes":[{"persistentVolumeClaim":{"claimName":"copy-a"}},{"persistentVolumeClaim":{"claimName":"copy-b"}}]},"status":{"phase":"Running"}}
+		]}`,
+	}, map[string]string{
+		"renamed-volsync-secret": auditHealthySecret,
+		"pending-volsync-secret": auditHealthySecret,
+		"idle-volsync-secret":    auditHealthySecret,
+		"partial-restic":         `{"data":{"RESTIC_REPOSITORY":"czM6Ly9iYWNrdXBzL3BhcnRpYWw="}}`,
+		"shared":                 auditHealthySecret,
+	})
+
+	report, err := buildVolsyncAuditReport(context.Background(), "")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"replicationsource/renamed":  "missing-source-pvc:FAIL ",
+		"replicationsource/pending":  "source-pvc-not-bound:FAIL ",
+		"replicationsource/idle":     "idle-source-pvc:WARN ",
+		"replicationsource/nosecret": "missing-repository-secret:FAIL ",
+		"replicationsource/partial":  "repository-secret-keys:FAIL ",
+		"replicationsource/copy-a":   "shared-repository:FAIL ",
+		"replicationsource/copy-b":   "shared-repository:FAIL ",
+		"pvc/renamed":                "no-replicationsource:FAIL ",
+	}, findingTypes(report))
+	assert.Equal(t, 1, report.Warn)
+	assert.Equal(t, 7, report.Fail)
+
+	for _, finding := range report.Findings {
+		assert.NotEmpty(t, finding.Hint, finding.Object)
+		assert.NotContains(t, finding.Detail, "filesystem:///mnt/repository", "repository values are never printed")
+		switch finding.Object {
+		case "replicationsource/partial":
+			assert.Contains(t, finding.Detail, "missing RESTIC_PASSWORD")
+		case "replicationsource/copy-a":
+			assert.Contains(t, finding.Detail, "tools/copy-b")
+		case "replicationsource/nosecret":
+			assert.Contains(t, finding.Detail, `"nosecret-volsync-secret" does not exist`)
+		}
+	}
+}
+
+func TestVolsyncAuditCommandJSONAndExitStatus(t *testing.T) {
+	fakeAuditCluster(t, map[string]string{
+		"replicationsources": `{"items":[{"metadata":{"name":"idle","namespace":"media"},"spec":{"sourcePVC":"idle","kopia":{"repository":"idle-volsync-secret"}}}]}`,
+		"pvc":                `{"items":[{"metadata":{"name":"idle","namespace":"media"},"status":{"phase":"Bound"}}]}`,
+	}, map[string]string{"idle-volsync-secret": auditHealthySecret})
+
+	out, err := testutil.ExecuteCommand(newAuditCommand(), "--output", "json")
+	require.NoError(t, err, "warnings alone do not fail the audit")
+	var report volsyncAuditReport
+	require.NoError(t, json.Unmarshal([]byte(out), &report))
+	require.Len(t, report.Findings, 1)
+	assert.Equal(t, auditIdleSourcePVC, report.Findings[0].Type)
+
+	fakeAuditCluster(t, map[string]string{
+		"replicationsources": `{"items":[{"metadata":{"name":"gone","namespace":"media"},"spec":{"sourcePVC":"gone"}}]}`,
+	}, nil)
+	out, err = testutil.ExecuteCommand(newAuditCommand(), "-n", "media")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 failing finding(s)")
+	assert.Contains(t, out, "missing-source-pvc")
+
+	_, err = testutil.ExecuteCommand(newAuditCommand(), "--output", "yaml")
+	require.Error(t, err)
+}
@@ -0,0 +1,390 @@
+package volsync
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"homeops-cli/cmd/completion"
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/config"
+	"homeops-cli/internal/ui"
+)
+
+const (
+	browseLabelKey    = "homeops.io/volsync-browse"
+	browseRunLabelKey = "homeops.io/volsync-browse-run"
+	browseMountPath   = "/data"
+)
+
+var (
+	browseStreamFn = func(ctx context.Context, stdout io.Writer, args ...string) error {
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "kubectl", args...)
+		cmd.Stdout = stdout
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		return redactCommandError(err, "", stderr.String(), ctx.Err())
+	}
+	browseShellFn = func(args ...string) error {
+		return common.RunInteractive(os.Stdin, os.Stdout, os.Stderr, "kubectl", args...)
+	}
+	browseInteractiveFn = ui.IsInteractive
+)
+
+type browseOptions struct {
+	Namespace string
+	App       string
+	Snapshot  string
+	Copy      string
+	CopyTo    string
+	Keep      bool
+	Timeout   time.Duration
+}
+
+type browseOperation struct {
+	namespace string
+	app       string
+	name      string
+	keep      bool
+	// pvcCreated gates the --keep reminder so a run that failed early does
+	// not point at a PVC that never existed.
+	pvcCreated bool
+	out        io.Writer
+	logger     *common.ColorLogger
+}
+
+func newBrowseCommand() *cobra.Command {
+	options := browseOptions{}
+	cmd := &cobra.Command{
+		Use:          "browse --app <name> [--copy <remote-path> [local-path]]",
+		Short:        "Restore a snapshot into a temporary PVC for file-level recovery",
+		SilenceUsage: true,
+		Long: `Restores a Kopia snapshot of the app into an ownerless scratch PVC (the same
+machinery as 'volsync verify'), mounts it read-only at /data in a short-lived
+pod, and opens an interactive shell there. The application PVC and
+ReplicationSource are never modified.
+
+--snapshot selects the snapshot: a number counts back from the latest (0, the
+default, is the latest; 1 the one before), and an RFC3339 timestamp restores
+the newest snapshot at or before that time.
+
+--copy <remote-path> [local-path] copies a file or directory out of the
+restored volume (remote paths are relative to /data; the local path defaults to
+the remote base name in the current directory) instead of opening a shell.
+
+The pod, ReplicationDestination, and scratch PVC are deleted when the command
+exits, fails, or is interrupted. --keep leaves the scratch PVC for manual
+inspection and prints the command to delete it.`,
+		Example: `  homeops-cli volsync browse --app paperless -n self-hosted
+  homeops-cli volsync browse --app paperless -n self-hosted --snapshot 3
+  homeops-cli volsync browse --app paperless -n self-hosted --snapshot 2026-10-01T00:00:00Z \
+    --copy media/documents/invoice.pdf ./invoice.pdf
+  homeops-cli volsync browse --app paperless -n self-hosted --keep --yes`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				if options.Copy == "" {
+					return fmt.Errorf("unexpected argument %q; a local path is only accepted after --copy <remote-path>", args[0])
+				}
+				options.CopyTo = args[0]
+			}
+			return runVolsyncBrowse(cmd.Context(), options, cmd.OutOrStdout())
+		},
+	}
+	cmd.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "Kubernetes namespace (prompts when omitted)")
+	cmd.Flags().StringVar(&options.App, "app", "", "application ReplicationSource name (required)")
+	cmd.Flags().StringVar(&options.Snapshot, "snapshot", "0", "snapshot to restore: count back from the latest, or an RFC3339 timestamp")
+	cmd.Flags().StringVar(&options.Copy, "copy", "", "copy <remote-path> (relative to /data) to the local path argument instead of opening a shell")
+	cmd.Flags().BoolVar(&options.Keep, "keep", false, "leave the scratch PVC in place for manual inspection")
+	cmd.Flags().DurationVar(&options.Timeout, "timeout", 15*time.Minute, "restore timeout")
+	_ = cmd.MarkFlagRequired("app")
+	_ = cmd.RegisterFlagCompletionFunc("namespace", completion.ValidNamespaces)
+	_ = cmd.RegisterFlagCompletionFunc("app", completion.ValidApplications)
+	return cmd
+}
+
+// parseBrowseSnapshot maps --snapshot onto the Kopia ReplicationDestination
+// selectors: an integer becomes spec.kopia.previous, a timestamp restoreAsOf.
+func parseBrowseSnapshot(value string) (int, string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, "", nil
+	}
+	if previous, err := strconv.Atoi(value); err == nil {
+		if previous < 0 {
+			return 0, "", fmt.Errorf("--snapshot must not be negative")
+		}
+		return previous, "", nil
+	}
+	if _, err := time.Parse(time.RFC3339, value); err != nil {
+		return 0, "", fmt.Errorf("--snapshot %q is neither a snapshot count nor an RFC3339 timestamp", value)
+	}
+	return 0, value, nil
+}
+
+func runVolsyncBrowse(ctx context.Context, options browseOptions, out io.Writer) error {
+	if options.Timeout <= 0 {
+		return fmt.Errorf("timeout must be greater than zero")
+	}
+	if options.Copy == "" && !options.Keep && !browseInteractiveFn() {
+		return fmt.Errorf("no terminal for an interactive shell; pass --copy <remote-path> [local-path] or --keep")
+	}
+	previous, restoreAsOf, err := parseBrowseSnapshot(options.Snapshot)
+	if err != nil {
+		return err
+	}
+	namespace, cancelled, err := promptForNamespace(options.Namespace)
+	if err != nil || cancelled {
+		return err
+	}
+	options.Namespace = namespace
+
+	restoreConfig, err := verifyBuildRestoreConfigFn(ctx, options.Namespace, options.App)
+	if err != nil {
+		return err
+	}
+	restoreConfig.Previous, restoreConfig.RestoreAsOf = previous, restoreAsOf
+
+	op := &browseOperation{
+		namespace: options.Namespace,
+		app:       options.App,
+		name:      browseObjectName(options.App, volsyncNow()),
+		keep:      options.Keep,
+		out:       out,
+		logger:    common.NewColorLogger(),
+	}
+	if confirmed, err := confirmActionFn(fmt.Sprintf("Restore snapshot %s of %s/%s into scratch PVC %s and mount it read-only in a temporary pod?",
+		describeBrowseSnapshot(options.Snapshot), options.Namespace, options.App, op.name), false); err != nil {
+		return fmt.Errorf("confirmation failed: %w", err)
+	} else if !confirmed {
+		return fmt.Errorf("browse cancelled")
+	}
+	defer op.cleanup()
+
+	if err := op.restore(ctx, restoreConfig, options.Timeout); err != nil {
+		return err
+	}
+	if err := op.startPod(ctx, config.Get().Volsync.CheckImage, options.Timeout); err != nil {
+		return err
+	}
+
+	if options.Copy != "" {
+		remote := browseRemotePath(options.Copy)
+		local := options.CopyTo
+		if local == "" {
+			local = path.Base(remote)
+		}
+		written, err := op.copyOut(ctx, remote, local)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(out, "Copied %s:%s to %s (%d file(s))\n", op.name, remote, local, written)
+		return nil
+	}
+	if !browseInteractiveFn() {
+		// Only reachable with --keep: the kept PVC is the deliverable.
+		return nil
+	}
+	op.printInstructions()
+	if err := browseShellFn("exec", "-it", op.name, "--namespace", op.namespace, "--", "sh", "-c", "cd "+browseMountPath+" && exec sh"); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("browse shell: %w", err)
+	}
+	return nil
+}
+
+func describeBrowseSnapshot(value string) string {
+	if value == "" || value == "0" {
+		return "latest"
+	}
+	return value
+}
+
+func browseObjectName(app string, now time.Time) string {
+	return scratchObjectPrefix("browse", app) + strconv.FormatInt(now.UnixNano(), 10)
+}
+
+func (op *browseOperation) labels() map[string]string {
+	return map[string]string{browseLabelKey: op.app, browseRunLabelKey: op.name}
+}
+
+func (op *browseOperation) restore(ctx context.Context, restoreConfig verifyRestoreConfig, timeout time.Duration) error {
+	pvcSpec, err := scratchPVCSpec(restoreConfig.PVC)
+	if err != nil {
+		return err
+	}
+	pvcYAML, err := marshalScratchObject("v1", "PersistentVolumeClaim", op.name, op.namespace, op.labels(), pvcSpec)
+	if err != nil {
+		return err
+	}
+	if _, err := verifyApplyYAMLFn(ctx, pvcYAML); err != nil {
+		return fmt.Errorf("create scratch PVC %s: %w", op.name, err)
+	}
+	op.pvcCreated = true
+
+	trigger := fmt.Sprintf("browse-%d", volsyncNow().UnixNano())
+	destinationSpec, err := scratchDestinationSpec(op.name, op.app, trigger, restoreConfig)
+	if err != nil {
+		return err
+	}
+	destinationYAML, err := marshalScratchObject("volsync.backube/v1alpha1", "ReplicationDestination", op.name, op.namespace, op.labels(), destinationSpec)
+	if err != nil {
+		return err
+	}
+	if _, err := verifyApplyYAMLFn(ctx, destinationYAML); err != nil {
+		return fmt.Errorf("create browse ReplicationDestination %s: %w", op.name, err)
+	}
+	op.logger.Info("Restoring %s/%s into scratch PVC %s...", op.namespace, op.app, op.name)
+	if _, err := waitForVerifyDestination(ctx, op.namespace, op.name, trigger, timeout); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (op *browseOperation) startPod(ctx context.Context, image string, timeout time.Duration) error {
+	podYAML, err := marshalScratchObject("v1", "Pod", op.name, op.namespace, op.labels(),
+		readOnlyScratchPodSpec(op.name, "browse", image, "sleep 86400"))
+	if err != nil {
+		return err
+	}
+	if _, err := verifyApplyYAMLFn(ctx, podYAML); err != nil {
+		return fmt.Errorf("create browse pod: %w", err)
+	}
+	if err := verifyRunFn(ctx, "wait", "pod/"+op.name, "--namespace", op.namespace, "--for=condition=Ready", "--timeout="+timeout.String()); err != nil {
+		return fmt.Errorf("wait for browse pod: %w", err)
+	}
+	return nil
+}
+
+// browseRemotePath resolves a user path to an absolute path under the mount;
+// both "docs/a.pdf" and "/data/docs/a.pdf" name the same file, and ".."
+// cannot climb out of the mount.
+func browseRemotePath(remotePath string) string {
+	remotePath = strings.TrimSpace(remotePath)
+	if remotePath == browseMountPath || strings.HasPrefix(remotePath, browseMountPath+"/") {
+		remotePath = strings.TrimPrefix(remotePath, browseMountPath)
+	}
+	return path.Join(browseMountPath, path.Clean("/"+remotePath))
+}
+
+// copyOut streams remotePath out of the browse pod as a tar archive over
+// `kubectl exec`, the same transport `kubectl cp` uses, and extracts it at
+// localPath. It returns the number of regular files written.
+func (op *browseOperation) copyOut(ctx context.Context, remotePath, localPath string) (int, error) {
+	dir, base := path.Split(remotePath)
+
+	reader, writer := io.Pipe()
+	streamErr := make(chan error, 1)
+	go func() {
+		err := browseStreamFn(ctx, writer, "exec", op.name, "--namespace", op.namespace, "--",
+			"tar", "cf", "-", "-C", dir, base)
+		_ = writer.CloseWithError(err)
+		streamErr <- err
+	}()
+	written, extractErr := extractBrowseTar(reader, base, localPath)
+	_ = reader.Close()
+	if err := <-streamErr; err != nil {
+		return written, fmt.Errorf("read %s from browse pod: %w", remotePath, err)
+	}
+	if extractErr != nil {
+		return written, extractErr
+	}
+	if written == 0 {
+		return 0, fmt.Errorf("%s contained no regular files", remotePath)
+	}
+	return written, nil
+}
+
+// extractBrowseTar writes the archive rooted at base to localPath: base
+// itself maps to localPath, and nothing may escape it. Links and special files
+// are skipped; restored data is never trusted to point elsewhere.
+func extractBrowseTar(r io.Reader, base, localPath string) (int, error) {
+	archive := tar.NewReader(r)
+	written := 0
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			return written, nil
+		}
+		if err != nil {
+			return written, fmt.Errorf("read tar stream: %w", err)
+		}
+		name := path.Clean(header.Name)
+		var rel string
+		switch {
+		case base == ".":
+			rel = name
+		case name == base:
+			rel = "."
+		case strings.HasPrefix(name, base+"/"):
+			rel = strings.TrimPrefix(name, base+"/")
+		default:
+			return written, fmt.Errorf("unexpected tar entry %q outside %q", header.Name, base)
+		}
+		if rel == ".." || strings.HasPrefix(rel, "../") || path.IsAbs(rel) {
+			return written, fmt.Errorf("refusing tar entry %q that escapes the destination", header.Name)
+		}
+		target := filepath.Join(localPath, filepath.FromSlash(rel))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o750); err != nil {
+				return written, err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o750); err != nil {
+				return written, err
+			}
+			// #nosec G304 -- target is confined to the operator-supplied local path above
+			file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode&0o666)|0o600)
+			if err != nil {
+				return written, err
+			}
+			// #nosec G110 -- size is bounded by the restored PVC the operator chose to copy
+			if _, err := io.Copy(file, archive); err != nil {
+				_ = file.Close()
+				return written, fmt.Errorf("write %s: %w", target, err)
+			}
+			if err := file.Close(); err != nil {
+				return written, err
+			}
+			written++
+		}
+	}
+}
+
+func (op *browseOperation) printInstructions() {
+	_, _ = fmt.Fprintf(op.out, `Snapshot restored to scratch PVC %[1]s, mounted read-only at %[3]s in pod %[1]s.
+Copy files out from another terminal while this session is open:
+  kubectl cp %[2]s/%[1]s:%[3]s/<path> ./<path>
+or re-run with --copy <remote-path> [local-path].
+`, op.name, op.namespace, browseMountPath)
+}
+
+func (op *browseOperation) cleanup() {
+	ctx, cancel := context.WithTimeout(context.Background(), verifyCleanupLimit)
+	defer cancel()
+	resources := []string{"pod", "replicationdestination", "pvc"}
+	if op.keep {
+		resources = resources[:2]
+	}
+	for _, resource := range resources {
+		if err := verifyRunFn(ctx, "delete", resource, op.name, "--namespace", op.namespace, "--ignore-not-found", "--wait=true"); err != nil {
+			op.logger.Warn("BROWSE CLEANUP FAILED: could not delete %s/%s in %s: %v", resource, op.name, op.namespace, err)
+		}
+	}
+	if op.keep && op.pvcCreated {
+		op.logger.Warn("Scratch PVC %s/%s was kept; delete it when done: kubectl delete pvc %s --namespace %s", op.namespace, op.name, op.name, op.namespace)
+	}
+}
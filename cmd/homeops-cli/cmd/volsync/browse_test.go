@@ -0,0 +1,256 @@
+package volsync
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homeops-cli/internal/testutil"
+)
+
+func browseTestTar(t *testing.T, entries map[string]string, dirs ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := tar.NewWriter(&buf)
+	for _, dir := range dirs {
+		require.NoError(t, writer.WriteHeader(&tar.Header{Name: dir + "/", Typeflag: tar.TypeDir, Mode: 0o755}))
+	}
+	for name, content := range entries {
+		require.NoError(t, writer.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(content))}))
+		_, err := writer.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+func TestParseBrowseSnapshot(t *testing.T) {
+	tests := []struct {
+		value       string
+		previous    int
+		restoreAsOf string
+		wantErr     string
+	}{
+		{value: "", previous: 0},
+		{value: "0", previous: 0},
+		{value: "3", previous: 3},
+		{value: "2026-10-01T00:00:00Z", restoreAsOf: "2026-10-01T00:00:00Z"},
+		{value: "-1", wantErr: "must not be negative"},
+		{value: "k7a1f2", wantErr: "neither a snapshot count nor an RFC3339 timestamp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			previous, restoreAsOf, err := parseBrowseSnapshot(tt.value)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.previous, previous)
+			assert.Equal(t, tt.restoreAsOf, restoreAsOf)
+		})
+	}
+}
+
+func TestBrowseRemotePathStaysUnderMount(t *testing.T) {
+	assert.Equal(t, "/data/docs/a.pdf", browseRemotePath("docs/a.pdf"))
+	assert.Equal(t, "/data/docs/a.pdf", browseRemotePath("/data/docs/a.pdf"))
+	assert.Equal(t, "/data/database", browseRemotePath("/database"))
+	assert.Equal(t, "/data/etc/passwd", browseRemotePath("../../etc/passwd"))
+	assert.Equal(t, "/data", browseRemotePath("/"))
+}
+
+func TestScratchDestinationSpecSelectsOlderSnapshot(t *testing.T) {
+	config := verifyTestConfig()
+	config.Previous = 2
+	spec, err := scratchDestinationSpec("scratch", "paperless", "trig", config)
+	require.NoError(t, err)
+	kopia := spec["kopia"].(map[string]any)
+	assert.Equal(t, 2, kopia["previous"])
+	assert.NotContains(t, kopia, "restoreAsOf")
+
+	config.Previous, config.RestoreAsOf = 0, "2026-10-01T00:00:00Z"
+	spec, err = scratchDestinationSpec("scratch", "paperless", "trig", config)
+	require.NoError(t, err)
+	assert.Equal(t, "2026-10-01T00:00:00Z", spec["kopia"].(map[string]any)["restoreAsOf"])
+}
+
+func TestExtractBrowseTarSingleFileAndDirectory(t *testing.T) {
+	dir := t.TempDir()
+
+	written, err := extractBrowseTar(bytes.NewReader(browseTestTar(t, map[string]string{"invoice.pdf": "pdf"})), "invoice.pdf", filepath.Join(dir, "copy.pdf"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, written)
+	content, err := os.ReadFile(filepath.Join(dir, "copy.pdf"))
+	require.NoError(t, err)
+	assert.Equal(t, "pdf", string(content))
+
+	archive := browseTestTar(t, map[string]string{"docs/a.txt": "a", "docs/sub/b.txt": "b"}, "docs", "docs/sub")
+	written, err = extractBrowseTar(bytes.NewReader(archive), "docs", filepath.Join(dir, "out"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, written)
+	content, err = os.ReadFile(filepath.Join(dir, "out", "sub", "b.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "b", string(content))
+}
+
+func TestExtractBrowseTarRejectsEscapes(t *testing.T) {
+	dir := t.TempDir()
+	_, err := extractBrowseTar(bytes.NewReader(browseTestTar(t, map[string]string{"docs/../../evil": "x"})), "docs", filepath.Join(dir, "out"))
+	require.Error(t, err)
+	_, err = os.Stat(filepath.Join(dir, "evil"))
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = extractBrowseTar(bytes.NewReader(browseTestTar(t, map[string]string{"other/file": "x"})), "docs", filepath.Join(dir, "out"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "outside")
+}
+
+type browseFakeCluster struct {
+	applied  []string
+	runCalls []string
+	shell    []string
+	stream   []string
+}
+
+func stubBrowseCluster(t *testing.T, restoreResult string) *browseFakeCluster {
+	t.Helper()
+	fake := &browseFakeCluster{}
+	testutil.Swap(t, &volsyncNow, func() time.Time { return time.Unix(1721000000, 0) })
+	testutil.Swap(t, &verifyBuildRestoreConfigFn, func(context.Context, string, string) (verifyRestoreConfig, error) {
+		return verifyTestConfig(), nil
+	})
+	testutil.Swap(t, &confirmActionFn, func(string, bool) (bool, error) { return true, nil })
+	testutil.Swap(t, &verifyApplyYAMLFn, func(_ context.Context, manifest string) ([]byte, error) {
+		fake.applied = append(fake.applied, manifest)
+		return nil, nil
+	})
+	testutil.Swap(t, &verifyOutputFn, func(_ context.Context, args ...string) ([]byte, error) {
+		if args[1] == "jobs,pods" {
+			return []byte(`{"items":[]}`), nil
+		}
+		trigger := "browse-" + "1721000000000000000"
+		return []byte(`{"status":{"lastManualSync":"` + trigger + `","latestMoverStatus":{"result":"` + restoreResult + `","logs":"restore failed"}}}`), nil
+	})
+	testutil.Swap(t, &verifyRunFn, func(_ context.Context, args ...string) error {
+		fake.runCalls = append(fake.runCalls, strings.Join(args[:2], " "))
+		return nil
+	})
+	testutil.Swap(t, &browseShellFn, func(args ...string) error {
+		fake.shell = args
+		return nil
+	})
+	testutil.Swap(t, &browseStreamFn, func(_ context.Context, w io.Writer, args ...string) error {
+		fake.stream = args
+		_, err := w.Write(browseTestTar(t, map[string]string{"invoice.pdf": "pdf"}))
+		return err
+	})
+	return fake
+}
+
+func TestRunVolsyncBrowseShellLifecycle(t *testing.T) {
+	fake := stubBrowseCluster(t, "Successful")
+	testutil.Swap(t, &browseInteractiveFn, func() bool { return true })
+	var out bytes.Buffer
+
+	err := runVolsyncBrowse(context.Background(), browseOptions{Namespace: "self-hosted", App: "paperless", Snapshot: "1", Timeout: time.Minute}, &out)
+	require.NoError(t, err)
+
+	require.Len(t, fake.applied, 3)
+	assert.Contains(t, fake.applied[0], "kind: PersistentVolumeClaim")
+	assert.Contains(t, fake.applied[1], "previous: 1")
+	assert.Contains(t, fake.applied[2], "readOnly: true")
+	for _, manifest := range fake.applied {
+		assert.Contains(t, manifest, browseLabelKey+": paperless")
+		assert.NotContains(t, manifest, verifyLabelKey+":")
+	}
+	assert.Equal(t, []string{"exec", "-it", "volsync-browse-paperless-1721000000000000000", "--namespace", "self-hosted", "--", "sh", "-c", "cd /data && exec sh"}, fake.shell)
+	assert.Equal(t, []string{"wait pod/volsync-browse-paperless-1721000000000000000", "delete pod", "delete replicationdestination", "delete pvc"}, fake.runCalls)
+	assert.Contains(t, out.String(), "kubectl cp self-hosted/volsync-browse-paperless-1721000000000000000:/data/<path>")
+}
+
+func TestRunVolsyncBrowseCopyOut(t *testing.T) {
+	fake := stubBrowseCluster(t, "Successful")
+	testutil.Swap(t, &browseInteractiveFn, func() bool { return false })
+	local := filepath.Join(t.TempDir(), "invoice.pdf")
+	var out bytes.Buffer
+
+	err := runVolsyncBrowse(context.Background(), browseOptions{Namespace: "self-hosted", App: "paperless", Copy: "docs/invoice.pdf", CopyTo: local, Timeout: time.Minute}, &out)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"exec", "volsync-browse-paperless-1721000000000000000", "--namespace", "self-hosted", "--", "tar", "cf", "-", "-C", "/data/docs/", "invoice.pdf"}, fake.stream)
+	content, err := os.ReadFile(local)
+	require.NoError(t, err)
+	assert.Equal(t, "pdf", string(content))
+	assert.Contains(t, out.String(), "Copied")
+	assert.Nil(t, fake.shell)
+	assert.Contains(t, fake.runCalls, "delete pvc")
+}
+
+func TestRunVolsyncBrowseKeepLeavesPVC(t *testing.T) {
+	fake := stubBrowseCluster(t, "Successful")
+	testutil.Swap(t, &browseInteractiveFn, func() bool { return false })
+
+	err := runVolsyncBrowse(context.Background(), browseOptions{Namespace: "self-hosted", App: "paperless", Keep: true, Timeout: time.Minute}, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"wait pod/volsync-browse-paperless-1721000000000000000", "delete pod", "delete replicationdestination"}, fake.runCalls)
+}
+
+func TestRunVolsyncBrowseCleansUpAfterRestoreFailure(t *testing.T) {
+	fake := stubBrowseCluster(t, "Failed")
+	testutil.Swap(t, &browseInteractiveFn, func() bool { return true })
+
+	err := runVolsyncBrowse(context.Background(), browseOptions{Namespace: "self-hosted", App: "paperless", Timeout: time.Minute}, io.Discard)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "restore failed")
+	assert.Len(t, fake.applied, 2, "no pod is created after a failed restore")
+	assert.Equal(t, []string{"delete pod", "delete replicationdestination", "delete pvc"}, fake.runCalls)
+}
+
+func TestRunVolsyncBrowseCleansUpWhenInterrupted(t *testing.T) {
+	fake := stubBrowseCluster(t, "Successful")
+	testutil.Swap(t, &browseInteractiveFn, func() bool { return true })
+	ctx, cancel := context.WithCancel(context.Background())
+	// Simulate Ctrl+C while the restore is pending: the root command cancels
+	// its context on SIGINT.
+	testutil.Swap(t, &verifyOutputFn, func(context.Context, ...string) ([]byte, error) {
+		cancel()
+		return nil, errors.New("signal: interrupt")
+	})
+
+	err := runVolsyncBrowse(ctx, browseOptions{Namespace: "self-hosted", App: "paperless", Timeout: time.Minute}, io.Discard)
+	require.Error(t, err)
+	assert.Equal(t, []string{"delete pod", "delete replicationdestination", "delete pvc"}, fake.runCalls)
+	assert.Nil(t, fake.shell)
+}
+
+func TestRunVolsyncBrowseRequiresTerminalOrCopy(t *testing.T) {
+	testutil.Swap(t, &browseInteractiveFn, func() bool { return false })
+	testutil.Swap(t, &verifyBuildRestoreConfigFn, func(context.Context, string, string) (verifyRestoreConfig, error) {
+		t.Fatal("nothing may be restored without a way to use it")
+		return verifyRestoreConfig{}, nil
+	})
+
+	err := runVolsyncBrowse(context.Background(), browseOptions{Namespace: "self-hosted", App: "paperless", Timeout: time.Minute}, io.Discard)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--copy")
+}
+
+func TestBrowseCommandRejectsLocalPathWithoutCopy(t *testing.T) {
+	cmd := newBrowseCommand()
+	cmd.SetArgs([]string{"--app", "paperless", "-n", "self-hosted", "./out"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	err := cmd.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "only accepted after --copy")
+}
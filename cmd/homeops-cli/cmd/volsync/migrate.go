@@ -743,6 +743,8 @@ func remainingMigrationTimeout(ctx context.Context) string {
 }
 
 func waitForMigratedPVC(ctx context.Context, namespace, pvc, targetClass, app string, logger *common.ColorLogger) (migrationPVC, error) {
+	stopProgress := startMoverProgressFn(namespace, app+"-dst", "Restoring "+app)
+	defer stopProgress()
 	lastProgress := time.Time{}
 	for {
 		var current migrationPVC
@@ -0,0 +1,486 @@
+package volsync
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+const (
+	moverProgressPollInterval = 5 * time.Second
+	moverProgressHeartbeat    = 30 * time.Second
+	moverProgressTick         = time.Second
+)
+
+var (
+	moverPodsOutputFn = func(ctx context.Context, namespace string) ([]byte, error) {
+		return commandOutputCtxFn(ctx, "kubectl", "get", "pods", "--namespace", namespace, "-o", "json")
+	}
+	streamMoverLogsFn              = streamMoverLogs
+	moverProgressSleepFn           = sleepVerifyContext
+	moverProgressOutput  io.Writer = os.Stderr
+	moverProgressIsTTY             = func() bool { return isatty.IsTerminal(os.Stderr.Fd()) }
+	startMoverProgressFn           = startMoverProgress
+)
+
+// moverProgress is one parsed progress line from a restic or kopia mover.
+// Zero fields were not reported by that format.
+type moverProgress struct {
+	Percent    float64
+	BytesDone  int64
+	BytesTotal int64
+	FilesDone  int64
+	FilesTotal int64
+	Elapsed    time.Duration
+}
+
+var (
+	// restic >= 0.14 text status, restore ("files/dirs", "N errors") and
+	// backup ("files ... ETA") flavours:
+	//   [0:05] 12.34%  120 files/dirs 1.234 GiB, total 5000 files/dirs 10.000 GiB
+	//   [1:02:03] 45.00%  120 files 1.234 GiB, total 5000 files 10.000 GiB, 0 errors ETA 0:35
+	resticStatusPattern = regexp.MustCompile(`^\[(?:(\d+):)?(\d+):(\d+)\]\s+([\d.]+)%\s+(\d+)\s+files(?:/dirs)?\s+([\d.]+\s*[KMGTPkmgtp]?i?B),\s+total\s+(\d+)\s+files(?:/dirs)?\s+([\d.]+\s*[KMGTPkmgtp]?i?B)`)
+	// kopia restore: Processed 6 (8.6 MB) of 29 (75.8 MB) 8.6 MB/s (11.4%) remaining 7s.
+	kopiaProcessedPattern = regexp.MustCompile(`Processed\s+(\d+)\s+\(([\d.]+\s*[KMGTPkmgtp]?i?B)\)\s+of\s+(\d+)\s+\(([\d.]+\s*[KMGTPkmgtp]?i?B)\).*?\(([\d.]+)%\)`)
+	moverSizePattern      = regexp.MustCompile(`^([\d.]+)\s*([KMGTPkmgtp]?)(i?)B$`)
+)
+
+// resticJSONStatus covers `restic --json` status messages from both restore
+// (files_restored/bytes_restored) and backup (files_done/bytes_done).
+type resticJSONStatus struct {
+	MessageType    string  `json:"message_type"`
+	SecondsElapsed float64 `json:"seconds_elapsed"`
+	PercentDone    float64 `json:"percent_done"`
+	TotalFiles     int64   `json:"total_files"`
+	FilesDone      int64   `json:"files_done"`
+	FilesRestored  int64   `json:"files_restored"`
+	TotalBytes     int64   `json:"total_bytes"`
+	BytesDone      int64   `json:"bytes_done"`
+	BytesRestored  int64   `json:"bytes_restored"`
+}
+
+// parseMoverProgress extracts progress from one mover log line. It reports
+// false for anything that is not a progress line.
+func parseMoverProgress(line string) (moverProgress, bool) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "{") {
+		var status resticJSONStatus
+		if err := json.Unmarshal([]byte(line), &status); err != nil || status.MessageType != "status" {
+			return moverProgress{}, false
+		}
+		return moverProgress{
+			Percent:    status.PercentDone * 100,
+			BytesDone:  max(status.BytesDone, status.BytesRestored),
+			BytesTotal: status.TotalBytes,
+			FilesDone:  max(status.FilesDone, status.FilesRestored),
+			FilesTotal: status.TotalFiles,
+			Elapsed:    time.Duration(status.SecondsElapsed * float64(time.Second)),
+		}, true
+	}
+	if match := resticStatusPattern.FindStringSubmatch(line); match != nil {
+		hours, _ := strconv.Atoi(match[1])
+		minutes, _ := strconv.Atoi(match[2])
+		seconds, _ := strconv.Atoi(match[3])
+		percent, err := strconv.ParseFloat(match[4], 64)
+		if err != nil {
+			return moverProgress{}, false
+		}
+		progress := moverProgress{
+			Percent: percent,
+			Elapsed: time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second,
+		}
+		progress.FilesDone, _ = strconv.ParseInt(match[5], 10, 64)
+		progress.FilesTotal, _ = strconv.ParseInt(match[7], 10, 64)
+		progress.BytesDone, _ = parseMoverSize(match[6])
+		progress.BytesTotal, _ = parseMoverSize(match[8])
+		return progress, true
+	}
+	if match := kopiaProcessedPattern.FindStringSubmatch(line); match != nil {
+		percent, err := strconv.ParseFloat(match[5], 64)
+		if err != nil {
+			return moverProgress{}, false
+		}
+		progress := moverProgress{Percent: percent}
+		progress.FilesDone, _ = strconv.ParseInt(match[1], 10, 64)
+		progress.FilesTotal, _ = strconv.ParseInt(match[3], 10, 64)
+		progress.BytesDone, _ = parseMoverSize(match[2])
+		progress.BytesTotal, _ = parseMoverSize(match[4])
+		return progress, true
+	}
+	return moverProgress{}, false
+}
+
+// parseMoverSize reads restic's binary ("1.234 GiB") and kopia's decimal
+// ("8.6 MB") sizes.
+func parseMoverSize(value string) (int64, bool) {
+	match := moverSizePattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return 0, false
+	}
+	number, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	base := 1000.0
+	if match[3] == "i" {
+		base = 1024
+	}
+	exponent := 0
+	if match[2] != "" {
+		exponent = strings.Index("KMGTP", strings.ToUpper(match[2])) + 1
+	}
+	for range exponent {
+		number *= base
+	}
+	return int64(number), true
+}
+
+func formatMoverBytes(size int64) string {
+	const unit = int64(1024)
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size)
+	units := []string{"KiB", "MiB", "GiB", "TiB"}
+	for _, suffix := range units {
+		value /= float64(unit)
+		if value < float64(unit) || suffix == units[len(units)-1] {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+	}
+	return fmt.Sprintf("%d B", size)
+}
+
+// moverProgressTracker turns parsed samples into a display line. The ETA comes
+// from the byte rate between the first and latest sample, falling back to the
+// mover's own elapsed time and percentage.
+type moverProgressTracker struct {
+	label        string
+	interval     time.Duration
+	lastActivity time.Time
+	lastRendered time.Time
+	first, last  moverProgress
+	firstAt      time.Time
+	lastAt       time.Time
+	samples      int
+}
+
+func (tracker *moverProgressTracker) reset(now time.Time) {
+	*tracker = moverProgressTracker{label: tracker.label, interval: tracker.interval, lastActivity: now, lastRendered: now}
+}
+
+// observe records any log line as activity and returns the progress line to
+// show when it parsed.
+func (tracker *moverProgressTracker) observe(line string, now time.Time) (string, bool) {
+	if strings.TrimSpace(line) == "" {
+		return "", false
+	}
+	tracker.lastActivity = now
+	progress, ok := parseMoverProgress(line)
+	if !ok {
+		return "", false
+	}
+	if tracker.samples == 0 {
+		tracker.first, tracker.firstAt = progress, now
+	}
+	tracker.last, tracker.lastAt = progress, now
+	tracker.samples++
+	tracker.lastRendered = now
+	return tracker.render(), true
+}
+
+// heartbeat returns a "still syncing" line once nothing has been rendered for
+// the heartbeat interval.
+func (tracker *moverProgressTracker) heartbeat(now time.Time) (string, bool) {
+	if now.Sub(tracker.lastRendered) < tracker.interval {
+		return "", false
+	}
+	tracker.lastRendered = now
+	return fmt.Sprintf("%s: still syncing, last activity %s ago", tracker.label, now.Sub(tracker.lastActivity).Round(time.Second)), true
+}
+
+func (tracker *moverProgressTracker) rate() float64 {
+	window := tracker.lastAt.Sub(tracker.firstAt).Seconds()
+	if tracker.samples < 2 || window <= 0 || tracker.last.BytesDone <= tracker.first.BytesDone {
+		return 0
+	}
+	return float64(tracker.last.BytesDone-tracker.first.BytesDone) / window
+}
+
+func (tracker *moverProgressTracker) eta() (time.Duration, bool) {
+	last := tracker.last
+	if rate := tracker.rate(); rate > 0 && last.BytesTotal > last.BytesDone {
+		return time.Duration(float64(last.BytesTotal-last.BytesDone) / rate * float64(time.Second)), true
+	}
+	if last.Percent > 0 && last.Percent < 100 && last.Elapsed > 0 {
+		return time.Duration(float64(last.Elapsed) * (100 - last.Percent) / last.Percent), true
+	}
+	return 0, false
+}
+
+func (tracker *moverProgressTracker) render() string {
+	last := tracker.last
+	percent := last.Percent
+	if percent == 0 && last.BytesTotal > 0 {
+		percent = float64(last.BytesDone) / float64(last.BytesTotal) * 100
+	}
+	parts := []string{fmt.Sprintf("%s: %.1f%%", tracker.label, percent)}
+	var detail []string
+	if last.BytesTotal > 0 {
+		detail = append(detail, fmt.Sprintf("%s of %s", formatMoverBytes(last.BytesDone), formatMoverBytes(last.BytesTotal)))
+	}
+	if last.FilesTotal > 0 {
+		detail = append(detail, fmt.Sprintf("%d/%d files", last.FilesDone, last.FilesTotal))
+	}
+	if len(detail) > 0 {
+		parts = append(parts, "("+strings.Join(detail, ", ")+")")
+	}
+	if rate := tracker.rate(); rate > 0 {
+		parts = append(parts, formatMoverBytes(int64(rate))+"/s")
+	}
+	if eta, ok := tracker.eta(); ok {
+		parts = append(parts, "ETA "+eta.Round(time.Second).String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// moverProgressPrinter rewrites a single line in place on a terminal and
+// otherwise prints at most one line per heartbeat interval so CI logs stay
+// readable.
+type moverProgressPrinter struct {
+	mu        sync.Mutex
+	out       io.Writer
+	tty       bool
+	interval  time.Duration
+	lastPrint time.Time
+	dirty     bool
+}
+
+func (printer *moverProgressPrinter) update(line string, now time.Time) {
+	printer.mu.Lock()
+	defer printer.mu.Unlock()
+	if printer.tty {
+		_, _ = fmt.Fprintf(printer.out, "\r\033[K%s", line)
+		printer.dirty = true
+		return
+	}
+	if !printer.lastPrint.IsZero() && now.Sub(printer.lastPrint) < printer.interval {
+		return
+	}
+	printer.lastPrint = now
+	_, _ = fmt.Fprintln(printer.out, line)
+}
+
+func (printer *moverProgressPrinter) notice(message string) {
+	printer.mu.Lock()
+	defer printer.mu.Unlock()
+	printer.clear()
+	_, _ = fmt.Fprintln(printer.out, message)
+}
+
+func (printer *moverProgressPrinter) finish() {
+	printer.mu.Lock()
+	defer printer.mu.Unlock()
+	printer.clear()
+}
+
+func (printer *moverProgressPrinter) clear() {
+	if printer.dirty {
+		_, _ = fmt.Fprint(printer.out, "\r\033[K")
+		printer.dirty = false
+	}
+}
+
+// startMoverProgress follows the mover pod of a ReplicationDestination in the
+// background until the returned stop function is called.
+func startMoverProgress(namespace, destination, label string) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	follower := &moverProgressFollower{
+		namespace:   namespace,
+		destination: destination,
+		printer:     &moverProgressPrinter{out: moverProgressOutput, tty: moverProgressIsTTY(), interval: moverProgressHeartbeat},
+		tracker:     moverProgressTracker{label: label, interval: moverProgressHeartbeat},
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		follower.run(ctx)
+	}()
+	return func() {
+		cancel()
+		<-done
+		follower.printer.finish()
+	}
+}
+
+type moverProgressFollower struct {
+	namespace   string
+	destination string
+	printer     *moverProgressPrinter
+	tracker     moverProgressTracker
+}
+
+// run attaches to the newest running mover pod, streams its logs until the
+// stream ends, and re-attaches when the pod restarts or is replaced.
+func (follower *moverProgressFollower) run(ctx context.Context) {
+	follower.tracker.reset(volsyncNow())
+	attached := ""
+	for ctx.Err() == nil {
+		pod, err := findMoverPod(ctx, follower.namespace, follower.destination)
+		if err == nil && pod != "" {
+			switch {
+			case attached == pod:
+				follower.printer.notice(fmt.Sprintf("Mover pod %s log stream ended; re-attaching", pod))
+			case attached != "":
+				follower.printer.notice(fmt.Sprintf("Mover pod %s replaced by %s; re-attaching", attached, pod))
+			}
+			if attached != "" {
+				follower.tracker.reset(volsyncNow())
+			}
+			attached = pod
+			follower.follow(ctx, pod)
+		} else if line, ok := follower.tracker.heartbeat(volsyncNow()); ok {
+			follower.printer.update(line, volsyncNow())
+		}
+		if moverProgressSleepFn(ctx, moverProgressPollInterval) != nil {
+			return
+		}
+	}
+}
+
+func (follower *moverProgressFollower) follow(ctx context.Context, pod string) {
+	stream, err := streamMoverLogsFn(ctx, follower.namespace, pod)
+	if err != nil {
+		return
+	}
+	defer func() { _ = stream.Close() }()
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stream)
+		scanner.Split(scanMoverLines)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(moverProgressTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+			if rendered, ok := follower.tracker.observe(line, volsyncNow()); ok {
+				follower.printer.update(rendered, volsyncNow())
+			}
+		case <-ticker.C:
+			if line, ok := follower.tracker.heartbeat(volsyncNow()); ok {
+				follower.printer.update(line, volsyncNow())
+			}
+		}
+	}
+}
+
+// scanMoverLines splits on both \n and the \r restic and kopia use to redraw
+// their status line.
+func scanMoverLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+type moverPodList struct {
+	Items []struct {
+		Metadata struct {
+			Name              string            `json:"name"`
+			Labels            map[string]string `json:"labels"`
+			CreationTimestamp string            `json:"creationTimestamp"`
+		} `json:"metadata"`
+		Status struct {
+			Phase string `json:"phase"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// findMoverPod returns the newest running pod of the destination's mover Job,
+// or "" when none is running yet.
+func findMoverPod(ctx context.Context, namespace, destination string) (string, error) {
+	output, err := moverPodsOutputFn(ctx, namespace)
+	if err != nil {
+		return "", err
+	}
+	var pods moverPodList
+	if err := json.Unmarshal(output, &pods); err != nil {
+		return "", fmt.Errorf("parse mover pods: %w", err)
+	}
+	job := "volsync-dst-" + destination
+	var running []int
+	for i, pod := range pods.Items {
+		if pod.Status.Phase != "Running" {
+			continue
+		}
+		labels := pod.Metadata.Labels
+		if labels["batch.kubernetes.io/job-name"] == job || labels["job-name"] == job || strings.HasPrefix(pod.Metadata.Name, job+"-") {
+			running = append(running, i)
+		}
+	}
+	if len(running) == 0 {
+		return "", nil
+	}
+	// RFC 3339 timestamps in UTC sort lexically.
+	sort.SliceStable(running, func(a, b int) bool {
+		return pods.Items[running[a]].Metadata.CreationTimestamp > pods.Items[running[b]].Metadata.CreationTimestamp
+	})
+	return pods.Items[running[0]].Metadata.Name, nil
+}
+
+type moverLogStream struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (stream *moverLogStream) Close() error {
+	_ = stream.ReadCloser.Close()
+	return stream.cmd.Wait()
+}
+
+func streamMoverLogs(ctx context.Context, namespace, pod string) (io.ReadCloser, error) {
+	cmd := exec.CommandContext(ctx, "kubectl", "logs", "--follow", "--namespace", namespace, pod, "--all-containers=true")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &moverLogStream{ReadCloser: stdout, cmd: cmd}, nil
+}
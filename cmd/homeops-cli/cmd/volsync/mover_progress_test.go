@@ -0,0 +1,217 @@
+package volsync
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homeops-cli/internal/testutil"
+)
+
+func readMoverLogFixture(t *testing.T, name string) []string {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", "mover-logs", name))
+	require.NoError(t, err)
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Split(scanMoverLines)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+func TestParseMoverProgressFixtures(t *testing.T) {
+	for _, tc := range []struct {
+		fixture string
+		parsed  int
+		last    moverProgress
+	}{
+		{"restic-0.16-restore.log", 2, moverProgress{Percent: 25, BytesDone: 2684354560, BytesTotal: 10 << 30, FilesDone: 1250, FilesTotal: 5000, Elapsed: 10 * time.Second}},
+		// Older restic backups redraw the status line with \r.
+		{"restic-0.12-backup.log", 3, moverProgress{Percent: 99.5, BytesDone: 3316788494, BytesTotal: 3332894621, FilesDone: 810, FilesTotal: 812, Elapsed: time.Hour + 2*time.Minute + 3*time.Second}},
+		{"restic-0.17-restore.jsonl", 2, moverProgress{Percent: 50, BytesDone: 1 << 30, BytesTotal: 2 << 30, FilesDone: 450, FilesTotal: 900, Elapsed: 20 * time.Second}},
+		{"kopia-restore.log", 2, moverProgress{Percent: 100, BytesDone: 75800000, BytesTotal: 75800000, FilesDone: 29, FilesTotal: 29}},
+		{"unparseable.log", 0, moverProgress{}},
+	} {
+		t.Run(tc.fixture, func(t *testing.T) {
+			var parsed []moverProgress
+			for _, line := range readMoverLogFixture(t, tc.fixture) {
+				if progress, ok := parseMoverProgress(line); ok {
+					parsed = append(parsed, progress)
+				}
+			}
+			require.Len(t, parsed, tc.parsed)
+			if tc.parsed == 0 {
+				return
+			}
+			last := parsed[len(parsed)-1]
+			assert.InDelta(t, tc.last.Percent, last.Percent, 0.001)
+			assert.InDelta(t, tc.last.BytesDone, last.BytesDone, 1<<20)
+			assert.InDelta(t, tc.last.BytesTotal, last.BytesTotal, 1<<20)
+			assert.Equal(t, tc.last.FilesDone, last.FilesDone)
+			assert.Equal(t, tc.last.FilesTotal, last.FilesTotal)
+			assert.Equal(t, tc.last.Elapsed, last.Elapsed)
+		})
+	}
+}
+
+func TestParseMoverSize(t *testing.T) {
+	for value, want := range map[string]int64{"0 B": 0, "512 B": 512, "1.5 KiB": 1536, "8.6 MB": 8600000, "2 GiB": 2 << 30, "1.0 TB": 1e12} {
+		got, ok := parseMoverSize(value)
+		assert.True(t, ok, value)
+		assert.Equal(t, want, got, value)
+	}
+	_, ok := parseMoverSize("lots")
+	assert.False(t, ok)
+}
+
+func TestMoverProgressTrackerETAAndHeartbeat(t *testing.T) {
+	start := time.Date(2026, 10, 14, 2, 0, 0, 0, time.UTC)
+	tracker := moverProgressTracker{label: "Restoring radarr", interval: 30 * time.Second}
+	tracker.reset(start)
+
+	line, ok := tracker.observe("[0:10] 10.00%  10 files/dirs 1.000 GiB, total 100 files/dirs 10.000 GiB", start)
+	require.True(t, ok)
+	assert.Equal(t, "Restoring radarr: 10.0% (1.0 GiB of 10.0 GiB, 10/100 files) ETA 1m30s", line, "first sample falls back to the mover's elapsed time")
+
+	line, ok = tracker.observe("[0:20] 20.00%  20 files/dirs 2.000 GiB, total 100 files/dirs 10.000 GiB", start.Add(4*time.Second))
+	require.True(t, ok)
+	assert.Equal(t, "Restoring radarr: 20.0% (2.0 GiB of 10.0 GiB, 20/100 files) 256.0 MiB/s ETA 32s", line, "ETA follows the observed byte rate")
+
+	_, ok = tracker.observe("unrelated chatter", start.Add(10*time.Second))
+	assert.False(t, ok)
+	_, ok = tracker.heartbeat(start.Add(20 * time.Second))
+	assert.False(t, ok, "progress was shown recently")
+	line, ok = tracker.heartbeat(start.Add(45 * time.Second))
+	require.True(t, ok)
+	assert.Equal(t, "Restoring radarr: still syncing, last activity 35s ago", line)
+	_, ok = tracker.heartbeat(start.Add(50 * time.Second))
+	assert.False(t, ok, "heartbeats are rate limited too")
+
+	tracker.reset(start.Add(time.Minute))
+	line, ok = tracker.observe(" Processed 6 (8.6 MB) of 29 (75.8 MB) 8.6 MB/s (11.4%) remaining 7s.", start.Add(time.Minute))
+	require.True(t, ok)
+	assert.Equal(t, "Restoring radarr: 11.4% (8.2 MiB of 72.3 MiB, 6/29 files)", line, "a reset drops the old rate; kopia reports no elapsed time")
+}
+
+func TestMoverProgressPrinterThrottlesWithoutTTY(t *testing.T) {
+	start := time.Date(2026, 10, 14, 2, 0, 0, 0, time.UTC)
+	var out bytes.Buffer
+	printer := &moverProgressPrinter{out: &out, interval: 30 * time.Second}
+	printer.update("one", start)
+	printer.update("two", start.Add(10*time.Second))
+	printer.update("three", start.Add(30*time.Second))
+	printer.notice("re-attaching")
+	assert.Equal(t, "one\nthree\nre-attaching\n", out.String())
+
+	out.Reset()
+	printer = &moverProgressPrinter{out: &out, tty: true, interval: 30 * time.Second}
+	printer.update("one", start)
+	printer.update("two", start)
+	printer.finish()
+	assert.Equal(t, "\r\033[Kone\r\033[Ktwo\r\033[K", out.String())
+}
+
+func TestFindMoverPodPicksNewestRunningPod(t *testing.T) {
+	testutil.Swap(t, &moverPodsOutputFn, func(_ context.Context, namespace string) ([]byte, error) {
+		assert.Equal(t, "media", namespace)
+		return []byte(`{"items":[
+			{"metadata":{"name":"volsync-dst-radarr-manual-old","creationTimestamp":"2026-10-14T02:00:00Z"},"status":{"phase":"Failed"}},
+			{"metadata":{"name":"volsync-dst-radarr-manual-abcde","creationTimestamp":"2026-10-14T02:01:00Z"},"status":{"phase":"Running"}},
+			{"metadata":{"name":"mover-xyz","labels":{"batch.kubernetes.io/job-name":"volsync-dst-radarr-manual"},"creationTimestamp":"2026-10-14T02:05:00Z"},"status":{"phase":"Running"}},
+			{"metadata":{"name":"volsync-dst-radarr-manual2-zzzzz","creationTimestamp":"2026-10-14T02:09:00Z"},"status":{"phase":"Running"}},
+			{"metadata":{"name":"radarr-0","creationTimestamp":"2026-10-14T02:10:00Z"},"status":{"phase":"Running"}}]}`), nil
+	})
+	pod, err := findMoverPod(context.Background(), "media", "radarr-manual")
+	require.NoError(t, err)
+	assert.Equal(t, "mover-xyz", pod)
+
+	testutil.Swap(t, &moverPodsOutputFn, func(context.Context, string) ([]byte, error) {
+		return []byte(`{"items":[]}`), nil
+	})
+	pod, err = findMoverPod(context.Background(), "media", "radarr-manual")
+	require.NoError(t, err)
+	assert.Empty(t, pod)
+}
+
+func TestMoverProgressFollowerReattachesAfterPodRestart(t *testing.T) {
+	// The first pod is restarted in place, then replaced by a new pod.
+	pods := []string{"", "volsync-dst-radarr-manual-aaaaa", "volsync-dst-radarr-manual-aaaaa", "volsync-dst-radarr-manual-bbbbb"}
+	logs := map[string][]string{
+		"volsync-dst-radarr-manual-aaaaa": {"restic-0.16-restore.log", "unparseable.log"},
+		"volsync-dst-radarr-manual-bbbbb": {"restic-0.17-restore.jsonl"},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	polls := 0
+	testutil.Swap(t, &moverPodsOutputFn, func(context.Context, string) ([]byte, error) {
+		pod := pods[polls]
+		if pod == "" {
+			return nil, errors.New("connection refused")
+		}
+		return []byte(`{"items":[{"metadata":{"name":"` + pod + `"},"status":{"phase":"Running"}}]}`), nil
+	})
+	testutil.Swap(t, &moverProgressSleepFn, func(context.Context, time.Duration) error {
+		polls++
+		if polls == len(pods) {
+			cancel()
+			return context.Canceled
+		}
+		return nil
+	})
+	var streamed []string
+	testutil.Swap(t, &streamMoverLogsFn, func(_ context.Context, namespace, pod string) (io.ReadCloser, error) {
+		assert.Equal(t, "media", namespace)
+		fixture := logs[pod][0]
+		logs[pod] = logs[pod][1:]
+		streamed = append(streamed, pod+":"+fixture)
+		raw, err := os.ReadFile(filepath.Join("testdata", "mover-logs", fixture))
+		require.NoError(t, err)
+		return io.NopCloser(bytes.NewReader(raw)), nil
+	})
+
+	var out bytes.Buffer
+	follower := &moverProgressFollower{
+		namespace:   "media",
+		destination: "radarr-manual",
+		// A zero printer interval prints every sample; the hour-long tracker
+		// interval keeps heartbeats out of the output.
+		printer: &moverProgressPrinter{out: &out},
+		tracker: moverProgressTracker{label: "Restoring radarr", interval: time.Hour},
+	}
+	follower.run(ctx)
+
+	assert.Equal(t, []string{
+		"volsync-dst-radarr-manual-aaaaa:restic-0.16-restore.log",
+		"volsync-dst-radarr-manual-aaaaa:unparseable.log",
+		"volsync-dst-radarr-manual-bbbbb:restic-0.17-restore.jsonl",
+	}, streamed)
+	output := out.String()
+	assert.Contains(t, output, "Restoring radarr: 25.0%")
+	assert.Contains(t, output, "Mover pod volsync-dst-radarr-manual-aaaaa log stream ended; re-attaching")
+	assert.Contains(t, output, "Mover pod volsync-dst-radarr-manual-aaaaa replaced by volsync-dst-radarr-manual-bbbbb; re-attaching")
+	assert.Contains(t, output, "Restoring radarr: 50.0% (1.0 GiB of 2.0 GiB, 450/900 files)")
+	assert.Less(t, strings.Index(output, "replaced by"), strings.Index(output, "50.0%"))
+}
+
+func TestStartMoverProgressStopsCleanly(t *testing.T) {
+	testutil.Swap(t, &moverPodsOutputFn, func(context.Context, string) ([]byte, error) {
+		return []byte(`{"items":[]}`), nil
+	})
+	var out bytes.Buffer
+	testutil.Swap(t, &moverProgressOutput, io.Writer(&out))
+	testutil.Swap(t, &moverProgressIsTTY, func() bool { return true })
+	stop := startMoverProgress("media", "radarr-manual", "Restoring radarr")
+	stop()
+	assert.Empty(t, out.String(), "nothing is printed before the heartbeat interval")
+}
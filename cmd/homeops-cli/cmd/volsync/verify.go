@@ -79,6 +79,10 @@ type verifyPVC struct {
 type verifyRestoreConfig struct {
 	PVC   verifyPVC
 	Kopia map[string]any
+	// Previous and RestoreAsOf select an older snapshot (browse --snapshot);
+	// verify always restores the latest.
+	Previous    int
+	RestoreAsOf string
 }
 
 type verifyDestinationStatus struct {
@@ -318,9 +322,18 @@ func buildVerifyRestoreConfig(ctx context.Context, namespace, app string) (verif
 }
 
 func buildVerifyPVC(name, namespace, app string, source verifyPVC) (string, error) {
+	spec, err := scratchPVCSpec(source)
+	if err != nil {
+		return "", err
+	}
+	return marshalVerifyObject("v1", "PersistentVolumeClaim", name, namespace, app, spec)
+}
+
+// scratchPVCSpec sizes an ownerless scratch PVC like the source claim.
+func scratchPVCSpec(source verifyPVC) (map[string]any, error) {
 	storage := source.Spec.Resources.Requests["storage"]
 	if storage == "" {
-		return "", fmt.Errorf("source PVC capacity is empty")
+		return nil, fmt.Errorf("source PVC capacity is empty")
 	}
 	accessModes := source.Spec.AccessModes
 	if len(accessModes) == 0 {
@@ -336,13 +349,23 @@ func buildVerifyPVC(name, namespace, app string, source verifyPVC) (string, erro
 	if source.Spec.VolumeMode != "" {
 		spec["volumeMode"] = source.Spec.VolumeMode
 	}
-	return marshalVerifyObject("v1", "PersistentVolumeClaim", name, namespace, app, spec)
+	return spec, nil
 }
 
 func buildVerifyDestination(name, namespace, app, trigger string, config verifyRestoreConfig) (string, error) {
+	spec, err := scratchDestinationSpec(name, app, trigger, config)
+	if err != nil {
+		return "", err
+	}
+	return marshalVerifyObject("volsync.backube/v1alpha1", "ReplicationDestination", name, namespace, app, spec)
+}
+
+// scratchDestinationSpec builds a manually triggered Kopia restore of app's
+// repository into the scratch PVC named name.
+func scratchDestinationSpec(name, app, trigger string, config verifyRestoreConfig) (map[string]any, error) {
 	storage := config.PVC.Spec.Resources.Requests["storage"]
 	if storage == "" {
-		return "", fmt.Errorf("source PVC capacity is empty")
+		return nil, fmt.Errorf("source PVC capacity is empty")
 	}
 
 	// NestedMap returns a deep copy, so the live ReplicationSource remains the
@@ -351,7 +374,7 @@ func buildVerifyDestination(name, namespace, app, trigger string, config verifyR
 	// the fields needed to target and clean up its ownerless scratch PVC.
 	kopia, ok := runtime.DeepCopyJSONValue(config.Kopia).(map[string]any)
 	if !ok {
-		return "", fmt.Errorf("copy ReplicationSource Kopia configuration")
+		return nil, fmt.Errorf("copy ReplicationSource Kopia configuration")
 	}
 	// Backup-side knobs exist only in the ReplicationSource schema; the API
 	// server rejects them on a ReplicationDestination (verified live with
@@ -365,7 +388,10 @@ func buildVerifyDestination(name, namespace, app, trigger string, config verifyR
 	kopia["copyMethod"] = "Direct"
 	kopia["destinationPVC"] = name
 	kopia["enableFileDeletion"] = true
-	kopia["previous"] = 0
+	kopia["previous"] = config.Previous
+	if config.RestoreAsOf != "" {
+		kopia["restoreAsOf"] = config.RestoreAsOf
+	}
 	if config.PVC.Spec.StorageClassName == "" {
 		delete(kopia, "storageClassName")
 	} else {
@@ -378,24 +404,27 @@ func buildVerifyDestination(name, namespace, app, trigger string, config verifyR
 	sourceIdentity["sourceName"] = app
 	kopia["sourceIdentity"] = sourceIdentity
 
-	spec := map[string]any{
+	return map[string]any{
 		"trigger": map[string]string{"manual": trigger},
 		"kopia":   kopia,
-	}
-	return marshalVerifyObject("volsync.backube/v1alpha1", "ReplicationDestination", name, namespace, app, spec)
+	}, nil
 }
 
 func marshalVerifyObject(apiVersion, kind, name, namespace, app string, spec map[string]any) (string, error) {
+	return marshalScratchObject(apiVersion, kind, name, namespace, map[string]string{
+		verifyLabelKey:    app,
+		verifyRunLabelKey: name,
+	}, spec)
+}
+
+func marshalScratchObject(apiVersion, kind, name, namespace string, labels map[string]string, spec map[string]any) (string, error) {
 	object := map[string]any{
 		"apiVersion": apiVersion,
 		"kind":       kind,
 		"metadata": map[string]any{
 			"name":      name,
 			"namespace": namespace,
-			"labels": map[string]string{
-				verifyLabelKey:    app,
-				verifyRunLabelKey: name,
-			},
+			"labels":    labels,
 		},
 		"spec": spec,
 	}
@@ -434,12 +463,18 @@ func findExistingVerifications(ctx context.Context, namespace, app string) ([]st
 }
 
 func verifyObjectPrefix(app string) string {
+	return scratchObjectPrefix("verify", app)
+}
+
+// scratchObjectPrefix keeps scratch object names (volsync-<purpose>-<app>-)
+// short enough for the timestamp suffix and volsync's derived mover names.
+func scratchObjectPrefix(purpose, app string) string {
 	const maxAppLength = 28
 	app = strings.Trim(strings.ToLower(app), "-")
 	if len(app) > maxAppLength {
 		app = strings.TrimRight(app[:maxAppLength], "-")
 	}
-	return "volsync-verify-" + app + "-"
+	return "volsync-" + purpose + "-" + app + "-"
 }
 
 func verifyObjectName(app string, now time.Time) string {
@@ -457,6 +492,8 @@ func verifyConfirmationMessage(namespace, name string, check bool) string {
 func waitForVerifyDestination(ctx context.Context, namespace, name, trigger string, timeout time.Duration) (verifyDestinationStatus, error) {
 	waitCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
+	stopProgress := startMoverProgressFn(namespace, name, "Restoring "+name)
+	defer stopProgress()
 	for {
 		output, err := verifyOutputFn(waitCtx, "get", "replicationdestination", name, "--namespace", namespace, "-o", "json")
 		if err != nil {
@@ -629,12 +666,18 @@ func (op *verifyOperation) runIntegrityCheck(ctx context.Context, timeout time.D
 }
 
 func buildVerifyCheckPod(name, namespace, app, checkImage string) (string, error) {
-	spec := map[string]any{
+	return marshalVerifyObject("v1", "Pod", name, namespace, app, readOnlyScratchPodSpec(name, "check", checkImage, "sleep 3600"))
+}
+
+// readOnlyScratchPodSpec mounts the scratch PVC claim read-only at /data in a
+// non-root, capability-free container that idles with sleepCommand.
+func readOnlyScratchPodSpec(claim, container, image, sleepCommand string) map[string]any {
+	return map[string]any{
 		"restartPolicy": "Never",
 		"containers": []map[string]any{{
-			"name":    "check",
-			"image":   checkImage,
-			"command": []string{"sh", "-c", "sleep 3600"},
+			"name":    container,
+			"image":   image,
+			"command": []string{"sh", "-c", sleepCommand},
 			"securityContext": map[string]any{
 				"allowPrivilegeEscalation": false,
 				"capabilities":             map[string]any{"drop": []string{"ALL"}},
@@ -644,9 +687,8 @@ func buildVerifyCheckPod(name, namespace, app, checkImage string) (string, error
 			},
 			"volumeMounts": []map[string]any{{"name": "data", "mountPath": "/data", "readOnly": true}},
 		}},
-		"volumes": []map[string]any{{"name": "data", "persistentVolumeClaim": map[string]any{"claimName": name, "readOnly": true}}},
+		"volumes": []map[string]any{{"name": "data", "persistentVolumeClaim": map[string]any{"claimName": claim, "readOnly": true}}},
 	}
-	return marshalVerifyObject("v1", "Pod", name, namespace, app, spec)
 }
 
 func (op *verifyOperation) cleanup() {
@@ -172,6 +172,7 @@ func NewCommand() *cobra.Command {
 	cmd.AddCommand(
 		newStateCommand(),
 		newStatusCommand(),
+		newAuditCommand(),
 		newSuspendCommand(),
 		newResumeCommand(),
 		newSnapshotCommand(),
@@ -182,6 +183,7 @@ func NewCommand() *cobra.Command {
 		newMigrateCommand(),
 		newVerifyCommand(),
 		newVerifyAllCommand(),
+		newBrowseCommand(),
 	)
 
 	return cmd
@@ -1421,6 +1423,8 @@ func (restore *restoreOperation) waitForRestoreJob(restoreTimeout time.Duration)
 	}
 
 	// Wait for completion
+	stopProgress := startMoverProgressFn(restore.namespace, restore.destName, "Restoring "+restore.app)
+	defer stopProgress()
 	if err := commandRunFn("kubectl", "--namespace", restore.namespace, "wait",
 		fmt.Sprintf("job/%s", jobName),
 		"--for=condition=complete",
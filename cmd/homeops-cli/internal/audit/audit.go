@@ -0,0 +1,264 @@
+// Package audit keeps the append-only log of mutating command invocations:
+// who ran what, against which cluster, how long it took, and how it ended.
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"homeops-cli/internal/cmdutil"
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/config"
+	"homeops-cli/internal/secrets"
+)
+
+// Entry is one line of the audit log.
+type Entry struct {
+	Time       time.Time `json:"time"`
+	User       string    `json:"user"`
+	Host       string    `json:"host"`
+	Command    string    `json:"command"`
+	Args       []string  `json:"args,omitempty"`
+	Cluster    string    `json:"cluster"`
+	Context    string    `json:"context,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	Result     string    `json:"result"`
+	Error      string    `json:"error,omitempty"`
+}
+
+const (
+	ResultOK    = "ok"
+	ResultError = "error"
+
+	mirrorTimeout  = 5 * time.Second
+	contextTimeout = 2 * time.Second
+)
+
+var (
+	nowFn         = time.Now
+	currentUserFn = func() string {
+		if u, err := user.Current(); err == nil && u.Username != "" {
+			return u.Username
+		}
+		return os.Getenv("USER")
+	}
+	hostnameFn    = os.Hostname
+	kubeContextFn = func() string {
+		ctx, cancel := context.WithTimeout(context.Background(), contextTimeout)
+		defer cancel()
+		result, err := common.RunCommand(ctx, common.CommandOptions{Name: "kubectl", Args: []string{"config", "current-context"}})
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(result.Stdout)
+	}
+	kubectlCreateFn = func(namespace string, manifest []byte) error {
+		ctx, cancel := context.WithTimeout(context.Background(), mirrorTimeout)
+		defer cancel()
+		result, err := common.RunCommand(ctx, common.CommandOptions{
+			Name:  "kubectl",
+			Args:  []string{"create", "--namespace", namespace, "-f", "-"},
+			Stdin: strings.NewReader(string(manifest)),
+		})
+		if err != nil {
+			return fmt.Errorf("kubectl create event: %w (%s)", err, strings.TrimSpace(result.Stderr))
+		}
+		return nil
+	}
+)
+
+// secretFlagPattern matches flag names whose values are credentials. Values
+// of matching flags are masked; label=value flags keep their label.
+var secretFlagPattern = regexp.MustCompile(`(?i)(token|password|passphrase|secret|cert-key|^key$|^field$)`)
+
+const masked = "<redacted>"
+
+// Record appends the entry for a finished invocation unless it is read-only
+// (see cmdutil.ReadOnly) or the log is disabled in config. Mirroring is best
+// effort: an unreachable cluster never fails the command, and the local log
+// stays authoritative.
+func Record(cmd *cobra.Command, args []string, started time.Time, runErr error) error {
+	cfg := config.Get()
+	if cfg.Audit.Disabled || cmdutil.ReadOnly(cmd, args) {
+		return nil
+	}
+	entry := NewEntry(cmd, args, started, runErr)
+	if err := Append(cfg.Audit.Path, entry); err != nil {
+		return err
+	}
+	if cfg.Audit.Mirror {
+		if err := mirrorEvent(cfg.Audit.Namespace, entry); err != nil {
+			common.NewColorLogger().Debug("audit entry not mirrored to the cluster: %v", err)
+		}
+	}
+	return nil
+}
+
+// NewEntry describes an invocation that started at started and finished with
+// runErr.
+func NewEntry(cmd *cobra.Command, args []string, started time.Time, runErr error) Entry {
+	host, _ := hostnameFn()
+	entry := Entry{
+		Time:       started.UTC(),
+		User:       currentUserFn(),
+		Host:       host,
+		Command:    cmd.CommandPath(),
+		Args:       MaskedArgs(cmd, args),
+		Cluster:    config.Get().ClusterNameWithDefault(),
+		Context:    kubeContextFn(),
+		DurationMS: nowFn().Sub(started).Milliseconds(),
+		Result:     ResultOK,
+	}
+	if runErr != nil {
+		entry.Result = ResultError
+		entry.Error = common.RedactError(runErr).Error()
+	}
+	return entry
+}
+
+// MaskedArgs renders the flags set on cmd as --name=value, followed by the
+// positional arguments, with credential-bearing values masked and any
+// resolved secret redacted.
+func MaskedArgs(cmd *cobra.Command, args []string) []string {
+	var out []string
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		values := []string{flag.Value.String()}
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			values = slice.GetSlice()
+		}
+		for _, value := range values {
+			if secretFlagPattern.MatchString(flag.Name) {
+				value = maskValue(value)
+			}
+			out = append(out, common.RedactCommandOutput(fmt.Sprintf("--%s=%s", flag.Name, value)))
+		}
+	})
+	for _, arg := range args {
+		out = append(out, common.RedactCommandOutput(arg))
+	}
+	return out
+}
+
+func maskValue(value string) string {
+	if label, _, ok := strings.Cut(value, "="); ok && label != "" {
+		return label + "=" + masked
+	}
+	if value == "" {
+		return ""
+	}
+	return masked
+}
+
+// Append writes entry as one JSON line. The file is opened O_APPEND and the
+// line written in a single call, so concurrent invocations never interleave
+// partial entries.
+func Append(path string, entry Entry) error {
+	path, err := secrets.ExpandHome(path)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshal audit entry: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create audit log directory %s: %w", filepath.Dir(path), err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600) // #nosec G304 -- operator-configured audit log path
+	if err != nil {
+		return fmt.Errorf("failed to open audit log %s: %w", path, err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write audit log %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+// Read returns the entries at or after since (zero means all), oldest first.
+// A missing log has no entries; a torn or hand-edited line is skipped.
+func Read(path string, since time.Time) ([]Entry, error) {
+	path, err := secrets.ExpandHome(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path) // #nosec G304 -- operator-configured audit log path
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log %s: %w", path, err)
+	}
+	defer func() { _ = file.Close() }()
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if !since.IsZero() && entry.Time.Before(since) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// mirrorEvent records entry as a core/v1 Event on the namespace itself, so it
+// shows up in 'kubectl get events' and ages out with the cluster's event TTL.
+func mirrorEvent(namespace string, entry Entry) error {
+	manifest, err := json.Marshal(eventManifest(namespace, entry))
+	if err != nil {
+		return err
+	}
+	return kubectlCreateFn(namespace, manifest)
+}
+
+func eventManifest(namespace string, entry Entry) map[string]any {
+	eventType, reason := "Normal", "HomeopsCommandSucceeded"
+	if entry.Result != ResultOK {
+		eventType, reason = "Warning", "HomeopsCommandFailed"
+	}
+	message := fmt.Sprintf("%s@%s ran %s (%s, %s)", entry.User, entry.Host,
+		strings.TrimSpace(entry.Command+" "+strings.Join(entry.Args, " ")), entry.Result, time.Duration(entry.DurationMS)*time.Millisecond)
+	if entry.Error != "" {
+		message += ": " + entry.Error
+	}
+	timestamp := entry.Time.Format(time.RFC3339)
+	return map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Event",
+		"metadata": map[string]any{
+			"generateName": "homeops-audit-",
+			"namespace":    namespace,
+			"labels":       map[string]string{"app.kubernetes.io/managed-by": "homeops-cli"},
+		},
+		"involvedObject":     map[string]any{"apiVersion": "v1", "kind": "Namespace", "name": namespace},
+		"type":               eventType,
+		"reason":             reason,
+		"message":            message,
+		"source":             map[string]any{"component": "homeops-cli", "host": entry.Host},
+		"reportingComponent": "homeops-cli",
+		"reportingInstance":  entry.Host,
+		"firstTimestamp":     timestamp,
+		"lastTimestamp":      timestamp,
+		"count":              1,
+	}
+}
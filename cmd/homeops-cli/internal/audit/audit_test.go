@@ -0,0 +1,199 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/config"
+	"homeops-cli/internal/testutil"
+)
+
+func setupAudit(t *testing.T, mutate func(*config.Config)) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "state", "audit.log")
+	cfg := &config.Config{}
+	cfg.Audit.Path = path
+	if mutate != nil {
+		mutate(cfg)
+	}
+	t.Cleanup(config.SetForTesting(cfg))
+	now := time.Date(2026, 10, 14, 12, 0, 5, 0, time.UTC)
+	testutil.Swap(t, &nowFn, func() time.Time { return now })
+	testutil.Swap(t, &currentUserFn, func() string { return "ops" })
+	testutil.Swap(t, &hostnameFn, func() (string, error) { return "laptop", nil })
+	testutil.Swap(t, &kubeContextFn, func() string { return "admin@home" })
+	testutil.Swap(t, &kubectlCreateFn, func(string, []byte) error {
+		t.Fatal("unexpected mirror")
+		return nil
+	})
+	return path
+}
+
+func newTree() (root, upgrade, list *cobra.Command) {
+	root = &cobra.Command{Use: "homeops-cli"}
+	talos := &cobra.Command{Use: "talos"}
+	upgrade = &cobra.Command{Use: "upgrade-node", RunE: func(*cobra.Command, []string) error { return nil }}
+	upgrade.Flags().String("ip", "", "")
+	upgrade.Flags().Bool("dry-run", false, "")
+	list = &cobra.Command{Use: "list"}
+	talos.AddCommand(upgrade, list)
+	root.AddCommand(talos)
+	return root, upgrade, list
+}
+
+func TestRecordAppendsMutatingInvocation(t *testing.T) {
+	path := setupAudit(t, nil)
+	_, upgrade, _ := newTree()
+	require.NoError(t, upgrade.Flags().Set("ip", "192.168.1.10"))
+
+	started := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, Record(upgrade, nil, started, nil))
+	require.NoError(t, Record(upgrade, nil, started, errors.New("node unreachable")))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	entries, err := Read(path, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, Entry{
+		Time: started, User: "ops", Host: "laptop", Command: "homeops-cli talos upgrade-node",
+		Args: []string{"--ip=192.168.1.10"}, Cluster: "home-ops-cluster", Context: "admin@home",
+		DurationMS: 5000, Result: ResultOK,
+	}, entries[0])
+	assert.Equal(t, ResultError, entries[1].Result)
+	assert.Equal(t, "node unreachable", entries[1].Error)
+}
+
+func TestRecordSkipsReadOnlyAndDisabled(t *testing.T) {
+	path := setupAudit(t, nil)
+	_, upgrade, list := newTree()
+
+	require.NoError(t, Record(list, nil, time.Now(), nil))
+	require.NoError(t, upgrade.Flags().Set("dry-run", "true"))
+	require.NoError(t, Record(upgrade, nil, time.Now(), nil))
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "read-only invocations must not be logged")
+
+	path = setupAudit(t, func(cfg *config.Config) { cfg.Audit.Disabled = true })
+	_, upgrade, _ = newTree()
+	require.NoError(t, Record(upgrade, nil, time.Now(), nil))
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "audit.disabled must suppress the log")
+}
+
+func TestMaskedArgs(t *testing.T) {
+	cmd := &cobra.Command{Use: "push"}
+	cmd.Flags().String("token", "", "")
+	cmd.Flags().String("cert-key", "", "")
+	cmd.Flags().StringArray("field", nil, "")
+	cmd.Flags().String("node", "", "")
+	require.NoError(t, cmd.Flags().Parse([]string{
+		"--token", "hunter2", "--cert-key", "abc123",
+		"--field", "password=s3cret", "--field", "username=admin",
+		"--node", "k8s-0",
+	}))
+
+	got := MaskedArgs(cmd, []string{"vault/item"})
+	assert.Equal(t, []string{
+		"--cert-key=<redacted>",
+		"--field=password=<redacted>",
+		"--field=username=<redacted>",
+		"--node=k8s-0",
+		"--token=<redacted>",
+		"vault/item",
+	}, got)
+	assert.NotContains(t, strings.Join(got, " "), "hunter2")
+	assert.NotContains(t, strings.Join(got, " "), "s3cret")
+}
+
+func TestAppendConcurrentLinesStayIntact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, Append(path, Entry{
+				Command: "homeops-cli talos apply-node",
+				Args:    []string{strings.Repeat("x", 2048)},
+				Result:  ResultOK, DurationMS: int64(i),
+			}))
+		}(i)
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	require.Len(t, lines, 50)
+	for _, line := range lines {
+		var entry Entry
+		require.NoError(t, json.Unmarshal([]byte(line), &entry), "torn line: %q", line[:40])
+	}
+}
+
+func TestReadSkipsTornLinesAndFiltersSince(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	old := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	recent := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, Append(path, Entry{Time: old, Command: "a", Result: ResultOK}))
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"time":"2026-10-` + "\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.NoError(t, Append(path, Entry{Time: recent, Command: "b", Result: ResultOK}))
+
+	all, err := Read(path, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, "a", all[0].Command)
+
+	since, err := Read(path, recent.Add(-time.Hour))
+	require.NoError(t, err)
+	require.Len(t, since, 1)
+	assert.Equal(t, "b", since[0].Command)
+
+	entries, err := Read(filepath.Join(t.TempDir(), "missing.log"), time.Time{})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestRecordMirrorsEventBestEffort(t *testing.T) {
+	path := setupAudit(t, func(cfg *config.Config) {
+		cfg.Audit.Mirror = true
+		cfg.Audit.Namespace = "flux-system"
+	})
+	_, upgrade, _ := newTree()
+	var gotNamespace string
+	var event map[string]any
+	testutil.Swap(t, &kubectlCreateFn, func(namespace string, manifest []byte) error {
+		gotNamespace = namespace
+		require.NoError(t, json.Unmarshal(manifest, &event))
+		return errors.New("cluster unreachable")
+	})
+
+	require.NoError(t, Record(upgrade, nil, time.Now(), errors.New("boom")), "mirror failures must not fail the command")
+	assert.Equal(t, "flux-system", gotNamespace)
+	assert.Equal(t, "Event", event["kind"])
+	assert.Equal(t, "Warning", event["type"])
+	assert.Equal(t, "HomeopsCommandFailed", event["reason"])
+	assert.Contains(t, event["message"], "ops@laptop ran homeops-cli talos upgrade-node")
+	assert.Equal(t, "flux-system", event["involvedObject"].(map[string]any)["name"])
+
+	entries, err := Read(path, time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "the local log is written even when mirroring fails")
+}
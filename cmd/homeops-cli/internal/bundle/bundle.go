@@ -0,0 +1,328 @@
+// Package bundle builds and checks the offline disaster-recovery bundle: one
+// tar.gz holding the homeops-cli binary, the pinned external tools for one
+// OS/arch, prepared Talos ISOs with their schematic state, the embedded
+// templates, and a BUNDLE-MANIFEST.json recording every file's SHA-256.
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/config"
+	"homeops-cli/internal/talos"
+	"homeops-cli/internal/templates"
+)
+
+const (
+	// ManifestName is the manifest's path inside the bundle.
+	ManifestName = "BUNDLE-MANIFEST.json"
+	// ReadmeName is written next to the extracted files.
+	ReadmeName = "README.md"
+	// FormatVersion is bumped when the manifest layout changes.
+	FormatVersion = 1
+	// BinaryName is the homeops-cli binary's path inside the bundle.
+	BinaryName = "homeops-cli"
+)
+
+// Entry kinds, listed in recovery order.
+const (
+	KindBinary    = "binary"
+	KindTool      = "tool"
+	KindISO       = "iso"
+	KindSchematic = "schematic"
+	KindTemplate  = "template"
+)
+
+// Manifest describes a bundle. Entries are in recovery order.
+type Manifest struct {
+	FormatVersion  int       `json:"format_version"`
+	CreatedAt      time.Time `json:"created_at"`
+	HomeopsVersion string    `json:"homeops_version"`
+	OS             string    `json:"os"`
+	Arch           string    `json:"arch"`
+	Entries        []Entry   `json:"entries"`
+}
+
+// Entry is one file in the bundle.
+type Entry struct {
+	Path    string `json:"path"`
+	Kind    string `json:"kind"`
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+	// Source is the URL a downloaded file came from.
+	Source string `json:"source,omitempty"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// CreateOptions selects what Create packages.
+type CreateOptions struct {
+	// Output is the tar.gz to write; it must not exist yet.
+	Output string
+	// BinaryPath is the homeops-cli executable to include.
+	BinaryPath     string
+	HomeopsVersion string
+	// OS and Arch pick the tool downloads (Go GOOS/GOARCH names).
+	OS, Arch string
+	Tools    []config.ToolPin
+	// ISOCacheDir holds the Talos factory's cached ISO records (schematic
+	// state); every record's ISO is downloaded unless SkipISOs is set.
+	ISOCacheDir string
+	SkipISOs    bool
+	HTTPClient  *http.Client
+	Now         time.Time
+	Logger      *common.ColorLogger
+}
+
+// staged is a manifest entry plus the local file holding its content.
+type staged struct {
+	entry Entry
+	file  string
+	data  []byte
+}
+
+// Create downloads and stages everything, then writes the bundle. Nothing is
+// written to Output unless every download verifies.
+func Create(ctx context.Context, opts CreateOptions) (*Manifest, error) {
+	if opts.Output == "" {
+		return nil, fmt.Errorf("output path is required")
+	}
+	if _, err := os.Stat(opts.Output); err == nil {
+		return nil, fmt.Errorf("%s already exists; remove it or choose another --output", opts.Output)
+	}
+	if len(opts.Tools) == 0 {
+		return nil, fmt.Errorf("no toolchain.tools are pinned in homeops.yaml; a recovery bundle needs at least kubectl, helmfile and the cluster's node tooling")
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = common.NewColorLogger()
+	}
+	client := opts.HTTPClient
+	if client == nil {
+		client = NewHTTPClient()
+	}
+
+	stageDir, err := os.MkdirTemp("", "homeops-bundle-*")
+	if err != nil {
+		return nil, fmt.Errorf("create staging directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(stageDir) }()
+
+	var items []staged
+	binary, err := hashFile(opts.BinaryPath)
+	if err != nil {
+		return nil, fmt.Errorf("hash homeops-cli binary: %w", err)
+	}
+	binary.Path, binary.Kind, binary.Name, binary.Version = BinaryName, KindBinary, BinaryName, opts.HomeopsVersion
+	items = append(items, staged{entry: binary, file: opts.BinaryPath})
+
+	for _, tool := range opts.Tools {
+		logger.Info("Downloading %s %s for %s/%s", tool.Name, tool.Version, opts.OS, opts.Arch)
+		item, err := fetchTool(ctx, client, tool, opts.OS, opts.Arch, stageDir)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	records, err := loadISORecords(opts.ISOCacheDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if !opts.SkipISOs {
+			logger.Info("Downloading Talos ISO for schematic %s", shortID(record.info.SchematicID))
+			item, err := fetchISO(ctx, client, record.info, stageDir)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		items = append(items, dataItem(path.Join("schematics", filepath.Base(record.file)), KindSchematic, record.info.SchematicID, record.info.TalosVersion, record.data))
+	}
+
+	err = templates.WalkEmbedded(func(name string, data []byte) error {
+		items = append(items, dataItem(path.Join("templates", name), KindTemplate, "", "", data))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{
+		FormatVersion:  FormatVersion,
+		CreatedAt:      opts.Now.UTC(),
+		HomeopsVersion: opts.HomeopsVersion,
+		OS:             opts.OS,
+		Arch:           opts.Arch,
+	}
+	for _, item := range items {
+		manifest.Entries = append(manifest.Entries, item.entry)
+	}
+	if err := writeArchive(opts.Output, manifest, items); err != nil {
+		_ = os.Remove(opts.Output)
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// isoRecord is one cached Talos factory ISO record.
+type isoRecord struct {
+	file string
+	data []byte
+	info talos.ISOInfo
+}
+
+// loadISORecords reads every *.json Talos factory cache record in dir. A
+// missing dir means no ISO has been prepared on this workstation.
+func loadISORecords(dir string) ([]isoRecord, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("list Talos ISO cache %s: %w", dir, err)
+	}
+	sort.Strings(files)
+	records := make([]isoRecord, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file) // #nosec G304 -- file comes from globbing the local Talos ISO cache directory
+		if err != nil {
+			return nil, fmt.Errorf("read Talos ISO cache record: %w", err)
+		}
+		var info talos.ISOInfo
+		if err := json.Unmarshal(data, &info); err != nil {
+			return nil, fmt.Errorf("parse Talos ISO cache record %s: %w", file, err)
+		}
+		if info.URL == "" || info.SchematicID == "" {
+			return nil, fmt.Errorf("Talos ISO cache record %s has no URL or schematic ID", file)
+		}
+		records = append(records, isoRecord{file: file, data: data, info: info})
+	}
+	return records, nil
+}
+
+func dataItem(name, kind, label, version string, data []byte) staged {
+	sum := sha256.Sum256(data)
+	return staged{
+		entry: Entry{Path: name, Kind: kind, Name: label, Version: version, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])},
+		data:  data,
+	}
+}
+
+// writeArchive writes the manifest first, then every staged item in order.
+func writeArchive(output string, manifest *Manifest, items []staged) (err error) {
+	raw, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode bundle manifest: %w", err)
+	}
+	// #nosec G304 -- output is the operator-chosen bundle path; O_EXCL refuses to overwrite.
+	file, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("create bundle: %w", err)
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("close bundle: %w", closeErr)
+		}
+	}()
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	if err := writeTarFile(tw, ManifestName, 0o644, manifest.CreatedAt, int64(len(raw)), bytes.NewReader(raw)); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if item.file == "" {
+			err = writeTarFile(tw, item.entry.Path, entryMode(item.entry), manifest.CreatedAt, item.entry.Size, bytes.NewReader(item.data))
+		} else {
+			err = copyTarFile(tw, item, manifest.CreatedAt)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("finish bundle tar: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("finish bundle gzip: %w", err)
+	}
+	return nil
+}
+
+func copyTarFile(tw *tar.Writer, item staged, modTime time.Time) error {
+	file, err := os.Open(item.file) // #nosec G304 -- staged files are the CLI binary or downloads in our private temp directory
+	if err != nil {
+		return fmt.Errorf("open %s: %w", item.entry.Path, err)
+	}
+	defer func() { _ = file.Close() }()
+	return writeTarFile(tw, item.entry.Path, entryMode(item.entry), modTime, item.entry.Size, file)
+}
+
+func writeTarFile(tw *tar.Writer, name string, mode int64, modTime time.Time, size int64, content io.Reader) error {
+	header := &tar.Header{Name: name, Mode: mode, Size: size, ModTime: modTime, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("write %s header: %w", name, err)
+	}
+	if _, err := io.CopyN(tw, content, size); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
+
+// entryMode marks the CLI and bare tool binaries executable. Tool archives
+// (tar.gz, zip) and everything else are plain files.
+func entryMode(entry Entry) int64 {
+	switch {
+	case entry.Kind == KindBinary:
+		return 0o755
+	case entry.Kind == KindTool && !isArchive(entry.Path):
+		return 0o755
+	}
+	return 0o644
+}
+
+func isArchive(name string) bool {
+	for _, suffix := range []string{".tar.gz", ".tgz", ".tar.xz", ".zip", ".pkg"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func hashFile(name string) (Entry, error) {
+	file, err := os.Open(name) // #nosec G304 -- callers pass the running executable or a file they just downloaded
+	if err != nil {
+		return Entry{}, err
+	}
+	defer func() { _ = file.Close() }()
+	digest := sha256.New()
+	size, err := io.Copy(digest, file)
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{Size: size, SHA256: hex.EncodeToString(digest.Sum(nil))}, nil
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
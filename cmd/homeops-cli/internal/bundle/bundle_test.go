@@ -0,0 +1,231 @@
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/config"
+)
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// artifactServer serves fixed bodies by path.
+func artifactServer(t *testing.T, files map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// createFixture builds a bundle with two tools (one pinned by digest, one by
+// checksum file) and one cached Talos ISO record.
+func createFixture(t *testing.T) (string, *Manifest) {
+	t.Helper()
+	server := artifactServer(t, map[string]string{
+		"/kubectl/v1.36.2/linux/amd64/kubectl":               "kubectl-binary",
+		"/helmfile/v1.1.0/helmfile_1.1.0_linux_amd64.tar.gz": "helmfile-archive",
+		"/helmfile/v1.1.0/checksums.txt":                     sha256Hex("other") + "  helmfile_1.1.0_darwin_arm64.tar.gz\n" + sha256Hex("helmfile-archive") + "  helmfile_1.1.0_linux_amd64.tar.gz\n",
+		"/image/abcdef0123456789/v1.13.6/metal-amd64.iso":    "talos-iso",
+	})
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "homeops-cli")
+	require.NoError(t, os.WriteFile(binary, []byte("homeops-binary"), 0o755))
+	cacheDir := filepath.Join(dir, "talos-isos")
+	require.NoError(t, os.MkdirAll(cacheDir, 0o700))
+	record := fmt.Sprintf(`{"URL":"%s/image/abcdef0123456789/v1.13.6/metal-amd64.iso","SchematicID":"abcdef0123456789","TalosVersion":"v1.13.6"}`, server.URL)
+	require.NoError(t, os.WriteFile(filepath.Join(cacheDir, "cafe.json"), []byte(record), 0o600))
+
+	output := filepath.Join(dir, "homeops-dr.tar.gz")
+	manifest, err := Create(context.Background(), CreateOptions{
+		Output:         output,
+		BinaryPath:     binary,
+		HomeopsVersion: "v1.2.3",
+		OS:             "linux",
+		Arch:           "amd64",
+		Tools: []config.ToolPin{
+			{Name: "kubectl", Version: "v1.36.2", URL: server.URL + "/kubectl/{version}/{os}/{arch}/kubectl",
+				SHA256: map[string]string{"linux/amd64": sha256Hex("kubectl-binary")}},
+			{Name: "helmfile", Version: "v1.1.0", URL: server.URL + "/helmfile/{version}/helmfile_1.1.0_{os}_{arch}.tar.gz",
+				ChecksumURL: server.URL + "/helmfile/{version}/checksums.txt"},
+		},
+		ISOCacheDir: cacheDir,
+		HTTPClient:  server.Client(),
+		Now:         time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+	return output, manifest
+}
+
+func TestCreateVerifyExtractRoundTrip(t *testing.T) {
+	output, manifest := createFixture(t)
+
+	require.Greater(t, len(manifest.Entries), 5)
+	assert.Equal(t, Entry{Path: "homeops-cli", Kind: KindBinary, Name: "homeops-cli", Version: "v1.2.3", Size: 14, SHA256: sha256Hex("homeops-binary")}, manifest.Entries[0])
+	assert.Equal(t, "tools/kubectl/kubectl", manifest.Entries[1].Path)
+	assert.Equal(t, "tools/helmfile/helmfile_1.1.0_linux_amd64.tar.gz", manifest.Entries[2].Path)
+	assert.Equal(t, sha256Hex("helmfile-archive"), manifest.Entries[2].SHA256)
+	assert.Equal(t, "isos/abcdef012345/metal-amd64.iso", manifest.Entries[3].Path)
+	assert.Equal(t, KindSchematic, manifest.Entries[4].Kind)
+	assert.Equal(t, KindTemplate, manifest.Entries[len(manifest.Entries)-1].Kind)
+
+	verified, err := Verify(output)
+	require.NoError(t, err)
+	assert.Equal(t, manifest.Entries, verified.Entries)
+
+	dir := filepath.Join(t.TempDir(), "dr")
+	_, err = Extract(output, dir)
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(dir, "tools", "kubectl", "kubectl"))
+	require.NoError(t, err)
+	assert.Equal(t, "kubectl-binary", string(data))
+	info, err := os.Stat(filepath.Join(dir, "tools", "kubectl", "kubectl"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+	_, err = os.Stat(filepath.Join(dir, "templates", "talos", "controlplane.yaml"))
+	require.NoError(t, err)
+	readme, err := os.ReadFile(filepath.Join(dir, ReadmeName))
+	require.NoError(t, err)
+	assert.Contains(t, string(readme), "1. Install the CLI and tools on PATH")
+	assert.Contains(t, string(readme), "unpack tools/helmfile/helmfile_1.1.0_linux_amd64.tar.gz")
+	assert.Contains(t, string(readme), "2. Upload a Talos ISO")
+	assert.Contains(t, string(readme), "| isos/abcdef012345/metal-amd64.iso | iso | v1.13.6 |")
+	_, err = os.Stat(filepath.Join(dir, ManifestName))
+	require.NoError(t, err)
+
+	_, err = Extract(output, dir)
+	require.ErrorContains(t, err, "is not empty")
+}
+
+func TestCreateRejectsBadDownloads(t *testing.T) {
+	server := artifactServer(t, map[string]string{"/kubectl": "tampered", "/sums": "no digest here\n"})
+	binary := filepath.Join(t.TempDir(), "homeops-cli")
+	require.NoError(t, os.WriteFile(binary, []byte("bin"), 0o755))
+	create := func(tool config.ToolPin) (string, error) {
+		output := filepath.Join(t.TempDir(), "out.tar.gz")
+		_, err := Create(context.Background(), CreateOptions{
+			Output: output, BinaryPath: binary, OS: "linux", Arch: "amd64",
+			Tools: []config.ToolPin{tool}, HTTPClient: server.Client(),
+		})
+		return output, err
+	}
+
+	output, err := create(config.ToolPin{Name: "kubectl", Version: "v1", URL: server.URL + "/kubectl", SHA256: map[string]string{"linux/amd64": sha256Hex("kubectl-binary")}})
+	require.ErrorContains(t, err, "kubectl: checksum mismatch for kubectl")
+	assert.NoFileExists(t, output, "nothing is written when a download fails verification")
+
+	_, err = create(config.ToolPin{Name: "kubectl", Version: "v1", URL: server.URL + "/kubectl", SHA256: map[string]string{"darwin/arm64": sha256Hex("x")}})
+	require.ErrorContains(t, err, "no sha256 for linux/amd64 and no checksum_url")
+
+	_, err = create(config.ToolPin{Name: "kubectl", Version: "v1", URL: server.URL + "/kubectl", ChecksumURL: server.URL + "/sums"})
+	require.ErrorContains(t, err, "no SHA-256 for kubectl in the checksum file")
+
+	_, err = create(config.ToolPin{Name: "kubectl", Version: "v1", URL: server.URL + "/missing", ChecksumURL: server.URL + "/sums"})
+	require.Error(t, err)
+
+	_, err = Create(context.Background(), CreateOptions{Output: filepath.Join(t.TempDir(), "x.tar.gz"), BinaryPath: binary})
+	require.ErrorContains(t, err, "no toolchain.tools are pinned")
+}
+
+func TestChecksumFor(t *testing.T) {
+	digest := sha256Hex("a")
+	got, err := checksumFor([]byte(digest+"\n"), "kubectl")
+	require.NoError(t, err)
+	assert.Equal(t, digest, got, "a bare digest file (kubectl.sha256) is accepted")
+
+	got, err = checksumFor([]byte(sha256Hex("b")+"  other\n"+digest+" *dist/op.zip\n"), "op.zip")
+	require.NoError(t, err)
+	assert.Equal(t, digest, got)
+
+	_, err = checksumFor([]byte("zz  op.zip\n"), "op.zip")
+	require.ErrorContains(t, err, "invalid SHA-256")
+}
+
+// rewriteBundle copies src to a new bundle, letting edit change each file.
+func rewriteBundle(t *testing.T, src string, edit func(name string, data []byte) []byte) string {
+	t.Helper()
+	in, err := os.Open(src)
+	require.NoError(t, err)
+	defer func() { _ = in.Close() }()
+	gz, err := gzip.NewReader(in)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+
+	dst := filepath.Join(t.TempDir(), "edited.tar.gz")
+	out, err := os.Create(dst)
+	require.NoError(t, err)
+	defer func() { _ = out.Close() }()
+	gzw := gzip.NewWriter(out)
+	tw := tar.NewWriter(gzw)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		if data = edit(header.Name, data); data == nil {
+			continue
+		}
+		header.Size = int64(len(data))
+		require.NoError(t, tw.WriteHeader(header))
+		_, err = tw.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gzw.Close())
+	return dst
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	output, _ := createFixture(t)
+
+	tampered := rewriteBundle(t, output, func(name string, data []byte) []byte {
+		if name == "tools/kubectl/kubectl" {
+			return []byte("kubectl-evil!!")
+		}
+		return data
+	})
+	_, err := Verify(tampered)
+	require.ErrorContains(t, err, "tools/kubectl/kubectl: checksum mismatch")
+	_, err = Extract(tampered, filepath.Join(t.TempDir(), "dr"))
+	require.ErrorContains(t, err, "failed verification", "extract refuses a bundle that does not verify")
+
+	missing := rewriteBundle(t, output, func(name string, data []byte) []byte {
+		if name == "homeops-cli" {
+			return nil
+		}
+		return data
+	})
+	_, err = Verify(missing)
+	require.ErrorContains(t, err, "homeops-cli: listed in BUNDLE-MANIFEST.json but missing")
+
+	notBundle := filepath.Join(t.TempDir(), "plain.tar.gz")
+	require.NoError(t, os.WriteFile(notBundle, []byte("nope"), 0o600))
+	_, err = Verify(notBundle)
+	require.Error(t, err)
+}
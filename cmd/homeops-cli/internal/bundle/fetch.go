@@ -0,0 +1,220 @@
+package bundle
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"homeops-cli/internal/config"
+	"homeops-cli/internal/talos"
+)
+
+// Download caps: tool and ISO artifacts, and checksum documents.
+const (
+	maxArtifactBytes = 2 << 30
+	maxChecksumBytes = 1 << 20
+)
+
+// NewHTTPClient returns the download client Create uses by default: a long
+// timeout for ISOs, and no redirects off HTTPS.
+func NewHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout: 30 * time.Minute,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("too many redirects")
+			}
+			if !secureURL(req.URL) {
+				return fmt.Errorf("refusing redirect to non-HTTPS URL %s", req.URL.Redacted())
+			}
+			return nil
+		},
+	}
+}
+
+// ExpandPin fills the {version}, {os} and {arch} placeholders in a pinned URL.
+func ExpandPin(raw, version, goos, goarch string) string {
+	return strings.NewReplacer("{version}", version, "{os}", goos, "{arch}", goarch).Replace(raw)
+}
+
+// fetchTool downloads one pinned tool into dir and checks it against the
+// pinned digest for the platform, or else the pin's checksum_url.
+func fetchTool(ctx context.Context, client *http.Client, tool config.ToolPin, goos, goarch, dir string) (staged, error) {
+	source := ExpandPin(tool.URL, tool.Version, goos, goarch)
+	artifact := artifactName(source)
+	if artifact == "" {
+		return staged{}, fmt.Errorf("%s: url %q does not name a file", tool.Name, source)
+	}
+	platform := goos + "/" + goarch
+	want := strings.ToLower(tool.SHA256[platform])
+	if want == "" {
+		if tool.ChecksumURL == "" {
+			return staged{}, fmt.Errorf("%s: no sha256 for %s and no checksum_url to verify the download", tool.Name, platform)
+		}
+		doc, err := fetchBytes(ctx, client, ExpandPin(tool.ChecksumURL, tool.Version, goos, goarch), maxChecksumBytes)
+		if err != nil {
+			return staged{}, fmt.Errorf("%s checksum: %w", tool.Name, err)
+		}
+		if want, err = checksumFor(doc, artifact); err != nil {
+			return staged{}, fmt.Errorf("%s checksum: %w", tool.Name, err)
+		}
+	}
+
+	local := filepath.Join(dir, "tool-"+tool.Name+"-"+artifact)
+	entry, err := download(ctx, client, source, local)
+	if err != nil {
+		return staged{}, fmt.Errorf("%s: %w", tool.Name, err)
+	}
+	if entry.SHA256 != want {
+		return staged{}, fmt.Errorf("%s: checksum mismatch for %s: expected %s, got %s", tool.Name, artifact, want, entry.SHA256)
+	}
+	entry.Path = path.Join("tools", tool.Name, artifact)
+	entry.Kind, entry.Name, entry.Version, entry.Source = KindTool, tool.Name, tool.Version, source
+	return staged{entry: entry, file: local}, nil
+}
+
+// fetchISO downloads a prepared Talos ISO. The image factory publishes no
+// digest, so the manifest records the one computed here.
+func fetchISO(ctx context.Context, client *http.Client, info talos.ISOInfo, dir string) (staged, error) {
+	artifact := artifactName(info.URL)
+	if artifact == "" {
+		return staged{}, fmt.Errorf("Talos ISO url %q does not name a file", info.URL)
+	}
+	id := shortID(info.SchematicID)
+	local := filepath.Join(dir, "iso-"+id+"-"+artifact)
+	entry, err := download(ctx, client, info.URL, local)
+	if err != nil {
+		return staged{}, fmt.Errorf("Talos ISO %s: %w", id, err)
+	}
+	entry.Path = path.Join("isos", id, artifact)
+	entry.Kind, entry.Name, entry.Version, entry.Source = KindISO, info.SchematicID, info.TalosVersion, info.URL
+	return staged{entry: entry, file: local}, nil
+}
+
+// checksumFor picks the digest for artifact out of a sha256sum-style document
+// ("<hex>  <name>" lines), or accepts a document holding a single bare digest.
+func checksumFor(doc []byte, artifact string) (string, error) {
+	var bare []string
+	for _, line := range strings.Split(string(doc), "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 1:
+			bare = append(bare, fields[0])
+		case len(fields) >= 2:
+			name := strings.TrimPrefix(fields[len(fields)-1], "*")
+			if name == artifact || path.Base(name) == artifact {
+				return validDigest(fields[0], artifact)
+			}
+		}
+	}
+	if len(bare) == 1 {
+		return validDigest(bare[0], artifact)
+	}
+	return "", fmt.Errorf("no SHA-256 for %s in the checksum file", artifact)
+}
+
+func validDigest(value, artifact string) (string, error) {
+	value = strings.ToLower(value)
+	if _, err := hex.DecodeString(value); err != nil || len(value) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid SHA-256 %q for %s", value, artifact)
+	}
+	return value, nil
+}
+
+func artifactName(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	name := path.Base(parsed.Path)
+	if name == "." || name == "/" {
+		return ""
+	}
+	return name
+}
+
+// download streams source into destination and returns its size and digest.
+func download(ctx context.Context, client *http.Client, source, destination string) (Entry, error) {
+	resp, err := get(ctx, client, source)
+	if err != nil {
+		return Entry{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	// #nosec G304 -- destination is a fixed name inside our private MkdirTemp directory.
+	file, err := os.OpenFile(destination, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return Entry{}, fmt.Errorf("create download: %w", err)
+	}
+	digest := sha256.New()
+	size, copyErr := io.Copy(io.MultiWriter(file, digest), io.LimitReader(resp.Body, maxArtifactBytes+1))
+	closeErr := file.Close()
+	switch {
+	case copyErr != nil:
+		return Entry{}, fmt.Errorf("download %s: %w", source, copyErr)
+	case closeErr != nil:
+		return Entry{}, fmt.Errorf("close download: %w", closeErr)
+	case size > maxArtifactBytes:
+		return Entry{}, fmt.Errorf("download %s exceeds %d bytes", source, maxArtifactBytes)
+	}
+	return Entry{Size: size, SHA256: hex.EncodeToString(digest.Sum(nil))}, nil
+}
+
+func fetchBytes(ctx context.Context, client *http.Client, source string, limit int64) ([]byte, error) {
+	resp, err := get(ctx, client, source)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", source, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s exceeds %d bytes", source, limit)
+	}
+	return data, nil
+}
+
+func get(ctx context.Context, client *http.Client, source string) (*http.Response, error) {
+	parsed, err := url.Parse(source)
+	if err != nil || !secureURL(parsed) {
+		return nil, fmt.Errorf("refusing non-HTTPS download URL %q", source)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request for %s: %w", source, err)
+	}
+	req.Header.Set("User-Agent", "homeops-cli-bundle")
+	// #nosec G107 -- pinned URLs are rejected unless HTTPS (or loopback-only in tests).
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", source, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("download %s: HTTP %s", source, resp.Status)
+	}
+	return resp, nil
+}
+
+// secureURL admits HTTPS, and plain HTTP only on literal loopback hosts so
+// tests can serve artifacts from httptest.
+func secureURL(parsed *url.URL) bool {
+	if parsed == nil {
+		return false
+	}
+	if strings.EqualFold(parsed.Scheme, "https") {
+		return true
+	}
+	host := parsed.Hostname()
+	return strings.EqualFold(parsed.Scheme, "http") && (host == "127.0.0.1" || host == "::1" || host == "localhost")
+}
@@ -0,0 +1,60 @@
+package bundle
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RenderReadme describes the bundle's contents in recovery order.
+func RenderReadme(m *Manifest) string {
+	byKind := map[string][]Entry{}
+	for _, entry := range m.Entries {
+		byKind[entry.Kind] = append(byKind[entry.Kind], entry)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "# homeops disaster-recovery bundle\n\n")
+	fmt.Fprintf(&b, "Created %s by homeops-cli %s for %s/%s. Every file's SHA-256 is in %s;\n",
+		m.CreatedAt.Format("2006-01-02 15:04 MST"), m.HomeopsVersion, m.OS, m.Arch, ManifestName)
+	fmt.Fprintf(&b, "`homeops-cli bundle verify <bundle.tar.gz>` re-checks the archive.\n\n")
+	fmt.Fprintf(&b, "## Recovery order\n\n")
+
+	step := 1
+	fmt.Fprintf(&b, "%d. Install the CLI and tools on PATH:\n\n", step)
+	fmt.Fprintf(&b, "       install -m 0755 %s ~/.local/bin/\n\n", BinaryName)
+	for _, entry := range byKind[KindTool] {
+		how := "install -m 0755 " + entry.Path + " ~/.local/bin/" + entry.Name
+		if isArchive(entry.Path) {
+			how = "unpack " + entry.Path + " and put " + entry.Name + " on PATH"
+		}
+		fmt.Fprintf(&b, "   - %s %s: %s\n", entry.Name, entry.Version, how)
+	}
+	b.WriteString("\n")
+	step++
+
+	if isos := byKind[KindISO]; len(isos) > 0 {
+		fmt.Fprintf(&b, "%d. Upload a Talos ISO to the hypervisor and boot the replacement nodes from it:\n\n", step)
+		for _, entry := range isos {
+			fmt.Fprintf(&b, "   - %s (Talos %s, schematic %s)\n", entry.Path, entry.Version, entry.Name)
+		}
+		b.WriteString("\n")
+		step++
+	} else if len(byKind[KindSchematic]) > 0 {
+		fmt.Fprintf(&b, "%d. The bundle holds schematic state but no ISOs; regenerate them with\n   `homeops-cli talos prepare-iso` once the image factory is reachable.\n\n", step)
+		step++
+	}
+
+	fmt.Fprintf(&b, "%d. Point homeops.yaml at the exported templates so renders match this bundle:\n\n", step)
+	fmt.Fprintf(&b, "       templates:\n         dir: <this directory>/templates\n\n")
+	step++
+	fmt.Fprintf(&b, "%d. Restore the home-ops repository, then run `homeops-cli config doctor`\n   followed by `homeops-cli bootstrap`.\n\n", step)
+
+	fmt.Fprintf(&b, "## Contents\n\n")
+	fmt.Fprintf(&b, "| Path | Kind | Version | SHA-256 |\n|---|---|---|---|\n")
+	for _, entry := range m.Entries {
+		if entry.Kind != KindTemplate {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", entry.Path, entry.Kind, entry.Version, entry.SHA256)
+		}
+	}
+	fmt.Fprintf(&b, "\nPlus %d embedded template(s) under templates/, listed in %s.\n", len(byKind[KindTemplate]), ManifestName)
+	return b.String()
+}
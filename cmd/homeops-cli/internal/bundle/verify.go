@@ -0,0 +1,170 @@
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Verify checks that every file in the bundle matches its manifest entry and
+// that nothing is missing or unlisted.
+func Verify(bundlePath string) (*Manifest, error) {
+	return scan(bundlePath, nil)
+}
+
+// Extract verifies the bundle, then unpacks it into dir (which must be empty
+// or absent) together with the manifest and a README describing the recovery
+// order.
+func Extract(bundlePath, dir string) (*Manifest, error) {
+	if _, err := Verify(bundlePath); err != nil {
+		return nil, err
+	}
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("%s is not empty; extract into a new directory", dir)
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read %s: %w", dir, err)
+	}
+	manifest, err := scan(bundlePath, func(entry Entry, content io.Reader) error {
+		return writeFile(filepath.Join(dir, filepath.FromSlash(entry.Path)), os.FileMode(entryMode(entry)), content)
+	})
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encode bundle manifest: %w", err)
+	}
+	if err := writeFile(filepath.Join(dir, ManifestName), 0o644, strings.NewReader(string(raw)+"\n")); err != nil {
+		return nil, err
+	}
+	if err := writeFile(filepath.Join(dir, ReadmeName), 0o644, strings.NewReader(RenderReadme(manifest))); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// scan reads the manifest (the bundle's first file), passes each listed file
+// to visit when it is non-nil, and checks every file's size and SHA-256.
+func scan(bundlePath string, visit func(Entry, io.Reader) error) (*Manifest, error) {
+	file, err := os.Open(bundlePath) // #nosec G304 -- bundlePath is the operator-named bundle to read
+	if err != nil {
+		return nil, fmt.Errorf("open bundle: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("open bundle gzip: %w", err)
+	}
+	defer func() { _ = gz.Close() }()
+	tr := tar.NewReader(gz)
+
+	header, err := tr.Next()
+	if err != nil || header.Name != ManifestName {
+		return nil, fmt.Errorf("%s is not a homeops bundle: %s must be its first file", bundlePath, ManifestName)
+	}
+	var manifest Manifest
+	if err := json.NewDecoder(io.LimitReader(tr, maxChecksumBytes*16)).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", ManifestName, err)
+	}
+	if manifest.FormatVersion != FormatVersion {
+		return nil, fmt.Errorf("bundle format version %d is not supported (want %d)", manifest.FormatVersion, FormatVersion)
+	}
+	listed := make(map[string]Entry, len(manifest.Entries))
+	for _, entry := range manifest.Entries {
+		if !safeName(entry.Path) {
+			return nil, fmt.Errorf("%s lists unsafe path %q", ManifestName, entry.Path)
+		}
+		listed[entry.Path] = entry
+	}
+
+	var problems []string
+	seen := map[string]bool{}
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read bundle: %w", err)
+		}
+		entry, ok := listed[header.Name]
+		switch {
+		case header.Typeflag != tar.TypeReg:
+			problems = append(problems, fmt.Sprintf("%s: not a regular file", header.Name))
+			continue
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s: not listed in %s", header.Name, ManifestName))
+			continue
+		case seen[header.Name]:
+			problems = append(problems, fmt.Sprintf("%s: appears more than once", header.Name))
+			continue
+		}
+		seen[header.Name] = true
+		digest := sha256.New()
+		var size byteCounter
+		content := io.TeeReader(tr, io.MultiWriter(digest, &size))
+		if visit != nil {
+			if err := visit(entry, content); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := io.Copy(io.Discard, content); err != nil {
+			return nil, fmt.Errorf("read %s: %w", header.Name, err)
+		}
+		if got := hex.EncodeToString(digest.Sum(nil)); got != entry.SHA256 || int64(size) != entry.Size {
+			problems = append(problems, fmt.Sprintf("%s: checksum mismatch (manifest %s, bundle %s)", header.Name, entry.SHA256, got))
+		}
+	}
+	for _, entry := range manifest.Entries {
+		if !seen[entry.Path] {
+			problems = append(problems, fmt.Sprintf("%s: listed in %s but missing", entry.Path, ManifestName))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("bundle %s failed verification:\n  %s", bundlePath, strings.Join(problems, "\n  "))
+	}
+	return &manifest, nil
+}
+
+// byteCounter counts the bytes written to it.
+type byteCounter int64
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
+
+// safeName admits clean relative slash paths that stay inside the bundle.
+func safeName(name string) bool {
+	return name != "" && path.Clean(name) == name && !path.IsAbs(name) &&
+		name != ".." && !strings.HasPrefix(name, "../") && name != ManifestName && name != ReadmeName
+}
+
+func writeFile(name string, mode os.FileMode, content io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o750); err != nil {
+		return fmt.Errorf("create %s: %w", filepath.Dir(name), err)
+	}
+	// #nosec G304 -- name is a manifest path checked by safeName, joined under the extract directory.
+	out, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", name, err)
+	}
+	_, copyErr := io.Copy(out, content)
+	closeErr := out.Close()
+	if copyErr != nil {
+		return fmt.Errorf("write %s: %w", name, copyErr)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("close %s: %w", name, closeErr)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+// Package cloudflare is a minimal typed wrapper around the Cloudflare v4 REST
+// API covering the DNS record reads and deletes used by `k8s dns-report`.
+package cloudflare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the Cloudflare v4 API root.
+const DefaultBaseURL = "https://api.cloudflare.com/client/v4"
+
+// recordsPerPage is the largest page size the DNS records endpoint accepts.
+const recordsPerPage = 1000
+
+// maxResponseBytes bounds how much of a single API response is read.
+const maxResponseBytes = 32 << 20
+
+// Zone is a Cloudflare DNS zone.
+type Zone struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// DNSRecord is a single DNS record within a zone.
+type DNSRecord struct {
+	ID      string `json:"id"`
+	ZoneID  string `json:"zone_id,omitempty"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Content string `json:"content"`
+	Proxied bool   `json:"proxied"`
+	TTL     int    `json:"ttl"`
+}
+
+// APIError is one entry of the errors array Cloudflare returns.
+type APIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type resultInfo struct {
+	Page       int `json:"page"`
+	PerPage    int `json:"per_page"`
+	TotalPages int `json:"total_pages"`
+}
+
+type envelope struct {
+	Success    bool            `json:"success"`
+	Errors     []APIError      `json:"errors"`
+	Result     json.RawMessage `json:"result"`
+	ResultInfo *resultInfo     `json:"result_info"`
+}
+
+// Client talks to the Cloudflare API with a scoped API token.
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewClient creates a client for the public Cloudflare API.
+func NewClient(token string) *Client {
+	return NewClientWithBaseURL(DefaultBaseURL, token, &http.Client{Timeout: 30 * time.Second})
+}
+
+// NewClientWithBaseURL creates a client against an alternate API root (used
+// by tests with an httptest server).
+func NewClientWithBaseURL(baseURL, token string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Client{baseURL: strings.TrimRight(baseURL, "/"), token: token, httpClient: httpClient}
+}
+
+// ZoneByName resolves a zone name (e.g. example.com) to its zone object.
+func (c *Client) ZoneByName(ctx context.Context, name string) (Zone, error) {
+	query := url.Values{"name": {name}}
+	var zones []Zone
+	if _, err := c.do(ctx, http.MethodGet, "/zones", query, &zones); err != nil {
+		return Zone{}, fmt.Errorf("look up zone %s: %w", name, err)
+	}
+	for _, zone := range zones {
+		if strings.EqualFold(zone.Name, name) {
+			return zone, nil
+		}
+	}
+	return Zone{}, fmt.Errorf("zone %s not found (check the token has Zone:Read access)", name)
+}
+
+// ListDNSRecords returns every DNS record in the zone, following pagination.
+func (c *Client) ListDNSRecords(ctx context.Context, zoneID string) ([]DNSRecord, error) {
+	var all []DNSRecord
+	for page := 1; ; page++ {
+		query := url.Values{
+			"page":     {fmt.Sprintf("%d", page)},
+			"per_page": {fmt.Sprintf("%d", recordsPerPage)},
+		}
+		var records []DNSRecord
+		info, err := c.do(ctx, http.MethodGet, "/zones/"+url.PathEscape(zoneID)+"/dns_records", query, &records)
+		if err != nil {
+			return nil, fmt.Errorf("list DNS records for zone %s: %w", zoneID, err)
+		}
+		for i := range records {
+			if records[i].ZoneID == "" {
+				records[i].ZoneID = zoneID
+			}
+		}
+		all = append(all, records...)
+		if info == nil || info.TotalPages <= page || len(records) == 0 {
+			return all, nil
+		}
+	}
+}
+
+// DeleteDNSRecord removes a single record from the zone.
+func (c *Client) DeleteDNSRecord(ctx context.Context, zoneID, recordID string) error {
+	path := "/zones/" + url.PathEscape(zoneID) + "/dns_records/" + url.PathEscape(recordID)
+	if _, err := c.do(ctx, http.MethodDelete, path, nil, nil); err != nil {
+		return fmt.Errorf("delete DNS record %s: %w", recordID, err)
+	}
+	return nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, result any) (*resultInfo, error) {
+	endpoint := c.baseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	var env envelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		return nil, fmt.Errorf("HTTP %d: unparseable response", resp.StatusCode)
+	}
+	if resp.StatusCode >= 300 || !env.Success {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, formatAPIErrors(env.Errors))
+	}
+	if result != nil && len(env.Result) > 0 {
+		if err := json.Unmarshal(env.Result, result); err != nil {
+			return nil, fmt.Errorf("parse result: %w", err)
+		}
+	}
+	return env.ResultInfo, nil
+}
+
+func formatAPIErrors(errs []APIError) string {
+	if len(errs) == 0 {
+		return "request failed"
+	}
+	parts := make([]string, 0, len(errs))
+	for _, e := range errs {
+		parts = append(parts, fmt.Sprintf("%d %s", e.Code, e.Message))
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,112 @@
+package cloudflare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeEnvelope(t *testing.T, w http.ResponseWriter, status int, result any, info *resultInfo, errs ...APIError) {
+	t.Helper()
+	raw, err := json.Marshal(result)
+	require.NoError(t, err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	require.NoError(t, json.NewEncoder(w).Encode(envelope{
+		Success: status < 300 && len(errs) == 0, Errors: errs, Result: raw, ResultInfo: info,
+	}))
+}
+
+func TestZoneByNameSendsBearerTokenAndMatchesName(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token-123", r.Header.Get("Authorization"))
+		assert.Equal(t, "/zones", r.URL.Path)
+		assert.Equal(t, "example.com", r.URL.Query().Get("name"))
+		writeEnvelope(t, w, http.StatusOK, []Zone{{ID: "zone-1", Name: "example.com"}}, nil)
+	}))
+	defer server.Close()
+
+	zone, err := NewClientWithBaseURL(server.URL, "token-123", server.Client()).ZoneByName(context.Background(), "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, Zone{ID: "zone-1", Name: "example.com"}, zone)
+}
+
+func TestZoneByNameNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeEnvelope(t, w, http.StatusOK, []Zone{}, nil)
+	}))
+	defer server.Close()
+
+	_, err := NewClientWithBaseURL(server.URL, "t", server.Client()).ZoneByName(context.Background(), "missing.test")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "zone missing.test not found")
+}
+
+func TestListDNSRecordsFollowsPagination(t *testing.T) {
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/zones/zone-1/dns_records", r.URL.Path)
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		switch page {
+		case "1":
+			writeEnvelope(t, w, http.StatusOK, []DNSRecord{{ID: "r1", Name: "a.example.com", Type: "CNAME"}},
+				&resultInfo{Page: 1, PerPage: 1, TotalPages: 2})
+		case "2":
+			writeEnvelope(t, w, http.StatusOK, []DNSRecord{{ID: "r2", Name: "k8s.cname-a.example.com", Type: "TXT"}},
+				&resultInfo{Page: 2, PerPage: 1, TotalPages: 2})
+		default:
+			t.Fatalf("unexpected page %s", page)
+		}
+	}))
+	defer server.Close()
+
+	records, err := NewClientWithBaseURL(server.URL, "t", server.Client()).ListDNSRecords(context.Background(), "zone-1")
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, []string{"1", "2"}, pages)
+	assert.Equal(t, "zone-1", records[1].ZoneID, "zone id is backfilled when the API omits it")
+}
+
+func TestDeleteDNSRecordUsesDeleteVerb(t *testing.T) {
+	var method, path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		writeEnvelope(t, w, http.StatusOK, map[string]string{"id": "r1"}, nil)
+	}))
+	defer server.Close()
+
+	require.NoError(t, NewClientWithBaseURL(server.URL, "t", server.Client()).DeleteDNSRecord(context.Background(), "zone-1", "r1"))
+	assert.Equal(t, http.MethodDelete, method)
+	assert.Equal(t, "/zones/zone-1/dns_records/r1", path)
+}
+
+func TestAPIErrorsAreSurfaced(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeEnvelope(t, w, http.StatusForbidden, nil, nil, APIError{Code: 9109, Message: "Invalid access token"})
+	}))
+	defer server.Close()
+
+	_, err := NewClientWithBaseURL(server.URL, "bad", server.Client()).ListDNSRecords(context.Background(), "zone-1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "HTTP 403")
+	assert.Contains(t, err.Error(), "9109 Invalid access token")
+}
+
+func TestUnparseableResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = fmt.Fprint(w, "<html>bad gateway</html>")
+	}))
+	defer server.Close()
+
+	_, err := NewClientWithBaseURL(server.URL, "t", server.Client()).ZoneByName(context.Background(), "example.com")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "HTTP 502: unparseable response")
+}
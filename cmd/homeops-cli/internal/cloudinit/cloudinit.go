@@ -1,6 +1,7 @@
 // Package cloudinit builds cloud-init payloads (user-data, meta-data,
 // network-config) and NoCloud seed ISOs for providers without a native
-// cloud-init drive (TrueNAS) or with guestinfo delivery (vSphere).
+// cloud-init drive (TrueNAS) or with guestinfo delivery (vSphere), plus the
+// Talos metal-iso config volume that serves the same purpose for Talos VMs.
 package cloudinit
 
 import (
@@ -96,13 +97,40 @@ func VSphereMetadata(name, ipCIDR, gateway string, nameservers []string) (string
 	return string(raw), nil
 }
 
-// seedISOSize is the fixed image size for NoCloud seeds; the three text
-// files are tiny, and ISO9660 metadata fits comfortably in 2MiB.
+// seedISOSize is the minimum image size; the NoCloud text files and a Talos
+// machine config are tiny, and ISO9660 metadata fits comfortably in 2MiB.
 const seedISOSize = 2 << 20
 
+// TalosConfigVolumeID is the volume label Talos' metal platform searches
+// for when booted with talos.config=metal-iso; it reads /config.yaml from
+// the first volume carrying it.
+const TalosConfigVolumeID = "metal-iso"
+
 // BuildNoCloudSeedISO assembles a NoCloud seed ISO (volume label "cidata")
 // holding user-data, meta-data, and optionally network-config.
 func BuildNoCloudSeedISO(userdata, metadata, networkConfig string) ([]byte, error) {
+	files := map[string][]byte{
+		"/user-data": []byte(userdata),
+		"/meta-data": []byte(metadata),
+	}
+	if networkConfig != "" {
+		files["/network-config"] = []byte(networkConfig)
+	}
+	return buildISO("cidata", files)
+}
+
+// BuildTalosConfigISO assembles the config volume a Talos node booted with
+// talos.config=metal-iso applies on first boot: machineConfig as
+// /config.yaml on a volume labelled metal-iso.
+func BuildTalosConfigISO(machineConfig []byte) ([]byte, error) {
+	if len(machineConfig) == 0 {
+		return nil, fmt.Errorf("machine config is empty")
+	}
+	return buildISO(TalosConfigVolumeID, map[string][]byte{"/config.yaml": machineConfig})
+}
+
+// buildISO writes files into a Rock Ridge ISO9660 image labelled volumeID.
+func buildISO(volumeID string, files map[string][]byte) ([]byte, error) {
 	tmpDir, err := os.MkdirTemp("", "homeops-seed")
 	if err != nil {
 		return nil, fmt.Errorf("create seed workspace: %w", err)
@@ -116,37 +144,37 @@ func BuildNoCloudSeedISO(userdata, metadata, networkConfig string) ([]byte, erro
 	if err := os.Mkdir(workDir, 0o750); err != nil {
 		return nil, fmt.Errorf("create seed workspace: %w", err)
 	}
+	size := int64(seedISOSize)
+	for _, content := range files {
+		size += int64(len(content))
+	}
+	size = (size + 2047) / 2048 * 2048
 	imgPath := filepath.Join(tmpDir, "seed.iso")
-	storage, err := file.CreateFromPath(imgPath, seedISOSize)
+	storage, err := file.CreateFromPath(imgPath, size)
 	if err != nil {
 		return nil, fmt.Errorf("create seed image: %w", err)
 	}
-	// 2048 is the canonical ISO9660 blocksize; cloud-init mounts the seed
-	// with the kernel iso9660 driver, which expects it.
-	fs, err := iso9660.Create(storage, seedISOSize, 0, 2048, workDir)
+	// 2048 is the canonical ISO9660 blocksize; cloud-init and Talos mount
+	// the volume with the kernel iso9660 driver, which expects it.
+	fs, err := iso9660.Create(storage, size, 0, 2048, workDir)
 	if err != nil {
 		return nil, fmt.Errorf("create seed filesystem: %w", err)
 	}
 
-	files := map[string]string{
-		"/user-data": userdata,
-		"/meta-data": metadata,
-	}
-	if networkConfig != "" {
-		files["/network-config"] = networkConfig
-	}
 	for name, content := range files {
 		f, err := fs.OpenFile(name, os.O_CREATE|os.O_RDWR)
 		if err != nil {
 			return nil, fmt.Errorf("create %s in seed: %w", name, err)
 		}
-		if _, err := f.Write([]byte(content)); err != nil {
+		if _, err := f.Write(content); err != nil {
 			return nil, fmt.Errorf("write %s in seed: %w", name, err)
 		}
 	}
 
-	// Rock Ridge keeps the lowercase hyphenated NoCloud filenames intact.
-	if err := fs.Finalize(iso9660.FinalizeOptions{RockRidge: true, VolumeIdentifier: "cidata"}); err != nil {
+	// Rock Ridge keeps lowercase hyphenated filenames (user-data,
+	// config.yaml) intact. ECMA-119 pads the 32-byte volume identifier with
+	// spaces; go-diskfs would leave NULs, which not every label prober trims.
+	if err := fs.Finalize(iso9660.FinalizeOptions{RockRidge: true, VolumeIdentifier: fmt.Sprintf("%-32s", volumeID)}); err != nil {
 		return nil, fmt.Errorf("finalize seed ISO: %w", err)
 	}
 	if err := storage.Close(); err != nil {
@@ -3,9 +3,14 @@ package cloudinit
 import (
 	"encoding/base64"
 	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/diskfs/go-diskfs/backend/file"
+	"github.com/diskfs/go-diskfs/filesystem/iso9660"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v3"
@@ -90,3 +95,28 @@ func TestBuildNoCloudSeedISO(t *testing.T) {
 	assert.Contains(t, string(iso[32768:34816]), "cidata")
 	assert.Contains(t, string(iso), "hostname: dev0")
 }
+
+func TestBuildTalosConfigISO(t *testing.T) {
+	// Larger than the 2MiB floor, so the image has to grow to fit.
+	machineConfig := []byte("version: v1alpha1\nmachine:\n  type: controlplane\n# " + strings.Repeat("x", 3<<20) + "\n")
+	image, err := BuildTalosConfigISO(machineConfig)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "config.iso")
+	require.NoError(t, os.WriteFile(path, image, 0o600))
+	storage, err := file.OpenFromPath(path, true)
+	require.NoError(t, err)
+	defer func() { _ = storage.Close() }()
+	fs, err := iso9660.Read(storage, int64(len(image)), 0, 2048)
+	require.NoError(t, err)
+	assert.Equal(t, "metal-iso", strings.TrimSpace(fs.Label()))
+
+	f, err := fs.OpenFile("/config.yaml", os.O_RDONLY)
+	require.NoError(t, err)
+	got, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, machineConfig, got, "Rock Ridge keeps the lowercase name and the content is byte-identical")
+
+	_, err = BuildTalosConfigISO(nil)
+	require.ErrorContains(t, err, "empty")
+}
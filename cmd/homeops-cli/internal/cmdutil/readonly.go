@@ -0,0 +1,106 @@
+package cmdutil
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// readOnlyLeaves are command names that only inspect state wherever they
+// appear (every hypervisor's vm subtree, for example).
+var readOnlyLeaves = map[string]bool{
+	"list":          true,
+	"list-all":      true,
+	"info":          true,
+	"ip":            true,
+	"status":        true,
+	"show":          true,
+	"console-log":   true,
+	"host-topology": true,
+	"usage":         true,
+	"help":          true,
+	"completion":    true,
+	"version":       true,
+}
+
+// readOnlyCommands are command paths below the root that only inspect state.
+// A value of nil means always; otherwise the invocation is read-only when the
+// func agrees, for commands with a mutating mode behind a flag or argument.
+var readOnlyCommands = map[string]func(cmd *cobra.Command, args []string) bool{
+	"":                        nil, // the interactive menu dispatches to other commands
+	"audit tail":              nil,
+	"bundle verify":           nil,
+	"config doctor":           nil,
+	"config lint-templates":   nil,
+	"flatcar gen-kubeadm":     nil,
+	"flatcar os-status":       nil,
+	"flatcar render-ignition": nil,
+	"k8s certs":               unlessFlags("renew"),
+	"k8s diff":                nil,
+	"k8s dns-report":          nil,
+	"k8s doctor":              nil,
+	"k8s drain-impact":        nil,
+	"k8s flux-tree":           nil,
+	"k8s gateway-status":      nil,
+	"k8s gen-externalsecret":  unlessFlags("apply", "write-to-repo"),
+	"k8s net-doctor":          nil,
+	"k8s object-report":       nil,
+	"k8s pressure-report":     nil,
+	"k8s preview":             nil,
+	"k8s render-ks":           nil,
+	"k8s right-size":          unlessFlags("write"),
+	"k8s rollout-lag":         nil,
+	"k8s sops verify":         nil,
+	"k8s storage-report":      nil,
+	"k8s support-bundle":      nil,
+	"k8s tls-probe":           nil,
+	"k8s upgrade-plan set":    unlessFlags("write"),
+	"k8s upgrade-status":      nil,
+	"k8s view-secret":         nil,
+	"op audit":                nil,
+	"op get":                  nil,
+	"op reveal":               nil,
+	"plugins list":            nil,
+	"talos check-ip":          nil,
+	"talos encryption-status": nil,
+	"talos health":            nil,
+	"version check":           nil,
+	"volsync audit":           nil,
+	"volsync snapshots":       nil,
+	"volsync state":           func(_ *cobra.Command, args []string) bool { return len(args) == 0 },
+}
+
+// ReadOnly reports whether an invocation only inspects state: a listed read-only
+// command, or any command run with --dry-run or --plan. Everything else counts
+// as mutating, so a new command is treated as mutating until it is listed here.
+func ReadOnly(cmd *cobra.Command, args []string) bool {
+	if cmd == nil {
+		return true
+	}
+	if flagTrue(cmd, "dry-run") || flagTrue(cmd, "plan") {
+		return true
+	}
+	if readOnlyLeaves[cmd.Name()] {
+		return true
+	}
+	path := strings.TrimPrefix(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()), " ")
+	check, ok := readOnlyCommands[path]
+	return ok && (check == nil || check(cmd, args))
+}
+
+// unlessFlags is read-only unless any of the named mutating flags is set.
+func unlessFlags(names ...string) func(*cobra.Command, []string) bool {
+	return func(cmd *cobra.Command, _ []string) bool {
+		for _, name := range names {
+			if flagTrue(cmd, name) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func flagTrue(cmd *cobra.Command, name string) bool {
+	flag := cmd.Flags().Lookup(name)
+	return flag != nil && flag.Changed && flag.Value.String() == "true"
+}
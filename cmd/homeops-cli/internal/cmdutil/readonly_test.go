@@ -0,0 +1,42 @@
+package cmdutil
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadOnly(t *testing.T) {
+	root := &cobra.Command{Use: "homeops-cli"}
+	k8s := &cobra.Command{Use: "k8s"}
+	certs := &cobra.Command{Use: "certs"}
+	certs.Flags().Bool("renew", false, "")
+	k8s.AddCommand(certs)
+	volsync := &cobra.Command{Use: "volsync"}
+	state := &cobra.Command{Use: "state"}
+	volsync.AddCommand(state)
+	vm := &cobra.Command{Use: "vm"}
+	proxmox := &cobra.Command{Use: "proxmox"}
+	list := &cobra.Command{Use: "list"}
+	proxmox.AddCommand(list)
+	vm.AddCommand(proxmox)
+	talos := &cobra.Command{Use: "talos"}
+	reset := &cobra.Command{Use: "reset-node"}
+	reset.Flags().Bool("dry-run", false, "")
+	talos.AddCommand(reset)
+	root.AddCommand(k8s, volsync, vm, talos)
+
+	assert.True(t, ReadOnly(nil, nil))
+	assert.True(t, ReadOnly(root, nil))
+	assert.True(t, ReadOnly(list, nil), "leaf names are read-only under any parent")
+	assert.True(t, ReadOnly(certs, nil))
+	assert.True(t, ReadOnly(state, nil))
+	assert.False(t, ReadOnly(state, []string{"suspend"}), "volsync state with an action mutates")
+	assert.False(t, ReadOnly(reset, nil), "unlisted commands are mutating")
+
+	assert.NoError(t, certs.Flags().Set("renew", "true"))
+	assert.False(t, ReadOnly(certs, nil))
+	assert.NoError(t, reset.Flags().Set("dry-run", "true"))
+	assert.True(t, ReadOnly(reset, nil))
+}
@@ -0,0 +1,14 @@
+package cmdutil
+
+import (
+	"homeops-cli/internal/truenas"
+
+	"github.com/spf13/pflag"
+)
+
+// AddTrueNASTLSFlags registers --truenas-ca and --insecure, which set
+// truenas.TLSOverrides for every TrueNAS connection the command makes.
+func AddTrueNASTLSFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&truenas.TLSOverrides.CAFile, "truenas-ca", "", "PEM CA bundle to trust for the TrueNAS API, e.g. the NAS's self-signed certificate (default: $TRUENAS_CA)")
+	flags.BoolVar(&truenas.TLSOverrides.InsecureSkipVerify, "insecure", false, "Skip TLS certificate verification of the TrueNAS API (default: $TRUENAS_INSECURE)")
+}
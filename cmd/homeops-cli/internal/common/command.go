@@ -161,8 +161,10 @@ func redactCommandOutput(output string, redactor Redactor) string {
 	return RedactCommandOutput(output)
 }
 
-// RedactCommandOutput conservatively masks obvious secret-labeled values.
+// RedactCommandOutput masks registered secret values (see RegisterSecret) and
+// conservatively masks obvious secret-labeled values.
 func RedactCommandOutput(output string) string {
+	output = RedactSecrets(output)
 	output = privateKeyBlockPattern.ReplaceAllString(output, "<redacted private key>")
 	output = secretLabelPattern.ReplaceAllString(output, "${1}${2}<redacted>")
 	output = kubeconfigClientDataPattern.ReplaceAllString(output, "${1}<redacted>")
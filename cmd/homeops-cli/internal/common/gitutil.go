@@ -1,9 +1,12 @@
 package common
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"homeops-cli/internal/constants"
 )
 
 // FindGitRoot walks up the directory tree to find the git repository root
@@ -32,16 +35,83 @@ func FindGitRoot(startDir string) (string, error) {
 	}
 }
 
-// GetWorkingDirectory returns the git repository root if possible, otherwise the current directory
-func GetWorkingDirectory() string {
-	gitRoot, err := FindGitRoot(".")
+// ErrRepoRootNotFound reports that no home-ops checkout could be located for a
+// repo-relative feature (version plans, template overrides, files written into
+// the repo).
+var ErrRepoRootNotFound = errors.New("home-ops repository root not found")
+
+// rootDirOverride holds the global --root-dir flag.
+var rootDirOverride string
+
+// SetRootDirOverride records the global --root-dir flag. It takes precedence
+// over HOMEOPS_ROOT and directory discovery; an empty value clears it.
+func SetRootDirOverride(dir string) {
+	rootDirOverride = dir
+}
+
+// FindRepoRoot walks up from startDir to the nearest directory containing a
+// .git entry (directory or worktree file) or a homeops.yaml marker.
+func FindRepoRoot(startDir string) (string, error) {
+	currentDir, err := filepath.Abs(startDir)
 	if err != nil {
-		// Fallback to current directory if git root not found
-		cwd, err := os.Getwd()
+		return "", fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	for {
+		for _, marker := range []string{".git", "homeops.yaml"} {
+			if _, err := os.Stat(filepath.Join(currentDir, marker)); err == nil {
+				return currentDir, nil
+			}
+		}
+		parentDir := filepath.Dir(currentDir)
+		if parentDir == currentDir {
+			return "", ErrRepoRootNotFound
+		}
+		currentDir = parentDir
+	}
+}
+
+// RepoRoot resolves the repository root for repo-relative features: the
+// --root-dir flag, then HOMEOPS_ROOT, then FindRepoRoot from the current
+// directory. An explicit override must name an existing directory.
+func RepoRoot() (string, error) {
+	for _, override := range []struct{ source, dir string }{
+		{"--root-dir", rootDirOverride},
+		{constants.EnvHomeOpsRoot, os.Getenv(constants.EnvHomeOpsRoot)},
+	} {
+		if override.dir == "" {
+			continue
+		}
+		dir, err := filepath.Abs(override.dir)
 		if err != nil {
-			return "." // Ultimate fallback
+			return "", fmt.Errorf("invalid %s %q: %w", override.source, override.dir, err)
 		}
-		return cwd
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return "", fmt.Errorf("%s %q is not a directory", override.source, override.dir)
+		}
+		return dir, nil
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get current directory: %w", err)
+	}
+	root, err := FindRepoRoot(cwd)
+	if err != nil {
+		return "", fmt.Errorf("%w: no .git or homeops.yaml found in %s or its parents; run from inside the repository, pass --root-dir, or set %s",
+			err, cwd, constants.EnvHomeOpsRoot)
+	}
+	return root, nil
+}
+
+// GetWorkingDirectory returns RepoRoot, or the current directory when there is
+// none. Only repo-independent features that merely prefer repository files may
+// use it; repo-relative features call RepoRoot and surface its error.
+func GetWorkingDirectory() string {
+	if root, err := RepoRoot(); err == nil {
+		return root
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "." // Ultimate fallback
 	}
-	return gitRoot
+	return cwd
 }
@@ -0,0 +1,81 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"homeops-cli/internal/constants"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resolvedPath(t *testing.T, path string) string {
+	t.Helper()
+	resolved, err := filepath.EvalSymlinks(path)
+	require.NoError(t, err)
+	return resolved
+}
+
+func TestRepoRootFromSubdirectory(t *testing.T) {
+	t.Setenv(constants.EnvHomeOpsRoot, "")
+	for _, marker := range []string{".git", "homeops.yaml"} {
+		t.Run(marker, func(t *testing.T) {
+			repo := t.TempDir()
+			if marker == ".git" {
+				require.NoError(t, os.Mkdir(filepath.Join(repo, marker), 0o755))
+			} else {
+				require.NoError(t, os.WriteFile(filepath.Join(repo, marker), nil, 0o600))
+			}
+			nested := filepath.Join(repo, "kubernetes", "apps", "media")
+			require.NoError(t, os.MkdirAll(nested, 0o755))
+			t.Chdir(nested)
+
+			root, err := RepoRoot()
+			require.NoError(t, err)
+			assert.Equal(t, resolvedPath(t, repo), resolvedPath(t, root))
+			assert.Equal(t, root, GetWorkingDirectory())
+		})
+	}
+}
+
+func TestRepoRootOutsideRepository(t *testing.T) {
+	t.Setenv(constants.EnvHomeOpsRoot, "")
+	outside := t.TempDir()
+	t.Chdir(outside)
+
+	_, err := RepoRoot()
+	require.ErrorIs(t, err, ErrRepoRootNotFound)
+	assert.Contains(t, err.Error(), "pass --root-dir, or set HOMEOPS_ROOT")
+	assert.Equal(t, resolvedPath(t, outside), resolvedPath(t, GetWorkingDirectory()), "repo-independent callers fall back to the current directory")
+}
+
+func TestRepoRootOverridePrecedence(t *testing.T) {
+	t.Cleanup(func() { SetRootDirOverride("") })
+	discovered := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(discovered, ".git"), 0o755))
+	t.Chdir(discovered)
+	fromEnv, fromFlag := t.TempDir(), t.TempDir()
+
+	t.Setenv(constants.EnvHomeOpsRoot, fromEnv)
+	root, err := RepoRoot()
+	require.NoError(t, err)
+	assert.Equal(t, fromEnv, root, "HOMEOPS_ROOT beats discovery")
+
+	SetRootDirOverride(fromFlag)
+	root, err = RepoRoot()
+	require.NoError(t, err)
+	assert.Equal(t, fromFlag, root, "--root-dir beats HOMEOPS_ROOT")
+
+	SetRootDirOverride(filepath.Join(fromFlag, "missing"))
+	_, err = RepoRoot()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--root-dir")
+
+	SetRootDirOverride("")
+	t.Setenv(constants.EnvHomeOpsRoot, "relative-missing")
+	_, err = RepoRoot()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `HOMEOPS_ROOT "relative-missing" is not a directory`)
+}
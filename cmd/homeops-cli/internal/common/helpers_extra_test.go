@@ -3,9 +3,13 @@ package common
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"homeops-cli/internal/constants"
 
@@ -124,3 +128,53 @@ func TestValidationAndLoggerHelpers(t *testing.T) {
 	assert.Equal(t, parseLogLevel("error").String(), "error")
 	assert.Equal(t, parseLogLevel("unknown").String(), "info")
 }
+
+func TestJSONLogFormatWritesOneObjectPerLineToStderr(t *testing.T) {
+	defer ResetSecretRegistryForTesting()()
+	RegisterSecret("Infra/nas/api_key", "nas-api-key-value")
+	oldNoColor, oldOutput, oldError := color.NoColor, color.Output, color.Error
+	var stdout, stderr bytes.Buffer
+	color.NoColor, color.Output, color.Error = true, &stdout, &stderr
+	defer func() { color.NoColor, color.Output, color.Error = oldNoColor, oldOutput, oldError }()
+	defer func() { _ = SetGlobalLogFormat(LogFormatText) }()
+
+	base := &ColorLogger{Level: DebugLevel}
+	logger := base.With("node", "k8s-0", "attempt", 2, "cause", errors.New("key nas-api-key-value rejected"))
+	logger.Info("plain %s", "text")
+	assert.Contains(t, stdout.String(), `INFO plain text node=k8s-0 attempt=2 cause="key <redacted:Infra/nas/api_key> rejected"`)
+
+	require.EqualError(t, SetGlobalLogFormat("yaml"), `invalid log format "yaml" (must be text or json)`)
+	require.NoError(t, SetGlobalLogFormat(LogFormatJSON))
+	assert.True(t, JSONLogging())
+	stdout.Reset()
+	logger.Debug("debug")
+	logger.Success("deployed %s", "k8s-0")
+	base.SetQuiet(true)
+	logger.Warn("quiet with its parent")
+	base.SetQuiet(false)
+	writer := base.LineWriter(WarnLevel)
+	_, _ = writer.Write([]byte("first line\nsecond "))
+	_, _ = writer.Write([]byte("line\n\nunterminated"))
+	require.NoError(t, writer.Close())
+
+	assert.Empty(t, stdout.String())
+	lines := strings.Split(strings.TrimSuffix(stderr.String(), "\n"), "\n")
+	require.Len(t, lines, 5, stderr.String())
+	var entries []map[string]interface{}
+	for _, line := range lines {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry), line)
+		_, err := time.Parse(time.RFC3339Nano, entry["timestamp"].(string))
+		require.NoError(t, err)
+		delete(entry, "timestamp")
+		entries = append(entries, entry)
+	}
+	assert.Equal(t, []map[string]interface{}{
+		{"level": "debug", "message": "debug", "node": "k8s-0", "attempt": 2.0, "cause": "key <redacted:Infra/nas/api_key> rejected"},
+		{"level": "success", "message": "deployed k8s-0", "node": "k8s-0", "attempt": 2.0, "cause": "key <redacted:Infra/nas/api_key> rejected"},
+		{"level": "warn", "message": "first line"},
+		{"level": "warn", "message": "second line"},
+		{"level": "warn", "message": "unterminated"},
+	}, entries)
+	assert.True(t, strings.HasPrefix(lines[0], `{"level":"debug","timestamp":`), "level, timestamp and message lead")
+}
@@ -1,8 +1,13 @@
 package common
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -23,19 +28,31 @@ const (
 	ErrorLevel
 )
 
-// ColorLogger provides colored console output
+// ColorLogger provides colored console output, or NDJSON on stderr when the
+// global log format is json.
 type ColorLogger struct {
-	Level LogLevel
-	quiet bool       // When true, suppress all output (use SetQuiet/IsQuiet for thread-safe access)
-	mu    sync.Mutex // Protects quiet field
+	Level  LogLevel
+	quiet  bool       // When true, suppress all output (use SetQuiet/IsQuiet for thread-safe access)
+	mu     sync.Mutex // Protects quiet field
+	parent *ColorLogger
+	fields []interface{} // key/value pairs added by With
 }
 
+// Log formats accepted by SetGlobalLogFormat.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
 // Global logger instance and mutex for singleton pattern
 var (
 	globalLogger     *ColorLogger
 	globalLoggerOnce sync.Once
 	globalLogLevel   LogLevel = InfoLevel
+	globalLogFormat           = LogFormatText
 	globalLogMu      sync.RWMutex
+	// logWriteMu keeps concurrent JSON lines whole.
+	logWriteMu sync.Mutex
 )
 
 // SetGlobalLogLevel sets the global log level for all loggers
@@ -80,6 +97,28 @@ func GetGlobalLogLevel() string {
 	}
 }
 
+// SetGlobalLogFormat switches every logger between colored text and one JSON
+// object per line on stderr. Called from the root command's --log-format.
+func SetGlobalLogFormat(format string) error {
+	switch format {
+	case LogFormatText, LogFormatJSON:
+	default:
+		return fmt.Errorf("invalid log format %q (must be text or json)", format)
+	}
+	globalLogMu.Lock()
+	defer globalLogMu.Unlock()
+	globalLogFormat = format
+	return nil
+}
+
+// JSONLogging reports whether the global log format is json, for output
+// (spinners, prompts) that has to fall back to log lines.
+func JSONLogging() bool {
+	globalLogMu.RLock()
+	defer globalLogMu.RUnlock()
+	return globalLogFormat == LogFormatJSON
+}
+
 // NewColorLogger creates a new colored logger using the global log level
 func NewColorLogger() *ColorLogger {
 	globalLogMu.RLock()
@@ -116,65 +155,197 @@ func (l *ColorLogger) SetQuiet(quiet bool) {
 	l.quiet = quiet
 }
 
-// IsQuiet returns the quiet mode status in a thread-safe manner
+// IsQuiet returns the quiet mode status in a thread-safe manner. A logger
+// from With is quiet while its parent is.
 func (l *ColorLogger) IsQuiet() bool {
 	l.mu.Lock()
-	defer l.mu.Unlock()
-	return l.quiet
+	quiet := l.quiet
+	l.mu.Unlock()
+	return quiet || (l.parent != nil && l.parent.IsQuiet())
+}
+
+// With returns a logger that adds the key/value pairs keyvals to every
+// message: as fields in json format, as key=value after the text otherwise.
+func (l *ColorLogger) With(keyvals ...interface{}) *ColorLogger {
+	if len(keyvals)%2 != 0 {
+		keyvals = append(keyvals, "(MISSING)")
+	}
+	return &ColorLogger{
+		Level:  l.Level,
+		parent: l,
+		fields: append(append([]interface{}{}, l.fields...), keyvals...),
+	}
 }
 
 // Debug logs debug messages
 func (l *ColorLogger) Debug(msg string, args ...interface{}) {
-	if l.IsQuiet() {
-		return
-	}
 	if l.Level <= DebugLevel {
-		timestamp := time.Now().UTC().Format("2006-01-02T15:04:05Z")
-		color.Blue("%s DEBUG %s", timestamp, fmt.Sprintf(msg, args...))
+		l.write("debug", color.FgBlue, false, msg, args)
 	}
 }
 
 // Info logs info messages
 func (l *ColorLogger) Info(msg string, args ...interface{}) {
-	if l.IsQuiet() {
-		return
-	}
 	if l.Level <= InfoLevel {
-		timestamp := time.Now().UTC().Format("2006-01-02T15:04:05Z")
-		color.Cyan("%s INFO %s", timestamp, fmt.Sprintf(msg, args...))
+		l.write("info", color.FgCyan, false, msg, args)
 	}
 }
 
 // Warn logs warning messages
 func (l *ColorLogger) Warn(msg string, args ...interface{}) {
-	if l.IsQuiet() {
-		return
-	}
+	// Warnings go to stderr (color.Error = stderr) so stdout stays clean for
+	// piped/captured output. color.Error is overridable for tests.
 	if l.Level <= WarnLevel {
-		timestamp := time.Now().UTC().Format("2006-01-02T15:04:05Z")
-		// Warnings go to stderr (color.Error = stderr) so stdout stays clean for
-		// piped/captured output. color.Error is overridable for tests.
-		_, _ = color.New(color.FgYellow).Fprintf(color.Error, "%s WARN %s\n", timestamp, fmt.Sprintf(msg, args...))
+		l.write("warn", color.FgYellow, true, msg, args)
 	}
 }
 
 // Error logs error messages
 func (l *ColorLogger) Error(msg string, args ...interface{}) {
-	if l.IsQuiet() {
-		return
-	}
-	timestamp := time.Now().UTC().Format("2006-01-02T15:04:05Z")
 	// Errors go to stderr (consistent with the final error printed by main).
-	_, _ = color.New(color.FgRed).Fprintf(color.Error, "%s ERROR %s\n", timestamp, fmt.Sprintf(msg, args...))
+	l.write("error", color.FgRed, true, msg, args)
 }
 
 // Success logs success messages (always shown)
 func (l *ColorLogger) Success(msg string, args ...interface{}) {
+	l.write("success", color.FgGreen, false, msg, args)
+}
+
+// write prints one message: a colored line on stdout (stderr for warnings
+// and errors), or in json format a JSON object on stderr.
+func (l *ColorLogger) write(level string, attr color.Attribute, stderr bool, msg string, args []interface{}) {
 	if l.IsQuiet() {
 		return
 	}
-	timestamp := time.Now().UTC().Format("2006-01-02T15:04:05Z")
-	color.Green("%s SUCCESS %s", timestamp, fmt.Sprintf(msg, args...))
+	now := time.Now().UTC()
+	message := formatLogMessage(msg, args...)
+	if JSONLogging() {
+		line := jsonLogLine(level, now, message, l.fields)
+		logWriteMu.Lock()
+		defer logWriteMu.Unlock()
+		_, _ = color.Error.Write(line)
+		return
+	}
+	out := color.Output
+	if stderr {
+		out = color.Error
+	}
+	_, _ = color.New(attr).Fprintf(out, "%s %s %s%s\n", now.Format("2006-01-02T15:04:05Z"), strings.ToUpper(level), message, textLogFields(l.fields))
+}
+
+// jsonLogLine encodes a message as {"level","timestamp","message",fields...}
+// plus a newline. String, error and Stringer values are redacted.
+func jsonLogLine(level string, now time.Time, message string, fields []interface{}) []byte {
+	var b bytes.Buffer
+	b.WriteString(`{"level":`)
+	writeJSONValue(&b, level)
+	b.WriteString(`,"timestamp":`)
+	writeJSONValue(&b, now.Format(time.RFC3339Nano))
+	b.WriteString(`,"message":`)
+	writeJSONValue(&b, message)
+	for i := 0; i+1 < len(fields); i += 2 {
+		b.WriteByte(',')
+		writeJSONValue(&b, fmt.Sprint(fields[i]))
+		b.WriteByte(':')
+		writeJSONValue(&b, logFieldValue(fields[i+1]))
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+// writeJSONValue appends value as JSON, without escaping <, > and & so
+// redaction markers stay readable; a value JSON cannot encode is printed.
+func writeJSONValue(b *bytes.Buffer, value interface{}) {
+	var encoded bytes.Buffer
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		encoded.Reset()
+		_ = encoder.Encode(RedactSecrets(fmt.Sprint(value)))
+	}
+	b.Write(bytes.TrimSuffix(encoded.Bytes(), []byte("\n")))
+}
+
+// logFieldValue is a With value as logged: text for strings, errors and
+// Stringers (redacted), anything else as is.
+func logFieldValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return RedactSecrets(v)
+	case error:
+		return RedactSecrets(v.Error())
+	case fmt.Stringer:
+		return RedactSecrets(v.String())
+	}
+	return value
+}
+
+// textLogFields renders fields as " key=value ...", quoting values with
+// spaces.
+func textLogFields(fields []interface{}) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(fields); i += 2 {
+		value := fmt.Sprint(logFieldValue(fields[i+1]))
+		if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(&b, " %v=%s", fields[i], value)
+	}
+	return b.String()
+}
+
+// LineWriter returns a writer that logs each line written to it as one
+// message at level, e.g. a subprocess's stderr in json format. Close logs a
+// final unterminated line.
+func (l *ColorLogger) LineWriter(level LogLevel) io.WriteCloser {
+	return &logLineWriter{logger: l, level: level}
+}
+
+type logLineWriter struct {
+	logger  *ColorLogger
+	level   LogLevel
+	pending []byte
+}
+
+func (w *logLineWriter) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		w.log(string(bytes.TrimRight(w.pending[:i], "\r")))
+		w.pending = w.pending[i+1:]
+	}
+}
+
+func (w *logLineWriter) Close() error {
+	if len(w.pending) > 0 {
+		w.log(string(w.pending))
+		w.pending = nil
+	}
+	return nil
+}
+
+func (w *logLineWriter) log(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	switch w.level {
+	case DebugLevel:
+		w.logger.Debug("%s", line)
+	case WarnLevel:
+		w.logger.Warn("%s", line)
+	case ErrorLevel:
+		w.logger.Error("%s", line)
+	default:
+		w.logger.Info("%s", line)
+	}
+}
+
+// formatLogMessage renders a log line with registered secret values masked.
+func formatLogMessage(msg string, args ...interface{}) string {
+	return RedactSecrets(fmt.Sprintf(msg, args...))
 }
 
 // CheckEnv verifies that required environment variables are set
@@ -224,7 +395,9 @@ func NewStructuredLogger(level string) (*StructuredLogger, error) {
 	config.EncoderConfig.CallerKey = "caller"
 	config.EncoderConfig.StacktraceKey = "stacktrace"
 
-	logger, err := config.Build()
+	logger, err := config.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return redactingCore{Core: core}
+	}))
 	if err != nil {
 		return nil, err
 	}
@@ -235,6 +408,53 @@ func NewStructuredLogger(level string) (*StructuredLogger, error) {
 	}, nil
 }
 
+// redactingCore masks registered secret values in the message and string-like
+// fields of every entry before the JSON encoder sees them, so escaping cannot
+// hide a value from the redactor.
+type redactingCore struct {
+	zapcore.Core
+}
+
+func (c redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return redactingCore{Core: c.Core.With(redactFields(fields))}
+}
+
+func (c redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = RedactSecrets(entry.Message)
+	return c.Core.Write(entry, redactFields(fields))
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	out := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		switch field.Type {
+		case zapcore.StringType:
+			field.String = RedactSecrets(field.String)
+		case zapcore.ByteStringType:
+			if b, ok := field.Interface.([]byte); ok {
+				field = zap.ByteString(field.Key, []byte(RedactSecrets(string(b))))
+			}
+		case zapcore.ErrorType:
+			if err, ok := field.Interface.(error); ok {
+				field = zap.String(field.Key, RedactSecrets(err.Error()))
+			}
+		case zapcore.StringerType:
+			if stringer, ok := field.Interface.(fmt.Stringer); ok {
+				field = zap.String(field.Key, RedactSecrets(stringer.String()))
+			}
+		}
+		out[i] = field
+	}
+	return out
+}
+
 // parseLogLevel converts string level to zap level
 func parseLogLevel(level string) zapcore.Level {
 	switch level {
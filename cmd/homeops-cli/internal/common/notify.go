@@ -0,0 +1,222 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	NotifySucceeded = "succeeded"
+	NotifyFailed    = "failed"
+
+	notifyTimeout    = 10 * time.Second
+	notifySummaryMax = 300
+)
+
+// NotifyConfig is the notify: section of homeops.yaml. The webhook URL is a
+// secret (Slack and Discord URLs embed a token), so it is resolved from the
+// notify_webhook_url secret key rather than stored here.
+type NotifyConfig struct {
+	// Desktop shows a desktop notification via osascript (macOS) or
+	// notify-send (Linux) when either is installed.
+	Desktop bool `yaml:"desktop,omitempty"`
+	// Webhook POSTs a JSON payload to the notify_webhook_url secret.
+	Webhook bool `yaml:"webhook,omitempty"`
+	// Topic is added to the payload for ntfy, whose JSON API takes the topic
+	// in the body when the webhook URL is the server root.
+	Topic string `yaml:"topic,omitempty"`
+}
+
+// Enabled reports whether any notification channel is configured.
+func (c NotifyConfig) Enabled() bool { return c.Desktop || c.Webhook }
+
+// Notification describes a finished long-running operation.
+type Notification struct {
+	Operation string // e.g. "bootstrap", "talos upgrade-cluster"
+	Status    string // NotifySucceeded or NotifyFailed
+	Duration  time.Duration
+	// Summary is the operation's final line (the error for a failure). It is
+	// redacted and truncated before it leaves the process.
+	Summary string
+}
+
+// Title is the one-line headline shown by every channel.
+func (n Notification) Title() string {
+	return fmt.Sprintf("homeops %s %s", n.Operation, n.Status)
+}
+
+// Notifier delivers a Notification to the configured channels. The function
+// fields default to the real platform, exec, and HTTP layers; tests replace
+// them.
+type Notifier struct {
+	Desktop    bool
+	WebhookURL string
+	Topic      string
+
+	GOOS     string
+	LookPath func(string) (string, error)
+	Run      func(ctx context.Context, name string, args ...string) error
+	Client   *http.Client
+	Logger   *ColorLogger
+}
+
+// Notify sends n on every configured channel. Delivery problems are logged
+// as warnings and never returned: a failed ping must not fail the operation
+// it reports on.
+func (nt Notifier) Notify(ctx context.Context, n Notification) {
+	n.Summary = sanitizeNotifySummary(n.Summary)
+	logger := nt.Logger
+	if logger == nil {
+		logger = Logger()
+	}
+	if nt.Desktop {
+		if err := nt.sendDesktop(ctx, n); err != nil {
+			logger.Warn("Desktop notification not sent: %v", err)
+		}
+	}
+	if nt.WebhookURL != "" {
+		if err := nt.sendWebhook(ctx, n); err != nil {
+			logger.Warn("Webhook notification not sent: %v", RedactSecrets(err.Error()))
+		}
+	}
+}
+
+// desktopCommand picks the notifier binary for goos, or reports why none
+// applies.
+func desktopCommand(goos string, lookPath func(string) (string, error), n Notification) (string, []string, error) {
+	body := n.Summary
+	if body == "" {
+		body = fmt.Sprintf("finished in %s", n.Duration.Round(time.Second))
+	}
+	switch goos {
+	case "darwin":
+		if _, err := lookPath("osascript"); err != nil {
+			return "", nil, fmt.Errorf("osascript not found")
+		}
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(body), appleScriptString(n.Title()))
+		return "osascript", []string{"-e", script}, nil
+	case "linux":
+		if _, err := lookPath("notify-send"); err != nil {
+			return "", nil, fmt.Errorf("notify-send not found")
+		}
+		args := []string{"--app-name=homeops"}
+		if n.Status == NotifyFailed {
+			args = append(args, "--urgency=critical")
+		}
+		return "notify-send", append(args, n.Title(), body), nil
+	}
+	return "", nil, fmt.Errorf("desktop notifications are not supported on %s", goos)
+}
+
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func (nt Notifier) sendDesktop(ctx context.Context, n Notification) error {
+	goos, lookPath, run := nt.GOOS, nt.LookPath, nt.Run
+	if goos == "" {
+		goos = runtime.GOOS
+	}
+	if lookPath == nil {
+		lookPath = LookPath
+	}
+	if run == nil {
+		run = func(ctx context.Context, name string, args ...string) error {
+			result, err := RunCommand(ctx, CommandOptions{Name: name, Args: args, Timeout: notifyTimeout})
+			if err != nil {
+				return fmt.Errorf("%s: %w (%s)", name, err, strings.TrimSpace(result.Stderr))
+			}
+			return nil
+		}
+	}
+	name, args, err := desktopCommand(goos, lookPath, n)
+	if err != nil {
+		return err
+	}
+	return run(ctx, name, args...)
+}
+
+// notifyPayload carries the same message under the field each service reads:
+// text (Slack), content (Discord), message and topic (ntfy). The structured
+// fields are for anything else.
+type notifyPayload struct {
+	Title           string `json:"title"`
+	Status          string `json:"status"`
+	Operation       string `json:"operation"`
+	DurationSeconds int64  `json:"duration_seconds"`
+	Summary         string `json:"summary,omitempty"`
+	Text            string `json:"text"`
+	Content         string `json:"content"`
+	Message         string `json:"message"`
+	Topic           string `json:"topic,omitempty"`
+}
+
+func webhookPayload(n Notification, topic string) ([]byte, error) {
+	message := fmt.Sprintf("%s after %s", n.Title(), n.Duration.Round(time.Second))
+	if n.Summary != "" {
+		message += ": " + n.Summary
+	}
+	return json.Marshal(notifyPayload{
+		Title:           n.Title(),
+		Status:          n.Status,
+		Operation:       n.Operation,
+		DurationSeconds: int64(n.Duration.Round(time.Second) / time.Second),
+		Summary:         n.Summary,
+		Text:            message,
+		Content:         message,
+		Message:         message,
+		Topic:           topic,
+	})
+}
+
+func (nt Notifier) sendWebhook(ctx context.Context, n Notification) error {
+	body, err := webhookPayload(n, nt.Topic)
+	if err != nil {
+		return err
+	}
+	client := nt.Client
+	if client == nil {
+		client = &http.Client{Timeout: notifyTimeout}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, nt.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		// *url.Error repeats the full URL, token and all; keep only the cause.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("POST to %s failed: %w", req.URL.Host, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s answered %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
+
+// sanitizeNotifySummary keeps the first line, masks registered secrets and
+// secret-looking assignments, and caps the length.
+func sanitizeNotifySummary(summary string) string {
+	summary = strings.TrimSpace(summary)
+	if i := strings.IndexByte(summary, '\n'); i >= 0 {
+		summary = strings.TrimSpace(summary[:i])
+	}
+	summary = RedactCommandOutput(summary)
+	if len(summary) > notifySummaryMax {
+		summary = summary[:notifySummaryMax-3] + "..."
+	}
+	return summary
+}
@@ -0,0 +1,140 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordedExec struct {
+	name string
+	args []string
+}
+
+// fakeDesktop returns a Notifier whose platform has only the given binaries
+// installed, recording every command it would run.
+func fakeDesktop(goos string, installed ...string) (Notifier, *[]recordedExec) {
+	var runs []recordedExec
+	return Notifier{
+		Desktop: true,
+		GOOS:    goos,
+		LookPath: func(file string) (string, error) {
+			for _, name := range installed {
+				if name == file {
+					return "/usr/bin/" + file, nil
+				}
+			}
+			return "", errors.New("not found")
+		},
+		Run: func(_ context.Context, name string, args ...string) error {
+			runs = append(runs, recordedExec{name, args})
+			return nil
+		},
+		Logger: &ColorLogger{quiet: true},
+	}, &runs
+}
+
+func TestDesktopNotificationPlatformDetection(t *testing.T) {
+	failed := Notification{Operation: "bootstrap", Status: NotifyFailed, Duration: 42 * time.Minute, Summary: `node "k8s-0" not Ready`}
+
+	linux, runs := fakeDesktop("linux", "notify-send")
+	linux.Notify(context.Background(), failed)
+	assert.Equal(t, []recordedExec{{"notify-send", []string{"--app-name=homeops", "--urgency=critical", "homeops bootstrap failed", `node "k8s-0" not Ready`}}}, *runs)
+
+	mac, runs := fakeDesktop("darwin", "osascript")
+	mac.Notify(context.Background(), failed)
+	require.Len(t, *runs, 1)
+	assert.Equal(t, "osascript", (*runs)[0].name)
+	assert.Equal(t, []string{"-e", `display notification "node \"k8s-0\" not Ready" with title "homeops bootstrap failed"`}, (*runs)[0].args)
+
+	missing, runs := fakeDesktop("linux")
+	missing.Notify(context.Background(), failed)
+	assert.Empty(t, *runs, "no notify-send, nothing runs")
+
+	_, _, err := desktopCommand("windows", missing.LookPath, failed)
+	assert.ErrorContains(t, err, "not supported on windows")
+
+	_, args, err := desktopCommand("linux", linux.LookPath, Notification{Operation: "volsync restore-all", Status: NotifySucceeded, Duration: 90 * time.Second})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--app-name=homeops", "homeops volsync restore-all succeeded", "finished in 1m30s"}, args)
+}
+
+func TestWebhookPayload(t *testing.T) {
+	raw, err := webhookPayload(Notification{Operation: "talos upgrade-cluster", Status: NotifySucceeded, Duration: 25*time.Minute + 400*time.Millisecond}, "homeops")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"title": "homeops talos upgrade-cluster succeeded",
+		"status": "succeeded",
+		"operation": "talos upgrade-cluster",
+		"duration_seconds": 1500,
+		"text": "homeops talos upgrade-cluster succeeded after 25m0s",
+		"content": "homeops talos upgrade-cluster succeeded after 25m0s",
+		"message": "homeops talos upgrade-cluster succeeded after 25m0s",
+		"topic": "homeops"
+	}`, string(raw))
+}
+
+func TestWebhookNotification(t *testing.T) {
+	defer ResetSecretRegistryForTesting()()
+	RegisterSecret("truenas_api_key", "1-abcdefghijklmnop")
+
+	var got notifyPayload
+	var contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	notifier := Notifier{WebhookURL: server.URL, Client: server.Client(), Logger: &ColorLogger{quiet: true}}
+	notifier.Notify(context.Background(), Notification{
+		Operation: "bootstrap", Status: NotifyFailed, Duration: time.Hour,
+		Summary: "apply failed: api_key=1-abcdefghijklmnop rejected\nfull stack follows",
+	})
+	assert.Equal(t, "application/json", contentType)
+	assert.Equal(t, "failed", got.Status)
+	assert.Equal(t, int64(3600), got.DurationSeconds)
+	assert.Equal(t, "apply failed: api_key=<redacted> rejected", got.Summary, "first line only, secrets masked")
+	assert.NotContains(t, got.Text, "abcdefghijklmnop")
+	assert.Empty(t, got.Topic)
+}
+
+func TestWebhookFailuresAreLogOnly(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	notifier := Notifier{WebhookURL: server.URL + "/hook/s3cr3t-token", Client: server.Client()}
+	err := notifier.sendWebhook(context.Background(), Notification{Operation: "bootstrap", Status: NotifySucceeded})
+	require.ErrorContains(t, err, "403 Forbidden")
+	assert.NotContains(t, err.Error(), "s3cr3t-token")
+
+	server.Close()
+	err = notifier.sendWebhook(context.Background(), Notification{Operation: "bootstrap", Status: NotifySucceeded})
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "s3cr3t-token", "transport errors drop the URL path")
+
+	// Notify itself never panics or returns on a dead webhook.
+	notifier.Logger = &ColorLogger{quiet: true}
+	notifier.Notify(context.Background(), Notification{Operation: "bootstrap", Status: NotifySucceeded})
+}
+
+func TestSanitizeNotifySummaryTruncates(t *testing.T) {
+	summary := sanitizeNotifySummary(strings.Repeat("x", 500))
+	assert.Len(t, summary, notifySummaryMax)
+	assert.True(t, strings.HasSuffix(summary, "..."))
+	assert.True(t, NotifyConfig{Webhook: true}.Enabled())
+	assert.False(t, NotifyConfig{Topic: "homeops"}.Enabled())
+}
@@ -0,0 +1,136 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MinRedactedSecretLength is the shortest resolved value the registry tracks.
+// Shorter values (ports, booleans, short usernames) would produce false
+// positives all over ordinary output.
+const MinRedactedSecretLength = 8
+
+type registeredSecret struct {
+	value string
+	label string
+}
+
+// secretRegistry holds every secret value resolved during this process so the
+// output sinks can mask them. Values live in memory only and are keyed by
+// hash so re-registering the same value is cheap.
+var secretRegistry = struct {
+	mu       sync.RWMutex
+	entries  map[[sha256.Size]byte]registeredSecret
+	replacer *strings.Replacer
+}{entries: make(map[[sha256.Size]byte]registeredSecret)}
+
+// RegisterSecret records a resolved secret value so that RedactSecrets
+// replaces it with <redacted:label> wherever it later appears. The base64
+// form is registered too because Kubernetes Secret data and kubeconfig
+// fields carry values encoded. Values shorter than MinRedactedSecretLength
+// are ignored.
+func RegisterSecret(label, value string) {
+	value = strings.TrimSpace(value)
+	if len(value) < MinRedactedSecretLength {
+		return
+	}
+	placeholder := "<redacted:" + label + ">"
+
+	secretRegistry.mu.Lock()
+	defer secretRegistry.mu.Unlock()
+	for _, v := range []string{value, base64.StdEncoding.EncodeToString([]byte(value))} {
+		key := sha256.Sum256([]byte(v))
+		if _, ok := secretRegistry.entries[key]; ok {
+			continue
+		}
+		secretRegistry.entries[key] = registeredSecret{value: v, label: placeholder}
+		secretRegistry.replacer = nil
+	}
+}
+
+// RedactSecrets replaces every registered secret value in s with its
+// placeholder in a single pass over the input.
+func RedactSecrets(s string) string {
+	if len(s) < MinRedactedSecretLength {
+		return s
+	}
+	replacer := registeredSecretReplacer()
+	if replacer == nil {
+		return s
+	}
+	return replacer.Replace(s)
+}
+
+// RedactError returns err with registered secret values masked in its
+// message. errors.Is/As still see the original chain through Unwrap.
+func RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	redacted := RedactSecrets(msg)
+	if redacted == msg {
+		return err
+	}
+	return &redactedError{msg: redacted, err: err}
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
+
+func registeredSecretReplacer() *strings.Replacer {
+	secretRegistry.mu.RLock()
+	replacer, empty := secretRegistry.replacer, len(secretRegistry.entries) == 0
+	secretRegistry.mu.RUnlock()
+	if replacer != nil || empty {
+		return replacer
+	}
+
+	secretRegistry.mu.Lock()
+	defer secretRegistry.mu.Unlock()
+	if secretRegistry.replacer != nil {
+		return secretRegistry.replacer
+	}
+	entries := make([]registeredSecret, 0, len(secretRegistry.entries))
+	for _, entry := range secretRegistry.entries {
+		entries = append(entries, entry)
+	}
+	// strings.Replacer prefers earlier pairs when several match at the same
+	// offset, so longer values go first and a secret that is a prefix of
+	// another cannot leave the tail of the longer one behind.
+	sort.Slice(entries, func(i, j int) bool {
+		if len(entries[i].value) != len(entries[j].value) {
+			return len(entries[i].value) > len(entries[j].value)
+		}
+		return entries[i].value < entries[j].value
+	})
+	pairs := make([]string, 0, 2*len(entries))
+	for _, entry := range entries {
+		pairs = append(pairs, entry.value, entry.label)
+	}
+	secretRegistry.replacer = strings.NewReplacer(pairs...)
+	return secretRegistry.replacer
+}
+
+// ResetSecretRegistryForTesting clears all registered values and returns a
+// function restoring the previous registry.
+func ResetSecretRegistryForTesting() func() {
+	secretRegistry.mu.Lock()
+	defer secretRegistry.mu.Unlock()
+	oldEntries, oldReplacer := secretRegistry.entries, secretRegistry.replacer
+	secretRegistry.entries = make(map[[sha256.Size]byte]registeredSecret)
+	secretRegistry.replacer = nil
+	return func() {
+		secretRegistry.mu.Lock()
+		defer secretRegistry.mu.Unlock()
+		secretRegistry.entries, secretRegistry.replacer = oldEntries, oldReplacer
+	}
+}
@@ -0,0 +1,116 @@
+package common
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRedactSecretsReplacesRegisteredValues(t *testing.T) {
+	defer ResetSecretRegistryForTesting()()
+
+	assert.Equal(t, "nothing registered", RedactSecrets("nothing registered"))
+
+	RegisterSecret("Infra/spice/password", "spice-hunter2-pw")
+	RegisterSecret("Infra/spice/password-long", "spice-hunter2-pw-extended")
+	RegisterSecret("Infra/short/pin", "1234")
+
+	out := RedactSecrets("a=spice-hunter2-pw b=spice-hunter2-pw-extended pin=1234")
+	assert.Equal(t, "a=<redacted:Infra/spice/password> b=<redacted:Infra/spice/password-long> pin=1234", out,
+		"the longer value wins at a shared prefix and short values are not tracked")
+
+	encoded := base64.StdEncoding.EncodeToString([]byte("spice-hunter2-pw"))
+	assert.Equal(t, "data: <redacted:Infra/spice/password>", RedactSecrets("data: "+encoded))
+
+	RegisterSecret("Infra/dup/label", "spice-hunter2-pw")
+	assert.Equal(t, "<redacted:Infra/spice/password>", RedactSecrets("spice-hunter2-pw"), "first registration keeps its label")
+}
+
+func TestRedactErrorKeepsErrorChain(t *testing.T) {
+	defer ResetSecretRegistryForTesting()()
+	RegisterSecret("Infra/api/key", "sk-live-0123456789")
+
+	cause := errors.New("upstream rejected sk-live-0123456789")
+	err := RedactError(fmt.Errorf("apply failed: %w", cause))
+	assert.Equal(t, "apply failed: upstream rejected <redacted:Infra/api/key>", err.Error())
+	assert.ErrorIs(t, err, cause)
+
+	plain := errors.New("no secrets here")
+	assert.Same(t, plain, RedactError(plain))
+	assert.NoError(t, RedactError(nil))
+}
+
+func TestRedactCommandOutputAppliesRegistry(t *testing.T) {
+	defer ResetSecretRegistryForTesting()()
+	RegisterSecret("Infra/talos/secretbox", "c2VjcmV0Ym94LWtleQ")
+
+	out := RedactCommandOutput("secretboxEncryptionSecret c2VjcmV0Ym94LWtleQ rejected\npassword=hunter2hunter2")
+	assert.NotContains(t, out, "c2VjcmV0Ym94LWtleQ")
+	assert.Contains(t, out, "<redacted:Infra/talos/secretbox>")
+	assert.Contains(t, out, "password=<redacted>")
+}
+
+func TestLoggerSinksRedactRegisteredSecrets(t *testing.T) {
+	defer ResetSecretRegistryForTesting()()
+	secret := "kubeconfig-client-key-material"
+	RegisterSecret("Infra/kube/config", secret)
+
+	oldNoColor, oldOutput, oldError := color.NoColor, color.Output, color.Error
+	color.NoColor = true
+	buf := &bytes.Buffer{}
+	color.Output, color.Error = buf, buf
+	defer func() { color.NoColor, color.Output, color.Error = oldNoColor, oldOutput, oldError }()
+
+	logger := &ColorLogger{Level: DebugLevel}
+	logger.Debug("debug %s", secret)
+	logger.Info("info %s", secret)
+	logger.Warn("warn %s", secret)
+	logger.Error("error %s", secret)
+	logger.Success("success %s", secret)
+	assert.NotContains(t, buf.String(), secret)
+	assert.Equal(t, 5, strings.Count(buf.String(), "<redacted:Infra/kube/config>"))
+
+	var jsonBuf bytes.Buffer
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(&jsonBuf), zapcore.DebugLevel)
+	structured := zap.New(redactingCore{Core: core})
+	structured.With(zap.String("with", secret)).Info("message "+secret,
+		zap.String("string", secret),
+		zap.ByteString("bytes", []byte(secret)),
+		zap.Error(errors.New("cause "+secret)),
+		zap.Stringer("stringer", stringerFunc(func() string { return secret })),
+	)
+	structured.Sugar().Infof("sugared %s", secret)
+	assert.NotContains(t, jsonBuf.String(), secret)
+	assert.Equal(t, 7, strings.Count(jsonBuf.String(), "<redacted:Infra/kube/config>"), jsonBuf.String())
+}
+
+type stringerFunc func() string
+
+func (f stringerFunc) String() string { return f() }
+
+func TestRedactSecretsLargeOutput(t *testing.T) {
+	defer ResetSecretRegistryForTesting()()
+	for i := 0; i < 200; i++ {
+		RegisterSecret(fmt.Sprintf("Infra/item%d/field", i), fmt.Sprintf("secret-value-%04d", i))
+	}
+
+	line := "ordinary log line with nothing sensitive in it, secret-value-0042 once\n"
+	input := strings.Repeat(line, 50000)
+	start := time.Now()
+	out := RedactSecrets(input)
+	elapsed := time.Since(start)
+
+	assert.NotContains(t, out, "secret-value-0042")
+	assert.Equal(t, 50000, strings.Count(out, "<redacted:Infra/item42/field>"))
+	require.Less(t, elapsed, 5*time.Second, "single-pass replacement over ~3.5MB should be fast")
+}
@@ -21,6 +21,7 @@ import (
 	"net/netip"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"sync"
@@ -120,6 +121,9 @@ type VMDefaults struct {
 type Node struct {
 	Name string `yaml:"name"`
 	IP   string `yaml:"ip"`
+	// IPv6 is the node's address on cluster.ipv6.node_subnet; rendered only
+	// with cluster.dual_stack.
+	IPv6 string `yaml:"ipv6,omitempty"`
 	// VM customizes this node's VM hardware profile on the hypervisor.
 	VM VMProfile `yaml:"vm,omitempty"`
 }
@@ -138,12 +142,33 @@ type TalosUserVolumeSettings struct {
 	MaxSize string `yaml:"max_size,omitempty"`
 }
 
+// Talos system disk encryption key providers.
+const (
+	DiskEncryptionNodeID = "nodeid"
+	DiskEncryptionStatic = "static"
+	DiskEncryptionTPM    = "tpm"
+)
+
+// TalosDiskEncryption selects the LUKS2 key for the STATE and EPHEMERAL
+// partitions. Changing Provider between empty and set only takes effect on a
+// node after a reset that wipes both partitions.
+type TalosDiskEncryption struct {
+	// Provider is nodeid (derived from the node UUID), static (the
+	// talos_disk_encryption_key secret), or tpm (sealed to the VM's vTPM).
+	// Empty leaves the partitions unencrypted.
+	Provider string `yaml:"provider,omitempty"`
+}
+
+// Enabled reports whether STATE and EPHEMERAL should be encrypted.
+func (e TalosDiskEncryption) Enabled() bool { return e.Provider != "" }
+
 // TalosSettings holds legacy Talos-provider cluster knobs.
 type TalosSettings struct {
 	DiscoveryEndpoint       string                  `yaml:"discovery_endpoint,omitempty"`
 	ControlPlaneInstallDisk string                  `yaml:"controlplane_install_disk,omitempty"`
 	WorkerInstallDisk       string                  `yaml:"worker_install_disk,omitempty"`
 	UserVolume              TalosUserVolumeSettings `yaml:"user_volume,omitempty"`
+	DiskEncryption          TalosDiskEncryption     `yaml:"disk_encryption,omitempty"`
 }
 
 // ObservabilityConfig identifies the namespace containing cluster metrics
@@ -178,6 +203,9 @@ type ClusterConfig struct {
 	Kubelet KubeletConfig `yaml:"kubelet,omitempty"`
 	// Maintenance controls safe node drain and readiness wait defaults.
 	Maintenance MaintenanceConfig `yaml:"maintenance,omitempty"`
+	// TLSProbe selects the external hostnames k8s tls-probe checks and the
+	// header policy they must meet.
+	TLSProbe TLSProbeConfig `yaml:"tls_probe,omitempty"`
 	// Talos holds legacy Talos-provider-only settings.
 	Talos TalosSettings `yaml:"talos,omitempty"`
 	// DomainRef is a secret reference resolving to the cluster base domain.
@@ -188,8 +216,17 @@ type ClusterConfig struct {
 	Endpoint string `yaml:"endpoint,omitempty"`
 	// ControlPlaneVIP is the kube-vip virtual IP fronting the apiserver.
 	ControlPlaneVIP string `yaml:"control_plane_vip,omitempty"`
+	// DualStack renders the IPv6 side of cluster networking (cluster.ipv6 and
+	// cluster.nodes[].ipv6) next to the IPv4 settings above. Off, the IPv6
+	// fields are ignored and every render is single-stack.
+	DualStack bool `yaml:"dual_stack,omitempty"`
+	// IPv6 holds the IPv6 counterparts of the CIDRs and VIP above.
+	IPv6 ClusterIPv6Config `yaml:"ipv6,omitempty"`
 	// NodeInterface is the primary NIC name on the nodes (e.g. eth0).
 	NodeInterface string `yaml:"node_interface,omitempty"`
+	// DHCPPool is the DHCP server's dynamic range on the node subnet; static
+	// node IPs must stay outside it. Used by talos check-ip.
+	DHCPPool DHCPPoolConfig `yaml:"dhcp_pool,omitempty"`
 	// Nodes are the control-plane nodes in order; the first is the kubeadm
 	// init node.
 	Nodes []Node `yaml:"nodes,omitempty"`
@@ -199,6 +236,39 @@ type ClusterConfig struct {
 	TestNode *Node `yaml:"test_node,omitempty"`
 }
 
+// ClusterIPv6Config is the IPv6 half of a dual-stack cluster. PodCIDR and
+// ServiceCIDR are required with cluster.dual_stack; NodeSubnet and
+// ControlPlaneVIP are optional.
+type ClusterIPv6Config struct {
+	PodCIDR         string `yaml:"pod_cidr,omitempty"`
+	ServiceCIDR     string `yaml:"service_cidr,omitempty"`
+	NodeSubnet      string `yaml:"node_subnet,omitempty"`
+	ControlPlaneVIP string `yaml:"control_plane_vip,omitempty"`
+}
+
+// DHCPPoolConfig is an inclusive IPv4 address range.
+type DHCPPoolConfig struct {
+	Start string `yaml:"start,omitempty"`
+	End   string `yaml:"end,omitempty"`
+}
+
+// Configured reports whether both ends of the pool are set.
+func (p DHCPPoolConfig) Configured() bool {
+	return p.Start != "" && p.End != ""
+}
+
+// Contains reports whether ip falls inside the pool. Unparseable input is
+// never contained; validate rejects bad bounds at load time.
+func (p DHCPPoolConfig) Contains(ip netip.Addr) bool {
+	start, startErr := netip.ParseAddr(p.Start)
+	end, endErr := netip.ParseAddr(p.End)
+	if startErr != nil || endErr != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	return start.Compare(ip) <= 0 && ip.Compare(end) <= 0
+}
+
 // MaintenanceConfig holds human-readable Go duration strings for node
 // maintenance workflows (for example "5m" or "15m30s").
 type MaintenanceConfig struct {
@@ -206,6 +276,45 @@ type MaintenanceConfig struct {
 	Timeout      string `yaml:"timeout,omitempty"`
 }
 
+// TLSProbeConfig is the k8s tls-probe policy. Zero values keep the defaults:
+// every Gateway whose name contains "external", and an HSTS max-age of at
+// least 180 days.
+type TLSProbeConfig struct {
+	// Gateways are "namespace/name" Gateways whose HTTPRoute hostnames count
+	// as externally exposed.
+	Gateways []string `yaml:"gateways,omitempty"`
+	// HSTSMinMaxAge is the smallest acceptable Strict-Transport-Security
+	// max-age, in seconds.
+	HSTSMinMaxAge int `yaml:"hsts_min_max_age,omitempty"`
+	// Hosts overrides the policy per hostname.
+	Hosts map[string]TLSProbeHost `yaml:"hosts,omitempty"`
+}
+
+// TLSProbeHost is a per-hostname tls-probe override.
+type TLSProbeHost struct {
+	// Skip leaves the hostname out of the probe entirely.
+	Skip bool `yaml:"skip,omitempty"`
+	// HSTS false drops the HSTS requirement for this host.
+	HSTS *bool `yaml:"hsts,omitempty"`
+	// HSTSMinMaxAge replaces the cluster-wide minimum for this host.
+	HSTSMinMaxAge int `yaml:"hsts_min_max_age,omitempty"`
+	// AllowMissingHeaders silences the missing-header warning for
+	// X-Frame-Options and/or Content-Security-Policy.
+	AllowMissingHeaders []string `yaml:"allow_missing_headers,omitempty"`
+}
+
+// NamingPolicy narrows the VM names a hypervisor accepts. The provider's own
+// constraints (character set, length) always apply; these fields can only
+// tighten them. Empty fields add nothing.
+type NamingPolicy struct {
+	// Pattern is a Go regular expression every VM name must match.
+	Pattern string `yaml:"pattern,omitempty"`
+	// Prefix is a required name prefix (e.g. "k8s").
+	Prefix string `yaml:"prefix,omitempty"`
+	// MaxLength caps the name length below the provider limit.
+	MaxLength int `yaml:"max_length,omitempty"`
+}
+
 // ProxmoxConfig holds Proxmox-specific knobs.
 type ProxmoxConfig struct {
 	// SnippetsDir is where rendered Ignition files are uploaded on the PVE
@@ -217,6 +326,8 @@ type ProxmoxConfig struct {
 	ImageCacheDir string `yaml:"image_cache_dir,omitempty"`
 	// VM overrides the default VM composition (sizing, disk backends, network).
 	VM VMDefaults `yaml:"vm,omitempty"`
+	// Naming tightens the VM names accepted for new VMs on this provider.
+	Naming NamingPolicy `yaml:"naming,omitempty"`
 }
 
 // TrueNASConfig holds TrueNAS-specific knobs.
@@ -242,6 +353,23 @@ type TrueNASConfig struct {
 	// VM overrides the default VM composition (sizing, zvol pool, network).
 	// BootStorage doubles as the zvol parent dataset (e.g. "flashstor/VM").
 	VM VMDefaults `yaml:"vm,omitempty"`
+	// Naming tightens the VM names accepted for new VMs on this provider.
+	Naming NamingPolicy `yaml:"naming,omitempty"`
+	// IPDiscovery is how `talos deploy-vm --wait-for-ip` finds a new VM's
+	// DHCP address from its NIC MAC.
+	IPDiscovery IPDiscoveryConfig `yaml:"ip_discovery,omitempty"`
+}
+
+// IPDiscoveryConfig picks where a guest's MAC is looked up.
+type IPDiscoveryConfig struct {
+	// Method is "arp" (default): probe cluster.dhcp_pool (or the VM
+	// bridge's subnet) from the NAS over SSH and read its neighbor table;
+	// or "dhcp": fetch LeasesURL.
+	Method string `yaml:"method,omitempty"`
+	// LeasesURL serves the DHCP server's leases as text; any line holding
+	// the MAC and an IPv4 address matches (dnsmasq leases, Kea CSV,
+	// one-lease-per-line JSON).
+	LeasesURL string `yaml:"leases_url,omitempty"`
 }
 
 // VSphereConfig holds vSphere/ESXi-specific knobs.
@@ -256,6 +384,8 @@ type VSphereConfig struct {
 	// imported once with govc/ovftool or converted via
 	// `vm template import --from-vm`). Override per-call with --template.
 	Template string `yaml:"template,omitempty"`
+	// Naming tightens the VM names accepted for new VMs on this provider.
+	Naming NamingPolicy `yaml:"naming,omitempty"`
 }
 
 // HypervisorsConfig groups per-hypervisor settings.
@@ -344,11 +474,20 @@ type TemplatesConfig struct {
 	Dir string `yaml:"dir,omitempty"`
 }
 
-// BootstrapSettings controls embedded bootstrap manifests.
+// PluginsConfig controls discovery of external homeops-<name> subcommands.
+type PluginsConfig struct {
+	// Dir is searched for plugin executables before PATH.
+	Dir string `yaml:"dir,omitempty"`
+}
+
+// BootstrapSettings controls embedded bootstrap manifests and checks.
 type BootstrapSettings struct {
 	// OpVault is the 1Password vault name used by the External Secrets
 	// ClusterSecretStore manifest.
 	OpVault string `yaml:"op_vault,omitempty"`
+	// SkipGatewayCheck disables the post-bootstrap Gateway API smoke test
+	// (same as bootstrap --skip-gateway-check).
+	SkipGatewayCheck bool `yaml:"skip_gateway_check,omitempty"`
 }
 
 // VolsyncConfig controls VolSync verification helpers.
@@ -357,14 +496,67 @@ type VolsyncConfig struct {
 	CheckImage string `yaml:"check_image,omitempty"`
 }
 
+// AuditConfig controls the append-only log of mutating command invocations.
+type AuditConfig struct {
+	// Disabled turns the log off; --no-audit does the same for one invocation.
+	Disabled bool `yaml:"disabled,omitempty"`
+	// Path is the JSONL log file. ~ is expanded.
+	Path string `yaml:"path,omitempty"`
+	// Mirror also records each entry as a Kubernetes Event when the cluster
+	// is reachable. The local log stays authoritative.
+	Mirror bool `yaml:"mirror,omitempty"`
+	// Namespace receives the mirrored Events.
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+// SecretBackendConfig selects the backend op:// references resolve through,
+// so templates keep op:// syntax for collaborators without 1Password. The
+// root command's --secret-backend overrides Type for one invocation.
+type SecretBackendConfig struct {
+	// Type is "1password" (default), "env" or "sops".
+	Type string `yaml:"type,omitempty"`
+	// EnvMapFile is a YAML map of op:// reference to environment variable
+	// name, used by the env backend. ~ is expanded.
+	EnvMapFile string `yaml:"env_map_file,omitempty"`
+	// SopsFile is the age-encrypted YAML the sops backend decrypts, nested
+	// vault: item: field: value. ~ is expanded.
+	SopsFile string `yaml:"sops_file,omitempty"`
+}
+
+// ToolchainConfig pins the external tools `bundle create` packages for
+// disaster recovery.
+type ToolchainConfig struct {
+	Tools []ToolPin `yaml:"tools,omitempty"`
+}
+
+// ToolPin is one external tool at a pinned version. URL and ChecksumURL may
+// use the {version}, {os} and {arch} placeholders (Go GOOS/GOARCH names).
+type ToolPin struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+	URL     string `yaml:"url"`
+	// SHA256 maps "os/arch" to the artifact's expected SHA-256.
+	SHA256 map[string]string `yaml:"sha256,omitempty"`
+	// ChecksumURL is a sha256sum-style file (or a bare digest) used when
+	// SHA256 has no entry for the platform.
+	ChecksumURL string `yaml:"checksum_url,omitempty"`
+}
+
 // Config is the root of homeops.yaml.
 type Config struct {
 	Cluster     ClusterConfig     `yaml:"cluster,omitempty"`
 	Hypervisors HypervisorsConfig `yaml:"hypervisors,omitempty"`
 	State       StateConfig       `yaml:"state,omitempty"`
 	Templates   TemplatesConfig   `yaml:"templates,omitempty"`
+	Plugins     PluginsConfig     `yaml:"plugins,omitempty"`
 	Bootstrap   BootstrapSettings `yaml:"bootstrap,omitempty"`
 	Volsync     VolsyncConfig     `yaml:"volsync,omitempty"`
+	Audit       AuditConfig       `yaml:"audit,omitempty"`
+	Toolchain   ToolchainConfig   `yaml:"toolchain,omitempty"`
+	// Notify pings the desktop and/or a webhook when a long-running
+	// operation (bootstrap, upgrade-cluster, restore-all, reset-cluster)
+	// finishes.
+	Notify common.NotifyConfig `yaml:"notify,omitempty"`
 	// Images overrides the cloud-image catalog used by `vm create`: a map of
 	// OS key (ubuntu, rocky, rhel, debian, fedora) to a qcow2 URL or a path
 	// already present on the hypervisor. RHEL requires this (subscription).
@@ -373,6 +565,8 @@ type Config struct {
 	// references (op://, env://, file://, cmd://, literal://). Keys not
 	// listed here fall back to their portable env:// defaults.
 	Secrets map[string]string `yaml:"secrets,omitempty"`
+	// SecretBackend selects where op:// references are read from.
+	SecretBackend SecretBackendConfig `yaml:"secret_backend,omitempty"`
 
 	// Source is the path the config was loaded from ("" = built-in defaults).
 	Source string `yaml:"-"`
@@ -485,8 +679,14 @@ func SetForTesting(c *Config) func() {
 	}
 }
 
-// registerKeymap wires the secret:// indirection scheme to this config.
+// registerKeymap wires the secret:// indirection scheme and the op://
+// backend to this config.
 func registerKeymap(c *Config) {
+	secrets.ConfigureBackend(secrets.BackendOptions{
+		Type:       c.SecretBackend.Type,
+		EnvMapFile: c.SecretBackend.EnvMapFile,
+		SopsFile:   c.SecretBackend.SopsFile,
+	})
 	secrets.RegisterKeymap(func(key string) (string, bool) {
 		ref := c.SecretRef(key)
 		return ref, ref != ""
@@ -550,8 +750,8 @@ func Locate() (path string, explicit bool) {
 		abs, _ := filepath.Abs("homeops.yaml")
 		return abs, false
 	}
-	if gitRoot, err := common.FindGitRoot("."); err == nil {
-		candidate := filepath.Join(gitRoot, "homeops.yaml")
+	if repoRoot, err := common.RepoRoot(); err == nil {
+		candidate := filepath.Join(repoRoot, "homeops.yaml")
 		if _, err := os.Stat(candidate); err == nil {
 			return candidate, false
 		}
@@ -589,7 +789,135 @@ func LoadFile(path string) (*Config, error) {
 	return cfg, nil
 }
 
+// validateDualStack checks the address families of a dual-stack cluster. The
+// IPv6 fields are not checked while cluster.dual_stack is off, so they can be
+// staged ahead of the switch.
+func validateDualStack(cluster ClusterConfig) []string {
+	if !cluster.DualStack {
+		return nil
+	}
+	var problems []string
+	checkPrefix := func(name, value string, wantIPv6, required bool) {
+		if value == "" {
+			if required {
+				problems = append(problems, fmt.Sprintf("%s: required with cluster.dual_stack", name))
+			}
+			return
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return // reported by the generic CIDR check
+		}
+		if prefix.Addr().Is6() != wantIPv6 {
+			problems = append(problems, fmt.Sprintf("%s: %q must be an %s CIDR with cluster.dual_stack", name, value, familyName(wantIPv6)))
+		}
+	}
+	checkAddr := func(name, value string, wantIPv6 bool) {
+		if value == "" {
+			return
+		}
+		addr, err := netip.ParseAddr(value)
+		if err != nil || addr.Is6() != wantIPv6 || addr.Is4In6() {
+			problems = append(problems, fmt.Sprintf("%s: %q is not an %s address", name, value, familyName(wantIPv6)))
+		}
+	}
+	checkPrefix("cluster.pod_cidr", cluster.PodCIDR, false, false)
+	checkPrefix("cluster.service_cidr", cluster.ServiceCIDR, false, false)
+	checkPrefix("cluster.node_subnet", cluster.NodeSubnet, false, false)
+	checkPrefix("cluster.ipv6.pod_cidr", cluster.IPv6.PodCIDR, true, true)
+	checkPrefix("cluster.ipv6.service_cidr", cluster.IPv6.ServiceCIDR, true, true)
+	checkPrefix("cluster.ipv6.node_subnet", cluster.IPv6.NodeSubnet, true, false)
+	checkAddr("cluster.ipv6.control_plane_vip", cluster.IPv6.ControlPlaneVIP, true)
+	for _, n := range cluster.Nodes {
+		checkAddr(fmt.Sprintf("cluster.nodes[%s].ipv6", n.Name), n.IPv6, true)
+	}
+	return problems
+}
+
+func familyName(ipv6 bool) string {
+	if ipv6 {
+		return "IPv6"
+	}
+	return "IPv4"
+}
+
 // validate rejects obviously broken configs early with actionable messages.
+func validateTLSProbe(probe TLSProbeConfig) []string {
+	var problems []string
+	for _, gateway := range probe.Gateways {
+		namespace, name, ok := strings.Cut(gateway, "/")
+		if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+			problems = append(problems, fmt.Sprintf("cluster.tls_probe.gateways: %q is not namespace/name", gateway))
+		}
+	}
+	if probe.HSTSMinMaxAge < 0 {
+		problems = append(problems, "cluster.tls_probe.hsts_min_max_age: must not be negative")
+	}
+	hosts := make([]string, 0, len(probe.Hosts))
+	for host := range probe.Hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		override := probe.Hosts[host]
+		if override.HSTSMinMaxAge < 0 {
+			problems = append(problems, fmt.Sprintf("cluster.tls_probe.hosts[%s].hsts_min_max_age: must not be negative", host))
+		}
+		for _, header := range override.AllowMissingHeaders {
+			switch strings.ToLower(header) {
+			case "x-frame-options", "content-security-policy":
+			default:
+				problems = append(problems, fmt.Sprintf("cluster.tls_probe.hosts[%s].allow_missing_headers: %q is not supported (use X-Frame-Options or Content-Security-Policy)", host, header))
+			}
+		}
+	}
+	return problems
+}
+
+// validateToolchain checks the pins bundle create downloads: each needs a
+// unique file-safe name, a version, a URL and a way to verify the download.
+func validateToolchain(toolchain ToolchainConfig) []string {
+	var problems []string
+	seen := map[string]bool{}
+	for i, tool := range toolchain.Tools {
+		field := fmt.Sprintf("toolchain.tools[%d]", i)
+		if tool.Name != "" {
+			field = fmt.Sprintf("toolchain.tools[%s]", tool.Name)
+		}
+		switch {
+		case strings.TrimSpace(tool.Name) == "":
+			problems = append(problems, field+".name: must be set")
+		case strings.ContainsAny(tool.Name, `/\`) || tool.Name == "." || tool.Name == "..":
+			problems = append(problems, fmt.Sprintf("%s.name: %q must be a plain file name", field, tool.Name))
+		case seen[tool.Name]:
+			problems = append(problems, field+": listed more than once")
+		}
+		seen[tool.Name] = true
+		if strings.TrimSpace(tool.Version) == "" {
+			problems = append(problems, field+".version: must be set")
+		}
+		if strings.TrimSpace(tool.URL) == "" {
+			problems = append(problems, field+".url: must be set")
+		}
+		if len(tool.SHA256) == 0 && tool.ChecksumURL == "" {
+			problems = append(problems, field+": set sha256 or checksum_url so the download can be verified")
+		}
+		platforms := make([]string, 0, len(tool.SHA256))
+		for platform := range tool.SHA256 {
+			platforms = append(platforms, platform)
+		}
+		sort.Strings(platforms)
+		for _, platform := range platforms {
+			if !sha256HexRe.MatchString(tool.SHA256[platform]) {
+				problems = append(problems, fmt.Sprintf("%s.sha256[%s]: %q is not a SHA-256 hex digest", field, platform, tool.SHA256[platform]))
+			}
+		}
+	}
+	return problems
+}
+
+var sha256HexRe = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
 func validate(c *Config) error {
 	var problems []string
 	if c.Cluster.NodeSSHPort < 0 || c.Cluster.NodeSSHPort > 65535 {
@@ -600,6 +928,10 @@ func validate(c *Config) error {
 		value string
 	}{
 		{"volsync.check_image", c.Volsync.CheckImage},
+		{"audit.path", c.Audit.Path},
+		{"audit.namespace", c.Audit.Namespace},
+		{"secret_backend.env_map_file", c.SecretBackend.EnvMapFile},
+		{"secret_backend.sops_file", c.SecretBackend.SopsFile},
 	} {
 		if field.value != "" && strings.TrimSpace(field.value) == "" {
 			problems = append(problems, fmt.Sprintf("%s: must not be blank", field.name))
@@ -622,6 +954,19 @@ func validate(c *Config) error {
 			problems = append(problems, fmt.Sprintf("secrets.%s: %q is not a valid secret reference (expected op://, env://, file://, cmd://, or literal://)", key, ref))
 		}
 	}
+	switch c.SecretBackend.Type {
+	case "", secrets.BackendOnePassword:
+	case secrets.BackendEnv:
+		if c.SecretBackend.EnvMapFile == "" {
+			problems = append(problems, "secret_backend.env_map_file: required when secret_backend.type is env")
+		}
+	case secrets.BackendSops:
+		if c.SecretBackend.SopsFile == "" {
+			problems = append(problems, "secret_backend.sops_file: required when secret_backend.type is sops")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("secret_backend.type: %q is not supported (use %s)", c.SecretBackend.Type, strings.Join(secrets.BackendNames, ", ")))
+	}
 	for _, store := range []struct {
 		name string
 		cfg  StoreConfig
@@ -639,6 +984,9 @@ func validate(c *Config) error {
 		{"cluster.pod_cidr", c.Cluster.PodCIDR},
 		{"cluster.service_cidr", c.Cluster.ServiceCIDR},
 		{"cluster.node_subnet", c.Cluster.NodeSubnet},
+		{"cluster.ipv6.pod_cidr", c.Cluster.IPv6.PodCIDR},
+		{"cluster.ipv6.service_cidr", c.Cluster.IPv6.ServiceCIDR},
+		{"cluster.ipv6.node_subnet", c.Cluster.IPv6.NodeSubnet},
 	} {
 		if cidr.value != "" {
 			if _, err := netip.ParsePrefix(cidr.value); err != nil {
@@ -646,6 +994,21 @@ func validate(c *Config) error {
 			}
 		}
 	}
+	problems = append(problems, validateDualStack(c.Cluster)...)
+	if pool := c.Cluster.DHCPPool; pool.Start != "" || pool.End != "" {
+		start, startErr := netip.ParseAddr(pool.Start)
+		end, endErr := netip.ParseAddr(pool.End)
+		switch {
+		case !pool.Configured():
+			problems = append(problems, "cluster.dhcp_pool: start and end must both be set")
+		case startErr != nil || !start.Is4():
+			problems = append(problems, fmt.Sprintf("cluster.dhcp_pool.start: %q is not an IPv4 address", pool.Start))
+		case endErr != nil || !end.Is4():
+			problems = append(problems, fmt.Sprintf("cluster.dhcp_pool.end: %q is not an IPv4 address", pool.End))
+		case end.Less(start):
+			problems = append(problems, fmt.Sprintf("cluster.dhcp_pool: end %s is before start %s", pool.End, pool.Start))
+		}
+	}
 	for _, duration := range []struct {
 		name  string
 		value string
@@ -660,6 +1023,8 @@ func validate(c *Config) error {
 			problems = append(problems, fmt.Sprintf("%s: %q is not a positive duration", duration.name, duration.value))
 		}
 	}
+	problems = append(problems, validateTLSProbe(c.Cluster.TLSProbe)...)
+	problems = append(problems, validateToolchain(c.Toolchain)...)
 	legacyOSDModes := []struct {
 		name string
 		mode string
@@ -684,6 +1049,11 @@ func validate(c *Config) error {
 			mode string
 		}{fmt.Sprintf("cluster.nodes[%s].vm.providers.vsphere.ceph", n.Name), n.VM.Providers.VSphere.Ceph.Mode})
 	}
+	switch c.Cluster.Talos.DiskEncryption.Provider {
+	case "", DiskEncryptionNodeID, DiskEncryptionStatic, DiskEncryptionTPM:
+	default:
+		problems = append(problems, fmt.Sprintf("cluster.talos.disk_encryption.provider: %q is not supported (use nodeid, static, or tpm)", c.Cluster.Talos.DiskEncryption.Provider))
+	}
 	for _, cm := range legacyOSDModes {
 		switch cm.mode {
 		case "", "passthrough", "virtual", "none":
@@ -691,6 +1061,32 @@ func validate(c *Config) error {
 			problems = append(problems, fmt.Sprintf("%s.mode: %q is not supported (use passthrough, virtual, or none)", cm.name, cm.mode))
 		}
 	}
+	switch discovery := c.Hypervisors.TrueNAS.IPDiscovery; discovery.Method {
+	case "", "arp":
+	case "dhcp":
+		if discovery.LeasesURL == "" {
+			problems = append(problems, "hypervisors.truenas.ip_discovery.leases_url: required for method dhcp")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("hypervisors.truenas.ip_discovery.method: %q is not supported (use arp or dhcp)", discovery.Method))
+	}
+	for _, naming := range []struct {
+		name   string
+		policy NamingPolicy
+	}{
+		{"hypervisors.proxmox.naming", c.Hypervisors.Proxmox.Naming},
+		{"hypervisors.truenas.naming", c.Hypervisors.TrueNAS.Naming},
+		{"hypervisors.vsphere.naming", c.Hypervisors.VSphere.Naming},
+	} {
+		if naming.policy.Pattern != "" {
+			if _, err := regexp.Compile(naming.policy.Pattern); err != nil {
+				problems = append(problems, fmt.Sprintf("%s.pattern: %q is not a valid regular expression: %v", naming.name, naming.policy.Pattern, err))
+			}
+		}
+		if naming.policy.MaxLength < 0 {
+			problems = append(problems, fmt.Sprintf("%s.max_length: must not be negative", naming.name))
+		}
+	}
 	switch strings.ToLower(c.Hypervisors.Default) {
 	case "", "proxmox", "truenas", "vsphere":
 	default:
@@ -763,10 +1159,14 @@ func (c *Config) ResolveSecretSilent(key string) string {
 
 // UsesOpReferences reports whether any effective secret reference or state
 // store uses the 1Password backend — i.e. whether the `op` CLI is required.
+// op:// secret references only count while the 1password secret backend reads
+// them.
 func (c *Config) UsesOpReferences() bool {
-	for key := range defaultSecretRefs {
-		if strings.HasPrefix(c.SecretRef(key), "op://") {
-			return true
+	if secrets.CurrentBackendName() == secrets.BackendOnePassword {
+		for key := range defaultSecretRefs {
+			if strings.HasPrefix(c.SecretRef(key), "op://") {
+				return true
+			}
 		}
 	}
 	return c.State.Kubeconfig.Backend == "op" || c.State.PKI.Backend == "op"
@@ -808,16 +1208,105 @@ func (c *Config) ProvisioningNodeByName(name string) (Node, bool) {
 	return Node{}, false
 }
 
-// NodeByIP returns the configured node with the given IP address.
+// NodeByIP returns the configured node with the given address, matching
+// either address family.
 func (c *Config) NodeByIP(ip string) (Node, bool) {
 	for _, n := range c.Cluster.Nodes {
-		if n.IP == ip {
+		if n.HasAddress(ip) {
 			return n, true
 		}
 	}
 	return Node{}, false
 }
 
+// Addresses returns the node's addresses, IPv4 first.
+func (n Node) Addresses() []string {
+	var addresses []string
+	for _, address := range []string{n.IP, n.IPv6} {
+		if address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// HasAddress reports whether ip is one of the node's addresses. Addresses
+// compare as IPs, so "fd00::a" matches "fd00:0::0a".
+func (n Node) HasAddress(ip string) bool {
+	want, err := netip.ParseAddr(strings.Trim(ip, "[]"))
+	for _, address := range n.Addresses() {
+		if address == ip {
+			return true
+		}
+		if got, parseErr := netip.ParseAddr(address); err == nil && parseErr == nil && got.Unmap() == want.Unmap() {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderAddresses returns addresses with IPv4 entries first, otherwise in
+// their original order; anything unparseable sorts last.
+func OrderAddresses(addresses []string) []string {
+	rank := func(address string) int {
+		ip, err := netip.ParseAddr(address)
+		switch {
+		case err != nil:
+			return 2
+		case ip.Unmap().Is4():
+			return 0
+		default:
+			return 1
+		}
+	}
+	ordered := append([]string(nil), addresses...)
+	sort.SliceStable(ordered, func(i, j int) bool { return rank(ordered[i]) < rank(ordered[j]) })
+	return ordered
+}
+
+// PreferredAddress picks the address to display and connect to for a node
+// with several: the first IPv4 address, else the first address.
+func PreferredAddress(addresses []string) string {
+	ordered := OrderAddresses(addresses)
+	if len(ordered) == 0 {
+		return ""
+	}
+	return ordered[0]
+}
+
+// PodCIDRs returns the pod CIDRs in family order: IPv4, then IPv6 when
+// cluster.dual_stack is on.
+func (c *Config) PodCIDRs() []string {
+	return c.Cluster.familyPair(c.Cluster.PodCIDR, c.Cluster.IPv6.PodCIDR)
+}
+
+// ServiceCIDRs returns the service CIDRs in family order.
+func (c *Config) ServiceCIDRs() []string {
+	return c.Cluster.familyPair(c.Cluster.ServiceCIDR, c.Cluster.IPv6.ServiceCIDR)
+}
+
+// NodeSubnets returns the node subnets in family order.
+func (c *Config) NodeSubnets() []string {
+	return c.Cluster.familyPair(c.Cluster.NodeSubnet, c.Cluster.IPv6.NodeSubnet)
+}
+
+// NodeAddresses returns the addresses a node is rendered with: both
+// families with cluster.dual_stack, only IPv4 otherwise.
+func (c *Config) NodeAddresses(n Node) []string {
+	return c.Cluster.familyPair(n.IP, n.IPv6)
+}
+
+func (c ClusterConfig) familyPair(ipv4, ipv6 string) []string {
+	var values []string
+	if ipv4 != "" {
+		values = append(values, ipv4)
+	}
+	if c.DualStack && ipv6 != "" {
+		values = append(values, ipv6)
+	}
+	return values
+}
+
 // ClusterNameWithDefault returns cluster.name, or the historical kubeconfig /
 // template name when cluster.name is unset. applyDefaults intentionally does
 // not write the default into Cluster.Name so config show can distinguish an
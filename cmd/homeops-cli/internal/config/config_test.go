@@ -269,10 +269,25 @@ func TestLoadFileRejectsBadConfig(t *testing.T) {
 		{"invalid reference", "secrets:\n  truenas_host: just-a-string\n", "not a valid secret reference"},
 		{"bad store backend", "state:\n  pki:\n    backend: s3\n", "not supported"},
 		{"bad hypervisor", "hypervisors:\n  default: xen\n", "not supported"},
+		{"bad naming pattern", "hypervisors:\n  truenas:\n    naming:\n      pattern: '^k8s[0-9'\n", "hypervisors.truenas.naming.pattern"},
+		{"negative naming max length", "hypervisors:\n  vsphere:\n    naming:\n      max_length: -1\n", "hypervisors.vsphere.naming.max_length"},
+		{"bad ip discovery method", "hypervisors:\n  truenas:\n    ip_discovery:\n      method: mdns\n", "hypervisors.truenas.ip_discovery.method"},
+		{"dhcp ip discovery without leases", "hypervisors:\n  truenas:\n    ip_discovery:\n      method: dhcp\n", "hypervisors.truenas.ip_discovery.leases_url: required"},
 		{"negative numeric vm knob", "hypervisors:\n  proxmox:\n    vm:\n      network_queues: -1\n", "must not be negative"},
+		{"half dhcp pool", "cluster:\n  dhcp_pool:\n    start: 192.168.120.100\n", "cluster.dhcp_pool"},
+		{"reversed dhcp pool", "cluster:\n  dhcp_pool:\n    start: 192.168.120.200\n    end: 192.168.120.100\n", "before start"},
+		{"bad tls probe gateway", "cluster:\n  tls_probe:\n    gateways: [kgateway-external]\n", "cluster.tls_probe.gateways"},
+		{"bad tls probe header", "cluster:\n  tls_probe:\n    hosts:\n      app.example.com:\n        allow_missing_headers: [X-XSS-Protection]\n", "cluster.tls_probe.hosts[app.example.com].allow_missing_headers"},
+		{"unverifiable tool pin", "toolchain:\n  tools:\n    - name: kubectl\n      version: v1.36.2\n      url: https://dl.k8s.io/{version}/kubectl\n", "toolchain.tools[kubectl]: set sha256 or checksum_url"},
+		{"bad tool digest", "toolchain:\n  tools:\n    - name: kubectl\n      version: v1.36.2\n      url: https://dl.k8s.io/{version}/kubectl\n      sha256:\n        linux/amd64: abc\n", "toolchain.tools[kubectl].sha256[linux/amd64]"},
 		{"bad pod cidr", "cluster:\n  pod_cidr: not-a-cidr\n", "cluster.pod_cidr"},
 		{"bad service cidr", "cluster:\n  service_cidr: not-a-cidr\n", "cluster.service_cidr"},
 		{"bad node subnet", "cluster:\n  node_subnet: not-a-cidr\n", "cluster.node_subnet"},
+		{"dual-stack without ipv6 pods", "cluster:\n  dual_stack: true\n  ipv6:\n    service_cidr: fd00:10:43::/112\n", "cluster.ipv6.pod_cidr: required"},
+		{"dual-stack ipv4 in ipv6 slot", "cluster:\n  dual_stack: true\n  ipv6:\n    pod_cidr: 10.50.0.0/16\n    service_cidr: fd00:10:43::/112\n", "must be an IPv6 CIDR"},
+		{"dual-stack bad ipv6 cidr", "cluster:\n  dual_stack: true\n  ipv6:\n    pod_cidr: fd00::/zz\n    service_cidr: fd00:10:43::/112\n", "cluster.ipv6.pod_cidr"},
+		{"dual-stack ipv4 vip", "cluster:\n  dual_stack: true\n  ipv6:\n    pod_cidr: fd00:10:42::/56\n    service_cidr: fd00:10:43::/112\n    control_plane_vip: 192.168.122.100\n", "cluster.ipv6.control_plane_vip"},
+		{"dual-stack ipv4 node address", "cluster:\n  dual_stack: true\n  ipv6:\n    pod_cidr: fd00:10:42::/56\n    service_cidr: fd00:10:43::/112\n  nodes:\n    - name: k8s-0\n      ip: 10.0.0.10\n      ipv6: 10.0.0.11\n", "cluster.nodes[k8s-0].ipv6"},
 		{"bad kubelet max pods", "cluster:\n  kubelet:\n    max_pods: -1\n", "cluster.kubelet.max_pods"},
 		{"bad node ssh port", "cluster:\n  node_ssh_port: 70000\n", "cluster.node_ssh_port"},
 		{"removed rook config", "cluster:\n  rook:\n    namespace: rook-ceph\n", "field rook not found"},
@@ -536,3 +551,47 @@ cluster:
 	assert.Equal(t, "10.0.0.19", n9.IP)
 	assert.Equal(t, 209, n9.VM.VMID)
 }
+
+func TestLoadFileDualStack(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "homeops.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+cluster:
+  dual_stack: true
+  ipv6:
+    pod_cidr: fd00:10:42::/56
+    service_cidr: fd00:10:43::/112
+    node_subnet: fd00:192:168:122::/64
+  nodes:
+    - name: k8s-0
+      ip: 192.168.122.10
+      ipv6: fd00:192:168:122::10
+`), 0o644))
+
+	c, err := LoadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{c.Cluster.PodCIDR, "fd00:10:42::/56"}, c.PodCIDRs())
+	assert.Equal(t, []string{c.Cluster.ServiceCIDR, "fd00:10:43::/112"}, c.ServiceCIDRs())
+
+	n0, ok := c.NodeByIP("fd00:192:168:122:0::10")
+	require.True(t, ok, "IPv6 lookups compare addresses, not strings")
+	assert.Equal(t, "k8s-0", n0.Name)
+	_, ok = c.NodeByIP("[fd00:192:168:122::10]")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"192.168.122.10", "fd00:192:168:122::10"}, c.NodeAddresses(n0))
+
+	n1, ok := c.NodeByName("k8s-1")
+	require.True(t, ok)
+	assert.Equal(t, []string{"192.168.122.11"}, c.NodeAddresses(n1), "nodes without ipv6 stay single-family")
+
+	c.Cluster.DualStack = false
+	assert.Equal(t, []string{c.Cluster.PodCIDR}, c.PodCIDRs(), "ipv6 settings are inert until dual_stack is on")
+	assert.Equal(t, []string{"192.168.122.10"}, c.NodeAddresses(n0))
+}
+
+func TestPreferredAddress(t *testing.T) {
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2", "fd00::1", "bogus"}, OrderAddresses([]string{"fd00::1", "bogus", "10.0.0.1", "10.0.0.2"}))
+	assert.Equal(t, "10.0.0.1", PreferredAddress([]string{"fd00::1", "10.0.0.1"}))
+	assert.Equal(t, "fd00::1", PreferredAddress([]string{"fd00::1"}))
+	assert.Empty(t, PreferredAddress(nil))
+}
@@ -30,6 +30,7 @@ const (
 	DefaultEtcdUploadKeep  = 14
 	DefaultDrainTimeout    = "5m"
 	DefaultMaintenanceWait = "10m"
+	DefaultAuditNamespace  = "default"
 )
 
 var (
@@ -167,6 +168,13 @@ func defaultStateDir() string {
 	return filepath.Join(".homeops", "state")
 }
 
+func defaultAuditLogPath() string {
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "state", "homeops", "audit.log")
+	}
+	return filepath.Join(".homeops", "audit.log")
+}
+
 // defaultConfig returns the fully-portable built-in configuration: secrets
 // from environment variables, cluster state on local disk.
 func defaultConfig() *Config {
@@ -307,6 +315,12 @@ func applyDefaults(c *Config) {
 	if c.Volsync.CheckImage == "" {
 		c.Volsync.CheckImage = constants.DefaultVolsyncCheckImage
 	}
+	if c.Audit.Path == "" {
+		c.Audit.Path = defaultAuditLogPath()
+	}
+	if c.Audit.Namespace == "" {
+		c.Audit.Namespace = DefaultAuditNamespace
+	}
 }
 
 func applyKubeletDefaults(k *KubeletConfig) {
@@ -461,6 +475,9 @@ func mergeNode(base, override Node) Node {
 	if override.IP != "" {
 		out.IP = override.IP
 	}
+	if override.IPv6 != "" {
+		out.IPv6 = override.IPv6
+	}
 	out.VM = mergeVMProfile(out.VM, override.VM)
 	return out
 }
@@ -43,11 +43,20 @@ const (
 	KeyTalosSecretboxSecret = "talos_secretbox_encryption_secret"
 	KeyTalosSAKey           = "talos_service_account_key"
 
+	// Talos LUKS2 passphrase (cluster.talos.disk_encryption.provider static)
+	KeyTalosDiskEncryptionKey = "talos_disk_encryption_key" // #nosec G101 -- semantic config key string only, not a secret value
+
 	// Bootstrap workload secrets (used by the embedded bootstrap manifests;
 	// override the templates via templates.dir if your cluster differs)
 	KeyOpCredentialsJSON  = "op_credentials_json"
 	KeyOpConnectToken     = "op_connect_token"
 	KeyCloudflareTunnelID = "cloudflare_tunnel_id"
+
+	// Day-2 API credentials
+	KeyCloudflareDNSToken = "cloudflare_dns_token" // #nosec G101 -- semantic config key string only, not a secret value
+
+	// Completion notifications (notify.webhook)
+	KeyNotifyWebhookURL = "notify_webhook_url"
 )
 
 // defaultSecretRefs is the canonical key registry with portable defaults.
@@ -86,9 +95,15 @@ var defaultSecretRefs = map[string]string{ // #nosec G101 -- map contains backen
 	KeyTalosSecretboxSecret: "env://TALOS_SECRETBOX_ENCRYPTION_SECRET",
 	KeyTalosSAKey:           "env://TALOS_SERVICE_ACCOUNT_KEY",
 
+	KeyTalosDiskEncryptionKey: "env://TALOS_DISK_ENCRYPTION_KEY",
+
 	KeyOpCredentialsJSON:  "env://OP_CREDENTIALS_JSON",
 	KeyOpConnectToken:     "env://OP_CONNECT_TOKEN",
 	KeyCloudflareTunnelID: "env://CLOUDFLARE_TUNNEL_ID",
+
+	KeyCloudflareDNSToken: "env://CLOUDFLARE_DNS_TOKEN",
+
+	KeyNotifyWebhookURL: "env://HOMEOPS_NOTIFY_WEBHOOK_URL",
 }
 
 // KnownSecretKeys returns the canonical secret keys (sorted) — used by
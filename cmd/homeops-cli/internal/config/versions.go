@@ -29,8 +29,26 @@ type VersionConfig struct {
 	FlatcarVersion string // Flatcar stable release version (e.g. "current" or "4152.2.0")
 	KubeVipVersion string // kube-vip image tag (e.g. "v1.2.0")
 	PauseImage     string // sandbox/pause image (e.g. "registry.k8s.io/pause:3.10")
+
+	// Sources records the SUC Plan files the versions above were read from,
+	// so freshness checks compare against exactly what the loader saw. Empty
+	// when the hardcoded fallback versions are in use.
+	Sources []VersionSource
+}
+
+// VersionSource is one SUC Plan file and the version parsed from it.
+type VersionSource struct {
+	Component string // "kubernetes" or "flatcar"
+	Path      string
+	Version   string // "" when the plan is absent or unparseable
 }
 
+// Version source components.
+const (
+	VersionComponentKubernetes = "kubernetes"
+	VersionComponentFlatcar    = "flatcar"
+)
+
 const (
 	defaultFlatcarVersion = "current"
 	defaultKubeVipVersion = "v1.2.0"
@@ -68,6 +86,10 @@ func LoadVersionsFromSystemUpgrade(rootDir string) (*VersionConfig, error) {
 		// if the plan is missing (e.g. pre-migration checkouts).
 		FlatcarVersion: loadFlatcarVersionFromPlan(rootDir),
 	}
+	config.Sources = []VersionSource{
+		{Component: VersionComponentKubernetes, Path: kubeadmPlanPath(rootDir), Version: config.KubernetesVersion},
+		{Component: VersionComponentFlatcar, Path: flatcarPlanPath(rootDir), Version: config.FlatcarVersion},
+	}
 	applyFlatcarDefaults(config)
 
 	logger.Debug("Loaded versions from kubeadm Plan:")
@@ -82,8 +104,7 @@ func LoadVersionsFromSystemUpgrade(rootDir string) (*VersionConfig, error) {
 // spec.version. Uses minimal string scraping rather than YAML
 // unmarshalling so it survives apiVersion/kind drift over time.
 func loadKubernetesVersionFromPlan(rootDir string) (string, error) {
-	planPath := filepath.Join(rootDir, "kubernetes", "apps", "system-upgrade",
-		"kubeadm-upgrade", "app", "plan.yaml")
+	planPath := kubeadmPlanPath(rootDir)
 	data, err := os.ReadFile(planPath) // #nosec G304 -- plan path is built from the local repository root, not remote input
 	if err != nil {
 		return "", fmt.Errorf("failed to read kubeadm upgrade plan %s: %w", planPath, err)
@@ -108,8 +129,7 @@ func loadKubernetesVersionFromPlan(rootDir string) (string, error) {
 // "current" default applies) when the plan is absent or malformed — the OS
 // version pin is best-effort for provisioning, unlike the Kubernetes version.
 func loadFlatcarVersionFromPlan(rootDir string) string {
-	planPath := filepath.Join(rootDir, "kubernetes", "apps", "system-upgrade",
-		"flatcar-upgrade", "app", "plan.yaml")
+	planPath := flatcarPlanPath(rootDir)
 	data, err := os.ReadFile(planPath) // #nosec G304 -- plan path is built from the local repository root, not remote input
 	if err != nil {
 		return ""
@@ -128,6 +148,24 @@ func loadFlatcarVersionFromPlan(rootDir string) string {
 	return ""
 }
 
+func kubeadmPlanPath(rootDir string) string {
+	return filepath.Join(rootDir, "kubernetes", "apps", "system-upgrade", "kubeadm-upgrade", "app", "plan.yaml")
+}
+
+func flatcarPlanPath(rootDir string) string {
+	return filepath.Join(rootDir, "kubernetes", "apps", "system-upgrade", "flatcar-upgrade", "app", "plan.yaml")
+}
+
+// SourceFor returns the plan source recorded for component, if any.
+func (c *VersionConfig) SourceFor(component string) (VersionSource, bool) {
+	for _, source := range c.Sources {
+		if source.Component == component {
+			return source, true
+		}
+	}
+	return VersionSource{}, false
+}
+
 // isValidVersionFormat validates that the version string looks like a semantic version.
 func isValidVersionFormat(version string) bool {
 	versionRegex := regexp.MustCompile(`^v\d+\.\d+\.\d+(?:-[\w\.]+)?$`)
@@ -168,12 +206,12 @@ func GetVersions(rootDir string) *VersionConfig {
 
 	actualRootDir := rootDir
 	if rootDir == "." {
-		gitRoot, err := common.FindGitRoot(".")
+		repoRoot, err := common.RepoRoot()
 		if err != nil {
-			logger.Debug("Could not find git root, using current directory: %v", err)
+			logger.Debug("Could not find repository root, using current directory: %v", err)
 		} else {
-			actualRootDir = gitRoot
-			logger.Debug("Found git repository root: %s", gitRoot)
+			actualRootDir = repoRoot
+			logger.Debug("Found repository root: %s", repoRoot)
 		}
 	}
 
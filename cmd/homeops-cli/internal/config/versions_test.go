@@ -112,6 +112,42 @@ spec:
 		require.NoError(t, err)
 		assert.Equal(t, "v1.35.0", versions.KubernetesVersion)
 	})
+
+	t.Run("records plan sources", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		for dir, content := range map[string]string{
+			"kubeadm-upgrade": "spec:\n  version: v1.36.2\n",
+			"flatcar-upgrade": "spec:\n  version: \"4593.2.2\"\n",
+		} {
+			planDir := filepath.Join(tmpDir, "kubernetes", "apps", "system-upgrade", dir, "app")
+			require.NoError(t, os.MkdirAll(planDir, 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(planDir, "plan.yaml"), []byte(content), 0644))
+		}
+
+		versions, err := LoadVersionsFromSystemUpgrade(tmpDir)
+		require.NoError(t, err)
+		k8s, ok := versions.SourceFor(VersionComponentKubernetes)
+		require.True(t, ok)
+		assert.Equal(t, "v1.36.2", k8s.Version)
+		assert.Equal(t, filepath.Join(tmpDir, "kubernetes", "apps", "system-upgrade", "kubeadm-upgrade", "app", "plan.yaml"), k8s.Path)
+		flatcar, ok := versions.SourceFor(VersionComponentFlatcar)
+		require.True(t, ok)
+		assert.Equal(t, "4593.2.2", flatcar.Version)
+	})
+
+	t.Run("missing flatcar plan records empty source version", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		planDir := filepath.Join(tmpDir, "kubernetes", "apps", "system-upgrade", "kubeadm-upgrade", "app")
+		require.NoError(t, os.MkdirAll(planDir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(planDir, "plan.yaml"), []byte("spec:\n  version: v1.36.2\n"), 0644))
+
+		versions, err := LoadVersionsFromSystemUpgrade(tmpDir)
+		require.NoError(t, err)
+		flatcar, ok := versions.SourceFor(VersionComponentFlatcar)
+		require.True(t, ok)
+		assert.Empty(t, flatcar.Version)
+		assert.Equal(t, defaultFlatcarVersion, versions.FlatcarVersion)
+	})
 }
 
 func TestApplyFlatcarDefaults(t *testing.T) {
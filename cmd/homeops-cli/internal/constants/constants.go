@@ -11,6 +11,15 @@ const (
 	EnvTrueNASHost   = "TRUENAS_HOST"
 	EnvTrueNASAPIKey = "TRUENAS_API_KEY" // #nosec G101 -- environment variable name only, not a secret value
 	EnvSPICEPassword = "SPICE_PASSWORD"
+	// EnvTrueNASCA: a PEM bundle to trust for the TrueNAS websocket (e.g. the
+	// NAS's self-signed certificate), in addition to the system pool.
+	EnvTrueNASCA = "TRUENAS_CA"
+	// EnvTrueNASInsecure: set to "true" to DISABLE TLS verification of the
+	// TrueNAS endpoint. Defaults to verifying (secure).
+	EnvTrueNASInsecure = "TRUENAS_INSECURE"
+	// EnvTrueNASServerName: the name to verify the TrueNAS certificate
+	// against when TRUENAS_HOST is an IP or another name.
+	EnvTrueNASServerName = "TRUENAS_TLS_SERVER_NAME"
 
 	EnvVSphereHost     = "VSPHERE_HOST"
 	EnvVSphereUsername = "VSPHERE_USERNAME"
@@ -35,6 +44,16 @@ const (
 	EnvDebug             = "DEBUG"
 	EnvLogLevel          = "LOG_LEVEL"
 	EnvHomeOpsNoInteract = "HOMEOPS_NO_INTERACTIVE"
+	EnvHomeOpsRoot       = "HOMEOPS_ROOT"
+	// 1Password credentials for non-interactive runs: a service account
+	// token the op CLI reads itself, or a Connect server queried directly.
+	EnvOpServiceAccountToken = "OP_SERVICE_ACCOUNT_TOKEN" // #nosec G101 -- environment variable name only, not a secret value
+	EnvOpConnectHost         = "OP_CONNECT_HOST"
+	EnvOpConnectToken        = "OP_CONNECT_TOKEN" // #nosec G101 -- environment variable name only, not a secret value
+	// EnvMetricsFile and EnvPushgatewayURL are the defaults of --metrics-file
+	// and --pushgateway-url: where a command's step timings are exported.
+	EnvMetricsFile    = "HOMEOPS_METRICS_FILE"
+	EnvPushgatewayURL = "HOMEOPS_PUSHGATEWAY_URL"
 
 	// Flatcar / kubeadm template substitution variable names. These are the keys
 	// expected by the embedded flatcar templates ({{ ENV.<NAME> }}).
@@ -47,6 +66,7 @@ const (
 	EnvNodeMAC          = "NODE_MAC"
 	EnvNodeName         = "NODE_NAME"
 	EnvNodeIP           = "NODE_IP"
+	EnvNodeIPs          = "NODE_IPS"
 	EnvNode0IP          = "NODE0_IP"
 	EnvNode1IP          = "NODE1_IP"
 	EnvNode2IP          = "NODE2_IP"
@@ -158,6 +178,7 @@ const (
 	BootstrapFluxMaxWait       = 900  // 15 minutes max for Flux reconciliation
 	BootstrapNodeMaxWait       = 1200 // 20 minutes max for nodes
 	BootstrapKubeconfigMaxWait = 300  // 5 minutes max for kubeconfig
+	BootstrapGatewayMaxWait    = 300  // 5 minutes max for a Gateway to be Programmed
 
 	// Legacy constants for backward compatibility (converted to use new approach)
 	BootstrapExtSecInstallAttempts = 12 // 1 minute to check if deployment exists
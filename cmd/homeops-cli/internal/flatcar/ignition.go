@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -51,6 +52,7 @@ var (
 type NodeEnv struct {
 	NodeName          string // NODE_NAME (e.g. k8s-0)
 	NodeIP            string // NODE_IP
+	NodeIPs           string // NODE_IPS kubelet node-ip list (both families with cluster.dual_stack)
 	Node0IP           string // NODE0_IP
 	Node1IP           string // NODE1_IP
 	Node2IP           string // NODE2_IP
@@ -64,8 +66,8 @@ type NodeEnv struct {
 	K8sEndpoint       string // K8S_ENDPOINT (apiserver cert SAN DNS, e.g. k8s.<domain>; sourced from op)
 	SSHAuthorizedKey  string // SSH_AUTHORIZED_KEY (node access public key; sourced from op)
 	ClusterName       string // CLUSTER_NAME
-	PodCIDR           string // POD_CIDR
-	ServiceCIDR       string // SERVICE_CIDR
+	PodCIDR           string // POD_CIDR (comma-separated pair with cluster.dual_stack)
+	ServiceCIDR       string // SERVICE_CIDR (comma-separated pair with cluster.dual_stack)
 	DNSDomain         string // DNS_DOMAIN / kubelet clusterDomain
 	ClusterDNS        string // CLUSTER_DNS derived from SERVICE_CIDR
 	ExtraCertSANs     string // EXTRA_CERT_SANS pre-rendered kubeadm YAML list
@@ -93,6 +95,7 @@ func (e NodeEnv) envMap() map[string]string {
 	}
 	add(constants.EnvNodeName, e.NodeName)
 	add(constants.EnvNodeIP, e.NodeIP)
+	add(constants.EnvNodeIPs, e.NodeIPs)
 	add(constants.EnvNode0IP, e.Node0IP)
 	add(constants.EnvNode1IP, e.Node1IP)
 	add(constants.EnvNode2IP, e.Node2IP)
@@ -127,11 +130,17 @@ func (e NodeEnv) withClusterDefaults() NodeEnv {
 	if e.ClusterName == "" {
 		e.ClusterName = cfg.ClusterNameWithDefault()
 	}
+	if e.NodeIPs == "" {
+		e.NodeIPs = e.NodeIP
+		if node, ok := cfg.NodeByIP(e.NodeIP); ok && e.NodeIP != "" {
+			e.NodeIPs = strings.Join(cfg.NodeAddresses(node), ",")
+		}
+	}
 	if e.PodCIDR == "" {
-		e.PodCIDR = cfg.Cluster.PodCIDR
+		e.PodCIDR = strings.Join(cfg.PodCIDRs(), ",")
 	}
 	if e.ServiceCIDR == "" {
-		e.ServiceCIDR = cfg.Cluster.ServiceCIDR
+		e.ServiceCIDR = strings.Join(cfg.ServiceCIDRs(), ",")
 	}
 	if e.DNSDomain == "" {
 		e.DNSDomain = cfg.Cluster.DNSDomain
@@ -140,7 +149,11 @@ func (e NodeEnv) withClusterDefaults() NodeEnv {
 		e.ClusterDNS = cfg.ClusterDNS()
 	}
 	if e.ExtraCertSANs == "" {
-		e.ExtraCertSANs = formatFlatcarCertSANs(cfg.Cluster.ExtraCertSANs)
+		sans := cfg.Cluster.ExtraCertSANs
+		if cfg.Cluster.DualStack && cfg.Cluster.IPv6.ControlPlaneVIP != "" {
+			sans = append(slices.Clone(sans), cfg.Cluster.IPv6.ControlPlaneVIP)
+		}
+		e.ExtraCertSANs = formatFlatcarCertSANs(sans)
 	}
 	if e.KubeletMaxPods == "" {
 		e.KubeletMaxPods = strconv.Itoa(cfg.Cluster.Kubelet.MaxPods)
@@ -243,6 +243,38 @@ func TestRenderKubeadmInitConfigUsesClusterEnvironmentFields(t *testing.T) {
 	assert.Contains(t, out, "imageGCLowThresholdPercent: 55")
 }
 
+func TestRenderKubeadmInitConfigDualStack(t *testing.T) {
+	cluster := config.ClusterConfig{
+		PodCIDR:     "10.244.0.0/16",
+		ServiceCIDR: "10.96.0.0/12",
+		IPv6: config.ClusterIPv6Config{
+			PodCIDR:         "fd00:10:244::/56",
+			ServiceCIDR:     "fd00:10:96::/112",
+			ControlPlaneVIP: "fd00::253",
+		},
+		Nodes: []config.Node{{Name: "k8s-0", IP: "192.168.122.10", IPv6: "fd00::10"}},
+	}
+	restore := config.SetForTesting(&config.Config{Cluster: cluster})
+	out, err := RenderKubeadmInitConfig(sampleEnv())
+	restore()
+	require.NoError(t, err)
+	assert.Contains(t, out, "podSubnet: 10.244.0.0/16\n", "ipv6 settings are ignored until dual_stack is on")
+	assert.Contains(t, out, `value: "192.168.122.10"`)
+	assert.NotContains(t, out, "fd00")
+
+	cluster.DualStack = true
+	restore = config.SetForTesting(&config.Config{Cluster: cluster})
+	defer restore()
+	out, err = RenderKubeadmInitConfig(sampleEnv())
+	require.NoError(t, err)
+	assert.Contains(t, out, "podSubnet: 10.244.0.0/16,fd00:10:244::/56")
+	assert.Contains(t, out, "serviceSubnet: 10.96.0.0/12,fd00:10:96::/112")
+	assert.Contains(t, out, `value: "192.168.122.10,fd00::10"`, "kubelet gets both node IPs")
+	assert.Contains(t, out, `advertiseAddress: "192.168.122.10"`, "the apiserver still advertises IPv4")
+	assert.Contains(t, out, "    - fd00::253")
+	assert.Contains(t, out, "- 10.96.0.10", "cluster DNS stays on the IPv4 service range")
+}
+
 func TestRenderKubeadmJoinConfig(t *testing.T) {
 	env := sampleEnv()
 	env.NodeName = "k8s-1"
@@ -0,0 +1,90 @@
+package iso
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"time"
+
+	"homeops-cli/internal/common"
+)
+
+var sha256HexRe = regexp.MustCompile(`(?i)\b([0-9a-f]{64})\b`)
+
+// isoHTTPClient bounds reference downloads like the ISO downloads in
+// cmd/talos: 30m accommodates slow links.
+var isoHTTPClient = &http.Client{Timeout: 30 * time.Minute}
+
+var urlDigestFn = URLDigest
+
+// Digest is an ISO's SHA256 and size.
+type Digest struct {
+	SHA256 string
+	Size   int64
+}
+
+// CopyAndDigest copies r to w and returns the digest of what was copied.
+// contentLength is the response's Content-Length (<= 0 when unknown); a body
+// shorter or longer than it is reported as a truncated download.
+func CopyAndDigest(w io.Writer, r io.Reader, contentLength int64) (Digest, error) {
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(w, hash), r)
+	if err != nil {
+		return Digest{}, err
+	}
+	if contentLength > 0 && size != contentLength {
+		return Digest{}, fmt.Errorf("download truncated: got %d of %d bytes", size, contentLength)
+	}
+	return Digest{SHA256: hex.EncodeToString(hash.Sum(nil)), Size: size}, nil
+}
+
+// URLDigest downloads isoURL without keeping it and returns its digest.
+func URLDigest(isoURL string) (Digest, error) {
+	resp, err := isoHTTPClient.Get(isoURL)
+	if err != nil {
+		return Digest{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return Digest{}, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return CopyAndDigest(io.Discard, resp.Body, resp.ContentLength)
+}
+
+// FileDigest hashes the file at path.
+func FileDigest(path string) (Digest, error) {
+	file, err := os.Open(path) // #nosec G304 -- callers pass ISO paths they created
+	if err != nil {
+		return Digest{}, err
+	}
+	defer func() { _ = file.Close() }()
+	return CopyAndDigest(io.Discard, file, 0)
+}
+
+// VerifyFile checks that the file at path is still the ISO described by want.
+func VerifyFile(path string, want Digest) error {
+	got, err := FileDigest(path)
+	if err != nil {
+		return fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	if got.Size != want.Size || got.SHA256 != want.SHA256 {
+		return fmt.Errorf("ISO %s is corrupt: SHA256 %s (%d bytes), expected %s (%d bytes)", path, got.SHA256, got.Size, want.SHA256, want.Size)
+	}
+	return nil
+}
+
+func remoteSHA256(sshClient sshClient, remotePath string) (string, error) {
+	cmd := fmt.Sprintf("sha256sum %s | awk '{print $1}'", common.ShellQuote(remotePath))
+	out, err := sshClient.ExecuteCommand(cmd)
+	if err != nil {
+		return "", err
+	}
+	if m := sha256HexRe.FindString(out); m != "" {
+		return m, nil
+	}
+	return "", fmt.Errorf("remote sha256sum output did not contain a SHA256 digest")
+}
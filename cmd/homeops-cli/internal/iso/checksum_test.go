@@ -0,0 +1,36 @@
+package iso
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyAndDigest(t *testing.T) {
+	var out bytes.Buffer
+	digest, err := CopyAndDigest(&out, strings.NewReader("iso-bytes"), 9)
+	require.NoError(t, err)
+	assert.Equal(t, "iso-bytes", out.String())
+	assert.Equal(t, Digest{SHA256: "4bc485f29c8bda3640b8d904070e38e722d7acd9cba16f7a0ea8bedce2528178", Size: 9}, digest)
+
+	_, err = CopyAndDigest(&bytes.Buffer{}, strings.NewReader("iso"), 9)
+	assert.EqualError(t, err, "download truncated: got 3 of 9 bytes")
+}
+
+func TestVerifyFileReportsBothHashes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "talos.iso")
+	require.NoError(t, os.WriteFile(path, []byte("iso-bytes"), 0o600))
+	want, err := FileDigest(path)
+	require.NoError(t, err)
+	require.NoError(t, VerifyFile(path, want))
+
+	require.NoError(t, os.WriteFile(path, []byte("iso-bytez"), 0o600))
+	got, err := FileDigest(path)
+	require.NoError(t, err)
+	assert.EqualError(t, VerifyFile(path, want), "ISO "+path+" is corrupt: SHA256 "+got.SHA256+" (9 bytes), expected "+want.SHA256+" (9 bytes)")
+}
@@ -31,6 +31,10 @@ type DownloadConfig struct {
 	ISOURL         string
 	ISOStoragePath string // Path on TrueNAS where ISO should be stored
 	ISOFilename    string // Filename for the ISO (e.g., "metal-amd64.iso")
+
+	// Resume continues a partial ISO left at the path by an interrupted
+	// download instead of removing it first.
+	Resume bool
 }
 
 // Downloader handles ISO download operations
@@ -45,6 +49,7 @@ type sshClient interface {
 	VerifyFile(string) (bool, int64, error)
 	RemoveFile(string) error
 	DownloadISO(string, string) error
+	ResumeISODownload(string, string) error
 }
 
 var newSSHClient = func(config ssh.SSHConfig) sshClient {
@@ -97,10 +102,14 @@ func (d *Downloader) DownloadCustomISO(config DownloadConfig) error {
 	fullISOPath := filepath.Join(config.ISOStoragePath, config.ISOFilename)
 	d.logger.Debug("Full ISO path: %s", fullISOPath)
 
-	// Check if ISO already exists and remove it
+	// Check if ISO already exists and remove it, unless it is the partial
+	// download being resumed. A stale complete file survives a resume too;
+	// the checksum verification below catches it.
 	exists, size, err := sshClient.VerifyFile(fullISOPath)
 	if err != nil {
 		d.logger.Warn("Failed to check existing ISO file: %v", err)
+	} else if exists && config.Resume {
+		d.logger.Info("Resuming the ISO download after the %d bytes already present", size)
 	} else if exists {
 		d.logger.Info("Existing ISO found (size: %d bytes), removing it", size)
 		if err := sshClient.RemoveFile(fullISOPath); err != nil {
@@ -110,7 +119,11 @@ func (d *Downloader) DownloadCustomISO(config DownloadConfig) error {
 
 	// Download the new ISO
 	d.logger.Info("Downloading ISO from %s", config.ISOURL)
-	if err := sshClient.DownloadISO(config.ISOURL, fullISOPath); err != nil {
+	download := sshClient.DownloadISO
+	if config.Resume {
+		download = sshClient.ResumeISODownload
+	}
+	if err := download(config.ISOURL, fullISOPath); err != nil {
 		return fmt.Errorf("failed to download ISO: %w", err)
 	}
 
@@ -125,7 +138,7 @@ func (d *Downloader) DownloadCustomISO(config DownloadConfig) error {
 	if size == 0 {
 		return fmt.Errorf("downloaded ISO file is empty")
 	}
-	if err := d.verifyChecksumIfAvailable(config.ISOURL, fullISOPath, sshClient); err != nil {
+	if err := d.verifyChecksumIfAvailable(config.ISOURL, fullISOPath, size, sshClient); err != nil {
 		return err
 	}
 
@@ -133,8 +146,41 @@ func (d *Downloader) DownloadCustomISO(config DownloadConfig) error {
 	return nil
 }
 
-func (d *Downloader) verifyChecksumIfAvailable(isoURL, remotePath string, sshClient sshClient) error {
+// StoredISOSize returns the size in bytes of the ISO at config's storage
+// path and filename on TrueNAS, or 0 when it does not exist. ISOURL is not
+// used.
+func (d *Downloader) StoredISOSize(config DownloadConfig) (int64, error) {
+	sshClient := newSSHClient(ssh.SSHConfig{
+		Host:     config.TrueNASHost,
+		Username: config.TrueNASUsername,
+		Port:     config.TrueNASPort,
+		KeyPath:  config.TrueNASKeyPath,
+	})
+	if err := sshClient.Connect(); err != nil {
+		return 0, fmt.Errorf("failed to connect to TrueNAS: %w", err)
+	}
+	defer func() {
+		if closeErr := sshClient.Close(); closeErr != nil {
+			d.logger.Warn("Failed to close SSH connection: %v", closeErr)
+		}
+	}()
+
+	fullISOPath := filepath.Join(config.ISOStoragePath, config.ISOFilename)
+	exists, size, err := sshClient.VerifyFile(fullISOPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check %s: %w", fullISOPath, err)
+	}
+	if !exists {
+		return 0, nil
+	}
+	return size, nil
+}
+
+func (d *Downloader) verifyChecksumIfAvailable(isoURL, remotePath string, remoteSize int64, sshClient sshClient) error {
 	checksumURL, reason, ok := checksumURLForISO(isoURL)
+	if !ok && isTalosFactoryURL(isoURL) {
+		return d.verifyAgainstReferenceDownload(isoURL, remotePath, remoteSize, sshClient)
+	}
 	if !ok {
 		d.logger.Warn("No vendor checksum verification for ISO %s: %s", isoURL, reason)
 		return nil
@@ -154,17 +200,41 @@ func (d *Downloader) verifyChecksumIfAvailable(isoURL, remotePath string, sshCli
 		return fmt.Errorf("verify ISO SHA512 checksum for %s: %w", remotePath, err)
 	}
 	if !strings.EqualFold(expected, actual) {
-		return fmt.Errorf("SHA512 checksum mismatch for %s", remotePath)
+		return fmt.Errorf("SHA512 checksum mismatch for %s: TrueNAS has %s, %s lists %s", remotePath, actual, checksumURL, expected)
 	}
 	d.logger.Success("Verified ISO SHA512 checksum using %s", checksumURL)
 	return nil
 }
 
+// verifyAgainstReferenceDownload checks a Talos factory ISO, which has no
+// published checksum, against a second download of the same URL hashed
+// locally: a truncated or corrupted copy on the NAS differs from it.
+func (d *Downloader) verifyAgainstReferenceDownload(isoURL, remotePath string, remoteSize int64, sshClient sshClient) error {
+	d.logger.Info("Hashing %s locally to verify the copy on TrueNAS", isoURL)
+	want, err := urlDigestFn(isoURL)
+	if err != nil {
+		d.logger.Warn("Unable to download %s for a reference checksum: %v; ISO integrity not verified", isoURL, err)
+		return nil
+	}
+	if remoteSize != want.Size {
+		return fmt.Errorf("ISO %s on TrueNAS is %d bytes, but %s is %d bytes (SHA256 %s)", remotePath, remoteSize, isoURL, want.Size, want.SHA256)
+	}
+	actual, err := remoteSHA256(sshClient, remotePath)
+	if err != nil {
+		return fmt.Errorf("verify ISO SHA256 checksum for %s: %w", remotePath, err)
+	}
+	if !strings.EqualFold(want.SHA256, actual) {
+		return fmt.Errorf("SHA256 checksum mismatch for %s: TrueNAS has %s, %s is %s", remotePath, actual, isoURL, want.SHA256)
+	}
+	d.logger.Success("Verified ISO SHA256 checksum %s", want.SHA256)
+	return nil
+}
+
 func checksumURLForISO(isoURL string) (checksumURL, reason string, ok bool) {
 	switch {
 	case strings.Contains(isoURL, "release.flatcar-linux.net/") && strings.HasSuffix(isoURL, ".iso"):
 		return isoURL + ".sha512", "", true
-	case strings.Contains(isoURL, "factory.talos.dev/"):
+	case isTalosFactoryURL(isoURL):
 		// Talos factory URLs are generated from a schematic ID; that ID is the
 		// content-address of the submitted schematic, so the factory image is tied
 		// to the requested machine definition even though this endpoint does not
@@ -175,6 +245,10 @@ func checksumURLForISO(isoURL string) (checksumURL, reason string, ok bool) {
 	}
 }
 
+func isTalosFactoryURL(isoURL string) bool {
+	return strings.Contains(isoURL, "factory.talos.dev/")
+}
+
 func parseSHA512Checksum(doc []byte) (string, error) {
 	m := sha512HexRe.FindSubmatch(doc)
 	if len(m) != 2 {
@@ -26,6 +26,7 @@ type fakeSSHClient struct {
 	verifyCalls   []string
 	removeCalls   []string
 	downloadCalls [][2]string
+	resumeCalls   [][2]string
 	commandCalls  []string
 	connectCalls  int
 	closeCalls    int
@@ -61,6 +62,11 @@ func (f *fakeSSHClient) DownloadISO(url, path string) error {
 	return f.downloadErr
 }
 
+func (f *fakeSSHClient) ResumeISODownload(url, path string) error {
+	f.resumeCalls = append(f.resumeCalls, [2]string{url, path})
+	return f.downloadErr
+}
+
 func (f *fakeSSHClient) ExecuteCommand(command string) (string, error) {
 	f.commandCalls = append(f.commandCalls, command)
 	return f.commandOutput, f.commandErr
@@ -219,6 +225,28 @@ func TestDownloaderDownloadCustomISO(t *testing.T) {
 		assert.Equal(t, 1, fake.closeCalls)
 	})
 
+	t.Run("resume keeps the partial file", func(t *testing.T) {
+		fake := &fakeSSHClient{
+			verifyResults: []struct {
+				exists bool
+				size   int64
+				err    error
+			}{
+				{exists: true, size: 600, err: nil},
+				{exists: true, size: 1024, err: nil},
+			},
+		}
+		testutil.Swap(t, &newSSHClient, func(config ssh.SSHConfig) sshClient { return fake })
+
+		config := baseConfig
+		config.Resume = true
+		require.NoError(t, NewDownloader().DownloadCustomISO(config))
+		fullPath := filepath.Join(baseConfig.ISOStoragePath, baseConfig.ISOFilename)
+		assert.Empty(t, fake.removeCalls)
+		assert.Empty(t, fake.downloadCalls)
+		assert.Equal(t, [][2]string{{baseConfig.ISOURL, fullPath}}, fake.resumeCalls)
+	})
+
 	t.Run("connect failure", func(t *testing.T) {
 		fake := &fakeSSHClient{connectErr: errors.New("ssh unavailable")}
 		oldNewSSHClient := newSSHClient
@@ -303,6 +331,26 @@ func TestDownloaderDownloadCustomISO(t *testing.T) {
 	})
 }
 
+func TestDownloaderStoredISOSize(t *testing.T) {
+	config := DownloadConfig{TrueNASHost: "nas.local", TrueNASUsername: "root", TrueNASPort: "22", ISOStoragePath: "/mnt/tank/isos", ISOFilename: "test.iso"}
+	fake := &fakeSSHClient{verifyResults: []struct {
+		exists bool
+		size   int64
+		err    error
+	}{{exists: true, size: 1024}, {exists: false}}}
+	testutil.Swap(t, &newSSHClient, func(ssh.SSHConfig) sshClient { return fake })
+
+	size, err := NewDownloader().StoredISOSize(config)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1024), size)
+	size, err = NewDownloader().StoredISOSize(config)
+	require.NoError(t, err)
+	assert.Zero(t, size)
+	assert.Equal(t, []string{"/mnt/tank/isos/test.iso", "/mnt/tank/isos/test.iso"}, fake.verifyCalls)
+	assert.Equal(t, 2, fake.closeCalls)
+	assert.Empty(t, fake.downloadCalls)
+}
+
 func TestDownloaderVerifiesFlatcarSHA512Checksum(t *testing.T) {
 	const expected = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef" +
 		"0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
@@ -373,7 +421,8 @@ func TestDownloaderFailsOnFlatcarSHA512Mismatch(t *testing.T) {
 	assert.Contains(t, err.Error(), "SHA512 checksum mismatch")
 }
 
-func TestDownloaderSkipsTalosFactoryChecksumFetch(t *testing.T) {
+func TestDownloaderVerifiesTalosFactoryISOAgainstReferenceDownload(t *testing.T) {
+	const reference = "1111111111111111111111111111111111111111111111111111111111111111"
 	config := DownloadConfig{
 		TrueNASHost:     "nas.local",
 		TrueNASUsername: "root",
@@ -382,24 +431,42 @@ func TestDownloaderSkipsTalosFactoryChecksumFetch(t *testing.T) {
 		ISOStoragePath:  "/mnt/tank/isos",
 		ISOFilename:     "metal-amd64.iso",
 	}
-	fake := &fakeSSHClient{
-		verifyResults: []struct {
-			exists bool
-			size   int64
-			err    error
-		}{
-			{exists: false, size: 0, err: nil},
-			{exists: true, size: 1024, err: nil},
-		},
+	newFake := func(remote string) *fakeSSHClient {
+		return &fakeSSHClient{
+			verifyResults: []struct {
+				exists bool
+				size   int64
+				err    error
+			}{
+				{exists: false, size: 0, err: nil},
+				{exists: true, size: 1024, err: nil},
+			},
+			commandOutput: remote + "  /mnt/tank/isos/metal-amd64.iso\n",
+		}
 	}
 	fetched := false
-	testutil.Swap(t, &newSSHClient, func(ssh.SSHConfig) sshClient { return fake })
 	testutil.Swap(t, &fetchChecksumFileFn, func(string) ([]byte, error) {
 		fetched = true
 		return nil, nil
 	})
+	testutil.Swap(t, &urlDigestFn, func(url string) (Digest, error) {
+		assert.Equal(t, config.ISOURL, url)
+		return Digest{SHA256: reference, Size: 1024}, nil
+	})
 
+	fake := newFake(reference)
+	testutil.Swap(t, &newSSHClient, func(ssh.SSHConfig) sshClient { return fake })
 	require.NoError(t, NewDownloader().DownloadCustomISO(config))
-	assert.False(t, fetched)
-	assert.Empty(t, fake.commandCalls)
+	assert.False(t, fetched, "the factory publishes no checksum file")
+	assert.Equal(t, []string{"sha256sum '/mnt/tank/isos/metal-amd64.iso' | awk '{print $1}'"}, fake.commandCalls)
+
+	const corrupt = "2222222222222222222222222222222222222222222222222222222222222222"
+	fake = newFake(corrupt)
+	err := NewDownloader().DownloadCustomISO(config)
+	assert.EqualError(t, err, "SHA256 checksum mismatch for /mnt/tank/isos/metal-amd64.iso: TrueNAS has "+corrupt+", "+config.ISOURL+" is "+reference)
+
+	testutil.Swap(t, &urlDigestFn, func(string) (Digest, error) { return Digest{SHA256: reference, Size: 4096}, nil })
+	fake = newFake(reference)
+	err = NewDownloader().DownloadCustomISO(config)
+	assert.ErrorContains(t, err, "ISO /mnt/tank/isos/metal-amd64.iso on TrueNAS is 1024 bytes, but "+config.ISOURL+" is 4096 bytes")
 }
@@ -0,0 +1,166 @@
+package iso
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rangeServer serves content with Range support and counts the body bytes
+// it sends.
+type rangeServer struct {
+	mu     sync.Mutex
+	ranges []string
+	sent   int64
+}
+
+func (s *rangeServer) start(t *testing.T, content []byte) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.ranges = append(s.ranges, r.Header.Get("Range"))
+		s.mu.Unlock()
+		http.ServeContent(countingWriter{w, s}, r, "talos.iso", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+type countingWriter struct {
+	http.ResponseWriter
+	s *rangeServer
+}
+
+func (w countingWriter) Write(p []byte) (int, error) {
+	w.s.mu.Lock()
+	w.s.sent += int64(len(p))
+	w.s.mu.Unlock()
+	return w.ResponseWriter.Write(p)
+}
+
+func isoContent(size int) []byte {
+	return bytes.Repeat([]byte("0123456789abcdef"), size/16)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestChunkedDownloadResumesPartialFile(t *testing.T) {
+	content := isoContent(1000 * 16)
+	var server rangeServer
+	srv := server.start(t, content)
+
+	path := filepath.Join(t.TempDir(), "talos.iso.part")
+	// A run killed at 60% leaves the first 60% of the ISO behind.
+	kept := len(content) * 6 / 10
+	require.NoError(t, os.WriteFile(path, content[:kept], 0o600))
+
+	var last Progress
+	download := ChunkedDownload{Do: srv.Client().Do, ChunkSize: 4096, Progress: func(p Progress) { last = p }}
+	digest, err := download.ToFile(srv.URL, path, true)
+	require.NoError(t, err)
+
+	assert.Equal(t, Digest{SHA256: sha256Hex(content), Size: int64(len(content))}, digest)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+	assert.Equal(t, int64(len(content)-kept), server.sent, "only the missing 40% is fetched")
+	assert.Equal(t, "bytes=9600-13695", server.ranges[0])
+	assert.Equal(t, Progress{Done: int64(len(content)), Total: int64(len(content)), Rate: last.Rate}, last)
+
+	// Without resume the file starts over.
+	server.sent = 0
+	_, err = download.ToFile(srv.URL, path, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), server.sent)
+
+	// A complete file only costs the request that finds nothing is missing.
+	server.sent, server.ranges = 0, nil
+	digest, err = download.ToFile(srv.URL, path, true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), digest.Size)
+	assert.Equal(t, []string{"bytes=16000-20095"}, server.ranges)
+}
+
+// flakyBody fails after n bytes.
+type flakyBody struct {
+	io.Reader
+	n int
+}
+
+func (b *flakyBody) Read(p []byte) (int, error) {
+	if b.n <= 0 {
+		return 0, errors.New("connection reset by peer")
+	}
+	if len(p) > b.n {
+		p = p[:b.n]
+	}
+	n, err := b.Reader.Read(p)
+	b.n -= n
+	return n, err
+}
+
+func (b *flakyBody) Close() error { return nil }
+
+func TestChunkedDownloadRetriesOnlyTheFailedChunk(t *testing.T) {
+	content := isoContent(64 * 16)
+	var server rangeServer
+	srv := server.start(t, content)
+
+	calls := 0
+	do := func(req *http.Request) (*http.Response, error) {
+		calls++
+		resp, err := srv.Client().Do(req)
+		if err == nil && calls%2 == 1 {
+			// Every chunk's first attempt drops after 100 bytes.
+			resp.Body = &flakyBody{Reader: resp.Body, n: 100}
+		}
+		return resp, err
+	}
+	download := ChunkedDownload{Do: do, ChunkSize: 256}
+	digest, err := download.ToFile(srv.URL, filepath.Join(t.TempDir(), "talos.iso"), false)
+	require.NoError(t, err)
+	assert.Equal(t, sha256Hex(content), digest.SHA256)
+	assert.Equal(t, "bytes=100-355", server.ranges[1], "the retry continues after the bytes that arrived")
+
+	failing := ChunkedDownload{Do: func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("network is unreachable")
+	}, Attempts: 2}
+	_, err = failing.ToFile(srv.URL, filepath.Join(t.TempDir(), "talos.iso"), false)
+	assert.EqualError(t, err, "chunk at byte 0 failed (attempt 2 of 2): network is unreachable")
+}
+
+func TestChunkedDownloadWithoutRangeSupport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "talos.iso")
+	require.NoError(t, os.WriteFile(path, []byte("stale"), 0o600))
+	do := func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("iso-bytes")), ContentLength: 9}, nil
+	}
+
+	digest, err := ChunkedDownload{Do: do, ChunkSize: 4}.ToFile("https://example.com/talos.iso", path, true)
+	require.NoError(t, err)
+	assert.Equal(t, Digest{SHA256: "4bc485f29c8bda3640b8d904070e38e722d7acd9cba16f7a0ea8bedce2528178", Size: 9}, digest)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "iso-bytes", string(data))
+}
+
+func TestProgressString(t *testing.T) {
+	assert.Equal(t, "62% of 100 MB at 4.0 MB/s", Progress{Done: 62 << 20, Total: 100 << 20, Rate: 4 << 20}.String())
+	assert.Equal(t, "62 MB at 0.5 MB/s", Progress{Done: 62 << 20, Rate: 1 << 19}.String())
+}
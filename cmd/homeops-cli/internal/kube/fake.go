@@ -0,0 +1,116 @@
+package kube
+
+import (
+	"encoding/json"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// Fake is a Client over client-go's fake clientsets, for tests. Typed
+// objects seed Clientset and unstructured ones DynamicClient; applied
+// objects land in DynamicClient.
+type Fake struct {
+	*Client
+	Clientset     *fake.Clientset
+	DynamicClient *dynamicfake.FakeDynamicClient
+}
+
+// fakeListKinds are the unstructured resources a Fake lists.
+var fakeListKinds = map[schema.GroupVersionResource]string{
+	CRDResource:                "CustomResourceDefinitionList",
+	GitRepositoryResource:      "GitRepositoryList",
+	KustomizationResource:      "KustomizationList",
+	GatewayResource:            "GatewayList",
+	ClusterSecretStoreResource: "ClusterSecretStoreList",
+}
+
+// fakeClusterScopedKinds are the cluster-scoped kinds a Fake maps; every
+// other kind it knows is namespaced.
+var fakeClusterScopedKinds = map[string]bool{
+	"Namespace": true, "Node": true, "PersistentVolume": true, "ClusterRole": true, "ClusterRoleBinding": true,
+	"StorageClass": true, "CustomResourceDefinition": true, "ClusterSecretStore": true, "ClusterIssuer": true,
+}
+
+// NewFake returns a Fake seeded with objects.
+func NewFake(objects ...runtime.Object) *Fake {
+	var typed, untyped []runtime.Object
+	for _, object := range objects {
+		if _, ok := object.(*unstructured.Unstructured); ok {
+			untyped = append(untyped, object)
+		} else {
+			typed = append(typed, object)
+		}
+	}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	addKind := func(gvk schema.GroupVersionKind) {
+		scope := meta.RESTScopeNamespace
+		if fakeClusterScopedKinds[gvk.Kind] {
+			scope = meta.RESTScopeRoot
+		}
+		mapper.Add(gvk, scope)
+	}
+	for gvk := range scheme.Scheme.AllKnownTypes() {
+		addKind(gvk)
+	}
+	for resource, listKind := range fakeListKinds {
+		gvk := resource.GroupVersion().WithKind(listKind[:len(listKind)-len("List")])
+		scope := meta.RESTScopeNamespace
+		if fakeClusterScopedKinds[gvk.Kind] {
+			scope = meta.RESTScopeRoot
+		}
+		mapper.AddSpecific(gvk, resource, resource.GroupVersion().WithResource(strings.ToLower(gvk.Kind)), scope)
+	}
+	addKind(schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "ClusterIssuer"})
+
+	clientset := fake.NewClientset(typed...)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), fakeListKinds)
+	dynamicClient.PrependReactor("patch", "*", fakeApply(dynamicClient.Tracker()))
+	// Seed through the mapper: the tracker's own guess at a resource name
+	// is wrong for Gateway ("gatewaies"), so the mapper is told it.
+	for _, object := range untyped {
+		u := object.(*unstructured.Unstructured)
+		mapping, err := mapper.RESTMapping(u.GroupVersionKind().GroupKind(), u.GroupVersionKind().Version)
+		if err != nil {
+			panic(err)
+		}
+		if err := dynamicClient.Tracker().Create(mapping.Resource, u, u.GetNamespace()); err != nil {
+			panic(err)
+		}
+	}
+
+	return &Fake{
+		Client:        NewForClients(clientset, dynamicClient, mapper),
+		Clientset:     clientset,
+		DynamicClient: dynamicClient,
+	}
+}
+
+// fakeApply stores a server-side applied object whole, creating it when
+// missing (the plain object tracker only applies to existing objects).
+func fakeApply(tracker k8stesting.ObjectTracker) k8stesting.ReactionFunc {
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch, ok := action.(k8stesting.PatchAction)
+		if !ok || patch.GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+		object := &unstructured.Unstructured{}
+		if err := json.Unmarshal(patch.GetPatch(), &object.Object); err != nil {
+			return true, nil, err
+		}
+		object.SetName(patch.GetName())
+		resource, namespace := patch.GetResource(), patch.GetNamespace()
+		if _, err := tracker.Get(resource, namespace, patch.GetName()); err == nil {
+			return true, object, tracker.Update(resource, object, namespace)
+		}
+		return true, object, tracker.Create(resource, object, namespace)
+	}
+}
@@ -0,0 +1,360 @@
+// Package kube talks to a cluster through client-go, for commands that must
+// work without a kubectl binary on PATH (or one whose version skews from the
+// cluster).
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// FieldManager owns the fields this CLI server-side applies.
+const FieldManager = "homeops-cli"
+
+// RequestTimeout bounds every API request, like kubectl --request-timeout.
+const RequestTimeout = 30 * time.Second
+
+// Resources read or watched without a typed client.
+var (
+	NodeResource               = corev1.SchemeGroupVersion.WithResource("nodes")
+	DeploymentResource         = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	EndpointSliceResource      = discoveryv1.SchemeGroupVersion.WithResource("endpointslices")
+	CRDResource                = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+	GitRepositoryResource      = schema.GroupVersionResource{Group: "source.toolkit.fluxcd.io", Version: "v1", Resource: "gitrepositories"}
+	KustomizationResource      = schema.GroupVersionResource{Group: "kustomize.toolkit.fluxcd.io", Version: "v1", Resource: "kustomizations"}
+	GatewayResource            = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "gateways"}
+	ClusterSecretStoreResource = schema.GroupVersionResource{Group: "external-secrets.io", Version: "v1", Resource: "clustersecretstores"}
+)
+
+// Client is a typed clientset, a dynamic client, and the REST mapper that
+// resolves the kinds of applied manifests.
+type Client struct {
+	Kubernetes kubernetes.Interface
+	Dynamic    dynamic.Interface
+	mapper     meta.RESTMapper
+}
+
+// New connects to the cluster of kubeconfig, or of the default loading rules
+// ($KUBECONFIG, ~/.kube/config) when it is empty.
+func New(kubeconfig string) (*Client, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	restConfig.Timeout = RequestTimeout
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientset.Discovery()))
+	return NewForClients(clientset, dynamicClient, mapper), nil
+}
+
+// NewForClients wraps existing clients. A mapper that is a
+// meta.ResettableRESTMapper is reset when a kind is missing, so kinds whose
+// CRDs were just applied are found.
+func NewForClients(clientset kubernetes.Interface, dynamicClient dynamic.Interface, mapper meta.RESTMapper) *Client {
+	return &Client{Kubernetes: clientset, Dynamic: dynamicClient, mapper: mapper}
+}
+
+// IsNotFound reports whether err means the object, or its kind, does not
+// exist, as opposed to the API being unusable.
+func IsNotFound(err error) bool {
+	return apierrors.IsNotFound(err) || meta.IsNoMatchError(err)
+}
+
+// Ping checks that the API server answers.
+func (c *Client) Ping() error {
+	_, err := c.Kubernetes.Discovery().ServerVersion()
+	return err
+}
+
+// EnsureNamespace creates the namespace unless it exists.
+func (c *Client) EnsureNamespace(ctx context.Context, name string) (created bool, err error) {
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	if _, err := c.Kubernetes.CoreV1().Namespaces().Create(ctx, namespace, metav1.CreateOptions{FieldManager: FieldManager}); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// ApplyOptions tune Apply.
+type ApplyOptions struct {
+	// Namespace is used for namespaced objects that name none ("default"
+	// when empty).
+	Namespace string
+	// Force takes over fields another manager owns, like kubectl
+	// --force-conflicts.
+	Force bool
+}
+
+// Apply server-side applies every document of a multi-document YAML (or
+// JSON) manifest, in order, like kubectl apply --server-side.
+func (c *Client) Apply(ctx context.Context, manifest string, options ApplyOptions) error {
+	objects, err := DecodeManifest(manifest)
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		if err := c.applyObject(ctx, object, options); err != nil {
+			return fmt.Errorf("apply %s %s: %w", object.GetKind(), object.GetName(), err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) applyObject(ctx context.Context, object *unstructured.Unstructured, options ApplyOptions) error {
+	mapping, err := c.mapping(object.GroupVersionKind())
+	if err != nil {
+		return err
+	}
+	var resource dynamic.ResourceInterface = c.Dynamic.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		namespace := object.GetNamespace()
+		if namespace == "" {
+			namespace = options.Namespace
+		}
+		if namespace == "" {
+			namespace = metav1.NamespaceDefault
+		}
+		object.SetNamespace(namespace)
+		resource = c.Dynamic.Resource(mapping.Resource).Namespace(namespace)
+	}
+	_, err = resource.Apply(ctx, object.GetName(), object, metav1.ApplyOptions{FieldManager: FieldManager, Force: options.Force})
+	return err
+}
+
+func (c *Client) mapping(gvk schema.GroupVersionKind) (*meta.RESTMapping, error) {
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		if resettable, ok := c.mapper.(meta.ResettableRESTMapper); ok {
+			resettable.Reset()
+			mapping, err = c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		}
+	}
+	return mapping, err
+}
+
+// DecodeManifest splits a multi-document YAML (or JSON) manifest into its
+// objects, skipping empty documents.
+func DecodeManifest(manifest string) ([]*unstructured.Unstructured, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(strings.NewReader(manifest), 4096)
+	var objects []*unstructured.Unstructured
+	for index := 1; ; index++ {
+		content := map[string]interface{}{}
+		if err := decoder.Decode(&content); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objects, nil
+			}
+			return nil, fmt.Errorf("failed to parse manifest document %d: %w", index, err)
+		}
+		if len(content) == 0 {
+			continue
+		}
+		object := &unstructured.Unstructured{Object: content}
+		if object.GetAPIVersion() == "" || object.GetKind() == "" {
+			return nil, fmt.Errorf("manifest document %d has no apiVersion or kind", index)
+		}
+		objects = append(objects, object)
+	}
+}
+
+// PatchMetadata merges labels and annotations into an object's metadata.
+func (c *Client) PatchMetadata(ctx context.Context, resource schema.GroupVersionResource, namespace, name string, labels, annotations map[string]string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": labels, "annotations": annotations},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.Dynamic.Resource(resource).Namespace(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{FieldManager: FieldManager})
+	return err
+}
+
+// Get reads one object; namespace is empty for a cluster-scoped one.
+func (c *Client) Get(ctx context.Context, resource schema.GroupVersionResource, namespace, name string) (*unstructured.Unstructured, error) {
+	return c.Dynamic.Resource(resource).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+// List reads every object of resource in namespace, or cluster-wide when it
+// is empty.
+func (c *Client) List(ctx context.Context, resource schema.GroupVersionResource, namespace string) ([]unstructured.Unstructured, error) {
+	list, err := c.Dynamic.Resource(resource).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// watchTimeout is how long the server keeps a watch open: it closes the
+// stream before RequestTimeout cuts the connection, so a caller reopens it.
+const watchTimeout = RequestTimeout - 5*time.Second
+
+// Watch streams changes to resource in namespace, or cluster-wide when it is
+// empty, until ctx ends or the server closes the stream after watchTimeout.
+func (c *Client) Watch(ctx context.Context, resource schema.GroupVersionResource, namespace string) (watch.Interface, error) {
+	seconds := int64(watchTimeout / time.Second)
+	return c.Dynamic.Resource(resource).Namespace(namespace).Watch(ctx, metav1.ListOptions{TimeoutSeconds: &seconds})
+}
+
+// Condition is the status and reason of an object's status condition of
+// conditionType, both empty when it has none.
+func Condition(object *unstructured.Unstructured, conditionType string) (status, reason string) {
+	conditions, _, _ := unstructured.NestedSlice(object.Object, "status", "conditions")
+	for _, entry := range conditions {
+		condition, ok := entry.(map[string]interface{})
+		if !ok || condition["type"] != conditionType {
+			continue
+		}
+		status, _ = condition["status"].(string)
+		reason, _ = condition["reason"].(string)
+		return status, reason
+	}
+	return "", ""
+}
+
+// NodeReadiness is a node's Ready condition status ("" when it reports none).
+type NodeReadiness struct {
+	Name  string
+	Ready string
+}
+
+// Nodes reads the Ready condition of every node.
+func (c *Client) Nodes(ctx context.Context) ([]NodeReadiness, error) {
+	nodes, err := c.Kubernetes.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	readiness := make([]NodeReadiness, 0, len(nodes.Items))
+	for _, node := range nodes.Items {
+		entry := NodeReadiness{Name: node.Name}
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == corev1.NodeReady {
+				entry.Ready = string(condition.Status)
+			}
+		}
+		readiness = append(readiness, entry)
+	}
+	return readiness, nil
+}
+
+// CRD is a CustomResourceDefinition's metadata and Established status.
+type CRD struct {
+	Name        string
+	Labels      map[string]string
+	Annotations map[string]string
+	Established string
+}
+
+// CRDs reads every CustomResourceDefinition.
+func (c *Client) CRDs(ctx context.Context) ([]CRD, error) {
+	items, err := c.List(ctx, CRDResource, "")
+	if err != nil {
+		return nil, err
+	}
+	crds := make([]CRD, 0, len(items))
+	for i := range items {
+		established, _ := Condition(&items[i], "Established")
+		crds = append(crds, CRD{
+			Name:        items[i].GetName(),
+			Labels:      items[i].GetLabels(),
+			Annotations: items[i].GetAnnotations(),
+			Established: established,
+		})
+	}
+	return crds, nil
+}
+
+// DeploymentStatus is a Deployment's replica counts and Available condition.
+type DeploymentStatus struct {
+	ReadyReplicas int32
+	Replicas      int32
+	Available     string
+}
+
+// Deployment reads a Deployment's status.
+func (c *Client) Deployment(ctx context.Context, namespace, name string) (DeploymentStatus, error) {
+	deployment, err := c.Kubernetes.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return DeploymentStatus{}, err
+	}
+	status := DeploymentStatus{ReadyReplicas: deployment.Status.ReadyReplicas, Replicas: deployment.Status.Replicas}
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == "Available" {
+			status.Available = string(condition.Status)
+		}
+	}
+	return status, nil
+}
+
+// ServiceEndpoints are the addresses of a Service's ready endpoints.
+func (c *Client) ServiceEndpoints(ctx context.Context, namespace, service string) ([]string, error) {
+	slices, err := c.Kubernetes.DiscoveryV1().EndpointSlices(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: discoveryv1.LabelServiceName + "=" + service,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var addresses []string
+	for _, slice := range slices.Items {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			addresses = append(addresses, endpoint.Addresses...)
+		}
+	}
+	return addresses, nil
+}
+
+// PodSummary is one line per pod in namespace: name, phase, ready
+// containers, restarts and node, for diagnostics.
+func (c *Client) PodSummary(ctx context.Context, namespace string) (string, error) {
+	pods, err := c.Kubernetes.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	for _, pod := range pods.Items {
+		ready, restarts := 0, int32(0)
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Ready {
+				ready++
+			}
+			restarts += status.RestartCount
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %d/%d restarts=%d node=%s",
+			pod.Name, pod.Status.Phase, ready, len(pod.Spec.Containers), restarts, pod.Spec.NodeName))
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,123 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestApplyServerSideAppliesEveryDocument(t *testing.T) {
+	client := NewFake()
+	ctx := context.Background()
+	manifest := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cluster-settings
+data:
+  TIMEZONE: Etc/UTC
+---
+apiVersion: cert-manager.io/v1
+kind: ClusterIssuer
+metadata:
+  name: letsencrypt
+---
+`
+	require.NoError(t, client.Apply(ctx, manifest, ApplyOptions{Namespace: "flux-system", Force: true}))
+
+	configMaps := client.DynamicClient.Resource(corev1.SchemeGroupVersion.WithResource("configmaps"))
+	settings, err := configMaps.Namespace("flux-system").Get(ctx, "cluster-settings", metav1.GetOptions{})
+	require.NoError(t, err, "a namespaced object without a namespace goes to ApplyOptions.Namespace")
+	value, _, _ := unstructured.NestedString(settings.Object, "data", "TIMEZONE")
+	assert.Equal(t, "Etc/UTC", value)
+
+	var applied []string
+	for _, action := range client.DynamicClient.Actions() {
+		if action.GetVerb() != "patch" {
+			continue
+		}
+		applied = append(applied, action.GetResource().Resource+"/"+action.GetNamespace())
+	}
+	assert.Equal(t, []string{"configmaps/flux-system", "clusterissuers/"}, applied)
+
+	err = client.Apply(ctx, "apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: w\n", ApplyOptions{})
+	assert.ErrorContains(t, err, "apply Widget w")
+	assert.True(t, IsNotFound(err), "an unknown kind is not found")
+
+	_, err = DecodeManifest("metadata:\n  name: nameless\n")
+	assert.EqualError(t, err, "manifest document 1 has no apiVersion or kind")
+}
+
+func TestReadersReportStatus(t *testing.T) {
+	ready := true
+	notReady := false
+	client := NewFake(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "k8s-0"}, Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionFalse}}}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "k8s-1"}},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "webhook", Namespace: "external-secrets"},
+			Status: appsv1.DeploymentStatus{Replicas: 2, ReadyReplicas: 1, Conditions: []appsv1.DeploymentCondition{
+				{Type: appsv1.DeploymentAvailable, Status: corev1.ConditionTrue},
+			}},
+		},
+		&discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{Name: "webhook-abc", Namespace: "external-secrets", Labels: map[string]string{discoveryv1.LabelServiceName: "webhook"}},
+			Endpoints: []discoveryv1.Endpoint{
+				{Addresses: []string{"10.42.0.7"}, Conditions: discoveryv1.EndpointConditions{Ready: &ready}},
+				{Addresses: []string{"10.42.0.8"}, Conditions: discoveryv1.EndpointConditions{Ready: &notReady}},
+			},
+		},
+		&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apiextensions.k8s.io/v1",
+			"kind":       "CustomResourceDefinition",
+			"metadata":   map[string]interface{}{"name": "certificates.cert-manager.io", "labels": map[string]interface{}{"app": "cert-manager"}},
+			"status": map[string]interface{}{"conditions": []interface{}{
+				map[string]interface{}{"type": "NamesAccepted", "status": "True"},
+				map[string]interface{}{"type": "Established", "status": "True", "reason": "InitialNamesAccepted"},
+			}},
+		}},
+	)
+	ctx := context.Background()
+
+	nodes, err := client.Nodes(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []NodeReadiness{{Name: "k8s-0", Ready: "False"}, {Name: "k8s-1"}}, nodes)
+
+	deployment, err := client.Deployment(ctx, "external-secrets", "webhook")
+	require.NoError(t, err)
+	assert.Equal(t, DeploymentStatus{ReadyReplicas: 1, Replicas: 2, Available: "True"}, deployment)
+	_, err = client.Deployment(ctx, "external-secrets", "missing")
+	assert.True(t, IsNotFound(err))
+
+	addresses, err := client.ServiceEndpoints(ctx, "external-secrets", "webhook")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.42.0.7"}, addresses, "endpoints that are not ready are left out")
+
+	crds, err := client.CRDs(ctx)
+	require.NoError(t, err)
+	require.Len(t, crds, 1)
+	assert.Equal(t, CRD{Name: "certificates.cert-manager.io", Labels: map[string]string{"app": "cert-manager"}, Established: "True"}, crds[0])
+
+	require.NoError(t, client.PatchMetadata(ctx, CRDResource, "", "certificates.cert-manager.io",
+		map[string]string{"app.kubernetes.io/managed-by": "Helm"}, map[string]string{"meta.helm.sh/release-name": "cert-manager"}))
+	patched, err := client.Get(ctx, CRDResource, "", "certificates.cert-manager.io")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"app": "cert-manager", "app.kubernetes.io/managed-by": "Helm"}, patched.GetLabels())
+	status, reason := Condition(patched, "Established")
+	assert.Equal(t, "True", status)
+	assert.Equal(t, "InitialNamesAccepted", reason)
+
+	created, err := client.EnsureNamespace(ctx, "flux-system")
+	require.NoError(t, err)
+	assert.True(t, created)
+	created, err = client.EnsureNamespace(ctx, "flux-system")
+	require.NoError(t, err)
+	assert.False(t, created, "an existing namespace is left alone")
+}
@@ -0,0 +1,266 @@
+package kubeutil
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Gateway API resources as kubectl names them.
+const (
+	GatewayClassResource = "gatewayclasses.gateway.networking.k8s.io"
+	GatewayResource      = "gateways.gateway.networking.k8s.io"
+	HTTPRouteResource    = "httproutes.gateway.networking.k8s.io"
+)
+
+// GatewayCondition is a metav1.Condition as Gateway API objects report it.
+type GatewayCondition struct {
+	Type    string `json:"type"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// GatewayObjectMeta is the part of ObjectMeta the Gateway checks read.
+type GatewayObjectMeta struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// GatewayClass is a gateway.networking.k8s.io/v1 GatewayClass.
+type GatewayClass struct {
+	Metadata GatewayObjectMeta `json:"metadata"`
+	Spec     struct {
+		ControllerName string `json:"controllerName"`
+	} `json:"spec"`
+	Status struct {
+		Conditions []GatewayCondition `json:"conditions"`
+	} `json:"status"`
+}
+
+// Gateway is a gateway.networking.k8s.io/v1 Gateway.
+type Gateway struct {
+	Metadata GatewayObjectMeta `json:"metadata"`
+	Spec     struct {
+		GatewayClassName string `json:"gatewayClassName"`
+	} `json:"spec"`
+	Status struct {
+		Addresses []struct {
+			Type  string `json:"type"`
+			Value string `json:"value"`
+		} `json:"addresses"`
+		Conditions []GatewayCondition `json:"conditions"`
+	} `json:"status"`
+}
+
+// HTTPRouteParentRef is a route's parentRef or the ref echoed in its status.
+type HTTPRouteParentRef struct {
+	Group       string `json:"group"`
+	Kind        string `json:"kind"`
+	Name        string `json:"name"`
+	Namespace   string `json:"namespace"`
+	SectionName string `json:"sectionName"`
+}
+
+// HTTPRoute is a gateway.networking.k8s.io/v1 HTTPRoute.
+type HTTPRoute struct {
+	Metadata GatewayObjectMeta `json:"metadata"`
+	Spec     struct {
+		ParentRefs []HTTPRouteParentRef `json:"parentRefs"`
+	} `json:"spec"`
+	Status struct {
+		Parents []struct {
+			ParentRef  HTTPRouteParentRef `json:"parentRef"`
+			Conditions []GatewayCondition `json:"conditions"`
+		} `json:"parents"`
+	} `json:"status"`
+}
+
+// GatewayClassList, GatewayList, and HTTPRouteList are the kubectl get -o
+// json envelopes.
+type (
+	GatewayClassList struct {
+		Items []GatewayClass `json:"items"`
+	}
+	GatewayList struct {
+		Items []Gateway `json:"items"`
+	}
+	HTTPRouteList struct {
+		Items []HTTPRoute `json:"items"`
+	}
+)
+
+// ConditionState is a condition's status, "Missing" when the controller has
+// not set it yet, with the reason appended when it is not True.
+func ConditionState(conditions []GatewayCondition, conditionType string) string {
+	for _, condition := range conditions {
+		if condition.Type != conditionType {
+			continue
+		}
+		if condition.Status == "True" || condition.Reason == "" {
+			return condition.Status
+		}
+		return condition.Status + " (" + condition.Reason + ")"
+	}
+	return "Missing"
+}
+
+func conditionTrue(conditions []GatewayCondition, conditionType string) bool {
+	return ConditionState(conditions, conditionType) == "True"
+}
+
+// RouteAttachment is one HTTPRoute as seen by one of its parent Gateways.
+type RouteAttachment struct {
+	Namespace    string `json:"namespace"`
+	Name         string `json:"name"`
+	Accepted     string `json:"accepted"`
+	ResolvedRefs string `json:"resolved_refs"`
+}
+
+// GatewayStatus summarizes a Gateway with the HTTPRoutes attached to it.
+type GatewayStatus struct {
+	Namespace    string            `json:"namespace"`
+	Name         string            `json:"name"`
+	Class        string            `json:"class"`
+	ClassState   string            `json:"class_accepted"`
+	Programmed   string            `json:"programmed"`
+	Accepted     string            `json:"accepted"`
+	Addresses    []string          `json:"addresses"`
+	Routes       []RouteAttachment `json:"routes"`
+	Problems     []string          `json:"problems,omitempty"`
+	RoutesOK     int               `json:"routes_ok"`
+	RoutesFailed int               `json:"routes_failed"`
+}
+
+// Serving reports whether the Gateway is Programmed and has an address,
+// i.e. traffic can reach it.
+func (s GatewayStatus) Serving() bool {
+	return s.Programmed == "True" && len(s.Addresses) > 0
+}
+
+// SummarizeGateways joins Gateways with their class and every HTTPRoute whose
+// parentRefs name them, using the route's per-parent status. A route parent
+// without status counts as Missing on both conditions: the Gateway's
+// controller has not picked it up.
+func SummarizeGateways(classes []GatewayClass, gateways []Gateway, routes []HTTPRoute) []GatewayStatus {
+	classByName := make(map[string]GatewayClass, len(classes))
+	for _, class := range classes {
+		classByName[class.Metadata.Name] = class
+	}
+	index := make(map[string]int, len(gateways))
+	statuses := make([]GatewayStatus, 0, len(gateways))
+	for _, gateway := range gateways {
+		status := GatewayStatus{
+			Namespace: gateway.Metadata.Namespace, Name: gateway.Metadata.Name, Class: gateway.Spec.GatewayClassName,
+			ClassState: "Missing", Routes: []RouteAttachment{},
+			Programmed: ConditionState(gateway.Status.Conditions, "Programmed"),
+			Accepted:   ConditionState(gateway.Status.Conditions, "Accepted"),
+		}
+		if class, ok := classByName[status.Class]; ok {
+			status.ClassState = ConditionState(class.Status.Conditions, "Accepted")
+		}
+		for _, address := range gateway.Status.Addresses {
+			status.Addresses = append(status.Addresses, address.Value)
+		}
+		index[gatewayKey(status.Namespace, status.Name)] = len(statuses)
+		statuses = append(statuses, status)
+	}
+
+	for _, route := range routes {
+		seen := map[string]bool{}
+		for _, ref := range route.Spec.ParentRefs {
+			if !refersToGateway(ref) {
+				continue
+			}
+			key := gatewayKey(defaultNamespace(ref.Namespace, route.Metadata.Namespace), ref.Name)
+			i, ok := index[key]
+			if !ok || seen[key] {
+				continue
+			}
+			seen[key] = true
+			attachment := RouteAttachment{Namespace: route.Metadata.Namespace, Name: route.Metadata.Name, Accepted: "Missing", ResolvedRefs: "Missing"}
+			for _, parent := range route.Status.Parents {
+				if refersToGateway(parent.ParentRef) && gatewayKey(defaultNamespace(parent.ParentRef.Namespace, route.Metadata.Namespace), parent.ParentRef.Name) == key {
+					attachment.Accepted = ConditionState(parent.Conditions, "Accepted")
+					attachment.ResolvedRefs = ConditionState(parent.Conditions, "ResolvedRefs")
+					break
+				}
+			}
+			statuses[i].Routes = append(statuses[i].Routes, attachment)
+		}
+	}
+
+	for i := range statuses {
+		status := &statuses[i]
+		sort.Slice(status.Routes, func(a, b int) bool {
+			return gatewayKey(status.Routes[a].Namespace, status.Routes[a].Name) < gatewayKey(status.Routes[b].Namespace, status.Routes[b].Name)
+		})
+		if status.ClassState != "True" {
+			status.Problems = append(status.Problems, fmt.Sprintf("GatewayClass %s Accepted=%s", dashIfBlank(status.Class), status.ClassState))
+		}
+		if status.Programmed != "True" {
+			status.Problems = append(status.Problems, "not Programmed: "+status.Programmed)
+		}
+		if len(status.Addresses) == 0 {
+			status.Problems = append(status.Problems, "no address assigned")
+		}
+		for _, route := range status.Routes {
+			switch {
+			case route.Accepted != "True":
+				status.RoutesFailed++
+				status.Problems = append(status.Problems, fmt.Sprintf("HTTPRoute %s/%s not accepted: %s", route.Namespace, route.Name, route.Accepted))
+			case route.ResolvedRefs != "True":
+				status.RoutesFailed++
+				status.Problems = append(status.Problems, fmt.Sprintf("HTTPRoute %s/%s has unresolved backends: ResolvedRefs=%s", route.Namespace, route.Name, route.ResolvedRefs))
+			default:
+				status.RoutesOK++
+			}
+		}
+	}
+	sort.Slice(statuses, func(a, b int) bool {
+		return gatewayKey(statuses[a].Namespace, statuses[a].Name) < gatewayKey(statuses[b].Namespace, statuses[b].Name)
+	})
+	return statuses
+}
+
+// CheckGatewayServing is the bootstrap smoke test: when any Gateway exists,
+// at least one must be Programmed with an address. No Gateways passes.
+func CheckGatewayServing(gateways []Gateway) error {
+	if len(gateways) == 0 {
+		return nil
+	}
+	var waiting []string
+	for _, status := range SummarizeGateways(nil, gateways, nil) {
+		if status.Serving() {
+			return nil
+		}
+		detail := "Programmed=" + status.Programmed
+		if len(status.Addresses) == 0 {
+			detail += ", no address"
+		}
+		waiting = append(waiting, fmt.Sprintf("%s/%s (%s)", status.Namespace, status.Name, detail))
+	}
+	return fmt.Errorf("no Gateway is Programmed with an address: %s", strings.Join(waiting, "; "))
+}
+
+func refersToGateway(ref HTTPRouteParentRef) bool {
+	return (ref.Group == "" || ref.Group == "gateway.networking.k8s.io") && (ref.Kind == "" || ref.Kind == "Gateway")
+}
+
+func defaultNamespace(namespace, fallback string) string {
+	if namespace == "" {
+		return fallback
+	}
+	return namespace
+}
+
+func gatewayKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+func dashIfBlank(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,93 @@
+package kubeutil
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gatewayFixtureKubectl serves testdata/gateway-api/<resource>.json to
+// `kubectl get <resource>.gateway.networking.k8s.io [-A] -o json`.
+func gatewayFixtureKubectl(t *testing.T) OutputFunc {
+	t.Helper()
+	return func(_ context.Context, args ...string) ([]byte, error) {
+		resource, ok := strings.CutSuffix(args[1], ".gateway.networking.k8s.io")
+		if !ok || !strings.HasSuffix(strings.Join(args[2:], " "), "-o json") {
+			return nil, fmt.Errorf("unexpected kubectl %v", args)
+		}
+		return os.ReadFile(filepath.Join("testdata", "gateway-api", resource+".json"))
+	}
+}
+
+func loadGatewayFixtures(t *testing.T) ([]GatewayClass, []Gateway, []HTTPRoute) {
+	t.Helper()
+	kubectl := gatewayFixtureKubectl(t)
+	var classes GatewayClassList
+	var gateways GatewayList
+	var routes HTTPRouteList
+	require.NoError(t, GetClusterJSON(context.Background(), kubectl, GatewayClassResource, &classes))
+	require.NoError(t, GetJSON(context.Background(), kubectl, "", GatewayResource, &gateways))
+	require.NoError(t, GetJSON(context.Background(), kubectl, "", HTTPRouteResource, &routes))
+	return classes.Items, gateways.Items, routes.Items
+}
+
+func TestConditionState(t *testing.T) {
+	conditions := []GatewayCondition{
+		{Type: "Accepted", Status: "True", Reason: "Accepted"},
+		{Type: "Programmed", Status: "False", Reason: "AddressNotAssigned"},
+		{Type: "ResolvedRefs", Status: "Unknown"},
+	}
+	assert.Equal(t, "True", ConditionState(conditions, "Accepted"), "a True condition's reason is noise")
+	assert.Equal(t, "False (AddressNotAssigned)", ConditionState(conditions, "Programmed"))
+	assert.Equal(t, "Unknown", ConditionState(conditions, "ResolvedRefs"))
+	assert.Equal(t, "Missing", ConditionState(conditions, "Ready"))
+}
+
+func TestSummarizeGatewaysAggregatesRouteAttachments(t *testing.T) {
+	classes, gateways, routes := loadGatewayFixtures(t)
+	statuses := SummarizeGateways(classes, gateways, routes)
+	require.Len(t, statuses, 3)
+
+	external := statuses[0]
+	assert.Equal(t, "network/external", external.Namespace+"/"+external.Name)
+	assert.True(t, external.Serving())
+	assert.Equal(t, []string{"192.168.122.201"}, external.Addresses)
+	assert.Equal(t, []RouteAttachment{
+		{Namespace: "default", Name: "echo", Accepted: "True", ResolvedRefs: "True"},
+		{Namespace: "media", Name: "plex", Accepted: "True", ResolvedRefs: "False (BackendNotFound)"},
+	}, external.Routes, "echo's two listener refs count once; the Service parentRef of network/mesh is not a Gateway")
+	assert.Equal(t, 1, external.RoutesOK)
+	assert.Equal(t, 1, external.RoutesFailed)
+	assert.Equal(t, []string{"HTTPRoute media/plex has unresolved backends: ResolvedRefs=False (BackendNotFound)"}, external.Problems)
+
+	internal := statuses[1]
+	assert.False(t, internal.Serving())
+	assert.Equal(t, []RouteAttachment{{Namespace: "media", Name: "plex", Accepted: "Missing", ResolvedRefs: "Missing"}}, internal.Routes,
+		"grafana's namespace-less parentRef points at observability/internal, which does not exist")
+	assert.Equal(t, []string{
+		"not Programmed: False (AddressNotAssigned)",
+		"no address assigned",
+		"HTTPRoute media/plex not accepted: Missing",
+	}, internal.Problems)
+
+	legacy := statuses[2]
+	assert.Equal(t, "Missing", legacy.ClassState)
+	assert.Equal(t, "Missing", legacy.Programmed)
+	assert.Contains(t, legacy.Problems, "GatewayClass envoy Accepted=Missing")
+	assert.Empty(t, legacy.Routes)
+}
+
+func TestCheckGatewayServing(t *testing.T) {
+	_, gateways, _ := loadGatewayFixtures(t)
+	require.NoError(t, CheckGatewayServing(nil), "a cluster without Gateways passes")
+	require.NoError(t, CheckGatewayServing(gateways), "one serving Gateway is enough")
+
+	err := CheckGatewayServing(gateways[1:])
+	require.EqualError(t, err, "no Gateway is Programmed with an address: network/internal (Programmed=False (AddressNotAssigned), no address); network/legacy (Programmed=Missing, no address)")
+}
@@ -11,6 +11,7 @@ import (
 // PerformanceCollector tracks operation metrics
 type PerformanceCollector struct {
 	operations map[string]*OperationMetrics
+	spans      map[spanKey]*durationHistogram
 	mu         sync.RWMutex
 }
 
@@ -38,6 +39,7 @@ type OperationReport struct {
 func NewPerformanceCollector() *PerformanceCollector {
 	return &PerformanceCollector{
 		operations: make(map[string]*OperationMetrics),
+		spans:      make(map[spanKey]*durationHistogram),
 	}
 }
 
@@ -45,8 +47,12 @@ func NewPerformanceCollector() *PerformanceCollector {
 func (pc *PerformanceCollector) TrackOperation(name string, fn func() error) error {
 	start := time.Now()
 	err := fn()
-	duration := time.Since(start)
+	pc.record(name, start, time.Since(start), err)
+	return err
+}
 
+// record adds one run of operation name to its metrics and histogram.
+func (pc *PerformanceCollector) record(name string, start time.Time, duration time.Duration, err error) {
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
 
@@ -69,7 +75,11 @@ func (pc *PerformanceCollector) TrackOperation(name string, fn func() error) err
 		metrics.Errors++
 	}
 
-	return err
+	key := spanKey{name: name, status: spanStatus(err)}
+	if pc.spans[key] == nil {
+		pc.spans[key] = newDurationHistogram(DefaultDurationBuckets)
+	}
+	pc.spans[key].observe(duration)
 }
 
 // TrackOperationWithResult executes a function that returns a result and tracks its performance
@@ -148,6 +158,7 @@ func (pc *PerformanceCollector) Reset() {
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
 	pc.operations = make(map[string]*OperationMetrics)
+	pc.spans = make(map[spanKey]*durationHistogram)
 }
 
 // GetOperationNames returns a list of all tracked operation names
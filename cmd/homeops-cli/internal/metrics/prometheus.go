@@ -0,0 +1,214 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"homeops-cli/internal/constants"
+)
+
+// StepDurationMetric is the histogram a command's steps are exported as,
+// labelled with command, step and status (success or failure).
+const StepDurationMetric = "homeops_step_duration_seconds"
+
+// DefaultDurationBuckets are the histogram upper bounds, in seconds: steps
+// run from a second (a namespace apply) to most of an hour (a Flux wait).
+var DefaultDurationBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600}
+
+// Spans collects the step timings a command exports when it finishes; see
+// ExportOptions.
+var Spans = NewPerformanceCollector()
+
+// ExportOptions says where step timings go. The zero value exports nothing.
+type ExportOptions struct {
+	// TextfilePath is a Prometheus textfile written when the command ends,
+	// e.g. for node_exporter's textfile collector.
+	TextfilePath string
+	// PushgatewayURL is a Pushgateway the timings are pushed to.
+	PushgatewayURL string
+}
+
+// ExportOverrides are the --metrics-file and --pushgateway-url flags.
+// Non-empty fields win over the environment.
+var ExportOverrides ExportOptions
+
+// DefaultExportOptions are HOMEOPS_METRICS_FILE and HOMEOPS_PUSHGATEWAY_URL,
+// overridden by ExportOverrides.
+func DefaultExportOptions() ExportOptions {
+	options := ExportOptions{
+		TextfilePath:   os.Getenv(constants.EnvMetricsFile),
+		PushgatewayURL: os.Getenv(constants.EnvPushgatewayURL),
+	}
+	if ExportOverrides.TextfilePath != "" {
+		options.TextfilePath = ExportOverrides.TextfilePath
+	}
+	if ExportOverrides.PushgatewayURL != "" {
+		options.PushgatewayURL = ExportOverrides.PushgatewayURL
+	}
+	return options
+}
+
+// pushClient sends Pushgateway requests.
+var pushClient = &http.Client{Timeout: 10 * time.Second}
+
+type spanKey struct {
+	name   string
+	status string
+}
+
+func spanStatus(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// durationHistogram counts durations per bucket (not cumulatively; the
+// export sums them).
+type durationHistogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newDurationHistogram(buckets []float64) *durationHistogram {
+	return &durationHistogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *durationHistogram) observe(duration time.Duration) {
+	seconds := duration.Seconds()
+	h.sum += seconds
+	h.count++
+	for i, bound := range h.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+			return
+		}
+	}
+}
+
+// Export writes the collected timings to every destination options names,
+// labelled with command. Nothing is written when nothing was collected.
+func (pc *PerformanceCollector) Export(options ExportOptions, command string) error {
+	pc.mu.RLock()
+	empty := len(pc.spans) == 0
+	pc.mu.RUnlock()
+	if empty {
+		return nil
+	}
+	var errs []error
+	if options.TextfilePath != "" {
+		errs = append(errs, pc.WriteTextfile(options.TextfilePath, command))
+	}
+	if options.PushgatewayURL != "" {
+		errs = append(errs, pc.Push(options.PushgatewayURL, command))
+	}
+	return errors.Join(errs...)
+}
+
+// WritePrometheus writes the collected timings as a homeops_step_duration_seconds
+// histogram in the Prometheus text format.
+func (pc *PerformanceCollector) WritePrometheus(w io.Writer, command string) error {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+
+	keys := make([]spanKey, 0, len(pc.spans))
+	for key := range pc.spans {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name != keys[j].name {
+			return keys[i].name < keys[j].name
+		}
+		return keys[i].status < keys[j].status
+	})
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "# HELP %s Duration of homeops-cli command steps.\n", StepDurationMetric)
+	fmt.Fprintf(&out, "# TYPE %s histogram\n", StepDurationMetric)
+	for _, key := range keys {
+		histogram := pc.spans[key]
+		labels := fmt.Sprintf(`command="%s",step="%s",status="%s"`,
+			escapeLabelValue(command), escapeLabelValue(key.name), key.status)
+		var cumulative uint64
+		for i, bound := range histogram.buckets {
+			cumulative += histogram.counts[i]
+			fmt.Fprintf(&out, "%s_bucket{%s,le=\"%s\"} %d\n", StepDurationMetric, labels, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(&out, "%s_bucket{%s,le=\"+Inf\"} %d\n", StepDurationMetric, labels, histogram.count)
+		fmt.Fprintf(&out, "%s_sum{%s} %s\n", StepDurationMetric, labels, strconv.FormatFloat(histogram.sum, 'g', -1, 64))
+		fmt.Fprintf(&out, "%s_count{%s} %d\n", StepDurationMetric, labels, histogram.count)
+	}
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// WriteTextfile replaces path with the collected timings, atomically so a
+// textfile collector never reads half a file.
+func (pc *PerformanceCollector) WriteTextfile(path, command string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create metrics directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if err := pc.WritePrometheus(tmp, command); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	return nil
+}
+
+// Push replaces the homeops-cli group for command on the Pushgateway at
+// gatewayURL with the collected timings.
+func (pc *PerformanceCollector) Push(gatewayURL, command string) error {
+	var body bytes.Buffer
+	if err := pc.WritePrometheus(&body, command); err != nil {
+		return err
+	}
+	endpoint := strings.TrimRight(gatewayURL, "/") + "/metrics/job/homeops-cli/command/" + url.PathEscape(command)
+	req, err := http.NewRequest(http.MethodPut, endpoint, &body)
+	if err != nil {
+		return fmt.Errorf("invalid Pushgateway URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := pushClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("pushgateway returned %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTextfileExportsAHistogramPerStepAndStatus(t *testing.T) {
+	collector := NewPerformanceCollector()
+	start := time.Now()
+	collector.record("kubeadm-init", start, 40*time.Second, nil)
+	collector.record("kubeadm-init", start, 90*time.Second, nil)
+	collector.record("gateways", start, 2*time.Hour, errors.New("no address"))
+
+	path := filepath.Join(t.TempDir(), "textfile", "homeops.prom")
+	require.NoError(t, collector.WriteTextfile(path, "bootstrap"))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	text := string(content)
+	assert.Contains(t, text, "# TYPE homeops_step_duration_seconds histogram\n")
+	assert.Contains(t, text, `homeops_step_duration_seconds_bucket{command="bootstrap",step="kubeadm-init",status="success",le="30"} 0`+"\n")
+	assert.Contains(t, text, `homeops_step_duration_seconds_bucket{command="bootstrap",step="kubeadm-init",status="success",le="60"} 1`+"\n")
+	assert.Contains(t, text, `homeops_step_duration_seconds_bucket{command="bootstrap",step="kubeadm-init",status="success",le="120"} 2`+"\n")
+	assert.Contains(t, text, `homeops_step_duration_seconds_sum{command="bootstrap",step="kubeadm-init",status="success"} 130`+"\n")
+	assert.Contains(t, text, `homeops_step_duration_seconds_count{command="bootstrap",step="kubeadm-init",status="success"} 2`+"\n")
+	assert.Contains(t, text, `homeops_step_duration_seconds_bucket{command="bootstrap",step="gateways",status="failure",le="3600"} 0`+"\n")
+	assert.Contains(t, text, `homeops_step_duration_seconds_bucket{command="bootstrap",step="gateways",status="failure",le="+Inf"} 1`+"\n")
+	assert.Less(t, strings.Index(text, `step="gateways"`), strings.Index(text, `step="kubeadm-init"`), "series are sorted")
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary file is left behind")
+}
+
+func TestExportPushesToThePushgateway(t *testing.T) {
+	var method, path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.EscapedPath()
+		content, _ := io.ReadAll(r.Body)
+		body = string(content)
+	}))
+	t.Cleanup(server.Close)
+
+	collector := NewPerformanceCollector()
+	require.NoError(t, collector.Export(ExportOptions{PushgatewayURL: server.URL + "/"}, "talos deploy-vm"), "nothing collected, nothing pushed")
+	assert.Empty(t, method)
+
+	require.NoError(t, collector.TrackOperation("deploy", func() error { return nil }))
+	require.NoError(t, collector.Export(ExportOptions{PushgatewayURL: server.URL + "/"}, "talos deploy-vm"))
+	assert.Equal(t, http.MethodPut, method)
+	assert.Equal(t, "/metrics/job/homeops-cli/command/talos%20deploy-vm", path)
+	assert.Contains(t, body, `homeops_step_duration_seconds_count{command="talos deploy-vm",step="deploy",status="success"} 1`)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad metrics", http.StatusBadRequest)
+	}))
+	t.Cleanup(failing.Close)
+	assert.EqualError(t, collector.Push(failing.URL, "bootstrap"), "pushgateway returned 400 Bad Request: bad metrics")
+}
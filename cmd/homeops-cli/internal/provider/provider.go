@@ -71,6 +71,22 @@ type VMSummary struct {
 	MemoryMB int               `json:"memory_mb,omitempty" yaml:"memory_mb,omitempty"`
 	CPUs     int               `json:"cpus,omitempty" yaml:"cpus,omitempty"`
 	Details  map[string]string `json:"details,omitempty" yaml:"details,omitempty"`
+	// Devices and ZVols are filled by providers that list them with the
+	// inventory (TrueNAS); the table output leaves them out.
+	Devices []VMDevice `json:"devices,omitempty" yaml:"devices,omitempty"`
+	ZVols   []string   `json:"zvols,omitempty" yaml:"zvols,omitempty"`
+}
+
+// VMDevice is one virtual device of a VMSummary. Only the fields that apply
+// to its Type are set.
+type VMDevice struct {
+	Type    string `json:"type" yaml:"type"` // DISK, NIC, CDROM, DISPLAY, ...
+	ID      string `json:"id,omitempty" yaml:"id,omitempty"`
+	Order   int    `json:"order,omitempty" yaml:"order,omitempty"`
+	Path    string `json:"path,omitempty" yaml:"path,omitempty"` // disk or ISO
+	MAC     string `json:"mac,omitempty" yaml:"mac,omitempty"`
+	Network string `json:"network,omitempty" yaml:"network,omitempty"` // NIC bridge
+	Model   string `json:"model,omitempty" yaml:"model,omitempty"`     // NIC or display type
 }
 
 // VMLifecycle is the name-addressed VM lifecycle contract. Construction and
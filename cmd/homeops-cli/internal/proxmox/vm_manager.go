@@ -398,6 +398,8 @@ type VMManager struct {
 	findVMByNameFn  func(string) (*proxmox.VirtualMachine, error)
 	uploadISOTaskFn func(string, string, string) (taskHandle, error)
 	verifyStorageFn func(string) error
+	// storageContentFn overrides the storage content listing (tests).
+	storageContentFn func(string) ([]*proxmox.StorageContent, error)
 	// convertToTemplateFn overrides the template-flag conversion (tests).
 	convertToTemplateFn func(string) error
 }
@@ -970,6 +972,33 @@ func (vm *VMManager) UploadISOFromURL(isoURL, filename, storageName string) erro
 	return nil
 }
 
+// ISOSize returns the size in bytes of filename in storageName's ISO
+// content, or 0 when it is not there.
+func (vm *VMManager) ISOSize(filename, storageName string) (int64, error) {
+	content, err := vm.storageContent(storageName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list storage %s: %w", storageName, err)
+	}
+	volid := GetISOPath(storageName, filename)
+	for _, item := range content {
+		if item.Volid == volid {
+			return int64(item.Size), nil // #nosec G115 -- file sizes fit in int64
+		}
+	}
+	return 0, nil
+}
+
+func (vm *VMManager) storageContent(storageName string) ([]*proxmox.StorageContent, error) {
+	if vm.storageContentFn != nil {
+		return vm.storageContentFn(storageName)
+	}
+	storage, err := vm.client.GetStorage(storageName)
+	if err != nil {
+		return nil, err
+	}
+	return storage.GetContent(vm.client.Context())
+}
+
 // GetISOPath returns the Proxmox-format ISO path
 func GetISOPath(storageName, filename string) string {
 	return fmt.Sprintf("%s:iso/%s", storageName, filename)
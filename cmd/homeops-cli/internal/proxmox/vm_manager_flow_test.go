@@ -332,6 +332,29 @@ func TestUploadISOFromURL(t *testing.T) {
 	require.NoError(t, manager.UploadISOFromURL("https://example.test/talos.iso", "talos.iso", "local"))
 }
 
+func TestISOSize(t *testing.T) {
+	manager := &VMManager{
+		storageContentFn: func(storageName string) ([]*proxmox.StorageContent, error) {
+			assert.Equal(t, "local", storageName)
+			return []*proxmox.StorageContent{
+				{Volid: "local:iso/other.iso", Size: 1},
+				{Volid: "local:iso/talos.iso", Size: 1 << 20},
+			}, nil
+		},
+	}
+	size, err := manager.ISOSize("talos.iso", "local")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1<<20), size)
+
+	size, err = manager.ISOSize("missing.iso", "local")
+	require.NoError(t, err)
+	assert.Zero(t, size)
+
+	manager.storageContentFn = func(string) ([]*proxmox.StorageContent, error) { return nil, fmt.Errorf("no such storage") }
+	_, err = manager.ISOSize("talos.iso", "local")
+	assert.EqualError(t, err, "failed to list storage local: no such storage")
+}
+
 func TestUploadISOFromURLErrors(t *testing.T) {
 	manager := &VMManager{
 		client: &Client{ctx: context.Background()},
@@ -0,0 +1,282 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"homeops-cli/internal/common"
+)
+
+// Backend reads op:// references. Templates keep their op:// syntax whatever
+// the backend, so collaborators without 1Password can keep the same secrets
+// in environment variables or a SOPS-encrypted file.
+type Backend interface {
+	// Name is the backend's --secret-backend value.
+	Name() string
+	// Read returns the value of an op:// reference. Like resolveOp, it never
+	// puts a value in an error.
+	Read(reference string) (string, error)
+}
+
+// Backend names accepted by --secret-backend and secret_backend.type.
+const (
+	BackendOnePassword = "1password"
+	BackendEnv         = "env"
+	BackendSops        = "sops"
+)
+
+// BackendNames lists the built-in backends, default first.
+var BackendNames = []string{BackendOnePassword, BackendEnv, BackendSops}
+
+// sopsCommandTimeout caps how long `sops --decrypt` may run.
+const sopsCommandTimeout = 30 * time.Second
+
+// BackendOptions configure the op:// backend; the config package passes the
+// homeops.yaml secret_backend block at load time.
+type BackendOptions struct {
+	Type string
+	// EnvMapFile is a YAML map of op:// reference to environment variable
+	// name, read by the env backend.
+	EnvMapFile string
+	// SopsFile is the age-encrypted YAML the sops backend decrypts.
+	SopsFile string
+}
+
+var backendState struct {
+	mu       sync.Mutex
+	options  BackendOptions
+	override string
+	current  Backend
+}
+
+// ConfigureBackend sets the backend options from the homeops config.
+func ConfigureBackend(options BackendOptions) {
+	backendState.mu.Lock()
+	defer backendState.mu.Unlock()
+	backendState.options = options
+	backendState.current = nil
+}
+
+// SetBackendOverride selects the backend for this process regardless of the
+// config, as --secret-backend does. "" restores the configured backend.
+func SetBackendOverride(name string) error {
+	if name != "" && !slices.Contains(BackendNames, name) {
+		return fmt.Errorf("unknown secret backend %q (want %s)", name, strings.Join(BackendNames, ", "))
+	}
+	backendState.mu.Lock()
+	defer backendState.mu.Unlock()
+	backendState.override = name
+	backendState.current = nil
+	return nil
+}
+
+// CurrentBackendName is the backend op:// references resolve through.
+func CurrentBackendName() string {
+	backendState.mu.Lock()
+	defer backendState.mu.Unlock()
+	return backendNameLocked()
+}
+
+func backendNameLocked() string {
+	if backendState.override != "" {
+		return backendState.override
+	}
+	if backendState.options.Type != "" {
+		return backendState.options.Type
+	}
+	return BackendOnePassword
+}
+
+// BackendBinary is the CLI the named backend runs, or "" when it needs none.
+func BackendBinary(name string) string {
+	switch name {
+	case BackendOnePassword:
+		return "op"
+	case BackendSops:
+		return "sops"
+	}
+	return ""
+}
+
+// CurrentBackend returns the selected backend, built on first use.
+func CurrentBackend() (Backend, error) {
+	backendState.mu.Lock()
+	defer backendState.mu.Unlock()
+	if backendState.current != nil {
+		return backendState.current, nil
+	}
+	options := backendState.options
+	switch name := backendNameLocked(); name {
+	case BackendOnePassword:
+		backendState.current = onePasswordBackend{}
+	case BackendEnv:
+		if options.EnvMapFile == "" {
+			return nil, fmt.Errorf("the env secret backend needs secret_backend.env_map_file in the homeops config")
+		}
+		backendState.current = &envBackend{mapFile: options.EnvMapFile}
+	case BackendSops:
+		if options.SopsFile == "" {
+			return nil, fmt.Errorf("the sops secret backend needs secret_backend.sops_file in the homeops config")
+		}
+		backendState.current = &sopsBackend{file: options.SopsFile}
+	default:
+		return nil, fmt.Errorf("unknown secret backend %q (want %s)", name, strings.Join(BackendNames, ", "))
+	}
+	return backendState.current, nil
+}
+
+func resolveOpBackend(reference string) (string, error) {
+	backend, err := CurrentBackend()
+	if err != nil {
+		return "", err
+	}
+	return backend.Read(reference)
+}
+
+// onePasswordBackend reads through the op CLI or a Connect server.
+type onePasswordBackend struct{}
+
+func (onePasswordBackend) Name() string { return BackendOnePassword }
+
+func (onePasswordBackend) Read(reference string) (string, error) {
+	return resolveOpCached(reference)
+}
+
+// envBackend maps each op:// reference to an environment variable.
+type envBackend struct {
+	mapFile string
+	once    sync.Once
+	vars    map[string]string
+	err     error
+}
+
+func (b *envBackend) Name() string { return BackendEnv }
+
+func (b *envBackend) Read(reference string) (string, error) {
+	b.once.Do(func() {
+		path, err := ExpandHome(b.mapFile)
+		if err != nil {
+			b.err = err
+			return
+		}
+		data, err := os.ReadFile(path) // #nosec G304 -- operator-configured env backend map
+		if err != nil {
+			b.err = fmt.Errorf("failed to read secret env map %s: %w", path, err)
+			return
+		}
+		if err := yaml.Unmarshal(data, &b.vars); err != nil {
+			b.err = fmt.Errorf("secret env map %s: %w", path, err)
+		}
+	})
+	if b.err != nil {
+		return "", b.err
+	}
+	name, ok := b.vars[reference]
+	if !ok {
+		return "", fmt.Errorf("%s has no environment variable in %s", reference, b.mapFile)
+	}
+	return resolveEnv(name)
+}
+
+// sopsDecryptFn runs `sops --decrypt` and is overridable in tests. Stdout is
+// the decrypted payload, so it is never redacted or put in an error.
+var sopsDecryptFn = func(path string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sopsCommandTimeout)
+	defer cancel()
+	result, err := common.RunCommand(ctx, common.CommandOptions{
+		Name:     "sops",
+		Args:     []string{"--decrypt", "--output-type", "json", path},
+		Redactor: identityRedactor,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sops could not decrypt %s: %w", path, err)
+	}
+	return []byte(result.Stdout), nil
+}
+
+// SetSopsDecryptFnForTesting overrides `sops --decrypt` for the duration of
+// a test. Returns a restore function the caller should defer.
+func SetSopsDecryptFnForTesting(fn func(path string) ([]byte, error)) func() {
+	old := sopsDecryptFn
+	sopsDecryptFn = fn
+	return func() { sopsDecryptFn = old }
+}
+
+// sopsBackend reads op://vault/item/[section/]field from a SOPS file nested
+// the same way (vault: item: field: value), or from a top-level key that is
+// the whole reference. The file is decrypted once per run unless
+// --no-secret-cache is set.
+type sopsBackend struct {
+	file string
+	mu   sync.Mutex
+	data map[string]any
+}
+
+func (b *sopsBackend) Name() string { return BackendSops }
+
+func (b *sopsBackend) Read(reference string) (string, error) {
+	data, err := b.decrypted()
+	if err != nil {
+		return "", err
+	}
+	if value, ok := data[reference]; ok {
+		return sopsValue(reference, value)
+	}
+	var node any = data
+	for _, part := range strings.Split(strings.TrimPrefix(reference, "op://"), "/") {
+		m, ok := node.(map[string]any)
+		if !ok {
+			node = nil
+			break
+		}
+		node = m[part]
+	}
+	if node == nil {
+		return "", fmt.Errorf("secret %s not found in %s", reference, b.file)
+	}
+	return sopsValue(reference, node)
+}
+
+func (b *sopsBackend) decrypted() (map[string]any, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.data != nil && !cacheDisabled() {
+		return b.data, nil
+	}
+	path, err := ExpandHome(b.file)
+	if err != nil {
+		return nil, err
+	}
+	out, err := sopsDecryptFn(path)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]any
+	if err := json.Unmarshal(out, &data); err != nil {
+		// Never echo the plaintext that failed to parse.
+		return nil, fmt.Errorf("sops output for %s is not a YAML map", path)
+	}
+	b.data = data
+	return data, nil
+}
+
+func sopsValue(reference string, value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return "", fmt.Errorf("secret %s is empty", reference)
+		}
+		return strings.TrimSpace(v), nil
+	case float64, bool:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("secret %s is not a single value; reference a field below it", reference)
+}
@@ -0,0 +1,86 @@
+package secrets
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/common"
+)
+
+// useBackend selects options for the test and restores the 1password default.
+func useBackend(t *testing.T, options BackendOptions) {
+	t.Helper()
+	ConfigureBackend(options)
+	t.Cleanup(func() {
+		ConfigureBackend(BackendOptions{})
+		_ = SetBackendOverride("")
+	})
+	restoreOp := SetOpReadFnForTesting(func(reference string) (common.CommandResult, error) {
+		t.Errorf("op read %s called with the %s backend", reference, CurrentBackendName())
+		return common.CommandResult{}, errors.New("op must not run")
+	})
+	t.Cleanup(restoreOp)
+}
+
+func TestSopsBackendResolvesNestedAndFlatKeys(t *testing.T) {
+	useBackend(t, BackendOptions{Type: BackendSops, SopsFile: "secrets.sops.yaml"})
+	decrypts := 0
+	t.Cleanup(SetSopsDecryptFnForTesting(func(path string) ([]byte, error) {
+		decrypts++
+		assert.Equal(t, "secrets.sops.yaml", path)
+		return []byte(`{"Talos":{"machine":{"token":"tok-123","ca":{"crt":" cert "}},"port":{"value":6443}},"op://Other/item/field":"flat"}`), nil
+	}))
+
+	out, err := Inject("token: op://Talos/machine/token\ncrt: op://Talos/machine/ca/crt\nport: op://Talos/port/value\nflat: op://Other/item/field\n")
+	require.NoError(t, err)
+	assert.Equal(t, "token: tok-123\ncrt: cert\nport: 6443\nflat: flat\n", out)
+	assert.Equal(t, 1, decrypts, "the file is decrypted once per run")
+
+	_, err = Resolve("op://Talos/machine/missing")
+	assert.EqualError(t, err, "secret op://Talos/machine/missing not found in secrets.sops.yaml")
+	_, err = Resolve("op://Talos/machine")
+	assert.ErrorContains(t, err, "not a single value")
+}
+
+func TestSopsBackendDecryptFailure(t *testing.T) {
+	useBackend(t, BackendOptions{Type: BackendSops, SopsFile: "secrets.sops.yaml"})
+	t.Cleanup(SetSopsDecryptFnForTesting(func(path string) ([]byte, error) {
+		return nil, errors.New("sops could not decrypt secrets.sops.yaml: exit status 128")
+	}))
+
+	_, err := Resolve("op://Talos/machine/token")
+	assert.ErrorContains(t, err, "sops could not decrypt")
+}
+
+func TestEnvBackendReadsMappedVariables(t *testing.T) {
+	mapFile := filepath.Join(t.TempDir(), "env-map.yaml")
+	require.NoError(t, os.WriteFile(mapFile, []byte("op://Talos/machine/token: TALOS_TOKEN\n"), 0o600))
+	useBackend(t, BackendOptions{Type: BackendEnv, EnvMapFile: mapFile})
+	t.Setenv("TALOS_TOKEN", "env-token")
+
+	value, err := Resolve("op://Talos/machine/token")
+	require.NoError(t, err)
+	assert.Equal(t, "env-token", value)
+
+	_, err = Resolve("op://Talos/machine/other")
+	assert.ErrorContains(t, err, "has no environment variable in "+mapFile)
+}
+
+func TestBackendOverrideAndValidation(t *testing.T) {
+	useBackend(t, BackendOptions{Type: BackendSops})
+	assert.Equal(t, BackendSops, CurrentBackendName())
+	_, err := Resolve("op://Talos/machine/token")
+	assert.ErrorContains(t, err, "needs secret_backend.sops_file")
+
+	require.NoError(t, SetBackendOverride(BackendEnv))
+	assert.Equal(t, BackendEnv, CurrentBackendName())
+	assert.ErrorContains(t, SetBackendOverride("vault"), `unknown secret backend "vault"`)
+
+	_, err = WritableBacking("op://Talos/machine/token")
+	assert.ErrorContains(t, err, "the env secret backend is read-only")
+}
@@ -0,0 +1,73 @@
+package secrets
+
+import "sync"
+
+// opCache holds the op:// values resolved in this process, so a reference
+// shared by several configs (base template, node patches, resources) is read
+// from 1Password once. Concurrent lookups of one reference share a single op
+// read. Failures are not kept: a lookup after `op signin` reads again.
+var opCache = struct {
+	mu       sync.Mutex
+	disabled bool
+	entries  map[string]*resolution
+}{entries: make(map[string]*resolution)}
+
+// SetCacheEnabled turns the process-wide op:// cache on or off. The root
+// command's --no-secret-cache disables it; disabling drops cached values.
+func SetCacheEnabled(enabled bool) {
+	opCache.mu.Lock()
+	defer opCache.mu.Unlock()
+	opCache.disabled = !enabled
+	if !enabled {
+		opCache.entries = make(map[string]*resolution)
+	}
+}
+
+func cacheDisabled() bool {
+	opCache.mu.Lock()
+	defer opCache.mu.Unlock()
+	return opCache.disabled
+}
+
+// resetCache empties the op:// cache and re-enables it.
+func resetCache() {
+	opCache.mu.Lock()
+	defer opCache.mu.Unlock()
+	opCache.disabled = false
+	opCache.entries = make(map[string]*resolution)
+}
+
+func resolveOpCached(reference string) (string, error) {
+	opCache.mu.Lock()
+	if opCache.disabled {
+		opCache.mu.Unlock()
+		return resolveOp(reference)
+	}
+	if cached, ok := opCache.entries[reference]; ok {
+		opCache.mu.Unlock()
+		<-cached.ready
+		return cached.value, cached.err
+	}
+	res := &resolution{ready: make(chan struct{})}
+	opCache.entries[reference] = res
+	opCache.mu.Unlock()
+
+	res.value, res.err = resolveOp(reference)
+
+	opCache.mu.Lock()
+	if res.err != nil && opCache.entries[reference] == res {
+		delete(opCache.entries, reference)
+	}
+	close(res.ready)
+	opCache.mu.Unlock()
+	return res.value, res.err
+}
+
+// forgetCached drops references whose stored value just changed.
+func forgetCached(references ...string) {
+	opCache.mu.Lock()
+	defer opCache.mu.Unlock()
+	for _, reference := range references {
+		delete(opCache.entries, reference)
+	}
+}
@@ -0,0 +1,73 @@
+package secrets
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/common"
+)
+
+func TestOpCacheReadsEachReferenceOnce(t *testing.T) {
+	var reads atomic.Int32
+	defer SetOpReadFnForTesting(func(reference string) (common.CommandResult, error) {
+		reads.Add(1)
+		return common.CommandResult{Stdout: "value-" + reference[len("op://talos/machine/"):]}, nil
+	})()
+
+	// Three node configs and resources.yaml share the token reference.
+	docs := []string{
+		"token: op://talos/machine/token\nca: op://talos/machine/ca\n",
+		"token: op://talos/machine/token\n",
+		"token: op://talos/machine/token\n",
+		"stringData:\n  token: op://talos/machine/token\n",
+	}
+	var wg sync.WaitGroup
+	for _, doc := range docs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resolved, err := Inject(doc)
+			assert.NoError(t, err)
+			assert.Contains(t, resolved, "token: value-token")
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), reads.Load(), "one op read per unique reference")
+
+	SetCacheEnabled(false)
+	_, err := Resolve("op://talos/machine/token")
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), reads.Load(), "--no-secret-cache reads every time")
+}
+
+func TestOpCacheRetriesFailuresAndForgetsWrites(t *testing.T) {
+	authenticated := false
+	var reads int
+	defer SetOpReadFnForTesting(func(reference string) (common.CommandResult, error) {
+		reads++
+		if !authenticated {
+			return common.CommandResult{Stderr: "[ERROR] not signed in"}, errors.New("exit 1")
+		}
+		return common.CommandResult{Stdout: "token-value"}, nil
+	})()
+
+	_, err := Resolve("op://talos/machine/token")
+	require.Error(t, err)
+	authenticated = true
+	value, err := Resolve("op://talos/machine/token")
+	require.NoError(t, err)
+	assert.Equal(t, "token-value", value, "a failed read is not cached")
+	_, err = Resolve("op://talos/machine/token")
+	require.NoError(t, err)
+	assert.Equal(t, 2, reads)
+
+	forgetCached("op://talos/machine/token")
+	_, err = Resolve("op://talos/machine/token")
+	require.NoError(t, err)
+	assert.Equal(t, 3, reads, "a written reference is read again")
+}
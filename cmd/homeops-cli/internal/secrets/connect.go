@@ -0,0 +1,153 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"homeops-cli/internal/constants"
+)
+
+// OpAuthMode is how op:// references are resolved in this process.
+type OpAuthMode string
+
+const (
+	// OpAuthConnect reads references from a 1Password Connect server's REST
+	// API; no op binary is needed.
+	OpAuthConnect OpAuthMode = "1Password Connect"
+	// OpAuthServiceAccount runs the op CLI, which authenticates itself from
+	// OP_SERVICE_ACCOUNT_TOKEN without a signin prompt.
+	OpAuthServiceAccount OpAuthMode = "service account token"
+	// OpAuthSession runs the op CLI against the user's signed-in session.
+	OpAuthSession OpAuthMode = "op CLI session"
+)
+
+// CurrentOpAuthMode picks Connect when OP_CONNECT_HOST and OP_CONNECT_TOKEN
+// are both set, then a service account token, then the op CLI session.
+func CurrentOpAuthMode() OpAuthMode {
+	switch {
+	case os.Getenv(constants.EnvOpConnectHost) != "" && os.Getenv(constants.EnvOpConnectToken) != "":
+		return OpAuthConnect
+	case os.Getenv(constants.EnvOpServiceAccountToken) != "":
+		return OpAuthServiceAccount
+	}
+	return OpAuthSession
+}
+
+// connectHTTPClient talks to the Connect server; tests point it elsewhere.
+var connectHTTPClient = &http.Client{Timeout: opCommandTimeout}
+
+type connectField struct {
+	ID      string `json:"id"`
+	Label   string `json:"label"`
+	Value   string `json:"value"`
+	Section *struct {
+		ID string `json:"id"`
+	} `json:"section"`
+}
+
+type connectItem struct {
+	Fields   []connectField `json:"fields"`
+	Sections []struct {
+		ID    string `json:"id"`
+		Label string `json:"label"`
+	} `json:"sections"`
+}
+
+// resolveConnect reads op://vault/item/[section/]field from the Connect
+// server. Vaults and items are matched by name, falling back to their ID;
+// fields by label or ID. Like resolveOp, it never puts a value in an error.
+func resolveConnect(reference string) (string, error) {
+	parts := strings.Split(strings.TrimPrefix(reference, "op://"), "/")
+	if len(parts) != 3 && len(parts) != 4 {
+		return "", fmt.Errorf("1Password reference %s must be op://vault/item/field or op://vault/item/section/field", reference)
+	}
+	vaultID, err := connectLookupID("/v1/vaults", parts[0])
+	if err != nil {
+		return "", fmt.Errorf("1Password Connect: vault of %s: %w", reference, err)
+	}
+	itemID, err := connectLookupID("/v1/vaults/"+url.PathEscape(vaultID)+"/items", parts[1])
+	if err != nil {
+		return "", fmt.Errorf("1Password Connect: item of %s: %w", reference, err)
+	}
+	var item connectItem
+	if err := connectGet("/v1/vaults/"+url.PathEscape(vaultID)+"/items/"+url.PathEscape(itemID), &item); err != nil {
+		return "", fmt.Errorf("1Password Connect: reading %s: %w", reference, err)
+	}
+
+	field, section := parts[len(parts)-1], ""
+	if len(parts) == 4 {
+		section = parts[2]
+	}
+	sectionIDs := map[string]bool{}
+	for _, s := range item.Sections {
+		if section != "" && (s.Label == section || s.ID == section) {
+			sectionIDs[s.ID] = true
+		}
+	}
+	for _, f := range item.Fields {
+		if f.Label != field && f.ID != field {
+			continue
+		}
+		if section != "" && (f.Section == nil || !sectionIDs[f.Section.ID]) {
+			continue
+		}
+		if strings.TrimSpace(f.Value) == "" {
+			return "", fmt.Errorf("1Password secret %s returned empty value", reference)
+		}
+		return strings.TrimSpace(f.Value), nil
+	}
+	return "", fmt.Errorf("1Password secret %s not found", reference)
+}
+
+// connectLookupID finds the ID of the vault or item named name under path.
+func connectLookupID(path, name string) (string, error) {
+	var matches []struct {
+		ID string `json:"id"`
+	}
+	filter := "name"
+	if strings.HasSuffix(path, "/items") {
+		filter = "title"
+	}
+	if err := connectGet(path+"?filter="+url.QueryEscape(fmt.Sprintf("%s eq %q", filter, name)), &matches); err != nil {
+		return "", err
+	}
+	switch len(matches) {
+	case 0:
+		// Not a name; Connect accepts the ID as-is and reports a bad one.
+		return name, nil
+	case 1:
+		return matches[0].ID, nil
+	}
+	return "", fmt.Errorf("%d matches for %q; use its ID", len(matches), name)
+}
+
+func connectGet(path string, dest any) error {
+	host := strings.TrimSuffix(os.Getenv(constants.EnvOpConnectHost), "/")
+	req, err := http.NewRequest(http.MethodGet, host+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+os.Getenv(constants.EnvOpConnectToken))
+	resp, err := connectHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		// The body is Connect's error message, not item content.
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&apiErr)
+		if apiErr.Message == "" {
+			apiErr.Message = resp.Status
+		}
+		return fmt.Errorf("%s (HTTP %d)", apiErr.Message, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(dest)
+}
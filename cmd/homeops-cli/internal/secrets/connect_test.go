@@ -0,0 +1,89 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/constants"
+)
+
+func TestCurrentOpAuthMode(t *testing.T) {
+	t.Setenv(constants.EnvOpConnectHost, "")
+	t.Setenv(constants.EnvOpConnectToken, "")
+	t.Setenv(constants.EnvOpServiceAccountToken, "")
+	assert.Equal(t, OpAuthSession, CurrentOpAuthMode())
+
+	t.Setenv(constants.EnvOpServiceAccountToken, "ops_token")
+	assert.Equal(t, OpAuthServiceAccount, CurrentOpAuthMode())
+
+	t.Setenv(constants.EnvOpConnectHost, "https://connect.local")
+	assert.Equal(t, OpAuthServiceAccount, CurrentOpAuthMode(), "Connect needs both host and token")
+	t.Setenv(constants.EnvOpConnectToken, "connect-token")
+	assert.Equal(t, OpAuthConnect, CurrentOpAuthMode())
+}
+
+func TestResolveOpViaConnect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer connect-token", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/v1/vaults":
+			assert.Equal(t, `name eq "Talos"`, r.URL.Query().Get("filter"))
+			_, _ = fmt.Fprint(w, `[{"id":"vault1"}]`)
+		case "/v1/vaults/vault1/items":
+			if r.URL.Query().Get("filter") == `title eq "missing"` {
+				_, _ = fmt.Fprint(w, `[]`)
+				return
+			}
+			assert.Equal(t, `title eq "machine"`, r.URL.Query().Get("filter"))
+			_, _ = fmt.Fprint(w, `[{"id":"item1"}]`)
+		case "/v1/vaults/vault1/items/item1":
+			_, _ = fmt.Fprint(w, `{"sections":[{"id":"s1","label":"etcd"}],"fields":[
+  {"id":"f1","label":"token","value":"machine-token-value\n"},
+  {"id":"f2","label":"crt","value":"etcd-crt-value","section":{"id":"s1"}},
+  {"id":"f3","label":"crt","value":"top-level-crt"}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, `{"status":404,"message":"Item not found"}`)
+		}
+	}))
+	defer server.Close()
+	t.Setenv(constants.EnvOpConnectHost, server.URL+"/")
+	t.Setenv(constants.EnvOpConnectToken, "connect-token")
+	defer SetOpReadFnForTesting(func(string) (common.CommandResult, error) {
+		t.Fatal("Connect mode must not run the op CLI")
+		return common.CommandResult{}, nil
+	})()
+
+	value, err := Resolve("op://Talos/machine/token")
+	require.NoError(t, err)
+	assert.Equal(t, "machine-token-value", value)
+	value, err = Resolve("op://Talos/machine/etcd/crt")
+	require.NoError(t, err)
+	assert.Equal(t, "etcd-crt-value", value, "a section narrows the field")
+
+	_, err = Resolve("op://Talos/machine/absent")
+	assert.EqualError(t, err, "1Password secret op://Talos/machine/absent not found")
+	_, err = Resolve("op://Talos/missing/token")
+	assert.EqualError(t, err, "1Password Connect: reading op://Talos/missing/token: Item not found (HTTP 404)")
+	require.NoError(t, EnsureOpAuth(), "Connect mode never signs in")
+}
+
+func TestEnsureOpAuthWithServiceAccountNeverSignsIn(t *testing.T) {
+	t.Setenv(constants.EnvOpConnectHost, "")
+	t.Setenv(constants.EnvOpServiceAccountToken, "ops_token")
+	old := opWhoamiFn
+	t.Cleanup(func() { opWhoamiFn = old })
+
+	opWhoamiFn = func() ([]byte, error) { return []byte(`{"user_type":"SERVICE_ACCOUNT"}`), nil }
+	require.NoError(t, EnsureOpAuth())
+
+	opWhoamiFn = func() ([]byte, error) { return nil, errors.New("exit status 1") }
+	assert.EqualError(t, EnsureOpAuth(), "op rejected OP_SERVICE_ACCOUNT_TOKEN: exit status 1 — check the token and that the op CLI is installed")
+}
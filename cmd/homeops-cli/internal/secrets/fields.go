@@ -0,0 +1,50 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ParseItemRef splits an op://vault/item reference that names a whole
+// 1Password item rather than one of its fields.
+func ParseItemRef(reference string) (vault, item string, err error) {
+	if !strings.HasPrefix(reference, "op://") {
+		return "", "", fmt.Errorf("1Password item reference %q must start with op://", reference)
+	}
+	parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(reference, "op://"), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("1Password item reference %q must be op://vault/item", reference)
+	}
+	return parts[0], parts[1], nil
+}
+
+// ItemFields returns the labels of an item's fields in 1Password's order.
+// Values are read by `op item get` but dropped here, so only names ever leave
+// this package. The notes field and unlabelled fields are skipped, and a label
+// repeated across sections is reported once.
+func ItemFields(vault, item string) ([]string, error) {
+	raw, err := opItemGetFn(vault, item)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Fields []struct {
+			Label   string `json:"label"`
+			Purpose string `json:"purpose"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse 1Password item op://%s/%s", vault, item)
+	}
+	seen := map[string]bool{}
+	var labels []string
+	for _, field := range doc.Fields {
+		if field.Label == "" || field.Purpose == "NOTES" || seen[field.Label] {
+			continue
+		}
+		seen[field.Label] = true
+		labels = append(labels, field.Label)
+	}
+	return labels, nil
+}
@@ -0,0 +1,56 @@
+package secrets
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/testutil"
+)
+
+func TestParseItemRef(t *testing.T) {
+	vault, item, err := ParseItemRef("op://Infrastructure/myapp")
+	require.NoError(t, err)
+	assert.Equal(t, [2]string{"Infrastructure", "myapp"}, [2]string{vault, item})
+
+	for _, bad := range []string{"Infrastructure/myapp", "op://Infrastructure", "op://Infrastructure/myapp/password", "op:///myapp"} {
+		_, _, err := ParseItemRef(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestItemFieldsGolden(t *testing.T) {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "op-item-myapp.json"))
+	require.NoError(t, err)
+	testutil.Swap(t, &opItemGetFn, func(vault, item string) ([]byte, error) {
+		assert.Equal(t, "Infrastructure", vault)
+		assert.Equal(t, "myapp", item)
+		return fixture, nil
+	})
+
+	labels, err := ItemFields("Infrastructure", "myapp")
+	require.NoError(t, err)
+	got := strings.Join(labels, "\n") + "\n"
+
+	golden := filepath.Join("testdata", "op-item-myapp.fields.golden")
+	if os.Getenv("UPDATE_GOLDEN") == "1" {
+		require.NoError(t, os.WriteFile(golden, []byte(got), 0o600))
+	}
+	want, err := os.ReadFile(golden)
+	require.NoError(t, err, "missing golden %s (run with UPDATE_GOLDEN=1)", golden)
+	assert.Equal(t, string(want), got)
+	assert.NotContains(t, got, "fixture-")
+}
+
+func TestItemFieldsPropagatesOpFailure(t *testing.T) {
+	testutil.Swap(t, &opItemGetFn, func(vault, item string) ([]byte, error) {
+		return nil, errors.New("failed to read 1Password item op://Infrastructure/missing")
+	})
+	_, err := ItemFields("Infrastructure", "missing")
+	assert.ErrorContains(t, err, "op://Infrastructure/missing")
+}
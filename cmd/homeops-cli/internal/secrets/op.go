@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"homeops-cli/internal/common"
+	"homeops-cli/internal/constants"
 )
 
 // opCommandTimeout caps how long a single `op read` call is allowed to run
@@ -20,6 +21,11 @@ const opCommandTimeout = 30 * time.Second
 // stdout are not corrupted by output redaction.
 var opReadFn = defaultOpRead
 
+// opWhoamiFn runs `op whoami`, which succeeds only when op is authenticated.
+var opWhoamiFn = func() ([]byte, error) {
+	return common.Command("op", "whoami", "--format=json").Output()
+}
+
 // identityRedactor preserves command output verbatim. We use it for `op read`
 // because the secret value IS the stdout payload — applying the standard
 // secret-label redactor would corrupt valid secrets that happen to look like
@@ -39,11 +45,16 @@ func defaultOpRead(reference string) (common.CommandResult, error) {
 }
 
 // SetOpReadFnForTesting overrides the underlying op-read implementation for
-// the duration of a test. Returns a restore function the caller should defer.
+// the duration of a test, starting and ending with an empty op:// cache.
+// Returns a restore function the caller should defer.
 func SetOpReadFnForTesting(fn func(reference string) (common.CommandResult, error)) func() {
 	old := opReadFn
 	opReadFn = fn
-	return func() { opReadFn = old }
+	resetCache()
+	return func() {
+		opReadFn = old
+		resetCache()
+	}
 }
 
 // resolveOp retrieves a secret from 1Password using the CLI with retry logic.
@@ -53,6 +64,9 @@ func SetOpReadFnForTesting(fn func(reference string) (common.CommandResult, erro
 // stderr — stdout is treated as the (possibly partial) secret payload and must
 // not leak into diagnostic output.
 func resolveOp(reference string) (string, error) {
+	if CurrentOpAuthMode() == OpAuthConnect {
+		return resolveConnect(reference)
+	}
 	const maxAttempts = 3
 	for attempts := 0; attempts < maxAttempts; attempts++ {
 		result, err := opReadFn(reference)
@@ -102,10 +116,22 @@ func classifyOpFailure(reference, stderr string) error {
 // interactive signin if necessary. It returns an error if authentication
 // cannot be confirmed. Callers should only invoke this when op:// references
 // are actually in play (see config.UsesOpReferences).
+//
+// With a Connect server configured there is no op session to check, and with
+// a service account token op authenticates itself, so neither ever prompts.
 func EnsureOpAuth() error {
+	switch CurrentOpAuthMode() {
+	case OpAuthConnect:
+		return nil
+	case OpAuthServiceAccount:
+		if _, err := opWhoamiFn(); err != nil {
+			return fmt.Errorf("op rejected %s: %w — check the token and that the op CLI is installed", constants.EnvOpServiceAccountToken, err)
+		}
+		return nil
+	}
+
 	// Check if already authenticated
-	cmd := common.Command("op", "whoami", "--format=json")
-	output, err := cmd.Output()
+	output, err := opWhoamiFn()
 	if err == nil {
 		var result map[string]interface{}
 		if jsonErr := json.Unmarshal(output, &result); jsonErr == nil {
@@ -119,12 +145,11 @@ func EnsureOpAuth() error {
 	signin.Stdout = os.Stdout
 	signin.Stderr = os.Stderr
 	if err := signin.Run(); err != nil {
-		return fmt.Errorf("failed to sign in to 1Password: %w — install the op CLI (https://developer.1password.com/docs/cli/get-started/) or switch your homeops config secrets to env://, file://, or cmd:// references", err)
+		return fmt.Errorf("failed to sign in to 1Password: %w — install the op CLI (https://developer.1password.com/docs/cli/get-started/), set OP_SERVICE_ACCOUNT_TOKEN or OP_CONNECT_HOST/OP_CONNECT_TOKEN, or switch your homeops config secrets to env://, file://, or cmd:// references", err)
 	}
 
 	// Verify authentication after signin
-	verify := common.Command("op", "whoami", "--format=json")
-	verifyOutput, err := verify.Output()
+	verifyOutput, err := opWhoamiFn()
 	if err != nil {
 		return fmt.Errorf("authentication verification failed: %w", err)
 	}
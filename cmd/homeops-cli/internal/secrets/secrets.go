@@ -2,7 +2,7 @@
 //
 // A secret reference is a URI whose scheme selects the backend:
 //
-//	op://vault/item/field    1Password CLI (`op read`)
+//	op://vault/item/field    1Password CLI (`op read`), or the selected Backend
 //	env://VAR_NAME           environment variable
 //	file:///path/to/file     file contents (trailing newline trimmed, ~ expanded)
 //	cmd://command args       stdout of a command run via the shell
@@ -20,18 +20,43 @@ import (
 	"sort"
 	"strings"
 	"sync"
+
+	"homeops-cli/internal/common"
 )
 
 // Resolve resolves a single secret reference via its scheme's provider.
 // References without a known scheme are rejected so typos fail loudly.
+// Resolved values (other than literal://) are registered with the common
+// redaction registry so they are masked in logs and error output.
 func Resolve(reference string) (string, error) {
 	scheme, rest, ok := splitScheme(reference)
 	if !ok {
 		return "", fmt.Errorf("secret reference %q has no recognised scheme (expected one of: %s)", reference, knownSchemeList())
 	}
+	value, err := resolveScheme(reference, scheme, rest)
+	if err == nil && scheme != "literal" {
+		common.RegisterSecret(redactionLabel(scheme, rest), value)
+	}
+	return value, err
+}
+
+// redactionLabel names a registered value in redacted output. op:// keeps the
+// vault/item/field path, which identifies the secret without revealing it;
+// env:// keeps the variable name; other schemes only name the backend.
+func redactionLabel(scheme, rest string) string {
+	switch scheme {
+	case "op":
+		return rest
+	case "env", "secret":
+		return scheme + ":" + rest
+	}
+	return scheme
+}
+
+func resolveScheme(reference, scheme, rest string) (string, error) {
 	switch scheme {
 	case "op":
-		return resolveOp(reference)
+		return resolveOpBackend(reference)
 	case "env":
 		return resolveEnv(rest)
 	case "file":
@@ -307,3 +332,36 @@ func ListReferences(content string) []string {
 	sort.Strings(out)
 	return out
 }
+
+// InjectedValues recovers the values Inject put in place of rendered's
+// references by matching the literal text around each reference in resolved.
+// References with no text between them come back as one value.
+func InjectedValues(rendered, resolved string) []string {
+	locs := RefRegex.FindAllStringIndex(rendered, -1)
+	if len(locs) == 0 {
+		return nil
+	}
+	prefix := rendered[:locs[0][0]]
+	suffix := rendered[locs[len(locs)-1][1]:]
+	if !strings.HasPrefix(resolved, prefix) || !strings.HasSuffix(resolved, suffix) || len(prefix)+len(suffix) > len(resolved) {
+		return nil
+	}
+	var values []string
+	start := len(prefix)
+	for i := 0; i < len(locs)-1; i++ {
+		literal := rendered[locs[i][1]:locs[i+1][0]]
+		if literal == "" {
+			continue
+		}
+		at := strings.Index(resolved[start:], literal)
+		if at < 0 {
+			return values
+		}
+		values = append(values, resolved[start:start+at])
+		start += at + len(literal)
+	}
+	if end := len(resolved) - len(suffix); end >= start {
+		values = append(values, resolved[start:end])
+	}
+	return values
+}
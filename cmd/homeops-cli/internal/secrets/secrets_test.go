@@ -90,6 +90,30 @@ func TestResolveOpProvider(t *testing.T) {
 	assert.Contains(t, err.Error(), "not found")
 }
 
+func TestResolveRegistersValuesForRedaction(t *testing.T) {
+	defer common.ResetSecretRegistryForTesting()()
+	restore := SetOpReadFnForTesting(func(string) (common.CommandResult, error) {
+		return common.CommandResult{Stdout: "op-secret-value\n"}, nil
+	})
+	defer restore()
+	t.Setenv("HOMEOPS_REDACT_TEST", "env-secret-value")
+
+	_, err := Resolve("op://Vault/Item/field")
+	require.NoError(t, err)
+	_, err = Resolve("env://HOMEOPS_REDACT_TEST")
+	require.NoError(t, err)
+	_, err = Resolve("literal://not-a-secret-knob")
+	require.NoError(t, err)
+
+	assert.Equal(t, "<redacted:Vault/Item/field> <redacted:env:HOMEOPS_REDACT_TEST> not-a-secret-knob",
+		common.RedactSecrets("op-secret-value env-secret-value not-a-secret-knob"))
+
+	// Re-resolving returns the real value, never the placeholder.
+	v, err := Resolve("op://Vault/Item/field")
+	require.NoError(t, err)
+	assert.Equal(t, "op-secret-value", v)
+}
+
 func TestResolveOpProviderMissingBinaryHint(t *testing.T) {
 	restore := SetOpReadFnForTesting(func(reference string) (common.CommandResult, error) {
 		return common.CommandResult{Stderr: `exec: "op": executable file not found in $PATH`}, errors.New("exec failure")
@@ -238,3 +262,11 @@ func TestExpandHome(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "/abs/path", got)
 }
+
+func TestInjectedValues(t *testing.T) {
+	rendered := "machine:\n  token: op://talos/machine/token\n  ca:\n    crt: op://talos/machine/crt\n    key: op://talos/machine/keyop://talos/machine/suffix\n"
+	resolved := "machine:\n  token: abcdef.0123456789abcdef\n  ca:\n    crt: LS0tLS1CRUdJTi\n    key: K3yPart1K3yPart2\n"
+	assert.Equal(t, []string{"abcdef.0123456789abcdef", "LS0tLS1CRUdJTi", "K3yPart1K3yPart2"}, InjectedValues(rendered, resolved),
+		"adjacent references come back as one value")
+	assert.Nil(t, InjectedValues("machine: {}\n", "machine: {}\n"))
+}
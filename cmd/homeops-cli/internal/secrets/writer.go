@@ -0,0 +1,227 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"homeops-cli/internal/common"
+)
+
+// Write-back is the inverse of Resolve for the two backends the CLI can
+// update in place: op:// fields inside an existing 1Password item, and
+// file:// paths. env://, cmd:// and literal:// have nothing to write to.
+
+// opItemGetFn returns an item's JSON (`op item get --format json`). The
+// payload holds every field value, so it must never be logged. Swappable for
+// tests.
+var opItemGetFn = func(vault, item string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), opCommandTimeout)
+	defer cancel()
+	result, err := common.RunCommand(ctx, common.CommandOptions{
+		Name:     "op",
+		Args:     []string{"item", "get", item, "--vault", vault, "--format", "json"},
+		Redactor: identityRedactor,
+	})
+	if err != nil {
+		if classified := classifyOpFailure(fmt.Sprintf("op://%s/%s", vault, item), result.Stderr); classified != nil {
+			return nil, classified
+		}
+		return nil, fmt.Errorf("failed to read 1Password item op://%s/%s", vault, item)
+	}
+	return []byte(result.Stdout), nil
+}
+
+// opItemEditFn pipes an edited item template to `op item edit` so new field
+// values travel via stdin and never appear in argv. Swappable for tests.
+var opItemEditFn = func(vault, item string, template []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), opCommandTimeout)
+	defer cancel()
+	result, err := common.RunCommand(ctx, common.CommandOptions{
+		Name:  "op",
+		Args:  []string{"item", "edit", item, "--vault", vault},
+		Stdin: strings.NewReader(string(template)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update 1Password item op://%s/%s: %w (output: %s)", vault, item, err, strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
+
+// WritableBacking follows a secret:// indirection and returns the op:// or
+// file:// reference a new value for it can be written to.
+func WritableBacking(reference string) (string, error) {
+	scheme, rest, ok := splitScheme(reference)
+	if !ok {
+		return "", fmt.Errorf("secret reference %q has no recognised scheme (expected one of: %s)", reference, knownSchemeList())
+	}
+	if scheme == "secret" {
+		keymapMu.RLock()
+		fn := keymapFn
+		keymapMu.RUnlock()
+		if fn == nil {
+			return "", fmt.Errorf("secret key %q referenced but no homeops config is loaded", rest)
+		}
+		backing, ok := fn(rest)
+		if !ok {
+			return "", fmt.Errorf("secret key %q is not defined in the homeops config 'secrets:' map", rest)
+		}
+		if strings.HasPrefix(backing, "secret://") {
+			return "", fmt.Errorf("secret key %q maps to another secret:// reference (%s) — only one level of indirection is allowed", rest, backing)
+		}
+		return WritableBacking(backing)
+	}
+	if scheme != "op" && scheme != "file" {
+		return "", fmt.Errorf("%s:// backings cannot be updated by the CLI; point the secret at op:// or file:// to have it written", scheme)
+	}
+	if scheme == "op" {
+		if name := CurrentBackendName(); name != BackendOnePassword {
+			return "", fmt.Errorf("the %s secret backend is read-only; update %s there yourself", name, reference)
+		}
+		if _, err := parseOpWriteRef(reference); err != nil {
+			return "", err
+		}
+	}
+	return reference, nil
+}
+
+// opWriteRef addresses one field of a 1Password item:
+// op://vault/item/field or op://vault/item/section/field.
+type opWriteRef struct {
+	vault, item, section, field string
+}
+
+func parseOpWriteRef(reference string) (opWriteRef, error) {
+	parts := strings.Split(strings.TrimPrefix(reference, "op://"), "/")
+	switch len(parts) {
+	case 3:
+		return opWriteRef{vault: parts[0], item: parts[1], field: parts[2]}, nil
+	case 4:
+		return opWriteRef{vault: parts[0], item: parts[1], section: parts[2], field: parts[3]}, nil
+	}
+	return opWriteRef{}, fmt.Errorf("1Password reference %s must be op://vault/item/field or op://vault/item/section/field to be written", reference)
+}
+
+// WriteAll stores each value at its reference (as returned by
+// WritableBacking). Fields of the same 1Password item are updated with one
+// edit; the fields must already exist, so a typo never creates a stray field.
+// Values are registered for redaction before anything runs.
+func WriteAll(values map[string]string) error {
+	refs := make([]string, 0, len(values))
+	for ref, value := range values {
+		common.RegisterSecret("write:"+ref, value)
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+
+	items := map[[2]string][]opWriteRef{}
+	var itemOrder [][2]string
+	for _, ref := range refs {
+		scheme, rest, _ := splitScheme(ref)
+		switch scheme {
+		case "file":
+			if err := writeSecretFile(rest, values[ref]); err != nil {
+				return err
+			}
+		case "op":
+			parsed, err := parseOpWriteRef(ref)
+			if err != nil {
+				return err
+			}
+			key := [2]string{parsed.vault, parsed.item}
+			if _, seen := items[key]; !seen {
+				itemOrder = append(itemOrder, key)
+			}
+			items[key] = append(items[key], parsed)
+		default:
+			return fmt.Errorf("cannot write secret reference %s: only op:// and file:// are writable", ref)
+		}
+	}
+	for _, key := range itemOrder {
+		fields := make(map[opWriteRef]string, len(items[key]))
+		for _, parsed := range items[key] {
+			fields[parsed] = values[opWriteRefString(parsed)]
+		}
+		if err := writeOpItemFields(key[0], key[1], fields); err != nil {
+			return err
+		}
+		for parsed := range fields {
+			forgetCached(opWriteRefString(parsed))
+		}
+	}
+	return nil
+}
+
+func opWriteRefString(ref opWriteRef) string {
+	if ref.section != "" {
+		return fmt.Sprintf("op://%s/%s/%s/%s", ref.vault, ref.item, ref.section, ref.field)
+	}
+	return fmt.Sprintf("op://%s/%s/%s", ref.vault, ref.item, ref.field)
+}
+
+func writeSecretFile(path, value string) error {
+	expanded, err := ExpandHome(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(expanded), 0700); err != nil {
+		return fmt.Errorf("failed to create directory for secret file %s: %w", expanded, err)
+	}
+	if err := os.WriteFile(expanded, []byte(value+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write secret file %s: %w", expanded, err)
+	}
+	return nil
+}
+
+// writeOpItemFields edits field values in the item's own JSON, so every other
+// field, section, and attribute round-trips unchanged.
+func writeOpItemFields(vault, item string, fields map[opWriteRef]string) error {
+	raw, err := opItemGetFn(vault, item)
+	if err != nil {
+		return err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return fmt.Errorf("failed to parse 1Password item op://%s/%s", vault, item)
+	}
+	itemFields, _ := doc["fields"].([]any)
+	for ref, value := range fields {
+		matched := false
+		for _, entry := range itemFields {
+			field, ok := entry.(map[string]any)
+			if !ok || !opFieldMatches(field, ref) {
+				continue
+			}
+			field["value"] = value
+			matched = true
+			break
+		}
+		if !matched {
+			return fmt.Errorf("1Password item op://%s/%s has no field %q to update", vault, item, ref.field)
+		}
+	}
+	template, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("marshal 1Password item op://%s/%s: %w", vault, item, err)
+	}
+	return opItemEditFn(vault, item, template)
+}
+
+func opFieldMatches(field map[string]any, ref opWriteRef) bool {
+	label, _ := field["label"].(string)
+	id, _ := field["id"].(string)
+	if label != ref.field && id != ref.field {
+		return false
+	}
+	if ref.section == "" {
+		return true
+	}
+	section, _ := field["section"].(map[string]any)
+	sectionLabel, _ := section["label"].(string)
+	sectionID, _ := section["id"].(string)
+	return sectionLabel == ref.section || sectionID == ref.section
+}
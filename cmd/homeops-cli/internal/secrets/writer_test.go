@@ -0,0 +1,125 @@
+package secrets
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/testutil"
+)
+
+func TestWritableBacking(t *testing.T) {
+	RegisterKeymap(func(key string) (string, bool) {
+		switch key {
+		case "in_op":
+			return "op://Infra/talos/machine_token", true
+		case "in_file":
+			return "file://~/secrets/token", true
+		case "in_env":
+			return "env://TALOS_TOKEN", true
+		case "short_op":
+			return "op://Infra/talos", true
+		}
+		return "", false
+	})
+	defer RegisterKeymap(nil)
+
+	backing, err := WritableBacking("secret://in_op")
+	require.NoError(t, err)
+	assert.Equal(t, "op://Infra/talos/machine_token", backing)
+
+	backing, err = WritableBacking("secret://in_file")
+	require.NoError(t, err)
+	assert.Equal(t, "file://~/secrets/token", backing)
+
+	_, err = WritableBacking("secret://in_env")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "env:// backings cannot be updated")
+
+	_, err = WritableBacking("secret://short_op")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "op://vault/item/field")
+
+	_, err = WritableBacking("secret://missing")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not defined")
+}
+
+func TestWriteAllEditsEachItemOnceViaStdin(t *testing.T) {
+	item := map[string]any{
+		"id":    "abc",
+		"title": "talos",
+		"fields": []any{
+			map[string]any{"id": "f1", "label": "machine_token", "value": "old-machine-token"},
+			map[string]any{"id": "f2", "label": "cluster_token", "value": "old-cluster-token", "section": map[string]any{"id": "s1", "label": "k8s"}},
+			map[string]any{"id": "f3", "label": "untouched", "value": "keep-me"},
+		},
+	}
+	raw, err := json.Marshal(item)
+	require.NoError(t, err)
+
+	var gets []string
+	testutil.Swap(t, &opItemGetFn, func(vault, name string) ([]byte, error) {
+		gets = append(gets, vault+"/"+name)
+		return raw, nil
+	})
+	var edited []byte
+	edits := 0
+	testutil.Swap(t, &opItemEditFn, func(vault, name string, template []byte) error {
+		edits++
+		assert.Equal(t, "Infra", vault)
+		assert.Equal(t, "talos", name)
+		edited = template
+		return nil
+	})
+
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "nested", "ca.key")
+	require.NoError(t, WriteAll(map[string]string{
+		"op://Infra/talos/machine_token":     "new-machine-token",
+		"op://Infra/talos/k8s/cluster_token": "new-cluster-token",
+		"file://" + filePath:                 "new-ca-key-material",
+	}))
+
+	assert.Equal(t, []string{"Infra/talos"}, gets)
+	assert.Equal(t, 1, edits, "fields of one item are written in a single edit")
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(edited, &got))
+	values := map[string]string{}
+	for _, entry := range got["fields"].([]any) {
+		field := entry.(map[string]any)
+		values[field["label"].(string)] = field["value"].(string)
+	}
+	assert.Equal(t, map[string]string{
+		"machine_token": "new-machine-token",
+		"cluster_token": "new-cluster-token",
+		"untouched":     "keep-me",
+	}, values)
+
+	content, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "new-ca-key-material\n", string(content))
+	info, err := os.Stat(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestWriteAllRefusesUnknownField(t *testing.T) {
+	testutil.Swap(t, &opItemGetFn, func(vault, name string) ([]byte, error) {
+		return []byte(`{"fields":[{"id":"f1","label":"machine_token","value":"x"}]}`), nil
+	})
+	testutil.Swap(t, &opItemEditFn, func(vault, name string, template []byte) error {
+		t.Fatal("no edit may run when a field is missing")
+		return nil
+	})
+
+	err := WriteAll(map[string]string{"op://Infra/talos/machine_tokn": "new-value-here"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `no field "machine_tokn"`)
+	assert.False(t, strings.Contains(err.Error(), "new-value-here"))
+}
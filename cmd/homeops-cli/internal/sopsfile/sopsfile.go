@@ -0,0 +1,456 @@
+// Package sopsfile decrypts, edits, re-keys, and verifies the repo's
+// sops-encrypted files in-process with the sops library, so none of it depends
+// on a sops binary or on SOPS_AGE_KEY_FILE being exported.
+package sopsfile
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/aes"
+	sopsage "github.com/getsops/sops/v3/age"
+	sopsconfig "github.com/getsops/sops/v3/config"
+	"github.com/getsops/sops/v3/keyservice"
+	sopsjson "github.com/getsops/sops/v3/stores/json"
+	sopsyaml "github.com/getsops/sops/v3/stores/yaml"
+)
+
+// DefaultPattern matches the repo's naming convention for encrypted files.
+var DefaultPattern = regexp.MustCompile(`\.sops\.(ya?ml|json)$`)
+
+// Identities are the age private keys used to decrypt data keys.
+type Identities struct {
+	parsed sopsage.ParsedIdentities
+}
+
+// ParseIdentities reads age identities ("AGE-SECRET-KEY-1...") from the
+// contents of a keys.txt; blank lines and # comments are ignored.
+func ParseIdentities(keys string) (Identities, error) {
+	var ids Identities
+	if err := ids.parsed.Import(keys); err != nil {
+		return Identities{}, err
+	}
+	if len(ids.parsed) == 0 {
+		return Identities{}, fmt.Errorf("no age identities found")
+	}
+	return ids, nil
+}
+
+// ReadIdentities reads age identities from a key file.
+func ReadIdentities(path string) (Identities, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Identities{}, fmt.Errorf("failed to read age key file: %w", err)
+	}
+	ids, err := ParseIdentities(string(data))
+	if err != nil {
+		return Identities{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return ids, nil
+}
+
+// identityKeyService decrypts age data keys with the given identities rather
+// than the ones sops would find through the environment. Every other key type
+// and all encryption go to the stock local key service.
+type identityKeyService struct {
+	keyservice.Server
+	ids sopsage.ParsedIdentities
+}
+
+func (s identityKeyService) Decrypt(ctx context.Context, req *keyservice.DecryptRequest) (*keyservice.DecryptResponse, error) {
+	ageKey := req.GetKey().GetAgeKey()
+	if ageKey == nil {
+		return s.Server.Decrypt(ctx, req)
+	}
+	key := sopsage.MasterKey{Recipient: ageKey.Recipient, EncryptedKey: string(req.Ciphertext)}
+	s.ids.ApplyToMasterKey(&key)
+	plaintext, err := key.Decrypt()
+	if err != nil {
+		return nil, err
+	}
+	return &keyservice.DecryptResponse{Plaintext: plaintext}, nil
+}
+
+func (ids Identities) keyServices() []keyservice.KeyServiceClient {
+	return []keyservice.KeyServiceClient{keyservice.NewCustomLocalClient(identityKeyService{ids: ids.parsed})}
+}
+
+// File is a decrypted sops file. Its metadata (key groups, encrypted_regex,
+// mac_only_encrypted, ...) is kept as loaded so Encrypted writes the file back
+// the way sops created it.
+type File struct {
+	Path     string
+	store    sops.Store
+	metadata sops.Metadata
+	plain    sops.TreeBranches
+	dataKey  []byte
+	ids      Identities
+}
+
+func storeFor(path string) sops.Store {
+	if strings.HasSuffix(path, ".json") {
+		return sopsjson.NewStore(&sopsconfig.JSONStoreConfig{})
+	}
+	return sopsyaml.NewStore(&sopsconfig.YAMLStoreConfig{})
+}
+
+// Decrypt loads and decrypts path, verifying its MAC.
+func Decrypt(path string, ids Identities) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	store := storeFor(path)
+	tree, err := store.LoadEncryptedFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a sops file: %w", path, err)
+	}
+	dataKey, err := tree.Metadata.GetDataKeyWithKeyServices(ids.keyServices(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt the data key of %s: %w", path, err)
+	}
+	cipher := aes.NewCipher()
+	computedMAC, err := tree.Decrypt(dataKey, cipher)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", path, err)
+	}
+	fileMAC, err := cipher.Decrypt(tree.Metadata.MessageAuthenticationCode, dataKey, tree.Metadata.LastModified.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt the MAC of %s: %w", path, err)
+	}
+	if fileMAC != computedMAC {
+		return nil, fmt.Errorf("MAC mismatch in %s: the file was modified outside sops", path)
+	}
+	return &File{Path: path, store: store, metadata: tree.Metadata, plain: tree.Branches, dataKey: dataKey, ids: ids}, nil
+}
+
+// Plaintext renders the decrypted document in the file's own format.
+func (f *File) Plaintext() ([]byte, error) {
+	return f.store.EmitPlainFile(f.plain)
+}
+
+// SetPlaintext replaces the document with edited plaintext and reports which
+// keys changed.
+func (f *File) SetPlaintext(plain []byte) (Changes, error) {
+	branches, err := f.store.LoadPlainFile(plain)
+	if err != nil {
+		return Changes{}, fmt.Errorf("edited %s is not valid: %w", filepath.Base(f.Path), err)
+	}
+	changes, err := diffBranches(f.plain, branches)
+	if err != nil {
+		return Changes{}, err
+	}
+	f.plain = branches
+	return changes, nil
+}
+
+// Encrypted re-encrypts the document with the file's data key and metadata.
+func (f *File) Encrypted() ([]byte, error) {
+	// Encrypt works in place; run it on a copy so f stays decrypted.
+	plain, err := f.Plaintext()
+	if err != nil {
+		return nil, err
+	}
+	branches, err := f.store.LoadPlainFile(plain)
+	if err != nil {
+		return nil, err
+	}
+	tree := sops.Tree{Branches: branches, Metadata: f.metadata, FilePath: f.Path}
+	cipher := aes.NewCipher()
+	mac, err := tree.Encrypt(f.dataKey, cipher)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt %s: %w", f.Path, err)
+	}
+	tree.Metadata.LastModified = time.Now().UTC()
+	if tree.Metadata.MessageAuthenticationCode, err = cipher.Encrypt(mac, f.dataKey, tree.Metadata.LastModified.Format(time.RFC3339)); err != nil {
+		return nil, fmt.Errorf("failed to encrypt the MAC of %s: %w", f.Path, err)
+	}
+	return f.store.EmitEncryptedFile(tree)
+}
+
+// Write re-encrypts the file and replaces it on disk, keeping its mode.
+func (f *File) Write() error {
+	out, err := f.Encrypted()
+	if err != nil {
+		return err
+	}
+	mode := fs.FileMode(0o644)
+	if info, err := os.Stat(f.Path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	return os.WriteFile(f.Path, out, mode)
+}
+
+// Recipients lists the file's age recipients in key-group order.
+func (f *File) Recipients() []string {
+	var recipients []string
+	for _, group := range f.metadata.KeyGroups {
+		for _, key := range group {
+			if ageKey, ok := key.(*sopsage.MasterKey); ok {
+				recipients = append(recipients, ageKey.Recipient)
+			}
+		}
+	}
+	return recipients
+}
+
+// KeyGroupSizes reports how many master keys each key group holds.
+func (f *File) KeyGroupSizes() []int {
+	sizes := make([]int, 0, len(f.metadata.KeyGroups))
+	for _, group := range f.metadata.KeyGroups {
+		sizes = append(sizes, len(group))
+	}
+	return sizes
+}
+
+// RotateRecipient adds recipient to the file and, when replace is set,
+// removes that recipient. recipient joins every key group replace was in, or,
+// when replace is empty, the first group with an age key; adding it to more
+// groups would let one key satisfy a shamir threshold alone. If anything changed, a
+// new data key is generated and wrapped for every master key, so a removed
+// recipient cannot read later versions. changed is false when the file
+// already has recipient and nothing was replaced.
+func (f *File) RotateRecipient(recipient, replace string) (changed bool, err error) {
+	newKey, err := sopsage.MasterKeyFromRecipient(recipient)
+	if err != nil {
+		return false, fmt.Errorf("invalid age recipient %q: %w", recipient, err)
+	}
+
+	groups := make([]sops.KeyGroup, len(f.metadata.KeyGroups))
+	targets := map[int]bool{}
+	for i, group := range f.metadata.KeyGroups {
+		for _, key := range group {
+			ageKey, isAge := key.(*sopsage.MasterKey)
+			switch {
+			case isAge && replace != "" && ageKey.Recipient == replace:
+				targets[i] = true
+				changed = true
+				continue
+			case isAge && replace == "" && len(targets) == 0 && !f.hasRecipient(recipient):
+				targets[i] = true
+			}
+			groups[i] = append(groups[i], key)
+		}
+	}
+	if replace != "" && !changed {
+		return false, fmt.Errorf("%s has no recipient %s", f.Path, replace)
+	}
+	if replace == "" && len(targets) == 0 && len(groups) > 0 && !f.hasRecipient(recipient) {
+		targets[0] = true
+	}
+	for i := range groups {
+		if !targets[i] || groupHasRecipient(groups[i], recipient) {
+			continue
+		}
+		groups[i] = append(groups[i], &sopsage.MasterKey{Recipient: newKey.Recipient})
+		changed = true
+	}
+	if !changed {
+		return false, nil
+	}
+	for i, group := range groups {
+		if len(group) == 0 {
+			return false, fmt.Errorf("removing %s would leave key group %d of %s empty", replace, i+1, f.Path)
+		}
+	}
+
+	metadata := f.metadata
+	metadata.KeyGroups = groups
+	tree := sops.Tree{Metadata: metadata}
+	dataKey, errs := tree.GenerateDataKeyWithKeyServices(f.ids.keyServices())
+	if len(errs) > 0 {
+		return false, fmt.Errorf("failed to wrap the new data key of %s: %v", f.Path, errs)
+	}
+	f.metadata, f.dataKey = tree.Metadata, dataKey
+	return true, nil
+}
+
+func (f *File) hasRecipient(recipient string) bool {
+	for _, group := range f.metadata.KeyGroups {
+		if groupHasRecipient(group, recipient) {
+			return true
+		}
+	}
+	return false
+}
+
+func groupHasRecipient(group sops.KeyGroup, recipient string) bool {
+	for _, key := range group {
+		if ageKey, ok := key.(*sopsage.MasterKey); ok && ageKey.Recipient == recipient {
+			return true
+		}
+	}
+	return false
+}
+
+// Find walks root for files whose root-relative path matches pattern,
+// skipping .git. Paths are returned sorted and relative to root.
+func Find(root string, pattern *regexp.Regexp) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if pattern.MatchString(filepath.ToSlash(rel)) {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// Changes lists the dotted key paths an edit added, removed, or modified.
+// Values are never included.
+type Changes struct {
+	Added    []string
+	Removed  []string
+	Modified []string
+}
+
+// Empty reports whether the edit changed nothing.
+func (c Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Modified) == 0
+}
+
+func diffBranches(before, after sops.TreeBranches) (Changes, error) {
+	old, err := flattenBranches(before)
+	if err != nil {
+		return Changes{}, err
+	}
+	updated, err := flattenBranches(after)
+	if err != nil {
+		return Changes{}, err
+	}
+	var changes Changes
+	for key, value := range updated {
+		previous, existed := old[key]
+		switch {
+		case !existed:
+			changes.Added = append(changes.Added, key)
+		case !reflect.DeepEqual(previous, value):
+			changes.Modified = append(changes.Modified, key)
+		}
+	}
+	for key := range old {
+		if _, kept := updated[key]; !kept {
+			changes.Removed = append(changes.Removed, key)
+		}
+	}
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Removed)
+	sort.Strings(changes.Modified)
+	return changes, nil
+}
+
+// flattenBranches maps every leaf of a (multi-document) tree to its path,
+// e.g. "stringData.password" or "items[2].name"; documents after the first
+// are prefixed "doc2:".
+func flattenBranches(branches sops.TreeBranches) (map[string]interface{}, error) {
+	out := map[string]interface{}{}
+	for i, branch := range branches {
+		tree, err := sops.EmitAsMap(sops.TreeBranches{branch})
+		if err != nil {
+			return nil, err
+		}
+		prefix := ""
+		if i > 0 {
+			prefix = fmt.Sprintf("doc%d:", i+1)
+		}
+		flattenValue(prefix, tree, out)
+	}
+	return out, nil
+}
+
+func flattenValue(path string, value interface{}, out map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			next := path + key
+			if path != "" && !strings.HasSuffix(path, ":") {
+				next = path + "." + key
+			}
+			flattenValue(next, child, out)
+		}
+	case sops.TreeBranch:
+		// EmitAsMap leaves branches nested in arrays unconverted.
+		tree, err := sops.EmitAsMap(sops.TreeBranches{v})
+		if err != nil {
+			out[path] = v
+			return
+		}
+		flattenValue(path, tree, out)
+	case []interface{}:
+		for i, child := range v {
+			flattenValue(fmt.Sprintf("%s[%d]", path, i), child, out)
+		}
+	default:
+		out[path] = v
+	}
+}
+
+// Result is the outcome for one file of a repo-wide operation.
+type Result struct {
+	Path    string // relative to the repo root
+	Changed bool
+	Err     error
+}
+
+// Verify decrypts every matching file under root with ids.
+func Verify(root string, pattern *regexp.Regexp, ids Identities) ([]Result, error) {
+	files, err := Find(root, pattern)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Result, 0, len(files))
+	for _, rel := range files {
+		_, err := Decrypt(filepath.Join(root, rel), ids)
+		results = append(results, Result{Path: rel, Err: err})
+	}
+	return results, nil
+}
+
+// RotateAll applies RotateRecipient to every matching file under root and
+// writes the changed ones back unless dryRun.
+func RotateAll(root string, pattern *regexp.Regexp, ids Identities, recipient, replace string, dryRun bool) ([]Result, error) {
+	if _, err := sopsage.MasterKeyFromRecipient(recipient); err != nil {
+		return nil, fmt.Errorf("invalid age recipient %q: %w", recipient, err)
+	}
+	files, err := Find(root, pattern)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Result, 0, len(files))
+	for _, rel := range files {
+		result := Result{Path: rel}
+		file, err := Decrypt(filepath.Join(root, rel), ids)
+		if err == nil {
+			result.Changed, err = file.RotateRecipient(recipient, replace)
+		}
+		if err == nil && result.Changed && !dryRun {
+			err = file.Write()
+		}
+		result.Err = err
+		results = append(results, result)
+	}
+	return results, nil
+}
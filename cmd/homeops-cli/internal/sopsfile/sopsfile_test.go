@@ -0,0 +1,234 @@
+package sopsfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// Recipients of the two identities in testdata/keys.txt.
+	recipientA = "age10mc7dsj7p2eaypnn2nkuwk4ja7fp0upxr9zx2j55pn8pjnsvpsaqcmc2le"
+	recipientB = "age174ad6qy8seswme3m70vzgy0vd6wvqqhv5shjjxfeejywcz2tdqqsesusau"
+
+	secretFile = "kubernetes/apps/media/app/secret.sops.yaml"
+	configFile = "kubernetes/apps/network/app/config.sops.json"
+	// foreignFile is encrypted to a recipient whose identity is not in keys.txt.
+	foreignFile = "kubernetes/apps/legacy/foreign.sops.yaml"
+)
+
+// fixtureRepo copies testdata/repo to a temp dir so tests can rewrite it.
+func fixtureRepo(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	require.NoError(t, os.CopyFS(root, os.DirFS(filepath.Join("testdata", "repo"))))
+	return root
+}
+
+func fixtureIdentities(t *testing.T) Identities {
+	t.Helper()
+	ids, err := ReadIdentities(filepath.Join("testdata", "keys.txt"))
+	require.NoError(t, err)
+	return ids
+}
+
+// sopsMetadata reads the raw, still-encrypted sops: block of a YAML file.
+func sopsMetadata(t *testing.T, path string) map[string]interface{} {
+	t.Helper()
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var doc struct {
+		Sops map[string]interface{} `yaml:"sops"`
+	}
+	require.NoError(t, yaml.Unmarshal(raw, &doc))
+	return doc.Sops
+}
+
+func TestEditPreservesSopsMetadata(t *testing.T) {
+	root := fixtureRepo(t)
+	path := filepath.Join(root, secretFile)
+	before := sopsMetadata(t, path)
+
+	file, err := Decrypt(path, fixtureIdentities(t))
+	require.NoError(t, err)
+	plain, err := file.Plaintext()
+	require.NoError(t, err)
+	assert.Contains(t, string(plain), "password: hunter2")
+
+	edited := strings.NewReplacer("password: hunter2", "password: correct-horse", "    api_key: abc123\n", "    region: eu\n").Replace(string(plain))
+	changes, err := file.SetPlaintext([]byte(edited))
+	require.NoError(t, err)
+	assert.Equal(t, Changes{Added: []string{"stringData.region"}, Removed: []string{"stringData.api_key"}, Modified: []string{"stringData.password"}}, changes)
+	require.NoError(t, file.Write())
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), "# Credentials for the media app", "comments survive")
+	assert.Contains(t, string(raw), "name: media-secret", "encrypted_regex still leaves metadata in the clear")
+	assert.NotContains(t, string(raw), "correct-horse")
+
+	after := sopsMetadata(t, path)
+	for _, key := range []string{"age", "encrypted_regex", "mac_only_encrypted", "version"} {
+		assert.Equal(t, before[key], after[key], "sops.%s is unchanged (same data key, same recipients)", key)
+	}
+	assert.NotEqual(t, before["mac"], after["mac"])
+
+	reopened, err := Decrypt(path, fixtureIdentities(t))
+	require.NoError(t, err)
+	plain, err = reopened.Plaintext()
+	require.NoError(t, err)
+	assert.Contains(t, string(plain), "password: correct-horse")
+	assert.NotContains(t, string(plain), "api_key")
+}
+
+func TestEditPreservesKeyGroups(t *testing.T) {
+	root := fixtureRepo(t)
+	path := filepath.Join(root, configFile)
+	file, err := Decrypt(path, fixtureIdentities(t))
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 1}, file.KeyGroupSizes())
+
+	plain, err := file.Plaintext()
+	require.NoError(t, err)
+	changes, err := file.SetPlaintext([]byte(strings.Replace(string(plain), `"t0k3n"`, `"n3w"`, 1)))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"accounts[0].token"}, changes.Modified)
+	require.NoError(t, file.Write())
+
+	reopened, err := Decrypt(path, fixtureIdentities(t))
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 1}, reopened.KeyGroupSizes())
+	assert.Equal(t, []string{recipientA, recipientB}, reopened.Recipients())
+
+	// shamir_threshold 2 survives: one group's identity is not enough.
+	onlyA, err := ParseIdentities(fixtureIdentity(t, 0))
+	require.NoError(t, err)
+	_, err = Decrypt(path, onlyA)
+	require.Error(t, err)
+}
+
+// fixtureIdentity returns the i-th AGE-SECRET-KEY line of testdata/keys.txt.
+func fixtureIdentity(t *testing.T, i int) string {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", "keys.txt"))
+	require.NoError(t, err)
+	var lines []string
+	for _, line := range strings.Split(string(raw), "\n") {
+		if strings.HasPrefix(line, "AGE-SECRET-KEY-") {
+			lines = append(lines, line)
+		}
+	}
+	require.Greater(t, len(lines), i)
+	return lines[i]
+}
+
+func TestSetPlaintextRejectsInvalidEdits(t *testing.T) {
+	file, err := Decrypt(filepath.Join("testdata", "repo", secretFile), fixtureIdentities(t))
+	require.NoError(t, err)
+	_, err = file.SetPlaintext([]byte("stringData: [unclosed"))
+	require.ErrorContains(t, err, "not valid")
+
+	plain, err := file.Plaintext()
+	require.NoError(t, err)
+	changes, err := file.SetPlaintext(plain)
+	require.NoError(t, err)
+	assert.True(t, changes.Empty())
+}
+
+func TestFindAndVerifyFixtureRepo(t *testing.T) {
+	root := fixtureRepo(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(root, ".git"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".git", "stale.sops.yaml"), []byte("x: 1\n"), 0o644))
+
+	files, err := Find(root, DefaultPattern)
+	require.NoError(t, err)
+	assert.Equal(t, []string{foreignFile, secretFile, configFile}, files, ".git and plain manifests are skipped")
+
+	results, err := Verify(root, DefaultPattern, fixtureIdentities(t))
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Error(t, results[0].Err, "foreign.sops.yaml is encrypted to someone else")
+	assert.NoError(t, results[1].Err)
+	assert.NoError(t, results[2].Err)
+}
+
+func TestRotateAllAcrossFixtureRepo(t *testing.T) {
+	root := fixtureRepo(t)
+	newIdentity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	newRecipient := newIdentity.Recipient().String()
+	ids := fixtureIdentities(t)
+	secretBefore := sopsMetadata(t, filepath.Join(root, secretFile))
+
+	results, err := RotateAll(root, DefaultPattern, ids, newRecipient, recipientA, true)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.True(t, results[1].Changed)
+	assert.Equal(t, secretBefore, sopsMetadata(t, filepath.Join(root, secretFile)), "--dry-run writes nothing")
+
+	results, err = RotateAll(root, DefaultPattern, ids, newRecipient, recipientA, false)
+	require.NoError(t, err)
+	assert.Error(t, results[0].Err)
+	for _, result := range results[1:] {
+		require.NoError(t, result.Err, result.Path)
+		assert.True(t, result.Changed, result.Path)
+	}
+
+	// The new identity plus B opens everything A used to; A alone opens nothing.
+	withNew, err := ParseIdentities(newIdentity.String() + "\n" + fixtureIdentity(t, 1))
+	require.NoError(t, err)
+	secret, err := Decrypt(filepath.Join(root, secretFile), withNew)
+	require.NoError(t, err)
+	assert.Equal(t, []string{newRecipient}, secret.Recipients())
+	config, err := Decrypt(filepath.Join(root, configFile), withNew)
+	require.NoError(t, err)
+	assert.Equal(t, []string{newRecipient, recipientB}, config.Recipients(), "the new key takes A's place in A's key group")
+	assert.Equal(t, []int{1, 1}, config.KeyGroupSizes())
+
+	onlyA, err := ParseIdentities(fixtureIdentity(t, 0))
+	require.NoError(t, err)
+	_, err = Decrypt(filepath.Join(root, secretFile), onlyA)
+	assert.Error(t, err)
+
+	secretAfter := sopsMetadata(t, filepath.Join(root, secretFile))
+	assert.Equal(t, secretBefore["encrypted_regex"], secretAfter["encrypted_regex"])
+	assert.Equal(t, secretBefore["mac_only_encrypted"], secretAfter["mac_only_encrypted"])
+
+	// Adding a recipient the files already have is a no-op.
+	results, err = RotateAll(root, DefaultPattern, withNew, newRecipient, "", false)
+	require.NoError(t, err)
+	assert.False(t, results[1].Changed)
+	assert.False(t, results[2].Changed)
+
+	_, err = RotateAll(root, DefaultPattern, ids, "not-a-recipient", "", false)
+	require.ErrorContains(t, err, "invalid age recipient")
+}
+
+func TestRotateRecipientAddsWithoutReplacing(t *testing.T) {
+	file, err := Decrypt(filepath.Join("testdata", "repo", secretFile), fixtureIdentities(t))
+	require.NoError(t, err)
+	changed, err := file.RotateRecipient(recipientB, "")
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, []string{recipientA, recipientB}, file.Recipients())
+
+	_, err = file.RotateRecipient(recipientB, "age1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqq")
+	require.ErrorContains(t, err, "has no recipient")
+
+	grouped, err := Decrypt(filepath.Join("testdata", "repo", configFile), fixtureIdentities(t))
+	require.NoError(t, err)
+	_, err = grouped.RotateRecipient(recipientB, "")
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 1}, grouped.KeyGroupSizes(), "B already holds a share")
+	newIdentity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	_, err = grouped.RotateRecipient(newIdentity.Recipient().String(), "")
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 1}, grouped.KeyGroupSizes(), "a new recipient joins one group, not every share")
+}
@@ -156,6 +156,49 @@ func (c *SSHClient) StreamCommand(ctx context.Context, command string, stdout io
 	return nil
 }
 
+// TailFile writes the last lines of remotePath to stdout. With follow it keeps
+// streaming appended output (tail -F, so a log that is recreated on VM restart
+// is picked up again) until ctx is cancelled, which counts as a clean exit.
+func (c *SSHClient) TailFile(ctx context.Context, remotePath string, lines int, follow bool, stdout io.Writer) error {
+	if stdout == nil {
+		return fmt.Errorf("SSH stream writer is required")
+	}
+	if c.keyLoadError != nil {
+		return c.keyLoadError
+	}
+	timeout := defaultSSHCommandTimeout
+	if follow {
+		timeout = 0
+	}
+	c.logger.Debug("Tailing %s (lines=%d, follow=%t)", remotePath, lines, follow)
+	result, err := runCommand(ctx, common.CommandOptions{
+		Name:    "ssh",
+		Args:    append(c.sshArgs(), tailFileCommand(remotePath, lines, follow)),
+		Timeout: timeout,
+		Stdout:  stdout,
+	})
+	if err != nil {
+		if follow && errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return fmt.Errorf("failed to tail %s via SSH: %w\nOutput: %s", remotePath, err, combinedCommandOutput(result))
+	}
+	return nil
+}
+
+// tailFileCommand builds the remote tail invocation; sudo because hypervisor
+// log files are owned by root.
+func tailFileCommand(remotePath string, lines int, follow bool) string {
+	if lines < 0 {
+		lines = 0
+	}
+	command := fmt.Sprintf("sudo tail -n %d", lines)
+	if follow {
+		command += " -F"
+	}
+	return command + " -- " + common.ShellQuote(remotePath)
+}
+
 func (c *SSHClient) runSSHCommand(remoteArgs ...string) (common.CommandResult, error) {
 	if c.keyLoadError != nil {
 		return common.CommandResult{}, c.keyLoadError
@@ -284,6 +327,16 @@ func combinedCommandOutput(result common.CommandResult) string {
 
 // DownloadISO downloads an ISO from a URL to a specific path on TrueNAS
 func (c *SSHClient) DownloadISO(isoURL, remotePath string) error {
+	return c.downloadISO(isoURL, remotePath, false)
+}
+
+// ResumeISODownload is DownloadISO that continues a partial file at
+// remotePath instead of starting over, retrying dropped connections.
+func (c *SSHClient) ResumeISODownload(isoURL, remotePath string) error {
+	return c.downloadISO(isoURL, remotePath, true)
+}
+
+func (c *SSHClient) downloadISO(isoURL, remotePath string, resume bool) error {
 	c.logger.Info("Downloading ISO from %s to %s", isoURL, remotePath)
 
 	// Create the directory if it doesn't exist (using sudo for permissions).
@@ -297,14 +350,18 @@ func (c *SSHClient) DownloadISO(isoURL, remotePath string) error {
 	}
 
 	// Download the ISO using wget or curl (using sudo for write permissions)
-	downloadCmd := fmt.Sprintf("sudo wget -O %s %s", common.ShellQuote(remotePath), common.ShellQuote(isoURL))
+	wgetFlags, curlFlags := "", ""
+	if resume {
+		wgetFlags, curlFlags = " -c --tries=5", " -C - --retry 5"
+	}
+	downloadCmd := fmt.Sprintf("sudo wget%s -O %s %s", wgetFlags, common.ShellQuote(remotePath), common.ShellQuote(isoURL))
 	c.logger.Debug("Download command: %s", downloadCmd)
 
 	_, err := c.ExecuteCommand(downloadCmd)
 	if err != nil {
 		// Try with curl as fallback (using sudo for write permissions)
 		c.logger.Debug("wget failed, trying curl: %v", err)
-		curlCmd := fmt.Sprintf("sudo curl -L -o %s %s", common.ShellQuote(remotePath), common.ShellQuote(isoURL))
+		curlCmd := fmt.Sprintf("sudo curl -L%s -o %s %s", curlFlags, common.ShellQuote(remotePath), common.ShellQuote(isoURL))
 		output, err := c.ExecuteCommand(curlCmd)
 		if err != nil {
 			return fmt.Errorf("failed to download ISO with both wget and curl: %w\nOutput: %s", err, output)
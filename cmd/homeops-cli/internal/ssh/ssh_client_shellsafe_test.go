@@ -51,4 +51,11 @@ func TestSSHCommandsAreShellSafe(t *testing.T) {
 		assert.Equal(t, "sudo mkdir -p "+common.ShellQuote("/tmp/iso dir"), captured[0])
 		assert.Equal(t, "sudo wget -O "+quoted+" "+common.ShellQuote("https://ex/x.iso"), captured[1])
 	})
+
+	t.Run("ResumeISODownload", func(t *testing.T) {
+		captured = nil
+		require.NoError(t, client.ResumeISODownload("https://ex/x.iso", evil))
+		require.Len(t, captured, 2)
+		assert.Equal(t, "sudo wget -c --tries=5 -O "+quoted+" "+common.ShellQuote("https://ex/x.iso"), captured[1])
+	})
 }
@@ -160,6 +160,50 @@ func TestSSHClientStreamCommandWritesDirectlyToWriter(t *testing.T) {
 	assert.Equal(t, snapshot, output.Bytes())
 }
 
+func TestSSHClientTailFile(t *testing.T) {
+	t.Run("prints the last lines with the default timeout", func(t *testing.T) {
+		restore := setCommandRunnerForTesting(func(_ context.Context, opts common.CommandOptions) (common.CommandResult, error) {
+			assert.Equal(t, defaultSSHCommandTimeout, opts.Timeout)
+			assert.Equal(t, "sudo tail -n 50 -- '/mnt/flashstor/vm-logs/k8s0.log'", opts.Args[len(opts.Args)-1])
+			_, err := opts.Stdout.Write([]byte("[    0.000000] Linux version 6.12\n"))
+			return common.CommandResult{}, err
+		})
+		defer restore()
+
+		client := NewSSHClient(SSHConfig{Host: "nas.local", Username: "admin", Port: "22"})
+		var output bytes.Buffer
+		require.NoError(t, client.TailFile(context.Background(), "/mnt/flashstor/vm-logs/k8s0.log", 50, false, &output))
+		assert.Equal(t, "[    0.000000] Linux version 6.12\n", output.String())
+	})
+
+	t.Run("follow has no timeout and treats cancellation as a clean exit", func(t *testing.T) {
+		restore := setCommandRunnerForTesting(func(ctx context.Context, opts common.CommandOptions) (common.CommandResult, error) {
+			assert.Zero(t, opts.Timeout)
+			assert.Equal(t, `sudo tail -n 0 -F -- '/mnt/tank/vm-logs/it'"'"'s.log'`, opts.Args[len(opts.Args)-1])
+			<-ctx.Done()
+			return common.CommandResult{ExitCode: -1}, ctx.Err()
+		})
+		defer restore()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		client := NewSSHClient(SSHConfig{Host: "nas.local", Username: "admin", Port: "22"})
+		require.NoError(t, client.TailFile(ctx, "/mnt/tank/vm-logs/it's.log", -5, true, &bytes.Buffer{}))
+	})
+
+	t.Run("surfaces remote failures", func(t *testing.T) {
+		restore := setCommandRunnerForTesting(func(context.Context, common.CommandOptions) (common.CommandResult, error) {
+			return common.CommandResult{Stderr: "tail: cannot open 'x.log': No such file or directory", ExitCode: 1}, errors.New("exit status 1")
+		})
+		defer restore()
+
+		client := NewSSHClient(SSHConfig{Host: "nas.local", Username: "admin", Port: "22"})
+		err := client.TailFile(context.Background(), "x.log", 10, false, &bytes.Buffer{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "No such file or directory")
+	})
+}
+
 func TestSSHClientUploadFileStreamsFileToSudoTee(t *testing.T) {
 	path := t.TempDir() + "/snapshot.db"
 	snapshot := []byte("snapshot\x00bytes")
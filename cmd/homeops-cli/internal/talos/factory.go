@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/netip"
 	"os"
 	"path/filepath"
 	"slices"
@@ -525,7 +526,7 @@ func parseTalosMemberIPs(output []byte) []string {
 		if err := json.Unmarshal(trimmed, &members); err == nil {
 			var ips []string
 			for _, member := range members {
-				ips = append(ips, member.Spec.Addresses...)
+				ips = append(ips, memberDisplayAddresses(member.Spec.Addresses)...)
 			}
 			return uniqueStrings(ips)
 		}
@@ -540,13 +541,28 @@ func parseTalosMemberIPs(output []byte) []string {
 		}
 		var member talosMember
 		if err := json.Unmarshal([]byte(line), &member); err == nil {
-			ips = append(ips, member.Spec.Addresses...)
+			ips = append(ips, memberDisplayAddresses(member.Spec.Addresses)...)
 		}
 	}
 
 	return uniqueStrings(ips)
 }
 
+// memberDisplayAddresses keeps a dual-stack member's IPv4 addresses so each
+// node is listed once; an IPv6-only member keeps its first address.
+func memberDisplayAddresses(addresses []string) []string {
+	var ipv4 []string
+	for _, address := range addresses {
+		if ip, err := netip.ParseAddr(address); err == nil && ip.Unmap().Is4() {
+			ipv4 = append(ipv4, address)
+		}
+	}
+	if len(ipv4) == 0 && len(addresses) > 0 {
+		return addresses[:1]
+	}
+	return ipv4
+}
+
 func parseTalosConfigEndpoints(output []byte) []string {
 	var configInfo struct {
 		Endpoints []string `json:"endpoints"`
@@ -302,6 +302,11 @@ func TestParseTalosMemberIPs(t *testing.T) {
 		assert.Equal(t, []string{"10.0.0.2", "10.0.0.3"}, parseTalosMemberIPs(output))
 	})
 
+	t.Run("dual-stack members list their IPv4 address", func(t *testing.T) {
+		output := []byte("[{\"spec\":{\"addresses\":[\"fd00::2\",\"10.0.0.2\"]}},{\"spec\":{\"addresses\":[\"fd00::3\"]}}]")
+		assert.Equal(t, []string{"10.0.0.2", "fd00::3"}, parseTalosMemberIPs(output))
+	})
+
 	t.Run("invalid", func(t *testing.T) {
 		assert.Nil(t, parseTalosMemberIPs([]byte("not-json")))
 	})
@@ -38,7 +38,7 @@ type TemplateData struct {
 func (r *GoTemplateRenderer) RenderTemplate(templateContent string, data TemplateData) (string, error) {
 	result, err := r.metrics.TrackOperationWithResult("gotemplate_render", func() (interface{}, error) {
 		// Create template with custom functions
-		tmpl := template.New("template").Funcs(r.createTemplateFuncs(data.RootDir))
+		tmpl := template.New("template").Funcs(r.createTemplateFuncs(data.RootDir, nil))
 
 		// Parse template
 		tmpl, err := tmpl.Parse(templateContent)
@@ -76,8 +76,9 @@ func (r *GoTemplateRenderer) RenderFile(templatePath string, data TemplateData)
 	return result.(string), nil
 }
 
-// createTemplateFuncs creates custom template functions similar to helmfile
-func (r *GoTemplateRenderer) createTemplateFuncs(rootDir string) template.FuncMap {
+// createTemplateFuncs creates custom template functions similar to helmfile.
+// settings back env the way bootstrap's helmfile environment does.
+func (r *GoTemplateRenderer) createTemplateFuncs(rootDir string, settings map[string]string) template.FuncMap {
 	return template.FuncMap{
 		"exec": func(command string, args []interface{}) (string, error) {
 			return r.execCommand(command, args)
@@ -102,6 +103,9 @@ func (r *GoTemplateRenderer) createTemplateFuncs(rootDir string) template.FuncMa
 			if key == "ROOT_DIR" && rootDir != "" {
 				return rootDir
 			}
+			if value, ok := settings[key]; ok {
+				return value
+			}
 			return os.Getenv(key)
 		},
 		// Add helmfile-compatible functions
@@ -187,7 +191,7 @@ func (r *GoTemplateRenderer) indentText(spaces int, text string) string {
 // ValidateTemplate validates a Go template without executing it
 func (r *GoTemplateRenderer) ValidateTemplate(templateContent string) error {
 	return r.metrics.TrackOperation("gotemplate_validate", func() error {
-		tmpl := template.New("validate").Funcs(r.createTemplateFuncs(r.rootDir))
+		tmpl := template.New("validate").Funcs(r.createTemplateFuncs(r.rootDir, nil))
 		_, err := tmpl.Parse(templateContent)
 		if err != nil {
 			return errors.NewTemplateError("VALIDATION_FAILED", "Go template validation failed", err)
@@ -229,8 +233,9 @@ type ReleaseInfo struct {
 	Namespace string
 }
 
-// RenderHelmfileValues renders helmfile values using the dynamic template
-func (r *GoTemplateRenderer) RenderHelmfileValues(valuesTemplate string, release string) (string, error) {
+// RenderHelmfileValues renders helmfile values using the dynamic template.
+// settings are exposed as .Settings (cluster-settings.yaml keys).
+func (r *GoTemplateRenderer) RenderHelmfileValues(valuesTemplate string, release string, settings map[string]string) (string, error) {
 	// Find the namespace for this release by searching the apps directory
 	namespace, err := r.findReleaseNamespace(release)
 	if err != nil {
@@ -252,9 +257,10 @@ func (r *GoTemplateRenderer) RenderHelmfileValues(valuesTemplate string, release
 
 	// Use a custom data structure that includes Release at the top level
 	customData := map[string]interface{}{
-		"RootDir": r.rootDir,
-		"Values":  data.Values,
-		"Release": releaseInfo,
+		"RootDir":  r.rootDir,
+		"Values":   data.Values,
+		"Release":  releaseInfo,
+		"Settings": settings,
 	}
 
 	return r.renderTemplateWithCustomData(valuesTemplate, customData)
@@ -265,17 +271,19 @@ func (r *GoTemplateRenderer) renderTemplateWithCustomData(templateContent string
 	result, err := r.metrics.TrackOperationWithResult("gotemplate_render_custom", func() (interface{}, error) {
 		// Create template with custom functions - we need to pass the root dir from the data
 		var rootDir string
+		var settings map[string]string
 		if dataMap, ok := data.(map[string]interface{}); ok {
 			if rd, ok := dataMap["RootDir"].(string); ok {
 				rootDir = rd
 			} else {
 				rootDir = r.rootDir
 			}
+			settings, _ = dataMap["Settings"].(map[string]string)
 		} else {
 			rootDir = r.rootDir
 		}
 
-		tmpl := template.New("template").Funcs(r.createTemplateFuncs(rootDir))
+		tmpl := template.New("template").Funcs(r.createTemplateFuncs(rootDir, settings))
 
 		// Parse template
 		tmpl, err := tmpl.Parse(templateContent)
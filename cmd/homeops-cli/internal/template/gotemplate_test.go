@@ -69,9 +69,9 @@ func TestGoTemplateRendererReleaseNamespaceHelpers(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "media", namespace)
 
-	rendered, err := renderer.RenderHelmfileValues(`{{ .Release.Namespace }}:{{ .Release.Name }}`, "seerr")
+	rendered, err := renderer.RenderHelmfileValues(`{{ .Release.Namespace }}:{{ .Release.Name }}:{{ .Settings.TIMEZONE }}`, "seerr", map[string]string{"TIMEZONE": "Etc/UTC"})
 	require.NoError(t, err)
-	assert.Equal(t, "media:seerr", rendered)
+	assert.Equal(t, "media:seerr:Etc/UTC", rendered)
 
 	_, err = renderer.findReleaseNamespace("missing")
 	require.Error(t, err)
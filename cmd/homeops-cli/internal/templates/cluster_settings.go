@@ -0,0 +1,238 @@
+package templates
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"homeops-cli/internal/config"
+)
+
+// ClusterSettingsFile is the settings file name, both embedded and as a
+// templates.dir override.
+const ClusterSettingsFile = "cluster-settings.yaml"
+
+// ClusterSettingsConfigMapName is the Flux cluster-settings ConfigMap that
+// cluster-apps reads for postBuild substitution.
+const ClusterSettingsConfigMapName = "cluster-settings"
+
+//go:embed cluster-settings.yaml
+var clusterSettingsTemplates embed.FS
+
+var settingKeyPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// ClusterSettings is the single source of cluster-specific substitution
+// values. Values maps each key to its effective value; Sources records where
+// the value came from for `config show`.
+type ClusterSettings struct {
+	Values  map[string]string
+	Sources map[string]string
+}
+
+// Keys returns the setting keys in sorted order.
+func (s *ClusterSettings) Keys() []string {
+	keys := make([]string, 0, len(s.Values))
+	for key := range s.Values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Has reports whether key is a known setting.
+func (s *ClusterSettings) Has(key string) bool {
+	_, ok := s.Values[key]
+	return ok
+}
+
+// derivedClusterSettings are the keys owned by homeops.yaml. Keeping them out
+// of cluster-settings.yaml's control is what stops the Talos, kubeadm and
+// Flux copies of the CIDRs and names from drifting apart again.
+//
+// The *_V6 keys are empty unless cluster.dual_stack is on; POD_CIDRS and
+// SERVICE_CIDRS are the comma-joined family pairs (just the IPv4 CIDR when
+// single-stack) that kubeadm and most charts accept.
+func derivedClusterSettings(cfg *config.Config) []struct{ key, value, source string } {
+	ipv6 := config.ClusterIPv6Config{}
+	if cfg.Cluster.DualStack {
+		ipv6 = cfg.Cluster.IPv6
+	}
+	return []struct{ key, value, source string }{
+		{"CLUSTER_NAME", cfg.ClusterNameWithDefault(), "homeops.yaml (cluster.name)"},
+		{"CLUSTER_DNS_DOMAIN", cfg.Cluster.DNSDomain, "homeops.yaml (cluster.dns_domain)"},
+		{"CLUSTER_DNS", cfg.ClusterDNS(), "homeops.yaml (derived from cluster.service_cidr)"},
+		{"POD_CIDR", cfg.Cluster.PodCIDR, "homeops.yaml (cluster.pod_cidr)"},
+		{"SERVICE_CIDR", cfg.Cluster.ServiceCIDR, "homeops.yaml (cluster.service_cidr)"},
+		{"NODE_SUBNET", cfg.Cluster.NodeSubnet, "homeops.yaml (cluster.node_subnet)"},
+		{"CONTROL_PLANE_VIP", cfg.Cluster.ControlPlaneVIP, "homeops.yaml (cluster.control_plane_vip)"},
+		{"DUAL_STACK", strconv.FormatBool(cfg.Cluster.DualStack), "homeops.yaml (cluster.dual_stack)"},
+		{"POD_CIDR_V6", ipv6.PodCIDR, "homeops.yaml (cluster.ipv6.pod_cidr)"},
+		{"SERVICE_CIDR_V6", ipv6.ServiceCIDR, "homeops.yaml (cluster.ipv6.service_cidr)"},
+		{"NODE_SUBNET_V6", ipv6.NodeSubnet, "homeops.yaml (cluster.ipv6.node_subnet)"},
+		{"CONTROL_PLANE_VIP_V6", ipv6.ControlPlaneVIP, "homeops.yaml (cluster.ipv6.control_plane_vip)"},
+		{"POD_CIDRS", strings.Join(cfg.PodCIDRs(), ","), "homeops.yaml (cluster.pod_cidr + cluster.ipv6.pod_cidr)"},
+		{"SERVICE_CIDRS", strings.Join(cfg.ServiceCIDRs(), ","), "homeops.yaml (cluster.service_cidr + cluster.ipv6.service_cidr)"},
+		{"TALOS_DISK_ENCRYPTION", diskEncryptionSetting(cfg.Cluster.Talos.DiskEncryption), "homeops.yaml (cluster.talos.disk_encryption.provider)"},
+	}
+}
+
+// diskEncryptionSetting is the key provider, or "off" when STATE and
+// EPHEMERAL stay unencrypted.
+func diskEncryptionSetting(enc config.TalosDiskEncryption) string {
+	if !enc.Enabled() {
+		return "off"
+	}
+	return enc.Provider
+}
+
+// LoadClusterSettings reads cluster-settings.yaml (templates.dir override
+// first, embedded copy otherwise) and merges in the keys derived from the
+// effective homeops.yaml.
+func LoadClusterSettings() (*ClusterSettings, error) {
+	data, err := readTemplateFile(clusterSettingsTemplates, ClusterSettingsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", ClusterSettingsFile, err)
+	}
+	return parseClusterSettings(data, config.Get())
+}
+
+func parseClusterSettings(data []byte, cfg *config.Config) (*ClusterSettings, error) {
+	raw := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ClusterSettingsFile, err)
+	}
+
+	settings := &ClusterSettings{Values: map[string]string{}, Sources: map[string]string{}}
+	for key, value := range raw {
+		if !settingKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("%s: key %q must be UPPER_SNAKE_CASE", ClusterSettingsFile, key)
+		}
+		switch v := value.(type) {
+		case nil:
+			settings.Values[key] = ""
+		case map[string]interface{}, []interface{}:
+			return nil, fmt.Errorf("%s: key %s must be a scalar value", ClusterSettingsFile, key)
+		default:
+			settings.Values[key] = fmt.Sprint(v)
+		}
+		settings.Sources[key] = ClusterSettingsFile
+	}
+
+	for _, derived := range derivedClusterSettings(cfg) {
+		if fileValue, ok := settings.Values[derived.key]; ok && fileValue != "" && fileValue != derived.value {
+			return nil, fmt.Errorf("%s sets %s=%q but %s is %q; set it in homeops.yaml instead",
+				ClusterSettingsFile, derived.key, fileValue, derived.source, derived.value)
+		}
+		settings.Values[derived.key] = derived.value
+		settings.Sources[derived.key] = derived.source
+	}
+	return settings, nil
+}
+
+// substituteSettings replaces {{ SETTINGS.KEY }} placeholders, the settings
+// counterpart of the {{ ENV.KEY }} replacement the Jinja-style renderers do.
+func substituteSettings(content string, settings *ClusterSettings) string {
+	if !strings.Contains(content, "{{ SETTINGS.") {
+		return content
+	}
+	for key, value := range settings.Values {
+		content = strings.ReplaceAll(content, fmt.Sprintf("{{ SETTINGS.%s }}", key), value)
+	}
+	return content
+}
+
+// Environ returns the settings as sorted KEY=value pairs, for tools such as
+// helmfile whose templates read them with env.
+func (s *ClusterSettings) Environ() []string {
+	environ := make([]string, 0, len(s.Values))
+	for _, key := range s.Keys() {
+		environ = append(environ, key+"="+s.Values[key])
+	}
+	return environ
+}
+
+// ConfigMap renders the Flux cluster-settings ConfigMap in namespace.
+func (s *ClusterSettings) ConfigMap(namespace string) (string, error) {
+	manifest := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":      ClusterSettingsConfigMapName,
+			"namespace": namespace,
+			"labels": map[string]string{
+				"app.kubernetes.io/managed-by": "homeops-cli",
+			},
+		},
+		"data": s.Values,
+	}
+	out, err := yaml.Marshal(manifest)
+	if err != nil {
+		return "", fmt.Errorf("failed to render %s ConfigMap: %w", ClusterSettingsConfigMapName, err)
+	}
+	return string(out), nil
+}
+
+var (
+	jinjaSettingRef = regexp.MustCompile(`\{\{\s*SETTINGS\.([A-Za-z0-9_]+)\s*\}\}`)
+	goSettingRef    = regexp.MustCompile(`\.Settings\.([A-Za-z0-9_]+)|index\s+\.Settings\s+"([^"]+)"`)
+)
+
+// UnknownSettingRef is a template reference to a key cluster-settings.yaml
+// does not define.
+type UnknownSettingRef struct {
+	Template string `json:"template"`
+	Line     int    `json:"line"`
+	Key      string `json:"key"`
+}
+
+// LintTemplateSettings scans every embedded template (through its
+// templates.dir override, when present) for {{ SETTINGS.KEY }} and
+// .Settings.KEY references and returns those naming unknown keys.
+func LintTemplateSettings() ([]UnknownSettingRef, error) {
+	settings, err := LoadClusterSettings()
+	if err != nil {
+		return nil, err
+	}
+	var unknown []UnknownSettingRef
+	for _, embedded := range []embed.FS{volsyncTemplates, talosTemplates, bootstrapTemplates, flatcarTemplates} {
+		err := fs.WalkDir(embedded, ".", func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			content, err := readTemplateFile(embedded, path)
+			if err != nil {
+				return err
+			}
+			unknown = append(unknown, unknownSettingRefs(path, string(content), settings)...)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan templates: %w", err)
+		}
+	}
+	return unknown, nil
+}
+
+func unknownSettingRefs(name, content string, settings *ClusterSettings) []UnknownSettingRef {
+	var unknown []UnknownSettingRef
+	for i, line := range strings.Split(content, "\n") {
+		for _, pattern := range []*regexp.Regexp{jinjaSettingRef, goSettingRef} {
+			for _, match := range pattern.FindAllStringSubmatch(line, -1) {
+				key := match[1]
+				if key == "" && len(match) > 2 {
+					key = match[2]
+				}
+				if !settings.Has(key) {
+					unknown = append(unknown, UnknownSettingRef{Template: name, Line: i + 1, Key: key})
+				}
+			}
+		}
+	}
+	return unknown
+}
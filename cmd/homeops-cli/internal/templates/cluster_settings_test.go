@@ -0,0 +1,169 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"homeops-cli/internal/config"
+	"homeops-cli/internal/metrics"
+)
+
+// withTemplateOverrides points templates.dir at a temp dir seeded with files
+// (relative path -> content) on top of cluster.
+func withTemplateOverrides(t *testing.T, cluster config.ClusterConfig, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for rel, content := range files {
+		path := filepath.Join(dir, rel)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	t.Cleanup(config.SetForTesting(&config.Config{Cluster: cluster, Templates: config.TemplatesConfig{Dir: dir}}))
+	return dir
+}
+
+func TestLoadClusterSettingsMergesFileAndHomeopsConfig(t *testing.T) {
+	restore := config.SetForTesting(&config.Config{Cluster: config.ClusterConfig{
+		Name: "lab", PodCIDR: "10.244.0.0/16", ServiceCIDR: "10.96.0.0/12", DNSDomain: "corp.local", ControlPlaneVIP: "10.0.0.5",
+	}})
+	defer restore()
+
+	settings, err := LoadClusterSettings()
+	require.NoError(t, err)
+	assert.Equal(t, "America/New_York", settings.Values["TIMEZONE"], "embedded default")
+	assert.Equal(t, ClusterSettingsFile, settings.Sources["TIMEZONE"])
+	assert.Equal(t, "lab", settings.Values["CLUSTER_NAME"])
+	assert.Equal(t, "10.96.0.10", settings.Values["CLUSTER_DNS"])
+	assert.Equal(t, "10.0.0.5", settings.Values["CONTROL_PLANE_VIP"])
+	assert.Equal(t, "homeops.yaml (cluster.pod_cidr)", settings.Sources["POD_CIDR"])
+	assert.Equal(t, []string{
+		"CLUSTER_DNS", "CLUSTER_DNS_DOMAIN", "CLUSTER_NAME", "CONTROL_PLANE_VIP", "CONTROL_PLANE_VIP_V6", "DUAL_STACK",
+		"NODE_SUBNET", "NODE_SUBNET_V6", "POD_CIDR", "POD_CIDRS", "POD_CIDR_V6", "SERVICE_CIDR", "SERVICE_CIDRS", "SERVICE_CIDR_V6", "TALOS_DISK_ENCRYPTION", "TIMEZONE",
+	}, settings.Keys())
+	assert.Equal(t, "false", settings.Values["DUAL_STACK"])
+	assert.Equal(t, "10.244.0.0/16", settings.Values["POD_CIDRS"], "single-stack pair is just the IPv4 CIDR")
+	assert.Empty(t, settings.Values["POD_CIDR_V6"])
+
+	withTemplateOverrides(t, config.ClusterConfig{PodCIDR: "10.244.0.0/16"}, map[string]string{
+		ClusterSettingsFile: "TIMEZONE: Etc/UTC\nPOD_CIDR: 10.244.0.0/16\nLB_POOL: 10.0.0.200-10.0.0.250\nREPLICAS: 3\n",
+	})
+	settings, err = LoadClusterSettings()
+	require.NoError(t, err)
+	assert.Equal(t, "Etc/UTC", settings.Values["TIMEZONE"], "templates.dir override")
+	assert.Equal(t, "3", settings.Values["REPLICAS"])
+	assert.True(t, settings.Has("LB_POOL"))
+	assert.False(t, settings.Has("SECRET_DOMAIN"))
+}
+
+func TestClusterSettingsRejectsDriftAndBadKeys(t *testing.T) {
+	cfg := &config.Config{Cluster: config.ClusterConfig{PodCIDR: "10.42.0.0/16"}}
+
+	_, err := parseClusterSettings([]byte("POD_CIDR: 10.244.0.0/16\n"), cfg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "set it in homeops.yaml instead")
+
+	_, err = parseClusterSettings([]byte("timezone: Etc/UTC\n"), cfg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "UPPER_SNAKE_CASE")
+
+	_, err = parseClusterSettings([]byte("POOLS: [a, b]\n"), cfg)
+	require.Error(t, err)
+
+	_, err = parseClusterSettings([]byte("- not a map\n"), cfg)
+	require.Error(t, err)
+}
+
+func TestClusterSettingsInjectedIntoTalosTemplates(t *testing.T) {
+	withTemplateOverrides(t, config.ClusterConfig{Name: "lab", DNSDomain: "corp.local", NodeSubnet: "10.0.0.0/24"}, map[string]string{
+		ClusterSettingsFile: "NTP_POOL: time.cloudflare.com\n",
+		"talos/worker.yaml": "cluster:\n  clusterName: {{ SETTINGS.CLUSTER_NAME }}\n  ntp: {{ SETTINGS.NTP_POOL }}\n  legacy: {{ ENV.CLUSTER_NAME }}\n",
+	})
+
+	worker, err := RenderTalosTemplate("talos/worker.yaml", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "cluster:\n  clusterName: lab\n  ntp: time.cloudflare.com\n  legacy: lab\n", worker)
+
+	controlplane, err := RenderTalosTemplate("talos/controlplane.yaml", nil)
+	require.NoError(t, err)
+	assert.Contains(t, controlplane, "dnsDomain: corp.local")
+	assert.Contains(t, controlplane, "- 10.0.0.0/24")
+	require.NoError(t, ValidateTemplateSubstitution("controlplane.yaml", "", controlplane))
+	require.Error(t, ValidateTemplateSubstitution("worker.yaml", "", "ntp: {{ SETTINGS.MISSING }}"))
+}
+
+func TestClusterSettingsInjectedIntoHelmfileValues(t *testing.T) {
+	withTemplateOverrides(t, config.ClusterConfig{Name: "lab"}, map[string]string{
+		"bootstrap/helmfile.d/templates/values.yaml.gotmpl": "tz: {{ .Settings.TIMEZONE }}\ncluster: {{ index .Settings \"CLUSTER_NAME\" }}\nrelease: {{ .Release.Namespace }}/{{ .Release.Name }}\n",
+	})
+	rootDir := t.TempDir()
+	releaseDir := filepath.Join(rootDir, "kubernetes", "apps", "media", "seerr", "app")
+	require.NoError(t, os.MkdirAll(releaseDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(releaseDir, "helmrelease.yaml"), []byte("spec: {}\n"), 0o644))
+
+	values, err := RenderHelmfileValues("seerr", rootDir, metrics.NewPerformanceCollector())
+	require.NoError(t, err)
+	assert.Equal(t, "tz: America/New_York\ncluster: lab\nrelease: media/seerr\n", values)
+}
+
+func TestCiliumValuesEnableIPv6ForDualStack(t *testing.T) {
+	rootDir := t.TempDir()
+	releaseDir := filepath.Join(rootDir, "kubernetes", "apps", "kube-system", "cilium", "app")
+	require.NoError(t, os.MkdirAll(releaseDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(releaseDir, "helmrelease.yaml"), []byte("spec:\n  values:\n    ipv4NativeRoutingCIDR: 10.42.0.0/16\n"), 0o644))
+
+	render := func() map[string]interface{} {
+		values, err := RenderHelmfileValues("cilium", rootDir, metrics.NewPerformanceCollector())
+		require.NoError(t, err)
+		var parsed map[string]interface{}
+		require.NoError(t, yaml.Unmarshal([]byte(values), &parsed))
+		return parsed
+	}
+
+	t.Cleanup(config.SetForTesting(&config.Config{}))
+	assert.Equal(t, map[string]interface{}{"ipv4NativeRoutingCIDR": "10.42.0.0/16"}, render(), "single-stack values are the HelmRelease values")
+
+	t.Cleanup(config.SetForTesting(&config.Config{Cluster: dualStackTestCluster()}))
+	assert.Equal(t, map[string]interface{}{
+		"ipv4NativeRoutingCIDR": "10.42.0.0/16",
+		"ipv6":                  map[string]interface{}{"enabled": true},
+		"ipv6NativeRoutingCIDR": "fd00:10:42::/56",
+	}, render())
+}
+
+func TestClusterSettingsConfigMap(t *testing.T) {
+	settings, err := parseClusterSettings([]byte("TIMEZONE: Etc/UTC\n"), &config.Config{Cluster: config.ClusterConfig{Name: "lab"}})
+	require.NoError(t, err)
+
+	manifest, err := settings.ConfigMap("flux-system")
+	require.NoError(t, err)
+	assert.Contains(t, manifest, "kind: ConfigMap")
+	assert.Contains(t, manifest, "name: cluster-settings")
+	assert.Contains(t, manifest, "namespace: flux-system")
+	assert.Contains(t, manifest, "app.kubernetes.io/managed-by: homeops-cli")
+	assert.Contains(t, manifest, "TIMEZONE: Etc/UTC")
+	assert.Contains(t, manifest, "CLUSTER_NAME: lab")
+}
+
+func TestLintTemplateSettingsFlagsUnknownKeys(t *testing.T) {
+	restore := config.SetForTesting(&config.Config{})
+	unknown, err := LintTemplateSettings()
+	restore()
+	require.NoError(t, err)
+	assert.Empty(t, unknown, "embedded templates only reference known settings")
+
+	withTemplateOverrides(t, config.ClusterConfig{}, map[string]string{
+		"talos/worker.yaml": "a: {{ SETTINGS.POD_CIDR }}\nb: {{ SETTINGS.POD_CIRD }}\n",
+		"bootstrap/helmfile.d/templates/values.yaml.gotmpl": "tz: {{ .Settings.TIMEZONE }}\nx: {{ index .Settings \"LB_POOL\" }}\n",
+	})
+	unknown, err = LintTemplateSettings()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []UnknownSettingRef{
+		{Template: "talos/worker.yaml", Line: 2, Key: "POD_CIRD"},
+		{Template: "bootstrap/helmfile.d/templates/values.yaml.gotmpl", Line: 2, Key: "LB_POOL"},
+	}, unknown)
+}
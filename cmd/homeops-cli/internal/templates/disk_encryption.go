@@ -0,0 +1,156 @@
+package templates
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"homeops-cli/internal/config"
+)
+
+// DiskEncryptionLayout names the key kinds protecting each system partition,
+// "" for an unencrypted one.
+type DiskEncryptionLayout struct {
+	State     string
+	Ephemeral string
+}
+
+func (l DiskEncryptionLayout) String() string {
+	describe := func(kind string) string {
+		if kind == "" {
+			return "none"
+		}
+		return kind
+	}
+	return fmt.Sprintf("state %s, ephemeral %s", describe(l.State), describe(l.Ephemeral))
+}
+
+// DesiredDiskEncryption is the layout cluster.talos.disk_encryption asks for.
+func DesiredDiskEncryption(enc config.TalosDiskEncryption) DiskEncryptionLayout {
+	return DiskEncryptionLayout{State: enc.Provider, Ephemeral: enc.Provider}
+}
+
+// talosDiskEncryptionKey renders the single key slot for provider at the
+// list indent under machine.systemDiskEncryption.<partition>.keys.
+func talosDiskEncryptionKey(provider string) string {
+	switch provider {
+	case config.DiskEncryptionNodeID:
+		return "          - slot: 0\n            nodeID: {}"
+	case config.DiskEncryptionStatic:
+		return "          - slot: 0\n            static:\n              passphrase: secret://" + config.KeyTalosDiskEncryptionKey
+	case config.DiskEncryptionTPM:
+		return "          - slot: 0\n            tpm: {}"
+	}
+	return ""
+}
+
+// talosDiskEncryptionStanza renders machine.systemDiskEncryption for STATE
+// and EPHEMERAL, or nothing when encryption is off. The static passphrase
+// stays a secret:// reference until secrets are injected.
+func talosDiskEncryptionStanza(enc config.TalosDiskEncryption) string {
+	key := talosDiskEncryptionKey(enc.Provider)
+	if key == "" {
+		return ""
+	}
+	var builder strings.Builder
+	builder.WriteString("  systemDiskEncryption:")
+	for _, partition := range []string{"state", "ephemeral"} {
+		fmt.Fprintf(&builder, "\n    %s:\n      provider: luks2\n      keys:\n%s", partition, key)
+	}
+	return builder.String()
+}
+
+// talosEncryptionDoc is the part of a rendered Talos config that carries
+// systemDiskEncryption.
+type talosEncryptionDoc struct {
+	Machine struct {
+		SystemDiskEncryption *struct {
+			State     *talosEncryptedPartition `yaml:"state"`
+			Ephemeral *talosEncryptedPartition `yaml:"ephemeral"`
+		} `yaml:"systemDiskEncryption"`
+	} `yaml:"machine"`
+}
+
+type talosEncryptedPartition struct {
+	Keys []map[string]any `yaml:"keys"`
+}
+
+// kinds lists the key kinds of a partition (nodeid, static, tpm, kms), sorted
+// and joined with "+".
+func (p *talosEncryptedPartition) kinds() string {
+	if p == nil {
+		return ""
+	}
+	seen := map[string]bool{}
+	for _, key := range p.Keys {
+		for field := range key {
+			if field != "slot" {
+				seen[strings.ToLower(field)] = true
+			}
+		}
+	}
+	kinds := make([]string, 0, len(seen))
+	for kind := range seen {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return strings.Join(kinds, "+")
+}
+
+// parseDiskEncryptionLayout reads the first document of a rendered Talos
+// config. ok is false when the document has no systemDiskEncryption key.
+func parseDiskEncryptionLayout(rendered string) (DiskEncryptionLayout, bool, error) {
+	var doc talosEncryptionDoc
+	if err := yaml.NewDecoder(strings.NewReader(rendered)).Decode(&doc); err != nil {
+		return DiskEncryptionLayout{}, false, err
+	}
+	enc := doc.Machine.SystemDiskEncryption
+	if enc == nil {
+		return DiskEncryptionLayout{}, false, nil
+	}
+	return DiskEncryptionLayout{State: enc.State.kinds(), Ephemeral: enc.Ephemeral.kinds()}, true, nil
+}
+
+// LintDiskEncryptionTemplates renders the Talos base templates and each
+// configured node's patch and reports every config whose
+// systemDiskEncryption differs from cluster.talos.disk_encryption. Node
+// patches only matter when they set systemDiskEncryption themselves, which
+// overrides the base for that node.
+func LintDiskEncryptionTemplates() ([]string, error) {
+	cfg := config.Get()
+	want := DesiredDiskEncryption(cfg.Cluster.Talos.DiskEncryption)
+	var problems []string
+	for _, name := range []string{"talos/controlplane.yaml", "talos/worker.yaml"} {
+		rendered, err := RenderTalosTemplate(name, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s: %w", name, err)
+		}
+		got, _, err := parseDiskEncryptionLayout(rendered)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse rendered %s: %w", name, err)
+		}
+		if got != want {
+			problems = append(problems, fmt.Sprintf("%s: systemDiskEncryption is %s, cluster.talos.disk_encryption wants %s", name, got, want))
+		}
+	}
+	for _, node := range cfg.Cluster.Nodes {
+		name := fmt.Sprintf("talos/nodes/%s.yaml", node.IP)
+		if _, err := GetTalosTemplate(name); err != nil {
+			continue
+		}
+		rendered, err := RenderTalosTemplate(name, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s: %w", name, err)
+		}
+		got, overrides, err := parseDiskEncryptionLayout(rendered)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse rendered %s: %w", name, err)
+		}
+		if overrides && got != want {
+			problems = append(problems, fmt.Sprintf("%s (%s): systemDiskEncryption is %s, cluster.talos.disk_encryption wants %s", name, node.Name, got, want))
+		}
+	}
+	return problems, nil
+}
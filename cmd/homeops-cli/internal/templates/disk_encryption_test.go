@@ -0,0 +1,71 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/config"
+)
+
+func TestTalosDiskEncryptionStanza(t *testing.T) {
+	assert.Empty(t, talosDiskEncryptionStanza(config.TalosDiskEncryption{}), "off renders nothing")
+	for provider, kind := range map[string]string{
+		config.DiskEncryptionNodeID: "nodeid",
+		config.DiskEncryptionStatic: "static",
+		config.DiskEncryptionTPM:    "tpm",
+	} {
+		enc := config.TalosDiskEncryption{Provider: provider}
+		layout, ok, err := parseDiskEncryptionLayout("machine:\n" + talosDiskEncryptionStanza(enc) + "\n")
+		require.NoError(t, err, provider)
+		assert.True(t, ok, provider)
+		assert.Equal(t, DiskEncryptionLayout{State: kind, Ephemeral: kind}, layout, provider)
+		assert.Equal(t, DesiredDiskEncryption(enc), layout, provider)
+	}
+	assert.Contains(t, talosDiskEncryptionStanza(config.TalosDiskEncryption{Provider: config.DiskEncryptionStatic}),
+		"passphrase: secret://"+config.KeyTalosDiskEncryptionKey)
+}
+
+func TestRenderedTalosConfigCarriesDiskEncryption(t *testing.T) {
+	t.Cleanup(config.SetForTesting(&config.Config{Cluster: config.ClusterConfig{
+		Talos: config.TalosSettings{DiskEncryption: config.TalosDiskEncryption{Provider: config.DiskEncryptionTPM}},
+	}}))
+	rendered, err := RenderTalosTemplate("talos/controlplane.yaml", nil)
+	require.NoError(t, err)
+	layout, ok, err := parseDiskEncryptionLayout(rendered)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, DiskEncryptionLayout{State: "tpm", Ephemeral: "tpm"}, layout)
+
+	problems, err := LintDiskEncryptionTemplates()
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+}
+
+func TestLintDiskEncryptionTemplatesFindsNodeOverride(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "talos", "nodes"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "talos", "nodes", "192.168.122.10.yaml"), []byte(`machine:
+  systemDiskEncryption:
+    state:
+      provider: luks2
+      keys:
+        - slot: 0
+          nodeID: {}
+`), 0o644))
+	t.Cleanup(config.SetForTesting(&config.Config{
+		Cluster: config.ClusterConfig{
+			Nodes: []config.Node{{Name: "k8s-0", IP: "192.168.122.10"}, {Name: "k8s-1", IP: "192.168.122.11"}},
+			Talos: config.TalosSettings{DiskEncryption: config.TalosDiskEncryption{Provider: config.DiskEncryptionTPM}},
+		},
+		Templates: config.TemplatesConfig{Dir: dir},
+	}))
+	problems, err := LintDiskEncryptionTemplates()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"talos/nodes/192.168.122.10.yaml (k8s-0): systemDiskEncryption is state nodeid, ephemeral none, cluster.talos.disk_encryption wants state tpm, ephemeral tpm",
+	}, problems)
+}
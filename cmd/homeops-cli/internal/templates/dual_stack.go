@@ -0,0 +1,91 @@
+package templates
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"homeops-cli/internal/config"
+)
+
+// talosNetworkDoc is the part of a rendered Talos base config that carries
+// per-family CIDRs.
+type talosNetworkDoc struct {
+	Machine struct {
+		Kubelet struct {
+			NodeIP struct {
+				ValidSubnets []string `yaml:"validSubnets"`
+			} `yaml:"nodeIP"`
+		} `yaml:"kubelet"`
+	} `yaml:"machine"`
+	Cluster struct {
+		Network struct {
+			PodSubnets     []string `yaml:"podSubnets"`
+			ServiceSubnets []string `yaml:"serviceSubnets"`
+		} `yaml:"network"`
+		Etcd struct {
+			AdvertisedSubnets []string `yaml:"advertisedSubnets"`
+		} `yaml:"etcd"`
+	} `yaml:"cluster"`
+}
+
+// LintDualStackTemplates renders the Talos base templates and reports every
+// CIDR list that should carry both address families but does not. It returns
+// nothing for single-stack clusters.
+func LintDualStackTemplates() ([]string, error) {
+	cfg := config.Get()
+	if !cfg.Cluster.DualStack {
+		return nil, nil
+	}
+	var problems []string
+	for _, name := range []string{"talos/controlplane.yaml", "talos/worker.yaml"} {
+		rendered, err := RenderTalosTemplate(name, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s: %w", name, err)
+		}
+		var doc talosNetworkDoc
+		if err := yaml.NewDecoder(strings.NewReader(rendered)).Decode(&doc); err != nil {
+			return nil, fmt.Errorf("failed to parse rendered %s: %w", name, err)
+		}
+		check := func(field string, values []string) {
+			if missing := missingFamilies(values); missing != "" {
+				problems = append(problems, fmt.Sprintf("%s: %s has no %s entry (got %v)", name, field, missing, values))
+			}
+		}
+		check("cluster.network.podSubnets", doc.Cluster.Network.PodSubnets)
+		check("cluster.network.serviceSubnets", doc.Cluster.Network.ServiceSubnets)
+		if cfg.Cluster.IPv6.NodeSubnet != "" {
+			check("machine.kubelet.nodeIP.validSubnets", doc.Machine.Kubelet.NodeIP.ValidSubnets)
+			if name == "talos/controlplane.yaml" {
+				check("cluster.etcd.advertisedSubnets", doc.Cluster.Etcd.AdvertisedSubnets)
+			}
+		}
+	}
+	return problems, nil
+}
+
+// missingFamilies names the address families absent from a CIDR list.
+func missingFamilies(values []string) string {
+	var ipv4, ipv6 bool
+	for _, value := range values {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			continue
+		}
+		if prefix.Addr().Is4() {
+			ipv4 = true
+		} else {
+			ipv6 = true
+		}
+	}
+	var missing []string
+	if !ipv4 {
+		missing = append(missing, "IPv4")
+	}
+	if !ipv6 {
+		missing = append(missing, "IPv6")
+	}
+	return strings.Join(missing, " or ")
+}
@@ -0,0 +1,83 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homeops-cli/internal/config"
+)
+
+func dualStackTestCluster() config.ClusterConfig {
+	return config.ClusterConfig{
+		PodCIDR:     "10.42.0.0/16",
+		ServiceCIDR: "10.43.0.0/16",
+		NodeSubnet:  "192.168.122.0/24",
+		DualStack:   true,
+		IPv6: config.ClusterIPv6Config{
+			PodCIDR:     "fd00:10:42::/56",
+			ServiceCIDR: "fd00:10:43::/112",
+			NodeSubnet:  "fd00:192:168:122::/64",
+		},
+	}
+}
+
+func TestDualStackClusterSettings(t *testing.T) {
+	cluster := dualStackTestCluster()
+	cluster.IPv6.ControlPlaneVIP = "fd00:192:168:122::100"
+	settings, err := parseClusterSettings(nil, &config.Config{Cluster: cluster})
+	require.NoError(t, err)
+	assert.Equal(t, "true", settings.Values["DUAL_STACK"])
+	assert.Equal(t, "10.42.0.0/16,fd00:10:42::/56", settings.Values["POD_CIDRS"])
+	assert.Equal(t, "10.43.0.0/16,fd00:10:43::/112", settings.Values["SERVICE_CIDRS"])
+	assert.Equal(t, "fd00:192:168:122::/64", settings.Values["NODE_SUBNET_V6"])
+	assert.Equal(t, "fd00:192:168:122::100", settings.Values["CONTROL_PLANE_VIP_V6"])
+	assert.Contains(t, settings.Environ(), "POD_CIDR_V6=fd00:10:42::/56")
+}
+
+func TestLintDualStackTemplates(t *testing.T) {
+	cluster := dualStackTestCluster()
+	cluster.DualStack = false
+	t.Cleanup(config.SetForTesting(&config.Config{Cluster: cluster}))
+	problems, err := LintDualStackTemplates()
+	require.NoError(t, err)
+	assert.Empty(t, problems, "single-stack is not linted")
+
+	t.Cleanup(config.SetForTesting(&config.Config{Cluster: dualStackTestCluster()}))
+	problems, err = LintDualStackTemplates()
+	require.NoError(t, err)
+	assert.Empty(t, problems, "the embedded templates carry both families")
+
+	// An override written before dual-stack lacks the IPv6 lines.
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "talos"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "talos", "worker.yaml"), []byte(`machine:
+  kubelet:
+    nodeIP:
+      validSubnets:
+        - {{ SETTINGS.NODE_SUBNET }}
+cluster:
+  network:
+    podSubnets:
+      - {{ SETTINGS.POD_CIDR }}
+{{ ENV.TALOS_POD_SUBNETS_V6 }}
+    serviceSubnets:
+      - {{ SETTINGS.SERVICE_CIDR }}
+`), 0o644))
+	t.Cleanup(config.SetForTesting(&config.Config{Cluster: dualStackTestCluster(), Templates: config.TemplatesConfig{Dir: dir}}))
+	problems, err = LintDualStackTemplates()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"talos/worker.yaml: cluster.network.serviceSubnets has no IPv6 entry (got [10.43.0.0/16])",
+		"talos/worker.yaml: machine.kubelet.nodeIP.validSubnets has no IPv6 entry (got [192.168.122.0/24])",
+	}, problems)
+}
+
+func TestMissingFamilies(t *testing.T) {
+	assert.Empty(t, missingFamilies([]string{"10.0.0.0/8", "fd00::/64"}))
+	assert.Equal(t, "IPv6", missingFamilies([]string{"10.0.0.0/8"}))
+	assert.Equal(t, "IPv4 or IPv6", missingFamilies([]string{"not-a-cidr"}))
+}
@@ -32,7 +32,7 @@ func NewTemplateRenderer(rootDir string, logger *common.ColorLogger, metrics *me
 func (r *TemplateRenderer) RenderTemplate(templateName, content string, env map[string]string, data map[string]interface{}) (string, error) {
 	// Determine template type based on content or filename
 	switch {
-	case strings.Contains(templateName, ".j2") || strings.Contains(content, "{%") || strings.Contains(content, "{{ ENV."):
+	case strings.Contains(templateName, ".j2") || strings.Contains(content, "{%") || strings.Contains(content, "{{ ENV.") || strings.Contains(content, "{{ SETTINGS."):
 		// Use Jinja2 renderer for Talos templates
 		if strings.HasPrefix(templateName, "talos/") {
 			return RenderTalosTemplate(strings.TrimPrefix(templateName, "talos/"), env)
@@ -74,7 +74,26 @@ func (r *TemplateRenderer) RenderTalosConfigWithMerge(baseTemplate, patchTemplat
 	if err != nil {
 		return nil, fmt.Errorf("failed to render patch template %s: %w", patchTemplate, err)
 	}
+	return r.mergeTalosPatch(baseConfig, patchConfig)
+}
+
+// RenderTalosConfigWithPatchContent renders and merges baseTemplate with a
+// node patch that is not embedded yet, rendered as if stored at patchTemplate.
+func (r *TemplateRenderer) RenderTalosConfigWithPatchContent(baseTemplate, patchTemplate, patchContent string, env map[string]string) ([]byte, error) {
+	baseConfig, err := RenderTalosTemplate(baseTemplate, env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render base template %s: %w", baseTemplate, err)
+	}
+	patchConfig, err := renderTalosContent(patchTemplate, patchContent, env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render patch template %s: %w", patchTemplate, err)
+	}
+	return r.mergeTalosPatch(baseConfig, patchConfig)
+}
 
+// mergeTalosPatch merges a rendered node patch's machine config document
+// into the rendered base and appends its other documents.
+func (r *TemplateRenderer) mergeTalosPatch(baseConfig, patchConfig string) ([]byte, error) {
 	// Trim leading document separator if present
 	patchConfigTrimmed := strings.TrimPrefix(patchConfig, "---\n")
 	patchConfigTrimmed = strings.TrimPrefix(patchConfigTrimmed, "---\r\n")
@@ -56,3 +56,37 @@ func TestTalosRenderCharacterization(t *testing.T) {
 		})
 	}
 }
+
+// TestTalosRenderDualStack pins the dual-stack output; the single-stack
+// goldens above must not change when cluster.dual_stack is off.
+func TestTalosRenderDualStack(t *testing.T) {
+	restore := config.SetForTesting(&config.Config{Cluster: config.ClusterConfig{
+		Name:        "home-ops-cluster",
+		PodCIDR:     "10.42.0.0/16",
+		ServiceCIDR: "10.43.0.0/16",
+		NodeSubnet:  "192.168.122.0/24",
+		DualStack:   true,
+		IPv6: config.ClusterIPv6Config{
+			PodCIDR:         "fd00:10:42::/56",
+			ServiceCIDR:     "fd00:10:43::/112",
+			NodeSubnet:      "fd00:192:168:122::/64",
+			ControlPlaneVIP: "fd00:192:168:122::100",
+		},
+		Nodes: []config.Node{
+			{Name: "k8s-0", IP: "192.168.122.10", IPv6: "fd00:192:168:122::10"},
+			{Name: "k8s-1", IP: "192.168.122.11"},
+		},
+	}})
+	defer restore()
+
+	renderer := NewTemplateRenderer(".", common.NewColorLogger(), metrics.NewPerformanceCollector())
+	env := map[string]string{"KUBERNETES_VERSION": "v1.36.1", "TALOS_VERSION": "v1.13.6"}
+	for _, base := range []string{"talos/controlplane.yaml", "talos/worker.yaml"} {
+		for _, node := range []string{"192.168.122.10", "192.168.122.11"} {
+			got, err := renderer.RenderTalosConfigWithMerge(base, "talos/nodes/"+node+".yaml", env)
+			require.NoError(t, err)
+			rel := filepath.Join("dual-stack", strings.TrimPrefix(base, "talos/")+"-"+node+".yaml")
+			talosGoldenCompare(t, rel, got)
+		}
+	}
+}
@@ -3,10 +3,14 @@ package templates
 import (
 	"embed"
 	"fmt"
+	"io/fs"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"homeops-cli/internal/common"
 	"homeops-cli/internal/config"
 	"homeops-cli/internal/metrics"
 	"homeops-cli/internal/secrets"
@@ -31,10 +35,18 @@ var flatcarTemplates embed.FS
 // readTemplateFile returns template content, preferring a user override from
 // the configured templates.dir (homeops.yaml) over the embedded copy. The
 // override file shadows the embedded one by relative path, e.g.
-// <templates.dir>/talos/controlplane.yaml.
+// <templates.dir>/talos/controlplane.yaml. A relative templates.dir is
+// resolved against the repository root, so it fails without one.
 func readTemplateFile(embedded embed.FS, path string) ([]byte, error) {
 	if dir := config.Get().Templates.Dir; dir != "" {
 		if expanded, err := secrets.ExpandHome(dir); err == nil {
+			if !filepath.IsAbs(expanded) {
+				root, err := common.RepoRoot()
+				if err != nil {
+					return nil, fmt.Errorf("resolve relative templates.dir %q: %w", dir, err)
+				}
+				expanded = filepath.Join(root, expanded)
+			}
 			cleanPath := filepath.Clean(path)
 			if filepath.IsAbs(cleanPath) || cleanPath == ".." || strings.HasPrefix(cleanPath, ".."+string(os.PathSeparator)) {
 				return nil, fmt.Errorf("template path escapes templates.dir: %s", path)
@@ -62,7 +74,7 @@ func RenderVolsyncTemplate(templateName string, env map[string]string) (string,
 		result = strings.ReplaceAll(result, placeholder, value)
 	}
 
-	return result, nil
+	return renderClusterSettings(result)
 }
 
 // GetVolsyncTemplate returns the raw template content
@@ -77,20 +89,36 @@ func GetVolsyncTemplate(templateName string) (string, error) {
 
 // RenderTalosTemplate renders a Jinja2-style Talos template with environment variables
 func RenderTalosTemplate(templateName string, env map[string]string) (string, error) {
-	env = enrichTalosEnv(templateName, env)
 	content, err := readTemplateFile(talosTemplates, templateName)
 	if err != nil {
 		return "", fmt.Errorf("failed to read template %s: %w", templateName, err)
 	}
+	return renderTalosContent(templateName, string(content), env)
+}
+
+// renderTalosContent renders Talos template content as if it were stored at
+// templateName, which selects the node whose values fill it.
+func renderTalosContent(templateName, content string, env map[string]string) (string, error) {
+	env = enrichTalosEnv(templateName, env)
 
 	// Simple Jinja2-style variable replacement (ORIGINAL IMPLEMENTATION)
-	result := string(content)
+	result := content
 	for key, value := range env {
 		placeholder := fmt.Sprintf("{{ ENV.%s }}", key)
 		result = strings.ReplaceAll(result, placeholder, value)
 	}
 
-	return result, nil
+	return renderClusterSettings(result)
+}
+
+// renderClusterSettings resolves {{ SETTINGS.KEY }} placeholders from
+// cluster-settings.yaml after the per-call ENV substitution.
+func renderClusterSettings(content string) (string, error) {
+	settings, err := LoadClusterSettings()
+	if err != nil {
+		return "", err
+	}
+	return substituteSettings(content, settings), nil
 }
 
 func enrichTalosEnv(templateName string, env map[string]string) map[string]string {
@@ -106,14 +134,25 @@ func enrichTalosEnv(templateName string, env map[string]string) map[string]strin
 
 func talosDefaultEnv(templateName string) map[string]string {
 	cfg := config.Get()
+	certSANs := cfg.Cluster.ExtraCertSANs
+	if cfg.Cluster.DualStack && cfg.Cluster.IPv6.ControlPlaneVIP != "" {
+		certSANs = append(slices.Clone(certSANs), cfg.Cluster.IPv6.ControlPlaneVIP)
+	}
+	// CLUSTER_NAME..NODE_SUBNET mirror {{ SETTINGS.* }} for templates.dir
+	// overrides written before cluster-settings.yaml existed.
 	out := map[string]string{
 		"CLUSTER_NAME":                    cfg.ClusterNameWithDefault(),
 		"POD_CIDR":                        cfg.Cluster.PodCIDR,
 		"SERVICE_CIDR":                    cfg.Cluster.ServiceCIDR,
 		"DNS_DOMAIN":                      cfg.Cluster.DNSDomain,
 		"NODE_SUBNET":                     cfg.Cluster.NodeSubnet,
-		"TALOS_EXTRA_CERT_SANS_MACHINE":   formatYAMLList(cfg.Cluster.ExtraCertSANs, 4, false),
-		"TALOS_EXTRA_CERT_SANS_APISERVER": formatYAMLList(cfg.Cluster.ExtraCertSANs, 6, false),
+		"TALOS_EXTRA_CERT_SANS_MACHINE":   formatYAMLList(certSANs, 4, false),
+		"TALOS_EXTRA_CERT_SANS_APISERVER": formatYAMLList(certSANs, 6, false),
+		"TALOS_POD_SUBNETS_V6":            formatYAMLList(dualStackOnly(cfg, cfg.Cluster.IPv6.PodCIDR), 6, false),
+		"TALOS_SERVICE_SUBNETS_V6":        formatYAMLList(dualStackOnly(cfg, cfg.Cluster.IPv6.ServiceCIDR), 6, false),
+		"TALOS_NODE_SUBNETS_V6":           formatYAMLList(dualStackOnly(cfg, cfg.Cluster.IPv6.NodeSubnet), 8, false),
+		"TALOS_ETCD_SUBNETS_V6":           formatYAMLList(dualStackOnly(cfg, cfg.Cluster.IPv6.NodeSubnet), 6, false),
+		"TALOS_NODE_ADDRESSES":            "",
 		"TALOS_NTP_SERVERS":               formatYAMLList(cfg.Cluster.NTPServers, 6, false),
 		"TALOS_DISCOVERY_ENDPOINT":        cfg.Cluster.Talos.DiscoveryEndpoint,
 		"TALOS_CONTROLPLANE_INSTALL_DISK": cfg.Cluster.Talos.ControlPlaneInstallDisk,
@@ -121,6 +160,7 @@ func talosDefaultEnv(templateName string) map[string]string {
 		"TALOS_USER_VOLUME_DISK":          cfg.Cluster.Talos.UserVolume.Disk,
 		"TALOS_USER_VOLUME_MIN_SIZE":      cfg.Cluster.Talos.UserVolume.MinSize,
 		"TALOS_USER_VOLUME_MAX_SIZE":      cfg.Cluster.Talos.UserVolume.MaxSize,
+		"TALOS_SYSTEM_DISK_ENCRYPTION":    talosDiskEncryptionStanza(cfg.Cluster.Talos.DiskEncryption),
 		"TALOS_KUBERNETES_VERSION":        config.GetVersions(".").TalosKubernetesVersion,
 		"TALOS_VERSION":                   config.GetVersions(".").TalosVersion,
 	}
@@ -128,10 +168,33 @@ func talosDefaultEnv(templateName string) map[string]string {
 		profile := node.VM.ForProvider("talos")
 		out["TALOS_NODE_MAC"] = profile.Mac
 		out["TALOS_NODE_HOSTNAME"] = node.Name
+		out["TALOS_NODE_ADDRESSES"] = talosNodeAddresses(cfg, node)
 	}
 	return out
 }
 
+// dualStackOnly lists an IPv6 value for the line that follows its IPv4
+// {{ SETTINGS.* }} entry; single-stack renders nothing there.
+func dualStackOnly(cfg *config.Config, value string) []string {
+	if !cfg.Cluster.DualStack || value == "" {
+		return nil
+	}
+	return []string{value}
+}
+
+// talosNodeAddresses renders the static IPv6 interface address for a
+// dual-stack node. IPv4 stays on DHCP reservations.
+func talosNodeAddresses(cfg *config.Config, node config.Node) string {
+	if !cfg.Cluster.DualStack || node.IPv6 == "" {
+		return ""
+	}
+	bits := 64
+	if prefix, err := netip.ParsePrefix(cfg.Cluster.IPv6.NodeSubnet); err == nil {
+		bits = prefix.Bits()
+	}
+	return fmt.Sprintf("        addresses:\n          - %s/%d", node.IPv6, bits)
+}
+
 func talosNodeForTemplate(templateName string, cfg *config.Config) (config.Node, bool) {
 	base := filepath.Base(templateName)
 	ip := strings.TrimSuffix(base, filepath.Ext(base))
@@ -150,6 +213,22 @@ func formatYAMLList(values []string, indent int, quote bool) string {
 	return strings.Join(lines, "\n")
 }
 
+// TalosNodeTemplateIPs lists the node IPs that have an embedded
+// talos/nodes/<ip>.yaml template.
+func TalosNodeTemplateIPs() []string {
+	entries, err := talosTemplates.ReadDir("talos/nodes")
+	if err != nil {
+		return nil
+	}
+	ips := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if name := entry.Name(); strings.HasSuffix(name, ".yaml") {
+			ips = append(ips, strings.TrimSuffix(name, ".yaml"))
+		}
+	}
+	return ips
+}
+
 // GetTalosTemplate returns the raw Talos template content
 func GetTalosTemplate(templateName string) (string, error) {
 	content, err := readTemplateFile(talosTemplates, templateName)
@@ -177,7 +256,7 @@ func RenderFlatcarTemplate(templateName string, env map[string]string) (string,
 		result = strings.ReplaceAll(result, placeholder, value)
 	}
 
-	return result, nil
+	return renderClusterSettings(result)
 }
 
 // GetFlatcarTemplate returns the raw Flatcar template content (no substitution).
@@ -232,7 +311,7 @@ func RenderBootstrapTemplate(templateName string, env map[string]string) (string
 		result = strings.ReplaceAll(result, placeholder, value)
 	}
 
-	return result, nil
+	return renderClusterSettings(result)
 }
 
 func enrichBootstrapEnv(env map[string]string) map[string]string {
@@ -285,6 +364,28 @@ func GetBrewfile() (string, error) {
 	return string(content), nil
 }
 
+// WalkEmbedded calls fn for every template compiled into the binary, in path
+// order, with paths as they shadow under templates.dir (e.g.
+// "talos/controlplane.yaml"). templates.dir overrides are not consulted.
+func WalkEmbedded(fn func(path string, data []byte) error) error {
+	for _, embedded := range []embed.FS{bootstrapTemplates, brewTemplates, clusterSettingsTemplates, flatcarTemplates, talosTemplates, volsyncTemplates} {
+		err := fs.WalkDir(embedded, ".", func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			data, err := embedded.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read embedded template %s: %w", path, err)
+			}
+			return fn(path, data)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // expandNamespaceLoop expands the Jinja2 for loop for namespaces
 func expandNamespaceLoop(content string) string {
 	// Find the for loop pattern
@@ -354,8 +455,13 @@ func RenderHelmfileValues(release, rootDir string, collector *metrics.Performanc
 	// Create Go template renderer
 	renderer := template.NewGoTemplateRenderer(rootDir, collector)
 
-	// Render values for the specific release
-	return renderer.RenderHelmfileValues(valuesTemplate, release)
+	settings, err := LoadClusterSettings()
+	if err != nil {
+		return "", err
+	}
+
+	// Render values for the specific release, with cluster settings as .Settings
+	return renderer.RenderHelmfileValues(valuesTemplate, release, settings.Values)
 }
 
 // validateTemplateSubstitution verifies that Jinja2 template substitution worked correctly
@@ -374,6 +480,9 @@ func ValidateTemplateSubstitution(templateName, originalTemplate, renderedConten
 	if strings.Contains(renderedContent, "{{ ENV.") {
 		return fmt.Errorf("template '%s' contains unresolved environment variables", templateName)
 	}
+	if strings.Contains(renderedContent, "{{ SETTINGS.") {
+		return fmt.Errorf("template '%s' contains unresolved cluster settings", templateName)
+	}
 
 	// For resources.yaml.j2, verify namespace expansion worked
 	if templateName == "resources.yaml.j2" {
@@ -54,6 +54,19 @@ func TestEmbeddedTemplateHelpers(t *testing.T) {
 	assert.Contains(t, brewfile, "brew")
 }
 
+func TestWalkEmbeddedVisitsEveryTreeInPathOrder(t *testing.T) {
+	var paths []string
+	require.NoError(t, WalkEmbedded(func(path string, data []byte) error {
+		assert.NotEmpty(t, data, path)
+		paths = append(paths, path)
+		return nil
+	}))
+	assert.IsIncreasing(t, paths)
+	for _, want := range []string{"bootstrap/resources.yaml", "brew/Brewfile", "cluster-settings.yaml", "talos/controlplane.yaml", "volsync/replicationdestination.yaml.j2"} {
+		assert.Contains(t, paths, want)
+	}
+}
+
 func TestTemplateSubstitutionHelpers(t *testing.T) {
 	loopTemplate := `{% for namespace in ["external-secrets", "flux-system", "network"] %}name: {{ namespace }}
 {% endfor %}`
@@ -221,3 +234,24 @@ func TestBootstrapTemplateHelpers(t *testing.T) {
 	require.NoError(t, err)
 	assert.Contains(t, rawTalos, "cluster:")
 }
+
+func TestRelativeTemplatesDirResolvesAgainstRepoRoot(t *testing.T) {
+	t.Setenv("HOMEOPS_ROOT", "")
+	repo := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "homeops.yaml"), nil, 0o600))
+	require.NoError(t, os.MkdirAll(filepath.Join(repo, "overrides", "volsync"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "overrides", "volsync", "custom.j2"), []byte("app: {{ ENV.APP }}\n"), 0o600))
+	nested := filepath.Join(repo, "kubernetes", "apps")
+	require.NoError(t, os.MkdirAll(nested, 0o755))
+	t.Cleanup(config.SetForTesting(&config.Config{Templates: config.TemplatesConfig{Dir: "overrides"}}))
+
+	t.Chdir(nested)
+	rendered, err := RenderVolsyncTemplate("custom.j2", map[string]string{"APP": "demo"})
+	require.NoError(t, err)
+	assert.Equal(t, "app: demo\n", rendered, "found from a subdirectory")
+
+	t.Chdir(t.TempDir())
+	_, err = RenderVolsyncTemplate("custom.j2", nil)
+	require.ErrorIs(t, err, common.ErrRepoRootNotFound, "no silent fallback to the embedded copy")
+	assert.Contains(t, err.Error(), `relative templates.dir "overrides"`)
+}
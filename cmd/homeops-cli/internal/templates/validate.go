@@ -0,0 +1,199 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/metrics"
+)
+
+// Checks reported by ValidateAll.
+const (
+	CheckYAML           = "yaml"
+	CheckOpReference    = "op-reference"
+	CheckNodeMerge      = "node-merge"
+	CheckHelmfileValues = "helmfile-values"
+)
+
+// TemplateProblem is one embedded template that failed a ValidateAll check.
+type TemplateProblem struct {
+	Template string `json:"template"`
+	Check    string `json:"check"`
+	Message  string `json:"message"`
+}
+
+func (p TemplateProblem) String() string {
+	return fmt.Sprintf("%s [%s]: %s", p.Template, p.Check, p.Message)
+}
+
+var (
+	// placeholderLine is a line that is only a placeholder or a Jinja tag;
+	// it expands to whole lines (or none), so the YAML check drops it.
+	placeholderLine = regexp.MustCompile(`(?m)^[ \t]*(\{\{[^}]*\}\}|\{%[^%]*%\})[ \t]*$`)
+	placeholderRef  = regexp.MustCompile(`\{\{[^}]*\}\}`)
+	inlineOpRef     = regexp.MustCompile(`op://[^\s"'}]+`)
+	validOpRef      = regexp.MustCompile(`^op://[^/]+/[^/]+/(?:[^/]+/)?[^/]+$`)
+)
+
+// ValidateAll checks every embedded template: YAML files parse with their
+// placeholders stubbed out, op:// references are op://vault/item/field or
+// op://vault/item/section/field, every talos/nodes patch merges against its
+// base as apply-node merges it, and the helmfile values template renders for
+// every release that uses it. rootDir is the repository holding
+// kubernetes/apps; "" skips the helmfile values check. The error is for
+// templates that cannot be read, not for problems found.
+func ValidateAll(rootDir string) ([]TemplateProblem, error) {
+	var problems []TemplateProblem
+	err := WalkEmbedded(func(name string, data []byte) error {
+		problems = append(problems, validateTemplateContent(name, string(data))...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	nodeProblems, err := validateNodeMerges()
+	if err != nil {
+		return nil, err
+	}
+	problems = append(problems, nodeProblems...)
+
+	if rootDir != "" {
+		valueProblems, err := validateHelmfileValues(rootDir)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, valueProblems...)
+	}
+	return problems, nil
+}
+
+// validateTemplateContent runs the checks that need only the file itself.
+func validateTemplateContent(name, content string) []TemplateProblem {
+	var problems []TemplateProblem
+	stubbed := stubPlaceholders(content)
+	if isYAMLTemplate(name) {
+		if err := parseYAMLDocuments(stubbed); err != nil {
+			problems = append(problems, TemplateProblem{Template: name, Check: CheckYAML, Message: err.Error()})
+		}
+	}
+	for _, ref := range inlineOpRef.FindAllString(stubbed, -1) {
+		if !validOpRef.MatchString(ref) {
+			problems = append(problems, TemplateProblem{Template: name, Check: CheckOpReference, Message: fmt.Sprintf("%s is not op://vault/item/field or op://vault/item/section/field", ref)})
+		}
+	}
+	return problems
+}
+
+func isYAMLTemplate(name string) bool {
+	switch path.Ext(strings.TrimSuffix(name, ".j2")) {
+	case ".yaml", ".yml", ".bu":
+		return !strings.HasSuffix(name, ".gotmpl")
+	}
+	return false
+}
+
+// stubPlaceholders drops whole-line placeholders and Jinja tags and turns
+// inline placeholders into a plain scalar, so what remains is the YAML the
+// template author wrote.
+func stubPlaceholders(content string) string {
+	content = placeholderLine.ReplaceAllString(content, "")
+	return placeholderRef.ReplaceAllString(content, "placeholder")
+}
+
+func parseYAMLDocuments(content string) error {
+	decoder := yaml.NewDecoder(strings.NewReader(content))
+	for {
+		var doc any
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// validateNodeMerges renders every embedded node patch merged with its
+// machine type's base through RenderTalosConfigWithMerge, as apply-node does.
+func validateNodeMerges() ([]TemplateProblem, error) {
+	renderer := NewTemplateRenderer(common.GetWorkingDirectory(), common.NewColorLogger(), metrics.NewPerformanceCollector())
+	var problems []TemplateProblem
+	for _, ip := range TalosNodeTemplateIPs() {
+		name := fmt.Sprintf("talos/nodes/%s.yaml", ip)
+		content, err := GetTalosTemplate(name)
+		if err != nil {
+			return nil, err
+		}
+		base := "talos/controlplane.yaml"
+		if strings.Contains(content, "type: worker") {
+			base = "talos/worker.yaml"
+		}
+		merged, err := renderer.RenderTalosConfigWithMerge(base, name, nil)
+		if err == nil {
+			err = parseYAMLDocuments(string(merged))
+		}
+		if err != nil {
+			problems = append(problems, TemplateProblem{Template: name, Check: CheckNodeMerge, Message: fmt.Sprintf("does not merge against %s: %v", base, err)})
+		}
+	}
+	return problems, nil
+}
+
+// validateHelmfileValues renders the shared values template for every
+// helmfile release that lists it.
+func validateHelmfileValues(rootDir string) ([]TemplateProblem, error) {
+	if _, err := os.Stat(filepath.Join(rootDir, "kubernetes", "apps")); err != nil {
+		return nil, fmt.Errorf("helmfile values need the repository's kubernetes/apps: %w", err)
+	}
+	collector := metrics.NewPerformanceCollector()
+	var problems []TemplateProblem
+	for _, helmfile := range []string{"helmfile.d/00-crds.yaml", "helmfile.d/01-apps.yaml"} {
+		content, err := GetBootstrapFile(helmfile)
+		if err != nil {
+			return nil, err
+		}
+		var parsed struct {
+			Releases []struct {
+				Name   string `yaml:"name"`
+				Values []any  `yaml:"values"`
+			} `yaml:"releases"`
+		}
+		if err := yaml.Unmarshal([]byte(content), &parsed); err != nil {
+			// The YAML check reports the syntax error.
+			continue
+		}
+		for _, release := range parsed.Releases {
+			if !usesValuesTemplate(release.Values) {
+				continue
+			}
+			rendered, err := RenderHelmfileValues(release.Name, rootDir, collector)
+			if err == nil {
+				err = parseYAMLDocuments(rendered)
+			}
+			if err != nil {
+				problems = append(problems, TemplateProblem{Template: "bootstrap/helmfile.d/templates/values.yaml.gotmpl", Check: CheckHelmfileValues, Message: fmt.Sprintf("release %s: %v", release.Name, err)})
+			}
+		}
+	}
+	return problems, nil
+}
+
+func usesValuesTemplate(values []any) bool {
+	for _, value := range values {
+		if s, ok := value.(string); ok && strings.HasSuffix(s, "templates/values.yaml.gotmpl") {
+			return true
+		}
+	}
+	return false
+}
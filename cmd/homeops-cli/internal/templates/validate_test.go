@@ -0,0 +1,37 @@
+package templates
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidateAllEmbeddedTemplates keeps every embedded template valid: a
+// stray tab in talos/controlplane.yaml or a malformed op:// reference fails
+// here before it reaches a cluster.
+func TestValidateAllEmbeddedTemplates(t *testing.T) {
+	problems, err := ValidateAll("../../../..")
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+}
+
+func TestValidateTemplateContentCatchesTabs(t *testing.T) {
+	content := "machine:\n\ttype: controlplane\n"
+	problems := validateTemplateContent("talos/controlplane.yaml", content)
+	require.Len(t, problems, 1)
+	assert.Equal(t, CheckYAML, problems[0].Check)
+	assert.Contains(t, problems[0].Message, "found character that cannot start any token")
+}
+
+func TestValidateTemplateContentStubsPlaceholders(t *testing.T) {
+	content := "---\nmachine:\n  install:\n    image: factory.talos.dev/installer/{{ ENV.SCHEMATIC_ID }}:{{ ENV.TALOS_VERSION }}\n{{ ENV.TALOS_NODE_ADDRESSES }}\n{% for ns in namespaces %}\n---\nkind: Namespace\n{% endfor %}\n"
+	assert.Empty(t, validateTemplateContent("talos/worker.yaml", content))
+}
+
+func TestValidateTemplateContentChecksOpReferences(t *testing.T) {
+	content := "a: op://kubernetes/talos/MACHINE_TOKEN\nb: op://kubernetes/talos/machine/ca\nc: op://kubernetes/talos\n"
+	problems := validateTemplateContent("talos/controlplane.yaml", content)
+	require.Len(t, problems, 1)
+	assert.Equal(t, TemplateProblem{Template: "talos/controlplane.yaml", Check: CheckOpReference, Message: "op://kubernetes/talos is not op://vault/item/field or op://vault/item/section/field"}, problems[0])
+}
@@ -1,6 +1,8 @@
 package truenas
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -25,10 +27,20 @@ type VM struct {
 	Description string                 `json:"description"`
 	Memory      int                    `json:"memory"`
 	VCPUs       int                    `json:"vcpus"`
+	Cores       int                    `json:"cores"`
+	Threads     int                    `json:"threads"`
+	CPUSet      string                 `json:"cpuset"`
+	NodeSet     string                 `json:"nodeset"`
+	PinVCPUs    bool                   `json:"pin_vcpus"`
+	CPUMode     string                 `json:"cpu_mode"`
+	CPUModel    string                 `json:"cpu_model"`
 	Bootloader  string                 `json:"bootloader"`
 	Autostart   bool                   `json:"autostart"`
 	Status      map[string]interface{} `json:"status"`
 	Devices     []VMDevice             `json:"devices"`
+	// CommandLineArgs is the extra qemu argument string (Flatcar Ignition
+	// fw_cfg, serial console log port).
+	CommandLineArgs string `json:"command_line_args"`
 }
 
 // VMCreateRequest represents a VM creation request
@@ -63,6 +75,10 @@ type DatasetCreateRequest struct {
 	Type         string `json:"type"`
 	Volsize      *int64 `json:"volsize,omitempty"`
 	Volblocksize string `json:"volblocksize,omitempty"`
+	// Sparse skips the volume's refreservation (thin provisioning); ZVols
+	// only.
+	Sparse      bool   `json:"sparse,omitempty"`
+	Compression string `json:"compression,omitempty"`
 }
 
 // WorkingClient wraps the official TrueNAS API client with the correct authentication
@@ -72,17 +88,34 @@ type WorkingClient struct {
 	host   string
 	port   int
 	useSSL bool
+	// tls verifies a wss connection; see TLSOptions.
+	tls    TLSOptions
 	callFn func(method string, params interface{}, timeoutSeconds int64) (json.RawMessage, error)
+	// ctx bounds every call made without an explicit context (nil means
+	// context.Background()); see SetContext.
+	ctx context.Context
+	// jobProgress receives updates of jobs the client waits for; see
+	// SetJobProgress.
+	jobProgress JobProgressFunc
+	// dryRun records mutating calls instead of sending them; see
+	// SetDryRun.
+	dryRun *DryRunPlan
 }
 
-// NewWorkingClient creates a new working TrueNAS client using the official API client
-func NewWorkingClient(host, apiKey string, port int, useSSL bool) *WorkingClient {
-	return &WorkingClient{
+// NewWorkingClient creates a new working TrueNAS client using the official API client.
+// A wss connection is verified as DefaultTLSOptions says unless opts change it.
+func NewWorkingClient(host, apiKey string, port int, useSSL bool, opts ...ClientOption) *WorkingClient {
+	c := &WorkingClient{
 		host:   host,
 		apiKey: apiKey,
 		port:   port,
 		useSSL: useSSL,
+		tls:    DefaultTLSOptions(),
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // Connect establishes connection and authenticates with TrueNAS
@@ -92,6 +125,13 @@ func (c *WorkingClient) Connect() error {
 	if !c.useSSL {
 		protocol = "ws"
 	}
+	var tlsConfig *tls.Config
+	if c.useSSL {
+		var err error
+		if tlsConfig, err = c.tls.config(); err != nil {
+			return err
+		}
+	}
 
 	serverURL := fmt.Sprintf("%s://%s:%d/api/current", protocol, c.host, c.port)
 	common.NewColorLogger().Debug("Connecting to TrueNAS at %s", serverURL)
@@ -104,10 +144,11 @@ func (c *WorkingClient) Connect() error {
 		MaxDelay:  8 * time.Second,
 		Sleep:     connectRetrySleep,
 	}, func() error {
-		// The second parameter is verifySSL (false sets InsecureSkipVerify in
-		// the library) — a previous inversion here disabled TLS verification
-		// on every wss connection. Verify whenever we dial over SSL.
-		client, err := truenas_api.NewClient(serverURL, c.useSSL)
+		// The library's verifySSL=false sets InsecureSkipVerify on the shared
+		// dialer for good — a previous inversion here disabled verification
+		// on every wss connection — so the dial always goes through
+		// dialAPIClient with the client's own TLS config.
+		client, err := dialAPIClient(serverURL, tlsConfig)
 		if err != nil {
 			return fmt.Errorf("failed to create TrueNAS client: %w", err)
 		}
@@ -131,16 +172,94 @@ func (c *WorkingClient) Close() error {
 	return nil
 }
 
-// Call makes a raw API call to TrueNAS
+// Ping checks the connection with core.ping. It is bounded by its own
+// timeout, not the client's context, so it keeps an idle connection alive
+// between commands.
+func (c *WorkingClient) Ping() error {
+	var pong string
+	if err := c.callResultContext(context.Background(), "core.ping", []interface{}{}, 10, &pong); err != nil {
+		return fmt.Errorf("TrueNAS ping failed: %w", err)
+	}
+	return nil
+}
+
+// SetContext binds ctx to every call that does not take its own context, so
+// cancelling it (Ctrl+C in the CLI) aborts whichever call is in flight.
+func (c *WorkingClient) SetContext(ctx context.Context) {
+	c.ctx = ctx
+}
+
+func (c *WorkingClient) baseContext() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// Call makes a raw API call to TrueNAS, bounded by the client's context.
 func (c *WorkingClient) Call(method string, params interface{}, timeoutSeconds int64) (json.RawMessage, error) {
-	if c.callFn != nil {
-		return c.callFn(method, params, timeoutSeconds)
+	return c.CallContext(c.baseContext(), method, params, timeoutSeconds)
+}
+
+// CallContext makes a raw API call that returns ctx.Err() as soon as ctx is
+// done. The library call cannot be interrupted, so cancelling closes the
+// websocket; the client must be reconnected before it is used again. A
+// response carrying an error object is returned as a *TrueNASError.
+func (c *WorkingClient) CallContext(ctx context.Context, method string, params interface{}, timeoutSeconds int64) (json.RawMessage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if c.dryRun != nil && !isReadOnlyMethod(method) {
+		return c.dryRun.record(method, params), nil
+	}
+	type result struct {
+		raw json.RawMessage
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var r result
+		if c.callFn != nil {
+			r.raw, r.err = c.callFn(method, params, timeoutSeconds)
+		} else {
+			r.raw, r.err = c.client.Call(method, timeoutSeconds, params)
+		}
+		done <- r
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return nil, r.err
+		}
+		if err := responseError(method, r.raw); err != nil {
+			return nil, err
+		}
+		return r.raw, nil
+	case <-ctx.Done():
+		if c.client != nil {
+			_ = c.client.Close()
+		}
+		return nil, ctx.Err()
 	}
-	return c.client.Call(method, timeoutSeconds, params)
+}
+
+// SystemVersion returns the release string reported by system.version
+// (e.g. "TrueNAS-SCALE-24.10.2").
+func (c *WorkingClient) SystemVersion() (string, error) {
+	var version string
+	if err := c.callResult("system.version", []interface{}{}, 30, &version); err != nil {
+		return "", fmt.Errorf("failed to query TrueNAS version: %w", err)
+	}
+	return version, nil
 }
 
 // QueryVMs retrieves all VMs from TrueNAS
 func (c *WorkingClient) QueryVMs(filters interface{}) ([]VM, error) {
+	return c.QueryVMsContext(c.baseContext(), filters)
+}
+
+// QueryVMsContext is QueryVMs bounded by ctx.
+func (c *WorkingClient) QueryVMsContext(ctx context.Context, filters interface{}) ([]VM, error) {
 	// Ensure filters is an array for JSON-RPC compatibility
 	var params []interface{}
 	if filters != nil {
@@ -150,14 +269,17 @@ func (c *WorkingClient) QueryVMs(filters interface{}) ([]VM, error) {
 	}
 
 	var vms []VM
-	if err := c.callResult("vm.query", params, 30, &vms); err != nil {
+	if err := c.callResultContext(ctx, "vm.query", params, 30, &vms); err != nil {
 		return nil, fmt.Errorf("failed to query VMs: %w", err)
 	}
 
 	// Query devices for each VM
 	for i := range vms {
-		devices, err := c.QueryVMDevices(vms[i].ID)
+		devices, err := c.queryVMDevices(ctx, vms[i].ID)
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			// Log error but don't fail the entire query
 			common.NewColorLogger().Warn("failed to query devices for VM %s: %v", vms[i].Name, err)
 			continue
@@ -176,8 +298,13 @@ func (c *WorkingClient) QueryVMs(filters interface{}) ([]VM, error) {
 
 // CreateVM creates a new VM
 func (c *WorkingClient) CreateVM(vmConfig map[string]interface{}) (*VM, error) {
+	return c.CreateVMContext(c.baseContext(), vmConfig)
+}
+
+// CreateVMContext is CreateVM bounded by ctx.
+func (c *WorkingClient) CreateVMContext(ctx context.Context, vmConfig map[string]interface{}) (*VM, error) {
 	var vm VM
-	if err := c.callResult("vm.create", []interface{}{vmConfig}, 120, &vm); err != nil {
+	if err := c.callJobResultContext(ctx, "vm.create", []interface{}{vmConfig}, 120, &vm); err != nil {
 		return nil, fmt.Errorf("failed to create VM: %w", err)
 	}
 
@@ -186,13 +313,26 @@ func (c *WorkingClient) CreateVM(vmConfig map[string]interface{}) (*VM, error) {
 
 // StartVM starts a VM
 func (c *WorkingClient) StartVM(vmID int) error {
-	_, err := c.Call("vm.start", []interface{}{vmID}, 60)
+	return c.StartVMContext(c.baseContext(), vmID)
+}
+
+// StartVMContext is StartVM bounded by ctx.
+func (c *WorkingClient) StartVMContext(ctx context.Context, vmID int) error {
+	_, err := c.CallContext(ctx, "vm.start", []interface{}{vmID}, 60)
 	return err
 }
 
-// StopVM stops a VM
+// StopVM asks a VM's guest to shut down and waits for the stop job.
 func (c *WorkingClient) StopVM(vmID int) error {
-	_, err := c.Call("vm.stop", []interface{}{vmID}, 60)
+	return c.StopVMContext(c.baseContext(), vmID)
+}
+
+// StopVMContext is StopVM bounded by ctx.
+func (c *WorkingClient) StopVMContext(ctx context.Context, vmID int) error {
+	// vm.stop {force: false} sends the guest an ACPI shutdown; its job ends
+	// when the guest is off or the VM's shutdown_timeout passes without
+	// forcing it, so the guest may still be running on return.
+	_, err := c.CallJobContext(ctx, "vm.stop", []interface{}{vmID, map[string]interface{}{"force": false, "force_after_timeout": false}}, 60)
 	return err
 }
 
@@ -204,17 +344,26 @@ func (c *WorkingClient) PowerOffVM(vmID int) error {
 
 // DeleteVM deletes a VM
 func (c *WorkingClient) DeleteVM(vmID int) error {
-	_, err := c.Call("vm.delete", []interface{}{vmID}, 60)
+	return c.DeleteVMContext(c.baseContext(), vmID)
+}
+
+// DeleteVMContext is DeleteVM bounded by ctx.
+func (c *WorkingClient) DeleteVMContext(ctx context.Context, vmID int) error {
+	_, err := c.CallContext(ctx, "vm.delete", []interface{}{vmID}, 60)
 	return err
 }
 
 func (c *WorkingClient) QueryVMDevices(vmID int) ([]map[string]interface{}, error) {
+	return c.queryVMDevices(c.baseContext(), vmID)
+}
+
+func (c *WorkingClient) queryVMDevices(ctx context.Context, vmID int) ([]map[string]interface{}, error) {
 	params := []interface{}{[]interface{}{[]interface{}{"vm", "=", vmID}}}
 
 	common.NewColorLogger().Debug("Querying VM devices for VM ID %d with params: %+v", vmID, params)
 
 	var devices []map[string]interface{}
-	if err := c.callResult("vm.device.query", params, 30, &devices); err != nil {
+	if err := c.callResultContext(ctx, "vm.device.query", params, 30, &devices); err != nil {
 		return nil, fmt.Errorf("failed to query VM devices: %w", err)
 	}
 
@@ -224,6 +373,11 @@ func (c *WorkingClient) QueryVMDevices(vmID int) ([]map[string]interface{}, erro
 
 // QueryDatasets retrieves datasets from TrueNAS
 func (c *WorkingClient) QueryDatasets(filters interface{}) ([]Dataset, error) {
+	return c.QueryDatasetsContext(c.baseContext(), filters)
+}
+
+// QueryDatasetsContext is QueryDatasets bounded by ctx.
+func (c *WorkingClient) QueryDatasetsContext(ctx context.Context, filters interface{}) ([]Dataset, error) {
 	// Ensure filters is an array for JSON-RPC compatibility
 	var params []interface{}
 	if filters != nil {
@@ -233,7 +387,7 @@ func (c *WorkingClient) QueryDatasets(filters interface{}) ([]Dataset, error) {
 	}
 
 	var datasets []Dataset
-	if err := c.callResult("pool.dataset.query", params, 30, &datasets); err != nil {
+	if err := c.callResultContext(ctx, "pool.dataset.query", params, 30, &datasets); err != nil {
 		return nil, fmt.Errorf("failed to query datasets: %w", err)
 	}
 
@@ -242,7 +396,12 @@ func (c *WorkingClient) QueryDatasets(filters interface{}) ([]Dataset, error) {
 
 // CreateDataset creates a new dataset
 func (c *WorkingClient) CreateDataset(datasetConfig DatasetCreateRequest) (*Dataset, error) {
-	result, err := c.Call("pool.dataset.create", []interface{}{datasetConfig}, 60)
+	return c.CreateDatasetContext(c.baseContext(), datasetConfig)
+}
+
+// CreateDatasetContext is CreateDataset bounded by ctx.
+func (c *WorkingClient) CreateDatasetContext(ctx context.Context, datasetConfig DatasetCreateRequest) (*Dataset, error) {
+	result, err := c.CallContext(ctx, "pool.dataset.create", []interface{}{datasetConfig}, 60)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create dataset: %w", err)
 	}
@@ -268,7 +427,7 @@ func (c *WorkingClient) DeleteDataset(name string, recursive bool) error {
 
 	common.NewColorLogger().Debug("Attempting to delete dataset: %s with params: %+v", name, params)
 
-	result, err := c.Call("pool.dataset.delete", []interface{}{name, params}, 120) // Increase timeout for large datasets
+	result, err := c.CallJob("pool.dataset.delete", []interface{}{name, params}, 120) // Increase timeout for large datasets
 	if err != nil {
 		common.NewColorLogger().Warn("failed to delete dataset %s: %v", name, err)
 		return fmt.Errorf("failed to delete dataset %s: %w", name, err)
@@ -1,9 +1,11 @@
 package truenas
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -138,3 +140,82 @@ func mustJSON(v interface{}) json.RawMessage {
 	}
 	return data
 }
+
+// datasetCreateArgs is the first pool.dataset.create argument as the
+// middleware receives it, whether it was passed as a map or a
+// DatasetCreateRequest.
+func datasetCreateArgs(params interface{}) map[string]interface{} {
+	var args []map[string]interface{}
+	if err := json.Unmarshal(mustJSON(params), &args); err != nil {
+		panic(err)
+	}
+	return args[0]
+}
+
+func TestWorkingClientCallContextCancelsInFlightCall(t *testing.T) {
+	client := NewWorkingClient("nas", "key", 443, true)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	client.callFn = func(method string, params interface{}, timeoutSeconds int64) (json.RawMessage, error) {
+		close(started)
+		<-release // a vm.create that would otherwise run to its 120s timeout
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	begin := time.Now()
+	_, err := client.CreateVMContext(ctx, map[string]interface{}{"name": "vm2"})
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(begin), time.Second)
+
+	calls := 0
+	client.callFn = func(string, interface{}, int64) (json.RawMessage, error) {
+		calls++
+		return nil, nil
+	}
+	_, err = client.CallContext(ctx, "vm.query", []interface{}{}, 30)
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Zero(t, calls, "a call on a cancelled context is never sent")
+}
+
+func TestVMManagerSetContextBoundsEveryCall(t *testing.T) {
+	manager := NewVMManager("nas", "key", 443, true)
+	manager.client.callFn = func(string, interface{}, int64) (json.RawMessage, error) {
+		return mustJSON(map[string]interface{}{"result": []interface{}{}}), nil
+	}
+	_, err := manager.client.QueryVMs(nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	manager.SetContext(ctx)
+	_, err = manager.client.QueryVMs(nil)
+	require.ErrorIs(t, err, context.Canceled)
+	_, err = manager.client.QueryDatasets(nil)
+	require.ErrorIs(t, err, context.Canceled)
+	require.ErrorIs(t, manager.client.StartVM(1), context.Canceled)
+}
+
+func TestPingOutlivesTheClientContext(t *testing.T) {
+	manager := NewVMManager("nas", "key", 443, true)
+	manager.client.callFn = func(method string, _ interface{}, _ int64) (json.RawMessage, error) {
+		if method != "core.ping" {
+			return nil, fmt.Errorf("unexpected method %s", method)
+		}
+		return mustJSON(map[string]interface{}{"result": "pong"}), nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	manager.SetContext(ctx)
+	require.NoError(t, manager.Ping(), "the keepalive of a shared connection is not bound to the last command")
+
+	manager.client.callFn = func(string, interface{}, int64) (json.RawMessage, error) {
+		return nil, fmt.Errorf("connection closed")
+	}
+	require.EqualError(t, manager.Ping(), "TrueNAS ping failed: connection closed")
+}
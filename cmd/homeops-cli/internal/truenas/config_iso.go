@@ -0,0 +1,64 @@
+package truenas
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Config injection spares a fresh Talos VM the maintenance-mode apply step.
+// The machine config is written to a small ISO9660 volume labelled
+// metal-iso (see cloudinit.BuildTalosConfigISO) that sits next to the boot
+// ISO on the NAS and is attached as a second CD-ROM. A Talos image whose
+// schematic carries talos.config=metal-iso reads /config.yaml from it on
+// first boot; without that kernel arg the volume is ignored and the node
+// waits in maintenance mode as before.
+
+// ConfigISOSuffix ends every generated config ISO's filename. It is how the
+// delete flow tells the VM's config volume apart from its boot ISO.
+const ConfigISOSuffix = "-talos-config.iso"
+
+// TalosConfigKernelArg points Talos' metal platform at the config volume.
+const TalosConfigKernelArg = "talos.config=metal-iso"
+
+// configISODeviceOrder places the config CD-ROM right after the boot CD-ROM
+// (1006) so the VM still boots the installer.
+const configISODeviceOrder = 1007
+
+// ConfigISOPath is where the config ISO for VM name is uploaded: alongside
+// the boot ISO in isoDir.
+func ConfigISOPath(isoDir, name string) string {
+	return path.Join(isoDir, name+ConfigISOSuffix)
+}
+
+// ConfigISOPathFromDevices returns the path of the attached config ISO, or
+// "" when the VM has none.
+func ConfigISOPathFromDevices(devices []map[string]interface{}) string {
+	for _, device := range devices {
+		attributes, ok := device["attributes"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if dtype, _ := attributes["dtype"].(string); dtype != "CDROM" {
+			continue
+		}
+		if isoPath, _ := attributes["path"].(string); strings.HasSuffix(isoPath, ConfigISOSuffix) {
+			return isoPath
+		}
+	}
+	return ""
+}
+
+// ConfigISOPath returns the config ISO attached to the named VM at deploy
+// time, or "" when it was deployed without one.
+func (vm *VMManager) ConfigISOPath(name string) (string, error) {
+	vmItem, err := vm.getVMByName(name)
+	if err != nil {
+		return "", err
+	}
+	devices, err := vm.client.QueryVMDevices(vmItem.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to query devices of VM %s: %w", name, err)
+	}
+	return ConfigISOPathFromDevices(devices), nil
+}
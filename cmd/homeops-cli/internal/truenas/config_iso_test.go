@@ -0,0 +1,72 @@
+package truenas
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateVMDevicesAttachesConfigISO(t *testing.T) {
+	manager := NewVMManager("nas", "key", 443, true)
+	var createdDevices []map[string]interface{}
+	manager.client.callFn = func(method string, params interface{}, _ int64) (json.RawMessage, error) {
+		if method == "vm.device.create" {
+			createdDevices = append(createdDevices, params.([]interface{})[0].(map[string]interface{}))
+		}
+		return mustJSON(map[string]any{"result": true}), nil
+	}
+
+	configISO := ConfigISOPath("/mnt/flashstor/ISO", "k8s_0")
+	assert.Equal(t, "/mnt/flashstor/ISO/k8s_0-talos-config.iso", configISO)
+	require.NoError(t, manager.createVMDevices(42, VMConfig{
+		Name:          "k8s_0",
+		StoragePool:   "flashstor",
+		NetworkBridge: "br0",
+		TalosISO:      "/mnt/flashstor/ISO/metal-amd64.iso",
+		BootZVol:      "flashstor/VM/k8s_0-boot",
+		ConfigISO:     configISO,
+	}))
+
+	var cdroms []map[string]interface{}
+	for _, device := range createdDevices {
+		if device["attributes"].(map[string]interface{})["dtype"] == "CDROM" {
+			cdroms = append(cdroms, device)
+		}
+	}
+	require.Len(t, cdroms, 2)
+	assert.Equal(t, float64(1006), asFloat(cdroms[0]["order"]), "the installer stays the first CD-ROM")
+	assert.Equal(t, "/mnt/flashstor/ISO/metal-amd64.iso", cdroms[0]["attributes"].(map[string]interface{})["path"])
+	assert.Equal(t, float64(1007), asFloat(cdroms[1]["order"]))
+	assert.Equal(t, configISO, cdroms[1]["attributes"].(map[string]interface{})["path"])
+}
+
+func TestVMManagerConfigISOPath(t *testing.T) {
+	manager := NewVMManager("nas", "key", 443, true)
+	manager.client.callFn = func(method string, params interface{}, _ int64) (json.RawMessage, error) {
+		switch method {
+		case "vm.query":
+			return mustJSON(map[string]any{"result": []map[string]any{{"id": 1, "name": "k8s_0"}, {"id": 2, "name": "k8s_1"}}}), nil
+		case "vm.device.query":
+			devices := []map[string]any{
+				{"attributes": map[string]any{"dtype": "CDROM", "path": "/mnt/flashstor/ISO/metal-amd64.iso"}},
+				{"attributes": map[string]any{"dtype": "DISK", "path": "/dev/zvol/flashstor/VM/k8s_0-boot"}},
+			}
+			if fmt.Sprint(params) == "[[[vm = 1]]]" {
+				devices = append(devices, map[string]any{"attributes": map[string]any{"dtype": "CDROM", "path": "/mnt/flashstor/ISO/k8s_0-talos-config.iso"}})
+			}
+			return mustJSON(map[string]any{"result": devices}), nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	}
+
+	configISO, err := manager.ConfigISOPath("k8s_0")
+	require.NoError(t, err)
+	assert.Equal(t, "/mnt/flashstor/ISO/k8s_0-talos-config.iso", configISO)
+
+	configISO, err = manager.ConfigISOPath("k8s_1")
+	require.NoError(t, err)
+	assert.Empty(t, configISO, "the shared boot ISO is never reported as the VM's config volume")
+}
@@ -0,0 +1,464 @@
+package truenas
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CPU placement maps straight onto the vm.create/vm.update fields libvirt
+// turns into <vcpu cpuset=…>, <numatune> and per-vCPU <vcpupin>. On a
+// multi-socket NAS, keeping a VM's vCPUs and memory on one NUMA node avoids
+// cross-node memory traffic.
+
+// CPU modes accepted by the middleware's cpu_mode field.
+const (
+	CPUModeCustom          = "CUSTOM"
+	CPUModeHostModel       = "HOST-MODEL"
+	CPUModeHostPassthrough = "HOST-PASSTHROUGH"
+)
+
+// CPUModes lists the valid cpu_mode values, default first.
+var CPUModes = []string{CPUModeHostPassthrough, CPUModeHostModel, CPUModeCustom}
+
+// homeopsDescriptionPrefixes mark the VMs this CLI created (see
+// buildVMConfig and CreateCloudImageVM); only those take part in the cpuset
+// overlap check.
+var homeopsDescriptionPrefixes = []string{"Talos Linux VM - ", "Flatcar Linux VM - ", "Cloud image VM - "}
+
+// CPUPlacement is the placement requested at deploy time. The zero value is
+// the previous hardcoded behaviour: unpinned, host-passthrough.
+type CPUPlacement struct {
+	CPUSet   string // host CPUs the vCPUs may run on, e.g. "0-15"
+	NodeSet  string // NUMA nodes guest memory is allocated from, e.g. "0"
+	PinVCPUs bool   // pin each vCPU to its own CPU from CPUSet
+	CPUMode  string // one of CPUModes; empty means HOST-PASSTHROUGH
+	CPUModel string // a vm.cpu_model_choices entry; CUSTOM mode only
+}
+
+// CPUPlacementUpdate changes an existing VM's placement. Nil fields are left
+// as they are; an empty CPUSet or NodeSet clears it.
+type CPUPlacementUpdate struct {
+	CPUSet   *string
+	NodeSet  *string
+	PinVCPUs *bool
+	CPUMode  *string
+	CPUModel *string
+}
+
+// IsZero reports whether the update changes nothing.
+func (u CPUPlacementUpdate) IsZero() bool {
+	return u.CPUSet == nil && u.NodeSet == nil && u.PinVCPUs == nil && u.CPUMode == nil && u.CPUModel == nil
+}
+
+// Validate checks the placement for a VM with vcpus vCPUs (cores and threads
+// are always 1 for VMs this CLI creates).
+func (p CPUPlacement) Validate(vcpus int) error {
+	mode := p.CPUMode
+	if mode == "" {
+		mode = CPUModeHostPassthrough
+	}
+	if !slices.Contains(CPUModes, mode) {
+		return fmt.Errorf("invalid --cpu-mode %q (want one of: %s)", p.CPUMode, strings.Join(CPUModes, ", "))
+	}
+	if p.CPUModel != "" && mode != CPUModeCustom {
+		return fmt.Errorf("--cpu-model requires --cpu-mode %s (got %s)", CPUModeCustom, mode)
+	}
+	if mode == CPUModeCustom && p.CPUModel == "" {
+		return fmt.Errorf("--cpu-mode %s requires --cpu-model", CPUModeCustom)
+	}
+	cpus, err := ParseCPUSet(p.CPUSet)
+	if err != nil {
+		return fmt.Errorf("invalid --cpuset: %w", err)
+	}
+	if _, err := ParseCPUSet(p.NodeSet); err != nil {
+		return fmt.Errorf("invalid --nodeset: %w", err)
+	}
+	if p.PinVCPUs {
+		if len(cpus) == 0 {
+			return fmt.Errorf("--pin-vcpus requires --cpuset")
+		}
+		if vcpus > 0 && len(cpus) != vcpus {
+			return fmt.Errorf("--pin-vcpus needs exactly one CPU per vCPU: --cpuset %s has %d CPUs for %d vCPUs", p.CPUSet, len(cpus), vcpus)
+		}
+	}
+	return nil
+}
+
+// apply writes the placement into a vm.create payload.
+func (p CPUPlacement) apply(vmConfig map[string]interface{}) {
+	mode := p.CPUMode
+	if mode == "" {
+		mode = CPUModeHostPassthrough
+	}
+	vmConfig["cpu_mode"] = mode
+	vmConfig["cpu_model"] = nil
+	if p.CPUModel != "" {
+		vmConfig["cpu_model"] = p.CPUModel
+	}
+	vmConfig["cpuset"] = p.CPUSet
+	vmConfig["nodeset"] = p.NodeSet
+	vmConfig["pin_vcpus"] = p.PinVCPUs
+}
+
+// merged is the placement the VM ends up with once u is applied to current.
+func (u CPUPlacementUpdate) merged(current CPUPlacement) CPUPlacement {
+	if u.CPUSet != nil {
+		current.CPUSet = *u.CPUSet
+	}
+	if u.NodeSet != nil {
+		current.NodeSet = *u.NodeSet
+	}
+	if u.PinVCPUs != nil {
+		current.PinVCPUs = *u.PinVCPUs
+	}
+	if u.CPUMode != nil {
+		current.CPUMode = *u.CPUMode
+		if current.CPUMode != CPUModeCustom && u.CPUModel == nil {
+			current.CPUModel = "" // leaving CUSTOM drops the model
+		}
+	}
+	if u.CPUModel != nil {
+		current.CPUModel = *u.CPUModel
+	}
+	return current
+}
+
+// updates is the vm.update payload for the fields u changes.
+func (u CPUPlacementUpdate) updates(merged CPUPlacement) map[string]interface{} {
+	out := map[string]interface{}{}
+	if u.CPUSet != nil {
+		out["cpuset"] = merged.CPUSet
+	}
+	if u.NodeSet != nil {
+		out["nodeset"] = merged.NodeSet
+	}
+	if u.PinVCPUs != nil {
+		out["pin_vcpus"] = merged.PinVCPUs
+	}
+	if u.CPUMode != nil || u.CPUModel != nil {
+		out["cpu_mode"] = merged.CPUMode
+		if merged.CPUModel == "" {
+			out["cpu_model"] = nil
+		} else {
+			out["cpu_model"] = merged.CPUModel
+		}
+	}
+	return out
+}
+
+// placementOf reads a queried VM's current placement.
+func placementOf(vmItem *VM) CPUPlacement {
+	return CPUPlacement{
+		CPUSet:   vmItem.CPUSet,
+		NodeSet:  vmItem.NodeSet,
+		PinVCPUs: vmItem.PinVCPUs,
+		CPUMode:  vmItem.CPUMode,
+		CPUModel: vmItem.CPUModel,
+	}
+}
+
+// ParseCPUSet expands a libvirt CPU list such as "0-3,8,10-11" into sorted,
+// de-duplicated CPU numbers. An empty list is valid and means "any".
+func ParseCPUSet(spec string) ([]int, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+	seen := map[int]bool{}
+	var cpus []int
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		lo, hi, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(lo)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("%q is not a CPU number or range", part)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(hi); err != nil || end < start {
+				return nil, fmt.Errorf("%q is not a CPU number or range", part)
+			}
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			if !seen[cpu] {
+				seen[cpu] = true
+				cpus = append(cpus, cpu)
+			}
+		}
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
+
+// FormatCPUSet renders CPU numbers as a compact libvirt list ("0-7,16-23").
+func FormatCPUSet(cpus []int) string {
+	sorted := slices.Clone(cpus)
+	sort.Ints(sorted)
+	var parts []string
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] == sorted[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(sorted[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", sorted[i], sorted[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// CPUSetOverlap is a homeops-managed VM whose cpuset shares CPUs with the one
+// requested.
+type CPUSetOverlap struct {
+	VM   string
+	CPUs []int
+}
+
+// CPUSetOverlaps lists the homeops-managed VMs other than name whose cpuset
+// intersects spec. VMs without a cpuset float over every CPU and are not
+// counted.
+func CPUSetOverlaps(spec, name string, vms []VM) ([]CPUSetOverlap, error) {
+	requested, err := ParseCPUSet(spec)
+	if err != nil || len(requested) == 0 {
+		return nil, err
+	}
+	want := map[int]bool{}
+	for _, cpu := range requested {
+		want[cpu] = true
+	}
+	var overlaps []CPUSetOverlap
+	for _, other := range vms {
+		if other.Name == name || other.CPUSet == "" || !isHomeopsManaged(other) {
+			continue
+		}
+		theirs, err := ParseCPUSet(other.CPUSet)
+		if err != nil {
+			continue // not ours to validate
+		}
+		var shared []int
+		for _, cpu := range theirs {
+			if want[cpu] {
+				shared = append(shared, cpu)
+			}
+		}
+		if len(shared) > 0 {
+			overlaps = append(overlaps, CPUSetOverlap{VM: other.Name, CPUs: shared})
+		}
+	}
+	return overlaps, nil
+}
+
+func isHomeopsManaged(vmItem VM) bool {
+	for _, prefix := range homeopsDescriptionPrefixes {
+		if strings.HasPrefix(vmItem.Description, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// warnCPUSetOverlaps logs one warning per overlapping VM. Overlap is allowed
+// (the VMs just compete for those CPUs), so it never fails the operation.
+func (vm *VMManager) warnCPUSetOverlaps(spec, name string, vms []VM) {
+	overlaps, err := CPUSetOverlaps(spec, name, vms)
+	if err != nil {
+		return
+	}
+	for _, o := range overlaps {
+		vm.logger.Warn("cpuset %s for %s overlaps VM %s on CPUs %s", spec, name, o.VM, FormatCPUSet(o.CPUs))
+	}
+}
+
+// CPUModelChoices lists the models vm.cpu_model_choices accepts for
+// --cpu-model, sorted.
+func (vm *VMManager) CPUModelChoices() ([]string, error) {
+	var choices map[string]string
+	if err := vm.client.callResult("vm.cpu_model_choices", []interface{}{}, 30, &choices); err != nil {
+		return nil, fmt.Errorf("failed to get CPU model choices: %w", err)
+	}
+	models := make([]string, 0, len(choices))
+	for model := range choices {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	return models, nil
+}
+
+// checkCPUModel rejects a model the NAS does not offer.
+func (vm *VMManager) checkCPUModel(model string) error {
+	if model == "" {
+		return nil
+	}
+	models, err := vm.CPUModelChoices()
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(models, model) {
+		return fmt.Errorf("CPU model %q is not offered by this NAS (see 'homeops-cli vm truenas host-topology' or vm.cpu_model_choices)", model)
+	}
+	return nil
+}
+
+// SetVMCPUPlacement updates a VM's cpuset, nodeset, pinning, and CPU mode.
+// Changes to a running VM apply on its next restart.
+func (vm *VMManager) SetVMCPUPlacement(name string, update CPUPlacementUpdate) error {
+	if update.IsZero() {
+		return fmt.Errorf("nothing to change: pass --cpuset, --nodeset, --pin-vcpus, --cpu-mode, or --cpu-model")
+	}
+	vms, err := vm.client.QueryVMs(nil)
+	if err != nil {
+		return fmt.Errorf("failed to query VMs: %w", err)
+	}
+	idx := slices.IndexFunc(vms, func(v VM) bool { return v.Name == name })
+	if idx < 0 {
+		return fmt.Errorf("VM %s not found", name)
+	}
+	vmItem := &vms[idx]
+	merged := update.merged(placementOf(vmItem))
+	vcpus := vmItem.VCPUs * max(vmItem.Cores, 1) * max(vmItem.Threads, 1)
+	if err := merged.Validate(vcpus); err != nil {
+		return err
+	}
+	if update.CPUModel != nil {
+		if err := vm.checkCPUModel(merged.CPUModel); err != nil {
+			return err
+		}
+	}
+	if update.CPUSet != nil {
+		vm.warnCPUSetOverlaps(merged.CPUSet, name, vms)
+	}
+	if err := vm.client.UpdateVM(vmItem.ID, update.updates(merged)); err != nil {
+		return err
+	}
+	if vmIsRunning(vmItem) {
+		vm.logger.Warn("VM %s is running — the new CPU placement applies after a restart ('homeops-cli vm restart --name %s')", name, name)
+	}
+	vm.logger.Success("VM %s CPU placement updated (cpuset=%q nodeset=%q pin_vcpus=%t cpu_mode=%s)", name, merged.CPUSet, merged.NodeSet, merged.PinVCPUs, merged.CPUMode)
+	return nil
+}
+
+// HostTopology is the NAS CPU layout host-topology prints.
+type HostTopology struct {
+	Model         string            `json:"model" yaml:"model"`
+	CPUs          int               `json:"cpus" yaml:"cpus"`
+	PhysicalCores int               `json:"physical_cores" yaml:"physical_cores"`
+	Source        string            `json:"source" yaml:"source"`
+	Nodes         []NUMANode        `json:"numa_nodes" yaml:"numa_nodes"`
+	VMs           []VMCPUAssignment `json:"vms" yaml:"vms"`
+}
+
+// NUMANode lists the logical CPUs of one NUMA node.
+type NUMANode struct {
+	ID          int    `json:"id" yaml:"id"`
+	CPUs        string `json:"cpus" yaml:"cpus"`
+	Cores       int    `json:"cores" yaml:"cores"`
+	LogicalCPUs int    `json:"logical_cpus" yaml:"logical_cpus"`
+}
+
+// VMCPUAssignment is the placement an existing VM already has.
+type VMCPUAssignment struct {
+	Name     string `json:"name" yaml:"name"`
+	VCPUs    int    `json:"vcpus" yaml:"vcpus"`
+	CPUSet   string `json:"cpuset" yaml:"cpuset"`
+	NodeSet  string `json:"nodeset" yaml:"nodeset"`
+	PinVCPUs bool   `json:"pin_vcpus" yaml:"pin_vcpus"`
+	Managed  bool   `json:"homeops_managed" yaml:"homeops_managed"`
+}
+
+// lscpuTopologyCommand prints one "cpu,core,socket,node" line per logical
+// CPU; node is empty on kernels without NUMA.
+const lscpuTopologyCommand = "lscpu -p=CPU,CORE,SOCKET,NODE"
+
+// HostTopology reads the CPU model and counts from system.info and the
+// per-NUMA-node CPU lists from lscpu over SSH, since the middleware does not
+// expose the NUMA layout. Without SSH (sshExec nil or failing) every CPU is
+// reported under node 0 and Source says so.
+func (vm *VMManager) HostTopology(sshExec SSHRunner) (HostTopology, error) {
+	var info struct {
+		Model         string `json:"model"`
+		Cores         int    `json:"cores"`
+		PhysicalCores int    `json:"physical_cores"`
+	}
+	if err := vm.client.callResult("system.info", []interface{}{}, 30, &info); err != nil {
+		return HostTopology{}, fmt.Errorf("failed to query system.info: %w", err)
+	}
+	topology := HostTopology{Model: info.Model, CPUs: info.Cores, PhysicalCores: info.PhysicalCores, Source: "lscpu"}
+
+	var lscpuErr error
+	if sshExec == nil {
+		lscpuErr = fmt.Errorf("no SSH access to the NAS")
+	} else {
+		var out string
+		if out, lscpuErr = sshExec.ExecuteCommand(lscpuTopologyCommand); lscpuErr == nil {
+			topology.Nodes, lscpuErr = ParseLscpuTopology(out)
+		}
+	}
+	if lscpuErr != nil {
+		vm.logger.Warn("NUMA layout unavailable (%v); reporting all %d CPUs as one node", lscpuErr, info.Cores)
+		topology.Source = "system.info (no NUMA layout)"
+		cpus := make([]int, info.Cores)
+		for i := range cpus {
+			cpus[i] = i
+		}
+		topology.Nodes = []NUMANode{{ID: 0, CPUs: FormatCPUSet(cpus), Cores: info.PhysicalCores, LogicalCPUs: info.Cores}}
+	}
+
+	vms, err := vm.client.QueryVMs(nil)
+	if err != nil {
+		return HostTopology{}, fmt.Errorf("failed to query VMs: %w", err)
+	}
+	for _, v := range vms {
+		topology.VMs = append(topology.VMs, VMCPUAssignment{
+			Name: v.Name, VCPUs: v.VCPUs * max(v.Cores, 1) * max(v.Threads, 1),
+			CPUSet: v.CPUSet, NodeSet: v.NodeSet, PinVCPUs: v.PinVCPUs, Managed: isHomeopsManaged(v),
+		})
+	}
+	return topology, nil
+}
+
+// ParseLscpuTopology groups `lscpu -p=CPU,CORE,SOCKET,NODE` output by NUMA
+// node.
+func ParseLscpuTopology(out string) ([]NUMANode, error) {
+	cpusByNode := map[int][]int{}
+	coresByNode := map[int]map[string]bool{}
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("unexpected lscpu line %q", line)
+		}
+		cpu, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected lscpu line %q", line)
+		}
+		node := 0
+		if fields[3] != "" {
+			if node, err = strconv.Atoi(fields[3]); err != nil {
+				return nil, fmt.Errorf("unexpected lscpu line %q", line)
+			}
+		}
+		cpusByNode[node] = append(cpusByNode[node], cpu)
+		if coresByNode[node] == nil {
+			coresByNode[node] = map[string]bool{}
+		}
+		coresByNode[node][fields[2]+"/"+fields[1]] = true
+	}
+	if len(cpusByNode) == 0 {
+		return nil, fmt.Errorf("lscpu reported no CPUs")
+	}
+	nodes := make([]NUMANode, 0, len(cpusByNode))
+	for id, cpus := range cpusByNode {
+		nodes = append(nodes, NUMANode{ID: id, CPUs: FormatCPUSet(cpus), Cores: len(coresByNode[id]), LogicalCPUs: len(cpus)})
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes, nil
+}
@@ -0,0 +1,234 @@
+package truenas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAndFormatCPUSet(t *testing.T) {
+	cpus, err := ParseCPUSet(" 8-11, 0-3,2,16 ")
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 3, 8, 9, 10, 11, 16}, cpus)
+	assert.Equal(t, "0-3,8-11,16", FormatCPUSet(cpus))
+
+	empty, err := ParseCPUSet("")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	for _, bad := range []string{"a", "3-1", "-1", "0-", "0,,1"} {
+		_, err := ParseCPUSet(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestCPUPlacementValidate(t *testing.T) {
+	cases := []struct {
+		name      string
+		placement CPUPlacement
+		vcpus     int
+		want      string
+	}{
+		{name: "zero value is valid", placement: CPUPlacement{}, vcpus: 4},
+		{name: "pinned with one cpu per vcpu", placement: CPUPlacement{CPUSet: "0-3", NodeSet: "0", PinVCPUs: true}, vcpus: 4},
+		{name: "custom with model", placement: CPUPlacement{CPUMode: CPUModeCustom, CPUModel: "EPYC"}, vcpus: 4},
+		{name: "unknown mode", placement: CPUPlacement{CPUMode: "FAST"}, vcpus: 4, want: "invalid --cpu-mode"},
+		{name: "model without custom", placement: CPUPlacement{CPUModel: "EPYC"}, vcpus: 4, want: "requires --cpu-mode CUSTOM"},
+		{name: "custom without model", placement: CPUPlacement{CPUMode: CPUModeCustom}, vcpus: 4, want: "requires --cpu-model"},
+		{name: "bad cpuset", placement: CPUPlacement{CPUSet: "0-x"}, vcpus: 4, want: "invalid --cpuset"},
+		{name: "bad nodeset", placement: CPUPlacement{NodeSet: "one"}, vcpus: 4, want: "invalid --nodeset"},
+		{name: "pin without cpuset", placement: CPUPlacement{PinVCPUs: true}, vcpus: 4, want: "requires --cpuset"},
+		{name: "pin with too few cpus", placement: CPUPlacement{CPUSet: "0-1", PinVCPUs: true}, vcpus: 4, want: "exactly one CPU per vCPU"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.placement.Validate(tc.vcpus)
+			if tc.want == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.want)
+		})
+	}
+}
+
+func TestBuildVMConfigCPUPlacement(t *testing.T) {
+	manager := NewVMManager("nas", "key", 443, true)
+
+	plain := manager.buildVMConfig(VMConfig{Name: "k8s0", VCPUs: 4})
+	assert.Equal(t, CPUModeHostPassthrough, plain["cpu_mode"])
+	assert.Nil(t, plain["cpu_model"])
+	assert.Equal(t, "", plain["cpuset"])
+	assert.Equal(t, "", plain["nodeset"])
+	assert.Equal(t, false, plain["pin_vcpus"])
+
+	pinned := manager.buildVMConfig(VMConfig{Name: "k8s0", VCPUs: 4, CPU: CPUPlacement{
+		CPUSet: "8-11", NodeSet: "1", PinVCPUs: true, CPUMode: CPUModeCustom, CPUModel: "EPYC-Rome",
+	}})
+	assert.Equal(t, CPUModeCustom, pinned["cpu_mode"])
+	assert.Equal(t, "EPYC-Rome", pinned["cpu_model"])
+	assert.Equal(t, "8-11", pinned["cpuset"])
+	assert.Equal(t, "1", pinned["nodeset"])
+	assert.Equal(t, true, pinned["pin_vcpus"])
+}
+
+func TestSetVMCPUPlacement(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+	yes := true
+
+	t.Run("sends only the changed fields", func(t *testing.T) {
+		manager, calls := opsTestManager(t, "STOPPED", func(method string, params interface{}) (json.RawMessage, error) {
+			if method == "vm.update" {
+				return mustJSON(map[string]any{"result": true}), nil
+			}
+			return nil, fmt.Errorf("unexpected method %s", method)
+		})
+		require.NoError(t, manager.SetVMCPUPlacement("web0", CPUPlacementUpdate{CPUSet: ptr("0-1"), PinVCPUs: &yes}))
+		updates := methodCalls(*calls, "vm.update")
+		require.Len(t, updates, 1)
+		args := updates[0].params.([]interface{})
+		assert.Equal(t, 7, args[0])
+		assert.Equal(t, map[string]interface{}{"cpuset": "0-1", "pin_vcpus": true}, args[1])
+	})
+
+	t.Run("leaving custom clears the model", func(t *testing.T) {
+		manager, calls := opsTestManager(t, "STOPPED", func(method string, params interface{}) (json.RawMessage, error) {
+			if method == "vm.update" {
+				return mustJSON(map[string]any{"result": true}), nil
+			}
+			return nil, fmt.Errorf("unexpected method %s", method)
+		})
+		require.NoError(t, manager.SetVMCPUPlacement("web0", CPUPlacementUpdate{CPUMode: ptr(CPUModeHostModel)}))
+		updates := methodCalls(*calls, "vm.update")
+		require.Len(t, updates, 1)
+		assert.Equal(t, map[string]interface{}{"cpu_mode": CPUModeHostModel, "cpu_model": nil}, updates[0].params.([]interface{})[1])
+	})
+
+	t.Run("rejects an invalid result before updating", func(t *testing.T) {
+		manager, calls := opsTestManager(t, "STOPPED", nil)
+		err := manager.SetVMCPUPlacement("web0", CPUPlacementUpdate{CPUSet: ptr("0-3"), PinVCPUs: &yes})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "has 4 CPUs for 2 vCPUs")
+		assert.Empty(t, methodCalls(*calls, "vm.update"))
+
+		err = manager.SetVMCPUPlacement("web0", CPUPlacementUpdate{CPUMode: ptr(CPUModeCustom), CPUModel: ptr("Nehalem")})
+		require.Error(t, err, "vm.cpu_model_choices is unavailable, so the model cannot be checked")
+		assert.Empty(t, methodCalls(*calls, "vm.update"))
+
+		require.Error(t, manager.SetVMCPUPlacement("web0", CPUPlacementUpdate{}))
+		require.Error(t, manager.SetVMCPUPlacement("db0", CPUPlacementUpdate{CPUSet: ptr("0")}))
+	})
+}
+
+func TestCPUSetOverlaps(t *testing.T) {
+	vms := []VM{
+		{Name: "k8s0", Description: "Talos Linux VM - k8s0", CPUSet: "0-7"},
+		{Name: "k8s1", Description: "Talos Linux VM - k8s1", CPUSet: "8-15"},
+		{Name: "fc0", Description: "Flatcar Linux VM - fc0", CPUSet: "6,7,30"},
+		{Name: "floating", Description: "Talos Linux VM - floating"},
+		{Name: "plex", Description: "hand-made", CPUSet: "0-31"},
+	}
+
+	overlaps, err := CPUSetOverlaps("4-9", "new0", vms)
+	require.NoError(t, err)
+	assert.Equal(t, []CPUSetOverlap{
+		{VM: "k8s0", CPUs: []int{4, 5, 6, 7}},
+		{VM: "k8s1", CPUs: []int{8, 9}},
+		{VM: "fc0", CPUs: []int{6, 7}},
+	}, overlaps, "unpinned and non-homeops VMs are not counted")
+
+	overlaps, err = CPUSetOverlaps("0-7", "k8s0", vms)
+	require.NoError(t, err)
+	assert.Equal(t, []CPUSetOverlap{{VM: "fc0", CPUs: []int{6, 7}}}, overlaps, "a VM never overlaps itself")
+
+	overlaps, err = CPUSetOverlaps("", "new0", vms)
+	require.NoError(t, err)
+	assert.Empty(t, overlaps)
+
+	_, err = CPUSetOverlaps("x", "new0", vms)
+	assert.Error(t, err)
+}
+
+func TestParseLscpuTopology(t *testing.T) {
+	out := `# The following is the parsable format, which can be fed to other
+# programs. Each different item in every column has an unique ID
+# starting from zero.
+# CPU,Core,Socket,Node
+0,0,0,0
+1,1,0,0
+2,2,1,1
+3,3,1,1
+4,0,0,0
+5,1,0,0
+6,2,1,1
+7,3,1,1
+`
+	nodes, err := ParseLscpuTopology(out)
+	require.NoError(t, err)
+	assert.Equal(t, []NUMANode{
+		{ID: 0, CPUs: "0-1,4-5", Cores: 2, LogicalCPUs: 4},
+		{ID: 1, CPUs: "2-3,6-7", Cores: 2, LogicalCPUs: 4},
+	}, nodes)
+
+	nodes, err = ParseLscpuTopology("0,0,0,\n1,1,0,\n")
+	require.NoError(t, err)
+	assert.Equal(t, []NUMANode{{ID: 0, CPUs: "0-1", Cores: 2, LogicalCPUs: 2}}, nodes, "no NUMA means one node 0")
+
+	_, err = ParseLscpuTopology("# only comments\n")
+	assert.Error(t, err)
+	_, err = ParseLscpuTopology("0,0\n")
+	assert.Error(t, err)
+}
+
+type fakeTopologySSH struct {
+	out string
+	err error
+}
+
+func (f fakeTopologySSH) ExecuteCommand(command string) (string, error) {
+	if command != lscpuTopologyCommand {
+		return "", fmt.Errorf("unexpected command %q", command)
+	}
+	return f.out, f.err
+}
+func (f fakeTopologySSH) UploadBytes([]byte, string) error { return nil }
+
+func TestHostTopology(t *testing.T) {
+	manager := NewVMManager("nas", "key", 443, true)
+	manager.client.callFn = func(method string, params interface{}, timeoutSeconds int64) (json.RawMessage, error) {
+		switch method {
+		case "system.info":
+			return mustJSON(map[string]any{"result": map[string]any{"model": "AMD EPYC 7302P", "cores": 4, "physical_cores": 2}}), nil
+		case "vm.query":
+			return mustJSON(map[string]any{"result": []map[string]any{
+				{"id": 7, "name": "k8s0", "description": "Talos Linux VM - k8s0", "vcpus": 2, "cores": 1, "threads": 1, "cpuset": "0-1", "nodeset": "0", "pin_vcpus": true},
+				{"id": 8, "name": "plex", "description": "", "vcpus": 1, "cores": 2, "threads": 1},
+			}}), nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	}
+	wantVMs := []VMCPUAssignment{
+		{Name: "k8s0", VCPUs: 2, CPUSet: "0-1", NodeSet: "0", PinVCPUs: true, Managed: true},
+		{Name: "plex", VCPUs: 2},
+	}
+
+	topology, err := manager.HostTopology(fakeTopologySSH{out: "0,0,0,0\n1,1,0,0\n2,0,1,1\n3,1,1,1\n"})
+	require.NoError(t, err)
+	assert.Equal(t, "AMD EPYC 7302P", topology.Model)
+	assert.Equal(t, "lscpu", topology.Source)
+	assert.Equal(t, []NUMANode{{ID: 0, CPUs: "0-1", Cores: 2, LogicalCPUs: 2}, {ID: 1, CPUs: "2-3", Cores: 2, LogicalCPUs: 2}}, topology.Nodes)
+	assert.Equal(t, wantVMs, topology.VMs)
+
+	for name, sshExec := range map[string]SSHRunner{"no ssh": nil, "lscpu fails": fakeTopologySSH{err: errors.New("permission denied")}} {
+		topology, err := manager.HostTopology(sshExec)
+		require.NoError(t, err, name)
+		assert.Equal(t, "system.info (no NUMA layout)", topology.Source, name)
+		assert.Equal(t, []NUMANode{{ID: 0, CPUs: "0-3", Cores: 2, LogicalCPUs: 4}}, topology.Nodes, name)
+		assert.Equal(t, wantVMs, topology.VMs, name)
+	}
+}
@@ -0,0 +1,116 @@
+package truenas
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// A deploy that fails half way (a NIC or display device the middleware
+// rejects) used to leave the VM record and its ZVols behind, and the next
+// run stopped at "VM already exists". DeployVM now records every resource it
+// creates and, unless VMConfig.KeepOnFailure is set, deletes them again in
+// reverse order: devices, then the VM, then the ZVols. Parent datasets and
+// ZVols that already existed are never touched. CloneVM rolls back the same
+// way, with the snapshots its ZVol clones were made from.
+
+// Kinds of resources a deploy creates.
+const (
+	rollbackZVol   = "ZVol"
+	rollbackVM     = "VM"
+	rollbackDevice = "device"
+	// rollbackSnapshot is a ZFS snapshot ("dataset@name") a clone was made
+	// from.
+	rollbackSnapshot = "snapshot"
+)
+
+type createdResource struct {
+	kind string
+	id   int
+	name string
+}
+
+func (r createdResource) String() string {
+	switch r.kind {
+	case rollbackZVol:
+		return "ZVol " + r.name
+	case rollbackVM:
+		return fmt.Sprintf("VM %s (ID %d)", r.name, r.id)
+	case rollbackSnapshot:
+		return "snapshot " + r.name
+	}
+	return fmt.Sprintf("%s device %d", r.name, r.id)
+}
+
+// deployRollback is the ordered list of what one DeployVM run created.
+type deployRollback struct {
+	created []createdResource
+}
+
+func (r *deployRollback) add(resource createdResource) {
+	if r != nil {
+		r.created = append(r.created, resource)
+	}
+}
+
+func (r *deployRollback) describe() string {
+	names := make([]string, len(r.created))
+	for i, resource := range r.created {
+		names[i] = resource.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// rollBack deletes what the failed deploy created, newest first, logging each
+// removal. cause is returned, extended with whatever could not be removed.
+func (vm *VMManager) rollBack(r *deployRollback, config VMConfig, cause error) error {
+	if r == nil || len(r.created) == 0 {
+		return cause
+	}
+	if config.KeepOnFailure {
+		vm.logger.Warn("Keeping partially created resources for %s (--keep-on-failure): %s", config.Name, r.describe())
+		return cause
+	}
+	vm.logger.Warn("Creating %s failed; rolling back %d created resource(s)", config.Name, len(r.created))
+	var leftover []string
+	for i := len(r.created) - 1; i >= 0; i-- {
+		resource := r.created[i]
+		var err error
+		switch resource.kind {
+		case rollbackDevice:
+			err = vm.client.DeleteVMDevice(resource.id)
+		case rollbackVM:
+			err = vm.client.DeleteVM(resource.id)
+		case rollbackZVol:
+			err = vm.client.DeleteDataset(resource.name, false)
+		case rollbackSnapshot:
+			err = vm.client.DeleteZFSSnapshot(resource.name)
+		}
+		if IsNotFound(err) {
+			vm.logger.Info("%s is already gone", resource)
+			continue
+		}
+		if err != nil {
+			vm.logger.Warn("Could not roll back %s: %v", resource, err)
+			leftover = append(leftover, resource.String())
+			continue
+		}
+		vm.logger.Info("Rolled back %s", resource)
+	}
+	if len(leftover) > 0 {
+		return fmt.Errorf("%w (rollback left behind %s; remove them with 'vm delete' or 'vm cleanup-zvols')", cause, strings.Join(leftover, ", "))
+	}
+	return cause
+}
+
+// createdDeviceID reads the ID from a vm.device.create response; 0 when the
+// response carries none (the device then goes with the VM).
+func createdDeviceID(raw json.RawMessage) int {
+	var device struct {
+		ID int `json:"id"`
+	}
+	if err := decodeResult("vm.device.create", raw, &device); err != nil {
+		return 0
+	}
+	return device.ID
+}
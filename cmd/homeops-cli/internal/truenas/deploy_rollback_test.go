@@ -0,0 +1,132 @@
+package truenas
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingDisplayNAS fakes a NAS whose vm.device.create rejects the SPICE
+// display device. It returns the manager and the mutating calls made, in
+// order, as "method arg".
+func failingDisplayNAS(t *testing.T, deleteErr error) (*VMManager, *[]string) {
+	t.Helper()
+	manager := NewVMManager("nas", "key", 443, true)
+	datasets := map[string]bool{"flashstor": true, "flashstor/VM": true}
+	var mutations []string
+	nextDevice := 100
+	manager.client.callFn = func(method string, params interface{}, _ int64) (json.RawMessage, error) {
+		args, _ := params.([]interface{})
+		switch method {
+		case "vm.query":
+			return mustJSON(map[string]any{"result": []map[string]any{}}), nil
+		case "pool.dataset.query":
+			var out []map[string]any
+			for name := range datasets {
+				out = append(out, map[string]any{"name": name})
+			}
+			return mustJSON(map[string]any{"result": out}), nil
+		case "pool.dataset.create":
+			name := datasetCreateArgs(params)["name"].(string)
+			datasets[name] = true
+			mutations = append(mutations, method+" "+name)
+			return mustJSON(map[string]any{"result": true}), nil
+		case "vm.create":
+			mutations = append(mutations, method)
+			return mustJSON(map[string]any{"result": map[string]any{"id": 41, "name": "cp-0"}}), nil
+		case "vm.device.create":
+			attrs := args[0].(map[string]interface{})["attributes"].(map[string]interface{})
+			if attrs["dtype"] == "DISPLAY" {
+				return nil, fmt.Errorf("[EINVAL] vm_device_create.attributes.bind: invalid address")
+			}
+			nextDevice++
+			mutations = append(mutations, fmt.Sprintf("%s %v", method, attrs["dtype"]))
+			return mustJSON(map[string]any{"result": map[string]any{"id": nextDevice}}), nil
+		case "vm.device.delete", "vm.delete":
+			mutations = append(mutations, fmt.Sprintf("%s %v", method, args[0]))
+			if deleteErr != nil && method == "vm.delete" {
+				return nil, deleteErr
+			}
+			return mustJSON(map[string]any{"result": true}), nil
+		case "pool.dataset.delete":
+			delete(datasets, args[0].(string))
+			mutations = append(mutations, fmt.Sprintf("%s %v", method, args[0]))
+			return mustJSON(map[string]any{"result": true}), nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	}
+	return manager, &mutations
+}
+
+var failingDisplayConfig = VMConfig{
+	Name: "cp-0", Memory: 8192, VCPUs: 4, DiskSize: 250, OpenEBSSize: 1000,
+	StoragePool: "flashstor", NetworkBridge: "br0", TalosISO: "/isos/talos.iso",
+	SpicePassword: "secret", UseSpice: true,
+}
+
+func TestDeployVMRollsBackWhenADeviceFails(t *testing.T) {
+	manager, mutations := failingDisplayNAS(t, nil)
+
+	err := manager.DeployVM(failingDisplayConfig)
+	require.ErrorContains(t, err, "failed to create display device")
+	assert.Equal(t, []string{
+		"pool.dataset.create flashstor/VM/cp-0-boot",
+		"pool.dataset.create flashstor/VM/cp-0-openebs",
+		"vm.create",
+		"vm.device.create CDROM",
+		"vm.device.create NIC",
+		"vm.device.create DISK",
+		"vm.device.create DISK",
+		// rolled back newest first
+		"vm.device.delete 104",
+		"vm.device.delete 103",
+		"vm.device.delete 102",
+		"vm.device.delete 101",
+		"vm.delete 41",
+		"pool.dataset.delete flashstor/VM/cp-0-openebs",
+		"pool.dataset.delete flashstor/VM/cp-0-boot",
+	}, *mutations)
+	assert.Nil(t, manager.rollback, "the tracker only lives for one deploy")
+}
+
+func TestDeployVMKeepOnFailureLeavesResources(t *testing.T) {
+	manager, mutations := failingDisplayNAS(t, nil)
+	config := failingDisplayConfig
+	config.KeepOnFailure = true
+
+	require.ErrorContains(t, manager.DeployVM(config), "failed to create display device")
+	assert.Len(t, *mutations, 7, "nothing is deleted")
+	assert.Equal(t, "vm.device.create DISK", (*mutations)[6])
+}
+
+func TestDeployVMRollbackReportsLeftovers(t *testing.T) {
+	manager, mutations := failingDisplayNAS(t, fmt.Errorf("VM is locked"))
+
+	err := manager.DeployVM(failingDisplayConfig)
+	require.ErrorContains(t, err, "failed to create display device")
+	require.ErrorContains(t, err, "rollback left behind VM cp-0 (ID 41)")
+	assert.Contains(t, *mutations, "pool.dataset.delete flashstor/VM/cp-0-boot", "a failed step does not stop the rest of the rollback")
+}
+
+func TestDeployVMDoesNotRollBackPreexistingZVols(t *testing.T) {
+	manager, mutations := failingDisplayNAS(t, nil)
+	config := failingDisplayConfig
+	config.SkipZVolCreate = true
+	query := manager.client.callFn
+	manager.client.callFn = func(method string, params interface{}, timeout int64) (json.RawMessage, error) {
+		if method == "pool.dataset.query" {
+			name := params.([]interface{})[0].([][]interface{})[0][2]
+			return mustJSON(map[string]any{"result": []map[string]any{{"name": name}}}), nil
+		}
+		return query(method, params, timeout)
+	}
+
+	require.ErrorContains(t, manager.DeployVM(config), "failed to create display device")
+	assert.Contains(t, *mutations, "vm.delete 41")
+	for _, call := range *mutations {
+		assert.NotContains(t, call, "pool.dataset", "ZVols the deploy did not create are kept")
+	}
+}
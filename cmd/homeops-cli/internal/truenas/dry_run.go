@@ -0,0 +1,181 @@
+package truenas
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// A dry run walks a deploy or delete against the live middleware: reads
+// (queries, choices, status) are made as usual so existence checks stay
+// accurate, while every mutating call is recorded in a DryRunPlan and
+// answered locally without reaching the NAS.
+
+// PlannedCall is one mutating call a dry run would have made.
+type PlannedCall struct {
+	Method string
+	Params interface{}
+}
+
+// DryRunPlan collects the mutating calls of a dry run, in order.
+type DryRunPlan struct {
+	Calls []PlannedCall
+}
+
+// readOnlyMethods are the methods without a .query, .get_instance or
+// _choices suffix that only read state.
+var readOnlyMethods = map[string]bool{
+	"system.version":             true,
+	"system.info":                true,
+	"core.get_jobs":              true,
+	"core.ping":                  true,
+	"vm.status":                  true,
+	"vm.random_mac":              true,
+	"vm.bootloader_options":      true,
+	"vm.get_available_memory":    true,
+	"vm.maximum_supported_vcpus": true,
+}
+
+// isReadOnlyMethod reports whether a dry run may send method to the NAS.
+// Anything not known to only read is treated as mutating.
+func isReadOnlyMethod(method string) bool {
+	return readOnlyMethods[method] || strings.HasSuffix(method, ".query") ||
+		strings.HasSuffix(method, ".get_instance") || strings.HasSuffix(method, "_choices")
+}
+
+// SetDryRun records every mutating call in plan instead of sending it; nil
+// turns the dry run off.
+func (c *WorkingClient) SetDryRun(plan *DryRunPlan) {
+	c.dryRun = plan
+}
+
+// SetDryRun walks the manager's deploys and deletes without changing the
+// NAS, recording what they would do in plan; nil turns the dry run off.
+func (vm *VMManager) SetDryRun(plan *DryRunPlan) {
+	vm.client.SetDryRun(plan)
+}
+
+// dryRunning reports whether the manager's mutating calls are being
+// recorded rather than made.
+func (vm *VMManager) dryRunning() bool {
+	return vm.client.dryRun != nil
+}
+
+// record adds a call to the plan and returns the response it stands in
+// for: a create yields ID 0 (the resource does not exist yet), anything
+// else true. A call identical to one already planned, such as the parent
+// dataset of a second ZVol, is planned once.
+func (p *DryRunPlan) record(method string, params interface{}) json.RawMessage {
+	encoded, _ := json.Marshal(params)
+	duplicate := false
+	for _, call := range p.Calls {
+		if planned, _ := json.Marshal(call.Params); call.Method == method && string(planned) == string(encoded) {
+			duplicate = true
+			break
+		}
+	}
+	if !duplicate {
+		p.Calls = append(p.Calls, PlannedCall{Method: method, Params: params})
+	}
+	if strings.HasSuffix(method, ".create") {
+		return json.RawMessage(`{"result": {"id": 0}}`)
+	}
+	return json.RawMessage(`{"result": true}`)
+}
+
+// Lines renders the plan for reading: one line per call, followed by the
+// indented JSON payload of a VM or device create.
+func (p *DryRunPlan) Lines() []string {
+	var lines []string
+	for _, call := range p.Calls {
+		lines = append(lines, call.describe()...)
+	}
+	return lines
+}
+
+func (call PlannedCall) describe() []string {
+	// Round-trip through JSON so struct and map payloads read alike.
+	var args []interface{}
+	encoded, _ := json.Marshal(call.Params)
+	_ = json.Unmarshal(encoded, &args)
+	arg := func(i int) interface{} {
+		if i < len(args) {
+			return args[i]
+		}
+		return nil
+	}
+	object := func(i int) map[string]interface{} {
+		m, _ := arg(i).(map[string]interface{})
+		return m
+	}
+
+	switch call.Method {
+	case "pool.dataset.create":
+		dataset := object(0)
+		if dataset["type"] != "VOLUME" {
+			return []string{fmt.Sprintf("Create dataset %v", dataset["name"])}
+		}
+		details := []string{}
+		if volsize, ok := dataset["volsize"].(float64); ok {
+			details = append(details, fmt.Sprintf("%dGB", int64(volsize)/(1024*1024*1024)))
+		}
+		if sparse, _ := dataset["sparse"].(bool); sparse {
+			details = append(details, "sparse")
+		}
+		for _, key := range []string{"volblocksize", "compression"} {
+			if value, ok := dataset[key]; ok {
+				details = append(details, fmt.Sprintf("%s %v", key, value))
+			}
+		}
+		return []string{fmt.Sprintf("Create ZVol %v (%s)", dataset["name"], strings.Join(details, ", "))}
+	case "pool.dataset.update":
+		return []string{fmt.Sprintf("Update dataset %v: %s", arg(0), compactJSON(arg(1)))}
+	case "pool.dataset.delete":
+		return []string{fmt.Sprintf("Destroy dataset %v and its snapshots", arg(0))}
+	case "vm.create":
+		return append([]string{fmt.Sprintf("Create VM %v:", object(0)["name"])}, indentedJSON(arg(0))...)
+	case "vm.update":
+		return append([]string{fmt.Sprintf("Update %s:", plannedVM(arg(0)))}, indentedJSON(arg(1))...)
+	case "vm.device.create":
+		device := object(0)
+		attributes, _ := device["attributes"].(map[string]interface{})
+		header := fmt.Sprintf("Add %v device (order %v) to %s:", attributes["dtype"], device["order"], plannedVM(device["vm"]))
+		return append([]string{header}, indentedJSON(redactPassword(attributes))...)
+	case "vm.device.update":
+		attributes, _ := object(1)["attributes"].(map[string]interface{})
+		return append([]string{fmt.Sprintf("Update device ID %v:", arg(0))}, indentedJSON(redactPassword(attributes))...)
+	case "vm.start":
+		return []string{"Start " + plannedVM(arg(0))}
+	case "vm.stop", "vm.poweroff":
+		return []string{"Stop " + plannedVM(arg(0))}
+	case "vm.delete":
+		return []string{"Delete " + plannedVM(arg(0))}
+	}
+	return []string{fmt.Sprintf("Call %s %s", call.Method, compactJSON(call.Params))}
+}
+
+// plannedVM names a VM by ID; ID 0 is the one the plan creates.
+func plannedVM(id interface{}) string {
+	if n, ok := id.(float64); ok && n == 0 {
+		return "the new VM"
+	}
+	return fmt.Sprintf("VM ID %v", id)
+}
+
+// redactPassword masks a display device's SPICE password.
+func redactPassword(attributes map[string]interface{}) map[string]interface{} {
+	if password, _ := attributes["password"].(string); password != "" {
+		attributes["password"] = "********"
+	}
+	return attributes
+}
+
+func compactJSON(value interface{}) string {
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+func indentedJSON(value interface{}) []string {
+	encoded, _ := json.MarshalIndent(value, "    ", "  ")
+	return strings.Split("    "+string(encoded), "\n")
+}
@@ -0,0 +1,93 @@
+package truenas
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRunDeleteListsTheZVolsItWouldDestroy(t *testing.T) {
+	manager := NewVMManager("nas", "key", 443, true)
+	var sent []string
+	manager.client.callFn = func(method string, _ interface{}, _ int64) (json.RawMessage, error) {
+		sent = append(sent, method)
+		switch method {
+		case "vm.query":
+			return mustJSON(map[string]any{"result": []map[string]any{
+				{"id": 12, "name": "k8s0", "status": map[string]any{"state": "RUNNING"}},
+			}}), nil
+		case "vm.device.query":
+			return mustJSON(map[string]any{"result": []map[string]any{
+				{"attributes": map[string]any{"dtype": "DISK", "path": "/dev/zvol/flashstor/VM/k8s0-boot"}},
+				{"attributes": map[string]any{"dtype": "DISK", "path": "/dev/zvol/flashstor/VM/k8s0-openebs"}},
+				{"attributes": map[string]any{"dtype": "DISK", "path": "/dev/zvol/tank/VM/k8s0-ceph"}},
+				{"attributes": map[string]any{"dtype": "CDROM", "path": "/mnt/flashstor/ISO/talos.iso"}},
+			}}), nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	}
+
+	plan := &DryRunPlan{}
+	manager.SetDryRun(plan)
+	require.NoError(t, manager.DeleteVM("k8s0", true, ""))
+	assert.NotContains(t, sent, "vm.stop")
+	assert.NotContains(t, sent, "vm.delete")
+	assert.NotContains(t, sent, "pool.dataset.delete")
+	assert.Equal(t, []string{
+		"Stop VM ID 12",
+		"Delete VM ID 12",
+		"Destroy dataset flashstor/VM/k8s0-boot and its snapshots",
+		"Destroy dataset flashstor/VM/k8s0-openebs and its snapshots",
+		"Destroy dataset tank/VM/k8s0-ceph and its snapshots",
+	}, plan.Lines())
+}
+
+func TestDryRunDeployPlansEveryCreate(t *testing.T) {
+	manager := NewVMManager("nas", "key", 443, true)
+	var sent []string
+	manager.client.callFn = func(method string, _ interface{}, _ int64) (json.RawMessage, error) {
+		sent = append(sent, method)
+		switch method {
+		case "vm.query":
+			return mustJSON(map[string]any{"result": []map[string]any{}}), nil
+		case "pool.dataset.query":
+			return mustJSON(map[string]any{"result": []map[string]any{{"name": "flashstor"}}}), nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	}
+
+	plan := &DryRunPlan{}
+	manager.SetDryRun(plan)
+	require.NoError(t, manager.DeployVM(VMConfig{
+		Name: "k8s0", Memory: 8192, VCPUs: 4, DiskSize: 250, OpenEBSSize: 1000, StoragePool: "flashstor",
+		NetworkBridge: "br0", TalosISO: "/isos/talos.iso", SpicePassword: "secret", UseSpice: true,
+		MacAddress: "00:a0:98:00:00:01", Start: true,
+	}))
+	for _, method := range sent {
+		assert.True(t, isReadOnlyMethod(method), "%s reached the NAS", method)
+	}
+
+	var methods []string
+	for _, call := range plan.Calls {
+		methods = append(methods, call.Method)
+	}
+	assert.Equal(t, []string{
+		"pool.dataset.create", "pool.dataset.create", "pool.dataset.create", "vm.create",
+		"vm.device.create", "vm.device.create", "vm.device.create", "vm.device.create", "vm.device.create", "vm.start",
+	}, methods, "the parent dataset is planned once for both ZVols")
+
+	lines := strings.Join(plan.Lines(), "\n")
+	assert.Contains(t, lines, "Create dataset flashstor/VM\n")
+	assert.Contains(t, lines, "Create ZVol flashstor/VM/k8s0-boot (250GB, sparse)")
+	assert.Contains(t, lines, "Create ZVol flashstor/VM/k8s0-openebs (1000GB, sparse)")
+	assert.Contains(t, lines, "Create VM k8s0:\n    {\n")
+	assert.Contains(t, lines, `      "memory": 8192,`)
+	assert.Contains(t, lines, "Add DISK device (order 1001) to the new VM:")
+	assert.Contains(t, lines, `      "password": "********",`)
+	assert.NotContains(t, lines, "secret")
+	assert.True(t, strings.HasSuffix(lines, "Start the new VM"))
+}
@@ -0,0 +1,204 @@
+package truenas
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The middleware reports a failed call as an error object rather than a
+// transport error: JSON-RPC 2.0 (/api/current) nests it under "data" of
+// {"code", "message"}, the legacy websocket protocol sends it bare, and a
+// failed job carries it in exc_info. All three become a *TrueNASError, so
+// callers classify failures by errno and rejected attribute instead of by
+// the wording of the message.
+
+// Linux errnos the middleware reports (they differ on other platforms, so
+// syscall's are not used).
+const (
+	errnoENOENT = 2
+	errnoEEXIST = 17
+)
+
+// TrueNASError is an error reported by the middleware.
+type TrueNASError struct {
+	Method  string // the call that failed; "" for a job's error
+	Code    int    // JSON-RPC error code, 0 on the legacy protocol
+	Errno   int    // errno of the failure, e.g. 2 (ENOENT)
+	ErrName string // its name: ENOENT, EEXIST, EINVAL, ...
+	Reason  string // the middleware's message
+	Class   string // exception class from the trace, e.g. ValidationErrors
+	Trace   string // formatted middleware traceback
+	// Validation holds one entry per attribute a validation failure
+	// rejected.
+	Validation []ValidationError
+}
+
+// ValidationError is one rejected attribute of a call, e.g.
+// vm_create.memory.
+type ValidationError struct {
+	Attribute string
+	Message   string
+	Errno     int
+}
+
+func (e *TrueNASError) Error() string {
+	detail := strings.TrimSpace(e.Reason)
+	if len(e.Validation) > 0 {
+		fields := make([]string, len(e.Validation))
+		for i, v := range e.Validation {
+			fields[i] = v.Attribute + ": " + v.Message
+		}
+		detail = strings.Join(fields, "; ")
+	}
+	if detail == "" {
+		detail = fmt.Sprintf("error %d", e.Code)
+	}
+	if e.Method == "" {
+		return detail
+	}
+	return fmt.Sprintf("%s failed: %s", e.Method, detail)
+}
+
+// hasErrno reports whether the failure, or any attribute it rejected,
+// carries errno.
+func (e *TrueNASError) hasErrno(errno int, name string) bool {
+	if e.Errno == errno || e.ErrName == name {
+		return true
+	}
+	for _, v := range e.Validation {
+		if v.Errno == errno {
+			return true
+		}
+	}
+	return false
+}
+
+// IsNotFound reports whether err is a middleware failure for something that
+// does not exist (ENOENT), such as vm.get_instance of a deleted VM.
+func IsNotFound(err error) bool {
+	var tnErr *TrueNASError
+	return errors.As(err, &tnErr) && tnErr.hasErrno(errnoENOENT, "ENOENT")
+}
+
+// IsAlreadyExists reports whether err is a middleware failure for something
+// that already exists (EEXIST).
+func IsAlreadyExists(err error) bool {
+	var tnErr *TrueNASError
+	return errors.As(err, &tnErr) && tnErr.hasErrno(errnoEEXIST, "EEXIST")
+}
+
+// IsValidationError reports whether err is the middleware rejecting a call's
+// arguments; the *TrueNASError's Validation names the attributes.
+func IsValidationError(err error) bool {
+	var tnErr *TrueNASError
+	if !errors.As(err, &tnErr) {
+		return false
+	}
+	return len(tnErr.Validation) > 0 || tnErr.Class == "ValidationErrors" || tnErr.Class == "ValidationError"
+}
+
+// rpcErrorObject is the middleware's error payload on either protocol.
+type rpcErrorObject struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	Errno   int             `json:"error"`
+	ErrName string          `json:"errname"`
+	Type    string          `json:"type"`
+	Reason  string          `json:"reason"`
+	Trace   *struct {
+		Class     string `json:"class"`
+		Formatted string `json:"formatted"`
+	} `json:"trace"`
+	Extra json.RawMessage `json:"extra"`
+}
+
+// responseError returns the *TrueNASError of a response that carries an
+// error object, nil for a successful one or a body that is not JSON.
+func responseError(method string, raw json.RawMessage) error {
+	var envelope struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil || isJSONNull(envelope.Error) {
+		return nil
+	}
+	return parseTrueNASError(method, envelope.Error)
+}
+
+func parseTrueNASError(method string, raw json.RawMessage) *TrueNASError {
+	tnErr := &TrueNASError{Method: method}
+	var object rpcErrorObject
+	if err := json.Unmarshal(raw, &object); err != nil {
+		tnErr.Reason = string(raw)
+		return tnErr
+	}
+	tnErr.Code = object.Code
+	if !isJSONNull(object.Data) {
+		// JSON-RPC 2.0: the middleware's details are in data.
+		var data rpcErrorObject
+		if err := json.Unmarshal(object.Data, &data); err == nil {
+			data.Code, data.Message = object.Code, object.Message
+			object = data
+		}
+	}
+	tnErr.Errno, tnErr.ErrName, tnErr.Reason = object.Errno, object.ErrName, object.Reason
+	if tnErr.Reason == "" {
+		tnErr.Reason = object.Message
+	}
+	if object.Trace != nil {
+		tnErr.Class, tnErr.Trace = object.Trace.Class, object.Trace.Formatted
+	}
+	if object.Type == "VALIDATION" && tnErr.Class == "" {
+		tnErr.Class = "ValidationErrors"
+	}
+	tnErr.Validation = parseValidationErrors(object.Extra)
+	return tnErr
+}
+
+// jobError is the *TrueNASError of a failed job (its exc_info).
+func jobError(job *Job) *TrueNASError {
+	tnErr := &TrueNASError{Reason: job.Error}
+	if job.ExcInfo != nil {
+		tnErr.Errno = job.ExcInfo.Errno
+		if job.ExcInfo.Type == "VALIDATION" {
+			tnErr.Class = "ValidationErrors"
+		}
+		tnErr.Validation = parseValidationErrors(job.ExcInfo.Extra)
+	}
+	if tnErr.Reason == "" && len(tnErr.Validation) == 0 {
+		tnErr.Reason = "no error reported"
+	}
+	return tnErr
+}
+
+// parseValidationErrors reads the [attribute, message, errno] triples of a
+// validation failure's extra; anything else yields none.
+func parseValidationErrors(extra json.RawMessage) []ValidationError {
+	var entries [][]json.RawMessage
+	if isJSONNull(extra) || json.Unmarshal(extra, &entries) != nil {
+		return nil
+	}
+	var out []ValidationError
+	for _, entry := range entries {
+		if len(entry) < 2 {
+			continue
+		}
+		var v ValidationError
+		if json.Unmarshal(entry[0], &v.Attribute) != nil || json.Unmarshal(entry[1], &v.Message) != nil {
+			continue
+		}
+		if len(entry) > 2 {
+			_ = json.Unmarshal(entry[2], &v.Errno)
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+func isJSONNull(raw json.RawMessage) bool {
+	raw = bytes.TrimSpace(raw)
+	return len(raw) == 0 || bytes.Equal(raw, []byte("null"))
+}
@@ -0,0 +1,105 @@
+package truenas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallReturnsTrueNASErrorForAValidationFailure(t *testing.T) {
+	client := NewWorkingClient("nas", "key", 443, true)
+	client.callFn = func(method string, _ interface{}, _ int64) (json.RawMessage, error) {
+		return json.RawMessage(`{"jsonrpc": "2.0", "id": 3, "error": {"code": -32001, "message": "Method call error", "data": {
+			"error": 22, "errname": "EINVAL",
+			"reason": "[EINVAL] vm_create.memory: Should be greater than or equal to 20\n",
+			"trace": {"class": "ValidationErrors", "formatted": "Traceback (most recent call last): ..."},
+			"extra": [["vm_create.memory", "Should be greater than or equal to 20", 22], ["vm_create.name", "Only alphanumeric characters are allowed", 22]]
+		}}}`), nil
+	}
+
+	_, err := client.CreateVM(map[string]interface{}{"name": "k8s-0", "memory": 1})
+	require.EqualError(t, err, "failed to create VM: vm.create failed: vm_create.memory: Should be greater than or equal to 20; vm_create.name: Only alphanumeric characters are allowed")
+	assert.True(t, IsValidationError(err))
+	assert.False(t, IsNotFound(err))
+	var tnErr *TrueNASError
+	require.True(t, errors.As(err, &tnErr))
+	assert.Equal(t, -32001, tnErr.Code)
+	assert.Equal(t, "EINVAL", tnErr.ErrName)
+	assert.Equal(t, "ValidationErrors", tnErr.Class)
+	assert.Equal(t, "Traceback (most recent call last): ...", tnErr.Trace)
+	assert.Equal(t, []ValidationError{
+		{Attribute: "vm_create.memory", Message: "Should be greater than or equal to 20", Errno: 22},
+		{Attribute: "vm_create.name", Message: "Only alphanumeric characters are allowed", Errno: 22},
+	}, tnErr.Validation)
+}
+
+func TestTrueNASErrorPredicates(t *testing.T) {
+	for name, tc := range map[string]struct {
+		body                    string
+		notFound, exists, valid bool
+		message                 string
+	}{
+		"legacy not found": {
+			body:     `{"msg": "result", "error": {"error": 2, "errname": "ENOENT", "type": null, "reason": "[ENOENT] VM 12 does not exist"}}`,
+			notFound: true, message: "vm.get_instance failed: [ENOENT] VM 12 does not exist",
+		},
+		"instance not found by validation errno": {
+			body:     `{"error": {"code": -32001, "message": "Method call error", "data": {"error": 22, "errname": "EINVAL", "reason": "[ENOENT] None: Vm 12 does not exist", "extra": [["None", "Vm 12 does not exist", 2]]}}}`,
+			notFound: true, valid: true, message: "vm.get_instance failed: None: Vm 12 does not exist",
+		},
+		"already exists": {
+			body:   `{"error": {"code": -32001, "message": "Method call error", "data": {"error": 22, "errname": "EINVAL", "reason": "[EEXIST] pool_dataset_create.name: Path flashstor/VM/k8s0-boot already exists", "extra": [["pool_dataset_create.name", "Path flashstor/VM/k8s0-boot already exists", 17]]}}}`,
+			exists: true, valid: true, message: "vm.get_instance failed: pool_dataset_create.name: Path flashstor/VM/k8s0-boot already exists",
+		},
+		"method not found": {
+			body:    `{"error": {"code": -32601, "message": "Method not found"}}`,
+			message: "vm.get_instance failed: Method not found",
+		},
+	} {
+		err := responseError("vm.get_instance", json.RawMessage(tc.body))
+		require.Error(t, err, name)
+		wrapped := fmt.Errorf("failed to get VM 12: %w", err)
+		assert.Equal(t, tc.notFound, IsNotFound(wrapped), name)
+		assert.Equal(t, tc.exists, IsAlreadyExists(wrapped), name)
+		assert.Equal(t, tc.valid, IsValidationError(wrapped), name)
+		assert.EqualError(t, err, tc.message, name)
+	}
+
+	assert.NoError(t, responseError("vm.query", json.RawMessage(`{"result": [], "error": null}`)))
+	assert.False(t, IsNotFound(errors.New("VM 12 does not exist")), "messages are not matched")
+}
+
+func TestFailedJobCarriesItsValidationErrors(t *testing.T) {
+	client, _ := jobClient(t, "vm.create", []Job{{State: JobFailed, Error: "[EINVAL] vm_create.cpu_model: Invalid choice\n",
+		ExcInfo: &JobExcInfo{Type: "VALIDATION", Errno: 22, Extra: json.RawMessage(`[["vm_create.cpu_model", "Invalid choice", 22]]`)}}})
+	_, err := client.WaitForJob(42, time.Minute)
+	require.EqualError(t, err, "vm.create job 42 failed: vm_create.cpu_model: Invalid choice")
+	require.True(t, IsValidationError(err))
+	var tnErr *TrueNASError
+	require.True(t, errors.As(err, &tnErr))
+	assert.Equal(t, "vm_create.cpu_model", tnErr.Validation[0].Attribute)
+}
+
+func TestRollBackSkipsResourcesThatAreAlreadyGone(t *testing.T) {
+	manager := NewVMManager("nas", "key", 443, true)
+	var deleted []string
+	manager.client.callFn = func(method string, params interface{}, _ int64) (json.RawMessage, error) {
+		deleted = append(deleted, method)
+		if method == "vm.delete" {
+			return json.RawMessage(`{"error": {"error": 2, "errname": "ENOENT", "reason": "[ENOENT] VM 9 does not exist"}}`), nil
+		}
+		return mustJSON(map[string]any{"result": true}), nil
+	}
+	created := &deployRollback{}
+	created.add(createdResource{kind: rollbackZVol, name: "flashstor/VM/k8s0-boot"})
+	created.add(createdResource{kind: rollbackVM, id: 9, name: "k8s0"})
+	cause := errors.New("failed to create VM devices")
+	err := manager.rollBack(created, VMConfig{Name: "k8s0"}, cause)
+	assert.Same(t, cause, err, "a VM that is already gone is not left behind")
+	assert.Equal(t, []string{"vm.delete", "pool.dataset.delete"}, deleted)
+}
@@ -0,0 +1,181 @@
+package truenas
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Some middleware methods (vm.stop, vm.restart, and on some releases
+// vm.create and pool.dataset.delete) run as jobs: the JSON-RPC result is a
+// bare job ID and the work carries on in the background. CallJob recognises
+// that shape and polls core.get_jobs until the job finishes, so a "stopped"
+// VM really is stopped when StopVM returns.
+
+// Job states reported by core.get_jobs.
+const (
+	JobWaiting = "WAITING"
+	JobRunning = "RUNNING"
+	JobSuccess = "SUCCESS"
+	JobFailed  = "FAILED"
+	JobAborted = "ABORTED"
+)
+
+// jobWaitTimeout bounds how long a job-style call waits for its job. vm.stop
+// alone may take the VM's shutdown_timeout (90s by default) before forcing.
+const jobWaitTimeout = 10 * time.Minute
+
+// jobPollInterval is the delay between core.get_jobs polls (shortened in
+// tests).
+var jobPollInterval = time.Second
+
+// Job is one middleware job as reported by core.get_jobs.
+type Job struct {
+	ID       int             `json:"id"`
+	Method   string          `json:"method"`
+	State    string          `json:"state"`
+	Progress JobProgress     `json:"progress"`
+	Result   json.RawMessage `json:"result"`
+	Error    string          `json:"error"`
+	ExcInfo  *JobExcInfo     `json:"exc_info"`
+}
+
+// JobExcInfo describes the exception a failed job raised; a validation
+// failure lists the rejected attributes in Extra.
+type JobExcInfo struct {
+	Type  string          `json:"type"`
+	Errno int             `json:"errno"`
+	Extra json.RawMessage `json:"extra"`
+}
+
+// JobProgress is a job's self-reported completion.
+type JobProgress struct {
+	Percent     float64 `json:"percent"`
+	Description string  `json:"description"`
+}
+
+// JobProgressFunc receives every job update that changes its state or
+// progress while a call waits for it.
+type JobProgressFunc func(Job)
+
+// Done reports whether the job has finished, successfully or not.
+func (j Job) Done() bool {
+	return j.State == JobSuccess || j.State == JobFailed || j.State == JobAborted
+}
+
+// ProgressText renders the job's progress for a spinner, e.g.
+// "vm.stop 40% (Stopping VM)".
+func (j Job) ProgressText() string {
+	text := fmt.Sprintf("%s %s%%", j.Method, strconv.FormatFloat(j.Progress.Percent, 'f', -1, 64))
+	if j.Progress.Description != "" {
+		text += " (" + j.Progress.Description + ")"
+	}
+	return text
+}
+
+// SetJobProgress registers fn to receive progress of every job the client
+// waits for; nil stops reporting.
+func (c *WorkingClient) SetJobProgress(fn JobProgressFunc) {
+	c.jobProgress = fn
+}
+
+// WaitForJob polls the job until it finishes or timeout elapses, bounded by
+// the client's context. A failed or aborted job is returned together with an
+// error wrapping its *TrueNASError.
+func (c *WorkingClient) WaitForJob(jobID int, timeout time.Duration) (*Job, error) {
+	return c.WaitForJobContext(c.baseContext(), jobID, timeout)
+}
+
+// WaitForJobContext is WaitForJob bounded by ctx.
+func (c *WorkingClient) WaitForJobContext(ctx context.Context, jobID int, timeout time.Duration) (*Job, error) {
+	deadline := time.Now().Add(timeout)
+	var last *Job
+	for {
+		job, err := c.queryJob(ctx, jobID)
+		if err != nil {
+			return nil, err
+		}
+		if c.jobProgress != nil && (last == nil || last.State != job.State || last.Progress != job.Progress) {
+			c.jobProgress(*job)
+		}
+		last = job
+		switch job.State {
+		case JobSuccess:
+			return job, nil
+		case JobFailed, JobAborted:
+			return job, fmt.Errorf("%s job %d %s: %w", job.Method, job.ID, strings.ToLower(job.State), jobError(job))
+		}
+		if !time.Now().Before(deadline) {
+			return job, fmt.Errorf("%s job %d still %s after %s", job.Method, job.ID, strings.ToLower(job.State), timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-time.After(jobPollInterval):
+		}
+	}
+}
+
+func (c *WorkingClient) queryJob(ctx context.Context, jobID int) (*Job, error) {
+	var jobs []Job
+	params := []interface{}{[]interface{}{[]interface{}{"id", "=", jobID}}}
+	if err := c.callResultContext(ctx, "core.get_jobs", params, 30, &jobs); err != nil {
+		return nil, fmt.Errorf("failed to query job %d: %w", jobID, err)
+	}
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("job %d not found", jobID)
+	}
+	return &jobs[0], nil
+}
+
+// CallJob is Call for methods that may run as a job: when the result is a
+// bare job ID it waits for the job and returns a response whose result is the
+// job's result, so callers decode it exactly as a synchronous reply.
+func (c *WorkingClient) CallJob(method string, params interface{}, timeoutSeconds int64) (json.RawMessage, error) {
+	return c.CallJobContext(c.baseContext(), method, params, timeoutSeconds)
+}
+
+// CallJobContext is CallJob bounded by ctx.
+func (c *WorkingClient) CallJobContext(ctx context.Context, method string, params interface{}, timeoutSeconds int64) (json.RawMessage, error) {
+	raw, err := c.CallContext(ctx, method, params, timeoutSeconds)
+	if err != nil {
+		return nil, err
+	}
+	jobID, ok := jobIDFromResponse(raw)
+	if !ok {
+		return raw, nil
+	}
+	job, err := c.WaitForJobContext(ctx, jobID, jobWaitTimeout)
+	if err != nil {
+		return nil, err
+	}
+	result := job.Result
+	if len(result) == 0 {
+		result = json.RawMessage("null")
+	}
+	return json.Marshal(map[string]json.RawMessage{"result": result})
+}
+
+// jobIDFromResponse extracts the job ID from a response whose result is a
+// bare integer.
+func jobIDFromResponse(raw json.RawMessage) (int, bool) {
+	var envelope struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return 0, false
+	}
+	result := bytes.TrimSpace(envelope.Result)
+	if len(result) == 0 || result[0] < '0' || result[0] > '9' {
+		return 0, false
+	}
+	id, err := strconv.Atoi(string(result))
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
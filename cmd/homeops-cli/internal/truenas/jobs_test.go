@@ -0,0 +1,112 @@
+package truenas
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jobClient answers method with job 42 and core.get_jobs with the next of
+// states (repeating the last one).
+func jobClient(t *testing.T, method string, states []Job) (*WorkingClient, *int) {
+	t.Helper()
+	shortJobPoll(t)
+	client := NewWorkingClient("nas", "key", 443, true)
+	polls := 0
+	client.callFn = func(m string, params interface{}, _ int64) (json.RawMessage, error) {
+		switch m {
+		case method:
+			return mustJSON(map[string]interface{}{"result": 42}), nil
+		case "core.get_jobs":
+			assert.Equal(t, []interface{}{[]interface{}{[]interface{}{"id", "=", 42}}}, params)
+			job := states[min(polls, len(states)-1)]
+			polls++
+			job.ID, job.Method = 42, method
+			return mustJSON(map[string]interface{}{"result": []Job{job}}), nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", m)
+	}
+	return client, &polls
+}
+
+func shortJobPoll(t *testing.T) {
+	old := jobPollInterval
+	jobPollInterval = time.Millisecond
+	t.Cleanup(func() { jobPollInterval = old })
+}
+
+func TestStopVMWaitsForTheStopJob(t *testing.T) {
+	client, polls := jobClient(t, "vm.stop", []Job{
+		{State: JobRunning, Progress: JobProgress{Percent: 10, Description: "Stopping VM"}},
+		{State: JobRunning, Progress: JobProgress{Percent: 10, Description: "Stopping VM"}},
+		{State: JobRunning, Progress: JobProgress{Percent: 80, Description: "Waiting for shutdown"}},
+		{State: JobSuccess, Progress: JobProgress{Percent: 100}},
+	})
+	var updates []string
+	client.SetJobProgress(func(job Job) { updates = append(updates, job.ProgressText()) })
+
+	require.NoError(t, client.StopVM(7))
+	assert.Equal(t, 4, *polls, "StopVM returns only once the job has succeeded")
+	assert.Equal(t, []string{"vm.stop 10% (Stopping VM)", "vm.stop 80% (Waiting for shutdown)", "vm.stop 100%"}, updates,
+		"unchanged polls are not reported again")
+}
+
+func TestCallJobReturnsTheJobResult(t *testing.T) {
+	client, _ := jobClient(t, "vm.create", []Job{{State: JobSuccess, Result: json.RawMessage(`{"id": 9, "name": "vm9"}`)}})
+	vm, err := client.CreateVM(map[string]interface{}{"name": "vm9"})
+	require.NoError(t, err)
+	assert.Equal(t, 9, vm.ID)
+}
+
+func TestCallJobPassesSynchronousResultsThrough(t *testing.T) {
+	client := NewWorkingClient("nas", "key", 443, true)
+	client.callFn = func(m string, _ interface{}, _ int64) (json.RawMessage, error) {
+		require.NotEqual(t, "core.get_jobs", m)
+		return mustJSON(map[string]interface{}{"result": true}), nil
+	}
+	require.NoError(t, client.DeleteDataset("flashstor/VM/vm1", true))
+	raw, err := client.CallJob("vm.stop", []interface{}{1}, 60)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"result": true}`, string(raw))
+}
+
+func TestWaitForJobFailures(t *testing.T) {
+	client, _ := jobClient(t, "vm.stop", []Job{{State: JobFailed, Error: "[EFAULT] VM is locked"}})
+	job, err := client.WaitForJob(42, time.Minute)
+	require.ErrorContains(t, err, "vm.stop job 42 failed: [EFAULT] VM is locked")
+	assert.Equal(t, JobFailed, job.State)
+	require.ErrorContains(t, client.StopVM(7), "VM is locked")
+
+	client, _ = jobClient(t, "vm.stop", []Job{{State: JobRunning}})
+	_, err = client.WaitForJob(42, 5*time.Millisecond)
+	require.ErrorContains(t, err, "vm.stop job 42 still running after 5ms")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client.SetJobProgress(func(Job) { cancel() })
+	_, err = client.WaitForJobContext(ctx, 42, time.Minute)
+	require.ErrorIs(t, err, context.Canceled)
+
+	client = NewWorkingClient("nas", "key", 443, true)
+	client.callFn = func(string, interface{}, int64) (json.RawMessage, error) {
+		return mustJSON(map[string]interface{}{"result": []Job{}}), nil
+	}
+	_, err = client.WaitForJob(42, time.Minute)
+	require.ErrorContains(t, err, "job 42 not found")
+}
+
+func TestJobIDFromResponse(t *testing.T) {
+	for raw, want := range map[string]int{`{"result": 42}`: 42, `{"result": 7 }`: 7} {
+		id, ok := jobIDFromResponse(json.RawMessage(raw))
+		assert.True(t, ok, raw)
+		assert.Equal(t, want, id)
+	}
+	for _, raw := range []string{`{"result": true}`, `{"result": null}`, `{"result": {"id": 1}}`, `{"result": 1.5}`, `{}`, `not json`} {
+		_, ok := jobIDFromResponse(json.RawMessage(raw))
+		assert.False(t, ok, raw)
+	}
+}
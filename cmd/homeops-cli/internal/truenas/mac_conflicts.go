@@ -0,0 +1,110 @@
+package truenas
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Two VMs sharing a NIC MAC on one bridge take turns losing their traffic,
+// so DeployVM checks the MACs it is about to attach against every NIC on the
+// NAS before creating anything. vm.query already returns each VM's devices,
+// so this costs no extra call. A MAC the operator gave that another VM uses
+// fails the deploy; a random one is rerolled until it is unused.
+
+// maxMACAttempts bounds rerolling a random MAC that is already taken.
+const maxMACAttempts = 32
+
+// nicMACOwners maps each NIC MAC on the NAS to the VM using it, skipping the
+// VM named self (an --update keeps its own MACs).
+func nicMACOwners(vms []VM, self string) map[string]string {
+	owners := map[string]string{}
+	for _, vmItem := range vms {
+		if vmItem.Name == self {
+			continue
+		}
+		for _, device := range vmItem.Devices {
+			attributes, _ := device["attributes"].(map[string]interface{})
+			if attributes["dtype"] != "NIC" {
+				continue
+			}
+			if mac, _ := attributes["mac"].(string); mac != "" {
+				owners[normalizeMAC(mac)] = vmItem.Name
+			}
+		}
+	}
+	return owners
+}
+
+// checkNICMACs fails when a MAC config asks for is used by another VM, or
+// by two of config's own NICs.
+func checkNICMACs(config VMConfig, owners map[string]string) error {
+	seen := map[string]bool{}
+	for _, nic := range configNICMACs(&config) {
+		if *nic == "" {
+			continue
+		}
+		mac := normalizeMAC(*nic)
+		if owner, ok := owners[mac]; ok {
+			return fmt.Errorf("MAC address %s is already in use by VM %s; choose another --mac-address (or nics[].mac in the spec)", *nic, owner)
+		}
+		if seen[mac] {
+			return fmt.Errorf("MAC address %s is given to more than one NIC of VM %s", *nic, config.Name)
+		}
+		seen[mac] = true
+	}
+	return nil
+}
+
+// assignNICMACs gives every NIC of config without a MAC a random one that
+// no VM on the NAS uses, so createVMDevices never rolls a taken address.
+func (vm *VMManager) assignNICMACs(config *VMConfig, owners map[string]string) error {
+	config.NICs = append([]VMNIC(nil), config.NICs...) // the caller's layout is left as given
+	taken := make(map[string]bool, len(owners))
+	for mac := range owners {
+		taken[mac] = true
+	}
+	for _, nic := range configNICMACs(config) {
+		if *nic != "" {
+			taken[normalizeMAC(*nic)] = true
+		}
+	}
+	for _, nic := range configNICMACs(config) {
+		if *nic != "" {
+			continue
+		}
+		for attempt := 0; ; attempt++ {
+			if attempt == maxMACAttempts {
+				return fmt.Errorf("could not generate a MAC address unused on the NAS after %d attempts", maxMACAttempts)
+			}
+			mac := vm.generateRandomMAC()
+			if !taken[mac] {
+				taken[mac] = true
+				*nic = mac
+				break
+			}
+			vm.logger.Debug("Random MAC %s is already in use by VM %s; generating another", mac, owners[mac])
+		}
+	}
+	return nil
+}
+
+// configNICMACs points at the MAC of each NIC config attaches: the
+// VMConfig.NICs layout, or MacAddress for the default single NIC.
+func configNICMACs(config *VMConfig) []*string {
+	if len(config.NICs) == 0 {
+		return []*string{&config.MacAddress}
+	}
+	macs := make([]*string, len(config.NICs))
+	for i := range config.NICs {
+		macs[i] = &config.NICs[i].MAC
+	}
+	return macs
+}
+
+func normalizeMAC(mac string) string {
+	if hw, err := net.ParseMAC(mac); err == nil {
+		return hw.String()
+	}
+	return strings.ToLower(mac)
+}
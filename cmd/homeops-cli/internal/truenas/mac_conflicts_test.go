@@ -0,0 +1,85 @@
+package truenas
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// macNAS fakes a NAS whose only other VM, cp_1, has a NIC with MAC
+// 00:0c:29:aa:bb:cc. It returns the manager and the NIC MACs created.
+func macNAS(t *testing.T) (*VMManager, *[]string) {
+	t.Helper()
+	manager := NewVMManager("nas", "key", 443, true)
+	var macs []string
+	manager.client.callFn = func(method string, params interface{}, _ int64) (json.RawMessage, error) {
+		args, _ := params.([]interface{})
+		switch method {
+		case "vm.query":
+			return mustJSON(map[string]any{"result": []map[string]any{{
+				"id": 3, "name": "cp_1",
+				"devices": []map[string]any{
+					{"id": 30, "order": 1002, "attributes": map[string]any{"dtype": "NIC", "mac": "00:0C:29:AA:BB:CC"}},
+					{"id": 31, "order": 1001, "attributes": map[string]any{"dtype": "DISK", "path": "/dev/zvol/flashstor/VM/cp_1-boot"}},
+				},
+			}}}), nil
+		case "pool.dataset.query":
+			return mustJSON(map[string]any{"result": []map[string]any{{"name": "flashstor"}, {"name": "flashstor/VM"}}}), nil
+		case "pool.dataset.create", "vm.create":
+			return mustJSON(map[string]any{"result": map[string]any{"id": 7}}), nil
+		case "vm.device.create":
+			attrs := args[0].(map[string]interface{})["attributes"].(map[string]interface{})
+			if attrs["dtype"] == "NIC" {
+				macs = append(macs, attrs["mac"].(string))
+			}
+			return mustJSON(map[string]any{"result": map[string]any{"id": 70}}), nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	}
+	return manager, &macs
+}
+
+var macConfig = VMConfig{
+	Name: "cp_0", Memory: 8192, VCPUs: 4, DiskSize: 250, OpenEBSSize: 1000,
+	StoragePool: "flashstor", NetworkBridge: "br0", TalosISO: "/isos/talos.iso",
+}
+
+func TestDeployVMRefusesAMACInUseByAnotherVM(t *testing.T) {
+	manager, macs := macNAS(t)
+	config := macConfig
+	config.MacAddress = "00:0c:29:aa:bb:cc"
+
+	err := manager.DeployVM(config)
+	require.EqualError(t, err, "MAC address 00:0c:29:aa:bb:cc is already in use by VM cp_1; choose another --mac-address (or nics[].mac in the spec)")
+	assert.Empty(t, *macs)
+
+	config.MacAddress = ""
+	config.NICs = []VMNIC{{Bridge: "br0", MAC: "00:0c:29:00:00:01", Model: "VIRTIO", Order: 1002}, {Bridge: "br1", MAC: "00:0C:29:00:00:01", Model: "VIRTIO", Order: 1021}}
+	require.EqualError(t, manager.DeployVM(config), "MAC address 00:0C:29:00:00:01 is given to more than one NIC of VM cp_0")
+}
+
+func TestDeployVMRerollsARandomMACThatIsTaken(t *testing.T) {
+	manager, macs := macNAS(t)
+	rolls := [][]byte{{0xaa, 0xbb, 0xcc}, {0x01, 0x02, 0x03}}
+	orig := randomBytes
+	t.Cleanup(func() { randomBytes = orig })
+	randomBytes = func(buf []byte) error {
+		if len(buf) == 3 && len(rolls) > 0 {
+			copy(buf, rolls[0])
+			rolls = rolls[1:]
+		}
+		return nil
+	}
+
+	require.NoError(t, manager.DeployVM(macConfig))
+	assert.Equal(t, []string{"00:0c:29:01:02:03"}, *macs)
+}
+
+func TestNICMACOwnersSkipsTheVMBeingUpdated(t *testing.T) {
+	vms := []VM{{Name: "cp_0", Devices: []VMDevice{{"attributes": map[string]interface{}{"dtype": "NIC", "mac": "00:0c:29:00:00:01"}}}}}
+	assert.Empty(t, nicMACOwners(vms, "cp_0"))
+	assert.Equal(t, map[string]string{"00:0c:29:00:00:01": "cp_0"}, nicMACOwners(vms, "cp_1"))
+}
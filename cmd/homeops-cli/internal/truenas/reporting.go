@@ -0,0 +1,349 @@
+package truenas
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Per-VM resource usage for `vm truenas usage`, read from the middleware's
+// reporting plugin. SCALE 23.10 replaced the collectd/RRD backend with
+// netdata and renamed the methods; both report a graph list with
+// per-graph identifiers and a data call returning a legend plus sample rows.
+
+// minNetdataReportingVersion is the first SCALE release whose reporting is
+// backed by netdata (reporting.netdata_*).
+var minNetdataReportingVersion = MiddlewareVersion{Major: 23, Minor: 10}
+
+// reportingAPI names the methods and units of one reporting backend.
+type reportingAPI struct {
+	graphsMethod string
+	dataMethod   string
+	// memoryScale and diskScale convert reported values to bytes and
+	// bytes per second: netdata charts are MiB and KiB/s, RRD is raw.
+	memoryScale float64
+	diskScale   float64
+}
+
+func reportingAPIFor(v MiddlewareVersion) reportingAPI {
+	if v.AtLeast(minNetdataReportingVersion) {
+		return reportingAPI{graphsMethod: "reporting.netdata_graphs", dataMethod: "reporting.netdata_get_data", memoryScale: 1 << 20, diskScale: 1 << 10}
+	}
+	return reportingAPI{graphsMethod: "reporting.graphs", dataMethod: "reporting.get_data", memoryScale: 1, diskScale: 1}
+}
+
+// ReportingGraph is one entry of the graph list.
+type ReportingGraph struct {
+	Name        string   `json:"name"`
+	Title       string   `json:"title"`
+	Identifiers []string `json:"identifiers"`
+}
+
+// ReportingSeries is one graph's data. Netdata rows lead with a timestamp
+// column named "time" in the legend; RRD rows carry only values. Missing
+// samples are null.
+type ReportingSeries struct {
+	Name       string       `json:"name"`
+	Identifier string       `json:"identifier"`
+	Legend     []string     `json:"legend"`
+	Data       [][]*float64 `json:"data"`
+}
+
+type reportingGraphQuery struct {
+	Name       string `json:"name"`
+	Identifier string `json:"identifier,omitempty"`
+}
+
+// UsageSample is a metric's latest sample and its mean over the window.
+type UsageSample struct {
+	Current float64 `json:"current" yaml:"current"`
+	Average float64 `json:"average" yaml:"average"`
+}
+
+// SummarizeSeries reduces a series to its latest and mean value. Each row's
+// value is the sum of the columns keep accepts, taken as magnitudes because
+// netdata reports writes as negative; rows where every kept column is null
+// are skipped. The result is nil when no row has data.
+func SummarizeSeries(series ReportingSeries, keep func(column string) bool, scale float64) *UsageSample {
+	offset := 0
+	if len(series.Legend) > 0 && strings.EqualFold(series.Legend[0], "time") {
+		offset = 1
+	}
+	var columns []int
+	for i := offset; i < len(series.Legend); i++ {
+		if keep(strings.ToLower(series.Legend[i])) {
+			columns = append(columns, i)
+		}
+	}
+	var (
+		total   float64
+		samples int
+		current float64
+	)
+	for _, row := range series.Data {
+		var sum float64
+		seen := false
+		for _, column := range columns {
+			if column < len(row) && row[column] != nil {
+				sum += math.Abs(*row[column])
+				seen = true
+			}
+		}
+		if !seen {
+			continue
+		}
+		total += sum
+		samples++
+		current = sum
+	}
+	if samples == 0 {
+		return nil
+	}
+	return &UsageSample{Current: current * scale, Average: total / float64(samples) * scale}
+}
+
+func addUsage(a, b *UsageSample) *UsageSample {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	return &UsageSample{Current: a.Current + b.Current, Average: a.Average + b.Average}
+}
+
+func anyColumn(string) bool { return true }
+
+func memoryColumn(column string) bool {
+	for _, name := range []string{"used", "rss", "ram", "mem", "usage"} {
+		if strings.Contains(column, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func readColumn(column string) bool  { return strings.Contains(column, "read") }
+func writeColumn(column string) bool { return strings.Contains(column, "write") }
+
+// summarizeMemory prefers the used/RSS column and falls back to the sum of
+// every column for charts with an unrecognised legend.
+func summarizeMemory(series ReportingSeries, scale float64) *UsageSample {
+	if sample := SummarizeSeries(series, memoryColumn, scale); sample != nil {
+		return sample
+	}
+	return SummarizeSeries(series, anyColumn, scale)
+}
+
+// VMUsage is one VM's row in the usage report. Samples are nil when the NAS
+// has no graph for them (stopped VM, reporting disabled, or an unmapped zvol).
+type VMUsage struct {
+	Name             string       `json:"name" yaml:"name"`
+	State            string       `json:"state" yaml:"state"`
+	VCPUs            int          `json:"vcpus" yaml:"vcpus"`
+	MemoryBytes      int64        `json:"memory_bytes" yaml:"memory_bytes"`
+	ZVols            []string     `json:"zvols" yaml:"zvols"`
+	CPUPercent       *UsageSample `json:"cpu_percent" yaml:"cpu_percent"`
+	HostMemoryBytes  *UsageSample `json:"host_memory_bytes" yaml:"host_memory_bytes"`
+	ReadBytesPerSec  *UsageSample `json:"read_bytes_per_sec" yaml:"read_bytes_per_sec"`
+	WriteBytesPerSec *UsageSample `json:"write_bytes_per_sec" yaml:"write_bytes_per_sec"`
+	UnmappedZVols    []string     `json:"unmapped_zvols,omitempty" yaml:"unmapped_zvols,omitempty"`
+	reportingDomain  string
+}
+
+// VMUsageReport covers every homeops-managed VM on the NAS.
+type VMUsageReport struct {
+	Source        string    `json:"source" yaml:"source"`
+	WindowSeconds int       `json:"window_seconds" yaml:"window_seconds"`
+	End           time.Time `json:"end" yaml:"end"`
+	// PhysicalMemoryBytes is the NAS RAM and CommittedMemoryBytes the
+	// configured memory of its running VMs, homeops-managed or not.
+	PhysicalMemoryBytes  int64     `json:"physical_memory_bytes" yaml:"physical_memory_bytes"`
+	CommittedMemoryBytes int64     `json:"committed_memory_bytes" yaml:"committed_memory_bytes"`
+	VMs                  []VMUsage `json:"vms" yaml:"vms"`
+}
+
+// zvolDeviceCommand resolves each /dev/zvol link to its zd device, which is
+// how the reporting disk graphs name zvols.
+const zvolDeviceCommand = "readlink -f"
+
+// ResolveZVolDevices maps zvol dataset paths to their zd device names (e.g.
+// "zd16") from `readlink -f /dev/zvol/<dataset>...` output, one line per
+// argument in order.
+func ResolveZVolDevices(zvols []string, out string) map[string]string {
+	devices := map[string]string{}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	for i, zvol := range zvols {
+		if i >= len(lines) {
+			break
+		}
+		device := path.Base(strings.TrimSpace(lines[i]))
+		if strings.HasPrefix(device, "zd") {
+			devices[zvol] = device
+		}
+	}
+	return devices
+}
+
+// VMUsage reports CPU, memory, and zvol throughput for the homeops-managed
+// VMs over the window ending at end. VM graphs are found by the libvirt
+// domain name (<id>_<name>) in their identifiers; disk graphs by the zvol's
+// zd device (resolved over SSH when sshExec is set) or its dataset path.
+func (vm *VMManager) VMUsage(sshExec SSHRunner, end time.Time, window time.Duration) (VMUsageReport, error) {
+	if window <= 0 {
+		return VMUsageReport{}, fmt.Errorf("usage window must be positive, got %s", window)
+	}
+	version, err := vm.MiddlewareVersion()
+	if err != nil {
+		return VMUsageReport{}, fmt.Errorf("failed to detect TrueNAS version: %w", err)
+	}
+	api := reportingAPIFor(version)
+	report := VMUsageReport{Source: api.dataMethod, WindowSeconds: int(window.Seconds()), End: end.UTC()}
+
+	var info struct {
+		PhysMem int64 `json:"physmem"`
+	}
+	if err := vm.client.callResult("system.info", []interface{}{}, 30, &info); err != nil {
+		return VMUsageReport{}, fmt.Errorf("failed to query system.info: %w", err)
+	}
+	report.PhysicalMemoryBytes = info.PhysMem
+
+	vms, err := vm.client.QueryVMs(nil)
+	if err != nil {
+		return VMUsageReport{}, fmt.Errorf("failed to query VMs: %w", err)
+	}
+	sort.Slice(vms, func(i, j int) bool { return vms[i].Name < vms[j].Name })
+	var allZVols []string
+	for i := range vms {
+		v := &vms[i]
+		if vmIsRunning(v) {
+			report.CommittedMemoryBytes += int64(v.Memory) << 20
+		}
+		if !isHomeopsManaged(*v) {
+			continue
+		}
+		state, _ := v.Status["state"].(string)
+		usage := VMUsage{
+			Name: v.Name, State: state, VCPUs: v.VCPUs * max(v.Cores, 1) * max(v.Threads, 1),
+			MemoryBytes: int64(v.Memory) << 20, reportingDomain: fmt.Sprintf("%d_%s", v.ID, v.Name),
+		}
+		devices, err := vm.client.QueryVMDevices(v.ID)
+		if err != nil {
+			return VMUsageReport{}, fmt.Errorf("failed to query devices of VM %s: %w", v.Name, err)
+		}
+		for _, device := range devices {
+			if zvol, ok := extractZVolPathFromDevice(device); ok {
+				usage.ZVols = append(usage.ZVols, zvol)
+			}
+		}
+		usage.ZVols = uniqueSortedStrings(usage.ZVols)
+		allZVols = append(allZVols, usage.ZVols...)
+		report.VMs = append(report.VMs, usage)
+	}
+	if len(report.VMs) == 0 {
+		return report, nil
+	}
+
+	devices := map[string]string{}
+	if sshExec != nil && len(allZVols) > 0 {
+		links := make([]string, 0, len(allZVols))
+		for _, zvol := range allZVols {
+			links = append(links, "/dev/zvol/"+zvol)
+		}
+		if out, err := sshExec.ExecuteCommand(zvolDeviceCommand + " " + strings.Join(links, " ")); err != nil {
+			vm.logger.Warn("Could not resolve zvol devices over SSH (%v); matching disk graphs by dataset path only", err)
+		} else {
+			devices = ResolveZVolDevices(allZVols, out)
+		}
+	}
+
+	var graphs []ReportingGraph
+	if err := vm.client.callResult(api.graphsMethod, []interface{}{}, 60, &graphs); err != nil {
+		return VMUsageReport{}, fmt.Errorf("failed to list reporting graphs: %w", err)
+	}
+	type wanted struct {
+		vm     int
+		metric string
+		zvol   string
+	}
+	var queries []reportingGraphQuery
+	var targets []wanted
+	for i := range report.VMs {
+		usage := &report.VMs[i]
+		if !strings.EqualFold(usage.State, "RUNNING") {
+			continue
+		}
+		mapped := map[string]bool{}
+		for _, graph := range graphs {
+			name := strings.ToLower(graph.Name)
+			for _, identifier := range graph.Identifiers {
+				switch {
+				case strings.Contains(identifier, usage.reportingDomain) && strings.Contains(name, "cpu"):
+					targets = append(targets, wanted{vm: i, metric: "cpu"})
+				case strings.Contains(identifier, usage.reportingDomain) && strings.Contains(name, "mem"):
+					targets = append(targets, wanted{vm: i, metric: "memory"})
+				case strings.Contains(name, "disk"):
+					zvol, ok := zvolForIdentifier(identifier, usage.ZVols, devices)
+					if !ok {
+						continue
+					}
+					mapped[zvol] = true
+					targets = append(targets, wanted{vm: i, metric: "disk", zvol: zvol})
+				default:
+					continue
+				}
+				queries = append(queries, reportingGraphQuery{Name: graph.Name, Identifier: identifier})
+			}
+		}
+		for _, zvol := range usage.ZVols {
+			if !mapped[zvol] {
+				usage.UnmappedZVols = append(usage.UnmappedZVols, zvol)
+			}
+		}
+	}
+	if len(queries) == 0 {
+		return report, nil
+	}
+
+	query := map[string]interface{}{"start": end.Add(-window).Unix(), "end": end.Unix(), "aggregate": false}
+	var series []ReportingSeries
+	if err := vm.client.callResult(api.dataMethod, []interface{}{queries, query}, 120, &series); err != nil {
+		return VMUsageReport{}, fmt.Errorf("failed to read reporting data: %w", err)
+	}
+	if len(series) != len(queries) {
+		return VMUsageReport{}, fmt.Errorf("%s returned %d series for %d graphs", api.dataMethod, len(series), len(queries))
+	}
+	for i, target := range targets {
+		usage := &report.VMs[target.vm]
+		switch target.metric {
+		case "cpu":
+			// Charts count one busy core as 100%; report against all vCPUs.
+			if sample := SummarizeSeries(series[i], anyColumn, 1/float64(max(usage.VCPUs, 1))); sample != nil {
+				usage.CPUPercent = sample
+			}
+		case "memory":
+			usage.HostMemoryBytes = summarizeMemory(series[i], api.memoryScale)
+		case "disk":
+			usage.ReadBytesPerSec = addUsage(usage.ReadBytesPerSec, SummarizeSeries(series[i], readColumn, api.diskScale))
+			usage.WriteBytesPerSec = addUsage(usage.WriteBytesPerSec, SummarizeSeries(series[i], writeColumn, api.diskScale))
+		}
+	}
+	return report, nil
+}
+
+// zvolForIdentifier matches a disk graph identifier against a VM's zvols,
+// by zd device name or by dataset path.
+func zvolForIdentifier(identifier string, zvols []string, devices map[string]string) (string, bool) {
+	for _, zvol := range zvols {
+		if device, ok := devices[zvol]; ok && (identifier == device || strings.HasSuffix(identifier, "/"+device)) {
+			return zvol, true
+		}
+		if identifier == zvol || strings.HasSuffix(identifier, "zvol/"+zvol) {
+			return zvol, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,151 @@
+package truenas
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var reportingTestEnd = time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+
+// reportingFixture reads testdata/reporting/<name>.json.
+func reportingFixture(t *testing.T, name string) json.RawMessage {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", "reporting", name+".json"))
+	require.NoError(t, err)
+	return raw
+}
+
+// reportingTestManager serves the reporting fixtures for a NAS running
+// version, answering the graph and data calls from <backend>-graphs/-data.
+func reportingTestManager(t *testing.T, version, backend string) (*VMManager, *[]recordedCall) {
+	t.Helper()
+	var devices map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(reportingFixture(t, "devices"), &devices))
+	result := func(raw json.RawMessage) json.RawMessage {
+		return json.RawMessage(`{"result":` + string(raw) + `}`)
+	}
+	manager := NewVMManager("nas", "key", 443, true)
+	calls := &[]recordedCall{}
+	manager.client.callFn = func(method string, params interface{}, timeoutSeconds int64) (json.RawMessage, error) {
+		*calls = append(*calls, recordedCall{method, params})
+		switch method {
+		case "system.version":
+			return mustJSON(map[string]any{"result": version}), nil
+		case "system.info":
+			return mustJSON(map[string]any{"result": map[string]any{"physmem": int64(64) << 30}}), nil
+		case "vm.query":
+			return result(reportingFixture(t, "vms")), nil
+		case "vm.device.query":
+			id := params.([]interface{})[0].([]interface{})[0].([]interface{})[2].(int)
+			if raw, ok := devices[strconv.Itoa(id)]; ok {
+				return result(raw), nil
+			}
+			return mustJSON(map[string]any{"result": []any{}}), nil
+		case "reporting.netdata_graphs", "reporting.graphs":
+			return result(reportingFixture(t, backend+"-graphs")), nil
+		case "reporting.netdata_get_data", "reporting.get_data":
+			return result(reportingFixture(t, backend+"-data")), nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	}
+	return manager, calls
+}
+
+// readlinkSSH answers the zvol device lookup with out.
+type readlinkSSH struct {
+	fakeSSHRunner
+	out string
+}
+
+func (r *readlinkSSH) ExecuteCommand(command string) (string, error) {
+	_, _ = r.fakeSSHRunner.ExecuteCommand(command)
+	return r.out, nil
+}
+
+func TestSummarizeSeries(t *testing.T) {
+	value := func(v float64) *float64 { return &v }
+	series := ReportingSeries{
+		Legend: []string{"time", "reads", "writes"},
+		Data: [][]*float64{
+			{value(1), value(10), value(-30)},
+			{value(2), nil, nil},
+			{value(3), value(20), nil},
+		},
+	}
+	assert.Equal(t, &UsageSample{Current: 20, Average: 15}, SummarizeSeries(series, readColumn, 1))
+	assert.Equal(t, &UsageSample{Current: 30 * 1024, Average: 30 * 1024}, SummarizeSeries(series, writeColumn, 1024),
+		"netdata writes are negative; rows without a write sample do not count")
+	assert.Equal(t, &UsageSample{Current: 20, Average: 30}, SummarizeSeries(series, anyColumn, 1), "the time column is never summed")
+	assert.Nil(t, SummarizeSeries(series, func(string) bool { return false }, 1))
+
+	rrd := ReportingSeries{Legend: []string{"free", "total"}, Data: [][]*float64{{value(1), value(3)}, {value(3), value(5)}}}
+	assert.Equal(t, &UsageSample{Current: 8, Average: 6}, summarizeMemory(rrd, 1), "no used/rss column: every column counts")
+}
+
+func TestVMUsageNetdata(t *testing.T) {
+	manager, calls := reportingTestManager(t, "TrueNAS-SCALE-24.10.2", "netdata")
+	ssh := &readlinkSSH{out: "/dev/zd0\n/dev/zd16\n/dev/zd32\n"}
+	report, err := manager.VMUsage(ssh, reportingTestEnd, 5*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"readlink -f /dev/zvol/flashstor/VM/k8s0-boot /dev/zvol/flashstor/VM/k8s0-openebs /dev/zvol/flashstor/VM/k8s1-boot"}, ssh.commands)
+
+	assert.Equal(t, "reporting.netdata_get_data", report.Source)
+	assert.Equal(t, int64(64)<<30, report.PhysicalMemoryBytes)
+	assert.Equal(t, int64(24)<<30, report.CommittedMemoryBytes, "running VMs count, managed or not; stopped k8s1 does not")
+	require.Len(t, report.VMs, 2, "plex is not homeops-managed")
+
+	k8s0 := report.VMs[0]
+	assert.Equal(t, "k8s0", k8s0.Name)
+	assert.Equal(t, &UsageSample{Current: 45, Average: 37.5}, k8s0.CPUPercent, "user+system over 4 vCPUs")
+	assert.Equal(t, &UsageSample{Current: 12 << 30, Average: 10 << 30}, k8s0.HostMemoryBytes, "rss in MiB; cache ignored")
+	assert.Equal(t, &UsageSample{Current: 2048 << 10, Average: 1536 << 10}, k8s0.ReadBytesPerSec)
+	assert.Equal(t, &UsageSample{Current: 5120 << 10, Average: 4096 << 10}, k8s0.WriteBytesPerSec, "both zvols summed")
+	assert.Empty(t, k8s0.UnmappedZVols)
+
+	k8s1 := report.VMs[1]
+	assert.Equal(t, "STOPPED", k8s1.State)
+	assert.Nil(t, k8s1.CPUPercent)
+	assert.Nil(t, k8s1.ReadBytesPerSec)
+
+	data := methodCalls(*calls, "reporting.netdata_get_data")
+	require.Len(t, data, 1, "every graph is read in one call")
+	args := data[0].params.([]interface{})
+	assert.Equal(t, []reportingGraphQuery{
+		{Name: "vm_cpu", Identifier: "1_k8s0"},
+		{Name: "vm_memory", Identifier: "1_k8s0"},
+		{Name: "disk", Identifier: "zd0"},
+		{Name: "disk", Identifier: "zd16"},
+	}, args[0])
+	assert.Equal(t, map[string]interface{}{"start": reportingTestEnd.Add(-5 * time.Minute).Unix(), "end": reportingTestEnd.Unix(), "aggregate": false}, args[1])
+}
+
+func TestVMUsageRRDWithoutSSH(t *testing.T) {
+	manager, calls := reportingTestManager(t, "TrueNAS-SCALE-22.12.4", "rrd")
+	report, err := manager.VMUsage(nil, reportingTestEnd, time.Minute)
+	require.NoError(t, err)
+
+	assert.Equal(t, "reporting.get_data", report.Source)
+	assert.Len(t, methodCalls(*calls, "reporting.graphs"), 1)
+	k8s0 := report.VMs[0]
+	assert.Nil(t, k8s0.CPUPercent, "no CPU graph names the domain")
+	assert.Equal(t, &UsageSample{Current: 10 << 30, Average: 9 << 30}, k8s0.HostMemoryBytes, "RRD reports bytes")
+	assert.Equal(t, &UsageSample{Current: 4096, Average: 4096}, k8s0.ReadBytesPerSec)
+	assert.Equal(t, []string{"flashstor/VM/k8s0-openebs"}, k8s0.UnmappedZVols, "without SSH only dataset-path identifiers match")
+
+	_, err = manager.VMUsage(nil, reportingTestEnd, 0)
+	require.ErrorContains(t, err, "usage window must be positive")
+}
+
+func TestResolveZVolDevices(t *testing.T) {
+	assert.Equal(t, map[string]string{"pool/a": "zd0", "pool/c": "zd32"},
+		ResolveZVolDevices([]string{"pool/a", "pool/b", "pool/c"}, "/dev/zd0\n/dev/zvol/pool/b\n/dev/zd32\n"),
+		"unresolved links are left out")
+}
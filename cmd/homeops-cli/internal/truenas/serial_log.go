@@ -0,0 +1,162 @@
+package truenas
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Serial console logging gives a VM a second serial port whose output qemu
+// appends to a file on the NAS, so boot failures that happen before the
+// network (and SSH/talosctl) come up can be read back as text instead of
+// squinting at the SPICE console.
+//
+// The middleware has no serial device type in vm.device.create, so the port
+// is attached the same way Flatcar's Ignition is: through the VM's
+// command_line_args, which TrueNAS passes to qemu verbatim. That field is only
+// honoured from SCALE 24.04 on, hence the version gate.
+
+// SerialLogDirName is the directory under the pool mountpoint holding the
+// per-VM serial logs.
+const SerialLogDirName = "vm-logs"
+
+// serialLogChardevID names the qemu chardev; it doubles as the marker used to
+// find the log path again in an existing VM's command_line_args.
+const serialLogChardevID = "homeops-serial-log"
+
+// minSerialLogVersion is the first SCALE release that passes
+// command_line_args through to qemu.
+var minSerialLogVersion = MiddlewareVersion{Major: 24, Minor: 4}
+
+var (
+	middlewareVersionPattern = regexp.MustCompile(`(\d+)\.(\d+)`)
+	serialLogPathPattern     = regexp.MustCompile(`-chardev\s+file,id=` + serialLogChardevID + `,path=([^,\s]+)`)
+)
+
+// MiddlewareVersion is the TrueNAS release reported by system.version,
+// reduced to the major.minor pair that feature gates compare against.
+type MiddlewareVersion struct {
+	Raw   string
+	Major int
+	Minor int
+}
+
+// ParseMiddlewareVersion extracts major.minor from a system.version string
+// such as "TrueNAS-SCALE-24.10.2" or "25.04.1".
+func ParseMiddlewareVersion(raw string) (MiddlewareVersion, error) {
+	match := middlewareVersionPattern.FindStringSubmatch(raw)
+	if match == nil {
+		return MiddlewareVersion{}, fmt.Errorf("unrecognised TrueNAS version %q", raw)
+	}
+	major, _ := strconv.Atoi(match[1])
+	minor, _ := strconv.Atoi(match[2])
+	return MiddlewareVersion{Raw: raw, Major: major, Minor: minor}, nil
+}
+
+// AtLeast reports whether v is the same release as other or newer.
+func (v MiddlewareVersion) AtLeast(other MiddlewareVersion) bool {
+	if v.Major != other.Major {
+		return v.Major > other.Major
+	}
+	return v.Minor >= other.Minor
+}
+
+func (v MiddlewareVersion) String() string {
+	if v.Raw != "" {
+		return v.Raw
+	}
+	return fmt.Sprintf("%d.%02d", v.Major, v.Minor)
+}
+
+// CheckSerialLogSupport returns an error when the detected release cannot
+// attach a serial log port.
+func CheckSerialLogSupport(v MiddlewareVersion) error {
+	if v.AtLeast(minSerialLogVersion) {
+		return nil
+	}
+	return fmt.Errorf("serial console logging requires TrueNAS SCALE %s or newer (command_line_args passthrough to qemu); this NAS reports %s", minSerialLogVersion, v)
+}
+
+// SerialLogPath is where a VM's serial log lives on the NAS:
+// /mnt/<pool>/vm-logs/<name>.log, using the top-level pool of a dataset path
+// such as "flashstor/VM".
+func SerialLogPath(pool, name string) string {
+	root, _, _ := strings.Cut(strings.Trim(pool, "/"), "/")
+	return path.Join("/mnt", root, SerialLogDirName, name+".log")
+}
+
+// SerialLogDevice describes the extra serial port attached at deploy time.
+type SerialLogDevice struct {
+	Path string
+}
+
+// NewSerialLogDevice builds the device for a VM in pool. The path ends up
+// inside a qemu option list, so characters qemu treats as separators are
+// rejected rather than escaped.
+func NewSerialLogDevice(pool, name string) (SerialLogDevice, error) {
+	if strings.TrimSpace(pool) == "" || strings.TrimSpace(name) == "" {
+		return SerialLogDevice{}, fmt.Errorf("serial log needs both a storage pool and a VM name")
+	}
+	logPath := SerialLogPath(pool, name)
+	if strings.ContainsAny(logPath, ", \t\n'\"") {
+		return SerialLogDevice{}, fmt.Errorf("serial log path %q contains characters qemu cannot take in an option value", logPath)
+	}
+	return SerialLogDevice{Path: logPath}, nil
+}
+
+// Dir is the directory the log file is created in.
+func (d SerialLogDevice) Dir() string {
+	return path.Dir(d.Path)
+}
+
+// CommandLineArgs is the qemu argument string attaching the port. qemu opens
+// the file in append mode so restarts keep earlier boots; the guest sees it
+// as the next free ttyS port after the console TrueNAS already provides.
+func (d SerialLogDevice) CommandLineArgs() string {
+	return fmt.Sprintf("-chardev file,id=%s,path=%s,append=on -device isa-serial,chardev=%s",
+		serialLogChardevID, d.Path, serialLogChardevID)
+}
+
+// SerialLogPathFromArgs recovers the log path from a VM's command_line_args,
+// returning "" when no serial log port is attached.
+func SerialLogPathFromArgs(args string) string {
+	match := serialLogPathPattern.FindStringSubmatch(args)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// joinCommandLineArgs appends extra qemu arguments to an existing string.
+func joinCommandLineArgs(existing, extra string) string {
+	existing = strings.TrimSpace(existing)
+	if existing == "" {
+		return extra
+	}
+	return existing + " " + extra
+}
+
+// MiddlewareVersion queries the NAS release for feature gating.
+func (vm *VMManager) MiddlewareVersion() (MiddlewareVersion, error) {
+	raw, err := vm.client.SystemVersion()
+	if err != nil {
+		return MiddlewareVersion{}, err
+	}
+	return ParseMiddlewareVersion(raw)
+}
+
+// SerialLogPath returns the serial log file attached to the named VM, or an
+// error when the VM was deployed without one.
+func (vm *VMManager) SerialLogPath(name string) (string, error) {
+	vmItem, err := vm.getVMByName(name)
+	if err != nil {
+		return "", err
+	}
+	logPath := SerialLogPathFromArgs(vmItem.CommandLineArgs)
+	if logPath == "" {
+		return "", fmt.Errorf("VM '%s' has no serial console log attached (redeploy it with --serial-log)", name)
+	}
+	return logPath, nil
+}
@@ -0,0 +1,114 @@
+package truenas
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMiddlewareVersionAndSerialLogGate(t *testing.T) {
+	tests := []struct {
+		raw       string
+		major     int
+		minor     int
+		supported bool
+	}{
+		{"TrueNAS-SCALE-24.10.2", 24, 10, true},
+		{"25.04.1", 25, 4, true},
+		{"TrueNAS-SCALE-24.04.0", 24, 4, true},
+		{"TrueNAS-SCALE-23.10.2", 23, 10, false},
+		{"TrueNAS-SCALE-22.12.4.2", 22, 12, false},
+	}
+	for _, tt := range tests {
+		v, err := ParseMiddlewareVersion(tt.raw)
+		require.NoError(t, err, tt.raw)
+		assert.Equal(t, tt.major, v.Major, tt.raw)
+		assert.Equal(t, tt.minor, v.Minor, tt.raw)
+		if tt.supported {
+			assert.NoError(t, CheckSerialLogSupport(v), tt.raw)
+		} else {
+			err := CheckSerialLogSupport(v)
+			require.Error(t, err, tt.raw)
+			assert.Contains(t, err.Error(), "24.04 or newer")
+			assert.Contains(t, err.Error(), tt.raw)
+		}
+	}
+
+	_, err := ParseMiddlewareVersion("TrueNAS-SCALE-MASTER")
+	require.Error(t, err)
+}
+
+func TestNewSerialLogDevice(t *testing.T) {
+	device, err := NewSerialLogDevice("flashstor/VM", "k8s0")
+	require.NoError(t, err)
+	assert.Equal(t, "/mnt/flashstor/vm-logs/k8s0.log", device.Path)
+	assert.Equal(t, "/mnt/flashstor/vm-logs", device.Dir())
+	assert.Equal(t,
+		"-chardev file,id=homeops-serial-log,path=/mnt/flashstor/vm-logs/k8s0.log,append=on -device isa-serial,chardev=homeops-serial-log",
+		device.CommandLineArgs())
+	assert.Equal(t, device.Path, SerialLogPathFromArgs(device.CommandLineArgs()))
+
+	bare, err := NewSerialLogDevice("/tank/", "web_1")
+	require.NoError(t, err)
+	assert.Equal(t, "/mnt/tank/vm-logs/web_1.log", bare.Path)
+
+	_, err = NewSerialLogDevice("my pool", "k8s0")
+	require.Error(t, err, "spaces would split the qemu argument")
+	_, err = NewSerialLogDevice("tank", "a,b")
+	require.Error(t, err, "commas separate qemu options")
+	_, err = NewSerialLogDevice("", "k8s0")
+	require.Error(t, err)
+
+	assert.Empty(t, SerialLogPathFromArgs("-fw_cfg name=opt/org.flatcar-linux/config,file=/mnt/tank/ign/k8s0.ign"))
+}
+
+func TestBuildVMConfigAppendsSerialLogArgs(t *testing.T) {
+	manager := NewVMManager("nas", "key", 443, true)
+	device, err := NewSerialLogDevice("flashstor/VM", "k8s0")
+	require.NoError(t, err)
+
+	talos := manager.buildVMConfig(VMConfig{Name: "k8s0", SerialLog: &device})
+	assert.Equal(t, device.CommandLineArgs(), talos["command_line_args"])
+
+	flatcar := manager.buildVMConfig(VMConfig{Name: "k8s0", Flatcar: true, IgnitionPath: "/mnt/tank/ign/k8s0.ign", SerialLog: &device})
+	assert.Equal(t, "-fw_cfg name=opt/org.flatcar-linux/config,file=/mnt/tank/ign/k8s0.ign "+device.CommandLineArgs(), flatcar["command_line_args"])
+	assert.Equal(t, device.Path, SerialLogPathFromArgs(flatcar["command_line_args"].(string)))
+
+	plain := manager.buildVMConfig(VMConfig{Name: "k8s0"})
+	assert.Equal(t, "", plain["command_line_args"])
+}
+
+func TestVMManagerSerialLogLookups(t *testing.T) {
+	device, err := NewSerialLogDevice("flashstor/VM", "k8s0")
+	require.NoError(t, err)
+	manager := NewVMManager("nas", "key", 443, true)
+	manager.client.callFn = func(method string, params interface{}, timeoutSeconds int64) (json.RawMessage, error) {
+		switch method {
+		case "system.version":
+			return mustJSON(map[string]any{"result": "TrueNAS-SCALE-24.10.2"}), nil
+		case "vm.query":
+			return mustJSON(map[string]any{"result": []map[string]any{
+				{"id": 1, "name": "k8s0", "command_line_args": device.CommandLineArgs()},
+				{"id": 2, "name": "k8s1", "command_line_args": ""},
+			}}), nil
+		case "vm.device.query":
+			return mustJSON(map[string]any{"result": []map[string]any{}}), nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	}
+
+	version, err := manager.MiddlewareVersion()
+	require.NoError(t, err)
+	assert.NoError(t, CheckSerialLogSupport(version))
+
+	logPath, err := manager.SerialLogPath("k8s0")
+	require.NoError(t, err)
+	assert.Equal(t, "/mnt/flashstor/vm-logs/k8s0.log", logPath)
+
+	_, err = manager.SerialLogPath("k8s1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--serial-log")
+}
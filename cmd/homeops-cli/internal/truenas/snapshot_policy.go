@@ -0,0 +1,496 @@
+package truenas
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SnapshotPolicy is a VM's snapshot retention: how many hourly, daily, and
+// weekly snapshots of each zvol to keep. 0 disables that tier.
+type SnapshotPolicy struct {
+	KeepHourly int `json:"keep_hourly" yaml:"keep_hourly"`
+	KeepDaily  int `json:"keep_daily" yaml:"keep_daily"`
+	KeepWeekly int `json:"keep_weekly" yaml:"keep_weekly"`
+}
+
+// Validate rejects negative counts.
+func (p SnapshotPolicy) Validate() error {
+	for _, tier := range snapshotTiers {
+		if keep := tier.keep(p); keep < 0 {
+			return fmt.Errorf("--keep-%s must be >= 0, got %d", tier.Name, keep)
+		}
+	}
+	return nil
+}
+
+// SnapshotSchedule is a periodic snapshot task's cron schedule.
+type SnapshotSchedule struct {
+	Minute string `json:"minute" yaml:"minute"`
+	Hour   string `json:"hour" yaml:"hour"`
+	Dom    string `json:"dom" yaml:"dom"`
+	Month  string `json:"month" yaml:"month"`
+	Dow    string `json:"dow" yaml:"dow"`
+	Begin  string `json:"begin,omitempty" yaml:"begin,omitempty"`
+	End    string `json:"end,omitempty" yaml:"end,omitempty"`
+}
+
+// Cron renders the schedule as a five-field cron expression.
+func (s SnapshotSchedule) Cron() string {
+	return strings.Join([]string{s.Minute, s.Hour, s.Dom, s.Month, s.Dow}, " ")
+}
+
+// SnapshotTask is a periodic snapshot task as returned by
+// pool.snapshottask.query.
+type SnapshotTask struct {
+	ID            int              `json:"id" yaml:"id"`
+	Dataset       string           `json:"dataset" yaml:"dataset"`
+	Recursive     bool             `json:"recursive" yaml:"recursive"`
+	LifetimeValue int              `json:"lifetime_value" yaml:"lifetime_value"`
+	LifetimeUnit  string           `json:"lifetime_unit" yaml:"lifetime_unit"`
+	NamingSchema  string           `json:"naming_schema" yaml:"naming_schema"`
+	Schedule      SnapshotSchedule `json:"schedule" yaml:"schedule"`
+	Enabled       bool             `json:"enabled" yaml:"enabled"`
+}
+
+// VMSnapshotTask is a snapshot task that covers one of a VM's zvols. Tier is
+// the homeops tier ("hourly", "daily", "weekly") or "" for tasks created
+// outside homeops-cli, which apply/prune leave alone.
+type VMSnapshotTask struct {
+	VM   string       `json:"vm" yaml:"vm"`
+	ZVol string       `json:"zvol" yaml:"zvol"`
+	Tier string       `json:"tier,omitempty" yaml:"tier,omitempty"`
+	Task SnapshotTask `json:"task" yaml:"task"`
+}
+
+// snapshotTier is one cadence of a SnapshotPolicy. Each tier is its own
+// periodic snapshot task per zvol, told apart by its naming schema, because a
+// TrueNAS task has a single schedule and lifetime.
+type snapshotTier struct {
+	Name     string
+	Unit     string // lifetime_unit
+	Schedule SnapshotSchedule
+	keep     func(SnapshotPolicy) int
+	set      func(*SnapshotPolicy, int)
+	bucket   func(time.Time) string
+}
+
+var snapshotTiers = []snapshotTier{
+	{
+		Name: "hourly", Unit: "HOUR",
+		Schedule: SnapshotSchedule{Minute: "0", Hour: "*", Dom: "*", Month: "*", Dow: "*", Begin: "00:00", End: "23:59"},
+		keep:     func(p SnapshotPolicy) int { return p.KeepHourly },
+		set:      func(p *SnapshotPolicy, n int) { p.KeepHourly = n },
+		bucket:   func(t time.Time) string { return t.Format("2006-01-02T15") },
+	},
+	{
+		Name: "daily", Unit: "DAY",
+		Schedule: SnapshotSchedule{Minute: "0", Hour: "0", Dom: "*", Month: "*", Dow: "*", Begin: "00:00", End: "23:59"},
+		keep:     func(p SnapshotPolicy) int { return p.KeepDaily },
+		set:      func(p *SnapshotPolicy, n int) { p.KeepDaily = n },
+		bucket:   func(t time.Time) string { return t.Format("2006-01-02") },
+	},
+	{
+		Name: "weekly", Unit: "WEEK",
+		Schedule: SnapshotSchedule{Minute: "0", Hour: "0", Dom: "*", Month: "*", Dow: "0", Begin: "00:00", End: "23:59"},
+		keep:     func(p SnapshotPolicy) int { return p.KeepWeekly },
+		set:      func(p *SnapshotPolicy, n int) { p.KeepWeekly = n },
+		bucket: func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		},
+	},
+}
+
+// snapshotNameTimeLayout is the Go layout of the %Y-%m-%d_%H-%M part of the
+// naming schemas.
+const snapshotNameTimeLayout = "2006-01-02_15-04"
+
+func (t snapshotTier) prefix() string { return "homeops-" + t.Name + "-" }
+
+func (t snapshotTier) namingSchema() string { return t.prefix() + "%Y-%m-%d_%H-%M" }
+
+// tierForSchema returns the homeops tier a task's naming schema belongs to.
+func tierForSchema(schema string) (snapshotTier, bool) {
+	for _, tier := range snapshotTiers {
+		if tier.namingSchema() == schema {
+			return tier, true
+		}
+	}
+	return snapshotTier{}, false
+}
+
+// snapshotTaskPayload is the pool.snapshottask.create/update body for one
+// tier of one zvol. lifetime equals the keep count in the tier's own unit, so
+// TrueNAS expires snapshots on the same terms prune applies immediately.
+func snapshotTaskPayload(zvol string, tier snapshotTier, keep int) map[string]interface{} {
+	return map[string]interface{}{
+		"dataset":        zvol,
+		"recursive":      false,
+		"exclude":        []string{},
+		"lifetime_value": keep,
+		"lifetime_unit":  tier.Unit,
+		"naming_schema":  tier.namingSchema(),
+		"schedule": map[string]interface{}{
+			"minute": tier.Schedule.Minute,
+			"hour":   tier.Schedule.Hour,
+			"dom":    tier.Schedule.Dom,
+			"month":  tier.Schedule.Month,
+			"dow":    tier.Schedule.Dow,
+			"begin":  tier.Schedule.Begin,
+			"end":    tier.Schedule.End,
+		},
+		// Snapshot unchanged zvols too, so the kept count is the tier count.
+		"allow_empty": true,
+		"enabled":     true,
+	}
+}
+
+// taskMatches reports whether task already has the payload's retention and
+// schedule.
+func taskMatches(task SnapshotTask, tier snapshotTier, keep int) bool {
+	schedule := task.Schedule
+	want := tier.Schedule
+	return task.Enabled && !task.Recursive && task.LifetimeValue == keep && task.LifetimeUnit == tier.Unit &&
+		schedule.Minute == want.Minute && schedule.Hour == want.Hour && schedule.Dom == want.Dom &&
+		schedule.Month == want.Month && schedule.Dow == want.Dow
+}
+
+// taskCovers reports whether task snapshots zvol, directly or through a
+// recursive task on a parent dataset.
+func taskCovers(task SnapshotTask, zvol string) bool {
+	return task.Dataset == zvol || (task.Recursive && strings.HasPrefix(zvol, task.Dataset+"/"))
+}
+
+// policyFromTasks derives the policy the homeops tasks on zvol implement. ok
+// is false when zvol has none.
+func policyFromTasks(tasks []SnapshotTask, zvol string) (SnapshotPolicy, bool) {
+	var policy SnapshotPolicy
+	found := false
+	for _, task := range tasks {
+		tier, managed := tierForSchema(task.NamingSchema)
+		if !managed || task.Dataset != zvol || !task.Enabled || task.LifetimeUnit != tier.Unit {
+			continue
+		}
+		tier.set(&policy, task.LifetimeValue)
+		found = true
+	}
+	return policy, found
+}
+
+// QuerySnapshotTasks lists every periodic snapshot task on the NAS.
+func (c *WorkingClient) QuerySnapshotTasks() ([]SnapshotTask, error) {
+	var tasks []SnapshotTask
+	if err := c.callResult("pool.snapshottask.query", []interface{}{}, 30, &tasks); err != nil {
+		return nil, fmt.Errorf("failed to query periodic snapshot tasks: %w", err)
+	}
+	return tasks, nil
+}
+
+// CreateSnapshotTask creates a periodic snapshot task.
+func (c *WorkingClient) CreateSnapshotTask(payload map[string]interface{}) error {
+	if err := c.callResult("pool.snapshottask.create", []interface{}{payload}, 30, nil); err != nil {
+		return fmt.Errorf("failed to create snapshot task for %v: %w", payload["dataset"], err)
+	}
+	return nil
+}
+
+// UpdateSnapshotTask replaces a periodic snapshot task's settings.
+func (c *WorkingClient) UpdateSnapshotTask(id int, payload map[string]interface{}) error {
+	if err := c.callResult("pool.snapshottask.update", []interface{}{id, payload}, 30, nil); err != nil {
+		return fmt.Errorf("failed to update snapshot task %d: %w", id, err)
+	}
+	return nil
+}
+
+// DeleteSnapshotTask removes a periodic snapshot task. Snapshots it already
+// took are kept.
+func (c *WorkingClient) DeleteSnapshotTask(id int) error {
+	if err := c.callResult("pool.snapshottask.delete", []interface{}{id}, 30, nil); err != nil {
+		return fmt.Errorf("failed to delete snapshot task %d: %w", id, err)
+	}
+	return nil
+}
+
+// ApplySnapshotPolicy creates, updates, or removes the VM's homeops snapshot
+// tasks so every zvol has one task per tier with a non-zero keep count. Tasks
+// created outside homeops-cli are not touched.
+func (vm *VMManager) ApplySnapshotPolicy(name string, policy SnapshotPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	vmItem, err := vm.getVMByName(name)
+	if err != nil {
+		return err
+	}
+	zvols, err := vm.vmZVolDatasets(vmItem)
+	if err != nil {
+		return err
+	}
+	tasks, err := vm.client.QuerySnapshotTasks()
+	if err != nil {
+		return err
+	}
+
+	created, updated, removed := 0, 0, 0
+	for _, zvol := range zvols {
+		for _, tier := range snapshotTiers {
+			keep := tier.keep(policy)
+			existing := -1
+			for i, task := range tasks {
+				if task.Dataset == zvol && task.NamingSchema == tier.namingSchema() {
+					existing = i
+					break
+				}
+			}
+			switch {
+			case keep == 0:
+				if existing < 0 {
+					continue
+				}
+				if err := vm.client.DeleteSnapshotTask(tasks[existing].ID); err != nil {
+					return err
+				}
+				removed++
+			case existing < 0:
+				if err := vm.client.CreateSnapshotTask(snapshotTaskPayload(zvol, tier, keep)); err != nil {
+					return err
+				}
+				created++
+			case !taskMatches(tasks[existing], tier, keep):
+				if err := vm.client.UpdateSnapshotTask(tasks[existing].ID, snapshotTaskPayload(zvol, tier, keep)); err != nil {
+					return err
+				}
+				updated++
+			}
+		}
+	}
+	vm.logger.Success("Snapshot policy for VM %s applied to %d zvols (hourly=%d daily=%d weekly=%d; %d created, %d updated, %d removed)",
+		name, len(zvols), policy.KeepHourly, policy.KeepDaily, policy.KeepWeekly, created, updated, removed)
+	return nil
+}
+
+// SnapshotPolicies lists the snapshot tasks covering each VM's zvols,
+// including recursive tasks on parent datasets. An empty name lists every VM.
+func (vm *VMManager) SnapshotPolicies(name string) ([]VMSnapshotTask, error) {
+	var vms []VM
+	if name != "" {
+		vmItem, err := vm.getVMByName(name)
+		if err != nil {
+			return nil, err
+		}
+		vms = []VM{*vmItem}
+	} else {
+		all, err := vm.client.QueryVMs(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query VMs: %w", err)
+		}
+		vms = all
+	}
+	tasks, err := vm.client.QuerySnapshotTasks()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []VMSnapshotTask
+	for i := range vms {
+		zvols, err := vm.discoverVMZVols(&vms[i])
+		if err != nil {
+			return nil, err
+		}
+		for _, zvol := range zvols {
+			for _, task := range tasks {
+				if !taskCovers(task, zvol) {
+					continue
+				}
+				entry := VMSnapshotTask{VM: vms[i].Name, ZVol: zvol, Task: task}
+				if tier, ok := tierForSchema(task.NamingSchema); ok && task.Dataset == zvol {
+					entry.Tier = tier.Name
+				}
+				out = append(out, entry)
+			}
+		}
+	}
+	return out, nil
+}
+
+// ProtectedSnapshot is a snapshot prune would otherwise delete but keeps
+// because something depends on it.
+type ProtectedSnapshot struct {
+	Snapshot ZFSSnapshot `json:"snapshot"`
+	Reason   string      `json:"reason"`
+}
+
+// SnapshotPrunePlan is what PruneVMSnapshots will delete for one VM.
+type SnapshotPrunePlan struct {
+	VM        string
+	Delete    []ZFSSnapshot
+	Protected []ProtectedSnapshot
+	Kept      int
+	Unmanaged int // snapshots not taken by a homeops tier (manual, pre-upgrade, clone sources)
+}
+
+// PlanSnapshotPrune works out which of the VM's homeops snapshots fall
+// outside the retention its snapshot tasks define. Nothing is deleted.
+func (vm *VMManager) PlanSnapshotPrune(name string) (SnapshotPrunePlan, error) {
+	plan := SnapshotPrunePlan{VM: name}
+	vmItem, err := vm.getVMByName(name)
+	if err != nil {
+		return plan, err
+	}
+	zvols, err := vm.vmZVolDatasets(vmItem)
+	if err != nil {
+		return plan, err
+	}
+	tasks, err := vm.client.QuerySnapshotTasks()
+	if err != nil {
+		return plan, err
+	}
+
+	withPolicy := 0
+	for _, zvol := range zvols {
+		policy, ok := policyFromTasks(tasks, zvol)
+		if !ok {
+			continue
+		}
+		withPolicy++
+		snaps, err := vm.client.QueryZFSSnapshots(zvol)
+		if err != nil {
+			return plan, err
+		}
+		keep, drop, unmanaged := retainSnapshots(snaps, policy)
+		plan.Kept += len(keep)
+		plan.Unmanaged += unmanaged
+		for _, snap := range drop {
+			if reason := cloneProtection(snap); reason != "" {
+				plan.Protected = append(plan.Protected, ProtectedSnapshot{Snapshot: snap, Reason: reason})
+				continue
+			}
+			plan.Delete = append(plan.Delete, snap)
+		}
+	}
+	if withPolicy == 0 {
+		return plan, fmt.Errorf("VM %s has no snapshot policy; set one with 'homeops-cli vm truenas snapshot-policy apply --name %s'", name, name)
+	}
+	return plan, nil
+}
+
+// PruneVMSnapshots deletes the snapshots a plan selected.
+func (vm *VMManager) PruneVMSnapshots(plan SnapshotPrunePlan) error {
+	var failures []string
+	for _, snap := range plan.Delete {
+		if err := vm.client.DeleteZFSSnapshot(snap.ID); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to delete %d of %d snapshots: %s", len(failures), len(plan.Delete), strings.Join(failures, "; "))
+	}
+	vm.logger.Success("Pruned %d snapshots of VM %s (%d protected, %d kept)", len(plan.Delete), plan.VM, len(plan.Protected), plan.Kept)
+	return nil
+}
+
+// retainSnapshots applies policy to one zvol's snapshots. Each tier keeps the
+// newest snapshot of each of its newest keep periods (hours, days, ISO
+// weeks); older ones and extras in an already-kept period are dropped.
+// Snapshots without a homeops tier name, or whose time can't be read, are
+// never dropped and are only counted.
+func retainSnapshots(snaps []ZFSSnapshot, policy SnapshotPolicy) (keep, drop []ZFSSnapshot, unmanaged int) {
+	type dated struct {
+		snap  ZFSSnapshot
+		taken time.Time
+	}
+	byTier := map[string][]dated{}
+	for _, snap := range snaps {
+		tier, taken, ok := parsePolicySnapshot(snap)
+		if !ok {
+			unmanaged++
+			continue
+		}
+		byTier[tier.Name] = append(byTier[tier.Name], dated{snap, taken})
+	}
+
+	for _, tier := range snapshotTiers {
+		entries := byTier[tier.Name]
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].taken.After(entries[j].taken) })
+		periods := map[string]bool{}
+		for _, entry := range entries {
+			period := tier.bucket(entry.taken)
+			if !periods[period] && len(periods) < tier.keep(policy) {
+				periods[period] = true
+				keep = append(keep, entry.snap)
+				continue
+			}
+			drop = append(drop, entry.snap)
+		}
+	}
+	return keep, drop, unmanaged
+}
+
+// parsePolicySnapshot reads the tier and time from a homeops snapshot name,
+// falling back to the creation property when the suffix doesn't parse.
+func parsePolicySnapshot(snap ZFSSnapshot) (snapshotTier, time.Time, bool) {
+	for _, tier := range snapshotTiers {
+		suffix, ok := strings.CutPrefix(snap.SnapshotName, tier.prefix())
+		if !ok {
+			continue
+		}
+		if taken, err := time.Parse(snapshotNameTimeLayout, suffix); err == nil {
+			return tier, taken, true
+		}
+		if taken, ok := snap.createdAt(); ok {
+			return tier, taken, true
+		}
+		return snapshotTier{}, time.Time{}, false
+	}
+	return snapshotTier{}, time.Time{}, false
+}
+
+// createdAt parses the creation property's raw value (Unix seconds).
+func (s ZFSSnapshot) createdAt() (time.Time, bool) {
+	prop, ok := s.Properties["creation"].(map[string]interface{})
+	if !ok {
+		return time.Time{}, false
+	}
+	raw, _ := prop["rawvalue"].(string)
+	seconds, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0).UTC(), true
+}
+
+// Clones returns the datasets cloned from this snapshot (the clones
+// property). ok is false when the middleware did not report the property.
+func (s ZFSSnapshot) Clones() (clones []string, ok bool) {
+	prop, ok := s.Properties["clones"].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	value, _ := prop["value"].(string)
+	if value == "" {
+		value, _ = prop["rawvalue"].(string)
+	}
+	for _, clone := range strings.Split(value, ",") {
+		if clone = strings.TrimSpace(clone); clone != "" && clone != "-" {
+			clones = append(clones, clone)
+		}
+	}
+	return clones, true
+}
+
+// cloneProtection explains why snap must not be deleted, or "" when it can
+// be. Snapshots backing a ZFS clone (e.g. from 'vm clone') are protected, and
+// so is any snapshot whose clones property is unknown.
+func cloneProtection(snap ZFSSnapshot) string {
+	clones, ok := snap.Clones()
+	switch {
+	case !ok:
+		return "clones property not reported; cannot prove nothing depends on it"
+	case len(clones) > 0:
+		return "backs clone " + strings.Join(clones, ", ")
+	}
+	return ""
+}
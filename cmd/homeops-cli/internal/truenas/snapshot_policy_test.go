@@ -0,0 +1,206 @@
+package truenas
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// snapshotPolicyFixture reads testdata/snapshot-policy/<name>.json.
+func snapshotPolicyFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", "snapshot-policy", name+".json"))
+	require.NoError(t, err)
+	return raw
+}
+
+// fixtureResult wraps a fixture in the middleware's {"result": ...} envelope.
+func fixtureResult(t *testing.T, name string) json.RawMessage {
+	return json.RawMessage(`{"result":` + string(snapshotPolicyFixture(t, name)) + `}`)
+}
+
+func snapshotNames(snaps []ZFSSnapshot) []string {
+	names := make([]string, 0, len(snaps))
+	for _, snap := range snaps {
+		names = append(names, snap.SnapshotName)
+	}
+	return names
+}
+
+func TestSnapshotTaskPayload(t *testing.T) {
+	tier, ok := tierForSchema("homeops-daily-%Y-%m-%d_%H-%M")
+	require.True(t, ok)
+	got, err := json.Marshal(snapshotTaskPayload("flashstor/VM/web0-boot", tier, 7))
+	require.NoError(t, err)
+	assert.JSONEq(t, string(snapshotPolicyFixture(t, "task-create-daily")), string(got))
+}
+
+func TestApplySnapshotPolicy(t *testing.T) {
+	manager, calls := opsTestManager(t, "RUNNING", func(method string, params interface{}) (json.RawMessage, error) {
+		switch method {
+		case "pool.snapshottask.query":
+			return fixtureResult(t, "tasks"), nil
+		case "pool.snapshottask.create", "pool.snapshottask.update", "pool.snapshottask.delete":
+			return mustJSON(map[string]any{"result": true}), nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	})
+	require.NoError(t, manager.ApplySnapshotPolicy("web0", SnapshotPolicy{KeepHourly: 24, KeepDaily: 3, KeepWeekly: 0}))
+
+	creates := methodCalls(*calls, "pool.snapshottask.create")
+	require.Len(t, creates, 2, "one hourly task per zvol")
+	for i, zvol := range []string{"flashstor/VM/web0-boot", "flashstor/VM/web0-openebs"} {
+		payload := creates[i].params.([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, zvol, payload["dataset"])
+		assert.Equal(t, "homeops-hourly-%Y-%m-%d_%H-%M", payload["naming_schema"])
+		assert.Equal(t, 24, payload["lifetime_value"])
+		assert.Equal(t, "HOUR", payload["lifetime_unit"])
+	}
+	assert.Empty(t, methodCalls(*calls, "pool.snapshottask.update"), "daily tasks already keep 3 days")
+	assert.Equal(t, []recordedCall{{"pool.snapshottask.delete", []interface{}{12}}}, methodCalls(*calls, "pool.snapshottask.delete"),
+		"only the homeops weekly task goes; the recursive auto- task is not ours")
+
+	*calls = nil
+	require.NoError(t, manager.ApplySnapshotPolicy("web0", SnapshotPolicy{KeepDaily: 7, KeepWeekly: 2}))
+	updates := methodCalls(*calls, "pool.snapshottask.update")
+	require.Len(t, updates, 2)
+	args := updates[0].params.([]interface{})
+	assert.Equal(t, 11, args[0])
+	assert.Equal(t, 7, args[1].(map[string]interface{})["lifetime_value"])
+	assert.Equal(t, 13, updates[1].params.([]interface{})[0])
+	assert.Len(t, methodCalls(*calls, "pool.snapshottask.create"), 1, "openebs has no weekly task yet")
+
+	require.ErrorContains(t, manager.ApplySnapshotPolicy("web0", SnapshotPolicy{KeepDaily: -1}), "--keep-daily must be >= 0")
+}
+
+func TestSnapshotPoliciesIncludesRecursiveParentTasks(t *testing.T) {
+	manager, _ := opsTestManager(t, "RUNNING", func(method string, params interface{}) (json.RawMessage, error) {
+		if method == "pool.snapshottask.query" {
+			return fixtureResult(t, "tasks"), nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	})
+	tasks, err := manager.SnapshotPolicies("")
+	require.NoError(t, err)
+
+	var summary []string
+	for _, entry := range tasks {
+		summary = append(summary, fmt.Sprintf("%s %s %d %s", entry.VM, entry.ZVol, entry.Task.ID, entry.Tier))
+	}
+	assert.Equal(t, []string{
+		"web0 flashstor/VM/web0-boot 11 daily",
+		"web0 flashstor/VM/web0-boot 12 weekly",
+		"web0 flashstor/VM/web0-boot 20 ",
+		"web0 flashstor/VM/web0-openebs 13 daily",
+		"web0 flashstor/VM/web0-openebs 20 ",
+	}, summary)
+}
+
+func TestRetainSnapshots(t *testing.T) {
+	var snaps []ZFSSnapshot
+	require.NoError(t, json.Unmarshal(snapshotPolicyFixture(t, "snapshots"), &snaps))
+
+	keep, drop, unmanaged := retainSnapshots(snaps, SnapshotPolicy{KeepDaily: 3, KeepWeekly: 2})
+	assert.Equal(t, []string{
+		"homeops-daily-2026-10-14_00-00", "homeops-daily-2026-10-13_00-00", "homeops-daily-2026-10-12_00-00",
+		"homeops-weekly-2026-10-11_00-00", "homeops-weekly-2026-10-04_00-00",
+	}, snapshotNames(keep))
+	assert.Equal(t, []string{
+		"homeops-hourly-2026-10-14_05-00",
+		"homeops-daily-2026-10-11_00-00", "homeops-daily-2026-10-10_00-00", "homeops-daily-2026-10-09_00-00", "homeops-daily-2026-10-08_00-00",
+		"homeops-weekly-2026-09-27_00-00",
+	}, snapshotNames(drop), "hourly tier is off, so its snapshot goes too")
+	assert.Equal(t, 2, unmanaged, "pre-upgrade and the middleware's auto- snapshot are never candidates")
+
+	t.Run("one snapshot per period", func(t *testing.T) {
+		extra := []ZFSSnapshot{
+			{SnapshotName: "homeops-daily-2026-10-14_00-00"},
+			{SnapshotName: "homeops-daily-2026-10-14_12-00"},
+			{SnapshotName: "homeops-weekly-2026-10-12_00-00"},
+			{SnapshotName: "homeops-weekly-2026-10-11_00-00"},
+		}
+		keep, drop, _ := retainSnapshots(extra, SnapshotPolicy{KeepDaily: 7, KeepWeekly: 4})
+		assert.Equal(t, []string{"homeops-daily-2026-10-14_12-00", "homeops-weekly-2026-10-12_00-00", "homeops-weekly-2026-10-11_00-00"}, snapshotNames(keep),
+			"Sunday 10-11 and Monday 10-12 are different ISO weeks")
+		assert.Equal(t, []string{"homeops-daily-2026-10-14_00-00"}, snapshotNames(drop))
+	})
+
+	t.Run("unparseable names fall back to creation", func(t *testing.T) {
+		odd := []ZFSSnapshot{
+			{SnapshotName: "homeops-daily-renamed", Properties: map[string]interface{}{"creation": map[string]interface{}{"rawvalue": "1791796500"}}},
+			{SnapshotName: "homeops-daily-undated"},
+		}
+		keep, _, unmanaged := retainSnapshots(odd, SnapshotPolicy{KeepDaily: 1})
+		assert.Equal(t, []string{"homeops-daily-renamed"}, snapshotNames(keep))
+		assert.Equal(t, 1, unmanaged, "a snapshot that can't be dated is left alone")
+	})
+}
+
+func TestCloneProtection(t *testing.T) {
+	var snaps []ZFSSnapshot
+	require.NoError(t, json.Unmarshal(snapshotPolicyFixture(t, "snapshots"), &snaps))
+	byName := map[string]ZFSSnapshot{}
+	for _, snap := range snaps {
+		byName[snap.SnapshotName] = snap
+	}
+
+	assert.Empty(t, cloneProtection(byName["homeops-daily-2026-10-10_00-00"]))
+	assert.Equal(t, "backs clone flashstor/VM/web1-boot", cloneProtection(byName["homeops-daily-2026-10-09_00-00"]))
+	assert.Contains(t, cloneProtection(byName["homeops-daily-2026-10-08_00-00"]), "clones property not reported")
+
+	clones, ok := ZFSSnapshot{Properties: map[string]interface{}{"clones": map[string]interface{}{"value": "a/b, c/d"}}}.Clones()
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a/b", "c/d"}, clones)
+}
+
+func TestPlanAndPruneVMSnapshots(t *testing.T) {
+	manager, calls := opsTestManager(t, "RUNNING", func(method string, params interface{}) (json.RawMessage, error) {
+		switch method {
+		case "pool.snapshottask.query":
+			return fixtureResult(t, "tasks"), nil
+		case "pool.snapshot.query":
+			if params.([]interface{})[0].([]interface{})[0].([]interface{})[2] == "flashstor/VM/web0-boot" {
+				return fixtureResult(t, "snapshots"), nil
+			}
+			return mustJSON(map[string]any{"result": []map[string]any{}}), nil
+		case "pool.snapshot.delete":
+			return mustJSON(map[string]any{"result": true}), nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	})
+
+	plan, err := manager.PlanSnapshotPrune("web0")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"homeops-hourly-2026-10-14_05-00", "homeops-daily-2026-10-11_00-00", "homeops-daily-2026-10-10_00-00", "homeops-weekly-2026-09-27_00-00",
+	}, snapshotNames(plan.Delete))
+	require.Len(t, plan.Protected, 2)
+	assert.Equal(t, "homeops-daily-2026-10-09_00-00", plan.Protected[0].Snapshot.SnapshotName)
+	assert.Equal(t, 5, plan.Kept)
+	assert.Equal(t, 2, plan.Unmanaged)
+	assert.Empty(t, methodCalls(*calls, "pool.snapshot.delete"), "planning deletes nothing")
+
+	require.NoError(t, manager.PruneVMSnapshots(plan))
+	deletes := methodCalls(*calls, "pool.snapshot.delete")
+	require.Len(t, deletes, 4)
+	assert.Equal(t, []interface{}{"flashstor/VM/web0-boot@homeops-hourly-2026-10-14_05-00"}, deletes[0].params)
+	for _, call := range deletes {
+		assert.NotEqual(t, []interface{}{"flashstor/VM/web0-boot@homeops-daily-2026-10-09_00-00"}, call.params)
+	}
+}
+
+func TestPlanSnapshotPruneWithoutPolicy(t *testing.T) {
+	manager, _ := opsTestManager(t, "RUNNING", func(method string, params interface{}) (json.RawMessage, error) {
+		if method == "pool.snapshottask.query" {
+			return mustJSON(map[string]any{"result": []map[string]any{}}), nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	})
+	_, err := manager.PlanSnapshotPrune("web0")
+	require.ErrorContains(t, err, "has no snapshot policy")
+}
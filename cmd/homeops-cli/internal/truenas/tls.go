@@ -0,0 +1,117 @@
+package truenas
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/constants"
+
+	"github.com/gorilla/websocket"
+	"github.com/truenas/api_client_golang/truenas_api"
+)
+
+// TLSOptions controls how a wss connection verifies the NAS certificate.
+// The zero value verifies against the system pool.
+type TLSOptions struct {
+	// CAFile is a PEM bundle trusted in addition to the system pool, e.g.
+	// the NAS's self-signed certificate.
+	CAFile string
+	// InsecureSkipVerify accepts any certificate.
+	InsecureSkipVerify bool
+	// ServerName is the name the certificate is verified against (and sent
+	// as SNI) when it differs from the host dialed, e.g. an IP.
+	ServerName string
+}
+
+// TLSOverrides are the --truenas-ca and --insecure flags, set by the
+// commands that register them. Non-zero fields win over the environment.
+var TLSOverrides TLSOptions
+
+// DefaultTLSOptions are the TLS options of a client built without any:
+// TRUENAS_CA, TRUENAS_INSECURE and TRUENAS_TLS_SERVER_NAME, overridden by
+// TLSOverrides.
+func DefaultTLSOptions() TLSOptions {
+	options := TLSOptions{
+		CAFile:             os.Getenv(constants.EnvTrueNASCA),
+		InsecureSkipVerify: common.EnvBool(constants.EnvTrueNASInsecure, false),
+		ServerName:         os.Getenv(constants.EnvTrueNASServerName),
+	}
+	if TLSOverrides.CAFile != "" {
+		options.CAFile = TLSOverrides.CAFile
+	}
+	if TLSOverrides.InsecureSkipVerify {
+		options.InsecureSkipVerify = true
+	}
+	if TLSOverrides.ServerName != "" {
+		options.ServerName = TLSOverrides.ServerName
+	}
+	return options
+}
+
+// ClientOption configures a WorkingClient.
+type ClientOption func(*WorkingClient)
+
+// WithCABundle trusts the PEM certificates in path besides the system pool.
+func WithCABundle(path string) ClientOption {
+	return func(c *WorkingClient) { c.tls.CAFile = path }
+}
+
+// WithInsecureSkipVerify turns certificate verification off (or back on).
+func WithInsecureSkipVerify(insecure bool) ClientOption {
+	return func(c *WorkingClient) { c.tls.InsecureSkipVerify = insecure }
+}
+
+// WithServerName verifies the certificate against name instead of the host.
+func WithServerName(name string) ClientOption {
+	return func(c *WorkingClient) { c.tls.ServerName = name }
+}
+
+// config builds the tls.Config for o, reading and parsing CAFile.
+func (o TLSOptions) config() (*tls.Config, error) {
+	if o.InsecureSkipVerify && o.CAFile != "" {
+		return nil, fmt.Errorf("a TrueNAS CA bundle (%s) and skipping TLS verification cannot be combined", o.CAFile)
+	}
+	config := &tls.Config{ServerName: o.ServerName, MinVersion: tls.VersionTLS12}
+	if o.InsecureSkipVerify {
+		common.NewColorLogger().Warn("TrueNAS TLS verification DISABLED via --insecure or %s=true (use --truenas-ca to trust a self-signed certificate instead)", constants.EnvTrueNASInsecure)
+		config.InsecureSkipVerify = true // #nosec G402 -- explicit opt-in with a loud warning; verification is the default
+		return config, nil
+	}
+	if o.CAFile == "" {
+		return config, nil
+	}
+	bundle, err := os.ReadFile(o.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TrueNAS CA bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("TrueNAS CA bundle %s holds no PEM certificates", o.CAFile)
+	}
+	config.RootCAs = pool
+	return config, nil
+}
+
+// dialMu serializes dials: the API client dials with websocket.DefaultDialer,
+// so a connection's TLS config is swapped into it for the one dial.
+var dialMu sync.Mutex
+
+// dialAPIClient opens the middleware websocket at serverURL, verifying a
+// wss endpoint with tlsConfig.
+func dialAPIClient(serverURL string, tlsConfig *tls.Config) (*truenas_api.Client, error) {
+	dialMu.Lock()
+	defer dialMu.Unlock()
+	previous := websocket.DefaultDialer.TLSClientConfig
+	websocket.DefaultDialer.TLSClientConfig = tlsConfig
+	defer func() { websocket.DefaultDialer.TLSClientConfig = previous }()
+	// verifySSL stays true: the library's own insecure branch would replace
+	// tlsConfig, and for good, on the shared dialer.
+	return truenas_api.NewClient(serverURL, true)
+}
@@ -0,0 +1,97 @@
+package truenas
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"homeops-cli/internal/constants"
+	"homeops-cli/internal/testutil"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// selfSignedNAS serves a middleware websocket that accepts any API key,
+// behind httptest's self-signed certificate (valid for 127.0.0.1 and
+// example.com). It returns the host, port and a PEM file of the certificate.
+func selfSignedNAS(t *testing.T) (string, int, string) {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		for {
+			var request struct {
+				ID int `json:"id"`
+			}
+			if err := conn.ReadJSON(&request); err != nil {
+				return
+			}
+			if err := conn.WriteJSON(map[string]any{"jsonrpc": "2.0", "id": request.ID, "result": true}); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	caFile := filepath.Join(t.TempDir(), "nas.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600))
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(serverURL.Port())
+	require.NoError(t, err)
+	return serverURL.Hostname(), port, caFile
+}
+
+func TestConnectVerifiesTheNASCertificate(t *testing.T) {
+	testutil.Swap(t, &connectRetrySleep, func(time.Duration) {})
+	t.Setenv(constants.EnvTrueNASCA, "")
+	t.Setenv(constants.EnvTrueNASInsecure, "")
+	t.Setenv(constants.EnvTrueNASServerName, "")
+	host, port, caFile := selfSignedNAS(t)
+
+	connect := func(opts ...ClientOption) error {
+		client := NewWorkingClient(host, "key", port, true, opts...)
+		err := client.Connect()
+		if err == nil {
+			_ = client.Close()
+		}
+		return err
+	}
+
+	err := connect()
+	require.Error(t, err, "a self-signed certificate is rejected by default")
+	assert.Contains(t, err.Error(), "certificate")
+	assert.NoError(t, connect(WithCABundle(caFile)))
+	assert.NoError(t, connect(WithCABundle(caFile), WithServerName("example.com")))
+	assert.Error(t, connect(WithCABundle(caFile), WithServerName("nas.example.test")), "the certificate does not name the override")
+	assert.NoError(t, connect(WithInsecureSkipVerify(true)))
+	assert.Error(t, connect(), "skipping verification does not leak into the next dial")
+	assert.EqualError(t, connect(WithCABundle(caFile), WithInsecureSkipVerify(true)),
+		"a TrueNAS CA bundle ("+caFile+") and skipping TLS verification cannot be combined")
+
+	t.Setenv(constants.EnvTrueNASCA, caFile)
+	assert.NoError(t, connect(), "TRUENAS_CA is trusted")
+}
+
+func TestDefaultTLSOptionsPreferTheFlagsOverTheEnvironment(t *testing.T) {
+	t.Setenv(constants.EnvTrueNASCA, "/etc/env-ca.pem")
+	t.Setenv(constants.EnvTrueNASInsecure, "")
+	t.Setenv(constants.EnvTrueNASServerName, "nas.example.test")
+	testutil.Swap(t, &TLSOverrides, TLSOptions{CAFile: "/etc/flag-ca.pem"})
+	assert.Equal(t, TLSOptions{CAFile: "/etc/flag-ca.pem", ServerName: "nas.example.test"}, DefaultTLSOptions())
+
+	_, err := TLSOptions{CAFile: filepath.Join(t.TempDir(), "missing.pem")}.config()
+	assert.ErrorContains(t, err, "failed to read TrueNAS CA bundle")
+}
@@ -0,0 +1,203 @@
+package truenas
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// The middleware's vm.clone names the copies "<zvol>_<name>_clone<N>" and
+// keeps the source's disk serials, so CloneVM builds the clone itself from a
+// stopped VM: every ZVol disk is snapshotted (clone-<target>) and cloned
+// next to the original as <target>-<disk>, the vm.get_instance settings are
+// copied to a new VM, and its devices are recreated pointing at the clones
+// with fresh disk serials and NIC MACs. A Talos config ISO is left out: it
+// carries the source node's machine config. Whatever was created is removed
+// again if a step fails.
+
+// cloneVMSettings are the vm.get_instance fields copied to vm.create.
+var cloneVMSettings = []string{
+	"description", "vcpus", "cores", "threads", "cpuset", "nodeset", "pin_vcpus",
+	"cpu_mode", "cpu_model", "memory", "min_memory", "bootloader", "bootloader_ovmf",
+	"autostart", "hide_from_msr", "hyperv_enlightenments", "ensure_display_device",
+	"time", "shutdown_timeout", "arch_type", "machine_type", "suspend_on_snapshot",
+	"trusted_platform_module", "enable_secure_boot", "command_line_args",
+}
+
+// GetVMConfig returns a VM's vm.get_instance record as returned, devices
+// included, for copying settings this client does not model.
+func (c *WorkingClient) GetVMConfig(vmID int) (map[string]interface{}, error) {
+	var config map[string]interface{}
+	if err := c.callResult("vm.get_instance", []interface{}{vmID}, 30, &config); err != nil {
+		return nil, fmt.Errorf("failed to get VM %d: %w", vmID, err)
+	}
+	return config, nil
+}
+
+// CloneZFSSnapshot creates dataset dst as a ZFS clone of snapshot id
+// ("dataset@name").
+func (c *WorkingClient) CloneZFSSnapshot(id, dst string) error {
+	params := []interface{}{map[string]interface{}{"snapshot": id, "dataset_dst": dst}}
+	if err := c.callResult("pool.snapshot.clone", params, 120, nil); err != nil {
+		return fmt.Errorf("failed to clone snapshot %s to %s: %w", id, dst, err)
+	}
+	return nil
+}
+
+// cloneZVolName is the clone of zvol for VM newName: "<dir>/k8s0-boot" of
+// VM k8s0 becomes "<dir>/<newName>-boot", and a ZVol not named after its VM
+// becomes "<dir>/<newName>-<zvol name>".
+func cloneZVolName(zvol, name, newName string) string {
+	dir, base := path.Split(zvol)
+	base = strings.TrimPrefix(base, name+"-")
+	return dir + newName + "-" + base
+}
+
+// CloneVM copies the stopped VM name to a new VM newName with its own ZFS
+// clones of every disk.
+func (vm *VMManager) CloneVM(name, newName string) (err error) {
+	allVMs, err := vm.client.QueryVMs(nil)
+	if err != nil {
+		return fmt.Errorf("failed to query existing VMs: %w", err)
+	}
+	var source *VM
+	for i := range allVMs {
+		switch allVMs[i].Name {
+		case newName:
+			return fmt.Errorf("VM with name '%s' already exists", newName)
+		case name:
+			source = &allVMs[i]
+		}
+	}
+	if source == nil {
+		return fmt.Errorf("VM %s not found", name)
+	}
+	if state := vmState(source); state != "STOPPED" {
+		return fmt.Errorf("VM %s is %s — stop it before cloning ('homeops-cli vm stop --provider truenas --name %s')", name, describeVMState(source), name)
+	}
+
+	live, err := vm.client.GetVMConfig(source.ID)
+	if err != nil {
+		return err
+	}
+	devices, clones, err := cloneDeviceLayout(live, name, newName)
+	if err != nil {
+		return err
+	}
+	nics := VMConfig{Name: newName}
+	for _, device := range devices {
+		if device.attributes["dtype"] == "NIC" {
+			nics.NICs = append(nics.NICs, VMNIC{})
+		}
+	}
+	if err := vm.assignNICMACs(&nics, nicMACOwners(allVMs, "")); err != nil {
+		return err
+	}
+
+	vm.logger.Info("Cloning VM %s (ID: %d) to %s", name, source.ID, newName)
+	vm.rollback = &deployRollback{}
+	defer func() {
+		created := vm.rollback
+		vm.rollback = nil
+		if err != nil {
+			err = vm.rollBack(created, VMConfig{Name: newName}, err)
+		}
+	}()
+
+	snapName := "clone-" + newName
+	for _, zvol := range slices.Sorted(maps.Keys(clones)) {
+		if err := vm.client.CreateZFSSnapshot(zvol, snapName); err != nil {
+			return err
+		}
+		vm.rollback.add(createdResource{kind: rollbackSnapshot, name: zvol + "@" + snapName})
+		if err := vm.client.CloneZFSSnapshot(zvol+"@"+snapName, clones[zvol]); err != nil {
+			return err
+		}
+		vm.rollback.add(createdResource{kind: rollbackZVol, name: clones[zvol]})
+		vm.logger.Info("Cloned ZVol %s to %s", zvol, clones[zvol])
+	}
+
+	config := map[string]interface{}{"name": newName}
+	for _, key := range cloneVMSettings {
+		if value, ok := live[key]; ok {
+			config[key] = value
+		}
+	}
+	if args, _ := config["command_line_args"].(string); SerialLogPathFromArgs(args) != "" {
+		logPath := SerialLogPathFromArgs(args)
+		config["command_line_args"] = strings.ReplaceAll(args, logPath, path.Join(path.Dir(logPath), newName+".log"))
+	}
+	createdVM, err := vm.client.CreateVM(config)
+	if err != nil {
+		return err
+	}
+	vm.rollback.add(createdResource{kind: rollbackVM, id: createdVM.ID, name: newName})
+
+	nic := 0
+	for _, device := range devices {
+		switch device.attributes["dtype"] {
+		case "DISK":
+			device.attributes["serial"] = vm.generateRandomSerial()
+		case "NIC":
+			device.attributes["mac"] = nics.NICs[nic].MAC
+			nic++
+		}
+		if err := vm.createVMDevice(createdVM.ID, device.order, device.attributes); err != nil {
+			return fmt.Errorf("failed to create %s device: %w", device.attributes["dtype"], err)
+		}
+	}
+	vm.logger.Success("VM %s cloned to %s (%d ZVols)", name, newName, len(clones))
+	return nil
+}
+
+type cloneDevice struct {
+	order      int
+	attributes map[string]interface{}
+}
+
+// cloneDeviceLayout is the source's devices in order as they are recreated
+// for newName, and the clone name of each ZVol they use. The display gets
+// new ports from the middleware; a disk not backed by a ZVol cannot be
+// cloned.
+func cloneDeviceLayout(live map[string]interface{}, name, newName string) ([]cloneDevice, map[string]string, error) {
+	rawDevices, _ := live["devices"].([]interface{})
+	var devices []cloneDevice
+	clones := map[string]string{}
+	for _, raw := range rawDevices {
+		device, _ := raw.(map[string]interface{})
+		source, _ := device["attributes"].(map[string]interface{})
+		attributes := make(map[string]interface{}, len(source))
+		for key, value := range source {
+			attributes[key] = value
+		}
+		devicePath, _ := attributes["path"].(string)
+		switch attributes["dtype"] {
+		case "DISK", "RAW":
+			zvol, ok := extractZVolPathFromDevice(map[string]interface{}{"attributes": attributes})
+			if !ok {
+				return nil, nil, fmt.Errorf("VM %s has a disk that is not a ZVol (%s); only ZVol disks can be cloned", name, devicePath)
+			}
+			clone := cloneZVolName(zvol, name, newName)
+			for other, taken := range clones {
+				if taken == clone && other != zvol {
+					return nil, nil, fmt.Errorf("ZVols %s and %s of VM %s would both be cloned to %s", other, zvol, name, clone)
+				}
+			}
+			clones[zvol] = clone
+			attributes["path"] = "/dev/zvol/" + clone
+		case "CDROM":
+			if strings.HasSuffix(devicePath, ConfigISOSuffix) {
+				continue
+			}
+		case "DISPLAY":
+			delete(attributes, "port")
+			delete(attributes, "web_port")
+		}
+		devices = append(devices, cloneDevice{order: intAttr(device, "order"), attributes: attributes})
+	}
+	sort.SliceStable(devices, func(i, j int) bool { return devices[i].order < devices[j].order })
+	return devices, clones, nil
+}
@@ -0,0 +1,144 @@
+package truenas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cloneTestManager answers for a stopped Talos node k8s0 (ID 3) with a boot
+// and a custom ceph ZVol, a NIC, a SPICE display, the Talos ISO and its
+// config ISO, and records every call. failOn makes that method fail.
+func cloneTestManager(t *testing.T, state, failOn string) (*VMManager, *[]recordedCall) {
+	t.Helper()
+	serialLog, err := NewSerialLogDevice("flashstor", "k8s0")
+	require.NoError(t, err)
+	devices := []map[string]any{
+		{"id": 10, "vm": 3, "order": 1001, "attributes": map[string]any{"dtype": "DISK", "type": "VIRTIO", "path": "/dev/zvol/flashstor/VM/k8s0-boot", "serial": "BOOT0001"}},
+		{"id": 11, "vm": 3, "order": 1004, "attributes": map[string]any{"dtype": "DISK", "type": "VIRTIO", "path": "/dev/zvol/tank/VM/k8s0-ceph", "serial": "CEPH0001"}},
+		{"id": 12, "vm": 3, "order": 1002, "attributes": map[string]any{"dtype": "NIC", "type": "VIRTIO", "nic_attach": "br0", "mac": "00:0c:29:aa:bb:cc"}},
+		{"id": 13, "vm": 3, "order": 1006, "attributes": map[string]any{"dtype": "CDROM", "path": "/mnt/flashstor/ISO/metal-amd64.iso"}},
+		{"id": 14, "vm": 3, "order": 1007, "attributes": map[string]any{"dtype": "CDROM", "path": "/mnt/flashstor/ISO/k8s0" + ConfigISOSuffix}},
+		{"id": 15, "vm": 3, "order": 1010, "attributes": map[string]any{"dtype": "DISPLAY", "type": "SPICE", "bind": "0.0.0.0", "port": 5900, "web": true, "web_port": 5901}},
+	}
+	manager := NewVMManager("nas", "key", 443, true)
+	calls := &[]recordedCall{}
+	manager.client.callFn = func(method string, params interface{}, _ int64) (json.RawMessage, error) {
+		*calls = append(*calls, recordedCall{method, params})
+		if method == failOn {
+			return nil, errors.New("middleware said no")
+		}
+		switch method {
+		case "vm.query":
+			return mustJSON(map[string]any{"result": []map[string]any{
+				{"id": 3, "name": "k8s0", "status": map[string]any{"state": state}, "devices": devices},
+				{"id": 4, "name": "k8s1", "status": map[string]any{"state": "RUNNING"}},
+			}}), nil
+		case "vm.get_instance":
+			return mustJSON(map[string]any{"result": map[string]any{
+				"id": 3, "name": "k8s0", "description": "Talos Linux VM - k8s0", "memory": 16384, "vcpus": 1, "cores": 4, "threads": 1,
+				"bootloader": "UEFI", "cpu_mode": "HOST-PASSTHROUGH", "autostart": true, "shutdown_timeout": 90,
+				"command_line_args": serialLog.CommandLineArgs(), "status": map[string]any{"state": state}, "devices": devices,
+			}}), nil
+		case "vm.device.query":
+			return mustJSON(map[string]any{"result": devices}), nil
+		case "vm.create":
+			return mustJSON(map[string]any{"result": map[string]any{"id": 9, "name": "k8stest"}}), nil
+		case "vm.device.create":
+			return mustJSON(map[string]any{"result": map[string]any{"id": 100 + len(methodCalls(*calls, "vm.device.create"))}}), nil
+		case "pool.snapshot.create", "pool.snapshot.clone", "pool.snapshot.delete", "pool.dataset.delete", "vm.delete", "vm.device.delete":
+			return mustJSON(map[string]any{"result": true}), nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	}
+	return manager, calls
+}
+
+func TestCloneVMClonesEveryZVolAndRecreatesTheDevices(t *testing.T) {
+	manager, calls := cloneTestManager(t, "STOPPED", "")
+	require.NoError(t, manager.CloneVM("k8s0", "k8stest"))
+
+	var storage []interface{}
+	for _, call := range *calls {
+		if call.method == "pool.snapshot.create" || call.method == "pool.snapshot.clone" {
+			storage = append(storage, call.params.([]interface{})[0])
+		}
+	}
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"dataset": "flashstor/VM/k8s0-boot", "name": "clone-k8stest"},
+		map[string]interface{}{"snapshot": "flashstor/VM/k8s0-boot@clone-k8stest", "dataset_dst": "flashstor/VM/k8stest-boot"},
+		map[string]interface{}{"dataset": "tank/VM/k8s0-ceph", "name": "clone-k8stest"},
+		map[string]interface{}{"snapshot": "tank/VM/k8s0-ceph@clone-k8stest", "dataset_dst": "tank/VM/k8stest-ceph"},
+	}, storage)
+
+	create := methodCalls(*calls, "vm.create")[0].params.([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "k8stest", create["name"])
+	assert.Equal(t, float64(16384), create["memory"])
+	assert.Equal(t, "HOST-PASSTHROUGH", create["cpu_mode"])
+	assert.Equal(t, true, create["autostart"])
+	assert.Contains(t, create["command_line_args"], "path=/mnt/flashstor/vm-logs/k8stest.log,")
+	assert.NotContains(t, create, "devices")
+	assert.NotContains(t, create, "status")
+
+	var recreated []string
+	for _, call := range methodCalls(*calls, "vm.device.create") {
+		device := call.params.([]interface{})[0].(map[string]interface{})
+		attributes := device["attributes"].(map[string]interface{})
+		assert.Equal(t, 9, device["vm"])
+		switch attributes["dtype"] {
+		case "DISK":
+			recreated = append(recreated, fmt.Sprintf("%d DISK %s", device["order"], attributes["path"]))
+			assert.Len(t, attributes["serial"], 8)
+			assert.NotContains(t, []interface{}{"BOOT0001", "CEPH0001"}, attributes["serial"], "the clone's disks get serials of their own")
+		case "NIC":
+			recreated = append(recreated, fmt.Sprintf("%d NIC %s", device["order"], attributes["nic_attach"]))
+			assert.NotEqual(t, "00:0c:29:aa:bb:cc", attributes["mac"])
+			assert.Regexp(t, `^00:0c:29(:[0-9a-f]{2}){3}$`, attributes["mac"])
+		case "DISPLAY":
+			recreated = append(recreated, fmt.Sprintf("%d DISPLAY %s", device["order"], attributes["type"]))
+			assert.NotContains(t, attributes, "port", "the middleware picks free ports")
+			assert.NotContains(t, attributes, "web_port")
+		default:
+			recreated = append(recreated, fmt.Sprintf("%d %s %s", device["order"], attributes["dtype"], attributes["path"]))
+		}
+	}
+	assert.Equal(t, []string{
+		"1001 DISK /dev/zvol/flashstor/VM/k8stest-boot",
+		"1002 NIC br0",
+		"1004 DISK /dev/zvol/tank/VM/k8stest-ceph",
+		"1006 CDROM /mnt/flashstor/ISO/metal-amd64.iso",
+		"1010 DISPLAY SPICE",
+	}, recreated, "the source's Talos config ISO is not attached to the clone")
+}
+
+func TestCloneVMRefusesARunningVMAndRollsBackAFailure(t *testing.T) {
+	manager, calls := cloneTestManager(t, "RUNNING", "")
+	err := manager.CloneVM("k8s0", "k8stest")
+	require.EqualError(t, err, "VM k8s0 is RUNNING — stop it before cloning ('homeops-cli vm stop --provider truenas --name k8s0')")
+	assert.Empty(t, methodCalls(*calls, "pool.snapshot.create"))
+
+	manager, _ = cloneTestManager(t, "STOPPED", "")
+	require.EqualError(t, manager.CloneVM("k8s0", "k8s1"), "VM with name 'k8s1' already exists")
+
+	manager, calls = cloneTestManager(t, "STOPPED", "vm.device.create")
+	err = manager.CloneVM("k8s0", "k8stest")
+	require.ErrorContains(t, err, "failed to create DISK device")
+	var undone []string
+	for _, call := range *calls {
+		switch call.method {
+		case "vm.delete", "pool.dataset.delete", "pool.snapshot.delete":
+			undone = append(undone, fmt.Sprintf("%s %v", call.method, call.params.([]interface{})[0]))
+		}
+	}
+	assert.Equal(t, []string{
+		"vm.delete 9",
+		"pool.dataset.delete tank/VM/k8stest-ceph",
+		"pool.snapshot.delete tank/VM/k8s0-ceph@clone-k8stest",
+		"pool.dataset.delete flashstor/VM/k8stest-boot",
+		"pool.snapshot.delete flashstor/VM/k8s0-boot@clone-k8stest",
+	}, undone, "everything the clone created is removed, newest first")
+}
@@ -0,0 +1,218 @@
+package truenas
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A VM's disks are one ordered slice: the boot disk first, then the data
+// disks. Device orders come from the slice index (diskDeviceOrder), so the
+// default boot/OpenEBS pair keeps 1001/1004 and a layout from
+// `talos deploy-vm --data-disk name=ceph,size=2000` is boot (1001), ceph
+// (1004), and so on. --openebs-size is sugar for a data disk named openebs.
+
+// VMDisk is one disk of a VMConfig.Disks layout.
+type VMDisk struct {
+	Name        string
+	ZVol        string
+	SizeGB      int
+	Sparse      bool
+	BlockSize   string
+	Order       int
+	Serial      string // default random
+	Compression string // default inherited from the parent dataset
+}
+
+// ZVolOptions are the deploy-vm --sparse, --volblocksize and --compression
+// settings. BlockSize and Compression map a disk name (case-insensitive) to
+// its value, with "" for every disk not named; a disk's own value (from a
+// spec) wins over them.
+type ZVolOptions struct {
+	Thick       bool // --sparse=false: reserve each ZVol's full size
+	BlockSize   map[string]string
+	Compression map[string]string
+}
+
+var (
+	diskNamePattern   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	diskSerialPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,20}$`)
+	// zvolCompressionPattern matches the compression values pool.dataset.create
+	// accepts for a ZVol.
+	zvolCompressionPattern = regexp.MustCompile(`^(OFF|LZ4|ZLE|LZJB|GZIP(-[1-9])?|ZSTD(-([1-9]|1[0-9]))?|ZSTD-FAST(-([1-9]|10|[2-9]0|100|500|1000))?)$`)
+)
+
+// diskDeviceOrder is the device order of the index-th disk: 1001 for the boot
+// disk, 1004 for the first data disk (the OpenEBS disk of the default
+// layout), then 1010+index.
+func diskDeviceOrder(index int) int {
+	switch index {
+	case 0:
+		return 1001
+	case 1:
+		return 1004
+	}
+	return 1010 + index
+}
+
+// zvolPathFor is the default ZVol of vmName's disk suffix under pool; a pool
+// that already ends in /VM is not given a second one.
+func zvolPathFor(pool, vmName, suffix string) string {
+	if strings.HasSuffix(pool, "/VM") {
+		return fmt.Sprintf("%s/%s-%s", pool, vmName, suffix)
+	}
+	return fmt.Sprintf("%s/VM/%s-%s", pool, vmName, suffix)
+}
+
+// vmDisks is config.Disks, or the boot and OpenEBS disks of the default
+// layout, with config.ZVol applied.
+func (vm *VMManager) vmDisks(config VMConfig) []VMDisk {
+	var disks []VMDisk
+	if len(config.Disks) > 0 {
+		disks = append(disks, config.Disks...)
+	} else {
+		paths := vm.getZVolPaths(config)
+		if path := paths["boot"]; path != "" {
+			disks = append(disks, VMDisk{Name: "boot", ZVol: path, SizeGB: config.DiskSize, Sparse: true, Order: diskDeviceOrder(0)})
+		}
+		if path := paths["openebs"]; path != "" {
+			disks = append(disks, VMDisk{Name: "OpenEBS", ZVol: path, SizeGB: config.OpenEBSSize, Sparse: true, Order: diskDeviceOrder(1)})
+		}
+	}
+	for i := range disks {
+		config.ZVol.apply(&disks[i])
+	}
+	return disks
+}
+
+func (o ZVolOptions) apply(disk *VMDisk) {
+	if o.Thick {
+		disk.Sparse = false
+	}
+	if disk.BlockSize == "" {
+		disk.BlockSize = optionFor(o.BlockSize, disk.Name)
+	}
+	if disk.Compression == "" {
+		disk.Compression = optionFor(o.Compression, disk.Name)
+	}
+}
+
+func optionFor(values map[string]string, disk string) string {
+	for name, value := range values {
+		if name != "" && strings.EqualFold(name, disk) {
+			return value
+		}
+	}
+	return values[""]
+}
+
+// validate rejects per-disk options naming none of disks.
+func (o ZVolOptions) validate(disks []VMDisk) error {
+	for _, option := range []struct {
+		flag   string
+		values map[string]string
+	}{{"--volblocksize", o.BlockSize}, {"--compression", o.Compression}} {
+		flag, values := option.flag, option.values
+		for _, name := range slices.Sorted(maps.Keys(values)) {
+			if name == "" || slices.ContainsFunc(disks, func(d VMDisk) bool { return strings.EqualFold(d.Name, name) }) {
+				continue
+			}
+			names := make([]string, len(disks))
+			for i, disk := range disks {
+				names[i] = strings.ToLower(disk.Name)
+			}
+			return fmt.Errorf("%s %s=%s: the VM has no disk %q (disks: %s)", flag, name, values[name], name, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+// ParseZVolOption parses a --volblocksize or --compression value: one value
+// for every disk ("16K"), or comma-separated disk=value pairs
+// ("boot=16K,openebs=64K") with an optional bare default among them. Values
+// are upper-cased and checked against what TrueNAS accepts; flag names the
+// option in errors.
+func ParseZVolOption(flag, value string) (map[string]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	valid, choices := func(v string) bool { return containsFold(vmSpecBlockSizes, v) }, strings.Join(vmSpecBlockSizes, ", ")
+	if flag == "compression" {
+		valid, choices = zvolCompressionPattern.MatchString, "OFF, LZ4, ZLE, LZJB, GZIP[-1..9], ZSTD[-1..19], ZSTD-FAST[-N]"
+	}
+	values := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		name, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			name, val = "", name
+		}
+		val = strings.ToUpper(val)
+		if !valid(val) {
+			return nil, fmt.Errorf("invalid --%s %q: %q is not one of %s", flag, value, val, choices)
+		}
+		if _, dup := values[strings.ToLower(name)]; dup {
+			if name == "" {
+				return nil, fmt.Errorf("invalid --%s %q: more than one value for every disk", flag, value)
+			}
+			return nil, fmt.Errorf("invalid --%s %q: disk %s is given twice", flag, value, name)
+		}
+		values[strings.ToLower(name)] = val
+	}
+	return values, nil
+}
+
+// ParseDataDisk parses one --data-disk value, comma-separated key=value
+// pairs: name and size (GB) are required, zvol (default
+// <pool>/VM/<vm>-<name>) and serial (default random) are optional.
+func ParseDataDisk(value string) (VMSpecDisk, error) {
+	var disk VMSpecDisk
+	for _, pair := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || val == "" {
+			return VMSpecDisk{}, fmt.Errorf("invalid data disk %q: %q is not key=value", value, pair)
+		}
+		switch key {
+		case "name":
+			if !diskNamePattern.MatchString(val) {
+				return VMSpecDisk{}, fmt.Errorf("invalid data disk %q: name %q may only use letters, digits, '.', '-' and '_'", value, val)
+			}
+			disk.Name = val
+		case "size":
+			size, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSuffix(val, "B"), "G"))
+			if err != nil || size <= 0 {
+				return VMSpecDisk{}, fmt.Errorf("invalid data disk %q: size %q must be a positive number of GB", value, val)
+			}
+			disk.SizeGB = size
+		case "zvol":
+			disk.ZVol = val
+		case "serial":
+			disk.Serial = val
+		default:
+			return VMSpecDisk{}, fmt.Errorf("invalid data disk %q: unknown key %q (use name, size, zvol, serial)", value, key)
+		}
+	}
+	if disk.Name == "" || disk.SizeGB == 0 {
+		return VMSpecDisk{}, fmt.Errorf("invalid data disk %q: name and size are required", value)
+	}
+	return disk, nil
+}
+
+// DataDiskSpec is the VM spec of a boot disk of bootGB followed by data, with
+// the ZVols left unset defaulted under pool. It is validated like a spec
+// file, so clashing names, ZVols or serials are reported together.
+func DataDiskSpec(vmName, pool string, bootGB int, data []VMSpecDisk) (*VMSpec, error) {
+	spec := &VMSpec{Disks: []VMSpecDisk{{Name: "boot", ZVol: zvolPathFor(pool, vmName, "boot"), SizeGB: bootGB}}}
+	for _, disk := range data {
+		if disk.ZVol == "" {
+			disk.ZVol = zvolPathFor(pool, vmName, disk.Name)
+		}
+		spec.Disks = append(spec.Disks, disk)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
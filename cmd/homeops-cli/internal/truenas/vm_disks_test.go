@@ -0,0 +1,148 @@
+package truenas
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeployVMWithTwoDataDisksCreatesExactlyThoseDisks(t *testing.T) {
+	ceph, err := ParseDataDisk("name=ceph,size=2000,zvol=tank/VM/k8s-0-ceph")
+	require.NoError(t, err)
+	scratch, err := ParseDataDisk("name=scratch,size=50G,serial=SCRATCH01")
+	require.NoError(t, err)
+	spec, err := DataDiskSpec("k8s-0", "flashstor", 250, []VMSpecDisk{ceph, scratch})
+	require.NoError(t, err)
+
+	manager := NewVMManager("nas", "key", 443, true)
+	datasets := map[string]bool{"flashstor": true, "flashstor/VM": true, "tank": true, "tank/VM": true}
+	var calls []string
+	manager.client.callFn = func(method string, params interface{}, _ int64) (json.RawMessage, error) {
+		args, _ := params.([]interface{})
+		switch method {
+		case "vm.query":
+			return mustJSON(map[string]any{"result": []map[string]any{}}), nil
+		case "pool.dataset.query":
+			var out []map[string]any
+			for name := range datasets {
+				out = append(out, map[string]any{"name": name})
+			}
+			return mustJSON(map[string]any{"result": out}), nil
+		case "pool.dataset.create":
+			zvol := datasetCreateArgs(params)
+			datasets[zvol["name"].(string)] = true
+			calls = append(calls, fmt.Sprintf("zvol %v %.0f", zvol["name"], zvol["volsize"]))
+			return mustJSON(map[string]any{"result": true}), nil
+		case "vm.create":
+			return mustJSON(map[string]any{"result": map[string]any{"id": 7, "name": "k8s-0"}}), nil
+		case "vm.device.create":
+			device := args[0].(map[string]interface{})
+			attrs := device["attributes"].(map[string]interface{})
+			if attrs["dtype"] == "DISK" {
+				calls = append(calls, fmt.Sprintf("disk %v %v", device["order"], attrs["path"]))
+				if attrs["path"] == "/dev/zvol/flashstor/VM/k8s-0-scratch" {
+					assert.Equal(t, "SCRATCH01", attrs["serial"])
+				}
+			}
+			return mustJSON(map[string]any{"result": map[string]any{"id": len(calls)}}), nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	}
+
+	config := VMConfig{
+		Name: "k8s-0", Memory: 8192, VCPUs: 4, DiskSize: 250, OpenEBSSize: 1000, StoragePool: "flashstor",
+		NetworkBridge: "br0", TalosISO: "/isos/talos.iso", SpicePassword: "secret", UseSpice: true,
+	}
+	spec.Apply(&config)
+	require.NoError(t, manager.DeployVM(config))
+	assert.Equal(t, []string{
+		fmt.Sprintf("zvol flashstor/VM/k8s-0-boot %d", int64(250)<<30),
+		fmt.Sprintf("zvol tank/VM/k8s-0-ceph %d", int64(2000)<<30),
+		fmt.Sprintf("zvol flashstor/VM/k8s-0-scratch %d", int64(50)<<30),
+		"disk 1001 /dev/zvol/flashstor/VM/k8s-0-boot",
+		"disk 1004 /dev/zvol/tank/VM/k8s-0-ceph",
+		"disk 1012 /dev/zvol/flashstor/VM/k8s-0-scratch",
+	}, calls, "no OpenEBS ZVol or disk")
+}
+
+func TestParseDataDiskRejectsBadValues(t *testing.T) {
+	for value, want := range map[string]string{
+		"name=ceph":                 "name and size are required",
+		"size=10":                   "name and size are required",
+		"name=ceph,size=0":          `size "0" must be a positive number of GB`,
+		"name=ceph,size=1T":         `size "1T" must be a positive number of GB`,
+		"name=../x,size=1":          `name "../x" may only use`,
+		"name=ceph,size=1,pool=a":   `unknown key "pool"`,
+		"name=ceph,size":            `"size" is not key=value`,
+		"name=ceph,size=1,zvol=":    `"zvol=" is not key=value`,
+		"name=ceph,size=1,serial=x": "",
+	} {
+		_, err := ParseDataDisk(value)
+		if want == "" {
+			assert.NoError(t, err, value)
+			continue
+		}
+		assert.ErrorContains(t, err, want, value)
+	}
+
+	disk, err := ParseDataDisk("name=boot,size=10")
+	require.NoError(t, err)
+	_, err = DataDiskSpec("k8s-0", "flashstor/VM", 250, []VMSpecDisk{disk})
+	require.EqualError(t, err, "disks[1].name: boot is already used by disks[0]\ndisks[1].zvol: flashstor/VM/k8s-0-boot is already used by disks[0]")
+}
+
+func TestDeployVMAppliesZVolOptionsPerDisk(t *testing.T) {
+	blockSize, err := ParseZVolOption("volblocksize", "16k,openebs=64K")
+	require.NoError(t, err)
+	compression, err := ParseZVolOption("compression", "openebs=zstd")
+	require.NoError(t, err)
+
+	manager, mutations := failingDisplayNAS(t, nil)
+	var zvols []map[string]interface{}
+	create := manager.client.callFn
+	manager.client.callFn = func(method string, params interface{}, timeout int64) (json.RawMessage, error) {
+		if method == "pool.dataset.create" {
+			zvols = append(zvols, datasetCreateArgs(params))
+		}
+		return create(method, params, timeout)
+	}
+	config := failingDisplayConfig
+	config.UseSpice = false
+	config.ZVol = ZVolOptions{BlockSize: blockSize, Compression: compression}
+	require.NoError(t, manager.DeployVM(config))
+	require.Len(t, zvols, 2, "the parent datasets already exist: %v", *mutations)
+	assert.Equal(t, map[string]interface{}{
+		"name": "flashstor/VM/cp-0-boot", "type": "VOLUME", "volsize": float64(int64(250) << 30),
+		"sparse": true, "volblocksize": "16K",
+	}, zvols[0], "a sparse ZVol is created without a refreservation")
+	assert.Equal(t, map[string]interface{}{
+		"name": "flashstor/VM/cp-0-openebs", "type": "VOLUME", "volsize": float64(int64(1000) << 30),
+		"sparse": true, "volblocksize": "64K", "compression": "ZSTD",
+	}, zvols[1])
+
+	zvols = nil
+	config.ZVol = ZVolOptions{Thick: true}
+	config.Name = "cp-1"
+	require.NoError(t, manager.DeployVM(config))
+	require.Len(t, zvols, 2)
+	assert.NotContains(t, zvols[0], "sparse", "--sparse=false leaves TrueNAS to reserve the full size")
+
+	config.ZVol = ZVolOptions{Compression: map[string]string{"rook": "LZ4"}}
+	config.Name = "cp-2"
+	require.EqualError(t, manager.DeployVM(config), `--compression rook=LZ4: the VM has no disk "rook" (disks: boot, openebs)`)
+}
+
+func TestParseZVolOptionRejectsValuesTrueNASDoesNot(t *testing.T) {
+	_, err := ParseZVolOption("volblocksize", "boot=12K")
+	require.EqualError(t, err, `invalid --volblocksize "boot=12K": "12K" is not one of 512, 1K, 2K, 4K, 8K, 16K, 32K, 64K, 128K`)
+	_, err = ParseZVolOption("compression", "gzip-10")
+	require.ErrorContains(t, err, `"GZIP-10" is not one of OFF, LZ4`)
+	_, err = ParseZVolOption("compression", "lz4,LZ4")
+	require.ErrorContains(t, err, "more than one value for every disk")
+	values, err := ParseZVolOption("compression", "zstd-fast-100")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"": "ZSTD-FAST-100"}, values)
+}
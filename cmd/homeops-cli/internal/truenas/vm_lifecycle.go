@@ -2,6 +2,7 @@ package truenas
 
 import (
 	"fmt"
+	"sort"
 
 	homeopscfg "homeops-cli/internal/config"
 	"homeops-cli/internal/provider"
@@ -26,25 +27,83 @@ func (vm *VMManager) VMIPAddresses(name string) ([]string, error) {
 	return nil, provider.Unsupported("truenas", trueNASIPReason)
 }
 
-// ConsoleURL returns the VM's display endpoint: the web (HTML5) console when
-// the display device has one, otherwise the native SPICE URL.
-func (vm *VMManager) ConsoleURL(name string) (string, error) {
+// VMNICMACs returns the MACs of the VM's NICs in device order, for callers
+// that find the guest's address on the network themselves (deploy-vm
+// --wait-for-ip).
+func (vm *VMManager) VMNICMACs(name string) ([]string, error) {
+	vmItem, err := vm.getVMByName(name)
+	if err != nil {
+		return nil, err
+	}
+	live, err := vm.client.GetVM(vmItem.ID)
+	if err != nil {
+		return nil, err
+	}
+	devices := append([]VMDevice(nil), live.Devices...)
+	sort.SliceStable(devices, func(i, j int) bool { return intAttr(devices[i], "order") < intAttr(devices[j], "order") })
+	var macs []string
+	for _, device := range devices {
+		attributes, _ := device["attributes"].(map[string]interface{})
+		if mac, _ := attributes["mac"].(string); attributes["dtype"] == "NIC" && mac != "" {
+			macs = append(macs, mac)
+		}
+	}
+	if len(macs) == 0 {
+		return nil, fmt.Errorf("VM %s has no NIC with a MAC address", name)
+	}
+	return macs, nil
+}
+
+// ConsoleInfo is a VM's display device with the URLs that reach it. A port
+// the middleware has not assigned yet (the VM is stopped) leaves its URL
+// empty.
+type ConsoleInfo struct {
+	DisplayInfo
+	Host     string // address the URLs use
+	SpiceURL string // native client (remote-viewer)
+	WebURL   string // HTML5 console in a browser
+}
+
+// ConsoleInfo returns the VM's display endpoints and console URLs. The host
+// is hypervisors.truenas.spice_host, else the device's bind address unless it
+// is a wildcard, else the NAS.
+func (vm *VMManager) ConsoleInfo(name string) (*ConsoleInfo, error) {
 	info, err := vm.VMDisplayInfo(name)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	host := homeopscfg.Get().Hypervisors.TrueNAS.SpiceHost
-	if host == "" && info.Bind != "" && info.Bind != "0.0.0.0" && info.Bind != "::" {
-		host = info.Bind
+	return vm.consoleInfo(*info), nil
+}
+
+func (vm *VMManager) consoleInfo(info DisplayInfo) *ConsoleInfo {
+	console := &ConsoleInfo{DisplayInfo: info, Host: homeopscfg.Get().Hypervisors.TrueNAS.SpiceHost}
+	if console.Host == "" && info.Bind != "" && info.Bind != "0.0.0.0" && info.Bind != "::" {
+		console.Host = info.Bind
 	}
-	if host == "" {
-		host = vm.client.host
+	if console.Host == "" {
+		console.Host = vm.client.host
+	}
+	if info.Port > 0 {
+		console.SpiceURL = fmt.Sprintf("spice://%s:%d", console.Host, info.Port)
 	}
 	if info.WebPort > 0 {
-		return fmt.Sprintf("https://%s:%d", host, info.WebPort), nil
+		console.WebURL = fmt.Sprintf("https://%s:%d/spice_auto.html", console.Host, info.WebPort)
 	}
-	if info.Port > 0 {
-		return fmt.Sprintf("spice://%s:%d", host, info.Port), nil
+	return console
+}
+
+// ConsoleURL returns the VM's display endpoint: the web (HTML5) console when
+// the display device has one, otherwise the native SPICE URL.
+func (vm *VMManager) ConsoleURL(name string) (string, error) {
+	console, err := vm.ConsoleInfo(name)
+	if err != nil {
+		return "", err
+	}
+	if console.WebURL != "" {
+		return console.WebURL, nil
+	}
+	if console.SpiceURL != "" {
+		return console.SpiceURL, nil
 	}
 	return "", fmt.Errorf("VM %s has a display device but no assigned ports (is the VM running?)", name)
 }
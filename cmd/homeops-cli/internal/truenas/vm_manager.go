@@ -1,6 +1,7 @@
 package truenas
 
 import (
+	"context"
 	crypto_rand "crypto/rand"
 	"fmt"
 	"slices"
@@ -51,6 +52,51 @@ type VMConfig struct {
 	// Ignition reads on first boot. Setting Flatcar selects this create path.
 	Flatcar      bool
 	IgnitionPath string // host path on the NAS to the rendered Ignition (.ign) file
+
+	// SerialLog, when set, attaches an extra serial port logging to a file on
+	// the NAS (see serial_log.go). Callers gate it on CheckSerialLogSupport.
+	SerialLog *SerialLogDevice
+
+	// ConfigISO is the NAS path of a metal-iso config volume (see
+	// config_iso.go) attached as a second CD-ROM so the node configures
+	// itself on first boot. Empty leaves the node in maintenance mode.
+	ConfigISO string
+
+	// TPM attaches a virtual TPM, which Talos needs to seal its disk
+	// encryption key with cluster.talos.disk_encryption.provider tpm.
+	TPM bool
+
+	// CPU pins the VM to host CPUs / NUMA nodes and picks the CPU mode (see
+	// cpu_placement.go). The zero value is unpinned host-passthrough.
+	CPU CPUPlacement
+
+	// ZVol sets the provisioning, volblocksize and compression of the ZVols
+	// DeployVM creates (see vm_disks.go). The zero value is sparse with the
+	// pool's defaults.
+	ZVol ZVolOptions
+
+	// KeepOnFailure leaves whatever a failed DeployVM created in place for
+	// inspection instead of rolling it back (see deploy_rollback.go).
+	KeepOnFailure bool
+
+	// Update reconciles a VM that already exists with this config instead
+	// of failing (see vm_update.go).
+	Update bool
+
+	// Start powers a newly created VM on and waits for it to be RUNNING
+	// (see vm_start.go); Autostart sets the VM to boot with the NAS.
+	Start     bool
+	Autostart bool
+
+	// Disks and NICs, when set (from a VM spec or --data-disk, see
+	// vm_spec.go and vm_disks.go), replace the boot/OpenEBS disk pair and the
+	// single NIC.
+	Disks []VMDisk
+	NICs  []VMNIC
+	// SpiceBind and SpiceResolution override the display defaults
+	// (hypervisors.truenas.spice_host, 1920x1080).
+	SpiceBind       string
+	SpiceResolution string
 }
 
 // GetDefaultVMConfig returns the effective TrueNAS VM defaults from
@@ -84,11 +130,16 @@ func GetDefaultVMConfig(name string) VMConfig {
 type VMManager struct {
 	client *WorkingClient
 	logger *common.ColorLogger
+	// rollback records what the running DeployVM has created; nil outside
+	// a deploy.
+	rollback *deployRollback
+	// stopTimeout is the SetStopTimeout wait; zero is DefaultVMStopTimeout.
+	stopTimeout time.Duration
 }
 
-// NewVMManager creates a new VM manager
-func NewVMManager(host, apiKey string, port int, useSSL bool) *VMManager {
-	client := NewWorkingClient(host, apiKey, port, useSSL)
+// NewVMManager creates a new VM manager; opts configure its client.
+func NewVMManager(host, apiKey string, port int, useSSL bool, opts ...ClientOption) *VMManager {
+	client := NewWorkingClient(host, apiKey, port, useSSL, opts...)
 	return &VMManager{
 		client: client,
 		logger: common.NewColorLogger(),
@@ -100,13 +151,29 @@ func (vm *VMManager) Connect() error {
 	return vm.client.Connect()
 }
 
+// SetContext bounds every TrueNAS call the manager makes by ctx.
+func (vm *VMManager) SetContext(ctx context.Context) {
+	vm.client.SetContext(ctx)
+}
+
+// SetJobProgress reports the progress of every middleware job the manager
+// waits for (vm.create, vm.stop, ...) to fn; nil stops reporting.
+func (vm *VMManager) SetJobProgress(fn JobProgressFunc) {
+	vm.client.SetJobProgress(fn)
+}
+
 // Close closes the connection
 func (vm *VMManager) Close() error {
 	return vm.client.Close()
 }
 
+// Ping checks that the manager's connection is still up.
+func (vm *VMManager) Ping() error {
+	return vm.client.Ping()
+}
+
 // DeployVM deploys a new VM with the specified configuration
-func (vm *VMManager) DeployVM(config VMConfig) error {
+func (vm *VMManager) DeployVM(config VMConfig) (err error) {
 	vm.logger.Info("Starting VM deployment: %s", config.Name)
 
 	// Check if VM already exists
@@ -115,20 +182,75 @@ func (vm *VMManager) DeployVM(config VMConfig) error {
 		return fmt.Errorf("failed to query existing VMs: %w", err)
 	}
 
-	for _, existingVM := range allVMs {
-		if existingVM.Name == config.Name {
-			return fmt.Errorf("VM with name '%s' already exists", config.Name)
+	var existing *VM
+	for i := range allVMs {
+		if allVMs[i].Name == config.Name {
+			existing = &allVMs[i]
+			break
 		}
 	}
+	if existing != nil && !config.Update {
+		return fmt.Errorf("VM with name '%s' already exists", config.Name)
+	}
+
+	if err := config.CPU.Validate(config.VCPUs); err != nil {
+		return err
+	}
+	if err := vm.checkCPUModel(config.CPU.CPUModel); err != nil {
+		return err
+	}
+	if err := config.ZVol.validate(vm.vmDisks(config)); err != nil {
+		return err
+	}
+	vm.warnCPUSetOverlaps(config.CPU.CPUSet, config.Name, allVMs)
+	macOwners := nicMACOwners(allVMs, config.Name)
+	if err := checkNICMACs(config, macOwners); err != nil {
+		return err
+	}
+	if existing != nil {
+		return vm.updateVM(existing, config)
+	}
+	if err := vm.assignNICMACs(&config, macOwners); err != nil {
+		return err
+	}
+
+	vmID, err := vm.createVM(config)
+	if err != nil {
+		return err
+	}
+	if vm.dryRunning() {
+		vm.logger.Info("Planned deployment of VM: %s", config.Name)
+	} else {
+		vm.logger.Success("Successfully deployed VM: %s", config.Name)
+	}
+	if config.Start {
+		return vm.startDeployedVM(vmID, config)
+	}
+	return nil
+}
+
+// createVM creates config's ZVols, VM and devices, rolling back whatever it
+// created if a step fails.
+func (vm *VMManager) createVM(config VMConfig) (vmID int, err error) {
+	// From here on every created resource is recorded and removed again if
+	// the deploy fails.
+	vm.rollback = &deployRollback{}
+	defer func() {
+		created := vm.rollback
+		vm.rollback = nil
+		if err != nil {
+			err = vm.rollBack(created, config, err)
+		}
+	}()
 
 	// Create ZVols if not skipping
 	if !config.SkipZVolCreate {
 		if err := vm.createZVols(config); err != nil {
-			return fmt.Errorf("failed to create ZVols: %w", err)
+			return 0, fmt.Errorf("failed to create ZVols: %w", err)
 		}
 	} else {
 		if err := vm.verifyZVols(config); err != nil {
-			return fmt.Errorf("failed to verify ZVols: %w", err)
+			return 0, fmt.Errorf("failed to verify ZVols: %w", err)
 		}
 	}
 
@@ -138,18 +260,17 @@ func (vm *VMManager) DeployVM(config VMConfig) error {
 	// Create the VM
 	createdVM, err := vm.client.CreateVM(vmConfig)
 	if err != nil {
-		return fmt.Errorf("failed to create VM: %w", err)
+		return 0, fmt.Errorf("failed to create VM: %w", err)
 	}
 
 	vm.logger.Info("VM created with ID: %d", createdVM.ID)
+	vm.rollback.add(createdResource{kind: rollbackVM, id: createdVM.ID, name: config.Name})
 
 	// Create VM devices
 	if err := vm.createVMDevices(createdVM.ID, config); err != nil {
-		return fmt.Errorf("failed to create VM devices: %w", err)
+		return 0, fmt.Errorf("failed to create VM devices: %w", err)
 	}
-
-	vm.logger.Success("Successfully deployed VM: %s", config.Name)
-	return nil
+	return createdVM.ID, nil
 }
 
 // ListVMs lists all VMs
@@ -198,11 +319,47 @@ func summarizeTrueNASVMs(vms []VM) []provider.VMSummary {
 			MemoryMB: vmItem.Memory,
 			CPUs:     vmItem.VCPUs,
 			Details:  map[string]string{"autostart": autostart},
+			Devices:  summarizeTrueNASDevices(vmItem.Devices),
+			ZVols:    vmZVolPaths(vmItem.Devices),
 		})
 	}
 	return summaries
 }
 
+// summarizeTrueNASDevices types a VM's devices for machine-readable output,
+// in device order.
+func summarizeTrueNASDevices(devices []VMDevice) []provider.VMDevice {
+	out := make([]provider.VMDevice, 0, len(devices))
+	for _, device := range devices {
+		attributes, _ := device["attributes"].(map[string]interface{})
+		summary := provider.VMDevice{Order: intAttr(device, "order")}
+		summary.Type, _ = attributes["dtype"].(string)
+		if id := intAttr(device, "id"); id != 0 {
+			summary.ID = fmt.Sprintf("%d", id)
+		}
+		summary.Path, _ = attributes["path"].(string)
+		summary.MAC, _ = attributes["mac"].(string)
+		summary.Network, _ = attributes["nic_attach"].(string)
+		if summary.Type == "NIC" || summary.Type == "DISPLAY" {
+			summary.Model, _ = attributes["type"].(string)
+		}
+		out = append(out, summary)
+	}
+	slices.SortStableFunc(out, func(a, b provider.VMDevice) int { return a.Order - b.Order })
+	return out
+}
+
+// vmZVolPaths lists the ZVols behind a VM's DISK devices.
+func vmZVolPaths(devices []VMDevice) []string {
+	var zvols []string
+	for _, device := range devices {
+		if zvol, ok := extractZVolPathFromDevice(device); ok {
+			zvols = append(zvols, zvol)
+		}
+	}
+	return uniqueSortedStrings(zvols)
+}
+
 // VMSummaries returns the inventory in the provider-neutral shape.
 func (vm *VMManager) VMSummaries() ([]provider.VMSummary, error) {
 	vms, err := vm.client.QueryVMs(nil)
@@ -229,7 +386,8 @@ func (vm *VMManager) StartVM(name string) error {
 	return nil
 }
 
-// StopVM stops a VM by name
+// StopVM stops a VM by name: a clean shutdown that is forced after the stop
+// timeout, or a forced power off at once with force.
 func (vm *VMManager) StopVM(name string, force bool) error {
 	vmItem, err := vm.getVMByName(name)
 	if err != nil {
@@ -242,7 +400,7 @@ func (vm *VMManager) StopVM(name string, force bool) error {
 	}
 	vm.logger.Info("%s VM: %s (ID: %d)", action, vmItem.Name, vmItem.ID)
 
-	if err := vm.stopVMByMode(vmItem.ID, force); err != nil {
+	if err := vm.stopVM(vmItem, force); err != nil {
 		return fmt.Errorf("failed to stop VM: %w", err)
 	}
 
@@ -250,8 +408,12 @@ func (vm *VMManager) StopVM(name string, force bool) error {
 	return nil
 }
 
-// DeleteVM deletes a VM by name
-func (vm *VMManager) DeleteVM(name string, deleteZVol bool, storagePool string) error {
+// DeleteVM deletes a VM by name and, with deleteZVol, exactly the ZVols its
+// DISK devices are backed by. They are looked up from the VM before it is
+// deleted rather than guessed from its name, so a data disk with a custom
+// ZVol is removed too and another VM's ZVols are never matched; the storage
+// pool argument is no longer needed for that.
+func (vm *VMManager) DeleteVM(name string, deleteZVol bool, _ string) error {
 	vmItem, err := vm.getVMByName(name)
 	if err != nil {
 		return err
@@ -259,54 +421,28 @@ func (vm *VMManager) DeleteVM(name string, deleteZVol bool, storagePool string)
 
 	vm.logger.Info("Deleting VM: %s (ID: %d)", vmItem.Name, vmItem.ID)
 
-	// Discover ZVols BEFORE deleting the VM
-	// This ensures we can still query the VM's devices
 	var zvolPaths []string
 	if deleteZVol {
-		vm.logger.Info("ZVol deletion requested for VM %s", name)
-
-		// Try primary discovery through VM devices
-		zvolPaths, err = vm.discoverVMZVols(vmItem)
-		if err != nil {
-			vm.logger.Warn("Primary ZVol discovery failed for VM %s: %v", name, err)
-		} else if len(zvolPaths) > 0 {
-			vm.logger.Success("Primary discovery found %d ZVols: %v", len(zvolPaths), zvolPaths)
-		} else {
-			vm.logger.Info("Primary discovery found no ZVols, will try pattern matching")
+		// A VM whose disks cannot be listed is kept, so its ZVols are not
+		// orphaned.
+		if zvolPaths, err = vm.discoverVMZVols(vmItem); err != nil {
+			return fmt.Errorf("refusing to delete VM %s: could not list its ZVols: %w", name, err)
 		}
-
-		// If primary discovery didn't find ZVols, try pattern matching immediately
-		if len(zvolPaths) == 0 {
-			vm.logger.Info("Attempting pattern-based ZVol discovery for VM %s", name)
-			fallbackPaths := vm.discoverZVolsByPattern(storagePool, name)
-			if len(fallbackPaths) > 0 {
-				vm.logger.Success("Pattern matching found %d ZVols: %v", len(fallbackPaths), fallbackPaths)
-				zvolPaths = fallbackPaths
-			} else {
-				vm.logger.Warn("No ZVols found using pattern matching")
-				// Last resort: try querying all VOLUMEs and filter by VM name
-				vm.logger.Info("Attempting comprehensive ZVol search for VM %s", name)
-				datasets, err := vm.client.QueryDatasets(nil)
-				if err == nil {
-					for _, dataset := range datasets {
-						if dataset.Type == "VOLUME" && strings.Contains(dataset.Name, name) {
-							vm.logger.Info("Found potential ZVol: %s", dataset.Name)
-							zvolPaths = append(zvolPaths, dataset.Name)
-						}
-					}
-					if len(zvolPaths) > 0 {
-						vm.logger.Success("Comprehensive search found %d potential ZVols", len(zvolPaths))
-					}
-				}
-			}
+		if len(zvolPaths) > 0 {
+			vm.logger.Info("Will delete the following ZVols after VM deletion: %v", zvolPaths)
 		}
 	} else {
 		vm.logger.Info("ZVol deletion not requested for VM %s", name)
 	}
 
-	// Log discovered ZVols before VM deletion
-	if deleteZVol && len(zvolPaths) > 0 {
-		vm.logger.Info("Will attempt to delete the following ZVols after VM deletion: %v", zvolPaths)
+	if vm.dryRunning() {
+		return vm.planDeleteVM(vmItem, zvolPaths)
+	}
+
+	// A running VM is shut down first; one that cannot be stopped is not
+	// deleted out from under its guest.
+	if err := vm.stopVM(vmItem, false); err != nil {
+		return fmt.Errorf("refusing to delete VM %s: it could not be stopped: %w", name, err)
 	}
 
 	// Delete the VM
@@ -348,14 +484,36 @@ func (vm *VMManager) DeleteVM(name string, deleteZVol bool, storagePool string)
 		} else {
 			vm.logger.Success("All %d ZVols deleted successfully", len(zvolPaths))
 		}
-	} else if deleteZVol && len(zvolPaths) == 0 {
-		vm.logger.Warn("No ZVols were found for VM %s - they may have been manually deleted or may require manual cleanup", name)
+	} else if deleteZVol {
+		vm.logger.Info("VM %s had no ZVol-backed disks", name)
 	}
 
 	vm.logger.Success("VM %s deletion completed", name)
 	return nil
 }
 
+// planDeleteVM records the calls DeleteVM would make for vmItem: the stop
+// of a running VM, its deletion and that of each of zvolPaths. The waits
+// and checks between them need the calls to have happened, so they are
+// skipped.
+func (vm *VMManager) planDeleteVM(vmItem *VM, zvolPaths []string) error {
+	if vmState(vmItem) != "STOPPED" {
+		if err := vm.client.StopVM(vmItem.ID); err != nil {
+			return err
+		}
+	}
+	if err := vm.client.DeleteVM(vmItem.ID); err != nil {
+		return err
+	}
+	for _, zvolPath := range zvolPaths {
+		if err := vm.client.DeleteDataset(zvolPath, true); err != nil {
+			return err
+		}
+	}
+	vm.logger.Info("Planned deletion of VM %s and %d ZVols", vmItem.Name, len(zvolPaths))
+	return nil
+}
+
 // GetVMInfo displays detailed information about a VM
 func (vm *VMManager) GetVMInfo(name string) error {
 	vmItem, err := vm.getVMByName(name)
@@ -382,9 +540,32 @@ func (vm *VMManager) GetVMInfo(name string) error {
 		}
 	}
 
+	for _, device := range vmItem.Devices {
+		attributes, _ := device["attributes"].(map[string]interface{})
+		if info := displayDeviceInfo(attributes); info != nil {
+			printConsoleInfo(vm.consoleInfo(*info))
+			break
+		}
+	}
+
 	return nil
 }
 
+// printConsoleInfo prints a display device's endpoints and console URLs.
+func printConsoleInfo(console *ConsoleInfo) {
+	fmt.Printf("\nConsole (%s, bind %s):\n", console.Type, console.Bind)
+	if console.SpiceURL == "" && console.WebURL == "" {
+		fmt.Println("  no ports assigned (is the VM running?)")
+		return
+	}
+	if console.SpiceURL != "" {
+		fmt.Printf("  SPICE: %s (port %d)\n", console.SpiceURL, console.Port)
+	}
+	if console.WebURL != "" {
+		fmt.Printf("  Web:   %s (web_port %d)\n", console.WebURL, console.WebPort)
+	}
+}
+
 // Helper methods
 
 func (vm *VMManager) getVMByName(name string) (*VM, error) {
@@ -405,37 +586,28 @@ func (vm *VMManager) getVMByName(name string) (*VM, error) {
 func (vm *VMManager) createZVols(config VMConfig) error {
 	vm.logger.Info("Creating ZVols...")
 
-	zvolPaths := vm.getZVolPaths(config)
-
-	// Create boot ZVol (250GB) - for TalosOS
-	if err := vm.createSingleZVol(zvolPaths["boot"], config.DiskSize, "boot"); err != nil {
-		return err
-	}
-
-	// Create OpenEBS ZVol (1TB) - for local storage
-	if err := vm.createSingleZVol(zvolPaths["openebs"], config.OpenEBSSize, "OpenEBS"); err != nil {
-		return err
+	for _, disk := range vm.vmDisks(config) {
+		if err := vm.createZVol(disk); err != nil {
+			return err
+		}
 	}
-
 	return nil
 }
 
 func (vm *VMManager) verifyZVols(config VMConfig) error {
 	vm.logger.Info("Verifying ZVols exist...")
 
-	zvolPaths := vm.getZVolPaths(config)
-
-	for zvolType, zvolPath := range zvolPaths {
-		datasets, err := vm.client.QueryDatasets([][]interface{}{{"name", "=", zvolPath}})
+	for _, disk := range vm.vmDisks(config) {
+		datasets, err := vm.client.QueryDatasets([][]interface{}{{"name", "=", disk.ZVol}})
 		if err != nil {
-			return fmt.Errorf("failed to query %s ZVol %s: %w", zvolType, zvolPath, err)
+			return fmt.Errorf("failed to query %s ZVol %s: %w", disk.Name, disk.ZVol, err)
 		}
 
 		if len(datasets) == 0 {
-			return fmt.Errorf("%s ZVol %s does not exist", zvolType, zvolPath)
+			return fmt.Errorf("%s ZVol %s does not exist", disk.Name, disk.ZVol)
 		}
 
-		vm.logger.Info("✓ %s ZVol verified: %s", zvolType, zvolPath)
+		vm.logger.Info("✓ %s ZVol verified: %s", disk.Name, disk.ZVol)
 	}
 
 	return nil
@@ -447,12 +619,7 @@ func (vm *VMManager) getZVolPaths(config VMConfig) map[string]string {
 	if config.BootZVol != "" {
 		paths["boot"] = config.BootZVol
 	} else {
-		// Check if StoragePool already contains /VM to avoid duplication
-		if strings.HasSuffix(config.StoragePool, "/VM") {
-			paths["boot"] = fmt.Sprintf("%s/%s-boot", config.StoragePool, config.Name)
-		} else {
-			paths["boot"] = fmt.Sprintf("%s/VM/%s-boot", config.StoragePool, config.Name)
-		}
+		paths["boot"] = zvolPathFor(config.StoragePool, config.Name, "boot")
 	}
 
 	// Flatcar nodes boot from a single pre-staged image disk; only attach (and
@@ -464,19 +631,25 @@ func (vm *VMManager) getZVolPaths(config VMConfig) map[string]string {
 	if config.OpenEBSZVol != "" {
 		paths["openebs"] = config.OpenEBSZVol
 	} else {
-		// Check if StoragePool already contains /VM to avoid duplication
-		if strings.HasSuffix(config.StoragePool, "/VM") {
-			paths["openebs"] = fmt.Sprintf("%s/%s-openebs", config.StoragePool, config.Name)
-		} else {
-			paths["openebs"] = fmt.Sprintf("%s/VM/%s-openebs", config.StoragePool, config.Name)
-		}
+		paths["openebs"] = zvolPathFor(config.StoragePool, config.Name, "openebs")
 	}
 
 	return paths
 }
 
 func (vm *VMManager) createSingleZVol(zvolPath string, sizeGB int, zvolType string) error {
-	vm.logger.Info("Creating thin provisioned %s ZVol: %s (%dGB)", zvolType, zvolPath, sizeGB)
+	return vm.createZVol(VMDisk{Name: zvolType, ZVol: zvolPath, SizeGB: sizeGB, Sparse: true})
+}
+
+// createZVol creates disk's ZVol and any missing parent datasets; an
+// existing ZVol is left as it is.
+func (vm *VMManager) createZVol(disk VMDisk) error {
+	zvolPath, sizeGB, zvolType := disk.ZVol, disk.SizeGB, disk.Name
+	provisioning := "thin provisioned"
+	if !disk.Sparse {
+		provisioning = "thick provisioned"
+	}
+	vm.logger.Info("Creating %s %s ZVol: %s (%dGB)", provisioning, zvolType, zvolPath, sizeGB)
 
 	// Check if ZVol already exists
 	allDatasets, err := vm.client.QueryDatasets(nil)
@@ -523,8 +696,8 @@ func (vm *VMManager) createSingleZVol(zvolPath string, sizeGB int, zvolType stri
 				"name": parentPath,
 				"type": "FILESYSTEM",
 			}
-			_, err := vm.client.Call("pool.dataset.create", []interface{}{parentConfig}, 60)
-			if err != nil {
+			// Another deploy may have created it since the query.
+			if _, err := vm.client.Call("pool.dataset.create", []interface{}{parentConfig}, 60); err != nil && !IsAlreadyExists(err) {
 				return fmt.Errorf("failed to create parent dataset %s: %w", parentPath, err)
 			}
 		}
@@ -533,22 +706,30 @@ func (vm *VMManager) createSingleZVol(zvolPath string, sizeGB int, zvolType stri
 	// Use the specified size
 	volsize := int64(sizeGB) * 1024 * 1024 * 1024 // Convert GB to bytes
 
-	vm.logger.Info("Creating thin provisioned %s ZVol: %s (%.1fGB)", zvolType, zvolPath, float64(volsize)/(1024*1024*1024))
+	vm.logger.Info("Creating %s %s ZVol: %s (%.1fGB)", provisioning, zvolType, zvolPath, float64(volsize)/(1024*1024*1024))
 
-	// Create thin provisioned ZVol with basic parameters - matching the working script
-	zvolConfig := map[string]interface{}{
-		"name":    zvolPath,
-		"type":    "VOLUME",
-		"volsize": volsize,
-		"sparse":  true, // Enable thin provisioning - this is the critical missing piece!
+	zvolConfig := DatasetCreateRequest{
+		Name:         zvolPath,
+		Type:         "VOLUME",
+		Volsize:      &volsize,
+		Volblocksize: disk.BlockSize,
+		Sparse:       disk.Sparse,
+		Compression:  disk.Compression,
 	}
 
 	_, err = vm.client.Call("pool.dataset.create", []interface{}{zvolConfig}, 60)
+	if IsAlreadyExists(err) {
+		// Created since the query; like a ZVol found there, it is not ours
+		// to roll back.
+		vm.logger.Info("✓ %s ZVol already exists: %s", zvolType, zvolPath)
+		return nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to create thin provisioned ZVol: %w", err)
+		return fmt.Errorf("failed to create %s ZVol: %w", provisioning, err)
 	}
+	vm.rollback.add(createdResource{kind: rollbackZVol, name: zvolPath})
 
-	vm.logger.Success("✓ Created thin provisioned %s ZVol: %s (%dGB)", zvolType, zvolPath, sizeGB)
+	vm.logger.Success("✓ Created %s %s ZVol: %s (%dGB)", provisioning, zvolType, zvolPath, sizeGB)
 	return nil
 }
 
@@ -563,22 +744,18 @@ func (vm *VMManager) buildVMConfig(config VMConfig) map[string]interface{} {
 		"memory":                        config.Memory,
 		"bootloader":                    "UEFI",
 		"bootloader_ovmf":               "OVMF_CODE.fd",
-		"autostart":                     false,
+		"autostart":                     config.Autostart,
 		"time":                          "LOCAL",
 		"shutdown_timeout":              90,
-		"cpu_mode":                      "HOST-PASSTHROUGH",
-		"cpu_model":                     nil,
-		"cpuset":                        "",
-		"nodeset":                       "",
 		"enable_cpu_topology_extension": false,
-		"pin_vcpus":                     false,
 		"suspend_on_snapshot":           false,
-		"trusted_platform_module":       false,
+		"trusted_platform_module":       config.TPM,
 		"min_memory":                    nil,
 		"hyperv_enlightenments":         false,
 		"command_line_args":             "",
 		"arch_type":                     nil,
 	}
+	config.CPU.apply(vmConfig)
 
 	// Flatcar: boot a pre-staged image and deliver Ignition through qemu fw_cfg.
 	// TrueNAS appends command_line_args to the qemu invocation (libvirt
@@ -590,6 +767,9 @@ func (vm *VMManager) buildVMConfig(config VMConfig) map[string]interface{} {
 				"-fw_cfg name=opt/org.flatcar-linux/config,file=%s", config.IgnitionPath)
 		}
 	}
+	if config.SerialLog != nil {
+		vmConfig["command_line_args"] = joinCommandLineArgs(vmConfig["command_line_args"].(string), config.SerialLog.CommandLineArgs())
+	}
 
 	return vmConfig
 }
@@ -633,76 +813,48 @@ func (vm *VMManager) createVMDevices(vmID int, config VMConfig) error {
 
 	// Create CD-ROM device first (order 1006) - avoid boot issues with 1000
 	// Use the TalosISO path from config to support both default and custom ISOs
-	isoPath := config.TalosISO
-	if isoPath == "" {
-		isoPath = homeopscfg.Get().TrueNASISOPath()
-	}
+	isoPath := installISOPath(config)
 
-	if err := vm.createVMDevice(vmID, 1006, map[string]interface{}{
-		"dtype": "CDROM",
-		"path":  isoPath,
-	}); err != nil {
+	if err := vm.createVMDevice(vmID, 1006, cdromDeviceAttributes(isoPath)); err != nil {
 		return fmt.Errorf("failed to create CD-ROM device: %w", err)
 	}
 	vm.logger.Info("Created CD-ROM device with ISO: %s", isoPath)
 
-	// Create network device (order 1002) - matching working script structure
-	if err := vm.createVMDevice(vmID, 1002, map[string]interface{}{
-		"dtype":                  "NIC",
-		"type":                   "VIRTIO",
-		"mac":                    macAddress,
-		"nic_attach":             config.NetworkBridge,
-		"trust_guest_rx_filters": false,
-	}); err != nil {
-		return fmt.Errorf("failed to create NIC device: %w", err)
+	if config.ConfigISO != "" {
+		if err := vm.createVMDevice(vmID, configISODeviceOrder, cdromDeviceAttributes(config.ConfigISO)); err != nil {
+			return fmt.Errorf("failed to create config CD-ROM device: %w", err)
+		}
+		vm.logger.Info("Created config CD-ROM device with machine config: %s", config.ConfigISO)
 	}
-	vm.logger.Info("Created NIC device with MAC %s on bridge %s", macAddress, config.NetworkBridge)
-
-	// Create disk devices with correct order matching working script
-	zvolPaths := vm.getZVolPaths(config)
 
-	// Boot/OpenEBS disk (order 1001) - 250GB combined disk
-	if bootPath, exists := zvolPaths["boot"]; exists && bootPath != "" {
-		if err := vm.createVMDevice(vmID, 1001, vm.buildDiskDeviceAttributes(bootPath)); err != nil {
-			return fmt.Errorf("failed to create boot/OpenEBS disk device: %w", err)
+	if len(config.NICs) > 0 {
+		if err := vm.createSpecNICs(vmID, config.NICs); err != nil {
+			return err
 		}
-		vm.logger.Info("Created boot/OpenEBS disk device (%dGB): /dev/zvol/%s", config.DiskSize, bootPath)
+	} else {
+		// Create network device (order 1002) - matching working script structure
+		if err := vm.createVMDevice(vmID, 1002, nicDeviceAttributes(macAddress, config.NetworkBridge)); err != nil {
+			return fmt.Errorf("failed to create NIC device: %w", err)
+		}
+		vm.logger.Info("Created NIC device with MAC %s on bridge %s", macAddress, config.NetworkBridge)
 	}
 
-	// OpenEBS disk (order 1004) - 1TB disk for local storage
-	if openebsPath, exists := zvolPaths["openebs"]; exists && openebsPath != "" {
-		if err := vm.createVMDevice(vmID, 1004, vm.buildDiskDeviceAttributes(openebsPath)); err != nil {
-			return fmt.Errorf("failed to create OpenEBS disk device: %w", err)
-		}
-		vm.logger.Info("Created OpenEBS disk device (%dGB): /dev/zvol/%s", config.OpenEBSSize, openebsPath)
+	// Create disk devices: the boot disk (order 1001) and the data disks
+	if err := vm.createDiskDevices(vmID, vm.vmDisks(config)); err != nil {
+		return err
 	}
 
 	if config.UseSpice {
 		if config.SpicePassword == "" {
 			return fmt.Errorf("SPICE password is required for display device")
 		}
-		// Bind address comes from hypervisors.truenas.spice_host in
-		// homeops.yaml; all interfaces when unset.
-		spiceBind := homeopscfg.Get().Hypervisors.TrueNAS.SpiceHost
-		if spiceBind == "" {
-			spiceBind = "0.0.0.0"
-		}
-		if err := vm.createVMDevice(vmID, 1003, map[string]interface{}{
-			"bind":       spiceBind,
-			"dtype":      "DISPLAY",
-			"password":   config.SpicePassword,
-			"port":       nil,
-			"resolution": "1920x1080",
-			"type":       "SPICE",
-			"wait":       false,
-			"web":        true,
-			"web_port":   nil,
-		}); err != nil {
+		spiceBind := config.spiceBind()
+		if err := vm.createVMDevice(vmID, 1003, spiceDisplayAttributes(config)); err != nil {
 			return fmt.Errorf("failed to create display device: %w", err)
 		}
 
 		vm.logger.Info("Created SPICE display device with password from config")
-		vm.logger.Info("Display access: SPICE://%s:[auto-assigned] (web: https://%s:[auto-assigned])", spiceBind, spiceBind)
+		vm.logger.Info("Display access: SPICE://%s (ports assigned at start; 'homeops-cli vm truenas console-info --name %s' prints the URLs)", spiceBind, config.Name)
 	} else {
 		vm.logger.Info("Skipping SPICE display device for VM %s", config.Name)
 	}
@@ -718,46 +870,73 @@ func (vm *VMManager) createVMDevices(vmID int, config VMConfig) error {
 func (vm *VMManager) createFlatcarVMDevices(vmID int, config VMConfig) error {
 	vm.logger.Info("Creating Flatcar VM devices...")
 
-	zvolPaths := vm.getZVolPaths(config)
-	bootPath := zvolPaths["boot"]
-	if bootPath == "" {
+	disks := vm.vmDisks(config)
+	if len(disks) == 0 || disks[0].ZVol == "" {
 		return fmt.Errorf("flatcar deploy requires a pre-staged boot zvol (set BootZVol)")
 	}
 
 	// Boot disk (order 1001) from the pre-staged Flatcar image.
-	if err := vm.createVMDevice(vmID, 1001, vm.buildDiskDeviceAttributes(bootPath)); err != nil {
-		return fmt.Errorf("failed to create boot disk device: %w", err)
+	if err := vm.createDiskDevices(vmID, disks[:1]); err != nil {
+		return err
 	}
-	vm.logger.Info("Created Flatcar boot disk device: /dev/zvol/%s", bootPath)
 
 	// Network device (order 1002).
 	macAddress := config.MacAddress
 	if macAddress == "" {
 		macAddress = vm.generateRandomMAC()
 	}
-	if err := vm.createVMDevice(vmID, 1002, map[string]interface{}{
-		"dtype":                  "NIC",
-		"type":                   "VIRTIO",
-		"mac":                    macAddress,
-		"nic_attach":             config.NetworkBridge,
-		"trust_guest_rx_filters": false,
-	}); err != nil {
+	if err := vm.createVMDevice(vmID, 1002, nicDeviceAttributes(macAddress, config.NetworkBridge)); err != nil {
 		return fmt.Errorf("failed to create NIC device: %w", err)
 	}
 	vm.logger.Info("Created NIC device with MAC %s on bridge %s", macAddress, config.NetworkBridge)
 
 	// Optional OpenEBS disk (order 1004), only if explicitly provided.
-	if openebsPath, ok := zvolPaths["openebs"]; ok && openebsPath != "" {
-		if err := vm.createVMDevice(vmID, 1004, vm.buildDiskDeviceAttributes(openebsPath)); err != nil {
-			return fmt.Errorf("failed to create OpenEBS disk device: %w", err)
-		}
-		vm.logger.Info("Created OpenEBS disk device: /dev/zvol/%s", openebsPath)
+	if err := vm.createDiskDevices(vmID, disks[1:]); err != nil {
+		return err
 	}
 
 	vm.logger.Success("All Flatcar VM devices created successfully")
 	return nil
 }
 
+// createSpecNICs attaches a VMConfig.NICs layout.
+func (vm *VMManager) createSpecNICs(vmID int, nics []VMNIC) error {
+	for i, nic := range nics {
+		mac := nic.MAC
+		if mac == "" {
+			mac = vm.generateRandomMAC()
+		}
+		attributes := nicDeviceAttributes(mac, nic.Bridge)
+		attributes["type"] = nic.Model
+		if err := vm.createVMDevice(vmID, nic.Order, attributes); err != nil {
+			return fmt.Errorf("failed to create NIC device %d: %w", i, err)
+		}
+		vm.logger.Info("Created %s NIC device %d with MAC %s on bridge %s (order %d)", nic.Model, i, mac, nic.Bridge, nic.Order)
+	}
+	return nil
+}
+
+// createDiskDevices attaches disks in order.
+func (vm *VMManager) createDiskDevices(vmID int, disks []VMDisk) error {
+	for _, disk := range disks {
+		if err := vm.createVMDevice(vmID, disk.Order, vm.diskDeviceAttributes(disk)); err != nil {
+			return fmt.Errorf("failed to create %s disk device: %w", disk.Name, err)
+		}
+		vm.logger.Info("Created %s disk device (%dGB, order %d): /dev/zvol/%s", disk.Name, disk.SizeGB, disk.Order, disk.ZVol)
+	}
+	return nil
+}
+
+// VMZVols returns the datasets behind the named VM's ZVol disks (sorted),
+// which DeleteVM removes with it.
+func (vm *VMManager) VMZVols(name string) ([]string, error) {
+	vmItem, err := vm.getVMByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return vm.discoverVMZVols(vmItem)
+}
+
 func (vm *VMManager) discoverVMZVols(vmItem *VM) ([]string, error) {
 	vm.logger.Info("Discovering ZVols for VM %s (ID: %d)", vmItem.Name, vmItem.ID)
 
@@ -791,7 +970,9 @@ func (vm *VMManager) deleteZVolsByPaths(zvolPaths []string, vmName string) error
 
 		// Always use recursive=true to handle snapshots
 		// ZVols often have automatic snapshots that prevent deletion without recursive flag
-		if err := vm.client.DeleteDataset(zvolPath, true); err != nil {
+		if err := vm.client.DeleteDataset(zvolPath, true); IsNotFound(err) {
+			vm.logger.Info("ZVol %s is already gone", zvolPath)
+		} else if err != nil {
 			vm.logger.Error("Failed to delete ZVol %s: %v", zvolPath, err)
 			failedZVols = append(failedZVols, fmt.Sprintf("%s (error: %v)", zvolPath, err))
 		} else {
@@ -913,21 +1094,21 @@ func (vm *VMManager) discoverZVolsByPattern(storagePool, vmName string) []string
 	return uniquePaths
 }
 
-func (vm *VMManager) stopVMByMode(vmID int, force bool) error {
-	if force {
-		return vm.client.PowerOffVM(vmID)
-	}
-	return vm.client.StopVM(vmID)
-}
-
 func (vm *VMManager) createVMDevice(vmID, order int, attributes map[string]interface{}) error {
 	device := map[string]interface{}{
 		"vm":         vmID,
 		"attributes": attributes,
 		"order":      order,
 	}
-	_, err := vm.client.Call("vm.device.create", []interface{}{device}, 30)
-	return err
+	raw, err := vm.client.Call("vm.device.create", []interface{}{device}, 30)
+	if err != nil {
+		return err
+	}
+	if id := createdDeviceID(raw); id != 0 {
+		dtype, _ := attributes["dtype"].(string)
+		vm.rollback.add(createdResource{kind: rollbackDevice, id: id, name: dtype})
+	}
+	return nil
 }
 
 func (vm *VMManager) buildDiskDeviceAttributes(zvolPath string) map[string]interface{} {
@@ -945,6 +1126,78 @@ func (vm *VMManager) buildDiskDeviceAttributes(zvolPath string) map[string]inter
 	}
 }
 
+// diskDeviceAttributes is buildDiskDeviceAttributes with disk's serial, if
+// it sets one.
+func (vm *VMManager) diskDeviceAttributes(disk VMDisk) map[string]interface{} {
+	attributes := vm.buildDiskDeviceAttributes(disk.ZVol)
+	if disk.Serial != "" {
+		attributes["serial"] = disk.Serial
+	}
+	return attributes
+}
+
+// installISOPath is the install CD-ROM's ISO: config.TalosISO (default or
+// custom) or the configured TrueNAS ISO path.
+func installISOPath(config VMConfig) string {
+	if config.TalosISO != "" {
+		return config.TalosISO
+	}
+	return homeopscfg.Get().TrueNASISOPath()
+}
+
+func cdromDeviceAttributes(isoPath string) map[string]interface{} {
+	return map[string]interface{}{
+		"dtype": "CDROM",
+		"path":  isoPath,
+	}
+}
+
+func nicDeviceAttributes(macAddress, bridge string) map[string]interface{} {
+	return map[string]interface{}{
+		"dtype":                  "NIC",
+		"type":                   "VIRTIO",
+		"mac":                    macAddress,
+		"nic_attach":             bridge,
+		"trust_guest_rx_filters": false,
+	}
+}
+
+// spiceBindAddress comes from hypervisors.truenas.spice_host in homeops.yaml;
+// all interfaces when unset.
+func spiceBindAddress() string {
+	if bind := homeopscfg.Get().Hypervisors.TrueNAS.SpiceHost; bind != "" {
+		return bind
+	}
+	return "0.0.0.0"
+}
+
+// spiceBind is the display's bind address: config.SpiceBind or the
+// spiceBindAddress default.
+func (config VMConfig) spiceBind() string {
+	if config.SpiceBind != "" {
+		return config.SpiceBind
+	}
+	return spiceBindAddress()
+}
+
+func spiceDisplayAttributes(config VMConfig) map[string]interface{} {
+	resolution := config.SpiceResolution
+	if resolution == "" {
+		resolution = "1920x1080"
+	}
+	return map[string]interface{}{
+		"bind":       config.spiceBind(),
+		"dtype":      "DISPLAY",
+		"password":   config.SpicePassword,
+		"port":       nil,
+		"resolution": resolution,
+		"type":       "SPICE",
+		"wait":       false,
+		"web":        true,
+		"web_port":   nil,
+	}
+}
+
 func extractZVolPathFromDevice(device map[string]interface{}) (string, bool) {
 	attributes, ok := device["attributes"].(map[string]interface{})
 	if !ok {
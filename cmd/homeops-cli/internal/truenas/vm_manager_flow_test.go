@@ -7,6 +7,8 @@ import (
 	"os"
 	"testing"
 
+	"homeops-cli/internal/provider"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -32,8 +34,7 @@ func TestVMManagerDeployVMSuccess(t *testing.T) {
 			}
 			return mustJSON(map[string]any{"result": datasets}), nil
 		case "pool.dataset.create":
-			args := params.([]interface{})
-			cfg := args[0].(map[string]interface{})
+			cfg := datasetCreateArgs(params)
 			name := cfg["name"].(string)
 			typ := cfg["type"].(string)
 			existingDatasets[name] = typ
@@ -224,6 +225,33 @@ func TestVMManagerListAndInfoOutput(t *testing.T) {
 	assert.Contains(t, output, "Devices (1)")
 }
 
+func TestVMManagerVMSummariesTypeDevicesAndZVols(t *testing.T) {
+	manager := NewVMManager("nas", "key", 443, true)
+	manager.client.callFn = func(method string, params interface{}, timeoutSeconds int64) (json.RawMessage, error) {
+		switch method {
+		case "vm.query":
+			return mustJSON(map[string]any{"result": []map[string]any{liveUpdateVM()}}), nil
+		case "vm.device.query":
+			return mustJSON(map[string]any{"result": liveUpdateVM()["devices"]}), nil
+		default:
+			return nil, fmt.Errorf("unexpected method %s", method)
+		}
+	}
+	summaries, err := manager.VMSummaries()
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	summary := summaries[0]
+	assert.Equal(t, []provider.VMDevice{
+		{Type: "DISK", ID: "3", Order: 1001, Path: "/dev/zvol/flashstor/VM/cp_0-boot"},
+		{Type: "NIC", ID: "2", Order: 1002, MAC: "00:0c:29:aa:bb:cc", Network: "br0", Model: "VIRTIO"},
+		{Type: "DISPLAY", ID: "5", Order: 1003, Model: "SPICE"},
+		{Type: "DISK", ID: "4", Order: 1004, Path: "/dev/zvol/flashstor/VM/cp_0-openebs"},
+		{Type: "CDROM", ID: "1", Order: 1006, Path: "/isos/talos.iso"},
+	}, summary.Devices)
+	assert.Equal(t, []string{"flashstor/VM/cp_0-boot", "flashstor/VM/cp_0-openebs"}, summary.ZVols)
+	assert.Equal(t, "STOPPED", summary.Status)
+}
+
 func TestVMManagerStartVM(t *testing.T) {
 	manager := NewVMManager("nas", "key", 443, true)
 	var startedIDs []int
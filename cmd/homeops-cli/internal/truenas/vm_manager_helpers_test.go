@@ -2,6 +2,7 @@ package truenas
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
@@ -24,6 +25,8 @@ func TestVMManagerBuildConfigAndIdentifiers(t *testing.T) {
 	assert.Equal(t, 16384, vmConfig["memory"])
 	assert.Equal(t, 4, vmConfig["vcpus"])
 	assert.Equal(t, "UEFI", vmConfig["bootloader"])
+	assert.Equal(t, false, vmConfig["trusted_platform_module"])
+	assert.Equal(t, true, manager.buildVMConfig(VMConfig{Name: "k8s-0", TPM: true})["trusted_platform_module"])
 
 	mac := manager.generateRandomMAC()
 	assert.Regexp(t, `^00:0c:29:[0-9a-f]{2}:[0-9a-f]{2}:[0-9a-f]{2}$`, mac)
@@ -179,6 +182,8 @@ func TestVMManagerStopVMUsesForceMode(t *testing.T) {
 			}), nil
 		case "vm.device.query", "vm.stop", "vm.poweroff":
 			return mustJSON(map[string]any{"result": true}), nil
+		case "vm.status":
+			return mustJSON(map[string]any{"result": map[string]any{"state": "STOPPED"}}), nil
 		default:
 			return nil, fmt.Errorf("unexpected method %s", method)
 		}
@@ -206,7 +211,7 @@ func TestVMManagerDeleteVMAbortsIfVMStillExistsAfterDeleteRequest(t *testing.T)
 			vmQueryCalls++
 			return mustJSON(map[string]interface{}{
 				"result": []map[string]interface{}{
-					{"id": 11, "name": "vm1"},
+					{"id": 11, "name": "vm1", "status": map[string]any{"state": "STOPPED"}},
 				},
 			}), nil
 		case "vm.device.query":
@@ -249,7 +254,7 @@ func TestVMManagerDeleteVMDeletesZVolsAfterVerifiedDelete(t *testing.T) {
 			if vmQueryCalls == 1 {
 				return mustJSON(map[string]interface{}{
 					"result": []map[string]interface{}{
-						{"id": 11, "name": "vm1"},
+						{"id": 11, "name": "vm1", "status": map[string]any{"state": "STOPPED"}},
 					},
 				}), nil
 			}
@@ -281,6 +286,67 @@ func TestVMManagerDeleteVMDeletesZVolsAfterVerifiedDelete(t *testing.T) {
 	assert.Equal(t, []string{"flashstor/VM/vm1-boot", "flashstor/VM/vm1-openebs"}, deletedDatasets)
 }
 
+func TestVMManagerDeleteVMDeletesExactlyItsDiskZVols(t *testing.T) {
+	manager := NewVMManager("nas", "key", 443, true)
+	oldSleep := sleepForOperation
+	sleepForOperation = func(time.Duration) {}
+	t.Cleanup(func() {
+		sleepForOperation = oldSleep
+	})
+
+	deleted := false
+	var deviceErr error
+	var calls []string
+	manager.client.callFn = func(method string, params interface{}, timeoutSeconds int64) (json.RawMessage, error) {
+		switch method {
+		case "vm.query":
+			if deleted {
+				return mustJSON(map[string]any{"result": []map[string]any{}}), nil
+			}
+			return mustJSON(map[string]any{"result": []map[string]any{
+				{"id": 11, "name": "k8s0", "status": map[string]any{"state": "STOPPED"}},
+			}}), nil
+		case "vm.device.query":
+			if deviceErr != nil {
+				return nil, deviceErr
+			}
+			return mustJSON(map[string]any{"result": []map[string]any{
+				{"attributes": map[string]any{"dtype": "DISK", "path": "/dev/zvol/flashstor/VM/k8s0-boot"}},
+				{"attributes": map[string]any{"dtype": "DISK", "path": "/dev/zvol/tank/VM/k8s0-ceph"}},
+				{"attributes": map[string]any{"dtype": "RAW", "path": "/mnt/flashstor/VM/k8s0-scratch.img"}},
+				{"attributes": map[string]any{"dtype": "CDROM", "path": "/mnt/flashstor/ISO/metal-amd64.iso"}},
+			}}), nil
+		case "vm.delete":
+			deleted = true
+			calls = append(calls, method)
+			return mustJSON(map[string]any{"result": true}), nil
+		case "pool.dataset.delete":
+			args := params.([]interface{})
+			calls = append(calls, fmt.Sprintf("%s %v %v", method, args[0], args[1].(map[string]interface{})["recursive"]))
+			return mustJSON(map[string]any{"result": true}), nil
+		default:
+			return nil, fmt.Errorf("unexpected method %s", method)
+		}
+	}
+
+	zvols, err := manager.VMZVols("k8s0")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"flashstor/VM/k8s0-boot", "tank/VM/k8s0-ceph"}, zvols)
+
+	require.NoError(t, manager.DeleteVM("k8s0", true, "flashstor"))
+	assert.Equal(t, []string{
+		"vm.delete",
+		"pool.dataset.delete flashstor/VM/k8s0-boot true",
+		"pool.dataset.delete tank/VM/k8s0-ceph true",
+	}, calls, "the custom ceph ZVol goes too, and no other dataset is searched for")
+
+	deleted, calls = false, nil
+	deviceErr = errors.New("connection reset")
+	err = manager.DeleteVM("k8s0", true, "flashstor")
+	require.ErrorContains(t, err, "refusing to delete VM k8s0: could not list its ZVols")
+	assert.Empty(t, calls, "the VM is kept so its ZVols are not orphaned")
+}
+
 func TestVMManagerCleanupOrphanedZVolsDeletesPatternMatches(t *testing.T) {
 	manager := NewVMManager("nas", "key", 443, true)
 	var deletedDatasets []string
@@ -346,3 +412,18 @@ func asFloat(v interface{}) float64 {
 		return 0
 	}
 }
+
+func TestVMManagerVMNICMACsInDeviceOrder(t *testing.T) {
+	live := liveUpdateVM()
+	live["devices"] = append(live["devices"].([]map[string]any), map[string]any{
+		"id": 6, "vm": 41, "order": 1021, "attributes": map[string]any{"dtype": "NIC", "mac": "00:0c:29:aa:bb:dd", "nic_attach": "br-storage"},
+	})
+	manager, _ := updateNAS(t, live, nil)
+	macs, err := manager.VMNICMACs("cp_0")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"00:0c:29:aa:bb:cc", "00:0c:29:aa:bb:dd"}, macs)
+
+	live["devices"] = live["devices"].([]map[string]any)[:1]
+	_, err = manager.VMNICMACs("cp_0")
+	require.EqualError(t, err, "VM cp_0 has no NIC with a MAC address")
+}
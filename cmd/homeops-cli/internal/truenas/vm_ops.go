@@ -1,9 +1,12 @@
 package truenas
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 
 	"homeops-cli/internal/common"
@@ -21,10 +24,28 @@ import (
 // callResult invokes an RPC method and decodes the JSON-RPC "result" field
 // into out (pass nil to discard).
 func (c *WorkingClient) callResult(method string, params interface{}, timeoutSeconds int64, out interface{}) error {
-	raw, err := c.Call(method, params, timeoutSeconds)
+	return c.callResultContext(c.baseContext(), method, params, timeoutSeconds, out)
+}
+
+func (c *WorkingClient) callResultContext(ctx context.Context, method string, params interface{}, timeoutSeconds int64, out interface{}) error {
+	raw, err := c.CallContext(ctx, method, params, timeoutSeconds)
 	if err != nil {
 		return err
 	}
+	return decodeResult(method, raw, out)
+}
+
+// callJobResultContext is callResultContext for methods that may run as a
+// job; it waits for the job and decodes the job's result.
+func (c *WorkingClient) callJobResultContext(ctx context.Context, method string, params interface{}, timeoutSeconds int64, out interface{}) error {
+	raw, err := c.CallJobContext(ctx, method, params, timeoutSeconds)
+	if err != nil {
+		return err
+	}
+	return decodeResult(method, raw, out)
+}
+
+func decodeResult(method string, raw json.RawMessage, out interface{}) error {
 	if out == nil {
 		return nil
 	}
@@ -36,8 +57,8 @@ func (c *WorkingClient) callResult(method string, params interface{}, timeoutSec
 		return fmt.Errorf("failed to unmarshal JSON-RPC response: %w", err)
 	}
 	if envelope.Result == nil {
-		if envelope.Error != nil {
-			return fmt.Errorf("%s failed: %s", method, string(envelope.Error))
+		if !isJSONNull(envelope.Error) {
+			return parseTrueNASError(method, envelope.Error)
 		}
 		return fmt.Errorf("no result field in response")
 	}
@@ -55,18 +76,63 @@ func (c *WorkingClient) UpdateVM(vmID int, updates map[string]interface{}) error
 	return nil
 }
 
+// GetVM returns one VM by ID, devices included (vm.get_instance).
+func (c *WorkingClient) GetVM(vmID int) (*VM, error) {
+	var vmItem VM
+	if err := c.callResult("vm.get_instance", []interface{}{vmID}, 30, &vmItem); err != nil {
+		return nil, fmt.Errorf("failed to get VM %d: %w", vmID, err)
+	}
+	return &vmItem, nil
+}
+
+// GetVMStatus returns a VM's vm.status: state (RUNNING, STOPPED, ...), pid,
+// and the libvirt domain_state.
+func (c *WorkingClient) GetVMStatus(vmID int) (map[string]interface{}, error) {
+	var status map[string]interface{}
+	if err := c.callResult("vm.status", []interface{}{vmID}, 30, &status); err != nil {
+		return nil, fmt.Errorf("failed to get status of VM %d: %w", vmID, err)
+	}
+	return status, nil
+}
+
 // RestartVM restarts a VM by ID (graceful stop + start in the middleware).
 func (c *WorkingClient) RestartVM(vmID int) error {
-	if err := c.callResult("vm.restart", []interface{}{vmID}, 180, nil); err != nil {
+	if err := c.callJobResultContext(c.baseContext(), "vm.restart", []interface{}{vmID}, 180, nil); err != nil {
 		return fmt.Errorf("failed to restart VM %d: %w", vmID, err)
 	}
 	return nil
 }
 
-// CloneVM clones a VM (and its zvols, as ZFS clones) to a new name.
-func (c *WorkingClient) CloneVM(vmID int, newName string) error {
-	if err := c.callResult("vm.clone", []interface{}{vmID, newName}, 600, nil); err != nil {
-		return fmt.Errorf("failed to clone VM %d to %s: %w", vmID, newName, err)
+// SuspendVM pauses a running VM's vCPUs (vm.suspend); its memory stays
+// allocated.
+func (c *WorkingClient) SuspendVM(vmID int) error {
+	if err := c.callResult("vm.suspend", []interface{}{vmID}, 30, nil); err != nil {
+		return fmt.Errorf("failed to suspend VM %d: %w", vmID, err)
+	}
+	return nil
+}
+
+// ResumeVM continues a suspended VM (vm.resume).
+func (c *WorkingClient) ResumeVM(vmID int) error {
+	if err := c.callResult("vm.resume", []interface{}{vmID}, 30, nil); err != nil {
+		return fmt.Errorf("failed to resume VM %d: %w", vmID, err)
+	}
+	return nil
+}
+
+// DeleteVMDevice removes one device from a VM by device ID.
+func (c *WorkingClient) DeleteVMDevice(deviceID int) error {
+	if err := c.callResult("vm.device.delete", []interface{}{deviceID}, 30, nil); err != nil {
+		return fmt.Errorf("failed to delete VM device %d: %w", deviceID, err)
+	}
+	return nil
+}
+
+// UpdateVMDevice replaces a device's attributes (vm.device.update).
+func (c *WorkingClient) UpdateVMDevice(deviceID int, attributes map[string]interface{}) error {
+	params := []interface{}{deviceID, map[string]interface{}{"attributes": attributes}}
+	if err := c.callResult("vm.device.update", params, 30, nil); err != nil {
+		return fmt.Errorf("failed to update VM device %d: %w", deviceID, err)
 	}
 	return nil
 }
@@ -144,9 +210,13 @@ func (c *WorkingClient) CreateZFSSnapshot(dataset, name string) error {
 	return nil
 }
 
-// QueryZFSSnapshots lists snapshots of one dataset/zvol.
+// QueryZFSSnapshots lists snapshots of one dataset/zvol, with the creation
+// and clones properties.
 func (c *WorkingClient) QueryZFSSnapshots(dataset string) ([]ZFSSnapshot, error) {
-	params := []interface{}{[]interface{}{[]interface{}{"dataset", "=", dataset}}}
+	params := []interface{}{
+		[]interface{}{[]interface{}{"dataset", "=", dataset}},
+		map[string]interface{}{"extra": map[string]interface{}{"properties": []string{"creation", "clones"}}},
+	}
 	var snaps []ZFSSnapshot
 	if err := c.callResult("pool.snapshot.query", params, 30, &snaps); err != nil {
 		return nil, fmt.Errorf("failed to query snapshots of %s: %w", dataset, err)
@@ -176,11 +246,21 @@ func (c *WorkingClient) RollbackZFSSnapshot(id string, force bool) error {
 
 // vmIsRunning reports whether the middleware considers the VM running.
 func vmIsRunning(vmItem *VM) bool {
-	if vmItem.Status == nil {
-		return false
-	}
+	return vmState(vmItem) == "RUNNING"
+}
+
+// vmState is the VM's upper-cased status.state as of its vm.query (RUNNING,
+// STOPPED, SUSPENDED, ...), or "" when none was reported.
+func vmState(vmItem *VM) string {
 	state, _ := vmItem.Status["state"].(string)
-	return strings.EqualFold(state, "RUNNING")
+	return strings.ToUpper(state)
+}
+
+func describeVMState(vmItem *VM) string {
+	if state := vmState(vmItem); state != "" {
+		return state
+	}
+	return "in an unknown state"
 }
 
 // vmZVolDatasets resolves the dataset paths of every zvol backing the VM.
@@ -250,22 +330,73 @@ func resolveVMDisk(zvols []string, disk string) (string, error) {
 	}
 }
 
+// vmDiskDevice is one ZVol-backed DISK device of a VM.
+type vmDiskDevice struct {
+	zvol   string
+	device map[string]interface{}
+}
+
+// vmDiskDevices lists the VM's ZVol disks in device (boot) order.
+func (vm *VMManager) vmDiskDevices(vmItem *VM) ([]vmDiskDevice, error) {
+	devices, err := vm.client.QueryVMDevices(vmItem.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query VM devices: %w", err)
+	}
+	var disks []vmDiskDevice
+	for _, device := range devices {
+		if zvol, ok := extractZVolPathFromDevice(device); ok {
+			disks = append(disks, vmDiskDevice{zvol: zvol, device: device})
+		}
+	}
+	if len(disks) == 0 {
+		return nil, fmt.Errorf("VM %s has no zvol-backed disks", vmItem.Name)
+	}
+	sort.SliceStable(disks, func(i, j int) bool {
+		return intAttr(disks[i].device, "order") < intAttr(disks[j].device, "order")
+	})
+	return disks, nil
+}
+
+// resolveVMDiskDevice picks the disk a selector refers to: a number is the
+// disk's position in boot order (1 = first), anything else is resolved by
+// resolveVMDisk.
+func resolveVMDiskDevice(disks []vmDiskDevice, disk string) (vmDiskDevice, error) {
+	if index, err := strconv.Atoi(strings.TrimSpace(disk)); err == nil {
+		if index < 1 || index > len(disks) {
+			return vmDiskDevice{}, fmt.Errorf("disk index %d is out of range: this VM has %d disks", index, len(disks))
+		}
+		return disks[index-1], nil
+	}
+	zvols := make([]string, len(disks))
+	for i, d := range disks {
+		zvols[i] = d.zvol
+	}
+	target, err := resolveVMDisk(zvols, disk)
+	if err != nil {
+		return vmDiskDevice{}, err
+	}
+	return disks[slices.Index(zvols, target)], nil
+}
+
 // ResizeVMDisk grows a VM disk (zvol). disk selects which zvol ("boot" by
-// default, "openebs", or a full dataset path); sizeSpec is "+20G" (grow by)
-// or "200G" (grow to). ZFS volumes can only grow, never shrink.
+// default, "openebs", a data disk name such as "rook", a full dataset path,
+// or its position in boot order); sizeSpec is "+20G" (grow by) or "200G"
+// (grow to). ZFS volumes can only grow, never shrink. A device that records
+// the size it was created with (zvol_volsize) is updated to match.
 func (vm *VMManager) ResizeVMDisk(name, disk, sizeSpec string) error {
 	vmItem, err := vm.getVMByName(name)
 	if err != nil {
 		return err
 	}
-	zvols, err := vm.vmZVolDatasets(vmItem)
+	disks, err := vm.vmDiskDevices(vmItem)
 	if err != nil {
 		return err
 	}
-	target, err := resolveVMDisk(zvols, disk)
+	selected, err := resolveVMDiskDevice(disks, disk)
 	if err != nil {
 		return err
 	}
+	target := selected.zvol
 	specBytes, relative, err := common.ParseSizeSpec(sizeSpec)
 	if err != nil {
 		return err
@@ -284,24 +415,120 @@ func (vm *VMManager) ResizeVMDisk(name, disk, sizeSpec string) error {
 	if err := vm.client.UpdateDataset(target, map[string]interface{}{"volsize": newSize}); err != nil {
 		return err
 	}
+	vm.logger.Info("ZVol %s grown from %dGiB to %dGiB", target, current>>30, newSize>>30)
+
+	source, _ := selected.device["attributes"].(map[string]interface{})
+	if tracked, ok := source["zvol_volsize"]; ok && tracked != nil {
+		attributes := make(map[string]interface{}, len(source))
+		for key, value := range source {
+			attributes[key] = value
+		}
+		attributes["zvol_volsize"] = newSize
+		if err := vm.client.UpdateVMDevice(intAttr(selected.device, "id"), attributes); err != nil {
+			return fmt.Errorf("ZVol %s was resized, but its VM device was not updated: %w", target, err)
+		}
+	}
+	if vmIsRunning(vmItem) {
+		vm.logger.Warn("VM %s is running — the guest sees the new size only after it rescans the disk (or reboots)", name)
+	}
 	vm.logger.Success("VM %s disk %s resized to %dGiB (grow the filesystem inside the guest)", name, target, newSize>>30)
 	return nil
 }
 
-// RestartVM restarts a VM through the middleware (graceful stop + start).
+// RestartVM restarts a VM through the middleware (graceful stop + start)
+// and waits for it to report RUNNING again. A stopped VM is started instead;
+// a suspended one is refused, since its guest cannot answer the shutdown.
 func (vm *VMManager) RestartVM(name string) error {
 	vmItem, err := vm.getVMByName(name)
 	if err != nil {
 		return err
 	}
-	vm.logger.Info("Restarting VM: %s (ID: %d)", vmItem.Name, vmItem.ID)
-	if err := vm.client.RestartVM(vmItem.ID); err != nil {
+	switch vmState(vmItem) {
+	case "STOPPED":
+		vm.logger.Info("VM %s is not running; starting it instead of restarting", name)
+		if err := vm.client.StartVM(vmItem.ID); err != nil {
+			return fmt.Errorf("failed to start VM: %w", err)
+		}
+	case "SUSPENDED":
+		return fmt.Errorf("VM %s is suspended: resume it first, or power-cycle it with 'vm restart --force'", name)
+	default:
+		vm.logger.Info("Restarting VM: %s (ID: %d)", vmItem.Name, vmItem.ID)
+		if err := vm.client.RestartVM(vmItem.ID); err != nil {
+			return err
+		}
+	}
+	if err := vm.waitForVMRunning(vmItem); err != nil {
+		return err
+	}
+	vm.logger.Success("VM %s restarted", name)
+	return nil
+}
+
+// PowerCycleVM is RestartVM for a wedged guest: the VM is forced off (if it
+// is not already stopped) and started, then waited for until RUNNING.
+func (vm *VMManager) PowerCycleVM(name string) error {
+	vmItem, err := vm.getVMByName(name)
+	if err != nil {
+		return err
+	}
+	vm.logger.Info("Power-cycling VM: %s (ID: %d)", vmItem.Name, vmItem.ID)
+	if err := vm.stopVM(vmItem, true); err != nil {
+		return fmt.Errorf("failed to stop VM: %w", err)
+	}
+	if err := vm.client.StartVM(vmItem.ID); err != nil {
+		return fmt.Errorf("failed to start VM: %w", err)
+	}
+	if err := vm.waitForVMRunning(vmItem); err != nil {
 		return err
 	}
 	vm.logger.Success("VM %s restarted", name)
 	return nil
 }
 
+// SuspendVM pauses a running VM. Suspending a suspended VM is a no-op; a
+// stopped one has nothing to pause.
+func (vm *VMManager) SuspendVM(name string) error {
+	vmItem, err := vm.getVMByName(name)
+	if err != nil {
+		return err
+	}
+	switch vmState(vmItem) {
+	case "SUSPENDED":
+		vm.logger.Info("VM %s is already suspended", name)
+		return nil
+	case "RUNNING":
+	default:
+		return fmt.Errorf("VM %s is %s; only a running VM can be suspended", name, describeVMState(vmItem))
+	}
+	if err := vm.client.SuspendVM(vmItem.ID); err != nil {
+		return err
+	}
+	vm.logger.Success("VM %s suspended", name)
+	return nil
+}
+
+// ResumeVM continues a suspended VM. Resuming a running VM is a no-op; a
+// stopped one needs 'vm start'.
+func (vm *VMManager) ResumeVM(name string) error {
+	vmItem, err := vm.getVMByName(name)
+	if err != nil {
+		return err
+	}
+	switch vmState(vmItem) {
+	case "RUNNING":
+		vm.logger.Info("VM %s is already running", name)
+		return nil
+	case "SUSPENDED":
+	default:
+		return fmt.Errorf("VM %s is %s, not suspended; start it with 'vm start'", name, describeVMState(vmItem))
+	}
+	if err := vm.client.ResumeVM(vmItem.ID); err != nil {
+		return err
+	}
+	vm.logger.Success("VM %s resumed", name)
+	return nil
+}
+
 // SnapshotVM creates a consistent-by-name ZFS snapshot of every zvol backing
 // the VM.
 func (vm *VMManager) SnapshotVM(name, snapName string) error {
@@ -363,19 +590,33 @@ func (vm *VMManager) ListVMSnapshots(name string) error {
 }
 
 // RollbackVM rolls every zvol backing the VM back to the named snapshot.
-// DESTRUCTIVE: disk state after the snapshot is lost. The VM must be stopped.
+// DESTRUCTIVE: disk state after the snapshot is lost. The VM must be stopped,
+// and no zvol is rolled back unless all of them have the snapshot.
 func (vm *VMManager) RollbackVM(name, snapName string) error {
 	vmItem, err := vm.getVMByName(name)
 	if err != nil {
 		return err
 	}
 	if vmIsRunning(vmItem) {
-		return fmt.Errorf("VM %s is running — stop it before rolling back ('homeops-cli vm stop --provider truenas --name %s')", name, name)
+		return fmt.Errorf("VM %s is running — stop it before rolling back ('homeops-cli vm stop --provider truenas --name %s'), or pass --force to power it off", name, name)
 	}
 	zvols, err := vm.vmZVolDatasets(vmItem)
 	if err != nil {
 		return err
 	}
+	var missing []string
+	for _, ds := range zvols {
+		snaps, err := vm.client.QueryZFSSnapshots(ds)
+		if err != nil {
+			return err
+		}
+		if !slices.ContainsFunc(snaps, func(s ZFSSnapshot) bool { return s.SnapshotName == snapName }) {
+			missing = append(missing, ds)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("snapshot %q of VM %s is missing on %s; nothing was rolled back", snapName, name, strings.Join(missing, ", "))
+	}
 	for _, ds := range zvols {
 		if err := vm.client.RollbackZFSSnapshot(ds+"@"+snapName, true); err != nil {
 			return err
@@ -429,24 +670,6 @@ func (vm *VMManager) DeleteVMSnapshot(name, snapName string) error {
 	return nil
 }
 
-// CloneVM clones a VM to a new name via the middleware (zvols become ZFS
-// clones of an automatic snapshot).
-func (vm *VMManager) CloneVM(name, newName string) error {
-	vmItem, err := vm.getVMByName(name)
-	if err != nil {
-		return err
-	}
-	if _, err := vm.getVMByName(newName); err == nil {
-		return fmt.Errorf("VM with name '%s' already exists", newName)
-	}
-	vm.logger.Info("Cloning VM %s (ID: %d) to %s", name, vmItem.ID, newName)
-	if err := vm.client.CloneVM(vmItem.ID, newName); err != nil {
-		return err
-	}
-	vm.logger.Success("VM %s cloned to %s", name, newName)
-	return nil
-}
-
 // DisplayInfo describes a VM's display (SPICE) device endpoints.
 type DisplayInfo struct {
 	Type    string // e.g. SPICE
@@ -471,25 +694,33 @@ func (vm *VMManager) VMDisplayInfo(name string) (*DisplayInfo, error) {
 		if !ok {
 			return nil, fmt.Errorf("unexpected device shape for VM %s", name)
 		}
-		if dtype, _ := attributes["dtype"].(string); dtype != "DISPLAY" {
-			continue
-		}
-		info := &DisplayInfo{}
-		if t, ok := attributes["type"].(string); ok {
-			info.Type = t
-		}
-		if b, ok := attributes["bind"].(string); ok {
-			info.Bind = b
+		if info := displayDeviceInfo(attributes); info != nil {
+			return info, nil
 		}
-		info.Port = intAttr(attributes, "port")
-		if web, _ := attributes["web"].(bool); web {
-			info.WebPort = intAttr(attributes, "web_port")
-		}
-		return info, nil
 	}
 	return nil, fmt.Errorf("VM %s has no display device", name)
 }
 
+// displayDeviceInfo reads the endpoints of a DISPLAY device's attributes; nil
+// for any other device.
+func displayDeviceInfo(attributes map[string]interface{}) *DisplayInfo {
+	if dtype, _ := attributes["dtype"].(string); dtype != "DISPLAY" {
+		return nil
+	}
+	info := &DisplayInfo{}
+	if t, ok := attributes["type"].(string); ok {
+		info.Type = t
+	}
+	if b, ok := attributes["bind"].(string); ok {
+		info.Bind = b
+	}
+	info.Port = intAttr(attributes, "port")
+	if web, _ := attributes["web"].(bool); web {
+		info.WebPort = intAttr(attributes, "web_port")
+	}
+	return info
+}
+
 // intAttr reads a numeric device attribute that JSON may deliver as float64.
 func intAttr(attributes map[string]interface{}, key string) int {
 	switch v := attributes[key].(type) {
@@ -3,10 +3,13 @@ package truenas
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	homeopscfg "homeops-cli/internal/config"
 )
 
 // opsTestManager wires a VMManager whose RPC layer answers vm.query /
@@ -139,6 +142,59 @@ func TestResizeVMDisk(t *testing.T) {
 		assert.Equal(t, map[string]interface{}{"volsize": int64(60 << 30)}, args[1])
 	})
 
+	t.Run("data disk by position updates its device", func(t *testing.T) {
+		manager := NewVMManager("nas", "key", 443, true)
+		var calls []recordedCall
+		manager.client.callFn = func(method string, params interface{}, timeoutSeconds int64) (json.RawMessage, error) {
+			calls = append(calls, recordedCall{method, params})
+			switch method {
+			case "vm.query":
+				return mustJSON(map[string]any{"result": []map[string]any{{"id": 7, "name": "k8s0", "status": map[string]any{"state": "RUNNING"}}}}), nil
+			case "vm.device.query":
+				return mustJSON(map[string]any{"result": []map[string]any{
+					{"id": 12, "order": 1002, "attributes": map[string]any{"dtype": "DISK", "path": "/dev/zvol/flashstor/VM/k8s0-rook", "zvol_volsize": float64(800 << 30)}},
+					{"id": 11, "order": 1001, "attributes": map[string]any{"dtype": "DISK", "path": "/dev/zvol/flashstor/VM/k8s0-boot"}},
+				}}), nil
+			case "pool.dataset.query":
+				return mustJSON(map[string]any{"result": []map[string]any{
+					{"id": "flashstor/VM/k8s0-rook", "type": "VOLUME", "volsize": map[string]any{"parsed": float64(800 << 30)}},
+				}}), nil
+			case "pool.dataset.update", "vm.device.update":
+				return mustJSON(map[string]any{"result": true}), nil
+			}
+			return nil, fmt.Errorf("unexpected method %s", method)
+		}
+		require.NoError(t, manager.ResizeVMDisk("k8s0", "2", "1200G"))
+		updates := methodCalls(calls, "pool.dataset.update")
+		require.Len(t, updates, 1)
+		assert.Equal(t, []interface{}{"flashstor/VM/k8s0-rook", map[string]interface{}{"volsize": int64(1200 << 30)}}, updates[0].params)
+		devices := methodCalls(calls, "vm.device.update")
+		require.Len(t, devices, 1)
+		assert.Equal(t, []interface{}{12, map[string]interface{}{"attributes": map[string]interface{}{
+			"dtype": "DISK", "path": "/dev/zvol/flashstor/VM/k8s0-rook", "zvol_volsize": int64(1200 << 30),
+		}}}, devices[0].params)
+
+		err := manager.ResizeVMDisk("k8s0", "3", "1400G")
+		require.EqualError(t, err, "disk index 3 is out of range: this VM has 2 disks")
+	})
+
+	t.Run("a device without zvol_volsize is left alone", func(t *testing.T) {
+		manager, calls := opsTestManager(t, "STOPPED", func(method string, params interface{}) (json.RawMessage, error) {
+			switch method {
+			case "pool.dataset.query":
+				return mustJSON(map[string]any{"result": []map[string]any{
+					{"id": "flashstor/VM/web0-openebs", "type": "VOLUME", "volsize": map[string]any{"parsed": float64(100 << 30)}},
+				}}), nil
+			case "pool.dataset.update":
+				return mustJSON(map[string]any{"result": true}), nil
+			}
+			return nil, fmt.Errorf("unexpected method %s", method)
+		})
+		require.NoError(t, manager.ResizeVMDisk("web0", "openebs", "200G"))
+		assert.Len(t, methodCalls(*calls, "pool.dataset.update"), 1)
+		assert.Empty(t, methodCalls(*calls, "vm.device.update"))
+	})
+
 	t.Run("absolute must grow", func(t *testing.T) {
 		manager, _ := opsTestManager(t, "STOPPED", func(method string, params interface{}) (json.RawMessage, error) {
 			if method == "pool.dataset.query" {
@@ -153,17 +209,70 @@ func TestResizeVMDisk(t *testing.T) {
 	})
 }
 
+// powerOpsAnswer answers the power calls of opsTestManager's VM, with
+// vm.status reporting RUNNING.
+func powerOpsAnswer(method string, _ interface{}) (json.RawMessage, error) {
+	switch method {
+	case "vm.restart", "vm.start", "vm.poweroff", "vm.suspend", "vm.resume":
+		return mustJSON(map[string]any{"result": nil}), nil
+	case "vm.status":
+		return mustJSON(map[string]any{"result": map[string]any{"state": "RUNNING"}}), nil
+	}
+	return nil, fmt.Errorf("unexpected method %s", method)
+}
+
 func TestRestartVM(t *testing.T) {
-	manager, calls := opsTestManager(t, "RUNNING", func(method string, params interface{}) (json.RawMessage, error) {
-		if method == "vm.restart" {
-			return mustJSON(map[string]any{"result": nil}), nil
-		}
-		return nil, fmt.Errorf("unexpected method %s", method)
-	})
+	manager, calls := opsTestManager(t, "RUNNING", powerOpsAnswer)
 	require.NoError(t, manager.RestartVM("web0"))
 	restarts := methodCalls(*calls, "vm.restart")
 	require.Len(t, restarts, 1)
 	assert.Equal(t, []interface{}{7}, restarts[0].params)
+	assert.NotEmpty(t, methodCalls(*calls, "vm.status"), "the restart is waited for until RUNNING")
+
+	manager, calls = opsTestManager(t, "STOPPED", powerOpsAnswer)
+	require.NoError(t, manager.RestartVM("web0"))
+	assert.Empty(t, methodCalls(*calls, "vm.restart"))
+	assert.Len(t, methodCalls(*calls, "vm.start"), 1, "a stopped VM is started")
+
+	manager, _ = opsTestManager(t, "SUSPENDED", powerOpsAnswer)
+	require.EqualError(t, manager.RestartVM("web0"), "VM web0 is suspended: resume it first, or power-cycle it with 'vm restart --force'")
+}
+
+func TestPowerCycleVM(t *testing.T) {
+	started := false
+	manager, calls := opsTestManager(t, "RUNNING", func(method string, params interface{}) (json.RawMessage, error) {
+		started = started || method == "vm.start"
+		if method == "vm.status" && !started {
+			return mustJSON(map[string]any{"result": map[string]any{"state": "STOPPED"}}), nil
+		}
+		return powerOpsAnswer(method, params)
+	})
+	require.NoError(t, manager.PowerCycleVM("web0"))
+	var power []string
+	for _, call := range *calls {
+		if call.method == "vm.poweroff" || call.method == "vm.start" || call.method == "vm.stop" {
+			power = append(power, call.method)
+		}
+	}
+	assert.Equal(t, []string{"vm.poweroff", "vm.start"}, power, "no clean shutdown is attempted")
+}
+
+func TestSuspendAndResumeVMCheckTheState(t *testing.T) {
+	manager, calls := opsTestManager(t, "RUNNING", powerOpsAnswer)
+	require.NoError(t, manager.SuspendVM("web0"))
+	require.NoError(t, manager.ResumeVM("web0"))
+	assert.Len(t, methodCalls(*calls, "vm.suspend"), 1)
+	assert.Empty(t, methodCalls(*calls, "vm.resume"), "resuming a running VM is a no-op")
+
+	manager, calls = opsTestManager(t, "SUSPENDED", powerOpsAnswer)
+	require.NoError(t, manager.SuspendVM("web0"))
+	require.NoError(t, manager.ResumeVM("web0"))
+	assert.Empty(t, methodCalls(*calls, "vm.suspend"), "suspending a suspended VM is a no-op")
+	assert.Equal(t, []interface{}{7}, methodCalls(*calls, "vm.resume")[0].params)
+
+	manager, _ = opsTestManager(t, "STOPPED", powerOpsAnswer)
+	require.EqualError(t, manager.SuspendVM("web0"), "VM web0 is STOPPED; only a running VM can be suspended")
+	require.EqualError(t, manager.ResumeVM("web0"), "VM web0 is STOPPED, not suspended; start it with 'vm start'")
 }
 
 func TestSnapshotVM(t *testing.T) {
@@ -203,13 +312,33 @@ func TestRollbackVM(t *testing.T) {
 		require.ErrorContains(t, err, "stop it before rolling back")
 	})
 
-	t.Run("rolls back every zvol", func(t *testing.T) {
-		manager, calls := opsTestManager(t, "STOPPED", func(method string, params interface{}) (json.RawMessage, error) {
-			if method == "pool.snapshot.rollback" {
+	snapshotsAnswer := func(present ...string) func(string, interface{}) (json.RawMessage, error) {
+		return func(method string, params interface{}) (json.RawMessage, error) {
+			switch method {
+			case "pool.snapshot.query":
+				filters := params.([]interface{})[0].([]interface{})[0].([]interface{})
+				ds := filters[2].(string)
+				var snaps []map[string]any
+				if slices.Contains(present, ds) {
+					snaps = append(snaps, map[string]any{"id": ds + "@pre-upgrade", "dataset": ds, "snapshot_name": "pre-upgrade"})
+				}
+				return mustJSON(map[string]any{"result": snaps}), nil
+			case "pool.snapshot.rollback":
 				return mustJSON(map[string]any{"result": nil}), nil
 			}
 			return nil, fmt.Errorf("unexpected method %s", method)
-		})
+		}
+	}
+
+	t.Run("a snapshot missing on one zvol rolls back none", func(t *testing.T) {
+		manager, calls := opsTestManager(t, "STOPPED", snapshotsAnswer("flashstor/VM/web0-boot"))
+		err := manager.RollbackVM("web0", "pre-upgrade")
+		require.EqualError(t, err, `snapshot "pre-upgrade" of VM web0 is missing on flashstor/VM/web0-openebs; nothing was rolled back`)
+		assert.Empty(t, methodCalls(*calls, "pool.snapshot.rollback"))
+	})
+
+	t.Run("rolls back every zvol", func(t *testing.T) {
+		manager, calls := opsTestManager(t, "STOPPED", snapshotsAnswer("flashstor/VM/web0-boot", "flashstor/VM/web0-openebs"))
 		require.NoError(t, manager.RollbackVM("web0", "pre-upgrade"))
 		rollbacks := methodCalls(*calls, "pool.snapshot.rollback")
 		require.Len(t, rollbacks, 2)
@@ -255,27 +384,6 @@ func TestDeleteVMSnapshot(t *testing.T) {
 	})
 }
 
-func TestCloneVMTrueNAS(t *testing.T) {
-	t.Run("clones by id", func(t *testing.T) {
-		manager, calls := opsTestManager(t, "STOPPED", func(method string, params interface{}) (json.RawMessage, error) {
-			if method == "vm.clone" {
-				return mustJSON(map[string]any{"result": true}), nil
-			}
-			return nil, fmt.Errorf("unexpected method %s", method)
-		})
-		require.NoError(t, manager.CloneVM("web0", "web1"))
-		clones := methodCalls(*calls, "vm.clone")
-		require.Len(t, clones, 1)
-		assert.Equal(t, []interface{}{7, "web1"}, clones[0].params)
-	})
-
-	t.Run("refuses existing target", func(t *testing.T) {
-		manager, _ := opsTestManager(t, "STOPPED", nil)
-		err := manager.CloneVM("web0", "web0")
-		require.ErrorContains(t, err, "already exists")
-	})
-}
-
 func TestVMDisplayInfo(t *testing.T) {
 	manager, _ := opsTestManager(t, "RUNNING", nil)
 	info, err := manager.VMDisplayInfo("web0")
@@ -286,6 +394,27 @@ func TestVMDisplayInfo(t *testing.T) {
 	assert.Equal(t, 5901, info.WebPort)
 }
 
+func TestVMConsoleInfo(t *testing.T) {
+	t.Cleanup(homeopscfg.SetForTesting(&homeopscfg.Config{}))
+	manager, _ := opsTestManager(t, "RUNNING", nil)
+	console, err := manager.ConsoleInfo("web0")
+	require.NoError(t, err)
+	assert.Equal(t, "nas", console.Host, "a wildcard bind falls back to the NAS")
+	assert.Equal(t, "spice://nas:5900", console.SpiceURL)
+	assert.Equal(t, "https://nas:5901/spice_auto.html", console.WebURL)
+	url, err := manager.ConsoleURL("web0")
+	require.NoError(t, err)
+	assert.Equal(t, console.WebURL, url, "the web console is preferred")
+
+	t.Cleanup(homeopscfg.SetForTesting(&homeopscfg.Config{Hypervisors: homeopscfg.HypervisorsConfig{
+		TrueNAS: homeopscfg.TrueNASConfig{SpiceHost: "192.168.120.10"},
+	}}))
+	stopped := manager.consoleInfo(DisplayInfo{Type: "SPICE", Bind: "192.168.120.2"})
+	assert.Equal(t, "192.168.120.10", stopped.Host, "spice_host wins over the bind address")
+	assert.Empty(t, stopped.SpiceURL, "no URL before the NAS assigns a port")
+	assert.Empty(t, stopped.WebURL)
+}
+
 func TestVMDisplayInfoMissing(t *testing.T) {
 	manager := NewVMManager("nas", "key", 443, true)
 	manager.client.callFn = func(method string, params interface{}, timeoutSeconds int64) (json.RawMessage, error) {
@@ -0,0 +1,281 @@
+package truenas
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A VM spec (talos deploy-vm --spec vm.yaml) declares a TrueNAS VM in one
+// file, including layouts the flags cannot express: any number of disks and
+// NICs. A minimal 2-disk example:
+//
+//	name: cp_0
+//	memory_mb: 16384
+//	vcpus: 4
+//	disks:
+//	  - zvol: flashstor/VM/cp_0-boot
+//	    size_gb: 250
+//	  - zvol: flashstor/VM/cp_0-openebs
+//	    size_gb: 1000
+//	    blocksize: 16K
+//	nics:
+//	  - bridge: br0
+//	    mac: 00:0c:29:aa:bb:cc
+//
+// Spec disks and NICs replace the default boot/OpenEBS pair and single NIC.
+// Unset device orders follow the default layout: the first disk boots at
+// 1001, the second is 1004 (where the OpenEBS disk goes), further disks take
+// 1010+index; the first NIC is 1002, further NICs 1020+index. 1003 (display), 1006 (install CD-ROM) and 1007 (config CD-ROM) are
+// reserved.
+
+// VMSpec is the parsed spec file.
+type VMSpec struct {
+	Name     string         `yaml:"name,omitempty"`
+	MemoryMB int            `yaml:"memory_mb,omitempty"`
+	VCPUs    int            `yaml:"vcpus,omitempty"`
+	Pool     string         `yaml:"pool,omitempty"`
+	ISO      string         `yaml:"iso,omitempty"`
+	Disks    []VMSpecDisk   `yaml:"disks,omitempty"`
+	NICs     []VMSpecNIC    `yaml:"nics,omitempty"`
+	Display  *VMSpecDisplay `yaml:"display,omitempty"`
+}
+
+// VMSpecDisk is one ZVol-backed disk.
+type VMSpecDisk struct {
+	Name      string `yaml:"name,omitempty"` // label in logs; default disk<index>
+	ZVol      string `yaml:"zvol"`           // dataset path, e.g. flashstor/VM/cp_0-boot
+	SizeGB    int    `yaml:"size_gb"`
+	Sparse    *bool  `yaml:"sparse,omitempty"`    // default true (thin provisioned)
+	BlockSize string `yaml:"blocksize,omitempty"` // volblocksize; default: the pool's
+	Order     int    `yaml:"order,omitempty"`
+	Serial    string `yaml:"serial,omitempty"` // default random
+	// Compression is the ZVol's compression (LZ4, ZSTD, OFF, ...); default
+	// inherited.
+	Compression string `yaml:"compression,omitempty"`
+}
+
+// VMSpecNIC is one network interface.
+type VMSpecNIC struct {
+	Bridge string `yaml:"bridge,omitempty"` // default hypervisors.truenas.vm.network_bridge
+	MAC    string `yaml:"mac,omitempty"`    // default random
+	Model  string `yaml:"model,omitempty"`  // VIRTIO (default) or E1000
+	Order  int    `yaml:"order,omitempty"`
+}
+
+// VMSpecDisplay tunes the SPICE display; its password still comes from
+// SPICE_PASSWORD or 1Password.
+type VMSpecDisplay struct {
+	Enabled    *bool  `yaml:"enabled,omitempty"` // default true
+	Bind       string `yaml:"bind,omitempty"`    // default hypervisors.truenas.spice_host
+	Resolution string `yaml:"resolution,omitempty"`
+}
+
+// VMNIC is one NIC of a VMConfig.NICs layout.
+type VMNIC struct {
+	Bridge string
+	MAC    string
+	Model  string
+	Order  int
+}
+
+var (
+	vmSpecNICModels  = []string{"VIRTIO", "E1000"}
+	vmSpecBlockSizes = []string{"512", "1K", "2K", "4K", "8K", "16K", "32K", "64K", "128K"}
+	// reservedDeviceOrders are taken by devices the spec does not list.
+	reservedDeviceOrders = map[int]string{1003: "the display", 1006: "the install CD-ROM", configISODeviceOrder: "the config CD-ROM"}
+)
+
+// LoadVMSpec reads and validates a spec file. Unknown keys are errors.
+func LoadVMSpec(path string) (*VMSpec, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- operator-supplied spec file
+	if err != nil {
+		return nil, fmt.Errorf("failed to read VM spec %s: %w", path, err)
+	}
+	spec, err := ParseVMSpec(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VM spec %s: %w", path, err)
+	}
+	return spec, nil
+}
+
+// ParseVMSpec decodes and validates spec YAML.
+func ParseVMSpec(data []byte) (*VMSpec, error) {
+	spec := &VMSpec{}
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(spec); err != nil {
+		return nil, err
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// Validate reports every invalid field, one "yaml.path: problem" per line.
+func (s *VMSpec) Validate() error {
+	var problems []string
+	add := func(path, format string, args ...interface{}) {
+		problems = append(problems, path+": "+fmt.Sprintf(format, args...))
+	}
+	if s.MemoryMB < 0 {
+		add("memory_mb", "must not be negative")
+	}
+	if s.VCPUs < 0 {
+		add("vcpus", "must not be negative")
+	}
+
+	orders := map[int]string{}
+	claim := func(path string, order int) {
+		if order <= 0 {
+			add(path+".order", "must be greater than 0")
+			return
+		}
+		if owner, ok := reservedDeviceOrders[order]; ok {
+			add(path+".order", "%d is reserved for %s", order, owner)
+			return
+		}
+		if other, ok := orders[order]; ok {
+			add(path+".order", "%d is already used by %s", order, other)
+			return
+		}
+		orders[order] = path
+	}
+	zvols, names := map[string]string{}, map[string]string{}
+	for i, disk := range s.resolvedDisks() {
+		path := fmt.Sprintf("disks[%d]", i)
+		if other, ok := names[disk.Name]; ok {
+			add(path+".name", "%s is already used by %s", disk.Name, other)
+		}
+		names[disk.Name] = path
+		switch {
+		case disk.ZVol == "":
+			add(path+".zvol", "required")
+		case strings.HasPrefix(disk.ZVol, "/"):
+			add(path+".zvol", "%q must be a dataset path like pool/VM/name, not a device path", disk.ZVol)
+		case !strings.Contains(disk.ZVol, "/"):
+			add(path+".zvol", "%q must include its pool, e.g. pool/VM/name", disk.ZVol)
+		default:
+			if other, ok := zvols[disk.ZVol]; ok {
+				add(path+".zvol", "%s is already used by %s", disk.ZVol, other)
+			}
+			zvols[disk.ZVol] = path
+		}
+		if disk.SizeGB <= 0 {
+			add(path+".size_gb", "must be greater than 0")
+		}
+		if disk.BlockSize != "" && !containsFold(vmSpecBlockSizes, disk.BlockSize) {
+			add(path+".blocksize", "%q is not a ZFS volblocksize (use one of %s)", disk.BlockSize, strings.Join(vmSpecBlockSizes, ", "))
+		}
+		if disk.Compression != "" && !zvolCompressionPattern.MatchString(disk.Compression) {
+			add(path+".compression", "%q is not a ZFS compression for a ZVol (e.g. LZ4, ZSTD, OFF)", disk.Compression)
+		}
+		if disk.Serial != "" && !diskSerialPattern.MatchString(disk.Serial) {
+			add(path+".serial", "%q must be 1-20 letters, digits, '-' or '_'", disk.Serial)
+		}
+		claim(path, disk.Order)
+	}
+	for i, nic := range s.resolvedNICs("") {
+		path := fmt.Sprintf("nics[%d]", i)
+		if nic.MAC != "" {
+			if _, err := net.ParseMAC(nic.MAC); err != nil {
+				add(path+".mac", "%q is not a MAC address", nic.MAC)
+			}
+		}
+		if !containsFold(vmSpecNICModels, nic.Model) {
+			add(path+".model", "%q is not supported (use VIRTIO or E1000)", nic.Model)
+		}
+		claim(path, nic.Order)
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// Apply copies the spec's devices, display settings and ISO onto config.
+// Name, memory, vCPUs and pool reach config through the deploy-vm flags the
+// spec fills in, so flags given on the command line win. The ISO is kept when
+// config already uses a generated one (--generate-iso).
+func (s *VMSpec) Apply(config *VMConfig) {
+	if len(s.Disks) > 0 {
+		config.Disks = s.resolvedDisks()
+	}
+	if len(s.NICs) > 0 {
+		config.NICs = s.resolvedNICs(config.NetworkBridge)
+	}
+	if s.Display != nil {
+		if s.Display.Enabled != nil && !*s.Display.Enabled {
+			config.UseSpice = false
+		}
+		config.SpiceBind = s.Display.Bind
+		config.SpiceResolution = s.Display.Resolution
+	}
+	if s.ISO != "" && !config.CustomISO {
+		config.TalosISO = s.ISO
+	}
+}
+
+func (s *VMSpec) resolvedDisks() []VMDisk {
+	disks := make([]VMDisk, len(s.Disks))
+	for i, disk := range s.Disks {
+		disks[i] = VMDisk{
+			Name:        disk.Name,
+			ZVol:        disk.ZVol,
+			SizeGB:      disk.SizeGB,
+			Sparse:      disk.Sparse == nil || *disk.Sparse,
+			BlockSize:   strings.ToUpper(disk.BlockSize),
+			Order:       disk.Order,
+			Serial:      disk.Serial,
+			Compression: strings.ToUpper(disk.Compression),
+		}
+		if disks[i].Name == "" {
+			disks[i].Name = fmt.Sprintf("disk%d", i)
+		}
+		if disks[i].Order == 0 {
+			disks[i].Order = diskDeviceOrder(i)
+		}
+	}
+	return disks
+}
+
+func (s *VMSpec) resolvedNICs(defaultBridge string) []VMNIC {
+	nics := make([]VMNIC, len(s.NICs))
+	for i, nic := range s.NICs {
+		nics[i] = VMNIC{Bridge: nic.Bridge, MAC: nic.MAC, Model: strings.ToUpper(nic.Model), Order: nic.Order}
+		if nics[i].Bridge == "" {
+			nics[i].Bridge = defaultBridge
+		}
+		if nics[i].Model == "" {
+			nics[i].Model = "VIRTIO"
+		}
+		if nics[i].Order == 0 {
+			nics[i].Order = defaultDeviceOrder(i, 1002, 1020)
+		}
+	}
+	return nics
+}
+
+// defaultDeviceOrder keeps the first device at its default-layout order and
+// numbers the rest from base.
+func defaultDeviceOrder(index, first, base int) int {
+	if index == 0 {
+		return first
+	}
+	return base + index
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package truenas
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fourDiskSpec = `
+name: storage_0
+memory_mb: 32768
+vcpus: 8
+iso: /mnt/flashstor/isos/talos-storage.iso
+disks:
+  - name: boot
+    zvol: flashstor/VM/storage_0-boot
+    size_gb: 64
+  - name: openebs
+    zvol: flashstor/VM/storage_0-openebs
+    size_gb: 500
+    blocksize: 16k
+  - name: ceph-a
+    zvol: tank/VM/storage_0-ceph-a
+    size_gb: 2000
+    sparse: false
+  - name: ceph-b
+    zvol: tank/VM/storage_0-ceph-b
+    size_gb: 2000
+    order: 1030
+nics:
+  - bridge: br0
+    mac: 00:0c:29:aa:bb:cc
+  - bridge: br-storage
+    model: e1000
+display:
+  enabled: false
+`
+
+func TestDeployVMFromAFourDiskTwoNICSpec(t *testing.T) {
+	spec, err := ParseVMSpec([]byte(fourDiskSpec))
+	require.NoError(t, err)
+	assert.Equal(t, "storage_0", spec.Name)
+	assert.Equal(t, 32768, spec.MemoryMB)
+
+	manager := NewVMManager("nas", "key", 443, true)
+	datasets := map[string]bool{"flashstor": true, "flashstor/VM": true, "tank": true, "tank/VM": true}
+	var calls []string
+	manager.client.callFn = func(method string, params interface{}, _ int64) (json.RawMessage, error) {
+		args, _ := params.([]interface{})
+		switch method {
+		case "vm.query":
+			return mustJSON(map[string]any{"result": []map[string]any{}}), nil
+		case "pool.dataset.query":
+			var out []map[string]any
+			for name := range datasets {
+				out = append(out, map[string]any{"name": name})
+			}
+			return mustJSON(map[string]any{"result": out}), nil
+		case "pool.dataset.create":
+			zvol := datasetCreateArgs(params)
+			datasets[zvol["name"].(string)] = true
+			calls = append(calls, fmt.Sprintf("zvol %v sparse=%v blocksize=%v", zvol["name"], zvol["sparse"] == true, zvol["volblocksize"]))
+			return mustJSON(map[string]any{"result": true}), nil
+		case "vm.create":
+			vmConfig := args[0].(map[string]interface{})
+			calls = append(calls, fmt.Sprintf("vm %v memory=%v vcpus=%v", vmConfig["name"], vmConfig["memory"], vmConfig["vcpus"]))
+			return mustJSON(map[string]any{"result": map[string]any{"id": 7, "name": "storage_0"}}), nil
+		case "vm.device.create":
+			device := args[0].(map[string]interface{})
+			attrs := device["attributes"].(map[string]interface{})
+			detail := attrs["path"]
+			if attrs["dtype"] == "NIC" {
+				detail = fmt.Sprintf("%v/%v", attrs["nic_attach"], attrs["type"])
+			}
+			calls = append(calls, fmt.Sprintf("device %v %v %v", device["order"], attrs["dtype"], detail))
+			return mustJSON(map[string]any{"result": map[string]any{"id": len(calls)}}), nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	}
+
+	config := VMConfig{
+		Name: spec.Name, Memory: spec.MemoryMB, VCPUs: spec.VCPUs, StoragePool: "flashstor",
+		NetworkBridge: "br0", TalosISO: "/mnt/flashstor/isos/talos.iso", SpicePassword: "secret", UseSpice: true,
+	}
+	spec.Apply(&config)
+	require.NoError(t, manager.DeployVM(config))
+	assert.Equal(t, []string{
+		"zvol flashstor/VM/storage_0-boot sparse=true blocksize=<nil>",
+		"zvol flashstor/VM/storage_0-openebs sparse=true blocksize=16K",
+		"zvol tank/VM/storage_0-ceph-a sparse=false blocksize=<nil>",
+		"zvol tank/VM/storage_0-ceph-b sparse=true blocksize=<nil>",
+		"vm storage_0 memory=32768 vcpus=8",
+		"device 1006 CDROM /mnt/flashstor/isos/talos-storage.iso",
+		"device 1002 NIC br0/VIRTIO",
+		"device 1021 NIC br-storage/E1000",
+		"device 1001 DISK /dev/zvol/flashstor/VM/storage_0-boot",
+		"device 1004 DISK /dev/zvol/flashstor/VM/storage_0-openebs",
+		"device 1012 DISK /dev/zvol/tank/VM/storage_0-ceph-a",
+		"device 1030 DISK /dev/zvol/tank/VM/storage_0-ceph-b",
+	}, calls, "no display device: display.enabled is false")
+}
+
+func TestParseVMSpecReportsTheYAMLPathOfEachProblem(t *testing.T) {
+	_, err := ParseVMSpec([]byte(`
+vcpus: -1
+disks:
+  - zvol: /dev/zvol/flashstor/VM/a
+    size_gb: 10
+  - zvol: flashstor/VM/b
+    size_gb: 0
+    blocksize: 3K
+    order: 1006
+  - zvol: flashstor/VM/b
+    size_gb: 10
+nics:
+  - mac: not-a-mac
+    model: rtl8139
+    order: 1001
+`))
+	require.Error(t, err)
+	assert.Equal(t, `disks[0].zvol: "/dev/zvol/flashstor/VM/a" must be a dataset path like pool/VM/name, not a device path
+disks[1].blocksize: "3K" is not a ZFS volblocksize (use one of 512, 1K, 2K, 4K, 8K, 16K, 32K, 64K, 128K)
+disks[1].order: 1006 is reserved for the install CD-ROM
+disks[1].size_gb: must be greater than 0
+disks[2].zvol: flashstor/VM/b is already used by disks[1]
+nics[0].mac: "not-a-mac" is not a MAC address
+nics[0].model: "RTL8139" is not supported (use VIRTIO or E1000)
+nics[0].order: 1001 is already used by disks[0]
+vcpus: must not be negative`, err.Error())
+
+	_, err = ParseVMSpec([]byte("name: a\ncpus: 4\n"))
+	require.ErrorContains(t, err, "field cpus not found")
+}
+
+func TestLoadVMSpecAndApplyPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vm.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("iso: /isos/spec.iso\nnics:\n  - mac: 00:0c:29:00:00:01\ndisplay:\n  bind: 10.0.0.2\n  resolution: 1280x1024\n"), 0o600))
+	spec, err := LoadVMSpec(path)
+	require.NoError(t, err)
+
+	config := VMConfig{NetworkBridge: "br0", TalosISO: "/isos/generated.iso", CustomISO: true, UseSpice: true}
+	spec.Apply(&config)
+	assert.Equal(t, "/isos/generated.iso", config.TalosISO, "a generated ISO wins over the spec's")
+	assert.Nil(t, config.Disks, "no spec disks keeps the default layout")
+	assert.Equal(t, []VMNIC{{Bridge: "br0", MAC: "00:0c:29:00:00:01", Model: "VIRTIO", Order: 1002}}, config.NICs)
+	assert.True(t, config.UseSpice)
+	assert.Equal(t, "1280x1024", spiceDisplayAttributes(config)["resolution"])
+	assert.Equal(t, "10.0.0.2", spiceDisplayAttributes(config)["bind"])
+
+	_, err = LoadVMSpec(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.ErrorContains(t, err, "failed to read VM spec")
+	require.NoError(t, os.WriteFile(path, []byte("disks:\n  - size_gb: 1\n"), 0o600))
+	_, err = LoadVMSpec(path)
+	require.ErrorContains(t, err, "invalid VM spec "+path+": disks[0].zvol: required")
+}
@@ -0,0 +1,68 @@
+package truenas
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// vmStartTimeout bounds how long DeployVM waits for a started VM to report
+// RUNNING; vm.start returns once libvirt accepts the domain, but a guest
+// that cannot be scheduled (memory overcommit, a missing PCI device) falls
+// back to STOPPED shortly after. Both are shortened in tests.
+var (
+	vmStartTimeout       = 60 * time.Second
+	vmStatusPollInterval = 2 * time.Second
+)
+
+// startDeployedVM starts the VM DeployVM just created and waits for it to
+// reach RUNNING. A VM that does not start is left in place: it is deployed,
+// and its configuration is what needs looking at.
+func (vm *VMManager) startDeployedVM(vmID int, config VMConfig) error {
+	vm.logger.Info("Starting VM: %s (ID: %d)", config.Name, vmID)
+	if err := vm.client.StartVM(vmID); err != nil {
+		return fmt.Errorf("VM %s was deployed but failed to start: %w", config.Name, err)
+	}
+	if vm.dryRunning() {
+		return nil
+	}
+	deadline := time.Now().Add(vmStartTimeout)
+	for {
+		status, err := vm.client.GetVMStatus(vmID)
+		if err != nil {
+			return fmt.Errorf("VM %s was started but its status is unknown: %w", config.Name, err)
+		}
+		state, _ := status["state"].(string)
+		if strings.EqualFold(state, "RUNNING") {
+			vm.logger.Success("VM %s is running", config.Name)
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("VM %s did not reach RUNNING within %s (%s); %s", config.Name, vmStartTimeout, describeVMStatus(status), vmStartHint(config))
+		}
+		vm.logger.Debug("VM %s is %s; waiting for RUNNING", config.Name, state)
+		time.Sleep(vmStatusPollInterval)
+	}
+}
+
+// describeVMStatus renders the vm.status fields that explain a VM that is
+// not running, e.g. "state STOPPED, domain_state SHUTOFF".
+func describeVMStatus(status map[string]interface{}) string {
+	parts := []string{}
+	for _, key := range []string{"state", "domain_state", "pid"} {
+		if value, ok := status[key]; ok && value != nil && value != "" {
+			parts = append(parts, fmt.Sprintf("%s %v", key, value))
+		}
+	}
+	if len(parts) == 0 {
+		return "no status reported"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func vmStartHint(config VMConfig) string {
+	if config.SerialLog != nil {
+		return fmt.Sprintf("check 'homeops-cli vm truenas console-log --name %s' and the NAS's /var/log/middlewared.log", config.Name)
+	}
+	return "check the NAS's /var/log/middlewared.log and the VM's devices"
+}
@@ -0,0 +1,68 @@
+package truenas
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startingNAS fakes a NAS that deploys cp-0 and reports the given vm.status
+// states in turn (the last one repeating). It returns the manager and the
+// vm.create payload.
+func startingNAS(t *testing.T, states ...string) (*VMManager, *[]string, *map[string]interface{}) {
+	t.Helper()
+	oldInterval, oldTimeout := vmStatusPollInterval, vmStartTimeout
+	vmStatusPollInterval, vmStartTimeout = time.Millisecond, 50*time.Millisecond
+	t.Cleanup(func() { vmStatusPollInterval, vmStartTimeout = oldInterval, oldTimeout })
+	manager, mutations := failingDisplayNAS(t, nil)
+	created := map[string]interface{}{}
+	deploy := manager.client.callFn
+	polls := 0
+	manager.client.callFn = func(method string, params interface{}, timeout int64) (json.RawMessage, error) {
+		args, _ := params.([]interface{})
+		switch method {
+		case "vm.create":
+			created = args[0].(map[string]interface{})
+		case "vm.start":
+			*mutations = append(*mutations, fmt.Sprintf("%s %v", method, args[0]))
+			return mustJSON(map[string]any{"result": true}), nil
+		case "vm.status":
+			state := states[min(polls, len(states)-1)]
+			polls++
+			return mustJSON(map[string]any{"result": map[string]any{"state": state, "pid": nil, "domain_state": state}}), nil
+		}
+		return deploy(method, params, timeout)
+	}
+	return manager, mutations, &created
+}
+
+func TestDeployVMStartsTheVMAndWaitsForRunning(t *testing.T) {
+	manager, mutations, created := startingNAS(t, "STOPPED", "STOPPED", "RUNNING")
+	config := failingDisplayConfig
+	config.UseSpice = false
+	config.Start = true
+	config.Autostart = true
+	require.NoError(t, manager.DeployVM(config))
+	assert.Equal(t, "vm.start 41", (*mutations)[len(*mutations)-1], "the VM is started after its devices")
+	assert.Equal(t, true, (*created)["autostart"], "--autostart reaches vm.create")
+
+	manager, mutations, created = startingNAS(t, "RUNNING")
+	config.Start, config.Autostart = false, false
+	require.NoError(t, manager.DeployVM(config))
+	assert.NotContains(t, *mutations, "vm.start 41")
+	assert.Equal(t, false, (*created)["autostart"])
+}
+
+func TestDeployVMReportsAVMThatDoesNotStart(t *testing.T) {
+	manager, mutations, _ := startingNAS(t, "STOPPED")
+	config := failingDisplayConfig
+	config.UseSpice = false
+	config.Start = true
+	err := manager.DeployVM(config)
+	require.EqualError(t, err, "VM cp-0 did not reach RUNNING within 50ms (state STOPPED, domain_state STOPPED); check the NAS's /var/log/middlewared.log and the VM's devices")
+	assert.NotContains(t, *mutations, "vm.delete 41", "a deployed VM that does not start is not rolled back")
+}
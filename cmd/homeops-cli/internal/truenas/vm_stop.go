@@ -0,0 +1,104 @@
+package truenas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Stopping a VM is an ACPI shutdown (vm.stop {force: false}) followed by a
+// wait of up to the stop timeout for vm.status to report STOPPED; a guest
+// that is still running then is forced off with vm.poweroff. A Talos node
+// given the time drains its etcd member and kubelet cleanly, where a
+// poweroff is a pulled plug.
+
+// DefaultVMStopTimeout is how long StopVM and DeleteVM wait for a guest to
+// power down after the shutdown request.
+const DefaultVMStopTimeout = 2 * time.Minute
+
+// vmPowerOffTimeout bounds the wait for STOPPED after vm.poweroff, which
+// returns before libvirt has torn the domain down; shortened in tests.
+var vmPowerOffTimeout = 30 * time.Second
+
+// SetStopTimeout sets how long StopVM and DeleteVM wait for a clean
+// shutdown before forcing the VM off (vm stop/delete --stop-timeout); zero
+// restores DefaultVMStopTimeout.
+func (vm *VMManager) SetStopTimeout(timeout time.Duration) {
+	vm.stopTimeout = timeout
+}
+
+// stopVM brings vmItem to STOPPED: a shutdown, then a poweroff once the stop
+// timeout passes, or a poweroff at once with force. A VM that is already
+// stopped is left alone.
+func (vm *VMManager) stopVM(vmItem *VM, force bool) error {
+	if vmState(vmItem) == "STOPPED" {
+		vm.logger.Info("VM %s is already stopped", vmItem.Name)
+		return nil
+	}
+	if !force {
+		timeout := vm.stopTimeout
+		if timeout <= 0 {
+			timeout = DefaultVMStopTimeout
+		}
+		vm.logger.Info("Waiting up to %s for VM %s to shut down...", timeout, vmItem.Name)
+		deadline := time.Now().Add(timeout)
+		// The stop job is cut short at the deadline: past it the VM is
+		// forced off whatever the middleware's shutdown_timeout says.
+		ctx, cancel := context.WithDeadline(vm.client.baseContext(), deadline)
+		err := vm.client.StopVMContext(ctx, vmItem.ID)
+		cancel()
+		if err != nil && !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return err
+		}
+		stopped, status, err := vm.waitForVMState(vmItem.ID, "STOPPED", time.Until(deadline))
+		if err != nil || stopped {
+			return err
+		}
+		vm.logger.Warn("VM %s did not shut down within %s (%s); forcing power off", vmItem.Name, timeout, describeVMStatus(status))
+	}
+	if err := vm.client.PowerOffVM(vmItem.ID); err != nil {
+		return err
+	}
+	stopped, status, err := vm.waitForVMState(vmItem.ID, "STOPPED", vmPowerOffTimeout)
+	if err != nil {
+		return err
+	}
+	if !stopped {
+		return fmt.Errorf("VM %s is still running after a forced power off (%s)", vmItem.Name, describeVMStatus(status))
+	}
+	return nil
+}
+
+// waitForVMState polls vm.status until the VM reports state or timeout
+// passes, returning the last status seen.
+func (vm *VMManager) waitForVMState(vmID int, state string, timeout time.Duration) (bool, map[string]interface{}, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		status, err := vm.client.GetVMStatus(vmID)
+		if err != nil {
+			return false, nil, err
+		}
+		if current, _ := status["state"].(string); strings.EqualFold(current, state) {
+			return true, status, nil
+		}
+		if !time.Now().Before(deadline) {
+			return false, status, nil
+		}
+		time.Sleep(vmStatusPollInterval)
+	}
+}
+
+// waitForVMRunning waits up to vmStartTimeout for a VM that was just
+// started or restarted to report RUNNING.
+func (vm *VMManager) waitForVMRunning(vmItem *VM) error {
+	running, status, err := vm.waitForVMState(vmItem.ID, "RUNNING", vmStartTimeout)
+	if err != nil {
+		return fmt.Errorf("VM %s was started but its status is unknown: %w", vmItem.Name, err)
+	}
+	if !running {
+		return fmt.Errorf("VM %s did not reach RUNNING within %s (%s)", vmItem.Name, vmStartTimeout, describeVMStatus(status))
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package truenas
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stoppingNAS fakes a NAS with one running VM, cp-0 (ID 9), that reports
+// RUNNING until the given number of vm.status polls has passed, or until it
+// is powered off when powerOffStops is set. It returns the manager and the
+// stop, poweroff and delete calls in order.
+func stoppingNAS(t *testing.T, runningPolls int, powerOffStops bool) (*VMManager, *[]string) {
+	t.Helper()
+	oldInterval, oldPowerOff := vmStatusPollInterval, vmPowerOffTimeout
+	vmStatusPollInterval, vmPowerOffTimeout = time.Millisecond, 20*time.Millisecond
+	t.Cleanup(func() { vmStatusPollInterval, vmPowerOffTimeout = oldInterval, oldPowerOff })
+	oldSleep := sleepForOperation
+	sleepForOperation = func(time.Duration) {}
+	t.Cleanup(func() { sleepForOperation = oldSleep })
+
+	manager := NewVMManager("nas", "key", 443, true)
+	var calls []string
+	polls, poweredOff, deleted := 0, false, false
+	manager.client.callFn = func(method string, params interface{}, _ int64) (json.RawMessage, error) {
+		args, _ := params.([]interface{})
+		switch method {
+		case "vm.query":
+			if deleted {
+				return mustJSON(map[string]any{"result": []map[string]any{}}), nil
+			}
+			return mustJSON(map[string]any{"result": []map[string]any{{"id": 9, "name": "cp-0", "status": map[string]any{"state": "RUNNING"}}}}), nil
+		case "vm.device.query":
+			return mustJSON(map[string]any{"result": []map[string]any{}}), nil
+		case "vm.stop":
+			calls = append(calls, fmt.Sprintf("stop %v", args[1]))
+			return mustJSON(map[string]any{"result": true}), nil
+		case "vm.poweroff":
+			calls = append(calls, "poweroff")
+			poweredOff = powerOffStops
+			return mustJSON(map[string]any{"result": true}), nil
+		case "vm.status":
+			state := "RUNNING"
+			if polls++; polls > runningPolls || poweredOff {
+				state = "STOPPED"
+			}
+			return mustJSON(map[string]any{"result": map[string]any{"state": state}}), nil
+		case "vm.delete":
+			calls = append(calls, "delete")
+			deleted = true
+			return mustJSON(map[string]any{"result": true}), nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	}
+	return manager, &calls
+}
+
+func TestStopVMWaitsForACleanShutdown(t *testing.T) {
+	manager, calls := stoppingNAS(t, 3, true)
+	require.NoError(t, manager.StopVM("cp-0", false))
+	assert.Equal(t, []string{"stop map[force:false force_after_timeout:false]"}, *calls, "a guest that powers down in time is not forced off")
+
+	manager, calls = stoppingNAS(t, 3, true)
+	require.NoError(t, manager.StopVM("cp-0", true))
+	assert.Equal(t, []string{"poweroff"}, *calls, "--force skips the shutdown")
+}
+
+func TestStopVMForcesAGuestThatIgnoresTheShutdown(t *testing.T) {
+	manager, calls := stoppingNAS(t, 1000, true)
+	manager.SetStopTimeout(10 * time.Millisecond)
+	require.NoError(t, manager.StopVM("cp-0", false))
+	assert.Equal(t, []string{"stop map[force:false force_after_timeout:false]", "poweroff"}, *calls)
+
+	manager, calls = stoppingNAS(t, 1000, false)
+	manager.SetStopTimeout(10 * time.Millisecond)
+	err := manager.DeleteVM("cp-0", false, "flashstor")
+	require.EqualError(t, err, "refusing to delete VM cp-0: it could not be stopped: VM cp-0 is still running after a forced power off (state RUNNING)")
+	assert.NotContains(t, *calls, "delete")
+
+	manager, calls = stoppingNAS(t, 2, true)
+	require.NoError(t, manager.DeleteVM("cp-0", false, "flashstor"))
+	assert.Equal(t, []string{"stop map[force:false force_after_timeout:false]", "delete"}, *calls, "a running VM is shut down before it is deleted")
+}
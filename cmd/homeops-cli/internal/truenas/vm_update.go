@@ -0,0 +1,257 @@
+package truenas
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DeployVM refuses a name that already exists unless VMConfig.Update is set.
+// It then reconciles the live VM (vm.get_instance) with the config instead:
+// vm.update for the VM fields that differ, vm.device.update for devices whose
+// tracked attributes differ (a missing device is created), and
+// pool.dataset.update to grow ZVols. Everything is planned before anything
+// is changed, so a ZVol larger than requested (ZFS volumes cannot shrink)
+// fails the run untouched. An unchanged re-run changes nothing.
+
+// updatableVMFields are the buildVMConfig keys reconciled by --update.
+var updatableVMFields = []string{"memory", "vcpus", "cpu_mode", "cpu_model", "cpuset", "nodeset", "pin_vcpus", "command_line_args"}
+
+// wantedDevice is one device the config asks for. Live devices are matched
+// by order and dtype; only the compare attributes are diffed, so generated
+// values such as disk serials do not count as changes.
+type wantedDevice struct {
+	label      string
+	order      int
+	attributes map[string]interface{}
+	compare    []string
+	// generated are the attributes given a random value at create (a
+	// disk's serial, a NIC's MAC), which verify does not compare.
+	generated []string
+}
+
+type deviceChange struct {
+	wantedDevice
+	id      int // 0 when the device is missing and gets created
+	current map[string]interface{}
+	changed []string
+}
+
+type zvolResize struct {
+	path     string
+	from, to int64
+}
+
+type vmUpdatePlan struct {
+	fields  map[string]interface{}
+	devices []deviceChange
+	zvols   []zvolResize
+}
+
+func (p *vmUpdatePlan) empty() bool {
+	return len(p.fields) == 0 && len(p.devices) == 0 && len(p.zvols) == 0
+}
+
+// updateVM reconciles existing with config (see the comment at the top).
+func (vm *VMManager) updateVM(existing *VM, config VMConfig) error {
+	vm.logger.Info("VM %s already exists (ID: %d); updating it in place", config.Name, existing.ID)
+	live, err := vm.client.GetVM(existing.ID)
+	if err != nil {
+		return err
+	}
+	plan, err := vm.planVMUpdate(live, config)
+	if err != nil {
+		return err
+	}
+	if plan.empty() {
+		vm.logger.Success("VM %s already matches the requested configuration; nothing to update", config.Name)
+		return nil
+	}
+
+	for _, resize := range plan.zvols {
+		if err := vm.client.UpdateDataset(resize.path, map[string]interface{}{"volsize": resize.to}); err != nil {
+			return err
+		}
+		vm.logger.Info("Grew ZVol %s from %dGiB to %dGiB", resize.path, resize.from>>30, resize.to>>30)
+	}
+	if len(plan.fields) > 0 {
+		if err := vm.client.UpdateVM(live.ID, plan.fields); err != nil {
+			return err
+		}
+		vm.logger.Info("Updated VM %s: %s", config.Name, strings.Join(sortedKeys(plan.fields), ", "))
+	}
+	for _, change := range plan.devices {
+		if change.id == 0 {
+			if err := vm.createVMDevice(live.ID, change.order, change.attributes); err != nil {
+				return fmt.Errorf("failed to create %s device: %w", change.label, err)
+			}
+			vm.logger.Info("Created missing %s device", change.label)
+			continue
+		}
+		attributes := make(map[string]interface{}, len(change.current))
+		for key, value := range change.current {
+			attributes[key] = value
+		}
+		for _, key := range change.changed {
+			attributes[key] = change.attributes[key]
+		}
+		if err := vm.client.UpdateVMDevice(change.id, attributes); err != nil {
+			return err
+		}
+		vm.logger.Info("Updated %s device %d: %s", change.label, change.id, strings.Join(change.changed, ", "))
+	}
+
+	if vmIsRunning(live) {
+		vm.logger.Warn("VM %s is running — the changes apply after a restart ('homeops-cli vm restart --name %s')", config.Name, config.Name)
+	}
+	vm.logger.Success("Successfully updated VM: %s", config.Name)
+	return nil
+}
+
+func (vm *VMManager) planVMUpdate(live *VM, config VMConfig) (*vmUpdatePlan, error) {
+	plan := &vmUpdatePlan{fields: map[string]interface{}{}}
+
+	if !config.SkipZVolCreate {
+		for _, disk := range vm.vmDisks(config) {
+			current, err := vm.client.GetZvolSize(disk.ZVol)
+			if err != nil {
+				return nil, err
+			}
+			want := int64(disk.SizeGB) * 1024 * 1024 * 1024
+			if want < current {
+				return nil, fmt.Errorf("%s ZVol %s is %dGiB; refusing to shrink it to %dGiB (ZVols can only grow)", disk.Name, disk.ZVol, current>>30, want>>30)
+			}
+			if want > current {
+				plan.zvols = append(plan.zvols, zvolResize{path: disk.ZVol, from: current, to: want})
+			}
+		}
+	}
+
+	desired := vm.buildVMConfig(config)
+	current := liveVMFields(live)
+	for _, key := range updatableVMFields {
+		if !sameAttribute(current[key], desired[key]) {
+			plan.fields[key] = desired[key]
+		}
+	}
+
+	for _, want := range vm.wantedVMDevices(config) {
+		liveDevice := findVMDevice(live.Devices, want.order, want.attributes["dtype"].(string))
+		if liveDevice == nil {
+			plan.devices = append(plan.devices, deviceChange{wantedDevice: want})
+			continue
+		}
+		attributes, _ := liveDevice["attributes"].(map[string]interface{})
+		var changed []string
+		for _, key := range want.compare {
+			if !sameAttribute(attributes[key], want.attributes[key]) {
+				changed = append(changed, key)
+			}
+		}
+		if len(changed) > 0 {
+			plan.devices = append(plan.devices, deviceChange{
+				wantedDevice: want,
+				id:           intAttr(liveDevice, "id"),
+				current:      attributes,
+				changed:      changed,
+			})
+		}
+	}
+	return plan, nil
+}
+
+// liveVMFields are live's buildVMConfig fields that --update and verify
+// compare.
+func liveVMFields(live *VM) map[string]interface{} {
+	return map[string]interface{}{
+		"memory":            live.Memory,
+		"vcpus":             live.VCPUs,
+		"bootloader":        live.Bootloader,
+		"autostart":         live.Autostart,
+		"cpu_mode":          live.CPUMode,
+		"cpu_model":         live.CPUModel,
+		"cpuset":            live.CPUSet,
+		"nodeset":           live.NodeSet,
+		"pin_vcpus":         live.PinVCPUs,
+		"command_line_args": live.CommandLineArgs,
+	}
+}
+
+// wantedVMDevices mirrors createVMDevices and createFlatcarVMDevices.
+func (vm *VMManager) wantedVMDevices(config VMConfig) []wantedDevice {
+	var devices []wantedDevice
+	if !config.Flatcar {
+		devices = append(devices, wantedDevice{label: "CD-ROM", order: 1006, attributes: cdromDeviceAttributes(installISOPath(config)), compare: []string{"path"}})
+		if config.ConfigISO != "" {
+			devices = append(devices, wantedDevice{label: "config CD-ROM", order: configISODeviceOrder, attributes: cdromDeviceAttributes(config.ConfigISO), compare: []string{"path"}})
+		}
+	}
+	for i, nic := range vm.vmNICs(config) {
+		compare := []string{"nic_attach", "type"}
+		var generated []string
+		mac := nic.MAC
+		if mac == "" {
+			mac = vm.generateRandomMAC()
+			generated = []string{"mac"}
+		} else {
+			compare = append(compare, "mac")
+		}
+		attributes := nicDeviceAttributes(mac, nic.Bridge)
+		attributes["type"] = nic.Model
+		label := "NIC"
+		if len(config.NICs) > 0 {
+			label = fmt.Sprintf("NIC %d", i)
+		}
+		devices = append(devices, wantedDevice{label: label, order: nic.Order, attributes: attributes, compare: compare, generated: generated})
+	}
+	for _, disk := range vm.vmDisks(config) {
+		var generated []string
+		if disk.Serial == "" {
+			generated = []string{"serial"}
+		}
+		devices = append(devices, wantedDevice{label: disk.Name + " disk", order: disk.Order, attributes: vm.diskDeviceAttributes(disk), compare: []string{"path"}, generated: generated})
+	}
+	if !config.Flatcar && config.UseSpice && config.SpicePassword != "" {
+		devices = append(devices, wantedDevice{label: "display", order: 1003, attributes: spiceDisplayAttributes(config), compare: []string{"bind", "password", "resolution"}})
+	}
+	return devices
+}
+
+// vmNICs is config.NICs, or the default layout's single NIC (1002).
+func (vm *VMManager) vmNICs(config VMConfig) []VMNIC {
+	if len(config.NICs) > 0 {
+		return config.NICs
+	}
+	return []VMNIC{{Bridge: config.NetworkBridge, MAC: config.MacAddress, Model: "VIRTIO", Order: 1002}}
+}
+
+func findVMDevice(devices []VMDevice, order int, dtype string) VMDevice {
+	for _, device := range devices {
+		attributes, _ := device["attributes"].(map[string]interface{})
+		if intAttr(device, "order") == order && attributes["dtype"] == dtype {
+			return device
+		}
+	}
+	return nil
+}
+
+// sameAttribute compares a live value with a desired one by their printed
+// form, so JSON numbers match ints and a nil cpu_model matches "".
+func sameAttribute(live, want interface{}) bool {
+	if live == nil {
+		live = ""
+	}
+	if want == nil {
+		want = ""
+	}
+	return fmt.Sprint(live) == fmt.Sprint(want)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,119 @@
+package truenas
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateConfig = VMConfig{
+	Name: "cp_0", Memory: 8192, VCPUs: 4, DiskSize: 250, OpenEBSSize: 1000,
+	StoragePool: "flashstor", NetworkBridge: "br0", TalosISO: "/isos/talos.iso",
+	MacAddress: "00:0c:29:aa:bb:cc", SpicePassword: "secret", UseSpice: true, Update: true,
+}
+
+// liveUpdateVM is cp_0 exactly as a deploy of updateConfig creates it.
+func liveUpdateVM() map[string]any {
+	device := func(id, order int, attributes map[string]any) map[string]any {
+		return map[string]any{"id": id, "vm": 41, "order": order, "attributes": attributes}
+	}
+	return map[string]any{
+		"id": 41, "name": "cp_0", "memory": 8192, "vcpus": 4,
+		"cpu_mode": CPUModeHostPassthrough, "cpu_model": nil, "cpuset": "", "nodeset": "", "pin_vcpus": false,
+		"command_line_args": "",
+		"status":            map[string]any{"state": "STOPPED"},
+		"devices": []map[string]any{
+			device(1, 1006, map[string]any{"dtype": "CDROM", "path": "/isos/talos.iso"}),
+			device(2, 1002, map[string]any{"dtype": "NIC", "type": "VIRTIO", "mac": "00:0c:29:aa:bb:cc", "nic_attach": "br0", "trust_guest_rx_filters": false}),
+			device(3, 1001, map[string]any{"dtype": "DISK", "path": "/dev/zvol/flashstor/VM/cp_0-boot", "serial": "AAAA1111"}),
+			device(4, 1004, map[string]any{"dtype": "DISK", "path": "/dev/zvol/flashstor/VM/cp_0-openebs", "serial": "BBBB2222"}),
+			device(5, 1003, map[string]any{"dtype": "DISPLAY", "type": "SPICE", "bind": spiceBindAddress(), "password": "secret", "resolution": "1920x1080"}),
+		},
+	}
+}
+
+// updateNAS fakes a NAS holding the live VM and ZVols of the given sizes
+// (GiB). It returns the manager and the mutating calls made, in order.
+func updateNAS(t *testing.T, live map[string]any, zvolGiB map[string]int64) (*VMManager, *[]string) {
+	t.Helper()
+	manager := NewVMManager("nas", "key", 443, true)
+	var mutations []string
+	manager.client.callFn = func(method string, params interface{}, _ int64) (json.RawMessage, error) {
+		args, _ := params.([]interface{})
+		switch method {
+		case "vm.query":
+			return mustJSON(map[string]any{"result": []map[string]any{{"id": 41, "name": "cp_0"}}}), nil
+		case "vm.get_instance":
+			require.Equal(t, 41, args[0])
+			return mustJSON(map[string]any{"result": live}), nil
+		case "pool.dataset.query":
+			id := args[0].([]interface{})[0].([]interface{})[2].(string)
+			size, ok := zvolGiB[id]
+			if !ok {
+				return mustJSON(map[string]any{"result": []map[string]any{}}), nil
+			}
+			return mustJSON(map[string]any{"result": []map[string]any{{
+				"id": id, "type": "VOLUME", "volsize": map[string]any{"parsed": size << 30},
+			}}}), nil
+		case "vm.update", "vm.device.update", "pool.dataset.update":
+			raw, _ := json.Marshal(args[1])
+			mutations = append(mutations, fmt.Sprintf("%s %v %s", method, args[0], raw))
+			return mustJSON(map[string]any{"result": true}), nil
+		case "vm.device.create":
+			attrs := args[0].(map[string]interface{})["attributes"].(map[string]interface{})
+			mutations = append(mutations, fmt.Sprintf("%s %v", method, attrs["dtype"]))
+			return mustJSON(map[string]any{"result": map[string]any{"id": 9}}), nil
+		}
+		return nil, fmt.Errorf("unexpected method %s", method)
+	}
+	return manager, &mutations
+}
+
+var updateZVols = map[string]int64{"flashstor/VM/cp_0-boot": 250, "flashstor/VM/cp_0-openebs": 1000}
+
+func TestDeployVMUpdateIsANoOpWhenNothingChanged(t *testing.T) {
+	manager, mutations := updateNAS(t, liveUpdateVM(), updateZVols)
+	require.NoError(t, manager.DeployVM(updateConfig))
+	require.NoError(t, manager.DeployVM(updateConfig))
+	assert.Empty(t, *mutations)
+}
+
+func TestDeployVMUpdateAppliesOnlyTheChanges(t *testing.T) {
+	live := liveUpdateVM()
+	devices := live["devices"].([]map[string]any)
+	devices[1]["attributes"].(map[string]any)["nic_attach"] = "br1"
+	live["devices"] = devices[:4] // no display device
+	manager, mutations := updateNAS(t, live, map[string]int64{"flashstor/VM/cp_0-boot": 250, "flashstor/VM/cp_0-openebs": 500})
+
+	config := updateConfig
+	config.Memory = 16384
+	require.NoError(t, manager.DeployVM(config))
+	assert.Equal(t, []string{
+		fmt.Sprintf(`pool.dataset.update flashstor/VM/cp_0-openebs {"volsize":%d}`, int64(1000)<<30),
+		`vm.update 41 {"memory":16384}`,
+		`vm.device.update 2 {"attributes":{"dtype":"NIC","mac":"00:0c:29:aa:bb:cc","nic_attach":"br0","trust_guest_rx_filters":false,"type":"VIRTIO"}}`,
+		"vm.device.create DISPLAY",
+	}, *mutations)
+}
+
+func TestDeployVMUpdateRefusesToShrinkAZVol(t *testing.T) {
+	manager, mutations := updateNAS(t, liveUpdateVM(), updateZVols)
+	config := updateConfig
+	config.Memory = 16384
+	config.DiskSize = 100
+
+	err := manager.DeployVM(config)
+	require.ErrorContains(t, err, "boot ZVol flashstor/VM/cp_0-boot is 250GiB; refusing to shrink it to 100GiB")
+	assert.Empty(t, *mutations, "nothing is changed when the plan is refused")
+}
+
+func TestDeployVMWithoutUpdateStillRefusesAnExistingVM(t *testing.T) {
+	manager, mutations := updateNAS(t, liveUpdateVM(), updateZVols)
+	config := updateConfig
+	config.Update = false
+	require.ErrorContains(t, manager.DeployVM(config), "VM with name 'cp_0' already exists")
+	assert.Empty(t, *mutations)
+}
@@ -0,0 +1,156 @@
+package truenas
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// VerifyVM audits an existing VM against the layout DeployVM would create
+// for config: the VM fields buildVMConfig sets, every attribute of every
+// device wantedVMDevices lists (matched by dtype and order, and by path for
+// a disk or CD-ROM moved to another order), devices the layout does not
+// have, and ZVol sizes. Values the middleware or the deploy chooses at
+// create time (display ports, generated serials and MACs) are not compared.
+// Nothing is changed.
+
+// VMDrift is one difference between a VM and its expected layout.
+type VMDrift struct {
+	Field string // e.g. memory, boot disk.iotype, ZVol flashstor/VM/k8s0-boot
+	Want  string
+	Live  string
+}
+
+// verifiedVMFields are the buildVMConfig keys VerifyVM compares.
+var verifiedVMFields = append([]string{"bootloader", "autostart"}, updatableVMFields...)
+
+// createOnlyAttributes are device attributes that only steer vm.device.create
+// and are not reported back.
+var createOnlyAttributes = []string{"create_zvol", "zvol_name", "zvol_volsize"}
+
+// VerifyVM returns every difference between VM config.Name and the layout
+// config describes, nil when there is none.
+func (vm *VMManager) VerifyVM(config VMConfig) ([]VMDrift, error) {
+	vmItem, err := vm.getVMByName(config.Name)
+	if err != nil {
+		return nil, err
+	}
+	live, err := vm.client.GetVM(vmItem.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var drift []VMDrift
+	desired := vm.buildVMConfig(config)
+	current := liveVMFields(live)
+	for _, key := range verifiedVMFields {
+		if !sameAttribute(current[key], desired[key]) {
+			drift = append(drift, VMDrift{Field: key, Want: printedAttribute(desired[key]), Live: printedAttribute(current[key])})
+		}
+	}
+
+	matched := map[int]bool{}
+	for _, want := range vm.wantedVMDevices(config) {
+		index := matchLiveDevice(live.Devices, matched, want)
+		if index < 0 {
+			drift = append(drift, VMDrift{Field: want.label + " device", Want: fmt.Sprintf("order %d", want.order), Live: "missing"})
+			continue
+		}
+		matched[index] = true
+		liveDevice := live.Devices[index]
+		if order := intAttr(liveDevice, "order"); order != want.order {
+			drift = append(drift, VMDrift{Field: want.label + ".order", Want: fmt.Sprint(want.order), Live: fmt.Sprint(order)})
+		}
+		attributes, _ := liveDevice["attributes"].(map[string]interface{})
+		drift = append(drift, attributeDrift(want, attributes)...)
+	}
+	for index, device := range live.Devices {
+		if matched[index] {
+			continue
+		}
+		attributes, _ := device["attributes"].(map[string]interface{})
+		drift = append(drift, VMDrift{
+			Field: fmt.Sprintf("%v device (order %d)", attributes["dtype"], intAttr(device, "order")),
+			Want:  "absent",
+			Live:  describeDevice(attributes),
+		})
+	}
+
+	if !config.SkipZVolCreate {
+		for _, disk := range vm.vmDisks(config) {
+			size, err := vm.client.GetZvolSize(disk.ZVol)
+			if err != nil {
+				return nil, err
+			}
+			if want := int64(disk.SizeGB) << 30; size != want {
+				drift = append(drift, VMDrift{Field: "ZVol " + disk.ZVol, Want: fmt.Sprintf("%dGiB", want>>30), Live: fmt.Sprintf("%dGiB", size>>30)})
+			}
+		}
+	}
+	return drift, nil
+}
+
+// matchLiveDevice is the index of the unmatched live device standing for
+// want: the one of its dtype at its order, else one of its dtype elsewhere
+// (with the same path for a disk or CD-ROM); -1 when there is none.
+func matchLiveDevice(devices []VMDevice, matched map[int]bool, want wantedDevice) int {
+	dtype := want.attributes["dtype"]
+	moved := -1
+	for index, device := range devices {
+		attributes, _ := device["attributes"].(map[string]interface{})
+		if matched[index] || attributes["dtype"] != dtype {
+			continue
+		}
+		if intAttr(device, "order") == want.order {
+			return index
+		}
+		path, hasPath := want.attributes["path"]
+		if moved < 0 && (!hasPath || sameAttribute(attributes["path"], path)) {
+			moved = index
+		}
+	}
+	return moved
+}
+
+// attributeDrift compares every attribute want sets, except those the
+// middleware assigns (nil in want), the create-only ones and generated
+// values.
+func attributeDrift(want wantedDevice, live map[string]interface{}) []VMDrift {
+	keys := make([]string, 0, len(want.attributes))
+	for key, value := range want.attributes {
+		if value == nil || slices.Contains(createOnlyAttributes, key) || slices.Contains(want.generated, key) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var drift []VMDrift
+	for _, key := range keys {
+		if sameAttribute(live[key], want.attributes[key]) {
+			continue
+		}
+		entry := VMDrift{Field: want.label + "." + key, Want: printedAttribute(want.attributes[key]), Live: printedAttribute(live[key])}
+		if key == "password" {
+			entry.Want, entry.Live = "(configured password)", "(a different password)"
+		}
+		drift = append(drift, entry)
+	}
+	return drift
+}
+
+// describeDevice names a device by its type and path or MAC.
+func describeDevice(attributes map[string]interface{}) string {
+	for _, key := range []string{"path", "mac", "type"} {
+		if value, ok := attributes[key].(string); ok && value != "" {
+			return value
+		}
+	}
+	return "present"
+}
+
+func printedAttribute(value interface{}) string {
+	if value == nil || value == "" {
+		return "(unset)"
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,123 @@
+package truenas
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNAS keeps the VM and ZVols a deploy creates and serves them back the
+// way vm.get_instance and pool.dataset.query report them.
+type fakeNAS struct {
+	vm      map[string]interface{}
+	devices []map[string]interface{}
+	zvols   map[string]float64
+}
+
+func (n *fakeNAS) call(method string, params interface{}) (json.RawMessage, error) {
+	args, _ := params.([]interface{})
+	switch method {
+	case "vm.query":
+		if n.vm == nil {
+			return mustJSON(map[string]any{"result": []any{}}), nil
+		}
+		return mustJSON(map[string]any{"result": []any{n.vm}}), nil
+	case "vm.get_instance":
+		instance := map[string]interface{}{"devices": n.devices}
+		for key, value := range n.vm {
+			instance[key] = value
+		}
+		return mustJSON(map[string]any{"result": instance}), nil
+	case "pool.dataset.query":
+		if len(args) > 0 {
+			filters := args[0].([]interface{})
+			id := filters[0].([]interface{})[2].(string)
+			return mustJSON(map[string]any{"result": []any{map[string]any{"id": id, "type": "VOLUME", "volsize": map[string]any{"parsed": n.zvols[id]}}}}), nil
+		}
+		out := []map[string]any{{"name": "flashstor", "id": "flashstor", "type": "FILESYSTEM"}, {"name": "flashstor/VM", "id": "flashstor/VM", "type": "FILESYSTEM"}}
+		for name, size := range n.zvols {
+			out = append(out, map[string]any{"name": name, "id": name, "type": "VOLUME", "volsize": map[string]any{"parsed": size}})
+		}
+		return mustJSON(map[string]any{"result": out}), nil
+	case "pool.dataset.create":
+		zvol := datasetCreateArgs(params)
+		n.zvols[zvol["name"].(string)] = zvol["volsize"].(float64)
+		return mustJSON(map[string]any{"result": true}), nil
+	case "vm.create":
+		var created map[string]interface{}
+		require.NoError(nil, json.Unmarshal(mustJSON(args[0]), &created))
+		created["id"] = 9
+		n.vm = created
+		return mustJSON(map[string]any{"result": created}), nil
+	case "vm.device.create":
+		var device map[string]interface{}
+		require.NoError(nil, json.Unmarshal(mustJSON(args[0]), &device))
+		attributes := device["attributes"].(map[string]interface{})
+		// The middleware assigns ports and does not echo create-only keys.
+		delete(attributes, "create_zvol")
+		if attributes["dtype"] == "DISPLAY" {
+			attributes["port"], attributes["web_port"] = 5900, 5901
+		}
+		device["id"] = len(n.devices) + 20
+		n.devices = append(n.devices, device)
+		return mustJSON(map[string]any{"result": device}), nil
+	}
+	return nil, fmt.Errorf("unexpected method %s", method)
+}
+
+func TestVerifyVMReportsNoDriftForAFreshDeployAndEachGUIEdit(t *testing.T) {
+	nas := &fakeNAS{zvols: map[string]float64{}}
+	manager := NewVMManager("nas", "key", 443, true)
+	manager.client.callFn = func(method string, params interface{}, _ int64) (json.RawMessage, error) {
+		return nas.call(method, params)
+	}
+	config := VMConfig{
+		Name: "k8s0", Memory: 8192, VCPUs: 4, DiskSize: 250, OpenEBSSize: 1000, StoragePool: "flashstor",
+		NetworkBridge: "br0", TalosISO: "/mnt/flashstor/ISO/talos.iso", SpicePassword: "secret", UseSpice: true,
+	}
+	require.NoError(t, manager.DeployVM(config))
+
+	drift, err := manager.VerifyVM(config)
+	require.NoError(t, err)
+	assert.Empty(t, drift, "an untouched VM has not drifted")
+
+	attributesOf := func(dtype string) map[string]interface{} {
+		for _, device := range nas.devices {
+			if attributes := device["attributes"].(map[string]interface{}); attributes["dtype"] == dtype {
+				return attributes
+			}
+		}
+		return nil
+	}
+	nas.vm["memory"] = 4096
+	attributesOf("DISK")["iotype"] = "IO_URING"
+	attributesOf("DISPLAY")["password"] = "changed"
+	for i, device := range nas.devices {
+		if device["attributes"].(map[string]interface{})["dtype"] == "CDROM" {
+			nas.devices = append(nas.devices[:i], nas.devices[i+1:]...)
+			break
+		}
+	}
+	for _, device := range nas.devices {
+		if device["attributes"].(map[string]interface{})["path"] == "/dev/zvol/flashstor/VM/k8s0-openebs" {
+			device["order"] = 1008
+		}
+	}
+	nas.devices = append(nas.devices, map[string]interface{}{"id": 40, "order": 1009, "attributes": map[string]interface{}{"dtype": "USB", "type": "PCI_DEVICE"}})
+	nas.zvols["flashstor/VM/k8s0-boot"] = float64(300 << 30)
+
+	drift, err = manager.VerifyVM(config)
+	require.NoError(t, err)
+	assert.Equal(t, []VMDrift{
+		{Field: "memory", Want: "8192", Live: "4096"},
+		{Field: "CD-ROM device", Want: "order 1006", Live: "missing"},
+		{Field: "boot disk.iotype", Want: "THREADS", Live: "IO_URING"},
+		{Field: "OpenEBS disk.order", Want: "1004", Live: "1008"},
+		{Field: "display.password", Want: "(configured password)", Live: "(a different password)"},
+		{Field: "USB device (order 1009)", Want: "absent", Live: "PCI_DEVICE"},
+		{Field: "ZVol flashstor/VM/k8s0-boot", Want: "250GiB", Live: "300GiB"},
+	}, drift)
+}
@@ -71,11 +71,25 @@ func TestSpinnerModelView(t *testing.T) {
 	assert.Contains(t, view, "doing things")
 	assert.True(t, strings.Contains(view, "(0s)") || strings.Contains(view, "(1s)"))
 
+	updated, _ := m.Update(spinnerStatusMsg("vm.create 40%"))
+	assert.Contains(t, updated.(spinnerModel).View().Content, "doing things — vm.create 40%")
+
 	// done message quits
 	_, cmd := m.Update(spinnerDoneMsg{})
 	require.NotNil(t, cmd)
 }
 
+func TestSpinWithProgressNonTTYDropsStatus(t *testing.T) {
+	called := false
+	err := SpinWithProgress("title", func(status func(string)) error {
+		status("ignored")
+		called = true
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, called)
+}
+
 func TestSuccessBoxOffTerminal(t *testing.T) {
 	// Tests run without a TTY: the flourish must vanish so CI logs stay plain.
 	assert.Empty(t, SuccessBox("done", "line"))
@@ -86,7 +86,11 @@ type StyleOptions struct {
 func Confirm(message string, defaultYes bool) (bool, error) {
 	if assumeYes {
 		// Leave an audit trail of what was auto-confirmed.
-		fmt.Fprintf(os.Stderr, "%s yes (--yes)\n", message)
+		if common.JSONLogging() {
+			common.Logger().Info("%s yes (--yes)", message)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s yes (--yes)\n", message)
+		}
 		return true, nil
 	}
 	if !isInteractive() {
@@ -258,13 +262,17 @@ func inputBasic(prompt string) (string, error) {
 // spinnerDoneMsg. The work runs in a goroutine owned by SpinWithFunc; Ctrl+C
 // detaches the UI but the work keeps running to completion.
 type spinnerModel struct {
-	spin  spinner.Model
-	title string
-	start time.Time
+	spin   spinner.Model
+	title  string
+	status string
+	start  time.Time
 }
 
 type spinnerDoneMsg struct{}
 
+// spinnerStatusMsg replaces the status shown after the title.
+type spinnerStatusMsg string
+
 type spinnerTickMsg time.Time
 
 func newSpinnerModel(title string) spinnerModel {
@@ -286,6 +294,9 @@ func (m spinnerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case spinnerDoneMsg:
 		return m, tea.Quit
+	case spinnerStatusMsg:
+		m.status = string(msg)
+		return m, nil
 	case spinnerTickMsg:
 		return m, spinnerTick()
 	case tea.KeyPressMsg:
@@ -304,7 +315,11 @@ var spinnerElapsedStyle = lipgloss.NewStyle().Faint(true)
 
 func (m spinnerModel) View() tea.View {
 	elapsed := time.Since(m.start).Round(time.Second)
-	return tea.NewView(fmt.Sprintf("%s%s %s", m.spin.View(), m.title, spinnerElapsedStyle.Render(fmt.Sprintf("(%s)", elapsed))))
+	title := m.title
+	if m.status != "" {
+		title += " — " + m.status
+	}
+	return tea.NewView(fmt.Sprintf("%s%s %s", m.spin.View(), title, spinnerElapsedStyle.Render(fmt.Sprintf("(%s)", elapsed))))
 }
 
 // Spin displays a spinner while executing a command.
@@ -336,8 +351,21 @@ func SpinWithOutput(title, command string, args ...string) (string, error) {
 // Off-terminal it simply runs the function. The function's error is always
 // returned; UI failures never mask it.
 func SpinWithFunc(title string, fn func() error) error {
+	return SpinWithProgress(title, func(func(string)) error { return fn() })
+}
+
+// SpinWithProgress is SpinWithFunc for work that reports progress: each call
+// to status replaces the text shown after the title. Off-terminal the updates
+// are dropped. With --log-format json the title and each update are logged
+// instead, so stderr stays NDJSON.
+func SpinWithProgress(title string, fn func(status func(string)) error) error {
+	if common.JSONLogging() {
+		logger := common.Logger().With("spinner", title)
+		logger.Info("%s", title)
+		return fn(func(status string) { logger.Info("%s", status) })
+	}
 	if !isInteractive() {
-		return fn()
+		return fn(func(string) {})
 	}
 
 	// The spinner renders on stderr so any stdout the work produces stays
@@ -352,7 +380,7 @@ func SpinWithFunc(title string, fn func() error) error {
 			}
 			prog.Send(spinnerDoneMsg{})
 		}()
-		errCh <- fn()
+		errCh <- fn(func(status string) { prog.Send(spinnerStatusMsg(status)) })
 	}()
 
 	if _, uiErr := prog.Run(); uiErr != nil {
@@ -0,0 +1,343 @@
+// Package versioncheck compares the versions the CLI resolves from the local
+// system-upgrade Plans with the versions actually running in the cluster and
+// with environment overrides. Renovate bumps the Plans, the controllers
+// upgrade the cluster, and a checkout that has not been pulled keeps building
+// images for the old release; this check makes that disagreement loud.
+package versioncheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/config"
+	"homeops-cli/internal/constants"
+)
+
+// Component names a versioned piece of the cluster.
+type Component string
+
+const (
+	Kubernetes Component = config.VersionComponentKubernetes
+	Flatcar    Component = config.VersionComponentFlatcar
+	Talos      Component = "talos"
+)
+
+// Comparison sides reported in Finding.Newer.
+const (
+	SidePlan     = "plan"
+	SideCluster  = "cluster"
+	SideOverride = "override"
+	SideUnknown  = "unknown"
+)
+
+// probeTimeout bounds the cluster queries so an unreachable API server does
+// not stall the command that triggered the check.
+const probeTimeout = 10 * time.Second
+
+// Observation is everything known about one component's version.
+type Observation struct {
+	Component   Component `json:"component"`
+	Path        string    `json:"path,omitempty"` // plan file the version came from; "" for built-in pins
+	Plan        string    `json:"plan"`
+	Running     string    `json:"cluster,omitempty"` // newest version reported by the cluster; "" when unknown
+	OverrideEnv string    `json:"override_env"`
+	Override    string    `json:"override,omitempty"`
+}
+
+// Finding is one disagreement between the plan version and another source.
+type Finding struct {
+	Component Component `json:"component"`
+	Path      string    `json:"path,omitempty"`
+	Plan      string    `json:"plan"`
+	Against   string    `json:"against"` // SideCluster or SideOverride
+	Other     string    `json:"other"`
+	Newer     string    `json:"newer"` // SidePlan, Against, or SideUnknown
+}
+
+// Message renders the finding as a single warning line.
+func (f Finding) Message() string {
+	source := f.Path
+	if source == "" {
+		source = "built-in pin"
+	}
+	other := "cluster runs " + f.Other
+	if f.Against == SideOverride {
+		other = "environment override is " + f.Other
+	}
+	var verdict string
+	switch f.Newer {
+	case SidePlan:
+		verdict = "the plan is newer"
+	case SideCluster:
+		verdict = "the cluster is newer; pull the latest plan changes before provisioning"
+	case SideOverride:
+		verdict = "the override is newer"
+	default:
+		verdict = "versions are not comparable"
+	}
+	return fmt.Sprintf("%s version mismatch: plan %s (%s) but %s — %s", f.Component, f.Plan, source, other, verdict)
+}
+
+// Compare returns a finding for every observation whose plan version
+// disagrees with the running cluster or an environment override. Unknown
+// values (empty, or the unpinned Flatcar "current") never produce findings.
+func Compare(observations []Observation) []Finding {
+	var findings []Finding
+	for _, obs := range observations {
+		if !comparable(obs.Plan) {
+			continue
+		}
+		if comparable(obs.Running) && !sameVersion(obs.Plan, obs.Running) {
+			findings = append(findings, newFinding(obs, SideCluster, obs.Running))
+		}
+		if comparable(obs.Override) && !sameVersion(obs.Plan, obs.Override) {
+			findings = append(findings, newFinding(obs, SideOverride, obs.Override))
+		}
+	}
+	return findings
+}
+
+func newFinding(obs Observation, against, other string) Finding {
+	finding := Finding{Component: obs.Component, Path: obs.Path, Plan: obs.Plan, Against: against, Other: other, Newer: SideUnknown}
+	if cmp, ok := CompareVersions(obs.Plan, other); ok {
+		if cmp > 0 {
+			finding.Newer = SidePlan
+		} else {
+			finding.Newer = against
+		}
+	}
+	return finding
+}
+
+func comparable(version string) bool {
+	version = strings.TrimSpace(version)
+	return version != "" && version != "current"
+}
+
+func sameVersion(a, b string) bool {
+	if cmp, ok := CompareVersions(a, b); ok {
+		return cmp == 0
+	}
+	return strings.TrimSpace(a) == strings.TrimSpace(b)
+}
+
+var versionNumberRe = regexp.MustCompile(`^v?(\d+(?:\.\d+)*)(?:[-+].*)?$`)
+
+// CompareVersions compares dotted numeric versions with an optional "v"
+// prefix and pre-release/build suffix (ignored). It returns -1, 0, or 1 and
+// false when either side does not parse.
+func CompareVersions(a, b string) (int, bool) {
+	pa, okA := parseVersion(a)
+	pb, okB := parseVersion(b)
+	if !okA || !okB {
+		return 0, false
+	}
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	return 0, true
+}
+
+func parseVersion(version string) ([]int, bool) {
+	match := versionNumberRe.FindStringSubmatch(strings.TrimSpace(version))
+	if match == nil {
+		return nil, false
+	}
+	parts := strings.Split(match[1], ".")
+	out := make([]int, 0, len(parts))
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, false
+		}
+		out = append(out, n)
+	}
+	return out, true
+}
+
+var strict bool
+
+// SetStrict makes Check return an error instead of only warning. Wired to
+// the global --strict-versions flag.
+func SetStrict(v bool) {
+	strict = v
+}
+
+var (
+	getVersionsFn = config.GetVersions
+	kubectlFn     = func(ctx context.Context, args ...string) ([]byte, error) {
+		return common.RunCommandWithContextOutput(ctx, "kubectl", args...)
+	}
+	lookupEnvFn = os.LookupEnv
+)
+
+// overrideEnv maps each component to the environment variable that
+// overrides its version for commands that honor one.
+var overrideEnv = map[Component]string{
+	Kubernetes: constants.EnvKubernetesVersion,
+	Flatcar:    constants.EnvFlatcarVersion,
+	Talos:      constants.EnvTalosVersion,
+}
+
+// Observe resolves the plan, running, and override versions of components.
+// Cluster probes are best-effort: an unreachable cluster leaves Running empty.
+func Observe(ctx context.Context, rootDir string, components ...Component) []Observation {
+	versions := getVersionsFn(rootDir)
+	running := probeClusterVersions(ctx, components)
+
+	observations := make([]Observation, 0, len(components))
+	for _, component := range components {
+		obs := Observation{Component: component, Running: running[component], OverrideEnv: overrideEnv[component]}
+		if source, ok := versions.SourceFor(string(component)); ok {
+			obs.Path, obs.Plan = source.Path, source.Version
+		} else if component == Talos {
+			obs.Plan = versions.TalosVersion
+		}
+		if value, ok := lookupEnvFn(obs.OverrideEnv); ok {
+			obs.Override = strings.TrimSpace(value)
+		}
+		observations = append(observations, obs)
+	}
+	return observations
+}
+
+// Check warns about every version disagreement for the given components and,
+// when strict mode is enabled, fails if any were found.
+func Check(ctx context.Context, rootDir string, components ...Component) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	findings := Compare(Observe(ctx, rootDir, components...))
+	if len(findings) == 0 {
+		return nil
+	}
+	logger := common.NewColorLogger()
+	for _, finding := range findings {
+		logger.Warn("%s", finding.Message())
+	}
+	if strict {
+		return fmt.Errorf("%d version mismatch(es) between the local plans and the cluster/environment (--strict-versions)", len(findings))
+	}
+	logger.Warn("Continuing anyway; pass --strict-versions to fail on version mismatches")
+	return nil
+}
+
+type nodeList struct {
+	Items []struct {
+		Status struct {
+			NodeInfo struct {
+				OSImage string `json:"osImage"`
+			} `json:"nodeInfo"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+var (
+	flatcarOSImageRe = regexp.MustCompile(`Flatcar Container Linux.*?(\d+\.\d+\.\d+)`)
+	talosOSImageRe   = regexp.MustCompile(`Talos \((v\d+\.\d+\.\d+[^)]*)\)`)
+)
+
+func probeClusterVersions(ctx context.Context, components []Component) map[Component]string {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	logger := common.NewColorLogger()
+	running := map[Component]string{}
+
+	wantOS := false
+	for _, component := range components {
+		switch component {
+		case Kubernetes:
+			version, err := kubernetesServerVersion(ctx)
+			if err != nil {
+				logger.Debug("Skipping cluster Kubernetes version check: %v", err)
+				continue
+			}
+			running[Kubernetes] = version
+		case Flatcar, Talos:
+			wantOS = true
+		}
+	}
+	if !wantOS {
+		return running
+	}
+
+	raw, err := kubectlFn(ctx, "get", "nodes", "-o", "json")
+	if err != nil {
+		logger.Debug("Skipping cluster OS version check: %v", err)
+		return running
+	}
+	var nodes nodeList
+	if err := json.Unmarshal(raw, &nodes); err != nil {
+		logger.Debug("Skipping cluster OS version check: parse nodes: %v", err)
+		return running
+	}
+	var osImages []string
+	for _, node := range nodes.Items {
+		osImages = append(osImages, node.Status.NodeInfo.OSImage)
+	}
+	if version := newestMatch(osImages, flatcarOSImageRe); version != "" {
+		running[Flatcar] = version
+	}
+	if version := newestMatch(osImages, talosOSImageRe); version != "" {
+		running[Talos] = version
+	}
+	return running
+}
+
+func kubernetesServerVersion(ctx context.Context) (string, error) {
+	raw, err := kubectlFn(ctx, "version", "-o", "json")
+	if err != nil {
+		return "", err
+	}
+	var out struct {
+		ServerVersion *struct {
+			GitVersion string `json:"gitVersion"`
+		} `json:"serverVersion"`
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return "", fmt.Errorf("parse kubectl version: %w", err)
+	}
+	if out.ServerVersion == nil || out.ServerVersion.GitVersion == "" {
+		return "", fmt.Errorf("kubectl version reported no server version")
+	}
+	return out.ServerVersion.GitVersion, nil
+}
+
+// newestMatch returns the newest version captured from values. During a
+// rolling OS upgrade the newest node is what the plan on disk should match.
+func newestMatch(values []string, re *regexp.Regexp) string {
+	var versions []string
+	for _, value := range values {
+		if match := re.FindStringSubmatch(value); match != nil {
+			versions = append(versions, match[1])
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		cmp, ok := CompareVersions(versions[i], versions[j])
+		return ok && cmp > 0
+	})
+	if len(versions) == 0 {
+		return ""
+	}
+	return versions[0]
+}
@@ -0,0 +1,186 @@
+package versioncheck
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"homeops-cli/internal/config"
+	"homeops-cli/internal/testutil"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+		ok   bool
+	}{
+		{"v1.36.2", "v1.36.2", 0, true},
+		{"v1.36.2", "v1.36.10", -1, true},
+		{"v1.37.0", "v1.36.9", 1, true},
+		{"1.36.2", "v1.36.2", 0, true},
+		{"v1.36.2-rc.1", "v1.36.2", 0, true},
+		{"4593.2.2", "4593.1.9", 1, true},
+		{"4593.2", "4593.2.0", 0, true},
+		{"current", "4593.2.2", 0, false},
+		{"", "v1.0.0", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"_vs_"+tt.b, func(t *testing.T) {
+			got, ok := CompareVersions(tt.a, tt.b)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name string
+		obs  Observation
+		want []Finding
+	}{
+		{
+			name: "all agree",
+			obs:  Observation{Component: Kubernetes, Plan: "v1.36.2", Running: "v1.36.2", Override: "v1.36.2"},
+		},
+		{
+			name: "cluster newer than stale checkout",
+			obs:  Observation{Component: Kubernetes, Path: "plan.yaml", Plan: "v1.36.1", Running: "v1.36.2"},
+			want: []Finding{{Component: Kubernetes, Path: "plan.yaml", Plan: "v1.36.1", Against: SideCluster, Other: "v1.36.2", Newer: SideCluster}},
+		},
+		{
+			name: "plan bumped before rollout",
+			obs:  Observation{Component: Flatcar, Plan: "4593.2.2", Running: "4593.1.0"},
+			want: []Finding{{Component: Flatcar, Plan: "4593.2.2", Against: SideCluster, Other: "4593.1.0", Newer: SidePlan}},
+		},
+		{
+			name: "override disagrees with plan",
+			obs:  Observation{Component: Talos, Plan: "v1.13.6", Override: "v1.12.0", OverrideEnv: "TALOS_VERSION"},
+			want: []Finding{{Component: Talos, Plan: "v1.13.6", Against: SideOverride, Other: "v1.12.0", Newer: SidePlan}},
+		},
+		{
+			name: "cluster and override both disagree",
+			obs:  Observation{Component: Kubernetes, Plan: "v1.36.1", Running: "v1.36.2", Override: "v1.37.0"},
+			want: []Finding{
+				{Component: Kubernetes, Plan: "v1.36.1", Against: SideCluster, Other: "v1.36.2", Newer: SideCluster},
+				{Component: Kubernetes, Plan: "v1.36.1", Against: SideOverride, Other: "v1.37.0", Newer: SideOverride},
+			},
+		},
+		{
+			name: "unknown running version is not a finding",
+			obs:  Observation{Component: Kubernetes, Plan: "v1.36.2"},
+		},
+		{
+			name: "unpinned flatcar plan is not compared",
+			obs:  Observation{Component: Flatcar, Plan: "current", Running: "4593.2.2"},
+		},
+		{
+			name: "unparseable values compare as strings",
+			obs:  Observation{Component: Talos, Plan: "v1.13.6", Running: "custom-build"},
+			want: []Finding{{Component: Talos, Plan: "v1.13.6", Against: SideCluster, Other: "custom-build", Newer: SideUnknown}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Compare([]Observation{tt.obs}))
+		})
+	}
+}
+
+func TestFindingMessageNamesNewerSide(t *testing.T) {
+	msg := Finding{Component: Kubernetes, Path: "/repo/plan.yaml", Plan: "v1.36.1", Against: SideCluster, Other: "v1.36.2", Newer: SideCluster}.Message()
+	assert.Contains(t, msg, "plan v1.36.1 (/repo/plan.yaml)")
+	assert.Contains(t, msg, "cluster runs v1.36.2")
+	assert.Contains(t, msg, "the cluster is newer")
+
+	msg = Finding{Component: Talos, Plan: "v1.13.6", Against: SideOverride, Other: "v1.12.0", Newer: SidePlan}.Message()
+	assert.Contains(t, msg, "built-in pin")
+	assert.Contains(t, msg, "environment override is v1.12.0")
+	assert.Contains(t, msg, "the plan is newer")
+}
+
+func TestNewestMatch(t *testing.T) {
+	images := []string{
+		"Flatcar Container Linux by Kinvolk 4593.1.0 (Oklo)",
+		"Flatcar Container Linux by Kinvolk 4593.2.2 (Oklo)",
+		"Talos (v1.13.6)",
+	}
+	assert.Equal(t, "4593.2.2", newestMatch(images, flatcarOSImageRe))
+	assert.Equal(t, "v1.13.6", newestMatch(images, talosOSImageRe))
+	assert.Empty(t, newestMatch([]string{"Ubuntu 24.04"}, flatcarOSImageRe))
+}
+
+func stubVersionSources(t *testing.T, kubectl func(args ...string) ([]byte, error), env map[string]string) {
+	t.Helper()
+	testutil.Swap(t, &getVersionsFn, func(string) *config.VersionConfig {
+		return &config.VersionConfig{
+			KubernetesVersion: "v1.36.1",
+			FlatcarVersion:    "4593.2.2",
+			TalosVersion:      "v1.13.6",
+			Sources: []config.VersionSource{
+				{Component: config.VersionComponentKubernetes, Path: "/repo/kubeadm/plan.yaml", Version: "v1.36.1"},
+				{Component: config.VersionComponentFlatcar, Path: "/repo/flatcar/plan.yaml", Version: "4593.2.2"},
+			},
+		}
+	})
+	testutil.Swap(t, &kubectlFn, func(_ context.Context, args ...string) ([]byte, error) { return kubectl(args...) })
+	testutil.Swap(t, &lookupEnvFn, func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	})
+}
+
+func TestObserveReadsPlanClusterAndOverride(t *testing.T) {
+	stubVersionSources(t, func(args ...string) ([]byte, error) {
+		if args[0] == "version" {
+			return []byte(`{"clientVersion":{"gitVersion":"v1.36.0"},"serverVersion":{"gitVersion":"v1.36.2"}}`), nil
+		}
+		return []byte(`{"items":[{"status":{"nodeInfo":{"osImage":"Flatcar Container Linux by Kinvolk 4593.2.2 (Oklo)"}}}]}`), nil
+	}, map[string]string{"FLATCAR_VERSION": "4459.0.0"})
+
+	observations := Observe(context.Background(), ".", Kubernetes, Flatcar)
+	require.Len(t, observations, 2)
+	assert.Equal(t, Observation{Component: Kubernetes, Path: "/repo/kubeadm/plan.yaml", Plan: "v1.36.1", Running: "v1.36.2", OverrideEnv: "KUBERNETES_VERSION"}, observations[0])
+	assert.Equal(t, Observation{Component: Flatcar, Path: "/repo/flatcar/plan.yaml", Plan: "4593.2.2", Running: "4593.2.2", OverrideEnv: "FLATCAR_VERSION", Override: "4459.0.0"}, observations[1])
+
+	findings := Compare(observations)
+	require.Len(t, findings, 2)
+	assert.Equal(t, SideCluster, findings[0].Newer)
+	assert.Equal(t, SidePlan, findings[1].Newer)
+}
+
+func TestObserveUsesBuiltInTalosPin(t *testing.T) {
+	stubVersionSources(t, func(args ...string) ([]byte, error) {
+		return []byte(`{"items":[{"status":{"nodeInfo":{"osImage":"Talos (v1.13.5)"}}}]}`), nil
+	}, nil)
+
+	observations := Observe(context.Background(), ".", Talos)
+	require.Len(t, observations, 1)
+	assert.Equal(t, "v1.13.6", observations[0].Plan)
+	assert.Empty(t, observations[0].Path)
+	assert.Equal(t, "v1.13.5", observations[0].Running)
+}
+
+func TestCheckUnreachableClusterIsSilent(t *testing.T) {
+	stubVersionSources(t, func(...string) ([]byte, error) { return nil, errors.New("connection refused") }, nil)
+	testutil.Swap(t, &strict, true)
+
+	require.NoError(t, Check(context.Background(), ".", Kubernetes, Flatcar))
+}
+
+func TestCheckStrictFailsOnMismatch(t *testing.T) {
+	stubVersionSources(t, func(args ...string) ([]byte, error) {
+		return []byte(`{"serverVersion":{"gitVersion":"v1.36.2"}}`), nil
+	}, nil)
+
+	testutil.Swap(t, &strict, false)
+	require.NoError(t, Check(context.Background(), ".", Kubernetes), "non-strict mode only warns")
+
+	strict = true
+	err := Check(context.Background(), ".", Kubernetes)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--strict-versions")
+}
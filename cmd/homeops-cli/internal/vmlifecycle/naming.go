@@ -0,0 +1,247 @@
+package vmlifecycle
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	versionconfig "homeops-cli/internal/config"
+)
+
+// providerNamingRule is a constraint the hypervisor itself enforces. A
+// homeops.yaml naming policy can tighten it but never loosen it.
+type providerNamingRule struct {
+	pattern   *regexp.Regexp
+	rule      string // human-readable statement of pattern
+	maxLength int    // 0 = no provider limit
+	separator string // preferred word separator for suggestions
+}
+
+var providerNamingRules = map[string]providerNamingRule{
+	// The TrueNAS middleware rejects anything but alphanumerics and
+	// underscores, and the name is reused verbatim for the VM's ZVols.
+	"truenas": {
+		pattern:   regexp.MustCompile(`^[A-Za-z0-9_]+$`),
+		rule:      "cannot contain dashes (-), dots, spaces, or other punctuation; TrueNAS allows only letters, digits, and underscores",
+		separator: "_",
+	},
+	// Proxmox validates the name as a DNS name (it doubles as the guest
+	// hostname for cloud-init).
+	"proxmox": {
+		pattern:   regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*$`),
+		rule:      "must be a DNS name; Proxmox allows letters, digits, and dashes, with dots between labels and no leading or trailing dash",
+		maxLength: 63,
+		separator: "-",
+	},
+	// vSphere escapes %, / and \ in inventory names and caps them at 80
+	// characters; spaces break datastore paths and govc invocations.
+	"vsphere": {
+		pattern:   regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`),
+		rule:      "must start with a letter or digit and contain only letters, digits, dots, dashes, and underscores",
+		maxLength: 80,
+		separator: "-",
+	},
+}
+
+// VMNamePolicy is the effective naming policy for one provider: the
+// provider's built-in rule combined with hypervisors.<provider>.naming.
+type VMNamePolicy struct {
+	Provider  string
+	Pattern   *regexp.Regexp // configured pattern; nil when unset
+	Prefix    string
+	MaxLength int // tighter of the provider limit and the configured limit; 0 = none
+
+	builtin providerNamingRule
+}
+
+// VMNameError reports every rule a VM name breaks and, when one can be
+// derived, a compliant alternative.
+type VMNameError struct {
+	Provider   string
+	Name       string
+	Violations []string
+	Suggestion string
+}
+
+func (e *VMNameError) Error() string {
+	msg := fmt.Sprintf("VM name '%s' violates the %s naming policy: %s", e.Name, e.Provider, strings.Join(e.Violations, "; "))
+	if e.Suggestion != "" {
+		return msg + fmt.Sprintf(" (suggested: '%s')", e.Suggestion)
+	}
+	return msg + fmt.Sprintf(" (no compliant name could be derived; check hypervisors.%s.naming)", e.Provider)
+}
+
+// VMNamePolicyFor resolves the naming policy for a provider from the built-in
+// rules and the loaded configuration.
+func VMNamePolicyFor(provider string) (VMNamePolicy, error) {
+	normalized, err := NormalizeVMProvider(provider)
+	if err != nil {
+		return VMNamePolicy{}, err
+	}
+	var configured versionconfig.NamingPolicy
+	hypervisors := versionconfig.Get().Hypervisors
+	switch normalized {
+	case "proxmox":
+		configured = hypervisors.Proxmox.Naming
+	case "truenas":
+		configured = hypervisors.TrueNAS.Naming
+	case "vsphere":
+		configured = hypervisors.VSphere.Naming
+	}
+	return newVMNamePolicy(normalized, configured)
+}
+
+func newVMNamePolicy(provider string, configured versionconfig.NamingPolicy) (VMNamePolicy, error) {
+	builtin := providerNamingRules[provider]
+	policy := VMNamePolicy{
+		Provider:  provider,
+		Prefix:    configured.Prefix,
+		MaxLength: builtin.maxLength,
+		builtin:   builtin,
+	}
+	if configured.MaxLength > 0 && (policy.MaxLength == 0 || configured.MaxLength < policy.MaxLength) {
+		policy.MaxLength = configured.MaxLength
+	}
+	if configured.Pattern != "" {
+		pattern, err := regexp.Compile(configured.Pattern)
+		if err != nil {
+			return VMNamePolicy{}, fmt.Errorf("hypervisors.%s.naming.pattern: %w", provider, err)
+		}
+		policy.Pattern = pattern
+	}
+	return policy, nil
+}
+
+// Violations lists every rule name breaks, in a stable order.
+func (p VMNamePolicy) Violations(name string) []string {
+	var violations []string
+	if p.builtin.pattern != nil && !p.builtin.pattern.MatchString(name) {
+		violations = append(violations, p.builtin.rule)
+	}
+	if p.MaxLength > 0 && len(name) > p.MaxLength {
+		violations = append(violations, fmt.Sprintf("must be at most %d characters (got %d)", p.MaxLength, len(name)))
+	}
+	if p.Prefix != "" && !strings.HasPrefix(name, p.Prefix) {
+		violations = append(violations, fmt.Sprintf("must start with %q (hypervisors.%s.naming.prefix)", p.Prefix, p.Provider))
+	}
+	if p.Pattern != nil && !p.Pattern.MatchString(name) {
+		violations = append(violations, fmt.Sprintf("must match %q (hypervisors.%s.naming.pattern)", p.Pattern.String(), p.Provider))
+	}
+	return violations
+}
+
+// Validate returns a *VMNameError when name breaks the policy.
+func (p VMNamePolicy) Validate(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("VM name cannot be empty")
+	}
+	violations := p.Violations(name)
+	if len(violations) == 0 {
+		return nil
+	}
+	suggestion, _ := p.Suggest(name)
+	return &VMNameError{Provider: p.Provider, Name: name, Violations: violations, Suggestion: suggestion}
+}
+
+// Suggest derives a compliant name from name by normalizing separators,
+// adding the required prefix, zero-padding a trailing index, and truncating
+// to the length limit. It returns false when no candidate satisfies the
+// policy (e.g. a configured pattern the transformations cannot reach).
+func (p VMNamePolicy) Suggest(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	separators := []string{p.builtin.separator, "-", "_", ""}
+	seen := map[string]bool{}
+	for _, sep := range separators {
+		if seen[sep] {
+			continue
+		}
+		seen[sep] = true
+		base := p.fit(replaceNameSeparators(name, sep))
+		for _, candidate := range padTrailingIndex(base) {
+			candidate = p.fit(candidate)
+			if candidate != "" && len(p.Violations(candidate)) == 0 {
+				return candidate, true
+			}
+		}
+	}
+	return "", false
+}
+
+// fit adds the required prefix and truncates to the length limit.
+func (p VMNamePolicy) fit(name string) string {
+	if p.Prefix != "" && !strings.HasPrefix(name, p.Prefix) {
+		name = p.Prefix + name
+	}
+	if p.MaxLength > 0 && len(name) > p.MaxLength {
+		name = strings.TrimRight(name[:p.MaxLength], "-_.")
+	}
+	return name
+}
+
+// replaceNameSeparators collapses every run of characters other than ASCII
+// letters and digits into sep and trims it from both ends.
+func replaceNameSeparators(name, sep string) string {
+	var b strings.Builder
+	pending := false
+	for _, r := range name {
+		if r < 0x80 && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			if pending && b.Len() > 0 {
+				b.WriteString(sep)
+			}
+			pending = false
+			b.WriteRune(r)
+			continue
+		}
+		pending = true
+	}
+	return b.String()
+}
+
+var trailingIndexRe = regexp.MustCompile(`^(.*?)(\d+)$`)
+
+// padTrailingIndex returns name followed by variants whose trailing number is
+// zero-padded to two and three digits, for conventions such as k8s<NN>.
+func padTrailingIndex(name string) []string {
+	candidates := []string{name}
+	match := trailingIndexRe.FindStringSubmatch(name)
+	if match == nil {
+		return candidates
+	}
+	index, err := strconv.Atoi(match[2])
+	if err != nil {
+		return candidates
+	}
+	for _, width := range []int{2, 3} {
+		if padded := fmt.Sprintf("%s%0*d", match[1], width, index); padded != name {
+			candidates = append(candidates, padded)
+		}
+	}
+	return candidates
+}
+
+// ValidateVMNameFor checks name against the naming policy of provider.
+func ValidateVMNameFor(provider, name string) error {
+	policy, err := VMNamePolicyFor(provider)
+	if err != nil {
+		return err
+	}
+	return policy.Validate(name)
+}
+
+// ValidateVMNamesFor checks every name up front, so a multi-node deployment
+// reports all offending names before anything is created.
+func ValidateVMNamesFor(provider string, names []string) error {
+	policy, err := VMNamePolicyFor(provider)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, name := range names {
+		if err := policy.Validate(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,133 @@
+package vmlifecycle
+
+import (
+	"errors"
+	"testing"
+
+	versionconfig "homeops-cli/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVMNamePolicyValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		provider   string
+		configured versionconfig.NamingPolicy
+		vmName     string
+		violations []string // substrings, one per expected violation
+		suggestion string
+	}{
+		{name: "truenas accepts underscores", provider: "truenas", vmName: "k8s_0"},
+		{name: "truenas rejects dashes", provider: "truenas", vmName: "k8s-0", violations: []string{"cannot contain dashes"}, suggestion: "k8s_0"},
+		{name: "truenas collapses punctuation runs", provider: "truenas", vmName: "web.-server 1", violations: []string{"only letters, digits, and underscores"}, suggestion: "web_server_1"},
+		{name: "proxmox accepts dns names", provider: "proxmox", vmName: "k8s-0.lab"},
+		{name: "proxmox rejects underscores", provider: "proxmox", vmName: "dev_vm", violations: []string{"must be a DNS name"}, suggestion: "dev-vm"},
+		{name: "proxmox rejects trailing dash", provider: "proxmox", vmName: "dev-", violations: []string{"must be a DNS name"}, suggestion: "dev"},
+		{name: "proxmox length limit", provider: "proxmox", vmName: "a123456789b123456789c123456789d123456789e123456789f123456789g1234", violations: []string{"at most 63 characters (got 65)"}, suggestion: "a123456789b123456789c123456789d123456789e123456789f123456789g12"},
+		{name: "vsphere accepts dots and underscores", provider: "vsphere", vmName: "k8s_0.lab-a"},
+		{name: "vsphere rejects slashes", provider: "vsphere", vmName: "prod/web", violations: []string{"must start with a letter or digit"}, suggestion: "prod-web"},
+		{name: "vsphere rejects leading dash", provider: "vsphere", vmName: "-web", violations: []string{"must start with a letter or digit"}, suggestion: "web"},
+		{
+			name: "configured prefix", provider: "proxmox", configured: versionconfig.NamingPolicy{Prefix: "k8s"},
+			vmName: "worker-1", violations: []string{`must start with "k8s"`}, suggestion: "k8sworker-1",
+		},
+		{
+			name: "configured pattern zero-pads index", provider: "truenas", configured: versionconfig.NamingPolicy{Prefix: "k8s", Pattern: `^k8s[0-9]{2}$`},
+			vmName: "k8s-1", violations: []string{"cannot contain dashes", `must match "^k8s[0-9]{2}$"`}, suggestion: "k8s01",
+		},
+		{
+			name: "configured limit tightens provider limit", provider: "vsphere", configured: versionconfig.NamingPolicy{MaxLength: 6},
+			vmName: "k8s-node-1", violations: []string{"at most 6 characters (got 10)"}, suggestion: "k8s-no",
+		},
+		{
+			name: "configured limit cannot loosen provider limit", provider: "proxmox", configured: versionconfig.NamingPolicy{MaxLength: 100},
+			vmName: "a123456789b123456789c123456789d123456789e123456789f123456789g1234", violations: []string{"at most 63 characters"}, suggestion: "a123456789b123456789c123456789d123456789e123456789f123456789g12",
+		},
+		{
+			name: "unreachable pattern has no suggestion", provider: "proxmox", configured: versionconfig.NamingPolicy{Pattern: `^node[a-z]$`},
+			vmName: "k8s-0", violations: []string{`must match "^node[a-z]$"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := newVMNamePolicy(tt.provider, tt.configured)
+			require.NoError(t, err)
+
+			err = policy.Validate(tt.vmName)
+			if len(tt.violations) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			var nameErr *VMNameError
+			require.True(t, errors.As(err, &nameErr), "expected *VMNameError, got %v", err)
+			require.Len(t, nameErr.Violations, len(tt.violations), nameErr.Violations)
+			for i, want := range tt.violations {
+				assert.Contains(t, nameErr.Violations[i], want)
+			}
+			assert.Equal(t, tt.suggestion, nameErr.Suggestion)
+			if tt.suggestion != "" {
+				assert.Empty(t, policy.Violations(tt.suggestion), "suggestion must itself comply")
+				assert.Contains(t, err.Error(), "suggested: '"+tt.suggestion+"'")
+			} else {
+				assert.Contains(t, err.Error(), "hypervisors."+tt.provider+".naming")
+			}
+		})
+	}
+}
+
+func TestVMNamePolicyRejectsEmptyName(t *testing.T) {
+	policy, err := newVMNamePolicy("vsphere", versionconfig.NamingPolicy{})
+	require.NoError(t, err)
+	require.EqualError(t, policy.Validate("  "), "VM name cannot be empty")
+}
+
+func TestReplaceNameSeparators(t *testing.T) {
+	tests := []struct {
+		in, sep, want string
+	}{
+		{"k8s-0", "_", "k8s_0"},
+		{"--a..b__c--", "-", "a-b-c"},
+		{"web server", "", "webserver"},
+		{"nöde1", "-", "n-de1"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, replaceNameSeparators(tt.in, tt.sep), tt.in)
+	}
+}
+
+func TestVMNamePolicyForReadsConfig(t *testing.T) {
+	defer versionconfig.SetForTesting(&versionconfig.Config{
+		Hypervisors: versionconfig.HypervisorsConfig{
+			TrueNAS: versionconfig.TrueNASConfig{Naming: versionconfig.NamingPolicy{Prefix: "k8s", MaxLength: 8}},
+		},
+	})()
+
+	policy, err := VMNamePolicyFor("truenas")
+	require.NoError(t, err)
+	assert.Equal(t, "k8s", policy.Prefix)
+	assert.Equal(t, 8, policy.MaxLength)
+
+	policy, err = VMNamePolicyFor("esxi")
+	require.NoError(t, err)
+	assert.Equal(t, "vsphere", policy.Provider)
+	assert.Equal(t, 80, policy.MaxLength)
+}
+
+func TestValidateVMNamesForReportsEveryName(t *testing.T) {
+	defer versionconfig.SetForTesting(&versionconfig.Config{
+		Hypervisors: versionconfig.HypervisorsConfig{
+			Proxmox: versionconfig.ProxmoxConfig{Naming: versionconfig.NamingPolicy{Pattern: `^k8s-[0-2]$`}},
+		},
+	})()
+
+	err := ValidateVMNamesFor("proxmox", []string{"k8s-1", "k8s-2", "k8s-3", "k8s-4"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "'k8s-3'")
+	assert.Contains(t, err.Error(), "'k8s-4'")
+	assert.NotContains(t, err.Error(), "'k8s-2'")
+
+	require.NoError(t, ValidateVMNamesFor("proxmox", []string{"k8s-0", "k8s-1", "k8s-2"}))
+}
@@ -0,0 +1,151 @@
+package vmlifecycle
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"homeops-cli/internal/common"
+)
+
+// TrueNASKeepalive is how often a shared TrueNAS connection is pinged while
+// no command is using it.
+const TrueNASKeepalive = 30 * time.Second
+
+// sharedTrueNAS is the one TrueNAS connection an interactive session reuses
+// across commands; see ShareTrueNASConnection. users counts the acquires
+// not yet released, so the keepalive never writes to the websocket while a
+// command does.
+var sharedTrueNAS struct {
+	mu      sync.Mutex
+	enabled bool
+	manager TrueNASVMManager
+	users   int
+}
+
+// trueNASPinger is implemented by managers that can check their connection
+// (the real TrueNAS manager).
+type trueNASPinger interface {
+	Ping() error
+}
+
+// ShareTrueNASConnection makes every TrueNAS manager this process acquires
+// the same one: dialed and authenticated on first use, pinged every
+// keepalive while idle (and redialed on next use if a ping fails), and
+// closed when ctx is done or the returned stop is called. The interactive
+// menu calls it once, so moving between list, info and start does not log
+// in again each time.
+func ShareTrueNASConnection(ctx context.Context, keepalive time.Duration) (stop func()) {
+	sharedTrueNAS.mu.Lock()
+	sharedTrueNAS.enabled = true
+	sharedTrueNAS.mu.Unlock()
+
+	done := make(chan struct{})
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			close(done)
+			sharedTrueNAS.mu.Lock()
+			defer sharedTrueNAS.mu.Unlock()
+			sharedTrueNAS.enabled = false
+			dropSharedTrueNASManager()
+		})
+	}
+	go func() {
+		ticker := time.NewTicker(keepalive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				stop()
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				pingSharedTrueNASManager()
+			}
+		}
+	}()
+	return stop
+}
+
+// pingSharedTrueNASManager keeps the idle shared connection alive, dropping
+// it when the ping fails. A connection a command is using is not idle.
+func pingSharedTrueNASManager() {
+	sharedTrueNAS.mu.Lock()
+	defer sharedTrueNAS.mu.Unlock()
+	pinger, ok := sharedTrueNAS.manager.(trueNASPinger)
+	if !ok || sharedTrueNAS.users > 0 {
+		return
+	}
+	if err := pinger.Ping(); err != nil {
+		common.NewColorLogger().Debug("Shared TrueNAS connection lost, redialing on next use: %v", err)
+		dropSharedTrueNASManager()
+	}
+}
+
+// dropSharedTrueNASManager closes the shared manager; sharedTrueNAS.mu must
+// be held.
+func dropSharedTrueNASManager() {
+	if sharedTrueNAS.manager == nil {
+		return
+	}
+	if err := sharedTrueNAS.manager.Close(); err != nil {
+		common.NewColorLogger().Warn("Failed to close VM manager: %v", err)
+	}
+	sharedTrueNAS.manager = nil
+}
+
+// acquireTrueNASVMManager returns a connected TrueNAS manager and the
+// release that ends its use: a fresh connection that release closes, or
+// while ShareTrueNASConnection is in effect the shared one, which release
+// leaves open.
+func acquireTrueNASVMManager() (TrueNASVMManager, func() error, error) {
+	if vmManager, release, ok, err := acquireSharedTrueNASVMManager(); ok {
+		return vmManager, release, err
+	}
+	vmManager, err := connectTrueNASVMManager()
+	if err != nil {
+		return nil, nil, err
+	}
+	return vmManager, vmManager.Close, nil
+}
+
+// acquireSharedTrueNASVMManager is acquireTrueNASVMManager for a shared
+// connection, dialing it on first use; ok is false when none is shared.
+func acquireSharedTrueNASVMManager() (vmManager TrueNASVMManager, release func() error, ok bool, err error) {
+	sharedTrueNAS.mu.Lock()
+	defer sharedTrueNAS.mu.Unlock()
+	if !sharedTrueNAS.enabled {
+		return nil, nil, false, nil
+	}
+	if sharedTrueNAS.manager == nil {
+		if sharedTrueNAS.manager, err = connectTrueNASVMManager(); err != nil {
+			return nil, nil, true, err
+		}
+	}
+	sharedTrueNAS.users++
+	var once sync.Once
+	release = func() error {
+		once.Do(func() {
+			sharedTrueNAS.mu.Lock()
+			sharedTrueNAS.users--
+			sharedTrueNAS.mu.Unlock()
+		})
+		return nil
+	}
+	return sharedTrueNAS.manager, release, true, nil
+}
+
+func connectTrueNASVMManager() (TrueNASVMManager, error) {
+	host, apiKey, err := GetTrueNASCredentialsFn()
+	if err != nil {
+		return nil, err
+	}
+	vmManager := NewTrueNASVMManagerFn(host, apiKey, 443, true)
+	if err := vmManager.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to TrueNAS: %w", err)
+	}
+	return vmManager, nil
+}
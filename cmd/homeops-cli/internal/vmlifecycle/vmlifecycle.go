@@ -7,9 +7,11 @@
 package vmlifecycle
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"homeops-cli/internal/common"
 	versionconfig "homeops-cli/internal/config"
@@ -58,11 +60,15 @@ type TrueNASVMManager interface {
 	Connect() error
 	Close() error
 	DeployVM(truenas.VMConfig) error
+	VerifyVM(truenas.VMConfig) ([]truenas.VMDrift, error)
 	ListVMs() error
 	VMSummaries() ([]vmprov.VMSummary, error)
 	StartVM(string) error
 	StopVM(string, bool) error
 	RestartVM(string) error
+	PowerCycleVM(string) error
+	SuspendVM(string) error
+	ResumeVM(string) error
 	DeleteVM(string, bool, string) error
 	GetVMInfo(string) error
 	SetVMResources(string, int, int) error
@@ -76,11 +82,25 @@ type TrueNASVMManager interface {
 	ConsoleURL(string) (string, error)
 	Capabilities() vmprov.Capabilities
 	CleanupOrphanedZVols(string, string) error
+	MiddlewareVersion() (truenas.MiddlewareVersion, error)
+	SerialLogPath(string) (string, error)
+	ConfigISOPath(string) (string, error)
+	VMZVols(string) ([]string, error)
+	ConsoleInfo(string) (*truenas.ConsoleInfo, error)
+	SetVMCPUPlacement(string, truenas.CPUPlacementUpdate) error
+	CPUModelChoices() ([]string, error)
+	HostTopology(truenas.SSHRunner) (truenas.HostTopology, error)
+	ApplySnapshotPolicy(string, truenas.SnapshotPolicy) error
+	SnapshotPolicies(string) ([]truenas.VMSnapshotTask, error)
+	PlanSnapshotPrune(string) (truenas.SnapshotPrunePlan, error)
+	PruneVMSnapshots(truenas.SnapshotPrunePlan) error
+	VMUsage(truenas.SSHRunner, time.Time, time.Duration) (truenas.VMUsageReport, error)
 }
 
 type ProxmoxVMManager interface {
 	vmprov.VMLifecycle
 	UploadISOFromURL(string, string, string) error
+	ISOSize(string, string) (int64, error)
 	DeployVM(proxmox.VMConfig) error
 	ImportTemplate(proxmox.VMConfig) error
 	ConvertVMToTemplate(string) error
@@ -94,6 +114,7 @@ type VSphereClient interface {
 	ListVMs() ([]*object.VirtualMachine, error)
 	GetVMInfo(*object.VirtualMachine) (*mo.VirtualMachine, error)
 	UploadISOToDatastore(string, string, string) error
+	DatastoreFileSize(string, string) (int64, error)
 	PowerOnVM(*object.VirtualMachine) error
 	PowerOffVM(*object.VirtualMachine) error
 	DeleteVM(*object.VirtualMachine) error
@@ -166,18 +187,83 @@ func GetVSphereCredentials() (host, username, password string, err error) {
 	return host, username, password, nil
 }
 
+// contextBinder is implemented by managers whose in-flight API calls can be
+// cancelled through a bound context (the real TrueNAS manager).
+type contextBinder interface {
+	SetContext(context.Context)
+}
+
+// BindContext bounds manager's API calls by ctx when it supports that; other
+// managers (and test doubles) are left unchanged.
+func BindContext(ctx context.Context, manager interface{}) {
+	if binder, ok := manager.(contextBinder); ok && ctx != nil {
+		binder.SetContext(ctx)
+	}
+}
+
+// stopTimeoutSetter is implemented by managers that wait for a clean shutdown
+// before forcing a VM off (the real TrueNAS manager).
+type stopTimeoutSetter interface {
+	SetStopTimeout(time.Duration)
+}
+
+// BindStopTimeout sets how long manager's StopVM and DeleteVM wait for a
+// guest to shut down when it supports that (vm stop/delete --stop-timeout);
+// other managers are left unchanged.
+func BindStopTimeout(timeout time.Duration, manager interface{}) {
+	if setter, ok := manager.(stopTimeoutSetter); ok && timeout > 0 {
+		setter.SetStopTimeout(timeout)
+	}
+}
+
+// zvolKeeper is implemented by lifecycles whose DeleteVM removes the VM's
+// disks with it (TrueNAS).
+type zvolKeeper interface {
+	KeepZVols()
+}
+
+// BindKeepZVols makes lifecycle's DeleteVM leave the VM's disks in place
+// (vm delete --keep-zvol); other lifecycles are left unchanged.
+func BindKeepZVols(keep bool, lifecycle interface{}) {
+	if keeper, ok := lifecycle.(zvolKeeper); ok && keep {
+		keeper.KeepZVols()
+	}
+}
+
+// dryRunSetter is implemented by managers that can walk a deploy or delete
+// without changing anything (the real TrueNAS manager).
+type dryRunSetter interface {
+	SetDryRun(*truenas.DryRunPlan)
+}
+
+// BindDryRun makes manager record its mutating calls in plan instead of
+// making them (deploy-vm and vm delete --dry-run). It reports whether
+// manager supports that; a TrueNAS lifecycle does when its manager does.
+func BindDryRun(plan *truenas.DryRunPlan, manager interface{}) bool {
+	if adapter, ok := manager.(*truenasLifecycleAdapter); ok {
+		manager = adapter.TrueNASVMManager
+	}
+	setter, ok := manager.(dryRunSetter)
+	if ok {
+		setter.SetDryRun(plan)
+	}
+	return ok
+}
+
 func WithTrueNASVMManager(logger *common.ColorLogger, fn func(TrueNASVMManager) error) error {
-	host, apiKey, err := GetTrueNASCredentialsFn()
+	return WithTrueNASVMManagerContext(context.Background(), logger, fn)
+}
+
+// WithTrueNASVMManagerContext is WithTrueNASVMManager with every TrueNAS
+// call bounded by ctx.
+func WithTrueNASVMManagerContext(ctx context.Context, logger *common.ColorLogger, fn func(TrueNASVMManager) error) error {
+	vmManager, release, err := acquireTrueNASVMManager()
 	if err != nil {
 		return err
 	}
-
-	vmManager := NewTrueNASVMManagerFn(host, apiKey, 443, true)
-	if err := vmManager.Connect(); err != nil {
-		return fmt.Errorf("failed to connect to TrueNAS: %w", err)
-	}
+	BindContext(ctx, vmManager)
 	defer func() {
-		if closeErr := vmManager.Close(); closeErr != nil {
+		if closeErr := release(); closeErr != nil {
 			logger.Warn("Failed to close VM manager: %v", closeErr)
 		}
 	}()
@@ -205,10 +291,20 @@ func WithProxmoxVMManager(logger *common.ColorLogger, fn func(ProxmoxVMManager)
 }
 
 func WithVSphereClient(logger *common.ColorLogger, fn func(VSphereClient) error) error {
-	host, username, password, err := GetVSphereCredsFn()
+	return WithVSphereClientOnHost(logger, "", fn)
+}
+
+// WithVSphereClientOnHost is WithVSphereClient against host, with the
+// configured credentials; "" is the configured host. It reaches ESXi hosts
+// that share an account but are not behind one vCenter.
+func WithVSphereClientOnHost(logger *common.ColorLogger, host string, fn func(VSphereClient) error) error {
+	configuredHost, username, password, err := GetVSphereCredsFn()
 	if err != nil {
 		return err
 	}
+	if host == "" {
+		host = configuredHost
+	}
 
 	client := NewVSphereClientFn(host, username, password, common.EnvBool(constants.EnvVSphereInsecure, false))
 	if err := client.Connect(host, username, password, common.EnvBool(constants.EnvVSphereInsecure, false)); err != nil {
@@ -226,18 +322,37 @@ func WithVSphereClient(logger *common.ColorLogger, fn func(VSphereClient) error)
 // truenasLifecycleAdapter narrows the TrueNAS manager to the shared
 // provider.VMLifecycle contract. TrueNAS deletion takes storage options;
 // they are fixed at construction because the CLI runs one lifecycle
-// operation per invocation.
+// operation per invocation, except that BindKeepZVols can turn ZVol
+// deletion off. Close releases the manager, which leaves a shared
+// connection open.
 type truenasLifecycleAdapter struct {
 	TrueNASVMManager
 	deleteZVols bool
 	storagePool string
+	release     func() error
+}
+
+func (a truenasLifecycleAdapter) Close() error {
+	return a.release()
 }
 
 func (a truenasLifecycleAdapter) DeleteVM(name string) error {
 	return a.TrueNASVMManager.DeleteVM(name, a.deleteZVols, a.storagePool)
 }
 
-var _ vmprov.VMLifecycle = truenasLifecycleAdapter{}
+func (a truenasLifecycleAdapter) SetContext(ctx context.Context) {
+	BindContext(ctx, a.TrueNASVMManager)
+}
+
+func (a truenasLifecycleAdapter) SetStopTimeout(timeout time.Duration) {
+	BindStopTimeout(timeout, a.TrueNASVMManager)
+}
+
+func (a *truenasLifecycleAdapter) KeepZVols() {
+	a.deleteZVols = false
+}
+
+var _ vmprov.VMLifecycle = &truenasLifecycleAdapter{}
 
 // newVMLifecycle builds the lifecycle implementation for a normalized
 // provider name. All VM lifecycle dispatch (list/start/stop/info/delete/
@@ -245,18 +360,15 @@ var _ vmprov.VMLifecycle = truenasLifecycleAdapter{}
 func newVMLifecycle(normalizedProvider string) (vmprov.VMLifecycle, error) {
 	switch normalizedProvider {
 	case "truenas":
-		host, apiKey, err := GetTrueNASCredentialsFn()
+		vmManager, release, err := acquireTrueNASVMManager()
 		if err != nil {
 			return nil, err
 		}
-		vmManager := NewTrueNASVMManagerFn(host, apiKey, 443, true)
-		if err := vmManager.Connect(); err != nil {
-			return nil, fmt.Errorf("failed to connect to TrueNAS: %w", err)
-		}
-		return truenasLifecycleAdapter{
+		return &truenasLifecycleAdapter{
 			TrueNASVMManager: vmManager,
 			deleteZVols:      true,
 			storagePool:      GetEnvOrDefault("STORAGE_POOL", versionconfig.Get().TrueNASPool()),
+			release:          release,
 		}, nil
 	case "proxmox":
 		host, tokenID, secret, nodeName, err := GetProxmoxCredentialsFn()
@@ -281,10 +393,17 @@ func newVMLifecycle(normalizedProvider string) (vmprov.VMLifecycle, error) {
 // WithVMLifecycle runs fn against a freshly constructed provider lifecycle
 // and always closes it afterwards.
 func WithVMLifecycle(normalizedProvider string, fn func(vmprov.VMLifecycle) error) error {
+	return WithVMLifecycleContext(context.Background(), normalizedProvider, fn)
+}
+
+// WithVMLifecycleContext is WithVMLifecycle with the lifecycle's API calls
+// bounded by ctx where the provider supports it (TrueNAS).
+func WithVMLifecycleContext(ctx context.Context, normalizedProvider string, fn func(vmprov.VMLifecycle) error) error {
 	lifecycle, err := NewVMLifecycleFn(normalizedProvider)
 	if err != nil {
 		return err
 	}
+	BindContext(ctx, lifecycle)
 	defer func() {
 		if closeErr := lifecycle.Close(); closeErr != nil {
 			common.NewColorLogger().Warn("Failed to close VM manager: %v", closeErr)
@@ -297,6 +416,11 @@ func WithVMLifecycle(normalizedProvider string, fn func(vmprov.VMLifecycle) erro
 // (prompting interactively when not given), then runs op against the
 // provider's lifecycle implementation.
 func RunVMLifecycleAction(name, providerName, action string, op func(vmprov.VMLifecycle, string) error) error {
+	return RunVMLifecycleActionContext(context.Background(), name, providerName, action, op)
+}
+
+// RunVMLifecycleActionContext is RunVMLifecycleAction bounded by ctx.
+func RunVMLifecycleActionContext(ctx context.Context, name, providerName, action string, op func(vmprov.VMLifecycle, string) error) error {
 	normalizedProvider, err := NormalizeVMProvider(providerName)
 	if err != nil {
 		return err
@@ -310,7 +434,7 @@ func RunVMLifecycleAction(name, providerName, action string, op func(vmprov.VMLi
 		return nil
 	}
 
-	return WithVMLifecycle(normalizedProvider, func(lifecycle vmprov.VMLifecycle) error {
+	return WithVMLifecycleContext(ctx, normalizedProvider, func(lifecycle vmprov.VMLifecycle) error {
 		return op(lifecycle, name)
 	})
 }
@@ -444,23 +568,17 @@ func ChooseVMNameForProvider(name, provider, action string) (string, error) {
 
 // getTrueNASVMNames retrieves the list of VM names from TrueNAS
 func getTrueNASVMNames() ([]string, error) {
-	// Get TrueNAS connection details
-	host, apiKey, err := truenas.GetCredentials()
+	// Reuse the shared connection of an interactive session, if any
+	vmManager, release, err := acquireTrueNASVMManager()
 	if err != nil {
 		return nil, err
 	}
-
-	// Create TrueNAS client and connect
-	client := truenas.NewWorkingClient(host, apiKey, 443, true)
-	if err := client.Connect(); err != nil {
-		return nil, fmt.Errorf("failed to connect to TrueNAS: %w", err)
-	}
-	defer func() { _ = client.Close() }()
+	defer func() { _ = release() }()
 
 	// Query VMs
-	vms, err := client.QueryVMs(nil)
+	vms, err := vmManager.VMSummaries()
 	if err != nil {
-		return nil, fmt.Errorf("failed to query VMs: %w", err)
+		return nil, err
 	}
 
 	// Extract VM names
@@ -538,16 +656,10 @@ func DefaultProviderName() string {
 	return "proxmox"
 }
 
-// ValidateVMName checks that a VM name contains no characters that break
-// downstream naming conventions (e.g. ZVol names that forbid dashes).
+// ValidateVMName checks a name against the TrueNAS naming policy, whose
+// middleware rejects dashes in VM and ZVol names.
 func ValidateVMName(name string) error {
-	if strings.Contains(name, "-") {
-		return fmt.Errorf("VM name '%s' cannot contain dashes (-). Use underscores (_) or alphanumeric characters only", name)
-	}
-	if name == "" {
-		return fmt.Errorf("VM name cannot be empty")
-	}
-	return nil
+	return ValidateVMNameFor("truenas", name)
 }
 
 // TrueNASNetworkBridge returns NETWORK_BRIDGE or the configured TrueNAS bridge.
@@ -1,8 +1,10 @@
 package vmlifecycle
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"homeops-cli/internal/common"
 	versionconfig "homeops-cli/internal/config"
@@ -41,6 +43,7 @@ func (f *helperFakeLifecycle) ConsoleURL(string) (string, error)               {
 func (f *helperFakeLifecycle) Capabilities() vmprov.Capabilities               { return vmprov.Capabilities{} }
 func (f *helperFakeLifecycle) Close() error                                    { f.closed++; return nil }
 func (f *helperFakeLifecycle) UploadISOFromURL(string, string, string) error   { return nil }
+func (f *helperFakeLifecycle) ISOSize(string, string) (int64, error)           { return 0, nil }
 func (f *helperFakeLifecycle) DeployVM(proxmox.VMConfig) error                 { return nil }
 func (f *helperFakeLifecycle) ImportTemplate(proxmox.VMConfig) error           { return nil }
 func (f *helperFakeLifecycle) ConvertVMToTemplate(string) error                { return nil }
@@ -49,16 +52,29 @@ func (f *helperFakeLifecycle) ConsoleURLs(string) (string, string, error)      {
 type helperFakeTrueNASManager struct {
 	connects int
 	closed   int
+	ctx      context.Context
+	pings    int
+	pingErr  error
 }
 
-func (f *helperFakeTrueNASManager) Connect() error                                  { f.connects++; return nil }
-func (f *helperFakeTrueNASManager) Close() error                                    { f.closed++; return nil }
-func (f *helperFakeTrueNASManager) DeployVM(truenas.VMConfig) error                 { return nil }
-func (f *helperFakeTrueNASManager) ListVMs() error                                  { return nil }
-func (f *helperFakeTrueNASManager) VMSummaries() ([]vmprov.VMSummary, error)        { return nil, nil }
+func (f *helperFakeTrueNASManager) SetContext(ctx context.Context) { f.ctx = ctx }
+
+func (f *helperFakeTrueNASManager) Connect() error                  { f.connects++; return nil }
+func (f *helperFakeTrueNASManager) Close() error                    { f.closed++; return nil }
+func (f *helperFakeTrueNASManager) DeployVM(truenas.VMConfig) error { return nil }
+func (f *helperFakeTrueNASManager) VerifyVM(truenas.VMConfig) ([]truenas.VMDrift, error) {
+	return nil, nil
+}
+func (f *helperFakeTrueNASManager) ListVMs() error { return nil }
+func (f *helperFakeTrueNASManager) VMSummaries() ([]vmprov.VMSummary, error) {
+	return []vmprov.VMSummary{{Name: "web0"}}, nil
+}
 func (f *helperFakeTrueNASManager) StartVM(string) error                            { return nil }
 func (f *helperFakeTrueNASManager) StopVM(string, bool) error                       { return nil }
 func (f *helperFakeTrueNASManager) RestartVM(string) error                          { return nil }
+func (f *helperFakeTrueNASManager) PowerCycleVM(string) error                       { return nil }
+func (f *helperFakeTrueNASManager) SuspendVM(string) error                          { return nil }
+func (f *helperFakeTrueNASManager) ResumeVM(string) error                           { return nil }
 func (f *helperFakeTrueNASManager) DeleteVM(string, bool, string) error             { return nil }
 func (f *helperFakeTrueNASManager) GetVMInfo(string) error                          { return nil }
 func (f *helperFakeTrueNASManager) SetVMResources(string, int, int) error           { return nil }
@@ -72,6 +88,96 @@ func (f *helperFakeTrueNASManager) VMIPAddresses(string) ([]string, error)
 func (f *helperFakeTrueNASManager) ConsoleURL(string) (string, error)               { return "", nil }
 func (f *helperFakeTrueNASManager) Capabilities() vmprov.Capabilities               { return vmprov.Capabilities{} }
 func (f *helperFakeTrueNASManager) CleanupOrphanedZVols(string, string) error       { return nil }
+func (f *helperFakeTrueNASManager) MiddlewareVersion() (truenas.MiddlewareVersion, error) {
+	return truenas.MiddlewareVersion{}, nil
+}
+func (f *helperFakeTrueNASManager) SerialLogPath(string) (string, error) { return "", nil }
+func (f *helperFakeTrueNASManager) ConfigISOPath(string) (string, error) { return "", nil }
+func (f *helperFakeTrueNASManager) VMZVols(string) ([]string, error)     { return nil, nil }
+func (f *helperFakeTrueNASManager) ConsoleInfo(string) (*truenas.ConsoleInfo, error) {
+	return &truenas.ConsoleInfo{}, nil
+}
+func (f *helperFakeTrueNASManager) SetVMCPUPlacement(string, truenas.CPUPlacementUpdate) error {
+	return nil
+}
+func (f *helperFakeTrueNASManager) CPUModelChoices() ([]string, error) { return nil, nil }
+func (f *helperFakeTrueNASManager) HostTopology(truenas.SSHRunner) (truenas.HostTopology, error) {
+	return truenas.HostTopology{}, nil
+}
+func (f *helperFakeTrueNASManager) ApplySnapshotPolicy(string, truenas.SnapshotPolicy) error {
+	return nil
+}
+func (f *helperFakeTrueNASManager) SnapshotPolicies(string) ([]truenas.VMSnapshotTask, error) {
+	return nil, nil
+}
+func (f *helperFakeTrueNASManager) PlanSnapshotPrune(string) (truenas.SnapshotPrunePlan, error) {
+	return truenas.SnapshotPrunePlan{}, nil
+}
+func (f *helperFakeTrueNASManager) PruneVMSnapshots(truenas.SnapshotPrunePlan) error { return nil }
+func (f *helperFakeTrueNASManager) VMUsage(truenas.SSHRunner, time.Time, time.Duration) (truenas.VMUsageReport, error) {
+	return truenas.VMUsageReport{}, nil
+}
+
+func (f *helperFakeTrueNASManager) Ping() error { f.pings++; return f.pingErr }
+
+func TestSharedTrueNASConnectionLogsInOncePerSession(t *testing.T) {
+	var managers []*helperFakeTrueNASManager
+	testutil.Swap(t, &GetTrueNASCredentialsFn, func() (string, string, error) {
+		return "nas.local", "api-key", nil
+	})
+	testutil.Swap(t, &NewTrueNASVMManagerFn, func(string, string, int, bool) TrueNASVMManager {
+		managers = append(managers, &helperFakeTrueNASManager{})
+		return managers[len(managers)-1]
+	})
+	testutil.Swap(t, &ChooseVMFunc, func(string, []string) (string, error) { return "web0", nil })
+
+	stop := ShareTrueNASConnection(context.Background(), time.Hour)
+	require.NoError(t, WithVMLifecycleContext(context.Background(), "truenas", func(lifecycle vmprov.VMLifecycle) error {
+		return lifecycle.ListVMs()
+	}))
+	for _, action := range []string{"info", "start"} {
+		require.NoError(t, RunVMLifecycleActionContext(context.Background(), "", "truenas", action, func(vmprov.VMLifecycle, string) error { return nil }))
+	}
+	require.Len(t, managers, 1, "list, the VM pickers, info and start share one connection")
+	assert.Equal(t, 1, managers[0].connects)
+	assert.Zero(t, managers[0].closed)
+
+	vmManager, release, err := acquireTrueNASVMManager()
+	require.NoError(t, err)
+	pingSharedTrueNASManager()
+	assert.Zero(t, managers[0].pings, "a connection in use is not pinged")
+	require.NoError(t, release())
+	require.NoError(t, release(), "releasing twice is harmless")
+	assert.Same(t, managers[0], vmManager)
+
+	pingSharedTrueNASManager()
+	assert.Equal(t, 1, managers[0].pings)
+	managers[0].pingErr = errors.New("connection closed")
+	pingSharedTrueNASManager()
+	assert.Equal(t, 1, managers[0].closed, "a dead connection is dropped")
+	require.NoError(t, WithTrueNASVMManagerContext(context.Background(), common.NewColorLogger(), func(TrueNASVMManager) error { return nil }))
+	require.Len(t, managers, 2, "and redialed on next use")
+
+	stop()
+	assert.Equal(t, 1, managers[1].closed, "stopping the session closes the connection")
+	require.NoError(t, WithTrueNASVMManagerContext(context.Background(), common.NewColorLogger(), func(TrueNASVMManager) error { return nil }))
+	require.Len(t, managers, 3)
+	assert.Equal(t, 1, managers[2].closed, "outside a session each use dials and closes its own")
+}
+
+func TestWithVMLifecycleContextBindsTrueNASManager(t *testing.T) {
+	fake := &helperFakeTrueNASManager{}
+	testutil.Swap(t, &GetTrueNASCredentialsFn, func() (string, string, error) {
+		return "nas.local", "api-key", nil
+	})
+	testutil.Swap(t, &NewTrueNASVMManagerFn, func(string, string, int, bool) TrueNASVMManager { return fake })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, WithVMLifecycleContext(ctx, "truenas", func(vmprov.VMLifecycle) error { return nil }))
+	assert.Equal(t, ctx, fake.ctx, "the bound context reaches the manager behind the lifecycle adapter")
+	assert.Equal(t, 1, fake.closed)
+}
 
 type helperFakeVSphereClient struct {
 	connectArgs []interface{}
@@ -89,6 +195,7 @@ func (f *helperFakeVSphereClient) GetVMInfo(*object.VirtualMachine) (*mo.Virtual
 	return nil, nil
 }
 func (f *helperFakeVSphereClient) UploadISOToDatastore(string, string, string) error { return nil }
+func (f *helperFakeVSphereClient) DatastoreFileSize(string, string) (int64, error)   { return 0, nil }
 func (f *helperFakeVSphereClient) PowerOnVM(*object.VirtualMachine) error            { return nil }
 func (f *helperFakeVSphereClient) PowerOffVM(*object.VirtualMachine) error           { return nil }
 func (f *helperFakeVSphereClient) DeleteVM(*object.VirtualMachine) error             { return nil }
@@ -164,6 +271,14 @@ func TestWithVSphereClientConstructsConnectsAndCloses(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, []interface{}{"vc.local", "administrator", "password", true}, fake.connectArgs)
 	assert.Equal(t, 1, fake.closed)
+
+	fake.connectArgs = nil
+	testutil.Swap(t, &NewVSphereClientFn, func(host, username, password string, insecure bool) VSphereClient {
+		assert.Equal(t, "esxi-02.local", host)
+		return fake
+	})
+	require.NoError(t, WithVSphereClientOnHost(common.NewColorLogger(), "esxi-02.local", func(VSphereClient) error { return nil }))
+	assert.Equal(t, []interface{}{"esxi-02.local", "administrator", "password", true}, fake.connectArgs)
 }
 
 func TestWithVMLifecycleConstructsAndClosesProviderLifecycle(t *testing.T) {
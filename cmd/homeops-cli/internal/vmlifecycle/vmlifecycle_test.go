@@ -224,7 +224,8 @@ func TestValidateVMName(t *testing.T) {
 		{
 			name:    "name with spaces",
 			vmName:  "test vm name",
-			wantErr: false, // Spaces are not actually validated in the function
+			wantErr: true,
+			errMsg:  "suggested: 'test_vm_name'",
 		},
 	}
 
@@ -2,10 +2,13 @@ package vsphere
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"net/url"
 	"os"
 	"os/exec"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -20,6 +23,7 @@ import (
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/vim25"
 	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/progress"
 	"github.com/vmware/govmomi/vim25/soap"
 	"github.com/vmware/govmomi/vim25/types"
 )
@@ -37,6 +41,9 @@ type Client struct {
 	ctx        context.Context
 	cancel     context.CancelFunc
 	datacenter *object.Datacenter
+
+	// uploadProgress, when set, receives UploadISOToDatastore's progress.
+	uploadProgress func(percent float32, rate string)
 }
 
 type lifecycleTask interface {
@@ -53,6 +60,7 @@ type vmLifecycle interface {
 
 type datastoreUploader interface {
 	UploadFile(context.Context, string, string, *soap.Upload) error
+	Stat(context.Context, string) (types.BaseFileInfo, error)
 }
 
 var (
@@ -84,8 +92,8 @@ var (
 		return finder.Datastore(ctx, name)
 	}
 	statFileFn            = os.Stat
-	uploadDatastoreFileFn = func(datastore datastoreUploader, ctx context.Context, localFilePath, remoteFileName string) error {
-		return datastore.UploadFile(ctx, localFilePath, remoteFileName, nil)
+	uploadDatastoreFileFn = func(datastore datastoreUploader, ctx context.Context, localFilePath, remoteFileName string, param *soap.Upload) error {
+		return datastore.UploadFile(ctx, localFilePath, remoteFileName, param)
 	}
 	createVMForDeployFn = func(client *Client, config VMConfig) (*object.VirtualMachine, error) {
 		return client.CreateVM(config)
@@ -227,16 +235,16 @@ func (c *Client) CreateVM(config VMConfig) (*object.VirtualMachine, error) {
 		return nil, err
 	}
 
-	vm, datastoreRef, err := c.createVMWithControllers(config, inventory)
+	vm, err := c.createVMWithControllers(config, inventory)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := c.addDisksToCreatedVM(config, vm, datastoreRef); err != nil {
+	if err := c.addDisksToCreatedVM(config, vm, inventory); err != nil {
 		return nil, err
 	}
 
-	vm, err = c.reregisterVMForDiskDescriptors(config, vm)
+	vm, err = c.reregisterVMForDiskDescriptors(config, vm, inventory)
 	if err != nil {
 		return nil, err
 	}
@@ -249,23 +257,25 @@ func (c *Client) CreateVM(config VMConfig) (*object.VirtualMachine, error) {
 }
 
 type createVMInventory struct {
-	pool      *object.ResourcePool
-	datastore *object.Datastore
-	network   object.NetworkReference
-	folders   *object.DatacenterFolders
+	pool             *object.ResourcePool
+	host             *object.HostSystem // nil lets the pool pick
+	bootDatastore    *object.Datastore
+	openebsDatastore *object.Datastore
+	network          object.NetworkReference
+	folder           *object.Folder
 }
 
 func (c *Client) resolveCreateVMInventory(config VMConfig) (*createVMInventory, error) {
-	// Find resource pool (use default for standalone ESXi)
-	pool, err := c.finder.DefaultResourcePool(c.ctx)
+	// Find resource pool and host (default pool for standalone ESXi)
+	pool, host, err := c.resolvePlacement(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find resource pool: %w", err)
+		return nil, err
 	}
 
-	// Find datastore
-	datastore, err := c.finder.Datastore(c.ctx, config.Datastore)
+	// Find the per-disk datastores and confirm they can hold the disks
+	datastores, err := c.preflightDatastores([]VMConfig{config})
 	if err != nil {
-		return nil, fmt.Errorf("failed to find datastore %s: %w", config.Datastore, err)
+		return nil, err
 	}
 
 	// Find network
@@ -275,20 +285,74 @@ func (c *Client) resolveCreateVMInventory(config VMConfig) (*createVMInventory,
 	}
 
 	// Find VM folder
-	folders, err := c.datacenter.Folders(c.ctx)
+	folder, err := c.resolveVMFolder(config.Folder)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get folders: %w", err)
+		return nil, err
 	}
 
 	return &createVMInventory{
-		pool:      pool,
-		datastore: datastore,
-		network:   network,
-		folders:   folders,
+		pool:             pool,
+		host:             host,
+		bootDatastore:    datastores[config.BootDiskDatastore()],
+		openebsDatastore: datastores[config.OpenEBSDiskDatastore()],
+		network:          network,
+		folder:           folder,
 	}, nil
 }
 
-func (c *Client) createVMWithControllers(config VMConfig, inventory *createVMInventory) (*object.VirtualMachine, types.ManagedObjectReference, error) {
+// PreflightDatastores confirms every datastore the configs place a disk on
+// exists, is accessible, and has free space for the disks allocated up front,
+// BEFORE any VM is created. Thick and eager-zeroed disks are charged their full
+// size (summed across the batch); thin disks only need the datastore to exist.
+func (c *Client) PreflightDatastores(configs []VMConfig) error {
+	_, err := c.preflightDatastores(configs)
+	return err
+}
+
+func (c *Client) preflightDatastores(configs []VMConfig) (map[string]*object.Datastore, error) {
+	required := make(map[string]int64)
+	var names []string
+	for _, config := range configs {
+		for _, disk := range config.DiskPlacements() {
+			if _, seen := required[disk.Datastore]; !seen {
+				names = append(names, disk.Datastore)
+			}
+			if disk.Provisioning != DiskProvisioningThin {
+				required[disk.Datastore] += int64(disk.SizeGB) << 30
+			}
+		}
+	}
+
+	datastores := make(map[string]*object.Datastore, len(names))
+	for _, name := range names {
+		datastore, err := c.finder.Datastore(c.ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find datastore %s: %w", name, err)
+		}
+		var props mo.Datastore
+		if err := datastore.Properties(c.ctx, datastore.Reference(), []string{"summary"}, &props); err != nil {
+			return nil, fmt.Errorf("failed to read datastore %s capacity: %w", name, err)
+		}
+		if err := checkDatastoreCapacity(name, props.Summary, required[name]); err != nil {
+			return nil, err
+		}
+		datastores[name] = datastore
+	}
+	return datastores, nil
+}
+
+func checkDatastoreCapacity(name string, summary types.DatastoreSummary, required int64) error {
+	if !summary.Accessible {
+		return fmt.Errorf("preflight: datastore %q is not accessible", name)
+	}
+	if required > summary.FreeSpace {
+		return fmt.Errorf("preflight: datastore %q has %.1f GiB free but the thick-provisioned disks need %.1f GiB",
+			name, float64(summary.FreeSpace)/(1<<30), float64(required)/(1<<30))
+	}
+	return nil
+}
+
+func (c *Client) createVMWithControllers(config VMConfig, inventory *createVMInventory) (*object.VirtualMachine, error) {
 	spec := buildInitialVMSpec(config)
 
 	// Log IOMMU status
@@ -296,32 +360,32 @@ func (c *Client) createVMWithControllers(config VMConfig, inventory *createVMInv
 		c.logger.Debug("IOMMU/VT-d enabled for VM %s", config.Name)
 	}
 
-	datastoreRef := inventory.datastore.Reference()
+	datastoreRef := inventory.bootDatastore.Reference()
 
 	// Create vmxnet3 network adapter and set to vl999 portgroup
 	backing, err := inventory.network.EthernetCardBackingInfo(c.ctx)
 	if err != nil {
-		return nil, types.ManagedObjectReference{}, fmt.Errorf("failed to get network backing: %w", err)
+		return nil, fmt.Errorf("failed to get network backing: %w", err)
 	}
 	spec.DeviceChange = buildInitialDeviceChanges(config, datastoreRef, backing)
 
 	// Create VM
-	task, err := inventory.folders.VmFolder.CreateVM(c.ctx, spec, inventory.pool, nil)
+	task, err := inventory.folder.CreateVM(c.ctx, spec, inventory.pool, inventory.host)
 	if err != nil {
-		return nil, types.ManagedObjectReference{}, fmt.Errorf("failed to create VM: %w", err)
+		return nil, fmt.Errorf("failed to create VM: %w", err)
 	}
 
 	info, err := task.WaitForResult(c.ctx, nil)
 	if err != nil {
-		return nil, types.ManagedObjectReference{}, fmt.Errorf("failed to create VM: %w", err)
+		return nil, fmt.Errorf("failed to create VM: %w", err)
 	}
 
 	vm := object.NewVirtualMachine(c.vim, info.Result.(types.ManagedObjectReference))
 	c.logger.Success("VM %s created successfully (with controllers)", config.Name)
-	return vm, datastoreRef, nil
+	return vm, nil
 }
 
-func (c *Client) addDisksToCreatedVM(config VMConfig, vm *object.VirtualMachine, datastoreRef types.ManagedObjectReference) error {
+func (c *Client) addDisksToCreatedVM(config VMConfig, vm *object.VirtualMachine, inventory *createVMInventory) error {
 	// PHASE 2: Add disks to the VM after controllers are created
 	c.logger.Info("Adding disks to VM %s...", config.Name)
 
@@ -332,16 +396,16 @@ func (c *Client) addDisksToCreatedVM(config VMConfig, vm *object.VirtualMachine,
 		return fmt.Errorf("failed to get VM properties: %w", err)
 	}
 
-	nvme0Key, nvme1Key, err := findNVMEControllerKeys(vmInfo.Config.Hardware.Device)
+	bootControllerKey, openebsControllerKey, err := findDiskControllerKeys(config.DiskController, vmInfo.Config.Hardware.Device)
 	if err != nil {
 		return err
 	}
 
-	c.logger.Debug("Found NVME controllers: nvme0=%d, nvme1=%d", nvme0Key, nvme1Key)
+	c.logger.Debug("Found %s controllers: bus0=%d, bus1=%d", config.DiskController.orDefault(), bootControllerKey, openebsControllerKey)
 
 	// Reconfigure VM to add disks
 	configSpec := types.VirtualMachineConfigSpec{
-		DeviceChange: buildDiskDeviceChanges(config, datastoreRef, nvme0Key, nvme1Key),
+		DeviceChange: buildDiskDeviceChanges(config, inventory.bootDatastore.Reference(), inventory.openebsDatastore.Reference(), bootControllerKey, openebsControllerKey),
 	}
 
 	task, err := vm.Reconfigure(c.ctx, configSpec)
@@ -358,7 +422,7 @@ func (c *Client) addDisksToCreatedVM(config VMConfig, vm *object.VirtualMachine,
 	return nil
 }
 
-func (c *Client) reregisterVMForDiskDescriptors(config VMConfig, vm *object.VirtualMachine) (*object.VirtualMachine, error) {
+func (c *Client) reregisterVMForDiskDescriptors(config VMConfig, vm *object.VirtualMachine, inventory *createVMInventory) (*object.VirtualMachine, error) {
 	// PHASE 2.5: Wait for vSphere to fully process VMDK files
 	// vSphere needs time to complete background operations on newly created VMDK files
 	// before they can be used for booting. This typically takes a few seconds.
@@ -380,17 +444,6 @@ func (c *Client) reregisterVMForDiskDescriptors(config VMConfig, vm *object.Virt
 	vmxPath := vmConfig.Config.Files.VmPathName
 	c.logger.Debug("VMX path: %s", vmxPath)
 
-	// Get the folder and resource pool for re-registration
-	regFolders, err := c.datacenter.Folders(c.ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get folders: %w", err)
-	}
-
-	regPool, err := c.finder.DefaultResourcePool(c.ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to find resource pool: %w", err)
-	}
-
 	// Unregister the VM
 	c.logger.Debug("Unregistering VM from inventory...")
 	err = vm.Unregister(c.ctx)
@@ -398,9 +451,9 @@ func (c *Client) reregisterVMForDiskDescriptors(config VMConfig, vm *object.Virt
 		return nil, fmt.Errorf("failed to unregister VM: %w", err)
 	}
 
-	// Re-register the VM (this fixes VMDK descriptors to match VMX controller types)
+	// Re-register the VM where it was created (this fixes VMDK descriptors to match VMX controller types)
 	c.logger.Debug("Re-registering VM to fix VMDK descriptors...")
-	regTask, err := regFolders.VmFolder.RegisterVM(c.ctx, vmxPath, config.Name, false, regPool, nil)
+	regTask, err := inventory.folder.RegisterVM(c.ctx, vmxPath, config.Name, false, inventory.pool, inventory.host)
 	if err != nil {
 		return nil, fmt.Errorf("failed to re-register VM: %w", err)
 	}
@@ -593,7 +646,11 @@ func (c *Client) UploadISOToDatastore(localFilePath, datastoreName, remoteFileNa
 	c.logger.Info("Uploading %s (%d MB) to datastore...", remoteFileName, fileInfo.Size()/(1024*1024))
 
 	// Upload file using datastore UploadFile method
-	if err := uploadDatastoreFileFn(datastore, c.ctx, localFilePath, remoteFileName); err != nil {
+	var param *soap.Upload
+	if c.uploadProgress != nil {
+		param = &soap.Upload{Progress: uploadProgressSink(c.uploadProgress)}
+	}
+	if err := uploadDatastoreFileFn(datastore, c.ctx, localFilePath, remoteFileName, param); err != nil {
 		return fmt.Errorf("failed to upload file to datastore: %w", err)
 	}
 
@@ -601,6 +658,44 @@ func (c *Client) UploadISOToDatastore(localFilePath, datastoreName, remoteFileNa
 	return nil
 }
 
+// SetUploadProgress makes UploadISOToDatastore report its progress to fn as
+// a percentage and a transfer rate such as "4.1MiB/s". nil stops reporting.
+func (c *Client) SetUploadProgress(fn func(percent float32, rate string)) {
+	c.uploadProgress = fn
+}
+
+// uploadProgressSink forwards govmomi's upload reports to fn until the
+// upload closes the channel.
+func uploadProgressSink(fn func(percent float32, rate string)) progress.Sinker {
+	return progress.SinkFunc(func() chan<- progress.Report {
+		ch := make(chan progress.Report)
+		go func() {
+			for report := range ch {
+				fn(report.Percentage(), report.Detail())
+			}
+		}()
+		return ch
+	})
+}
+
+// DatastoreFileSize returns the size in bytes of remoteFileName on the
+// datastore, or 0 when the file does not exist.
+func (c *Client) DatastoreFileSize(datastoreName, remoteFileName string) (int64, error) {
+	datastore, err := findDatastoreFn(c.finder, c.ctx, datastoreName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find datastore %s: %w", datastoreName, err)
+	}
+	info, err := datastore.Stat(c.ctx, remoteFileName)
+	if err != nil {
+		var missing object.DatastoreNoSuchFileError
+		if errors.As(err, &missing) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to stat [%s] %s: %w", datastoreName, remoteFileName, err)
+	}
+	return info.GetFileInfo().FileSize, nil
+}
+
 // DeployVMsConcurrently deploys multiple VMs in parallel
 func (c *Client) DeployVMsConcurrently(configs []VMConfig) error {
 	return deployVMsConcurrently(configs, c.logger, func(cfg VMConfig) error {
@@ -775,6 +870,10 @@ func (c *ESXiSSHClient) CreateK8sVM(config VMConfig) error {
 	if !exists {
 		return fmt.Errorf("no predefined configuration for k8s node: %s", config.Name)
 	}
+	// The VMX below attaches both disks to nvme0/nvme1.
+	if config.DiskController.orDefault() != DiskControllerNVMe {
+		return fmt.Errorf("k8s node %s uses NVMe controllers; disk controller %s is not supported", config.Name, config.DiskController)
+	}
 
 	// Merge node-specific config
 	config.RDMPath = nodeConfig.RDMPath
@@ -782,7 +881,9 @@ func (c *ESXiSSHClient) CreateK8sVM(config VMConfig) error {
 	config.PCIDeviceHex = nodeConfig.PCIDeviceHex
 	config.MacAddress = nodeConfig.MacAddress
 	config.CPUAffinity = nodeConfig.CPUAffinity
-	config.BootDatastore = nodeConfig.BootDatastore
+	if config.BootDatastore == "" {
+		config.BootDatastore = nodeConfig.BootDatastore
+	}
 
 	// Step 1: Create VM directory on boot datastore
 	vmDir := fmt.Sprintf("/vmfs/volumes/%s/%s", config.BootDatastore, config.Name)
@@ -791,7 +892,7 @@ func (c *ESXiSSHClient) CreateK8sVM(config VMConfig) error {
 		return fmt.Errorf("failed to create VM directory: %w", err)
 	}
 
-	// Step 2: Create OpenEBS directory on truenas-iscsi
+	// Step 2: Create OpenEBS directory on the OpenEBS datastore
 	openebsDir := fmt.Sprintf("/vmfs/volumes/%s/%s", config.OpenEBSDatastore, config.Name)
 	c.logger.Info("Creating OpenEBS directory: %s", openebsDir)
 	if _, err := c.ExecuteCommand(fmt.Sprintf("mkdir -p %s", shellQuote(openebsDir))); err != nil {
@@ -801,7 +902,7 @@ func (c *ESXiSSHClient) CreateK8sVM(config VMConfig) error {
 	// Step 3: Create boot disk VMDK
 	bootVMDK := fmt.Sprintf("%s/%s.vmdk", vmDir, config.Name)
 	c.logger.Info("Creating boot disk: %s (%dGB)", bootVMDK, config.DiskSize)
-	createBootDisk := fmt.Sprintf("vmkfstools -c %dG -d thin %s", config.DiskSize, shellQuote(bootVMDK))
+	createBootDisk := fmt.Sprintf("vmkfstools -c %dG -d %s %s", config.DiskSize, config.BootDiskProvisioning().vmkfstoolsFormat(), shellQuote(bootVMDK))
 	if _, err := c.ExecuteCommand(createBootDisk); err != nil {
 		return fmt.Errorf("failed to create boot disk: %w", err)
 	}
@@ -809,7 +910,7 @@ func (c *ESXiSSHClient) CreateK8sVM(config VMConfig) error {
 	// Step 4: Create OpenEBS disk VMDK
 	openebsVMDK := fmt.Sprintf("%s/%s.vmdk", openebsDir, config.Name)
 	c.logger.Info("Creating OpenEBS disk: %s (%dGB)", openebsVMDK, config.OpenEBSSize)
-	createOpenEBSDisk := fmt.Sprintf("vmkfstools -c %dG -d thin %s", config.OpenEBSSize, shellQuote(openebsVMDK))
+	createOpenEBSDisk := fmt.Sprintf("vmkfstools -c %dG -d %s %s", config.OpenEBSSize, config.OpenEBSDiskProvisioning().vmkfstoolsFormat(), shellQuote(openebsVMDK))
 	if _, err := c.ExecuteCommand(createOpenEBSDisk); err != nil {
 		return fmt.Errorf("failed to create OpenEBS disk: %w", err)
 	}
@@ -994,12 +1095,14 @@ func buildInitialVMSpec(config VMConfig) types.VirtualMachineConfigSpec {
 		NumCPUs:  numCPUs,
 		MemoryMB: int64(config.Memory),
 		Files: &types.VirtualMachineFileInfo{
-			VmPathName: fmt.Sprintf("[%s] %s", config.Datastore, config.Name),
+			VmPathName: fmt.Sprintf("[%s] %s", config.BootDiskDatastore(), config.Name),
 		},
-		Firmware: "efi",
+		Firmware: string(config.Firmware.orDefault()),
 		BootOptions: &types.VirtualMachineBootOptions{
-			EfiSecureBootEnabled: types.NewBool(false),
+			EfiSecureBootEnabled: types.NewBool(config.SecureBoot && config.Firmware.orDefault() == FirmwareEFI),
 		},
+		CpuHotAddEnabled:    types.NewBool(config.CPUHotAdd),
+		MemoryHotAddEnabled: types.NewBool(config.MemoryHotAdd),
 		Flags: &types.VirtualMachineFlagInfo{
 			VirtualMmuUsage:  "automatic",
 			VirtualExecUsage: "hvAuto",
@@ -1029,6 +1132,17 @@ func buildExtraConfig(config VMConfig) []types.BaseOptionValue {
 			&types.OptionValue{Key: "guestinfo.ignition.config.data.encoding", Value: "base64"},
 		)
 	}
+	// Operator-supplied keys come last, in key order; one the CLI already set
+	// takes the operator's value instead of appearing twice.
+	for _, key := range slices.Sorted(maps.Keys(config.ExtraConfig)) {
+		value := config.ExtraConfig[key]
+		i := slices.IndexFunc(extraConfig, func(option types.BaseOptionValue) bool { return option.GetOptionValue().Key == key })
+		if i >= 0 {
+			extraConfig[i] = &types.OptionValue{Key: key, Value: value}
+			continue
+		}
+		extraConfig = append(extraConfig, &types.OptionValue{Key: key, Value: value})
+	}
 	return extraConfig
 }
 
@@ -1068,18 +1182,8 @@ func buildInitialDeviceChanges(config VMConfig, datastoreRef types.ManagedObject
 
 func buildInitialDevices(config VMConfig, datastoreRef types.ManagedObjectReference, backing types.BaseVirtualDeviceBackingInfo) []types.BaseVirtualDevice {
 	devices := []types.BaseVirtualDevice{
-		&types.VirtualNVMEController{
-			VirtualController: types.VirtualController{
-				VirtualDevice: types.VirtualDevice{Key: -100},
-				BusNumber:     0,
-			},
-		},
-		&types.VirtualNVMEController{
-			VirtualController: types.VirtualController{
-				VirtualDevice: types.VirtualDevice{Key: -101},
-				BusNumber:     1,
-			},
-		},
+		buildDiskControllerDevice(config.DiskController, -100, 0),
+		buildDiskControllerDevice(config.DiskController, -101, 1),
 		buildVmxnet3Device(config, backing),
 	}
 
@@ -1131,6 +1235,37 @@ func buildInitialDevices(config VMConfig, datastoreRef types.ManagedObjectRefere
 	return devices
 }
 
+// buildDiskControllerDevice builds one disk controller of the given type.
+// SCSI controllers do not share their bus with other VMs.
+func buildDiskControllerDevice(controller DiskController, key, busNumber int32) types.BaseVirtualDevice {
+	base := types.VirtualController{
+		VirtualDevice: types.VirtualDevice{Key: key},
+		BusNumber:     busNumber,
+	}
+	scsi := types.VirtualSCSIController{VirtualController: base, SharedBus: types.VirtualSCSISharingNoSharing}
+	switch controller.orDefault() {
+	case DiskControllerPVSCSI:
+		return &types.ParaVirtualSCSIController{VirtualSCSIController: scsi}
+	case DiskControllerLSILogic:
+		return &types.VirtualLsiLogicController{VirtualSCSIController: scsi}
+	default:
+		return &types.VirtualNVMEController{VirtualController: base}
+	}
+}
+
+// isDiskController reports whether device is a controller of the given type.
+func isDiskController(controller DiskController, device types.BaseVirtualDevice) bool {
+	switch device.(type) {
+	case *types.VirtualNVMEController:
+		return controller.orDefault() == DiskControllerNVMe
+	case *types.ParaVirtualSCSIController:
+		return controller.orDefault() == DiskControllerPVSCSI
+	case *types.VirtualLsiLogicController:
+		return controller.orDefault() == DiskControllerLSILogic
+	}
+	return false
+}
+
 func buildVmxnet3Device(config VMConfig, backing types.BaseVirtualDeviceBackingInfo) *types.VirtualVmxnet3 {
 	netDevice := &types.VirtualVmxnet3{
 		VirtualVmxnet: types.VirtualVmxnet{
@@ -1174,74 +1309,66 @@ func buildDeviceChangeSpecs(devices []types.BaseVirtualDevice) []types.BaseVirtu
 	return deviceChanges
 }
 
-func findNVMEControllerKeys(devices []types.BaseVirtualDevice) (int32, int32, error) {
-	var nvme0Key, nvme1Key int32
+// findDiskControllerKeys returns the keys of the bus 0 and bus 1 controllers
+// of the given type.
+func findDiskControllerKeys(controller DiskController, devices []types.BaseVirtualDevice) (int32, int32, error) {
+	var bus0Key, bus1Key int32
 	for _, device := range devices {
-		if ctrl, ok := device.(*types.VirtualNVMEController); ok {
-			switch ctrl.BusNumber {
-			case 0:
-				nvme0Key = ctrl.Key
-			case 1:
-				nvme1Key = ctrl.Key
-			}
+		ctrl, ok := device.(types.BaseVirtualController)
+		if !ok || !isDiskController(controller, device) {
+			continue
+		}
+		switch info := ctrl.GetVirtualController(); info.BusNumber {
+		case 0:
+			bus0Key = info.Key
+		case 1:
+			bus1Key = info.Key
 		}
 	}
-	if nvme0Key == 0 || nvme1Key == 0 {
-		return 0, 0, fmt.Errorf("failed to find NVME controllers (nvme0: %d, nvme1: %d)", nvme0Key, nvme1Key)
+	if bus0Key == 0 || bus1Key == 0 {
+		return 0, 0, fmt.Errorf("failed to find %s controllers (bus 0: %d, bus 1: %d)", strings.ToUpper(string(controller.orDefault())), bus0Key, bus1Key)
 	}
-	return nvme0Key, nvme1Key, nil
+	return bus0Key, bus1Key, nil
 }
 
-func buildDiskDeviceChanges(config VMConfig, datastoreRef types.ManagedObjectReference, nvme0Key, nvme1Key int32) []types.BaseVirtualDeviceConfigSpec {
+// buildDiskDeviceChanges adds the boot disk (bus 0 controller) and, when
+// sized, the OpenEBS disk (bus 1 controller), each on its own datastore with
+// its provisioning mode. A "[datastore]" file name lets vSphere create the
+// VMDK in a folder named after the VM on that datastore.
+func buildDiskDeviceChanges(config VMConfig, bootRef, openebsRef types.ManagedObjectReference, bootControllerKey, openebsControllerKey int32) []types.BaseVirtualDeviceConfigSpec {
 	diskChanges := []types.BaseVirtualDeviceConfigSpec{
-		&types.VirtualDeviceConfigSpec{
-			Operation:     types.VirtualDeviceConfigSpecOperationAdd,
-			FileOperation: types.VirtualDeviceConfigSpecFileOperationCreate,
-			Device: &types.VirtualDisk{
-				VirtualDevice: types.VirtualDevice{
-					Key:           -1,
-					ControllerKey: nvme0Key,
-					UnitNumber:    types.NewInt32(0),
-					Backing: &types.VirtualDiskFlatVer2BackingInfo{
-						VirtualDeviceFileBackingInfo: types.VirtualDeviceFileBackingInfo{
-							FileName:  "",
-							Datastore: &datastoreRef,
-						},
-						DiskMode:        "persistent",
-						ThinProvisioned: types.NewBool(true),
-						EagerlyScrub:    types.NewBool(false),
-					},
-				},
-				CapacityInKB: int64(config.DiskSize) * 1024 * 1024,
-			},
-		},
+		buildVirtualDiskSpec(-1, bootControllerKey, config.DiskSize, config.BootDiskDatastore(), bootRef, config.BootDiskProvisioning()),
 	}
-
 	if config.OpenEBSSize > 0 {
-		diskChanges = append(diskChanges, &types.VirtualDeviceConfigSpec{
-			Operation:     types.VirtualDeviceConfigSpecOperationAdd,
-			FileOperation: types.VirtualDeviceConfigSpecFileOperationCreate,
-			Device: &types.VirtualDisk{
-				VirtualDevice: types.VirtualDevice{
-					Key:           -2,
-					ControllerKey: nvme1Key,
-					UnitNumber:    types.NewInt32(0),
-					Backing: &types.VirtualDiskFlatVer2BackingInfo{
-						VirtualDeviceFileBackingInfo: types.VirtualDeviceFileBackingInfo{
-							FileName:  "",
-							Datastore: &datastoreRef,
-						},
-						DiskMode:        "persistent",
-						ThinProvisioned: types.NewBool(true),
-						EagerlyScrub:    types.NewBool(false),
+		diskChanges = append(diskChanges,
+			buildVirtualDiskSpec(-2, openebsControllerKey, config.OpenEBSSize, config.OpenEBSDiskDatastore(), openebsRef, config.OpenEBSDiskProvisioning()))
+	}
+	return diskChanges
+}
+
+func buildVirtualDiskSpec(key, controllerKey int32, sizeGB int, datastoreName string, datastoreRef types.ManagedObjectReference, provisioning DiskProvisioning) *types.VirtualDeviceConfigSpec {
+	thin, eagerlyScrub := provisioning.backing()
+	return &types.VirtualDeviceConfigSpec{
+		Operation:     types.VirtualDeviceConfigSpecOperationAdd,
+		FileOperation: types.VirtualDeviceConfigSpecFileOperationCreate,
+		Device: &types.VirtualDisk{
+			VirtualDevice: types.VirtualDevice{
+				Key:           key,
+				ControllerKey: controllerKey,
+				UnitNumber:    types.NewInt32(0),
+				Backing: &types.VirtualDiskFlatVer2BackingInfo{
+					VirtualDeviceFileBackingInfo: types.VirtualDeviceFileBackingInfo{
+						FileName:  fmt.Sprintf("[%s]", datastoreName),
+						Datastore: &datastoreRef,
 					},
+					DiskMode:        "persistent",
+					ThinProvisioned: types.NewBool(thin),
+					EagerlyScrub:    types.NewBool(eagerlyScrub),
 				},
-				CapacityInKB: int64(config.OpenEBSSize) * 1024 * 1024,
 			},
-		})
+			CapacityInKB: int64(sizeGB) * 1024 * 1024,
+		},
 	}
-
-	return diskChanges
 }
 
 func powerOnWithRetry(ctx context.Context, logger *common.ColorLogger, vm vmLifecycle, maxRetries int, retryDelays []time.Duration, vmName string) error {
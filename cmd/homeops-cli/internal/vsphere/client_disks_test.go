@@ -0,0 +1,244 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"homeops-cli/internal/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// withSimulatedESXi runs fn against a vcsim standalone host with two local
+// datastores (LocalDS_0, LocalDS_1) and the "VM Network" portgroup.
+func withSimulatedESXi(t *testing.T, fn func(client *Client)) {
+	t.Helper()
+	originalSleep := vsphereSleep
+	t.Cleanup(func() { vsphereSleep = originalSleep })
+	vsphereSleep = func(time.Duration) {}
+
+	model := simulator.ESX()
+	model.Datastore = 2
+	model.Run(func(ctx context.Context, vim *vim25.Client) error {
+		finder := find.NewFinder(vim, true)
+		datacenter, err := finder.DefaultDatacenter(ctx)
+		require.NoError(t, err)
+		finder.SetDatacenter(datacenter)
+		fn(&Client{vim: vim, finder: finder, datacenter: datacenter, logger: common.NewColorLogger(), ctx: ctx})
+		return nil
+	})
+}
+
+func simulatedVMConfig(name string) VMConfig {
+	config := defaultVMConfig(name)
+	config.Memory = 1024
+	config.VCPUs = 2
+	config.DiskSize = 1
+	config.OpenEBSSize = 2
+	config.Datastore = "LocalDS_0"
+	config.Network = "VM Network"
+	config.EnableSRIOV = false
+	config.PowerOn = false
+	return config
+}
+
+// createSimulatedVM runs CreateVM's inventory, controller, and disk phases.
+// The re-register phase is skipped: vcsim re-registers from the VMX without
+// its devices.
+func createSimulatedVM(t *testing.T, client *Client, config VMConfig) *object.VirtualMachine {
+	t.Helper()
+	inventory, err := client.resolveCreateVMInventory(config)
+	require.NoError(t, err)
+	vm, err := client.createVMWithControllers(config, inventory)
+	require.NoError(t, err)
+	require.NoError(t, client.addDisksToCreatedVM(config, vm, inventory))
+	return vm
+}
+
+type simulatedDisk struct {
+	capacityGB   int64
+	datastore    string
+	thin         bool
+	eagerlyScrub bool
+}
+
+func createdVMDisks(t *testing.T, client *Client, vm *object.VirtualMachine) []simulatedDisk {
+	t.Helper()
+	var props mo.VirtualMachine
+	require.NoError(t, vm.Properties(client.ctx, vm.Reference(), []string{"config.hardware.device"}, &props))
+
+	var disks []simulatedDisk
+	for _, device := range object.VirtualDeviceList(props.Config.Hardware.Device).SelectByType((*types.VirtualDisk)(nil)) {
+		disk := device.(*types.VirtualDisk)
+		backing := disk.Backing.(*types.VirtualDiskFlatVer2BackingInfo)
+		datastore, err := object.NewDatastore(client.vim, *backing.Datastore).ObjectName(client.ctx)
+		require.NoError(t, err)
+		disks = append(disks, simulatedDisk{
+			capacityGB:   disk.CapacityInKB / (1024 * 1024),
+			datastore:    datastore,
+			thin:         backing.ThinProvisioned != nil && *backing.ThinProvisioned,
+			eagerlyScrub: backing.EagerlyScrub != nil && *backing.EagerlyScrub,
+		})
+	}
+	return disks
+}
+
+func TestCreateVMDiskProvisioningModes(t *testing.T) {
+	withSimulatedESXi(t, func(client *Client) {
+		for _, tc := range []struct {
+			mode          DiskProvisioning
+			thin, eagerly bool
+		}{
+			{DiskProvisioningThin, true, false},
+			{DiskProvisioningThick, false, false},
+			{DiskProvisioningEagerZeroedThick, false, true},
+		} {
+			config := simulatedVMConfig("vm-" + string(tc.mode))
+			config.DiskProvisioning = tc.mode
+
+			vm := createSimulatedVM(t, client, config)
+			assert.Equal(t, []simulatedDisk{
+				{capacityGB: 1, datastore: "LocalDS_0", thin: tc.thin, eagerlyScrub: tc.eagerly},
+				{capacityGB: 2, datastore: "LocalDS_0", thin: tc.thin, eagerlyScrub: tc.eagerly},
+			}, createdVMDisks(t, client, vm), tc.mode)
+		}
+	})
+}
+
+func TestCreateVMPerDiskProvisioning(t *testing.T) {
+	withSimulatedESXi(t, func(client *Client) {
+		config := simulatedVMConfig("mixed")
+		config.OpenEBSProvisioning = DiskProvisioningEagerZeroedThick
+
+		vm := createSimulatedVM(t, client, config)
+		assert.Equal(t, []simulatedDisk{
+			{capacityGB: 1, datastore: "LocalDS_0", thin: true},
+			{capacityGB: 2, datastore: "LocalDS_0", eagerlyScrub: true},
+		}, createdVMDisks(t, client, vm))
+	})
+}
+
+// diskControllerTypes maps each of the VM's disks to the type and bus number
+// of the controller it hangs off, e.g. "*types.ParaVirtualSCSIController/1".
+func diskControllerTypes(t *testing.T, client *Client, vm *object.VirtualMachine) []string {
+	t.Helper()
+	var props mo.VirtualMachine
+	require.NoError(t, vm.Properties(client.ctx, vm.Reference(), []string{"config.hardware.device"}, &props))
+	devices := object.VirtualDeviceList(props.Config.Hardware.Device)
+
+	var controllers []string
+	for _, disk := range devices.SelectByType((*types.VirtualDisk)(nil)) {
+		controller := devices.FindByKey(disk.GetVirtualDevice().ControllerKey)
+		require.NotNil(t, controller)
+		controllers = append(controllers, fmt.Sprintf("%T/%d", controller, controller.(types.BaseVirtualController).GetVirtualController().BusNumber))
+	}
+	return controllers
+}
+
+func TestCreateVMDiskControllers(t *testing.T) {
+	withSimulatedESXi(t, func(client *Client) {
+		for _, tc := range []struct {
+			controller DiskController
+			want       string
+		}{
+			{"", "*types.VirtualNVMEController"},
+			{DiskControllerNVMe, "*types.VirtualNVMEController"},
+			{DiskControllerPVSCSI, "*types.ParaVirtualSCSIController"},
+			{DiskControllerLSILogic, "*types.VirtualLsiLogicController"},
+		} {
+			config := simulatedVMConfig("vm-" + string(tc.controller))
+			config.DiskController = tc.controller
+
+			vm := createSimulatedVM(t, client, config)
+			assert.Equal(t, []string{tc.want + "/0", tc.want + "/1"}, diskControllerTypes(t, client, vm), tc.controller)
+		}
+	})
+}
+
+func TestCreateVMAppliesVMOptions(t *testing.T) {
+	withSimulatedESXi(t, func(client *Client) {
+		config := simulatedVMConfig("options")
+		config.SecureBoot = true
+		config.CPUHotAdd = true
+		config.MemoryHotAdd = true
+		config.ExtraConfig = map[string]string{"guestinfo.role": "worker", "disk.EnableUUID": "TRUE"}
+
+		vm := createSimulatedVM(t, client, config)
+		var props mo.VirtualMachine
+		require.NoError(t, vm.Properties(client.ctx, vm.Reference(), []string{"config"}, &props))
+		assert.Equal(t, "efi", props.Config.Firmware)
+		assert.True(t, *props.Config.BootOptions.EfiSecureBootEnabled)
+		assert.True(t, *props.Config.CpuHotAddEnabled)
+		assert.True(t, *props.Config.MemoryHotAddEnabled)
+		extraConfig := map[string]any{}
+		for _, option := range props.Config.ExtraConfig {
+			extraConfig[option.GetOptionValue().Key] = option.GetOptionValue().Value
+		}
+		assert.Equal(t, "worker", extraConfig["guestinfo.role"])
+		assert.Equal(t, "TRUE", extraConfig["disk.EnableUUID"])
+	})
+}
+
+func TestCreateVMPlacesDisksPerDatastore(t *testing.T) {
+	withSimulatedESXi(t, func(client *Client) {
+		config := simulatedVMConfig("split")
+		config.OpenEBSDatastore = "LocalDS_1"
+
+		vm := createSimulatedVM(t, client, config)
+		disks := createdVMDisks(t, client, vm)
+		require.Len(t, disks, 2)
+		assert.Equal(t, "LocalDS_0", disks[0].datastore, "boot disk falls back to --datastore")
+		assert.Equal(t, "LocalDS_1", disks[1].datastore)
+
+		var props mo.VirtualMachine
+		require.NoError(t, vm.Properties(client.ctx, vm.Reference(), []string{"config.files"}, &props))
+		assert.Contains(t, props.Config.Files.VmPathName, "[LocalDS_0]", "VM home follows the boot disk")
+	})
+}
+
+func TestPreflightDatastores(t *testing.T) {
+	withSimulatedESXi(t, func(client *Client) {
+		config := simulatedVMConfig("preflight")
+		config.BootDatastore = "LocalDS_1"
+		require.NoError(t, client.PreflightDatastores([]VMConfig{config}))
+
+		config.OpenEBSDatastore = "missing-ds"
+		err := client.PreflightDatastores([]VMConfig{config})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "missing-ds")
+
+		// Thin disks may overcommit; thick ones are charged full size.
+		config = simulatedVMConfig("huge")
+		config.OpenEBSSize = 1 << 30
+		require.NoError(t, client.PreflightDatastores([]VMConfig{config}))
+		config.DiskProvisioning = DiskProvisioningThick
+		err = client.PreflightDatastores([]VMConfig{config})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `preflight: datastore "LocalDS_0"`)
+
+		_, err = client.CreateVM(config)
+		require.Error(t, err, "CreateVM runs the same preflight")
+		_, findErr := client.finder.VirtualMachine(client.ctx, "huge")
+		assert.Error(t, findErr, "no VM is created when the preflight fails")
+	})
+}
+
+func TestCheckDatastoreCapacity(t *testing.T) {
+	summary := types.DatastoreSummary{Accessible: true, FreeSpace: 10 << 30}
+	require.NoError(t, checkDatastoreCapacity("ds", summary, 10<<30))
+	require.Error(t, checkDatastoreCapacity("ds", summary, 11<<30))
+
+	summary.Accessible = false
+	err := checkDatastoreCapacity("ds", summary, 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not accessible")
+}
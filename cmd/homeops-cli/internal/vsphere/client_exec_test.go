@@ -99,6 +99,36 @@ func TestESXiSSHClientCreateK8sVM(t *testing.T) {
 		assert.Equal(t, "vim-cmd vmsvc/power.on 123", commands[6])
 	})
 
+	t.Run("provisioning and datastore overrides", func(t *testing.T) {
+		var commands []string
+		sshCombinedOutputFn = func(_ string, args ...string) ([]byte, error) {
+			command := args[len(args)-1]
+			commands = append(commands, command)
+			if strings.Contains(command, "vim-cmd solo/registervm") {
+				return []byte("Registered virtual machine: 123\n"), nil
+			}
+			return []byte("ok"), nil
+		}
+
+		client := &ESXiSSHClient{host: "esxi.local", username: "root", keyFile: "/tmp/key", logger: common.NewColorLogger()}
+		config := GetK8sVMConfig("k8s-0")
+		config.BootDatastore = "local-nvme2"
+		config.OpenEBSDatastore = "bulk-ds"
+		config.DiskProvisioning = DiskProvisioningEagerZeroedThick
+		require.NoError(t, client.CreateK8sVM(config))
+		assert.Contains(t, commands[2], "vmkfstools -c 250G -d eagerzeroedthick '/vmfs/volumes/local-nvme2/k8s-0/k8s-0.vmdk'")
+		assert.Contains(t, commands[3], "-d eagerzeroedthick '/vmfs/volumes/bulk-ds/k8s-0/k8s-0.vmdk'")
+
+		commands = nil
+		config.BootProvisioning = DiskProvisioningThin
+		require.NoError(t, client.CreateK8sVM(config))
+		assert.Contains(t, commands[2], "-d thin '/vmfs/volumes/local-nvme2/k8s-0/k8s-0.vmdk'", "a per-disk mode overrides the shared one")
+		assert.Contains(t, commands[3], "-d eagerzeroedthick '/vmfs/volumes/bulk-ds/k8s-0/k8s-0.vmdk'")
+
+		config.DiskController = DiskControllerPVSCSI
+		assert.EqualError(t, client.CreateK8sVM(config), "k8s node k8s-0 uses NVMe controllers; disk controller pvscsi is not supported")
+	})
+
 	t.Run("fails on command error", func(t *testing.T) {
 		sshCombinedOutputFn = func(_ string, args ...string) ([]byte, error) {
 			command := args[len(args)-1]
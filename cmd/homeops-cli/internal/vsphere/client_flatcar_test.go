@@ -37,6 +37,18 @@ func TestBuildExtraConfigGuestinfo(t *testing.T) {
 		assert.False(t, hasEnc)
 		assert.Equal(t, "45", m["monitor.phys_bits_used"]) // existing behavior preserved
 	})
+
+	t.Run("appends operator keys and lets them override built-in ones", func(t *testing.T) {
+		opts := buildExtraConfig(VMConfig{ExtraConfig: map[string]string{"guestinfo.role": "worker", "disk.EnableUUID": "FALSE", "a.first": "1"}})
+		keys := make([]string, len(opts))
+		for i, o := range opts {
+			keys[i] = o.GetOptionValue().Key
+		}
+		assert.Equal(t, []string{"disk.EnableUUID", "a.first", "guestinfo.role"}, keys, "no key appears twice")
+		m := extraConfigMap(opts)
+		assert.Equal(t, "FALSE", m["disk.EnableUUID"])
+		assert.Equal(t, "worker", m["guestinfo.role"])
+	})
 }
 
 func TestBuildFlatcarCloneSpec(t *testing.T) {
@@ -37,18 +37,37 @@ type powerOnResult struct {
 type fakeDatastoreUploader struct {
 	uploads []uploadCall
 	err     error
+	files   map[string]int64
 }
 
 type uploadCall struct {
 	local  string
 	remote string
+	param  *soap.Upload
 }
 
-func (f *fakeDatastoreUploader) UploadFile(_ context.Context, localFilePath, remoteFileName string, _ *soap.Upload) error {
-	f.uploads = append(f.uploads, uploadCall{local: localFilePath, remote: remoteFileName})
+type fakeProgressReport struct {
+	percent float32
+	detail  string
+}
+
+func (r fakeProgressReport) Percentage() float32 { return r.percent }
+func (r fakeProgressReport) Detail() string      { return r.detail }
+func (r fakeProgressReport) Error() error        { return nil }
+
+func (f *fakeDatastoreUploader) UploadFile(_ context.Context, localFilePath, remoteFileName string, param *soap.Upload) error {
+	f.uploads = append(f.uploads, uploadCall{local: localFilePath, remote: remoteFileName, param: param})
 	return f.err
 }
 
+func (f *fakeDatastoreUploader) Stat(_ context.Context, file string) (types.BaseFileInfo, error) {
+	size, ok := f.files[file]
+	if !ok {
+		return nil, object.DatastoreNoSuchFileError{}
+	}
+	return &types.FileInfo{Path: file, FileSize: size}, nil
+}
+
 func (f *fakePowerOnVM) PowerOn(context.Context) (lifecycleTask, error) {
 	if f.powerOnCalls >= len(f.powerOnResults) {
 		f.powerOnCalls++
@@ -98,6 +117,17 @@ func TestBuildInitialVMSpecAndDevices(t *testing.T) {
 	require.Len(t, spec.ExtraConfig, 2)
 	require.True(t, *spec.Flags.VvtdEnabled)
 	require.True(t, *spec.VPMCEnabled)
+	assert.Equal(t, "efi", spec.Firmware)
+	assert.False(t, *spec.BootOptions.EfiSecureBootEnabled)
+	assert.False(t, *spec.CpuHotAddEnabled)
+	assert.False(t, *spec.MemoryHotAddEnabled)
+
+	bios := config
+	bios.Firmware = FirmwareBIOS
+	bios.SecureBoot = true
+	biosSpec := buildInitialVMSpec(bios)
+	assert.Equal(t, "bios", biosSpec.Firmware)
+	assert.False(t, *biosSpec.BootOptions.EfiSecureBootEnabled, "secure boot is EFI-only")
 
 	deviceChanges := buildInitialDeviceChanges(config, datastoreRef, backing)
 	require.Len(t, deviceChanges, 7)
@@ -147,12 +177,12 @@ func TestFindNVMEControllerKeysAndDiskChanges(t *testing.T) {
 		},
 	}
 
-	nvme0, nvme1, err := findNVMEControllerKeys(devices)
+	nvme0, nvme1, err := findDiskControllerKeys(DiskControllerNVMe, devices)
 	require.NoError(t, err)
 	assert.Equal(t, int32(200), nvme0)
 	assert.Equal(t, int32(201), nvme1)
 
-	_, _, err = findNVMEControllerKeys([]types.BaseVirtualDevice{
+	_, _, err = findDiskControllerKeys("", []types.BaseVirtualDevice{
 		&types.VirtualNVMEController{
 			VirtualController: types.VirtualController{
 				VirtualDevice: types.VirtualDevice{Key: 200},
@@ -165,7 +195,7 @@ func TestFindNVMEControllerKeysAndDiskChanges(t *testing.T) {
 
 	config := VMConfig{DiskSize: 250, OpenEBSSize: 800}
 	datastoreRef := types.ManagedObjectReference{Type: "Datastore", Value: "ds-1"}
-	changes := buildDiskDeviceChanges(config, datastoreRef, 200, 201)
+	changes := buildDiskDeviceChanges(config, datastoreRef, datastoreRef, 200, 201)
 	require.Len(t, changes, 2)
 
 	bootSpec := changes[0].(*types.VirtualDeviceConfigSpec)
@@ -175,7 +205,7 @@ func TestFindNVMEControllerKeysAndDiskChanges(t *testing.T) {
 	assert.Equal(t, types.VirtualDeviceConfigSpecFileOperationCreate, bootSpec.FileOperation)
 
 	config.OpenEBSSize = 0
-	changes = buildDiskDeviceChanges(config, datastoreRef, 200, 201)
+	changes = buildDiskDeviceChanges(config, datastoreRef, datastoreRef, 200, 201)
 	require.Len(t, changes, 1)
 }
 
@@ -559,8 +589,8 @@ func TestClientFinderAndUploadHelpers(t *testing.T) {
 		return datastore, nil
 	}
 	statFileFn = os.Stat
-	uploadDatastoreFileFn = func(datastore datastoreUploader, ctx context.Context, localFilePath, remoteFileName string) error {
-		return datastore.UploadFile(ctx, localFilePath, remoteFileName, nil)
+	uploadDatastoreFileFn = func(datastore datastoreUploader, ctx context.Context, localFilePath, remoteFileName string, param *soap.Upload) error {
+		return datastore.UploadFile(ctx, localFilePath, remoteFileName, param)
 	}
 
 	err = client.UploadISOToDatastore(localISO, "datastore1", "talos.iso")
@@ -568,6 +598,18 @@ func TestClientFinderAndUploadHelpers(t *testing.T) {
 	require.Len(t, datastore.uploads, 1)
 	assert.Equal(t, localISO, datastore.uploads[0].local)
 	assert.Equal(t, "talos.iso", datastore.uploads[0].remote)
+	assert.Nil(t, datastore.uploads[0].param)
+
+	reports := make(chan string, 1)
+	client.SetUploadProgress(func(percent float32, rate string) { reports <- fmt.Sprintf("%.0f%% at %s", percent, rate) })
+	require.NoError(t, client.UploadISOToDatastore(localISO, "datastore1", "talos.iso"))
+	require.NotNil(t, datastore.uploads[1].param)
+	sink := datastore.uploads[1].param.Progress.Sink()
+	sink <- fakeProgressReport{percent: 60, detail: "4.1MiB/s"}
+	close(sink)
+	assert.Equal(t, "60% at 4.1MiB/s", <-reports)
+	client.SetUploadProgress(nil)
+	datastore.uploads = nil
 
 	findDatastoreFn = func(*find.Finder, context.Context, string) (datastoreUploader, error) {
 		return nil, errors.New("missing datastore")
@@ -579,13 +621,21 @@ func TestClientFinderAndUploadHelpers(t *testing.T) {
 	findDatastoreFn = func(*find.Finder, context.Context, string) (datastoreUploader, error) {
 		return datastore, nil
 	}
+	datastore.files = map[string]int64{"talos.iso": 1 << 20}
+	size, err := client.DatastoreFileSize("datastore1", "talos.iso")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1<<20), size)
+	size, err = client.DatastoreFileSize("datastore1", "missing.iso")
+	require.NoError(t, err)
+	assert.Zero(t, size)
+
 	statFileFn = func(string) (os.FileInfo, error) { return nil, errors.New("stat failure") }
 	err = client.UploadISOToDatastore(localISO, "datastore1", "talos.iso")
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to get file info")
 
 	statFileFn = os.Stat
-	uploadDatastoreFileFn = func(datastore datastoreUploader, ctx context.Context, localFilePath, remoteFileName string) error {
+	uploadDatastoreFileFn = func(datastore datastoreUploader, ctx context.Context, localFilePath, remoteFileName string, param *soap.Upload) error {
 		return errors.New("upload failure")
 	}
 	err = client.UploadISOToDatastore(localISO, "datastore1", "talos.iso")
@@ -0,0 +1,267 @@
+package vsphere
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// resolvePlacement finds the resource pool and host a new VM is created in.
+// A host without a resource pool uses the root pool of the host's cluster or
+// standalone compute resource; a nil host lets the pool (or DRS) pick.
+func (c *Client) resolvePlacement(config VMConfig) (*object.ResourcePool, *object.HostSystem, error) {
+	var host *object.HostSystem
+	if config.Host != "" {
+		var err error
+		if host, err = c.findHost(config.Host); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	switch {
+	case config.ResourcePool != "":
+		pool, err := c.finder.ResourcePool(c.ctx, config.ResourcePool)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to find resource pool %s: %w", config.ResourcePool, err)
+		}
+		return pool, host, nil
+	case host != nil:
+		pool, err := host.ResourcePool(c.ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to find resource pool of host %s: %w", config.Host, err)
+		}
+		return pool, host, nil
+	default:
+		pool, err := c.finder.DefaultResourcePool(c.ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to find resource pool: %w", err)
+		}
+		return pool, nil, nil
+	}
+}
+
+// findHost finds a host by inventory path, or by its bare name inside a
+// cluster.
+func (c *Client) findHost(name string) (*object.HostSystem, error) {
+	host, err := c.finder.HostSystem(c.ctx, name)
+	if _, notFound := err.(*find.NotFoundError); notFound && !strings.Contains(name, "/") {
+		host, err = c.finder.HostSystem(c.ctx, "*/"+name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find host %s: %w", name, err)
+	}
+	return host, nil
+}
+
+// resolveVMFolder finds the folder a new VM is created in: the datacenter's
+// VM folder, or a folder path relative to it.
+func (c *Client) resolveVMFolder(name string) (*object.Folder, error) {
+	folders, err := c.datacenter.Folders(c.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get folders: %w", err)
+	}
+	if name == "" {
+		return folders.VmFolder, nil
+	}
+	folderPath := name
+	if !strings.HasPrefix(name, "/") {
+		folderPath = path.Join(folders.VmFolder.InventoryPath, name)
+	}
+	folder, err := c.finder.Folder(c.ctx, folderPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find VM folder %s: %w", name, err)
+	}
+	return folder, nil
+}
+
+// SpreadAcrossHosts pins each config to a host, round-robin over the
+// connected hosts outside maintenance mode, so a batch of VMs lands on
+// distinct hosts. The hosts are those of the configs' resource pool, or of the
+// whole datacenter. With a single host the configs are returned unchanged,
+// and with fewer hosts than VMs some hosts get more than one.
+func (c *Client) SpreadAcrossHosts(configs []VMConfig) ([]VMConfig, error) {
+	if len(configs) == 0 {
+		return configs, nil
+	}
+	hosts, err := c.placementHosts(configs[0].ResourcePool)
+	if err != nil {
+		return nil, err
+	}
+	if len(hosts) < 2 {
+		c.logger.Warn("Anti-affinity: only one usable host, so all %d VMs run on it", len(configs))
+		return configs, nil
+	}
+	if len(hosts) < len(configs) {
+		c.logger.Warn("Anti-affinity: %d VMs across %d hosts; some hosts run more than one", len(configs), len(hosts))
+	}
+
+	spread := make([]VMConfig, len(configs))
+	for i, config := range configs {
+		config.Host = hosts[i%len(hosts)]
+		c.logger.Info("Anti-affinity: %s → %s", config.Name, config.Host)
+		spread[i] = config
+	}
+	return spread, nil
+}
+
+// placementHosts lists the inventory paths of the usable hosts behind
+// poolPath, or in the datacenter when it is empty, in name order.
+func (c *Client) placementHosts(poolPath string) ([]string, error) {
+	var candidates []*object.HostSystem
+	if poolPath != "" {
+		pool, err := c.finder.ResourcePool(c.ctx, poolPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find resource pool %s: %w", poolPath, err)
+		}
+		owner, err := pool.Owner(c.ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the owner of resource pool %s: %w", poolPath, err)
+		}
+		var compute mo.ComputeResource
+		if err := property.DefaultCollector(c.vim).RetrieveOne(c.ctx, owner.Reference(), []string{"host"}, &compute); err != nil {
+			return nil, fmt.Errorf("failed to list the hosts of resource pool %s: %w", poolPath, err)
+		}
+		for _, ref := range compute.Host {
+			candidates = append(candidates, object.NewHostSystem(c.vim, ref))
+		}
+	} else {
+		var err error
+		if candidates, err = c.finder.HostSystemList(c.ctx, "*"); err != nil {
+			return nil, fmt.Errorf("failed to list hosts: %w", err)
+		}
+	}
+
+	refs := make([]types.ManagedObjectReference, len(candidates))
+	for i, host := range candidates {
+		refs[i] = host.Reference()
+	}
+	var props []mo.HostSystem
+	if err := property.DefaultCollector(c.vim).Retrieve(c.ctx, refs, []string{"name", "runtime"}, &props); err != nil {
+		return nil, fmt.Errorf("failed to read host state: %w", err)
+	}
+	paths := make(map[types.ManagedObjectReference]string, len(candidates))
+	for _, host := range candidates {
+		paths[host.Reference()] = host.InventoryPath
+	}
+
+	var hosts []string
+	for _, host := range props {
+		if host.Runtime.ConnectionState != types.HostSystemConnectionStateConnected || host.Runtime.InMaintenanceMode {
+			c.logger.Debug("Anti-affinity: skipping host %s (%s, maintenance mode %t)", host.Name, host.Runtime.ConnectionState, host.Runtime.InMaintenanceMode)
+			continue
+		}
+		// Hosts read through the resource pool carry no inventory path; their
+		// name finds them just as well.
+		if p := paths[host.Reference()]; p != "" {
+			hosts = append(hosts, p)
+		} else {
+			hosts = append(hosts, host.Name)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts, nil
+}
+
+// AddAntiAffinityRule asks DRS to keep the named VMs on separate hosts with a
+// VM anti-affinity rule on their cluster. Without vCenter, or when the VMs do
+// not share a cluster, there is no DRS to enforce it and the rule is skipped
+// with a warning. An existing rule of the same name gains the VMs.
+func (c *Client) AddAntiAffinityRule(ruleName string, vmNames []string) error {
+	if !c.vim.IsVC() {
+		c.logger.Warn("Anti-affinity: no vCenter, so no DRS rule; the VMs stay on the hosts they were placed on")
+		return nil
+	}
+
+	var cluster *object.ClusterComputeResource
+	refs := make([]types.ManagedObjectReference, 0, len(vmNames))
+	for _, name := range vmNames {
+		vm, err := c.finder.VirtualMachine(c.ctx, name)
+		if err != nil {
+			return fmt.Errorf("failed to find VM %s: %w", name, err)
+		}
+		vmCluster, err := c.vmCluster(vm)
+		if err != nil {
+			return fmt.Errorf("failed to find the cluster of VM %s: %w", name, err)
+		}
+		if vmCluster == nil || (cluster != nil && vmCluster.Reference() != cluster.Reference()) {
+			c.logger.Warn("Anti-affinity: the VMs do not share one DRS cluster, so no rule is created")
+			return nil
+		}
+		cluster = vmCluster
+		refs = append(refs, vm.Reference())
+	}
+	if cluster == nil {
+		return nil
+	}
+
+	rule := types.ClusterRuleSpec{
+		ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationAdd},
+		Info: &types.ClusterAntiAffinityRuleSpec{
+			ClusterRuleInfo: types.ClusterRuleInfo{Name: ruleName, Enabled: types.NewBool(true)},
+			Vm:              refs,
+		},
+	}
+	var props mo.ClusterComputeResource
+	if err := cluster.Properties(c.ctx, cluster.Reference(), []string{"configurationEx"}, &props); err != nil {
+		return fmt.Errorf("failed to read cluster rules: %w", err)
+	}
+	if info, ok := props.ConfigurationEx.(*types.ClusterConfigInfoEx); ok {
+		for _, existing := range info.Rule {
+			existing, ok := existing.(*types.ClusterAntiAffinityRuleSpec)
+			if !ok || existing.Name != ruleName {
+				continue
+			}
+			// A later batch of the same base name joins the rule.
+			merged := *existing
+			merged.Vm = mergeVMRefs(existing.Vm, refs)
+			rule = types.ClusterRuleSpec{ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationEdit}, Info: &merged}
+			break
+		}
+	}
+
+	task, err := cluster.Reconfigure(c.ctx, &types.ClusterConfigSpecEx{RulesSpec: []types.ClusterRuleSpec{rule}}, true)
+	if err != nil {
+		return fmt.Errorf("failed to add anti-affinity rule %s: %w", ruleName, err)
+	}
+	if err := task.Wait(c.ctx); err != nil {
+		return fmt.Errorf("failed to add anti-affinity rule %s: %w", ruleName, err)
+	}
+	c.logger.Success("DRS anti-affinity rule %s keeps %s on separate hosts", ruleName, strings.Join(vmNames, ", "))
+	return nil
+}
+
+func mergeVMRefs(existing, added []types.ManagedObjectReference) []types.ManagedObjectReference {
+	merged := append([]types.ManagedObjectReference(nil), existing...)
+	for _, ref := range added {
+		if !slices.Contains(merged, ref) {
+			merged = append(merged, ref)
+		}
+	}
+	return merged
+}
+
+// vmCluster is the cluster a VM's resource pool belongs to; nil for a
+// standalone host.
+func (c *Client) vmCluster(vm *object.VirtualMachine) (*object.ClusterComputeResource, error) {
+	var props mo.VirtualMachine
+	if err := vm.Properties(c.ctx, vm.Reference(), []string{"resourcePool"}, &props); err != nil {
+		return nil, err
+	}
+	if props.ResourcePool == nil {
+		return nil, nil
+	}
+	owner, err := object.NewResourcePool(c.vim, *props.ResourcePool).Owner(c.ctx)
+	if err != nil {
+		return nil, err
+	}
+	cluster, _ := owner.(*object.ClusterComputeResource)
+	return cluster, nil
+}
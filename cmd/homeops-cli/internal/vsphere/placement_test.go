@@ -0,0 +1,143 @@
+package vsphere
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"homeops-cli/internal/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// withSimulatedVCenter runs fn against a vcsim vCenter with one cluster
+// (DC0_C0) of three hosts sharing LocalDS_0 and the "VM Network" portgroup.
+func withSimulatedVCenter(t *testing.T, fn func(client *Client)) {
+	t.Helper()
+	originalSleep := vsphereSleep
+	t.Cleanup(func() { vsphereSleep = originalSleep })
+	vsphereSleep = func(time.Duration) {}
+
+	model := simulator.VPX()
+	model.Host = 0
+	model.ClusterHost = 3
+	model.Machine = 0
+	model.Run(func(ctx context.Context, vim *vim25.Client) error {
+		finder := find.NewFinder(vim, true)
+		datacenter, err := finder.DefaultDatacenter(ctx)
+		require.NoError(t, err)
+		finder.SetDatacenter(datacenter)
+		fn(&Client{vim: vim, finder: finder, datacenter: datacenter, logger: common.NewColorLogger(), ctx: ctx})
+		return nil
+	})
+}
+
+func vmHostName(t *testing.T, client *Client, vm *object.VirtualMachine) string {
+	t.Helper()
+	var props mo.VirtualMachine
+	require.NoError(t, vm.Properties(client.ctx, vm.Reference(), []string{"runtime.host"}, &props))
+	name, err := object.NewHostSystem(client.vim, *props.Runtime.Host).ObjectName(client.ctx)
+	require.NoError(t, err)
+	return name
+}
+
+func TestSpreadAcrossHostsPlacesOneVMPerHost(t *testing.T) {
+	withSimulatedVCenter(t, func(client *Client) {
+		names := []string{"k8s-0", "k8s-1", "k8s-2"}
+		configs := make([]VMConfig, len(names))
+		for i, name := range names {
+			configs[i] = simulatedVMConfig(name)
+		}
+		spread, err := client.SpreadAcrossHosts(configs)
+		require.NoError(t, err)
+		assert.Empty(t, configs[0].Host, "the caller's configs are left alone")
+
+		hosts := make(map[string]bool)
+		for _, config := range spread {
+			hosts[vmHostName(t, client, createSimulatedVM(t, client, config))] = true
+		}
+		assert.Equal(t, map[string]bool{"DC0_C0_H0": true, "DC0_C0_H1": true, "DC0_C0_H2": true}, hosts)
+
+		require.NoError(t, client.AddAntiAffinityRule("homeops-k8s-anti-affinity", names[:2]))
+		// A later batch joins the rule rather than adding a second one.
+		require.NoError(t, client.AddAntiAffinityRule("homeops-k8s-anti-affinity", names[1:]))
+		cluster, err := client.finder.ClusterComputeResource(client.ctx, "DC0_C0")
+		require.NoError(t, err)
+		var props mo.ClusterComputeResource
+		require.NoError(t, cluster.Properties(client.ctx, cluster.Reference(), []string{"configurationEx"}, &props))
+		rules := props.ConfigurationEx.(*types.ClusterConfigInfoEx).Rule
+		require.Len(t, rules, 1)
+		rule := rules[0].(*types.ClusterAntiAffinityRuleSpec)
+		assert.Equal(t, "homeops-k8s-anti-affinity", rule.Name)
+		assert.Len(t, rule.Vm, 3)
+	})
+}
+
+func TestSpreadAcrossHostsWithFewerHostsThanVMs(t *testing.T) {
+	withSimulatedVCenter(t, func(client *Client) {
+		configs := make([]VMConfig, 4)
+		for i := range configs {
+			configs[i] = simulatedVMConfig("k8s")
+		}
+		spread, err := client.SpreadAcrossHosts(configs)
+		require.NoError(t, err)
+		assert.Equal(t, spread[0].Host, spread[3].Host, "the fourth VM shares the first host")
+		assert.NotEqual(t, spread[0].Host, spread[1].Host)
+	})
+}
+
+func TestSpreadAcrossHostsOnStandaloneESXi(t *testing.T) {
+	withSimulatedESXi(t, func(client *Client) {
+		configs := []VMConfig{simulatedVMConfig("k8s-0"), simulatedVMConfig("k8s-1")}
+		spread, err := client.SpreadAcrossHosts(configs)
+		require.NoError(t, err)
+		assert.Equal(t, configs, spread, "a single host leaves placement to the default pool")
+
+		createSimulatedVM(t, client, spread[0])
+		createSimulatedVM(t, client, spread[1])
+		assert.NoError(t, client.AddAntiAffinityRule("homeops-k8s-anti-affinity", []string{"k8s-0", "k8s-1"}), "no vCenter skips the rule")
+	})
+}
+
+func TestCreateVMHonoursHostAndFolder(t *testing.T) {
+	withSimulatedVCenter(t, func(client *Client) {
+		folders, err := client.datacenter.Folders(client.ctx)
+		require.NoError(t, err)
+		_, err = folders.VmFolder.CreateFolder(client.ctx, "k8s")
+		require.NoError(t, err)
+
+		config := simulatedVMConfig("k8s-0")
+		config.Host = "DC0_C0_H1"
+		config.Folder = "k8s"
+		vm := createSimulatedVM(t, client, config)
+		assert.Equal(t, "DC0_C0_H1", vmHostName(t, client, vm))
+		found, err := client.finder.VirtualMachine(client.ctx, "/DC0/vm/k8s/k8s-0")
+		require.NoError(t, err)
+		assert.Equal(t, vm.Reference(), found.Reference())
+
+		config = simulatedVMConfig("k8s-1")
+		config.ResourcePool = "DC0_C0/Resources"
+		createSimulatedVM(t, client, config)
+
+		for _, tc := range []struct {
+			mutate func(*VMConfig)
+			want   string
+		}{
+			{func(c *VMConfig) { c.Host = "esxi-99" }, "failed to find host esxi-99"},
+			{func(c *VMConfig) { c.ResourcePool = "nope" }, "failed to find resource pool nope"},
+			{func(c *VMConfig) { c.Folder = "missing" }, "failed to find VM folder missing"},
+		} {
+			config := simulatedVMConfig("k8s-x")
+			tc.mutate(&config)
+			_, err := client.resolveCreateVMInventory(config)
+			assert.ErrorContains(t, err, tc.want)
+		}
+	})
+}
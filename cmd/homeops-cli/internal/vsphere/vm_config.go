@@ -2,6 +2,7 @@ package vsphere
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -31,6 +32,11 @@ type VMConfig struct {
 	ISODatastore     string // Datastore where ISO is stored (e.g., "datastore1")
 	MacAddress       string // Static MAC address for network (SR-IOV or vmxnet3)
 
+	// Placement (default: the datacenter's default resource pool and VM folder)
+	Host         string // ESXi host to run on, by name or inventory path (e.g., "esxi-02.local")
+	ResourcePool string // Resource pool path (e.g., "cluster/Resources/k8s")
+	Folder       string // VM folder, relative to the datacenter's VM folder (e.g., "k8s")
+
 	// Legacy OSD-disk RDM configuration retained for nodes[].vm.ceph compatibility.
 	// These physical SSD mappings are not used when the compatibility mode is none.
 	RDMPath string // Path to RDM descriptor (e.g., "[datastore1] rdm/intel-ssd-1.vmdk")
@@ -46,12 +52,22 @@ type VMConfig struct {
 	CoresPerSocket int    // Cores per socket (default: 1)
 
 	// Deployment options
-	PowerOn              bool // Power on VM after creation
-	EnableIOMMU          bool // Enable IOMMU/VT-d for VM
-	ExposeCounters       bool // Expose CPU performance counters
-	ThinProvisioned      bool // Use thin provisioned disks (default: true)
-	EnablePrecisionClock bool // Add precision clock device (default: true)
-	EnableWatchdog       bool // Add watchdog timer device (default: true)
+	PowerOn              bool             // Power on VM after creation
+	EnableIOMMU          bool             // Enable IOMMU/VT-d for VM
+	ExposeCounters       bool             // Expose CPU performance counters
+	DiskProvisioning     DiskProvisioning // Backing for both disks (default: thin)
+	BootProvisioning     DiskProvisioning // Boot disk backing (default: DiskProvisioning)
+	OpenEBSProvisioning  DiskProvisioning // OpenEBS disk backing (default: DiskProvisioning)
+	DiskController       DiskController   // Controller type both disks attach to (default: nvme)
+	EnablePrecisionClock bool             // Add precision clock device (default: true)
+	EnableWatchdog       bool             // Add watchdog timer device (default: true)
+
+	// VM options
+	Firmware     Firmware          // Boot firmware (default: efi)
+	SecureBoot   bool              // UEFI secure boot (EFI firmware only)
+	CPUHotAdd    bool              // Allow adding vCPUs while powered on
+	MemoryHotAdd bool              // Allow adding memory while powered on
+	ExtraConfig  map[string]string // Additional .vmx keys; a key the CLI sets itself takes this value
 
 	// Talos specific
 	SchematicID  string // Optional: Talos factory schematic ID
@@ -66,6 +82,243 @@ type VMConfig struct {
 	TemplateName string // name of the imported Flatcar OVA template to clone
 }
 
+// DiskProvisioning is the VMDK allocation mode for a VM's virtual disks.
+type DiskProvisioning string
+
+const (
+	DiskProvisioningThin             DiskProvisioning = "thin"
+	DiskProvisioningThick            DiskProvisioning = "thick"            // lazy-zeroed thick
+	DiskProvisioningEagerZeroedThick DiskProvisioning = "eagerZeroedThick" // zeroed at creation; required for FT/clustering
+)
+
+// DiskProvisioningModes lists the accepted --disk-provisioning values.
+var DiskProvisioningModes = []DiskProvisioning{DiskProvisioningThin, DiskProvisioningThick, DiskProvisioningEagerZeroedThick}
+
+// ParseDiskProvisioning validates a provisioning mode name (case-insensitive).
+// An empty value selects thin provisioning.
+func ParseDiskProvisioning(value string) (DiskProvisioning, error) {
+	if value == "" {
+		return DiskProvisioningThin, nil
+	}
+	for _, mode := range DiskProvisioningModes {
+		if strings.EqualFold(value, string(mode)) {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("invalid disk provisioning %q (valid: thin, thick, eagerZeroedThick)", value)
+}
+
+// ParseDiskProvisioningOption parses a --disk-provisioning value: one mode for
+// every disk ("thin"), or comma-separated disk=mode pairs
+// ("boot=thin,openebs=eagerZeroedThick") with an optional bare default among
+// them. The result maps "boot", "openebs", and "" (every disk not named) to a
+// mode.
+func ParseDiskProvisioningOption(value string) (map[string]DiskProvisioning, error) {
+	modes := map[string]DiskProvisioning{}
+	for _, pair := range strings.Split(value, ",") {
+		name, mode, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			name, mode = "", name
+		}
+		name = strings.ToLower(name)
+		if name != "" && name != "boot" && name != "openebs" {
+			return nil, fmt.Errorf("invalid --disk-provisioning %q: the VM has no disk %q (disks: boot, openebs)", value, name)
+		}
+		provisioning, err := ParseDiskProvisioning(mode)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := modes[name]; dup {
+			if name == "" {
+				return nil, fmt.Errorf("invalid --disk-provisioning %q: more than one mode for every disk", value)
+			}
+			return nil, fmt.Errorf("invalid --disk-provisioning %q: disk %s is given twice", value, name)
+		}
+		modes[name] = provisioning
+	}
+	return modes, nil
+}
+
+func (p DiskProvisioning) orDefault() DiskProvisioning {
+	if p == "" {
+		return DiskProvisioningThin
+	}
+	return p
+}
+
+// backing returns the VirtualDiskFlatVer2BackingInfo thin/eagerlyScrub pair.
+func (p DiskProvisioning) backing() (thin, eagerlyScrub bool) {
+	switch p.orDefault() {
+	case DiskProvisioningThick:
+		return false, false
+	case DiskProvisioningEagerZeroedThick:
+		return false, true
+	default:
+		return true, false
+	}
+}
+
+// vmkfstoolsFormat returns the vmkfstools -d disk format for the mode.
+func (p DiskProvisioning) vmkfstoolsFormat() string {
+	switch p.orDefault() {
+	case DiskProvisioningThick:
+		return "zeroedthick"
+	case DiskProvisioningEagerZeroedThick:
+		return "eagerzeroedthick"
+	default:
+		return "thin"
+	}
+}
+
+// Firmware is a VM's boot firmware.
+type Firmware string
+
+const (
+	FirmwareEFI  Firmware = "efi"
+	FirmwareBIOS Firmware = "bios"
+)
+
+// ParseFirmware validates a firmware name (case-insensitive). An empty value
+// selects EFI.
+func ParseFirmware(value string) (Firmware, error) {
+	switch {
+	case value == "" || strings.EqualFold(value, string(FirmwareEFI)):
+		return FirmwareEFI, nil
+	case strings.EqualFold(value, string(FirmwareBIOS)):
+		return FirmwareBIOS, nil
+	}
+	return "", fmt.Errorf("invalid firmware %q (valid: efi, bios)", value)
+}
+
+func (f Firmware) orDefault() Firmware {
+	if f == "" {
+		return FirmwareEFI
+	}
+	return f
+}
+
+// extraConfigKeyPattern matches a .vmx key such as "guestinfo.foo" or
+// "sched.nvme0:0.shares".
+var extraConfigKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.:-]*$`)
+
+// ParseExtraConfig parses --extra-config values, each key=value. A key may be
+// given once.
+func ParseExtraConfig(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	extraConfig := make(map[string]string, len(values))
+	for _, value := range values {
+		key, val, ok := strings.Cut(value, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !extraConfigKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("invalid --extra-config %q: want key=value with a .vmx key such as guestinfo.role", value)
+		}
+		if _, dup := extraConfig[key]; dup {
+			return nil, fmt.Errorf("invalid --extra-config %q: key %s is given twice", value, key)
+		}
+		extraConfig[key] = val
+	}
+	return extraConfig, nil
+}
+
+// DiskController is the virtual storage controller type a VM's disks attach
+// to. Each disk gets a controller of its own: the boot disk on bus 0, the
+// OpenEBS disk on bus 1.
+type DiskController string
+
+const (
+	DiskControllerNVMe     DiskController = "nvme"
+	DiskControllerPVSCSI   DiskController = "pvscsi"   // VMware Paravirtual SCSI
+	DiskControllerLSILogic DiskController = "lsilogic" // LSI Logic Parallel SCSI
+)
+
+// DiskControllers lists the accepted --disk-controller values.
+var DiskControllers = []DiskController{DiskControllerNVMe, DiskControllerPVSCSI, DiskControllerLSILogic}
+
+// ParseDiskController validates a controller type name (case-insensitive).
+// An empty value selects NVMe.
+func ParseDiskController(value string) (DiskController, error) {
+	if value == "" {
+		return DiskControllerNVMe, nil
+	}
+	for _, controller := range DiskControllers {
+		if strings.EqualFold(value, string(controller)) {
+			return controller, nil
+		}
+	}
+	return "", fmt.Errorf("invalid disk controller %q (valid: nvme, pvscsi, lsilogic)", value)
+}
+
+func (c DiskController) orDefault() DiskController {
+	if c == "" {
+		return DiskControllerNVMe
+	}
+	return c
+}
+
+// TalosInstallDisk is the device Talos names the boot disk (bus 0, unit 0)
+// behind the controller type.
+func (c DiskController) TalosInstallDisk() string {
+	if c.orDefault() == DiskControllerNVMe {
+		return "/dev/nvme0n1"
+	}
+	return "/dev/sda"
+}
+
+// DiskPlacement describes where one virtual disk lands and how it is allocated.
+type DiskPlacement struct {
+	Disk         string // "boot" or "openebs"
+	SizeGB       int
+	Datastore    string
+	Provisioning DiskProvisioning
+}
+
+// BootDiskDatastore is the datastore for the boot disk and VM home, falling
+// back to the single Datastore.
+func (c VMConfig) BootDiskDatastore() string {
+	if c.BootDatastore != "" {
+		return c.BootDatastore
+	}
+	return c.Datastore
+}
+
+// OpenEBSDiskDatastore is the datastore for the OpenEBS disk, falling back to
+// the single Datastore.
+func (c VMConfig) OpenEBSDiskDatastore() string {
+	if c.OpenEBSDatastore != "" {
+		return c.OpenEBSDatastore
+	}
+	return c.Datastore
+}
+
+// BootDiskProvisioning is the boot disk's provisioning mode, falling back to
+// DiskProvisioning.
+func (c VMConfig) BootDiskProvisioning() DiskProvisioning {
+	if c.BootProvisioning != "" {
+		return c.BootProvisioning
+	}
+	return c.DiskProvisioning.orDefault()
+}
+
+// OpenEBSDiskProvisioning is the OpenEBS disk's provisioning mode, falling
+// back to DiskProvisioning.
+func (c VMConfig) OpenEBSDiskProvisioning() DiskProvisioning {
+	if c.OpenEBSProvisioning != "" {
+		return c.OpenEBSProvisioning
+	}
+	return c.DiskProvisioning.orDefault()
+}
+
+// DiskPlacements returns the boot disk and, when sized, the OpenEBS disk.
+func (c VMConfig) DiskPlacements() []DiskPlacement {
+	placements := []DiskPlacement{{Disk: "boot", SizeGB: c.DiskSize, Datastore: c.BootDiskDatastore(), Provisioning: c.BootDiskProvisioning()}}
+	if c.OpenEBSSize > 0 {
+		placements = append(placements, DiskPlacement{Disk: "openebs", SizeGB: c.OpenEBSSize, Datastore: c.OpenEBSDiskDatastore(), Provisioning: c.OpenEBSDiskProvisioning()})
+	}
+	return placements
+}
+
 // VMDeploymentConfig represents configuration for batch VM deployment
 type VMDeploymentConfig struct {
 	// Connection details
@@ -129,14 +382,14 @@ func GetDefaultVMConfig(name string) VMConfig {
 func defaultVMConfig(name string) VMConfig {
 	return VMConfig{
 		Name:                 name,
-		PowerOn:              true, // Power on by default
-		EnableIOMMU:          true, // Enable IOMMU/VT-d
-		ExposeCounters:       true, // Expose CPU performance counters
-		ThinProvisioned:      true, // Use thin provisioned disks
-		EnablePrecisionClock: true, // Add precision clock device
-		EnableWatchdog:       true, // Add watchdog timer device
-		EnableSRIOV:          true, // Use SR-IOV by default for k8s nodes
-		MemoryPinned:         true, // Pin memory reservation
+		PowerOn:              true,                 // Power on by default
+		EnableIOMMU:          true,                 // Enable IOMMU/VT-d
+		ExposeCounters:       true,                 // Expose CPU performance counters
+		DiskProvisioning:     DiskProvisioningThin, // Use thin provisioned disks
+		EnablePrecisionClock: true,                 // Add precision clock device
+		EnableWatchdog:       true,                 // Add watchdog timer device
+		EnableSRIOV:          true,                 // Use SR-IOV by default for k8s nodes
+		MemoryPinned:         true,                 // Pin memory reservation
 	}
 }
 
@@ -256,9 +509,31 @@ func (c *VMConfig) Validate() error {
 	if c.DiskSize <= 0 {
 		return fmt.Errorf("disk size must be greater than 0")
 	}
-	if c.Datastore == "" {
+	if c.BootDiskDatastore() == "" {
 		return fmt.Errorf("datastore is required")
 	}
+	if c.OpenEBSSize > 0 && c.OpenEBSDiskDatastore() == "" {
+		return fmt.Errorf("OpenEBS datastore is required")
+	}
+	for _, provisioning := range []DiskProvisioning{c.DiskProvisioning, c.BootProvisioning, c.OpenEBSProvisioning} {
+		if _, err := ParseDiskProvisioning(string(provisioning)); err != nil {
+			return err
+		}
+	}
+	if _, err := ParseDiskController(string(c.DiskController)); err != nil {
+		return err
+	}
+	if _, err := ParseFirmware(string(c.Firmware)); err != nil {
+		return err
+	}
+	if c.SecureBoot && c.Firmware.orDefault() != FirmwareEFI {
+		return fmt.Errorf("secure boot needs EFI firmware")
+	}
+	for key := range c.ExtraConfig {
+		if !extraConfigKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid extraConfig key %q", key)
+		}
+	}
 	if c.Network == "" {
 		return fmt.Errorf("network is required")
 	}
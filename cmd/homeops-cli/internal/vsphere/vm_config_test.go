@@ -61,6 +61,113 @@ func TestVMConfigValidate(t *testing.T) {
 	bad = config
 	bad.ISO = ""
 	require.Error(t, bad.Validate())
+	bad = config
+	bad.DiskProvisioning = "sparse"
+	require.Error(t, bad.Validate())
+	bad = config
+	bad.OpenEBSProvisioning = "sparse"
+	require.Error(t, bad.Validate())
+	bad = config
+	bad.DiskController = "ide"
+	require.Error(t, bad.Validate())
+	bad = config
+	bad.Firmware = FirmwareBIOS
+	bad.SecureBoot = true
+	assert.EqualError(t, bad.Validate(), "secure boot needs EFI firmware")
+	bad = config
+	bad.ExtraConfig = map[string]string{"bad key": "x"}
+	require.Error(t, bad.Validate())
+
+	split := config
+	split.Datastore = ""
+	split.BootDatastore = "local-nvme1"
+	split.OpenEBSSize = 100
+	require.Error(t, split.Validate(), "OpenEBS disk needs a datastore")
+	split.OpenEBSDatastore = "truenas-iscsi"
+	require.NoError(t, split.Validate())
+}
+
+func TestParseDiskProvisioning(t *testing.T) {
+	for input, want := range map[string]DiskProvisioning{
+		"":                 DiskProvisioningThin,
+		"thin":             DiskProvisioningThin,
+		"THICK":            DiskProvisioningThick,
+		"eagerzeroedthick": DiskProvisioningEagerZeroedThick,
+	} {
+		got, err := ParseDiskProvisioning(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, got, input)
+	}
+	_, err := ParseDiskProvisioning("lazy")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "eagerZeroedThick")
+}
+
+func TestParseDiskProvisioningOption(t *testing.T) {
+	modes, err := ParseDiskProvisioningOption("thick")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]DiskProvisioning{"": DiskProvisioningThick}, modes)
+
+	modes, err = ParseDiskProvisioningOption("thin, OpenEBS=eagerzeroedthick")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]DiskProvisioning{"": DiskProvisioningThin, "openebs": DiskProvisioningEagerZeroedThick}, modes)
+
+	config := VMConfig{DiskSize: 40, OpenEBSSize: 100, Datastore: "ds", DiskProvisioning: DiskProvisioningThick, BootProvisioning: DiskProvisioningThin}
+	assert.Equal(t, DiskProvisioningThin, config.BootDiskProvisioning())
+	assert.Equal(t, DiskProvisioningThick, config.OpenEBSDiskProvisioning(), "the OpenEBS disk falls back to DiskProvisioning")
+
+	for value, want := range map[string]string{
+		"boot=thin,ceph=thick":   `invalid --disk-provisioning "boot=thin,ceph=thick": the VM has no disk "ceph" (disks: boot, openebs)`,
+		"boot=thin,boot=thick":   `invalid --disk-provisioning "boot=thin,boot=thick": disk boot is given twice`,
+		"thin,thick":             `invalid --disk-provisioning "thin,thick": more than one mode for every disk`,
+		"openebs=eagerZeroedFat": `invalid disk provisioning "eagerZeroedFat" (valid: thin, thick, eagerZeroedThick)`,
+	} {
+		_, err := ParseDiskProvisioningOption(value)
+		assert.EqualError(t, err, want, value)
+	}
+}
+
+func TestParseFirmware(t *testing.T) {
+	for input, want := range map[string]Firmware{"": FirmwareEFI, "EFI": FirmwareEFI, "bios": FirmwareBIOS} {
+		got, err := ParseFirmware(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, got, input)
+	}
+	_, err := ParseFirmware("uefi")
+	assert.EqualError(t, err, `invalid firmware "uefi" (valid: efi, bios)`)
+}
+
+func TestParseExtraConfig(t *testing.T) {
+	extraConfig, err := ParseExtraConfig([]string{"guestinfo.role=worker", "sched.nvme0:0.shares=high", "svga.present="})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"guestinfo.role": "worker", "sched.nvme0:0.shares": "high", "svga.present": ""}, extraConfig)
+
+	for value, want := range map[string]string{
+		"guestinfo.role":     `invalid --extra-config "guestinfo.role": want key=value with a .vmx key such as guestinfo.role`,
+		"bad key=1":          `invalid --extra-config "bad key=1": want key=value with a .vmx key such as guestinfo.role`,
+		"guestinfo.role=api": `invalid --extra-config "guestinfo.role=api": key guestinfo.role is given twice`,
+	} {
+		_, err := ParseExtraConfig([]string{"guestinfo.role=worker", value})
+		assert.EqualError(t, err, want, value)
+	}
+}
+
+func TestParseDiskController(t *testing.T) {
+	for input, want := range map[string]DiskController{
+		"":         DiskControllerNVMe,
+		"NVMe":     DiskControllerNVMe,
+		"pvscsi":   DiskControllerPVSCSI,
+		"LSILogic": DiskControllerLSILogic,
+	} {
+		got, err := ParseDiskController(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, got, input)
+	}
+	_, err := ParseDiskController("ide")
+	assert.EqualError(t, err, `invalid disk controller "ide" (valid: nvme, pvscsi, lsilogic)`)
+
+	assert.Equal(t, "/dev/nvme0n1", DiskController("").TalosInstallDisk())
+	assert.Equal(t, "/dev/sda", DiskControllerPVSCSI.TalosInstallDisk())
 }
 
 func TestVSphereClientHelpers(t *testing.T) {
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -10,22 +11,32 @@ import (
 	"runtime/debug"
 	"strings"
 	"syscall"
+	"time"
 
+	auditcmd "homeops-cli/cmd/audit"
 	"homeops-cli/cmd/bootstrap"
+	bundlecmd "homeops-cli/cmd/bundle"
 	"homeops-cli/cmd/cluster"
 	"homeops-cli/cmd/completion"
 	configcmd "homeops-cli/cmd/config"
 	"homeops-cli/cmd/flatcar"
 	"homeops-cli/cmd/kubernetes"
 	opvault "homeops-cli/cmd/opvault"
+	"homeops-cli/cmd/plugins"
 	"homeops-cli/cmd/talos"
 	"homeops-cli/cmd/vm"
 	"homeops-cli/cmd/volsync"
 	"homeops-cli/cmd/workstation"
+	"homeops-cli/internal/audit"
+	"homeops-cli/internal/cmdutil"
 	"homeops-cli/internal/common"
 	"homeops-cli/internal/config"
 	"homeops-cli/internal/constants"
+	"homeops-cli/internal/metrics"
+	"homeops-cli/internal/secrets"
 	"homeops-cli/internal/ui"
+	"homeops-cli/internal/versioncheck"
+	"homeops-cli/internal/vmlifecycle"
 
 	"charm.land/fang/v2"
 	"github.com/spf13/cobra"
@@ -37,8 +48,16 @@ var (
 	commit         = "none"
 	date           = "unknown"
 	logLevel       string
+	logFormat      string
 	assumeYes      bool
+	strictVersions bool
 	configPath     string
+	rootDir        string
+	noAudit        bool
+	forceNotify    bool
+	noNotify       bool
+	noSecretCache  bool
+	secretBackend  string
 	chooseFn       = ui.Choose
 	signalNotifyFn = signal.Notify
 	// executeRootCmdFn runs the root command through fang, which provides
@@ -47,11 +66,23 @@ var (
 	executeRootCmdFn = func(cmd *cobra.Command) error {
 		return fang.Execute(cmd.Context(), cmd,
 			fang.WithVersion(fmt.Sprintf("%s (commit: %s, built: %s)", version, commit, date)),
+			fang.WithErrorHandler(redactingErrorHandler),
 		)
 	}
-	stderrWriter io.Writer = os.Stderr
+	stderrWriter  io.Writer = os.Stderr
+	auditRecordFn           = audit.Record
+	notifyFn                = func(ctx context.Context, n common.Notifier, note common.Notification) { n.Notify(ctx, note) }
+	// invocation is the command cobra resolved, captured in PersistentPreRunE
+	// so runApp can audit it once it has finished.
+	invocation *auditedInvocation
 )
 
+type auditedInvocation struct {
+	cmd     *cobra.Command
+	args    []string
+	started time.Time
+}
+
 func main() {
 	sigChan := make(chan os.Signal, 1)
 	if code := runApp(sigChan); code != 0 {
@@ -71,15 +102,121 @@ func runApp(sigChan chan os.Signal) int {
 		cancel()
 	}()
 
+	invocation = nil
+	metrics.Spans.Reset()
 	rootCmd := newRootCommand(ctx)
-	if err := executeRootCmdFn(rootCmd); err != nil {
-		// fang already rendered the error; just map it to an exit code.
+	err := executeRootCmdFn(rootCmd)
+	recordInvocation(err)
+	notifyInvocation(err)
+	exportInvocationMetrics()
+	if err != nil {
+		// fang already rendered the error; just map it to an exit code. A
+		// plugin's own exit status passes through unchanged.
+		var pluginExit *plugins.ExitError
+		if errors.As(err, &pluginExit) {
+			return pluginExit.Code
+		}
 		return 1
 	}
 
 	return 0
 }
 
+// recordInvocation appends the finished command to the audit log. A failure to
+// write the log is reported but never changes the command's exit status.
+func recordInvocation(runErr error) {
+	if invocation == nil || noAudit {
+		return
+	}
+	if err := auditRecordFn(invocation.cmd, invocation.args, invocation.started, runErr); err != nil {
+		printWarning("audit log not written: %v", err)
+	}
+}
+
+// notifiedCommands are the long-running operations that ping on completion
+// when notify: is configured in homeops.yaml or --notify is passed.
+var notifiedCommands = map[string]bool{
+	"bootstrap":             true,
+	"talos upgrade-cluster": true,
+	"talos reset-cluster":   true,
+	"flatcar reset-cluster": true,
+	"volsync restore-all":   true,
+}
+
+// notifyInvocation reports a finished long-running operation on the
+// configured channels. --notify with nothing configured falls back to a
+// desktop notification. Dry runs and --no-notify stay quiet, and delivery
+// problems are only logged.
+func notifyInvocation(runErr error) {
+	if invocation == nil || noNotify {
+		return
+	}
+	cmd := invocation.cmd
+	operation := strings.TrimPrefix(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()), " ")
+	if !notifiedCommands[operation] || cmdutil.ReadOnly(cmd, invocation.args) {
+		return
+	}
+	cfg := config.Get()
+	settings := cfg.Notify
+	if !settings.Enabled() {
+		if !forceNotify {
+			return
+		}
+		settings.Desktop = true
+	}
+	notifier := common.Notifier{Desktop: settings.Desktop, Topic: settings.Topic}
+	if settings.Webhook {
+		url, err := cfg.ResolveSecret(config.KeyNotifyWebhookURL)
+		if err != nil {
+			printWarning("webhook notification not sent: %v", common.RedactError(err))
+		}
+		notifier.WebhookURL = url
+	}
+	note := common.Notification{Operation: operation, Status: common.NotifySucceeded, Duration: time.Since(invocation.started)}
+	if runErr != nil {
+		note.Status, note.Summary = common.NotifyFailed, runErr.Error()
+	}
+	notifyFn(context.Background(), notifier, note)
+}
+
+// exportInvocationMetrics writes the step timings the finished command
+// collected to --metrics-file and --pushgateway-url. Like the audit log, an
+// export failure is reported but never changes the exit status.
+func exportInvocationMetrics() {
+	if invocation == nil {
+		return
+	}
+	cmd := invocation.cmd
+	operation := strings.TrimPrefix(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()), " ")
+	if err := metrics.Spans.Export(metrics.DefaultExportOptions(), operation); err != nil {
+		printWarning("step metrics not exported: %v", err)
+	}
+}
+
+// redactingErrorHandler renders the final command error through fang with any
+// resolved secret values masked.
+func redactingErrorHandler(w io.Writer, styles fang.Styles, err error) {
+	var pluginExit *plugins.ExitError
+	if errors.As(err, &pluginExit) {
+		return // the plugin already wrote its own diagnostics
+	}
+	if common.JSONLogging() {
+		common.NewColorLogger().Error("%s", err.Error())
+		return
+	}
+	fang.DefaultErrorHandler(w, styles, common.RedactError(err))
+}
+
+// printWarning reports a problem that does not fail the command: a
+// "Warning:" line on stderr, or a warn message with --log-format json.
+func printWarning(format string, args ...interface{}) {
+	if common.JSONLogging() {
+		common.NewColorLogger().Warn(format, args...)
+		return
+	}
+	_, _ = fmt.Fprintf(stderrWriter, "Warning: "+format+"\n", args...)
+}
+
 func newRootCommand(ctx context.Context) *cobra.Command {
 	rootCmd := &cobra.Command{
 		Use:   "homeops-cli",
@@ -94,14 +231,33 @@ and 'homeops-cli config doctor' to validate your setup.
 
 Environment:
   HOMEOPS_CONFIG          path to the config file (same as --config)
-  HOMEOPS_NO_INTERACTIVE  set to 1 to disable interactive prompts (CI mode)`,
+  HOMEOPS_ROOT            path to the home-ops repository (same as --root-dir)
+  HOMEOPS_NO_INTERACTIVE  set to 1 to disable interactive prompts (CI mode)
+  HOMEOPS_METRICS_FILE    Prometheus textfile for step timings (same as --metrics-file)
+  HOMEOPS_PUSHGATEWAY_URL Pushgateway for step timings (same as --pushgateway-url)`,
 		Version: fmt.Sprintf("%s (commit: %s, built: %s)", version, commit, date),
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			// Set global log level from flag (if provided) before any command runs
 			if logLevel != "" {
 				common.SetGlobalLogLevel(logLevel)
 			}
+			if err := common.SetGlobalLogFormat(logFormat); err != nil {
+				return err
+			}
 			ui.SetAssumeYes(assumeYes)
+			versioncheck.SetStrict(strictVersions)
+			secrets.SetCacheEnabled(!noSecretCache)
+			if err := secrets.SetBackendOverride(secretBackend); err != nil {
+				return err
+			}
+			// Record --root-dir before config discovery, which looks in the repo.
+			if rootDir != "" {
+				common.SetRootDirOverride(rootDir)
+				if _, err := common.RepoRoot(); err != nil {
+					return err
+				}
+				setEnvironment()
+			}
 			// Record --config before any command loads the configuration.
 			if configPath != "" {
 				config.SetExplicitPath(configPath)
@@ -110,6 +266,7 @@ Environment:
 			if err := config.LoadError(); err != nil && config.IsExplicitLoadError(err) {
 				return err
 			}
+			invocation = &auditedInvocation{cmd: cmd, args: args, started: time.Now()}
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -127,15 +284,32 @@ Environment:
 
 	// Add global flags
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "", "Set log level (debug, info, warn, error)")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", common.LogFormatText, "Log format: text, or json for one JSON object per line on stderr (spinners become log lines)")
 	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "Assume yes for all confirmation prompts (non-interactive)")
-	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Path to the homeops config file (default: ./homeops.yaml, <git root>/homeops.yaml, or ~/.config/homeops/config.yaml)")
+	rootCmd.PersistentFlags().BoolVar(&strictVersions, "strict-versions", false, "Fail (instead of warn) when the local system-upgrade plan versions disagree with the cluster or environment overrides")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Path to the homeops config file (default: ./homeops.yaml, <repo root>/homeops.yaml, or ~/.config/homeops/config.yaml)")
+	rootCmd.PersistentFlags().StringVar(&rootDir, "root-dir", "", "Path to the home-ops repository for version plans, template overrides, and repo outputs (default: HOMEOPS_ROOT, else the nearest parent with .git or homeops.yaml)")
+	rootCmd.PersistentFlags().BoolVar(&noSecretCache, "no-secret-cache", false, "Read every secret reference from its backend each time instead of once per run")
+	rootCmd.PersistentFlags().StringVar(&secretBackend, "secret-backend", "", "Backend op:// references resolve through: "+strings.Join(secrets.BackendNames, ", ")+" (default: secret_backend.type in the homeops config, else 1password)")
+	rootCmd.PersistentFlags().BoolVar(&noAudit, "no-audit", false, "Do not record this invocation in the audit log")
+	rootCmd.PersistentFlags().BoolVar(&forceNotify, "notify", false, "Notify when a long-running operation finishes, even if notify: is not configured")
+	rootCmd.PersistentFlags().BoolVar(&noNotify, "no-notify", false, "Do not send the configured completion notification")
+	rootCmd.MarkFlagsMutuallyExclusive("notify", "no-notify")
+	rootCmd.PersistentFlags().StringVar(&metrics.ExportOverrides.TextfilePath, "metrics-file", "", "Write the command's step timings to this Prometheus textfile when it finishes (default: $HOMEOPS_METRICS_FILE)")
+	rootCmd.PersistentFlags().StringVar(&metrics.ExportOverrides.PushgatewayURL, "pushgateway-url", "", "Push the command's step timings to this Prometheus Pushgateway when it finishes (default: $HOMEOPS_PUSHGATEWAY_URL)")
 
 	// Set global environment variables
 	setEnvironment()
 
+	// flatcar cannot import talos (talos imports flatcar), so the shared IP
+	// preflight is wired here.
+	flatcar.NodeIPPreflightFn = talos.NodeIPPreflight
+
 	// Add subcommands
 	rootCmd.AddCommand(
+		auditcmd.NewCommand(),
 		bootstrap.NewCommand(),
+		bundlecmd.NewCommand(),
 		completion.NewCommand(),
 		cluster.NewCommand(),
 		configcmd.NewCommand(),
@@ -144,11 +318,15 @@ Environment:
 		talos.NewCommand(),
 		vm.NewVMCommand(),
 		opvault.NewCommand(),
+		plugins.NewCommand(),
 		volsync.NewCommand(),
 		workstation.NewCommand(),
 		newSelfUpdateCommand(),
+		newValidateCommand(),
 		newVersionCommand(),
 	)
+	// Plugins register last so collisions with the built-ins above are caught.
+	plugins.Register(rootCmd)
 
 	// Enable completion for all commands
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
@@ -174,6 +352,13 @@ func showInteractiveMenu(rootCmd *cobra.Command) error {
 		return err
 	}
 	ui.PrintBanner(fmt.Sprintf("homeops %s — clusters, VMs, and secrets from one CLI", version))
+	// One TrueNAS login serves the whole session (list → info → start ...),
+	// closed when the menu exits or the root context is cancelled.
+	ctx := rootCmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	defer vmlifecycle.ShareTrueNASConnection(ctx, vmlifecycle.TrueNASKeepalive)()
 	for {
 		// Build the menu from the live command tree so it never drifts from the
 		// registered subcommands. Skip hidden + non-interactive helpers.
@@ -436,7 +621,7 @@ func applyBuildInfo(info *debug.BuildInfo) {
 // newVersionCommand exposes the build info as `homeops-cli version` so
 // scripts don't have to parse --help output.
 func newVersionCommand() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "version",
 		Short: "Print version, commit, and build date",
 		Run: func(cmd *cobra.Command, args []string) {
@@ -444,24 +629,109 @@ func newVersionCommand() *cobra.Command {
 			fmt.Printf("homeops-cli %s\ncommit: %s\nbuilt:  %s\n", version, commit, date)
 		},
 	}
+	cmd.AddCommand(newVersionCheckCommand())
+	return cmd
+}
+
+// versionObserveFn resolves plan/cluster/override versions (swappable for tests).
+var versionObserveFn = versioncheck.Observe
+
+// newVersionCheckCommand reports the same plan-vs-cluster-vs-environment
+// comparison that prepare-iso, deploy-vm, upgrade-node, and bootstrap run
+// before consuming versions.
+func newVersionCheckCommand() *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:          "check",
+		Short:        "Compare system-upgrade plan versions with the cluster and environment overrides",
+		SilenceUsage: true,
+		Example: `  homeops-cli version check
+  homeops-cli version check --output json
+  homeops-cli version check --strict-versions`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ui.ValidateOutputFormat(output); err != nil {
+				return err
+			}
+			root, err := common.RepoRoot()
+			if err != nil {
+				return err
+			}
+			observations := versionObserveFn(cmd.Context(), root,
+				versioncheck.Kubernetes, versioncheck.Flatcar, versioncheck.Talos)
+			findings := versioncheck.Compare(observations)
+			rendered, err := renderVersionCheck(observations, findings, output)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), rendered)
+			if strictVersions && len(findings) > 0 {
+				return fmt.Errorf("%d version mismatch(es) between the local plans and the cluster/environment (--strict-versions)", len(findings))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table or json")
+	return cmd
+}
+
+func renderVersionCheck(observations []versioncheck.Observation, findings []versioncheck.Finding, output string) (string, error) {
+	if output == "json" {
+		return ui.RenderJSON(struct {
+			Observations []versioncheck.Observation `json:"observations"`
+			Findings     []versioncheck.Finding     `json:"findings"`
+		}{Observations: observations, Findings: append([]versioncheck.Finding{}, findings...)})
+	}
+	var rows [][]string
+	for _, obs := range observations {
+		status := "OK"
+		for _, finding := range findings {
+			if finding.Component == obs.Component {
+				status = "MISMATCH (" + finding.Newer + " newer)"
+				if finding.Newer == versioncheck.SideUnknown {
+					status = "MISMATCH"
+				}
+			}
+		}
+		source := obs.Path
+		if source == "" {
+			source = "built-in pin"
+		}
+		rows = append(rows, []string{string(obs.Component), valueOrDash(obs.Plan), source,
+			valueOrDash(obs.Running), valueOrDash(obs.Override), status})
+	}
+	rendered := ui.Table([]string{"COMPONENT", "PLAN", "SOURCE", "CLUSTER", "OVERRIDE", "STATUS"}, rows)
+	for _, finding := range findings {
+		rendered += "\n" + finding.Message()
+	}
+	return rendered, nil
 }
 
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// defaultedEnv records the variables setEnvironment filled in, so a later
+// --root-dir can re-derive them.
+var defaultedEnv = map[string]bool{}
+
 func setEnvironment() {
 	// Set default environment variables if not already set
 	// KUBECONFIG and TALOSCONFIG should use global environment variables
 
-	// Convert relative path to absolute for MINIJINJA_CONFIG_FILE
-	minijinjaConfig := "./.minijinja.toml"
-	if absPath, err := filepath.Abs(minijinjaConfig); err == nil {
-		minijinjaConfig = absPath
-	}
+	// MINIJINJA_CONFIG_FILE is the repository's .minijinja.toml (the current
+	// directory's outside a checkout).
+	minijinjaConfig := filepath.Join(common.GetWorkingDirectory(), ".minijinja.toml")
 
 	envDefaults := map[string]string{
 		constants.EnvMiniJinjaConfig: minijinjaConfig,
 	}
 
 	for key, defaultValue := range envDefaults {
-		if os.Getenv(key) == "" {
+		if os.Getenv(key) == "" || defaultedEnv[key] {
+			defaultedEnv[key] = true
 			if err := os.Setenv(key, defaultValue); err != nil {
 				// Log the error but continue execution
 				// Note: We can't use logger here as it may not be initialized yet
@@ -3,8 +3,10 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime/debug"
@@ -12,8 +14,16 @@ import (
 	"testing"
 	"time"
 
+	"homeops-cli/cmd/plugins"
+	"homeops-cli/internal/common"
 	"homeops-cli/internal/config"
+	"homeops-cli/internal/constants"
+	"homeops-cli/internal/templates"
+	"homeops-cli/internal/testutil"
+	"homeops-cli/internal/versioncheck"
 
+	"charm.land/fang/v2"
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -234,6 +244,92 @@ func TestRunApp(t *testing.T) {
 		code := runApp(make(chan os.Signal, 1))
 		assert.Equal(t, 1, code)
 	})
+
+	t.Run("plugin exit status passes through silently", func(t *testing.T) {
+		signalNotifyFn = func(c chan<- os.Signal, sig ...os.Signal) {}
+		exitErr := &plugins.ExitError{Name: "ups", Code: 42}
+		executeRootCmdFn = func(cmd *cobra.Command) error {
+			return fmt.Errorf("wrapped: %w", exitErr)
+		}
+
+		assert.Equal(t, 42, runApp(make(chan os.Signal, 1)))
+		var out bytes.Buffer
+		redactingErrorHandler(&out, fang.Styles{}, exitErr)
+		assert.Empty(t, out.String())
+	})
+
+	t.Run("finished invocation is audited unless --no-audit", func(t *testing.T) {
+		var stderr bytes.Buffer
+		stderrWriter = &stderr
+		signalNotifyFn = func(c chan<- os.Signal, sig ...os.Signal) {}
+		var recorded []string
+		testutil.Swap(t, &auditRecordFn, func(cmd *cobra.Command, args []string, started time.Time, runErr error) error {
+			recorded = append(recorded, fmt.Sprintf("%s %v %v", cmd.CommandPath(), args, runErr))
+			return errors.New("disk full")
+		})
+		runWith := func(args ...string) int {
+			executeRootCmdFn = func(cmd *cobra.Command) error {
+				cmd.AddCommand(&cobra.Command{
+					Use:  "fake",
+					RunE: func(*cobra.Command, []string) error { return errors.New("boom") },
+				})
+				cmd.SetArgs(args)
+				cmd.SetOut(io.Discard)
+				cmd.SetErr(io.Discard)
+				return cmd.Execute()
+			}
+			return runApp(make(chan os.Signal, 1))
+		}
+
+		assert.Equal(t, 1, runWith("fake", "node-1"))
+		assert.Equal(t, []string{"homeops-cli fake [node-1] boom"}, recorded)
+		assert.Contains(t, stderr.String(), "audit log not written: disk full")
+
+		assert.Equal(t, 1, runWith("fake", "--no-audit"))
+		assert.Len(t, recorded, 1)
+	})
+
+	t.Run("long-running operations notify on completion", func(t *testing.T) {
+		var stderr bytes.Buffer
+		stderrWriter = &stderr
+		signalNotifyFn = func(c chan<- os.Signal, sig ...os.Signal) {}
+		testutil.Swap(t, &auditRecordFn, func(*cobra.Command, []string, time.Time, error) error { return nil })
+		t.Setenv("HOMEOPS_NOTIFY_WEBHOOK_URL", "https://ntfy.example/")
+		cfg := &config.Config{Notify: common.NotifyConfig{Webhook: true, Topic: "homeops"}}
+		t.Cleanup(config.SetForTesting(cfg))
+		var sent []string
+		testutil.Swap(t, &notifyFn, func(_ context.Context, n common.Notifier, note common.Notification) {
+			sent = append(sent, fmt.Sprintf("%s %s %q desktop=%v webhook=%s topic=%s", note.Operation, note.Status, note.Summary, n.Desktop, n.WebhookURL, n.Topic))
+		})
+		runWith := func(args ...string) int {
+			executeRootCmdFn = func(cmd *cobra.Command) error {
+				restoreAll, _, err := cmd.Find([]string{"volsync", "restore-all"})
+				require.NoError(t, err)
+				restoreAll.PreRunE = nil
+				restoreAll.RunE = func(*cobra.Command, []string) error { return errors.New("restore of media/jellyfin failed") }
+				cmd.AddCommand(&cobra.Command{Use: "fake", RunE: func(*cobra.Command, []string) error { return nil }})
+				cmd.SetArgs(args)
+				cmd.SetOut(io.Discard)
+				cmd.SetErr(io.Discard)
+				return cmd.Execute()
+			}
+			return runApp(make(chan os.Signal, 1))
+		}
+
+		assert.Equal(t, 1, runWith("volsync", "restore-all", "--previous", "2"))
+		assert.Equal(t, []string{`volsync restore-all failed "restore of media/jellyfin failed" desktop=false webhook=https://ntfy.example/ topic=homeops`}, sent)
+
+		assert.Equal(t, 1, runWith("volsync", "restore-all", "--previous", "2", "--no-notify"))
+		assert.Equal(t, 0, runWith("fake"))
+		assert.Len(t, sent, 1, "--no-notify and other commands stay quiet")
+
+		t.Cleanup(config.SetForTesting(&config.Config{}))
+		assert.Equal(t, 1, runWith("volsync", "restore-all", "--previous", "2"))
+		assert.Len(t, sent, 1, "nothing configured")
+		assert.Equal(t, 1, runWith("volsync", "restore-all", "--previous", "2", "--notify"))
+		require.Len(t, sent, 2)
+		assert.Equal(t, `volsync restore-all failed "restore of media/jellyfin failed" desktop=true webhook= topic=`, sent[1], "--notify falls back to the desktop")
+	})
 }
 
 func TestMenuGuardsPositionalCommands(t *testing.T) {
@@ -345,6 +441,38 @@ func TestRootCommandHonorsExplicitConfigErrors(t *testing.T) {
 	assert.True(t, config.IsExplicitLoadError(err))
 }
 
+func TestRedactingErrorHandlerMasksResolvedSecrets(t *testing.T) {
+	defer common.ResetSecretRegistryForTesting()()
+	common.RegisterSecret("Homelab/spice/password", "spice-Pa55w0rd-xyz")
+
+	var out bytes.Buffer
+	redactingErrorHandler(&out, fang.Styles{}, errors.New("remote-viewer rejected spice-Pa55w0rd-xyz"))
+	assert.NotContains(t, out.String(), "spice-Pa55w0rd-xyz")
+	assert.Contains(t, out.String(), "<redacted:Homelab/spice/password>")
+}
+
+func TestLogFormatFlagSwitchesTheFinalErrorToJSON(t *testing.T) {
+	config.ResetForTesting()
+	t.Cleanup(config.ResetForTesting)
+	t.Cleanup(func() { _ = common.SetGlobalLogFormat(common.LogFormatText) })
+
+	cmd := newRootCommand(context.Background())
+	require.NoError(t, cmd.ParseFlags([]string{"--log-format", "yaml"}))
+	assert.EqualError(t, cmd.PersistentPreRunE(cmd, nil), `invalid log format "yaml" (must be text or json)`)
+
+	cmd = newRootCommand(context.Background())
+	require.NoError(t, cmd.ParseFlags([]string{"--log-format", "json"}))
+	require.NoError(t, cmd.PersistentPreRunE(cmd, nil))
+	var stderr, out bytes.Buffer
+	testutil.Swap[io.Writer](t, &color.Error, &stderr)
+	redactingErrorHandler(&out, fang.Styles{}, errors.New("bootstrap failed"))
+	assert.Empty(t, out.String())
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(stderr.Bytes(), &entry), stderr.String())
+	assert.Equal(t, "error", entry["level"])
+	assert.Equal(t, "bootstrap failed", entry["message"])
+}
+
 func TestRootConfigFlagWinsAfterCommandTreeConstruction(t *testing.T) {
 	config.ResetForTesting()
 	t.Cleanup(config.ResetForTesting)
@@ -352,6 +480,11 @@ func TestRootConfigFlagWinsAfterCommandTreeConstruction(t *testing.T) {
 	t.Cleanup(func() { configPath = origConfigPath })
 	configPath = ""
 
+	// Version plans are repo-relative, so running from outside the checkout
+	// needs --root-dir.
+	repoRoot, err := common.FindRepoRoot(".")
+	require.NoError(t, err)
+	t.Cleanup(func() { common.SetRootDirOverride("") })
 	tempCWD := t.TempDir()
 	t.Chdir(tempCWD)
 	fixturePath := writeRootConfigFixture(t, t.TempDir(), "fixture-cluster", "fixture.k8s.test")
@@ -365,6 +498,7 @@ func TestRootConfigFlagWinsAfterCommandTreeConstruction(t *testing.T) {
 		"--node", "k8s-0",
 		"--mode", "init",
 		"--config", fixturePath,
+		"--root-dir", repoRoot,
 	})
 
 	require.NoError(t, cmd.Execute())
@@ -374,6 +508,32 @@ func TestRootConfigFlagWinsAfterCommandTreeConstruction(t *testing.T) {
 	assert.Equal(t, fixturePath, config.Get().Source)
 }
 
+func TestRepoRelativeCommandsRequireRepoRoot(t *testing.T) {
+	config.ResetForTesting()
+	t.Cleanup(config.ResetForTesting)
+	t.Cleanup(func() { common.SetRootDirOverride("") })
+	t.Setenv(constants.EnvHomeOpsRoot, "")
+	t.Chdir(t.TempDir())
+	fixturePath := writeRootConfigFixture(t, t.TempDir(), "fixture-cluster", "fixture.k8s.test")
+
+	run := func(args ...string) error {
+		cmd := newRootCommand(context.Background())
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+		cmd.SetArgs(append(args, "--config", fixturePath))
+		return cmd.Execute()
+	}
+
+	err := run("flatcar", "gen-kubeadm", "--node", "k8s-0", "--mode", "init")
+	require.ErrorIs(t, err, common.ErrRepoRootNotFound, "version plans are never silently replaced by built-in defaults")
+	assert.Contains(t, err.Error(), "pass --root-dir, or set HOMEOPS_ROOT")
+
+	err = run("version", "check", "--root-dir", filepath.Join(t.TempDir(), "missing"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `--root-dir "`)
+}
+
 func writeRootConfigFixture(t *testing.T, dir, clusterName, endpoint string) string {
 	t.Helper()
 	path := filepath.Join(dir, "homeops.yaml")
@@ -422,3 +582,63 @@ func TestApplyBuildInfoFallsBackToVCS(t *testing.T) {
 		assert.Equal(t, "2026-06-12", date)
 	})
 }
+
+func TestVersionCheckCommand(t *testing.T) {
+	testutil.Swap(t, &versionObserveFn, func(context.Context, string, ...versioncheck.Component) []versioncheck.Observation {
+		return []versioncheck.Observation{
+			{Component: versioncheck.Kubernetes, Path: "/repo/plan.yaml", Plan: "v1.36.1", Running: "v1.36.2", OverrideEnv: "KUBERNETES_VERSION"},
+			{Component: versioncheck.Talos, Plan: "v1.13.6", OverrideEnv: "TALOS_VERSION"},
+		}
+	})
+
+	run := func(args ...string) (string, error) {
+		cmd := newVersionCheckCommand()
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	testutil.Swap(t, &strictVersions, false)
+	out, err := run()
+	require.NoError(t, err)
+	assert.Contains(t, out, "MISMATCH (cluster newer)")
+	assert.Contains(t, out, "built-in pin")
+	assert.Contains(t, out, "the cluster is newer")
+
+	out, err = run("--output", "json")
+	require.NoError(t, err)
+	assert.Contains(t, out, `"newer": "cluster"`)
+
+	strictVersions = true
+	_, err = run()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--strict-versions")
+}
+
+func TestValidateCommand(t *testing.T) {
+	t.Cleanup(func() { common.SetRootDirOverride("") })
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "kubernetes", "apps"), 0o755))
+	common.SetRootDirOverride(root)
+
+	var gotRoot string
+	problems := []templates.TemplateProblem{{Template: "talos/controlplane.yaml", Check: templates.CheckYAML, Message: "yaml: line 4: found character that cannot start any token"}}
+	testutil.Swap(t, &validateTemplatesFn, func(rootDir string) ([]templates.TemplateProblem, error) {
+		gotRoot = rootDir
+		return problems, nil
+	})
+
+	out, err := testutil.ExecuteCommand(newValidateCommand(), "--output", "json")
+	assert.EqualError(t, err, "1 template problem(s)")
+	assert.Equal(t, root, gotRoot)
+	var decoded []templates.TemplateProblem
+	require.NoError(t, json.NewDecoder(strings.NewReader(out)).Decode(&decoded))
+	assert.Equal(t, problems, decoded)
+
+	problems = nil
+	out, err = testutil.ExecuteCommand(newValidateCommand(), "--output", "json")
+	require.NoError(t, err)
+	assert.Equal(t, "[]\n", out)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"homeops-cli/internal/common"
+	"homeops-cli/internal/templates"
+	"homeops-cli/internal/ui"
+)
+
+// validateTemplatesFn checks the embedded templates (swappable for tests).
+var validateTemplatesFn = templates.ValidateAll
+
+// newValidateCommand runs the same checks as the templates package's test
+// suite, so a rebuilt binary can be checked without the Go toolchain.
+func newValidateCommand() *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check every embedded template for errors",
+		Long: `Check the templates compiled into homeops-cli: every YAML template parses,
+op:// references are op://vault/item/field or op://vault/item/section/field,
+every talos/nodes patch merges against its base template as apply-node merges
+it, and the helmfile values template renders for every bootstrap release.
+
+The helmfile values check reads kubernetes/apps and is skipped outside the
+repository.`,
+		Example: `  homeops-cli validate
+  homeops-cli validate --output json`,
+		// Problems are a validation failure, not a usage error.
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ui.ValidateOutputFormat(output); err != nil {
+				return err
+			}
+			logger := common.NewColorLogger()
+			root, err := common.RepoRoot()
+			if errors.Is(err, common.ErrRepoRootNotFound) {
+				logger.Warn("Not in the home-ops repository; skipping the helmfile values check")
+				root = ""
+			} else if err != nil {
+				return err
+			}
+
+			problems, err := validateTemplatesFn(root)
+			if err != nil {
+				return err
+			}
+			if output == "json" {
+				rendered, err := ui.RenderJSON(append([]templates.TemplateProblem{}, problems...))
+				if err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintln(cmd.OutOrStdout(), rendered)
+			} else {
+				for _, problem := range problems {
+					logger.Error("%s", problem)
+				}
+			}
+			if len(problems) > 0 {
+				return fmt.Errorf("%d template problem(s)", len(problems))
+			}
+			if output != "json" {
+				logger.Success("all embedded templates are valid")
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table or json")
+	return cmd
+}